@@ -21,6 +21,21 @@ type rpcServer struct {
 func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.CreateResponse, error) {
 	s.log.Debug("Received Create request.")
 
+	// the backend only permits privileged containers over its local socket, so
+	// they can't be requested through the remote API either
+	if in.Privileged {
+		return nil, fmt.Errorf("privileged containers cannot be launched remotely")
+	}
+	if in.SecurityLabel != "" {
+		return nil, fmt.Errorf("security labels cannot be set remotely")
+	}
+
+	// images retrieved by the backend can't have their manifest validated here
+	// before the container is created, so they must be uploaded instead
+	if in.ImageUri != "" {
+		return nil, fmt.Errorf("creating from an image uri is not supported remotely, the image must be uploaded")
+	}
+
 	// unmarshal the image manifest, ensure its valid
 	var imageManifest *schema.ImageManifest
 	if err := json.Unmarshal(in.Manifest, &imageManifest); err != nil {
@@ -28,7 +43,15 @@ func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.Creat
 	}
 
 	// locally validate the manifest to gate remote vs local container functionality
-	if err := validateImageManifest(imageManifest); err != nil {
+	if len(in.PodManifest) > 0 {
+		var pod *schema.PodManifest
+		if err := json.Unmarshal(in.PodManifest, &pod); err != nil {
+			return nil, fmt.Errorf("invalid pod manifest: %v", err)
+		}
+		if err := validatePodManifest(imageManifest, pod); err != nil {
+			return nil, fmt.Errorf("pod manifest is not valid: %v", err)
+		}
+	} else if err := validateImageManifest(imageManifest); err != nil {
 		return nil, fmt.Errorf("image manifest is not valid: %v", err)
 	}
 
@@ -48,37 +71,56 @@ func (s *rpcServer) UploadImage(inStream pb.Kurma_UploadImageServer) error {
 	// and re-use it on the UploadImage call, not pulling it from the binary
 	// image.
 
-	packet, err := inStream.Recv()
-	if err != nil {
-		return err
-	}
-
 	outStream, err := s.client.UploadImage(inStream.Context())
 	if err != nil {
 		return err
 	}
 
-	r := pb.NewByteStreamReader(inStream, packet)
-	w := pb.NewByteStreamWriter(outStream, packet.StreamId)
-
-	if _, err := io.Copy(w, r); err != nil {
-		return fmt.Errorf("write error: %v", err)
+	// relay the acknowledgements back to the client as the chunks are relayed
+	// to the backend, which ends the stream once the container is created
+	errch := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := outStream.Recv()
+			if err == io.EOF {
+				errch <- nil
+				return
+			} else if err != nil {
+				errch <- err
+				return
+			}
+			if err := inStream.Send(ack); err != nil {
+				errch <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		chunk, err := inStream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// the client's stream was interrupted, so end the backend's stream
+			// without closing it cleanly to leave the upload resumable
+			return err
+		}
+		if err := outStream.Send(chunk); err != nil {
+			break
+		}
 	}
-	if _, err := outStream.CloseAndRecv(); err != nil {
+	if err := outStream.CloseSend(); err != nil {
 		return err
 	}
-	if err := inStream.SendAndClose(&pb.None{}); err != nil {
-		return err
-	}
-	return nil
+	return <-errch
 }
 
-func (s *rpcServer) Destroy(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
+func (s *rpcServer) Destroy(ctx context.Context, in *pb.DestroyRequest) (*pb.None, error) {
 	s.log.Debugf("Received container destroy request for %s", in.Uuid)
 	return s.client.Destroy(ctx, in)
 }
 
-func (s *rpcServer) List(ctx context.Context, in *pb.None) (*pb.ListResponse, error) {
+func (s *rpcServer) List(ctx context.Context, in *pb.ListRequest) (*pb.ListResponse, error) {
 	s.log.Debug("Received container list request")
 	return s.client.List(ctx, in)
 }
@@ -87,3 +129,8 @@ func (s *rpcServer) Get(ctx context.Context, in *pb.ContainerRequest) (*pb.Conta
 	s.log.Debug("Received container get request for %s", in.Uuid)
 	return s.client.Get(ctx, in)
 }
+
+func (s *rpcServer) Inspect(ctx context.Context, in *pb.ContainerRequest) (*pb.InspectResponse, error) {
+	s.log.Debugf("Received container inspect request for %s", in.Uuid)
+	return s.client.Inspect(ctx, in)
+}
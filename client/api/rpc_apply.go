@@ -0,0 +1,18 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Apply(ctx context.Context, in *pb.ApplyRequest) (*pb.ApplyResponse, error) {
+	s.log.Debugf("Received apply request for %d containers", len(in.Containers))
+
+	// applied containers are created from image uris, which are refused here
+	// as they are for Create
+	return nil, fmt.Errorf("specs cannot be applied remotely")
+}
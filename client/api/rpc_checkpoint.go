@@ -0,0 +1,18 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Checkpoint(ctx context.Context, in *pb.CheckpointRequest) (*pb.None, error) {
+	s.log.Debugf("Received checkpoint request for %s", in.Uuid)
+	return s.client.Checkpoint(ctx, in)
+}
+
+func (s *rpcServer) Restore(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
+	s.log.Debugf("Received restore request for %s", in.Uuid)
+	return s.client.Restore(ctx, in)
+}
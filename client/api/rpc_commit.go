@@ -0,0 +1,13 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Commit(ctx context.Context, in *pb.CommitRequest) (*pb.CommitResponse, error) {
+	s.log.Debugf("Received commit request for %s", in.Uuid)
+	return s.client.Commit(ctx, in)
+}
@@ -0,0 +1,31 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Events(in *pb.EventsRequest, stream pb.Kurma_EventsServer) error {
+	s.log.Debugf("Received events request for %q", in.Uuid)
+
+	outStream, err := s.client.Events(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
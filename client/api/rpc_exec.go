@@ -0,0 +1,56 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Exec(inStream pb.Kurma_ExecServer) error {
+	s.log.Debug("Received exec request")
+
+	// read the first request to make sure its real before connecting
+	req, err := inStream.Recv()
+	if err != nil {
+		return err
+	}
+
+	// create the outbound stream and pass along the initial request
+	outStream, err := s.client.Exec(inStream.Context())
+	if err != nil {
+		return err
+	}
+	if err := outStream.Send(req); err != nil {
+		return err
+	}
+
+	// relay the remaining requests to the backend
+	go func() {
+		defer outStream.CloseSend()
+		for {
+			r, err := inStream.Recv()
+			if err != nil {
+				return
+			}
+			if err := outStream.Send(r); err != nil {
+				return
+			}
+		}
+	}()
+
+	// relay the responses back to the client
+	for {
+		resp, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := inStream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) ExportImage(in *pb.ImageRequest, stream pb.Kurma_ExportImageServer) error {
+	s.log.Debugf("Received image export request for %s", in.Uri)
+
+	outStream, err := s.client.ExportImage(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	return forwardChunks(outStream, stream)
+}
+
+func (s *rpcServer) Export(in *pb.ContainerRequest, stream pb.Kurma_ExportServer) error {
+	s.log.Debugf("Received export request for %s", in.Uuid)
+
+	outStream, err := s.client.Export(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	return forwardChunks(outStream, stream)
+}
+
+// forwardChunks passes the chunks received from the server on to the client
+// until the server's stream ends.
+func forwardChunks(from pb.ByteStreamReceiver, to pb.ByteStreamSender) error {
+	for {
+		chunk, err := from.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := to.Send(chunk); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) GarbageCollect(ctx context.Context, in *pb.None) (*pb.GarbageCollectResponse, error) {
+	s.log.Debug("Received garbage collection request")
+	return s.client.GarbageCollect(ctx, in)
+}
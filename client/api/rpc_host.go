@@ -0,0 +1,68 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) HostStatus(ctx context.Context, in *pb.None) (*pb.HostStatusResponse, error) {
+	s.log.Debug("Received host status request")
+	return s.client.HostStatus(ctx, in)
+}
+
+func (s *rpcServer) HostInfo(ctx context.Context, in *pb.None) (*pb.HostInfoResponse, error) {
+	s.log.Debug("Received host info request")
+	return s.client.HostInfo(ctx, in)
+}
+
+func (s *rpcServer) HostStorage(ctx context.Context, in *pb.None) (*pb.HostStorageResponse, error) {
+	s.log.Debug("Received host storage request")
+	return s.client.HostStorage(ctx, in)
+}
+
+func (s *rpcServer) HostEncryption(ctx context.Context, in *pb.None) (*pb.HostEncryptionResponse, error) {
+	s.log.Debug("Received host encryption request")
+	return s.client.HostEncryption(ctx, in)
+}
+
+func (s *rpcServer) ListCrashes(ctx context.Context, in *pb.None) (*pb.ListCrashesResponse, error) {
+	s.log.Debug("Received list crashes request")
+	return s.client.ListCrashes(ctx, in)
+}
+
+func (s *rpcServer) GetCrash(ctx context.Context, in *pb.CrashRequest) (*pb.Crash, error) {
+	s.log.Debug("Received get crash request")
+	return s.client.GetCrash(ctx, in)
+}
+
+func (s *rpcServer) ReloadConfig(ctx context.Context, in *pb.None) (*pb.ReloadConfigResponse, error) {
+	s.log.Debug("Received reload config request")
+	return s.client.ReloadConfig(ctx, in)
+}
+
+func (s *rpcServer) StartConsole(ctx context.Context, in *pb.None) (*pb.ConsoleResponse, error) {
+	s.log.Debug("Received start console request")
+	return s.client.StartConsole(ctx, in)
+}
+
+func (s *rpcServer) UpgradeHost(ctx context.Context, in *pb.UpgradeHostRequest) (*pb.UpgradeHostResponse, error) {
+	s.log.Debug("Received upgrade host request")
+	return s.client.UpgradeHost(ctx, in)
+}
+
+func (s *rpcServer) UpdateStatus(ctx context.Context, in *pb.None) (*pb.UpdateStatusResponse, error) {
+	s.log.Debug("Received update status request")
+	return s.client.UpdateStatus(ctx, in)
+}
+
+func (s *rpcServer) UpdateHost(ctx context.Context, in *pb.UpdateHostRequest) (*pb.UpdateStatusResponse, error) {
+	s.log.Debug("Received update host request")
+	return s.client.UpdateHost(ctx, in)
+}
+
+func (s *rpcServer) AttestHost(ctx context.Context, in *pb.AttestRequest) (*pb.AttestResponse, error) {
+	s.log.Debug("Received attest host request")
+	return s.client.AttestHost(ctx, in)
+}
@@ -0,0 +1,19 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+// Info describes the backend, since it is the backend's API which is served.
+func (s *rpcServer) Info(ctx context.Context, in *pb.None) (*pb.InfoResponse, error) {
+	s.log.Debug("Received info request")
+	return s.client.Info(ctx, in)
+}
+
+func (s *rpcServer) ListNodes(ctx context.Context, in *pb.None) (*pb.ListNodesResponse, error) {
+	s.log.Debug("Received node list request")
+	return s.client.ListNodes(ctx, in)
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) AddJob(ctx context.Context, in *pb.AddJobRequest) (*pb.None, error) {
+	s.log.Debugf("Received job add request for %s", in.Name)
+
+	// jobs run images retrieved by the backend, which can't be validated here
+	// any more than those given to Create
+	return nil, fmt.Errorf("jobs cannot be added remotely")
+}
+
+func (s *rpcServer) ListJobs(ctx context.Context, in *pb.None) (*pb.ListJobsResponse, error) {
+	s.log.Debug("Received job list request")
+	return s.client.ListJobs(ctx, in)
+}
+
+func (s *rpcServer) RemoveJob(ctx context.Context, in *pb.JobRequest) (*pb.None, error) {
+	s.log.Debugf("Received job remove request for %s", in.Name)
+	return s.client.RemoveJob(ctx, in)
+}
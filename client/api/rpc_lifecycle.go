@@ -0,0 +1,28 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Stop(ctx context.Context, in *pb.StopRequest) (*pb.None, error) {
+	s.log.Debugf("Received stop request for %s", in.Uuid)
+	return s.client.Stop(ctx, in)
+}
+
+func (s *rpcServer) Start(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
+	s.log.Debugf("Received start request for %s", in.Uuid)
+	return s.client.Start(ctx, in)
+}
+
+func (s *rpcServer) Restart(ctx context.Context, in *pb.StopRequest) (*pb.None, error) {
+	s.log.Debugf("Received restart request for %s", in.Uuid)
+	return s.client.Restart(ctx, in)
+}
+
+func (s *rpcServer) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.WaitResponse, error) {
+	s.log.Debugf("Received wait request for %s", in.Uuid)
+	return s.client.Wait(ctx, in)
+}
@@ -0,0 +1,31 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Logs(in *pb.LogsRequest, stream pb.Kurma_LogsServer) error {
+	s.log.Debugf("Received logs request for %s", in.Uuid)
+
+	outStream, err := s.client.Logs(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
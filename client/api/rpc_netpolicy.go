@@ -0,0 +1,23 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) SetNetworkPolicy(ctx context.Context, in *pb.NetworkPolicy) (*pb.None, error) {
+	s.log.Debugf("Received network policy set request for %s", in.Name)
+	return s.client.SetNetworkPolicy(ctx, in)
+}
+
+func (s *rpcServer) ListNetworkPolicies(ctx context.Context, in *pb.None) (*pb.ListNetworkPoliciesResponse, error) {
+	s.log.Debug("Received network policy list request")
+	return s.client.ListNetworkPolicies(ctx, in)
+}
+
+func (s *rpcServer) RemoveNetworkPolicy(ctx context.Context, in *pb.NetworkPolicyRequest) (*pb.None, error) {
+	s.log.Debugf("Received network policy remove request for %s", in.Name)
+	return s.client.RemoveNetworkPolicy(ctx, in)
+}
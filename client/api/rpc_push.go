@@ -0,0 +1,30 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) PushImage(in *pb.PushRequest, stream pb.Kurma_PushImageServer) error {
+	s.log.Debugf("Received image push request for %s", in.Uri)
+
+	outStream, err := s.client.PushImage(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	for {
+		progress, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(progress); err != nil {
+			return err
+		}
+	}
+}
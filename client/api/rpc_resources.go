@@ -0,0 +1,13 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) UpdateResources(ctx context.Context, in *pb.UpdateResourcesRequest) (*pb.None, error) {
+	s.log.Debugf("Received resource update request for %s", in.Uuid)
+	return s.client.UpdateResources(ctx, in)
+}
@@ -0,0 +1,23 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) CreateSecret(ctx context.Context, in *pb.SecretRequest) (*pb.None, error) {
+	s.log.Debugf("Received secret create request for %s", in.Name)
+	return s.client.CreateSecret(ctx, in)
+}
+
+func (s *rpcServer) ListSecrets(ctx context.Context, in *pb.None) (*pb.ListSecretsResponse, error) {
+	s.log.Debug("Received secret list request")
+	return s.client.ListSecrets(ctx, in)
+}
+
+func (s *rpcServer) DeleteSecret(ctx context.Context, in *pb.SecretRequest) (*pb.None, error) {
+	s.log.Debugf("Received secret delete request for %s", in.Name)
+	return s.client.DeleteSecret(ctx, in)
+}
@@ -0,0 +1,13 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Shutdown(ctx context.Context, in *pb.ShutdownRequest) (*pb.None, error) {
+	s.log.Debugf("Received shutdown request, reboot: %t", in.Reboot)
+	return s.client.Shutdown(ctx, in)
+}
@@ -0,0 +1,37 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Stats(ctx context.Context, in *pb.ContainerRequest) (*pb.StatsResponse, error) {
+	s.log.Debugf("Received stats request for %s", in.Uuid)
+	return s.client.Stats(ctx, in)
+}
+
+func (s *rpcServer) StreamStats(in *pb.StatsRequest, stream pb.Kurma_StreamStatsServer) error {
+	s.log.Debugf("Received stats stream request for %s", in.Uuid)
+
+	outStream, err := s.client.StreamStats(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) CreateVolume(ctx context.Context, in *pb.VolumeRequest) (*pb.None, error) {
+	s.log.Debugf("Received volume create request for %s", in.Name)
+	return s.client.CreateVolume(ctx, in)
+}
+
+func (s *rpcServer) ListVolumes(ctx context.Context, in *pb.None) (*pb.ListVolumesResponse, error) {
+	s.log.Debug("Received volume list request")
+	return s.client.ListVolumes(ctx, in)
+}
+
+func (s *rpcServer) DeleteVolume(ctx context.Context, in *pb.VolumeRequest) (*pb.None, error) {
+	s.log.Debugf("Received volume delete request for %s", in.Name)
+	return s.client.DeleteVolume(ctx, in)
+}
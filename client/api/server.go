@@ -3,8 +3,6 @@
 package api
 
 import (
-	"net"
-
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/logray"
 	"google.golang.org/grpc"
@@ -14,6 +12,10 @@ import (
 // instantiating a new api.Server.
 type Options struct {
 	BindAddress string
+
+	// BackendEndpoint is the endpoint of the local Kurma API that requests are
+	// proxied to. It defaults to the local tcp listener.
+	BackendEndpoint string
 }
 
 // Server represents the process that acts as a daemon to receive container
@@ -29,6 +31,9 @@ func New(options *Options) *Server {
 	if options.BindAddress == "" {
 		options.BindAddress = ":12312"
 	}
+	if options.BackendEndpoint == "" {
+		options.BackendEndpoint = "tcp://127.0.0.1:12311"
+	}
 
 	s := &Server{
 		log:     logray.New(),
@@ -40,14 +45,14 @@ func New(options *Options) *Server {
 // Start begins the server. It will return an error if starting the Server
 // fails, or return nil on success.
 func (s *Server) Start() error {
-	l, err := net.Listen("tcp", s.options.BindAddress)
+	l, err := pb.Listen(s.options.BindAddress)
 	if err != nil {
 		return err
 	}
 	defer l.Close()
 
 	// create the client RPC connection to the host
-	conn, err := grpc.Dial("127.0.0.1:12311")
+	conn, err := pb.Dial(s.options.BackendEndpoint)
 	if err != nil {
 		return err
 	}
@@ -58,9 +63,9 @@ func (s *Server) Start() error {
 		client: pb.NewKurmaClient(conn),
 	}
 
-	// create the gRPC server and run
+	// create the gRPC server, which also serves older clients, and run
 	gs := grpc.NewServer()
-	pb.RegisterKurmaServer(gs, rpc)
+	pb.RegisterKurmaServerWithInterceptors(gs, rpc)
 	s.log.Debug("Server is ready")
 	gs.Serve(l)
 	return nil
@@ -7,16 +7,38 @@ import (
 
 	kschema "github.com/apcera/kurma/schema"
 	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
 )
 
 func validateImageManifest(imageManifest *schema.ImageManifest) error {
 	if imageManifest.App == nil {
 		return fmt.Errorf("the imageManifest must specify an App")
 	}
+	return validateApp(imageManifest.App)
+}
+
+// validatePodManifest validates each of the apps within the pod, falling back
+// to the image's App for any which don't specify their own.
+func validatePodManifest(imageManifest *schema.ImageManifest, pod *schema.PodManifest) error {
+	for _, ra := range pod.Apps {
+		app := ra.App
+		if app == nil {
+			app = imageManifest.App
+		}
+		if app == nil {
+			return fmt.Errorf("app %q must specify an App", ra.Name)
+		}
+		if err := validateApp(app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func validateApp(app *types.App) error {
 	// Reject any containers that request host privilege. This can only be started
 	// with the local API, not remote API.
-	if iso := imageManifest.App.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
+	if iso := app.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
 		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
 			if *piso {
 				return fmt.Errorf("host privileged containers cannot be launched remotely")
@@ -0,0 +1,48 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+func TestValidatePodManifest(t *testing.T) {
+	image := schema.BlankImageManifest()
+	image.Name = types.ACIdentifier("example.com/web")
+	image.App = &types.App{Exec: types.Exec{"/bin/web"}, User: "0", Group: "0"}
+
+	var privileged types.App
+	err := json.Unmarshal([]byte(`{
+		"exec": ["/bin/sh"], "user": "0", "group": "0",
+		"isolators": [{"name": "host/privileged", "value": true}]
+	}`), &privileged)
+	if err != nil {
+		t.Fatalf("Failed to parse the privileged app: %s", err)
+	}
+
+	tests := []struct {
+		desc  string
+		image *schema.ImageManifest
+		app   *types.App
+		valid bool
+	}{
+		{"the image's app", image, nil, true},
+		{"its own app", image, &types.App{Exec: types.Exec{"/bin/worker"}, User: "0", Group: "0"}, true},
+		{"no app", schema.BlankImageManifest(), nil, false},
+		{"a privileged app", image, &privileged, false},
+	}
+	for _, test := range tests {
+		pod := schema.BlankPodManifest()
+		pod.Apps = schema.AppList{{Name: types.ACName("app"), App: test.app}}
+		err := validatePodManifest(test.image, pod)
+		if test.valid && err != nil {
+			t.Fatalf("Expected the pod with %s to be valid; got %s", test.desc, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("Expected the pod with %s to be invalid", test.desc)
+		}
+	}
+}
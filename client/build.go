@@ -0,0 +1,273 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// buildChunkSize is the size of the chunks the build context is sent in.
+const buildChunkSize = 64 * 1024
+
+// ParseKurmafile parses a Kurmafile into the spec of the image it builds,
+// without the image's name. Each line holds an instruction, where lines ending
+// in a backslash continue onto the next, and blank lines and those starting
+// with # are ignored:
+//
+//	FROM URI            the base image, which must come first
+//	RUN COMMAND         runs the command with /bin/sh -c
+//	RUN ["ARG", ...]    runs the command given as a JSON array
+//	COPY SOURCE DEST    copies the path within the build context into the image
+//	LABEL NAME=VALUE... adds the labels to the image
+func ParseKurmafile(r io.Reader) (*pb.BuildSpec, error) {
+	spec := &pb.BuildSpec{Labels: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	var line string
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimSpace(scanner.Text())
+		if line == "" && (text == "" || strings.HasPrefix(text, "#")) {
+			continue
+		}
+		if strings.HasSuffix(text, "\\") {
+			line += strings.TrimSpace(strings.TrimSuffix(text, "\\")) + " "
+			continue
+		}
+		line += text
+		if err := parseInstruction(spec, line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		line = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if line != "" {
+		if err := parseInstruction(spec, line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	if spec.Base == "" {
+		return nil, fmt.Errorf("the Kurmafile has no FROM instruction")
+	}
+	if len(spec.Steps) == 0 {
+		return nil, fmt.Errorf("the Kurmafile has no RUN or COPY instructions")
+	}
+	return spec, nil
+}
+
+// parseInstruction adds the Kurmafile's instruction to the spec.
+func parseInstruction(spec *pb.BuildSpec, line string) error {
+	fields := strings.Fields(line)
+	keyword := strings.ToUpper(fields[0])
+	args := strings.TrimSpace(line[len(fields[0]):])
+	fields = fields[1:]
+
+	if keyword != "FROM" && spec.Base == "" {
+		return fmt.Errorf("%s comes before FROM", keyword)
+	}
+	switch keyword {
+	case "FROM":
+		if spec.Base != "" {
+			return fmt.Errorf("FROM may only be given once")
+		}
+		if len(fields) != 1 {
+			return fmt.Errorf("FROM takes the URI of the base image")
+		}
+		spec.Base = fields[0]
+
+	case "RUN":
+		if args == "" {
+			return fmt.Errorf("RUN takes a command")
+		}
+		command := []string{"/bin/sh", "-c", args}
+		if strings.HasPrefix(args, "[") {
+			command = nil
+			if err := json.Unmarshal([]byte(args), &command); err != nil {
+				return fmt.Errorf("invalid RUN command: %v", err)
+			}
+			if len(command) == 0 {
+				return fmt.Errorf("RUN takes a command")
+			}
+		}
+		spec.Steps = append(spec.Steps, &pb.BuildStep{Run: command})
+
+	case "COPY":
+		if len(fields) != 2 {
+			return fmt.Errorf("COPY takes a source and a destination")
+		}
+		if _, err := contextPath(fields[0]); err != nil {
+			return err
+		}
+		spec.Steps = append(spec.Steps, &pb.BuildStep{Source: fields[0], Destination: fields[1]})
+
+	case "LABEL":
+		if len(fields) == 0 {
+			return fmt.Errorf("LABEL takes NAME=VALUE pairs")
+		}
+		for _, f := range fields {
+			parts := strings.SplitN(f, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return fmt.Errorf("invalid label %q, expected NAME=VALUE", f)
+			}
+			spec.Labels[parts[0]] = parts[1]
+		}
+
+	default:
+		return fmt.Errorf("unknown instruction %q", fields[0])
+	}
+	return nil
+}
+
+// contextPath returns the cleaned path of a COPY source, which must be within
+// the build context.
+func contextPath(source string) (string, error) {
+	p := path.Clean(filepath.ToSlash(source))
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("COPY source %q is outside the build context", source)
+	}
+	return p, nil
+}
+
+// Build builds the image the spec describes on the server, and returns where it
+// is held in the server's image store. The sources of the spec's COPY steps are
+// sent from the build context directory. The build's progress and the output of
+// the commands it runs are written to stdout and stderr.
+func (c *Client) Build(ctx context.Context, spec *pb.BuildSpec, dir string, stdout, stderr io.Writer) (*pb.CommitResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&pb.BuildRequest{Spec: spec}); err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriterSize(&buildContextWriter{stream: stream}, buildChunkSize)
+	if err := writeBuildContext(w, dir, spec.Steps); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the build ended without producing an image")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Output) > 0 && stdout != nil {
+			stdout.Write(resp.Output)
+		}
+		if len(resp.Stderr) > 0 && stderr != nil {
+			stderr.Write(resp.Stderr)
+		}
+		if resp.Image != nil {
+			return resp.Image, nil
+		}
+	}
+}
+
+// writeBuildContext writes a tar archive of the sources the steps copy from the
+// directory to w, with their names relative to it.
+func writeBuildContext(w io.Writer, dir string, steps []*pb.BuildStep) error {
+	tw := tar.NewWriter(w)
+	written := make(map[string]bool)
+	for _, step := range steps {
+		if step.Run != nil {
+			continue
+		}
+		source, err := contextPath(step.Source)
+		if err != nil {
+			return err
+		}
+		err = filepath.Walk(filepath.Join(dir, filepath.FromSlash(source)), func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if written[name] {
+				return nil
+			}
+			written[name] = true
+			return writeContextEntry(tw, p, name, fi)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive %q: %v", step.Source, err)
+		}
+	}
+	return tw.Close()
+}
+
+// writeContextEntry writes the file at the path to the build context archive
+// under the name.
+func writeContextEntry(tw *tar.Writer, p, name string, fi os.FileInfo) error {
+	var link string
+	switch {
+	case fi.Mode().IsRegular(), fi.IsDir():
+	case fi.Mode()&os.ModeSymlink != 0:
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s is not a file, directory, or symlink", p)
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// buildContextWriter sends the bytes written to it as the build context.
+type buildContextWriter struct {
+	stream pb.Kurma_BuildClient
+}
+
+func (w *buildContextWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	if err := w.stream.Send(&pb.BuildRequest{Context: b}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
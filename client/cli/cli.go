@@ -5,6 +5,7 @@ package cli
 import (
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 
 	// Include so godep properly finds it.
@@ -26,9 +27,27 @@ var (
 	// ShowVersion triggers the 'version' command when called.
 	ShowVersion bool
 
-	// KurmaHost is the host (ip or name) of the Kurma server we're talking to.
+	// KurmaHost is the host (ip or name) of the Kurma server we're talking to,
+	// or an endpoint such as unix:///var/kurma/kurma.sock or tcp://host:port.
+	// It defaults to the KURMA_HOST environment variable when set.
 	KurmaHost string
 
+	// Node is the name of the host in the cluster of the Kurma server to send
+	// commands to, rather than the server itself. It defaults to the
+	// KURMA_NODE environment variable when set.
+	Node string
+
+	// TLSCert and TLSKey are the client certificate and key presented to the
+	// Kurma server, and TLSCACert is the CA its certificate is verified against.
+	// The connection uses TLS when any of them are set.
+	TLSCert   string
+	TLSKey    string
+	TLSCACert string
+
+	// Token authenticates the client to a Kurma server which requires it. It
+	// defaults to the KURMA_TOKEN environment variable when set.
+	Token string
+
 	// global map of defined commands
 	apcCommands = make(map[string]cmdDef)
 	// global map of command aliases
@@ -67,6 +86,15 @@ func DefineCommand(name string, f flagParser, i impl, c CliWrapper, h interface{
 	apcCommands[name] = cmdDef{Name: name, flagParser: f, impl: i, cli: c, Help: h}
 }
 
+// DefineLocalCommand defines a command which runs without connecting to the
+// Kurma server, such as help, so its Cmd has no Client.
+func DefineLocalCommand(name string, f flagParser, i impl, c CliWrapper, h interface{}) {
+	DefineCommand(name, f, i, c, h)
+	def := apcCommands[name]
+	def.local = true
+	apcCommands[name] = def
+}
+
 // DefineAlias allows a defined command to be invoked using an alternate name
 func DefineAlias(orig string, alternates ...string) {
 	origCmd, ok := apcCommands[orig]
@@ -105,6 +133,17 @@ func addGlobalFlags(f *flag.FlagSet) {
 	f.BoolVar(&ShowVersion, "version", false, "")
 	f.BoolVar(&ShowVersion, "ver", false, "")
 	f.BoolVar(&ShowVersion, "v", false, "")
-	f.StringVar(&KurmaHost, "host", defaultKurmaIP, "")
-	f.StringVar(&KurmaHost, "H", defaultKurmaIP, "")
+	defaultHost := defaultKurmaIP
+	if env := os.Getenv("KURMA_HOST"); env != "" {
+		defaultHost = env
+	}
+	f.StringVar(&KurmaHost, "host", defaultHost, "")
+	f.StringVar(&KurmaHost, "H", defaultHost, "")
+	f.StringVar(&Node, "node", os.Getenv("KURMA_NODE"), "")
+	f.StringVar(&TLSCert, "tlscert", "", "")
+	f.StringVar(&TLSKey, "tlskey", "", "")
+	f.StringVar(&TLSCACert, "tlscacert", "", "")
+	f.StringVar(&Token, "token", os.Getenv("KURMA_TOKEN"), "")
+	f.StringVar(&Output, "output", OutputTable, "")
+	f.StringVar(&Output, "o", OutputTable, "")
 }
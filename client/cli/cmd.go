@@ -20,6 +20,16 @@ var PanicStack []byte
 var PanicError = errors.New("Command panicked!")
 var ErrIntResponseQuit = errors.New("User requested Quit at selection")
 
+// ExitCodeError is returned by commands that need the CLI to exit with a
+// specific exit code, such as when relaying the exit status of a process.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exited with status %d", e.Code)
+}
+
 type cmdDef struct {
 	// Name of the command, as invoked on the command line.
 	Name string
@@ -30,6 +40,7 @@ type cmdDef struct {
 	flagParser flagParser // func allowing the command to interpret flags
 	impl       impl       // the command's implementation
 	cli        CliWrapper // command line wrapper around impl
+	local      bool       // whether the command runs without the server
 }
 
 type Cmd struct {
@@ -53,6 +64,10 @@ type Cmd struct {
 	// Kurma server.
 	Client pb.KurmaClient
 
+	// Local is set by the flags of commands which can also run without the
+	// server, so that the CLI doesn't connect to it.
+	Local bool
+
 	errChan  chan error // Receives errors returned during execution
 	def      *cmdDef    // Command definition
 	origArgs []string   // Used for string output
@@ -89,6 +104,11 @@ func (c *Cmd) IsDefined() bool {
 	return c.def != nil
 }
 
+// IsLocal returns whether the command runs without connecting to the server.
+func (c *Cmd) IsLocal() bool {
+	return c.def != nil && (c.def.local || c.Local)
+}
+
 func (c *Cmd) PrintHelp() {
 	if c.IsDefined() {
 		c.def.PrintHelp(os.Stderr)
@@ -163,23 +183,24 @@ func (c *Cmd) parseArgs(originalArgs, lowerArgs []string) (err error) {
 	for i := 0; i < len(originalArgs); i++ {
 		a := originalArgs[i]
 
-		// Ensure the argument has at least one character. If this it isn't, add it
-		// to the Args so that the command can handle this oddity. This can happen
-		// if you do: apc app create ""
-		if len(a) < 1 {
-			c.Args = append(c.Args, a)
-			continue
-		}
-
 		// If we hit "--"" in the args, then break. Anything after this shouldn't be parsed
-		// Only stored so we can use it within a specific command.
+		// Only stored so we can use it within a specific command, including any
+		// further "--" or empty arguments.
 		// NOTE: this does not support quoted strings as arguments in tests!
+		if subFlags {
+			c.RawSubFlags = append(c.RawSubFlags, a)
+			continue
+		}
 		if a == "--" {
 			subFlags = true
 			continue
 		}
-		if subFlags {
-			c.RawSubFlags = append(c.RawSubFlags, a)
+
+		// Ensure the argument has at least one character. If this it isn't, add it
+		// to the Args so that the command can handle this oddity. This can happen
+		// if you do: apc app create ""
+		if len(a) < 1 {
+			c.Args = append(c.Args, a)
 			continue
 		}
 
@@ -217,6 +238,9 @@ func (c *Cmd) parseArgs(originalArgs, lowerArgs []string) (err error) {
 	if err := c.Flags.Parse(flagArgs); err != nil {
 		return err
 	}
+	if err := SetOutput(Output); err != nil {
+		return err
+	}
 	// Validate the args using the ValidatedInput function.
 	if c.ValidatedInput != nil {
 		return c.ValidatedInput.ParseArgs(c.Args)
@@ -403,10 +427,15 @@ func (c *cmdDef) PrintHelp(w io.Writer) {
 	}
 
 	var msg string
-	switch h := c.Help.(type) {
-	case string:
-		msg = h
-	default:
+	if h := c.help(); h != nil {
+		msg = h.Text
+		if len(h.Examples) > 0 {
+			msg = strings.TrimRight(msg, "\n") + "\n\nExamples:\n"
+			for _, example := range h.Examples {
+				msg += "  " + example + "\n"
+			}
+		}
+	} else {
 		msg = "Warning: unable to interpret help for command: " + c.Name
 	}
 
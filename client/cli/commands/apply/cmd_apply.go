@@ -0,0 +1,280 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package apply
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/kurma/client/cli/commands/create"
+	"github.com/apcera/termtables"
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+const applyHelp = `
+Usage: kurma-cli apply [--dry-run] -f FILE
+
+Makes the containers on the host match those described by the spec file, or
+by stdin if the file is "-". Each container in the spec is created if it is
+missing, and is replaced if its spec has changed since it was created. If its
+apps have exited or were stopped, they are started again. Containers created
+by an earlier apply which are no longer in the spec are destroyed. Containers
+created any other way are left alone, and can't share a name with one in the
+spec.
+
+The spec file is JSON, with a list of containers:
+
+  {
+    "containers": [
+      {
+        "name": "web",
+        "image": "example.com/web:1.0",
+        "publish": ["8080:80"],
+        "volume": ["static:/srv/static:ro"],
+        "label": {"tier": "frontend"},
+        "restart": "always"
+      }
+    ]
+  }
+
+Each container must have a name and the image, which the server retrieves.
+Its other keys are the create command's options, without the dashes. Options
+which may be given multiple times take a list, and those taking NAME=VALUE
+take an object. A pod manifest's path is relative to the spec file. Resource
+limits are set by the isolators of the image's apps, or of those in the pod.
+
+Images are only retrieved again when a container's spec changes, so a tag
+which has moved to a new image is not noticed.
+
+Options:
+  -f, --file   The spec file to apply.
+  --dry-run    Show the changes which are needed without making them.
+`
+
+var (
+	specFile string
+	dryRun   bool
+)
+
+func init() {
+	cli.DefineCommand("apply", parseApplyFlags, apply, cliApply, &cli.Help{
+		Summary: "Make the containers match a spec file",
+		Text:    applyHelp,
+		Examples: []string{
+			"kurma-cli apply -f containers.json",
+			"kurma-cli apply --dry-run -f containers.json",
+		},
+		Args: cli.ArgsFile,
+	})
+}
+
+func parseApplyFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&specFile, "file", "", "")
+	cmd.Flags.StringVar(&specFile, "f", "", "")
+	cmd.Flags.BoolVar(&dryRun, "dry-run", false, "")
+}
+
+func cliApply(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 || specFile == "" {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+// changeSummary is the change made to a container as it is output.
+type changeSummary struct {
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Container string `json:"container,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func apply(cmd *cli.Cmd) error {
+	reqs, err := readSpec(specFile)
+	if err != nil {
+		return err
+	}
+	changes, err := client.New(cmd.Client).Apply(context.Background(), reqs, dryRun)
+	if err != nil {
+		return err
+	}
+
+	summaries := make([]*changeSummary, len(changes))
+	failed := 0
+	for i, c := range changes {
+		summaries[i] = &changeSummary{
+			Name:      c.Name,
+			Action:    strings.ToLower(c.Action.String()),
+			Container: c.Uuid,
+			Error:     c.Error,
+		}
+		if c.Error != "" {
+			failed++
+		}
+	}
+	err = cli.Render(summaries, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Action", "Container", "Error")
+		for _, s := range summaries {
+			table.AddRow(s.Name, s.Action, s.Container, s.Error)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to apply %d of the containers", failed)
+	}
+	return nil
+}
+
+// readSpec reads the spec file, returning the request for each container.
+func readSpec(path string) ([]*pb.CreateRequest, error) {
+	var r io.Reader = os.Stdin
+	dir := "."
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+		dir = filepath.Dir(path)
+	}
+
+	var spec struct {
+		Containers []map[string]interface{} `json:"containers"`
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid spec file: %v", err)
+	}
+
+	reqs := make([]*pb.CreateRequest, len(spec.Containers))
+	for i, c := range spec.Containers {
+		req, err := specRequest(c, dir)
+		if err != nil {
+			if name, ok := c["name"].(string); ok && name != "" {
+				return nil, fmt.Errorf("container %q: %v", name, err)
+			}
+			return nil, fmt.Errorf("container %d: %v", i+1, err)
+		}
+		reqs[i] = req
+	}
+	return reqs, nil
+}
+
+// specRequest returns the request for a container in a spec file, by setting
+// the create command's options from its keys.
+func specRequest(spec map[string]interface{}, dir string) (*pb.CreateRequest, error) {
+	image, _ := spec["image"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("the image must be given")
+	}
+	if _, err := os.Stat(image); err == nil || create.IsLocalPath(image) {
+		return nil, fmt.Errorf("only images the server retrieves may be applied, not local files")
+	}
+
+	create.ResetContainerFlags()
+	flags := flag.NewFlagSet("spec", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	create.ParseContainerFlags(&cli.Cmd{Flags: flags})
+
+	keys := make([]string, 0, len(spec))
+	for key := range spec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "image" {
+			continue
+		}
+		if flags.Lookup(key) == nil {
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+		values, err := specValues(spec[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		for _, value := range values {
+			if key == "pod" && !filepath.IsAbs(value) {
+				value = filepath.Join(dir, value)
+			}
+			if err := flags.Set(key, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", key, err)
+			}
+		}
+	}
+
+	req, err := create.ImageRequest(image)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContainerName == "" {
+		return nil, fmt.Errorf("the name must be given")
+	}
+	return req, nil
+}
+
+// specValues converts the value of an option in a spec file to the values it
+// is set to. A list sets the option once for each of its items, and an object
+// sets it to NAME=VALUE for each of its keys.
+func specValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, err := specScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		return values, nil
+	case map[string]interface{}:
+		values := make([]string, 0, len(v))
+		for name, item := range v {
+			s, err := specScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, name+"="+s)
+		}
+		sort.Strings(values)
+		return values, nil
+	}
+	s, err := specScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// specScalar converts a string, number, or boolean from a spec file to the
+// string it is given as on the command line.
+func specScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("expected a string, number, or boolean")
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package attach
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const attachHelp = `
+Usage: kurma-cli attach [--detach-keys KEYS] CONTAINER
+
+Attaches the local terminal to the console of a running container. The app
+must have been started with the kurma/console isolator.
+
+Options:
+  --detach-keys KEYS   The key sequence used to detach from the console,
+                       given as a comma separated list of keys such as
+                       "ctrl-p,ctrl-q" (the default).
+`
+
+var (
+	detachKeys string
+)
+
+func init() {
+	cli.DefineCommand("attach", parseFlags, attach, cliAttach, &cli.Help{
+		Summary: "Attach to the console of a running container",
+		Text:    attachHelp,
+		Examples: []string{
+			"kurma-cli attach web",
+			"kurma-cli attach --detach-keys ctrl-x web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&detachKeys, "detach-keys", "ctrl-p,ctrl-q", "")
+}
+
+func cliAttach(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func attach(cmd *cli.Cmd) error {
+	keys, err := parseDetachKeys(detachKeys)
+	if err != nil {
+		return err
+	}
+
+	req := &pb.AttachRequest{Uuid: cmd.Args[0]}
+
+	// Set the local terminal in raw mode to turn off buffering and local
+	// echo. Also defers setting it back to normal for when the call is done.
+	termios, err := raw.MakeRaw(os.Stdin.Fd())
+	if err != nil {
+		return err
+	}
+	defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+
+	if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+		req.Rows, req.Cols = uint32(rows), uint32(cols)
+	}
+
+	stream, err := cmd.Client.Attach(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(req); err != nil {
+		return err
+	}
+
+	// Sends may come from multiple goroutines, so serialize them.
+	var sendLock sync.Mutex
+	send := func(r *pb.AttachRequest) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(r)
+	}
+
+	// Pass along any changes to the window size.
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for _ = range winch {
+			if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+				send(&pb.AttachRequest{Rows: uint32(rows), Cols: uint32(cols)})
+			}
+		}
+	}()
+
+	// Stream stdin up to the console, watching for the detach sequence.
+	detached := make(chan struct{})
+	go func() {
+		defer close(detached)
+		buf := make([]byte, 4096)
+		matched := 0
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+
+			var out []byte
+			for _, b := range buf[:n] {
+				if b == keys[matched] {
+					matched++
+					if matched == len(keys) {
+						return
+					}
+					continue
+				}
+				// the sequence was broken, so pass along what was held back
+				out = append(out, keys[:matched]...)
+				matched = 0
+				if b == keys[0] {
+					matched = 1
+					continue
+				}
+				out = append(out, b)
+			}
+			if len(out) > 0 {
+				if err := send(&pb.AttachRequest{Stdin: out}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Stream the console output back until the console is closed.
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				recvErr <- nil
+				return
+			}
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			os.Stdout.Write(resp.Output)
+		}
+	}()
+
+	select {
+	case <-detached:
+		sendLock.Lock()
+		stream.CloseSend()
+		sendLock.Unlock()
+		fmt.Fprint(os.Stderr, "\r\n")
+		return nil
+	case err := <-recvErr:
+		return err
+	}
+}
+
+// parseDetachKeys converts a comma separated list of keys, such as
+// "ctrl-p,ctrl-q", into the byte sequence that will be read from the terminal.
+func parseDetachKeys(s string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(s, ",") {
+		key = strings.TrimSpace(key)
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case strings.HasPrefix(key, "ctrl-") && len(key) == 6:
+			c := key[5]
+			if c >= 'a' && c <= 'z' {
+				keys = append(keys, c-'a'+1)
+			} else if c >= '@' && c <= '_' {
+				keys = append(keys, c-'@')
+			} else {
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+		default:
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("a detach key sequence must be specified")
+	}
+	return keys, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const buildHelp = `
+Usage: kurma-cli build [-f KURMAFILE] [--label NAME=VALUE]... [--insecure]
+                       NAME [DIRECTORY]
+
+Builds an image named NAME on the host from the steps of a Kurmafile, which is
+read from the build context DIRECTORY, or the current directory if none is
+given. Each RUN step runs its command within a container created from the image
+so far, and each COPY step copies a path from the build context into the image.
+The image is held in the host's image store under its name, followed by its
+version label if it has one, and containers are created from it by giving that
+instead of an image file or URI.
+
+A Kurmafile holds an instruction on each line:
+
+  FROM URI            The base image, which must come first.
+  RUN COMMAND         Run the command with /bin/sh -c.
+  RUN ["ARG", ...]    Run the command given as a JSON array.
+  COPY SOURCE DEST    Copy the path within the build context into the image. A
+                      directory's contents are copied into DEST, and a file is
+                      copied into DEST if it ends with a slash.
+  LABEL NAME=VALUE... Add the labels to the image's manifest.
+
+Options:
+  -f, --file KURMAFILE   Read the Kurmafile from the path, rather than the
+                         Kurmafile within the build context.
+  --label                Add the label to the image's manifest, replacing any
+                         the Kurmafile gives of the same name. May be given
+                         multiple times.
+  --insecure             Skip verifying the signature of the base image.
+`
+
+var (
+	kurmafile string
+	labels    cli.LabelFlags
+	insecure  bool
+)
+
+func init() {
+	cli.DefineCommand("build", parseFlags, build, cliBuild, &cli.Help{
+		Summary: "Build an image from a Kurmafile",
+		Text:    buildHelp,
+		Examples: []string{
+			"kurma-cli build example.com/web",
+			"kurma-cli build -f web.kurmafile --label version=1.2 example.com/web ./web",
+			"kurma-cli create example.com/web:1.2",
+		},
+		Args: cli.ArgsFile,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&kurmafile, "file", "", "")
+	cmd.Flags.StringVar(&kurmafile, "f", "", "")
+	cmd.Flags.Var(&labels, "label", "")
+	cmd.Flags.BoolVar(&insecure, "insecure", false, "")
+}
+
+func cliBuild(cmd *cli.Cmd) error {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func build(cmd *cli.Cmd) error {
+	dir := "."
+	if len(cmd.Args) == 2 {
+		dir = cmd.Args[1]
+	}
+	path := kurmafile
+	if path == "" {
+		path = filepath.Join(dir, "Kurmafile")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	spec, err := client.ParseKurmafile(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	spec.Name = cmd.Args[0]
+	spec.Insecure = insecure
+	for name, value := range labels {
+		spec.Labels[name] = value
+	}
+
+	resp, err := client.New(cmd.Client).Build(context.Background(), spec, dir, os.Stderr, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return cli.Render(resp, func() error {
+		fmt.Printf("Built %s (%s)\n", resp.Uri, resp.ImageHash[:len("sha512-")+32])
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package checkpoint
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const checkpointHelp = `
+Usage: kurma-cli checkpoint [--leave-running] CONTAINER
+
+Checkpoints a running container with CRIU. Its processes are dumped into the
+container's directory and then stopped, leaving the container checkpointed until
+it is restored with "kurma-cli restore". To restore it on another host, copy the
+container's directory to the other host's pods directory.
+
+Options:
+  --leave-running   Leave the container's processes running after they're
+                    dumped.
+`
+
+var (
+	leaveRunning bool
+)
+
+func init() {
+	cli.DefineCommand("checkpoint", parseFlags, checkpoint, cliCheckpoint, &cli.Help{
+		Summary: "Checkpoint a running container with CRIU",
+		Text:    checkpointHelp,
+		Examples: []string{
+			"kurma-cli checkpoint web",
+			"kurma-cli checkpoint --leave-running web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&leaveRunning, "leave-running", false, "")
+}
+
+func cliCheckpoint(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func checkpoint(cmd *cli.Cmd) error {
+	req := &pb.CheckpointRequest{
+		Uuid:         cmd.Args[0],
+		LeaveRunning: leaveRunning,
+	}
+
+	if _, err := cmd.Client.Checkpoint(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Checkpointed container %s\n", cmd.Args[0])
+	return nil
+}
@@ -3,9 +3,39 @@
 package commands
 
 import (
+	_ "github.com/apcera/kurma/client/cli/commands/apply"
+	_ "github.com/apcera/kurma/client/cli/commands/attach"
+	_ "github.com/apcera/kurma/client/cli/commands/build"
+	_ "github.com/apcera/kurma/client/cli/commands/checkpoint"
+	_ "github.com/apcera/kurma/client/cli/commands/commit"
+	_ "github.com/apcera/kurma/client/cli/commands/completion"
 	_ "github.com/apcera/kurma/client/cli/commands/create"
+	_ "github.com/apcera/kurma/client/cli/commands/destroy"
 	_ "github.com/apcera/kurma/client/cli/commands/enter"
+	_ "github.com/apcera/kurma/client/cli/commands/events"
+	_ "github.com/apcera/kurma/client/cli/commands/exec"
+	_ "github.com/apcera/kurma/client/cli/commands/export"
+	_ "github.com/apcera/kurma/client/cli/commands/gc"
+	_ "github.com/apcera/kurma/client/cli/commands/help"
+	_ "github.com/apcera/kurma/client/cli/commands/host"
+	_ "github.com/apcera/kurma/client/cli/commands/image"
+	_ "github.com/apcera/kurma/client/cli/commands/info"
+	_ "github.com/apcera/kurma/client/cli/commands/inspect"
+	_ "github.com/apcera/kurma/client/cli/commands/jobs"
 	_ "github.com/apcera/kurma/client/cli/commands/list"
+	_ "github.com/apcera/kurma/client/cli/commands/logs"
+	_ "github.com/apcera/kurma/client/cli/commands/netpolicy"
+	_ "github.com/apcera/kurma/client/cli/commands/nodes"
+	_ "github.com/apcera/kurma/client/cli/commands/restart"
+	_ "github.com/apcera/kurma/client/cli/commands/restore"
+	_ "github.com/apcera/kurma/client/cli/commands/run"
+	_ "github.com/apcera/kurma/client/cli/commands/secrets"
 	_ "github.com/apcera/kurma/client/cli/commands/show"
+	_ "github.com/apcera/kurma/client/cli/commands/start"
+	_ "github.com/apcera/kurma/client/cli/commands/stats"
 	_ "github.com/apcera/kurma/client/cli/commands/stop"
+	_ "github.com/apcera/kurma/client/cli/commands/update"
+	_ "github.com/apcera/kurma/client/cli/commands/version"
+	_ "github.com/apcera/kurma/client/cli/commands/volume"
+	_ "github.com/apcera/kurma/client/cli/commands/wait"
 )
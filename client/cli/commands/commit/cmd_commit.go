@@ -0,0 +1,67 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package commit
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const commitHelp = `
+Usage: kurma-cli commit [--label NAME=VALUE]... CONTAINER NAME
+
+Creates an image from the container's filesystem as it currently is, with the
+manifest of the image the container was created from renamed to NAME. The image
+is held in the host's image store under its name, followed by its version label
+if it has one, and containers are created from it by giving that instead of an
+image file or URI.
+
+Files being written within the container as it is committed may be captured
+partway through the change, so the container should be stopped or idle.
+
+Options:
+  --label   Add the label to the image's manifest, replacing any label of the
+            same name, such as --label version=debug. May be given multiple
+            times.
+`
+
+var (
+	labels cli.LabelFlags
+)
+
+func init() {
+	cli.DefineCommand("commit", parseFlags, commit, cliCommit, &cli.Help{
+		Summary: "Create an image from a container's filesystem",
+		Text:    commitHelp,
+		Examples: []string{
+			"kurma-cli commit --label version=debug web example.com/web",
+			"kurma-cli create example.com/web:debug",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.Var(&labels, "label", "")
+}
+
+func cliCommit(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func commit(cmd *cli.Cmd) error {
+	resp, err := client.New(cmd.Client).Commit(context.Background(), cmd.Args[0], cmd.Args[1], labels)
+	if err != nil {
+		return err
+	}
+	return cli.Render(resp, func() error {
+		fmt.Printf("Committed %s as %s (%s)\n", cmd.Args[0], resp.Uri, resp.ImageHash[:len("sha512-")+32])
+		return nil
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package completion
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+
+	"golang.org/x/net/context"
+)
+
+const completionHelp = `
+Usage: kurma-cli completion bash|zsh
+
+Outputs the script which completes kurma-cli's commands, options, and
+arguments in the shell. Containers and volumes are completed by their names
+from the server, which is found by $KURMA_HOST, and authenticated with by
+$KURMA_TOKEN, if they're set.
+`
+
+// listTimeout is how long completion waits to connect to the server, and for it
+// to list containers or volumes, before giving up on completing them.
+const listTimeout = 2 * time.Second
+
+func init() {
+	cli.DefineLocalCommand("completion", parseFlags, completion, cliCompletion, &cli.Help{
+		Summary: "Output the shell completion script for bash or zsh",
+		Text:    completionHelp,
+		Examples: []string{
+			"# complete kurma-cli in the current bash session",
+			"source <(kurma-cli completion bash)",
+			"# complete kurma-cli in zsh, from a directory in $fpath",
+			"kurma-cli completion zsh > ~/.zsh/completion/_kurma-cli",
+		},
+	})
+
+	// __complete is called by the completion scripts with the words on the
+	// command line after --, and prints the candidates for the last of them. It
+	// only connects to the server when completing containers or volumes.
+	cli.DefineLocalCommand("__complete", parseFlags, complete, cliComplete, nil)
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliCompletion(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 || (cmd.Args[0] != "bash" && cmd.Args[0] != "zsh") {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func completion(cmd *cli.Cmd) error {
+	if cmd.Args[0] == "bash" {
+		fmt.Print(bashCompletion)
+	} else {
+		fmt.Print(zshCompletion)
+	}
+	return nil
+}
+
+func cliComplete(cmd *cli.Cmd) error {
+	return cmd.Run()
+}
+
+func complete(cmd *cli.Cmd) error {
+	candidates, files := cli.Complete(cmd.RawSubFlags, func(kind cli.ArgKind) []string {
+		return list(kind)
+	})
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+	if files {
+		fmt.Println(filesDirective)
+	}
+	return nil
+}
+
+// list returns the names of the server's containers, volumes, secrets, or
+// jobs, or nothing if the server can't be reached in time.
+func list(kind cli.ArgKind) []string {
+	client, err := cli.Dial(listTimeout)
+	if err != nil {
+		return nil
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	var names []string
+	switch kind {
+	case cli.ArgsContainer:
+		containers, err := client.List(ctx)
+		if err != nil {
+			return nil
+		}
+		for _, c := range containers {
+			if c.Name != "" {
+				names = append(names, c.Name)
+			} else {
+				names = append(names, c.Uuid)
+			}
+		}
+	case cli.ArgsVolume:
+		volumes, err := client.ListVolumes(ctx)
+		if err != nil {
+			return nil
+		}
+		names = volumes
+	case cli.ArgsSecret:
+		secrets, err := client.ListSecrets(ctx)
+		if err != nil {
+			return nil
+		}
+		names = secrets
+	case cli.ArgsJob:
+		jobs, err := client.ListJobs(ctx)
+		if err != nil {
+			return nil
+		}
+		for _, j := range jobs {
+			names = append(names, j.Name)
+		}
+	case cli.ArgsNetworkPolicy:
+		policies, err := client.ListNetworkPolicies(ctx)
+		if err != nil {
+			return nil
+		}
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// filesDirective is printed by __complete to have the scripts complete the
+// names of local files.
+const filesDirective = ":files"
+
+const bashCompletion = `# bash completion for kurma-cli
+
+_kurma_cli() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local IFS=$'\n'
+	local candidates=($("${COMP_WORDS[0]}" __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	local n=${#candidates[@]}
+	if [[ $n -gt 0 && ${candidates[n-1]} == "` + filesDirective + `" ]]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+	fi
+	COMPREPLY=("${candidates[@]}")
+}
+
+complete -o filenames -F _kurma_cli kurma-cli
+`
+
+const zshCompletion = `#compdef kurma-cli
+# zsh completion for kurma-cli
+
+_kurma_cli() {
+	local -a candidates
+	candidates=("${(@f)$("${words[1]}" __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	candidates=(${candidates:#})
+	if [[ ${candidates[-1]} == "` + filesDirective + `" ]]; then
+		_files
+		return
+	fi
+	compadd -- "${candidates[@]}"
+}
+
+compdef _kurma_cli kurma-cli
+`
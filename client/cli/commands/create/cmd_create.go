@@ -4,98 +4,762 @@ package create
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/apcera/kurma/client"
 	"github.com/apcera/kurma/client/cli"
-	"github.com/apcera/util/tarhelper"
 
 	pb "github.com/apcera/kurma/stage1/client"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const createHelp = `
+Usage: kurma-cli create [--name NAME] [--label KEY=VALUE]... [--insecure]
+                        [--hash HASH] [--volume NAME:PATH[:ro]]...
+                        [--publish HOST:CONTAINER[/PROTOCOL]]...
+                        [--restart POLICY] [--max-retries N]
+                        [--stop-grace-period SECONDS] [--pod FILE]
+                        [--privileged] [--security-label LABEL]
+                        [--read-only] [--tmpfs PATH[:SIZE]]...
+                        [--secret NAME[:PATH]]... [--secret-env VAR=NAME]...
+                        [--disk-limit SIZE] [--health-cmd COMMAND]
+                        [--health-tcp PORT] [--health-http PORT[/PATH]]
+                        [--health-interval SECONDS]
+                        [--health-timeout SECONDS] [--health-retries N]
+                        [--sysctl NAME=VALUE]... [--log-driver DRIVER]
+                        [--log-max-size SIZE] [--log-max-files N]
+                        [--log-opt NAME=VALUE]... [--ingress-limit RATE]
+                        [--egress-limit RATE] [--isolation MODE]
+                        [--runtime NAME] [--exclusive-cpus N]
+                        [--numa-node NODE] [--priority CLASS] IMAGE
+       kurma-cli create --resume UPLOAD_ID FILE
+
+Creates a new container from the specified image. The image may be any of:
+
+  - a local ACI file, which is uploaded to the server.
+  - a local directory holding an image's manifest and rootfs, which is archived
+    and uploaded to the server.
+  - a local OCI image layout, as an archive or a directory, which is uploaded
+    and converted to an ACI by the server.
+  - a url such as https:// or docker://, which is retrieved by the server.
+  - an image name such as example.com/app:1.0, which the server locates using
+    appc discovery and retrieves.
+
+The progress of uploads is shown as the server receives the image. Uploads of
+ACI files are resumed if they're interrupted, and if they still fail, may be
+resumed later with --resume and the upload ID which is printed.
+
+Options:
+  --name       A unique name for the container, by which it may be referred to
+               instead of its UUID. One is generated from the image's name if
+               it isn't given.
+  --label      Label the container with the key and value, by which it may be
+               selected with "kurma-cli list --filter". May be given multiple
+               times.
+  --insecure   Skip verifying the signature of a retrieved image.
+  --hash       The expected SHA-512 hash of a retrieved image, in the form
+               sha512-<hex>. The container isn't created if it differs.
+  --volume     Mount the named volume at the path within the container,
+               optionally read only. May be given multiple times.
+  --publish    Publish the container's port on the host port, using tcp or
+               udp. Ports declared by the image are published on the same
+               host port by default. May be given multiple times.
+  --restart    When to restart the app once it exits: never, always, or
+               on-failure. Defaults to never.
+  --max-retries
+               The maximum number of times to restart the app. Defaults to
+               0, which places no limit on restarts.
+  --stop-grace-period
+               How many seconds the apps are given to exit when the container
+               is stopped before they are killed. Defaults to 10.
+  --pod        A pod manifest listing the apps to run from the image within
+               the container. Each app must use the image the container is
+               created from, as no other images are retrieved, and may give
+               its own App in place of the image's. By default, only the
+               image's app is run.
+  --privileged
+               Run the apps with all capabilities, rather than the default
+               set or those given by their isolators. Only permitted over the
+               local unix socket.
+  --security-label
+               The AppArmor profile or SELinux context to confine the apps
+               by, rather than the host's default.
+  --read-only  Mount the container's root filesystem read-only, leaving only
+               volumes and tmpfs mounts writable.
+  --tmpfs      Mount a tmpfs scratch area at the path within the container,
+               optionally limited to the size in bytes, or with a k, m, or g
+               suffix. May be given multiple times.
+  --secret     Expose the host's secret as a read-only file at the path
+               within the container, /run/secrets/NAME by default. The file
+               is kept in memory, never on disk. May be given multiple times.
+  --secret-env Expose the host's secret as the environment variable of the
+               apps. May be given multiple times.
+  --disk-limit The most disk space the container may write to its root
+               filesystem, in bytes or with a k, m, or g suffix. It overrides
+               the limit from the apps' kurma/storage isolator.
+  --health-cmd Check the container's health by running the command with
+               /bin/sh within it, which must exit successfully.
+  --health-tcp Check the container's health by connecting to its port.
+  --health-http
+               Check the container's health by requesting the path, "/" by
+               default, from an HTTP server on its port, which must respond
+               with a 2xx or 3xx status.
+  --health-interval
+               How many seconds apart the health checks are run.
+               Defaults to 30.
+  --health-timeout
+               How many seconds each health check is given to pass.
+               Defaults to 10.
+  --health-retries
+               How many health checks must fail in a row for the container
+               to be unhealthy. Once it is, its apps are restarted if the
+               restart policy is always or on-failure. Defaults to 3.
+  --sysctl     Set the kernel parameter within the container's namespaces.
+               Only net.* parameters, which require the container network,
+               and kernel.shm* parameters may be set. May be given multiple
+               times.
+  --log-driver How the apps' output is captured: json-file, which keeps it
+               to be shown by the logs command, syslog, or none. Defaults to
+               the host's configuration.
+  --log-max-size
+               The size in bytes, or with a k, m, or g suffix, at which the
+               json-file driver starts a new file.
+  --log-max-files
+               The most files the json-file driver keeps.
+  --log-opt    Set an option of the log driver, such as the syslog driver's
+               address, protocol, facility, or tag. May be given multiple
+               times.
+  --ingress-limit
+               The most bytes per second, or with a k, m, or g suffix, which
+               may be sent to the container over the container network. It
+               overrides the limit from the apps' resource/network-bandwidth
+               isolator.
+  --egress-limit
+               The most bytes per second, or with a k, m, or g suffix, the
+               container may send over the container network. Traffic over
+               the limit is dropped. It overrides the limit from the apps'
+               resource/network-bandwidth isolator.
+  --isolation  How the apps are isolated from the host: namespaces, or vm to
+               run them within a VM of their own, which requires the host to
+               be configured to boot VMs. Defaults to namespaces.
+  --runtime    The host's runtime which runs the apps, as listed by the info
+               command. Defaults to the runtime providing the isolation.
+  --exclusive-cpus
+               Give the container N of the host's CPUs for its use alone.
+               Other containers are moved off them until it is destroyed.
+  --numa-node  Keep the container's processes and memory on the host's NUMA
+               node, along with its exclusive CPUs if it has any.
+  --priority   The container's priority class: critical, high, normal, or
+               low. When the host is short of memory, the containers of the
+               lowest class are throttled and then evicted first, while
+               critical containers, which only privileged clients may create,
+               are left alone. Defaults to normal.
+  --resume     Resume the interrupted upload of the ACI file with the upload
+               ID, rather than creating another container.
+`
+
+var (
+	containerName string
+	labels        keyValueFlags
+	insecure      bool
+	volumes       volumeFlags
+	ports         portFlags
+	restartPolicy string
+	maxRetries    int
+	stopGrace     int
+	podFile       string
+	resumeID      string
+	imageHash     string
+	privileged    bool
+	securityLabel string
+	readOnly      bool
+	tmpfs         tmpfsFlags
+	secrets       secretFlags
+	diskLimit     sizeFlag
+	sysctls       keyValueFlags
+	logDriver     string
+	logMaxSize    sizeFlag
+	logMaxFiles   int
+	logOpts       keyValueFlags
+	ingressLimit  sizeFlag
+	egressLimit   sizeFlag
+	isolation     string
+	runtimeName   string
+	exclusiveCPUs int
+	numaNode      int
+	priority      string
+
+	healthCmd      string
+	healthTCP      int
+	healthHTTP     string
+	healthInterval int
+	healthTimeout  int
+	healthRetries  int
+)
+
+const (
+	// uploadRetries is how many times an interrupted upload is resumed before
+	// giving up.
+	uploadRetries = 3
+
+	// uploadRetryDelay is how long to wait before resuming an upload.
+	uploadRetryDelay = 2 * time.Second
 )
 
 func init() {
-	cli.DefineCommand("create", parseFlags, create, cliCreate, "FIXME")
+	cli.DefineCommand("create", parseFlags, create, cliCreate, &cli.Help{
+		Summary: "Create a container from an image",
+		Text:    createHelp,
+		Examples: []string{
+			"kurma-cli create --name web example.com/web:1.0",
+			"kurma-cli create --label env=prod --publish 8080:80 web.aci",
+		},
+		Args: cli.ArgsFile,
+	})
 }
 
 func parseFlags(cmd *cli.Cmd) {
+	ParseContainerFlags(cmd)
+	cmd.Flags.StringVar(&resumeID, "resume", "", "")
+}
+
+// ParseContainerFlags defines the flags describing the container, which are
+// shared with the run, jobs add, and apply commands.
+func ParseContainerFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&containerName, "name", "", "")
+	cmd.Flags.Var(&labels, "label", "")
+	cmd.Flags.BoolVar(&insecure, "insecure", false, "")
+	cmd.Flags.Var(&volumes, "volume", "")
+	cmd.Flags.Var(&ports, "publish", "")
+	cmd.Flags.StringVar(&restartPolicy, "restart", "never", "")
+	cmd.Flags.IntVar(&maxRetries, "max-retries", 0, "")
+	cmd.Flags.IntVar(&stopGrace, "stop-grace-period", 0, "")
+	cmd.Flags.StringVar(&podFile, "pod", "", "")
+	cmd.Flags.StringVar(&imageHash, "hash", "", "")
+	cmd.Flags.BoolVar(&privileged, "privileged", false, "")
+	cmd.Flags.StringVar(&securityLabel, "security-label", "", "")
+	cmd.Flags.BoolVar(&readOnly, "read-only", false, "")
+	cmd.Flags.Var(&tmpfs, "tmpfs", "")
+	cmd.Flags.Var(&secrets, "secret", "")
+	cmd.Flags.Var(secretEnvFlags{&secrets}, "secret-env", "")
+	cmd.Flags.Var(&diskLimit, "disk-limit", "")
+	cmd.Flags.Var(&sysctls, "sysctl", "")
+	cmd.Flags.StringVar(&logDriver, "log-driver", "", "")
+	cmd.Flags.Var(&logMaxSize, "log-max-size", "")
+	cmd.Flags.IntVar(&logMaxFiles, "log-max-files", 0, "")
+	cmd.Flags.Var(&logOpts, "log-opt", "")
+	cmd.Flags.Var(&ingressLimit, "ingress-limit", "")
+	cmd.Flags.Var(&egressLimit, "egress-limit", "")
+	cmd.Flags.StringVar(&isolation, "isolation", "", "")
+	cmd.Flags.StringVar(&runtimeName, "runtime", "", "")
+	cmd.Flags.IntVar(&exclusiveCPUs, "exclusive-cpus", 0, "")
+	cmd.Flags.IntVar(&numaNode, "numa-node", -1, "")
+	cmd.Flags.StringVar(&priority, "priority", "", "")
+	cmd.Flags.StringVar(&healthCmd, "health-cmd", "", "")
+	cmd.Flags.IntVar(&healthTCP, "health-tcp", 0, "")
+	cmd.Flags.StringVar(&healthHTTP, "health-http", "", "")
+	cmd.Flags.IntVar(&healthInterval, "health-interval", 0, "")
+	cmd.Flags.IntVar(&healthTimeout, "health-timeout", 0, "")
+	cmd.Flags.IntVar(&healthRetries, "health-retries", 0, "")
+}
+
+// ResetContainerFlags clears the values of the options which collect multiple
+// values, which aren't reset when the options are defined again.
+func ResetContainerFlags() {
+	labels = nil
+	volumes = nil
+	ports = nil
+	tmpfs = nil
+	secrets = nil
+	diskLimit = 0
+	sysctls = nil
+	logMaxSize = 0
+	logOpts = nil
+	ingressLimit = 0
+	egressLimit = 0
+}
+
+// CheckContainerFlags returns an error if any of the flags describing the
+// container was given an invalid value.
+func CheckContainerFlags() error {
+	if maxRetries < 0 || stopGrace < 0 || logMaxFiles < 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if healthInterval < 0 || healthTimeout < 0 || healthRetries < 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if exclusiveCPUs < 0 || numaNode < -1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return nil
 }
 
 func cliCreate(cmd *cli.Cmd) error {
-	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+	if len(cmd.Args) != 1 {
 		return fmt.Errorf("Invalid command options specified.")
 	}
+	if err := CheckContainerFlags(); err != nil {
+		return err
+	}
 	return cmd.Run()
 }
 
 func create(cmd *cli.Cmd) error {
-	// open the file
-	f, err := os.Open(cmd.Args[0])
-	if err != nil {
-		return err
+	var container *pb.Container
+	if resumeID != "" {
+		fi, err := os.Stat(cmd.Args[0])
+		if err != nil {
+			return err
+		} else if fi.IsDir() {
+			return fmt.Errorf("only uploads of ACI files may be resumed")
+		}
+		container, err = uploadFile(cmd, resumeID, cmd.Args[0], fi.Size())
+		if err != nil {
+			return err
+		}
+	} else {
+		req, err := Request()
+		if err != nil {
+			return err
+		}
+		container, err = CreateContainer(cmd, req, cmd.Args[0])
+		if err != nil {
+			return err
+		}
 	}
-	defer f.Close()
+	fmt.Printf("Created container %s (%s)\n", container.Name, container.Uuid)
+	return nil
+}
 
-	// find the manifest file, then rewind
-	manifest, err := findManifest(f)
+// Request returns the request for the container described by the flags.
+func Request() (*pb.CreateRequest, error) {
+	req := &pb.CreateRequest{
+		ContainerName:   containerName,
+		Labels:          labels,
+		Insecure:        insecure,
+		Volumes:         volumes,
+		Ports:           ports,
+		RestartPolicy:   restartPolicy,
+		MaxRetries:      int32(maxRetries),
+		StopGracePeriod: int32(stopGrace),
+		Privileged:      privileged,
+		SecurityLabel:   securityLabel,
+		ReadOnlyRootfs:  readOnly,
+		Tmpfs:           tmpfs,
+		Secrets:         secrets,
+		DiskLimit:       int64(diskLimit),
+		Sysctls:         sysctls,
+		IngressLimit:    int64(ingressLimit),
+		EgressLimit:     int64(egressLimit),
+		Isolation:       isolation,
+		Runtime:         runtimeName,
+		Priority:        priority,
+	}
+	healthCheck, err := parseHealthCheck()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, err := f.Seek(0, 0); err != nil {
-		return err
+	req.HealthCheck = healthCheck
+	if exclusiveCPUs > 0 || numaNode >= 0 {
+		req.CpuPlacement = &pb.CPUPlacement{
+			ExclusiveCpus: int32(exclusiveCPUs),
+			PinNumaNode:   numaNode >= 0,
+		}
+		if numaNode >= 0 {
+			req.CpuPlacement.NumaNode = int32(numaNode)
+		}
+	}
+	if logDriver != "" || logMaxSize > 0 || logMaxFiles > 0 || len(logOpts) > 0 {
+		req.LogConfig = &pb.LogConfig{
+			Driver:   logDriver,
+			MaxSize:  int64(logMaxSize),
+			MaxFiles: int32(logMaxFiles),
+			Options:  logOpts,
+		}
+	}
+	if podFile != "" {
+		b, err := ioutil.ReadFile(podFile)
+		if err != nil {
+			return nil, err
+		}
+		req.PodManifest = b
 	}
+	return req, nil
+}
 
-	req := &pb.CreateRequest{
-		Manifest: manifest,
+// ImageRequest returns the request for the container described by the flags,
+// created from the image the server retrieves from the url or by discovery of
+// the image name.
+func ImageRequest(image string) (*pb.CreateRequest, error) {
+	req, err := Request()
+	if err != nil {
+		return nil, err
 	}
+	req.ImageUri = image
+	req.ImageHash = imageHash
+	return req, nil
+}
+
+// CreateContainer creates the container from the image source. Local files and
+// directories are uploaded, and anything else is a url or discovery name
+// retrieved by the server.
+func CreateContainer(cmd *cli.Cmd, req *pb.CreateRequest, source string) (*pb.Container, error) {
+	fi, err := os.Stat(source)
+	switch {
+	case err == nil && fi.IsDir():
+		return createFromDirectory(cmd, req, source)
+	case err == nil:
+		return createFromFile(cmd, req, source, fi.Size())
+	case !os.IsNotExist(err) || IsLocalPath(source):
+		return nil, err
+	}
+	return createFromURI(cmd, req, source)
+}
 
-	// trigger container creation then upload the ACI image
+// createFromURI has the server retrieve the image, either from the url or by
+// appc discovery of the image name.
+func createFromURI(cmd *cli.Cmd, req *pb.CreateRequest, uri string) (*pb.Container, error) {
+	if strings.Contains(uri, "://") {
+		fmt.Fprintf(os.Stderr, "Retrieving %s\n", uri)
+	} else {
+		fmt.Fprintf(os.Stderr, "Discovering and retrieving %s\n", uri)
+	}
+	req.ImageUri = uri
+	req.ImageHash = imageHash
 	resp, err := cmd.Client.Create(context.Background(), req)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	stream, err := cmd.Client.UploadImage(context.Background())
+	return resp.Container, nil
+}
+
+// createFromFile uploads the local ACI file.
+func createFromFile(cmd *cli.Cmd, req *pb.CreateRequest, path string, size int64) (*pb.Container, error) {
+	uploadID, err := client.New(cmd.Client).CreateFromFile(context.Background(), req, path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return uploadFile(cmd, uploadID, path, size)
+}
 
-	w := pb.NewByteStreamWriter(stream, resp.ImageUploadId)
-	if _, err := io.Copy(w, f); err != nil {
-		return fmt.Errorf("write error: %v", err)
+// uploadFile uploads the ACI file for the pending container, resuming the
+// upload if it is interrupted.
+func uploadFile(cmd *cli.Cmd, uploadID, path string, size int64) (*pb.Container, error) {
+	c := client.New(cmd.Client)
+	bar := cli.NewProgressBar(os.Stderr, "Uploading "+filepath.Base(path), size)
+	for attempt := 0; ; attempt++ {
+		container, err := c.UploadFile(context.Background(), uploadID, path, bar.Set)
+		if err == nil {
+			bar.Done()
+			return container, nil
+		} else if os.IsNotExist(err) || os.IsPermission(err) {
+			bar.Done()
+			return nil, err
+		}
+
+		// the whole image was received when the host can't fit the
+		// container, so there is nothing left to resume
+		if pb.InsufficientResources(err) != nil {
+			bar.Done()
+			return nil, err
+		}
+
+		// errors returned by the server, rather than from the connection
+		// breaking, won't be resolved by resuming
+		if attempt == uploadRetries || grpc.Code(err) == codes.Unknown {
+			bar.Done()
+			fmt.Fprintf(os.Stderr, "The upload may be resumed with: kurma-cli create --resume %s %s\n",
+				uploadID, path)
+			return nil, err
+		}
+		bar.Done()
+		fmt.Fprintf(os.Stderr, "Upload interrupted, resuming: %v\n", err)
+		time.Sleep(uploadRetryDelay)
 	}
-	if _, err := stream.CloseAndRecv(); err != nil {
-		return err
+}
+
+// createFromDirectory archives the image layout within the directory, with its
+// manifest and rootfs, and uploads it as it is written.
+func createFromDirectory(cmd *cli.Cmd, req *pb.CreateRequest, dir string) (*pb.Container, error) {
+	bar := cli.NewProgressBar(os.Stderr, "Uploading "+filepath.Base(filepath.Clean(dir)), 0)
+	container, err := client.New(cmd.Client).CreateFromDirectory(context.Background(), req, dir, bar.Set)
+	bar.Done()
+	return container, err
+}
+
+// IsLocalPath returns whether the image source can only be a local path, so
+// that a missing file isn't mistaken for an image name to discover.
+func IsLocalPath(source string) bool {
+	return strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") ||
+		strings.HasSuffix(source, ".aci")
+}
+
+// volumeFlags collects the volumes specified on the command line in the form
+// NAME:PATH[:ro].
+type volumeFlags []*pb.VolumeMount
+
+func (v *volumeFlags) String() string {
+	parts := make([]string, len(*v))
+	for i, m := range *v {
+		parts[i] = m.Name + ":" + m.Path
+		if m.ReadOnly {
+			parts[i] += ":ro"
+		}
 	}
+	return strings.Join(parts, ",")
+}
 
-	// fmt.Printf("Launched container %s\n", resp.Uuid)
+func (v *volumeFlags) Set(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("volumes must be specified as NAME:PATH[:ro]")
+	}
+	m := &pb.VolumeMount{Name: parts[0], Path: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] != "ro" {
+			return fmt.Errorf("unknown volume option %q", parts[2])
+		}
+		m.ReadOnly = true
+	}
+	*v = append(*v, m)
 	return nil
 }
 
-func findManifest(r io.Reader) ([]byte, error) {
-	arch, err := tarhelper.DetectArchiveCompression(r)
-	if err != nil {
-		return nil, err
+// portFlags collects the ports to publish specified on the command line in the
+// form HOST:CONTAINER[/PROTOCOL]. The protocol defaults to tcp.
+type portFlags []*pb.PortMapping
+
+func (p *portFlags) String() string {
+	parts := make([]string, len(*p))
+	for i, m := range *p {
+		parts[i] = fmt.Sprintf("%d:%d/%s", m.HostPort, m.ContainerPort, m.Protocol)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p *portFlags) Set(value string) error {
+	protocol := "tcp"
+	if i := strings.Index(value, "/"); i >= 0 {
+		value, protocol = value[:i], value[i+1:]
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("ports must be specified as HOST:CONTAINER[/PROTOCOL]")
+	}
+	hostPort, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || hostPort == 0 {
+		return fmt.Errorf("invalid host port %q", parts[0])
+	}
+	containerPort, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil || containerPort == 0 {
+		return fmt.Errorf("invalid container port %q", parts[1])
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return fmt.Errorf("unknown protocol %q, must be tcp or udp", protocol)
 	}
+	*p = append(*p, &pb.PortMapping{
+		Protocol:      protocol,
+		HostPort:      int32(hostPort),
+		ContainerPort: int32(containerPort),
+	})
+	return nil
+}
+
+// tmpfsFlags collects the tmpfs mounts specified on the command line in the
+// form PATH[:SIZE].
+type tmpfsFlags []*pb.TmpfsMount
 
-	for {
-		header, err := arch.Next()
-		if err == io.EOF {
-			return nil, fmt.Errorf("failed to locate manifest file")
+func (t *tmpfsFlags) String() string {
+	parts := make([]string, len(*t))
+	for i, m := range *t {
+		parts[i] = m.Path
+		if m.Size > 0 {
+			parts[i] += fmt.Sprintf(":%d", m.Size)
 		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (t *tmpfsFlags) Set(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+		return fmt.Errorf("tmpfs mounts must be specified as PATH[:SIZE]")
+	}
+	m := &pb.TmpfsMount{Path: parts[0]}
+	if len(parts) == 2 {
+		size, err := parseSize(parts[1])
 		if err != nil {
-			return nil, err
+			return err
 		}
+		m.Size = size
+	}
+	*t = append(*t, m)
+	return nil
+}
+
+// secretFlags collects the secrets specified on the command line, in the form
+// NAME[:PATH] for those exposed as files.
+type secretFlags []*pb.SecretMount
 
-		if filepath.Clean(header.Name) != "manifest" {
-			continue
+func (s *secretFlags) String() string {
+	parts := make([]string, len(*s))
+	for i, m := range *s {
+		switch {
+		case m.Env != "":
+			parts[i] = m.Env + "=" + m.Name
+		case m.Path != "":
+			parts[i] = m.Name + ":" + m.Path
+		default:
+			parts[i] = m.Name
 		}
+	}
+	return strings.Join(parts, ",")
+}
 
-		b, err := ioutil.ReadAll(arch)
-		if err != nil {
-			return nil, err
+func (s *secretFlags) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+		return fmt.Errorf("secrets must be specified as NAME[:PATH]")
+	}
+	m := &pb.SecretMount{Name: parts[0]}
+	if len(parts) == 2 {
+		m.Path = parts[1]
+	}
+	*s = append(*s, m)
+	return nil
+}
+
+// secretEnvFlags adds the secrets exposed as environment variables, specified
+// on the command line in the form VAR=NAME, to the other secrets.
+type secretEnvFlags struct {
+	secrets *secretFlags
+}
+
+func (s secretEnvFlags) String() string {
+	return ""
+}
+
+func (s secretEnvFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("secret environment variables must be specified as VAR=NAME")
+	}
+	*s.secrets = append(*s.secrets, &pb.SecretMount{Name: parts[1], Env: parts[0]})
+	return nil
+}
+
+// keyValueFlags collects the settings, such as sysctls or labels, specified on
+// the command line in the form NAME=VALUE.
+type keyValueFlags map[string]string
+
+func (s *keyValueFlags) String() string {
+	parts := make([]string, 0, len(*s))
+	for name, value := range *s {
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (s *keyValueFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("must be specified as NAME=VALUE")
+	}
+	if *s == nil {
+		*s = make(keyValueFlags)
+	}
+	(*s)[parts[0]] = parts[1]
+	return nil
+}
+
+// parseHealthCheck returns the health check specified by the --health flags, or
+// nil if there is none.
+func parseHealthCheck() (*pb.HealthCheck, error) {
+	h := &pb.HealthCheck{
+		Interval:  int32(healthInterval),
+		Timeout:   int32(healthTimeout),
+		Threshold: int32(healthRetries),
+	}
+	probes := 0
+	if healthCmd != "" {
+		h.Exec = []string{"/bin/sh", "-c", healthCmd}
+		probes++
+	}
+	if healthTCP != 0 {
+		h.TcpPort = int32(healthTCP)
+		probes++
+	}
+	if healthHTTP != "" {
+		port, path := healthHTTP, ""
+		if i := strings.Index(healthHTTP, "/"); i >= 0 {
+			port, path = healthHTTP[:i], healthHTTP[i:]
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("the http health check must be specified as PORT[/PATH]")
 		}
-		return b, nil
+		h.HttpPort = int32(n)
+		h.HttpPath = path
+		probes++
+	}
+
+	switch {
+	case probes > 1:
+		return nil, fmt.Errorf("only one of --health-cmd, --health-tcp, and --health-http may be specified")
+	case probes == 0 && (healthInterval > 0 || healthTimeout > 0 || healthRetries > 0):
+		return nil, fmt.Errorf("a health check must be specified with --health-cmd, --health-tcp, or --health-http")
+	case probes == 0:
+		return nil, nil
+	}
+	return h, nil
+}
+
+// sizeFlag is a size in bytes specified on the command line, which may have a
+// k, m, or g suffix.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("a size must be specified")
+	}
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(size)
+	return nil
+}
+
+// parseSize parses a size in bytes, which may have a k, m, or g suffix.
+func parseSize(value string) (int64, error) {
+	number, multiplier := value, int64(1)
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier = 1024
+	case "m":
+		multiplier = 1024 * 1024
+	case "g":
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		number = value[:len(value)-1]
+	}
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
 	}
+	return size * multiplier, nil
 }
@@ -0,0 +1,160 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package destroy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const destroyHelp = `
+Usage: kurma-cli destroy [-f] [--all-stopped] [CONTAINER|PATTERN...]
+       kurma-cli rm [-f] [--all-stopped] [CONTAINER|PATTERN...]
+
+Removes containers and their filesystems from the host. Each container may be
+given by its name, its UUID, or a prefix of its UUID which no other container
+shares, or by a pattern such as "web-*" which selects every container whose
+name or UUID it matches. Containers whose apps are running are not destroyed
+unless --force is given, in which case their processes are killed first.
+
+Each container is destroyed in turn, and those which fail are reported without
+stopping the rest.
+
+Options:
+  -f, --force     Kill the processes of containers which are running, rather
+                  than refusing to destroy them.
+  --all-stopped   Destroy every container whose apps have exited or were
+                  stopped, in addition to any given.
+`
+
+var (
+	force      bool
+	allStopped bool
+)
+
+func init() {
+	cli.DefineCommand("destroy", parseFlags, destroy, cliDestroy, &cli.Help{
+		Summary: "Remove containers from the host",
+		Text:    destroyHelp,
+		Examples: []string{
+			"kurma-cli destroy web",
+			"# destroy every container whose name starts with \"test-\", even if running",
+			"kurma-cli rm -f 'test-*'",
+			"kurma-cli rm --all-stopped",
+		},
+		Args: cli.ArgsContainer,
+	})
+	cli.DefineAlias("destroy", "rm")
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&force, "force", false, "")
+	cmd.Flags.BoolVar(&force, "f", false, "")
+	cmd.Flags.BoolVar(&allStopped, "all-stopped", false, "")
+}
+
+func cliDestroy(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 && !allStopped {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	for _, arg := range cmd.Args {
+		if _, err := path.Match(arg, ""); err != nil {
+			return fmt.Errorf("Invalid pattern %q.", arg)
+		}
+	}
+	return cmd.Run()
+}
+
+func destroy(cmd *cli.Cmd) error {
+	uuids, err := selectContainers(cmd)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, uuid := range uuids {
+		req := &pb.DestroyRequest{Uuid: uuid, Force: force}
+		if _, err := cmd.Client.Destroy(context.Background(), req); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to destroy container %s: %v\n", uuid, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Destroyed container %s\n", uuid)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to destroy %d of %d container(s)", failed, len(uuids))
+	}
+	return nil
+}
+
+// selectContainers returns the containers the arguments and flags select,
+// without duplicates, in the order they were given. Arguments which aren't
+// patterns are passed on for the server to resolve, while the containers
+// patterns select are returned by UUID. Containers are only listed from the
+// server when patterns or --all-stopped select them.
+func selectContainers(cmd *cli.Cmd) ([]string, error) {
+	var containers []*pb.Container
+	if allStopped || hasPattern(cmd.Args) {
+		resp, err := cmd.Client.List(context.Background(), &pb.ListRequest{})
+		if err != nil {
+			return nil, err
+		}
+		containers = resp.Containers
+	}
+
+	var uuids []string
+	seen := make(map[string]bool)
+	add := func(uuid string) {
+		if !seen[uuid] {
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	for _, arg := range cmd.Args {
+		if !hasPattern([]string{arg}) {
+			add(arg)
+			continue
+		}
+		matched := false
+		for _, c := range containers {
+			uuidMatch, _ := path.Match(arg, c.Uuid)
+			nameMatch, _ := path.Match(arg, c.Name)
+			if uuidMatch || (nameMatch && c.Name != "") {
+				add(c.Uuid)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no containers match %q", arg)
+		}
+	}
+	if allStopped {
+		for _, c := range containers {
+			if c.State == pb.Container_EXITED || c.State == pb.Container_STOPPED {
+				add(c.Uuid)
+			}
+		}
+	}
+	if len(uuids) == 0 {
+		fmt.Printf("No containers to destroy\n")
+	}
+	return uuids, nil
+}
+
+// hasPattern returns whether any of the arguments contain pattern characters.
+func hasPattern(args []string) bool {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			return true
+		}
+	}
+	return false
+}
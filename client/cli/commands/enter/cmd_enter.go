@@ -14,11 +14,36 @@ import (
 	"golang.org/x/net/context"
 )
 
+const enterHelp = `
+Usage: kurma-cli enter [--local] CONTAINER
+
+Opens /bin/bash within a running container, with the local terminal attached
+to it in raw mode. The container's image must include bash.
+
+Options:
+  -l, --local   Join the container's namespaces directly rather than going
+                through the server, for when it can't be reached. This must
+                be run as root on the container's host, with the container
+                given by its UUID. The shell is run as root with all of its
+                capabilities.
+`
+
 func init() {
-	cli.DefineCommand("enter", parseFlags, enter, cliEnter, "FIXME")
+	cli.DefineCommand("enter", parseFlags, enter, cliEnter, &cli.Help{
+		Summary: "Open a shell within a running container",
+		Text:    enterHelp,
+		Examples: []string{
+			"kurma-cli enter web",
+			"# when the server isn't responding",
+			"sudo kurma-cli enter --local 6f1c2d0e-0b2a-4a8e-9d5c-3f4e5a6b7c8d",
+		},
+		Args: cli.ArgsContainer,
+	})
 }
 
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&cmd.Local, "local", false, "")
+	cmd.Flags.BoolVar(&cmd.Local, "l", false, "")
 }
 
 func cliEnter(cmd *cli.Cmd) error {
@@ -29,6 +54,10 @@ func cliEnter(cmd *cli.Cmd) error {
 }
 
 func enter(cmd *cli.Cmd) error {
+	if cmd.Local {
+		return enterLocal(cmd.Args[0])
+	}
+
 	// Set the local terminal in raw mode to turn off buffering and local
 	// echo. Also defers setting it back to normal for when the call is done.
 	termios, err := raw.MakeRaw(os.Stdin.Fd())
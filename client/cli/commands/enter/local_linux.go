@@ -0,0 +1,164 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package enter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
+
+	client2 "github.com/apcera/kurma/stage2/client"
+)
+
+// localEnvironment is the environment of shells opened locally, since the
+// container's own environment is only known to the server.
+var localEnvironment = []string{
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	"HOME=/root",
+	"TERM=" + os.Getenv("TERM"),
+}
+
+// enterLocal opens a shell within the container by joining its namespaces and
+// cgroups through the stage2 linked into the CLI, without the server.
+func enterLocal(uuid string) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("entering a container locally must be done as root")
+	}
+	cg, err := findCgroup(uuid)
+	if err != nil {
+		return err
+	}
+	tasks, err := cg.Tasks()
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("no processes are running inside the container")
+	}
+
+	// the shell gets its own pty, so that it doesn't take over the local
+	// terminal as its controlling terminal
+	master, slave, err := pty.Open()
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+	resize(master)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for _ = range winch {
+			resize(master)
+		}
+	}()
+
+	launcher := &client2.Launcher{
+		Environment: localEnvironment,
+		Taskfiles:   cg.TasksFiles(),
+		Stdin:       slave,
+		Stdout:      slave,
+		Stderr:      slave,
+	}
+	launcher.SetNS(tasks[0])
+	if same, err := sameNamespace(tasks[0], "user"); err != nil {
+		return err
+	} else if same {
+		launcher.UserNamespace = 0
+	}
+
+	termios, err := raw.MakeRaw(os.Stdin.Fd())
+	if err != nil {
+		return err
+	}
+	defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+
+	p, err := launcher.Run("/bin/bash")
+	if err != nil {
+		return err
+	}
+	go io.Copy(master, os.Stdin)
+	go io.Copy(os.Stdout, master)
+	ps, err := p.Wait()
+	if err != nil {
+		return err
+	}
+	if status, ok := ps.Sys().(syscall.WaitStatus); ok && status.ExitStatus() != 0 {
+		return &cli.ExitCodeError{Code: status.ExitStatus()}
+	}
+	return nil
+}
+
+// findCgroup returns the cgroup of the container with the UUID, which is named
+// for the UUID's first 8 characters under the cgroup the server was configured
+// to create them in.
+func findCgroup(uuid string) (*cgroups.Cgroup, error) {
+	if len(uuid) < 8 {
+		return nil, fmt.Errorf("containers must be given by their UUID to enter them locally")
+	}
+	name := uuid[:8]
+
+	root := cgroups.CgroupsDirPrefix()
+	if !cgroups.Unified() {
+		root = filepath.Join(root, cgroups.Controllers()[0])
+	}
+	var found string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == name {
+			found = path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == "" {
+		return nil, fmt.Errorf("container %q is not running on this host", uuid)
+	}
+	rel, err := filepath.Rel(root, found)
+	if err != nil {
+		return nil, err
+	}
+	return cgroups.Open(rel), nil
+}
+
+// sameNamespace returns whether the process is in the same namespace of the kind
+// as the CLI, which can't be joined again.
+func sameNamespace(pid int, kind string) (bool, error) {
+	theirs, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", pid, kind))
+	if err != nil {
+		return false, err
+	}
+	ours, err := os.Readlink(filepath.Join("/proc/self/ns", kind))
+	if err != nil {
+		return false, err
+	}
+	return theirs == ours, nil
+}
+
+// resize sets the size of the shell's pty to that of the local terminal.
+func resize(master *os.File) {
+	rows, cols, err := pty.Getsize(os.Stdin)
+	if err != nil {
+		return
+	}
+	ws := struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}{uint16(rows), uint16(cols), 0, 0}
+	syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
@@ -0,0 +1,15 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build !linux !cgo
+
+package enter
+
+import (
+	"fmt"
+)
+
+// enterLocal is unsupported where the CLI can't be built with the stage2 that
+// joins the container's namespaces.
+func enterLocal(uuid string) error {
+	return fmt.Errorf("entering containers locally is only supported on Linux")
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const eventsHelp = `
+Usage: kurma-cli events [--json] [CONTAINER]
+
+Streams container lifecycle events as they happen, until interrupted. The
+events are: created, started, exited, stopped, oom-killed,
+disk-quota-exceeded, unhealthy, healthy, destroyed, image-pulled, throttled,
+and evicted. Containers are throttled and evicted when the host is short of
+memory, and those events give the reason.
+If a container is given, by its name, UUID, or a unique prefix of its UUID, only
+its events are shown. A container which doesn't exist yet must be given by its
+full UUID.
+
+Options:
+  --json   Output each event as a line of JSON, as does --output json, while
+           --output go-template=TEMPLATE outputs each event with the
+           template.
+`
+
+var jsonOutput bool
+
+func init() {
+	cli.DefineCommand("events", parseFlags, events, cliEvents, &cli.Help{
+		Summary: "Stream container lifecycle events",
+		Text:    eventsHelp,
+		Examples: []string{
+			"kurma-cli events",
+			"kurma-cli events --json web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&jsonOutput, "json", false, "")
+}
+
+func cliEvents(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+// event is the JSON form of an event output with --json.
+type event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	UUID     string    `json:"uuid,omitempty"`
+	App      string    `json:"app,omitempty"`
+	Image    string    `json:"image,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+func events(cmd *cli.Cmd) error {
+	req := &pb.EventsRequest{}
+	if len(cmd.Args) > 0 {
+		req.Uuid = cmd.Args[0]
+	}
+
+	stream, err := cmd.Client.Events(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		e := newEvent(resp)
+		if jsonOutput || cli.Output == cli.OutputJSON {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", string(b))
+			continue
+		}
+		err = cli.Render(e, func() error {
+			fmt.Println(formatEvent(e))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newEvent converts the response into the event which is output.
+func newEvent(resp *pb.Event) *event {
+	e := &event{
+		Type:   strings.ToLower(strings.Replace(resp.Type.String(), "_", "-", -1)),
+		Time:   time.Unix(0, resp.Time),
+		UUID:   resp.Uuid,
+		App:    resp.App,
+		Image:  resp.Image,
+		Reason: resp.Reason,
+	}
+	if resp.Type == pb.Event_EXITED || resp.Type == pb.Event_STOPPED || resp.Type == pb.Event_EVICTED {
+		code := int(resp.ExitCode)
+		e.ExitCode = &code
+	}
+	return e
+}
+
+// formatEvent renders the event as a line of text with its time, type and
+// container, followed by any attributes such as "(app=web, exit_code=1)".
+func formatEvent(e *event) string {
+	parts := []string{e.Time.Format(time.RFC3339Nano), e.Type}
+	if e.UUID != "" {
+		parts = append(parts, e.UUID)
+	}
+
+	var attrs []string
+	if e.Image != "" {
+		attrs = append(attrs, fmt.Sprintf("image=%s", e.Image))
+	}
+	if e.App != "" {
+		attrs = append(attrs, fmt.Sprintf("app=%s", e.App))
+	}
+	if e.ExitCode != nil {
+		attrs = append(attrs, fmt.Sprintf("exit_code=%d", *e.ExitCode))
+	}
+	if e.Reason != "" {
+		attrs = append(attrs, fmt.Sprintf("reason=%q", e.Reason))
+	}
+	if len(attrs) > 0 {
+		parts = append(parts, fmt.Sprintf("(%s)", strings.Join(attrs, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
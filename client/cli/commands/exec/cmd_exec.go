@@ -0,0 +1,107 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
+	"golang.org/x/net/context"
+)
+
+const execHelp = `
+Usage: kurma-cli exec [-t] CONTAINER -- COMMAND [ARGS...]
+
+Runs the specified command within a running container. The stdin, stdout, and
+stderr of the command are streamed to the local terminal and the CLI exits with
+the command's exit code.
+
+Options:
+  -t, --tty   Allocate a pseudo-terminal for the command.
+`
+
+var (
+	tty bool
+)
+
+func init() {
+	cli.DefineCommand("exec", parseFlags, execute, cliExec, &cli.Help{
+		Summary: "Run a command within a running container",
+		Text:    execHelp,
+		Examples: []string{
+			"kurma-cli exec web -- ls /",
+			"# run an interactive shell",
+			"kurma-cli exec -t web -- /bin/sh",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&tty, "tty", false, "")
+	cmd.Flags.BoolVar(&tty, "t", false, "")
+}
+
+func cliExec(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if len(cmd.Args) == 1 && len(cmd.RawSubFlags) == 0 {
+		return fmt.Errorf("A command to execute must be specified.")
+	}
+	return cmd.Run()
+}
+
+func execute(cmd *cli.Cmd) error {
+	command := append(cmd.Args[1:], cmd.RawSubFlags...)
+
+	opts := client.ExecOptions{
+		Tty:    tty,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	if tty {
+		// Set the local terminal in raw mode to turn off buffering and local
+		// echo. Also defers setting it back to normal for when the call is done.
+		termios, err := raw.MakeRaw(os.Stdin.Fd())
+		if err != nil {
+			return err
+		}
+		defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+
+		if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+			opts.Size = client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+		}
+
+		// Pass along any changes to the window size.
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		resize := make(chan client.WindowSize, 1)
+		go func() {
+			for _ = range winch {
+				if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+					resize <- client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+				}
+			}
+		}()
+		opts.Resize = resize
+	}
+
+	exitCode, err := client.New(cmd.Client).Exec(context.Background(), cmd.Args[0], command, opts)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return &cli.ExitCodeError{Code: exitCode}
+	}
+	return nil
+}
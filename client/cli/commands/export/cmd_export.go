@@ -0,0 +1,58 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const exportHelp = `
+Usage: kurma-cli export [-f FILE] CONTAINER
+
+Writes a tar archive of the container's root filesystem, as it currently is,
+so that it can be inspected offline or copied to another host. The archive is
+written to stdout unless a file is given, and isn't written to a terminal.
+Volumes and other filesystems mounted within the container aren't included.
+
+Options:
+  -f, --file FILE   Write the archive to the file.
+`
+
+var (
+	file string
+)
+
+func init() {
+	cli.DefineCommand("export", parseFlags, export, cliExport, &cli.Help{
+		Summary: "Export a container's filesystem as a tar archive",
+		Text:    exportHelp,
+		Examples: []string{
+			"kurma-cli export -f web.tar web",
+			"kurma-cli export web | tar -t",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&file, "file", "", "")
+	cmd.Flags.StringVar(&file, "f", "", "")
+}
+
+func cliExport(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func export(cmd *cli.Cmd) error {
+	return cli.WriteExport(file, func(w io.Writer) error {
+		return client.New(cmd.Client).Export(context.Background(), cmd.Args[0], w)
+	})
+}
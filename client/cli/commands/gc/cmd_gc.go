@@ -0,0 +1,62 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package gc
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const gcHelp = `
+Usage: kurma-cli gc
+
+Runs garbage collection on the host immediately, rather than waiting for the
+next periodic sweep. Containers whose apps exited longer ago than the host's
+retention period are destroyed, image layers which no containers are using are
+removed, and the least recently used cached images are removed until the image
+cache fits within its configured size.
+`
+
+func init() {
+	cli.DefineCommand("gc", parseFlags, gc, cliGC, &cli.Help{
+		Summary: "Run garbage collection on the host",
+		Text:    gcHelp,
+		Examples: []string{
+			"kurma-cli gc",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliGC(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func gc(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.GarbageCollect(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	for _, uuid := range resp.Containers {
+		fmt.Printf("Destroyed container %s\n", uuid)
+	}
+	for _, uri := range resp.Images {
+		fmt.Printf("Removed image %s\n", uri)
+	}
+	for _, id := range resp.Layers {
+		fmt.Printf("Removed image layer %s\n", id)
+	}
+	fmt.Printf("Removed %d container(s) and %d image(s), freeing %d bytes\n",
+		len(resp.Containers), len(resp.Images), resp.ImageBytes)
+	return nil
+}
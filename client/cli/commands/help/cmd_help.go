@@ -0,0 +1,44 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package help
+
+import (
+	"os"
+
+	"github.com/apcera/kurma/client/cli"
+)
+
+const helpHelp = `
+Usage: kurma-cli help [COMMAND...]
+
+Lists the commands, along with the options every command accepts, or shows the
+help of a command, including its options and examples. Commands with several
+words, such as "volume create", are given with each word as an argument.
+`
+
+func init() {
+	cli.DefineLocalCommand("help", parseFlags, help, cliHelp, &cli.Help{
+		Summary: "Show the list of commands or the help of one",
+		Text:    helpHelp,
+		Examples: []string{
+			"kurma-cli help create",
+			"kurma-cli help volume create",
+		},
+		Args: cli.ArgsCommand,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliHelp(cmd *cli.Cmd) error {
+	return cmd.Run()
+}
+
+func help(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 {
+		cli.PrintCommands(os.Stdout)
+		return nil
+	}
+	return cli.PrintCommandHelp(os.Stdout, cmd.Args)
+}
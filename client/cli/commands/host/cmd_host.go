@@ -0,0 +1,709 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package host
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/kurma/util/tpm"
+	"github.com/apcera/termtables"
+	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const hostShutdownHelp = `
+Usage: kurma-cli host shutdown
+
+Powers off the host. The containers are stopped in the reverse of the order
+they were created, each being given its stop grace period, and the filesystems
+are unmounted before the host is powered off.
+`
+
+const hostRebootHelp = `
+Usage: kurma-cli host reboot
+
+Reboots the host. The containers are stopped and the filesystems unmounted the
+same way as with "kurma-cli host shutdown".
+`
+
+const hostStatusHelp = `
+Usage: kurma-cli host status
+
+Lists the units the host was set up with, such as mounting its disks and
+launching its init containers, in the order they were started. Each is shown
+with its state, when it started relative to the first unit, how long it took,
+and why it failed or was skipped.
+
+Units are run once the units they are ordered after have finished, and those
+without an ordering between them are run in parallel. A unit is skipped when a
+unit it requires fails.
+`
+
+const hostInfoHelp = `
+Usage: kurma-cli host info
+
+Shows the host's name, kernel version, clock, and uptime. When the built-in NTP
+client sets the clock, the servers it uses are shown along with whether the
+clock is synchronized, the last correction made to it, and when it was made.
+`
+
+const hostStorageHelp = `
+Usage: kurma-cli host storage
+
+Shows the health of the RAID arrays the host assembles at boot, including how
+many of their devices are missing and the progress of any resync or recovery,
+along with the size and logical volumes of its LVM volume groups. Arrays and
+volume groups which couldn't be assembled are shown with the reason.
+`
+
+const hostEncryptionHelp = `
+Usage: kurma-cli host encryption
+
+Shows the LUKS encrypted devices the host unlocks at boot, with whether each is
+unlocked, the device it is on, how it is encrypted, and where its key is read
+from. Devices which couldn't be unlocked are shown with the reason.
+`
+
+const hostCrashesHelp = `
+Usage: kurma-cli host crashes
+
+Lists the kernel crashes recorded on the host, from the most recent, with the
+pstore records collected for each on the boot after it. Crashes are kept across
+reboots when a disk is configured with the "crash" usage.
+`
+
+const hostCrashHelp = `
+Usage: kurma-cli host crash NAME [FILE]
+
+Prints the records of the named crash, as listed by "kurma-cli host crashes",
+such as the kernel log written as it panicked. If a FILE is given, only that
+record is printed.
+`
+
+const hostReloadHelp = `
+Usage: kurma-cli host reload
+
+Reloads the host's configuration without a reboot, as is also done when init
+receives SIGHUP. Init containers and modules which were added are started and
+loaded, sysctls which were added or changed are set, and the hostname and
+nameservers are updated. Other changes are listed as requiring a restart, and
+only take effect once the host is rebooted.
+`
+
+const hostConsoleHelp = `
+Usage: kurma-cli host console
+
+Opens a shell within the host's console container, a privileged container which
+is launched when the shell is first requested unless the host launched it at
+boot. The local terminal is attached to the shell in raw mode, and the CLI exits
+with the shell's exit code. The host must be configured with the console's
+image, and the console must not be disabled.
+`
+
+const hostUpgradeHelp = `
+Usage: kurma-cli host upgrade --from URL
+
+Upgrades the host's kurma without stopping its containers. The update bundle at
+the URL is a tar archive holding the new binary as "kurma", and must be signed,
+with its armored detached signature at the URL with ".asc" appended, by a key
+in the host's image keystore which is trusted for images named "kurma".
+
+Once the bundle is verified, the containers are handed off and the new binary
+is exec'd in place of the running kurma. It keeps serving the API on the same
+listeners and reattaches to the containers, which keep running throughout. The
+upgrade is refused while any container is starting, stopping, or restarting,
+or while a container is running in a VM, which can't be reattached to.
+
+Options:
+  --from URL   The file, http, or https URL of the update bundle.
+`
+
+const hostUpdateHelp = `
+Usage: kurma-cli host update [--from URL [--reboot]]
+
+Shows the partitions the host's system image is booted from, for hosts which
+boot it from a pair of partitions and are configured with the disk holding
+them. The bootloader boots whichever partition has the highest priority and
+has either booted successfully or has tries left.
+
+With --from, the system image at the URL is written to the partition the host
+didn't boot from, which is tried on the next boot. The image is a raw
+filesystem, which may be gzip compressed, and must be signed, with its armored
+detached signature at the URL with ".asc" appended, by a key in the host's
+image keystore which is trusted for images named "kurma". The update is
+refused while the booted image is itself on trial.
+
+An updated image is on trial until the host's containers are running and
+healthy. If they aren't within the configured timeout, or the host fails to
+boot, the host rolls back to the image it was updated from.
+
+Options:
+  --from URL   The file, http, or https URL of the system image.
+  --reboot     Reboot into the updated image once it has been written.
+`
+
+const hostAttestHelp = `
+Usage: kurma-cli host attest [--nonce HEX] [--output DIR]
+
+Requests a quote of the host's PCRs from its TPM, signed by its attestation
+key, for hosts with measured boot enabled. The nonce is included in the quote
+so that it can't be replayed, and a random one is used unless it is given.
+
+The PCRs are listed along with the log of what kurma measured into them while
+booting, such as its binary and configuration. The log is replayed, and each
+PCR it covers is shown as matching it or not. With --output, the quote, its
+signature, the attestation key, and the nonce are written to the directory as
+quote.msg, quote.sig, ak.pem, and nonce, to be verified with tpm2_checkquote.
+
+Options:
+  --nonce HEX   The nonce to include in the quote, hex encoded.
+  --output DIR  The directory to write the quote to.
+`
+
+var (
+	from        string
+	rebootAfter bool
+	nonce       string
+	output      string
+)
+
+func init() {
+	cli.DefineCommand("host shutdown", parseFlags, shutdown, cliHost, &cli.Help{
+		Summary: "Power off the host",
+		Text:    hostShutdownHelp,
+		Examples: []string{
+			"kurma-cli host shutdown",
+		},
+	})
+	cli.DefineCommand("host reboot", parseFlags, reboot, cliHost, &cli.Help{
+		Summary: "Reboot the host",
+		Text:    hostRebootHelp,
+		Examples: []string{
+			"kurma-cli host reboot",
+		},
+	})
+	cli.DefineCommand("host status", parseFlags, status, cliHost, &cli.Help{
+		Summary: "List the units the host was set up with",
+		Text:    hostStatusHelp,
+		Examples: []string{
+			"kurma-cli host status",
+		},
+	})
+	cli.DefineCommand("host reload", parseFlags, reload, cliHost, &cli.Help{
+		Summary: "Reload the host's configuration",
+		Text:    hostReloadHelp,
+		Examples: []string{
+			"kurma-cli host reload",
+		},
+	})
+	cli.DefineCommand("host info", parseFlags, info, cliHost, &cli.Help{
+		Summary: "Show the host's name, kernel, clock, and uptime",
+		Text:    hostInfoHelp,
+		Examples: []string{
+			"kurma-cli host info",
+		},
+	})
+	cli.DefineCommand("host storage", parseFlags, storage, cliHost, &cli.Help{
+		Summary: "Show the host's RAID arrays and volume groups",
+		Text:    hostStorageHelp,
+		Examples: []string{
+			"kurma-cli host storage",
+		},
+	})
+	cli.DefineCommand("host encryption", parseFlags, encryption, cliHost, &cli.Help{
+		Summary: "Show the host's encrypted devices",
+		Text:    hostEncryptionHelp,
+		Examples: []string{
+			"kurma-cli host encryption",
+		},
+	})
+	cli.DefineCommand("host crashes", parseFlags, crashes, cliHost, &cli.Help{
+		Summary: "List the kernel crashes recorded on the host",
+		Text:    hostCrashesHelp,
+		Examples: []string{
+			"kurma-cli host crashes",
+		},
+	})
+	cli.DefineCommand("host console", parseFlags, console, cliHost, &cli.Help{
+		Summary: "Open a debug shell on the host",
+		Text:    hostConsoleHelp,
+		Examples: []string{
+			"kurma-cli host console",
+		},
+	})
+	cli.DefineCommand("host upgrade", parseUpgradeFlags, upgrade, cliUpgrade, &cli.Help{
+		Summary: "Upgrade the host's kurma without stopping its containers",
+		Text:    hostUpgradeHelp,
+		Examples: []string{
+			"kurma-cli host upgrade --from https://example.com/kurma-0.5.0.tar.gz",
+		},
+	})
+	cli.DefineCommand("host update", parseUpdateFlags, update, cliUpdate, &cli.Help{
+		Summary: "Show or update the host's system image",
+		Text:    hostUpdateHelp,
+		Examples: []string{
+			"kurma-cli host update",
+			"kurma-cli host update --from https://example.com/kurmaos-0.5.0.img.gz --reboot",
+		},
+	})
+	cli.DefineCommand("host attest", parseAttestFlags, attest, cliHost, &cli.Help{
+		Summary: "Request a TPM quote of what the host booted",
+		Text:    hostAttestHelp,
+		Examples: []string{
+			"kurma-cli host attest",
+			"kurma-cli host attest --nonce 5f2c1a9e --output ./quote",
+		},
+	})
+	cli.DefineCommand("host crash", parseFlags, crash, cliCrash, &cli.Help{
+		Summary: "Print the records of a kernel crash",
+		Text:    hostCrashHelp,
+		Examples: []string{
+			"kurma-cli host crash 1445001234",
+			"kurma-cli host crash 1445001234 dmesg-ramoops-0",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func parseUpgradeFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&from, "from", "", "")
+}
+
+func parseUpdateFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&from, "from", "", "")
+	cmd.Flags.BoolVar(&rebootAfter, "reboot", false, "")
+}
+
+func parseAttestFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&nonce, "nonce", "", "")
+	cmd.Flags.StringVar(&output, "output", "", "")
+}
+
+func cliHost(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliUpgrade(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 || from == "" {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliUpdate(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 || (rebootAfter && from == "") {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliCrash(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 2 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func shutdown(cmd *cli.Cmd) error {
+	if _, err := cmd.Client.Shutdown(context.Background(), &pb.ShutdownRequest{}); err != nil {
+		return err
+	}
+
+	fmt.Printf("The host is shutting down\n")
+	return nil
+}
+
+func reboot(cmd *cli.Cmd) error {
+	req := &pb.ShutdownRequest{Reboot: true}
+	if _, err := cmd.Client.Shutdown(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("The host is rebooting\n")
+	return nil
+}
+
+func info(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.HostInfo(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		table := termtables.CreateTable()
+		table.AddRow("Hostname", resp.Hostname)
+		table.AddRow("Kernel", resp.Kernel)
+		table.AddRow("Time", time.Unix(0, resp.Time).UTC().Format(time.RFC3339))
+		table.AddRow("Uptime", (time.Duration(resp.Uptime) * time.Second).String())
+		if ts := resp.TimeSync; ts != nil {
+			table.AddRow("NTP Servers", strings.Join(ts.Servers, ", "))
+			table.AddRow("Synchronized", fmt.Sprintf("%t", ts.Synchronized))
+			if ts.LastSync != 0 {
+				table.AddRow("Last Sync", fmt.Sprintf("%s from %s",
+					time.Unix(0, ts.LastSync).UTC().Format(time.RFC3339), ts.Server))
+				table.AddRow("Last Offset", time.Duration(ts.Offset).String())
+			}
+			if ts.Error != "" {
+				table.AddRow("Sync Error", ts.Error)
+			}
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+func storage(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.HostStorage(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		if len(resp.Arrays)+len(resp.VolumeGroups) == 0 {
+			fmt.Printf("No RAID arrays or volume groups are configured\n")
+			return nil
+		}
+		if len(resp.Arrays) > 0 {
+			table := termtables.CreateTable()
+			table.AddHeaders("Array", "Device", "Level", "State", "Devices", "Sync", "Error")
+			for _, a := range resp.Arrays {
+				devices := fmt.Sprintf("%d", a.Devices)
+				if a.Missing > 0 {
+					devices = fmt.Sprintf("%d of %d (degraded)", a.Devices-a.Missing, a.Devices)
+				}
+				sync := "-"
+				if a.SyncAction != "" && a.SyncAction != "idle" {
+					sync = fmt.Sprintf("%s %.1f%%", a.SyncAction, a.SyncProgress)
+				}
+				table.AddRow(a.Name, a.Device, a.Level, a.State, devices, sync, a.Error)
+			}
+			fmt.Printf("%s", table.Render())
+		}
+		if len(resp.VolumeGroups) > 0 {
+			table := termtables.CreateTable()
+			table.AddHeaders("Volume Group", "Size", "Free", "Volumes", "Error")
+			for _, vg := range resp.VolumeGroups {
+				table.AddRow(vg.Name, cli.FormatBytes(vg.Size), cli.FormatBytes(vg.Free),
+					strings.Join(vg.Volumes, ", "), vg.Error)
+			}
+			fmt.Printf("%s", table.Render())
+		}
+		return nil
+	})
+}
+
+func encryption(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.HostEncryption(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		if len(resp.Devices) == 0 {
+			fmt.Printf("No encrypted devices are configured\n")
+			return nil
+		}
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Unlocked", "Device", "Mapped To", "Encryption", "Key Source", "Error")
+		for _, d := range resp.Devices {
+			encryption := "-"
+			if d.Unlocked {
+				encryption = fmt.Sprintf("%s %s (%d bits)", d.Type, d.Cipher, d.KeySize)
+			}
+			table.AddRow(d.Name, fmt.Sprintf("%t", d.Unlocked), d.Device, d.MappedDevice,
+				encryption, d.KeySource, d.Error)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+func crashes(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.ListCrashes(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		if len(resp.Crashes) == 0 {
+			fmt.Printf("No crashes have been recorded\n")
+			return nil
+		}
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Time", "Records")
+		for _, c := range resp.Crashes {
+			records := make([]string, 0, len(c.Files))
+			for _, f := range c.Files {
+				records = append(records, fmt.Sprintf("%s (%s)", f.Name, cli.FormatBytes(f.Size)))
+			}
+			table.AddRow(c.Name, time.Unix(c.Time, 0).UTC().Format(time.RFC3339), strings.Join(records, ", "))
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+func crash(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.GetCrash(context.Background(), &pb.CrashRequest{Name: cmd.Args[0]})
+	if err != nil {
+		return err
+	}
+
+	if len(cmd.Args) == 2 {
+		for _, f := range resp.Files {
+			if f.Name == cmd.Args[1] {
+				os.Stdout.Write(f.Data)
+				return nil
+			}
+		}
+		return fmt.Errorf("Crash %s has no record %q.", resp.Name, cmd.Args[1])
+	}
+	for i, f := range resp.Files {
+		if i > 0 {
+			fmt.Printf("\n")
+		}
+		fmt.Printf("==> %s <==\n", f.Name)
+		os.Stdout.Write(f.Data)
+	}
+	return nil
+}
+
+func upgrade(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.UpgradeHost(context.Background(), &pb.UpgradeHostRequest{Url: from})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The host is being upgraded to kurma %s\n", resp.Digest)
+	return nil
+}
+
+func update(cmd *cli.Cmd) error {
+	var resp *pb.UpdateStatusResponse
+	var err error
+	if from != "" {
+		resp, err = cmd.Client.UpdateHost(context.Background(), &pb.UpdateHostRequest{Url: from, Reboot: rebootAfter})
+	} else {
+		resp, err = cmd.Client.UpdateStatus(context.Background(), &pb.None{})
+	}
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Device", "State", "Priority", "Tries", "Successful", "Trial Deadline")
+		for _, p := range resp.Partitions {
+			name := p.Name
+			if p.Booted {
+				name += " (booted)"
+			}
+			deadline := "-"
+			if p.TrialDeadline > 0 {
+				deadline = time.Unix(p.TrialDeadline, 0).UTC().Format(time.RFC3339)
+			}
+			table.AddRow(name, p.Device, p.State, p.Priority, p.Tries, fmt.Sprintf("%t", p.Successful), deadline)
+		}
+		fmt.Printf("%s", table.Render())
+		if from != "" && rebootAfter {
+			fmt.Printf("The host is rebooting into the updated image\n")
+		}
+		return nil
+	})
+}
+
+func attest(cmd *cli.Cmd) error {
+	var n []byte
+	if nonce != "" {
+		var err error
+		if n, err = hex.DecodeString(nonce); err != nil {
+			return fmt.Errorf("Invalid nonce: %v", err)
+		}
+	} else {
+		n = make([]byte, 32)
+		if _, err := rand.Read(n); err != nil {
+			return err
+		}
+	}
+
+	resp, err := cmd.Client.AttestHost(context.Background(), &pb.AttestRequest{Nonce: n})
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		if err := os.MkdirAll(output, os.FileMode(0755)); err != nil {
+			return err
+		}
+		files := map[string][]byte{
+			"quote.msg": resp.Quote,
+			"quote.sig": resp.Signature,
+			"ak.pem":    []byte(resp.AttestationKey),
+			"nonce":     []byte(hex.EncodeToString(n)),
+		}
+		for name, data := range files {
+			if err := ioutil.WriteFile(filepath.Join(output, name), data, os.FileMode(0644)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return cli.Render(resp, func() error {
+		events := make([]*tpm.Event, 0, len(resp.Events))
+		measured := make(map[int]bool)
+		for _, e := range resp.Events {
+			events = append(events, &tpm.Event{PCR: int(e.Pcr), Description: e.Description, Digest: e.Digest})
+			measured[int(e.Pcr)] = true
+		}
+
+		table := termtables.CreateTable()
+		table.AddHeaders("PCR", "Value ("+resp.HashAlgorithm+")", "Log")
+		for _, p := range resp.Pcrs {
+			log := "-"
+			if measured[int(p.Index)] {
+				log = "matches"
+				if !bytes.Equal(tpm.Replay(int(p.Index), events), p.Digest) {
+					log = "does not match"
+				}
+			}
+			table.AddRow(p.Index, hex.EncodeToString(p.Digest), log)
+		}
+		fmt.Printf("%s", table.Render())
+
+		if len(resp.Events) > 0 {
+			table = termtables.CreateTable()
+			table.AddHeaders("PCR", "Digest", "Measured")
+			for _, e := range resp.Events {
+				table.AddRow(e.Pcr, hex.EncodeToString(e.Digest), e.Description)
+			}
+			fmt.Printf("\n%s", table.Render())
+		}
+		return nil
+	})
+}
+
+func reload(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.ReloadConfig(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Applied)+len(resp.RequiresRestart)+len(resp.Failed) == 0 {
+		fmt.Printf("The configuration is unchanged\n")
+		return nil
+	}
+	for _, msg := range resp.Applied {
+		fmt.Printf("Applied: %s\n", msg)
+	}
+	for _, msg := range resp.RequiresRestart {
+		fmt.Printf("Requires restart: %s\n", msg)
+	}
+	for _, msg := range resp.Failed {
+		fmt.Printf("Failed: %s\n", msg)
+	}
+	if len(resp.Failed) > 0 {
+		return fmt.Errorf("some changes failed to be applied")
+	}
+	return nil
+}
+
+func console(cmd *cli.Cmd) error {
+	// Set the local terminal in raw mode, and set it back to normal once the
+	// shell exits.
+	termios, err := raw.MakeRaw(os.Stdin.Fd())
+	if err != nil {
+		return err
+	}
+	defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+
+	opts := client.ExecOptions{
+		Tty:    true,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+		opts.Size = client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+	}
+
+	// Pass along any changes to the window size.
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	resize := make(chan client.WindowSize, 1)
+	go func() {
+		for _ = range winch {
+			if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+				resize <- client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+			}
+		}
+	}()
+	opts.Resize = resize
+
+	exitCode, err := client.New(cmd.Client).Console(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return &cli.ExitCodeError{Code: exitCode}
+	}
+	return nil
+}
+
+func status(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.HostStatus(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		var boot int64
+		for _, u := range resp.Units {
+			if u.Started != 0 && (boot == 0 || u.Started < boot) {
+				boot = u.Started
+			}
+		}
+
+		table := termtables.CreateTable()
+		table.AddHeaders("Unit", "State", "Started", "Duration", "Error")
+		for _, u := range resp.Units {
+			started, duration := "-", "-"
+			if u.Started != 0 {
+				started = "+" + formatDuration(u.Started-boot)
+			}
+			if u.Finished != 0 {
+				duration = formatDuration(u.Finished - u.Started)
+			} else if u.Started != 0 {
+				duration = formatDuration(time.Now().UnixNano()-u.Started) + " so far"
+			}
+			table.AddRow(u.Name, u.State, started, duration, u.Error)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+// formatDuration formats a duration in nanoseconds to the millisecond.
+func formatDuration(d int64) string {
+	return (time.Duration(d) / time.Millisecond * time.Millisecond).String()
+}
@@ -0,0 +1,57 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const imageExportHelp = `
+Usage: kurma-cli image export [-f FILE] URI
+
+Writes the ACI the host retrieved from the URI, as held in its image store, so
+that it can be copied to another host or inspected offline. Only images the
+host has retrieved to create containers are held, and uploaded images aren't.
+The image is written to stdout unless a file is given, and isn't written to a
+terminal.
+
+Options:
+  -f, --file FILE   Write the image to the file.
+`
+
+var (
+	file string
+)
+
+func init() {
+	cli.DefineCommand("image export", parseFlags, exportImage, cliExport, &cli.Help{
+		Summary: "Export an image from the host's image store",
+		Text:    imageExportHelp,
+		Examples: []string{
+			"kurma-cli image export -f busybox.aci https://example.com/busybox.aci",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&file, "file", "", "")
+	cmd.Flags.StringVar(&file, "f", "", "")
+}
+
+func cliExport(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func exportImage(cmd *cli.Cmd) error {
+	return cli.WriteExport(file, func(w io.Writer) error {
+		return client.New(cmd.Client).ExportImage(context.Background(), cmd.Args[0], w)
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const imagePushHelp = `
+Usage: kurma-cli image push [--insecure] URI [DESTINATION]
+
+Has the host push the image it holds in its image store under URI, such as an
+image it built or committed, to a remote registry. The DESTINATION is one of:
+
+  https://HOST/PATH     The ACI is uploaded to the URL with a PUT request.
+  docker://IMAGE:TAG    The image is converted to a Docker image, with its root
+                        filesystem as a single layer, and pushed to the Docker
+                        registry. Images without a registry go to Docker Hub.
+  NAME[:VERSION]        The ACI is uploaded to the URL found for the name and
+                        labels through appc discovery, with any labels not
+                        given taken from the image.
+
+Without a DESTINATION, the image is pushed through appc discovery under its own
+name and labels.
+
+Credentials for each registry are read from the client's configuration file,
+~/.kurma/config.json or the path in $KURMA_CONFIG, which maps the registry's
+host to them:
+
+  {"registries": {"docker.io": {"username": "USER", "password": "PASSWORD"}}}
+
+Options:
+  --insecure   Allow pushing to registries only served over plain HTTP.
+`
+
+var (
+	insecure bool
+)
+
+func init() {
+	cli.DefineCommand("image push", parsePushFlags, pushImage, cliPush, &cli.Help{
+		Summary: "Push an image from the host's image store to a registry",
+		Text:    imagePushHelp,
+		Examples: []string{
+			"kurma-cli image push example.com/web:1.2",
+			"kurma-cli image push example.com/web:1.2 docker://example/web:1.2",
+			"kurma-cli image push --insecure example.com/web:1.2 http://10.0.0.5/web.aci",
+		},
+	})
+}
+
+func parsePushFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&insecure, "insecure", false, "")
+}
+
+func cliPush(cmd *cli.Cmd) error {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func pushImage(cmd *cli.Cmd) error {
+	req := &pb.PushRequest{Uri: cmd.Args[0], Insecure: insecure}
+	if len(cmd.Args) == 2 {
+		req.Destination = cmd.Args[1]
+	}
+
+	cfg, err := client.LoadConfigFile(client.DefaultConfigFilePath())
+	if err != nil {
+		return err
+	}
+	destination := req.Destination
+	if destination == "" {
+		destination = req.Uri
+	}
+	creds := cfg.Credentials(destination)
+	req.Username, req.Password = creds.Username, creds.Password
+
+	var bar *cli.ProgressBar
+	location, err := client.New(cmd.Client).PushImage(context.Background(), req, func(sent, total int64) {
+		if bar == nil {
+			bar = cli.NewProgressBar(os.Stderr, "Pushing "+req.Uri, total)
+		}
+		bar.Set(sent)
+	})
+	if bar != nil {
+		bar.Done()
+	}
+	if err != nil {
+		return err
+	}
+	resp := &pb.PushProgress{Location: location}
+	return cli.Render(resp, func() error {
+		fmt.Printf("Pushed %s to %s\n", req.Uri, location)
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package info
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const infoHelp = `
+Usage: kurma-cli info
+
+Shows an overview of the Kurma server and its host: the version of Kurma it
+runs, the host's kernel and uptime, how containers' filesystems and cgroups are
+set up, the host's CPUs, memory, and the disk space left for containers, and
+how many containers are in each state. When the host admits containers by the
+CPU and memory they reserve, it shows how much of what they may reserve is
+reserved. The pressure is the percentage of the
+last 10 seconds tasks were stalled waiting on each resource, when the kernel
+reports it.
+`
+
+func init() {
+	cli.DefineCommand("info", parseFlags, info, cliInfo, &cli.Help{
+		Summary: "Show an overview of the server and its host",
+		Text:    infoHelp,
+		Examples: []string{
+			"kurma-cli info",
+			"kurma-cli info -o json",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliInfo(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func info(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.Info(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(resp, func() error {
+		table := termtables.CreateTable()
+		table.AddRow("Version", resp.Version)
+		table.AddRow("API Revisions", fmt.Sprintf("%d to %d", resp.MinApiVersion, resp.MaxApiVersion))
+		table.AddRow("Hostname", resp.Hostname)
+		table.AddRow("Kernel", resp.Kernel)
+		table.AddRow("Uptime", (time.Duration(resp.Uptime) * time.Second).String())
+		table.AddRow("Storage Driver", resp.StorageDriver)
+		table.AddRow("Runtimes", strings.Join(resp.Runtimes, ", "))
+		table.AddRow("Cgroup Root", resp.CgroupRoot)
+		if resp.CgroupVersion > 0 {
+			table.AddRow("Cgroup Version", fmt.Sprintf("%d", resp.CgroupVersion))
+		}
+		table.AddRow("Cgroup Controllers", strings.Join(resp.CgroupControllers, ", "))
+		table.AddRow("Parent Cgroup", resp.CgroupParent)
+		table.AddRow("CPUs", fmt.Sprintf("%d", resp.Cpus))
+		table.AddRow("Memory", fmt.Sprintf("%s available of %s",
+			cli.FormatBytes(resp.MemoryAvailable), cli.FormatBytes(resp.MemoryTotal)))
+		table.AddRow("Disk", fmt.Sprintf("%s available of %s",
+			cli.FormatBytes(resp.DiskAvailable), cli.FormatBytes(resp.DiskTotal)))
+		if resp.CpuAllocatable > 0 || resp.MemoryAllocatable > 0 {
+			table.AddRow("Reserved", fmt.Sprintf("%s of %s CPUs, %s of %s",
+				formatMillicores(resp.CpuReserved), formatMillicores(resp.CpuAllocatable),
+				cli.FormatBytes(resp.MemoryReserved), cli.FormatBytes(resp.MemoryAllocatable)))
+		}
+		if len(resp.Pressure) > 0 {
+			table.AddRow("Pressure", formatPressure(resp.Pressure))
+		}
+		table.AddRow("Containers", formatContainers(resp.Containers))
+		fmt.Printf("%s", table.Render())
+
+		if err := pb.CheckAPIVersion(resp); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return nil
+	})
+}
+
+// formatContainers formats the total number of containers along with how many
+// are in each state.
+func formatContainers(states map[string]int32) string {
+	if len(states) == 0 {
+		return "0"
+	}
+	names := make([]string, 0, len(states))
+	var total int32
+	for state, n := range states {
+		names = append(names, state)
+		total += n
+	}
+	sort.Strings(names)
+	counts := make([]string, 0, len(names))
+	for _, state := range names {
+		counts = append(counts, fmt.Sprintf("%d %s", states[state], strings.ToLower(state)))
+	}
+	return fmt.Sprintf("%d (%s)", total, strings.Join(counts, ", "))
+}
+
+// formatPressure formats the share of the last 10 seconds some of the tasks
+// were stalled waiting on each resource.
+func formatPressure(pressure []*pb.PressureStats) string {
+	parts := make([]string, len(pressure))
+	for i, p := range pressure {
+		parts[i] = fmt.Sprintf("%s %.2f%%", p.Resource, p.SomeAvg10)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMillicores formats millicores as a number of CPUs.
+func formatMillicores(n int64) string {
+	return strconv.FormatFloat(float64(n)/1000, 'f', -1, 64)
+}
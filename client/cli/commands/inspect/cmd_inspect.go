@@ -0,0 +1,327 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+	"github.com/appc/spec/schema"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const inspectHelp = `
+Usage: kurma-cli inspect [--json] [--format TEMPLATE] CONTAINER
+
+Displays the runtime details of a container, including its pod manifest, the
+state of its apps and the capabilities they were granted, its security label,
+its init process's pid, cgroups, network addresses, and the host paths mounted
+into it.
+
+Options:
+  --json              Output the details as JSON, like --output json.
+  --format TEMPLATE   Output the details using a Go template, such as
+                      '{{.Pid}}' or '{{range .Addresses}}{{.}} {{end}}', like
+                      --output go-template=TEMPLATE.
+`
+
+var (
+	jsonOutput bool
+	format     string
+)
+
+func init() {
+	cli.DefineCommand("inspect", parseFlags, inspect, cliInspect, &cli.Help{
+		Summary: "Display the runtime details of a container",
+		Text:    inspectHelp,
+		Examples: []string{
+			"kurma-cli inspect web",
+			"kurma-cli inspect --format '{{.Pid}}' web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&jsonOutput, "json", false, "")
+	cmd.Flags.StringVar(&format, "format", "", "")
+}
+
+func cliInspect(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if jsonOutput && format != "" {
+		return fmt.Errorf("Only one of --json and --format may be specified.")
+	}
+
+	// --json and --format predate the global --output flag, and override it
+	var err error
+	switch {
+	case jsonOutput:
+		err = cli.SetOutput(cli.OutputJSON)
+	case format != "":
+		err = cli.SetOutput("go-template=" + format)
+	}
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+// details is the information about a container which is rendered by the
+// command, and which templates given with --format are executed against.
+type details struct {
+	UUID          string              `json:"uuid"`
+	Name          string              `json:"name"`
+	Labels        map[string]string   `json:"labels,omitempty"`
+	State         string              `json:"state"`
+	StartTime     *time.Time          `json:"start_time,omitempty"`
+	Pid           int                 `json:"pid,omitempty"`
+	ExitCode      int                 `json:"exit_code"`
+	RestartPolicy string              `json:"restart_policy"`
+	Restarts      int                 `json:"restarts"`
+	OOMKills      int64               `json:"oom_kills"`
+	Privileged    bool                `json:"privileged"`
+	SecurityLabel string              `json:"security_label,omitempty"`
+	ReadOnly      bool                `json:"read_only_rootfs"`
+	Isolation     string              `json:"isolation"`
+	Runtime       string              `json:"runtime,omitempty"`
+	Priority      string              `json:"priority,omitempty"`
+	LogDriver     string              `json:"log_driver,omitempty"`
+	ExclusiveCPUs int                 `json:"exclusive_cpus,omitempty"`
+	NUMANode      *int                `json:"numa_node,omitempty"`
+	CPUs          string              `json:"cpus,omitempty"`
+	Mems          string              `json:"mems,omitempty"`
+	Apps          []appDetails        `json:"apps"`
+	Cgroups       []string            `json:"cgroups"`
+	Addresses     []string            `json:"addresses"`
+	Ports         []string            `json:"ports"`
+	Mounts        []mountDetails      `json:"mounts"`
+	Manifest      *schema.PodManifest `json:"manifest"`
+}
+
+type appDetails struct {
+	Name         string   `json:"name"`
+	State        string   `json:"state"`
+	ExitCode     int      `json:"exit_code"`
+	Restarts     int      `json:"restarts"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type mountDetails struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+func inspect(cmd *cli.Cmd) error {
+	req := &pb.ContainerRequest{Uuid: cmd.Args[0]}
+
+	resp, err := cmd.Client.Inspect(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	d, err := newDetails(resp)
+	if err != nil {
+		return err
+	}
+
+	return cli.Render(d, func() error {
+		return printDetails(d)
+	})
+}
+
+// newDetails converts the response into the details rendered by the command.
+func newDetails(resp *pb.InspectResponse) (*details, error) {
+	c := resp.Container
+	if c == nil {
+		return nil, fmt.Errorf("the response did not include the container")
+	}
+
+	var pod *schema.PodManifest
+	if err := json.Unmarshal(c.Manifest, &pod); err != nil {
+		return nil, err
+	}
+
+	d := &details{
+		UUID:          c.Uuid,
+		Name:          c.Name,
+		Labels:        c.Labels,
+		State:         c.State.String(),
+		Pid:           int(resp.Pid),
+		ExitCode:      int(resp.ExitCode),
+		RestartPolicy: c.RestartPolicy,
+		Restarts:      int(c.Restarts),
+		OOMKills:      resp.OomKills,
+		Privileged:    resp.Privileged,
+		SecurityLabel: resp.SecurityLabel,
+		ReadOnly:      resp.ReadOnlyRootfs,
+		Isolation:     resp.Isolation,
+		Runtime:       resp.Runtime,
+		Priority:      resp.Priority,
+		Apps:          make([]appDetails, len(c.Apps)),
+		Cgroups:       resp.Cgroups,
+		Addresses:     resp.Addresses,
+		Ports:         make([]string, len(c.Ports)),
+		Mounts:        make([]mountDetails, len(resp.Mounts)),
+		Manifest:      pod,
+	}
+	if resp.LogConfig != nil {
+		d.LogDriver = resp.LogConfig.Driver
+	}
+	if p := resp.CpuPlacement; p != nil {
+		d.ExclusiveCPUs = int(p.ExclusiveCpus)
+		if p.PinNumaNode {
+			node := int(p.NumaNode)
+			d.NUMANode = &node
+		}
+		d.CPUs = p.Cpus
+		d.Mems = p.Mems
+	}
+	if resp.StartTime != 0 {
+		t := time.Unix(resp.StartTime, 0)
+		d.StartTime = &t
+	}
+	capabilities := make(map[string][]string, len(resp.Capabilities))
+	for _, ac := range resp.Capabilities {
+		capabilities[ac.App] = ac.Capabilities
+	}
+	for i, app := range c.Apps {
+		d.Apps[i] = appDetails{
+			Name:         app.Name,
+			State:        app.State.String(),
+			ExitCode:     int(app.ExitCode),
+			Restarts:     int(app.Restarts),
+			Capabilities: capabilities[app.Name],
+		}
+	}
+	for i, p := range c.Ports {
+		d.Ports[i] = fmt.Sprintf("%d->%d/%s", p.HostPort, p.ContainerPort, p.Protocol)
+	}
+	for i, m := range resp.Mounts {
+		d.Mounts[i] = mountDetails{
+			Source:      m.Source,
+			Destination: m.Destination,
+			ReadOnly:    m.ReadOnly,
+		}
+	}
+	return d, nil
+}
+
+// printDetails renders the details as a set of tables, followed by the pod
+// manifest.
+func printDetails(d *details) error {
+	startTime := ""
+	if d.StartTime != nil {
+		startTime = d.StartTime.Format(time.RFC3339)
+	}
+	pid := ""
+	if d.Pid != 0 {
+		pid = fmt.Sprintf("%d", d.Pid)
+	}
+	exitCode := ""
+	if d.State == pb.Container_EXITED.String() {
+		exitCode = fmt.Sprintf("%d", d.ExitCode)
+	}
+	restart := d.RestartPolicy
+	if d.Restarts > 0 {
+		restart = fmt.Sprintf("%s (%d)", restart, d.Restarts)
+	}
+	cpus := d.CPUs
+	if d.ExclusiveCPUs > 0 && cpus != "" {
+		cpus += " (exclusive)"
+	}
+	mems := d.Mems
+	if d.NUMANode != nil && mems != "" {
+		mems += " (pinned)"
+	}
+
+	table := termtables.CreateTable()
+	table.AddRow("UUID", d.UUID)
+	table.AddRow("Name", d.Name)
+	table.AddRow("State", d.State)
+	table.AddRow("Started", startTime)
+	table.AddRow("Pid", pid)
+	table.AddRow("Exit Code", exitCode)
+	table.AddRow("Restart", restart)
+	table.AddRow("OOM Kills", fmt.Sprintf("%d", d.OOMKills))
+	table.AddRow("Privileged", fmt.Sprintf("%t", d.Privileged))
+	table.AddRow("Security Label", d.SecurityLabel)
+	table.AddRow("Read-only Root", fmt.Sprintf("%t", d.ReadOnly))
+	table.AddRow("Isolation", d.Isolation)
+	table.AddRow("Runtime", d.Runtime)
+	table.AddRow("Priority", d.Priority)
+	table.AddRow("Log Driver", d.LogDriver)
+	table.AddRow("CPUs", cpus)
+	table.AddRow("Memory Nodes", mems)
+	table.AddRow("Addresses", strings.Join(d.Addresses, ", "))
+	table.AddRow("Ports", strings.Join(d.Ports, ", "))
+	labels := make([]string, 0, len(d.Labels))
+	for key, value := range d.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	for i, l := range labels {
+		label := ""
+		if i == 0 {
+			label = "Labels"
+		}
+		table.AddRow(label, l)
+	}
+	for i, cgroup := range d.Cgroups {
+		label := ""
+		if i == 0 {
+			label = "Cgroups"
+		}
+		table.AddRow(label, cgroup)
+	}
+	fmt.Printf("%s\n", table.Render())
+
+	table = termtables.CreateTable()
+	table.AddHeaders("App", "State", "Exit Code", "Restarts")
+	for _, app := range d.Apps {
+		exitCode := ""
+		if app.State == pb.Container_EXITED.String() {
+			exitCode = fmt.Sprintf("%d", app.ExitCode)
+		}
+		table.AddRow(app.Name, app.State, exitCode, app.Restarts)
+	}
+	fmt.Printf("%s\n", table.Render())
+
+	table = termtables.CreateTable()
+	table.AddHeaders("App", "Capabilities")
+	for _, app := range d.Apps {
+		for i, c := range app.Capabilities {
+			name := ""
+			if i == 0 {
+				name = app.Name
+			}
+			table.AddRow(name, c)
+		}
+	}
+	fmt.Printf("%s\n", table.Render())
+
+	if len(d.Mounts) > 0 {
+		table = termtables.CreateTable()
+		table.AddHeaders("Source", "Destination", "Read Only")
+		for _, m := range d.Mounts {
+			table.AddRow(m.Source, m.Destination, m.ReadOnly)
+		}
+		fmt.Printf("%s\n", table.Render())
+	}
+
+	b, err := json.MarshalIndent(d.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", string(b))
+	return nil
+}
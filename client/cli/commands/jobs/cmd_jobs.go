@@ -0,0 +1,221 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/kurma/client/cli/commands/create"
+	"github.com/apcera/termtables"
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+const jobsAddHelp = `
+Usage: kurma-cli jobs add [CREATE OPTIONS] NAME SCHEDULE IMAGE
+
+Schedules a container to be created from the image each time the schedule
+matches. The host keeps the history of each job's most recent runs, which is
+shown by "kurma-cli jobs list". A run's container is left in place once it
+exits, so its output can be read, until the job's next run replaces it. If a
+run is still going when the job comes due again, that time is skipped.
+
+The schedule is a cron expression of five fields, the minute, hour, day of the
+month, month, and day of the week, and must be quoted. Each field is *, a
+value, a range such as 1-5, or a list of them such as 1,15, and * and ranges
+may take a step such as */15. Months and days may be given by name, such as
+jan or mon. The shorthands @hourly, @daily, @weekly, @monthly, and @yearly
+may be used instead. Schedules are in the host's time zone.
+
+The image must be a url or an image name the server retrieves, and the
+container's settings are given with the same options as the create command,
+before the job's name.
+`
+
+const jobsListHelp = `
+Usage: kurma-cli jobs list
+
+Lists the scheduled jobs, with when each is next run and the outcome of its
+last run.
+`
+
+const jobsRemoveHelp = `
+Usage: kurma-cli jobs rm NAME
+
+Removes the job, so it is no longer run. A run which is in progress is left to
+finish, and the job's last container is left in place.
+`
+
+func init() {
+	cli.DefineCommand("jobs add", create.ParseContainerFlags, jobsAdd, cliJobsAdd, &cli.Help{
+		Summary: "Schedule a container to run periodically",
+		Text:    jobsAddHelp,
+		Examples: []string{
+			`kurma-cli jobs add backup "30 2 * * *" example.com/backup:1.0`,
+			`kurma-cli jobs add --volume data:/data report "*/15 9-17 * * mon-fri" docker://example/report`,
+		},
+	})
+	cli.DefineCommand("jobs list", parseJobsFlags, jobsList, cliJobsList, &cli.Help{
+		Summary: "List the scheduled jobs",
+		Text:    jobsListHelp,
+		Examples: []string{
+			"kurma-cli jobs list",
+		},
+	})
+	cli.DefineCommand("jobs rm", parseJobsFlags, jobsRemove, cliJobsRemove, &cli.Help{
+		Summary: "Remove a scheduled job",
+		Text:    jobsRemoveHelp,
+		Examples: []string{
+			"kurma-cli jobs rm backup",
+		},
+		Args: cli.ArgsJob,
+	})
+}
+
+func parseJobsFlags(cmd *cli.Cmd) {
+}
+
+func cliJobsAdd(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 3 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if err := create.CheckContainerFlags(); err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+func cliJobsList(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliJobsRemove(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func jobsAdd(cmd *cli.Cmd) error {
+	name, schedule, image := cmd.Args[0], cmd.Args[1], cmd.Args[2]
+	if _, err := os.Stat(image); err == nil || create.IsLocalPath(image) {
+		return fmt.Errorf("jobs can only run images the server retrieves, not local files")
+	}
+
+	req, err := create.ImageRequest(image)
+	if err != nil {
+		return err
+	}
+	if err := client.New(cmd.Client).AddJob(context.Background(), name, schedule, req); err != nil {
+		return err
+	}
+	fmt.Printf("Added job %s\n", name)
+	return nil
+}
+
+// jobSummary is a job as it is output.
+type jobSummary struct {
+	Name     string       `json:"name"`
+	Schedule string       `json:"schedule"`
+	Image    string       `json:"image"`
+	Created  time.Time    `json:"created"`
+	NextRun  *time.Time   `json:"next_run,omitempty"`
+	Running  bool         `json:"running"`
+	Runs     []runSummary `json:"runs"`
+}
+
+// runSummary is one of a job's runs as it is output.
+type runSummary struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Container string    `json:"container,omitempty"`
+	ExitCode  int       `json:"exit_code"`
+	OOMKilled bool      `json:"oom_killed,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func jobsList(cmd *cli.Cmd) error {
+	jobs, err := client.New(cmd.Client).ListJobs(context.Background())
+	if err != nil {
+		return err
+	}
+
+	summaries := make([]*jobSummary, len(jobs))
+	for i, j := range jobs {
+		summaries[i] = summarizeJob(j)
+	}
+	return cli.Render(summaries, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Schedule", "Image", "Next Run", "Last Run", "Result")
+		for _, j := range summaries {
+			next := ""
+			if j.NextRun != nil {
+				next = j.NextRun.Format(time.RFC3339)
+			}
+			last, result := "", ""
+			if len(j.Runs) > 0 {
+				r := j.Runs[len(j.Runs)-1]
+				last = r.Start.Format(time.RFC3339)
+				switch {
+				case r.Error != "":
+					result = "failed: " + r.Error
+				case r.OOMKilled:
+					result = fmt.Sprintf("exited %d, out of memory", r.ExitCode)
+				default:
+					result = fmt.Sprintf("exited %d", r.ExitCode)
+				}
+			}
+			if j.Running {
+				result = "running"
+			}
+			table.AddRow(j.Name, j.Schedule, j.Image, next, last, result)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+// summarizeJob converts the job to how it is output.
+func summarizeJob(j *pb.Job) *jobSummary {
+	s := &jobSummary{
+		Name:     j.Name,
+		Schedule: j.Schedule,
+		Created:  time.Unix(j.Created, 0),
+		Running:  j.Running,
+		Runs:     make([]runSummary, len(j.Runs)),
+	}
+	if j.Create != nil {
+		s.Image = j.Create.ImageUri
+	}
+	if j.NextRun != 0 {
+		t := time.Unix(j.NextRun, 0)
+		s.NextRun = &t
+	}
+	for i, r := range j.Runs {
+		s.Runs[i] = runSummary{
+			Start:     time.Unix(r.Start, 0),
+			End:       time.Unix(r.End, 0),
+			Container: r.Container,
+			ExitCode:  int(r.ExitCode),
+			OOMKilled: r.OomKilled,
+			Error:     r.Error,
+		}
+	}
+	return s
+}
+
+func jobsRemove(cmd *cli.Cmd) error {
+	if err := client.New(cmd.Client).RemoveJob(context.Background(), cmd.Args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Removed job %s\n", cmd.Args[0])
+	return nil
+}
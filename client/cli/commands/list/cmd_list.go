@@ -5,7 +5,11 @@ package list
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 
+	"github.com/apcera/kurma/client"
 	"github.com/apcera/kurma/client/cli"
 	"github.com/apcera/termtables"
 	"github.com/appc/spec/schema"
@@ -14,11 +18,61 @@ import (
 	"golang.org/x/net/context"
 )
 
+const listHelp = `
+Usage: kurma-cli list [--filter KEY=VALUE]... [--all-nodes]
+
+Lists the containers on the host, with their names, apps, and states. With
+--output json or go-template=TEMPLATE, the containers are output as a list,
+which a template may range over, as in
+'{{range .}}{{.Name}} {{.State}}{{"\n"}}{{end}}'.
+
+Options:
+  --filter   Only list the containers matching the filter. The key may be
+             state, name, which matches a pattern such as "web-*", uuid,
+             which matches a prefix, or health. Any other key selects by the
+             label, or by whether the container has it if no value is given.
+             Labels with those keys are selected by label=KEY=VALUE. Filters
+             of the same key are alternatives, while those of different keys
+             must all match. May be given multiple times.
+  --all-nodes
+             List the containers of every host in the server's cluster,
+             along with the node each is on. Nodes which can't be reached
+             are reported and skipped.
+`
+
+// filterFlags collects the filters given with --filter.
+type filterFlags []string
+
+func (f *filterFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *filterFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+var (
+	filters  filterFlags
+	allNodes bool
+)
+
 func init() {
-	cli.DefineCommand("list", parseFlags, list, cliList, "FIXME")
+	cli.DefineCommand("list", parseFlags, list, cliList, &cli.Help{
+		Summary: "List the containers on the host",
+		Text:    listHelp,
+		Examples: []string{
+			"kurma-cli list",
+			"kurma-cli list --filter state=running --filter env=prod",
+			"kurma-cli list -o 'go-template={{range .}}{{.Name}} {{end}}'",
+			"kurma-cli list --all-nodes --filter state=running",
+		},
+	})
 }
 
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.Var(&filters, "filter", "")
+	cmd.Flags.BoolVar(&allNodes, "all-nodes", false, "")
 }
 
 func cliList(cmd *cli.Cmd) error {
@@ -28,29 +82,142 @@ func cliList(cmd *cli.Cmd) error {
 	return cmd.Run()
 }
 
+// summary is the information about a container which is listed, and which is
+// output as JSON or given to the --output template.
+type summary struct {
+	UUID          string            `json:"uuid"`
+	Name          string            `json:"name"`
+	Apps          []string          `json:"apps"`
+	State         string            `json:"state"`
+	Health        string            `json:"health,omitempty"`
+	RestartPolicy string            `json:"restart_policy"`
+	Restarts      int               `json:"restarts"`
+	Ports         []string          `json:"ports"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Node          string            `json:"node,omitempty"`
+}
+
 func list(cmd *cli.Cmd) error {
-	resp, err := cmd.Client.List(context.Background(), &pb.None{})
-	if err != nil {
-		return err
+	var summaries []*summary
+	if allNodes {
+		var err error
+		if summaries, err = listNodes(cmd); err != nil {
+			return err
+		}
+	} else {
+		resp, err := cmd.Client.List(context.Background(), &pb.ListRequest{Filters: filters})
+		if err != nil {
+			return err
+		}
+		if summaries, err = summarize(resp.Containers, ""); err != nil {
+			return err
+		}
 	}
 
-	// create the table
-	table := termtables.CreateTable()
-
-	table.AddHeaders("UUID", "Name", "State")
+	return cli.Render(summaries, func() error {
+		table := termtables.CreateTable()
+		headers := []interface{}{"UUID", "Name", "Apps", "State", "Health", "Restart", "Ports"}
+		if allNodes {
+			headers = append([]interface{}{"Node"}, headers...)
+		}
+		table.AddHeaders(headers...)
+		for _, s := range summaries {
+			restart := s.RestartPolicy
+			if s.Restarts > 0 {
+				restart = fmt.Sprintf("%s (%d)", restart, s.Restarts)
+			}
+			health := s.Health
+			if health == "" {
+				health = "-"
+			}
+			row := []interface{}{s.UUID, s.Name, strings.Join(s.Apps, ", "), s.State, health, restart, strings.Join(s.Ports, ", ")}
+			if allNodes {
+				row = append([]interface{}{s.Node}, row...)
+			}
+			table.AddRow(row...)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
 
-	for _, container := range resp.Containers {
+// summarize returns the summaries of the containers, which are on the node.
+func summarize(containers []*pb.Container, node string) ([]*summary, error) {
+	summaries := make([]*summary, len(containers))
+	for i, container := range containers {
 		var pod *schema.PodManifest
 		if err := json.Unmarshal(container.Manifest, &pod); err != nil {
-			return err
+			return nil, err
 		}
-		var appName string
-		for _, app := range pod.Apps {
-			appName = app.Name.String()
-			break
+		s := &summary{
+			UUID:          container.Uuid,
+			Name:          container.Name,
+			Apps:          make([]string, len(pod.Apps)),
+			State:         container.State.String(),
+			Health:        container.Health,
+			RestartPolicy: container.RestartPolicy,
+			Restarts:      int(container.Restarts),
+			Ports:         make([]string, len(container.Ports)),
+			Labels:        container.Labels,
+			Node:          node,
 		}
-		table.AddRow(container.Uuid, appName, container.State.String())
+		for i, app := range pod.Apps {
+			s.Apps[i] = app.Name.String()
+		}
+		for i, p := range container.Ports {
+			s.Ports[i] = fmt.Sprintf("%d->%d/%s", p.HostPort, p.ContainerPort, p.Protocol)
+		}
+		summaries[i] = s
 	}
-	fmt.Printf("%s", table.Render())
-	return nil
+	return summaries, nil
+}
+
+// listNodes returns the summaries of the containers on each of the nodes in
+// the server's cluster, which are queried concurrently.
+func listNodes(cmd *cli.Cmd) ([]*summary, error) {
+	nodes, err := client.New(cmd.Client).ListNodes(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]*summary, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *pb.Node) {
+			defer wg.Done()
+			results[i], errs[i] = listNode(cmd, n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var summaries []*summary
+	for i, n := range nodes {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list the containers on node %s: %v\n", n.Name, errs[i])
+			continue
+		}
+		summaries = append(summaries, results[i]...)
+	}
+	if summaries == nil {
+		summaries = []*summary{}
+	}
+	return summaries, nil
+}
+
+// listNode returns the summaries of the containers on the node.
+func listNode(cmd *cli.Cmd, n *pb.Node) ([]*summary, error) {
+	c, done, err := cli.NodeClient(cmd, n)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), cli.NodeTimeout)
+	defer cancel()
+	resp, err := c.List(ctx, &pb.ListRequest{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+	return summarize(resp.Containers, n.Name)
 }
@@ -0,0 +1,92 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const logsHelp = `
+Usage: kurma-cli logs [-f] [--tail N] [--since TIME] CONTAINER
+
+Displays the stdout and stderr output of the application within a container.
+Only output captured by the json-file log driver is available.
+
+Options:
+  -f, --follow   Continue streaming new output until the container exits.
+  --tail N       Only show the last N lines of output.
+  --since TIME   Only show output written since TIME, which may be a duration
+                 (such as 10m) or an RFC3339 timestamp.
+`
+
+var (
+	follow bool
+	tail   int
+	since  string
+)
+
+func init() {
+	cli.DefineCommand("logs", parseFlags, logs, cliLogs, &cli.Help{
+		Summary: "Display the output of a container's apps",
+		Text:    logsHelp,
+		Examples: []string{
+			"kurma-cli logs web",
+			"kurma-cli logs -f --tail 100 web",
+			"kurma-cli logs --since 10m web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&follow, "follow", false, "")
+	cmd.Flags.BoolVar(&follow, "f", false, "")
+	cmd.Flags.IntVar(&tail, "tail", 0, "")
+	cmd.Flags.StringVar(&since, "since", "", "")
+}
+
+func cliLogs(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if tail < 0 {
+		return fmt.Errorf("The --tail value must not be negative.")
+	}
+	return cmd.Run()
+}
+
+func logs(cmd *cli.Cmd) error {
+	opts := client.LogsOptions{
+		Follow: follow,
+		Tail:   tail,
+	}
+
+	if since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			return err
+		}
+		opts.Since = t
+	}
+
+	return client.New(cmd.Client).Logs(context.Background(), cmd.Args[0], opts, os.Stdout, os.Stderr)
+}
+
+// parseSince converts the --since value into a time. It accepts either a
+// duration relative to now or an RFC3339 timestamp.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value %q", s)
+	}
+	return t, nil
+}
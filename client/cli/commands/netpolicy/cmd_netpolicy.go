@@ -0,0 +1,347 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package netpolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+const setHelp = `
+Usage: kurma-cli netpolicy set -f FILE
+
+Adds the network policies in the file, or stdin if the file is "-", replacing
+any with the same names. The file is JSON, with either a policy or a list of
+them:
+
+  {
+    "name": "isolate-db",
+    "selector": {"tier": "db"},
+    "default": "deny",
+    "rules": [
+      {"action": "allow", "from": {"tier": "web"}, "protocol": "tcp", "port": 5432}
+    ]
+  }
+
+A policy applies to the traffic from containers to those with all of the
+selector's labels, or to every container if it has no selector. With "host"
+set to true, it applies to the traffic from containers to the host instead,
+including to its DNS server and metadata service.
+
+The traffic to a container is checked against the rules of each policy which
+applies to it, in the order of the policies' names, and the first rule to
+match decides whether it is allowed. A rule matches the traffic from the
+containers with all of its "from" labels, or from any container if it has
+none, and may be limited to a protocol, tcp, udp, or icmp, and for tcp and udp,
+a port. Traffic which matches no rule is allowed, unless one of the policies
+has a default of "deny". Replies to allowed traffic, and traffic from the host
+to the containers, are always allowed.
+
+The output of "kurma-cli netpolicy list -o json" may be given as the file.
+
+Options:
+  -f, --file   The file with the policies.
+`
+
+const listHelp = `
+Usage: kurma-cli netpolicy list
+
+Lists the network policies, with what each applies to, how many rules it has,
+and its default.
+`
+
+const removeHelp = `
+Usage: kurma-cli netpolicy rm NAME
+
+Removes the network policy, lifting the restrictions it made.
+`
+
+var (
+	policyFile string
+)
+
+func init() {
+	cli.DefineCommand("netpolicy set", parseSetFlags, set, cliSet, &cli.Help{
+		Summary: "Add or replace network policies",
+		Text:    setHelp,
+		Examples: []string{
+			"kurma-cli netpolicy set -f isolate-db.json",
+		},
+		Args: cli.ArgsFile,
+	})
+	cli.DefineCommand("netpolicy list", parseFlags, list, cliList, &cli.Help{
+		Summary: "List the network policies",
+		Text:    listHelp,
+		Examples: []string{
+			"kurma-cli netpolicy list",
+		},
+	})
+	cli.DefineCommand("netpolicy rm", parseFlags, remove, cliRemove, &cli.Help{
+		Summary: "Remove a network policy",
+		Text:    removeHelp,
+		Examples: []string{
+			"kurma-cli netpolicy rm isolate-db",
+		},
+		Args: cli.ArgsNetworkPolicy,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func parseSetFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&policyFile, "file", "", "")
+	cmd.Flags.StringVar(&policyFile, "f", "", "")
+}
+
+func cliSet(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 || policyFile == "" {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliList(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliRemove(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+// policy is a network policy as it is read from a file and output.
+type policy struct {
+	Name     string            `json:"name"`
+	Selector map[string]string `json:"selector,omitempty"`
+	Host     bool              `json:"host,omitempty"`
+	Rules    []*rule           `json:"rules,omitempty"`
+	Default  string            `json:"default,omitempty"`
+}
+
+type rule struct {
+	Action   string            `json:"action"`
+	From     map[string]string `json:"from,omitempty"`
+	Protocol string            `json:"protocol,omitempty"`
+	Port     int               `json:"port,omitempty"`
+}
+
+func set(cmd *cli.Cmd) error {
+	policies, err := readPolicies(policyFile)
+	if err != nil {
+		return err
+	}
+	c := client.New(cmd.Client)
+	for _, p := range policies {
+		pbp, err := p.pb()
+		if err != nil {
+			return fmt.Errorf("policy %q: %v", p.Name, err)
+		}
+		if err := c.SetNetworkPolicy(context.Background(), pbp); err != nil {
+			return fmt.Errorf("failed to set policy %q: %v", p.Name, err)
+		}
+		fmt.Printf("Set network policy %s\n", p.Name)
+	}
+	return nil
+}
+
+// readPolicies reads the policy, or list of them, in the file.
+func readPolicies(path string) ([]*policy, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []json.RawMessage
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &raws); err != nil {
+			return nil, fmt.Errorf("invalid policy file: %v", err)
+		}
+	} else {
+		raws = []json.RawMessage{b}
+	}
+
+	policies := make([]*policy, len(raws))
+	for i, raw := range raws {
+		var p policy
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("invalid policy %d: %v", i+1, err)
+		}
+		// a misspelled key would silently leave a policy more open than
+		// intended, so they're refused
+		if err := checkKeys(raw, p.Rules); err != nil {
+			return nil, fmt.Errorf("invalid policy %d: %v", i+1, err)
+		}
+		policies[i] = &p
+	}
+	return policies, nil
+}
+
+// checkKeys returns an error if the policy, or any of its rules, has a key
+// which isn't known.
+func checkKeys(raw json.RawMessage, rules []*rule) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return err
+	}
+	if err := knownKeys(keys, "name", "selector", "host", "rules", "default"); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	var rawRules []map[string]json.RawMessage
+	if err := json.Unmarshal(keys["rules"], &rawRules); err != nil {
+		return err
+	}
+	for i, r := range rawRules {
+		if err := knownKeys(r, "action", "from", "protocol", "port"); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func knownKeys(keys map[string]json.RawMessage, known ...string) error {
+	for key := range keys {
+		found := false
+		for _, k := range known {
+			if key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// pb converts the policy to its protobuf equivalent.
+func (p *policy) pb() (*pb.NetworkPolicy, error) {
+	pbp := &pb.NetworkPolicy{
+		Name:     p.Name,
+		Selector: p.Selector,
+		Host:     p.Host,
+	}
+	switch p.Default {
+	case "", "allow":
+	case "deny":
+		pbp.DefaultDeny = true
+	default:
+		return nil, fmt.Errorf("the default %q must be allow or deny", p.Default)
+	}
+	for i, r := range p.Rules {
+		pbr := &pb.NetworkPolicyRule{
+			From:     r.From,
+			Protocol: r.Protocol,
+			Port:     int32(r.Port),
+		}
+		switch r.Action {
+		case "allow":
+			pbr.Action = pb.NetworkPolicyRule_ALLOW
+		case "deny":
+			pbr.Action = pb.NetworkPolicyRule_DENY
+		default:
+			return nil, fmt.Errorf("the action %q of rule %d must be allow or deny", r.Action, i+1)
+		}
+		pbp.Rules = append(pbp.Rules, pbr)
+	}
+	return pbp, nil
+}
+
+// policyFromPB converts a policy from the server to the form it's output in.
+func policyFromPB(pbp *pb.NetworkPolicy) *policy {
+	p := &policy{
+		Name:     pbp.Name,
+		Selector: pbp.Selector,
+		Host:     pbp.Host,
+		Default:  "allow",
+	}
+	if pbp.DefaultDeny {
+		p.Default = "deny"
+	}
+	for _, pbr := range pbp.Rules {
+		r := &rule{
+			Action:   strings.ToLower(pbr.Action.String()),
+			From:     pbr.From,
+			Protocol: pbr.Protocol,
+			Port:     int(pbr.Port),
+		}
+		p.Rules = append(p.Rules, r)
+	}
+	return p
+}
+
+func list(cmd *cli.Cmd) error {
+	pbps, err := client.New(cmd.Client).ListNetworkPolicies(context.Background())
+	if err != nil {
+		return err
+	}
+	policies := make([]*policy, len(pbps))
+	for i, pbp := range pbps {
+		policies[i] = policyFromPB(pbp)
+	}
+
+	return cli.Render(policies, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Applies To", "Rules", "Default")
+		for _, p := range policies {
+			table.AddRow(p.Name, appliesTo(p), len(p.Rules), p.Default)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+// appliesTo describes the traffic the policy applies to.
+func appliesTo(p *policy) string {
+	if p.Host {
+		return "the host"
+	}
+	if len(p.Selector) == 0 {
+		return "all containers"
+	}
+	labels := make([]string, 0, len(p.Selector))
+	for key, value := range p.Selector {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+func remove(cmd *cli.Cmd) error {
+	if err := client.New(cmd.Client).RemoveNetworkPolicy(context.Background(), cmd.Args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Removed network policy %s\n", cmd.Args[0])
+	return nil
+}
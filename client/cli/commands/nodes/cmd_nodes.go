@@ -0,0 +1,118 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package nodes
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const nodesHelp = `
+Usage: kurma-cli nodes
+
+Lists the hosts in the cluster of the Kurma server, with the endpoint each is
+reached at, the version of Kurma it runs, and how many containers it has. The
+peers of each host are listed in the cluster section of its configuration.
+Any node can be sent commands with the --node option, through the server
+named by --host, and "kurma-cli list --all-nodes" lists the containers across
+all of them. Nodes which can't be reached are shown as unreachable.
+`
+
+func init() {
+	cli.DefineCommand("nodes", parseFlags, nodes, cliNodes, &cli.Help{
+		Summary: "List the hosts in the server's cluster",
+		Text:    nodesHelp,
+		Examples: []string{
+			"kurma-cli nodes",
+			"kurma-cli --node host-b list",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliNodes(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+// node is a host in the cluster as it is output.
+type node struct {
+	Name       string `json:"name"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Self       bool   `json:"self"`
+	Reachable  bool   `json:"reachable"`
+	Version    string `json:"version,omitempty"`
+	Containers int    `json:"containers"`
+	Error      string `json:"error,omitempty"`
+}
+
+func nodes(cmd *cli.Cmd) error {
+	list, err := client.New(cmd.Client).ListNodes(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// query the nodes concurrently, so unreachable ones only delay the list
+	// once
+	out := make([]*node, len(list))
+	var wg sync.WaitGroup
+	for i, n := range list {
+		out[i] = &node{Name: n.Name, Endpoint: n.Endpoint, Self: n.Self}
+		wg.Add(1)
+		go func(n *pb.Node, o *node) {
+			defer wg.Done()
+			info, err := nodeInfo(cmd, n)
+			if err != nil {
+				o.Error = err.Error()
+				return
+			}
+			o.Reachable = true
+			o.Version = info.Version
+			for _, count := range info.Containers {
+				o.Containers += int(count)
+			}
+		}(n, out[i])
+	}
+	wg.Wait()
+
+	return cli.Render(out, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name", "Endpoint", "Status", "Version", "Containers")
+		for _, n := range out {
+			endpoint := n.Endpoint
+			if n.Self {
+				endpoint = "(this server)"
+			}
+			if !n.Reachable {
+				table.AddRow(n.Name, endpoint, "unreachable", "", "")
+				continue
+			}
+			table.AddRow(n.Name, endpoint, "ready", n.Version, n.Containers)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+// nodeInfo connects to the node and returns its info.
+func nodeInfo(cmd *cli.Cmd, n *pb.Node) (*pb.InfoResponse, error) {
+	c, done, err := cli.NodeClient(cmd, n)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), cli.NodeTimeout)
+	defer cancel()
+	return c.Info(ctx, &pb.None{})
+}
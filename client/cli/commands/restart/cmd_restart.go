@@ -0,0 +1,68 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package restart
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const restartHelp = `
+Usage: kurma-cli restart [-t SECONDS] CONTAINER
+
+Stops the apps within a container, if they're running, and starts them again.
+
+Options:
+  -t, --time SECONDS   The grace period to give the apps to exit before they
+                       are killed. Defaults to the container's stop grace
+                       period.
+`
+
+var (
+	gracePeriod int
+)
+
+func init() {
+	cli.DefineCommand("restart", parseFlags, restart, cliRestart, &cli.Help{
+		Summary: "Restart the apps within a container",
+		Text:    restartHelp,
+		Examples: []string{
+			"kurma-cli restart web",
+			"kurma-cli restart -t 5 web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.IntVar(&gracePeriod, "time", 0, "")
+	cmd.Flags.IntVar(&gracePeriod, "t", 0, "")
+}
+
+func cliRestart(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("The --time value must not be negative.")
+	}
+	return cmd.Run()
+}
+
+func restart(cmd *cli.Cmd) error {
+	req := &pb.StopRequest{
+		Uuid:        cmd.Args[0],
+		GracePeriod: int32(gracePeriod),
+	}
+
+	if _, err := cmd.Client.Restart(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restarted container %s\n", cmd.Args[0])
+	return nil
+}
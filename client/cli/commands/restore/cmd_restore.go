@@ -0,0 +1,51 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package restore
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const restoreHelp = `
+Usage: kurma-cli restore CONTAINER
+
+Restores a checkpointed container from the checkpoint in its directory. The
+directory may have been copied from another host.
+`
+
+func init() {
+	cli.DefineCommand("restore", parseFlags, restore, cliRestore, &cli.Help{
+		Summary: "Restore a checkpointed container",
+		Text:    restoreHelp,
+		Examples: []string{
+			"kurma-cli restore web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliRestore(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func restore(cmd *cli.Cmd) error {
+	req := &pb.ContainerRequest{Uuid: cmd.Args[0]}
+
+	if _, err := cmd.Client.Restore(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored container %s\n", cmd.Args[0])
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/kurma/client/cli/commands/create"
+	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
+	"golang.org/x/net/context"
+)
+
+const runHelp = `
+Usage: kurma-cli run [-i] [-t] [--rm] [CREATE OPTIONS] IMAGE
+
+Creates a container from the specified image and attaches to it, streaming its
+output to the local terminal until it exits. The CLI then exits with the
+container's exit code.
+
+The image and the container's settings are specified as with the create
+command, which lists the other options. Only the first app's input is taken.
+
+Options:
+  -i, --interactive
+               Keep the app's stdin open and stream the local stdin to it.
+  -t, --tty    Run the app with a console, which the local terminal is
+               attached to.
+  --rm         Destroy the container once it exits.
+`
+
+var (
+	interactive bool
+	tty         bool
+	remove      bool
+)
+
+func init() {
+	cli.DefineCommand("run", parseRunFlags, run, cliRun, &cli.Help{
+		Summary: "Create a container and attach to it until it exits",
+		Text:    runHelp,
+		Examples: []string{
+			"kurma-cli run --rm example.com/batch:1.0",
+			"# run an interactive shell",
+			"kurma-cli run -i -t --rm example.com/busybox:1.0",
+		},
+		Args: cli.ArgsFile,
+	})
+}
+
+func parseRunFlags(cmd *cli.Cmd) {
+	create.ParseContainerFlags(cmd)
+	cmd.Flags.BoolVar(&interactive, "interactive", false, "")
+	cmd.Flags.BoolVar(&interactive, "i", false, "")
+	cmd.Flags.BoolVar(&tty, "tty", false, "")
+	cmd.Flags.BoolVar(&tty, "t", false, "")
+	cmd.Flags.BoolVar(&remove, "rm", false, "")
+}
+
+func cliRun(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if err := create.CheckContainerFlags(); err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+func run(cmd *cli.Cmd) error {
+	req, err := create.Request()
+	if err != nil {
+		return err
+	}
+	req.Tty = tty
+	req.Stdin = interactive
+	req.Attach = true
+
+	container, err := create.CreateContainer(cmd, req, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	c := client.New(cmd.Client)
+	ctx := context.Background()
+
+	exitCode, err := attachAndWait(ctx, c, container.Uuid)
+	if remove {
+		if rerr := c.Destroy(ctx, container.Uuid, true); rerr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to destroy container %s: %v\n", container.Uuid, rerr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return &cli.ExitCodeError{Code: exitCode}
+	}
+	return nil
+}
+
+// attachAndWait attaches the local terminal to the container until it exits,
+// and returns its exit code.
+func attachAndWait(ctx context.Context, c *client.Client, uuid string) (int, error) {
+	opts := client.AttachOptions{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	if interactive {
+		opts.Stdin = os.Stdin
+	}
+
+	if tty {
+		// Set the local terminal in raw mode to turn off buffering and local
+		// echo. Also defers setting it back to normal for when the call is done.
+		termios, err := raw.MakeRaw(os.Stdin.Fd())
+		if err != nil {
+			return 0, err
+		}
+		defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+
+		if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+			opts.Size = client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+		}
+
+		// Pass along any changes to the window size.
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		resize := make(chan client.WindowSize, 1)
+		go func() {
+			for _ = range winch {
+				if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+					resize <- client.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+				}
+			}
+		}()
+		opts.Resize = resize
+	}
+
+	if err := c.Attach(ctx, uuid, opts); err != nil {
+		return 0, err
+	}
+	resp, err := c.Wait(ctx, uuid, 0)
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.ExitCode), nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	"golang.org/x/net/context"
+)
+
+const secretsCreateHelp = `
+Usage: kurma-cli secrets create NAME [FILE]
+
+Stores a new secret on the host, with the contents of the file as its value,
+or what is read from stdin if no file or "-" is given. Secrets are encrypted on
+the host's disk, and their values can't be retrieved through the API. They are
+given to containers with "kurma-cli create --secret" or "--secret-env".
+`
+
+const secretsListHelp = `
+Usage: kurma-cli secrets list
+
+Lists the names of the secrets on the host.
+`
+
+const secretsDeleteHelp = `
+Usage: kurma-cli secrets delete NAME
+
+Deletes the secret from the host. A secret cannot be deleted while a container
+references it.
+`
+
+// maxSecretSize is the largest secret the server accepts, which is read before
+// sending it so larger ones are refused without being sent.
+const maxSecretSize = 1 << 20
+
+func init() {
+	cli.DefineCommand("secrets create", parseFlags, create, cliCreate, &cli.Help{
+		Summary: "Store a secret for containers",
+		Text:    secretsCreateHelp,
+		Examples: []string{
+			"kurma-cli secrets create db-password ./password.txt",
+			"echo -n hunter2 | kurma-cli secrets create db-password",
+		},
+		Args: cli.ArgsFile,
+	})
+	cli.DefineCommand("secrets list", parseFlags, list, cliList, &cli.Help{
+		Summary: "List the secrets",
+		Text:    secretsListHelp,
+		Examples: []string{
+			"kurma-cli secrets list",
+		},
+	})
+	cli.DefineCommand("secrets delete", parseFlags, remove, cliName, &cli.Help{
+		Summary: "Delete a secret",
+		Text:    secretsDeleteHelp,
+		Examples: []string{
+			"kurma-cli secrets delete db-password",
+		},
+		Args: cli.ArgsSecret,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliCreate(cmd *cli.Cmd) error {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliName(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliList(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func create(cmd *cli.Cmd) error {
+	var r io.Reader = os.Stdin
+	if len(cmd.Args) == 2 && cmd.Args[1] != "-" {
+		f, err := os.Open(cmd.Args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	value, err := ioutil.ReadAll(io.LimitReader(r, maxSecretSize+1))
+	if err != nil {
+		return err
+	}
+	if len(value) > maxSecretSize {
+		return fmt.Errorf("secrets may be at most %d bytes", maxSecretSize)
+	}
+
+	if err := client.New(cmd.Client).CreateSecret(context.Background(), cmd.Args[0], value); err != nil {
+		return err
+	}
+	fmt.Printf("Created secret %s\n", cmd.Args[0])
+	return nil
+}
+
+func list(cmd *cli.Cmd) error {
+	secrets, err := client.New(cmd.Client).ListSecrets(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if secrets == nil {
+		secrets = []string{}
+	}
+	return cli.Render(secrets, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name")
+		for _, name := range secrets {
+			table.AddRow(name)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+func remove(cmd *cli.Cmd) error {
+	if err := client.New(cmd.Client).DeleteSecret(context.Background(), cmd.Args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted secret %s\n", cmd.Args[0])
+	return nil
+}
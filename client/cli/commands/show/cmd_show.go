@@ -7,14 +7,29 @@ import (
 	"fmt"
 
 	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
 	"github.com/appc/spec/schema"
 
 	pb "github.com/apcera/kurma/stage1/client"
 	"golang.org/x/net/context"
 )
 
+const showHelp = `
+Usage: kurma-cli show CONTAINER
+
+Shows the state, exit code, and restart count of each of a container's apps,
+followed by the container's pod manifest.
+`
+
 func init() {
-	cli.DefineCommand("show", parseFlags, show, cliShow, "FIXME")
+	cli.DefineCommand("show", parseFlags, show, cliShow, &cli.Help{
+		Summary: "Show a container's apps and pod manifest",
+		Text:    showHelp,
+		Examples: []string{
+			"kurma-cli show web",
+		},
+		Args: cli.ArgsContainer,
+	})
 }
 
 func parseFlags(cmd *cli.Cmd) {
@@ -37,6 +52,18 @@ func show(cmd *cli.Cmd) error {
 
 	fmt.Printf("Container %s:\n\n", resp.Uuid)
 
+	// show the state of each app
+	table := termtables.CreateTable()
+	table.AddHeaders("App", "State", "Exit Code", "Restarts")
+	for _, app := range resp.Apps {
+		exitCode := ""
+		if app.State == pb.Container_EXITED {
+			exitCode = fmt.Sprintf("%d", app.ExitCode)
+		}
+		table.AddRow(app.Name, app.State.String(), exitCode, app.Restarts)
+	}
+	fmt.Printf("%s\n", table.Render())
+
 	// convert the manifest to the object
 	var pod *schema.PodManifest
 	if err := json.Unmarshal(resp.Manifest, &pod); err != nil {
@@ -0,0 +1,51 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package start
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const startHelp = `
+Usage: kurma-cli start CONTAINER
+
+Starts the apps within a container which was stopped, or whose apps have all
+exited.
+`
+
+func init() {
+	cli.DefineCommand("start", parseFlags, start, cliStart, &cli.Help{
+		Summary: "Start the apps within a stopped container",
+		Text:    startHelp,
+		Examples: []string{
+			"kurma-cli start web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliStart(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func start(cmd *cli.Cmd) error {
+	req := &pb.ContainerRequest{Uuid: cmd.Args[0]}
+
+	if _, err := cmd.Client.Start(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Started container %s\n", cmd.Args[0])
+	return nil
+}
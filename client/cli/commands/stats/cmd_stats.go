@@ -0,0 +1,355 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const statsHelp = `
+Usage: kurma-cli stats [--no-stream] [--interval SECONDS] [CONTAINER...]
+
+Displays a live view of the resource usage of containers, including their CPU,
+memory, network, block device, and disk usage. The network throughput is shown
+along with the container's bandwidth limits, if it has any. If no containers
+are specified, all of the running containers are shown. On hosts using the
+unified cgroup hierarchy, the JSON output also gives the percentage of the last
+10 seconds the container's tasks were stalled on its CPU, memory, and io.
+
+Options:
+  --no-stream            Display the usage once rather than continuously.
+  --interval SECONDS     How often the usage is updated. Defaults to 1.
+`
+
+// clearScreen is the terminal escape sequence to clear the screen and move the
+// cursor to the top left corner.
+const clearScreen = "\033[2J\033[H"
+
+var (
+	noStream bool
+	interval int
+)
+
+func init() {
+	cli.DefineCommand("stats", parseFlags, stats, cliStats, &cli.Help{
+		Summary: "Display the resource usage of containers",
+		Text:    statsHelp,
+		Examples: []string{
+			"kurma-cli stats",
+			"kurma-cli stats --no-stream web db",
+			"kurma-cli stats --no-stream -o json",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&noStream, "no-stream", false, "")
+	cmd.Flags.IntVar(&interval, "interval", 1, "")
+}
+
+func cliStats(cmd *cli.Cmd) error {
+	if interval < 1 {
+		return fmt.Errorf("The --interval value must be at least 1.")
+	}
+	return cmd.Run()
+}
+
+// sample holds the two most recent stats received for a container, so that
+// the CPU usage can be calculated as a rate.
+type sample struct {
+	prev, cur *pb.StatsResponse
+}
+
+func (s *sample) add(resp *pb.StatsResponse) {
+	s.prev, s.cur = s.cur, resp
+}
+
+func stats(cmd *cli.Cmd) error {
+	uuids := cmd.Args
+	if len(uuids) == 0 {
+		var err error
+		if uuids, err = runningContainers(cmd); err != nil {
+			return err
+		}
+		if len(uuids) == 0 {
+			fmt.Println("No containers are running.")
+			return nil
+		}
+	}
+
+	if noStream {
+		return statsOnce(cmd, uuids)
+	}
+	return streamStats(cmd, uuids)
+}
+
+// runningContainers returns the names of the containers which are running, or
+// their UUIDs if the server doesn't name them.
+func runningContainers(cmd *cli.Cmd) ([]string, error) {
+	resp, err := cmd.Client.List(context.Background(), &pb.ListRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var uuids []string
+	for _, c := range resp.Containers {
+		switch {
+		case c.State != pb.Container_RUNNING:
+		case c.Name != "":
+			uuids = append(uuids, c.Name)
+		default:
+			uuids = append(uuids, c.Uuid)
+		}
+	}
+	return uuids, nil
+}
+
+// statsOnce takes two samples of each container's stats an interval apart and
+// displays the usage.
+func statsOnce(cmd *cli.Cmd, uuids []string) error {
+	samples := make(map[string]*sample)
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(interval) * time.Second)
+		}
+		for _, uuid := range uuids {
+			resp, err := cmd.Client.Stats(context.Background(), &pb.ContainerRequest{Uuid: uuid})
+			if err != nil {
+				return fmt.Errorf("failed to get the stats for %s: %v", uuid, err)
+			}
+			if samples[uuid] == nil {
+				samples[uuid] = &sample{}
+			}
+			samples[uuid].add(resp)
+		}
+	}
+	return render(samples, false)
+}
+
+// streamStats streams the stats of each container and redraws the table every
+// interval, or outputs the usage again in the --output format, until all of the
+// streams have ended.
+func streamStats(cmd *cli.Cmd, uuids []string) error {
+	type result struct {
+		uuid string
+		resp *pb.StatsResponse
+		err  error
+	}
+	results := make(chan result)
+
+	for _, uuid := range uuids {
+		go func(uuid string) {
+			req := &pb.StatsRequest{Uuid: uuid, Interval: int32(interval)}
+			stream, err := cmd.Client.StreamStats(context.Background(), req)
+			if err != nil {
+				results <- result{uuid: uuid, err: err}
+				return
+			}
+			for {
+				resp, err := stream.Recv()
+				if err != nil {
+					results <- result{uuid: uuid, err: err}
+					return
+				}
+				results <- result{uuid: uuid, resp: resp}
+			}
+		}(uuid)
+	}
+
+	samples := make(map[string]*sample)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	var firstErr error
+	for remaining := len(uuids); remaining > 0; {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				remaining--
+				delete(samples, r.uuid)
+				if r.err != io.EOF && firstErr == nil {
+					firstErr = fmt.Errorf("failed to get the stats for %s: %v", r.uuid, r.err)
+				}
+				continue
+			}
+			if samples[r.uuid] == nil {
+				samples[r.uuid] = &sample{}
+			}
+			samples[r.uuid].add(r.resp)
+
+		case <-ticker.C:
+			if err := render(samples, true); err != nil {
+				return err
+			}
+		}
+	}
+	return firstErr
+}
+
+// usage is a container's resource usage, which is output as JSON or given to
+// the --output template. The CPU and memory percentages, and the network rates
+// in bytes per second, are calculated between the last two samples.
+type usage struct {
+	Container     string  `json:"container"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   int64   `json:"memory_usage"`
+	MemoryLimit   int64   `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	OOMKills      int64   `json:"oom_kills"`
+	NetworkRx     int64   `json:"network_rx"`
+	NetworkTx     int64   `json:"network_tx"`
+	NetworkRxRate int64   `json:"network_rx_rate"`
+	NetworkTxRate int64   `json:"network_tx_rate"`
+	IngressLimit  int64   `json:"ingress_limit,omitempty"`
+	EgressLimit   int64   `json:"egress_limit,omitempty"`
+	BlockRead     int64   `json:"block_read"`
+	BlockWrite    int64   `json:"block_write"`
+	DiskUsage     int64   `json:"disk_usage"`
+	DiskLimit     int64   `json:"disk_limit,omitempty"`
+
+	Pressure map[string]float64 `json:"pressure,omitempty"`
+}
+
+// usages calculates the usage of each of the containers, ordered by the names
+// they were given by.
+func usages(samples map[string]*sample) []*usage {
+	uuids := make([]string, 0, len(samples))
+	for uuid := range samples {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	usages := make([]*usage, len(uuids))
+	for i, uuid := range uuids {
+		s := samples[uuid]
+		cur := s.cur
+		u := &usage{
+			Container:     uuid,
+			CPUPercent:    cpuPercent(s.prev, cur),
+			MemoryUsage:   cur.MemoryUsage,
+			MemoryLimit:   cur.MemoryLimit,
+			MemoryPercent: percent(cur.MemoryUsage, cur.MemoryLimit),
+			OOMKills:      cur.OomKills,
+			BlockRead:     cur.BlkioRead,
+			BlockWrite:    cur.BlkioWrite,
+			DiskUsage:     cur.DiskUsage,
+			DiskLimit:     cur.DiskLimit,
+			IngressLimit:  cur.IngressLimit,
+			EgressLimit:   cur.EgressLimit,
+		}
+		for _, p := range cur.Pressure {
+			if u.Pressure == nil {
+				u.Pressure = make(map[string]float64)
+			}
+			u.Pressure[p.Resource] = p.SomeAvg10
+		}
+		u.NetworkRx, u.NetworkTx = networkBytes(cur)
+		if prev := s.prev; prev != nil && cur.Time > prev.Time {
+			rx, tx := networkBytes(prev)
+			u.NetworkRxRate = perSecond(u.NetworkRx-rx, cur.Time-prev.Time)
+			u.NetworkTxRate = perSecond(u.NetworkTx-tx, cur.Time-prev.Time)
+		}
+		usages[i] = u
+	}
+	return usages
+}
+
+// render displays the usage of each of the containers. When it is displayed as
+// a table while streaming, the screen is cleared first so the table is redrawn
+// in place.
+func render(samples map[string]*sample, redraw bool) error {
+	u := usages(samples)
+	return cli.Render(u, func() error {
+		if redraw {
+			fmt.Print(clearScreen)
+		}
+		fmt.Printf("%s", renderTable(u))
+		return nil
+	})
+}
+
+// renderTable renders the usage of each of the containers.
+func renderTable(usages []*usage) string {
+	table := termtables.CreateTable()
+	table.AddHeaders("Container", "CPU %", "Mem Usage / Limit", "Mem %", "OOM Kills", "Net I/O", "Net Rate In / Out", "Block I/O", "Disk Usage / Limit")
+	for _, u := range usages {
+		table.AddRow(
+			u.Container,
+			fmt.Sprintf("%.2f%%", u.CPUPercent),
+			fmt.Sprintf("%s / %s", cli.FormatBytes(u.MemoryUsage), cli.FormatBytes(u.MemoryLimit)),
+			fmt.Sprintf("%.2f%%", u.MemoryPercent),
+			fmt.Sprintf("%d", u.OOMKills),
+			fmt.Sprintf("%s / %s", cli.FormatBytes(u.NetworkRx), cli.FormatBytes(u.NetworkTx)),
+			fmt.Sprintf("%s / %s", formatRate(u.NetworkRxRate, u.IngressLimit), formatRate(u.NetworkTxRate, u.EgressLimit)),
+			fmt.Sprintf("%s / %s", cli.FormatBytes(u.BlockRead), cli.FormatBytes(u.BlockWrite)),
+			formatDisk(u.DiskUsage, u.DiskLimit),
+		)
+	}
+	return table.Render()
+}
+
+// formatDisk formats the container's disk usage and limit, or a dash if it has
+// no limit.
+func formatDisk(usage, limit int64) string {
+	if limit == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s / %s", cli.FormatBytes(usage), cli.FormatBytes(limit))
+}
+
+// formatRate formats the rate of the container's traffic in one direction, and
+// its limit if it has one.
+func formatRate(rate, limit int64) string {
+	if limit == 0 {
+		return cli.FormatBytes(rate) + "/s"
+	}
+	return fmt.Sprintf("%s/s of %s/s", cli.FormatBytes(rate), cli.FormatBytes(limit))
+}
+
+// networkBytes returns the bytes the container has received and sent over its
+// interfaces, other than loopback.
+func networkBytes(resp *pb.StatsResponse) (rx, tx int64) {
+	for _, n := range resp.Networks {
+		if n.Interface == "lo" {
+			continue
+		}
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+// perSecond converts the change in a counter over the nanoseconds to a rate per
+// second. Counters which went backwards, such as when the container's
+// interface was recreated, are treated as unchanged.
+func perSecond(delta, nanoseconds int64) int64 {
+	if delta <= 0 || nanoseconds <= 0 {
+		return 0
+	}
+	return int64(float64(delta) * float64(time.Second) / float64(nanoseconds))
+}
+
+// cpuPercent calculates the percentage of a CPU used between the two samples.
+// It may exceed 100% when multiple CPUs are used.
+func cpuPercent(prev, cur *pb.StatsResponse) float64 {
+	if prev == nil || cur.Time <= prev.Time {
+		return 0
+	}
+	return percent(cur.CpuUsage-prev.CpuUsage, cur.Time-prev.Time)
+}
+
+func percent(value, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(value) / float64(total) * 100
+}
@@ -11,27 +11,60 @@ import (
 	"golang.org/x/net/context"
 )
 
+const stopHelp = `
+Usage: kurma-cli stop [-t SECONDS] CONTAINER
+
+Stops the apps within a container. Each app is sent SIGTERM and is killed if it
+has not exited within the grace period. The container remains on the host and
+its apps can be started again with "kurma-cli start".
+
+Options:
+  -t, --time SECONDS   The grace period to give the apps to exit. Defaults to
+                       the container's stop grace period, which is 10 seconds
+                       unless it was set when the container was created.
+`
+
+var (
+	gracePeriod int
+)
+
 func init() {
-	cli.DefineCommand("stop", parseFlags, stop, cliStop, "FIXME")
+	cli.DefineCommand("stop", parseFlags, stop, cliStop, &cli.Help{
+		Summary: "Stop the apps within a container",
+		Text:    stopHelp,
+		Examples: []string{
+			"kurma-cli stop web",
+			"kurma-cli stop -t 30 web",
+		},
+		Args: cli.ArgsContainer,
+	})
 }
 
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.IntVar(&gracePeriod, "time", 0, "")
+	cmd.Flags.IntVar(&gracePeriod, "t", 0, "")
 }
 
 func cliStop(cmd *cli.Cmd) error {
 	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
 		return fmt.Errorf("Invalid command options specified.")
 	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("The --time value must not be negative.")
+	}
 	return cmd.Run()
 }
 
 func stop(cmd *cli.Cmd) error {
-	req := &pb.ContainerRequest{Uuid: cmd.Args[0]}
+	req := &pb.StopRequest{
+		Uuid:        cmd.Args[0],
+		GracePeriod: int32(gracePeriod),
+	}
 
-	if _, err := cmd.Client.Destroy(context.Background(), req); err != nil {
+	if _, err := cmd.Client.Stop(context.Background(), req); err != nil {
 		return err
 	}
 
-	fmt.Printf("Destroyed container %s\n", cmd.Args[0])
+	fmt.Printf("Stopped container %s\n", cmd.Args[0])
 	return nil
 }
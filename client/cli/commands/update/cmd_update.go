@@ -0,0 +1,90 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package update
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const updateHelp = `
+Usage: kurma-cli update [--memory SIZE] [--cpu-shares N] [--blkio-weight N] CONTAINER
+
+Adjusts the resource limits of a running container without restarting it. Only
+the limits which are specified are changed.
+
+Options:
+  --memory SIZE      The memory limit, such as 512Mi or 2G. Lowering it below
+                     what the container is currently using will fail.
+  --cpu-shares N     The relative weight of the container when competing for
+                     CPU time. The default for a container is 1024.
+  --blkio-weight N   The relative weight of the container when competing for
+                     block IO, between 10 and 1000.
+`
+
+var (
+	memory      string
+	cpuShares   int64
+	blkioWeight int64
+)
+
+func init() {
+	cli.DefineCommand("update", parseFlags, update, cliUpdate, &cli.Help{
+		Summary: "Adjust the resource limits of a running container",
+		Text:    updateHelp,
+		Examples: []string{
+			"kurma-cli update --memory 512m web",
+			"kurma-cli update --cpu-shares 512 --blkio-weight 300 web",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&memory, "memory", "", "")
+	cmd.Flags.Int64Var(&cpuShares, "cpu-shares", 0, "")
+	cmd.Flags.Int64Var(&blkioWeight, "blkio-weight", 0, "")
+}
+
+func cliUpdate(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if memory == "" && cpuShares == 0 && blkioWeight == 0 {
+		return fmt.Errorf("At least one resource limit must be specified.")
+	}
+	if cpuShares < 0 || blkioWeight < 0 {
+		return fmt.Errorf("Resource limits must not be negative.")
+	}
+	return cmd.Run()
+}
+
+func update(cmd *cli.Cmd) error {
+	req := &pb.UpdateResourcesRequest{
+		Uuid:        cmd.Args[0],
+		CpuShares:   cpuShares,
+		BlkioWeight: blkioWeight,
+	}
+	if memory != "" {
+		q, err := resource.ParseQuantity(memory)
+		if err != nil {
+			return fmt.Errorf("Invalid memory limit %q: %v", memory, err)
+		}
+		if q.Value() <= 0 {
+			return fmt.Errorf("The memory limit must be greater than 0.")
+		}
+		req.Memory = q.Value()
+	}
+
+	if _, err := cmd.Client.UpdateResources(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated container %s\n", cmd.Args[0])
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package version
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const versionHelp = `
+Usage: kurma-cli version
+
+Shows the version of the client and of the Kurma server, along with the
+revisions of the API each supports, and compares them. A warning is shown when
+the client uses a revision the server doesn't support, in which case one of
+them should be upgraded.
+`
+
+func init() {
+	cli.DefineCommand("version", parseFlags, version, cliVersion, &cli.Help{
+		Summary: "Show the versions of the client and server",
+		Text:    versionHelp,
+		Examples: []string{
+			"kurma-cli version",
+		},
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliVersion(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func version(cmd *cli.Cmd) error {
+	fmt.Printf("Client version: %s (API revision %d)\n", pb.Version, pb.APIVersion)
+
+	// the --version flag shows the version without connecting to the server
+	if cmd.Client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := cmd.Client.Info(ctx, &pb.None{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Server version: %s (API revisions %d to %d)\n",
+		info.Version, info.MinApiVersion, info.MaxApiVersion)
+	if err := pb.CheckAPIVersion(info); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	} else if info.Version != pb.Version {
+		fmt.Printf("The client and server versions differ, but they share API revision %d\n", pb.APIVersion)
+	}
+	return nil
+}
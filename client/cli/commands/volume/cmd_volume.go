@@ -0,0 +1,116 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package volume
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+const volumeCreateHelp = `
+Usage: kurma-cli volume create NAME
+
+Creates a new named volume on the host. Volumes persist until they're deleted
+and can be mounted into containers with "kurma-cli create --volume".
+`
+
+const volumeListHelp = `
+Usage: kurma-cli volume list
+
+Lists the named volumes on the host.
+`
+
+const volumeDeleteHelp = `
+Usage: kurma-cli volume delete NAME
+
+Deletes the named volume and all of its data. A volume cannot be deleted while
+a container is using it.
+`
+
+func init() {
+	cli.DefineCommand("volume create", parseFlags, create, cliName, &cli.Help{
+		Summary: "Create a named volume",
+		Text:    volumeCreateHelp,
+		Examples: []string{
+			"kurma-cli volume create data",
+		},
+	})
+	cli.DefineCommand("volume list", parseFlags, list, cliList, &cli.Help{
+		Summary: "List the named volumes",
+		Text:    volumeListHelp,
+		Examples: []string{
+			"kurma-cli volume list",
+		},
+	})
+	cli.DefineCommand("volume delete", parseFlags, remove, cliName, &cli.Help{
+		Summary: "Delete a named volume and its data",
+		Text:    volumeDeleteHelp,
+		Examples: []string{
+			"kurma-cli volume delete data",
+		},
+		Args: cli.ArgsVolume,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+}
+
+func cliName(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func cliList(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func create(cmd *cli.Cmd) error {
+	req := &pb.VolumeRequest{Name: cmd.Args[0]}
+	if _, err := cmd.Client.CreateVolume(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created volume %s\n", cmd.Args[0])
+	return nil
+}
+
+func list(cmd *cli.Cmd) error {
+	resp, err := cmd.Client.ListVolumes(context.Background(), &pb.None{})
+	if err != nil {
+		return err
+	}
+
+	volumes := resp.Volumes
+	if volumes == nil {
+		volumes = []string{}
+	}
+	return cli.Render(volumes, func() error {
+		table := termtables.CreateTable()
+		table.AddHeaders("Name")
+		for _, name := range volumes {
+			table.AddRow(name)
+		}
+		fmt.Printf("%s", table.Render())
+		return nil
+	})
+}
+
+func remove(cmd *cli.Cmd) error {
+	req := &pb.VolumeRequest{Name: cmd.Args[0]}
+	if _, err := cmd.Client.DeleteVolume(context.Background(), req); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted volume %s\n", cmd.Args[0])
+	return nil
+}
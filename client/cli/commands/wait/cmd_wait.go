@@ -0,0 +1,89 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package wait
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/client"
+	"github.com/apcera/kurma/client/cli"
+	"golang.org/x/net/context"
+)
+
+const waitHelp = `
+Usage: kurma-cli wait [--timeout SECONDS] CONTAINER
+
+Blocks until the container exits or its apps are stopped, then prints its exit
+code. A note is written to stderr if any of its processes were killed for
+exceeding the memory limit since its apps were last started.
+
+Options:
+  --timeout SECONDS   Give up waiting after the number of seconds, failing
+                      if the container is still running. By default, there
+                      is no limit.
+`
+
+var (
+	timeout int
+)
+
+func init() {
+	cli.DefineCommand("wait", parseFlags, wait, cliWait, &cli.Help{
+		Summary: "Wait for a container to exit and print its exit code",
+		Text:    waitHelp,
+		Examples: []string{
+			"kurma-cli wait batch",
+			"kurma-cli wait --timeout 60 batch",
+		},
+		Args: cli.ArgsContainer,
+	})
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.IntVar(&timeout, "timeout", 0, "")
+}
+
+func cliWait(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if timeout < 0 {
+		return fmt.Errorf("The --timeout value must not be negative.")
+	}
+	return cmd.Run()
+}
+
+// result is the outcome of the wait which is output.
+type result struct {
+	ExitCode  int    `json:"exit_code"`
+	OOMKilled bool   `json:"oom_killed"`
+	State     string `json:"state"`
+}
+
+func wait(cmd *cli.Cmd) error {
+	c := client.New(cmd.Client)
+	resp, err := c.Wait(context.Background(), cmd.Args[0], time.Duration(timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	state := strings.ToLower(resp.State.String())
+	if resp.TimedOut {
+		return fmt.Errorf("Timed out waiting for the container, which is %s.", state)
+	}
+
+	r := &result{
+		ExitCode:  int(resp.ExitCode),
+		OOMKilled: resp.OomKilled,
+		State:     state,
+	}
+	return cli.Render(r, func() error {
+		if r.OOMKilled {
+			fmt.Fprintln(os.Stderr, "The container was killed for exceeding its memory limit.")
+		}
+		fmt.Println(r.ExitCode)
+		return nil
+	})
+}
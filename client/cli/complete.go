@@ -0,0 +1,132 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"flag"
+	"sort"
+	"strings"
+)
+
+// Complete returns the candidates for the last of the words, which is being
+// typed, given the words before it on the command line. The list function is
+// called for the names of the server's containers, volumes, or secrets when a
+// command takes them. If files is returned, the shell should complete the
+// names of local files instead.
+func Complete(words []string, list func(ArgKind) []string) (candidates []string, files bool) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+
+	def, n := findCompletedCommand(prev)
+	if def == nil {
+		return commandWords(prev, cur), false
+	}
+	args := prev[n:]
+
+	flags := flag.NewFlagSet("complete", flag.ContinueOnError)
+	addGlobalFlags(flags)
+	if def.flagParser != nil {
+		def.flagParser(&Cmd{Flags: flags})
+	}
+
+	// the value of a flag is left to the shell
+	if len(args) > 0 && strings.HasPrefix(args[len(args)-1], "-") && !strings.Contains(args[len(args)-1], "=") {
+		if f := flags.Lookup(strings.TrimLeft(args[len(args)-1], "-")); f != nil && !isBoolFlag(f) {
+			return nil, true
+		}
+	}
+	if strings.HasPrefix(cur, "-") {
+		flags.VisitAll(func(f *flag.Flag) {
+			name := "-" + f.Name
+			if len(f.Name) > 1 {
+				name = "--" + f.Name
+			}
+			if strings.HasPrefix(name, cur) {
+				candidates = append(candidates, name)
+			}
+		})
+		sort.Strings(candidates)
+		return candidates, false
+	}
+
+	h := def.help()
+	if h == nil {
+		return nil, true
+	}
+	switch h.Args {
+	case ArgsContainer, ArgsVolume, ArgsSecret, ArgsJob, ArgsNetworkPolicy:
+		for _, name := range list(h.Args) {
+			if strings.HasPrefix(name, cur) {
+				candidates = append(candidates, name)
+			}
+		}
+		sort.Strings(candidates)
+		return candidates, false
+	case ArgsCommand:
+		return commandWords(args, cur), false
+	case ArgsFile:
+		return nil, true
+	}
+	return nil, false
+}
+
+// findCompletedCommand returns the command named by the longest run of the
+// leading words, and how many words name it.
+func findCompletedCommand(words []string) (*cmdDef, int) {
+	for i := len(words); i > 0; i-- {
+		name := strings.Join(words[:i], " ")
+		if def, ok := apcCommands[name]; ok && !def.hidden() {
+			return &def, i
+		}
+		if def, ok := aliasedCommands[name]; ok {
+			return &def, i
+		}
+	}
+	return nil, 0
+}
+
+// commandWords returns the next words of the commands and aliases which begin
+// with the words given, which start with the word being typed.
+func commandWords(prev []string, cur string) []string {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		parts := strings.Fields(name)
+		if len(parts) <= len(prev) {
+			return
+		}
+		for i, word := range prev {
+			if parts[i] != word {
+				return
+			}
+		}
+		if next := parts[len(prev)]; strings.HasPrefix(next, cur) {
+			seen[next] = true
+		}
+	}
+	for name, def := range apcCommands {
+		if !def.hidden() {
+			add(name)
+		}
+	}
+	for name := range aliasedCommands {
+		add(name)
+	}
+
+	words := make([]string, 0, len(seen))
+	for word := range seen {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// isBoolFlag returns whether the flag is a boolean, which takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	if gv, ok := f.Value.(flag.Getter); ok {
+		_, ok := gv.Get().(bool)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/client"
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+const (
+	defaultKurmaLocalPort  = "12311"
+	defaultKurmaRemotePort = "12312"
+)
+
+// Dial connects to the Kurma server given by the global flags, using TLS and
+// the token if they were given. When a node is given, the server is asked for
+// the node's endpoint in its cluster, which is connected to instead. A zero
+// timeout waits until the server can be reached.
+func Dial(timeout time.Duration) (*client.Client, error) {
+	c, err := DialEndpoint(determineKurmaEndpoint(), timeout)
+	if err != nil || Node == "" {
+		return c, err
+	}
+
+	node, err := findNode(c, Node)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if node.Self {
+		return c, nil
+	}
+	c.Close()
+	return DialEndpoint(node.Endpoint, timeout)
+}
+
+// DialEndpoint connects to the Kurma server at the endpoint, using the TLS
+// settings and token given by the global flags.
+func DialEndpoint(endpoint string, timeout time.Duration) (*client.Client, error) {
+	return client.Dial(client.Config{
+		Endpoint:  endpoint,
+		Token:     Token,
+		TLSCert:   TLSCert,
+		TLSKey:    TLSKey,
+		TLSCACert: TLSCACert,
+		Timeout:   timeout,
+	})
+}
+
+// NodeTimeout limits how long a node is given to answer when looking up the
+// cluster's nodes or connecting to one of them.
+const NodeTimeout = 10 * time.Second
+
+// findNode returns the node with the name from the server's cluster.
+func findNode(c *client.Client, name string) (*pb.Node, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), NodeTimeout)
+	defer cancel()
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the nodes of the cluster: %v", err)
+	}
+	for _, n := range nodes {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no node named %q in the cluster", name)
+}
+
+// NodeClient returns a client for one of the nodes the command's server listed
+// in its cluster, which is the command's own client for the server itself.
+// The connection is closed by calling done.
+func NodeClient(cmd *Cmd, node *pb.Node) (c pb.KurmaClient, done func(), err error) {
+	if node.Self {
+		return cmd.Client, func() {}, nil
+	}
+	nc, err := DialEndpoint(node.Endpoint, NodeTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nc.RPC(), func() { nc.Close() }, nil
+}
+
+// determineKurmaEndpoint returns the endpoint to connect to. Full endpoints,
+// such as unix:// or tcp:// urls, are used as is, while a plain host is
+// connected to on the local or remote API port.
+func determineKurmaEndpoint() string {
+	if strings.Contains(KurmaHost, "://") {
+		return KurmaHost
+	}
+
+	// quick check if it is referring to the local host
+	ip := net.ParseIP(KurmaHost)
+	if ip != nil && ip.IsLoopback() {
+		return "tcp://" + net.JoinHostPort(KurmaHost, defaultKurmaLocalPort)
+	}
+	return "tcp://" + net.JoinHostPort(KurmaHost, defaultKurmaRemotePort)
+}
@@ -0,0 +1,34 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LabelFlags collects the labels specified on the command line in the form
+// NAME=VALUE.
+type LabelFlags map[string]string
+
+func (l *LabelFlags) String() string {
+	parts := make([]string, 0, len(*l))
+	for name, value := range *l {
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (l *LabelFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("must be specified as NAME=VALUE")
+	}
+	if *l == nil {
+		*l = make(LabelFlags)
+	}
+	(*l)[parts[0]] = parts[1]
+	return nil
+}
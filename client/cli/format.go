@@ -0,0 +1,21 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"fmt"
+)
+
+// FormatBytes formats the number of bytes using binary units.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
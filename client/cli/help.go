@@ -0,0 +1,141 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ArgKind is what a command's arguments name, which shell completion offers
+// as the arguments are typed.
+type ArgKind int
+
+const (
+	// ArgsNone is for commands whose arguments can't be completed.
+	ArgsNone ArgKind = iota
+
+	// ArgsContainer is for commands taking containers, which are completed by
+	// name from the server.
+	ArgsContainer
+
+	// ArgsVolume is for commands taking volumes, which are completed from the
+	// server.
+	ArgsVolume
+
+	// ArgsSecret is for commands taking secrets, which are completed from the
+	// server.
+	ArgsSecret
+
+	// ArgsJob is for commands taking scheduled jobs, which are completed from
+	// the server.
+	ArgsJob
+
+	// ArgsNetworkPolicy is for commands taking network policies, which are
+	// completed from the server.
+	ArgsNetworkPolicy
+
+	// ArgsFile is for commands taking local files, which are completed by the
+	// shell.
+	ArgsFile
+
+	// ArgsCommand is for commands taking the name of another command.
+	ArgsCommand
+)
+
+// Help documents a command for "kurma-cli help" and for shell completion. It
+// is given to DefineCommand in place of a help string.
+type Help struct {
+	// Summary is a line describing the command in the list of commands.
+	Summary string
+
+	// Text is the full help for the command: its usage, description, and
+	// options.
+	Text string
+
+	// Examples are command lines showing how the command is used, which may
+	// be preceded by a "# comment" line explaining them.
+	Examples []string
+
+	// Args is what the command's arguments name.
+	Args ArgKind
+}
+
+// globalHelp documents the flags every command accepts.
+const globalHelp = `
+Global options:
+  -H, --host HOST        The Kurma server to connect to: a host name or IP
+                         address, or an endpoint such as tcp://host:port or
+                         unix:///path. Defaults to $KURMA_HOST, or 127.0.0.1.
+  --node NAME            Send the command to the host of the server's cluster
+                         with the name, as listed by the nodes command.
+                         Defaults to $KURMA_NODE.
+  --tlscert FILE         The client certificate to present to the server.
+  --tlskey FILE          The key of the client certificate.
+  --tlscacert FILE       The CA to verify the server's certificate against.
+  --token TOKEN          The token to authenticate with. Defaults to
+                         $KURMA_TOKEN.
+  -o, --output FORMAT    How commands display information: table, json, or
+                         go-template=TEMPLATE. Defaults to table.
+  -v, --version          Show the version of the client and server.
+`
+
+// help returns the command's help, converting a help string into a Help with
+// only its Text set.
+func (c *cmdDef) help() *Help {
+	switch h := c.Help.(type) {
+	case *Help:
+		return h
+	case string:
+		return &Help{Text: h}
+	}
+	return nil
+}
+
+// hidden returns whether the command is left out of the list of commands and
+// completion, as is done for commands used internally by the CLI.
+func (c *cmdDef) hidden() bool {
+	return strings.HasPrefix(c.Name, "__")
+}
+
+// PrintCommands writes the list of commands, with their summaries, followed by
+// the global options.
+func PrintCommands(w io.Writer) {
+	names := make([]string, 0, len(apcCommands))
+	width := 0
+	for name, def := range apcCommands {
+		if def.hidden() {
+			continue
+		}
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Usage: kurma-cli [OPTIONS] COMMAND [ARGS...]\n\nCommands:\n")
+	for _, name := range names {
+		def := apcCommands[name]
+		summary := ""
+		if h := def.help(); h != nil {
+			summary = h.Summary
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, name, summary)
+	}
+	fmt.Fprint(w, globalHelp)
+	fmt.Fprintf(w, "\nRun 'kurma-cli help COMMAND' for more information on a command.\n")
+}
+
+// PrintCommandHelp writes the help of the command the words name, such as
+// "volume create".
+func PrintCommandHelp(w io.Writer, words []string) error {
+	def, n, err := FindCommand(words)
+	if err != nil || n != len(words) || def.hidden() {
+		return fmt.Errorf("Command not found: %s", strings.Join(words, " "))
+	}
+	def.PrintHelp(w)
+	return nil
+}
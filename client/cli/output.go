@@ -0,0 +1,100 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/apcera/util/str"
+)
+
+const (
+	// OutputTable and OutputJSON are the values of the global --output flag
+	// which display information as tables, the default, or as JSON.
+	OutputTable = "table"
+	OutputJSON  = "json"
+
+	// outputTemplatePrefix begins values of the --output flag which give a Go
+	// template to display information with.
+	outputTemplatePrefix = "go-template="
+)
+
+var (
+	// Output is how commands display the information they show, as set by the
+	// global --output flag: table, json, or go-template=TEMPLATE.
+	Output string
+
+	// outputTemplate is the template parsed from Output, if it gives one.
+	outputTemplate *template.Template
+)
+
+// SetOutput sets how commands display information, returning an error if the
+// output isn't one of those --output accepts.
+func SetOutput(output string) error {
+	switch {
+	case output == OutputTable, output == OutputJSON:
+		Output, outputTemplate = output, nil
+		return nil
+	case strings.HasPrefix(output, outputTemplatePrefix):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(output, outputTemplatePrefix))
+		if err != nil {
+			return fmt.Errorf("Invalid output template: %v", err)
+		}
+		Output, outputTemplate = output, tmpl
+		return nil
+	}
+	return fmt.Errorf("Invalid output %q, it must be table, json, or go-template=TEMPLATE.", output)
+}
+
+// Render displays v as JSON or with the output template, or otherwise calls
+// printTable to display it for people. The JSON is indented and the template's
+// output followed by a newline, so each is written as a whole document.
+func Render(v interface{}, printTable func() error) error {
+	switch {
+	case outputTemplate != nil:
+		if err := outputTemplate.Execute(os.Stdout, v); err != nil {
+			return err
+		}
+		fmt.Println()
+		return nil
+	case Output == OutputJSON:
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(b))
+		return nil
+	}
+	return printTable()
+}
+
+// WriteExport calls write with the file at the path, or with stdout if no path
+// is given, so long as stdout isn't a terminal the exported bytes would garble.
+// The file is removed if write fails, so an incomplete export isn't left
+// behind.
+func WriteExport(path string, write func(io.Writer) error) error {
+	if path == "" {
+		if str.IsTerminal(os.Stdout) {
+			return fmt.Errorf("refusing to write the export to a terminal, redirect it or use --file")
+		}
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
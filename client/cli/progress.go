@@ -0,0 +1,65 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// progressInterval is how often the progress bar is redrawn.
+	progressInterval = 100 * time.Millisecond
+
+	// progressWidth is the number of characters within the progress bar.
+	progressWidth = 30
+)
+
+// ProgressBar reports the progress of a transfer on a single line, which is
+// redrawn as the transfer progresses.
+type ProgressBar struct {
+	out   io.Writer
+	label string
+	total int64
+
+	current int64
+	drawn   time.Time
+}
+
+// NewProgressBar returns a ProgressBar drawn on out. If the total size isn't
+// known, total should be 0 and only the bytes transferred are shown.
+func NewProgressBar(out io.Writer, label string, total int64) *ProgressBar {
+	return &ProgressBar{out: out, label: label, total: total}
+}
+
+// Set updates the number of bytes transferred.
+func (p *ProgressBar) Set(n int64) {
+	p.current = n
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+}
+
+// Done draws the final progress and ends the line.
+func (p *ProgressBar) Done() {
+	p.draw()
+	fmt.Fprint(p.out, "\n")
+}
+
+func (p *ProgressBar) draw() {
+	p.drawn = time.Now()
+	if p.total <= 0 {
+		fmt.Fprintf(p.out, "\r%s: %s", p.label, FormatBytes(p.current))
+		return
+	}
+
+	filled := int(p.current * progressWidth / p.total)
+	if filled > progressWidth {
+		filled = progressWidth
+	}
+	fmt.Fprintf(p.out, "\r%s: [%s%s] %s / %s (%d%%)", p.label,
+		strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled),
+		FormatBytes(p.current), FormatBytes(p.total), p.current*100/p.total)
+}
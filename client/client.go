@@ -0,0 +1,86 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package client is a Go client for the Kurma API. It wraps the gRPC service
+// with calls which take care of its streams, such as image uploads and exec,
+// so that programs can manage containers without the CLI.
+package client
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// Config describes how to connect to a Kurma server.
+type Config struct {
+	// Endpoint is the server's API endpoint, such as unix:///path/to/socket or
+	// tcp://host:port.
+	Endpoint string
+
+	// Token is sent to authenticate each call, if the server requires it.
+	Token string
+
+	// TLSCert and TLSKey are the client certificate and key presented to the
+	// server, and TLSCACert is the CA its certificate is verified against. The
+	// connection uses TLS when any of them are set.
+	TLSCert   string
+	TLSKey    string
+	TLSCACert string
+
+	// Timeout limits how long Dial waits to connect. Without one, Dial retries
+	// until the server can be reached.
+	Timeout time.Duration
+}
+
+// Client is a connection to a Kurma server. It is safe to use from multiple
+// goroutines.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  pb.KurmaClient
+}
+
+// Dial connects to the Kurma server described by the config, along with any
+// other gRPC options.
+func Dial(cfg Config, opts ...grpc.DialOption) (*Client, error) {
+	if cfg.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(pb.TokenCredentials(cfg.Token)))
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.TLSCACert != "" {
+		tlsConfig, err := pb.ClientTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCACert)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, grpc.WithTimeout(cfg.Timeout))
+	}
+
+	conn, err := pb.Dial(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, rpc: pb.NewKurmaClient(conn)}, nil
+}
+
+// New returns a Client which makes its calls with the gRPC client, such as
+// one created for an existing connection. Close does nothing for it.
+func New(rpc pb.KurmaClient) *Client {
+	return &Client{rpc: rpc}
+}
+
+// RPC returns the underlying gRPC client, for the calls Client doesn't wrap.
+func (c *Client) RPC() pb.KurmaClient {
+	return c.rpc
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
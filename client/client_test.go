@@ -0,0 +1,494 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// writeACI returns a tar archive of the files, given as alternating names and
+// contents.
+func writeACI(t *testing.T, files ...string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		hdr := &tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1]))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadManifest(t *testing.T) {
+	aci := writeACI(t, "rootfs/etc/hostname", "web\n", "./manifest", `{"acKind":"ImageManifest"}`)
+	manifest, err := ReadManifest(aci)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest) != `{"acKind":"ImageManifest"}` {
+		t.Fatalf("unexpected manifest %q", manifest)
+	}
+
+	aci = writeACI(t, "rootfs/etc/hostname", "web\n")
+	if _, err := ReadManifest(aci); err == nil {
+		t.Fatal("expected an error for an image without a manifest")
+	}
+
+	// OCI image layouts give the manifest of the ACI they convert to
+	digest := func(b string) string {
+		sum := sha256.Sum256([]byte(b))
+		return hex.EncodeToString(sum[:])
+	}
+	layer := writeACI(t, "bin/web", "binary").String()
+	config := `{"os": "linux", "config": {"Entrypoint": ["/bin/web"]}}`
+	ociManifest := fmt.Sprintf(`{"schemaVersion": 2, "config": {"digest": "sha256:%s"}, "layers": [{"digest": "sha256:%s"}]}`,
+		digest(config), digest(layer))
+	layout := writeACI(t,
+		"oci-layout", `{"imageLayoutVersion": "1.0.0"}`,
+		"index.json", fmt.Sprintf(`{"schemaVersion": 2, "manifests": [{"digest": "sha256:%s"}]}`, digest(ociManifest)),
+		"blobs/sha256/"+digest(layer), layer,
+		"blobs/sha256/"+digest(config), config,
+		"blobs/sha256/"+digest(ociManifest), ociManifest)
+	manifest, err = ReadManifest(bytes.NewReader(layout.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var converted struct {
+		App struct{ Exec []string }
+	}
+	if err := json.Unmarshal(manifest, &converted); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(converted.App.Exec, []string{"/bin/web"}) {
+		t.Fatalf("unexpected manifest %s", manifest)
+	}
+	if _, err := ReadManifest(layout); err == nil {
+		t.Fatal("expected an error for a layout which can't be rewound")
+	}
+}
+
+// logsClient is a KurmaClient which only supports retrieving logs, which it
+// returns from its responses.
+type logsClient struct {
+	pb.KurmaClient
+	req       *pb.LogsRequest
+	responses []*pb.LogsResponse
+}
+
+func (c *logsClient) Logs(ctx context.Context, in *pb.LogsRequest, opts ...grpc.CallOption) (pb.Kurma_LogsClient, error) {
+	c.req = in
+	return &logsStream{responses: c.responses}, nil
+}
+
+type logsStream struct {
+	grpc.ClientStream
+	responses []*pb.LogsResponse
+}
+
+func (s *logsStream) Recv() (*pb.LogsResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestLogs(t *testing.T) {
+	rpc := &logsClient{responses: []*pb.LogsResponse{
+		{Stdout: []byte("started\n")},
+		{Stderr: []byte("warning\n")},
+		{Stdout: []byte("ready\n")},
+	}}
+
+	var stdout, stderr bytes.Buffer
+	err := New(rpc).Logs(context.Background(), "web", LogsOptions{Tail: 3}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpc.req.Uuid != "web" || rpc.req.Tail != 3 || rpc.req.Since != 0 {
+		t.Fatalf("unexpected request %v", rpc.req)
+	}
+	if stdout.String() != "started\nready\n" {
+		t.Fatalf("unexpected stdout %q", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+}
+
+// attachClient is a KurmaClient which only supports attaching, recording the
+// requests sent and returning its responses.
+type attachClient struct {
+	pb.KurmaClient
+	stream *attachStream
+}
+
+func (c *attachClient) Attach(ctx context.Context, opts ...grpc.CallOption) (pb.Kurma_AttachClient, error) {
+	return c.stream, nil
+}
+
+type attachStream struct {
+	grpc.ClientStream
+	sent      chan *pb.AttachRequest
+	responses []*pb.AttachResponse
+}
+
+func (s *attachStream) Send(req *pb.AttachRequest) error {
+	s.sent <- req
+	return nil
+}
+
+func (s *attachStream) Recv() (*pb.AttachResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestAttach(t *testing.T) {
+	stream := &attachStream{
+		sent: make(chan *pb.AttachRequest, 10),
+		responses: []*pb.AttachResponse{
+			{Output: []byte("started\n")},
+			{Stderr: []byte("warning\n")},
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	opts := AttachOptions{
+		Size:   WindowSize{Rows: 24, Cols: 80},
+		Stdin:  bytes.NewBufferString("input"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	if err := New(&attachClient{stream: stream}).Attach(context.Background(), "web", opts); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "started\n" {
+		t.Fatalf("unexpected stdout %q", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	req := <-stream.sent
+	if req.Uuid != "web" || req.Rows != 24 || req.Cols != 80 {
+		t.Fatalf("unexpected first request %v", req)
+	}
+	if req = <-stream.sent; string(req.Stdin) != "input" {
+		t.Fatalf("unexpected stdin request %v", req)
+	}
+	if req = <-stream.sent; !req.StdinClosed {
+		t.Fatalf("expected stdin to be closed, got %v", req)
+	}
+}
+
+// exportClient is a KurmaClient which only supports exporting containers,
+// returning the chunks as the archive.
+type exportClient struct {
+	pb.KurmaClient
+	req    *pb.ContainerRequest
+	chunks []string
+}
+
+func (c *exportClient) Export(ctx context.Context, in *pb.ContainerRequest, opts ...grpc.CallOption) (pb.Kurma_ExportClient, error) {
+	c.req = in
+	return &chunkStream{chunks: c.chunks}, nil
+}
+
+type chunkStream struct {
+	grpc.ClientStream
+	chunks []string
+}
+
+func (s *chunkStream) Recv() (*pb.ByteChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := &pb.ByteChunk{Bytes: []byte(s.chunks[0])}
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func TestExport(t *testing.T) {
+	rpc := &exportClient{chunks: []string{"first ", "", "second"}}
+
+	var buf bytes.Buffer
+	if err := New(rpc).Export(context.Background(), "web", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if rpc.req.Uuid != "web" {
+		t.Fatalf("unexpected request %v", rpc.req)
+	}
+	if buf.String() != "first second" {
+		t.Fatalf("unexpected archive %q", buf.String())
+	}
+}
+
+func TestParseKurmafile(t *testing.T) {
+	spec, err := ParseKurmafile(strings.NewReader(`# an example
+FROM example.com/base:v1
+run apk add \
+    curl
+RUN ["/bin/echo", "hello world"]
+COPY web/ /srv/
+LABEL version=2 arch=amd64
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &pb.BuildSpec{
+		Base:   "example.com/base:v1",
+		Labels: map[string]string{"version": "2", "arch": "amd64"},
+		Steps: []*pb.BuildStep{
+			{Run: []string{"/bin/sh", "-c", "apk add curl"}},
+			{Run: []string{"/bin/echo", "hello world"}},
+			{Source: "web/", Destination: "/srv/"},
+		},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Fatalf("unexpected spec %v", spec)
+	}
+
+	for _, kurmafile := range []string{
+		"",
+		"FROM example.com/base",
+		"RUN /bin/true",
+		"FROM a\nFROM b\nRUN /bin/true",
+		"FROM example.com/base\nRUN [\"/bin/true\"",
+		"FROM example.com/base\nCOPY ../secret /",
+		"FROM example.com/base\nCOPY /etc/passwd /",
+		"FROM example.com/base\nLABEL version",
+		"FROM example.com/base\nEXPOSE 80",
+	} {
+		if _, err := ParseKurmafile(strings.NewReader(kurmafile)); err == nil {
+			t.Fatalf("expected an error parsing %q", kurmafile)
+		}
+	}
+}
+
+// buildClient is a KurmaClient which only supports builds, recording the
+// requests sent and returning the responses.
+type buildClient struct {
+	pb.KurmaClient
+	stream *buildStream
+}
+
+func (c *buildClient) Build(ctx context.Context, opts ...grpc.CallOption) (pb.Kurma_BuildClient, error) {
+	return c.stream, nil
+}
+
+type buildStream struct {
+	grpc.ClientStream
+	spec      *pb.BuildSpec
+	context   bytes.Buffer
+	closed    bool
+	responses []*pb.BuildResponse
+}
+
+func (s *buildStream) Send(req *pb.BuildRequest) error {
+	if req.Spec != nil {
+		s.spec = req.Spec
+	}
+	s.context.Write(req.Context)
+	return nil
+}
+
+func (s *buildStream) CloseSend() error {
+	s.closed = true
+	return nil
+}
+
+func (s *buildStream) Recv() (*pb.BuildResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kurma-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"web/index.html": "hello", "app.conf": "conf", "unused": "x"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := &buildStream{responses: []*pb.BuildResponse{
+		{Output: []byte("Step 1/3\n")},
+		{Stderr: []byte("warning\n")},
+		{Image: &pb.CommitResponse{Uri: "example.com/app"}},
+	}}
+	spec := &pb.BuildSpec{Base: "example.com/base", Name: "example.com/app", Steps: []*pb.BuildStep{
+		{Source: "web", Destination: "/srv"},
+		{Run: []string{"/bin/true"}},
+		{Source: "app.conf", Destination: "/etc/"},
+	}}
+
+	var stdout, stderr bytes.Buffer
+	image, err := New(&buildClient{stream: stream}).Build(context.Background(), spec, dir, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Uri != "example.com/app" || stream.spec != spec || !stream.closed {
+		t.Fatalf("unexpected build of %v", image)
+	}
+	if stdout.String() != "Step 1/3\n" || stderr.String() != "warning\n" {
+		t.Fatalf("unexpected output %q and %q", stdout.String(), stderr.String())
+	}
+
+	// only the sources the steps copy are sent
+	var names []string
+	tr := tar.NewReader(&stream.context)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"web", "web/index.html", "app.conf"}) {
+		t.Fatalf("unexpected build context %v", names)
+	}
+}
+
+// pushClient is a KurmaClient which only supports pushing images, returning
+// the progress reported.
+type pushClient struct {
+	pb.KurmaClient
+	req       *pb.PushRequest
+	responses []*pb.PushProgress
+}
+
+func (c *pushClient) PushImage(ctx context.Context, in *pb.PushRequest, opts ...grpc.CallOption) (pb.Kurma_PushImageClient, error) {
+	c.req = in
+	return &pushStream{responses: c.responses}, nil
+}
+
+type pushStream struct {
+	grpc.ClientStream
+	responses []*pb.PushProgress
+}
+
+func (s *pushStream) Recv() (*pb.PushProgress, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestPushImage(t *testing.T) {
+	rpc := &pushClient{responses: []*pb.PushProgress{
+		{Sent: 10, Total: 20},
+		{Sent: 20, Total: 20},
+		{Location: "docker://docker.io/library/app:1.0"},
+	}}
+	var sent []int64
+	req := &pb.PushRequest{Uri: "example.com/app:1.0", Destination: "docker://app:1.0"}
+	location, err := New(rpc).PushImage(context.Background(), req, func(s, total int64) {
+		sent = append(sent, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location != "docker://docker.io/library/app:1.0" || rpc.req != req {
+		t.Fatalf("unexpected push to %q", location)
+	}
+	if !reflect.DeepEqual(sent, []int64{10, 20}) {
+		t.Fatalf("unexpected progress %v", sent)
+	}
+
+	// a push ending without a location failed
+	rpc.responses = rpc.responses[:1]
+	if _, err := New(rpc).PushImage(context.Background(), req, nil); err == nil {
+		t.Fatal("expected an error for a push without a location")
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kurma-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Credentials("docker://app") != (Credentials{}) {
+		t.Fatalf("expected no credentials from a missing file")
+	}
+
+	content := `{"registries": {
+		"docker.io": {"username": "hub", "password": "a"},
+		"localhost:5000": {"username": "local", "password": "b"},
+		"example.com": {"username": "web", "password": "c"}
+	}}`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for destination, username := range map[string]string{
+		"docker://app:1.0":                   "hub",
+		"docker://library/app":               "hub",
+		"docker://localhost:5000/app":        "local",
+		"https://example.com/images/app.aci": "web",
+		"example.com/app:1.0":                "web",
+		"example.org/app":                    "",
+	} {
+		if got := cfg.Credentials(destination).Username; got != username {
+			t.Fatalf("expected %q for %s; got %q", username, destination, got)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigFile(path); err == nil {
+		t.Fatal("expected an error for an invalid file")
+	}
+}
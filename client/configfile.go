@@ -0,0 +1,83 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFile is the client's configuration file, which holds the credentials
+// images are pushed to registries with.
+type ConfigFile struct {
+	// Registries maps the host of each registry, such as docker.io or
+	// example.com, to the credentials used with it.
+	Registries map[string]Credentials `json:"registries,omitempty"`
+}
+
+// Credentials authenticate with a registry.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// DefaultConfigFilePath returns where the configuration file is read from,
+// which is $KURMA_CONFIG if it is set, and otherwise .kurma/config.json within
+// the user's home directory.
+func DefaultConfigFilePath() string {
+	if p := os.Getenv("KURMA_CONFIG"); p != "" {
+		return p
+	}
+	return filepath.Join(os.Getenv("HOME"), ".kurma", "config.json")
+}
+
+// LoadConfigFile reads the configuration file at the path. A file which doesn't
+// exist is treated as an empty configuration.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	cfg := &ConfigFile{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Credentials returns the credentials for the registry an image is pushed to
+// at the destination, which is a docker:// URI, an http or https URL, or an
+// image name, whose registry is the domain it starts with. The zero value is
+// returned if the file holds none for it.
+func (cfg *ConfigFile) Credentials(destination string) Credentials {
+	return cfg.Registries[RegistryHost(destination)]
+}
+
+// RegistryHost returns the host of the registry an image is pushed to at the
+// destination. Docker images without a registry are on docker.io.
+func RegistryHost(destination string) string {
+	switch {
+	case strings.HasPrefix(destination, "docker://"):
+		p := strings.TrimPrefix(destination, "docker://")
+		host := strings.SplitN(p, "/", 2)[0]
+		if !strings.Contains(p, "/") || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+			return "docker.io"
+		}
+		return host
+	case strings.HasPrefix(destination, "http://"), strings.HasPrefix(destination, "https://"):
+		u, err := url.Parse(destination)
+		if err != nil {
+			return ""
+		}
+		return u.Host
+	default:
+		return strings.SplitN(strings.SplitN(destination, "/", 2)[0], ":", 2)[0]
+	}
+}
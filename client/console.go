@@ -0,0 +1,20 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// Console opens a shell within the host's console container, starting the
+// container first if it isn't running, and returns the shell's exit code once
+// it exits.
+func (c *Client) Console(ctx context.Context, opts ExecOptions) (int, error) {
+	resp, err := c.rpc.StartConsole(ctx, &pb.None{})
+	if err != nil {
+		return 0, err
+	}
+	return c.Exec(ctx, resp.Uuid, resp.Shell, opts)
+}
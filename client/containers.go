@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// The calls taking a container ref accept the container's UUID, its name, or
+// an unambiguous prefix of its UUID.
+
+// Create creates a container from the request. When the request gives the
+// image's manifest, the container waits for the image to be uploaded with the
+// returned upload ID, otherwise the server retrieves the image from its URI
+// and the created container is returned.
+func (c *Client) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	return c.rpc.Create(ctx, req)
+}
+
+// List returns the server's containers, optionally filtered. Each filter is of
+// the form key=value, as described by pb.ListRequest.
+func (c *Client) List(ctx context.Context, filters ...string) ([]*pb.Container, error) {
+	resp, err := c.rpc.List(ctx, &pb.ListRequest{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Containers, nil
+}
+
+// Get returns the container with its apps and pod manifest.
+func (c *Client) Get(ctx context.Context, ref string) (*pb.Container, error) {
+	return c.rpc.Get(ctx, &pb.ContainerRequest{Uuid: ref})
+}
+
+// Inspect returns the runtime details of the container.
+func (c *Client) Inspect(ctx context.Context, ref string) (*pb.InspectResponse, error) {
+	return c.rpc.Inspect(ctx, &pb.ContainerRequest{Uuid: ref})
+}
+
+// Start starts the apps within a stopped container.
+func (c *Client) Start(ctx context.Context, ref string) error {
+	_, err := c.rpc.Start(ctx, &pb.ContainerRequest{Uuid: ref})
+	return err
+}
+
+// Stop stops the container's apps, waiting up to the grace period for them to
+// exit before they're killed. A zero grace period uses the container's own.
+func (c *Client) Stop(ctx context.Context, ref string, grace time.Duration) error {
+	_, err := c.rpc.Stop(ctx, &pb.StopRequest{Uuid: ref, GracePeriod: int32(grace / time.Second)})
+	return err
+}
+
+// Restart stops the container's apps, as with Stop, and starts them again.
+func (c *Client) Restart(ctx context.Context, ref string, grace time.Duration) error {
+	_, err := c.rpc.Restart(ctx, &pb.StopRequest{Uuid: ref, GracePeriod: int32(grace / time.Second)})
+	return err
+}
+
+// Destroy removes the container. A container whose apps are running is only
+// destroyed if force is set, in which case they're killed.
+func (c *Client) Destroy(ctx context.Context, ref string, force bool) error {
+	_, err := c.rpc.Destroy(ctx, &pb.DestroyRequest{Uuid: ref, Force: force})
+	return err
+}
+
+// Wait blocks until the container exits or its apps are stopped, and returns
+// its exit code and whether it was OOM killed. A timeout of a second or more
+// ends the wait after that long, in whole seconds, with TimedOut set in the
+// response. It returns an error if the container fails to start or is destroyed
+// first.
+func (c *Client) Wait(ctx context.Context, ref string, timeout time.Duration) (*pb.WaitResponse, error) {
+	return c.rpc.Wait(ctx, &pb.WaitRequest{Uuid: ref, Timeout: int32(timeout / time.Second)})
+}
+
+// Apply has the server make its containers match those of the requests, each
+// of which must give the container's name and image uri. If dryRun is set, the
+// changes which are needed are returned without making them.
+func (c *Client) Apply(ctx context.Context, reqs []*pb.CreateRequest, dryRun bool) ([]*pb.ApplyChange, error) {
+	resp, err := c.rpc.Apply(ctx, &pb.ApplyRequest{Containers: reqs, DryRun: dryRun})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Changes, nil
+}
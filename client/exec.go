@@ -0,0 +1,266 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// WindowSize is the size of a terminal.
+type WindowSize struct {
+	Rows uint32
+	Cols uint32
+}
+
+// ExecOptions are the terminal and streams a command is run with.
+type ExecOptions struct {
+	// Tty runs the command in a terminal of the initial size, which is resized
+	// with each size received from Resize.
+	Tty    bool
+	Size   WindowSize
+	Resize <-chan WindowSize
+
+	// Stdin is streamed to the command until it returns EOF, if it is given.
+	// The command's output is written to Stdout and Stderr, and discarded for
+	// those not given.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Exec runs the command within the container and returns its exit code once
+// it exits.
+func (c *Client) Exec(ctx context.Context, ref string, command []string, opts ExecOptions) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	req := &pb.ExecRequest{
+		Uuid:    ref,
+		Command: command,
+		Tty:     opts.Tty,
+		Rows:    opts.Size.Rows,
+		Cols:    opts.Size.Cols,
+	}
+	if err := stream.Send(req); err != nil {
+		return 0, err
+	}
+
+	// Sends may come from multiple goroutines, so serialize them.
+	var sendLock sync.Mutex
+	send := func(r *pb.ExecRequest) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(r)
+	}
+
+	if opts.Tty && opts.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.Resize:
+					if !ok {
+						return
+					}
+					send(&pb.ExecRequest{Rows: size.Rows, Cols: size.Cols})
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if opts.Stdin != nil {
+		go func() {
+			buf := make([]byte, 4096)
+			for {
+				n, err := opts.Stdin.Read(buf)
+				if n > 0 {
+					if serr := send(&pb.ExecRequest{Stdin: buf[:n]}); serr != nil {
+						return
+					}
+				}
+				if err != nil {
+					send(&pb.ExecRequest{StdinClosed: true})
+					return
+				}
+			}
+		}()
+	} else if err := send(&pb.ExecRequest{StdinClosed: true}); err != nil {
+		return 0, err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return 0, fmt.Errorf("connection closed before the command exited")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(resp.Stdout) > 0 && opts.Stdout != nil {
+			opts.Stdout.Write(resp.Stdout)
+		}
+		if len(resp.Stderr) > 0 && opts.Stderr != nil {
+			opts.Stderr.Write(resp.Stderr)
+		}
+		if resp.Exited {
+			stream.CloseSend()
+			return int(resp.ExitCode), nil
+		}
+	}
+}
+
+// AttachOptions are the terminal size and streams used to attach to a
+// container.
+type AttachOptions struct {
+	// Size is the initial size of the container's console, which is resized
+	// with each size received from Resize. They're ignored for containers
+	// without a console.
+	Size   WindowSize
+	Resize <-chan WindowSize
+
+	// Stdin is streamed to the console, or to the app's stdin if the container
+	// was created with it open, until it returns EOF, which closes the app's
+	// stdin. The output is written to Stdout and Stderr, and discarded for
+	// those not given. A console's output is all written to Stdout.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Attach connects to the container's console, or to its apps' stdio if it has
+// none, and copies the output until the container exits.
+func (c *Client) Attach(ctx context.Context, ref string, opts AttachOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.Attach(ctx)
+	if err != nil {
+		return err
+	}
+	req := &pb.AttachRequest{
+		Uuid: ref,
+		Rows: opts.Size.Rows,
+		Cols: opts.Size.Cols,
+	}
+	if err := stream.Send(req); err != nil {
+		return err
+	}
+
+	// Sends may come from multiple goroutines, so serialize them.
+	var sendLock sync.Mutex
+	send := func(r *pb.AttachRequest) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(r)
+	}
+
+	if opts.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.Resize:
+					if !ok {
+						return
+					}
+					send(&pb.AttachRequest{Rows: size.Rows, Cols: size.Cols})
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if opts.Stdin != nil {
+		go func() {
+			buf := make([]byte, 4096)
+			for {
+				n, err := opts.Stdin.Read(buf)
+				if n > 0 {
+					if serr := send(&pb.AttachRequest{Stdin: buf[:n]}); serr != nil {
+						return
+					}
+				}
+				if err != nil {
+					send(&pb.AttachRequest{StdinClosed: true})
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp.Output) > 0 && opts.Stdout != nil {
+			opts.Stdout.Write(resp.Output)
+		}
+		if len(resp.Stderr) > 0 && opts.Stderr != nil {
+			opts.Stderr.Write(resp.Stderr)
+		}
+	}
+}
+
+// LogsOptions select the output Logs returns.
+type LogsOptions struct {
+	// Follow continues streaming the output as it is written, until the
+	// context is done.
+	Follow bool
+
+	// Tail limits the output to its last number of lines, if it is positive.
+	Tail int
+
+	// Since skips the output written before then, if it is set.
+	Since time.Time
+}
+
+// Logs writes the output of the container's apps to stdout and stderr. Only
+// output captured by the json-file log driver can be retrieved.
+func (c *Client) Logs(ctx context.Context, ref string, opts LogsOptions, stdout, stderr io.Writer) error {
+	req := &pb.LogsRequest{
+		Uuid:   ref,
+		Follow: opts.Follow,
+		Tail:   int32(opts.Tail),
+	}
+	if !opts.Since.IsZero() {
+		req.Since = opts.Since.Unix()
+	}
+
+	stream, err := c.rpc.Logs(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp.Stdout) > 0 {
+			if _, err := stdout.Write(resp.Stdout); err != nil {
+				return err
+			}
+		}
+		if len(resp.Stderr) > 0 {
+			if _, err := stderr.Write(resp.Stderr); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"io"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// ExportImage writes the ACI retrieved from the URI, as held in the server's
+// image store, to w.
+func (c *Client) ExportImage(ctx context.Context, uri string, w io.Writer) error {
+	stream, err := c.rpc.ExportImage(ctx, &pb.ImageRequest{Uri: uri})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, pb.NewByteStreamReader(stream, nil))
+	return err
+}
+
+// Export writes a tar archive of the container's root filesystem, as it
+// currently is, to w.
+func (c *Client) Export(ctx context.Context, ref string, w io.Writer) error {
+	stream, err := c.rpc.Export(ctx, &pb.ContainerRequest{Uuid: ref})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, pb.NewByteStreamReader(stream, nil))
+	return err
+}
@@ -0,0 +1,175 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apcera/util/tarhelper"
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/remote"
+)
+
+// CreateFromFile creates a container from the request for the local ACI file,
+// or OCI image layout archive, which is read for the image's manifest. The container is created pending
+// the upload of the image, and the returned upload ID is given to UploadFile.
+func (c *Client) CreateFromFile(ctx context.Context, req *pb.CreateRequest, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	manifest, err := ReadManifest(f)
+	if err != nil {
+		return "", err
+	}
+
+	req.Manifest = manifest
+	resp, err := c.rpc.Create(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.ImageUploadId, nil
+}
+
+// UploadFile uploads the local ACI file for the container created with the
+// upload ID, returning the container once the upload completes. If the upload
+// is interrupted, calling UploadFile again resumes it from however much of the
+// file the server received. If progress is given, it is called with the
+// number of bytes the server has received as the upload proceeds.
+func (c *Client) UploadFile(ctx context.Context, uploadID, path string, progress func(int64)) (*pb.Container, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pb.UploadImage(ctx, c.rpc, uploadID, f, progress)
+}
+
+// CreateFromDirectory creates a container from the request for the image
+// layout within the directory, with its manifest file and rootfs directory.
+// The layout is archived as it is uploaded, and since the archive may differ
+// if it is written again, an interrupted upload can't be resumed. If progress
+// is given, it is called with the number of bytes the server has received.
+// The directory may instead hold an OCI image layout, which the server converts
+// to an ACI.
+func (c *Client) CreateFromDirectory(ctx context.Context, req *pb.CreateRequest, dir string, progress func(int64)) (*pb.Container, error) {
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err == nil {
+		return c.createFromOCILayout(ctx, req, dir, progress)
+	}
+
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, "manifest"))
+	if err != nil {
+		return nil, fmt.Errorf("directory is not an image layout: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "rootfs")); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("directory is not an image layout: missing the rootfs directory")
+	}
+
+	req.Manifest = manifest
+	resp, err := c.rpc.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		tw := tarhelper.NewTar(w, dir)
+		tw.IncludeOwners = true
+		w.CloseWithError(tw.Archive())
+	}()
+	defer r.Close()
+	return pb.UploadImage(ctx, c.rpc, resp.ImageUploadId, r, progress)
+}
+
+// createFromOCILayout creates a container from the OCI image layout within the
+// directory. Its manifest is that of the ACI the layout converts to, so the
+// layout is archived to a temporary file to be read for it before the archive
+// is uploaded.
+func (c *Client) createFromOCILayout(ctx context.Context, req *pb.CreateRequest, dir string, progress func(int64)) (*pb.Container, error) {
+	f, err := ioutil.TempFile("", "kurma-oci-layout")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	if err := tarhelper.NewTar(f, dir).Archive(); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	manifest, err := ReadManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	req.Manifest = manifest
+	resp, err := c.rpc.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return pb.UploadImage(ctx, c.rpc, resp.ImageUploadId, f, progress)
+}
+
+// ReadManifest returns the manifest file of the ACI read from r, which may be
+// compressed. If r is an io.ReadSeeker holding an OCI image layout, the
+// manifest of the ACI it converts to is returned.
+func ReadManifest(r io.Reader) ([]byte, error) {
+	arch, err := tarhelper.DetectArchiveCompression(r)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := false
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch filepath.Clean(header.Name) {
+		case "manifest":
+			return ioutil.ReadAll(arch)
+		case "oci-layout", "index.json":
+			layout = true
+		}
+	}
+
+	rs, ok := r.(io.ReadSeeker)
+	if !layout || !ok {
+		return nil, fmt.Errorf("failed to locate manifest file")
+	}
+	if _, err := rs.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	manifest, err := remote.ConvertOCILayout(ioutil.Discard, rs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(manifest)
+}
+
+// Commit creates an image from the container's filesystem as it currently is,
+// with the manifest of the container's image given the name and the labels
+// added to it. The image is held in the server's image store under the
+// returned URI.
+func (c *Client) Commit(ctx context.Context, ref, name string, labels map[string]string) (*pb.CommitResponse, error) {
+	return c.rpc.Commit(ctx, &pb.CommitRequest{Uuid: ref, Name: name, Labels: labels})
+}
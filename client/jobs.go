@@ -0,0 +1,31 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// AddJob schedules a container to be created from the request each time the
+// cron expression matches. The request must give the image's uri.
+func (c *Client) AddJob(ctx context.Context, name, schedule string, req *pb.CreateRequest) error {
+	_, err := c.rpc.AddJob(ctx, &pb.AddJobRequest{Name: name, Schedule: schedule, Create: req})
+	return err
+}
+
+// ListJobs returns the server's jobs along with their recent runs.
+func (c *Client) ListJobs(ctx context.Context) ([]*pb.Job, error) {
+	resp, err := c.rpc.ListJobs(ctx, &pb.None{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// RemoveJob removes the named job, so it is no longer run.
+func (c *Client) RemoveJob(ctx context.Context, name string) error {
+	_, err := c.rpc.RemoveJob(ctx, &pb.JobRequest{Name: name})
+	return err
+}
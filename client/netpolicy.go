@@ -0,0 +1,32 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// SetNetworkPolicy adds the network policy, or replaces the one with the same
+// name.
+func (c *Client) SetNetworkPolicy(ctx context.Context, policy *pb.NetworkPolicy) error {
+	_, err := c.rpc.SetNetworkPolicy(ctx, policy)
+	return err
+}
+
+// ListNetworkPolicies returns the server's network policies, sorted by name.
+func (c *Client) ListNetworkPolicies(ctx context.Context) ([]*pb.NetworkPolicy, error) {
+	resp, err := c.rpc.ListNetworkPolicies(ctx, &pb.None{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Policies, nil
+}
+
+// RemoveNetworkPolicy removes the named network policy, along with the
+// restrictions it made.
+func (c *Client) RemoveNetworkPolicy(ctx context.Context, name string) error {
+	_, err := c.rpc.RemoveNetworkPolicy(ctx, &pb.NetworkPolicyRequest{Name: name})
+	return err
+}
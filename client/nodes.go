@@ -0,0 +1,19 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// ListNodes returns the hosts in the server's cluster, starting with the
+// server's own host, whose endpoint is empty.
+func (c *Client) ListNodes(ctx context.Context) ([]*pb.Node, error) {
+	resp, err := c.rpc.ListNodes(ctx, &pb.None{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Nodes, nil
+}
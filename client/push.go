@@ -0,0 +1,38 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// PushImage has the server push an image held in its image store to the
+// request's destination, and returns where it was pushed to. Progress, if
+// given, is called with the bytes sent of the total as the server reports
+// them.
+func (c *Client) PushImage(ctx context.Context, req *pb.PushRequest, progress func(sent, total int64)) (string, error) {
+	stream, err := c.rpc.PushImage(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return "", fmt.Errorf("the push ended without the image's location")
+		}
+		if err != nil {
+			return "", err
+		}
+		if resp.Location != "" {
+			return resp.Location, nil
+		}
+		if progress != nil {
+			progress(resp.Sent, resp.Total)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// CreateSecret stores a secret on the server, which containers may then be
+// created with. Its value can't be retrieved through the API.
+func (c *Client) CreateSecret(ctx context.Context, name string, value []byte) error {
+	_, err := c.rpc.CreateSecret(ctx, &pb.SecretRequest{Name: name, Value: value})
+	return err
+}
+
+// ListSecrets returns the names of the server's secrets.
+func (c *Client) ListSecrets(ctx context.Context) ([]string, error) {
+	resp, err := c.rpc.ListSecrets(ctx, &pb.None{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Secrets, nil
+}
+
+// DeleteSecret deletes the named secret.
+func (c *Client) DeleteSecret(ctx context.Context, name string) error {
+	_, err := c.rpc.DeleteSecret(ctx, &pb.SecretRequest{Name: name})
+	return err
+}
@@ -0,0 +1,30 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// CreateVolume creates a named volume, which persists until it is deleted.
+func (c *Client) CreateVolume(ctx context.Context, name string) error {
+	_, err := c.rpc.CreateVolume(ctx, &pb.VolumeRequest{Name: name})
+	return err
+}
+
+// ListVolumes returns the names of the server's volumes.
+func (c *Client) ListVolumes(ctx context.Context) ([]string, error) {
+	resp, err := c.rpc.ListVolumes(ctx, &pb.None{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Volumes, nil
+}
+
+// DeleteVolume deletes the named volume and its data.
+func (c *Client) DeleteVolume(ctx context.Context, name string) error {
+	_, err := c.rpc.DeleteVolume(ctx, &pb.VolumeRequest{Name: name})
+	return err
+}
@@ -0,0 +1,274 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util"
+	"github.com/apcera/kurma/util/gpt"
+)
+
+// assembleStorageArrays assembles the RAID arrays and then activates the LVM
+// volume groups, creating those which don't exist yet on blank devices, so the
+// disks built on them can be mounted. Each one which fails is logged and
+// reported in the host's storage status, rather than failing the boot.
+func (r *runner) assembleStorageArrays() error {
+	if len(r.config.RaidArrays) == 0 && len(r.config.VolumeGroups) == 0 {
+		return nil
+	}
+
+	r.log.Info("Assembling storage arrays...")
+	for _, a := range r.config.RaidArrays {
+		if err := r.assembleRaidArray(a); err != nil {
+			r.log.Errorf("- %v", err)
+			r.storageErrors["md:"+a.Name] = err.Error()
+		}
+	}
+	for _, vg := range r.config.VolumeGroups {
+		if err := r.activateVolumeGroup(vg); err != nil {
+			r.log.Errorf("- %v", err)
+			r.storageErrors["vg:"+vg.Name] = err.Error()
+		}
+	}
+	return nil
+}
+
+// assembleRaidArray assembles the array from its devices, or creates it if none
+// of them hold any data.
+func (r *runner) assembleRaidArray(a *kurmaRaidArray) error {
+	if a.Name == "" || strings.Contains(a.Name, "/") {
+		return fmt.Errorf("invalid RAID array name %q", a.Name)
+	}
+	device := raidDevicePath(a.Name)
+	if _, err := os.Stat(device); err == nil {
+		r.log.Debugf("- RAID array %s is already assembled", a.Name)
+		return r.checkRaidArray(a.Name, device)
+	}
+
+	devices, missing := resolveDevices(a.Devices)
+	for _, spec := range missing {
+		r.log.Warnf("- unable to resolve device %q of RAID array %s", spec, a.Name)
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("none of the devices of RAID array %s were found", a.Name)
+	}
+
+	args := []string{"--assemble", device}
+	if a.AllowDegraded {
+		args = append(args, "--run")
+	}
+	err := runCommand("mdadm", append(args, devices...)...)
+	if err != nil {
+		if len(missing) > 0 || !allBlank(devices) || (a.Create != nil && !*a.Create) {
+			return fmt.Errorf("failed to assemble RAID array %s: %v", a.Name, err)
+		}
+		if a.Level == "" {
+			return fmt.Errorf("RAID array %s can't be created without a level", a.Name)
+		}
+		r.log.Infof("- creating %s array %s from %s", a.Level, a.Name, strings.Join(devices, ", "))
+		args := []string{
+			"--create", device, "--run", "--metadata=1.2", "--name=" + a.Name,
+			"--level=" + a.Level, fmt.Sprintf("--raid-devices=%d", len(devices)),
+		}
+		if err := runCommand("mdadm", append(args, devices...)...); err != nil {
+			return fmt.Errorf("failed to create RAID array %s: %v", a.Name, err)
+		}
+	}
+	return r.checkRaidArray(a.Name, device)
+}
+
+// checkRaidArray logs a warning if the array is degraded.
+func (r *runner) checkRaidArray(name, device string) error {
+	status, err := raidArrayStatus(name, device)
+	if err != nil {
+		return err
+	}
+	if status.Missing > 0 {
+		r.log.Warnf("- RAID array %s is degraded, %d of its %d devices are missing", name, status.Missing, status.Devices)
+	} else {
+		r.log.Infof("- assembled RAID array %s", name)
+	}
+	return nil
+}
+
+// activateVolumeGroup activates the volume group, creating it if it doesn't
+// exist and none of its devices hold any data, and creates the logical volumes
+// which don't exist yet.
+func (r *runner) activateVolumeGroup(vg *kurmaVolumeGroup) error {
+	if vg.Name == "" || strings.Contains(vg.Name, "/") {
+		return fmt.Errorf("invalid volume group name %q", vg.Name)
+	}
+
+	if err := runCommand("lvm", "vgs", vg.Name); err != nil {
+		devices, missing := resolveDevices(vg.Devices)
+		if len(devices) == 0 || len(missing) > 0 || !allBlank(devices) || (vg.Create != nil && !*vg.Create) {
+			return fmt.Errorf("volume group %s was not found", vg.Name)
+		}
+		r.log.Infof("- creating volume group %s from %s", vg.Name, strings.Join(devices, ", "))
+		if err := runCommand("lvm", append([]string{"pvcreate", "--yes"}, devices...)...); err != nil {
+			return fmt.Errorf("failed to create the physical volumes of %s: %v", vg.Name, err)
+		}
+		if err := runCommand("lvm", append([]string{"vgcreate", vg.Name}, devices...)...); err != nil {
+			return fmt.Errorf("failed to create volume group %s: %v", vg.Name, err)
+		}
+	}
+	if err := runCommand("lvm", "vgchange", "--activate", "y", vg.Name); err != nil {
+		return fmt.Errorf("failed to activate volume group %s: %v", vg.Name, err)
+	}
+
+	for _, lv := range vg.Volumes {
+		if err := runCommand("lvm", "lvs", vg.Name+"/"+lv.Name); err == nil {
+			continue
+		}
+		size := []string{"--extents", "100%FREE"}
+		if lv.SizeMB > 0 {
+			size = []string{"--size", fmt.Sprintf("%dm", lv.SizeMB)}
+		}
+		r.log.Infof("- creating logical volume %s/%s", vg.Name, lv.Name)
+		args := append([]string{"lvcreate", "--yes", "--name", lv.Name}, size...)
+		if err := runCommand("lvm", append(args, vg.Name)...); err != nil {
+			return fmt.Errorf("failed to create logical volume %s/%s: %v", vg.Name, lv.Name, err)
+		}
+	}
+	r.log.Infof("- activated volume group %s", vg.Name)
+	return nil
+}
+
+// hostStorage returns the health of the configured RAID arrays and the state of
+// the volume groups.
+func (r *runner) hostStorage() *pb.HostStorageResponse {
+	resp := &pb.HostStorageResponse{}
+	for _, a := range r.config.RaidArrays {
+		status, err := raidArrayStatus(a.Name, raidDevicePath(a.Name))
+		if err != nil {
+			status = &pb.RaidArray{Name: a.Name, Level: a.Level, State: "inactive", Error: err.Error()}
+		}
+		if msg := r.storageErrors["md:"+a.Name]; msg != "" {
+			status.Error = msg
+		}
+		resp.Arrays = append(resp.Arrays, status)
+	}
+	for _, vg := range r.config.VolumeGroups {
+		status, err := volumeGroupStatus(vg.Name)
+		if err != nil {
+			status = &pb.VolumeGroup{Name: vg.Name, Error: err.Error()}
+		}
+		if msg := r.storageErrors["vg:"+vg.Name]; msg != "" {
+			status.Error = msg
+		}
+		resp.VolumeGroups = append(resp.VolumeGroups, status)
+	}
+	return resp
+}
+
+// raidDevicePath returns the path of the named RAID array.
+func raidDevicePath(name string) string {
+	return filepath.Join("/dev/md", name)
+}
+
+// raidArrayStatus reads the health of the array from sysfs.
+func raidArrayStatus(name, device string) (*pb.RaidArray, error) {
+	node, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return nil, fmt.Errorf("RAID array %s is not assembled", name)
+	}
+	md := filepath.Join("/sys/block", filepath.Base(node), "md")
+	read := func(attr string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(md, attr))
+		return strings.TrimSpace(string(b))
+	}
+	if _, err := os.Stat(md); err != nil {
+		return nil, fmt.Errorf("%s is not a RAID array", device)
+	}
+
+	status := &pb.RaidArray{
+		Name:       name,
+		Device:     node,
+		Level:      read("level"),
+		State:      read("array_state"),
+		SyncAction: read("sync_action"),
+	}
+	devices, _ := strconv.Atoi(read("raid_disks"))
+	missing, _ := strconv.Atoi(read("degraded"))
+	status.Devices, status.Missing = int32(devices), int32(missing)
+	status.SyncProgress = syncProgress(read("sync_completed"))
+	return status, nil
+}
+
+// syncProgress converts the sync_completed attribute of an array, which is the
+// sectors done out of the total or "none", into a percentage.
+func syncProgress(completed string) float64 {
+	parts := strings.Split(completed, "/")
+	if len(parts) != 2 {
+		return 0
+	}
+	done, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	total, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || total == 0 {
+		return 0
+	}
+	return done / total * 100
+}
+
+// volumeGroupStatus returns the size and free space of the volume group, and
+// its logical volumes.
+func volumeGroupStatus(name string) (*pb.VolumeGroup, error) {
+	out, err := exec.Command("lvm", "vgs", "--noheadings", "--nosuffix", "--units", "b",
+		"--options", "vg_size,vg_free", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("volume group %s was not found", name)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected output from vgs: %q", string(out))
+	}
+	status := &pb.VolumeGroup{Name: name}
+	status.Size, _ = strconv.ParseInt(fields[0], 10, 64)
+	status.Free, _ = strconv.ParseInt(fields[1], 10, 64)
+
+	out, err = exec.Command("lvm", "lvs", "--noheadings", "--options", "lv_name", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the logical volumes of %s: %v", name, err)
+	}
+	status.Volumes = strings.Fields(string(out))
+	return status, nil
+}
+
+// resolveDevices resolves the device specs, returning those which were found
+// and the specs of those which weren't.
+func resolveDevices(specs []string) (devices, missing []string) {
+	for _, spec := range specs {
+		if device := util.ResolveDevice(spec); device != "" {
+			devices = append(devices, device)
+		} else {
+			missing = append(missing, spec)
+		}
+	}
+	return devices, missing
+}
+
+// allBlank returns whether none of the devices hold any data.
+func allBlank(devices []string) bool {
+	for _, device := range devices {
+		if blank, err := gpt.IsBlank(device); err != nil || !blank {
+			return false
+		}
+	}
+	return true
+}
+
+// runCommand runs the command, returning its output in the error if it fails.
+func runCommand(name string, args ...string) error {
+	if b, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
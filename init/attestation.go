@@ -0,0 +1,148 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/tpm"
+)
+
+// measureBoot measures the kurma binary and the configuration into the TPM
+// before any containers are run, so that a quote shows what the host is
+// running. The measurements are appended to a log which outlives an upgrade,
+// whose binary is then measured in turn.
+func (r *runner) measureBoot() error {
+	cfg := r.config.Attestation
+	if cfg.Enabled == nil || !*cfg.Enabled {
+		r.log.Trace("Skipping measured boot")
+		return nil
+	}
+	pcr := cfg.PCR
+	if pcr <= 0 {
+		pcr = defaultMeasurementPCR
+	}
+
+	events, err := loadMeasurements()
+	if err != nil {
+		return err
+	}
+	r.measurements = events
+
+	exe, err := os.Open("/proc/self/exe")
+	if err != nil {
+		return err
+	}
+	defer exe.Close()
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	config, err := json.Marshal(r.config)
+	if err != nil {
+		return err
+	}
+
+	measurements := []struct {
+		description string
+		data        io.Reader
+	}{
+		{"kurma binary " + path, exe},
+		{"kurma configuration", bytes.NewReader(config)},
+	}
+	for _, m := range measurements {
+		e, err := tpm.Measure(pcr, m.description, m.data)
+		if err != nil {
+			return err
+		}
+		r.measurements = append(r.measurements, e)
+		if err := saveMeasurements(r.measurements); err != nil {
+			return fmt.Errorf("failed to save the measurements: %v", err)
+		}
+	}
+	r.log.Infof("Measured the kurma binary and configuration into PCR %d", pcr)
+	return nil
+}
+
+// loadMeasurements returns the measurements logged before an upgrade, if
+// there were any.
+func loadMeasurements() ([]*tpm.Event, error) {
+	b, err := ioutil.ReadFile(measurementsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var events []*tpm.Event
+	if err := json.Unmarshal(b, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", measurementsFile, err)
+	}
+	return events, nil
+}
+
+// saveMeasurements writes the log of the measurements.
+func saveMeasurements(events []*tpm.Event) error {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	tmp := measurementsFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, os.FileMode(0644)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, measurementsFile)
+}
+
+// attestHost quotes the configured PCRs with the nonce, and returns the quote
+// along with the log of the measurements.
+func (r *runner) attestHost(nonce []byte) (*pb.AttestResponse, error) {
+	cfg := r.config.Attestation
+	if cfg.Enabled == nil || !*cfg.Enabled {
+		return nil, fmt.Errorf("measured boot is not enabled on the host")
+	}
+	if cfg.AttestationKey == "" {
+		return nil, fmt.Errorf("the host has no attestation key configured")
+	}
+	pcrs := cfg.QuotePCRs
+	if len(pcrs) == 0 {
+		pcr := cfg.PCR
+		if pcr <= 0 {
+			pcr = defaultMeasurementPCR
+		}
+		pcrs = []int{0, 1, 2, 3, 4, 5, 6, 7, pcr}
+	}
+
+	q, err := tpm.QuotePCRs(cfg.AttestationKey, pcrs, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote the PCRs: %v", err)
+	}
+	resp := &pb.AttestResponse{
+		Quote:          q.Message,
+		Signature:      q.Signature,
+		AttestationKey: string(q.PublicKey),
+		HashAlgorithm:  tpm.HashAlgorithm,
+	}
+	indices := make([]int, 0, len(q.PCRs))
+	for i := range q.PCRs {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		resp.Pcrs = append(resp.Pcrs, &pb.PCRValue{Index: int32(i), Digest: q.PCRs[i]})
+	}
+	for _, e := range r.measurements {
+		resp.Events = append(resp.Events, &pb.MeasurementEvent{
+			Pcr:         int32(e.PCR),
+			Description: e.Description,
+			Digest:      e.Digest,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/apcera/kurma/stage1/server"
+)
+
+// internalAPIUser is the name of the read-only user the init process itself
+// authenticates to the API as, such as to check the server is responsive.
+const internalAPIUser = "kurma-init"
+
+// apiAuth returns the users the API requires clients to authenticate as, along
+// with the internal user, whose token is kept in apiToken. It returns nil if
+// authentication isn't configured.
+func (r *runner) apiAuth() (*server.AuthOptions, error) {
+	cfg := r.config.Services.API.Auth
+	if cfg == nil {
+		return nil, nil
+	}
+
+	auth := &server.AuthOptions{}
+	for _, u := range cfg.Users {
+		if u.Name == "" || u.Name == internalAPIUser {
+			return nil, fmt.Errorf("invalid API user name %q", u.Name)
+		}
+		role, err := server.ParseRole(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("API user %q: %v", u.Name, err)
+		}
+		token := u.Token
+		if u.TokenFile != "" {
+			b, err := ioutil.ReadFile(u.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the token of API user %q: %v", u.Name, err)
+			}
+			token = strings.TrimSpace(string(b))
+		}
+		auth.Users = append(auth.Users, &server.User{Name: u.Name, Role: role, Token: token})
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate the internal API token: %v", err)
+	}
+	r.apiToken = hex.EncodeToString(b)
+	auth.Users = append(auth.Users, &server.User{Name: internalAPIUser, Role: server.RoleReadOnly, Token: r.apiToken})
+	return auth, nil
+}
@@ -3,84 +3,116 @@
 package init
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/dns"
+	"github.com/apcera/kurma/stage1/metadata"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/secrets"
 	"github.com/apcera/kurma/stage1/server"
+	"github.com/apcera/kurma/stage1/storage"
+	"github.com/apcera/kurma/stage1/upgrade"
+	"github.com/apcera/kurma/stage1/vm"
 	"github.com/apcera/kurma/util"
-	"github.com/apcera/logray"
-	"github.com/apcera/util/aciremote"
+	"github.com/apcera/kurma/util/dhcp"
+	"github.com/apcera/kurma/util/gpt"
+	"github.com/apcera/kurma/util/lsm"
+	"github.com/apcera/kurma/util/remote"
 	"github.com/apcera/util/proc"
 	"github.com/appc/spec/discovery"
 	"github.com/vishvananda/netlink"
 )
 
-// loadConfigurationFile loads the configuration for the process. It will take
-// the default coded configuration, merge it with the base configuration file
-// within the initrd filesystem, and then check for the OEM configuration to
-// merge in as well.
+// loadConfigurationFile loads the configuration for the process.
 func (r *runner) loadConfigurationFile() error {
+	cfg, err := r.readConfiguration()
+	if err != nil {
+		return err
+	}
+	r.config = cfg
+	return nil
+}
+
+// readConfiguration reads the configuration for the process. It will take the
+// default coded configuration, merge it with the base configuration file within
+// the initrd filesystem, and then check for the OEM configuration to merge in
+// as well. Lastly, the kurma.* parameters on the kernel command line override
+// the configuration.
+func (r *runner) readConfiguration() (*kurmaConfig, error) {
+	cfg := defaultConfiguration()
+
 	// first, load the config from the local filesystem in the initrd
 	diskConfig, err := getConfigurationFromFile(configurationFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if diskConfig != nil {
-		r.config.mergeConfig(diskConfig)
+		cfg.mergeConfig(diskConfig)
 	}
 
-	// if an OEM config is specified, attempt to find it
-	if r.config.OEMConfig != nil {
-		device := util.ResolveDevice(r.config.OEMConfig.Device)
-		if device == "" {
-			r.log.Warnf("Unable to resolve oem config device %q, skipping", r.config.OEMConfig.Device)
-			return nil
-		}
-		fstype, _ := util.GetFsType(device)
+	r.mergeOEMConfiguration(cfg)
 
-		// FIXME check fstype against currently supported types
+	for _, err := range cfg.applyKernelParams(kernelParams()) {
+		r.log.Warnf("Ignoring kernel parameter: %v", err)
+	}
+	return cfg, nil
+}
 
-		// mount the disk
-		diskPath := filepath.Join(mountPath, strings.Replace(device, "/", "_", -1))
+// mergeOEMConfiguration merges in the OEM configuration, if one is specified.
+// The OEM disk is mounted unless it already is. Failures are logged, and the
+// configuration is left as it was.
+func (r *runner) mergeOEMConfiguration(cfg *kurmaConfig) {
+	if cfg.OEMConfig == nil {
+		return
+	}
+	device := util.ResolveDevice(cfg.OEMConfig.Device)
+	if device == "" {
+		r.log.Warnf("Unable to resolve oem config device %q, skipping", cfg.OEMConfig.Device)
+		return
+	}
+	fstype, _ := util.GetFsType(device)
+
+	// FIXME check fstype against currently supported types
+
+	// mount the disk
+	diskPath := filepath.Join(mountPath, strings.Replace(device, "/", "_", -1))
+	mounts, err := proc.MountPoints()
+	if err != nil {
+		r.log.Errorf("failed to read existing mount points: %v", err)
+		return
+	}
+	if _, mounted := mounts[diskPath]; !mounted {
 		if err := handleMount(device, diskPath, fstype, 0, ""); err != nil {
 			r.log.Errorf("failed to mount oem config disk %q: %v", device, err)
-			return nil
-		}
-
-		// attempt to load the configuration
-		configPath := filepath.Join(diskPath, r.config.OEMConfig.ConfigPath)
-		r.log.Infof("Loading OEM config: %q", configPath)
-		diskConfig, err := getConfigurationFromFile(configPath)
-		if err != nil {
-			r.log.Errorf("Failed to load oem config: %v", err)
-			return nil
-		}
-		if diskConfig != nil {
-			r.config.mergeConfig(diskConfig)
+			return
 		}
 	}
 
-	return nil
-}
-
-// configureLogging is used to enable tracing logging, if it is turned on in the
-// configuration.
-func (r *runner) configureLogging() error {
-	if r.config.Debug {
-		logray.ResetDefaultLogLevel(logray.ALL)
+	// attempt to load the configuration
+	configPath := filepath.Join(diskPath, cfg.OEMConfig.ConfigPath)
+	r.log.Infof("Loading OEM config: %q", configPath)
+	diskConfig, err := getConfigurationFromFile(configPath)
+	if err != nil {
+		r.log.Errorf("Failed to load oem config: %v", err)
+		return
+	}
+	if diskConfig != nil {
+		cfg.mergeConfig(diskConfig)
 	}
-	return nil
 }
 
 // createSystemMounts configured the default mounts for the host. Since kurma is
@@ -155,18 +187,38 @@ func (r *runner) mountCgroups() error {
 	cgroupTypes := []string{
 		"blkio",
 		"cpu",
+		"cpuset",
 		"cpuacct",
 		"devices",
 		"memory",
 	}
 
+	// Optional cgroups, which are mounted only when the kernel has them.
+	optionalCgroupTypes := []string{
+		"hugetlb",
+	}
+
+	// use the unified hierarchy of cgroup v2 if it is configured or detected
+	unified, err := r.useUnifiedCgroups()
+	if err != nil {
+		return err
+	} else if unified {
+		return r.mountUnifiedCgroups()
+	}
+
 	r.log.Info("Setting up cgroups")
 
-	// mount the cgroups
-	for _, cgrouptype := range cgroupTypes {
+	// mount the cgroups, falling back to the unified hierarchy if the kernel
+	// can't mount them and no version is configured
+	for i, cgrouptype := range cgroupTypes {
 		location := filepath.Join(cgroupsMount, cgrouptype)
 		r.log.Tracef("- mounting cgroup %q to %q", cgrouptype, location)
 		if err := handleMount("none", location, "cgroup", 0, cgrouptype); err != nil {
+			if r.cgroupVersion() == 0 && cgroup2Supported() {
+				r.log.Warnf("Failed to mount cgroup %q, using the unified hierarchy: %v", cgrouptype, err)
+				unmountCgroups(cgroupTypes[:i+1])
+				return r.mountUnifiedCgroups()
+			}
 			return fmt.Errorf("failed to mount cgroup %q: %v", cgrouptype, err)
 		}
 
@@ -190,9 +242,47 @@ func (r *runner) mountCgroups() error {
 		}
 	}
 
+	available, err := availableCgroups()
+	if err != nil {
+		r.log.Warnf("Failed to check the kernel's cgroups: %v", err)
+	}
+	for _, cgrouptype := range optionalCgroupTypes {
+		if !available[cgrouptype] {
+			r.log.Debugf("- the kernel has no %q cgroup, skipping", cgrouptype)
+			continue
+		}
+		location := filepath.Join(cgroupsMount, cgrouptype)
+		r.log.Tracef("- mounting cgroup %q to %q", cgrouptype, location)
+		if err := handleMount("none", location, "cgroup", 0, cgrouptype); err != nil {
+			r.log.Warnf("Failed to mount cgroup %q: %v", cgrouptype, err)
+			os.Remove(location)
+		}
+	}
+
 	return nil
 }
 
+// availableCgroups returns the cgroup controllers the kernel has which are
+// enabled, from /proc/cgroups.
+func availableCgroups() (map[string]bool, error) {
+	f, err := os.Open("/proc/cgroups")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	available := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		available[fields[0]] = fields[3] == "1"
+	}
+	return available, scanner.Err()
+}
+
 // loadModules handles loading all of the kernel modules that are specified in
 // the configuration.
 func (r *runner) loadModules() error {
@@ -202,13 +292,21 @@ func (r *runner) loadModules() error {
 
 	r.log.Infof("Loading specified modules [%s]", strings.Join(r.config.Modules, ", "))
 	for _, mod := range r.config.Modules {
-		if b, err := exec.Command("modprobe", mod).CombinedOutput(); err != nil {
-			r.log.Errorf("- Failed to load module %q: %s", mod, string(b))
+		if err := loadModule(mod); err != nil {
+			r.log.Errorf("- %v", err)
 		}
 	}
 	return nil
 }
 
+// loadModule loads the kernel module with modprobe.
+func loadModule(mod string) error {
+	if b, err := exec.Command("modprobe", mod).CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to load module %q: %s", mod, string(b))
+	}
+	return nil
+}
+
 // mountDisks handles walking the disk configuration to configure the specified
 // disks, mount them, and make them accessible at the right locations.
 func (r *runner) mountDisks() error {
@@ -231,6 +329,14 @@ func (r *runner) mountDisks() error {
 			r.log.Warnf("Unable to resolve device %q, skipping", disk.Device)
 			continue
 		}
+		if disk.Partition {
+			partition, err := r.provisionPartition(device)
+			if err != nil {
+				r.log.Errorf("failed to partition disk %q: %v", device, err)
+				continue
+			}
+			device = partition
+		}
 		fstype, _ := util.GetFsType(device)
 
 		// FIXME check fstype against currently supported types
@@ -238,7 +344,7 @@ func (r *runner) mountDisks() error {
 		// format it, if needed
 		if shouldFormatDisk(disk, fstype) {
 			r.log.Infof("Formatting disk %s to %s", device, disk.FsType)
-			if err := formatDisk(device, disk.FsType); err != nil {
+			if err := formatDisk(device, disk.FsType, disk.Label); err != nil {
 				r.log.Errorf("failed to format disk %q: %v", device, err)
 				continue
 			}
@@ -270,8 +376,18 @@ func (r *runner) mountDisks() error {
 				continue
 			}
 
-			// bind mount it to the kurma path
+			// bind mount it to the kurma path, or the configured volumes path
 			kurmaUsagePath := filepath.Join(kurmaPath, string(usage))
+			switch usage {
+			case kurmaPathVolumes:
+				kurmaUsagePath = r.config.Paths.Volumes
+			case kurmaPathImages:
+				kurmaUsagePath = r.config.Paths.Images
+			case kurmaPathDownloads:
+				kurmaUsagePath = r.config.Paths.Downloads
+			case kurmaPathStorage:
+				kurmaUsagePath = r.config.Paths.Storage
+			}
 			if err := bindMount(usagePath, kurmaUsagePath); err != nil {
 				r.log.Errorf("failed to bind mount the selected volume: %v", err)
 				continue
@@ -281,6 +397,41 @@ func (r *runner) mountDisks() error {
 	return nil
 }
 
+// provisionPartition returns the path of the disk's first partition, creating
+// it if the disk is blank. A disk which isn't blank but has no partition table,
+// such as one formatted as a whole, is used as it is.
+func (r *runner) provisionPartition(device string) (string, error) {
+	hasTable, err := gpt.HasPartitionTable(device)
+	if err != nil {
+		return "", err
+	}
+	if !hasTable {
+		blank, err := gpt.IsBlank(device)
+		if err != nil {
+			return "", err
+		}
+		if !blank {
+			r.log.Debugf("Disk %s has no partition table but isn't blank, using it as is", device)
+			return device, nil
+		}
+		r.log.Infof("Partitioning blank disk %s", device)
+		if err := gpt.Partition(device, "kurma"); err != nil {
+			return "", err
+		}
+	}
+
+	// wait for the partition's node to be created
+	partition := gpt.PartitionPath(device, 1)
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		if _, err := os.Stat(partition); err == nil {
+			return partition, nil
+		}
+		if time.Since(start) > partitionTimeout {
+			return "", fmt.Errorf("partition %s did not appear", partition)
+		}
+	}
+}
+
 // cleanOldPods removes the directories for any pods remaining from a previous
 // run. If the host is booting up, those pods are obviously dead and stale.
 func (r *runner) cleanOldPods() error {
@@ -319,11 +470,16 @@ func (r *runner) configureHostname() error {
 func (r *runner) configureNetwork() error {
 	r.log.Info("Configuring network...")
 
+	// create the bonds, vlans, and bridges before configuring addresses, so
+	// they're configured along with the physical interfaces
+	r.createNetworkDevices()
+
 	links, err := netlink.LinkList()
 	if err != nil {
 		return fmt.Errorf("failed to list network interfaces: %v", err)
 	}
 
+	var leases []*dhcp.Lease
 	for _, link := range links {
 		linkName := link.Attrs().Name
 		r.log.Debugf("Configuring %s...", linkName)
@@ -348,50 +504,85 @@ func (r *runner) configureNetwork() error {
 		}
 
 		// configure it
-		if err := configureInterface(link, netconf); err != nil {
+		client, lease, err := configureInterface(link, netconf, r.config.Hostname)
+		if err != nil {
 			r.log.Warnf("- %s", err.Error())
+			continue
+		}
+		if lease != nil {
+			r.log.Infof("- acquired DHCP lease for %s on %s", lease.Address, linkName)
+			leases = append(leases, lease)
+			go r.maintainLease(link, client, lease)
 		}
 	}
 
-	// configure the gateway
-	if r.config.NetworkConfig.Gateway != "" {
-		gateway := net.ParseIP(r.config.NetworkConfig.Gateway)
-		if gateway == nil {
-			r.log.Warnf("Failed to configure gatway to %q", r.config.NetworkConfig.Gateway)
+	// the gateway and DNS servers from the DHCP leases are used when none are
+	// configured
+	gatewayAddr := r.config.NetworkConfig.Gateway
+	dns := r.config.NetworkConfig.DNS
+	var search string
+	for _, lease := range leases {
+		if gatewayAddr == "" && lease.Router != nil {
+			gatewayAddr = lease.Router.String()
 		}
+		if len(r.config.NetworkConfig.DNS) == 0 {
+			for _, ns := range lease.DNS {
+				dns = append(dns, ns.String())
+			}
+			if search == "" {
+				search = lease.Domain
+			}
+		}
+	}
 
-		route := &netlink.Route{
-			Scope: netlink.SCOPE_UNIVERSE,
-			Gw:    gateway,
+	// configure the gateways
+	for _, gateway := range []string{gatewayAddr, r.config.NetworkConfig.Gateway6} {
+		if gateway == "" {
+			continue
 		}
-		if err := netlink.RouteAdd(route); err != nil {
-			r.log.Warnf("Failed to configure gateway: %v", err)
-			return nil
+		if err := addDefaultRoute(gateway); err != nil {
+			r.log.Warnf("Failed to configure gateway %q: %v", gateway, err)
+			continue
 		}
-		r.log.Infof("Configured gatway to %s", r.config.NetworkConfig.Gateway)
+		r.log.Infof("Configured gateway to %s", gateway)
 	}
 
 	// configure DNS
-	if len(r.config.NetworkConfig.DNS) > 0 {
-		// write the resolv.conf
-		if err := os.RemoveAll("/etc/resolv.conf"); err != nil {
-			r.log.Errorf("failed to cleanup old resolv.conf: %v", err)
-			return nil
+	r.dnsSearch = search
+	if len(dns) > 0 {
+		if err := r.writeResolvConf(dns, search); err != nil {
+			r.log.Errorf("%v", err)
 		}
-		f, err := os.OpenFile("/etc/resolv.conf", os.O_CREATE, os.FileMode(0644))
-		if err != nil {
-			r.log.Errorf("failed to open /etc/resolv.conf: %v", err)
-			return nil
+	}
+
+	return nil
+}
+
+// writeResolvConf replaces /etc/resolv.conf with one listing the nameservers
+// and search domain.
+func (r *runner) writeResolvConf(dns []string, search string) error {
+	if err := os.RemoveAll("/etc/resolv.conf"); err != nil {
+		return fmt.Errorf("failed to cleanup old resolv.conf: %v", err)
+	}
+	f, err := os.OpenFile("/etc/resolv.conf", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return fmt.Errorf("failed to open /etc/resolv.conf: %v", err)
+	}
+	defer f.Close()
+	if search != "" {
+		if _, err := fmt.Fprintf(f, "search %s\n", search); err != nil {
+			return fmt.Errorf("failed to write to resolv.conf: %v", err)
 		}
-		defer f.Close()
-		for _, ns := range r.config.NetworkConfig.DNS {
-			if _, err := fmt.Fprintf(f, "nameserver %s\n", ns); err != nil {
-				r.log.Errorf("failed to write to resolv.conf: %v", err)
-				return nil
-			}
+	}
+	for _, ns := range dns {
+		if parseZonedIP(ns) == nil {
+			r.log.Warnf("Skipping invalid nameserver %q", ns)
+			continue
+		}
+		if _, err := fmt.Fprintf(f, "nameserver %s\n", ns); err != nil {
+			return fmt.Errorf("failed to write to resolv.conf: %v", err)
 		}
 	}
-
 	return nil
 }
 
@@ -399,7 +590,8 @@ func (r *runner) configureNetwork() error {
 // exist.
 func (r *runner) createDirectories() error {
 	podsPath := filepath.Join(kurmaPath, string(kurmaPathPods))
-	volumesPath := filepath.Join(kurmaPath, string(kurmaPathVolumes))
+	volumesPath := r.config.Paths.Volumes
+	imagesPath := r.config.Paths.Images
 
 	if err := os.MkdirAll(podsPath, os.FileMode(0755)); err != nil {
 		return fmt.Errorf("failed to create pods directory: %v", err)
@@ -407,6 +599,15 @@ func (r *runner) createDirectories() error {
 	if err := os.MkdirAll(volumesPath, os.FileMode(0755)); err != nil {
 		return fmt.Errorf("failed to create volumes directory: %v", err)
 	}
+	if err := os.MkdirAll(imagesPath, os.FileMode(0755)); err != nil {
+		return fmt.Errorf("failed to create images directory: %v", err)
+	}
+	if err := os.MkdirAll(r.config.Paths.Downloads, os.FileMode(0755)); err != nil {
+		return fmt.Errorf("failed to create downloads directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(kurmaPath, string(kurmaPathSecrets)), os.FileMode(0700)); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %v", err)
+	}
 	return nil
 }
 
@@ -444,24 +645,291 @@ func (r *runner) displayNetwork() error {
 // launchManager creates the container manager to allow containers to be
 // launched.
 func (r *runner) launchManager() error {
+	n := r.setupContainerNetwork()
+	ml := r.listenMetadata(n)
+	dl := r.listenDNS(n)
 	mopts := &container.Options{
 		ParentCgroupName:   r.config.ParentCgroupName,
 		ContainerDirectory: filepath.Join(kurmaPath, string(kurmaPathPods)),
-		VolumeDirectory:    filepath.Join(kurmaPath, string(kurmaPathVolumes)),
+		VolumeDirectory:    r.config.Paths.Volumes,
 		RequiredNamespaces: r.config.RequiredNamespaces,
+		Network:            n,
+		Storage:            r.storageDriver(),
+		RuntimeBundles:     r.config.Storage.RuntimeBundles,
+		Secrets:            r.secretStore(),
+		ReservedCPUs:       r.config.CPUs.Reserved,
+		Upgraded:           upgrade.Upgraded(),
+		Logging: container.LogConfig{
+			Driver:   r.config.Logging.ContainerLogs.Driver,
+			MaxSize:  int64(r.config.Logging.ContainerLogs.MaxSizeMB) << 20,
+			MaxFiles: r.config.Logging.ContainerLogs.MaxFiles,
+			Options:  r.config.Logging.ContainerLogs.Options,
+		},
+	}
+	if res := r.config.Resources; res.Enabled == nil || *res.Enabled {
+		mopts.OvercommitRatio = res.OvercommitRatio
+		if mopts.OvercommitRatio <= 0 {
+			mopts.OvercommitRatio = defaultOvercommitRatio
+		}
+		mopts.SystemReserved.CPU = int64(res.SystemCPUMillis)
+		if mopts.SystemReserved.CPU <= 0 {
+			mopts.SystemReserved.CPU = defaultSystemCPUMillis
+		}
+		mopts.SystemReserved.Memory = int64(res.SystemMemoryMB) << 20
+		if mopts.SystemReserved.Memory <= 0 {
+			mopts.SystemReserved.Memory = defaultSystemMemoryMB << 20
+		}
 	}
+	if ev := r.config.Eviction; ev.Enabled == nil || *ev.Enabled {
+		mopts.Eviction = &container.EvictionOptions{
+			Pressure:     ev.PressurePercent,
+			MinAvailable: int64(ev.MinAvailableMB) << 20,
+			Interval:     time.Duration(ev.IntervalSeconds) * time.Second,
+		}
+		if mopts.Eviction.Pressure <= 0 {
+			mopts.Eviction.Pressure = defaultEvictionPressure
+		}
+		if mopts.Eviction.MinAvailable <= 0 {
+			mopts.Eviction.MinAvailable = defaultEvictionMinAvailableMB << 20
+		}
+		if mopts.Eviction.Interval <= 0 {
+			mopts.Eviction.Interval = defaultEvictionIntervalSecs * time.Second
+		}
+	}
+	if un := r.config.UserNamespaces; un.Enabled != nil && *un.Enabled {
+		mopts.UserNamespaces = &container.IDRange{
+			UIDStart:  un.UIDStart,
+			GIDStart:  un.GIDStart,
+			Length:    un.Length,
+			BlockSize: un.BlockSize,
+		}
+	}
+	if v := r.config.VirtualMachines; v.Kernel != "" {
+		mopts.VM = &vm.Config{
+			Kernel: v.Kernel,
+			Qemu:   v.Qemu,
+			Agent:  v.Agent,
+			Memory: v.MemoryMB,
+			CPUs:   v.CPUs,
+		}
+	}
+	if ml != nil {
+		mopts.MetadataURL = "http://" + ml.Addr().String()
+	}
+	if dl != nil {
+		mopts.Nameserver = n.Gateway().String()
+		mopts.NameserverDomain = r.config.Services.DNS.Domain
+		if mopts.NameserverDomain == "" {
+			mopts.NameserverDomain = dns.DefaultDomain
+		}
+	}
+	mopts.SecurityModule, mopts.SecurityLabel = r.securityLabel()
 	m, err := container.NewManager(mopts)
 	if err != nil {
 		return fmt.Errorf("failed to create the container manager: %v", err)
 	}
 	m.Log = r.log.Clone()
 	r.manager = m
+	r.network = n
 	r.log.Trace("Container Manager has been initialized.")
 
+	// reattach to the containers left running if kurma was restarted
+	if recovered, err := m.Recover(); err != nil {
+		r.log.Errorf("Failed to recover existing containers: %v", err)
+	} else if len(recovered) > 0 {
+		r.log.Infof("Recovered %d existing container(s)", len(recovered))
+		for _, c := range recovered {
+			if c.Name() == "console" && c.Privileged() {
+				r.console = c
+			}
+		}
+	}
+
+	if ml != nil {
+		ms, err := metadata.New(m)
+		if err != nil {
+			return fmt.Errorf("failed to create the metadata service: %v", err)
+		}
+		go func() {
+			if err := ms.Serve(ml); err != nil {
+				r.log.Errorf("Metadata service on %s stopped: %v", ml.Addr(), err)
+			}
+		}()
+		r.log.Infof("Serving the metadata service on %s", ml.Addr())
+	}
+
+	if dl != nil {
+		ds := dns.New(mopts.NameserverDomain, dns.ManagerRecords(m),
+			dns.ResolvConfUpstreams("/etc/resolv.conf", n.Gateway()))
+		ds.Log = r.log.Clone()
+		go func() {
+			if err := ds.Serve(dl); err != nil {
+				r.log.Errorf("DNS server on %s stopped: %v", dl.LocalAddr(), err)
+			}
+		}()
+		r.log.Infof("Serving DNS for the %s domain on %s", ds.Domain(), dl.LocalAddr())
+	}
+
 	os.Chdir("/var/kurma")
 	return nil
 }
 
+// storageDriver returns the configured storage driver for containers' root
+// filesystems. If it can't be used, each container's image is extracted
+// separately with the plain driver.
+func (r *runner) storageDriver() storage.Driver {
+	cfg := r.config.Storage
+	driver, err := storage.New(cfg.Driver, &storage.Options{
+		Directory: r.config.Paths.Storage,
+		ThinPool:  cfg.ThinPool,
+		BaseSize:  int64(cfg.BaseSizeGB) << 30,
+	})
+	if err != nil {
+		r.log.Errorf("Failed to set up the %s storage driver, falling back to the plain driver: %v", cfg.Driver, err)
+		return storage.NewPlain(nil, nil)
+	}
+	r.log.Infof("Using the %s storage driver for container filesystems", driver.Name())
+	return driver
+}
+
+// secretStore opens the store of secrets containers may be given, encrypted
+// with the configured key or one generated and kept with the secrets. It
+// returns nil if the key can't be obtained, in which case containers can't be
+// given secrets.
+func (r *runner) secretStore() *secrets.Store {
+	dir := filepath.Join(kurmaPath, string(kurmaPathSecrets))
+	cfg := r.config.Secrets
+
+	var key []byte
+	var err error
+	if cfg.KeySource != "" {
+		key, err = r.sourceKey(cfg.KeySource, cfg.TPMHandle, secretsKeyParam)
+	} else {
+		key, err = secrets.LoadKey(filepath.Join(dir, ".key"))
+	}
+	if err != nil {
+		r.log.Errorf("Failed to get the key to encrypt secrets with, secrets are unavailable: %v", err)
+		return nil
+	}
+	store, err := secrets.NewStore(dir, key)
+	if err != nil {
+		r.log.Errorf("Failed to open the secret store, secrets are unavailable: %v", err)
+		return nil
+	}
+	return store
+}
+
+// securityLabel detects the security module enabled on the host, and returns
+// it along with the configured default label for it.
+func (r *runner) securityLabel() (lsm.Module, string) {
+	module := lsm.Detect()
+	switch module {
+	case lsm.AppArmor:
+		r.log.Infof("AppArmor is enabled, default profile %q", r.config.Security.AppArmorProfile)
+		return module, r.config.Security.AppArmorProfile
+	case lsm.SELinux:
+		r.log.Infof("SELinux is enabled, default label %q", r.config.Security.SELinuxLabel)
+		return module, r.config.Security.SELinuxLabel
+	}
+	return module, ""
+}
+
+// setupContainerNetwork creates the bridge and NAT rules containers are
+// connected through, or in the macvlan and ipvlan modes, prepares to put them
+// on the parent interface's LAN. It returns nil if the container network is disabled or
+// fails to be set up, in which case containers share the host's network.
+func (r *runner) setupContainerNetwork() *network.Network {
+	cn := r.config.NetworkConfig.ContainerNetwork
+	if cn.Enabled != nil && !*cn.Enabled {
+		r.log.Trace("Skipping container network")
+		return nil
+	}
+
+	n, err := network.New(network.Config{
+		Mode:    cn.Mode,
+		Bridge:  cn.Bridge,
+		Subnet:  cn.Subnet,
+		Subnet6: cn.Subnet6,
+		MTU:     cn.MTU,
+		NAT:     cn.NAT == nil || *cn.NAT,
+		Parent:  cn.Parent,
+		Gateway: cn.Gateway,
+		Range:   cn.Range,
+		DHCP:    cn.DHCP != nil && *cn.DHCP,
+	})
+	if err != nil {
+		r.log.Errorf("Failed to set up the container network, containers will use the host network: %v", err)
+		return nil
+	}
+	n.Log = r.log.Clone()
+	n.Log.SetField("service", "network")
+	if !n.Bridged() {
+		if n.Subnet() == nil {
+			r.log.Infof("Container network configured in the %s mode on %s using DHCP", n.Mode(), cn.Parent)
+		} else {
+			r.log.Infof("Container network configured in the %s mode on %s, %s", n.Mode(), cn.Parent, n.Subnet())
+		}
+		return n
+	}
+	r.log.Infof("Container network configured on %s", n.Subnet())
+	if n.Subnet6() != nil {
+		r.log.Infof("Container network configured on %s", n.Subnet6())
+	}
+	return n
+}
+
+// listenMetadata opens the listener for the App Container metadata service, on
+// the container network's gateway address so containers can reach it, or on
+// the loopback address if there is no container network. It returns nil if
+// the service is disabled, if containers are on the LAN, where they can't
+// reach the host, or if the address can't be listened on, in which case
+// containers aren't given a metadata URL.
+func (r *runner) listenMetadata(n *network.Network) net.Listener {
+	cfg := r.config.Services.Metadata
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		r.log.Trace("Skipping metadata service")
+		return nil
+	}
+	if n != nil && !n.Bridged() {
+		r.log.Warnf("Skipping metadata service, containers can't reach the host in the %s mode", n.Mode())
+		return nil
+	}
+
+	ip := net.IPv4(127, 0, 0, 1)
+	if n != nil {
+		ip = n.Gateway()
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = metadata.DefaultPort
+	}
+	l, err := upgrade.Listen("tcp://" + net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	if err != nil {
+		r.log.Errorf("Failed to listen for the metadata service, containers will have no metadata URL: %v", err)
+		return nil
+	}
+	return l
+}
+
+// listenDNS opens the connection for the DNS server on the container network's
+// gateway address. It returns nil if the server is disabled, if there is no
+// bridged container network, or if the address can't be listened on, in which
+// case containers use the host's nameservers.
+func (r *runner) listenDNS(n *network.Network) net.PacketConn {
+	cfg := r.config.Services.DNS
+	if (cfg.Enabled != nil && !*cfg.Enabled) || n == nil || !n.Bridged() {
+		r.log.Trace("Skipping DNS server")
+		return nil
+	}
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(n.Gateway().String(), "53"))
+	if err != nil {
+		r.log.Errorf("Failed to listen for the DNS server, containers will use the host's nameservers: %v", err)
+		return nil
+	}
+	return conn
+}
+
 // startSignalHandling configures the necessary signal handlers for the init
 // process.
 func (r *runner) startSignalHandling() error {
@@ -469,13 +937,60 @@ func (r *runner) startSignalHandling() error {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGCHLD)
 	go r.handleSIGCHLD(ch)
+
+	// configure the signals which shut down the host and reload its
+	// configuration
+	r.startShutdownSignalHandling()
+	r.startReloadSignalHandling()
 	return nil
 }
 
 // startServer begins the main Kurma RPC server and will take over execution.
 func (r *runner) startServer() error {
 	opts := &server.Options{
-		ContainerManager: r.manager,
+		ContainerManager:      r.manager,
+		Listeners:             r.config.Services.API.Listeners,
+		MetricsListener:       r.config.Services.Metrics.Listener,
+		GatewayListener:       r.config.Services.Gateway.Listener,
+		CRIListener:           r.config.Services.CRI.Listener,
+		CRIFile:               filepath.Join(kurmaPath, "cri.json"),
+		ShutdownHandler:       r.shutdown,
+		ImageStore:            r.images,
+		UploadDirectory:       r.config.Paths.Images,
+		GarbageCollector:      r.collector,
+		HostStatusHandler:     r.units.status,
+		HostInfoHandler:       r.hostInfo,
+		HostStorageHandler:    r.hostStorage,
+		HostEncryptionHandler: r.hostEncryption,
+		CrashesHandler:        r.listCrashes,
+		CrashHandler:          r.getCrash,
+		ReloadConfigHandler:   r.reloadConfig,
+		ConsoleHandler:        r.consoleSession,
+		UpdateStatusHandler:   r.updateStatus,
+		UpdateHandler:         r.updateSystemImage,
+		AttestHandler:         r.attestHost,
+		NodesHandler:          r.clusterNodes,
+		JobsFile:              filepath.Join(kurmaPath, "jobs.json"),
+		NetworkPolicies:       r.policies,
+		UpgradeDirectory:      filepath.Join(kurmaPath, "upgrades"),
+	}
+	if api := r.config.Services.API; api.TLSCert != "" {
+		opts.TLS = &server.TLSOptions{
+			CertFile: api.TLSCert,
+			KeyFile:  api.TLSKey,
+			CAFile:   api.TLSCACert,
+		}
+	}
+	auth, err := r.apiAuth()
+	if err != nil {
+		return err
+	}
+	opts.Auth = auth
+	if rl := r.config.Services.API.RateLimit; rl != nil && rl.RequestsPerSecond > 0 {
+		opts.RateLimit = &server.RateLimitOptions{
+			Rate:  rl.RequestsPerSecond,
+			Burst: rl.Burst,
+		}
 	}
 
 	s := server.New(opts)
@@ -483,39 +998,31 @@ func (r *runner) startServer() error {
 	return nil
 }
 
-// startInitContainers launches the initial containers that are specified in the
-// configuration.
-func (r *runner) startInitContainers() error {
-	for _, img := range r.config.InitContainers {
-		func() {
-			f, err := aciremote.RetrieveImage(img, true)
-			if err != nil {
-				r.log.Errorf("Failed to retrieve image %q: %v", img, err)
-				return
-			}
-			defer f.Close()
-
-			manifest, err := findManifest(f)
-			if err != nil {
-				r.log.Errorf("Failed to find manifest in image %q: %v", img, err)
-				return
-			}
-
-			if _, err := f.Seek(0, 0); err != nil {
-				r.log.Errorf("Failed to set up %q: %v", img, err)
-				return
-			}
+// configureImageKeystore sets the directory of trusted keys which are used to
+// verify the signatures of images that are retrieved.
+func (r *runner) configureImageKeystore() error {
+	remote.KeystoreDirectory = r.config.ImageKeystore
+	return nil
+}
 
-			if _, err := r.manager.Create("", manifest, f); err != nil {
-				r.log.Warnf("Failed to launch container %s: %v", manifest.Name.String(), err)
-				return
-			}
-			r.log.Infof("Launched container %s", manifest.Name.String())
-		}()
+// configureRegistryCredentials sets the credentials used to retrieve images
+// from private registries.
+func (r *runner) configureRegistryCredentials() error {
+	remote.SetCredentials(r.config.Registries)
+	for host, creds := range r.config.Registries {
+		r.log.Debugf("Using %s for images from %s", creds, host)
 	}
 	return nil
 }
 
+// configureImageDownloads sets the directory remote images are downloaded to,
+// which can be placed on a disk rather than held in memory. Images retrieved
+// before it is configured, such as udev's, use the temporary directory.
+func (r *runner) configureImageDownloads() error {
+	remote.DownloadDirectory = r.config.Paths.Downloads
+	return nil
+}
+
 // startUdev handles launching the udev service.
 func (r *runner) startUdev() error {
 	if r.config.Services.Udev.Enabled == nil || !*r.config.Services.Udev.Enabled {
@@ -523,14 +1030,14 @@ func (r *runner) startUdev() error {
 		return nil
 	}
 
-	f, err := aciremote.RetrieveImage(r.config.Services.Udev.ACI, true)
+	f, err := r.retrieveImage(r.config.Services.Udev.ACI, r.config.Services.Udev.Insecure)
 	if err != nil {
 		r.log.Errorf("Failed to retrieve udev image: %v", err)
 		return nil
 	}
 	defer f.Close()
 
-	manifest, err := findManifest(f)
+	manifest, err := remote.FindManifest(f)
 	if err != nil {
 		r.log.Errorf("Failed to find manifest in udev image: %v", err)
 		return nil
@@ -541,7 +1048,7 @@ func (r *runner) startUdev() error {
 		return nil
 	}
 
-	container, err := r.manager.Create("udev", manifest, f)
+	container, err := r.manager.Create("udev", manifest, f, nil)
 	if err != nil {
 		r.log.Warnf("Failed to launch udev: %v", err)
 		return nil
@@ -558,23 +1065,25 @@ func (r *runner) startUdev() error {
 	return nil
 }
 
-// startConsole handles launching the udev service.
+// startNTP handles launching the NTP service, when an image is configured to set
+// the clock rather than the built-in client.
 func (r *runner) startNTP() error {
-	if r.config.Services.NTP.Enabled == nil || !*r.config.Services.NTP.Enabled {
-		r.log.Trace("Skipping NTP")
+	ntp := r.config.Services.NTP
+	if ntp.Enabled == nil || !*ntp.Enabled || ntp.ACI == "" {
+		r.log.Trace("Skipping NTP container")
 		return nil
 	}
 
 	r.log.Info("Updating system clock via NTP...")
 
-	f, err := aciremote.RetrieveImage(r.config.Services.NTP.ACI, true)
+	f, err := r.retrieveImage(ntp.ACI, ntp.Insecure)
 	if err != nil {
 		r.log.Errorf("Failed to retrieve NTP image: %v", err)
 		return nil
 	}
 	defer f.Close()
 
-	manifest, err := findManifest(f)
+	manifest, err := remote.FindManifest(f)
 	if err != nil {
 		r.log.Errorf("Failed to find manifest in console image: %v", err)
 		return nil
@@ -589,7 +1098,7 @@ func (r *runner) startNTP() error {
 	manifest.App.Environment.Set(
 		"NTP_SERVERS", strings.Join(r.config.Services.NTP.Servers, " "))
 
-	if _, err := r.manager.Create("ntp", manifest, f); err != nil {
+	if _, err := r.manager.Create("ntp", manifest, f, nil); err != nil {
 		r.log.Warnf("Failed to start NTP: %v", err)
 		return nil
 	}
@@ -597,70 +1106,32 @@ func (r *runner) startNTP() error {
 	return nil
 }
 
-// startConsole handles launching the udev service.
-func (r *runner) startConsole() error {
-	if r.config.Services.Console.Enabled == nil || !*r.config.Services.Console.Enabled {
-		r.log.Trace("Skipping console")
-		return nil
+// configureImageTransport sets the proxies and the additional trusted CA
+// certificates used by image discovery and retrieval.
+func (r *runner) configureImageTransport() error {
+	cfg := r.config.NetworkConfig
+	proxy := remote.ProxyConfig{
+		HTTPProxy:  cfg.HTTPProxy,
+		HTTPSProxy: cfg.HTTPSProxy,
+		NoProxy:    cfg.NoProxy,
 	}
-
-	f, err := aciremote.RetrieveImage(r.config.Services.Console.ACI, true)
-	if err != nil {
-		r.log.Errorf("Failed to retrieve console image: %v", err)
-		return nil
-	}
-	defer f.Close()
-
-	manifest, err := findManifest(f)
-	if err != nil {
-		r.log.Errorf("Failed to find manifest in console image: %v", err)
-		return nil
-	}
-
-	if _, err := f.Seek(0, 0); err != nil {
-		r.log.Errorf("Failed to set up console image: %v", err)
-		return nil
-	}
-
-	// send in the configuration information
-	if r.config.Services.Console.Password != nil {
-		manifest.App.Environment.Set(
-			"CONSOLE_PASSWORD", *r.config.Services.Console.Password)
-	}
-	manifest.App.Environment.Set(
-		"CONSOLE_KEYS", strings.Join(r.config.Services.Console.SSHKeys, "\n"))
-
-	if _, err := r.manager.Create("console", manifest, f); err != nil {
-		return fmt.Errorf("Failed to start console: %v", err)
+	if proxy.HTTPProxy == "" {
+		proxy.HTTPProxy = cfg.ProxyURL
 	}
-	r.log.Debug("Started console")
-	return nil
-}
-
-func (r *runner) setupDiscoveryProxy() error {
-	uri, err := url.Parse(r.config.NetworkConfig.ProxyURL)
-	if err != nil {
-		r.log.Warnf("Failed to parse proxy url: %v", err)
-		return nil
+	if proxy.HTTPSProxy == "" {
+		proxy.HTTPSProxy = cfg.ProxyURL
 	}
 
-	// discovery requests
-	transport, ok := discovery.Client.Transport.(*http.Transport)
-	if !ok {
-		r.log.Warnf("Failed to configure discovery proxy, transport was not the expected type: %T",
-			discovery.Client.Transport)
-		return nil
-	}
-	transport.Proxy = http.ProxyURL(uri)
-
-	// actual download requests
-	transport, ok = aciremote.Client.Transport.(*http.Transport)
-	if !ok {
-		r.log.Warnf("Failed to configure remote download proxy, transport was not the expected type: %T",
-			aciremote.Client.Transport)
-		return nil
+	for name, client := range map[string]*http.Client{"discovery": discovery.Client, "image": remote.Client} {
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			r.log.Warnf("Failed to configure the %s transport, it was not the expected type: %T",
+				name, client.Transport)
+			continue
+		}
+		if err := remote.ConfigureTransport(transport, proxy, cfg.CADirectory); err != nil {
+			r.log.Warnf("Failed to configure the %s transport: %v", name, err)
+		}
 	}
-	transport.Proxy = http.ProxyURL(uri)
-
 	return nil
 }
@@ -0,0 +1,112 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// cgroup2SuperMagic is the filesystem type statfs reports for the unified
+// hierarchy.
+const cgroup2SuperMagic = 0x63677270
+
+// cgroupVersion returns the configured version of cgroups, 1 or 2, or 0 if the
+// version is to be detected.
+func (r *runner) cgroupVersion() int {
+	if r.config == nil {
+		return 0
+	}
+	return r.config.CgroupVersion
+}
+
+// useUnifiedCgroups returns whether the host's cgroups are to be on the
+// unified hierarchy of cgroup v2. Unless a version is configured, it is used
+// when the kernel was booted with the v1 controllers disabled through
+// cgroup_no_v1, or when the unified hierarchy is already mounted.
+func (r *runner) useUnifiedCgroups() (bool, error) {
+	switch version := r.cgroupVersion(); version {
+	case 0:
+	case 1:
+		return false, nil
+	case 2:
+		if !cgroup2Supported() {
+			return false, fmt.Errorf("cgroup v2 is configured, but the kernel doesn't support it")
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("cgroup version %d is not supported", version)
+	}
+	if unifiedMounted() {
+		return true, nil
+	}
+	if !cgroup2Supported() {
+		return false, nil
+	}
+	b, err := ioutil.ReadFile("/proc/cmdline")
+	if err != nil {
+		return false, nil
+	}
+	for _, field := range strings.Fields(string(b)) {
+		if strings.HasPrefix(field, "cgroup_no_v1=") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cgroup2Supported returns whether the kernel supports the unified hierarchy,
+// from /proc/filesystems.
+func cgroup2Supported() bool {
+	b, err := ioutil.ReadFile("/proc/filesystems")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == "cgroup2" {
+			return true
+		}
+	}
+	return false
+}
+
+// unifiedMounted returns whether the unified hierarchy is already mounted
+// where the cgroups are, such as when kurma is launched by kurma.
+func unifiedMounted() bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(cgroupsMount, &st); err != nil {
+		return false
+	}
+	return st.Type == cgroup2SuperMagic
+}
+
+// mountUnifiedCgroups mounts the unified hierarchy where the cgroups are, over
+// the tmpfs the hierarchies of cgroup v1 would be mounted in. The controllers
+// are enabled as the manager creates its cgroups.
+func (r *runner) mountUnifiedCgroups() error {
+	r.log.Info("Setting up cgroups on the unified hierarchy")
+	if unifiedMounted() {
+		r.log.Tracef("- skipping %q, already mounted", cgroupsMount)
+		return nil
+	}
+	if err := handleMount("none", cgroupsMount, "cgroup2", 0, ""); err != nil {
+		return fmt.Errorf("failed to mount the unified cgroup hierarchy: %v", err)
+	}
+	return nil
+}
+
+// unmountCgroups unmounts the cgroup v1 hierarchies of the types, and removes
+// the directories they were mounted on, before falling back to the unified
+// hierarchy.
+func unmountCgroups(types []string) {
+	for _, cgrouptype := range types {
+		location := filepath.Join(cgroupsMount, cgrouptype)
+		syscall.Unmount(location, 0)
+		os.Remove(location)
+	}
+}
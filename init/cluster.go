@@ -0,0 +1,38 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"os"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// clusterNodes returns this host, followed by the peers it was configured
+// with, so that clients connected to it can find the rest of the cluster.
+// Peers missing a name or endpoint are left out.
+func (r *runner) clusterNodes() []*pb.Node {
+	r.reloadMutex.Lock()
+	cluster := r.config.Cluster
+	r.reloadMutex.Unlock()
+
+	name := nodeName(cluster)
+	nodes := []*pb.Node{{Name: name, Self: true}}
+	for _, p := range cluster.Peers {
+		if p.Name == "" || p.Endpoint == "" || p.Name == name {
+			continue
+		}
+		nodes = append(nodes, &pb.Node{Name: p.Name, Endpoint: p.Endpoint})
+	}
+	return nodes
+}
+
+// nodeName returns the name this host goes by in the cluster, which is the
+// hostname unless it is given one.
+func nodeName(cluster kurmaCluster) string {
+	if cluster.Name != "" {
+		return cluster.Name
+	}
+	name, _ := os.Hostname()
+	return name
+}
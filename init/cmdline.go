@@ -0,0 +1,287 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// kernelParamPrefix is the prefix of the kernel command line parameters which
+// override the configuration.
+const kernelParamPrefix = "kurma."
+
+// kernelParam is a kurma.* parameter from the kernel command line, with the
+// prefix removed from its name. Parameters given without a value, such as
+// kurma.debug, have an empty value.
+type kernelParam struct {
+	name  string
+	value string
+}
+
+// kernelParams returns the kurma.* parameters on the kernel command line, in
+// the order they were given.
+func kernelParams() []kernelParam {
+	b, err := ioutil.ReadFile("/proc/cmdline")
+	if err != nil {
+		return nil
+	}
+	return parseKernelParams(string(b))
+}
+
+// parseKernelParams parses the kurma.* parameters from the kernel command line.
+// Values may be quoted to include spaces, such as kurma.hostname="a b".
+func parseKernelParams(cmdline string) []kernelParam {
+	var params []kernelParam
+	var field []rune
+	quoted := false
+	add := func() {
+		s := string(field)
+		field = field[:0]
+		if !strings.HasPrefix(s, kernelParamPrefix) {
+			return
+		}
+		s = strings.TrimPrefix(s, kernelParamPrefix)
+		p := kernelParam{name: s}
+		if i := strings.Index(s, "="); i >= 0 {
+			p.name, p.value = s[:i], s[i+1:]
+		}
+		params = append(params, p)
+	}
+	for _, c := range cmdline {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t' || c == '\n'):
+			if len(field) > 0 {
+				add()
+			}
+		default:
+			field = append(field, c)
+		}
+	}
+	if len(field) > 0 {
+		add()
+	}
+	return params
+}
+
+// applyKernelParams overrides the configuration with the kernel command line
+// parameters, so hosts which are network booted can be configured without
+// rebuilding their image. The parameters are:
+//
+//	kurma.hostname=NAME           sets the hostname
+//	kurma.ip=DEVICE,dhcp          configures the interface with DHCP
+//	kurma.ip=DEVICE,ADDR/PREFIX[,GATEWAY]
+//	                              configures the interface with the address,
+//	                              and the default gateway if one is given
+//	kurma.gateway=ADDR            sets the default gateway
+//	kurma.dns=ADDR[,ADDR...]      sets the nameservers
+//	kurma.modules=MOD[,MOD...]    loads the kernel modules
+//	kurma.debug[=1]               enables debug logging, or disables it if 0
+//	kurma.emergency[=1]           only sets up enough of the host to debug it,
+//	                              and then enters emergency mode
+//	kurma.config_url=SOURCE       fetches the configuration from the
+//	                              datasource, such as an https URL or ec2,
+//	                              after those which are configured
+//	kurma.luks_key.NAME=KEY       the key of the encrypted device NAME, which
+//	                              is read when it is unlocked rather than
+//	                              being added to the configuration
+//	kurma.partition=NAME          the partition the system image was booted
+//	                              from, which the bootloader passes when the
+//	                              host is updated through A/B partitions
+//	kurma.cgroup_version=1|2      mounts the cgroup v1 hierarchies or the
+//	                              unified hierarchy of cgroup v2
+//
+// The kurma.ip parameter may be given for multiple interfaces, and takes
+// precedence over the configured interfaces matching the same device. An error
+// is returned for each parameter which isn't recognized or is invalid, and it
+// is ignored.
+func (cfg *kurmaConfig) applyKernelParams(params []kernelParam) []error {
+	var errs []error
+	invalid := func(p kernelParam, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s%s=%s: %s",
+			kernelParamPrefix, p.name, p.value, fmt.Sprintf(format, args...)))
+	}
+
+	for _, p := range params {
+		if strings.HasPrefix(p.name, luksKeyParamPrefix) {
+			continue
+		}
+		switch p.name {
+		case "hostname":
+			if p.value == "" {
+				invalid(p, "a hostname must be given")
+				continue
+			}
+			cfg.Hostname = p.value
+
+		case "ip":
+			netconf, gateway, err := parseKernelIP(p.value)
+			if err != nil {
+				invalid(p, "%v", err)
+				continue
+			}
+			cfg.NetworkConfig.Interfaces = append(
+				[]*kurmaNetworkInterface{netconf}, cfg.NetworkConfig.Interfaces...)
+			if gateway != nil {
+				cfg.setGateway(gateway)
+			}
+
+		case "gateway":
+			gateway := net.ParseIP(p.value)
+			if gateway == nil {
+				invalid(p, "the gateway must be an IP address")
+				continue
+			}
+			cfg.setGateway(gateway)
+
+		case "dns":
+			servers := splitList(p.value)
+			if len(servers) == 0 {
+				invalid(p, "a nameserver must be given")
+				continue
+			}
+			cfg.NetworkConfig.DNS = servers
+
+		case "modules":
+			cfg.Modules = append(cfg.Modules, splitList(p.value)...)
+
+		case "debug":
+			enabled, err := parseFlagParam(p.value)
+			if err != nil {
+				invalid(p, "%v", err)
+				continue
+			}
+			cfg.Debug = enabled
+
+		case "emergency":
+			// it is acted on before the configuration is loaded, so it
+			// is only checked here
+			if _, err := parseFlagParam(p.value); err != nil {
+				invalid(p, "%v", err)
+			}
+
+		case "partition":
+			// it is read when the system image is checked, so it is only
+			// checked here
+			if p.value == "" {
+				invalid(p, "a partition name must be given")
+			}
+
+		case "cgroup_version":
+			switch p.value {
+			case "1":
+				cfg.CgroupVersion = 1
+			case "2":
+				cfg.CgroupVersion = 2
+			default:
+				invalid(p, "the version must be 1 or 2")
+			}
+
+		case "config_url":
+			if p.value == "" {
+				invalid(p, "a datasource must be given")
+				continue
+			}
+			cfg.Datasources = append(cfg.Datasources, p.value)
+
+		default:
+			invalid(p, "unrecognized parameter")
+		}
+	}
+	return errs
+}
+
+// parseFlagParam parses the value of a parameter which is either enabled or
+// disabled, and is enabled when it is given without a value.
+func parseFlagParam(value string) (bool, error) {
+	switch value {
+	case "", "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("the value must be 1 or 0")
+	}
+}
+
+// emergencyRequested returns whether emergency mode was requested with the
+// kurma.emergency parameter. The last one given wins.
+func emergencyRequested(params []kernelParam) bool {
+	requested := false
+	for _, p := range params {
+		if p.name == "emergency" {
+			if enabled, err := parseFlagParam(p.value); err == nil {
+				requested = enabled
+			}
+		}
+	}
+	return requested
+}
+
+// parseKernelIP parses the interface configuration from a kurma.ip parameter,
+// along with its gateway if one is given.
+func parseKernelIP(value string) (*kurmaNetworkInterface, net.IP, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return nil, nil, fmt.Errorf("the value must be DEVICE,dhcp or DEVICE,ADDR/PREFIX[,GATEWAY]")
+	}
+
+	netconf := &kurmaNetworkInterface{Device: parts[0]}
+	if parts[1] == "dhcp" {
+		if len(parts) > 2 {
+			return nil, nil, fmt.Errorf("a gateway can't be given with dhcp")
+		}
+		netconf.DHCP = true
+		return netconf, nil, nil
+	}
+
+	if _, _, err := net.ParseCIDR(parts[1]); err != nil {
+		return nil, nil, fmt.Errorf("the address must include its prefix length")
+	}
+	netconf.Address = parts[1]
+	if len(parts) < 3 {
+		return netconf, nil, nil
+	}
+	gateway := net.ParseIP(parts[2])
+	if gateway == nil {
+		return nil, nil, fmt.Errorf("the gateway must be an IP address")
+	}
+	return netconf, gateway, nil
+}
+
+// setGateway sets the default gateway for the gateway's address family.
+func (cfg *kurmaConfig) setGateway(gateway net.IP) {
+	if gateway.To4() != nil {
+		cfg.NetworkConfig.Gateway = gateway.String()
+	} else {
+		cfg.NetworkConfig.Gateway6 = gateway.String()
+	}
+}
+
+// splitList splits a comma separated list, leaving out empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, s := range strings.Split(value, ",") {
+		if s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// bootedPartition returns the name of the partition the system image was
+// booted from, given with the kurma.partition parameter, or an empty string if
+// it wasn't given. The last one given wins.
+func bootedPartition(params []kernelParam) string {
+	name := ""
+	for _, p := range params {
+		if p.name == "partition" && p.value != "" {
+			name = p.value
+		}
+	}
+	return name
+}
@@ -2,18 +2,78 @@
 
 package init
 
+import (
+	"encoding/json"
+
+	"github.com/apcera/kurma/util/remote"
+)
+
 type kurmaConfig struct {
 	Debug              bool                      `json:"debug,omitempty"`
+	Logging            kurmaLogging              `json:"logging,omitempty"`
 	OEMConfig          *OEMConfig                `json:"oem_config"`
 	Datasources        []string                  `json:"datasources,omitempty"`
 	Hostname           string                    `json:"hostname,omitempty"`
 	NetworkConfig      kurmaNetworkConfig        `json:"network_config,omitempty"`
 	Modules            []string                  `json:"modules,omitmepty"`
+	Sysctls            map[string]string         `json:"sysctls,omitempty"`
 	Disks              []*kurmaDiskConfiguration `json:"disks,omitempty"`
+	Swap               []*kurmaSwap              `json:"swap,omitempty"`
+	RaidArrays         []*kurmaRaidArray         `json:"raid_arrays,omitempty"`
+	VolumeGroups       []*kurmaVolumeGroup       `json:"volume_groups,omitempty"`
+	EncryptedDevices   []*kurmaEncryptedDevice   `json:"encrypted_devices,omitempty"`
+	Crash              kurmaCrash                `json:"crash,omitempty"`
 	ParentCgroupName   string                    `json:"parent_cgroup_name,omitempty"`
+	CgroupVersion      int                       `json:"cgroup_version,omitempty"`
 	RequiredNamespaces []string                  `json:"required_namespaces,omitempty"`
 	Services           kurmaServices             `json:"services,omitempty"`
-	InitContainers     []string                  `json:"init_containers,omitempty"`
+	InitContainers     []kurmaInitContainer      `json:"init_containers,omitempty"`
+	ImageKeystore      string                    `json:"image_keystore,omitempty"`
+	Registries         kurmaRegistries           `json:"registries,omitempty"`
+	PreloadImages      kurmaPreloadImages        `json:"preload_images,omitempty"`
+	Paths              kurmaPaths                `json:"paths,omitempty"`
+	GarbageCollection  kurmaGarbageCollection    `json:"gc,omitempty"`
+	UserNamespaces     kurmaUserNamespaces       `json:"user_namespaces,omitempty"`
+	CPUs               kurmaCPUs                 `json:"cpus,omitempty"`
+	Hugepages          kurmaHugepages            `json:"hugepages,omitempty"`
+	Resources          kurmaResources            `json:"resources,omitempty"`
+	Eviction           kurmaEviction             `json:"eviction,omitempty"`
+	Security           kurmaSecurity             `json:"security,omitempty"`
+	Firewall           kurmaFirewall             `json:"firewall,omitempty"`
+	Emergency          kurmaEmergency            `json:"emergency,omitempty"`
+	Secrets            kurmaSecrets              `json:"secrets,omitempty"`
+	Storage            kurmaStorage              `json:"storage,omitempty"`
+	VirtualMachines    kurmaVirtualMachines      `json:"virtual_machines,omitempty"`
+	Cluster            kurmaCluster              `json:"cluster,omitempty"`
+	Updates            kurmaUpdates              `json:"updates,omitempty"`
+	Attestation        kurmaAttestation          `json:"attestation,omitempty"`
+}
+
+// kurmaLogging configures the host's logs. The Level, such as "debug+", is the
+// least severe level which is logged, and defaults to "info+", or to everything
+// when Debug is set. The console's Format is "text" or "json". The logs are
+// also sent to each of the Sinks, which are URLs such as a rotated file, a
+// remote syslog server, journald, or an HTTP endpoint, and may give their own
+// level with a level parameter. When ContainerOutput is set, the containers'
+// output captured by the json-file log driver is sent to the sinks as well.
+type kurmaLogging struct {
+	Level           string             `json:"level,omitempty"`
+	Format          string             `json:"format,omitempty"`
+	Sinks           []string           `json:"sinks,omitempty"`
+	ContainerOutput *bool              `json:"container_output,omitempty"`
+	ContainerLogs   kurmaContainerLogs `json:"container_logs,omitempty"`
+}
+
+// kurmaContainerLogs configures the log driver which captures the containers'
+// output, for whatever a container doesn't set itself. The Driver is json-file,
+// the default, syslog, or none. The json-file driver keeps up to MaxFiles files
+// of MaxSizeMB each within the container's directory, while the syslog driver
+// is given the server's address and its other settings as Options.
+type kurmaContainerLogs struct {
+	Driver    string            `json:"driver,omitempty"`
+	MaxSizeMB int               `json:"max_size_mb,omitempty"`
+	MaxFiles  int               `json:"max_files,omitempty"`
+	Options   map[string]string `json:"options,omitempty"`
 }
 
 type OEMConfig struct {
@@ -21,62 +81,608 @@ type OEMConfig struct {
 	ConfigPath string `json:"config_path"`
 }
 
+// kurmaNetworkConfig configures the host's network. Images are discovered and
+// retrieved through the HTTPProxy and HTTPSProxy, which both default to the
+// ProxyURL, except for the hosts in NoProxy, a comma separated list as in the
+// NO_PROXY environment variable. The PEM certificates in the CADirectory are
+// trusted for those requests as well as the system's.
 type kurmaNetworkConfig struct {
-	DNS        []string                 `json:"dns,omitempty"`
-	Gateway    string                   `json:"gateway,omitempty"`
-	Interfaces []*kurmaNetworkInterface `json:"interfaces,omitempty"`
-	ProxyURL   string                   `json:"proxy_url,omitempty"`
+	DNS         []string                 `json:"dns,omitempty"`
+	Gateway     string                   `json:"gateway,omitempty"`
+	Gateway6    string                   `json:"gateway6,omitempty"`
+	Interfaces  []*kurmaNetworkInterface `json:"interfaces,omitempty"`
+	ProxyURL    string                   `json:"proxy_url,omitempty"`
+	HTTPProxy   string                   `json:"http_proxy,omitempty"`
+	HTTPSProxy  string                   `json:"https_proxy,omitempty"`
+	NoProxy     string                   `json:"no_proxy,omitempty"`
+	CADirectory string                   `json:"ca_directory,omitempty"`
+
+	ContainerNetwork kurmaContainerNetwork `json:"container_network,omitempty"`
 }
 
+// kurmaContainerNetwork configures the network containers are isolated on.
+// Each container is given its own network namespace, connected to the bridge
+// and with an address from the subnet, unless it is explicitly disabled or the
+// container is host privileged. Traffic from the containers is masqueraded
+// behind the host's addresses unless NAT is explicitly disabled. Containers are
+// only given IPv6 addresses if Subnet6 is set.
+//
+// With a Mode of "macvlan" or "ipvlan", containers are instead given a
+// sub-interface of the Parent interface, putting them directly on its LAN. They
+// are given addresses from the LAN's Subnet, limited to Range if it is set, and
+// route through Gateway, or if DHCP is set, they acquire their addresses from
+// the LAN's DHCP server, which is only supported with macvlan.
+type kurmaContainerNetwork struct {
+	Enabled *bool  `json:"enabled,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+	Bridge  string `json:"bridge,omitempty"`
+	Subnet  string `json:"subnet,omitempty"`
+	Subnet6 string `json:"subnet6,omitempty"`
+	MTU     int    `json:"mtu,omitempty"`
+	NAT     *bool  `json:"nat,omitempty"`
+	Parent  string `json:"parent,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+	Range   string `json:"range,omitempty"`
+	DHCP    *bool  `json:"dhcp,omitempty"`
+}
+
+// kurmaNetworkInterface configures the interfaces matching Device. IPv6 may be
+// set to "slaac" or "dhcpv6" to configure the interface's IPv6 address from
+// router advertisements or a DHCPv6 server, respectively.
+//
+// If Type is set, the device named Device is created at boot. A "bond" bonds
+// the Slaves together using BondMode, a "vlan" is the 802.1q sub-interface of
+// Parent for VlanID, and a "bridge" has the Slaves attached as its ports.
 type kurmaNetworkInterface struct {
 	Device    string   `json:"device"`
 	DHCP      bool     `json:"dhcp,omitmepty"`
+	IPv6      string   `json:"ipv6,omitempty"`
 	Address   string   `json:"address,omitempty"`
 	Addresses []string `json:"addresses,omitempty"`
 	MTU       int      `json:"mtu,omitmepty"`
+
+	Type       string   `json:"type,omitempty"`
+	Slaves     []string `json:"slaves,omitempty"`
+	BondMode   string   `json:"bond_mode,omitempty"`
+	BondMiimon int      `json:"bond_miimon,omitempty"`
+	Parent     string   `json:"parent,omitempty"`
+	VlanID     int      `json:"vlan_id,omitempty"`
+}
+
+// kurmaPaths contains the host directories used to store Kurma's data.
+type kurmaPaths struct {
+	Volumes   string `json:"volumes,omitempty"`
+	Images    string `json:"images,omitempty"`
+	Downloads string `json:"downloads,omitempty"`
+	Storage   string `json:"storage,omitempty"`
+}
+
+// kurmaGarbageCollection configures the cleanup of exited containers, which
+// are destroyed once they have been exited for ExitedRetentionHours, and of
+// the image cache, which is limited to ImageCacheGB with the least recently
+// used images removed first. The cleanup runs every IntervalMinutes, and can be
+// disabled by setting Enabled to false.
+type kurmaGarbageCollection struct {
+	Enabled              *bool `json:"enabled,omitempty"`
+	IntervalMinutes      int   `json:"interval_minutes,omitempty"`
+	ExitedRetentionHours *int  `json:"exited_retention_hours,omitempty"`
+	ImageCacheGB         int   `json:"image_cache_gb,omitempty"`
+}
+
+// kurmaUserNamespaces configures running containers in user namespaces, which
+// is disabled unless Enabled is set. Each container is mapped to its own block
+// of BlockSize host uids and gids, taken from the Length IDs starting at
+// UIDStart and GIDStart.
+type kurmaUserNamespaces struct {
+	Enabled   *bool `json:"enabled,omitempty"`
+	UIDStart  int   `json:"uid_start,omitempty"`
+	GIDStart  int   `json:"gid_start,omitempty"`
+	Length    int   `json:"length,omitempty"`
+	BlockSize int   `json:"block_size,omitempty"`
+}
+
+// kurmaCPUs configures how the host's CPUs are given to containers. The
+// Reserved CPUs, a list such as "0-1" which defaults to CPU 0, are kept for
+// kurma and the containers sharing CPUs, and never given to a container for its
+// exclusive use.
+type kurmaCPUs struct {
+	Reserved string `json:"reserved,omitempty"`
+}
+
+// kurmaHugepages reserves hugepages at boot for containers given them by their
+// hugepages isolator. Pages gives the number of pages of each size, keyed by
+// the size such as "2Mi" or "1Gi". A hugetlbfs of the kernel's default size is
+// mounted at Mount, /dev/hugepages by default, and those of other sizes beside
+// it, such as at /dev/hugepages-1GB.
+type kurmaHugepages struct {
+	Pages map[string]int `json:"pages,omitempty"`
+	Mount string         `json:"mount,omitempty"`
+}
+
+// kurmaResources configures how containers are admitted by the CPU and memory
+// their apps' resource isolators request. SystemCPUMillis and SystemMemoryMB,
+// 100 and 256 by default, are kept for kurma and the host's services, and what
+// is left of the host is
+// scaled by the OvercommitRatio, 1 by default, for the containers to reserve
+// between them. A container which doesn't fit is refused, unless Enabled is
+// false.
+type kurmaResources struct {
+	Enabled         *bool   `json:"enabled,omitempty"`
+	SystemCPUMillis int     `json:"system_cpu_millis,omitempty"`
+	SystemMemoryMB  int     `json:"system_memory_mb,omitempty"`
+	OvercommitRatio float64 `json:"overcommit_ratio,omitempty"`
+}
+
+// kurmaEviction configures how the host is relieved when it runs short of
+// memory, which is once its memory pressure reaches PressurePercent, 40 by
+// default, or once less than MinAvailableMB, 128 by default, is available.
+// Every IntervalSeconds, 10 by default, the largest container of the lowest
+// priority is throttled, or evicted once the others of its priority are
+// throttled too. Containers with the critical priority are never touched.
+// Nothing is throttled or evicted if Enabled is false.
+type kurmaEviction struct {
+	Enabled         *bool   `json:"enabled,omitempty"`
+	PressurePercent float64 `json:"pressure_percent,omitempty"`
+	MinAvailableMB  int     `json:"min_available_mb,omitempty"`
+	IntervalSeconds int     `json:"interval_seconds,omitempty"`
+}
+
+// kurmaStorage selects the storage driver which provides containers' root
+// filesystems: overlay, btrfs, devicemapper, or plain. If Driver is empty,
+// overlay is used when the kernel supports it, and plain otherwise. The
+// devicemapper driver allocates devices of BaseSizeGB from the existing thin
+// pool named by ThinPool. When RuntimeBundles is set, each container's
+// directory is also written as an OCI runtime bundle.
+type kurmaStorage struct {
+	Driver         string `json:"driver,omitempty"`
+	ThinPool       string `json:"thin_pool,omitempty"`
+	BaseSizeGB     int    `json:"base_size_gb,omitempty"`
+	RuntimeBundles bool   `json:"runtime_bundles,omitempty"`
+}
+
+// kurmaVirtualMachines configures the booting of the VMs containers may be run
+// within rather than within namespaces. Containers can only be run in VMs when
+// the Kernel is set, which must have virtio, 9p, and devtmpfs built in. Qemu is
+// looked up within the PATH if it isn't set, and the Agent run as each VM's
+// init defaults to the kurma binary itself. Each VM is given MemoryMB of
+// memory and CPUs CPUs, 256MB and 1 by default.
+type kurmaVirtualMachines struct {
+	Kernel   string `json:"kernel,omitempty"`
+	Qemu     string `json:"qemu,omitempty"`
+	Agent    string `json:"agent,omitempty"`
+	MemoryMB int    `json:"memory_mb,omitempty"`
+	CPUs     int    `json:"cpus,omitempty"`
+}
+
+// kurmaSecurity configures the default label containers are confined by,
+// using whichever of AppArmor or SELinux is enabled on the host. The AppArmor
+// profile must already be loaded.
+type kurmaSecurity struct {
+	AppArmorProfile string `json:"apparmor_profile,omitempty"`
+	SELinuxLabel    string `json:"selinux_label,omitempty"`
+}
+
+// kurmaFirewall configures the host's firewall, which drops the traffic the
+// host is sent unless it is to the ports of the API and its gateway, the
+// metrics endpoint, SSH when the console is enabled, or the additional Ports,
+// such as "9100" or "53/udp". Traffic from the TrustedInterfaces, and from the
+// container network's bridge, is always accepted. The Rules and Rules6 are
+// raw iptables and ip6tables rules checked ahead of the ports. Ports published
+// by containers are forwarded before the host's rules are reached, so they
+// stay open. The firewall is enabled unless disabled.
+type kurmaFirewall struct {
+	Enabled           *bool    `json:"enabled,omitempty"`
+	Ports             []string `json:"ports,omitempty"`
+	TrustedInterfaces []string `json:"trusted_interfaces,omitempty"`
+	Rules             []string `json:"rules,omitempty"`
+	Rules6            []string `json:"rules6,omitempty"`
+}
+
+// kurmaEmergency configures emergency mode, which the host enters when it fails
+// to load its configuration, launch its container manager, or serve the API,
+// or when it is booted with kurma.emergency. The host's own Shell, /bin/sh
+// unless another is given, is then offered on each of the Consoles, or on
+// /dev/console if none are given, to those who enter the console's password,
+// or to anyone if it has none.
+type kurmaEmergency struct {
+	Consoles []string `json:"consoles,omitempty"`
+	Shell    string   `json:"shell,omitempty"`
+}
+
+// kurmaSecrets configures the key the secrets given to containers are
+// encrypted with on disk. The KeySource is tpm, to unseal it from TPMHandle,
+// cmdline, for the kurma.secrets_key kernel parameter, or a key server's URL.
+// Without one, a key is generated and kept alongside the secrets, which then
+// are only as safe as the disk they're on.
+type kurmaSecrets struct {
+	KeySource string `json:"key_source,omitempty"`
+	TPMHandle string `json:"tpm_handle,omitempty"`
+}
+
+// kurmaRegistries maps the hosts of the registries images are retrieved from,
+// with their port if it isn't the default, to the credentials used with them,
+// both for the init containers and for the containers created through the API.
+// Docker Hub's are given under docker.io.
+type kurmaRegistries map[string]remote.RegistryCredentials
+
+// kurmaPreloadImages configures the images imported into the image store at
+// boot from the Directory, so that hosts without network access can run
+// containers from them. When a Device is given, such as a second partition or
+// USB drive, it is mounted read-only and the Directory is within it.
+type kurmaPreloadImages struct {
+	Device    string `json:"device,omitempty"`
+	Directory string `json:"directory,omitempty"`
+}
+
+// kurmaCluster lists the Peers, the other hosts in the cluster, which clients
+// connected to this host may reach by their names. The Name is what this host
+// is known by in the cluster, and defaults to its hostname.
+type kurmaCluster struct {
+	Name  string       `json:"name,omitempty"`
+	Peers []*kurmaPeer `json:"peers,omitempty"`
+}
+
+// kurmaUpdates configures updating the system image of a host which boots it
+// from one of two partitions on the Disk, named by Partitions, KURMA-A and
+// KURMA-B by default. The bootloader boots whichever has the highest GPT
+// priority and has either booted successfully or has tries left, passing its
+// name as kurma.partition. An update is written to the other partition, which
+// is then tried once, and the host rolls back to the image it was updated from
+// unless its containers are healthy within HealthTimeoutSeconds, 600 by
+// default.
+type kurmaUpdates struct {
+	Disk                 string   `json:"disk,omitempty"`
+	Partitions           []string `json:"partitions,omitempty"`
+	HealthTimeoutSeconds int      `json:"health_timeout_seconds,omitempty"`
+}
+
+// kurmaAttestation configures measured boot, for hosts with a TPM 2.0. Once it
+// is enabled, the kurma binary and the configuration are measured into the
+// PCR, 13 by default, before any containers are run. Clients may then request
+// a quote of the QuotePCRs, 0 through 7 and the PCR by default, signed by the
+// AttestationKey, the persistent handle of a restricted signing key in the TPM
+// such as 0x81010002.
+type kurmaAttestation struct {
+	Enabled        *bool  `json:"enabled,omitempty"`
+	PCR            int    `json:"pcr,omitempty"`
+	QuotePCRs      []int  `json:"quote_pcrs,omitempty"`
+	AttestationKey string `json:"attestation_key,omitempty"`
+}
+
+// kurmaPeer is another host in the cluster, with the Endpoint its API is
+// served on, such as tcp://10.0.0.2:12311.
+type kurmaPeer struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+}
+
+// kurmaSwap is swap space enabled at boot, which is one of a block Device,
+// formatted as swap unless it already is or Format is false; a swap File,
+// created with SizeMB if it doesn't exist; or a Zram device, which is
+// compressed swap in memory of SizeMB, using the Compression algorithm if it is
+// set. Swap spaces with a higher Priority, from 0 to 32767, are used first.
+type kurmaSwap struct {
+	Device      string `json:"device,omitempty"`
+	File        string `json:"file,omitempty"`
+	Zram        bool   `json:"zram,omitempty"`
+	SizeMB      int    `json:"size_mb,omitempty"`
+	Priority    *int   `json:"priority,omitempty"`
+	Format      *bool  `json:"format,omitempty"`
+	Compression string `json:"compression,omitempty"`
+}
+
+// kurmaDiskConfiguration is a disk to mount at boot, which is formatted with
+// FsType unless it already has that filesystem or Format is false, and is used
+// for the paths in Usage. If Partition is set, a blank disk is given a
+// partition table with a single partition spanning it, and the disk's first
+// partition is used instead. A filesystem created on it is given the Label, so
+// it can be found by LABEL= on later boots.
+// kurmaRaidArray is an md RAID array assembled at boot from its Devices, and
+// available at /dev/md/Name. If the array doesn't exist and all of its devices
+// are blank, it is created with the RAID Level, such as raid1 or raid5, unless
+// Create is false. An array which is missing devices is only started degraded
+// if AllowDegraded is set.
+type kurmaRaidArray struct {
+	Name          string   `json:"name"`
+	Level         string   `json:"level,omitempty"`
+	Devices       []string `json:"devices"`
+	Create        *bool    `json:"create,omitempty"`
+	AllowDegraded bool     `json:"allow_degraded,omitempty"`
+}
+
+// kurmaVolumeGroup is an LVM volume group activated at boot after the RAID
+// arrays are assembled, so its Devices may be arrays. If the volume group
+// doesn't exist and all of its devices are blank, it is created unless Create
+// is false. Its Volumes which don't exist are created.
+type kurmaVolumeGroup struct {
+	Name    string                `json:"name"`
+	Devices []string              `json:"devices"`
+	Create  *bool                 `json:"create,omitempty"`
+	Volumes []*kurmaLogicalVolume `json:"volumes,omitempty"`
+}
+
+// kurmaLogicalVolume is a logical volume within a volume group, available at
+// /dev/GROUP/Name. It is created with SizeMB, or with the rest of the volume
+// group's free space if SizeMB is 0.
+type kurmaLogicalVolume struct {
+	Name   string `json:"name"`
+	SizeMB int    `json:"size_mb,omitempty"`
+}
+
+// kurmaEncryptedDevice is a LUKS encrypted Device, unlocked at boot and mapped
+// to /dev/mapper/Name so that the disks holding the container and volume
+// directories can be placed on it. A blank device is formatted with LUKS
+// unless Format is false. The key is given by the KeySource, which is one of:
+// "cmdline", read from the kurma.luks_key.NAME kernel parameter; "tpm",
+// unsealed by the TPM from the persistent object at TPMHandle; or the http or
+// https URL of a key server, which is fetched once the network is up. The
+// kernel command line can be read from within containers, so it is best
+// reserved for hosts which only run trusted ones.
+type kurmaEncryptedDevice struct {
+	Name      string `json:"name"`
+	Device    string `json:"device"`
+	KeySource string `json:"key_source"`
+	TPMHandle string `json:"tpm_handle,omitempty"`
+	Format    *bool  `json:"format,omitempty"`
+}
+
+// kurmaCrash configures how the host handles a kernel crash. It reboots
+// PanicTimeoutSeconds after a panic, which defaults to 10 and hangs instead
+// when 0, and an oops is treated as a panic when PanicOnOops is set. If a
+// CrashKernel is given, it is loaded with its CrashInitrd to be booted into on
+// a panic, which requires memory to have been reserved with crashkernel= on
+// the kernel command line. Its CrashCmdline defaults to the host's, adjusted
+// for capturing a dump.
+type kurmaCrash struct {
+	PanicTimeoutSeconds *int   `json:"panic_timeout_seconds,omitempty"`
+	PanicOnOops         *bool  `json:"panic_on_oops,omitempty"`
+	CrashKernel         string `json:"crash_kernel,omitempty"`
+	CrashInitrd         string `json:"crash_initrd,omitempty"`
+	CrashCmdline        string `json:"crash_cmdline,omitempty"`
 }
 
 type kurmaDiskConfiguration struct {
-	Device string           `json:"device"`
-	FsType string           `json:"fstype,omitempty"`
-	Format *bool            `json:"format,omitempty"`
-	Usage  []kurmaPathUsage `json:"usage"`
-	Resize bool             `json:"resize"`
+	Device    string           `json:"device"`
+	FsType    string           `json:"fstype,omitempty"`
+	Format    *bool            `json:"format,omitempty"`
+	Usage     []kurmaPathUsage `json:"usage"`
+	Resize    bool             `json:"resize"`
+	Partition bool             `json:"partition,omitempty"`
+	Label     string           `json:"label,omitempty"`
+}
+
+// kurmaInitContainer is an image to launch on boot. It may be specified either
+// as just the image or as an object which allows the signature verification of
+// the image to be skipped, the image's expected SHA-512 hash to be given, and
+// the number of seconds its apps are given to exit when it is stopped to be
+// set.
+//
+// The object may also give the container a Name, which other init containers
+// list in their DependsOn to be started only once it is ready, as determined
+// by its Readiness check.
+//
+// Init containers are critical, and so are never throttled or evicted when the
+// host is short of memory, unless the object gives them a lower Priority.
+type kurmaInitContainer struct {
+	Image           string               `json:"image"`
+	ImageHash       string               `json:"image_hash,omitempty"`
+	Insecure        bool                 `json:"insecure,omitempty"`
+	StopGracePeriod int                  `json:"stop_grace_period,omitempty"`
+	Privileged      bool                 `json:"privileged,omitempty"`
+	Name            string               `json:"name,omitempty"`
+	DependsOn       []string             `json:"depends_on,omitempty"`
+	Readiness       *kurmaReadinessCheck `json:"readiness,omitempty"`
+	Priority        string               `json:"priority,omitempty"`
+}
+
+// kurmaReadinessCheck determines when an init container is ready for those
+// depending on it to be started. It is ready once the Exec command, run within
+// the container, exits successfully, or once a connection can be made to the
+// TCP address. It is checked every IntervalSeconds for up to TimeoutSeconds.
+// Without a check, the container is ready once its apps are running.
+type kurmaReadinessCheck struct {
+	Exec            []string `json:"exec,omitempty"`
+	TCP             string   `json:"tcp,omitempty"`
+	IntervalSeconds int      `json:"interval_seconds,omitempty"`
+	TimeoutSeconds  int      `json:"timeout_seconds,omitempty"`
+}
+
+func (c *kurmaInitContainer) UnmarshalJSON(b []byte) error {
+	var image string
+	if err := json.Unmarshal(b, &image); err == nil {
+		c.Image = image
+		return nil
+	}
+
+	type initContainer kurmaInitContainer
+	var ic initContainer
+	if err := json.Unmarshal(b, &ic); err != nil {
+		return err
+	}
+	*c = kurmaInitContainer(ic)
+	return nil
 }
 
 type kurmaPathUsage string
 
 const (
-	kurmaPathPods    = kurmaPathUsage("pods")
-	kurmaPathVolumes = kurmaPathUsage("volumes")
+	kurmaPathPods      = kurmaPathUsage("pods")
+	kurmaPathVolumes   = kurmaPathUsage("volumes")
+	kurmaPathImages    = kurmaPathUsage("images")
+	kurmaPathDownloads = kurmaPathUsage("downloads")
+	kurmaPathStorage   = kurmaPathUsage("storage")
+	kurmaPathCrash     = kurmaPathUsage("crash")
+	kurmaPathSecrets   = kurmaPathUsage("secrets")
 
 	kurmaPath = "/var/kurma"
 	mountPath = "/mnt"
 )
 
 type kurmaServices struct {
-	NTP     kurmaNTPService     `json:"ntp,omitempty"`
-	Udev    kurmaGenericService `json:"udev,omitempty"`
-	Console kurmaConsoleService `json:"console,omitempty"`
+	API      kurmaAPIService      `json:"api,omitempty"`
+	NTP      kurmaNTPService      `json:"ntp,omitempty"`
+	Udev     kurmaGenericService  `json:"udev,omitempty"`
+	Devices  kurmaDevicesService  `json:"devices,omitempty"`
+	Console  kurmaConsoleService  `json:"console,omitempty"`
+	DNS      kurmaDNSService      `json:"dns,omitempty"`
+	Metrics  kurmaMetricsService  `json:"metrics,omitempty"`
+	Gateway  kurmaGatewayService  `json:"gateway,omitempty"`
+	CRI      kurmaCRIService      `json:"cri,omitempty"`
+	Metadata kurmaMetadataService `json:"metadata,omitempty"`
+	Registry kurmaRegistryService `json:"registry,omitempty"`
+	Watchdog kurmaWatchdogService `json:"watchdog,omitempty"`
 }
 
-type kurmaGenericService struct {
+// kurmaAPIService configures the endpoints the API is served on, such as
+// unix:///var/kurma/kurma.sock or tcp://127.0.0.1:12311, and the certificates
+// used to secure the tcp listeners. Privileged operations are only permitted
+// over unix sockets, or tcp listeners requiring a client certificate.
+type kurmaAPIService struct {
+	Listeners []string           `json:"listeners,omitempty"`
+	TLSCert   string             `json:"tls_cert,omitempty"`
+	TLSKey    string             `json:"tls_key,omitempty"`
+	TLSCACert string             `json:"tls_ca_cert,omitempty"`
+	Auth      *kurmaAPIAuth      `json:"auth,omitempty"`
+	RateLimit *kurmaAPIRateLimit `json:"rate_limit,omitempty"`
+}
+
+// kurmaAPIRateLimit limits each client of the API to Burst requests at once,
+// and then RequestsPerSecond. Clients are told apart by the user they
+// authenticate as.
+type kurmaAPIRateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst,omitempty"`
+}
+
+// kurmaAPIAuth lists the users clients of the API must authenticate as, except
+// over local unix sockets. A client authenticates by sending a user's token,
+// or by presenting a client certificate whose common name is the user's name.
+// Each user's role is read-only, operator, or admin.
+type kurmaAPIAuth struct {
+	Users []kurmaAPIUser `json:"users,omitempty"`
+}
+
+// kurmaAPIUser is a user of the API. Its token is given directly or read from
+// TokenFile, and may be left out for users who authenticate by certificate.
+type kurmaAPIUser struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Token     string `json:"token,omitempty"`
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// kurmaMetricsService configures the endpoint, such as tcp://0.0.0.0:12312,
+// which Prometheus metrics are served on. They are disabled when it is unset.
+type kurmaMetricsService struct {
+	Listener string `json:"listener,omitempty"`
+}
+
+// kurmaGatewayService configures the endpoint, such as tcp://0.0.0.0:12313, on
+// which the API is also served as JSON over HTTP. It uses the API's
+// certificates and users, and is disabled when the listener is unset.
+type kurmaGatewayService struct {
+	Listener string `json:"listener,omitempty"`
+}
+
+// kurmaCRIService configures the unix socket, such as
+// unix:///var/run/kurma-cri.sock, on which the Kubernetes Container Runtime
+// Interface is served for a kubelet on the host. It is disabled when the
+// listener is unset.
+type kurmaCRIService struct {
+	Listener string `json:"listener,omitempty"`
+}
+
+// kurmaMetadataService configures the App Container metadata service, which
+// apps reach at the URL they're given in AC_METADATA_URL. It is enabled unless
+// disabled, and served on port 18112 unless another is given.
+type kurmaMetadataService struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	Port    int   `json:"port,omitempty"`
+}
+
+// kurmaDNSService configures the DNS server on the container network, which
+// resolves containers and their apps by name under the Domain, "kurma" unless
+// another is given, and forwards other queries to the host's nameservers. It
+// is enabled unless disabled, and is only run when there is a container
+// network.
+type kurmaDNSService struct {
 	Enabled *bool  `json:"enabled,omitempty"`
-	ACI     string `json:"aci,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+}
+
+// kurmaRegistryService configures publishing the host and its containers to
+// etcd or Consul, for service discovery and schedulers outside of the host. The
+// Backend is "etcd" or "consul", and publishing is disabled when it is unset.
+// The Endpoint is the store's HTTP URL, the keys are put under the Prefix, and
+// they expire TTLSeconds after the host stops refreshing them. The Address is
+// the one the host's published ports are reached on, which defaults to the
+// host's first non-loopback IPv4 address.
+type kurmaRegistryService struct {
+	Backend    string `json:"backend,omitempty"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+	Address    string `json:"address,omitempty"`
+}
+
+// kurmaGenericService configures a service run as a container from the ACI
+// when Enabled is set to true. The image's signature is verified against the
+// image keystore unless Insecure is set.
+type kurmaGenericService struct {
+	Enabled  *bool  `json:"enabled,omitempty"`
+	ACI      string `json:"aci,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// kurmaDevicesService configures the built-in device manager, which creates and
+// removes the nodes in /dev as devices are added and removed, and loads the
+// modules for them. It can be disabled by setting Enabled to false.
+type kurmaDevicesService struct {
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// kurmaWatchdogService configures the hardware watchdog, which is opened and
+// kept from resetting the host when Enabled is set to true. The Device
+// defaults to /dev/watchdog, and TimeoutSeconds is how long the host may go
+// unresponsive, or have its container manager or API server wedged, before it
+// is reset.
+type kurmaWatchdogService struct {
+	Enabled        *bool  `json:"enabled,omitempty"`
+	Device         string `json:"device,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
 }
 
+// kurmaNTPService configures how the host's clock is set. Unless it is disabled
+// by setting Enabled to false, the built-in SNTP client steps the clock from
+// the Servers at boot, and then slews it every Interval, such as "30m". If an
+// ACI is given and Enabled is set to true, the image is run as a container to
+// set the clock instead, once its signature is verified unless Insecure is set.
 type kurmaNTPService struct {
 	Enabled  *bool    `json:"enabled,omitempty"`
 	ACI      string   `json:"aci,omitempty"`
+	Insecure bool     `json:"insecure,omitempty"`
 	Servers  []string `json:"servers,omitempty"`
 	Interval string   `json:"interval,omitempty"`
 }
 
+// kurmaConsoleService configures the console container, a privileged container
+// from the ACI which is given the Password and SSHKeys to run an SSH server
+// with. It is launched at boot when Enabled is set to true, and otherwise when
+// a debug shell is first requested with "kurma-cli host console", unless
+// Enabled is set to false. An ACI with the host/privileged isolator joins the
+// host's namespaces. The debug shells run the Shell within the container,
+// /bin/sh unless another is given. A login prompt is also run on each of the
+// Terminals, such as ttyS0 or tty1, giving those who enter the Password a
+// shell within the console. The ACI's signature is verified against the image
+// keystore unless Insecure is set.
 type kurmaConsoleService struct {
-	Enabled  *bool    `json:"enabled,omitempty"`
-	ACI      string   `json:"aci,omitempty"`
-	Password *string  `json:"password,omitmepty"`
-	SSHKeys  []string `json:"ssh_keys,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+	ACI       string   `json:"aci,omitempty"`
+	Insecure  bool     `json:"insecure,omitempty"`
+	Password  *string  `json:"password,omitmepty"`
+	SSHKeys   []string `json:"ssh_keys,omitempty"`
+	Shell     string   `json:"shell,omitempty"`
+	Terminals []string `json:"terminals,omitempty"`
 }
 
 func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
@@ -84,13 +690,42 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 		return
 	}
 
-	// FIXME datasources
+	// append datasources
+	if len(o.Datasources) > 0 {
+		cfg.Datasources = append(cfg.Datasources, o.Datasources...)
+	}
 
 	// oem config
 	if o.OEMConfig != nil {
 		cfg.OEMConfig = o.OEMConfig
 	}
 
+	// logging
+	if o.Logging.Level != "" {
+		cfg.Logging.Level = o.Logging.Level
+	}
+	if o.Logging.Format != "" {
+		cfg.Logging.Format = o.Logging.Format
+	}
+	if len(o.Logging.Sinks) > 0 {
+		cfg.Logging.Sinks = o.Logging.Sinks
+	}
+	if o.Logging.ContainerOutput != nil {
+		cfg.Logging.ContainerOutput = o.Logging.ContainerOutput
+	}
+	if o.Logging.ContainerLogs.Driver != "" {
+		cfg.Logging.ContainerLogs.Driver = o.Logging.ContainerLogs.Driver
+	}
+	if o.Logging.ContainerLogs.MaxSizeMB > 0 {
+		cfg.Logging.ContainerLogs.MaxSizeMB = o.Logging.ContainerLogs.MaxSizeMB
+	}
+	if o.Logging.ContainerLogs.MaxFiles > 0 {
+		cfg.Logging.ContainerLogs.MaxFiles = o.Logging.ContainerLogs.MaxFiles
+	}
+	if len(o.Logging.ContainerLogs.Options) > 0 {
+		cfg.Logging.ContainerLogs.Options = o.Logging.ContainerLogs.Options
+	}
+
 	// replace hostname
 	if o.Hostname != "" {
 		cfg.Hostname = o.Hostname
@@ -104,31 +739,410 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 	if o.NetworkConfig.Gateway != "" {
 		cfg.NetworkConfig.Gateway = o.NetworkConfig.Gateway
 	}
+	if o.NetworkConfig.Gateway6 != "" {
+		cfg.NetworkConfig.Gateway6 = o.NetworkConfig.Gateway6
+	}
+	// replace proxies and CAs
+	if o.NetworkConfig.ProxyURL != "" {
+		cfg.NetworkConfig.ProxyURL = o.NetworkConfig.ProxyURL
+	}
+	if o.NetworkConfig.HTTPProxy != "" {
+		cfg.NetworkConfig.HTTPProxy = o.NetworkConfig.HTTPProxy
+	}
+	if o.NetworkConfig.HTTPSProxy != "" {
+		cfg.NetworkConfig.HTTPSProxy = o.NetworkConfig.HTTPSProxy
+	}
+	if o.NetworkConfig.NoProxy != "" {
+		cfg.NetworkConfig.NoProxy = o.NetworkConfig.NoProxy
+	}
+	if o.NetworkConfig.CADirectory != "" {
+		cfg.NetworkConfig.CADirectory = o.NetworkConfig.CADirectory
+	}
 	// replace interfaces
 	if len(o.NetworkConfig.Interfaces) > 0 {
 		cfg.NetworkConfig.Interfaces = o.NetworkConfig.Interfaces
 	}
+	// container network
+	if o.NetworkConfig.ContainerNetwork.Enabled != nil {
+		cfg.NetworkConfig.ContainerNetwork.Enabled = o.NetworkConfig.ContainerNetwork.Enabled
+	}
+	if o.NetworkConfig.ContainerNetwork.Bridge != "" {
+		cfg.NetworkConfig.ContainerNetwork.Bridge = o.NetworkConfig.ContainerNetwork.Bridge
+	}
+	if o.NetworkConfig.ContainerNetwork.Subnet != "" {
+		cfg.NetworkConfig.ContainerNetwork.Subnet = o.NetworkConfig.ContainerNetwork.Subnet
+	}
+	if o.NetworkConfig.ContainerNetwork.Subnet6 != "" {
+		cfg.NetworkConfig.ContainerNetwork.Subnet6 = o.NetworkConfig.ContainerNetwork.Subnet6
+	}
+	if o.NetworkConfig.ContainerNetwork.MTU > 0 {
+		cfg.NetworkConfig.ContainerNetwork.MTU = o.NetworkConfig.ContainerNetwork.MTU
+	}
+	if o.NetworkConfig.ContainerNetwork.NAT != nil {
+		cfg.NetworkConfig.ContainerNetwork.NAT = o.NetworkConfig.ContainerNetwork.NAT
+	}
+	if o.NetworkConfig.ContainerNetwork.Mode != "" {
+		cfg.NetworkConfig.ContainerNetwork.Mode = o.NetworkConfig.ContainerNetwork.Mode
+	}
+	if o.NetworkConfig.ContainerNetwork.Parent != "" {
+		cfg.NetworkConfig.ContainerNetwork.Parent = o.NetworkConfig.ContainerNetwork.Parent
+	}
+	if o.NetworkConfig.ContainerNetwork.Gateway != "" {
+		cfg.NetworkConfig.ContainerNetwork.Gateway = o.NetworkConfig.ContainerNetwork.Gateway
+	}
+	if o.NetworkConfig.ContainerNetwork.Range != "" {
+		cfg.NetworkConfig.ContainerNetwork.Range = o.NetworkConfig.ContainerNetwork.Range
+	}
+	if o.NetworkConfig.ContainerNetwork.DHCP != nil {
+		cfg.NetworkConfig.ContainerNetwork.DHCP = o.NetworkConfig.ContainerNetwork.DHCP
+	}
 
 	// append modules
 	if len(o.Modules) > 0 {
 		cfg.Modules = append(cfg.Modules, o.Modules...)
 	}
 
+	// merge sysctls, replacing the values of those already set
+	if len(o.Sysctls) > 0 {
+		sysctls := make(map[string]string, len(cfg.Sysctls)+len(o.Sysctls))
+		for name, value := range cfg.Sysctls {
+			sysctls[name] = value
+		}
+		for name, value := range o.Sysctls {
+			sysctls[name] = value
+		}
+		cfg.Sysctls = sysctls
+	}
+
 	// replace disks
 	if len(o.Disks) > 0 {
 		cfg.Disks = o.Disks
 	}
 
+	// replace raid arrays and volume groups
+	if len(o.RaidArrays) > 0 {
+		cfg.RaidArrays = o.RaidArrays
+	}
+	if len(o.VolumeGroups) > 0 {
+		cfg.VolumeGroups = o.VolumeGroups
+	}
+
+	// replace encrypted devices
+	if len(o.EncryptedDevices) > 0 {
+		cfg.EncryptedDevices = o.EncryptedDevices
+	}
+
+	// crash handling
+	if o.Crash.PanicTimeoutSeconds != nil {
+		cfg.Crash.PanicTimeoutSeconds = o.Crash.PanicTimeoutSeconds
+	}
+	if o.Crash.PanicOnOops != nil {
+		cfg.Crash.PanicOnOops = o.Crash.PanicOnOops
+	}
+	if o.Crash.CrashKernel != "" {
+		cfg.Crash.CrashKernel = o.Crash.CrashKernel
+		cfg.Crash.CrashInitrd = o.Crash.CrashInitrd
+		cfg.Crash.CrashCmdline = o.Crash.CrashCmdline
+	}
+
+	// replace swap
+	if len(o.Swap) > 0 {
+		cfg.Swap = o.Swap
+	}
+
 	// replace cgroup name
 	if o.ParentCgroupName != "" {
 		cfg.ParentCgroupName = o.ParentCgroupName
 	}
+	if o.CgroupVersion != 0 {
+		cfg.CgroupVersion = o.CgroupVersion
+	}
 
 	// append init containers
 	if len(o.InitContainers) > 0 {
 		cfg.InitContainers = append(cfg.InitContainers, o.InitContainers...)
 	}
 
+	// replace the image keystore
+	if o.ImageKeystore != "" {
+		cfg.ImageKeystore = o.ImageKeystore
+	}
+
+	// add registry credentials, replacing those for the same hosts
+	if len(o.Registries) > 0 && cfg.Registries == nil {
+		cfg.Registries = make(kurmaRegistries)
+	}
+	for host, creds := range o.Registries {
+		cfg.Registries[host] = creds
+	}
+
+	// replace the images to preload
+	if o.PreloadImages.Device != "" {
+		cfg.PreloadImages.Device = o.PreloadImages.Device
+	}
+	if o.PreloadImages.Directory != "" {
+		cfg.PreloadImages.Directory = o.PreloadImages.Directory
+	}
+
+	// replace paths
+	if o.Paths.Volumes != "" {
+		cfg.Paths.Volumes = o.Paths.Volumes
+	}
+	if o.Paths.Images != "" {
+		cfg.Paths.Images = o.Paths.Images
+	}
+	if o.Paths.Downloads != "" {
+		cfg.Paths.Downloads = o.Paths.Downloads
+	}
+	if o.Paths.Storage != "" {
+		cfg.Paths.Storage = o.Paths.Storage
+	}
+
+	// garbage collection
+	if o.GarbageCollection.Enabled != nil {
+		cfg.GarbageCollection.Enabled = o.GarbageCollection.Enabled
+	}
+	if o.GarbageCollection.IntervalMinutes > 0 {
+		cfg.GarbageCollection.IntervalMinutes = o.GarbageCollection.IntervalMinutes
+	}
+	if o.GarbageCollection.ExitedRetentionHours != nil {
+		cfg.GarbageCollection.ExitedRetentionHours = o.GarbageCollection.ExitedRetentionHours
+	}
+	if o.GarbageCollection.ImageCacheGB > 0 {
+		cfg.GarbageCollection.ImageCacheGB = o.GarbageCollection.ImageCacheGB
+	}
+
+	// user namespaces
+	if o.UserNamespaces.Enabled != nil {
+		cfg.UserNamespaces.Enabled = o.UserNamespaces.Enabled
+	}
+	if o.UserNamespaces.UIDStart > 0 {
+		cfg.UserNamespaces.UIDStart = o.UserNamespaces.UIDStart
+	}
+	if o.UserNamespaces.GIDStart > 0 {
+		cfg.UserNamespaces.GIDStart = o.UserNamespaces.GIDStart
+	}
+	if o.UserNamespaces.Length > 0 {
+		cfg.UserNamespaces.Length = o.UserNamespaces.Length
+	}
+	if o.UserNamespaces.BlockSize > 0 {
+		cfg.UserNamespaces.BlockSize = o.UserNamespaces.BlockSize
+	}
+
+	// cpus
+	if o.CPUs.Reserved != "" {
+		cfg.CPUs.Reserved = o.CPUs.Reserved
+	}
+
+	// hugepages
+	if len(o.Hugepages.Pages) > 0 {
+		cfg.Hugepages.Pages = o.Hugepages.Pages
+	}
+	if o.Hugepages.Mount != "" {
+		cfg.Hugepages.Mount = o.Hugepages.Mount
+	}
+
+	// resources
+	if o.Resources.Enabled != nil {
+		cfg.Resources.Enabled = o.Resources.Enabled
+	}
+	if o.Resources.SystemCPUMillis > 0 {
+		cfg.Resources.SystemCPUMillis = o.Resources.SystemCPUMillis
+	}
+	if o.Resources.SystemMemoryMB > 0 {
+		cfg.Resources.SystemMemoryMB = o.Resources.SystemMemoryMB
+	}
+	if o.Resources.OvercommitRatio > 0 {
+		cfg.Resources.OvercommitRatio = o.Resources.OvercommitRatio
+	}
+
+	// eviction
+	if o.Eviction.Enabled != nil {
+		cfg.Eviction.Enabled = o.Eviction.Enabled
+	}
+	if o.Eviction.PressurePercent > 0 {
+		cfg.Eviction.PressurePercent = o.Eviction.PressurePercent
+	}
+	if o.Eviction.MinAvailableMB > 0 {
+		cfg.Eviction.MinAvailableMB = o.Eviction.MinAvailableMB
+	}
+	if o.Eviction.IntervalSeconds > 0 {
+		cfg.Eviction.IntervalSeconds = o.Eviction.IntervalSeconds
+	}
+
+	// storage
+	if o.Storage.Driver != "" {
+		cfg.Storage.Driver = o.Storage.Driver
+	}
+	if o.Storage.ThinPool != "" {
+		cfg.Storage.ThinPool = o.Storage.ThinPool
+	}
+	if o.Storage.BaseSizeGB > 0 {
+		cfg.Storage.BaseSizeGB = o.Storage.BaseSizeGB
+	}
+	if o.Storage.RuntimeBundles {
+		cfg.Storage.RuntimeBundles = true
+	}
+
+	// virtual machines
+	if o.VirtualMachines.Kernel != "" {
+		cfg.VirtualMachines.Kernel = o.VirtualMachines.Kernel
+	}
+	if o.VirtualMachines.Qemu != "" {
+		cfg.VirtualMachines.Qemu = o.VirtualMachines.Qemu
+	}
+	if o.VirtualMachines.Agent != "" {
+		cfg.VirtualMachines.Agent = o.VirtualMachines.Agent
+	}
+	if o.VirtualMachines.MemoryMB > 0 {
+		cfg.VirtualMachines.MemoryMB = o.VirtualMachines.MemoryMB
+	}
+	if o.VirtualMachines.CPUs > 0 {
+		cfg.VirtualMachines.CPUs = o.VirtualMachines.CPUs
+	}
+
+	// security
+	if o.Security.AppArmorProfile != "" {
+		cfg.Security.AppArmorProfile = o.Security.AppArmorProfile
+	}
+	if o.Security.SELinuxLabel != "" {
+		cfg.Security.SELinuxLabel = o.Security.SELinuxLabel
+	}
+
+	// emergency
+	if len(o.Emergency.Consoles) > 0 {
+		cfg.Emergency.Consoles = o.Emergency.Consoles
+	}
+	if o.Emergency.Shell != "" {
+		cfg.Emergency.Shell = o.Emergency.Shell
+	}
+
+	// firewall
+	if o.Firewall.Enabled != nil {
+		cfg.Firewall.Enabled = o.Firewall.Enabled
+	}
+	if len(o.Firewall.Ports) > 0 {
+		cfg.Firewall.Ports = o.Firewall.Ports
+	}
+	if len(o.Firewall.TrustedInterfaces) > 0 {
+		cfg.Firewall.TrustedInterfaces = o.Firewall.TrustedInterfaces
+	}
+	if len(o.Firewall.Rules) > 0 {
+		cfg.Firewall.Rules = o.Firewall.Rules
+	}
+	if len(o.Firewall.Rules6) > 0 {
+		cfg.Firewall.Rules6 = o.Firewall.Rules6
+	}
+
+	// secrets
+	if o.Secrets.KeySource != "" {
+		cfg.Secrets.KeySource = o.Secrets.KeySource
+	}
+	if o.Secrets.TPMHandle != "" {
+		cfg.Secrets.TPMHandle = o.Secrets.TPMHandle
+	}
+
+	// cluster
+	if o.Cluster.Name != "" {
+		cfg.Cluster.Name = o.Cluster.Name
+	}
+	if len(o.Cluster.Peers) > 0 {
+		cfg.Cluster.Peers = o.Cluster.Peers
+	}
+
+	// updates
+	if o.Updates.Disk != "" {
+		cfg.Updates.Disk = o.Updates.Disk
+	}
+	if len(o.Updates.Partitions) > 0 {
+		cfg.Updates.Partitions = o.Updates.Partitions
+	}
+	if o.Updates.HealthTimeoutSeconds > 0 {
+		cfg.Updates.HealthTimeoutSeconds = o.Updates.HealthTimeoutSeconds
+	}
+
+	// attestation
+	if o.Attestation.Enabled != nil {
+		cfg.Attestation.Enabled = o.Attestation.Enabled
+	}
+	if o.Attestation.PCR > 0 {
+		cfg.Attestation.PCR = o.Attestation.PCR
+	}
+	if len(o.Attestation.QuotePCRs) > 0 {
+		cfg.Attestation.QuotePCRs = o.Attestation.QuotePCRs
+	}
+	if o.Attestation.AttestationKey != "" {
+		cfg.Attestation.AttestationKey = o.Attestation.AttestationKey
+	}
+
+	// API
+	if len(o.Services.API.Listeners) > 0 {
+		cfg.Services.API.Listeners = o.Services.API.Listeners
+	}
+	if o.Services.API.TLSCert != "" {
+		cfg.Services.API.TLSCert = o.Services.API.TLSCert
+	}
+	if o.Services.API.TLSKey != "" {
+		cfg.Services.API.TLSKey = o.Services.API.TLSKey
+	}
+	if o.Services.API.TLSCACert != "" {
+		cfg.Services.API.TLSCACert = o.Services.API.TLSCACert
+	}
+	if o.Services.API.Auth != nil {
+		cfg.Services.API.Auth = o.Services.API.Auth
+	}
+	if o.Services.API.RateLimit != nil {
+		cfg.Services.API.RateLimit = o.Services.API.RateLimit
+	}
+
+	// Metrics
+	if o.Services.Metrics.Listener != "" {
+		cfg.Services.Metrics.Listener = o.Services.Metrics.Listener
+	}
+
+	// Gateway
+	if o.Services.Gateway.Listener != "" {
+		cfg.Services.Gateway.Listener = o.Services.Gateway.Listener
+	}
+
+	// CRI
+	if o.Services.CRI.Listener != "" {
+		cfg.Services.CRI.Listener = o.Services.CRI.Listener
+	}
+
+	// Metadata
+	if o.Services.Metadata.Enabled != nil {
+		cfg.Services.Metadata.Enabled = o.Services.Metadata.Enabled
+	}
+	if o.Services.Metadata.Port != 0 {
+		cfg.Services.Metadata.Port = o.Services.Metadata.Port
+	}
+
+	// DNS
+	if o.Services.DNS.Enabled != nil {
+		cfg.Services.DNS.Enabled = o.Services.DNS.Enabled
+	}
+	if o.Services.DNS.Domain != "" {
+		cfg.Services.DNS.Domain = o.Services.DNS.Domain
+	}
+
+	// Registry
+	if o.Services.Registry.Backend != "" {
+		cfg.Services.Registry.Backend = o.Services.Registry.Backend
+	}
+	if o.Services.Registry.Endpoint != "" {
+		cfg.Services.Registry.Endpoint = o.Services.Registry.Endpoint
+	}
+	if o.Services.Registry.Prefix != "" {
+		cfg.Services.Registry.Prefix = o.Services.Registry.Prefix
+	}
+	if o.Services.Registry.TTLSeconds > 0 {
+		cfg.Services.Registry.TTLSeconds = o.Services.Registry.TTLSeconds
+	}
+	if o.Services.Registry.Address != "" {
+		cfg.Services.Registry.Address = o.Services.Registry.Address
+	}
+
 	// NTP
 	if o.Services.NTP.Enabled != nil {
 		cfg.Services.NTP.Enabled = o.Services.NTP.Enabled
@@ -136,9 +1150,31 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 	if o.Services.NTP.ACI != "" {
 		cfg.Services.NTP.ACI = o.Services.NTP.ACI
 	}
+	if o.Services.NTP.Insecure {
+		cfg.Services.NTP.Insecure = true
+	}
 	if len(o.Services.NTP.Servers) > 0 {
 		cfg.Services.NTP.Servers = o.Services.NTP.Servers
 	}
+	if o.Services.NTP.Interval != "" {
+		cfg.Services.NTP.Interval = o.Services.NTP.Interval
+	}
+
+	// Devices
+	if o.Services.Devices.Enabled != nil {
+		cfg.Services.Devices.Enabled = o.Services.Devices.Enabled
+	}
+
+	// Watchdog
+	if o.Services.Watchdog.Enabled != nil {
+		cfg.Services.Watchdog.Enabled = o.Services.Watchdog.Enabled
+	}
+	if o.Services.Watchdog.Device != "" {
+		cfg.Services.Watchdog.Device = o.Services.Watchdog.Device
+	}
+	if o.Services.Watchdog.TimeoutSeconds > 0 {
+		cfg.Services.Watchdog.TimeoutSeconds = o.Services.Watchdog.TimeoutSeconds
+	}
 
 	// Udev
 	if o.Services.Udev.Enabled != nil {
@@ -147,6 +1183,9 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 	if o.Services.Udev.ACI != "" {
 		cfg.Services.Udev.ACI = o.Services.Udev.ACI
 	}
+	if o.Services.Udev.Insecure {
+		cfg.Services.Udev.Insecure = true
+	}
 
 	// Console
 	if o.Services.Console.Enabled != nil {
@@ -155,10 +1194,19 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 	if o.Services.Console.ACI != "" {
 		cfg.Services.Console.ACI = o.Services.Console.ACI
 	}
+	if o.Services.Console.Insecure {
+		cfg.Services.Console.Insecure = true
+	}
 	if o.Services.Console.Password != nil {
 		cfg.Services.Console.Password = o.Services.Console.Password
 	}
 	if len(o.Services.Console.SSHKeys) > 0 {
 		cfg.Services.Console.SSHKeys = o.Services.Console.SSHKeys
 	}
+	if o.Services.Console.Shell != "" {
+		cfg.Services.Console.Shell = o.Services.Console.Shell
+	}
+	if len(o.Services.Console.Terminals) > 0 {
+		cfg.Services.Console.Terminals = o.Services.Console.Terminals
+	}
 }
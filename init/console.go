@@ -0,0 +1,246 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/util/remote"
+	"github.com/creack/termios/raw"
+)
+
+const (
+	// defaultConsoleShell is the shell debug sessions run within the console
+	// container when none is configured.
+	defaultConsoleShell = "/bin/sh"
+
+	// loginDelay is how long a failed login on a terminal is held up, to slow
+	// down guessing the password.
+	loginDelay = 3 * time.Second
+
+	// terminalRetryInterval is how long to wait before reopening a terminal
+	// after it fails.
+	terminalRetryInterval = 10 * time.Second
+)
+
+// startConsole launches the console container when it is enabled, and offers
+// debug shells on the configured terminals. Unless the console is disabled, the
+// container is otherwise launched on demand.
+func (r *runner) startConsole() error {
+	cfg := r.config.Services.Console
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		r.log.Trace("Skipping console")
+		return nil
+	}
+
+	if len(cfg.Terminals) > 0 && cfg.Password == nil {
+		r.log.Errorf("Skipping the login prompts on %s, the console has no password",
+			strings.Join(cfg.Terminals, ", "))
+	} else {
+		for _, t := range cfg.Terminals {
+			go r.serveTerminal(terminalPath(t))
+		}
+	}
+
+	if cfg.Enabled == nil {
+		r.log.Trace("Deferring the console until a debug shell is requested")
+		return nil
+	}
+	if _, err := r.launchConsole(); err != nil {
+		return err
+	}
+	r.log.Debug("Started console")
+	return nil
+}
+
+// launchConsole returns the console container once it is running, creating it
+// if it hasn't been or was destroyed, and starting it again if it was stopped.
+func (r *runner) launchConsole() (*container.Container, error) {
+	r.consoleMutex.Lock()
+	defer r.consoleMutex.Unlock()
+
+	cfg := r.config.Services.Console
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		return nil, fmt.Errorf("the console is disabled")
+	}
+
+	c := r.console
+	if c != nil && r.manager.Container(c.UUID()) == nil {
+		c = nil
+	}
+	if c == nil {
+		if cfg.ACI == "" {
+			return nil, fmt.Errorf("no console image is configured")
+		}
+		var err error
+		if c, err = r.createConsole(cfg); err != nil {
+			return nil, err
+		}
+		r.console = c
+	} else if s := c.State(); s == container.STOPPED || s == container.EXITED {
+		if err := c.StartApps(); err != nil {
+			return nil, fmt.Errorf("failed to start the console: %v", err)
+		}
+	}
+
+	if err := waitForReadiness(c, nil); err != nil {
+		return nil, fmt.Errorf("the console did not start: %v", err)
+	}
+	return c, nil
+}
+
+// createConsole creates the console container from its image, passing it the
+// password and SSH keys to accept.
+func (r *runner) createConsole(cfg kurmaConsoleService) (*container.Container, error) {
+	f, err := r.retrieveImage(cfg.ACI, cfg.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the console image: %v", err)
+	}
+	defer f.Close()
+
+	manifest, err := remote.FindManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the manifest in the console image: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to set up the console image: %v", err)
+	}
+
+	// send in the configuration information
+	if cfg.Password != nil {
+		manifest.App.Environment.Set("CONSOLE_PASSWORD", *cfg.Password)
+	}
+	manifest.App.Environment.Set("CONSOLE_KEYS", strings.Join(cfg.SSHKeys, "\n"))
+
+	c, err := r.manager.Create("console", manifest, f, &container.CreateOptions{
+		Privileged: true,
+		Priority:   container.PriorityCritical,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the console: %v", err)
+	}
+	return c, nil
+}
+
+// consoleShell returns the command debug sessions run within the console.
+func (r *runner) consoleShell() []string {
+	if shell := r.config.Services.Console.Shell; shell != "" {
+		return []string{shell}
+	}
+	return []string{defaultConsoleShell}
+}
+
+// consoleSession launches the console for a debug shell requested through the
+// API, which the client then runs within it.
+func (r *runner) consoleSession() (*pb.ConsoleResponse, error) {
+	c, err := r.launchConsole()
+	if err != nil {
+		return nil, err
+	}
+	r.log.Info("Opening a debug shell within the console")
+	return &pb.ConsoleResponse{Uuid: c.UUID(), Shell: r.consoleShell()}, nil
+}
+
+// serveTerminal offers a debug shell on the terminal to those who enter the
+// console's password, prompting again each time the shell exits.
+func (r *runner) serveTerminal(path string) {
+	r.log.Infof("Offering debug shells on %s", path)
+	for {
+		if err := r.terminalSession(path); err != nil {
+			r.log.Warnf("Login prompt on %s failed: %v", path, err)
+			time.Sleep(terminalRetryInterval)
+		}
+	}
+}
+
+// terminalSession prompts for the password on the terminal, and runs a shell
+// within the console container on it if the password is correct.
+func (r *runner) terminalSession(path string) error {
+	f, err := openTerminal(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hostname, _ := os.Hostname()
+	fmt.Fprintf(f, "\r\n%s debug console\r\n", hostname)
+	if !r.login(f, path) {
+		return nil
+	}
+
+	c, err := r.launchConsole()
+	if err != nil {
+		fmt.Fprintf(f, "%v\r\n", err)
+		return err
+	}
+	r.log.Infof("Opening a debug shell on %s", path)
+	if _, err := c.Exec(r.consoleShell(), f, f, f); err != nil {
+		fmt.Fprintf(f, "%v\r\n", err)
+		return err
+	}
+	return nil
+}
+
+// login prompts for the console's password on the terminal, and returns
+// whether it was entered correctly. Anyone may log in if the console has no
+// password.
+func (r *runner) login(f *os.File, path string) bool {
+	expected := r.config.Services.Console.Password
+	if expected == nil {
+		return true
+	}
+	fmt.Fprint(f, "Password: ")
+	password, err := readPassword(f)
+	if err == nil && subtle.ConstantTimeCompare([]byte(password), []byte(*expected)) == 1 {
+		return true
+	}
+	r.log.Warnf("Failed login on %s", path)
+	time.Sleep(loginDelay)
+	fmt.Fprint(f, "Login incorrect\r\n")
+	return false
+}
+
+// terminalPath returns the path of the terminal device, which may be given by
+// its name within /dev.
+func terminalPath(device string) string {
+	if filepath.IsAbs(device) {
+		return device
+	}
+	return filepath.Join("/dev", device)
+}
+
+// openTerminal opens the terminal device without it becoming init's
+// controlling terminal.
+func openTerminal(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+}
+
+// readPassword reads a line from the terminal with its echo turned off.
+func readPassword(f *os.File) (string, error) {
+	termios, err := raw.TcGetAttr(f.Fd())
+	if err != nil {
+		return "", err
+	}
+	noecho := *termios
+	noecho.Lflag &^= syscall.ECHO
+	if err := raw.TcSetAttr(f.Fd(), &noecho); err != nil {
+		return "", err
+	}
+	defer raw.TcSetAttr(f.Fd(), termios)
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	fmt.Fprint(f, "\r\n")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -2,32 +2,306 @@
 
 package init
 
+import (
+	"time"
+
+	"github.com/apcera/kurma/stage1/server"
+)
+
 var (
-	// The setup functions that should be run in order to handle setting up the
-	// host system to create and manage containers. These functions focus
-	// primarily on runtime actions that must be done each time on boot.
-	setupFunctions = []func(*runner) error{
-		(*runner).startSignalHandling,
-		(*runner).createSystemMounts,
-		(*runner).loadConfigurationFile,
-		(*runner).configureLogging,
-		(*runner).configureEnvironment,
-		(*runner).mountCgroups,
-		(*runner).loadModules,
-		(*runner).launchManager,
-		(*runner).createDirectories,
-		(*runner).startUdev,
-		(*runner).mountDisks,
-		(*runner).cleanOldPods,
-		(*runner).configureHostname,
-		(*runner).configureNetwork,
-		(*runner).rootReadonly,
-		(*runner).setupDiscoveryProxy,
-		(*runner).startNTP,
-		(*runner).startServer,
-		(*runner).startInitContainers,
-		(*runner).displayNetwork,
-		(*runner).startConsole,
+	// The units that are run to set up the host system to create and manage
+	// containers. These focus primarily on runtime actions that must be done
+	// each time on boot. Units without an ordering between them are run in
+	// parallel.
+	// criticalUnits are the units without which the host can't be managed, so
+	// it enters emergency mode if any of them don't succeed.
+	criticalUnits = []string{"config", "manager", "server"}
+
+	// emergencyUnits are the only units run when emergency mode is requested
+	// on the kernel command line, which set up just enough to debug the host.
+	emergencyUnits = map[string]bool{
+		"signals":       true,
+		"system-mounts": true,
+		"config":        true,
+		"logging":       true,
+		"modules":       true,
+		"devices":       true,
+	}
+
+	// inheritedUnits are the units whose work outlives the process, such as
+	// the mounts, the disks, and the containers launched at boot. When kurma
+	// is exec'd by an upgrade, the earlier kurma has already run them, so
+	// they're treated as having succeeded rather than being run again.
+	inheritedUnits = map[string]bool{
+		"system-mounts":    true,
+		"cgroups":          true,
+		"modules":          true,
+		"udev":             true,
+		"disks":            true,
+		"storage-arrays":   true,
+		"encryption":       true,
+		"swap":             true,
+		"hugepages":        true,
+		"clean-pods":       true,
+		"crash-handling":   true,
+		"crash-collection": true,
+		"root-readonly":    true,
+		"ntp":              true,
+		"init-containers":  true,
+	}
+
+	setupUnits = []unit{
+		{name: "signals", run: (*runner).startSignalHandling},
+		{name: "system-mounts", run: (*runner).createSystemMounts},
+		{
+			name:     "config",
+			run:      (*runner).loadConfigurationFile,
+			requires: []string{"system-mounts"},
+		},
+		{
+			name:     "logging",
+			run:      (*runner).configureLogging,
+			requires: []string{"config"},
+			before:   []string{"devices", "manager", "network"},
+		},
+		{
+			name:     "log-sinks",
+			run:      (*runner).addLogSinks,
+			requires: []string{"logging"},
+			after:    []string{"network", "remote-config"},
+			before:   []string{"manager"},
+		},
+		{
+			name:   "environment",
+			run:    (*runner).configureEnvironment,
+			before: []string{"modules", "udev", "disks", "manager"},
+		},
+		{
+			name:     "cgroups",
+			run:      (*runner).mountCgroups,
+			requires: []string{"system-mounts"},
+			after:    []string{"config"},
+		},
+		{
+			name:     "modules",
+			run:      (*runner).loadModules,
+			requires: []string{"config"},
+		},
+		{
+			name:     "devices",
+			run:      (*runner).startDeviceManager,
+			requires: []string{"config"},
+			after:    []string{"environment", "modules"},
+			before:   []string{"disks", "network", "udev"},
+		},
+		{
+			name:     "manager",
+			run:      (*runner).launchManager,
+			requires: []string{"config", "cgroups"},
+			after:    []string{"modules"},
+		},
+		{
+			name:     "directories",
+			run:      (*runner).createDirectories,
+			requires: []string{"config"},
+			after:    []string{"manager"},
+		},
+		{
+			name:     "udev",
+			run:      (*runner).startUdev,
+			requires: []string{"manager"},
+		},
+		{
+			name:     "disks",
+			run:      (*runner).mountDisks,
+			requires: []string{"config"},
+			after:    []string{"directories", "udev"},
+		},
+		{
+			name:     "storage-arrays",
+			run:      (*runner).assembleStorageArrays,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "remote-config"},
+			before:   []string{"disks", "swap"},
+		},
+		{
+			name:     "encryption",
+			run:      (*runner).unlockEncryptedDevices,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "storage-arrays", "network", "remote-config"},
+			before:   []string{"disks", "swap"},
+		},
+		{
+			name:     "swap",
+			run:      (*runner).configureSwap,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "disks", "remote-config"},
+		},
+		{
+			name:     "hugepages",
+			run:      (*runner).configureHugepages,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "remote-config"},
+			before:   []string{"manager", "init-containers"},
+		},
+		{
+			name:     "clean-pods",
+			run:      (*runner).cleanOldPods,
+			requires: []string{"directories"},
+			after:    []string{"disks"},
+		},
+		{
+			name:     "garbage-collection",
+			run:      (*runner).startGarbageCollection,
+			requires: []string{"manager"},
+			after:    []string{"disks"},
+		},
+		{
+			name:     "image-preload",
+			run:      (*runner).preloadImages,
+			requires: []string{"garbage-collection"},
+			after:    []string{"image-keystore"},
+			before:   []string{"ntp", "server", "init-containers"},
+		},
+		{
+			name:     "hostname",
+			run:      (*runner).configureHostname,
+			requires: []string{"config"},
+		},
+		{
+			name:     "network",
+			run:      (*runner).configureNetwork,
+			requires: []string{"config"},
+			after:    []string{"hostname", "modules"},
+		},
+		{
+			name:     "sysctls",
+			run:      (*runner).applySysctls,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "network", "remote-config"},
+			before:   []string{"manager"},
+		},
+		{
+			name:     "crash-handling",
+			run:      (*runner).configureCrashHandling,
+			requires: []string{"config"},
+			after:    []string{"modules", "remote-config"},
+			before:   []string{"sysctls"},
+		},
+		{
+			name:     "crash-collection",
+			run:      (*runner).collectCrashes,
+			requires: []string{"config"},
+			after:    []string{"modules", "disks"},
+		},
+		{
+			name:     "remote-config",
+			run:      (*runner).loadRemoteConfiguration,
+			requires: []string{"config"},
+			after:    []string{"network"},
+			before: []string{"manager", "directories", "disks", "image-transport",
+				"image-keystore", "image-downloads", "registry-credentials", "root-readonly"},
+		},
+		{
+			name:  "root-readonly",
+			run:   (*runner).rootReadonly,
+			after: []string{"system-mounts", "cgroups", "directories", "disks", "network"},
+		},
+		{
+			name:     "measurement",
+			run:      (*runner).measureBoot,
+			requires: []string{"config"},
+			after:    []string{"modules", "devices", "remote-config"},
+			before:   []string{"manager", "server", "init-containers"},
+		},
+		{
+			name:     "image-transport",
+			run:      (*runner).configureImageTransport,
+			requires: []string{"config"},
+		},
+		{
+			name:     "image-keystore",
+			run:      (*runner).configureImageKeystore,
+			requires: []string{"config"},
+		},
+		{
+			name:     "registry-credentials",
+			run:      (*runner).configureRegistryCredentials,
+			requires: []string{"config"},
+		},
+		{
+			name:     "image-downloads",
+			run:      (*runner).configureImageDownloads,
+			requires: []string{"config"},
+			after:    []string{"disks"},
+		},
+		{
+			name:     "time-sync",
+			run:      (*runner).startTimeSync,
+			requires: []string{"config"},
+			after:    []string{"network", "remote-config"},
+		},
+		{
+			name:     "ntp",
+			run:      (*runner).startNTP,
+			requires: []string{"manager"},
+			after: []string{"network", "garbage-collection", "image-transport", "image-keystore",
+				"image-downloads", "registry-credentials"},
+		},
+		{
+			name:     "server",
+			run:      (*runner).startServer,
+			requires: []string{"manager"},
+			after: []string{"garbage-collection", "network", "time-sync", "image-transport",
+				"registry-credentials"},
+		},
+		{
+			name:     "container-output",
+			run:      (*runner).forwardContainerOutput,
+			requires: []string{"log-sinks", "manager"},
+		},
+		{
+			name:     "watchdog",
+			run:      (*runner).startWatchdog,
+			requires: []string{"config", "manager", "server"},
+		},
+		{
+			name:     "network-policy",
+			run:      (*runner).startNetworkPolicy,
+			requires: []string{"manager"},
+			after:    []string{"disks"},
+			before:   []string{"server", "init-containers"},
+		},
+		{
+			name:     "firewall",
+			run:      (*runner).startFirewall,
+			requires: []string{"config"},
+			after:    []string{"modules", "manager", "remote-config"},
+			before:   []string{"server", "init-containers", "console"},
+		},
+		{
+			name:     "registry",
+			run:      (*runner).startRegistry,
+			requires: []string{"config", "manager"},
+			after:    []string{"network", "server", "remote-config"},
+		},
+		{
+			name:     "init-containers",
+			run:      (*runner).startInitContainers,
+			requires: []string{"manager"},
+			after:    []string{"server", "time-sync", "ntp", "image-transport", "registry-credentials"},
+		},
+		{
+			name:  "display-network",
+			run:   (*runner).displayNetwork,
+			after: []string{"network", "init-containers"},
+		},
+		{
+			name:     "console",
+			run:      (*runner).startConsole,
+			requires: []string{"manager"},
+			after:    []string{"display-network"},
+		},
 	}
 )
 
@@ -38,6 +312,103 @@ const (
 	// The default location where cgroups should be mounted. This is a constant
 	// because it is referenced in multiple functions.
 	cgroupsMount = "/sys/fs/cgroup"
+
+	// defaultImageKeystore is the default directory holding the keys trusted to
+	// sign images.
+	defaultImageKeystore = "/etc/kurma/trustedkeys"
+
+	// defaultVolumesPath is the default directory where named volumes are
+	// stored.
+	defaultVolumesPath = kurmaPath + "/" + string(kurmaPathVolumes)
+
+	// defaultImagesPath is the default directory where retrieved images are
+	// cached.
+	defaultImagesPath = kurmaPath + "/" + string(kurmaPathImages)
+
+	// defaultDownloadsPath is the default directory where images are
+	// downloaded to while they're being used.
+	defaultDownloadsPath = kurmaPath + "/" + string(kurmaPathDownloads)
+
+	// defaultStoragePath is the default directory where the storage driver
+	// keeps the images extracted for containers to share.
+	defaultStoragePath = kurmaPath + "/" + string(kurmaPathStorage)
+
+	// The defaults for how often garbage collection runs, how long exited
+	// containers are kept, and how large the image cache may grow.
+	defaultGCIntervalMinutes    = 60
+	defaultExitedRetentionHours = 24
+	defaultImageCacheGB         = 10
+
+	// The defaults for the host uids and gids given to containers run in user
+	// namespaces, which allow for 1000 containers with 65536 IDs each.
+	defaultUserNamespaceStart  = 100000
+	defaultUserNamespaceLength = 1000 * 65536
+
+	// The defaults for how often an init container's readiness is checked, and
+	// how long it is given to become ready.
+	defaultReadinessIntervalSeconds = 1
+	defaultReadinessTimeoutSeconds  = 60
+
+	// defaultPanicTimeoutSeconds is how long the host waits after a kernel
+	// panic before rebooting.
+	defaultPanicTimeoutSeconds = 10
+
+	// outputPollInterval is how often the containers' output is checked for new
+	// lines to forward to the log sinks.
+	outputPollInterval = 250 * time.Millisecond
+
+	// The defaults for the hardware watchdog's device, and how long the host
+	// may go without it being petted before it is reset.
+	defaultWatchdogDevice         = "/dev/watchdog"
+	defaultWatchdogTimeoutSeconds = 60
+
+	// The default and shortest TTLs of the entries published to the
+	// registry. Consul doesn't allow sessions shorter than 10 seconds.
+	defaultRegistryTTLSeconds = 30
+	minRegistryTTLSeconds     = 10
+
+	// partitionTimeout is how long the node of a disk's new partition is
+	// waited for.
+	partitionTimeout = 10 * time.Second
+
+	// The default names of the partitions the system image is booted from,
+	// and how long an updated image is given for its containers to become
+	// healthy before the host rolls back.
+	defaultUpdatePartitionA           = "KURMA-A"
+	defaultUpdatePartitionB           = "KURMA-B"
+	defaultUpdateHealthTimeoutSeconds = 600
+
+	// defaultHugepagesMount is where the hugetlbfs of the kernel's default
+	// hugepage size is mounted.
+	defaultHugepagesMount = "/dev/hugepages"
+
+	// The defaults for the CPU, in millicores, and memory kept for kurma and
+	// the host's services, and for how far what's left is scaled for
+	// containers to reserve.
+	defaultSystemCPUMillis = 100
+	defaultSystemMemoryMB  = 256
+	defaultOvercommitRatio = 1.0
+
+	// The defaults for the memory pressure, in percent, and the available
+	// memory below which the host is short of memory, and for how often, in
+	// seconds, it is checked.
+	defaultEvictionPressure       = 40
+	defaultEvictionMinAvailableMB = 128
+	defaultEvictionIntervalSecs   = 10
+
+	// updateCheckInterval is how often the containers' health is checked
+	// while an updated image is on trial.
+	updateCheckInterval = 5 * time.Second
+
+	// defaultMeasurementPCR is the PCR kurma is measured into, which is one
+	// of those left to the operating system. measurementsFile is the log of
+	// what was measured, which outlives an upgrade but not a reboot.
+	defaultMeasurementPCR = 13
+	measurementsFile      = kurmaPath + "/measurements.json"
+
+	// defaultAPISocket is the unix socket the API is served on by default,
+	// alongside the local tcp listener.
+	defaultAPISocket = "unix://" + kurmaPath + "/kurma.sock"
 )
 
 // defaultConfiguration returns the default codified configuration that is
@@ -45,6 +416,27 @@ const (
 // loopback device will be configured for the network.
 func defaultConfiguration() *kurmaConfig {
 	return &kurmaConfig{
+		ImageKeystore: defaultImageKeystore,
+		Paths: kurmaPaths{
+			Volumes:   defaultVolumesPath,
+			Images:    defaultImagesPath,
+			Downloads: defaultDownloadsPath,
+			Storage:   defaultStoragePath,
+		},
+		GarbageCollection: kurmaGarbageCollection{
+			IntervalMinutes: defaultGCIntervalMinutes,
+			ImageCacheGB:    defaultImageCacheGB,
+		},
+		UserNamespaces: kurmaUserNamespaces{
+			UIDStart: defaultUserNamespaceStart,
+			GIDStart: defaultUserNamespaceStart,
+			Length:   defaultUserNamespaceLength,
+		},
+		Services: kurmaServices{
+			API: kurmaAPIService{
+				Listeners: []string{server.DefaultListener, defaultAPISocket},
+			},
+		},
 		NetworkConfig: kurmaNetworkConfig{
 			Interfaces: []*kurmaNetworkInterface{
 				&kurmaNetworkInterface{
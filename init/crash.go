@@ -0,0 +1,263 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/sysctl"
+	"github.com/apcera/util/proc"
+)
+
+const (
+	// pstorePath is where the records the kernel saved to persistent storage
+	// as it crashed are exposed.
+	pstorePath = "/sys/fs/pstore"
+
+	// crashNameFormat is the format of the time a crash's records were
+	// written, which names the directory they're collected into.
+	crashNameFormat = "20060102-150405"
+
+	// The files describing the memory reserved for the crash kernel, and
+	// whether one is loaded into it.
+	kexecCrashSizePath   = "/sys/kernel/kexec_crash_size"
+	kexecCrashLoadedPath = "/sys/kernel/kexec_crash_loaded"
+)
+
+// crashKernelParams are added to the host's kernel command line to boot the
+// crash kernel, which has to come up on a single CPU with the devices in
+// whatever state the crashed kernel left them.
+var crashKernelParams = []string{"irqpoll", "nr_cpus=1", "reset_devices"}
+
+// configureCrashHandling sets how the kernel reacts to a panic or an oops, and
+// loads the crash kernel if one is configured. Failing to load the crash kernel
+// is logged rather than failing the boot.
+func (r *runner) configureCrashHandling() error {
+	timeout := defaultPanicTimeoutSeconds
+	if t := r.config.Crash.PanicTimeoutSeconds; t != nil {
+		timeout = *t
+	}
+	if err := sysctl.Set("kernel.panic", strconv.Itoa(timeout)); err != nil {
+		return fmt.Errorf("failed to set the panic timeout: %v", err)
+	}
+	if oops := r.config.Crash.PanicOnOops; oops != nil {
+		value := "0"
+		if *oops {
+			value = "1"
+		}
+		if err := sysctl.Set("kernel.panic_on_oops", value); err != nil {
+			return fmt.Errorf("failed to set whether to panic on an oops: %v", err)
+		}
+	}
+
+	if r.config.Crash.CrashKernel != "" {
+		if err := r.loadCrashKernel(); err != nil {
+			r.log.Errorf("Failed to load the crash kernel: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadCrashKernel loads the crash kernel into the memory reserved for it, so
+// it is booted into if the host panics.
+func (r *runner) loadCrashKernel() error {
+	b, err := ioutil.ReadFile(kexecCrashSizePath)
+	if err != nil || strings.TrimSpace(string(b)) == "0" {
+		return fmt.Errorf("no memory is reserved for it, crashkernel= must be given on the kernel command line")
+	}
+	if b, err := ioutil.ReadFile(kexecCrashLoadedPath); err == nil && strings.TrimSpace(string(b)) == "1" {
+		r.log.Debug("The crash kernel is already loaded")
+		return nil
+	}
+
+	cmdline := r.config.Crash.CrashCmdline
+	if cmdline == "" {
+		b, err := ioutil.ReadFile("/proc/cmdline")
+		if err != nil {
+			return err
+		}
+		cmdline = crashCmdline(string(b))
+	}
+
+	args := []string{"--load-panic", r.config.Crash.CrashKernel, "--append=" + cmdline}
+	if r.config.Crash.CrashInitrd != "" {
+		args = append(args, "--initrd="+r.config.Crash.CrashInitrd)
+	}
+	if err := runCommand("kexec", args...); err != nil {
+		return err
+	}
+	r.log.Infof("Loaded crash kernel %s", r.config.Crash.CrashKernel)
+	return nil
+}
+
+// crashCmdline returns the command line for the crash kernel from the host's,
+// without the memory reservation, which only applies to the first kernel.
+func crashCmdline(cmdline string) string {
+	var params []string
+	for _, p := range strings.Fields(cmdline) {
+		if !strings.HasPrefix(p, "crashkernel=") {
+			params = append(params, p)
+		}
+	}
+	return strings.Join(append(params, crashKernelParams...), " ")
+}
+
+// collectCrashes moves the records of the last crash out of pstore, where space
+// is very limited, into a directory for the crash under /var/kurma/crash, which
+// is kept across reboots when a disk is used for it.
+func (r *runner) collectCrashes() error {
+	if err := mountPstore(); err != nil {
+		r.log.Warnf("Failed to mount pstore, crashes won't be collected: %v", err)
+		return nil
+	}
+
+	records, err := ioutil.ReadDir(pstorePath)
+	if err != nil {
+		return fmt.Errorf("failed to read the pstore records: %v", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	// the records are written as the kernel crashes, so the newest is about
+	// when it crashed
+	var crashed time.Time
+	for _, fi := range records {
+		if fi.ModTime().After(crashed) {
+			crashed = fi.ModTime()
+		}
+	}
+	dir := filepath.Join(kurmaPath, string(kurmaPathCrash), crashed.UTC().Format(crashNameFormat))
+	if err := os.MkdirAll(dir, os.FileMode(0700)); err != nil {
+		return fmt.Errorf("failed to create the crash directory: %v", err)
+	}
+
+	collected := 0
+	for _, fi := range records {
+		src := filepath.Join(pstorePath, fi.Name())
+		if err := copyFile(src, filepath.Join(dir, fi.Name())); err != nil {
+			r.log.Errorf("Failed to collect pstore record %s: %v", fi.Name(), err)
+			continue
+		}
+		// removing the record frees its space for the next crash
+		if err := os.Remove(src); err != nil {
+			r.log.Warnf("Failed to remove pstore record %s: %v", fi.Name(), err)
+		}
+		collected++
+	}
+	r.log.Warnf("The host crashed at %s, collected %d record(s) into %s",
+		crashed.UTC().Format(time.RFC3339), collected, dir)
+	return nil
+}
+
+// mountPstore mounts the pstore filesystem, unless it already is.
+func mountPstore() error {
+	mounts, err := proc.MountPoints()
+	if err != nil {
+		return err
+	}
+	if _, exists := mounts[pstorePath]; exists {
+		return nil
+	}
+	return handleMount("pstore", pstorePath, "pstore", 0, "")
+}
+
+// copyFile copies the contents of the file at src to a new file at dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// listCrashes returns the crashes which have been collected, from the most
+// recent, without the contents of their files.
+func (r *runner) listCrashes() (*pb.ListCrashesResponse, error) {
+	resp := &pb.ListCrashesResponse{}
+	dirs, err := ioutil.ReadDir(filepath.Join(kurmaPath, string(kurmaPathCrash)))
+	if os.IsNotExist(err) {
+		return resp, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range dirs {
+		if !fi.IsDir() {
+			continue
+		}
+		crash, err := readCrash(fi.Name(), false)
+		if err != nil {
+			r.log.Warnf("Failed to read crash %s: %v", fi.Name(), err)
+			continue
+		}
+		resp.Crashes = append(resp.Crashes, crash)
+	}
+	sort.Sort(sort.Reverse(crashesByTime(resp.Crashes)))
+	return resp, nil
+}
+
+// getCrash returns the named crash with the contents of its files.
+func (r *runner) getCrash(name string) (*pb.Crash, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid crash name %q", name)
+	}
+	crash, err := readCrash(name, true)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("crash %q was not found", name)
+	}
+	return crash, err
+}
+
+// readCrash reads the files of the crash, and their contents if data is set.
+func readCrash(name string, data bool) (*pb.Crash, error) {
+	dir := filepath.Join(kurmaPath, string(kurmaPathCrash), name)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	crash := &pb.Crash{Name: name}
+	if t, err := time.Parse(crashNameFormat, name); err == nil {
+		crash.Time = t.Unix()
+	}
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		file := &pb.CrashFile{Name: fi.Name(), Size: fi.Size()}
+		if data {
+			if file.Data, err = ioutil.ReadFile(filepath.Join(dir, fi.Name())); err != nil {
+				return nil, err
+			}
+		}
+		crash.Files = append(crash.Files, file)
+	}
+	return crash, nil
+}
+
+// crashesByTime sorts crashes from the oldest to the most recent.
+type crashesByTime []*pb.Crash
+
+func (s crashesByTime) Len() int           { return len(s) }
+func (s crashesByTime) Less(i, j int) bool { return s[i].Time < s[j].Time }
+func (s crashesByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,163 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// datasourceEC2 and datasourceGCE fetch the configuration from the user
+	// data given to the instance through the cloud's metadata service.
+	datasourceEC2 = "ec2"
+	datasourceGCE = "gce"
+
+	ec2UserDataURL = "http://169.254.169.254/latest/user-data"
+	gceUserDataURL = "http://169.254.169.254/computeMetadata/v1/instance/attributes/user-data"
+
+	// datasourceAttempts is how many times a datasource is tried before it is
+	// skipped, waiting datasourceRetryInterval after the first failure and
+	// twice as long after each one following it.
+	datasourceAttempts      = 5
+	datasourceRetryInterval = 2 * time.Second
+
+	// datasourceTimeout is how long each attempt to fetch from a datasource is
+	// given.
+	datasourceTimeout = 10 * time.Second
+)
+
+// errNoUserData is returned by a metadata service datasource when the instance
+// was given no user data, which is not retried.
+var errNoUserData = fmt.Errorf("no user data was given to the instance")
+
+// fetchRemoteConfiguration fetches the configuration from each of the
+// configured datasources, including any given on the kernel command line, and
+// merges them in order over the local configuration. A datasource is retried
+// if it can't be reached, and then skipped so the host still boots with the
+// rest of the configuration. When strict is set, an error is returned instead.
+func (r *runner) fetchRemoteConfiguration(cfg *kurmaConfig, strict bool) error {
+	sources := append([]string(nil), cfg.Datasources...)
+	for _, source := range sources {
+		r.log.Infof("Fetching configuration from %s", source)
+		remoteConfig, err := r.fetchDatasource(source)
+		switch {
+		case err == errNoUserData:
+			r.log.Debugf("- skipping %s: %v", source, err)
+		case err != nil && strict:
+			return fmt.Errorf("failed to fetch configuration from %s: %v", source, err)
+		case err != nil:
+			r.log.Errorf("Failed to fetch configuration from %s, continuing without it: %v", source, err)
+		default:
+			cfg.mergeConfig(remoteConfig)
+		}
+	}
+	return nil
+}
+
+// fetchDatasource fetches and parses the configuration from the datasource,
+// retrying while it can't be reached.
+func (r *runner) fetchDatasource(source string) (*kurmaConfig, error) {
+	var body []byte
+	var err error
+	interval := datasourceRetryInterval
+	for attempt := 1; attempt <= datasourceAttempts; attempt++ {
+		body, err = fetchDatasourceBody(source)
+		if err == nil || err == errNoUserData {
+			break
+		}
+		if attempt < datasourceAttempts {
+			r.log.Warnf("- failed to fetch %s, retrying in %s: %v", source, interval, err)
+			time.Sleep(interval)
+			interval *= 2
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config *kurmaConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %v", err)
+	}
+	return config, nil
+}
+
+// fetchDatasourceBody retrieves the configuration from an http or https URL,
+// or from a cloud's metadata service.
+func fetchDatasourceBody(source string) ([]byte, error) {
+	var req *http.Request
+	var err error
+	switch {
+	case source == datasourceEC2:
+		req, err = http.NewRequest("GET", ec2UserDataURL, nil)
+	case source == datasourceGCE:
+		req, err = http.NewRequest("GET", gceUserDataURL, nil)
+		if req != nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		req, err = http.NewRequest("GET", source, nil)
+	default:
+		return nil, fmt.Errorf("unrecognized datasource %q", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: datasourceTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && (source == datasourceEC2 || source == datasourceGCE) {
+		return nil, errNoUserData
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// loadRemoteConfiguration merges the configuration from the datasources once
+// the network is up. The settings used to bring the network up have already
+// been applied, so only the hostname, nameservers, and modules among them are
+// updated, while the other network settings take effect once they're given in
+// the local configuration.
+func (r *runner) loadRemoteConfiguration() error {
+	cfg := *r.config
+	cfg.NetworkConfig.DNS = append([]string(nil), r.config.NetworkConfig.DNS...)
+	cfg.Modules = append([]string(nil), r.config.Modules...)
+	cfg.InitContainers = append([]kurmaInitContainer(nil), r.config.InitContainers...)
+	if err := r.fetchRemoteConfiguration(&cfg, false); err != nil {
+		return err
+	}
+
+	if cfg.Hostname != r.config.Hostname && cfg.Hostname != "" {
+		r.log.Infof("Setting hostname: %s", cfg.Hostname)
+		if err := syscall.Sethostname([]byte(cfg.Hostname)); err != nil {
+			r.log.Errorf("- Failed to set hostname: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(cfg.NetworkConfig.DNS, r.config.NetworkConfig.DNS) {
+		if err := r.writeResolvConf(cfg.NetworkConfig.DNS, r.dnsSearch); err != nil {
+			r.log.Errorf("%v", err)
+		}
+	}
+	for _, mod := range cfg.Modules[len(r.config.Modules):] {
+		if err := loadModule(mod); err != nil {
+			r.log.Errorf("- %v", err)
+		}
+	}
+
+	*r.config = cfg
+	return nil
+}
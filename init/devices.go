@@ -0,0 +1,208 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/apcera/kurma/util/uevent"
+	"github.com/apcera/logray"
+)
+
+// defaultDeviceMode is the mode of device nodes whose event doesn't specify
+// one.
+const defaultDeviceMode = 0600
+
+// deviceManager maintains the nodes in /dev as devices are added and removed,
+// and loads the modules for the devices which need them.
+type deviceManager struct {
+	log *logray.Logger
+
+	modules chan moduleRequest
+
+	mutex   sync.Mutex
+	aliases map[string]bool
+}
+
+// moduleRequest is a device's alias for which to load a module. Done is called
+// once it has been loaded, if it is set.
+type moduleRequest struct {
+	alias string
+	done  func()
+}
+
+// startDeviceManager listens for the kernel's device events, and then replays
+// those of the devices which are already present. It returns once the modules
+// needed by the present devices have been loaded, so the devices the modules
+// add exist before the disks and network are configured.
+func (r *runner) startDeviceManager() error {
+	if e := r.config.Services.Devices.Enabled; e != nil && !*e {
+		r.log.Trace("Skipping the device manager")
+		return nil
+	}
+
+	l, err := uevent.Listen()
+	if err != nil {
+		return err
+	}
+	dm := &deviceManager{
+		log:     r.log.Clone(),
+		modules: make(chan moduleRequest, 256),
+		aliases: make(map[string]bool),
+	}
+	go dm.loadModules()
+	go dm.listen(l)
+
+	r.log.Info("Setting up devices")
+	events, err := uevent.Existing()
+	if err != nil {
+		r.log.Warnf("Failed to find all of the existing devices: %v", err)
+	}
+	var pending sync.WaitGroup
+	for _, e := range events {
+		dm.handle(e, &pending)
+	}
+	pending.Wait()
+	r.log.Debugf("Set up %d existing devices", len(events))
+	return nil
+}
+
+// listen handles the events received from the kernel.
+func (dm *deviceManager) listen(l *uevent.Listener) {
+	defer l.Close()
+	for {
+		e, err := l.Read()
+		if err != nil {
+			dm.log.Errorf("Stopped receiving device events: %v", err)
+			return
+		}
+		dm.handle(e, nil)
+	}
+}
+
+// handle creates or removes the device's node, and queues the device's module
+// to be loaded when it is added. If pending is set, it is done once the module
+// has been loaded.
+func (dm *deviceManager) handle(e *uevent.Event, pending *sync.WaitGroup) {
+	if devname := e.Env["DEVNAME"]; devname != "" {
+		var err error
+		switch e.Action {
+		case "add", "change":
+			err = createDeviceNode(devname, e.Env)
+		case "remove":
+			err = removeDeviceNode(devname)
+		}
+		if err != nil {
+			dm.log.Warnf("Failed to %s device node %s: %v", e.Action, devname, err)
+		}
+	}
+
+	if alias := e.Env["MODALIAS"]; alias != "" && e.Action == "add" {
+		dm.mutex.Lock()
+		seen := dm.aliases[alias]
+		dm.aliases[alias] = true
+		dm.mutex.Unlock()
+		if !seen {
+			req := moduleRequest{alias: alias}
+			if pending != nil {
+				pending.Add(1)
+				req.done = pending.Done
+			}
+			dm.modules <- req
+		}
+	}
+}
+
+// loadModules loads the modules for the queued aliases, one at a time. An alias
+// without a module isn't an error, since many devices need none.
+func (dm *deviceManager) loadModules() {
+	for req := range dm.modules {
+		if b, err := exec.Command("modprobe", "-q", "-b", req.alias).CombinedOutput(); err != nil {
+			dm.log.Tracef("No module loaded for %s: %v %s", req.alias, err, string(b))
+		}
+		if req.done != nil {
+			req.done()
+		}
+	}
+}
+
+// devicePath returns the path of the device node within /dev, refusing names
+// which would place it elsewhere.
+func devicePath(devname string) (string, error) {
+	path := filepath.Join("/dev", devname)
+	if !strings.HasPrefix(path, "/dev/") {
+		return "", fmt.Errorf("invalid device name")
+	}
+	return path, nil
+}
+
+// createDeviceNode creates the node for the device from its event's MAJOR,
+// MINOR, and DEVMODE properties, or updates its mode if it already exists, as it
+// does when devtmpfs created it.
+func createDeviceNode(devname string, env map[string]string) error {
+	path, err := devicePath(devname)
+	if err != nil {
+		return err
+	}
+	mode := uint32(defaultDeviceMode)
+	if s := env["DEVMODE"]; s != "" {
+		m, err := strconv.ParseUint(s, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode %q", s)
+		}
+		mode = uint32(m)
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		if env["DEVMODE"] == "" {
+			return nil
+		}
+		return os.Chmod(path, os.FileMode(mode))
+	}
+
+	major, err := strconv.ParseUint(env["MAJOR"], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid major number %q", env["MAJOR"])
+	}
+	minor, err := strconv.ParseUint(env["MINOR"], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid minor number %q", env["MINOR"])
+	}
+	nodeType := uint32(syscall.S_IFCHR)
+	if env["SUBSYSTEM"] == "block" {
+		nodeType = syscall.S_IFBLK
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+		return err
+	}
+	if err := syscall.Mknod(path, nodeType|mode, mkdev(major, minor)); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeDeviceNode removes the node for the device, if it exists.
+func removeDeviceNode(devname string) error {
+	path, err := devicePath(devname)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// mkdev encodes the major and minor numbers as a device number, as the kernel's
+// new_encode_dev does.
+func mkdev(major, minor uint64) int {
+	return int((minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32)
+}
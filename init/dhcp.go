@@ -0,0 +1,142 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apcera/kurma/util/dhcp"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// dhcpTimeout is how long to wait for a lease when configuring an interface.
+	dhcpTimeout = time.Minute
+
+	// dhcpRetryInterval is the minimum time between attempts to renew or
+	// re-acquire a lease.
+	dhcpRetryInterval = time.Minute
+)
+
+// acquireLease brings up the link, obtains a lease for it from a DHCP server,
+// and assigns the leased address.
+func acquireLease(link netlink.Link, hostname string) (*dhcp.Client, *dhcp.Lease, error) {
+	linkName := link.Attrs().Name
+
+	// the link must be up to send and receive the requests
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, nil, fmt.Errorf("failed to set link %s up: %v", linkName, err)
+	}
+	iface, err := net.InterfaceByIndex(link.Attrs().Index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := dhcp.NewClient(iface)
+	client.Hostname = hostname
+	lease, err := client.Acquire(dhcpTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: lease.Address}); err != nil {
+		return nil, nil, fmt.Errorf("failed to configure address %q: %v", lease.Address, err)
+	}
+	return client, lease, nil
+}
+
+// maintainLease renews the link's lease in the background for as long as the
+// host is running. If the lease expires before it could be renewed, the address
+// is removed and a new lease is acquired.
+func (r *runner) maintainLease(link netlink.Link, client *dhcp.Client, lease *dhcp.Lease) {
+	linkName := link.Attrs().Name
+
+	for {
+		time.Sleep(lease.Acquired.Add(lease.RenewalTime).Sub(time.Now()))
+
+		// retry until the lease expires, waiting half of the remaining time
+		// between attempts
+		var renewed *dhcp.Lease
+		for remaining := lease.Expires().Sub(time.Now()); remaining > 0; remaining = lease.Expires().Sub(time.Now()) {
+			var err error
+			timeout := remaining
+			if timeout > dhcpTimeout {
+				timeout = dhcpTimeout
+			}
+			if renewed, err = client.Renew(lease, timeout); err == nil {
+				break
+			}
+			r.log.Warnf("Failed to renew the DHCP lease on %s: %v", linkName, err)
+
+			wait := lease.Expires().Sub(time.Now()) / 2
+			if wait < dhcpRetryInterval {
+				wait = dhcpRetryInterval
+			}
+			time.Sleep(wait)
+		}
+
+		if renewed == nil {
+			r.log.Warnf("The DHCP lease for %s on %s has expired", lease.Address, linkName)
+			renewed = r.reacquireLease(link, client, lease)
+			// the expired lease's configuration was removed, so all of the new
+			// lease's needs to be applied
+			lease = &dhcp.Lease{}
+		}
+		r.applyLease(link, lease, renewed)
+		lease = renewed
+	}
+}
+
+// reacquireLease removes the expired lease's address from the link and retries
+// until a new lease is acquired.
+func (r *runner) reacquireLease(link netlink.Link, client *dhcp.Client, expired *dhcp.Lease) *dhcp.Lease {
+	linkName := link.Attrs().Name
+	if err := netlink.AddrDel(link, &netlink.Addr{IPNet: expired.Address}); err != nil {
+		r.log.Warnf("Failed to remove %s from %s: %v", expired.Address, linkName, err)
+	}
+
+	for {
+		lease, err := client.Acquire(dhcpTimeout)
+		if err == nil {
+			return lease
+		}
+		r.log.Warnf("Failed to acquire a DHCP lease on %s: %v", linkName, err)
+		time.Sleep(dhcpRetryInterval)
+	}
+}
+
+// applyLease updates the link's address and the default gateway when a new
+// lease differs from the previous one.
+func (r *runner) applyLease(link netlink.Link, old, lease *dhcp.Lease) {
+	linkName := link.Attrs().Name
+	r.log.Debugf("DHCP lease for %s on %s is valid until %s",
+		lease.Address, linkName, lease.Expires().Format(time.RFC3339))
+
+	if old.Address.String() != lease.Address.String() {
+		if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: lease.Address}); err != nil {
+			r.log.Warnf("Failed to configure address %q on %s: %v", lease.Address, linkName, err)
+			return
+		}
+		if old.Address != nil {
+			if err := netlink.AddrDel(link, &netlink.Addr{IPNet: old.Address}); err != nil {
+				r.log.Warnf("Failed to remove %s from %s: %v", old.Address, linkName, err)
+			}
+		}
+		r.log.Infof("Configured %s on %s", lease.Address, linkName)
+	}
+
+	// a configured gateway takes precedence over the lease's
+	if r.config.NetworkConfig.Gateway != "" || lease.Router == nil {
+		return
+	}
+	if old.Router != nil && old.Router.Equal(lease.Router) && old.Address.String() == lease.Address.String() {
+		return
+	}
+	if old.Router != nil {
+		netlink.RouteDel(&netlink.Route{Scope: netlink.SCOPE_UNIVERSE, Gw: old.Router})
+	}
+	if err := netlink.RouteAdd(&netlink.Route{Scope: netlink.SCOPE_UNIVERSE, Gw: lease.Router}); err != nil {
+		r.log.Warnf("Failed to configure gateway: %v", err)
+	}
+}
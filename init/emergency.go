@@ -0,0 +1,109 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultEmergencyShell is the shell run in emergency mode when none is
+// configured.
+const defaultEmergencyShell = "/bin/sh"
+
+// bootFailure returns why the host can't be managed if any of the critical
+// units didn't succeed, or an empty string if they all did.
+func (r *runner) bootFailure() string {
+	var reasons []string
+	for _, name := range criticalUnits {
+		if !r.units.succeeded(name) {
+			reasons = append(reasons, fmt.Sprintf("the %s unit did not succeed", name))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// startEmergencyMode offers emergency shells on each of the consoles, so the
+// host can be debugged when it can't be reached through the API.
+func (r *runner) startEmergencyMode(reason string) {
+	r.log.Errorf("Entering emergency mode, %s", reason)
+	consoles := r.config.Emergency.Consoles
+	if len(consoles) == 0 {
+		consoles = []string{"console"}
+	}
+	for _, c := range consoles {
+		go r.serveEmergencyShell(terminalPath(c), reason)
+	}
+}
+
+// serveEmergencyShell prompts on the console for a shell, or to reboot or power
+// off the host, prompting again each time the shell exits.
+func (r *runner) serveEmergencyShell(path, reason string) {
+	for {
+		if err := r.emergencySession(path, reason); err != nil {
+			r.log.Warnf("Emergency shell on %s failed: %v", path, err)
+			time.Sleep(terminalRetryInterval)
+		}
+	}
+}
+
+// emergencySession shows why the host entered emergency mode and which units
+// failed, and then runs the emergency shell once the console's password is
+// entered, or reboots or powers off the host if that is asked for instead.
+func (r *runner) emergencySession(path, reason string) error {
+	f, err := openTerminal(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "\r\nKurmaOS is in emergency mode, %s.\r\n", reason)
+	for _, u := range r.units.status() {
+		if u.State == string(unitFailed) || u.State == string(unitSkipped) {
+			fmt.Fprintf(f, "  %s %s: %s\r\n", u.Name, u.State, u.Error)
+		}
+	}
+	fmt.Fprint(f, "Press enter for a shell, or type reboot or poweroff: ")
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	switch strings.TrimSpace(line) {
+	case "":
+	case "reboot":
+		r.shutdown(true)
+		return nil
+	case "poweroff":
+		r.shutdown(false)
+		return nil
+	default:
+		return nil
+	}
+	if !r.login(f, path) {
+		return nil
+	}
+
+	shell := r.config.Emergency.Shell
+	if shell == "" {
+		shell = defaultEmergencyShell
+	}
+	cmd := exec.Command(shell)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = f, f, f
+	// the shell is given the console as its controlling terminal, so job
+	// control and ctrl-c work within it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(f, "Failed to run %s: %v\r\n", shell, err)
+		return err
+	}
+	r.log.Warnf("Opened an emergency shell on %s", path)
+
+	// init reaps its children as they exit, so the shell may already be gone
+	// by the time it is waited on
+	cmd.Wait()
+	return nil
+}
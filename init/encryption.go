@@ -0,0 +1,224 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util"
+	"github.com/apcera/kurma/util/gpt"
+)
+
+const (
+	// luksKeyParamPrefix is the prefix of the kernel command line parameters
+	// holding the keys of encrypted devices, following kurma.
+	luksKeyParamPrefix = "luks_key."
+
+	// secretsKeyParam is the kernel command line parameter, following kurma.,
+	// holding the key secrets are encrypted with.
+	secretsKeyParam = "secrets_key"
+
+	// The key sources which aren't a key server's URL.
+	keySourceCmdline = "cmdline"
+	keySourceTPM     = "tpm"
+)
+
+// unlockEncryptedDevices unlocks the encrypted devices with their keys,
+// formatting those which are blank, so the disks on them can be mounted. Each
+// one which fails is logged and reported in the host's encryption status, and
+// the disks on it are skipped.
+func (r *runner) unlockEncryptedDevices() error {
+	if len(r.config.EncryptedDevices) == 0 {
+		return nil
+	}
+
+	r.log.Info("Unlocking encrypted devices...")
+	for _, d := range r.config.EncryptedDevices {
+		if err := r.unlockEncryptedDevice(d); err != nil {
+			r.log.Errorf("- %v", err)
+			r.storageErrors["luks:"+d.Name] = err.Error()
+		}
+	}
+	return nil
+}
+
+// unlockEncryptedDevice maps the device to /dev/mapper with its key.
+func (r *runner) unlockEncryptedDevice(d *kurmaEncryptedDevice) error {
+	if d.Name == "" || strings.Contains(d.Name, "/") {
+		return fmt.Errorf("invalid encrypted device name %q", d.Name)
+	}
+	mapped := mappedDevicePath(d.Name)
+	if _, err := os.Stat(mapped); err == nil {
+		r.log.Debugf("- %s is already unlocked", d.Name)
+		return nil
+	}
+
+	device := util.ResolveDevice(d.Device)
+	if device == "" {
+		return fmt.Errorf("unable to resolve device %q of %s", d.Device, d.Name)
+	}
+	key, err := r.encryptionKey(d)
+	if err != nil {
+		return fmt.Errorf("failed to get the key of %s: %v", d.Name, err)
+	}
+
+	if err := runCommand("cryptsetup", "isLuks", device); err != nil {
+		blank, berr := gpt.IsBlank(device)
+		if berr != nil || !blank || (d.Format != nil && !*d.Format) {
+			return fmt.Errorf("%s is not a LUKS device", device)
+		}
+		r.log.Infof("- formatting %s with LUKS for %s", device, d.Name)
+		if err := runCommandWithInput(key, "cryptsetup", "luksFormat", "--batch-mode", "--key-file=-", device); err != nil {
+			return fmt.Errorf("failed to format %s: %v", device, err)
+		}
+	}
+
+	if err := runCommandWithInput(key, "cryptsetup", "luksOpen", "--key-file=-", device, d.Name); err != nil {
+		return fmt.Errorf("failed to unlock %s: %v", d.Name, err)
+	}
+	r.log.Infof("- unlocked %s at %s", d.Name, mapped)
+	return nil
+}
+
+// encryptionKey returns the key of the encrypted device from its key source.
+func (r *runner) encryptionKey(d *kurmaEncryptedDevice) ([]byte, error) {
+	return r.sourceKey(d.KeySource, d.TPMHandle, luksKeyParamPrefix+d.Name)
+}
+
+// sourceKey returns a key from its source: the kernel command line parameter
+// param, the TPM handle, or a key server.
+func (r *runner) sourceKey(source, tpmHandle, param string) ([]byte, error) {
+	switch {
+	case source == keySourceCmdline:
+		for _, p := range kernelParams() {
+			if p.name == param && p.value != "" {
+				return []byte(p.value), nil
+			}
+		}
+		return nil, fmt.Errorf("no %s%s parameter is on the kernel command line",
+			kernelParamPrefix, param)
+
+	case source == keySourceTPM:
+		if tpmHandle == "" {
+			return nil, fmt.Errorf("a TPM handle is required to unseal the key")
+		}
+		key, err := exec.Command("tpm2_unseal", "-c", tpmHandle).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to unseal the key from %s: %v", tpmHandle, err)
+		}
+		return key, nil
+
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		return r.fetchEncryptionKey(source)
+
+	default:
+		return nil, fmt.Errorf("unrecognized key source %q", source)
+	}
+}
+
+// fetchEncryptionKey fetches the key from the key server, retrying while it
+// can't be reached.
+func (r *runner) fetchEncryptionKey(url string) ([]byte, error) {
+	client := &http.Client{Timeout: datasourceTimeout}
+	interval := datasourceRetryInterval
+	var err error
+	for attempt := 1; attempt <= datasourceAttempts; attempt++ {
+		var resp *http.Response
+		resp, err = client.Get(url)
+		if err == nil {
+			var key []byte
+			key, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			switch {
+			case err != nil:
+			case resp.StatusCode != http.StatusOK:
+				err = fmt.Errorf("unexpected response: %s", resp.Status)
+			case len(key) == 0:
+				return nil, fmt.Errorf("the key server returned an empty key")
+			default:
+				return key, nil
+			}
+		}
+		if attempt < datasourceAttempts {
+			r.log.Warnf("- failed to fetch the key, retrying in %s: %v", interval, err)
+			time.Sleep(interval)
+			interval *= 2
+		}
+	}
+	return nil, err
+}
+
+// hostEncryption returns the status of the encrypted devices.
+func (r *runner) hostEncryption() *pb.HostEncryptionResponse {
+	resp := &pb.HostEncryptionResponse{}
+	for _, d := range r.config.EncryptedDevices {
+		status := encryptedDeviceStatus(d.Name)
+		status.KeySource = d.KeySource
+		if strings.Contains(d.KeySource, "://") {
+			status.KeySource = "key server"
+		}
+		if msg := r.storageErrors["luks:"+d.Name]; msg != "" {
+			status.Error = msg
+		}
+		resp.Devices = append(resp.Devices, status)
+	}
+	return resp
+}
+
+// encryptedDeviceStatus returns whether the device is unlocked, and if it is,
+// the device it is on and how it is encrypted, from cryptsetup's status.
+func encryptedDeviceStatus(name string) *pb.EncryptedDevice {
+	status := &pb.EncryptedDevice{Name: name}
+	out, err := exec.Command("cryptsetup", "status", name).Output()
+	if err != nil {
+		return status
+	}
+	status.Unlocked = true
+	status.MappedDevice = mappedDevicePath(name)
+	for _, line := range strings.Split(string(out), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch kv[0] {
+		case "type":
+			status.Type = value
+		case "cipher":
+			status.Cipher = value
+		case "keysize":
+			bits, _ := strconv.Atoi(strings.TrimSuffix(value, " bits"))
+			status.KeySize = int32(bits)
+		case "device":
+			status.Device = value
+		}
+	}
+	return status
+}
+
+// mappedDevicePath returns the path the named encrypted device is mapped to
+// once it is unlocked.
+func mappedDevicePath(name string) string {
+	return filepath.Join("/dev/mapper", name)
+}
+
+// runCommandWithInput runs the command with the input on its stdin, returning
+// its output in the error if it fails.
+func runCommandWithInput(input []byte, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apcera/kurma/stage1/server"
+	"github.com/apcera/kurma/util/firewall"
+)
+
+// sshPort is the port the console's SSH server listens on.
+const sshPort = 22
+
+// startFirewall has the host drop the traffic it is sent, other than that to
+// the ports its services are reached on and that from trusted interfaces.
+func (r *runner) startFirewall() error {
+	cfg := r.config.Firewall
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		r.log.Trace("Skipping the firewall")
+		return nil
+	}
+
+	config, err := r.firewallConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := firewall.Apply(config); err != nil {
+		return fmt.Errorf("failed to apply the firewall rules: %v", err)
+	}
+
+	ports := make([]string, len(config.Ports))
+	for i, p := range config.Ports {
+		ports[i] = p.String()
+	}
+	r.log.Infof("Firewall accepting traffic to %s", strings.Join(ports, ", "))
+	return nil
+}
+
+// firewallConfig returns the firewall's configuration, with the ports of the
+// host's services and the container network's bridge added to those
+// configured.
+func (r *runner) firewallConfig(cfg kurmaFirewall) (firewall.Config, error) {
+	config := firewall.Config{
+		Interfaces: cfg.TrustedInterfaces,
+		Rules:      cfg.Rules,
+		Rules6:     cfg.Rules6,
+	}
+	seen := make(map[firewall.Port]bool)
+	accept := func(p firewall.Port) {
+		if !seen[p] {
+			seen[p] = true
+			config.Ports = append(config.Ports, p)
+		}
+	}
+
+	listeners := append([]string{
+		r.config.Services.Metrics.Listener,
+		r.config.Services.Gateway.Listener,
+	}, r.config.Services.API.Listeners...)
+	if len(r.config.Services.API.Listeners) == 0 {
+		listeners = append(listeners, server.DefaultListener)
+	}
+	for _, l := range listeners {
+		if p, ok := listenerPort(l); ok {
+			accept(p)
+		}
+	}
+	if c := r.config.Services.Console; c.Enabled != nil && *c.Enabled {
+		accept(firewall.Port{Protocol: "tcp", Port: sshPort})
+	}
+	for _, s := range cfg.Ports {
+		p, err := firewall.ParsePort(s)
+		if err != nil {
+			return firewall.Config{}, fmt.Errorf("invalid firewall port: %v", err)
+		}
+		accept(p)
+	}
+
+	// containers reach the host's DNS and metadata services over the bridge,
+	// which network policies restrict instead
+	if n := r.network; n != nil && n.Bridged() {
+		config.Interfaces = append(append([]string(nil), config.Interfaces...), n.Bridge())
+	}
+	return config, nil
+}
+
+// listenerPort returns the port of a tcp:// listener, unless it is only on the
+// loopback address, which is always accepted.
+func listenerPort(listener string) (firewall.Port, bool) {
+	if listener == "" {
+		return firewall.Port{}, false
+	}
+	u, err := url.Parse(listener)
+	if err != nil || u.Scheme != "tcp" {
+		return firewall.Port{}, false
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return firewall.Port{}, false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() || host == "localhost" {
+		return firewall.Port{}, false
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return firewall.Port{}, false
+	}
+	return firewall.Port{Protocol: "tcp", Port: n}, true
+}
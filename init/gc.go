@@ -0,0 +1,97 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apcera/kurma/stage1/gc"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/util"
+	"github.com/apcera/kurma/util/remote"
+)
+
+// startGarbageCollection opens the cache of retrieved images and starts the
+// periodic cleanup of exited containers and cached images. If the cache can't
+// be opened, images are retrieved each time they're used.
+func (r *runner) startGarbageCollection() error {
+	images, err := image.NewStore(r.config.Paths.Images)
+	if err != nil {
+		r.log.Errorf("Failed to open the image cache, images will not be cached: %v", err)
+	} else {
+		r.images = images
+	}
+
+	cfg := r.config.GarbageCollection
+	retention := defaultExitedRetentionHours
+	if cfg.ExitedRetentionHours != nil {
+		retention = *cfg.ExitedRetentionHours
+	}
+	opts := &gc.Options{
+		ExitedRetention: time.Duration(retention) * time.Hour,
+		ImageCacheSize:  int64(cfg.ImageCacheGB) << 30,
+	}
+	if cfg.Enabled == nil || *cfg.Enabled {
+		opts.Interval = time.Duration(cfg.IntervalMinutes) * time.Minute
+	}
+
+	r.collector = gc.New(r.manager, r.images, opts)
+	r.collector.Log = r.log.Clone()
+	r.collector.Start()
+	return nil
+}
+
+// preloadImages imports the images from the configured directory into the
+// image store, mounting the device it is on if one is given.
+func (r *runner) preloadImages() error {
+	cfg := r.config.PreloadImages
+	if cfg.Directory == "" && cfg.Device == "" {
+		return nil
+	}
+	if r.images == nil {
+		r.log.Warn("Skipping preloading images, the image store isn't open")
+		return nil
+	}
+
+	dir := cfg.Directory
+	if cfg.Device != "" {
+		device := util.ResolveDevice(cfg.Device)
+		if device == "" {
+			r.log.Warnf("Unable to resolve image preload device %q, skipping", cfg.Device)
+			return nil
+		}
+		fstype, _ := util.GetFsType(device)
+		mountPoint := filepath.Join(mountPath, "preload")
+		if err := handleMount(device, mountPoint, fstype, syscall.MS_RDONLY, ""); err != nil {
+			r.log.Errorf("Failed to mount image preload device %q: %v", device, err)
+			return nil
+		}
+		defer func() {
+			if err := syscall.Unmount(mountPoint, 0); err != nil {
+				r.log.Warnf("Failed to unmount image preload device %q: %v", device, err)
+			}
+		}()
+		dir = filepath.Join(mountPoint, cfg.Directory)
+	}
+
+	r.log.Infof("Preloading images from %s", dir)
+	imported, err := r.images.Preload(dir)
+	for _, img := range imported {
+		r.log.Debugf("Preloaded image %s", img.URI)
+	}
+	if err != nil {
+		r.log.Errorf("Failed to preload images: %v", err)
+	}
+	return nil
+}
+
+// retrieveImage retrieves the image from the URI, using the image cache once
+// it has been opened.
+func (r *runner) retrieveImage(uri string, insecure bool) (remote.ReaderCloserSeeker, error) {
+	if r.images == nil {
+		return remote.RetrieveImage(uri, insecure)
+	}
+	return r.images.Retrieve(uri, insecure)
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
+	"github.com/apcera/kurma/util/hugepages"
+	"github.com/apcera/util/proc"
+)
+
+// configureHugepages reserves the configured number of hugepages of each size
+// and mounts a hugetlbfs for each, so that processes on the host can map them.
+// The pages are reserved at boot, before memory becomes too fragmented for them
+// to be found, and are then given to containers by their hugepages isolator. A
+// size which can't be reserved is logged and skipped.
+func (r *runner) configureHugepages() error {
+	if len(r.config.Hugepages.Pages) == 0 {
+		return nil
+	}
+
+	r.log.Info("Reserving hugepages...")
+	defaultSize, err := hugepages.DefaultSize()
+	if err != nil {
+		return fmt.Errorf("failed to check the kernel's hugepage size: %v", err)
+	}
+	existingMounts, err := proc.MountPoints()
+	if err != nil {
+		return fmt.Errorf("failed to read existing mount points: %v", err)
+	}
+	mount := r.config.Hugepages.Mount
+	if mount == "" {
+		mount = defaultHugepagesMount
+	}
+
+	names := make([]string, 0, len(r.config.Hugepages.Pages))
+	for name := range r.config.Hugepages.Pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		count := r.config.Hugepages.Pages[name]
+		q, err := resource.ParseQuantity(name)
+		if err != nil {
+			r.log.Errorf("- invalid hugepage size %q: %v", name, err)
+			continue
+		}
+		size := q.Value()
+		if !hugepages.Supported(size) {
+			r.log.Errorf("- %s hugepages are not supported by the host", name)
+			continue
+		}
+
+		reserved, err := hugepages.Reserve(size, count)
+		if err != nil {
+			r.log.Errorf("- failed to reserve %s hugepages: %v", name, err)
+			continue
+		}
+		if reserved < count {
+			r.log.Warnf("- only %d of %d %s hugepages could be reserved", reserved, count, name)
+		} else {
+			r.log.Debugf("- reserved %d %s hugepages", reserved, name)
+		}
+
+		location := hugepages.MountPath(mount, size, defaultSize)
+		if _, exists := existingMounts[location]; exists {
+			r.log.Tracef("- skipping %q, already mounted", location)
+			continue
+		}
+		data := fmt.Sprintf("pagesize=%d", size)
+		if err := handleMount("hugetlbfs", location, "hugetlbfs", 0, data); err != nil {
+			r.log.Errorf("- failed to mount %s hugepages at %s: %v", name, location, err)
+		}
+	}
+	return nil
+}
@@ -3,9 +3,17 @@
 package init
 
 import (
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/gc"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/stage1/netpolicy"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/registry"
+	"github.com/apcera/kurma/stage1/upgrade"
+	"github.com/apcera/kurma/util/tpm"
 	"github.com/apcera/logray"
 )
 
@@ -16,28 +24,109 @@ type runner struct {
 	config  *kurmaConfig
 	log     *logray.Logger
 	manager *container.Manager
+
+	images    *image.Store
+	collector *gc.Collector
+	units     *unitGraph
+	clock     *timeSync
+	registry  *registry.Publisher
+	network   *network.Network
+	policies  *netpolicy.Engine
+
+	// console is the console container, which is launched at boot or when a
+	// debug shell is first requested. consoleMutex serializes launching it.
+	console      *container.Container
+	consoleMutex sync.Mutex
+
+	// storageErrors are why the RAID arrays, volume groups, and encrypted
+	// devices which failed at boot couldn't be set up, keyed by md:NAME,
+	// vg:NAME, and luks:NAME. It is set before the API server is started.
+	storageErrors map[string]string
+
+	// dnsSearch is the search domain from the DHCP leases, which is kept when
+	// the nameservers are reconfigured.
+	dnsSearch string
+
+	// apiToken authenticates the init process to the API when clients are
+	// required to authenticate. It is set before the API server is started.
+	apiToken string
+
+	// measurements are what was measured into the TPM, since the host booted.
+	// It is set before the API server is started.
+	measurements []*tpm.Event
+
+	// updateMutex serializes writing system images and checking the booted
+	// one, and trialDeadline is when the host rolls back unless the booted
+	// image is confirmed healthy, while it is on trial.
+	updateMutex   sync.Mutex
+	trialDeadline time.Time
+
+	// reloadMutex serializes reloading the configuration, which is refused
+	// until the host has been set up.
+	reloadMutex  sync.Mutex
+	setupDone    bool
+	shutdownOnce sync.Once
 }
 
 // Run takes over the process and launches KurmaOS.
 func Run() error {
 	r := &runner{
-		config: defaultConfiguration(),
-		log:    logray.New(),
+		config:        defaultConfiguration(),
+		log:           logray.New(),
+		storageErrors: make(map[string]string),
 	}
 	return r.Run()
 }
 
 // Run handles executing the bootstrap setup. This prepares the current host
-// environment to run and manage containers. It will return an error if any part
-// of the setup fails.
+// environment to run and manage containers. The setup units are run until each
+// has finished, and an error is returned if any of them failed. Units which
+// require a failed unit are skipped, while the rest of the host is still set
+// up. If a critical unit doesn't succeed, or emergency mode was requested on
+// the kernel command line, emergency shells are offered on the consoles. When
+// init was exec'd by an upgrade, the units whose work outlives the earlier
+// process aren't run again. An updated system image which is on trial is then
+// confirmed or rolled back from.
 func (r *runner) Run() error {
-	r.log.Info("Launching KurmaOS\n\n")
-
-	for _, f := range setupFunctions {
-		if err := f(r); err != nil {
-			r.log.Errorf("ERROR: %v", err)
-			return fmt.Errorf("%v: %v", f, err)
+	started := time.Now()
+	units := setupUnits
+	upgraded := upgrade.Upgraded()
+	forced := !upgraded && emergencyRequested(kernelParams())
+	if upgraded {
+		r.log.Info("Resuming KurmaOS after an upgrade\n\n")
+		units = make([]unit, len(setupUnits))
+		copy(units, setupUnits)
+		for i := range units {
+			if inheritedUnits[units[i].name] {
+				units[i].run = func(*runner) error { return nil }
+			}
+		}
+	} else {
+		r.log.Info("Launching KurmaOS\n\n")
+	}
+	if forced {
+		units = nil
+		for _, u := range setupUnits {
+			if emergencyUnits[u.name] {
+				units = append(units, u)
+			}
 		}
 	}
-	return nil
+	r.units = newUnitGraph(units)
+	err := r.units.run(r)
+
+	r.reloadMutex.Lock()
+	r.setupDone = true
+	r.reloadMutex.Unlock()
+
+	if forced {
+		r.startEmergencyMode("it was requested on the kernel command line")
+		return err
+	}
+	reason := r.bootFailure()
+	if reason != "" {
+		r.startEmergencyMode(reason)
+	}
+	go r.checkSystemImage(started, reason)
+	return err
 }
@@ -0,0 +1,195 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/util/remote"
+	"github.com/appc/spec/schema/types"
+)
+
+// startInitContainers adds a unit for each of the initial containers that are
+// specified in the configuration.
+func (r *runner) startInitContainers() error {
+	ics := r.config.InitContainers
+	indices := make([]int, len(ics))
+	for i := range ics {
+		indices[i] = i
+	}
+	return r.units.add(r.initContainerUnits(ics, indices)...)
+}
+
+// initContainerUnits returns the units for the init containers at the indices
+// within the configured init containers. A container's unit requires the units
+// of the containers it depends on, which are only finished once those
+// containers are ready. Containers with an invalid name are given failed units.
+func (r *runner) initContainerUnits(ics []kurmaInitContainer, indices []int) []*unit {
+	depended := make(map[string]bool)
+	for _, ic := range ics {
+		for _, dep := range ic.DependsOn {
+			depended[dep] = true
+		}
+	}
+	include := make(map[int]bool)
+	for _, i := range indices {
+		include[i] = true
+	}
+
+	units := make([]*unit, 0, len(indices))
+	names := make(map[string]bool)
+	for i := range ics {
+		ic := &ics[i]
+		u := &unit{
+			name: fmt.Sprintf("container:%s#%d", ic.Image, i+1),
+			run: func(r *runner) error {
+				return r.runInitContainer(ic, depended[ic.Name])
+			},
+		}
+		if ic.Name != "" {
+			if _, err := types.NewACName(ic.Name); err != nil {
+				u.state, u.err = unitFailed, fmt.Errorf("invalid name %q: %v", ic.Name, err)
+			} else if names[ic.Name] {
+				u.state, u.err = unitFailed, fmt.Errorf("%s is the name of another init container", ic.Name)
+			} else {
+				names[ic.Name] = true
+				u.name = initContainerUnit(ic.Name)
+			}
+		}
+		if !include[i] {
+			continue
+		}
+		if u.state == unitFailed {
+			r.log.Errorf("Not starting init container %s: %v", ic.Image, u.err)
+			u.started = time.Now()
+			u.finished = u.started
+		}
+		for _, dep := range ic.DependsOn {
+			u.requires = append(u.requires, initContainerUnit(dep))
+		}
+		units = append(units, u)
+	}
+	return units
+}
+
+// initContainerUnit returns the name of the unit for the named init container.
+func initContainerUnit(name string) string {
+	return "container:" + name
+}
+
+// runInitContainer launches the init container. If other containers depend on
+// it, it then waits for the container to become ready.
+func (r *runner) runInitContainer(ic *kurmaInitContainer, depended bool) error {
+	c, err := r.launchInitContainer(ic)
+	if err != nil {
+		return err
+	}
+	if !depended {
+		return nil
+	}
+	if err := waitForReadiness(c, ic.Readiness); err != nil {
+		return fmt.Errorf("the container did not become ready: %v", err)
+	}
+	r.log.Infof("Init container %s is ready", ic.Name)
+	return nil
+}
+
+// launchInitContainer retrieves the init container's image and creates the
+// container from it.
+func (r *runner) launchInitContainer(ic *kurmaInitContainer) (*container.Container, error) {
+	img := ic.Image
+	f, err := r.retrieveImage(img, ic.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image %q: %v", img, err)
+	}
+	defer f.Close()
+	if ic.ImageHash != "" {
+		if err := remote.VerifyHash(f, ic.ImageHash); err != nil {
+			return nil, fmt.Errorf("failed to verify image %q: %v", img, err)
+		}
+	}
+	r.manager.Publish(&container.Event{Type: container.EventImagePulled, Image: img})
+
+	manifest, err := remote.FindManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find manifest in image %q: %v", img, err)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to set up %q: %v", img, err)
+	}
+
+	opts := &container.CreateOptions{
+		StopGracePeriod: time.Duration(ic.StopGracePeriod) * time.Second,
+		Privileged:      ic.Privileged,
+		Priority:        container.PriorityCritical,
+	}
+	if ic.Priority != "" {
+		opts.Priority = container.Priority(ic.Priority)
+	}
+	c, err := r.manager.Create(ic.Name, manifest, f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch container %s: %v", manifest.Name.String(), err)
+	}
+	r.log.Infof("Launched container %s", manifest.Name.String())
+	return c, nil
+}
+
+// waitForReadiness waits for the container to pass its readiness check, or for
+// its apps to be running if it has none. It fails if the container stops or the
+// check's timeout passes first.
+func waitForReadiness(c *container.Container, check *kurmaReadinessCheck) error {
+	interval := defaultReadinessIntervalSeconds * time.Second
+	timeout := defaultReadinessTimeoutSeconds * time.Second
+	if check != nil && check.IntervalSeconds > 0 {
+		interval = time.Duration(check.IntervalSeconds) * time.Second
+	}
+	if check != nil && check.TimeoutSeconds > 0 {
+		timeout = time.Duration(check.TimeoutSeconds) * time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	err := fmt.Errorf("its apps are not running")
+	for {
+		switch c.State() {
+		case container.RUNNING:
+			if err = readinessCheck(c, check, interval); err == nil {
+				return nil
+			}
+		case container.STOPPING, container.STOPPED, container.EXITED:
+			return fmt.Errorf("the container is no longer running")
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s: %v", timeout, err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// readinessCheck runs the readiness check against the running container. A TCP
+// connection is given the interval to succeed.
+func readinessCheck(c *container.Container, check *kurmaReadinessCheck, interval time.Duration) error {
+	if check == nil {
+		return nil
+	}
+	if len(check.Exec) > 0 {
+		code, err := c.Exec(check.Exec, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("%s exited with %d", check.Exec[0], code)
+		}
+	}
+	if check.TCP != "" {
+		conn, err := net.DialTimeout("tcp", check.TCP, interval)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+	return nil
+}
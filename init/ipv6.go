@@ -0,0 +1,104 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// The modes for configuring an interface's IPv6 address.
+const (
+	ipv6SLAAC  = "slaac"
+	ipv6DHCPv6 = "dhcpv6"
+)
+
+// configureIPv6 configures the link to take its IPv6 address from router
+// advertisements or a DHCPv6 server, and brings it up. In either mode, the
+// default route is taken from router advertisements.
+func configureIPv6(link netlink.Link, mode string) error {
+	linkName := link.Attrs().Name
+
+	var autoconf string
+	switch mode {
+	case ipv6SLAAC:
+		autoconf = "1"
+	case ipv6DHCPv6:
+		autoconf = "0"
+	default:
+		return fmt.Errorf("unknown mode %q, must be %q or %q", mode, ipv6SLAAC, ipv6DHCPv6)
+	}
+
+	// Router advertisements are ignored by default when forwarding is enabled,
+	// as it is for the container network, so they are explicitly accepted.
+	sysctls := []struct{ name, value string }{
+		{"disable_ipv6", "0"},
+		{"accept_ra", "2"},
+		{"autoconf", autoconf},
+	}
+	for _, sysctl := range sysctls {
+		path := filepath.Join("/proc/sys/net/ipv6/conf", linkName, sysctl.name)
+		if err := ioutil.WriteFile(path, []byte(sysctl.value+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to set link up: %v", err)
+	}
+
+	if mode == ipv6DHCPv6 {
+		cmd := exec.Command("udhcpc6", "-i", linkName, "-t", "20", "-n")
+		cmd.Stdin = nil
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("DHCPv6 failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// addDefaultRoute adds the default route via the gateway. IPv6 gateways are
+// often link-local addresses, which must specify the interface as a zone, such
+// as fe80::1%eth0.
+func addDefaultRoute(gateway string) error {
+	ip := parseZonedIP(gateway)
+	if ip == nil {
+		return fmt.Errorf("invalid address")
+	}
+
+	route := &netlink.Route{
+		Scope: netlink.SCOPE_UNIVERSE,
+		Gw:    ip,
+	}
+	if i := strings.Index(gateway, "%"); i >= 0 {
+		link, err := netlink.LinkByName(gateway[i+1:])
+		if err != nil {
+			return err
+		}
+		route.LinkIndex = link.Attrs().Index
+	} else if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("link-local gateways must specify the interface, such as %s%%eth0", ip)
+	}
+	return netlink.RouteAdd(route)
+}
+
+// parseZonedIP parses an IPv4 or IPv6 address, which may be followed by a zone
+// naming an interface. It returns nil if the address is invalid.
+func parseZonedIP(s string) net.IP {
+	if i := strings.Index(s, "%"); i >= 0 {
+		if i == len(s)-1 {
+			return nil
+		}
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/logray"
+
+	// registers the outputs the log sinks are created with
+	_ "github.com/apcera/kurma/util/logsink"
+)
+
+// jsonConsoleOutput is the console's output when its format is json.
+const jsonConsoleOutput = "console://?format=json"
+
+// configureLogging sets the level and format of the console's logs, for both
+// the loggers created from now on and the init process's own.
+func (r *runner) configureLogging() error {
+	if l := r.config.Logging.Level; l != "" {
+		if _, err := logray.ParseLogClass(l); err != nil {
+			r.log.Warnf("Invalid log level %q, using %s", l, logray.INFOPLUS)
+		}
+	}
+
+	level := r.logLevel()
+	switch r.config.Logging.Format {
+	case "", "text":
+		logray.ResetDefaultLogLevel(level)
+	case "json":
+		logray.ResetDefaultOutput()
+		if err := logray.AddDefaultOutput(jsonConsoleOutput, level); err != nil {
+			return err
+		}
+		r.log.ResetOutput()
+		r.log.AddOutput(jsonConsoleOutput, level)
+	default:
+		r.log.Warnf("Unknown log format %q, using text", r.config.Logging.Format)
+		logray.ResetDefaultLogLevel(level)
+	}
+	return nil
+}
+
+// addLogSinks adds the configured sinks as outputs of the loggers created from
+// now on and the init process's own. Sinks which are invalid are logged and
+// skipped.
+func (r *runner) addLogSinks() error {
+	for _, sink := range r.config.Logging.Sinks {
+		uri, class, err := parseLogSink(sink, r.logLevel())
+		if err == nil {
+			err = logray.AddDefaultOutput(uri, class)
+		}
+		if err != nil {
+			r.log.Errorf("Failed to add log sink %q: %v", sink, err)
+			continue
+		}
+		r.log.AddOutput(uri, class)
+	}
+	return nil
+}
+
+// logLevel returns the configured log level, or everything in debug mode.
+func (r *runner) logLevel() logray.LogClass {
+	if r.config.Debug {
+		return logray.ALL
+	}
+	if class, err := logray.ParseLogClass(r.config.Logging.Level); err == nil {
+		return class
+	}
+	return logray.INFOPLUS
+}
+
+// parseLogSink removes the level parameter from the sink's URL, returning the
+// URL of the output and the level, which defaults to the one given.
+func parseLogSink(sink string, level logray.LogClass) (string, logray.LogClass, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return "", 0, err
+	}
+	values := u.Query()
+	if l := values.Get("level"); l != "" {
+		level, err = logray.ParseLogClass(l)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid level %q", l)
+		}
+	}
+	delete(values, "level")
+	u.RawQuery = values.Encode()
+	return u.String(), level, nil
+}
+
+// forwardContainerOutput sends each line the containers write to the log
+// sinks, tagged with the container and the stream it was written to.
+func (r *runner) forwardContainerOutput() error {
+	if e := r.config.Logging.ContainerOutput; e == nil || !*e || len(r.config.Logging.Sinks) == 0 {
+		return nil
+	}
+
+	// the output only goes to the sinks, not the console
+	log := logray.New()
+	log.ResetOutput()
+	for _, sink := range r.config.Logging.Sinks {
+		uri, class, err := parseLogSink(sink, r.logLevel())
+		if err != nil {
+			continue
+		}
+		if err := log.AddOutput(uri, class); err != nil {
+			r.log.Errorf("Failed to forward container output to %q: %v", sink, err)
+		}
+	}
+
+	f := &outputForwarder{
+		log:     log,
+		manager: r.manager,
+		tailers: make(map[string]chan struct{}),
+	}
+	events, _ := r.manager.Subscribe()
+	for _, c := range r.manager.Containers() {
+		f.follow(c)
+	}
+	go f.run(events)
+	return nil
+}
+
+// outputForwarder follows the output of each container from when it is started
+// until it is destroyed.
+type outputForwarder struct {
+	log     *logray.Logger
+	manager *container.Manager
+
+	// tailers are closed to stop following the container with the UUID.
+	tailers map[string]chan struct{}
+}
+
+// run follows the containers as they're started and stops following them once
+// they're destroyed.
+func (f *outputForwarder) run(events <-chan *container.Event) {
+	for e := range events {
+		switch e.Type {
+		case container.EventStarted:
+			if c := f.manager.Container(e.UUID); c != nil {
+				f.follow(c)
+			}
+		case container.EventDestroyed:
+			if stop, ok := f.tailers[e.UUID]; ok {
+				close(stop)
+				delete(f.tailers, e.UUID)
+			}
+		}
+	}
+}
+
+// follow begins forwarding the container's output, unless it is already being
+// followed. Only output captured by the json-file log driver can be followed.
+func (f *outputForwarder) follow(c *container.Container) {
+	if _, ok := f.tailers[c.UUID()]; ok {
+		return
+	}
+	logs, err := c.OpenLogs()
+	if err != nil {
+		return
+	}
+	stop := make(chan struct{})
+	f.tailers[c.UUID()] = stop
+
+	log := f.log.Clone()
+	log.SetField("container", c.UUID())
+	go forwardOutput(logs, log, stop)
+}
+
+// forwardOutput logs each line of the container's output, from the oldest
+// which is kept, until it is stopped. The output is polled for new lines.
+func forwardOutput(logs *container.LogReader, log *logray.Logger, stop <-chan struct{}) {
+	defer logs.Close()
+
+	streams := make(map[string]*logray.Logger)
+	for _, stream := range []string{"stdout", "stderr"} {
+		streams[stream] = log.Clone()
+		streams[stream].SetField("stream", stream)
+	}
+
+	ticker := time.NewTicker(outputPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			entry, err := logs.Next()
+			if err != nil {
+				break
+			}
+			if l, ok := streams[entry.Stream]; ok {
+				l.Info(strings.TrimSuffix(entry.Log, "\n"))
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/vishvananda/netlink"
+)
+
+// The types of network devices which can be created at boot.
+const (
+	netdevBond   = "bond"
+	netdevVlan   = "vlan"
+	netdevBridge = "bridge"
+)
+
+// netdevOrder is the order devices are created in, so that each device's
+// slaves or parent already exist. This allows vlans on a bond and bridges
+// across vlans.
+var netdevOrder = map[string]int{
+	netdevBond:   0,
+	netdevVlan:   1,
+	netdevBridge: 2,
+}
+
+// createNetworkDevices creates the devices declared in the network config.
+// Devices which already exist are left as is, and failures are logged so the
+// remaining devices and interfaces are still configured.
+func (r *runner) createNetworkDevices() {
+	var devices []*kurmaNetworkInterface
+	for _, netconf := range r.config.NetworkConfig.Interfaces {
+		if netconf.Type != "" {
+			devices = append(devices, netconf)
+		}
+	}
+	sort.Stable(byNetdevOrder(devices))
+
+	for _, netconf := range devices {
+		if _, err := netlink.LinkByName(netconf.Device); err == nil {
+			r.log.Debugf("Network device %s already exists", netconf.Device)
+			continue
+		}
+
+		var err error
+		switch netconf.Type {
+		case netdevBond:
+			err = createBond(netconf)
+		case netdevVlan:
+			err = createVlan(netconf)
+		case netdevBridge:
+			err = createBridge(netconf)
+		default:
+			err = fmt.Errorf("unknown type %q", netconf.Type)
+		}
+		if err != nil {
+			r.log.Warnf("Failed to create %s %s: %v", netconf.Type, netconf.Device, err)
+			continue
+		}
+		r.log.Infof("Created %s %s", netconf.Type, netconf.Device)
+	}
+}
+
+// createBond creates the bond and enslaves its slaves. The bond's settings can
+// only be changed while it has no slaves, so they're applied first.
+func createBond(netconf *kurmaNetworkInterface) error {
+	if len(netconf.Slaves) == 0 {
+		return fmt.Errorf("no slaves specified")
+	}
+
+	bond := &netlink.Generic{
+		LinkAttrs: netlink.LinkAttrs{Name: netconf.Device},
+		LinkType:  netdevBond,
+	}
+	if err := netlink.LinkAdd(bond); err != nil {
+		// loading the bonding module may create a bond with the same name
+		if _, lerr := netlink.LinkByName(netconf.Device); lerr != nil {
+			return err
+		}
+	}
+	link, err := netlink.LinkByName(netconf.Device)
+	if err != nil {
+		return err
+	}
+
+	if netconf.BondMode != "" {
+		if err := writeBondSetting(netconf.Device, "mode", netconf.BondMode); err != nil {
+			return err
+		}
+	}
+	if netconf.BondMiimon > 0 {
+		if err := writeBondSetting(netconf.Device, "miimon", fmt.Sprintf("%d", netconf.BondMiimon)); err != nil {
+			return err
+		}
+	}
+
+	// slaves must be down to be enslaved, and are brought up by the bond
+	return enslave(link, netconf.Slaves, true)
+}
+
+// writeBondSetting sets one of the bond's settings through sysfs.
+func writeBondSetting(bond, name, value string) error {
+	path := filepath.Join("/sys/class/net", bond, "bonding", name)
+	if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to set %s to %q: %v", name, value, err)
+	}
+	return nil
+}
+
+// createVlan creates the 802.1q sub-interface of the parent.
+func createVlan(netconf *kurmaNetworkInterface) error {
+	if netconf.Parent == "" {
+		return fmt.Errorf("no parent specified")
+	}
+	if netconf.VlanID < 1 || netconf.VlanID > 4094 {
+		return fmt.Errorf("invalid vlan id %d", netconf.VlanID)
+	}
+
+	parent, err := netlink.LinkByName(netconf.Parent)
+	if err != nil {
+		return fmt.Errorf("failed to find parent %s: %v", netconf.Parent, err)
+	}
+	vlan := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{Name: netconf.Device, ParentIndex: parent.Attrs().Index},
+		VlanId:    netconf.VlanID,
+	}
+	if err := netlink.LinkAdd(vlan); err != nil {
+		return err
+	}
+
+	// the vlan can't pass traffic unless its parent is up
+	return netlink.LinkSetUp(parent)
+}
+
+// createBridge creates the bridge and attaches its ports.
+func createBridge(netconf *kurmaNetworkInterface) error {
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: netconf.Device}}
+	if err := netlink.LinkAdd(bridge); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(netconf.Device)
+	if err != nil {
+		return err
+	}
+	return enslave(link, netconf.Slaves, false)
+}
+
+// enslave sets the master of each of the named links. Bond slaves must be
+// down when enslaved, while bridge ports must be brought up afterwards.
+func enslave(master netlink.Link, slaves []string, bond bool) error {
+	for _, name := range slaves {
+		slave, err := netlink.LinkByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to find %s: %v", name, err)
+		}
+		if bond {
+			if err := netlink.LinkSetDown(slave); err != nil {
+				return fmt.Errorf("failed to set %s down: %v", name, err)
+			}
+		}
+		if err := netlink.LinkSetMasterByIndex(slave, master.Attrs().Index); err != nil {
+			return fmt.Errorf("failed to enslave %s: %v", name, err)
+		}
+		if !bond {
+			if err := netlink.LinkSetUp(slave); err != nil {
+				return fmt.Errorf("failed to set %s up: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// byNetdevOrder sorts devices by the order they need to be created in.
+type byNetdevOrder []*kurmaNetworkInterface
+
+func (s byNetdevOrder) Len() int      { return len(s) }
+func (s byNetdevOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byNetdevOrder) Less(i, j int) bool {
+	return netdevOrder[s[i].Type] < netdevOrder[s[j].Type]
+}
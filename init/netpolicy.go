@@ -0,0 +1,58 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"path/filepath"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/netpolicy"
+)
+
+// startNetworkPolicy programs the rules for the saved network policies on the
+// container network, and reprograms them as containers come and go so the
+// policies follow their addresses.
+func (r *runner) startNetworkPolicy() error {
+	n := r.network
+	if n == nil {
+		r.log.Trace("Skipping network policies, there is no container network")
+		return nil
+	}
+	if !n.Bridged() {
+		// traffic on the LAN never passes through the host's rules
+		r.log.Tracef("Skipping network policies, they aren't supported in the %s mode", n.Mode())
+		return nil
+	}
+
+	e, err := netpolicy.New(netpolicy.Config{
+		Path:   filepath.Join(kurmaPath, "netpolicies.json"),
+		Bridge: n.Bridge(),
+		IPv6:   n.Subnet6() != nil,
+	}, netpolicy.ManagerMembers(r.manager))
+	if err != nil {
+		return err
+	}
+	e.Log = r.log.Clone()
+	e.Log.SetField("service", "netpolicy")
+
+	// a failure here is retried whenever a container changes, and policies
+	// can still be managed, so it doesn't fail the unit
+	if err := e.Start(); err != nil {
+		r.log.Errorf("Failed to program the network policies: %v", err)
+	}
+
+	events, _ := r.manager.Subscribe()
+	go func() {
+		for ev := range events {
+			switch ev.Type {
+			case container.EventCreated, container.EventStarted, container.EventExited,
+				container.EventStopped, container.EventDestroyed:
+				e.Wake()
+			}
+		}
+	}()
+
+	r.policies = e
+	r.log.Infof("Applying %d network policies on %s", len(e.Policies()), n.Bridge())
+	return nil
+}
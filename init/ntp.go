@@ -0,0 +1,178 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/ntp"
+	"github.com/apcera/kurma/util/sysctl"
+)
+
+const (
+	// ntpTimeout is how long each NTP server is given to respond.
+	ntpTimeout = 5 * time.Second
+
+	// defaultNTPInterval is how often the clock is synchronized after boot when
+	// no interval is configured.
+	defaultNTPInterval = 30 * time.Minute
+
+	// ntpStepThreshold is the largest offset which is slewed when the clock is
+	// periodically synchronized. Larger offsets are stepped, since slewing them
+	// would take too long.
+	ntpStepThreshold = 500 * time.Millisecond
+)
+
+// defaultNTPServers are used when none are configured.
+var defaultNTPServers = []string{
+	"0.pool.ntp.org",
+	"1.pool.ntp.org",
+	"2.pool.ntp.org",
+	"3.pool.ntp.org",
+}
+
+// timeSync is the status of synchronizing the clock with the NTP servers.
+type timeSync struct {
+	mutex    sync.Mutex
+	servers  []string
+	synced   bool
+	server   string
+	offset   time.Duration
+	lastSync time.Time
+	err      error
+}
+
+// startTimeSync steps the clock from the NTP servers with the built-in client,
+// and then periodically slews it to keep it synchronized. If no server can be
+// reached, the host still boots and the clock is set on the next attempt.
+func (r *runner) startTimeSync() error {
+	cfg := r.config.Services.NTP
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		r.log.Trace("Skipping NTP")
+		return nil
+	}
+	if cfg.Enabled != nil && cfg.ACI != "" {
+		r.log.Trace("Skipping the NTP client, the NTP container sets the clock")
+		return nil
+	}
+
+	interval := defaultNTPInterval
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil || d <= 0 {
+			r.log.Warnf("Invalid NTP interval %q, using %s", cfg.Interval, defaultNTPInterval)
+		} else {
+			interval = d
+		}
+	}
+	servers := cfg.Servers
+	if len(servers) == 0 {
+		servers = defaultNTPServers
+	}
+
+	r.clock = &timeSync{servers: servers}
+	r.log.Info("Updating system clock via NTP...")
+	r.syncClock(true)
+
+	go func() {
+		for range time.Tick(interval) {
+			r.syncClock(false)
+		}
+	}()
+	return nil
+}
+
+// syncClock queries the NTP servers in order until one responds, and corrects
+// the clock by its offset. The clock is stepped if step is set or the offset is
+// too large to slew.
+func (r *runner) syncClock(step bool) {
+	var resp *ntp.Response
+	var err error
+	for _, server := range r.clock.servers {
+		resp, err = ntp.Query(server, ntpTimeout)
+		if err == nil {
+			break
+		}
+		r.log.Debugf("- failed to query NTP server %s: %v", server, err)
+	}
+	if err != nil {
+		r.log.Errorf("Failed to synchronize the clock, no NTP server responded: %v", err)
+		r.clock.mutex.Lock()
+		r.clock.err = err
+		r.clock.mutex.Unlock()
+		return
+	}
+
+	offset := resp.Offset
+	if step || offset > ntpStepThreshold || offset < -ntpStepThreshold {
+		err = ntp.Step(offset)
+		if err == nil {
+			r.log.Infof("Stepped the clock by %s from %s", offset, resp.Server)
+		}
+	} else {
+		err = ntp.Slew(offset)
+		if err == nil {
+			r.log.Debugf("Slewing the clock by %s from %s", offset, resp.Server)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to adjust the clock by %s: %v", offset, err)
+		r.log.Errorf("%v", err)
+	}
+
+	r.clock.mutex.Lock()
+	defer r.clock.mutex.Unlock()
+	r.clock.err = err
+	if err == nil {
+		r.clock.synced = true
+		r.clock.server = resp.Server
+		r.clock.offset = offset
+		r.clock.lastSync = time.Now()
+	}
+}
+
+// status returns the status of synchronizing the clock. It is nil if the
+// built-in client isn't used.
+func (t *timeSync) status() *pb.TimeSync {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	ts := &pb.TimeSync{
+		Synchronized: t.synced,
+		Servers:      t.servers,
+		Server:       t.server,
+		Offset:       int64(t.offset),
+	}
+	if !t.lastSync.IsZero() {
+		ts.LastSync = t.lastSync.UnixNano()
+	}
+	if t.err != nil {
+		ts.Error = t.err.Error()
+	}
+	return ts
+}
+
+// hostInfo describes the host, including the status of synchronizing its clock.
+func (r *runner) hostInfo() *pb.HostInfoResponse {
+	info := &pb.HostInfoResponse{
+		Time:     time.Now().UnixNano(),
+		TimeSync: r.clock.status(),
+	}
+	info.Hostname, _ = os.Hostname()
+
+	if release, err := sysctl.KernelRelease(); err == nil {
+		info.Kernel = release
+	}
+	var si syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&si); err == nil {
+		info.Uptime = int64(si.Uptime)
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/registry"
+)
+
+// startRegistry publishes the host and its containers to the configured etcd
+// or Consul store until the host is shut down. The entries are published again
+// right away when a container changes, rather than on the next round.
+func (r *runner) startRegistry() error {
+	cfg := r.config.Services.Registry
+	if cfg.Backend == "" {
+		r.log.Trace("Skipping the registry")
+		return nil
+	}
+
+	store, err := registry.NewStore(cfg.Backend, cfg.Endpoint)
+	if err != nil {
+		return err
+	}
+	ttl := cfg.TTLSeconds
+	if ttl == 0 {
+		ttl = defaultRegistryTTLSeconds
+	} else if ttl < minRegistryTTLSeconds {
+		r.log.Warnf("The registry's TTL of %ds is too short, using %ds", ttl, minRegistryTTLSeconds)
+		ttl = minRegistryTTLSeconds
+	}
+	address := cfg.Address
+	if address == "" {
+		if address, err = hostAddress(); err != nil {
+			r.log.Warnf("Failed to find the host's address to publish to the registry: %v", err)
+		}
+	}
+
+	node := nodeName(r.config.Cluster)
+	p := registry.NewPublisher(store, cfg.Prefix, time.Duration(ttl)*time.Second,
+		registry.ManagerEntries(r.manager, node, address))
+	p.Log = r.log.Clone()
+	p.Log.SetField("service", "registry")
+
+	events, _ := r.manager.Subscribe()
+	go func() {
+		for e := range events {
+			switch e.Type {
+			case container.EventCreated, container.EventStarted, container.EventExited,
+				container.EventStopped, container.EventDestroyed,
+				container.EventHealthy, container.EventUnhealthy:
+				p.Wake()
+			}
+		}
+	}()
+
+	r.registry = p
+	p.Start()
+	r.log.Infof("Publishing to the %s registry as node %s with a %ds TTL", cfg.Backend, node, ttl)
+	return nil
+}
+
+// hostAddress returns the host's first non-loopback IPv4 address.
+func hostAddress() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+			continue
+		}
+		return ipnet.IP.String(), nil
+	}
+	return "", fmt.Errorf("the host has no IPv4 address")
+}
@@ -0,0 +1,287 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/firewall"
+	"github.com/apcera/kurma/util/remote"
+)
+
+// startReloadSignalHandling reloads the configuration each time init receives
+// SIGHUP.
+func (r *runner) startReloadSignalHandling() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			r.log.Info("Received SIGHUP, reloading the configuration")
+			if _, err := r.reloadConfig(); err != nil {
+				r.log.Errorf("Failed to reload the configuration: %v", err)
+			}
+		}
+	}()
+}
+
+// reloadConfig re-reads the configuration, including that from the
+// datasources, and applies the changes from the running configuration which can
+// be made without a reboot: init containers which were added are started,
+// modules which were added are loaded, sysctls which were added or changed are
+// set, the hostname and nameservers are updated, the firewall's rules and the
+// cluster's peers are replaced. Any other changes are reported as requiring a reboot, and remain
+// pending in later reloads until then. The reload fails if a datasource can't
+// be fetched, so its settings aren't mistaken for having been removed.
+func (r *runner) reloadConfig() (*pb.ReloadConfigResponse, error) {
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+	if !r.setupDone {
+		return nil, fmt.Errorf("the host is still being set up")
+	}
+
+	cfg, err := r.readConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fetchRemoteConfiguration(cfg, true); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ReloadConfigResponse{}
+	applied := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		r.log.Infof("Reload: %s", msg)
+		resp.Applied = append(resp.Applied, msg)
+	}
+	restart := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		r.log.Warnf("Reload: %s, which requires a reboot", msg)
+		resp.RequiresRestart = append(resp.RequiresRestart, msg)
+	}
+	failed := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		r.log.Errorf("Reload: %s", msg)
+		resp.Failed = append(resp.Failed, msg)
+	}
+
+	// hostname
+	if cfg.Hostname != r.config.Hostname {
+		switch {
+		case cfg.Hostname == "":
+			restart("the hostname was removed")
+		default:
+			if err := syscall.Sethostname([]byte(cfg.Hostname)); err != nil {
+				failed("failed to set the hostname to %s: %v", cfg.Hostname, err)
+			} else {
+				applied("set the hostname to %s", cfg.Hostname)
+				r.config.Hostname = cfg.Hostname
+			}
+		}
+	}
+
+	// nameservers
+	if !reflect.DeepEqual(cfg.NetworkConfig.DNS, r.config.NetworkConfig.DNS) {
+		switch {
+		case len(cfg.NetworkConfig.DNS) == 0:
+			restart("the nameservers were removed")
+		default:
+			if err := r.reloadResolvConf(cfg.NetworkConfig.DNS); err != nil {
+				failed("%v", err)
+			} else {
+				applied("set the nameservers to %s", strings.Join(cfg.NetworkConfig.DNS, ", "))
+				r.config.NetworkConfig.DNS = cfg.NetworkConfig.DNS
+			}
+		}
+	}
+
+	// modules
+	loaded := make(map[string]bool)
+	for _, mod := range r.config.Modules {
+		loaded[mod] = true
+	}
+	wanted := make(map[string]bool)
+	for _, mod := range cfg.Modules {
+		wanted[mod] = true
+		if loaded[mod] {
+			continue
+		}
+		if err := loadModule(mod); err != nil {
+			failed("%v", err)
+			continue
+		}
+		applied("loaded module %s", mod)
+		loaded[mod] = true
+		r.config.Modules = append(r.config.Modules, mod)
+	}
+	for _, mod := range r.config.Modules {
+		if !wanted[mod] {
+			restart("module %s was removed", mod)
+		}
+	}
+
+	// sysctls
+	r.reloadSysctls(cfg.Sysctls, applied, restart, failed)
+
+	// init containers
+	r.reloadInitContainers(cfg.InitContainers, applied, restart, failed)
+
+	// firewall
+	if !reflect.DeepEqual(cfg.Firewall, r.config.Firewall) {
+		switch {
+		case cfg.Firewall.Enabled != nil && !*cfg.Firewall.Enabled:
+			restart("the firewall was disabled")
+		default:
+			if err := r.reloadFirewall(cfg.Firewall); err != nil {
+				failed("%v", err)
+			} else {
+				applied("updated the firewall rules")
+				r.config.Firewall = cfg.Firewall
+			}
+		}
+	}
+
+	// registry credentials
+	if !reflect.DeepEqual(cfg.Registries, r.config.Registries) {
+		remote.SetCredentials(cfg.Registries)
+		r.config.Registries = cfg.Registries
+		applied("updated the registry credentials")
+	}
+
+	// cluster
+	if !reflect.DeepEqual(cfg.Cluster, r.config.Cluster) {
+		r.config.Cluster = cfg.Cluster
+		applied("updated the cluster's name and peers")
+	}
+
+	// everything else only takes effect when the host is set up
+	current, updated := *r.config, *cfg
+	current.Hostname, updated.Hostname = "", ""
+	current.NetworkConfig.DNS, updated.NetworkConfig.DNS = nil, nil
+	current.Modules, updated.Modules = nil, nil
+	current.Sysctls, updated.Sysctls = nil, nil
+	current.InitContainers, updated.InitContainers = nil, nil
+	current.Cluster, updated.Cluster = kurmaCluster{}, kurmaCluster{}
+	current.Registries, updated.Registries = nil, nil
+	current.Firewall, updated.Firewall = kurmaFirewall{}, kurmaFirewall{}
+	cv, uv := reflect.ValueOf(current), reflect.ValueOf(updated)
+	for i := 0; i < cv.NumField(); i++ {
+		if !reflect.DeepEqual(cv.Field(i).Interface(), uv.Field(i).Interface()) {
+			name := strings.Split(cv.Type().Field(i).Tag.Get("json"), ",")[0]
+			restart("%s was changed", name)
+		}
+	}
+
+	return resp, nil
+}
+
+// reloadInitContainers starts the init containers which aren't in the running
+// configuration, and waits for them to be launched. Those which were removed or
+// changed require a reboot.
+func (r *runner) reloadInitContainers(ics []kurmaInitContainer, applied, restart, failed func(string, ...interface{})) {
+	running := r.config.InitContainers
+	matched := make(map[int]bool)
+	names := make(map[string]bool)
+	for _, ic := range running {
+		if ic.Name != "" {
+			names[ic.Name] = true
+		}
+	}
+
+	var added []int
+	for i, ic := range ics {
+		found := false
+		for j := range running {
+			if !matched[j] && reflect.DeepEqual(ic, running[j]) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		switch {
+		case found:
+		case ic.Name != "" && names[ic.Name]:
+			restart("init container %s was changed", ic.Name)
+		default:
+			added = append(added, i)
+		}
+	}
+	for j, ic := range running {
+		if !matched[j] && (ic.Name == "" || !namedIn(ics, ic.Name)) {
+			restart("init container %s was removed", initContainerLabel(&ic))
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	units := r.initContainerUnits(ics, added)
+	if err := r.units.add(units...); err != nil {
+		failed("failed to start the new init containers: %v", err)
+		return
+	}
+	r.units.run(r)
+
+	for n, u := range units {
+		ic := ics[added[n]]
+		if !r.units.succeeded(u.name) {
+			failed("init container %s did not start: %v", initContainerLabel(&ic), u.err)
+			continue
+		}
+		applied("started init container %s", initContainerLabel(&ic))
+		r.config.InitContainers = append(r.config.InitContainers, ic)
+	}
+}
+
+// reloadFirewall replaces the firewall's rules with those for the updated
+// configuration.
+func (r *runner) reloadFirewall(cfg kurmaFirewall) error {
+	config, err := r.firewallConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := firewall.Apply(config); err != nil {
+		return fmt.Errorf("failed to apply the firewall rules: %v", err)
+	}
+	return nil
+}
+
+// reloadResolvConf rewrites resolv.conf with the nameservers, keeping the
+// search domain from the DHCP leases. The root filesystem is made writable
+// while it is rewritten if it was made read only.
+func (r *runner) reloadResolvConf(dns []string) error {
+	if r.units.succeeded("root-readonly") {
+		if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT, ""); err != nil {
+			return fmt.Errorf("failed to make the root filesystem writable: %v", err)
+		}
+		defer func() {
+			if err := r.rootReadonly(); err != nil {
+				r.log.Errorf("Failed to make the root filesystem read only again: %v", err)
+			}
+		}()
+	}
+	return r.writeResolvConf(dns, r.dnsSearch)
+}
+
+// namedIn returns whether one of the init containers has the name.
+func namedIn(ics []kurmaInitContainer, name string) bool {
+	for _, ic := range ics {
+		if ic.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// initContainerLabel returns how the init container is referred to, which is
+// its name if it has one and otherwise its image.
+func initContainerLabel(ic *kurmaInitContainer) string {
+	if ic.Name != "" {
+		return ic.Name
+	}
+	return ic.Image
+}
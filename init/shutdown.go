@@ -0,0 +1,117 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/apcera/util/proc"
+)
+
+// processGracePeriod is how long the processes which are still running after
+// the containers have been stopped are given to exit before they are killed.
+const processGracePeriod = 5 * time.Second
+
+// startShutdownSignalHandling has the kernel deliver ctrl-alt-del to init as
+// SIGINT rather than rebooting immediately, and handles the signals which
+// request that the host be shut down. SIGINT reboots the host, while SIGTERM
+// and SIGPWR power it off.
+func (r *runner) startShutdownSignalHandling() {
+	if err := syscall.Reboot(syscall.LINUX_REBOOT_CMD_CAD_OFF); err != nil {
+		r.log.Warnf("Failed to disable the ctrl-alt-del reboot: %v", err)
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGPWR)
+	go func() {
+		sig := <-ch
+		r.log.Infof("Received %s", sig)
+		r.shutdown(sig == syscall.SIGINT)
+	}()
+}
+
+// shutdown stops the containers, unmounts the filesystems, and then powers off
+// or reboots the host. Only the first call takes effect, and it does not
+// return unless the host could not be powered off.
+func (r *runner) shutdown(reboot bool) {
+	r.shutdownOnce.Do(func() {
+		if reboot {
+			r.log.Info("Rebooting KurmaOS")
+		} else {
+			r.log.Info("Shutting down KurmaOS")
+		}
+
+		// the host is removed from the registry first, so it isn't found
+		// while its containers are stopped
+		if r.registry != nil {
+			r.registry.Stop()
+		}
+		if r.manager != nil {
+			r.manager.Shutdown()
+		}
+		r.killProcesses()
+
+		syscall.Sync()
+		r.unmountFilesystems()
+		syscall.Sync()
+
+		cmd := syscall.LINUX_REBOOT_CMD_POWER_OFF
+		if reboot {
+			cmd = syscall.LINUX_REBOOT_CMD_RESTART
+		}
+		if err := syscall.Reboot(cmd); err != nil {
+			r.log.Errorf("Failed to power off the host: %v", err)
+		}
+	})
+}
+
+// killProcesses sends SIGTERM to all of the remaining processes, such as the
+// console and NTP, and then kills any which haven't exited after the grace
+// period.
+func (r *runner) killProcesses() {
+	if err := syscall.Kill(-1, syscall.SIGTERM); err != nil {
+		if err == syscall.ESRCH {
+			return
+		}
+		r.log.Warnf("Failed to signal the remaining processes: %v", err)
+	}
+	time.Sleep(processGracePeriod)
+	syscall.Kill(-1, syscall.SIGKILL)
+}
+
+// unmountFilesystems unmounts everything other than the root filesystem in the
+// reverse of the order it was mounted, so that mounts are removed before their
+// parents. Filesystems which are still busy are remounted read only instead,
+// as is the root filesystem.
+func (r *runner) unmountFilesystems() {
+	mountPoints := make([]string, 0, 100)
+	err := proc.ParseSimpleProcFile(
+		proc.MountProcFile,
+		nil,
+		func(line int, index int, elem string) error {
+			if index == 1 && elem != "/" {
+				mountPoints = append(mountPoints, elem)
+			}
+			return nil
+		})
+	if err != nil {
+		r.log.Errorf("Failed to read the mount points: %v", err)
+	}
+
+	for i := len(mountPoints) - 1; i >= 0; i-- {
+		if err := syscall.Unmount(mountPoints[i], 0); err == nil {
+			continue
+		}
+		err := syscall.Mount("", mountPoints[i], "", syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+		if err != nil {
+			r.log.Debugf("Failed to unmount %q: %v", mountPoints[i], err)
+		}
+	}
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		r.log.Warnf("Failed to remount the root filesystem read only: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/apcera/kurma/util"
+)
+
+const (
+	// swapFlagPrefer and swapFlagPrioMask are the swapon flags which set the
+	// priority of the swap space.
+	swapFlagPrefer   = 0x8000
+	swapFlagPrioMask = 0x7fff
+
+	// zramControlPath is where zram devices are added on kernels which support
+	// adding them on demand.
+	zramControlPath = "/sys/class/zram-control/hot_add"
+)
+
+// configureSwap creates and enables the configured swap spaces. Each one which
+// fails is logged and skipped, so the host still boots without it.
+func (r *runner) configureSwap() error {
+	if len(r.config.Swap) == 0 {
+		return nil
+	}
+
+	active, err := activeSwaps()
+	if err != nil {
+		r.log.Warnf("Failed to check the enabled swap spaces: %v", err)
+	}
+
+	r.log.Info("Setting up swap...")
+	for _, swap := range r.config.Swap {
+		if err := swap.validate(); err != nil {
+			r.log.Errorf("- invalid swap configuration: %v", err)
+			continue
+		}
+
+		var path string
+		switch {
+		case swap.Device != "":
+			path = util.ResolveDevice(swap.Device)
+			if path == "" {
+				r.log.Warnf("- unable to resolve device %q, skipping", swap.Device)
+				continue
+			}
+		case swap.File != "":
+			path = swap.File
+			if err := createSwapFile(path, swap.SizeMB); err != nil {
+				r.log.Errorf("- failed to create swap file %s: %v", path, err)
+				continue
+			}
+		case swap.Zram:
+			path, err = createZramDevice(swap.SizeMB, swap.Compression)
+			if err != nil {
+				r.log.Errorf("- failed to create the zram device: %v", err)
+				continue
+			}
+		}
+		if active[path] {
+			r.log.Debugf("- swap on %s is already enabled", path)
+			continue
+		}
+
+		// format it, unless it is already swap space or a device which
+		// shouldn't be formatted
+		if fstype, _ := util.GetFsType(path); fstype != "swap" {
+			if swap.Device != "" && swap.Format != nil && !*swap.Format {
+				r.log.Warnf("- %s is not formatted as swap, skipping", path)
+				continue
+			}
+			r.log.Infof("- formatting %s as swap", path)
+			if b, err := exec.Command("mkswap", path).CombinedOutput(); err != nil {
+				r.log.Errorf("- failed to format %s as swap: %s", path, string(b))
+				continue
+			}
+		}
+
+		if err := swapon(path, swap.Priority); err != nil {
+			r.log.Errorf("- failed to enable swap on %s: %v", path, err)
+			continue
+		}
+		r.log.Infof("- enabled swap on %s", path)
+	}
+	return nil
+}
+
+// validate checks that the swap space is of exactly one kind, and that its
+// size and priority are valid.
+func (s *kurmaSwap) validate() error {
+	kinds := 0
+	for _, set := range []bool{s.Device != "", s.File != "", s.Zram} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of device, file, or zram must be given")
+	}
+	if s.SizeMB < 0 {
+		return fmt.Errorf("the size must not be negative")
+	}
+	if s.Zram && s.SizeMB == 0 {
+		return fmt.Errorf("a zram device requires a size")
+	}
+	if s.Priority != nil && (*s.Priority < 0 || *s.Priority > swapFlagPrioMask) {
+		return fmt.Errorf("the priority must be from 0 to %d", swapFlagPrioMask)
+	}
+	return nil
+}
+
+// createSwapFile creates the swap file with the size if it doesn't exist yet.
+// Swap files must not be sparse, so its space is allocated up front.
+func createSwapFile(path string, sizeMB int) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if sizeMB == 0 {
+		return fmt.Errorf("it doesn't exist and no size is given to create it")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	size := int64(sizeMB) << 20
+	if err := syscall.Fallocate(int(f.Fd()), 0, 0, size); err != nil {
+		// not every filesystem supports fallocate, so fall back to writing
+		// out the file
+		err = writeZeros(f, size)
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// writeZeros writes size bytes of zeros to the file.
+func writeZeros(f *os.File, size int64) error {
+	buf := make([]byte, 1<<20)
+	for written := int64(0); written < size; {
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return nil
+}
+
+// createZramDevice sets up a zram device of the size, compressed with the
+// algorithm if one is given, and returns the path of its node. A new device is
+// added if the kernel supports it, otherwise the first unused one is taken.
+func createZramDevice(sizeMB int, compression string) (string, error) {
+	if err := loadModule("zram"); err != nil {
+		return "", err
+	}
+
+	var name string
+	if b, err := ioutil.ReadFile(zramControlPath); err == nil {
+		name = "zram" + strings.TrimSpace(string(b))
+	} else {
+		blocks, _ := filepath.Glob("/sys/block/zram*")
+		for _, block := range blocks {
+			b, err := ioutil.ReadFile(filepath.Join(block, "disksize"))
+			if err == nil && strings.TrimSpace(string(b)) == "0" {
+				name = filepath.Base(block)
+				break
+			}
+		}
+		if name == "" {
+			return "", fmt.Errorf("no unused zram device is available")
+		}
+	}
+
+	block := filepath.Join("/sys/block", name)
+	if compression != "" {
+		if err := ioutil.WriteFile(filepath.Join(block, "comp_algorithm"), []byte(compression), 0); err != nil {
+			return "", fmt.Errorf("failed to set the compression of %s to %s: %v", name, compression, err)
+		}
+	}
+	size := strconv.FormatInt(int64(sizeMB)<<20, 10)
+	if err := ioutil.WriteFile(filepath.Join(block, "disksize"), []byte(size), 0); err != nil {
+		return "", fmt.Errorf("failed to set the size of %s: %v", name, err)
+	}
+	return filepath.Join("/dev", name), nil
+}
+
+// activeSwaps returns the swap spaces which are already enabled, from
+// /proc/swaps.
+func activeSwaps() (map[string]bool, error) {
+	f, err := os.Open("/proc/swaps")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	active := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "Filename" {
+			continue
+		}
+		active[fields[0]] = true
+	}
+	return active, scanner.Err()
+}
+
+// swapon enables swapping to the device or file, with the priority if one is
+// given.
+func swapon(path string, priority *int) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	flags := 0
+	if priority != nil {
+		flags = swapFlagPrefer | (*priority & swapFlagPrioMask)
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_SWAPON, uintptr(unsafe.Pointer(p)), uintptr(flags), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
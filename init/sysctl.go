@@ -0,0 +1,68 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"sort"
+
+	"github.com/apcera/kurma/util/sysctl"
+)
+
+// applySysctls sets the kernel parameters from the configuration. A parameter
+// which can't be set is logged rather than failing the boot.
+func (r *runner) applySysctls() error {
+	if len(r.config.Sysctls) == 0 {
+		return nil
+	}
+
+	r.log.Info("Setting kernel parameters")
+	for _, name := range sortedSysctls(r.config.Sysctls) {
+		value := r.config.Sysctls[name]
+		if err := sysctl.Set(name, value); err != nil {
+			r.log.Errorf("- %v", err)
+			continue
+		}
+		r.log.Debugf("- %s = %s", name, value)
+	}
+	return nil
+}
+
+// reloadSysctls sets the kernel parameters which were added or changed from the
+// running configuration. Those which were removed require a reboot to return to
+// the kernel's defaults.
+func (r *runner) reloadSysctls(sysctls map[string]string, applied, restart, failed func(string, ...interface{})) {
+	running := make(map[string]string, len(r.config.Sysctls))
+	for name, value := range r.config.Sysctls {
+		running[name] = value
+	}
+
+	for _, name := range sortedSysctls(sysctls) {
+		value := sysctls[name]
+		if current, ok := running[name]; ok && current == value {
+			continue
+		}
+		if err := sysctl.Set(name, value); err != nil {
+			failed("%v", err)
+			continue
+		}
+		applied("set sysctl %s to %s", name, value)
+		running[name] = value
+	}
+	for _, name := range sortedSysctls(running) {
+		if _, ok := sysctls[name]; !ok {
+			restart("sysctl %s was removed", name)
+		}
+	}
+	r.config.Sysctls = running
+}
+
+// sortedSysctls returns the names of the sysctls in order, so they're set the
+// same way each time.
+func sortedSysctls(sysctls map[string]string) []string {
+	names := make([]string, 0, len(sysctls))
+	for name := range sysctls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
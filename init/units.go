@@ -0,0 +1,303 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// unitState is the progress of a unit in setting up the host.
+type unitState string
+
+const (
+	unitPending   = unitState("pending")
+	unitRunning   = unitState("running")
+	unitSucceeded = unitState("succeeded")
+	unitFailed    = unitState("failed")
+	unitSkipped   = unitState("skipped")
+)
+
+// unit is a step in setting up the host, such as mounting the disks or
+// launching an init container. Units are started once the units they're
+// ordered after have finished, and those with no ordering between them are run
+// in parallel.
+type unit struct {
+	name string
+	run  func(*runner) error
+
+	// requires lists the units which must succeed before this unit is run. If
+	// any of them fail, or don't exist, this unit is skipped. after and before
+	// only order this unit after or before the named units, whether or not they
+	// succeed, and are ignored for units which don't exist.
+	requires []string
+	after    []string
+	before   []string
+
+	state    unitState
+	err      error
+	started  time.Time
+	finished time.Time
+}
+
+// done returns whether the unit has finished, whether or not it succeeded.
+func (u *unit) done() bool {
+	return u.state == unitSucceeded || u.state == unitFailed || u.state == unitSkipped
+}
+
+// unitGraph runs the units setting up the host in the order of their
+// relationships, and keeps their status once they've finished.
+type unitGraph struct {
+	mutex   sync.Mutex
+	units   []*unit
+	byName  map[string]*unit
+	changed chan bool
+}
+
+// newUnitGraph returns a graph of the units, which are copied so that each graph
+// tracks the status of its own.
+func newUnitGraph(units []unit) *unitGraph {
+	g := &unitGraph{
+		byName:  make(map[string]*unit),
+		changed: make(chan bool, 1),
+	}
+	for i := range units {
+		u := units[i]
+		if err := g.add(&u); err != nil {
+			panic(err)
+		}
+	}
+	return g
+}
+
+// add adds units to the graph, which may be done by a running unit. Units which
+// have already failed can be added to report them in the status.
+func (g *unitGraph) add(units ...*unit) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, u := range units {
+		if _, exists := g.byName[u.name]; exists {
+			return fmt.Errorf("there is already a unit named %s", u.name)
+		}
+	}
+	for _, u := range units {
+		if u.state == "" {
+			u.state = unitPending
+		}
+		g.units = append(g.units, u)
+		g.byName[u.name] = u
+	}
+	g.notify()
+	return nil
+}
+
+// notify wakes the graph's run loop to schedule any units which may now be
+// started.
+func (g *unitGraph) notify() {
+	select {
+	case g.changed <- true:
+	default:
+	}
+}
+
+// run runs the units until all of them have finished, including those added
+// while it is running. An error listing the units which failed is returned if
+// there are any.
+func (g *unitGraph) run(r *runner) error {
+	for {
+		g.mutex.Lock()
+		running, pending := g.schedule(r)
+		g.mutex.Unlock()
+		if running == 0 && pending == 0 {
+			break
+		}
+		<-g.changed
+	}
+
+	var failed []string
+	for _, u := range g.status() {
+		if u.State == string(unitFailed) {
+			failed = append(failed, fmt.Sprintf("%s: %s", u.Name, u.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("units failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// schedule starts each pending unit whose relationships are satisfied, and
+// skips those whose required units failed. It returns how many units are
+// running and pending afterwards. If none are running but some are pending,
+// those are waiting on a cycle, so they're failed. The graph's mutex must be
+// held.
+func (g *unitGraph) schedule(r *runner) (int, int) {
+	for {
+		changed := false
+		running, pending := 0, 0
+		for _, u := range g.units {
+			if u.state == unitPending {
+				if g.blocked(u) {
+					pending++
+					continue
+				}
+				if u.state == unitSkipped {
+					r.log.Warnf("Skipping %s: %v", u.name, u.err)
+					changed = true
+					continue
+				}
+				g.start(r, u)
+			}
+			if u.state == unitRunning {
+				running++
+			}
+		}
+		if changed {
+			continue
+		}
+		if running > 0 || pending == 0 {
+			return running, pending
+		}
+
+		now := time.Now()
+		for _, u := range g.units {
+			if u.state == unitPending {
+				u.state = unitFailed
+				u.err = fmt.Errorf("part of or depends on a dependency cycle")
+				u.started, u.finished = now, now
+				r.log.Errorf("Not running %s: %v", u.name, u.err)
+			}
+		}
+	}
+}
+
+// blocked returns whether the pending unit must wait for other units to finish.
+// If a unit it requires failed or doesn't exist, it is marked as skipped and
+// false is returned.
+func (g *unitGraph) blocked(u *unit) bool {
+	for _, name := range u.requires {
+		dep, exists := g.byName[name]
+		switch {
+		case !exists:
+			g.skip(u, fmt.Errorf("it requires %s, which does not exist", name))
+			return false
+		case dep.state == unitFailed || dep.state == unitSkipped:
+			g.skip(u, fmt.Errorf("it requires %s, which %s", name, dep.state))
+			return false
+		case !dep.done():
+			return true
+		}
+	}
+	for _, name := range u.after {
+		if dep, exists := g.byName[name]; exists && !dep.done() {
+			return true
+		}
+	}
+	for _, other := range g.units {
+		if other.done() {
+			continue
+		}
+		for _, name := range other.before {
+			if name == u.name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skip marks the unit as not being run because of err.
+func (g *unitGraph) skip(u *unit, err error) {
+	now := time.Now()
+	u.state = unitSkipped
+	u.err = err
+	u.started, u.finished = now, now
+}
+
+// start runs the unit in the background, and records its result when it
+// finishes.
+func (g *unitGraph) start(r *runner, u *unit) {
+	u.state = unitRunning
+	u.started = time.Now()
+	r.log.Debugf("Starting %s", u.name)
+
+	go func() {
+		err := u.run(r)
+
+		g.mutex.Lock()
+		u.finished = time.Now()
+		u.err = err
+		if err != nil {
+			u.state = unitFailed
+		} else {
+			u.state = unitSucceeded
+		}
+		elapsed := u.finished.Sub(u.started)
+		g.mutex.Unlock()
+
+		if err != nil {
+			r.log.Errorf("ERROR: %s failed after %s: %v", u.name, elapsed, err)
+		} else {
+			r.log.Tracef("Finished %s in %s", u.name, elapsed)
+		}
+		g.notify()
+	}()
+}
+
+// succeeded returns whether the named unit has run successfully.
+func (g *unitGraph) succeeded(name string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	u, exists := g.byName[name]
+	return exists && u.state == unitSucceeded
+}
+
+// status returns the status of each unit, ordered by when they were started.
+// Units which haven't started are listed last, in the order they were added.
+func (g *unitGraph) status() []*pb.HostUnit {
+	g.mutex.Lock()
+	units := make([]*unit, len(g.units))
+	copy(units, g.units)
+	result := make([]*pb.HostUnit, len(units))
+	for i, u := range units {
+		hu := &pb.HostUnit{
+			Name:     u.name,
+			State:    string(u.state),
+			Requires: u.requires,
+			After:    u.after,
+			Before:   u.before,
+		}
+		if u.err != nil {
+			hu.Error = u.err.Error()
+		}
+		if !u.started.IsZero() {
+			hu.Started = u.started.UnixNano()
+		}
+		if !u.finished.IsZero() {
+			hu.Finished = u.finished.UnixNano()
+		}
+		result[i] = hu
+	}
+	g.mutex.Unlock()
+
+	sort.Stable(unitsByStart(result))
+	return result
+}
+
+// unitsByStart sorts units by when they were started, with those which haven't
+// started last.
+type unitsByStart []*pb.HostUnit
+
+func (s unitsByStart) Len() int      { return len(s) }
+func (s unitsByStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s unitsByStart) Less(i, j int) bool {
+	if s[i].Started == 0 || s[j].Started == 0 {
+		return s[i].Started != 0 && s[j].Started == 0
+	}
+	return s[i].Started < s[j].Started
+}
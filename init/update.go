@@ -0,0 +1,310 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/upgrade"
+	"github.com/apcera/kurma/util/gpt"
+	"github.com/apcera/kurma/util/remote"
+)
+
+// The priorities the booted image and an update are given. The update is tried
+// first, and the booted image remains the fallback until the update has been
+// confirmed healthy.
+const (
+	fallbackPriority = 1
+	updatePriority   = 2
+)
+
+// systemPartitions returns the partition the system image was booted from and
+// the other partition of the pair, from the disk's partition table.
+func (r *runner) systemPartitions() (*gpt.Entry, *gpt.Entry, error) {
+	cfg := r.config.Updates
+	if cfg.Disk == "" {
+		return nil, nil, fmt.Errorf("updating the system image is not configured")
+	}
+	names := cfg.Partitions
+	if len(names) == 0 {
+		names = []string{defaultUpdatePartitionA, defaultUpdatePartitionB}
+	}
+	if len(names) != 2 {
+		return nil, nil, fmt.Errorf("two partitions must be configured for the system image, not %d", len(names))
+	}
+	bootedName := bootedPartition(kernelParams())
+	if bootedName == "" {
+		return nil, nil, fmt.Errorf("the booted partition was not given with kurma.partition")
+	}
+	if bootedName != names[0] && bootedName != names[1] {
+		return nil, nil, fmt.Errorf("the booted partition %s is not one of %s and %s", bootedName, names[0], names[1])
+	}
+
+	parts, err := gpt.ReadTable(cfg.Disk)
+	if err != nil {
+		return nil, nil, err
+	}
+	var booted, other *gpt.Entry
+	for _, name := range names {
+		var found *gpt.Entry
+		for _, p := range parts {
+			if p.Name == name {
+				found = p
+				break
+			}
+		}
+		if found == nil {
+			return nil, nil, fmt.Errorf("%s has no partition named %s", cfg.Disk, name)
+		}
+		if name == bootedName {
+			booted = found
+		} else {
+			other = found
+		}
+	}
+	return booted, other, nil
+}
+
+// systemPartitionState returns the state of the partition the update status
+// reports, given the partition which was booted.
+func systemPartitionState(p, booted *gpt.Entry) string {
+	switch {
+	case p == booted && p.Successful():
+		return "active"
+	case p == booted:
+		return "trial"
+	case p.Bootable() && p.Priority() > booted.Priority():
+		return "pending"
+	case p.Priority() > booted.Priority():
+		// the bootloader passed over it, or it was rolled back from
+		return "failed"
+	case p.Bootable():
+		return "fallback"
+	default:
+		return "inactive"
+	}
+}
+
+// updateStatus returns the state of the partitions the system image is booted
+// from.
+func (r *runner) updateStatus() (*pb.UpdateStatusResponse, error) {
+	r.updateMutex.Lock()
+	defer r.updateMutex.Unlock()
+	return r.updateStatusLocked()
+}
+
+func (r *runner) updateStatusLocked() (*pb.UpdateStatusResponse, error) {
+	booted, other, err := r.systemPartitions()
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.UpdateStatusResponse{Disk: r.config.Updates.Disk}
+	for _, p := range []*gpt.Entry{booted, other} {
+		sp := &pb.SystemPartition{
+			Name:       p.Name,
+			Device:     gpt.PartitionPath(r.config.Updates.Disk, p.Number),
+			State:      systemPartitionState(p, booted),
+			Booted:     p == booted,
+			Priority:   int32(p.Priority()),
+			Tries:      int32(p.Tries()),
+			Successful: p.Successful(),
+		}
+		if sp.State == "trial" && !r.trialDeadline.IsZero() {
+			sp.TrialDeadline = r.trialDeadline.Unix()
+		}
+		resp.Partitions = append(resp.Partitions, sp)
+	}
+	return resp, nil
+}
+
+// updateSystemImage writes the signed system image at the url to the partition
+// which wasn't booted, and has the bootloader try it once on the next boot.
+// The booted image must have been confirmed healthy, since it is what the host
+// rolls back to.
+func (r *runner) updateSystemImage(url string) (*pb.UpdateStatusResponse, error) {
+	r.updateMutex.Lock()
+	defer r.updateMutex.Unlock()
+
+	booted, other, err := r.systemPartitions()
+	if err != nil {
+		return nil, err
+	}
+	if !booted.Successful() {
+		return nil, fmt.Errorf("the booted system image is on trial, retry once it has been confirmed healthy")
+	}
+
+	r.log.Infof("Updating the system image in %s from %s", other.Name, url)
+	image, err := remote.RetrieveSignedFile(url, upgrade.BundleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the system image: %v", err)
+	}
+	defer image.Close()
+
+	// The partition is made unbootable while it is written, so that a partly
+	// written image is never booted.
+	disk := r.config.Updates.Disk
+	if err := gpt.SetAttributes(disk, other.Number, gpt.PriorityAttributes(other.Attributes, 0, 0, false)); err != nil {
+		return nil, fmt.Errorf("failed to update the partition table: %v", err)
+	}
+	device := gpt.PartitionPath(disk, other.Number)
+	if err := writeSystemImage(image, device); err != nil {
+		return nil, fmt.Errorf("failed to write the system image to %s: %v", device, err)
+	}
+
+	if err := gpt.SetAttributes(disk, booted.Number, gpt.PriorityAttributes(booted.Attributes, fallbackPriority, 0, true)); err != nil {
+		return nil, fmt.Errorf("failed to update the partition table: %v", err)
+	}
+	if err := gpt.SetAttributes(disk, other.Number, gpt.PriorityAttributes(other.Attributes, updatePriority, 1, false)); err != nil {
+		return nil, fmt.Errorf("failed to update the partition table: %v", err)
+	}
+	r.log.Infof("The system image in %s will be tried on the next boot", other.Name)
+	return r.updateStatusLocked()
+}
+
+// writeSystemImage writes the image, which is decompressed if it is gzip
+// compressed, to the partition's device.
+func writeSystemImage(image io.Reader, device string) error {
+	br := bufio.NewReader(image)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	f, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, src); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// checkSystemImage confirms an updated system image which is on trial once the
+// host's containers are healthy, so that the bootloader keeps booting it. The
+// host rolls back to the image it was updated from if the boot failed, given
+// as the reason, or if the containers aren't healthy within the timeout from
+// when the host started.
+func (r *runner) checkSystemImage(started time.Time, failure string) {
+	if r.config.Updates.Disk == "" {
+		return
+	}
+	r.updateMutex.Lock()
+	booted, _, err := r.systemPartitions()
+	if err != nil {
+		r.updateMutex.Unlock()
+		r.log.Warnf("Failed to check the system image: %v", err)
+		return
+	}
+	if booted.Successful() {
+		r.updateMutex.Unlock()
+		return
+	}
+	timeout := r.config.Updates.HealthTimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultUpdateHealthTimeoutSeconds
+	}
+	r.trialDeadline = started.Add(time.Duration(timeout) * time.Second)
+	deadline := r.trialDeadline
+	r.updateMutex.Unlock()
+
+	if failure == "" {
+		r.log.Infof("The system image in %s is on trial until its containers are healthy", booted.Name)
+		for {
+			if r.containersHealthy() {
+				r.confirmSystemImage()
+				return
+			}
+			if time.Now().After(deadline) {
+				failure = fmt.Sprintf("the containers weren't healthy within %ds", timeout)
+				break
+			}
+			time.Sleep(updateCheckInterval)
+		}
+	}
+	r.rollbackSystemImage(failure)
+}
+
+// containersHealthy returns whether each of the containers is running or has
+// exited successfully, and passes its health checks if it has any.
+func (r *runner) containersHealthy() bool {
+	if r.manager == nil {
+		return false
+	}
+	for _, c := range r.manager.Containers() {
+		switch c.State() {
+		case container.RUNNING:
+			if h := c.Health(); h != container.HealthNone && h != container.HealthHealthy {
+				return false
+			}
+		case container.EXITED:
+			if c.ExitCode() != 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// confirmSystemImage marks the booted image as having booted successfully.
+func (r *runner) confirmSystemImage() {
+	r.updateMutex.Lock()
+	defer r.updateMutex.Unlock()
+
+	booted, _, err := r.systemPartitions()
+	if err != nil {
+		r.log.Errorf("Failed to confirm the system image: %v", err)
+		return
+	}
+	attrs := gpt.PriorityAttributes(booted.Attributes, booted.Priority(), 0, true)
+	if err := gpt.SetAttributes(r.config.Updates.Disk, booted.Number, attrs); err != nil {
+		r.log.Errorf("Failed to confirm the system image: %v", err)
+		return
+	}
+	r.trialDeadline = time.Time{}
+	r.log.Infof("Confirmed the system image in %s is healthy", booted.Name)
+}
+
+// rollbackSystemImage has the bootloader pass over the booted image, which is
+// on trial, and reboots into the image it was updated from. If there is no
+// image to roll back to, the booted one is kept so the host stays bootable.
+func (r *runner) rollbackSystemImage(reason string) {
+	r.updateMutex.Lock()
+	booted, other, err := r.systemPartitions()
+	if err != nil {
+		r.updateMutex.Unlock()
+		r.log.Errorf("Failed to roll back the system image: %v", err)
+		return
+	}
+	if !other.Bootable() {
+		r.updateMutex.Unlock()
+		r.log.Errorf("The system image in %s failed, %s, but there is no image to roll back to", booted.Name, reason)
+		r.confirmSystemImage()
+		return
+	}
+	attrs := gpt.PriorityAttributes(booted.Attributes, booted.Priority(), 0, false)
+	err = gpt.SetAttributes(r.config.Updates.Disk, booted.Number, attrs)
+	r.updateMutex.Unlock()
+	if err != nil {
+		r.log.Errorf("Failed to roll back the system image: %v", err)
+		return
+	}
+
+	r.log.Errorf("The system image in %s failed, %s, rolling back to %s", booted.Name, reason, other.Name)
+	r.shutdown(true)
+}
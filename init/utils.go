@@ -5,15 +5,12 @@ package init
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"syscall"
 
-	"github.com/apcera/util/tarhelper"
-	"github.com/appc/spec/schema"
+	"github.com/apcera/kurma/util/dhcp"
 	"github.com/vishvananda/netlink"
 )
 
@@ -32,20 +29,30 @@ func bindMount(source, dest string) error {
 }
 
 // configureInterface is used to configure an individual interface against a
-// matched configuration. It sets up the addresses, the MTU, and invokes DHCP if
-// necessary.
-func configureInterface(link netlink.Link, netconf *kurmaNetworkInterface) error {
+// matched configuration. It sets up the addresses, the MTU, and acquires a DHCP
+// lease if necessary. The client and lease are returned when DHCP is used, so
+// the lease can be renewed.
+func configureInterface(link netlink.Link, netconf *kurmaNetworkInterface, hostname string) (*dhcp.Client, *dhcp.Lease, error) {
 	linkName := link.Attrs().Name
 	addressConfigured := true
 
+	// configure IPv6 autoconfiguration first, so router advertisements are
+	// accepted as soon as the link is up
+	if netconf.IPv6 != "" {
+		if err := configureIPv6(link, netconf.IPv6); err != nil {
+			return nil, nil, fmt.Errorf("failed to configure IPv6 on %s: %v", linkName, err)
+		}
+		addressConfigured = true
+	}
+
 	// configure using DHCP
+	var client *dhcp.Client
+	var lease *dhcp.Lease
 	if netconf.DHCP {
-		cmd := exec.Command("udhcpc", "-i", linkName, "-t", "20", "-n")
-		cmd.Stdin = nil
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to configure %s with DHCP: %v", linkName, err)
+		var err error
+		client, lease, err = acquireLease(link, hostname)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to configure %s with DHCP: %v", linkName, err)
 		}
 		addressConfigured = true
 	}
@@ -54,10 +61,10 @@ func configureInterface(link netlink.Link, netconf *kurmaNetworkInterface) error
 	if netconf.Address != "" {
 		addr, err := netlink.ParseAddr(netconf.Address)
 		if err != nil {
-			return fmt.Errorf("failed to parse address %q on %s", netconf.Address, linkName)
+			return nil, nil, fmt.Errorf("failed to parse address %q on %s", netconf.Address, linkName)
 		}
 		if err := netlink.AddrAdd(link, addr); err != nil {
-			return fmt.Errorf("failed to configure address %q on %s: %v",
+			return nil, nil, fmt.Errorf("failed to configure address %q on %s: %v",
 				netconf.Address, linkName, err)
 		}
 		addressConfigured = true
@@ -67,33 +74,33 @@ func configureInterface(link netlink.Link, netconf *kurmaNetworkInterface) error
 	for _, address := range netconf.Addresses {
 		addr, err := netlink.ParseAddr(address)
 		if err != nil {
-			return fmt.Errorf("failed to parse address %q on %s", address, linkName)
+			return nil, nil, fmt.Errorf("failed to parse address %q on %s", address, linkName)
 		}
 		if err := netlink.AddrAdd(link, addr); err != nil {
-			return fmt.Errorf("failed to configure address %q on %s: %v",
+			return nil, nil, fmt.Errorf("failed to configure address %q on %s: %v",
 				address, linkName, err)
 		}
 		addressConfigured = true
 	}
 
 	if !addressConfigured {
-		return fmt.Errorf("no address configured to %s: unable to set link up", linkName)
+		return nil, nil, fmt.Errorf("no address configured to %s: unable to set link up", linkName)
 	}
 
 	if netconf.MTU > 0 {
 		if err := netlink.LinkSetMTU(link, netconf.MTU); err != nil {
-			return fmt.Errorf("failed to set mtu on %s: %v", linkName, err)
+			return nil, nil, fmt.Errorf("failed to set mtu on %s: %v", linkName, err)
 		}
 	}
 
 	// verify it is up at the end
 	if link.Attrs().Flags&net.FlagUp == 0 {
 		if err := netlink.LinkSetUp(link); err != nil {
-			return fmt.Errorf("failed to set link %s up: %v", linkName, err)
+			return nil, nil, fmt.Errorf("failed to set link %s up: %v", linkName, err)
 		}
 	}
 
-	return nil
+	return client, lease, nil
 }
 
 // handleSIGCHLD is used to loop over and receive a SIGCHLD signal, which is
@@ -121,38 +128,15 @@ func (r *runner) handleSIGCHLD(ch chan os.Signal) {
 	}
 }
 
-// findManifest retrieves the manifest from the provided reader and unmarshals
-// it.
-func findManifest(r io.Reader) (*schema.ImageManifest, error) {
-	arch, err := tarhelper.DetectArchiveCompression(r)
-	if err != nil {
-		return nil, err
-	}
-
-	for {
-		header, err := arch.Next()
-		if err == io.EOF {
-			return nil, fmt.Errorf("failed to locate manifest file")
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		if filepath.Clean(header.Name) != "manifest" {
-			continue
-		}
-
-		var manifest *schema.ImageManifest
-		if err := json.NewDecoder(arch).Decode(&manifest); err != nil {
-			return nil, err
-		}
-		return manifest, nil
+// formatDisk formats the device with the specified fstype, and gives the
+// filesystem the label if one is specified.
+func formatDisk(device, fstype, label string) error {
+	var args []string
+	if label != "" {
+		args = append(args, "-L", label)
 	}
-}
-
-// formatDisk formats the device with the specified fstype.
-func formatDisk(device, fstype string) error {
-	cmd := exec.Command(fmt.Sprintf("mkfs.%s", fstype), device)
+	args = append(args, device)
+	cmd := exec.Command(fmt.Sprintf("mkfs.%s", fstype), args...)
 	if b, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to format %s: %s", device, string(b))
 	}
@@ -0,0 +1,165 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package init
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/server"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// ioctls to set and get the watchdog's timeout, and to pet it.
+	wdiocSetTimeout = 0xc0045706
+	wdiocGetTimeout = 0x80045707
+	wdiocKeepAlive  = 0x80045705
+)
+
+// startWatchdog opens the hardware watchdog and pets it in the background for
+// as long as the container manager and the API server are responsive. Once
+// either is wedged it is no longer petted, so the host is reset when it times
+// out.
+func (r *runner) startWatchdog() error {
+	cfg := r.config.Services.Watchdog
+	if cfg.Enabled == nil || !*cfg.Enabled {
+		r.log.Trace("Skipping the watchdog")
+		return nil
+	}
+
+	device := cfg.Device
+	if device == "" {
+		device = defaultWatchdogDevice
+	}
+	timeout := cfg.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultWatchdogTimeoutSeconds
+	}
+
+	// the watchdog is armed as soon as it is opened
+	f, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open the watchdog %s: %v", device, err)
+	}
+
+	// the driver may round the timeout to one the hardware supports, and it
+	// sets it to what it used
+	t := int32(timeout)
+	if err := watchdogIoctl(f, wdiocSetTimeout, &t); err != nil {
+		r.log.Warnf("Failed to set the watchdog's timeout to %ds, using its default: %v", timeout, err)
+		if err := watchdogIoctl(f, wdiocGetTimeout, &t); err != nil {
+			r.log.Warnf("Failed to get the watchdog's timeout, assuming it is %ds: %v", timeout, err)
+			t = int32(timeout)
+		}
+	}
+
+	endpoint := localEndpoint(r.config.Services.API.Listeners)
+	if endpoint == "" {
+		r.log.Warn("The API is not served on a local endpoint, so the watchdog won't check it")
+	}
+
+	r.log.Infof("Petting the watchdog %s with a %ds timeout", device, t)
+	go r.petWatchdog(f, time.Duration(t)*time.Second, endpoint)
+	return nil
+}
+
+// petWatchdog pets the watchdog three times per timeout, as long as the
+// container manager and the API server respond within a third of it.
+func (r *runner) petWatchdog(f *os.File, timeout time.Duration, endpoint string) {
+	interval := timeout / 3
+	wedged := false
+	for {
+		err := r.manager.Ping(interval)
+		if err == nil && endpoint != "" {
+			err = pingServer(endpoint, r.apiToken, interval)
+		}
+
+		switch {
+		case err != nil:
+			if !wedged {
+				r.log.Errorf("Not petting the watchdog, the host will be reset if this persists: %v", err)
+			}
+			wedged = true
+		default:
+			if wedged {
+				r.log.Info("The host is responsive again, resuming petting the watchdog")
+			}
+			wedged = false
+			if err := watchdogIoctl(f, wdiocKeepAlive, nil); err != nil {
+				r.log.Errorf("Failed to pet the watchdog: %v", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// pingServer returns an error if the API server at the endpoint doesn't answer
+// a request, authenticated with the token if one is given, within the timeout.
+func pingServer(endpoint, token string, timeout time.Duration) error {
+	opts := []grpc.DialOption{grpc.WithTimeout(timeout)}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(pb.TokenCredentials(token)))
+	}
+	conn, err := pb.Dial(endpoint, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the API server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// being refused for exceeding the rate limit is still an answer
+	_, err = pb.NewKurmaClient(conn).HostStatus(ctx, &pb.None{})
+	if err != nil && grpc.Code(err) != codes.ResourceExhausted {
+		return fmt.Errorf("the API server did not respond: %v", err)
+	}
+	return nil
+}
+
+// localEndpoint returns the first of the API listeners which can be reached
+// from the host without a client certificate, which are unix sockets and tcp
+// listeners on a loopback address.
+func localEndpoint(listeners []string) string {
+	if len(listeners) == 0 {
+		listeners = []string{server.DefaultListener}
+	}
+	for _, endpoint := range listeners {
+		network, address, err := pb.ParseEndpoint(endpoint)
+		if err != nil {
+			continue
+		}
+		if network == "unix" {
+			return endpoint
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// watchdogIoctl issues the watchdog ioctl, with the value as its argument if
+// one is given.
+func watchdogIoctl(f *os.File, req uintptr, value *int32) error {
+	var arg uintptr
+	if value != nil {
+		arg = uintptr(unsafe.Pointer(value))
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
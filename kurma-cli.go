@@ -6,26 +6,25 @@ package main
 
 import (
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"runtime"
 	"syscall"
+	"time"
 
 	"github.com/apcera/kurma/client/cli"
 	"github.com/apcera/util/terminal"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	pb "github.com/apcera/kurma/stage1/client"
-	"google.golang.org/grpc"
 
 	_ "github.com/apcera/kurma/client/cli/commands"
 )
 
 const (
 	ERROR_PREFIX = "Error: "
-
-	defaultKurmaLocalPort  = "12311"
-	defaultKurmaRemotePort = "12312"
 )
 
 func main() {
@@ -107,14 +106,18 @@ func main() {
 		return
 	}
 
-	conn, err := grpc.Dial(determineKurmaHostPort())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, terminal.Colorize(terminal.ColorError, ERROR_PREFIX+"%s\n"), err.Error())
-		exitcode = 1
-		return
+	// Commands such as help run without the server, so are only connected to it
+	// if needed.
+	if !cmd.IsLocal() {
+		c, err := cli.Dial(0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, terminal.Colorize(terminal.ColorError, ERROR_PREFIX+"%s\n"), err.Error())
+			exitcode = 1
+			return
+		}
+		defer c.Close()
+		cmd.Client = c.RPC()
 	}
-	defer conn.Close()
-	cmd.Client = pb.NewKurmaClient(conn)
 
 	exitcode = runCommand(cmd)
 }
@@ -148,14 +151,58 @@ func handleTypedErrors(cmd *cli.Cmd, err error) (code int) {
 	code = 1
 	// Switch off the type of error received, if any.
 	switch aerr := err.(type) {
+	case *cli.ExitCodeError:
+		// The command has already reported its output, just pass along the code.
+		return aerr.Code
 	default:
 		// Unknown error received.
 		fmt.Fprintf(os.Stderr, terminal.Colorize(terminal.ColorError, ERROR_PREFIX+"%s\n"), aerr.Error())
+		if ierr := pb.InsufficientResources(err); ierr != nil {
+			explainInsufficientResources(ierr)
+			return
+		}
+		if grpc.Code(err) == codes.Unimplemented && cmd.Client != nil {
+			explainUnimplemented(cmd.Client)
+			return
+		}
 		fmt.Fprintf(os.Stdout, "Try `kurma-cli help` for more information.\n")
 		return
 	}
 }
 
+// explainUnimplemented checks whether a request the server didn't implement
+// failed because the server's API is older or newer than the client's, and
+// says so.
+func explainUnimplemented(client pb.KurmaClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := client.Info(ctx, &pb.None{})
+	switch {
+	case grpc.Code(err) == codes.Unimplemented:
+		fmt.Fprintln(os.Stderr, "The Kurma server predates API versioning and doesn't support this client; upgrade the server to use it.")
+	case err != nil:
+		return
+	default:
+		if err := pb.CheckAPIVersion(info); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// explainInsufficientResources says how much of the resource the container
+// reserves and how much the host has left for containers to reserve.
+func explainInsufficientResources(err *pb.InsufficientResourcesError) {
+	switch err.Resource {
+	case "cpu":
+		fmt.Fprintf(os.Stderr, "The container reserves %g CPUs, while %g are left to reserve on the host.\n",
+			float64(err.Requested)/1000, float64(err.Available)/1000)
+	case "memory":
+		fmt.Fprintf(os.Stderr, "The container reserves %d MiB of memory, while %d MiB is left to reserve on the host.\n",
+			err.Requested>>20, err.Available>>20)
+	}
+	fmt.Fprintf(os.Stdout, "Try `kurma-cli info` to see what the host has reserved.\n")
+}
+
 // printHelp dispatches into individual defined commands to find their help, as
 // needed
 func printHelp(stream *os.File) {
@@ -189,12 +236,3 @@ func reportPanic() error {
 	fmt.Fprintln(os.Stderr, string(cli.PanicStack))
 	return nil
 }
-
-func determineKurmaHostPort() string {
-	// quick check if it is referring to the local host
-	ip := net.ParseIP(cli.KurmaHost)
-	if ip != nil && ip.IsLoopback() {
-		return net.JoinHostPort(cli.KurmaHost, defaultKurmaLocalPort)
-	}
-	return net.JoinHostPort(cli.KurmaHost, defaultKurmaRemotePort)
-}
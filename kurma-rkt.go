@@ -0,0 +1,59 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apcera/kurma/stage1/rkt"
+	"github.com/apcera/logray"
+)
+
+// main runs the rkt stage1 entrypoint named by the binary, which rkt calls
+// through the run, enter, and gc links to it within the stage1 image. It may
+// also be called with the entrypoint as its first argument.
+func main() {
+	entrypoint, args := filepath.Base(os.Args[0]), os.Args[1:]
+	switch entrypoint {
+	case "run", "enter", "gc":
+	default:
+		if len(args) == 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s run|enter|gc [arguments]\n", entrypoint)
+			os.Exit(2)
+		}
+		entrypoint, args = args[0], args[1:]
+	}
+
+	level := logray.WARNPLUS
+	for _, arg := range args {
+		if arg == "--debug" || arg == "--debug=true" {
+			level = logray.ALL
+		}
+	}
+	logray.AddDefaultOutput("stderr://", level)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find the pod's directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := 0
+	switch entrypoint {
+	case "run":
+		code, err = rkt.Run(dir, args)
+	case "enter":
+		code, err = rkt.Enter(dir, args)
+	case "gc":
+		err = rkt.GC(dir, args)
+	default:
+		err = fmt.Errorf("unknown entrypoint %q", entrypoint)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s the pod: %v\n", entrypoint, err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/apcera/kurma/stage1/server"
 	"github.com/apcera/logray"
@@ -21,6 +22,7 @@ func main() {
 		ParentCgroupName:   "kurma",
 		ContainerDirectory: directory,
 		RequiredNamespaces: []string{"ipc", "mount", "pid", "uts"},
+		UpgradeDirectory:   filepath.Join(directory, "upgrades"),
 	}
 
 	s := server.New(opts)
@@ -0,0 +1,40 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	ConsoleName = "kurma/console"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(ConsoleName, newConsole)
+}
+
+func newConsole() types.IsolatorValue {
+	n := Console(false)
+	return &n
+}
+
+// Console is an isolator which specifies that the app should be started with a
+// console, a pseudo-terminal which is used as its stdin, stdout, and stderr and
+// can be attached to.
+type Console bool
+
+func (n *Console) UnmarshalJSON(b []byte) error {
+	console := false
+	if err := json.Unmarshal(b, &console); err != nil {
+		return err
+	}
+	*n = Console(console)
+	return nil
+}
+
+func (n Console) AssertValid() error {
+	return nil
+}
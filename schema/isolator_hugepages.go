@@ -0,0 +1,73 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	HugepagesName = "kurma/hugepages"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(HugepagesName, newHugepages)
+}
+
+func newHugepages() types.IsolatorValue {
+	return &Hugepages{limits: make(map[int64]int64)}
+}
+
+// Hugepages is an isolator which limits the hugepages the container may use of
+// each page size, such as {"2Mi": "1Gi", "1Gi": "4Gi"}. A hugetlbfs of each
+// size is mounted into the container for the apps to map the pages from. The
+// apps share the container's cgroup, so the limits apply to the container as a
+// whole.
+type Hugepages struct {
+	limits map[int64]int64
+}
+
+func (h *Hugepages) UnmarshalJSON(b []byte) error {
+	var limits map[string]string
+	if err := json.Unmarshal(b, &limits); err != nil {
+		return err
+	}
+	for size, limit := range limits {
+		s, err := resource.ParseQuantity(size)
+		if err != nil {
+			return fmt.Errorf("invalid page size %q: %v", size, err)
+		}
+		l, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return fmt.Errorf("invalid limit %q: %v", limit, err)
+		}
+		h.limits[s.Value()] = l.Value()
+	}
+	return nil
+}
+
+func (h *Hugepages) AssertValid() error {
+	for size, limit := range h.limits {
+		if size < 4096 || size&(size-1) != 0 {
+			return fmt.Errorf("the %s isolator's page size %d is not a power of two of at least 4096", HugepagesName, size)
+		}
+		if limit <= 0 || limit%size != 0 {
+			return fmt.Errorf("the %s isolator's limit for %d byte pages must be a positive multiple of the page size", HugepagesName, size)
+		}
+	}
+	return nil
+}
+
+// Limits returns the most bytes of hugepages the container may use, by their
+// page size in bytes.
+func (h *Hugepages) Limits() map[int64]int64 {
+	limits := make(map[int64]int64, len(h.limits))
+	for size, limit := range h.limits {
+		limits[size] = limit
+	}
+	return limits
+}
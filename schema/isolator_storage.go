@@ -0,0 +1,35 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	StorageName = "kurma/storage"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(StorageName, newStorage)
+}
+
+func newStorage() types.IsolatorValue {
+	return &Storage{}
+}
+
+// Storage is an isolator which limits the disk space the container may write
+// to its root filesystem, such as {"limit": "10G"}. The apps share the
+// container's filesystem, so the limit applies to the container as a whole.
+type Storage struct {
+	types.ResourceBase
+}
+
+func (s Storage) AssertValid() error {
+	if s.Limit() == nil || s.Limit().Value() <= 0 {
+		return fmt.Errorf("the %s isolator must specify a positive limit", StorageName)
+	}
+	return nil
+}
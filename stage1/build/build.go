@@ -0,0 +1,360 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package build produces images by running a series of steps on a base image,
+// either running commands within containers created from the image so far or
+// copying files into it from a build context.
+package build
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/util/remote"
+	"github.com/apcera/logray"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+	"golang.org/x/net/context"
+)
+
+// layerPrefix is the prefix of the temporary files holding the image as each
+// step of a build leaves it.
+const layerPrefix = ".build-"
+
+// Spec describes an image to build from the base image.
+type Spec struct {
+	// Base is the URI of the image the build starts from, which is retrieved
+	// insecurely if Insecure is set.
+	Base     string
+	Insecure bool
+
+	// Name and Labels are given to the image built, as they are to an image
+	// committed from a container.
+	Name   string
+	Labels map[string]string
+
+	// Steps are run in order on the image.
+	Steps []Step
+}
+
+// Step either runs a command within a container created from the image so far,
+// or copies a path from the build context into the image.
+type Step struct {
+	// Run is the command to run, with the app's environment and working
+	// directory. The changes it makes to the filesystem are kept if it exits
+	// successfully.
+	Run []string
+
+	// Source is the path within the build context to copy to Destination
+	// within the image. A directory's contents are copied into the
+	// destination, while a file is copied into it if the destination ends
+	// with a slash and otherwise to it.
+	Source      string
+	Destination string
+}
+
+// String describes the step as it is written in a Kurmafile.
+func (s Step) String() string {
+	if s.Run != nil {
+		return "RUN " + strings.Join(s.Run, " ")
+	}
+	return "COPY " + s.Source + " " + s.Destination
+}
+
+// Validate returns an error if the spec can't be built.
+func (s *Spec) Validate() error {
+	if s.Base == "" {
+		return fmt.Errorf("a base image must be given")
+	}
+	if s.Name == "" {
+		return fmt.Errorf("a name must be given for the image")
+	}
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("the build has no steps")
+	}
+	for i, step := range s.Steps {
+		switch {
+		case step.Run != nil && (step.Source != "" || step.Destination != ""):
+			return fmt.Errorf("step %d both runs a command and copies files", i+1)
+		case step.Run != nil && len(step.Run) == 0:
+			return fmt.Errorf("step %d has no command to run", i+1)
+		case step.Run == nil && (step.Source == "" || step.Destination == ""):
+			return fmt.Errorf("step %d must run a command or copy a source to a destination", i+1)
+		}
+	}
+	return nil
+}
+
+// Result describes an image which was built. The URI is the one it is held
+// under in the image store, the hash its SHA-512 hash in the form
+// sha512-<hex>, and the size its size in bytes.
+type Result struct {
+	URI  string
+	Hash string
+	Size int64
+}
+
+// Builder runs builds with the containers of the manager, adding the images
+// they produce to the store. The image is written to a temporary file within
+// the directory after each step.
+type Builder struct {
+	Log *logray.Logger
+
+	manager   *container.Manager
+	images    *image.Store
+	directory string
+}
+
+// New returns a Builder which creates the build's containers with the manager,
+// and adds the images built to the store. Temporary files are kept within the
+// directory, or the system's temporary directory if it is empty. Any left in it
+// by builds interrupted by a restart are removed.
+func New(manager *container.Manager, images *image.Store, directory string) *Builder {
+	if directory != "" {
+		if fis, err := ioutil.ReadDir(directory); err == nil {
+			for _, fi := range fis {
+				if strings.HasPrefix(fi.Name(), layerPrefix) {
+					os.Remove(filepath.Join(directory, fi.Name()))
+				}
+			}
+		}
+	}
+	return &Builder{
+		Log:       logray.New(),
+		manager:   manager,
+		images:    images,
+		directory: directory,
+	}
+}
+
+// Build runs the spec's steps on its base image, and adds the image they
+// produce to the image store. The context is a tar archive holding the files
+// the steps copy, and may be nil if none do. The progress of the build and the
+// output of the commands it runs are written to stdout and stderr. Cancelling
+// the context destroys the container of the step being run.
+func (b *Builder) Build(ctx context.Context, spec *Spec, buildContext io.ReadSeeker, stdout, stderr io.Writer) (*Result, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	f, err := b.images.Retrieve(spec.Base, spec.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the base image: %v", err)
+	}
+	base, err := remote.FindManifest(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to find the manifest in the base image: %v", err)
+	}
+	manifest, err := Manifest(base, spec.Name, spec.Labels)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// each step reads the image the previous one left, starting with a copy
+	// of the base image, so that it can be opened again for each container
+	layer, err := b.tempFile()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	defer os.Remove(layer.Name())
+	_, err = io.Copy(layer, f)
+	f.Close()
+	layer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy the base image: %v", err)
+	}
+
+	b.Log.Infof("Building %s from %s", manifest.Name, spec.Base)
+	for i, step := range spec.Steps {
+		fmt.Fprintf(stdout, "Step %d/%d: %s\n", i+1, len(spec.Steps), step)
+		next, err := b.tempFile()
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(next.Name())
+
+		if step.Run != nil {
+			err = b.run(ctx, step, manifest, layer.Name(), next, stdout, stderr)
+		} else {
+			err = copyStep(step, manifest, layer.Name(), buildContext, next)
+		}
+		next.Close()
+		if err != nil {
+			return nil, fmt.Errorf("step %d failed: %v", i+1, err)
+		}
+		os.Remove(layer.Name())
+		layer = next
+	}
+
+	f, err = os.Open(layer.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	uri := ImageURI(manifest)
+	h := sha512.New()
+	img, err := b.images.Add(uri, io.TeeReader(f, h))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store the image: %v", err)
+	}
+	b.Log.Infof("Built %s", uri)
+	return &Result{
+		URI:  uri,
+		Hash: "sha512-" + hex.EncodeToString(h.Sum(nil)),
+		Size: img.Size,
+	}, nil
+}
+
+// tempFile creates a temporary file for an image produced by a step.
+func (b *Builder) tempFile() (*os.File, error) {
+	return ioutil.TempFile(b.directory, layerPrefix)
+}
+
+// waitPollInterval is how often a step's container is checked for having been
+// destroyed before it exited.
+const waitPollInterval = 10 * time.Second
+
+// run runs the step's command within a container created from the image at the
+// path, and commits its filesystem to w with the manifest once the command
+// exits successfully. The container is destroyed once the step is done.
+func (b *Builder) run(ctx context.Context, step Step, manifest *schema.ImageManifest, path string, w io.Writer, stdout, stderr io.Writer) error {
+	runManifest := *manifest
+	app := types.App{}
+	if manifest.App != nil {
+		app = *manifest.App
+	}
+	app.Exec = step.Run
+	runManifest.App = &app
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	c, err := b.manager.Create("", &runManifest, f, &container.CreateOptions{Attach: true})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer func() {
+		if err := c.Stop(); err != nil {
+			b.Log.Warnf("Failed to destroy the build container %s: %v", c.UUID(), err)
+		}
+	}()
+	if err := c.StartError(); err != nil {
+		return fmt.Errorf("the container failed to start: %v", err)
+	}
+
+	_, detach, err := c.AttachStdio(stdout, stderr)
+	if err != nil {
+		return err
+	}
+	err = b.wait(ctx, c)
+	c.DrainOutput()
+	detach()
+	if err != nil {
+		return err
+	}
+	if code := c.ExitCode(); code != 0 {
+		return fmt.Errorf("the command exited with code %d", code)
+	}
+	return c.Commit(w, manifest)
+}
+
+// wait blocks until the container exits, returning an error if it failed to
+// start, was destroyed first, or the build was cancelled.
+func (b *Builder) wait(ctx context.Context, c *container.Container) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	exited := c.Exited()
+	for {
+		select {
+		case <-exited:
+			if err := c.StartError(); err != nil {
+				return fmt.Errorf("the container failed to start: %v", err)
+			}
+			return nil
+		case <-ticker.C:
+			if b.manager.Container(c.UUID()) == nil {
+				return fmt.Errorf("the container was destroyed before it exited")
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Manifest returns a copy of the base image's manifest with the name and labels
+// given for an image built from it. The image holds the whole of its
+// filesystem, so it has none of the dependencies the base image did.
+func Manifest(base *schema.ImageManifest, name string, labels map[string]string) (*schema.ImageManifest, error) {
+	if name == "" {
+		return nil, fmt.Errorf("a name must be given for the image")
+	}
+	acName, err := types.NewACIdentifier(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image name %q: %v", name, err)
+	}
+
+	b, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(schema.ImageManifest)
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, err
+	}
+	manifest.Name = *acName
+	manifest.Dependencies = nil
+	manifest.PathWhitelist = nil
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		labelName, err := types.NewACIdentifier(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label name %q: %v", k, err)
+		}
+		label := types.Label{Name: *labelName, Value: labels[k]}
+		replaced := false
+		for i, l := range manifest.Labels {
+			if l.Name.Equals(*labelName) {
+				manifest.Labels[i] = label
+				replaced = true
+			}
+		}
+		if !replaced {
+			manifest.Labels = append(manifest.Labels, label)
+		}
+	}
+	return manifest, nil
+}
+
+// ImageURI returns the URI an image with the manifest is held under in the
+// image store, which is its name followed by its version label if it has one.
+func ImageURI(manifest *schema.ImageManifest) string {
+	uri := manifest.Name.String()
+	if version, ok := manifest.GetLabel("version"); ok {
+		uri += ":" + version
+	}
+	return uri
+}
@@ -0,0 +1,212 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// testEntry is an entry of a tar archive written or read by the tests.
+type testEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	uid      int
+}
+
+// writeTar returns a tar archive of the entries.
+func writeTar(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+			Uid:      e.uid,
+			Gid:      e.uid,
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		TestExpectSuccess(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		TestExpectSuccess(t, err)
+	}
+	TestExpectSuccess(t, tw.Close())
+	return buf.Bytes()
+}
+
+// readTar returns the entries of the tar archive.
+func readTar(t *testing.T, r io.Reader) []testEntry {
+	var entries []testEntry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		TestExpectSuccess(t, err)
+		b, err := ioutil.ReadAll(tr)
+		TestExpectSuccess(t, err)
+		entries = append(entries, testEntry{hdr.Name, hdr.Typeflag, string(b), hdr.Uid})
+	}
+}
+
+func testManifest() *schema.ImageManifest {
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier("example.com/base")
+	manifest.Labels = types.Labels{
+		{Name: "version", Value: "1.0"},
+		{Name: "os", Value: "linux"},
+	}
+	manifest.Dependencies = types.Dependencies{{ImageName: "example.com/dep"}}
+	manifest.App = &types.App{Exec: types.Exec{"/bin/server"}, User: "0", Group: "0"}
+	return manifest
+}
+
+func TestSpecValidate(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	run := Step{Run: []string{"/bin/true"}}
+	copy := Step{Source: "app", Destination: "/opt/app"}
+	TestExpectSuccess(t, (&Spec{Base: "example.com/base", Name: "example.com/app", Steps: []Step{run, copy}}).Validate())
+
+	for _, spec := range []*Spec{
+		{Name: "example.com/app", Steps: []Step{run}},
+		{Base: "example.com/base", Steps: []Step{run}},
+		{Base: "example.com/base", Name: "example.com/app"},
+		{Base: "example.com/base", Name: "example.com/app", Steps: []Step{{Run: []string{}}}},
+		{Base: "example.com/base", Name: "example.com/app", Steps: []Step{{Source: "app"}}},
+		{Base: "example.com/base", Name: "example.com/app", Steps: []Step{{Run: []string{"/bin/true"}, Source: "app", Destination: "/"}}},
+	} {
+		TestExpectError(t, spec.Validate())
+	}
+}
+
+func TestManifest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	base := testManifest()
+	manifest, err := Manifest(base, "example.com/app", map[string]string{"version": "2.0", "arch": "amd64"})
+	TestExpectSuccess(t, err)
+	TestEqual(t, manifest.Name.String(), "example.com/app")
+	TestEqual(t, len(manifest.Dependencies), 0)
+	TestEqual(t, manifest.App.Exec, types.Exec{"/bin/server"})
+	TestEqual(t, ImageURI(manifest), "example.com/app:2.0")
+	arch, _ := manifest.GetLabel("arch")
+	TestEqual(t, arch, "amd64")
+
+	// the base manifest is left as it was
+	TestEqual(t, ImageURI(base), "example.com/base:1.0")
+	TestEqual(t, len(base.Dependencies), 1)
+
+	_, err = Manifest(base, "", nil)
+	TestExpectError(t, err)
+	_, err = Manifest(base, "Not A Name", nil)
+	TestExpectError(t, err)
+	_, err = Manifest(base, "example.com/app", map[string]string{"bad label": "x"})
+	TestExpectError(t, err)
+}
+
+func TestCopyStep(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	imagePath := filepath.Join(dir, "image.aci")
+	TestExpectSuccess(t, ioutil.WriteFile(imagePath, writeTar(t, []testEntry{
+		{name: "manifest", typeflag: tar.TypeReg, content: "{}"},
+		{name: "rootfs", typeflag: tar.TypeDir},
+		{name: "rootfs/etc", typeflag: tar.TypeDir},
+		{name: "rootfs/etc/app.conf", typeflag: tar.TypeReg, content: "old"},
+		{name: "rootfs/etc/hosts", typeflag: tar.TypeReg, content: "hosts"},
+	}), 0644))
+	buildContext := bytes.NewReader(writeTar(t, []testEntry{
+		{name: "app.conf", typeflag: tar.TypeReg, content: "new", uid: 1000},
+		{name: "web", typeflag: tar.TypeDir, uid: 1000},
+		{name: "web/index.html", typeflag: tar.TypeReg, content: "hello", uid: 1000},
+	}))
+	manifest := testManifest()
+
+	// Test 1: A file replaces the one at the destination, and is owned by root.
+	var buf bytes.Buffer
+	TestExpectSuccess(t, copyStep(Step{Source: "app.conf", Destination: "/etc/app.conf"}, manifest, imagePath, buildContext, &buf))
+	entries := readTar(t, &buf)
+	TestEqual(t, len(entries), 5)
+	TestEqual(t, entries[0].name, "manifest")
+	var written schema.ImageManifest
+	TestExpectSuccess(t, json.Unmarshal([]byte(entries[0].content), &written))
+	TestEqual(t, written.Name.String(), "example.com/base")
+	TestEqual(t, entries[3], testEntry{"rootfs/etc/hosts", tar.TypeReg, "hosts", 0})
+	TestEqual(t, entries[4], testEntry{"rootfs/etc/app.conf", tar.TypeReg, "new", 0})
+
+	// Test 2: A file is copied into a destination ending in a slash, and the
+	// missing parent directories are created.
+	buf.Reset()
+	TestExpectSuccess(t, copyStep(Step{Source: "app.conf", Destination: "/opt/app/"}, manifest, imagePath, buildContext, &buf))
+	entries = readTar(t, &buf)
+	TestEqual(t, len(entries), 8)
+	TestEqual(t, entries[5].name, "rootfs/opt")
+	TestEqual(t, entries[6].name, "rootfs/opt/app")
+	TestEqual(t, entries[7].name, "rootfs/opt/app/app.conf")
+
+	// Test 3: A directory's contents are copied into the destination.
+	buf.Reset()
+	TestExpectSuccess(t, copyStep(Step{Source: "web", Destination: "/srv"}, manifest, imagePath, buildContext, &buf))
+	entries = readTar(t, &buf)
+	TestEqual(t, len(entries), 7)
+	TestEqual(t, entries[5].name, "rootfs/srv")
+	TestEqual(t, entries[6], testEntry{"rootfs/srv/index.html", tar.TypeReg, "hello", 0})
+
+	// Test 4: Sources missing from the context are an error.
+	TestExpectError(t, copyStep(Step{Source: "missing", Destination: "/"}, manifest, imagePath, buildContext, ioutil.Discard))
+	TestExpectError(t, copyStep(Step{Source: "app.conf", Destination: "/"}, manifest, imagePath, nil, ioutil.Discard))
+
+	// Test 5: Entries outside of the context are an error, so they can't
+	// replace the manifest or escape the image.
+	for _, name := range []string{"../manifest", "./../manifest", "/../../x", "web/../../x", ".."} {
+		escaping := bytes.NewReader(writeTar(t, []testEntry{
+			{name: "app.conf", typeflag: tar.TypeReg, content: "new"},
+			{name: name, typeflag: tar.TypeReg, content: "{}"},
+		}))
+		TestExpectError(t, copyStep(Step{Source: ".", Destination: "/"}, manifest, imagePath, escaping, ioutil.Discard))
+	}
+}
+
+func TestCopyTarget(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	target, ok, err := copyTarget("web/index.html", ".", "rootfs", false, false)
+	TestExpectSuccess(t, err)
+	TestEqual(t, ok, true)
+	TestEqual(t, target, "rootfs/web/index.html")
+
+	_, ok, err = copyTarget("other/file", "web", "rootfs/srv", false, false)
+	TestExpectSuccess(t, err)
+	TestEqual(t, ok, false)
+
+	for _, name := range []string{"..", "../manifest", "../../x", "web/../../manifest"} {
+		_, _, err := copyTarget(name, ".", "rootfs", false, false)
+		TestExpectError(t, err)
+	}
+
+	// the target must be within the rootfs, whatever the destination
+	_, _, err = copyTarget("manifest", ".", ".", false, false)
+	TestExpectError(t, err)
+}
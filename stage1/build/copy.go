@@ -0,0 +1,209 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package build
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appc/spec/schema"
+)
+
+// copyStep writes the image at the path to w with the step's source copied into
+// it from the build context, and with the manifest in place of its own. The
+// files copied are owned by root, and replace any at the same paths.
+func copyStep(step Step, manifest *schema.ImageManifest, imagePath string, buildContext io.ReadSeeker, w io.Writer) error {
+	if buildContext == nil {
+		return fmt.Errorf("no build context was given to copy %q from", step.Source)
+	}
+	source := path.Clean(strings.TrimPrefix(step.Source, "/"))
+	destination := path.Join("rootfs", path.Clean("/"+step.Destination))
+	intoDirectory := strings.HasSuffix(step.Destination, "/")
+
+	// find which paths the context's entries are copied to, so the image's
+	// entries at those paths can be left out
+	targets := make(map[string]bool)
+	err := walkContext(buildContext, func(hdr *tar.Header, r io.Reader) error {
+		target, ok, err := copyTarget(hdr.Name, source, destination, intoDirectory, hdr.Typeflag == tar.TypeDir)
+		if err != nil {
+			return err
+		} else if ok {
+			targets[target] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%q is not in the build context", step.Source)
+	}
+
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(w)
+	if err := writeManifest(tw, manifest); err != nil {
+		return err
+	}
+	present := make(map[string]bool)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		name := entryName(hdr.Name)
+		if name == "manifest" || targets[name] && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		present[name] = true
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	// the parent directories of the files copied are created if neither the
+	// image nor the context has them, and directories the image already has
+	// are left as they are
+	var parents []string
+	for target := range targets {
+		for dir := path.Dir(target); dir != "." && !present[dir] && !targets[dir]; dir = path.Dir(dir) {
+			present[dir] = true
+			parents = append(parents, dir)
+		}
+	}
+	sort.Strings(parents)
+	for _, dir := range parents {
+		if err := tw.WriteHeader(directoryHeader(dir)); err != nil {
+			return err
+		}
+	}
+	err = walkContext(buildContext, func(hdr *tar.Header, r io.Reader) error {
+		target, ok, err := copyTarget(hdr.Name, source, destination, intoDirectory, hdr.Typeflag == tar.TypeDir)
+		if err != nil {
+			return err
+		} else if !ok || hdr.Typeflag == tar.TypeDir && present[target] {
+			return nil
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA, tar.TypeDir, tar.TypeSymlink:
+		default:
+			return fmt.Errorf("%q in the build context is not a file, directory, or symlink", hdr.Name)
+		}
+		copied := *hdr
+		copied.Name = target
+		copied.Uid, copied.Gid = 0, 0
+		copied.Uname, copied.Gname = "", ""
+		if err := tw.WriteHeader(&copied); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// walkContext calls fn with each of the entries of the build context's tar
+// archive, reading it from the start.
+func walkContext(buildContext io.ReadSeeker, fn func(hdr *tar.Header, r io.Reader) error) error {
+	if _, err := buildContext.Seek(0, 0); err != nil {
+		return err
+	}
+	tr := tar.NewReader(buildContext)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read the build context: %v", err)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// copyTarget returns the name within the image an entry of the build context
+// is copied to, if it is the source or within it. A directory source's
+// contents are copied into the destination, while a file source is copied to
+// the destination, or into it if intoDirectory is set. Entries outside of the
+// context, or which would be copied outside of the image's rootfs, are an
+// error.
+func copyTarget(name, source, destination string, intoDirectory, isDir bool) (string, bool, error) {
+	name = entryName(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", false, fmt.Errorf("%q in the build context is outside of it", name)
+	}
+
+	var target string
+	switch {
+	case source == ".":
+		target = path.Join(destination, name)
+	case name == source && !isDir && intoDirectory:
+		target = path.Join(destination, path.Base(name))
+	case name == source:
+		target = destination
+	case strings.HasPrefix(name, source+"/"):
+		target = path.Join(destination, strings.TrimPrefix(name, source+"/"))
+	default:
+		return "", false, nil
+	}
+	if target != "rootfs" && !strings.HasPrefix(target, "rootfs/") {
+		return "", false, fmt.Errorf("%q in the build context would be copied outside of the rootfs", name)
+	}
+	return target, true, nil
+}
+
+// entryName returns the cleaned name of a tar entry, without any leading ./ or
+// slashes.
+func entryName(name string) string {
+	return path.Clean(strings.TrimLeft(strings.TrimPrefix(name, "./"), "/"))
+}
+
+// writeManifest writes the manifest as an image's first entry.
+func writeManifest(tw *tar.Writer, manifest *schema.ImageManifest) error {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    "manifest",
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+// directoryHeader returns the header of a directory created within an image.
+func directoryHeader(name string) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		ModTime:  time.Now(),
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// authorizationKey is the metadata key the client's token is sent under.
+const authorizationKey = "authorization"
+
+// TokenCredentials returns the credentials which send the token with each
+// request, to authenticate the client to the Kurma API.
+func TokenCredentials(token string) credentials.Credentials {
+	return tokenCredentials(token)
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context) (map[string]string, error) {
+	return map[string]string{authorizationKey: "Bearer " + string(t)}, nil
+}
+
+// NewTokenContext returns a context carrying the token as though a client had
+// sent it with a request, for requests which don't arrive over gRPC.
+func NewTokenContext(ctx context.Context, token string) context.Context {
+	return metadata.NewContext(ctx, metadata.MD{authorizationKey: "Bearer " + token})
+}
+
+// TokenFromContext returns the token the client sent with the request, or an
+// empty string if it didn't send one.
+func TokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.TrimPrefix(md[authorizationKey], "Bearer ")
+}
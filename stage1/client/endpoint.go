@@ -0,0 +1,79 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ParseEndpoint splits an API endpoint into the network and address to use
+// with it. Endpoints are given as unix:///path/to/socket or tcp://host:port.
+// For convenience, a bare absolute path is treated as a unix socket and any
+// other value without a scheme as a tcp address.
+func ParseEndpoint(endpoint string) (network, address string, err error) {
+	switch {
+	case strings.HasPrefix(endpoint, "unix://"):
+		network, address = "unix", strings.TrimPrefix(endpoint, "unix://")
+	case strings.HasPrefix(endpoint, "tcp://"):
+		network, address = "tcp", strings.TrimPrefix(endpoint, "tcp://")
+	case strings.Contains(endpoint, "://"):
+		return "", "", fmt.Errorf("unsupported endpoint %q", endpoint)
+	case strings.HasPrefix(endpoint, "/"):
+		network, address = "unix", endpoint
+	default:
+		network, address = "tcp", endpoint
+	}
+
+	if address == "" {
+		return "", "", fmt.Errorf("endpoint %q is missing an address", endpoint)
+	}
+	return network, address, nil
+}
+
+// Listen creates a listener for the endpoint. Any stale unix socket left behind
+// at the endpoint's path is removed first, and the new socket is made
+// accessible only to its owner, root, regardless of the umask, since requests
+// over it are trusted.
+func Listen(endpoint string) (net.Listener, error) {
+	network, address, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "unix" {
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := os.Chmod(address, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Dial creates a client connection to the Kurma API at the endpoint.
+func Dial(endpoint string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	network, address, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout(network, addr, timeout)
+	}
+	opts = append(opts, grpc.WithDialer(dialer))
+	return grpc.Dial(address, opts...)
+}
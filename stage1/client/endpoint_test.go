@@ -0,0 +1,66 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		network  string
+		address  string
+	}{
+		{"unix:///var/run/kurma.sock", "unix", "/var/run/kurma.sock"},
+		{"/var/run/kurma.sock", "unix", "/var/run/kurma.sock"},
+		{"tcp://127.0.0.1:12311", "tcp", "127.0.0.1:12311"},
+		{"127.0.0.1:12311", "tcp", "127.0.0.1:12311"},
+		{"kurma.example.com:12312", "tcp", "kurma.example.com:12312"},
+	}
+
+	for _, test := range tests {
+		network, address, err := ParseEndpoint(test.endpoint)
+		if err != nil {
+			t.Fatalf("Expected no error parsing %q; got %s", test.endpoint, err)
+		}
+		if network != test.network || address != test.address {
+			t.Fatalf("Unexpected result parsing %q: %s %s", test.endpoint, network, address)
+		}
+	}
+
+	for _, endpoint := range []string{"unix://", "tcp://", "http://127.0.0.1:12311"} {
+		if _, _, err := ParseEndpoint(endpoint); err == nil {
+			t.Fatalf("Expected an error parsing %q", endpoint)
+		}
+	}
+}
+
+func TestListenUnixMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "endpoint")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a permissive umask mustn't leave the socket open to other users
+	defer syscall.Umask(syscall.Umask(0))
+	path := filepath.Join(dir, "kurma.sock")
+	l, err := Listen("unix://" + path)
+	if err != nil {
+		t.Fatalf("Expected no error listening on %q; got %s", path, err)
+	}
+	defer l.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat the socket: %s", err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Fatalf("Expected the socket to have mode 0600; got %o", mode)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// InsufficientResourcesError describes why the server refused to create or
+// restore a container whose reservation the host can't fit. The Resource is
+// "cpu", in millicores, or "memory", in bytes.
+type InsufficientResourcesError struct {
+	Resource  string
+	Requested int64
+	Available int64
+}
+
+// insufficientFormat is the description the error is sent with, which is
+// parsed back into the error by clients.
+const insufficientFormat = "insufficient %s on the host: %d requested, %d available"
+
+func (e *InsufficientResourcesError) Error() string {
+	return fmt.Sprintf(insufficientFormat, e.Resource, e.Requested, e.Available)
+}
+
+// GRPCError returns the error as it is sent to clients, with the
+// ResourceExhausted code.
+func (e *InsufficientResourcesError) GRPCError() error {
+	return grpc.Errorf(codes.ResourceExhausted, "%s", e.Error())
+}
+
+// InsufficientResources returns the InsufficientResourcesError a request
+// failed with, or nil if it failed for any other reason.
+func InsufficientResources(err error) *InsufficientResourcesError {
+	if grpc.Code(err) != codes.ResourceExhausted {
+		return nil
+	}
+	msg := err.Error()
+	i := strings.Index(msg, " desc = ")
+	if i < 0 {
+		return nil
+	}
+	desc, uerr := strconv.Unquote(msg[i+len(" desc = "):])
+	if uerr != nil {
+		return nil
+	}
+	e := &InsufficientResourcesError{}
+	if _, serr := fmt.Sscanf(desc, insufficientFormat, &e.Resource, &e.Requested, &e.Available); serr != nil {
+		return nil
+	}
+	return e
+}
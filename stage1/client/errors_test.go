@@ -0,0 +1,34 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestInsufficientResources(t *testing.T) {
+	sent := &InsufficientResourcesError{Resource: "memory", Requested: 1 << 30, Available: 512 << 20}
+	err := sent.GRPCError()
+	if grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected the ResourceExhausted code, got %v", grpc.Code(err))
+	}
+	got := InsufficientResources(err)
+	if got == nil || *got != *sent {
+		t.Fatalf("Expected %+v, got %+v", sent, got)
+	}
+
+	for _, err := range []error{
+		nil,
+		errors.New(sent.Error()),
+		grpc.Errorf(codes.ResourceExhausted, "rate limit exceeded"),
+		grpc.Errorf(codes.Unknown, "%s", sent.Error()),
+	} {
+		if got := InsufficientResources(err); got != nil {
+			t.Fatalf("Unexpected insufficient resources from %v: %+v", err, got)
+		}
+	}
+}
@@ -10,11 +10,96 @@ It is generated from these files:
 
 It has these top-level messages:
 	CreateRequest
+	CPUPlacement
+	LogConfig
+	PortMapping
 	CreateResponse
 	ContainerRequest
+	ListRequest
 	ListResponse
+	ExecRequest
+	ExecResponse
+	LogsRequest
+	LogsResponse
+	AttachRequest
+	AttachResponse
+	WaitRequest
+	WaitResponse
+	InspectResponse
+	AppCapabilities
+	StopRequest
+	DestroyRequest
+	CheckpointRequest
+	ShutdownRequest
+	UpdateResourcesRequest
+	StatsRequest
+	StatsResponse
+	NetworkStats
+	PressureStats
+	EventsRequest
+	Event
+	VolumeRequest
+	ListVolumesResponse
+	SecretRequest
+	ListSecretsResponse
+	ApplyRequest
+	ApplyResponse
+	ApplyChange
+	AddJobRequest
+	JobRequest
+	ListJobsResponse
+	Job
+	JobRun
+	NetworkPolicy
+	NetworkPolicyRule
+	NetworkPolicyRequest
+	ListNetworkPoliciesResponse
+	GarbageCollectResponse
+	HostStatusResponse
+	HostUnit
+	HostInfoResponse
+	TimeSync
+	HostStorageResponse
+	RaidArray
+	VolumeGroup
+	HostEncryptionResponse
+	EncryptedDevice
+	ListCrashesResponse
+	CrashRequest
+	Crash
+	CrashFile
+	ReloadConfigResponse
+	ConsoleResponse
+	UpgradeHostRequest
+	UpgradeHostResponse
+	UpdateHostRequest
+	UpdateStatusResponse
+	SystemPartition
+	AttestRequest
+	AttestResponse
+	PCRValue
+	MeasurementEvent
+	ImageRequest
+	CommitRequest
+	CommitResponse
+	BuildRequest
+	BuildSpec
+	BuildStep
+	BuildResponse
+	PushRequest
+	PushProgress
+	UploadAck
 	ByteChunk
 	Container
+	AppStatus
+	VolumeMount
+	SecretMount
+	TmpfsMount
+	HealthCheck
+	InfoResponse
+	Node
+	ListNodesResponse
+	Mount
 	None
 */
 package client
@@ -33,15 +118,116 @@ var _ grpc.ClientConn
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 
+type Event_Type int32
+
+const (
+	Event_CREATED             Event_Type = 0
+	Event_STARTED             Event_Type = 1
+	Event_EXITED              Event_Type = 2
+	Event_OOM_KILLED          Event_Type = 3
+	Event_DESTROYED           Event_Type = 4
+	Event_IMAGE_PULLED        Event_Type = 5
+	Event_DISK_QUOTA_EXCEEDED Event_Type = 6
+	Event_UNHEALTHY           Event_Type = 7
+	Event_HEALTHY             Event_Type = 8
+	Event_STOPPED             Event_Type = 9
+	Event_THROTTLED           Event_Type = 10
+	Event_EVICTED             Event_Type = 11
+)
+
+var Event_Type_name = map[int32]string{
+	0:  "CREATED",
+	1:  "STARTED",
+	2:  "EXITED",
+	3:  "OOM_KILLED",
+	4:  "DESTROYED",
+	5:  "IMAGE_PULLED",
+	6:  "DISK_QUOTA_EXCEEDED",
+	7:  "UNHEALTHY",
+	8:  "HEALTHY",
+	9:  "STOPPED",
+	10: "THROTTLED",
+	11: "EVICTED",
+}
+var Event_Type_value = map[string]int32{
+	"CREATED":             0,
+	"STARTED":             1,
+	"EXITED":              2,
+	"OOM_KILLED":          3,
+	"DESTROYED":           4,
+	"IMAGE_PULLED":        5,
+	"DISK_QUOTA_EXCEEDED": 6,
+	"UNHEALTHY":           7,
+	"HEALTHY":             8,
+	"STOPPED":             9,
+	"THROTTLED":           10,
+	"EVICTED":             11,
+}
+
+func (x Event_Type) String() string {
+	return proto.EnumName(Event_Type_name, int32(x))
+}
+
+type ApplyChange_Action int32
+
+const (
+	ApplyChange_UNCHANGED ApplyChange_Action = 0
+	ApplyChange_CREATED   ApplyChange_Action = 1
+	ApplyChange_REPLACED  ApplyChange_Action = 2
+	ApplyChange_STARTED   ApplyChange_Action = 3
+	ApplyChange_REMOVED   ApplyChange_Action = 4
+)
+
+var ApplyChange_Action_name = map[int32]string{
+	0: "UNCHANGED",
+	1: "CREATED",
+	2: "REPLACED",
+	3: "STARTED",
+	4: "REMOVED",
+}
+var ApplyChange_Action_value = map[string]int32{
+	"UNCHANGED": 0,
+	"CREATED":   1,
+	"REPLACED":  2,
+	"STARTED":   3,
+	"REMOVED":   4,
+}
+
+func (x ApplyChange_Action) String() string {
+	return proto.EnumName(ApplyChange_Action_name, int32(x))
+}
+
+type NetworkPolicyRule_Action int32
+
+const (
+	NetworkPolicyRule_ALLOW NetworkPolicyRule_Action = 0
+	NetworkPolicyRule_DENY  NetworkPolicyRule_Action = 1
+)
+
+var NetworkPolicyRule_Action_name = map[int32]string{
+	0: "ALLOW",
+	1: "DENY",
+}
+var NetworkPolicyRule_Action_value = map[string]int32{
+	"ALLOW": 0,
+	"DENY":  1,
+}
+
+func (x NetworkPolicyRule_Action) String() string {
+	return proto.EnumName(NetworkPolicyRule_Action_name, int32(x))
+}
+
 type Container_State int32
 
 const (
-	Container_NEW      Container_State = 0
-	Container_STARTING Container_State = 1
-	Container_RUNNING  Container_State = 2
-	Container_STOPPING Container_State = 3
-	Container_STOPPED  Container_State = 4
-	Container_EXITED   Container_State = 5
+	Container_NEW          Container_State = 0
+	Container_STARTING     Container_State = 1
+	Container_RUNNING      Container_State = 2
+	Container_STOPPING     Container_State = 3
+	Container_STOPPED      Container_State = 4
+	Container_EXITED       Container_State = 5
+	Container_RESTARTING   Container_State = 6
+	Container_CHECKPOINTED Container_State = 7
 )
 
 var Container_State_name = map[int32]string{
@@ -51,14 +237,18 @@ var Container_State_name = map[int32]string{
 	3: "STOPPING",
 	4: "STOPPED",
 	5: "EXITED",
+	6: "RESTARTING",
+	7: "CHECKPOINTED",
 }
 var Container_State_value = map[string]int32{
-	"NEW":      0,
-	"STARTING": 1,
-	"RUNNING":  2,
-	"STOPPING": 3,
-	"STOPPED":  4,
-	"EXITED":   5,
+	"NEW":          0,
+	"STARTING":     1,
+	"RUNNING":      2,
+	"STOPPING":     3,
+	"STOPPED":      4,
+	"EXITED":       5,
+	"RESTARTING":   6,
+	"CHECKPOINTED": 7,
 }
 
 func (x Container_State) String() string {
@@ -66,14 +256,146 @@ func (x Container_State) String() string {
 }
 
 type CreateRequest struct {
-	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
-	Manifest []byte `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Name            string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Manifest        []byte            `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	ImageUri        string            `protobuf:"bytes,3,opt,name=image_uri" json:"image_uri,omitempty"`
+	Insecure        bool              `protobuf:"varint,4,opt,name=insecure" json:"insecure,omitempty"`
+	Volumes         []*VolumeMount    `protobuf:"bytes,5,rep,name=volumes" json:"volumes,omitempty"`
+	RestartPolicy   string            `protobuf:"bytes,6,opt,name=restart_policy" json:"restart_policy,omitempty"`
+	MaxRetries      int32             `protobuf:"varint,7,opt,name=max_retries" json:"max_retries,omitempty"`
+	PodManifest     []byte            `protobuf:"bytes,8,opt,name=pod_manifest,proto3" json:"pod_manifest,omitempty"`
+	Ports           []*PortMapping    `protobuf:"bytes,9,rep,name=ports" json:"ports,omitempty"`
+	StopGracePeriod int32             `protobuf:"varint,10,opt,name=stop_grace_period" json:"stop_grace_period,omitempty"`
+	ImageHash       string            `protobuf:"bytes,11,opt,name=image_hash" json:"image_hash,omitempty"`
+	Privileged      bool              `protobuf:"varint,12,opt,name=privileged" json:"privileged,omitempty"`
+	SecurityLabel   string            `protobuf:"bytes,13,opt,name=security_label" json:"security_label,omitempty"`
+	ReadOnlyRootfs  bool              `protobuf:"varint,14,opt,name=read_only_rootfs" json:"read_only_rootfs,omitempty"`
+	Tmpfs           []*TmpfsMount     `protobuf:"bytes,15,rep,name=tmpfs" json:"tmpfs,omitempty"`
+	DiskLimit       int64             `protobuf:"varint,16,opt,name=disk_limit" json:"disk_limit,omitempty"`
+	HealthCheck     *HealthCheck      `protobuf:"bytes,17,opt,name=health_check" json:"health_check,omitempty"`
+	Sysctls         map[string]string `protobuf:"bytes,18,rep,name=sysctls" json:"sysctls,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LogConfig       *LogConfig        `protobuf:"bytes,19,opt,name=log_config" json:"log_config,omitempty"`
+	ContainerName   string            `protobuf:"bytes,20,opt,name=container_name" json:"container_name,omitempty"`
+	Labels          map[string]string `protobuf:"bytes,21,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Secrets         []*SecretMount    `protobuf:"bytes,22,rep,name=secrets" json:"secrets,omitempty"`
+	Tty             bool              `protobuf:"varint,23,opt,name=tty" json:"tty,omitempty"`
+	Stdin           bool              `protobuf:"varint,24,opt,name=stdin" json:"stdin,omitempty"`
+	Attach          bool              `protobuf:"varint,25,opt,name=attach" json:"attach,omitempty"`
+	IngressLimit    int64             `protobuf:"varint,26,opt,name=ingress_limit" json:"ingress_limit,omitempty"`
+	EgressLimit     int64             `protobuf:"varint,27,opt,name=egress_limit" json:"egress_limit,omitempty"`
+	Isolation       string            `protobuf:"bytes,28,opt,name=isolation" json:"isolation,omitempty"`
+	Runtime         string            `protobuf:"bytes,29,opt,name=runtime" json:"runtime,omitempty"`
+	CpuPlacement    *CPUPlacement     `protobuf:"bytes,30,opt,name=cpu_placement" json:"cpu_placement,omitempty"`
+	Priority        string            `protobuf:"bytes,31,opt,name=priority" json:"priority,omitempty"`
 }
 
 func (m *CreateRequest) Reset()         { *m = CreateRequest{} }
 func (m *CreateRequest) String() string { return proto.CompactTextString(m) }
 func (*CreateRequest) ProtoMessage()    {}
 
+func (m *CreateRequest) GetVolumes() []*VolumeMount {
+	if m != nil {
+		return m.Volumes
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetPorts() []*PortMapping {
+	if m != nil {
+		return m.Ports
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetTmpfs() []*TmpfsMount {
+	if m != nil {
+		return m.Tmpfs
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetHealthCheck() *HealthCheck {
+	if m != nil {
+		return m.HealthCheck
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetSysctls() map[string]string {
+	if m != nil {
+		return m.Sysctls
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetLogConfig() *LogConfig {
+	if m != nil {
+		return m.LogConfig
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetSecrets() []*SecretMount {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetCpuPlacement() *CPUPlacement {
+	if m != nil {
+		return m.CpuPlacement
+	}
+	return nil
+}
+
+type CPUPlacement struct {
+	ExclusiveCpus int32  `protobuf:"varint,1,opt,name=exclusive_cpus" json:"exclusive_cpus,omitempty"`
+	PinNumaNode   bool   `protobuf:"varint,2,opt,name=pin_numa_node" json:"pin_numa_node,omitempty"`
+	NumaNode      int32  `protobuf:"varint,3,opt,name=numa_node" json:"numa_node,omitempty"`
+	Cpus          string `protobuf:"bytes,4,opt,name=cpus" json:"cpus,omitempty"`
+	Mems          string `protobuf:"bytes,5,opt,name=mems" json:"mems,omitempty"`
+}
+
+func (m *CPUPlacement) Reset()         { *m = CPUPlacement{} }
+func (m *CPUPlacement) String() string { return proto.CompactTextString(m) }
+func (*CPUPlacement) ProtoMessage()    {}
+
+type LogConfig struct {
+	Driver   string            `protobuf:"bytes,1,opt,name=driver" json:"driver,omitempty"`
+	MaxSize  int64             `protobuf:"varint,2,opt,name=max_size" json:"max_size,omitempty"`
+	MaxFiles int32             `protobuf:"varint,3,opt,name=max_files" json:"max_files,omitempty"`
+	Options  map[string]string `protobuf:"bytes,4,rep,name=options" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *LogConfig) Reset()         { *m = LogConfig{} }
+func (m *LogConfig) String() string { return proto.CompactTextString(m) }
+func (*LogConfig) ProtoMessage()    {}
+
+func (m *LogConfig) GetOptions() map[string]string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+type PortMapping struct {
+	Protocol      string `protobuf:"bytes,1,opt,name=protocol" json:"protocol,omitempty"`
+	HostPort      int32  `protobuf:"varint,2,opt,name=host_port" json:"host_port,omitempty"`
+	ContainerPort int32  `protobuf:"varint,3,opt,name=container_port" json:"container_port,omitempty"`
+}
+
+func (m *PortMapping) Reset()         { *m = PortMapping{} }
+func (m *PortMapping) String() string { return proto.CompactTextString(m) }
+func (*PortMapping) ProtoMessage()    {}
+
 type CreateResponse struct {
 	ImageUploadId string     `protobuf:"bytes,1,opt,name=image_upload_id" json:"image_upload_id,omitempty"`
 	Container     *Container `protobuf:"bytes,2,opt,name=container" json:"container,omitempty"`
@@ -98,6 +420,14 @@ func (m *ContainerRequest) Reset()         { *m = ContainerRequest{} }
 func (m *ContainerRequest) String() string { return proto.CompactTextString(m) }
 func (*ContainerRequest) ProtoMessage()    {}
 
+type ListRequest struct {
+	Filters []string `protobuf:"bytes,1,rep,name=filters" json:"filters,omitempty"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+
 type ListResponse struct {
 	Containers []*Container `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
 }
@@ -113,273 +443,2832 @@ func (m *ListResponse) GetContainers() []*Container {
 	return nil
 }
 
-type ByteChunk struct {
-	StreamId string `protobuf:"bytes,1,opt,name=stream_id" json:"stream_id,omitempty"`
-	Bytes    []byte `protobuf:"bytes,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+type ExecRequest struct {
+	Uuid        string   `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Command     []string `protobuf:"bytes,2,rep,name=command" json:"command,omitempty"`
+	Tty         bool     `protobuf:"varint,3,opt,name=tty" json:"tty,omitempty"`
+	Stdin       []byte   `protobuf:"bytes,4,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	StdinClosed bool     `protobuf:"varint,5,opt,name=stdin_closed" json:"stdin_closed,omitempty"`
+	Rows        uint32   `protobuf:"varint,6,opt,name=rows" json:"rows,omitempty"`
+	Cols        uint32   `protobuf:"varint,7,opt,name=cols" json:"cols,omitempty"`
 }
 
-func (m *ByteChunk) Reset()         { *m = ByteChunk{} }
-func (m *ByteChunk) String() string { return proto.CompactTextString(m) }
-func (*ByteChunk) ProtoMessage()    {}
+func (m *ExecRequest) Reset()         { *m = ExecRequest{} }
+func (m *ExecRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecRequest) ProtoMessage()    {}
 
-type Container struct {
-	Uuid     string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
-	Manifest []byte          `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
-	State    Container_State `protobuf:"varint,3,opt,name=state,enum=client.Container_State" json:"state,omitempty"`
+type ExecResponse struct {
+	Stdout   []byte `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr   []byte `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Exited   bool   `protobuf:"varint,3,opt,name=exited" json:"exited,omitempty"`
+	ExitCode int32  `protobuf:"varint,4,opt,name=exit_code" json:"exit_code,omitempty"`
 }
 
-func (m *Container) Reset()         { *m = Container{} }
-func (m *Container) String() string { return proto.CompactTextString(m) }
-func (*Container) ProtoMessage()    {}
+func (m *ExecResponse) Reset()         { *m = ExecResponse{} }
+func (m *ExecResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecResponse) ProtoMessage()    {}
 
-type None struct {
+type LogsRequest struct {
+	Uuid   string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Follow bool   `protobuf:"varint,2,opt,name=follow" json:"follow,omitempty"`
+	Tail   int32  `protobuf:"varint,3,opt,name=tail" json:"tail,omitempty"`
+	Since  int64  `protobuf:"varint,4,opt,name=since" json:"since,omitempty"`
 }
 
-func (m *None) Reset()         { *m = None{} }
-func (m *None) String() string { return proto.CompactTextString(m) }
-func (*None) ProtoMessage()    {}
+func (m *LogsRequest) Reset()         { *m = LogsRequest{} }
+func (m *LogsRequest) String() string { return proto.CompactTextString(m) }
+func (*LogsRequest) ProtoMessage()    {}
 
-func init() {
-	proto.RegisterEnum("client.Container_State", Container_State_name, Container_State_value)
+type LogsResponse struct {
+	Stdout []byte `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr []byte `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
 }
 
-// Client API for Kurma service
+func (m *LogsResponse) Reset()         { *m = LogsResponse{} }
+func (m *LogsResponse) String() string { return proto.CompactTextString(m) }
+func (*LogsResponse) ProtoMessage()    {}
 
-type KurmaClient interface {
-	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
-	UploadImage(ctx context.Context, opts ...grpc.CallOption) (Kurma_UploadImageClient, error)
-	Destroy(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error)
-	List(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListResponse, error)
-	Get(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*Container, error)
-	Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma_EnterClient, error)
+type AttachRequest struct {
+	Uuid        string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Stdin       []byte `protobuf:"bytes,2,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Rows        uint32 `protobuf:"varint,3,opt,name=rows" json:"rows,omitempty"`
+	Cols        uint32 `protobuf:"varint,4,opt,name=cols" json:"cols,omitempty"`
+	StdinClosed bool   `protobuf:"varint,5,opt,name=stdin_closed" json:"stdin_closed,omitempty"`
 }
 
-type kurmaClient struct {
-	cc *grpc.ClientConn
-}
+func (m *AttachRequest) Reset()         { *m = AttachRequest{} }
+func (m *AttachRequest) String() string { return proto.CompactTextString(m) }
+func (*AttachRequest) ProtoMessage()    {}
 
-func NewKurmaClient(cc *grpc.ClientConn) KurmaClient {
-	return &kurmaClient{cc}
+type AttachResponse struct {
+	Output []byte `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Stderr []byte `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
 }
 
-func (c *kurmaClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
-	out := new(CreateResponse)
-	err := grpc.Invoke(ctx, "/client.Kurma/Create", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
+func (m *AttachResponse) Reset()         { *m = AttachResponse{} }
+func (m *AttachResponse) String() string { return proto.CompactTextString(m) }
+func (*AttachResponse) ProtoMessage()    {}
 
-func (c *kurmaClient) UploadImage(ctx context.Context, opts ...grpc.CallOption) (Kurma_UploadImageClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[0], c.cc, "/client.Kurma/UploadImage", opts...)
-	if err != nil {
-		return nil, err
-	}
-	x := &kurmaUploadImageClient{stream}
-	return x, nil
+type WaitRequest struct {
+	Uuid    string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Timeout int32  `protobuf:"varint,2,opt,name=timeout" json:"timeout,omitempty"`
 }
 
-type Kurma_UploadImageClient interface {
-	Send(*ByteChunk) error
-	CloseAndRecv() (*None, error)
-	grpc.ClientStream
-}
+func (m *WaitRequest) Reset()         { *m = WaitRequest{} }
+func (m *WaitRequest) String() string { return proto.CompactTextString(m) }
+func (*WaitRequest) ProtoMessage()    {}
 
-type kurmaUploadImageClient struct {
-	grpc.ClientStream
+type WaitResponse struct {
+	ExitCode  int32           `protobuf:"varint,1,opt,name=exit_code" json:"exit_code,omitempty"`
+	OomKilled bool            `protobuf:"varint,2,opt,name=oom_killed" json:"oom_killed,omitempty"`
+	State     Container_State `protobuf:"varint,3,opt,name=state,enum=kurma.v1.Container_State" json:"state,omitempty"`
+	TimedOut  bool            `protobuf:"varint,4,opt,name=timed_out" json:"timed_out,omitempty"`
 }
 
-func (x *kurmaUploadImageClient) Send(m *ByteChunk) error {
-	return x.ClientStream.SendMsg(m)
+func (m *WaitResponse) Reset()         { *m = WaitResponse{} }
+func (m *WaitResponse) String() string { return proto.CompactTextString(m) }
+func (*WaitResponse) ProtoMessage()    {}
+
+type InspectResponse struct {
+	Container      *Container         `protobuf:"bytes,1,opt,name=container" json:"container,omitempty"`
+	Cgroups        []string           `protobuf:"bytes,2,rep,name=cgroups" json:"cgroups,omitempty"`
+	Addresses      []string           `protobuf:"bytes,3,rep,name=addresses" json:"addresses,omitempty"`
+	StartTime      int64              `protobuf:"varint,4,opt,name=start_time" json:"start_time,omitempty"`
+	Pid            int32              `protobuf:"varint,5,opt,name=pid" json:"pid,omitempty"`
+	ExitCode       int32              `protobuf:"varint,6,opt,name=exit_code" json:"exit_code,omitempty"`
+	Mounts         []*Mount           `protobuf:"bytes,7,rep,name=mounts" json:"mounts,omitempty"`
+	OomKills       int64              `protobuf:"varint,8,opt,name=oom_kills" json:"oom_kills,omitempty"`
+	Capabilities   []*AppCapabilities `protobuf:"bytes,9,rep,name=capabilities" json:"capabilities,omitempty"`
+	Privileged     bool               `protobuf:"varint,10,opt,name=privileged" json:"privileged,omitempty"`
+	SecurityLabel  string             `protobuf:"bytes,11,opt,name=security_label" json:"security_label,omitempty"`
+	ReadOnlyRootfs bool               `protobuf:"varint,12,opt,name=read_only_rootfs" json:"read_only_rootfs,omitempty"`
+	LogConfig      *LogConfig         `protobuf:"bytes,13,opt,name=log_config" json:"log_config,omitempty"`
+	Isolation      string             `protobuf:"bytes,14,opt,name=isolation" json:"isolation,omitempty"`
+	Runtime        string             `protobuf:"bytes,15,opt,name=runtime" json:"runtime,omitempty"`
+	CpuPlacement   *CPUPlacement      `protobuf:"bytes,16,opt,name=cpu_placement" json:"cpu_placement,omitempty"`
+	Priority       string             `protobuf:"bytes,17,opt,name=priority" json:"priority,omitempty"`
 }
 
-func (x *kurmaUploadImageClient) CloseAndRecv() (*None, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(None)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *InspectResponse) Reset()         { *m = InspectResponse{} }
+func (m *InspectResponse) String() string { return proto.CompactTextString(m) }
+func (*InspectResponse) ProtoMessage()    {}
+
+func (m *InspectResponse) GetContainer() *Container {
+	if m != nil {
+		return m.Container
 	}
-	return m, nil
+	return nil
 }
 
-func (c *kurmaClient) Destroy(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error) {
-	out := new(None)
-	err := grpc.Invoke(ctx, "/client.Kurma/Destroy", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *InspectResponse) GetMounts() []*Mount {
+	if m != nil {
+		return m.Mounts
 	}
-	return out, nil
+	return nil
 }
 
-func (c *kurmaClient) List(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListResponse, error) {
-	out := new(ListResponse)
-	err := grpc.Invoke(ctx, "/client.Kurma/List", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *InspectResponse) GetCapabilities() []*AppCapabilities {
+	if m != nil {
+		return m.Capabilities
 	}
-	return out, nil
+	return nil
 }
 
-func (c *kurmaClient) Get(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*Container, error) {
-	out := new(Container)
-	err := grpc.Invoke(ctx, "/client.Kurma/Get", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *InspectResponse) GetLogConfig() *LogConfig {
+	if m != nil {
+		return m.LogConfig
 	}
-	return out, nil
+	return nil
 }
 
-func (c *kurmaClient) Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma_EnterClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[1], c.cc, "/client.Kurma/Enter", opts...)
-	if err != nil {
-		return nil, err
+func (m *InspectResponse) GetCpuPlacement() *CPUPlacement {
+	if m != nil {
+		return m.CpuPlacement
 	}
-	x := &kurmaEnterClient{stream}
-	return x, nil
+	return nil
 }
 
-type Kurma_EnterClient interface {
-	Send(*ByteChunk) error
-	Recv() (*ByteChunk, error)
-	grpc.ClientStream
+type AppCapabilities struct {
+	App          string   `protobuf:"bytes,1,opt,name=app" json:"app,omitempty"`
+	Capabilities []string `protobuf:"bytes,2,rep,name=capabilities" json:"capabilities,omitempty"`
 }
 
-type kurmaEnterClient struct {
-	grpc.ClientStream
+func (m *AppCapabilities) Reset()         { *m = AppCapabilities{} }
+func (m *AppCapabilities) String() string { return proto.CompactTextString(m) }
+func (*AppCapabilities) ProtoMessage()    {}
+
+type StopRequest struct {
+	Uuid        string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	GracePeriod int32  `protobuf:"varint,2,opt,name=grace_period" json:"grace_period,omitempty"`
 }
 
-func (x *kurmaEnterClient) Send(m *ByteChunk) error {
-	return x.ClientStream.SendMsg(m)
+func (m *StopRequest) Reset()         { *m = StopRequest{} }
+func (m *StopRequest) String() string { return proto.CompactTextString(m) }
+func (*StopRequest) ProtoMessage()    {}
+
+type DestroyRequest struct {
+	Uuid  string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Force bool   `protobuf:"varint,2,opt,name=force" json:"force,omitempty"`
 }
 
-func (x *kurmaEnterClient) Recv() (*ByteChunk, error) {
-	m := new(ByteChunk)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+func (m *DestroyRequest) Reset()         { *m = DestroyRequest{} }
+func (m *DestroyRequest) String() string { return proto.CompactTextString(m) }
+func (*DestroyRequest) ProtoMessage()    {}
+
+type CheckpointRequest struct {
+	Uuid         string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	LeaveRunning bool   `protobuf:"varint,2,opt,name=leave_running" json:"leave_running,omitempty"`
 }
 
-// Server API for Kurma service
+func (m *CheckpointRequest) Reset()         { *m = CheckpointRequest{} }
+func (m *CheckpointRequest) String() string { return proto.CompactTextString(m) }
+func (*CheckpointRequest) ProtoMessage()    {}
 
-type KurmaServer interface {
-	Create(context.Context, *CreateRequest) (*CreateResponse, error)
-	UploadImage(Kurma_UploadImageServer) error
-	Destroy(context.Context, *ContainerRequest) (*None, error)
-	List(context.Context, *None) (*ListResponse, error)
-	Get(context.Context, *ContainerRequest) (*Container, error)
-	Enter(Kurma_EnterServer) error
+type ShutdownRequest struct {
+	Reboot bool `protobuf:"varint,1,opt,name=reboot" json:"reboot,omitempty"`
 }
 
-func RegisterKurmaServer(s *grpc.Server, srv KurmaServer) {
-	s.RegisterService(&_Kurma_serviceDesc, srv)
+func (m *ShutdownRequest) Reset()         { *m = ShutdownRequest{} }
+func (m *ShutdownRequest) String() string { return proto.CompactTextString(m) }
+func (*ShutdownRequest) ProtoMessage()    {}
+
+type UpdateResourcesRequest struct {
+	Uuid        string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Memory      int64  `protobuf:"varint,2,opt,name=memory" json:"memory,omitempty"`
+	CpuShares   int64  `protobuf:"varint,3,opt,name=cpu_shares" json:"cpu_shares,omitempty"`
+	BlkioWeight int64  `protobuf:"varint,4,opt,name=blkio_weight" json:"blkio_weight,omitempty"`
 }
 
-func _Kurma_Create_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
-	in := new(CreateRequest)
-	if err := codec.Unmarshal(buf, in); err != nil {
-		return nil, err
-	}
-	out, err := srv.(KurmaServer).Create(ctx, in)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *UpdateResourcesRequest) Reset()         { *m = UpdateResourcesRequest{} }
+func (m *UpdateResourcesRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateResourcesRequest) ProtoMessage()    {}
+
+type StatsRequest struct {
+	Uuid     string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Interval int32  `protobuf:"varint,2,opt,name=interval" json:"interval,omitempty"`
 }
 
-func _Kurma_UploadImage_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(KurmaServer).UploadImage(&kurmaUploadImageServer{stream})
+func (m *StatsRequest) Reset()         { *m = StatsRequest{} }
+func (m *StatsRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()    {}
+
+type StatsResponse struct {
+	Uuid         string           `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Time         int64            `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	CpuUsage     int64            `protobuf:"varint,3,opt,name=cpu_usage" json:"cpu_usage,omitempty"`
+	MemoryUsage  int64            `protobuf:"varint,4,opt,name=memory_usage" json:"memory_usage,omitempty"`
+	MemoryLimit  int64            `protobuf:"varint,5,opt,name=memory_limit" json:"memory_limit,omitempty"`
+	BlkioRead    int64            `protobuf:"varint,6,opt,name=blkio_read" json:"blkio_read,omitempty"`
+	BlkioWrite   int64            `protobuf:"varint,7,opt,name=blkio_write" json:"blkio_write,omitempty"`
+	Networks     []*NetworkStats  `protobuf:"bytes,8,rep,name=networks" json:"networks,omitempty"`
+	OomKills     int64            `protobuf:"varint,9,opt,name=oom_kills" json:"oom_kills,omitempty"`
+	DiskUsage    int64            `protobuf:"varint,10,opt,name=disk_usage" json:"disk_usage,omitempty"`
+	DiskLimit    int64            `protobuf:"varint,11,opt,name=disk_limit" json:"disk_limit,omitempty"`
+	IngressLimit int64            `protobuf:"varint,12,opt,name=ingress_limit" json:"ingress_limit,omitempty"`
+	EgressLimit  int64            `protobuf:"varint,13,opt,name=egress_limit" json:"egress_limit,omitempty"`
+	Pressure     []*PressureStats `protobuf:"bytes,14,rep,name=pressure" json:"pressure,omitempty"`
 }
 
-type Kurma_UploadImageServer interface {
-	SendAndClose(*None) error
-	Recv() (*ByteChunk, error)
-	grpc.ServerStream
+func (m *StatsResponse) Reset()         { *m = StatsResponse{} }
+func (m *StatsResponse) String() string { return proto.CompactTextString(m) }
+func (*StatsResponse) ProtoMessage()    {}
+
+func (m *StatsResponse) GetNetworks() []*NetworkStats {
+	if m != nil {
+		return m.Networks
+	}
+	return nil
 }
 
-type kurmaUploadImageServer struct {
-	grpc.ServerStream
+func (m *StatsResponse) GetPressure() []*PressureStats {
+	if m != nil {
+		return m.Pressure
+	}
+	return nil
 }
 
-func (x *kurmaUploadImageServer) SendAndClose(m *None) error {
-	return x.ServerStream.SendMsg(m)
+type NetworkStats struct {
+	Interface string `protobuf:"bytes,1,opt,name=interface" json:"interface,omitempty"`
+	RxBytes   int64  `protobuf:"varint,2,opt,name=rx_bytes" json:"rx_bytes,omitempty"`
+	RxPackets int64  `protobuf:"varint,3,opt,name=rx_packets" json:"rx_packets,omitempty"`
+	TxBytes   int64  `protobuf:"varint,4,opt,name=tx_bytes" json:"tx_bytes,omitempty"`
+	TxPackets int64  `protobuf:"varint,5,opt,name=tx_packets" json:"tx_packets,omitempty"`
 }
 
-func (x *kurmaUploadImageServer) Recv() (*ByteChunk, error) {
-	m := new(ByteChunk)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+func (m *NetworkStats) Reset()         { *m = NetworkStats{} }
+func (m *NetworkStats) String() string { return proto.CompactTextString(m) }
+func (*NetworkStats) ProtoMessage()    {}
+
+type PressureStats struct {
+	Resource   string  `protobuf:"bytes,1,opt,name=resource" json:"resource,omitempty"`
+	SomeAvg10  float64 `protobuf:"fixed64,2,opt,name=some_avg10" json:"some_avg10,omitempty"`
+	SomeAvg60  float64 `protobuf:"fixed64,3,opt,name=some_avg60" json:"some_avg60,omitempty"`
+	SomeAvg300 float64 `protobuf:"fixed64,4,opt,name=some_avg300" json:"some_avg300,omitempty"`
+	SomeTotal  int64   `protobuf:"varint,5,opt,name=some_total" json:"some_total,omitempty"`
+	FullAvg10  float64 `protobuf:"fixed64,6,opt,name=full_avg10" json:"full_avg10,omitempty"`
+	FullAvg60  float64 `protobuf:"fixed64,7,opt,name=full_avg60" json:"full_avg60,omitempty"`
+	FullAvg300 float64 `protobuf:"fixed64,8,opt,name=full_avg300" json:"full_avg300,omitempty"`
+	FullTotal  int64   `protobuf:"varint,9,opt,name=full_total" json:"full_total,omitempty"`
 }
 
-func _Kurma_Destroy_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
-	in := new(ContainerRequest)
-	if err := codec.Unmarshal(buf, in); err != nil {
-		return nil, err
-	}
-	out, err := srv.(KurmaServer).Destroy(ctx, in)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *PressureStats) Reset()         { *m = PressureStats{} }
+func (m *PressureStats) String() string { return proto.CompactTextString(m) }
+func (*PressureStats) ProtoMessage()    {}
+
+type EventsRequest struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
 }
 
-func _Kurma_List_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
-	in := new(None)
-	if err := codec.Unmarshal(buf, in); err != nil {
-		return nil, err
-	}
-	out, err := srv.(KurmaServer).List(ctx, in)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *EventsRequest) Reset()         { *m = EventsRequest{} }
+func (m *EventsRequest) String() string { return proto.CompactTextString(m) }
+func (*EventsRequest) ProtoMessage()    {}
+
+type Event struct {
+	Type     Event_Type `protobuf:"varint,1,opt,name=type,enum=kurma.v1.Event_Type" json:"type,omitempty"`
+	Time     int64      `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	Uuid     string     `protobuf:"bytes,3,opt,name=uuid" json:"uuid,omitempty"`
+	App      string     `protobuf:"bytes,4,opt,name=app" json:"app,omitempty"`
+	Image    string     `protobuf:"bytes,5,opt,name=image" json:"image,omitempty"`
+	ExitCode int32      `protobuf:"varint,6,opt,name=exit_code" json:"exit_code,omitempty"`
+	Reason   string     `protobuf:"bytes,7,opt,name=reason" json:"reason,omitempty"`
 }
 
-func _Kurma_Get_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
-	in := new(ContainerRequest)
-	if err := codec.Unmarshal(buf, in); err != nil {
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+
+type VolumeRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *VolumeRequest) Reset()         { *m = VolumeRequest{} }
+func (m *VolumeRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeRequest) ProtoMessage()    {}
+
+type ListVolumesResponse struct {
+	Volumes []string `protobuf:"bytes,1,rep,name=volumes" json:"volumes,omitempty"`
+}
+
+func (m *ListVolumesResponse) Reset()         { *m = ListVolumesResponse{} }
+func (m *ListVolumesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListVolumesResponse) ProtoMessage()    {}
+
+type SecretRequest struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *SecretRequest) Reset()         { *m = SecretRequest{} }
+func (m *SecretRequest) String() string { return proto.CompactTextString(m) }
+func (*SecretRequest) ProtoMessage()    {}
+
+type ListSecretsResponse struct {
+	Secrets []string `protobuf:"bytes,1,rep,name=secrets" json:"secrets,omitempty"`
+}
+
+func (m *ListSecretsResponse) Reset()         { *m = ListSecretsResponse{} }
+func (m *ListSecretsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSecretsResponse) ProtoMessage()    {}
+
+type ApplyRequest struct {
+	Containers []*CreateRequest `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
+	DryRun     bool             `protobuf:"varint,2,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *ApplyRequest) Reset()         { *m = ApplyRequest{} }
+func (m *ApplyRequest) String() string { return proto.CompactTextString(m) }
+func (*ApplyRequest) ProtoMessage()    {}
+
+func (m *ApplyRequest) GetContainers() []*CreateRequest {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type ApplyResponse struct {
+	Changes []*ApplyChange `protobuf:"bytes,1,rep,name=changes" json:"changes,omitempty"`
+}
+
+func (m *ApplyResponse) Reset()         { *m = ApplyResponse{} }
+func (m *ApplyResponse) String() string { return proto.CompactTextString(m) }
+func (*ApplyResponse) ProtoMessage()    {}
+
+func (m *ApplyResponse) GetChanges() []*ApplyChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+type ApplyChange struct {
+	Name   string             `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Action ApplyChange_Action `protobuf:"varint,2,opt,name=action,enum=kurma.v1.ApplyChange_Action" json:"action,omitempty"`
+	Uuid   string             `protobuf:"bytes,3,opt,name=uuid" json:"uuid,omitempty"`
+	Error  string             `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ApplyChange) Reset()         { *m = ApplyChange{} }
+func (m *ApplyChange) String() string { return proto.CompactTextString(m) }
+func (*ApplyChange) ProtoMessage()    {}
+
+type AddJobRequest struct {
+	Name     string         `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Schedule string         `protobuf:"bytes,2,opt,name=schedule" json:"schedule,omitempty"`
+	Create   *CreateRequest `protobuf:"bytes,3,opt,name=create" json:"create,omitempty"`
+}
+
+func (m *AddJobRequest) Reset()         { *m = AddJobRequest{} }
+func (m *AddJobRequest) String() string { return proto.CompactTextString(m) }
+func (*AddJobRequest) ProtoMessage()    {}
+
+func (m *AddJobRequest) GetCreate() *CreateRequest {
+	if m != nil {
+		return m.Create
+	}
+	return nil
+}
+
+type JobRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *JobRequest) Reset()         { *m = JobRequest{} }
+func (m *JobRequest) String() string { return proto.CompactTextString(m) }
+func (*JobRequest) ProtoMessage()    {}
+
+type ListJobsResponse struct {
+	Jobs []*Job `protobuf:"bytes,1,rep,name=jobs" json:"jobs,omitempty"`
+}
+
+func (m *ListJobsResponse) Reset()         { *m = ListJobsResponse{} }
+func (m *ListJobsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListJobsResponse) ProtoMessage()    {}
+
+func (m *ListJobsResponse) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+type Job struct {
+	Name     string         `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Schedule string         `protobuf:"bytes,2,opt,name=schedule" json:"schedule,omitempty"`
+	Create   *CreateRequest `protobuf:"bytes,3,opt,name=create" json:"create,omitempty"`
+	Created  int64          `protobuf:"varint,4,opt,name=created" json:"created,omitempty"`
+	NextRun  int64          `protobuf:"varint,5,opt,name=next_run" json:"next_run,omitempty"`
+	Running  bool           `protobuf:"varint,6,opt,name=running" json:"running,omitempty"`
+	Runs     []*JobRun      `protobuf:"bytes,7,rep,name=runs" json:"runs,omitempty"`
+}
+
+func (m *Job) Reset()         { *m = Job{} }
+func (m *Job) String() string { return proto.CompactTextString(m) }
+func (*Job) ProtoMessage()    {}
+
+func (m *Job) GetCreate() *CreateRequest {
+	if m != nil {
+		return m.Create
+	}
+	return nil
+}
+
+func (m *Job) GetRuns() []*JobRun {
+	if m != nil {
+		return m.Runs
+	}
+	return nil
+}
+
+type JobRun struct {
+	Start     int64  `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	End       int64  `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+	Container string `protobuf:"bytes,3,opt,name=container" json:"container,omitempty"`
+	ExitCode  int32  `protobuf:"varint,4,opt,name=exit_code" json:"exit_code,omitempty"`
+	OomKilled bool   `protobuf:"varint,5,opt,name=oom_killed" json:"oom_killed,omitempty"`
+	Error     string `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *JobRun) Reset()         { *m = JobRun{} }
+func (m *JobRun) String() string { return proto.CompactTextString(m) }
+func (*JobRun) ProtoMessage()    {}
+
+type NetworkPolicy struct {
+	Name        string               `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Selector    map[string]string    `protobuf:"bytes,2,rep,name=selector" json:"selector,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Host        bool                 `protobuf:"varint,3,opt,name=host" json:"host,omitempty"`
+	Rules       []*NetworkPolicyRule `protobuf:"bytes,4,rep,name=rules" json:"rules,omitempty"`
+	DefaultDeny bool                 `protobuf:"varint,5,opt,name=default_deny" json:"default_deny,omitempty"`
+}
+
+func (m *NetworkPolicy) Reset()         { *m = NetworkPolicy{} }
+func (m *NetworkPolicy) String() string { return proto.CompactTextString(m) }
+func (*NetworkPolicy) ProtoMessage()    {}
+
+func (m *NetworkPolicy) GetSelector() map[string]string {
+	if m != nil {
+		return m.Selector
+	}
+	return nil
+}
+
+func (m *NetworkPolicy) GetRules() []*NetworkPolicyRule {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
+type NetworkPolicyRule struct {
+	Action   NetworkPolicyRule_Action `protobuf:"varint,1,opt,name=action,enum=kurma.v1.NetworkPolicyRule_Action" json:"action,omitempty"`
+	From     map[string]string        `protobuf:"bytes,2,rep,name=from" json:"from,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Protocol string                   `protobuf:"bytes,3,opt,name=protocol" json:"protocol,omitempty"`
+	Port     int32                    `protobuf:"varint,4,opt,name=port" json:"port,omitempty"`
+}
+
+func (m *NetworkPolicyRule) Reset()         { *m = NetworkPolicyRule{} }
+func (m *NetworkPolicyRule) String() string { return proto.CompactTextString(m) }
+func (*NetworkPolicyRule) ProtoMessage()    {}
+
+func (m *NetworkPolicyRule) GetFrom() map[string]string {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+type NetworkPolicyRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *NetworkPolicyRequest) Reset()         { *m = NetworkPolicyRequest{} }
+func (m *NetworkPolicyRequest) String() string { return proto.CompactTextString(m) }
+func (*NetworkPolicyRequest) ProtoMessage()    {}
+
+type ListNetworkPoliciesResponse struct {
+	Policies []*NetworkPolicy `protobuf:"bytes,1,rep,name=policies" json:"policies,omitempty"`
+}
+
+func (m *ListNetworkPoliciesResponse) Reset()         { *m = ListNetworkPoliciesResponse{} }
+func (m *ListNetworkPoliciesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListNetworkPoliciesResponse) ProtoMessage()    {}
+
+func (m *ListNetworkPoliciesResponse) GetPolicies() []*NetworkPolicy {
+	if m != nil {
+		return m.Policies
+	}
+	return nil
+}
+
+type GarbageCollectResponse struct {
+	Containers []string `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
+	Images     []string `protobuf:"bytes,2,rep,name=images" json:"images,omitempty"`
+	ImageBytes int64    `protobuf:"varint,3,opt,name=image_bytes" json:"image_bytes,omitempty"`
+	Layers     []string `protobuf:"bytes,4,rep,name=layers" json:"layers,omitempty"`
+}
+
+func (m *GarbageCollectResponse) Reset()         { *m = GarbageCollectResponse{} }
+func (m *GarbageCollectResponse) String() string { return proto.CompactTextString(m) }
+func (*GarbageCollectResponse) ProtoMessage()    {}
+
+type HostStatusResponse struct {
+	Units []*HostUnit `protobuf:"bytes,1,rep,name=units" json:"units,omitempty"`
+}
+
+func (m *HostStatusResponse) Reset()         { *m = HostStatusResponse{} }
+func (m *HostStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*HostStatusResponse) ProtoMessage()    {}
+
+func (m *HostStatusResponse) GetUnits() []*HostUnit {
+	if m != nil {
+		return m.Units
+	}
+	return nil
+}
+
+type HostUnit struct {
+	Name     string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	State    string   `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	Error    string   `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Requires []string `protobuf:"bytes,4,rep,name=requires" json:"requires,omitempty"`
+	After    []string `protobuf:"bytes,5,rep,name=after" json:"after,omitempty"`
+	Before   []string `protobuf:"bytes,6,rep,name=before" json:"before,omitempty"`
+	Started  int64    `protobuf:"varint,7,opt,name=started" json:"started,omitempty"`
+	Finished int64    `protobuf:"varint,8,opt,name=finished" json:"finished,omitempty"`
+}
+
+func (m *HostUnit) Reset()         { *m = HostUnit{} }
+func (m *HostUnit) String() string { return proto.CompactTextString(m) }
+func (*HostUnit) ProtoMessage()    {}
+
+type HostInfoResponse struct {
+	Hostname string    `protobuf:"bytes,1,opt,name=hostname" json:"hostname,omitempty"`
+	Kernel   string    `protobuf:"bytes,2,opt,name=kernel" json:"kernel,omitempty"`
+	Time     int64     `protobuf:"varint,3,opt,name=time" json:"time,omitempty"`
+	Uptime   int64     `protobuf:"varint,4,opt,name=uptime" json:"uptime,omitempty"`
+	TimeSync *TimeSync `protobuf:"bytes,5,opt,name=time_sync" json:"time_sync,omitempty"`
+}
+
+func (m *HostInfoResponse) Reset()         { *m = HostInfoResponse{} }
+func (m *HostInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*HostInfoResponse) ProtoMessage()    {}
+
+func (m *HostInfoResponse) GetTimeSync() *TimeSync {
+	if m != nil {
+		return m.TimeSync
+	}
+	return nil
+}
+
+type TimeSync struct {
+	Synchronized bool     `protobuf:"varint,1,opt,name=synchronized" json:"synchronized,omitempty"`
+	Servers      []string `protobuf:"bytes,2,rep,name=servers" json:"servers,omitempty"`
+	Server       string   `protobuf:"bytes,3,opt,name=server" json:"server,omitempty"`
+	Offset       int64    `protobuf:"varint,4,opt,name=offset" json:"offset,omitempty"`
+	LastSync     int64    `protobuf:"varint,5,opt,name=last_sync" json:"last_sync,omitempty"`
+	Error        string   `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *TimeSync) Reset()         { *m = TimeSync{} }
+func (m *TimeSync) String() string { return proto.CompactTextString(m) }
+func (*TimeSync) ProtoMessage()    {}
+
+type HostStorageResponse struct {
+	Arrays       []*RaidArray   `protobuf:"bytes,1,rep,name=arrays" json:"arrays,omitempty"`
+	VolumeGroups []*VolumeGroup `protobuf:"bytes,2,rep,name=volume_groups" json:"volume_groups,omitempty"`
+}
+
+func (m *HostStorageResponse) Reset()         { *m = HostStorageResponse{} }
+func (m *HostStorageResponse) String() string { return proto.CompactTextString(m) }
+func (*HostStorageResponse) ProtoMessage()    {}
+
+func (m *HostStorageResponse) GetArrays() []*RaidArray {
+	if m != nil {
+		return m.Arrays
+	}
+	return nil
+}
+
+func (m *HostStorageResponse) GetVolumeGroups() []*VolumeGroup {
+	if m != nil {
+		return m.VolumeGroups
+	}
+	return nil
+}
+
+type RaidArray struct {
+	Name         string  `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Device       string  `protobuf:"bytes,2,opt,name=device" json:"device,omitempty"`
+	Level        string  `protobuf:"bytes,3,opt,name=level" json:"level,omitempty"`
+	State        string  `protobuf:"bytes,4,opt,name=state" json:"state,omitempty"`
+	Devices      int32   `protobuf:"varint,5,opt,name=devices" json:"devices,omitempty"`
+	Missing      int32   `protobuf:"varint,6,opt,name=missing" json:"missing,omitempty"`
+	SyncAction   string  `protobuf:"bytes,7,opt,name=sync_action" json:"sync_action,omitempty"`
+	SyncProgress float64 `protobuf:"fixed64,8,opt,name=sync_progress" json:"sync_progress,omitempty"`
+	Error        string  `protobuf:"bytes,9,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RaidArray) Reset()         { *m = RaidArray{} }
+func (m *RaidArray) String() string { return proto.CompactTextString(m) }
+func (*RaidArray) ProtoMessage()    {}
+
+type VolumeGroup struct {
+	Name    string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Size    int64    `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Free    int64    `protobuf:"varint,3,opt,name=free" json:"free,omitempty"`
+	Volumes []string `protobuf:"bytes,4,rep,name=volumes" json:"volumes,omitempty"`
+	Error   string   `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *VolumeGroup) Reset()         { *m = VolumeGroup{} }
+func (m *VolumeGroup) String() string { return proto.CompactTextString(m) }
+func (*VolumeGroup) ProtoMessage()    {}
+
+type HostEncryptionResponse struct {
+	Devices []*EncryptedDevice `protobuf:"bytes,1,rep,name=devices" json:"devices,omitempty"`
+}
+
+func (m *HostEncryptionResponse) Reset()         { *m = HostEncryptionResponse{} }
+func (m *HostEncryptionResponse) String() string { return proto.CompactTextString(m) }
+func (*HostEncryptionResponse) ProtoMessage()    {}
+
+func (m *HostEncryptionResponse) GetDevices() []*EncryptedDevice {
+	if m != nil {
+		return m.Devices
+	}
+	return nil
+}
+
+type EncryptedDevice struct {
+	Name         string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Device       string `protobuf:"bytes,2,opt,name=device" json:"device,omitempty"`
+	MappedDevice string `protobuf:"bytes,3,opt,name=mapped_device" json:"mapped_device,omitempty"`
+	Unlocked     bool   `protobuf:"varint,4,opt,name=unlocked" json:"unlocked,omitempty"`
+	Type         string `protobuf:"bytes,5,opt,name=type" json:"type,omitempty"`
+	Cipher       string `protobuf:"bytes,6,opt,name=cipher" json:"cipher,omitempty"`
+	KeySize      int32  `protobuf:"varint,7,opt,name=key_size" json:"key_size,omitempty"`
+	KeySource    string `protobuf:"bytes,8,opt,name=key_source" json:"key_source,omitempty"`
+	Error        string `protobuf:"bytes,9,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *EncryptedDevice) Reset()         { *m = EncryptedDevice{} }
+func (m *EncryptedDevice) String() string { return proto.CompactTextString(m) }
+func (*EncryptedDevice) ProtoMessage()    {}
+
+type ListCrashesResponse struct {
+	Crashes []*Crash `protobuf:"bytes,1,rep,name=crashes" json:"crashes,omitempty"`
+}
+
+func (m *ListCrashesResponse) Reset()         { *m = ListCrashesResponse{} }
+func (m *ListCrashesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListCrashesResponse) ProtoMessage()    {}
+
+func (m *ListCrashesResponse) GetCrashes() []*Crash {
+	if m != nil {
+		return m.Crashes
+	}
+	return nil
+}
+
+type CrashRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *CrashRequest) Reset()         { *m = CrashRequest{} }
+func (m *CrashRequest) String() string { return proto.CompactTextString(m) }
+func (*CrashRequest) ProtoMessage()    {}
+
+type Crash struct {
+	Name  string       `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Time  int64        `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	Files []*CrashFile `protobuf:"bytes,3,rep,name=files" json:"files,omitempty"`
+}
+
+func (m *Crash) Reset()         { *m = Crash{} }
+func (m *Crash) String() string { return proto.CompactTextString(m) }
+func (*Crash) ProtoMessage()    {}
+
+func (m *Crash) GetFiles() []*CrashFile {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+type CrashFile struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Size int64  `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *CrashFile) Reset()         { *m = CrashFile{} }
+func (m *CrashFile) String() string { return proto.CompactTextString(m) }
+func (*CrashFile) ProtoMessage()    {}
+
+type ReloadConfigResponse struct {
+	Applied         []string `protobuf:"bytes,1,rep,name=applied" json:"applied,omitempty"`
+	RequiresRestart []string `protobuf:"bytes,2,rep,name=requires_restart" json:"requires_restart,omitempty"`
+	Failed          []string `protobuf:"bytes,3,rep,name=failed" json:"failed,omitempty"`
+}
+
+func (m *ReloadConfigResponse) Reset()         { *m = ReloadConfigResponse{} }
+func (m *ReloadConfigResponse) String() string { return proto.CompactTextString(m) }
+func (*ReloadConfigResponse) ProtoMessage()    {}
+
+type ConsoleResponse struct {
+	Uuid  string   `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Shell []string `protobuf:"bytes,2,rep,name=shell" json:"shell,omitempty"`
+}
+
+func (m *ConsoleResponse) Reset()         { *m = ConsoleResponse{} }
+func (m *ConsoleResponse) String() string { return proto.CompactTextString(m) }
+func (*ConsoleResponse) ProtoMessage()    {}
+
+type UpgradeHostRequest struct {
+	Url string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+}
+
+func (m *UpgradeHostRequest) Reset()         { *m = UpgradeHostRequest{} }
+func (m *UpgradeHostRequest) String() string { return proto.CompactTextString(m) }
+func (*UpgradeHostRequest) ProtoMessage()    {}
+
+type UpgradeHostResponse struct {
+	Digest string `protobuf:"bytes,1,opt,name=digest" json:"digest,omitempty"`
+}
+
+func (m *UpgradeHostResponse) Reset()         { *m = UpgradeHostResponse{} }
+func (m *UpgradeHostResponse) String() string { return proto.CompactTextString(m) }
+func (*UpgradeHostResponse) ProtoMessage()    {}
+
+type UpdateHostRequest struct {
+	Url    string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+	Reboot bool   `protobuf:"varint,2,opt,name=reboot" json:"reboot,omitempty"`
+}
+
+func (m *UpdateHostRequest) Reset()         { *m = UpdateHostRequest{} }
+func (m *UpdateHostRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateHostRequest) ProtoMessage()    {}
+
+type UpdateStatusResponse struct {
+	Disk       string             `protobuf:"bytes,1,opt,name=disk" json:"disk,omitempty"`
+	Partitions []*SystemPartition `protobuf:"bytes,2,rep,name=partitions" json:"partitions,omitempty"`
+}
+
+func (m *UpdateStatusResponse) Reset()         { *m = UpdateStatusResponse{} }
+func (m *UpdateStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateStatusResponse) ProtoMessage()    {}
+
+func (m *UpdateStatusResponse) GetPartitions() []*SystemPartition {
+	if m != nil {
+		return m.Partitions
+	}
+	return nil
+}
+
+type SystemPartition struct {
+	Name          string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Device        string `protobuf:"bytes,2,opt,name=device" json:"device,omitempty"`
+	State         string `protobuf:"bytes,3,opt,name=state" json:"state,omitempty"`
+	Booted        bool   `protobuf:"varint,4,opt,name=booted" json:"booted,omitempty"`
+	Priority      int32  `protobuf:"varint,5,opt,name=priority" json:"priority,omitempty"`
+	Tries         int32  `protobuf:"varint,6,opt,name=tries" json:"tries,omitempty"`
+	Successful    bool   `protobuf:"varint,7,opt,name=successful" json:"successful,omitempty"`
+	TrialDeadline int64  `protobuf:"varint,8,opt,name=trial_deadline" json:"trial_deadline,omitempty"`
+}
+
+func (m *SystemPartition) Reset()         { *m = SystemPartition{} }
+func (m *SystemPartition) String() string { return proto.CompactTextString(m) }
+func (*SystemPartition) ProtoMessage()    {}
+
+type AttestRequest struct {
+	Nonce []byte `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *AttestRequest) Reset()         { *m = AttestRequest{} }
+func (m *AttestRequest) String() string { return proto.CompactTextString(m) }
+func (*AttestRequest) ProtoMessage()    {}
+
+type AttestResponse struct {
+	Quote          []byte              `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
+	Signature      []byte              `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	AttestationKey string              `protobuf:"bytes,3,opt,name=attestation_key" json:"attestation_key,omitempty"`
+	HashAlgorithm  string              `protobuf:"bytes,4,opt,name=hash_algorithm" json:"hash_algorithm,omitempty"`
+	Pcrs           []*PCRValue         `protobuf:"bytes,5,rep,name=pcrs" json:"pcrs,omitempty"`
+	Events         []*MeasurementEvent `protobuf:"bytes,6,rep,name=events" json:"events,omitempty"`
+}
+
+func (m *AttestResponse) Reset()         { *m = AttestResponse{} }
+func (m *AttestResponse) String() string { return proto.CompactTextString(m) }
+func (*AttestResponse) ProtoMessage()    {}
+
+func (m *AttestResponse) GetPcrs() []*PCRValue {
+	if m != nil {
+		return m.Pcrs
+	}
+	return nil
+}
+
+func (m *AttestResponse) GetEvents() []*MeasurementEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+type PCRValue struct {
+	Index  int32  `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (m *PCRValue) Reset()         { *m = PCRValue{} }
+func (m *PCRValue) String() string { return proto.CompactTextString(m) }
+func (*PCRValue) ProtoMessage()    {}
+
+type MeasurementEvent struct {
+	Pcr         int32  `protobuf:"varint,1,opt,name=pcr" json:"pcr,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+	Digest      []byte `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (m *MeasurementEvent) Reset()         { *m = MeasurementEvent{} }
+func (m *MeasurementEvent) String() string { return proto.CompactTextString(m) }
+func (*MeasurementEvent) ProtoMessage()    {}
+
+type ImageRequest struct {
+	Uri string `protobuf:"bytes,1,opt,name=uri" json:"uri,omitempty"`
+}
+
+func (m *ImageRequest) Reset()         { *m = ImageRequest{} }
+func (m *ImageRequest) String() string { return proto.CompactTextString(m) }
+func (*ImageRequest) ProtoMessage()    {}
+
+type CommitRequest struct {
+	Uuid   string            `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Name   string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Labels map[string]string `protobuf:"bytes,3,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CommitRequest) Reset()         { *m = CommitRequest{} }
+func (m *CommitRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitRequest) ProtoMessage()    {}
+
+func (m *CommitRequest) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type CommitResponse struct {
+	Uri       string `protobuf:"bytes,1,opt,name=uri" json:"uri,omitempty"`
+	ImageHash string `protobuf:"bytes,2,opt,name=image_hash" json:"image_hash,omitempty"`
+	Size      int64  `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *CommitResponse) Reset()         { *m = CommitResponse{} }
+func (m *CommitResponse) String() string { return proto.CompactTextString(m) }
+func (*CommitResponse) ProtoMessage()    {}
+
+type BuildRequest struct {
+	Spec    *BuildSpec `protobuf:"bytes,1,opt,name=spec" json:"spec,omitempty"`
+	Context []byte     `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`
+}
+
+func (m *BuildRequest) Reset()         { *m = BuildRequest{} }
+func (m *BuildRequest) String() string { return proto.CompactTextString(m) }
+func (*BuildRequest) ProtoMessage()    {}
+
+func (m *BuildRequest) GetSpec() *BuildSpec {
+	if m != nil {
+		return m.Spec
+	}
+	return nil
+}
+
+type BuildSpec struct {
+	Base     string            `protobuf:"bytes,1,opt,name=base" json:"base,omitempty"`
+	Insecure bool              `protobuf:"varint,2,opt,name=insecure" json:"insecure,omitempty"`
+	Name     string            `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	Labels   map[string]string `protobuf:"bytes,4,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Steps    []*BuildStep      `protobuf:"bytes,5,rep,name=steps" json:"steps,omitempty"`
+}
+
+func (m *BuildSpec) Reset()         { *m = BuildSpec{} }
+func (m *BuildSpec) String() string { return proto.CompactTextString(m) }
+func (*BuildSpec) ProtoMessage()    {}
+
+func (m *BuildSpec) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *BuildSpec) GetSteps() []*BuildStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+type BuildStep struct {
+	Run         []string `protobuf:"bytes,1,rep,name=run" json:"run,omitempty"`
+	Source      string   `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
+	Destination string   `protobuf:"bytes,3,opt,name=destination" json:"destination,omitempty"`
+}
+
+func (m *BuildStep) Reset()         { *m = BuildStep{} }
+func (m *BuildStep) String() string { return proto.CompactTextString(m) }
+func (*BuildStep) ProtoMessage()    {}
+
+type BuildResponse struct {
+	Output []byte          `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Stderr []byte          `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Image  *CommitResponse `protobuf:"bytes,3,opt,name=image" json:"image,omitempty"`
+}
+
+func (m *BuildResponse) Reset()         { *m = BuildResponse{} }
+func (m *BuildResponse) String() string { return proto.CompactTextString(m) }
+func (*BuildResponse) ProtoMessage()    {}
+
+func (m *BuildResponse) GetImage() *CommitResponse {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+type PushRequest struct {
+	Uri         string `protobuf:"bytes,1,opt,name=uri" json:"uri,omitempty"`
+	Destination string `protobuf:"bytes,2,opt,name=destination" json:"destination,omitempty"`
+	Insecure    bool   `protobuf:"varint,3,opt,name=insecure" json:"insecure,omitempty"`
+	Username    string `protobuf:"bytes,4,opt,name=username" json:"username,omitempty"`
+	Password    string `protobuf:"bytes,5,opt,name=password" json:"password,omitempty"`
+}
+
+func (m *PushRequest) Reset()         { *m = PushRequest{} }
+func (m *PushRequest) String() string { return proto.CompactTextString(m) }
+func (*PushRequest) ProtoMessage()    {}
+
+type PushProgress struct {
+	Sent     int64  `protobuf:"varint,1,opt,name=sent" json:"sent,omitempty"`
+	Total    int64  `protobuf:"varint,2,opt,name=total" json:"total,omitempty"`
+	Location string `protobuf:"bytes,3,opt,name=location" json:"location,omitempty"`
+}
+
+func (m *PushProgress) Reset()         { *m = PushProgress{} }
+func (m *PushProgress) String() string { return proto.CompactTextString(m) }
+func (*PushProgress) ProtoMessage()    {}
+
+type UploadAck struct {
+	Offset    int64      `protobuf:"varint,1,opt,name=offset" json:"offset,omitempty"`
+	Container *Container `protobuf:"bytes,2,opt,name=container" json:"container,omitempty"`
+}
+
+func (m *UploadAck) Reset()         { *m = UploadAck{} }
+func (m *UploadAck) String() string { return proto.CompactTextString(m) }
+func (*UploadAck) ProtoMessage()    {}
+
+func (m *UploadAck) GetContainer() *Container {
+	if m != nil {
+		return m.Container
+	}
+	return nil
+}
+
+type ByteChunk struct {
+	StreamId string `protobuf:"bytes,1,opt,name=stream_id" json:"stream_id,omitempty"`
+	Bytes    []byte `protobuf:"bytes,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Offset   int64  `protobuf:"varint,3,opt,name=offset" json:"offset,omitempty"`
+}
+
+func (m *ByteChunk) Reset()         { *m = ByteChunk{} }
+func (m *ByteChunk) String() string { return proto.CompactTextString(m) }
+func (*ByteChunk) ProtoMessage()    {}
+
+type Container struct {
+	Uuid          string            `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Manifest      []byte            `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	State         Container_State   `protobuf:"varint,3,opt,name=state,enum=kurma.v1.Container_State" json:"state,omitempty"`
+	RestartPolicy string            `protobuf:"bytes,4,opt,name=restart_policy" json:"restart_policy,omitempty"`
+	Restarts      int32             `protobuf:"varint,5,opt,name=restarts" json:"restarts,omitempty"`
+	Apps          []*AppStatus      `protobuf:"bytes,6,rep,name=apps" json:"apps,omitempty"`
+	Ports         []*PortMapping    `protobuf:"bytes,7,rep,name=ports" json:"ports,omitempty"`
+	Health        string            `protobuf:"bytes,8,opt,name=health" json:"health,omitempty"`
+	Name          string            `protobuf:"bytes,9,opt,name=name" json:"name,omitempty"`
+	Labels        map[string]string `protobuf:"bytes,10,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Container) Reset()         { *m = Container{} }
+func (m *Container) String() string { return proto.CompactTextString(m) }
+func (*Container) ProtoMessage()    {}
+
+func (m *Container) GetApps() []*AppStatus {
+	if m != nil {
+		return m.Apps
+	}
+	return nil
+}
+
+func (m *Container) GetPorts() []*PortMapping {
+	if m != nil {
+		return m.Ports
+	}
+	return nil
+}
+
+func (m *Container) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type AppStatus struct {
+	Name     string          `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	State    Container_State `protobuf:"varint,2,opt,name=state,enum=kurma.v1.Container_State" json:"state,omitempty"`
+	ExitCode int32           `protobuf:"varint,3,opt,name=exit_code" json:"exit_code,omitempty"`
+	Restarts int32           `protobuf:"varint,4,opt,name=restarts" json:"restarts,omitempty"`
+}
+
+func (m *AppStatus) Reset()         { *m = AppStatus{} }
+func (m *AppStatus) String() string { return proto.CompactTextString(m) }
+func (*AppStatus) ProtoMessage()    {}
+
+type VolumeMount struct {
+	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Path     string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	ReadOnly bool   `protobuf:"varint,3,opt,name=read_only" json:"read_only,omitempty"`
+}
+
+func (m *VolumeMount) Reset()         { *m = VolumeMount{} }
+func (m *VolumeMount) String() string { return proto.CompactTextString(m) }
+func (*VolumeMount) ProtoMessage()    {}
+
+type SecretMount struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	Env  string `protobuf:"bytes,3,opt,name=env" json:"env,omitempty"`
+}
+
+func (m *SecretMount) Reset()         { *m = SecretMount{} }
+func (m *SecretMount) String() string { return proto.CompactTextString(m) }
+func (*SecretMount) ProtoMessage()    {}
+
+type TmpfsMount struct {
+	Path string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Size int64  `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *TmpfsMount) Reset()         { *m = TmpfsMount{} }
+func (m *TmpfsMount) String() string { return proto.CompactTextString(m) }
+func (*TmpfsMount) ProtoMessage()    {}
+
+type HealthCheck struct {
+	Exec      []string `protobuf:"bytes,1,rep,name=exec" json:"exec,omitempty"`
+	TcpPort   int32    `protobuf:"varint,2,opt,name=tcp_port" json:"tcp_port,omitempty"`
+	HttpPort  int32    `protobuf:"varint,3,opt,name=http_port" json:"http_port,omitempty"`
+	HttpPath  string   `protobuf:"bytes,4,opt,name=http_path" json:"http_path,omitempty"`
+	Interval  int32    `protobuf:"varint,5,opt,name=interval" json:"interval,omitempty"`
+	Timeout   int32    `protobuf:"varint,6,opt,name=timeout" json:"timeout,omitempty"`
+	Threshold int32    `protobuf:"varint,7,opt,name=threshold" json:"threshold,omitempty"`
+}
+
+func (m *HealthCheck) Reset()         { *m = HealthCheck{} }
+func (m *HealthCheck) String() string { return proto.CompactTextString(m) }
+func (*HealthCheck) ProtoMessage()    {}
+
+type InfoResponse struct {
+	Version           string           `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	MinApiVersion     int32            `protobuf:"varint,2,opt,name=min_api_version" json:"min_api_version,omitempty"`
+	MaxApiVersion     int32            `protobuf:"varint,3,opt,name=max_api_version" json:"max_api_version,omitempty"`
+	Hostname          string           `protobuf:"bytes,4,opt,name=hostname" json:"hostname,omitempty"`
+	Kernel            string           `protobuf:"bytes,5,opt,name=kernel" json:"kernel,omitempty"`
+	Uptime            int64            `protobuf:"varint,6,opt,name=uptime" json:"uptime,omitempty"`
+	StorageDriver     string           `protobuf:"bytes,7,opt,name=storage_driver" json:"storage_driver,omitempty"`
+	CgroupRoot        string           `protobuf:"bytes,8,opt,name=cgroup_root" json:"cgroup_root,omitempty"`
+	CgroupParent      string           `protobuf:"bytes,9,opt,name=cgroup_parent" json:"cgroup_parent,omitempty"`
+	CgroupControllers []string         `protobuf:"bytes,10,rep,name=cgroup_controllers" json:"cgroup_controllers,omitempty"`
+	Cpus              int32            `protobuf:"varint,11,opt,name=cpus" json:"cpus,omitempty"`
+	MemoryTotal       int64            `protobuf:"varint,12,opt,name=memory_total" json:"memory_total,omitempty"`
+	MemoryAvailable   int64            `protobuf:"varint,13,opt,name=memory_available" json:"memory_available,omitempty"`
+	DiskTotal         int64            `protobuf:"varint,14,opt,name=disk_total" json:"disk_total,omitempty"`
+	DiskAvailable     int64            `protobuf:"varint,15,opt,name=disk_available" json:"disk_available,omitempty"`
+	Containers        map[string]int32 `protobuf:"bytes,16,rep,name=containers" json:"containers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Runtimes          []string         `protobuf:"bytes,17,rep,name=runtimes" json:"runtimes,omitempty"`
+	CgroupVersion     int32            `protobuf:"varint,18,opt,name=cgroup_version" json:"cgroup_version,omitempty"`
+	Pressure          []*PressureStats `protobuf:"bytes,19,rep,name=pressure" json:"pressure,omitempty"`
+	CpuAllocatable    int64            `protobuf:"varint,20,opt,name=cpu_allocatable" json:"cpu_allocatable,omitempty"`
+	MemoryAllocatable int64            `protobuf:"varint,21,opt,name=memory_allocatable" json:"memory_allocatable,omitempty"`
+	CpuReserved       int64            `protobuf:"varint,22,opt,name=cpu_reserved" json:"cpu_reserved,omitempty"`
+	MemoryReserved    int64            `protobuf:"varint,23,opt,name=memory_reserved" json:"memory_reserved,omitempty"`
+}
+
+func (m *InfoResponse) Reset()         { *m = InfoResponse{} }
+func (m *InfoResponse) String() string { return proto.CompactTextString(m) }
+func (*InfoResponse) ProtoMessage()    {}
+
+func (m *InfoResponse) GetContainers() map[string]int32 {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+func (m *InfoResponse) GetPressure() []*PressureStats {
+	if m != nil {
+		return m.Pressure
+	}
+	return nil
+}
+
+type Node struct {
+	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Endpoint string `protobuf:"bytes,2,opt,name=endpoint" json:"endpoint,omitempty"`
+	Self     bool   `protobuf:"varint,3,opt,name=self" json:"self,omitempty"`
+}
+
+func (m *Node) Reset()         { *m = Node{} }
+func (m *Node) String() string { return proto.CompactTextString(m) }
+func (*Node) ProtoMessage()    {}
+
+type ListNodesResponse struct {
+	Nodes []*Node `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty"`
+}
+
+func (m *ListNodesResponse) Reset()         { *m = ListNodesResponse{} }
+func (m *ListNodesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListNodesResponse) ProtoMessage()    {}
+
+func (m *ListNodesResponse) GetNodes() []*Node {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+type Mount struct {
+	Source      string `protobuf:"bytes,1,opt,name=source" json:"source,omitempty"`
+	Destination string `protobuf:"bytes,2,opt,name=destination" json:"destination,omitempty"`
+	ReadOnly    bool   `protobuf:"varint,3,opt,name=read_only" json:"read_only,omitempty"`
+}
+
+func (m *Mount) Reset()         { *m = Mount{} }
+func (m *Mount) String() string { return proto.CompactTextString(m) }
+func (*Mount) ProtoMessage()    {}
+
+type None struct {
+}
+
+func (m *None) Reset()         { *m = None{} }
+func (m *None) String() string { return proto.CompactTextString(m) }
+func (*None) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterEnum("kurma.v1.Event_Type", Event_Type_name, Event_Type_value)
+	proto.RegisterEnum("kurma.v1.ApplyChange_Action", ApplyChange_Action_name, ApplyChange_Action_value)
+	proto.RegisterEnum("kurma.v1.NetworkPolicyRule_Action", NetworkPolicyRule_Action_name, NetworkPolicyRule_Action_value)
+	proto.RegisterEnum("kurma.v1.Container_State", Container_State_name, Container_State_value)
+}
+
+// Client API for Kurma service
+
+type KurmaClient interface {
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	UploadImage(ctx context.Context, opts ...grpc.CallOption) (Kurma_UploadImageClient, error)
+	Destroy(ctx context.Context, in *DestroyRequest, opts ...grpc.CallOption) (*None, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Get(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*Container, error)
+	Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma_EnterClient, error)
+	Exec(ctx context.Context, opts ...grpc.CallOption) (Kurma_ExecClient, error)
+	Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Kurma_LogsClient, error)
+	Attach(ctx context.Context, opts ...grpc.CallOption) (Kurma_AttachClient, error)
+	Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error)
+	CreateVolume(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*None, error)
+	ListVolumes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListVolumesResponse, error)
+	DeleteVolume(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*None, error)
+	CreateSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*None, error)
+	ListSecrets(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	DeleteSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*None, error)
+	AddJob(ctx context.Context, in *AddJobRequest, opts ...grpc.CallOption) (*None, error)
+	ListJobs(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	RemoveJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*None, error)
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
+	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*None, error)
+	Start(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error)
+	Restart(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*None, error)
+	Inspect(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*InspectResponse, error)
+	UpdateResources(ctx context.Context, in *UpdateResourcesRequest, opts ...grpc.CallOption) (*None, error)
+	Stats(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	StreamStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Kurma_StreamStatsClient, error)
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Kurma_EventsClient, error)
+	Checkpoint(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (*None, error)
+	Restore(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error)
+	Shutdown(ctx context.Context, in *ShutdownRequest, opts ...grpc.CallOption) (*None, error)
+	GarbageCollect(ctx context.Context, in *None, opts ...grpc.CallOption) (*GarbageCollectResponse, error)
+	HostStatus(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostStatusResponse, error)
+	ReloadConfig(ctx context.Context, in *None, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+	HostInfo(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostInfoResponse, error)
+	HostStorage(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostStorageResponse, error)
+	HostEncryption(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostEncryptionResponse, error)
+	ListCrashes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListCrashesResponse, error)
+	GetCrash(ctx context.Context, in *CrashRequest, opts ...grpc.CallOption) (*Crash, error)
+	Info(ctx context.Context, in *None, opts ...grpc.CallOption) (*InfoResponse, error)
+	ListNodes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListNodesResponse, error)
+	SetNetworkPolicy(ctx context.Context, in *NetworkPolicy, opts ...grpc.CallOption) (*None, error)
+	ListNetworkPolicies(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListNetworkPoliciesResponse, error)
+	RemoveNetworkPolicy(ctx context.Context, in *NetworkPolicyRequest, opts ...grpc.CallOption) (*None, error)
+	StartConsole(ctx context.Context, in *None, opts ...grpc.CallOption) (*ConsoleResponse, error)
+	UpgradeHost(ctx context.Context, in *UpgradeHostRequest, opts ...grpc.CallOption) (*UpgradeHostResponse, error)
+	UpdateStatus(ctx context.Context, in *None, opts ...grpc.CallOption) (*UpdateStatusResponse, error)
+	UpdateHost(ctx context.Context, in *UpdateHostRequest, opts ...grpc.CallOption) (*UpdateStatusResponse, error)
+	AttestHost(ctx context.Context, in *AttestRequest, opts ...grpc.CallOption) (*AttestResponse, error)
+	ExportImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (Kurma_ExportImageClient, error)
+	Export(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (Kurma_ExportClient, error)
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
+	Build(ctx context.Context, opts ...grpc.CallOption) (Kurma_BuildClient, error)
+	PushImage(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (Kurma_PushImageClient, error)
+}
+
+type kurmaClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKurmaClient(cc *grpc.ClientConn) KurmaClient {
+	return &kurmaClient{cc}
+}
+
+func (c *kurmaClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Create", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) UploadImage(ctx context.Context, opts ...grpc.CallOption) (Kurma_UploadImageClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[0], c.cc, "/kurma.v1.Kurma/UploadImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaUploadImageClient{stream}
+	return x, nil
+}
+
+type Kurma_UploadImageClient interface {
+	Send(*ByteChunk) error
+	Recv() (*UploadAck, error)
+	grpc.ClientStream
+}
+
+type kurmaUploadImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaUploadImageClient) Send(m *ByteChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaUploadImageClient) Recv() (*UploadAck, error) {
+	m := new(UploadAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Destroy(ctx context.Context, in *DestroyRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Destroy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/List", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Get(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*Container, error) {
+	out := new(Container)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Get", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma_EnterClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[1], c.cc, "/kurma.v1.Kurma/Enter", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaEnterClient{stream}
+	return x, nil
+}
+
+type Kurma_EnterClient interface {
+	Send(*ByteChunk) error
+	Recv() (*ByteChunk, error)
+	grpc.ClientStream
+}
+
+type kurmaEnterClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaEnterClient) Send(m *ByteChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaEnterClient) Recv() (*ByteChunk, error) {
+	m := new(ByteChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Exec(ctx context.Context, opts ...grpc.CallOption) (Kurma_ExecClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[2], c.cc, "/kurma.v1.Kurma/Exec", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaExecClient{stream}
+	return x, nil
+}
+
+type Kurma_ExecClient interface {
+	Send(*ExecRequest) error
+	Recv() (*ExecResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaExecClient) Send(m *ExecRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaExecClient) Recv() (*ExecResponse, error) {
+	m := new(ExecResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Kurma_LogsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[3], c.cc, "/kurma.v1.Kurma/Logs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_LogsClient interface {
+	Recv() (*LogsResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaLogsClient) Recv() (*LogsResponse, error) {
+	m := new(LogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Attach(ctx context.Context, opts ...grpc.CallOption) (Kurma_AttachClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[4], c.cc, "/kurma.v1.Kurma/Attach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaAttachClient{stream}
+	return x, nil
+}
+
+type Kurma_AttachClient interface {
+	Send(*AttachRequest) error
+	Recv() (*AttachResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaAttachClient) Send(m *AttachRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaAttachClient) Recv() (*AttachResponse, error) {
+	m := new(AttachResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error) {
+	out := new(WaitResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Wait", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) CreateVolume(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/CreateVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListVolumes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListVolumesResponse, error) {
+	out := new(ListVolumesResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListVolumes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) DeleteVolume(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/DeleteVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) CreateSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/CreateSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListSecrets(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListSecretsResponse, error) {
+	out := new(ListSecretsResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListSecrets", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) DeleteSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/DeleteSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) AddJob(ctx context.Context, in *AddJobRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/AddJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListJobs(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	out := new(ListJobsResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListJobs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) RemoveJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/RemoveJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error) {
+	out := new(ApplyResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Apply", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Stop", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Start(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Start", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Restart(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Restart", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Inspect(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*InspectResponse, error) {
+	out := new(InspectResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Inspect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) UpdateResources(ctx context.Context, in *UpdateResourcesRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/UpdateResources", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Stats(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Stats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) StreamStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Kurma_StreamStatsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[5], c.cc, "/kurma.v1.Kurma/StreamStats", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaStreamStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_StreamStatsClient interface {
+	Recv() (*StatsResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaStreamStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaStreamStatsClient) Recv() (*StatsResponse, error) {
+	m := new(StatsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Kurma_EventsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[6], c.cc, "/kurma.v1.Kurma/Events", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_EventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type kurmaEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Checkpoint(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Checkpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Restore(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Restore", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Shutdown(ctx context.Context, in *ShutdownRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Shutdown", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) GarbageCollect(ctx context.Context, in *None, opts ...grpc.CallOption) (*GarbageCollectResponse, error) {
+	out := new(GarbageCollectResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/GarbageCollect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) HostStatus(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostStatusResponse, error) {
+	out := new(HostStatusResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/HostStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ReloadConfig(ctx context.Context, in *None, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	out := new(ReloadConfigResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ReloadConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) HostInfo(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostInfoResponse, error) {
+	out := new(HostInfoResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/HostInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) HostStorage(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostStorageResponse, error) {
+	out := new(HostStorageResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/HostStorage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) HostEncryption(ctx context.Context, in *None, opts ...grpc.CallOption) (*HostEncryptionResponse, error) {
+	out := new(HostEncryptionResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/HostEncryption", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListCrashes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListCrashesResponse, error) {
+	out := new(ListCrashesResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListCrashes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) GetCrash(ctx context.Context, in *CrashRequest, opts ...grpc.CallOption) (*Crash, error) {
+	out := new(Crash)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/GetCrash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Info(ctx context.Context, in *None, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Info", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListNodes(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListNodesResponse, error) {
+	out := new(ListNodesResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListNodes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) SetNetworkPolicy(ctx context.Context, in *NetworkPolicy, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/SetNetworkPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ListNetworkPolicies(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListNetworkPoliciesResponse, error) {
+	out := new(ListNetworkPoliciesResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/ListNetworkPolicies", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) RemoveNetworkPolicy(ctx context.Context, in *NetworkPolicyRequest, opts ...grpc.CallOption) (*None, error) {
+	out := new(None)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/RemoveNetworkPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) StartConsole(ctx context.Context, in *None, opts ...grpc.CallOption) (*ConsoleResponse, error) {
+	out := new(ConsoleResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/StartConsole", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) UpgradeHost(ctx context.Context, in *UpgradeHostRequest, opts ...grpc.CallOption) (*UpgradeHostResponse, error) {
+	out := new(UpgradeHostResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/UpgradeHost", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) UpdateStatus(ctx context.Context, in *None, opts ...grpc.CallOption) (*UpdateStatusResponse, error) {
+	out := new(UpdateStatusResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/UpdateStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) UpdateHost(ctx context.Context, in *UpdateHostRequest, opts ...grpc.CallOption) (*UpdateStatusResponse, error) {
+	out := new(UpdateStatusResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/UpdateHost", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) AttestHost(ctx context.Context, in *AttestRequest, opts ...grpc.CallOption) (*AttestResponse, error) {
+	out := new(AttestResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/AttestHost", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) ExportImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (Kurma_ExportImageClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[7], c.cc, "/kurma.v1.Kurma/ExportImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaExportImageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_ExportImageClient interface {
+	Recv() (*ByteChunk, error)
+	grpc.ClientStream
+}
+
+type kurmaExportImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaExportImageClient) Recv() (*ByteChunk, error) {
+	m := new(ByteChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Export(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (Kurma_ExportClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[8], c.cc, "/kurma.v1.Kurma/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_ExportClient interface {
+	Recv() (*ByteChunk, error)
+	grpc.ClientStream
+}
+
+type kurmaExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaExportClient) Recv() (*ByteChunk, error) {
+	m := new(ByteChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error) {
+	out := new(CommitResponse)
+	err := grpc.Invoke(ctx, "/kurma.v1.Kurma/Commit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) Build(ctx context.Context, opts ...grpc.CallOption) (Kurma_BuildClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[9], c.cc, "/kurma.v1.Kurma/Build", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaBuildClient{stream}
+	return x, nil
+}
+
+type Kurma_BuildClient interface {
+	Send(*BuildRequest) error
+	Recv() (*BuildResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaBuildClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaBuildClient) Send(m *BuildRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaBuildClient) Recv() (*BuildResponse, error) {
+	m := new(BuildResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) PushImage(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (Kurma_PushImageClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[10], c.cc, "/kurma.v1.Kurma/PushImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaPushImageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_PushImageClient interface {
+	Recv() (*PushProgress, error)
+	grpc.ClientStream
+}
+
+type kurmaPushImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaPushImageClient) Recv() (*PushProgress, error) {
+	m := new(PushProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Kurma service
+
+type KurmaServer interface {
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	UploadImage(Kurma_UploadImageServer) error
+	Destroy(context.Context, *DestroyRequest) (*None, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Get(context.Context, *ContainerRequest) (*Container, error)
+	Enter(Kurma_EnterServer) error
+	Exec(Kurma_ExecServer) error
+	Logs(*LogsRequest, Kurma_LogsServer) error
+	Attach(Kurma_AttachServer) error
+	Wait(context.Context, *WaitRequest) (*WaitResponse, error)
+	CreateVolume(context.Context, *VolumeRequest) (*None, error)
+	ListVolumes(context.Context, *None) (*ListVolumesResponse, error)
+	DeleteVolume(context.Context, *VolumeRequest) (*None, error)
+	CreateSecret(context.Context, *SecretRequest) (*None, error)
+	ListSecrets(context.Context, *None) (*ListSecretsResponse, error)
+	DeleteSecret(context.Context, *SecretRequest) (*None, error)
+	AddJob(context.Context, *AddJobRequest) (*None, error)
+	ListJobs(context.Context, *None) (*ListJobsResponse, error)
+	RemoveJob(context.Context, *JobRequest) (*None, error)
+	Apply(context.Context, *ApplyRequest) (*ApplyResponse, error)
+	Stop(context.Context, *StopRequest) (*None, error)
+	Start(context.Context, *ContainerRequest) (*None, error)
+	Restart(context.Context, *StopRequest) (*None, error)
+	Inspect(context.Context, *ContainerRequest) (*InspectResponse, error)
+	UpdateResources(context.Context, *UpdateResourcesRequest) (*None, error)
+	Stats(context.Context, *ContainerRequest) (*StatsResponse, error)
+	StreamStats(*StatsRequest, Kurma_StreamStatsServer) error
+	Events(*EventsRequest, Kurma_EventsServer) error
+	Checkpoint(context.Context, *CheckpointRequest) (*None, error)
+	Restore(context.Context, *ContainerRequest) (*None, error)
+	Shutdown(context.Context, *ShutdownRequest) (*None, error)
+	GarbageCollect(context.Context, *None) (*GarbageCollectResponse, error)
+	HostStatus(context.Context, *None) (*HostStatusResponse, error)
+	ReloadConfig(context.Context, *None) (*ReloadConfigResponse, error)
+	HostInfo(context.Context, *None) (*HostInfoResponse, error)
+	HostStorage(context.Context, *None) (*HostStorageResponse, error)
+	HostEncryption(context.Context, *None) (*HostEncryptionResponse, error)
+	ListCrashes(context.Context, *None) (*ListCrashesResponse, error)
+	GetCrash(context.Context, *CrashRequest) (*Crash, error)
+	Info(context.Context, *None) (*InfoResponse, error)
+	ListNodes(context.Context, *None) (*ListNodesResponse, error)
+	SetNetworkPolicy(context.Context, *NetworkPolicy) (*None, error)
+	ListNetworkPolicies(context.Context, *None) (*ListNetworkPoliciesResponse, error)
+	RemoveNetworkPolicy(context.Context, *NetworkPolicyRequest) (*None, error)
+	StartConsole(context.Context, *None) (*ConsoleResponse, error)
+	UpgradeHost(context.Context, *UpgradeHostRequest) (*UpgradeHostResponse, error)
+	UpdateStatus(context.Context, *None) (*UpdateStatusResponse, error)
+	UpdateHost(context.Context, *UpdateHostRequest) (*UpdateStatusResponse, error)
+	AttestHost(context.Context, *AttestRequest) (*AttestResponse, error)
+	ExportImage(*ImageRequest, Kurma_ExportImageServer) error
+	Export(*ContainerRequest, Kurma_ExportServer) error
+	Commit(context.Context, *CommitRequest) (*CommitResponse, error)
+	Build(Kurma_BuildServer) error
+	PushImage(*PushRequest, Kurma_PushImageServer) error
+}
+
+func RegisterKurmaServer(s *grpc.Server, srv KurmaServer) {
+	s.RegisterService(&_Kurma_serviceDesc, srv)
+}
+
+func _Kurma_Create_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Create(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_UploadImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).UploadImage(&kurmaUploadImageServer{stream})
+}
+
+type Kurma_UploadImageServer interface {
+	Send(*UploadAck) error
+	Recv() (*ByteChunk, error)
+	grpc.ServerStream
+}
+
+type kurmaUploadImageServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaUploadImageServer) Send(m *UploadAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaUploadImageServer) Recv() (*ByteChunk, error) {
+	m := new(ByteChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Kurma_Destroy_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(DestroyRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Destroy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_List_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ListRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).List(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Get_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Get(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Enter_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).Enter(&kurmaEnterServer{stream})
+}
+
+type Kurma_EnterServer interface {
+	Send(*ByteChunk) error
+	Recv() (*ByteChunk, error)
+	grpc.ServerStream
+}
+
+type kurmaEnterServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaEnterServer) Send(m *ByteChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaEnterServer) Recv() (*ByteChunk, error) {
+	m := new(ByteChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Kurma_Exec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).Exec(&kurmaExecServer{stream})
+}
+
+type Kurma_ExecServer interface {
+	Send(*ExecResponse) error
+	Recv() (*ExecRequest, error)
+	grpc.ServerStream
+}
+
+type kurmaExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaExecServer) Send(m *ExecResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaExecServer) Recv() (*ExecRequest, error) {
+	m := new(ExecRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Kurma_Logs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).Logs(m, &kurmaLogsServer{stream})
+}
+
+type Kurma_LogsServer interface {
+	Send(*LogsResponse) error
+	grpc.ServerStream
+}
+
+type kurmaLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaLogsServer) Send(m *LogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).Attach(&kurmaAttachServer{stream})
+}
+
+type Kurma_AttachServer interface {
+	Send(*AttachResponse) error
+	Recv() (*AttachRequest, error)
+	grpc.ServerStream
+}
+
+type kurmaAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaAttachServer) Send(m *AttachResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaAttachServer) Recv() (*AttachRequest, error) {
+	m := new(AttachRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Kurma_Wait_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(WaitRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Wait(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_CreateVolume_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(VolumeRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).CreateVolume(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ListVolumes_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListVolumes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_DeleteVolume_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(VolumeRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).DeleteVolume(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_CreateSecret_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SecretRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).CreateSecret(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ListSecrets_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListSecrets(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_DeleteSecret_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SecretRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).DeleteSecret(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_AddJob_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(AddJobRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).AddJob(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ListJobs_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListJobs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_RemoveJob_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(JobRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).RemoveJob(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Apply_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Apply(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Stop_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Stop(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Start_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Start(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Restart_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Restart(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Inspect_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Inspect(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_UpdateResources_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(UpdateResourcesRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).UpdateResources(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Stats_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Stats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_StreamStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).StreamStats(m, &kurmaStreamStatsServer{stream})
+}
+
+type Kurma_StreamStatsServer interface {
+	Send(*StatsResponse) error
+	grpc.ServerStream
+}
+
+type kurmaStreamStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaStreamStatsServer) Send(m *StatsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).Events(m, &kurmaEventsServer{stream})
+}
+
+type Kurma_EventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type kurmaEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Checkpoint_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CheckpointRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Checkpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Restore_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Restore(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Shutdown_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ShutdownRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Shutdown(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_GarbageCollect_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).GarbageCollect(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_HostStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).HostStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ReloadConfig_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ReloadConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_HostInfo_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).HostInfo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_HostStorage_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).HostStorage(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_HostEncryption_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).HostEncryption(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ListCrashes_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListCrashes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_GetCrash_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CrashRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).GetCrash(ctx, in)
+	if err != nil {
 		return nil, err
 	}
-	out, err := srv.(KurmaServer).Get(ctx, in)
+	return out, nil
+}
+
+func _Kurma_Info_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Info(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _Kurma_Enter_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(KurmaServer).Enter(&kurmaEnterServer{stream})
+func _Kurma_ListNodes_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListNodes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type Kurma_EnterServer interface {
+func _Kurma_SetNetworkPolicy_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(NetworkPolicy)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).SetNetworkPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ListNetworkPolicies_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).ListNetworkPolicies(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_RemoveNetworkPolicy_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(NetworkPolicyRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).RemoveNetworkPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_StartConsole_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).StartConsole(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_UpgradeHost_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(UpgradeHostRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).UpgradeHost(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_UpdateStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(None)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).UpdateStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_UpdateHost_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(UpdateHostRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).UpdateHost(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_AttestHost_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(AttestRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).AttestHost(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_ExportImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ImageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).ExportImage(m, &kurmaExportImageServer{stream})
+}
+
+type Kurma_ExportImageServer interface {
 	Send(*ByteChunk) error
-	Recv() (*ByteChunk, error)
 	grpc.ServerStream
 }
 
-type kurmaEnterServer struct {
+type kurmaExportImageServer struct {
 	grpc.ServerStream
 }
 
-func (x *kurmaEnterServer) Send(m *ByteChunk) error {
+func (x *kurmaExportImageServer) Send(m *ByteChunk) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *kurmaEnterServer) Recv() (*ByteChunk, error) {
-	m := new(ByteChunk)
+func _Kurma_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ContainerRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).Export(m, &kurmaExportServer{stream})
+}
+
+type Kurma_ExportServer interface {
+	Send(*ByteChunk) error
+	grpc.ServerStream
+}
+
+type kurmaExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaExportServer) Send(m *ByteChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Commit_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Commit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_Build_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).Build(&kurmaBuildServer{stream})
+}
+
+type Kurma_BuildServer interface {
+	Send(*BuildResponse) error
+	Recv() (*BuildRequest, error)
+	grpc.ServerStream
+}
+
+type kurmaBuildServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaBuildServer) Send(m *BuildResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaBuildServer) Recv() (*BuildRequest, error) {
+	m := new(BuildRequest)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
+func _Kurma_PushImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PushRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).PushImage(m, &kurmaPushImageServer{stream})
+}
+
+type Kurma_PushImageServer interface {
+	Send(*PushProgress) error
+	grpc.ServerStream
+}
+
+type kurmaPushImageServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaPushImageServer) Send(m *PushProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Kurma_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "client.Kurma",
+	ServiceName: "kurma.v1.Kurma",
 	HandlerType: (*KurmaServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
@@ -398,11 +3287,168 @@ var _Kurma_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _Kurma_Get_Handler,
 		},
+		{
+			MethodName: "Wait",
+			Handler:    _Kurma_Wait_Handler,
+		},
+		{
+			MethodName: "CreateVolume",
+			Handler:    _Kurma_CreateVolume_Handler,
+		},
+		{
+			MethodName: "ListVolumes",
+			Handler:    _Kurma_ListVolumes_Handler,
+		},
+		{
+			MethodName: "DeleteVolume",
+			Handler:    _Kurma_DeleteVolume_Handler,
+		},
+		{
+			MethodName: "CreateSecret",
+			Handler:    _Kurma_CreateSecret_Handler,
+		},
+		{
+			MethodName: "ListSecrets",
+			Handler:    _Kurma_ListSecrets_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _Kurma_DeleteSecret_Handler,
+		},
+		{
+			MethodName: "AddJob",
+			Handler:    _Kurma_AddJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _Kurma_ListJobs_Handler,
+		},
+		{
+			MethodName: "RemoveJob",
+			Handler:    _Kurma_RemoveJob_Handler,
+		},
+		{
+			MethodName: "Apply",
+			Handler:    _Kurma_Apply_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Kurma_Stop_Handler,
+		},
+		{
+			MethodName: "Start",
+			Handler:    _Kurma_Start_Handler,
+		},
+		{
+			MethodName: "Restart",
+			Handler:    _Kurma_Restart_Handler,
+		},
+		{
+			MethodName: "Inspect",
+			Handler:    _Kurma_Inspect_Handler,
+		},
+		{
+			MethodName: "UpdateResources",
+			Handler:    _Kurma_UpdateResources_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Kurma_Stats_Handler,
+		},
+		{
+			MethodName: "Checkpoint",
+			Handler:    _Kurma_Checkpoint_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _Kurma_Restore_Handler,
+		},
+		{
+			MethodName: "Shutdown",
+			Handler:    _Kurma_Shutdown_Handler,
+		},
+		{
+			MethodName: "GarbageCollect",
+			Handler:    _Kurma_GarbageCollect_Handler,
+		},
+		{
+			MethodName: "HostStatus",
+			Handler:    _Kurma_HostStatus_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _Kurma_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "HostInfo",
+			Handler:    _Kurma_HostInfo_Handler,
+		},
+		{
+			MethodName: "HostStorage",
+			Handler:    _Kurma_HostStorage_Handler,
+		},
+		{
+			MethodName: "HostEncryption",
+			Handler:    _Kurma_HostEncryption_Handler,
+		},
+		{
+			MethodName: "ListCrashes",
+			Handler:    _Kurma_ListCrashes_Handler,
+		},
+		{
+			MethodName: "GetCrash",
+			Handler:    _Kurma_GetCrash_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Kurma_Info_Handler,
+		},
+		{
+			MethodName: "ListNodes",
+			Handler:    _Kurma_ListNodes_Handler,
+		},
+		{
+			MethodName: "SetNetworkPolicy",
+			Handler:    _Kurma_SetNetworkPolicy_Handler,
+		},
+		{
+			MethodName: "ListNetworkPolicies",
+			Handler:    _Kurma_ListNetworkPolicies_Handler,
+		},
+		{
+			MethodName: "RemoveNetworkPolicy",
+			Handler:    _Kurma_RemoveNetworkPolicy_Handler,
+		},
+		{
+			MethodName: "StartConsole",
+			Handler:    _Kurma_StartConsole_Handler,
+		},
+		{
+			MethodName: "UpgradeHost",
+			Handler:    _Kurma_UpgradeHost_Handler,
+		},
+		{
+			MethodName: "UpdateStatus",
+			Handler:    _Kurma_UpdateStatus_Handler,
+		},
+		{
+			MethodName: "UpdateHost",
+			Handler:    _Kurma_UpdateHost_Handler,
+		},
+		{
+			MethodName: "AttestHost",
+			Handler:    _Kurma_AttestHost_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Kurma_Commit_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "UploadImage",
 			Handler:       _Kurma_UploadImage_Handler,
+			ServerStreams: true,
 			ClientStreams: true,
 		},
 		{
@@ -411,5 +3457,53 @@ var _Kurma_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Exec",
+			Handler:       _Kurma_Exec_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Logs",
+			Handler:       _Kurma_Logs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Attach",
+			Handler:       _Kurma_Attach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamStats",
+			Handler:       _Kurma_StreamStats_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Events",
+			Handler:       _Kurma_Events_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportImage",
+			Handler:       _Kurma_ExportImage_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _Kurma_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Build",
+			Handler:       _Kurma_Build_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "PushImage",
+			Handler:       _Kurma_PushImage_Handler,
+			ServerStreams: true,
+		},
 	},
 }
@@ -0,0 +1,72 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Interceptor wraps the handling of each request to a method of the Kurma API,
+// such as "Create". It calls handle to pass the request on, with the context
+// the handler is to see, and returns the error the request fails with.
+type Interceptor func(ctx context.Context, method string, handle func(context.Context) error) error
+
+// RegisterKurmaServerWithInterceptors registers the server like
+// RegisterKurmaServer, but passes each request through the interceptors before
+// it reaches the server. The first interceptor is the outermost. The server is
+// also registered under the legacy service name.
+func RegisterKurmaServerWithInterceptors(s *grpc.Server, srv KurmaServer, interceptors ...Interceptor) {
+	desc := _Kurma_serviceDesc
+	desc.Methods = make([]grpc.MethodDesc, len(_Kurma_serviceDesc.Methods))
+	for i, m := range _Kurma_serviceDesc.Methods {
+		name, handler := m.MethodName, m.Handler
+		m.Handler = func(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+			var out interface{}
+			err := Intercept(ctx, name, interceptors, func(ctx context.Context) error {
+				var err error
+				out, err = handler(srv, ctx, codec, buf)
+				return err
+			})
+			return out, err
+		}
+		desc.Methods[i] = m
+	}
+	desc.Streams = make([]grpc.StreamDesc, len(_Kurma_serviceDesc.Streams))
+	for i, sd := range _Kurma_serviceDesc.Streams {
+		name, handler := sd.StreamName, sd.Handler
+		sd.Handler = func(srv interface{}, stream grpc.ServerStream) error {
+			return Intercept(stream.Context(), name, interceptors, func(ctx context.Context) error {
+				return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+			})
+		}
+		desc.Streams[i] = sd
+	}
+	s.RegisterService(&desc, srv)
+
+	legacy := desc
+	legacy.ServiceName = legacyServiceName
+	s.RegisterService(&legacy, srv)
+}
+
+// Intercept passes the request through the first of the interceptors, which
+// passes it on to the rest, and then to the handler. It is used to serve
+// requests which don't arrive over gRPC the same way as those which do.
+func Intercept(ctx context.Context, method string, interceptors []Interceptor, handle func(context.Context) error) error {
+	if len(interceptors) == 0 {
+		return handle(ctx)
+	}
+	return interceptors[0](ctx, method, func(ctx context.Context) error {
+		return Intercept(ctx, method, interceptors[1:], handle)
+	})
+}
+
+// contextStream is a stream whose context was replaced by an interceptor.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
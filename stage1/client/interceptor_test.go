@@ -0,0 +1,100 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// interceptedServer implements only the methods the test calls, and checks
+// that they see the context the interceptors passed on.
+type interceptedServer struct {
+	KurmaServer
+}
+
+type testKey struct{}
+
+func (s *interceptedServer) List(ctx context.Context, in *ListRequest) (*ListResponse, error) {
+	if ctx.Value(testKey{}) == nil {
+		return nil, errors.New("missing context value")
+	}
+	return &ListResponse{}, nil
+}
+
+func (s *interceptedServer) Logs(in *LogsRequest, stream Kurma_LogsServer) error {
+	if stream.Context().Value(testKey{}) == nil {
+		return errors.New("missing context value")
+	}
+	return nil
+}
+
+func TestRegisterKurmaServerWithInterceptors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error listening; got %s", err)
+	}
+	defer l.Close()
+
+	var calls []string
+	record := func(ctx context.Context, method string, handle func(context.Context) error) error {
+		calls = append(calls, "record "+method)
+		return handle(context.WithValue(ctx, testKey{}, true))
+	}
+	authorize := func(ctx context.Context, method string, handle func(context.Context) error) error {
+		calls = append(calls, "authorize "+method)
+		if TokenFromContext(ctx) != "secret" {
+			return errors.New("denied")
+		}
+		return handle(ctx)
+	}
+
+	s := grpc.NewServer()
+	RegisterKurmaServerWithInterceptors(s, &interceptedServer{}, record, authorize)
+	go s.Serve(l)
+
+	call := func(opts ...grpc.DialOption) (listErr, logsErr error) {
+		conn, err := Dial("tcp://"+l.Addr().String(), opts...)
+		if err != nil {
+			t.Fatalf("Expected no error dialing; got %s", err)
+		}
+		defer conn.Close()
+		c := NewKurmaClient(conn)
+
+		_, listErr = c.List(context.Background(), &ListRequest{})
+		stream, err := c.Logs(context.Background(), &LogsRequest{})
+		if err != nil {
+			return listErr, err
+		}
+		if _, err := stream.Recv(); err != io.EOF {
+			return listErr, err
+		}
+		return listErr, nil
+	}
+
+	if listErr, logsErr := call(); listErr == nil || logsErr == nil {
+		t.Fatalf("Expected requests without a token to be denied; got %v and %v", listErr, logsErr)
+	}
+	if listErr, logsErr := call(grpc.WithPerRPCCredentials(TokenCredentials("secret"))); listErr != nil || logsErr != nil {
+		t.Fatalf("Expected requests with the token to be permitted; got %v and %v", listErr, logsErr)
+	}
+
+	// the service is also served under its legacy name
+	conn, err := Dial("tcp://"+l.Addr().String(), grpc.WithPerRPCCredentials(TokenCredentials("secret")))
+	if err != nil {
+		t.Fatalf("Expected no error dialing; got %s", err)
+	}
+	defer conn.Close()
+	if err := grpc.Invoke(context.Background(), "/client.Kurma/List", &None{}, &ListResponse{}, conn); err != nil {
+		t.Fatalf("Expected no error calling the legacy service; got %s", err)
+	}
+
+	if len(calls) != 10 || calls[0] != "record List" || calls[1] != "authorize List" || calls[3] != "authorize Logs" {
+		t.Fatalf("Unexpected interceptor calls: %v", calls)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The enums are encoded in JSON by their names rather than their numbers, so
+// the JSON served by the gateway and output by the CLI can be read without
+// the protocol definition.
+
+func (x Container_State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.String())
+}
+
+func (x *Container_State) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, Container_State_value, "Container_State")
+	*x = Container_State(v)
+	return err
+}
+
+func (x Event_Type) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.String())
+}
+
+func (x *Event_Type) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, Event_Type_value, "Event_Type")
+	*x = Event_Type(v)
+	return err
+}
+
+// unmarshalEnum decodes an enum given by its name or number.
+func unmarshalEnum(b []byte, values map[string]int32, enum string) (int32, error) {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		v, ok := values[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown %s %q", enum, name)
+		}
+		return v, nil
+	}
+	var v int32
+	if err := json.Unmarshal(b, &v); err != nil {
+		return 0, fmt.Errorf("invalid %s %s", enum, b)
+	}
+	return v, nil
+}
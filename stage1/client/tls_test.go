@@ -0,0 +1,93 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate generates a self-signed certificate and key and writes them
+// into the directory.
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error generating key; got %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kurma"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Expected no error creating certificate; got %s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Expected no error marshaling key; got %s", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatalf("Expected no error writing certificate; got %s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatalf("Expected no error writing key; got %s", err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kurma-tls")
+	if err != nil {
+		t.Fatalf("Expected no error creating temp dir; got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCertificate(t, dir)
+
+	config, err := ServerTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("Expected no error creating server config; got %s", err)
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		t.Fatalf("Expected client certificates not to be required without a CA")
+	}
+
+	config, err = ServerTLSConfig(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatalf("Expected no error creating server config; got %s", err)
+	}
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Fatalf("Expected client certificates to be required with a CA")
+	}
+
+	config, err = ClientTLSConfig("", "", certFile)
+	if err != nil {
+		t.Fatalf("Expected no error creating client config; got %s", err)
+	}
+	if len(config.Certificates) != 0 || config.RootCAs == nil {
+		t.Fatalf("Unexpected client config: %#v", config)
+	}
+
+	if _, err := ClientTLSConfig("", "", keyFile); err == nil {
+		t.Fatalf("Expected an error loading a CA without certificates")
+	}
+}
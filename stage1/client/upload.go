@@ -0,0 +1,113 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/net/context"
+)
+
+// uploadChunkSize is the size of the chunks an image is uploaded in.
+const uploadChunkSize = 64 * 1024
+
+// UploadImage uploads the image read from r for the container requested with
+// the upload ID, returning the container once it has been created. The upload
+// continues from however much of the image the server has already received,
+// which is skipped over in r, so an interrupted upload can be resumed by
+// calling UploadImage again with the image read from the start. If progress is
+// given, it is called with the number of bytes received by the server each
+// time a chunk is acknowledged.
+func UploadImage(ctx context.Context, client KurmaClient, uploadID string, r io.Reader, progress func(int64)) (*Container, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.UploadImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// an empty chunk opens the upload and is acknowledged with where to resume
+	if err := stream.Send(&ByteChunk{StreamId: uploadID}); err != nil {
+		return nil, err
+	}
+	ack, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	offset := ack.Offset
+	if offset > 0 {
+		if err := skip(r, offset); err != nil {
+			return nil, fmt.Errorf("failed to resume the upload at %d bytes: %v", offset, err)
+		}
+	}
+	if progress != nil {
+		progress(offset)
+	}
+
+	// receive the acknowledgements while the chunks are sent
+	type result struct {
+		container *Container
+		err       error
+	}
+	resultch := make(chan result, 1)
+	go func() {
+		var container *Container
+		for {
+			ack, err := stream.Recv()
+			if err == io.EOF {
+				if container == nil {
+					err = fmt.Errorf("upload ended before the container was created")
+				} else {
+					err = nil
+				}
+				resultch <- result{container, err}
+				return
+			} else if err != nil {
+				resultch <- result{nil, err}
+				return
+			}
+			if progress != nil {
+				progress(ack.Offset)
+			}
+			if ack.Container != nil {
+				container = ack.Container
+			}
+		}
+	}()
+
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			chunk := &ByteChunk{StreamId: uploadID, Bytes: buf[:n], Offset: offset}
+			if err := stream.Send(chunk); err != nil {
+				// the server ended the stream, and its error is returned by Recv
+				break
+			}
+			offset += int64(n)
+		}
+		if rerr == io.EOF {
+			if err := stream.CloseSend(); err != nil {
+				return nil, err
+			}
+			break
+		} else if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	res := <-resultch
+	return res.container, res.err
+}
+
+// skip advances r past the first n bytes, seeking if it is able to.
+func skip(r io.Reader, n int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(n, 1)
+		return err
+	}
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return err
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// uploadServer imitates the server's side of an image upload, breaking the
+// stream once interruptAt bytes have been received.
+type uploadServer struct {
+	received    []byte
+	interruptAt int
+	streams     int
+}
+
+// uploadClient is a KurmaClient which only supports uploading images to its
+// uploadServer.
+type uploadClient struct {
+	KurmaClient
+	server *uploadServer
+}
+
+func (c *uploadClient) UploadImage(ctx context.Context, opts ...grpc.CallOption) (Kurma_UploadImageClient, error) {
+	c.server.streams++
+	return &uploadStream{server: c.server, acks: make(chan *UploadAck, 1024)}, nil
+}
+
+type uploadStream struct {
+	grpc.ClientStream
+	server *uploadServer
+	acks   chan *UploadAck
+	err    error
+}
+
+func (s *uploadStream) Send(chunk *ByteChunk) error {
+	if s.err != nil {
+		return io.EOF
+	}
+	if len(chunk.Bytes) > 0 {
+		if chunk.Offset != int64(len(s.server.received)) {
+			s.err = errors.New("unexpected offset")
+			close(s.acks)
+			return io.EOF
+		}
+		s.server.received = append(s.server.received, chunk.Bytes...)
+	}
+	s.acks <- &UploadAck{Offset: int64(len(s.server.received))}
+
+	if s.server.interruptAt > 0 && len(s.server.received) >= s.server.interruptAt {
+		s.server.interruptAt = 0
+		s.err = errors.New("transport is closing")
+		close(s.acks)
+	}
+	return nil
+}
+
+func (s *uploadStream) Recv() (*UploadAck, error) {
+	ack, ok := <-s.acks
+	if !ok {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	return ack, nil
+}
+
+func (s *uploadStream) CloseSend() error {
+	s.acks <- &UploadAck{
+		Offset:    int64(len(s.server.received)),
+		Container: &Container{Uuid: "test"},
+	}
+	close(s.acks)
+	return nil
+}
+
+func TestUploadImageResume(t *testing.T) {
+	image := []byte(strings.Repeat("0123456789", 20000))
+	server := &uploadServer{interruptAt: uploadChunkSize + 1}
+	client := &uploadClient{server: server}
+
+	var progress []int64
+	record := func(n int64) { progress = append(progress, n) }
+
+	// the first upload is interrupted after two chunks
+	_, err := UploadImage(context.Background(), client, "id", bytes.NewReader(image), record)
+	if err == nil || err.Error() != "transport is closing" {
+		t.Fatalf("Expected the upload to be interrupted; got %v", err)
+	}
+	if len(server.received) != 2*uploadChunkSize {
+		t.Fatalf("Expected %d bytes to be received; got %d", 2*uploadChunkSize, len(server.received))
+	}
+
+	// resuming continues from what was received, even when r can't seek
+	container, err := UploadImage(context.Background(), client, "id",
+		io.MultiReader(bytes.NewReader(image)), record)
+	if err != nil {
+		t.Fatalf("Expected no error resuming the upload; got %s", err)
+	}
+	if container == nil || container.Uuid != "test" {
+		t.Fatalf("Expected the created container to be returned; got %v", container)
+	}
+	if !bytes.Equal(server.received, image) {
+		t.Fatalf("Expected the server to receive the image; got %d bytes", len(server.received))
+	}
+	if server.streams != 2 {
+		t.Fatalf("Expected 2 upload streams; got %d", server.streams)
+	}
+	if last := progress[len(progress)-1]; last != int64(len(image)) {
+		t.Fatalf("Expected the progress to finish at %d; got %d", len(image), last)
+	}
+}
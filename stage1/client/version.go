@@ -0,0 +1,40 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+)
+
+// Version is the version of Kurma the client or server was built from. It is
+// set at build time with -ldflags "-X github.com/apcera/kurma/stage1/client.Version=...".
+var Version = "dev"
+
+const (
+	// APIVersion is the revision of the API this package implements, which is
+	// raised whenever methods or fields are added to it.
+	APIVersion = 27
+
+	// MinAPIVersion is the oldest revision of the API the server still
+	// supports. Clients built against an older revision must be upgraded.
+	MinAPIVersion = 1
+)
+
+// legacyServiceName is the name the service was served under before the API
+// was versioned, which servers still register it under so older clients keep
+// working.
+const legacyServiceName = "client.Kurma"
+
+// CheckAPIVersion returns why a client of this package's revision of the API
+// may not work with the server the info describes, or nil if it will.
+func CheckAPIVersion(info *InfoResponse) error {
+	switch {
+	case APIVersion < int(info.MinApiVersion):
+		return fmt.Errorf("the server, running Kurma %s, no longer supports API revision %d used by this client; upgrade the client to one using revisions %d to %d",
+			info.Version, APIVersion, info.MinApiVersion, info.MaxApiVersion)
+	case APIVersion > int(info.MaxApiVersion):
+		return fmt.Errorf("the server, running Kurma %s, supports API revisions up to %d, while this client uses %d; features the server lacks will fail until it is upgraded",
+			info.Version, info.MaxApiVersion, APIVersion)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"testing"
+)
+
+func TestCheckAPIVersion(t *testing.T) {
+	tests := []struct {
+		min, max int32
+		ok       bool
+	}{
+		{APIVersion, APIVersion, true},
+		{1, APIVersion + 1, true},
+		{APIVersion + 1, APIVersion + 2, false},
+		{0, APIVersion - 1, false},
+	}
+
+	for _, test := range tests {
+		info := &InfoResponse{Version: "test", MinApiVersion: test.min, MaxApiVersion: test.max}
+		if err := CheckAPIVersion(info); (err == nil) != test.ok {
+			t.Fatalf("Unexpected result checking against revisions %d to %d: %v", test.min, test.max, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
+	"github.com/appc/spec/schema/types"
+)
+
+// Resources is an amount of CPU, in millicores, and of memory, in bytes.
+type Resources struct {
+	CPU    int64
+	Memory int64
+}
+
+// sub returns the resources left once o is taken from r, which are never
+// negative.
+func (r Resources) sub(o Resources) Resources {
+	r.CPU -= o.CPU
+	r.Memory -= o.Memory
+	if r.CPU < 0 {
+		r.CPU = 0
+	}
+	if r.Memory < 0 {
+		r.Memory = 0
+	}
+	return r
+}
+
+// InsufficientResourcesError is returned when a container is created or
+// restored while the host hasn't enough of a resource left to reserve what the
+// container requests. The Resource is "cpu", in millicores, or "memory", in
+// bytes.
+type InsufficientResourcesError struct {
+	Resource  string
+	Requested int64
+	Available int64
+}
+
+func (e *InsufficientResourcesError) Error() string {
+	return fmt.Sprintf("insufficient %s on the host: %d requested, %d available",
+		e.Resource, e.Requested, e.Available)
+}
+
+// newAllocatable returns the resources containers may reserve between them,
+// which are the host's CPUs and memory less those reserved for the system,
+// scaled by the overcommit ratio.
+func newAllocatable(system Resources, ratio float64) (Resources, error) {
+	if system.CPU < 0 || system.Memory < 0 {
+		return Resources{}, fmt.Errorf("the resources reserved for the system must not be negative")
+	}
+	if ratio < 0 {
+		return Resources{}, fmt.Errorf("the overcommit ratio must not be negative")
+	}
+	memory, err := hostMeminfo("MemTotal")
+	if err != nil {
+		return Resources{}, fmt.Errorf("failed to read the host's memory: %v", err)
+	}
+	capacity := Resources{CPU: int64(runtime.NumCPU()) * 1000, Memory: memory}.sub(system)
+	return Resources{
+		CPU:    int64(float64(capacity.CPU) * ratio),
+		Memory: int64(float64(capacity.Memory) * ratio),
+	}, nil
+}
+
+// hostMeminfo returns the field of /proc/meminfo, such as "MemTotal", in bytes.
+func hostMeminfo(field string) (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != field+":" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", field, fields[1])
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("/proc/meminfo lacks %s", field)
+}
+
+// reservation returns the resources the container reserves on the host, which
+// are the sum of what its apps' resource/cpu and resource/memory isolators
+// request, or their limits when they don't give a request. Exclusive CPUs are
+// reserved in full.
+func (c *Container) reservation() Resources {
+	var r Resources
+	for _, a := range c.apps {
+		if q := appResource(a.app, types.ResourceCPUName); q != nil {
+			r.CPU += q.MilliValue()
+		}
+		if q := appResource(a.app, types.ResourceMemoryName); q != nil {
+			r.Memory += q.Value()
+		}
+	}
+	if p := c.cpuPlacement; p != nil && int64(p.ExclusiveCPUs)*1000 > r.CPU {
+		r.CPU = int64(p.ExclusiveCPUs) * 1000
+	}
+	return r
+}
+
+// appResource returns the request of the app's resource isolator with the name,
+// or its limit if it has no request, or nil if the app doesn't have one.
+func appResource(app *types.App, name types.ACIdentifier) *resource.Quantity {
+	if app == nil {
+		return nil
+	}
+	iso := app.Isolators.GetByName(name)
+	if iso == nil {
+		return nil
+	}
+	res, ok := iso.Value().(types.Resource)
+	if !ok {
+		return nil
+	}
+	if q := res.Request(); q != nil {
+		return q
+	}
+	return res.Limit()
+}
+
+// reserves returns whether the container's reservation counts against the
+// host's, which it does until it exits or is checkpointed.
+func reserves(state ContainerState) bool {
+	return state != EXITED && state != CHECKPOINTED
+}
+
+// committed returns the resources reserved by the containers other than the
+// one excluded. The caller holds the containers lock.
+func (manager *Manager) committed(exclude *Container) Resources {
+	var r Resources
+	for _, c := range manager.containers {
+		if c == exclude || !reserves(c.State()) {
+			continue
+		}
+		cr := c.reservation()
+		r.CPU += cr.CPU
+		r.Memory += cr.Memory
+	}
+	return r
+}
+
+// admit checks that the host has enough left to reserve what the container
+// requests, beyond what the other containers have reserved. The caller holds
+// the containers lock, so that containers are admitted one at a time.
+func (manager *Manager) admit(c *Container) error {
+	if manager.allocatable == nil {
+		return nil
+	}
+	want := c.reservation()
+	available := manager.allocatable.sub(manager.committed(c))
+	if want.CPU > available.CPU {
+		return &InsufficientResourcesError{Resource: "cpu", Requested: want.CPU, Available: available.CPU}
+	}
+	if want.Memory > available.Memory {
+		return &InsufficientResourcesError{Resource: "memory", Requested: want.Memory, Available: available.Memory}
+	}
+	return nil
+}
+
+// Allocatable returns the resources containers may reserve between them, and
+// whether containers are refused once they are all reserved.
+func (manager *Manager) Allocatable() (Resources, bool) {
+	if manager.allocatable == nil {
+		return Resources{}, false
+	}
+	return *manager.allocatable, true
+}
+
+// Committed returns the resources reserved by the containers which haven't
+// exited or been checkpointed.
+func (manager *Manager) Committed() Resources {
+	manager.containersLock.RLock()
+	defer manager.containersLock.RUnlock()
+	return manager.committed(nil)
+}
@@ -0,0 +1,261 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/util/envmap"
+	"github.com/appc/spec/schema/types"
+)
+
+// AppStatus describes the state of one of the apps running within a container.
+// The ExitCode is only set once the app has exited.
+type AppStatus struct {
+	Name     string
+	State    ContainerState
+	ExitCode int
+	Restarts int
+
+	// Capabilities are the names of the capabilities the app is run with.
+	Capabilities []string
+}
+
+// app tracks an individual app from the pod that is run within the container.
+// Its fields are protected by the container's mutex.
+type app struct {
+	name     types.ACName
+	app      *types.App
+	state    ContainerState
+	exitCode int
+	restarts int
+	runs     int
+
+	// tty gives the app a console even if it didn't request one.
+	tty bool
+}
+
+// processName returns the name the current run of the app is tracked under
+// within initd. Each restart, or start after being stopped, uses a new name so
+// its status can be told apart from the runs before it.
+func (a *app) processName() string {
+	if a.runs == 0 {
+		return a.name.String()
+	}
+	return fmt.Sprintf("%s-%d", a.name, a.runs)
+}
+
+// hasConsole returns whether the app has requested to be started with a
+// console, or was given one when the container was created.
+func (a *app) hasConsole() bool {
+	if a.tty {
+		return true
+	}
+	if iso := a.app.Isolators.GetByName(kschema.ConsoleName); iso != nil {
+		if ciso, ok := iso.Value().(*kschema.Console); ok {
+			return bool(*ciso)
+		}
+	}
+	return false
+}
+
+// Apps returns the status of each of the apps within the container.
+func (c *Container) Apps() []AppStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	statuses := make([]AppStatus, len(c.apps))
+	for i, a := range c.apps {
+		statuses[i] = AppStatus{
+			Name:     a.name.String(),
+			State:    a.state,
+			ExitCode: a.exitCode,
+			Restarts: a.restarts,
+
+			Capabilities: capabilityNamesFor(c.appCapabilities(a)),
+		}
+	}
+	return statuses
+}
+
+// isolator returns the first isolator with the specified name from the apps in
+// the pod. Isolators such as namespaces apply to the container as a whole, so
+// they're shared by all of its apps.
+func (c *Container) isolator(name types.ACIdentifier) *types.Isolator {
+	for _, a := range c.apps {
+		if iso := a.app.Isolators.GetByName(name); iso != nil {
+			return iso
+		}
+	}
+	return nil
+}
+
+// isHostPrivileged returns whether the container has requested host
+// privileged access.
+func (c *Container) isHostPrivileged() bool {
+	if iso := c.isolator(kschema.HostPrivilegedName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
+			return bool(*piso)
+		}
+	}
+	return false
+}
+
+// appEnvironment returns the environment the app should be run with, which is
+// the container's environment along with the app's own settings.
+func (c *Container) appEnvironment(a *app) *envmap.EnvMap {
+	env := c.environment.NewChild()
+	env.Set("USER", a.app.User)
+	env.Set("LOGNAME", a.app.User)
+	env.Set("AC_APP_NAME", a.name.String())
+	if url := c.metadataURL(); url != "" {
+		env.Set("AC_METADATA_URL", url)
+	}
+
+	appenv := env.NewChild()
+	for _, e := range a.app.Environment {
+		appenv.Set(e.Name, e.Value)
+	}
+
+	// secrets take precedence over the image's placeholders for them
+	c.mutex.Lock()
+	for name, value := range c.secretEnv {
+		appenv.Set(name, value)
+	}
+	c.mutex.Unlock()
+	return appenv
+}
+
+// startAppProcess launches the app through initd.
+func (c *Container) startAppProcess(a *app) error {
+	client := c.getInitdClient()
+	environment := c.appEnvironment(a)
+
+	// iterate the command arguments and fill in any potential environment
+	// variable references
+	envmap := environment.Map()
+	envfunc := func(env string) string { return envmap[env] }
+	cmdargs := make([]string, len(a.app.Exec))
+	copy(cmdargs, a.app.Exec)
+	for i, s := range cmdargs {
+		cmdargs[i] = os.Expand(s, envfunc)
+	}
+
+	// validate the working directory
+	workingDirectory := a.app.WorkingDirectory
+	if workingDirectory == "" {
+		workingDirectory = "/"
+	}
+
+	// use the console for all of the app's stdio if one was allocated, and
+	// give the first app the stdin FIFO while it is held open
+	stdin, stdout, stderr := "", "/app.stdout", "/app.stderr"
+	if c.console != nil && a.hasConsole() {
+		stdin, stdout, stderr = consolePath, consolePath, consolePath
+	} else if c.stdin && a == c.apps[0] {
+		c.mutex.Lock()
+		capture := c.logCapture
+		c.mutex.Unlock()
+		if capture != nil && capture.stdinWriter() != nil {
+			stdin = "/app.stdin"
+		}
+	}
+
+	c.mutex.Lock()
+	name := a.processName()
+	c.mutex.Unlock()
+
+	c.log.Tracef("Launching application %s [%q:%q]: %#v", name, a.app.User, a.app.Group, cmdargs)
+	c.log.Tracef("Application environment: %#v", environment.Strings())
+	err := client.Start(
+		name, cmdargs, workingDirectory, environment.Strings(),
+		stdin, stdout, stderr,
+		a.app.User, a.app.Group, c.appCapabilities(a), c.execLabel(),
+		time.Second*5)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	a.state = RUNNING
+	c.mutex.Unlock()
+	return nil
+}
+
+// updateApps applies the process statuses returned by initd to the apps and
+// schedules any restarts. It returns whether any apps are waiting to be
+// restarted.
+func (c *Container) updateApps(statuses map[string]string) bool {
+	var restarts []*app
+	pending := false
+	exited := false
+	killed := ""
+
+	c.mutex.Lock()
+	for _, a := range c.apps {
+		if a.state == RESTARTING {
+			pending = true
+			continue
+		}
+		if a.state != RUNNING {
+			continue
+		}
+		status, ok := statuses[a.processName()]
+		if !ok || status == "running" {
+			continue
+		}
+
+		a.exitCode = exitCode(status)
+		exited = true
+		if status == "signaled(9)" && killed == "" {
+			killed = a.name.String()
+		}
+		if c.shouldRestart(a) {
+			a.state = RESTARTING
+			restarts = append(restarts, a)
+			pending = true
+		} else {
+			a.state = EXITED
+		}
+	}
+	c.mutex.Unlock()
+
+	// Processes killed for exceeding the memory limit are sent SIGKILL, so the
+	// first app seen to be killed by it is assumed to be the one.
+	if exited {
+		c.checkOOMKills(killed)
+		c.saveState()
+	}
+
+	for _, a := range restarts {
+		c.log.Debugf("App %s exited with code %d, restarting it.", a.name, a.exitCode)
+		go c.restartApp(a)
+	}
+	return pending
+}
+
+// exitCode converts a process status from initd into an exit code. Processes
+// killed by a signal are given 128 plus the signal number, as a shell would.
+func exitCode(status string) int {
+	var offset int
+	switch {
+	case strings.HasPrefix(status, "exited(") && strings.HasSuffix(status, ")"):
+		status = status[len("exited(") : len(status)-1]
+	case strings.HasPrefix(status, "signaled(") && strings.HasSuffix(status, ")"):
+		status = status[len("signaled(") : len(status)-1]
+		offset = 128
+	default:
+		return -1
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return -1
+	}
+	return code + offset
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		status string
+		code   int
+	}{
+		{"exited(0)", 0},
+		{"exited(1)", 1},
+		{"exited(255)", 255},
+		{"signaled(9)", 137},
+		{"signaled(15)", 143},
+		{"running", -1},
+		{"", -1},
+		{"exited()", -1},
+		{"exited(x)", -1},
+		{"exited(1", -1},
+		{"signaled(9)x", -1},
+		{"stopped(1)", -1},
+	}
+	for _, test := range tests {
+		if code := exitCode(test.status); code != test.code {
+			t.Fatalf("Expected exit code %d for %q; got %d", test.code, test.status, code)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxHeldOutput is the most output held for the first client to attach to a
+// container which was created to be attached to. Output past it is only
+// logged.
+const maxHeldOutput = 1 << 20
+
+// outputTap copies the output of the container's apps to the clients attached
+// to it. A container created to be attached to has its output held from when
+// it starts until the first client attaches, so nothing is missed by a client
+// which creates a container and then attaches to it.
+type outputTap struct {
+	clients  map[*attachedClient]bool
+	holding  bool
+	held     []heldOutput
+	heldSize int
+	mutex    sync.Mutex
+}
+
+// attachedClient receives the stdout and stderr of the apps. The console's
+// output is written to stdout.
+type attachedClient struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// heldOutput is output written to a stream before the first client attached.
+type heldOutput struct {
+	stream string
+	data   []byte
+}
+
+// outputTap returns the container's tap, creating it the first time.
+func (c *Container) outputTap() *outputTap {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.tap == nil {
+		c.tap = &outputTap{
+			clients: make(map[*attachedClient]bool),
+			holding: c.holdOutput,
+		}
+	}
+	return c.tap
+}
+
+// write copies the output to the attached clients, or holds it until the first
+// client attaches. Clients which return an error are detached.
+func (t *outputTap) write(stream string, p []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.holding {
+		if t.heldSize+len(p) <= maxHeldOutput {
+			t.held = append(t.held, heldOutput{stream: stream, data: append([]byte(nil), p...)})
+			t.heldSize += len(p)
+		}
+		return
+	}
+	for client := range t.clients {
+		if err := client.write(stream, p); err != nil {
+			delete(t.clients, client)
+		}
+	}
+}
+
+// write sends the output to the client's writer for the stream.
+func (a *attachedClient) write(stream string, p []byte) error {
+	w := a.stdout
+	if stream == "stderr" {
+		w = a.stderr
+	}
+	_, err := w.Write(p)
+	return err
+}
+
+// attach adds a client to receive the output, and returns the function which
+// detaches it. The first client to attach is sent any output which was held
+// for it.
+func (t *outputTap) attach(stdout, stderr io.Writer) func() {
+	client := &attachedClient{stdout: stdout, stderr: stderr}
+
+	t.mutex.Lock()
+	t.clients[client] = true
+	for _, h := range t.held {
+		if err := client.write(h.stream, h.data); err != nil {
+			delete(t.clients, client)
+			break
+		}
+	}
+	t.holding = false
+	t.held = nil
+	t.heldSize = 0
+	t.mutex.Unlock()
+
+	return func() {
+		t.mutex.Lock()
+		delete(t.clients, client)
+		t.mutex.Unlock()
+	}
+}
+
+// writer returns a writer which copies what is written to it to the stream of
+// the attached clients.
+func (t *outputTap) writer(stream string) io.Writer {
+	return &tapWriter{tap: t, stream: stream}
+}
+
+type tapWriter struct {
+	tap    *outputTap
+	stream string
+}
+
+func (w *tapWriter) Write(p []byte) (int, error) {
+	w.tap.write(w.stream, p)
+	return len(p), nil
+}
+
+// AttachStdio connects to the stdio of the container's first app, for
+// containers without a console. Output from the apps is copied to stdout and
+// stderr until the returned function is called to detach. The returned stdin is
+// nil unless the container was created with its stdin open, and closing it
+// closes the app's stdin.
+func (c *Container) AttachStdio(stdout, stderr io.Writer) (io.WriteCloser, func(), error) {
+	c.mutex.Lock()
+	capture := c.logCapture
+	hasConsole := c.console != nil
+	c.mutex.Unlock()
+
+	if hasConsole {
+		return nil, nil, fmt.Errorf("the container has a console to attach to")
+	}
+	if capture == nil {
+		return nil, nil, fmt.Errorf("the container's output isn't being captured")
+	}
+
+	var stdin io.WriteCloser
+	if w := capture.stdinWriter(); w != nil {
+		stdin = &stdinWriter{capture: capture, file: w}
+	}
+	return stdin, c.outputTap().attach(stdout, stderr), nil
+}
+
+// DrainOutput waits for the output the apps have written so far to be copied
+// to the attached clients. It is used once the container has exited, so that
+// the clients get all of its output before they're told it exited.
+func (c *Container) DrainOutput() {
+	c.mutex.Lock()
+	capture := c.logCapture
+	console := c.console
+	c.mutex.Unlock()
+
+	if console != nil {
+		select {
+		case <-console.done:
+		case <-time.After(time.Second):
+		}
+	}
+	if capture != nil {
+		capture.drain()
+	}
+}
+
+// stdinWriter writes to the app's stdin FIFO. Closing it closes the capture's
+// write end, so the app reads EOF.
+type stdinWriter struct {
+	capture *logCapture
+	file    *os.File
+}
+
+func (w *stdinWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+func (w *stdinWriter) Close() error {
+	w.capture.closeStdin(w.file)
+	return nil
+}
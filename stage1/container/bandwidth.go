@@ -0,0 +1,42 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+// setBandwidth fills in the directions of the container's bandwidth limits
+// which weren't given from the apps' network bandwidth isolator. The isolator
+// has a single limit, which applies to both directions.
+func (c *Container) setBandwidth() error {
+	if iso := c.isolator(types.ResourceNetworkBandwidthName); iso != nil {
+		if biso, ok := iso.Value().(*types.ResourceNetworkBandwidth); ok && biso.Limit() != nil {
+			limit := biso.Limit().Value()
+			if c.bandwidth.Ingress == 0 {
+				c.bandwidth.Ingress = limit
+			}
+			if c.bandwidth.Egress == 0 {
+				c.bandwidth.Egress = limit
+			}
+		}
+	}
+	if err := c.bandwidth.Validate(); err != nil {
+		return fmt.Errorf("invalid bandwidth limit: %v", err)
+	}
+	if c.bandwidth.Limited() && c.manager.network == nil {
+		return fmt.Errorf("bandwidth limits require the container network, which the host is not configured with")
+	}
+	if c.bandwidth.Limited() && !c.manager.network.Bridged() {
+		return fmt.Errorf("bandwidth limits aren't supported in the %s mode", c.manager.network.Mode())
+	}
+	return nil
+}
+
+// Bandwidth returns the limits on the rate of the container's traffic on the
+// container network.
+func (c *Container) Bandwidth() (ingress, egress int64) {
+	return c.bandwidth.Ingress, c.bandwidth.Egress
+}
@@ -0,0 +1,204 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// runtimeSpecVersion is the version of the OCI runtime specification the
+	// bundles' configuration follows.
+	runtimeSpecVersion = "1.0.2"
+
+	// runtimeBundleConfig is the name of the bundle's configuration file within
+	// the container's directory.
+	runtimeBundleConfig = "config.json"
+
+	// runtimeAnnotationPrefix prefixes the annotations recording what the
+	// configuration has no place for.
+	runtimeAnnotationPrefix = "org.apcera.kurma."
+)
+
+// runtimeSpec is the subset of the OCI runtime configuration which describes
+// how kurma runs a container.
+type runtimeSpec struct {
+	Version     string            `json:"ociVersion"`
+	Process     *runtimeProcess   `json:"process,omitempty"`
+	Root        runtimeRoot       `json:"root"`
+	Mounts      []runtimeMount    `json:"mounts,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Linux       runtimeLinux      `json:"linux"`
+}
+
+type runtimeProcess struct {
+	Terminal     bool                 `json:"terminal,omitempty"`
+	User         runtimeUser          `json:"user"`
+	Args         []string             `json:"args"`
+	Env          []string             `json:"env,omitempty"`
+	Cwd          string               `json:"cwd"`
+	Capabilities *runtimeCapabilities `json:"capabilities,omitempty"`
+}
+
+type runtimeUser struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+}
+
+type runtimeCapabilities struct {
+	Bounding    []string `json:"bounding"`
+	Effective   []string `json:"effective"`
+	Permitted   []string `json:"permitted"`
+	Inheritable []string `json:"inheritable"`
+}
+
+type runtimeRoot struct {
+	Path     string `json:"path"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
+type runtimeMount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+type runtimeLinux struct {
+	UIDMappings []runtimeIDMapping `json:"uidMappings,omitempty"`
+	GIDMappings []runtimeIDMapping `json:"gidMappings,omitempty"`
+	Namespaces  []runtimeNamespace `json:"namespaces"`
+	CgroupsPath string             `json:"cgroupsPath,omitempty"`
+}
+
+type runtimeIDMapping struct {
+	ContainerID uint32 `json:"containerID"`
+	HostID      uint32 `json:"hostID"`
+	Size        uint32 `json:"size"`
+}
+
+type runtimeNamespace struct {
+	Type string `json:"type"`
+}
+
+// writeRuntimeBundle writes the OCI runtime configuration describing how the
+// container is about to be launched into its directory, which makes the
+// directory an OCI runtime bundle around its root filesystem. A bundle only
+// has a single process, which is the pod's first app, so the names of all of
+// the apps are annotated on it. Apps given a user or group by name have them
+// resolved within the container as they're started, so those are annotated as
+// well, and the secrets within the environment are left out.
+func (c *Container) writeRuntimeBundle(launch *LaunchSpec) error {
+	spec := &runtimeSpec{
+		Version:     runtimeSpecVersion,
+		Root:        runtimeRoot{Path: filepath.Base(c.stage3Path()), Readonly: c.readOnlyRootFS},
+		Annotations: make(map[string]string),
+	}
+
+	names := make([]string, len(c.apps))
+	for i, a := range c.apps {
+		names[i] = a.name.String()
+	}
+	spec.Annotations[runtimeAnnotationPrefix+"apps"] = strings.Join(names, ",")
+
+	if len(c.apps) > 0 {
+		a := c.apps[0]
+		env := c.appEnvironment(a).Map()
+		c.mutex.Lock()
+		for name := range c.secretEnv {
+			delete(env, name)
+		}
+		c.mutex.Unlock()
+
+		process := &runtimeProcess{
+			Terminal: c.console != nil && a.hasConsole(),
+			Args:     append([]string(nil), a.app.Exec...),
+			Cwd:      a.app.WorkingDirectory,
+		}
+		if process.Cwd == "" {
+			process.Cwd = "/"
+		}
+		for name, value := range env {
+			process.Env = append(process.Env, name+"="+value)
+		}
+		sort.Strings(process.Env)
+		if uid, err := strconv.ParseUint(a.app.User, 10, 32); err == nil {
+			process.User.UID = uint32(uid)
+		} else {
+			spec.Annotations[runtimeAnnotationPrefix+"user"] = a.app.User
+		}
+		if gid, err := strconv.ParseUint(a.app.Group, 10, 32); err == nil {
+			process.User.GID = uint32(gid)
+		} else {
+			spec.Annotations[runtimeAnnotationPrefix+"group"] = a.app.Group
+		}
+		caps := capabilityNamesFor(c.appCapabilities(a))
+		process.Capabilities = &runtimeCapabilities{
+			Bounding:    caps,
+			Effective:   caps,
+			Permitted:   caps,
+			Inheritable: caps,
+		}
+		spec.Process = process
+	}
+
+	// the filesystems stage2 mounts in every container
+	spec.Mounts = []runtimeMount{
+		{Destination: "/proc", Type: "proc", Source: "proc", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/dev", Type: "tmpfs", Source: "tmpfs", Options: []string{"noexec", "strictatime", "mode=755"}},
+		{Destination: "/dev/mqueue", Type: "mqueue", Source: "mqueue", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/dev/pts", Type: "devpts", Source: "devpts", Options: []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666"}},
+		{Destination: "/dev/shm", Type: "tmpfs", Source: "shm", Options: []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"}},
+		{Destination: "/sys", Type: "sysfs", Source: "sysfs", Options: []string{"nosuid", "noexec", "nodev", "ro"}},
+	}
+	if launch.HostPrivileged {
+		spec.Mounts[1] = runtimeMount{Destination: "/dev", Type: "devtmpfs", Source: "devtmpfs"}
+	}
+	c.mutex.Lock()
+	for _, m := range c.mounts {
+		mount := runtimeMount{Destination: m.Destination, Type: "bind", Source: m.Source, Options: []string{"rbind"}}
+		if m.Source == "tmpfs" || m.Source == "hugetlbfs" {
+			mount.Type, mount.Options = m.Source, nil
+		}
+		if m.ReadOnly {
+			mount.Options = append(mount.Options, "ro")
+		}
+		spec.Mounts = append(spec.Mounts, mount)
+	}
+	c.mutex.Unlock()
+
+	for _, ns := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"pid", launch.NewPIDNamespace},
+		{"network", launch.NewNetworkNamespace},
+		{"ipc", launch.NewIPCNamespace},
+		{"uts", launch.NewUTSNamespace},
+		{"mount", launch.NewMountNamespace},
+		{"user", launch.NewUserNamespace},
+	} {
+		if ns.enabled {
+			spec.Linux.Namespaces = append(spec.Linux.Namespaces, runtimeNamespace{Type: ns.name})
+		}
+	}
+	if m := c.idMapping; m != nil {
+		spec.Linux.UIDMappings = []runtimeIDMapping{{HostID: uint32(m.UID), Size: uint32(m.Size)}}
+		spec.Linux.GIDMappings = []runtimeIDMapping{{HostID: uint32(m.GID), Size: uint32(m.Size)}}
+	}
+	if c.cgroup != nil {
+		spec.Linux.CgroupsPath = "/" + c.cgroup.Name()
+	}
+
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(c.directory, runtimeBundleConfig), b, os.FileMode(0600))
+}
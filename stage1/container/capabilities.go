@@ -0,0 +1,165 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+// capabilityNames lists the Linux capabilities by their number, in the form
+// used by the appc capability isolators.
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+}
+
+// defaultCapabilities is the set of capabilities apps are limited to unless
+// their isolators say otherwise. It covers what is commonly needed to set up
+// and run a service as root within the container, while leaving out those
+// which give access to the host, such as CAP_SYS_ADMIN and CAP_NET_ADMIN.
+var defaultCapabilities = []string{
+	"CAP_AUDIT_WRITE",
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_MKNOD",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_RAW",
+	"CAP_SETFCAP",
+	"CAP_SETGID",
+	"CAP_SETPCAP",
+	"CAP_SETUID",
+	"CAP_SYS_CHROOT",
+}
+
+// capabilityNumber returns the number of the named capability.
+func capabilityNumber(name string) (int, error) {
+	for i, n := range capabilityNames {
+		if n == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown capability %q", name)
+}
+
+// validateCapabilities checks that the app's capability isolators only name
+// known capabilities.
+func validateCapabilities(app *types.App) error {
+	for _, name := range []types.ACIdentifier{types.LinuxCapabilitiesRetainSetName, types.LinuxCapabilitiesRevokeSetName} {
+		iso := app.Isolators.GetByName(name)
+		if iso == nil {
+			continue
+		}
+		set, ok := iso.Value().(types.LinuxCapabilitiesSet)
+		if !ok {
+			continue
+		}
+		for _, c := range set.Set() {
+			if _, err := capabilityNumber(string(c)); err != nil {
+				return fmt.Errorf("the manifest %s isolator is invalid: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// capabilities returns the numbers of the capabilities the app is limited to.
+// It starts from the default set, which the app's retain set isolator
+// replaces, and then removes those in its remove set isolator.
+func (a *app) capabilities() []int {
+	names := defaultCapabilities
+	if iso := a.app.Isolators.GetByName(types.LinuxCapabilitiesRetainSetName); iso != nil {
+		if set, ok := iso.Value().(types.LinuxCapabilitiesSet); ok {
+			names = nil
+			for _, c := range set.Set() {
+				names = append(names, string(c))
+			}
+		}
+	}
+
+	retain := make([]bool, len(capabilityNames))
+	for _, name := range names {
+		if n, err := capabilityNumber(name); err == nil {
+			retain[n] = true
+		}
+	}
+	if iso := a.app.Isolators.GetByName(types.LinuxCapabilitiesRevokeSetName); iso != nil {
+		if set, ok := iso.Value().(types.LinuxCapabilitiesSet); ok {
+			for _, c := range set.Set() {
+				if n, err := capabilityNumber(string(c)); err == nil {
+					retain[n] = false
+				}
+			}
+		}
+	}
+
+	caps := make([]int, 0, len(names))
+	for n, r := range retain {
+		if r {
+			caps = append(caps, n)
+		}
+	}
+	return caps
+}
+
+// appCapabilities returns the numbers of the capabilities the app is run with,
+// or nil if it keeps all of them, as privileged containers do.
+func (c *Container) appCapabilities(a *app) []int {
+	if c.privileged || c.isHostPrivileged() {
+		return nil
+	}
+	return a.capabilities()
+}
+
+// capabilityNamesFor returns the names of the numbered capabilities, or of all
+// of them if caps is nil.
+func capabilityNamesFor(caps []int) []string {
+	if caps == nil {
+		return append([]string(nil), capabilityNames...)
+	}
+	names := make([]string, len(caps))
+	for i, n := range caps {
+		names[i] = capabilityNames[n]
+	}
+	return names
+}
@@ -0,0 +1,596 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/storage"
+	client3 "github.com/apcera/kurma/stage3/client"
+	"github.com/appc/spec/schema"
+)
+
+// checkpointStateFile is the file within the checkpoint directory which records
+// the container's configuration, so that it can be restored on another host.
+const checkpointStateFile = "container.json"
+
+// checkpointState is the configuration of a checkpointed container which isn't
+// captured in CRIU's images.
+type checkpointState struct {
+	UUID             string                `json:"uuid"`
+	Name             string                `json:"name,omitempty"`
+	Labels           map[string]string     `json:"labels,omitempty"`
+	Image            *schema.ImageManifest `json:"image"`
+	Pod              *schema.PodManifest   `json:"pod"`
+	Apps             []checkpointApp       `json:"apps"`
+	RestartPolicy    RestartPolicy         `json:"restart_policy"`
+	MaxRetries       int                   `json:"max_retries"`
+	StopGracePeriod  time.Duration         `json:"stop_grace_period,omitempty"`
+	Privileged       bool                  `json:"privileged,omitempty"`
+	SecurityLabel    string                `json:"security_label,omitempty"`
+	ReadOnlyRootFS   bool                  `json:"read_only_rootfs,omitempty"`
+	Tmpfs            []*TmpfsMount         `json:"tmpfs,omitempty"`
+	HealthCheck      *HealthCheck          `json:"health_check,omitempty"`
+	Sysctls          map[string]string     `json:"sysctls,omitempty"`
+	Logging          LogConfig             `json:"logging"`
+	Ports            []network.PortMapping `json:"ports,omitempty"`
+	Mounts           []*Mount              `json:"mounts,omitempty"`
+	ImageSize        int64                 `json:"image_size"`
+	NetworkNamespace bool                  `json:"network_namespace"`
+	MetadataToken    string                `json:"metadata_token,omitempty"`
+
+	// The host IDs the container's user namespace is mapped to, which its
+	// files are owned by.
+	UserNamespace *idMapping `json:"user_namespace,omitempty"`
+
+	// The container's root filesystem, as set up by the storage driver.
+	Filesystem *storage.Filesystem `json:"filesystem,omitempty"`
+
+	// The quota limiting the disk space used within the container's directory.
+	Quota *storage.Quota `json:"quota,omitempty"`
+
+	// The container's connection to the container network, if it has one.
+	HostInterface string `json:"host_interface,omitempty"`
+	Address       string `json:"address,omitempty"`
+	Address6      string `json:"address6,omitempty"`
+
+	// The limits on the rate of the container's traffic on the network.
+	Bandwidth network.Bandwidth `json:"bandwidth"`
+
+	// Where the container is placed on the host's CPUs. The CPUs themselves
+	// are allocated again when it is restored.
+	CPUPlacement *CPUPlacement `json:"cpu_placement,omitempty"`
+
+	// The container's priority class.
+	Priority Priority `json:"priority,omitempty"`
+}
+
+// checkpointApp is the state of one of the apps when it was checkpointed.
+type checkpointApp struct {
+	State    ContainerState `json:"state"`
+	ExitCode int            `json:"exit_code"`
+	Restarts int            `json:"restarts"`
+	Runs     int            `json:"runs"`
+}
+
+func (c *Container) checkpointPath() string {
+	return filepath.Join(c.directory, "checkpoint")
+}
+
+// Checkpoint dumps the processes of the running container with CRIU into its
+// directory, from which they can later be restored. Unless leaveRunning is set,
+// the processes are stopped once they're dumped and the container is left in
+// the CHECKPOINTED state.
+func (c *Container) Checkpoint(leaveRunning bool) error {
+	initdClient := c.getInitdClient()
+
+	c.mutex.Lock()
+	if c.shuttingDown || initdClient == nil || c.state != RUNNING {
+		c.mutex.Unlock()
+		return fmt.Errorf("the container is not running")
+	}
+	if c.console != nil {
+		c.mutex.Unlock()
+		return fmt.Errorf("containers with a console can't be checkpointed")
+	}
+	if c.runtime.Name() != NativeRuntime {
+		c.mutex.Unlock()
+		return fmt.Errorf("containers run by the %s runtime can't be checkpointed", c.runtime.Name())
+	}
+	if c.stdin {
+		c.mutex.Unlock()
+		return fmt.Errorf("containers with an open stdin can't be checkpointed")
+	}
+	if len(c.secrets) > 0 {
+		c.mutex.Unlock()
+		return fmt.Errorf("containers with secrets can't be checkpointed, as the secrets would be written to disk")
+	}
+	if c.endpoint != nil && !c.manager.network.Bridged() {
+		c.mutex.Unlock()
+		return fmt.Errorf("containers on the LAN can't be checkpointed, as their interfaces can't be restored")
+	}
+	c.state = STOPPING
+	c.stopping = !leaveRunning
+	c.checkpointing = !leaveRunning
+	waitLoopch := c.waitLoopch
+	state := c.checkpointState()
+	c.mutex.Unlock()
+
+	// a failed dump leaves the processes running
+	resume := func() {
+		c.mutex.Lock()
+		c.state = RUNNING
+		c.stopping = false
+		c.checkpointing = false
+		c.mutex.Unlock()
+	}
+
+	c.log.Debug("Checkpointing the container.")
+	if err := c.writeCheckpointState(state); err != nil {
+		resume()
+		return err
+	}
+	args := []string{"--tree", strconv.Itoa(initdClient.Pid())}
+	if leaveRunning {
+		args = append(args, "--leave-running")
+	}
+	if err := c.criu("dump", args...); err != nil {
+		resume()
+		return err
+	}
+	if leaveRunning {
+		resume()
+		c.log.Info("Checkpointed the container, leaving it running.")
+		return nil
+	}
+
+	// The wait loop exits once it loses its connection to the initd, since the
+	// container is being checkpointed.
+	initdClient.Stop()
+	if waitLoopch != nil {
+		<-waitLoopch
+	}
+	c.stoppingContainerNetwork()
+	c.stoppingConsole()
+	c.stoppingLogs()
+	c.stopWatchingHealth()
+
+	c.mutex.Lock()
+	c.initdClient = nil
+	c.checkpointing = false
+	c.state = CHECKPOINTED
+	c.mutex.Unlock()
+	c.saveState()
+	c.log.Info("Checkpointed the container.")
+	return nil
+}
+
+// Restore restores the processes of a checkpointed container and resumes
+// managing them.
+func (c *Container) Restore() error {
+	// the containers lock is held until the container is starting, so that no
+	// other container is admitted with what it reserves
+	c.manager.containersLock.Lock()
+	c.mutex.Lock()
+	if c.shuttingDown || c.state != CHECKPOINTED {
+		c.mutex.Unlock()
+		c.manager.containersLock.Unlock()
+		return fmt.Errorf("the container is not checkpointed")
+	}
+	if err := c.manager.admit(c); err != nil {
+		c.mutex.Unlock()
+		c.manager.containersLock.Unlock()
+		return err
+	}
+	c.state = STARTING
+	c.mutex.Unlock()
+	c.manager.containersLock.Unlock()
+
+	if err := c.restore(); err != nil {
+		c.mutex.Lock()
+		c.state = CHECKPOINTED
+		c.mutex.Unlock()
+		return err
+	}
+	c.publish(&Event{Type: EventStarted})
+	return nil
+}
+
+// Restore restores the container with the UUID from its checkpoint. If the
+// manager doesn't know of the container, such as when its directory was copied
+// from another host, it is recreated from the configuration saved with the
+// checkpoint.
+func (manager *Manager) Restore(uuid string) (*Container, error) {
+	if c := manager.Container(uuid); c != nil {
+		return c, c.Restore()
+	}
+	if len(uuid) < 8 || strings.ContainsAny(uuid, "/.") {
+		return nil, fmt.Errorf("invalid container UUID %q", uuid)
+	}
+
+	// the container's directory is named after the short form of its UUID, and
+	// the loopback filesystem of its disk quota is mounted over it, if it has one
+	directory := filepath.Join(manager.containerDirectory, uuid[0:8])
+	if err := storage.MountQuota(directory); err != nil {
+		return nil, err
+	}
+	state, err := readCheckpointState(filepath.Join(directory, "checkpoint"))
+	if err == nil && state.UUID != uuid {
+		err = fmt.Errorf("the checkpoint in %s is for container %s", directory, state.UUID)
+	}
+	if err == nil && state.SecurityLabel != "" && manager.securityModule == "" {
+		err = fmt.Errorf("the container's security label requires a security module")
+	}
+	if err != nil {
+		storage.UnmountQuota(directory)
+		return nil, err
+	}
+
+	c, err := manager.loadContainer(directory, state, manager.runtimes[NativeRuntime])
+	if err != nil {
+		storage.UnmountQuota(directory)
+		return nil, err
+	}
+	c.state = CHECKPOINTED
+	release := func() {
+		c.releaseIDs()
+		c.releaseCPUs()
+		storage.UnmountQuota(directory)
+	}
+
+	if err := c.startingEnvironment(); err != nil {
+		release()
+		return nil, err
+	}
+	if err := c.startingCgroups(); err != nil {
+		release()
+		return nil, err
+	}
+	if err := manager.register(c, state.Name, false); err != nil {
+		release()
+		return nil, err
+	}
+	c.publish(&Event{Type: EventCreated, Image: state.Image.Name.String()})
+	if c.quota != nil {
+		c.watchDiskQuota()
+	}
+
+	// If the restore fails, the container is left checkpointed so it can be
+	// retried or destroyed.
+	return c, c.Restore()
+}
+
+// loadContainer recreates a container from its saved configuration, to be run
+// by the runtime. The IDs of its user namespace are reserved and its filesystem
+// is made available again, which are released if it fails.
+func (manager *Manager) loadContainer(directory string, state *checkpointState, runtime Runtime) (*Container, error) {
+	c := &Container{
+		manager:          manager,
+		log:              manager.Log.Clone(),
+		uuid:             state.UUID,
+		directory:        directory,
+		waitch:           make(chan bool),
+		restartch:        make(chan bool, 1),
+		image:            state.Image,
+		pod:              state.Pod,
+		requestedPorts:   state.Ports,
+		restartPolicy:    state.RestartPolicy,
+		maxRetries:       state.MaxRetries,
+		stopGracePeriod:  state.StopGracePeriod,
+		privileged:       state.Privileged,
+		securityLabel:    state.SecurityLabel,
+		readOnlyRootFS:   state.ReadOnlyRootFS,
+		tmpfs:            state.Tmpfs,
+		healthCheck:      state.HealthCheck,
+		sysctls:          state.Sysctls,
+		labels:           state.Labels,
+		logConfig:        state.Logging.withDefaults(manager.logConfig),
+		mounts:           state.Mounts,
+		imageSize:        state.ImageSize,
+		quota:            state.Quota,
+		bandwidth:        state.Bandwidth,
+		cpuPlacement:     state.CPUPlacement,
+		priority:         state.Priority,
+		metadataToken:    state.MetadataToken,
+		networkNamespace: state.NetworkNamespace,
+		runtime:          runtime,
+	}
+	for i, ra := range state.Pod.Apps {
+		a := state.Apps[i]
+		c.apps = append(c.apps, &app{
+			name:     ra.Name,
+			app:      ra.App,
+			state:    a.State,
+			exitCode: a.ExitCode,
+			restarts: a.Restarts,
+			runs:     a.Runs,
+		})
+	}
+	c.log.SetField("container", c.uuid)
+
+	// the running processes keep the metadata URL they were started with, but
+	// checkpoints from before the metadata service have no token to keep
+	if c.metadataToken == "" {
+		var err error
+		if c.metadataToken, err = newMetadataToken(); err != nil {
+			return nil, err
+		}
+	}
+	if state.Quota != nil {
+		c.diskLimit = state.Quota.Limit
+	}
+
+	// the container's files are already owned by its IDs, so it can only be
+	// loaded if it can be given the same ones
+	if state.UserNamespace != nil {
+		if manager.userNamespaces == nil {
+			return nil, fmt.Errorf("the container requires user namespaces, which the host is not configured for")
+		}
+		if err := manager.userNamespaces.reserve(state.UserNamespace); err != nil {
+			return nil, err
+		}
+		c.idMapping = state.UserNamespace
+	}
+
+	// the container's filesystem is mounted again by the storage driver which
+	// created it, if the host has restarted since it was saved
+	if state.Filesystem != nil {
+		driver, err := manager.storageDriver(state.Filesystem)
+		if err == nil {
+			err = driver.Restore(c.uuid, directory, state.Filesystem)
+		}
+		if err != nil {
+			c.releaseIDs()
+			return nil, err
+		}
+		c.filesystem = state.Filesystem
+	}
+	return c, nil
+}
+
+// releaseIDs releases the IDs reserved for the container's user namespace, if
+// it has them.
+func (c *Container) releaseIDs() {
+	if c.idMapping != nil {
+		c.manager.userNamespaces.release(c.idMapping)
+		c.idMapping = nil
+	}
+}
+
+// isCheckpointing returns whether the container's processes are being dumped
+// and stopped.
+func (c *Container) isCheckpointing() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.checkpointing
+}
+
+// restore restores the processes from the checkpoint, reconnects them to the
+// container network, and reconnects to the initd.
+func (c *Container) restore() error {
+	c.log.Debug("Restoring the container from its checkpoint.")
+	state, err := readCheckpointState(c.checkpointPath())
+	if err != nil {
+		return err
+	}
+
+	// the restored apps reopen the FIFOs their output is captured through
+	if err := c.startingLogs(); err != nil {
+		return err
+	}
+
+	var args []string
+	var endpoint *network.Endpoint
+	if state.HostInterface != "" {
+		if endpoint, err = c.reserveEndpoint(state); err != nil {
+			c.stoppingLogs()
+			return err
+		}
+		args = append(args, "--veth-pair", fmt.Sprintf("%s=%s", network.ContainerInterface, endpoint.HostInterface))
+	}
+	teardown := func() {
+		if endpoint != nil {
+			c.manager.network.Teardown(endpoint)
+		}
+		c.stoppingLogs()
+	}
+
+	// the initd's socket is recreated along with it
+	os.Remove(c.socketPath())
+	args = append(args, "--restore-detached", "--pidfile", "restore.pid")
+	if err := c.criu("restore", args...); err != nil {
+		teardown()
+		return err
+	}
+
+	if endpoint != nil {
+		if err := c.manager.network.Attach(endpoint); err != nil {
+			teardown()
+			return fmt.Errorf("failed to set up the container network: %v", err)
+		}
+		if err := c.manager.network.Limit(endpoint, c.bandwidth); err != nil {
+			teardown()
+			return fmt.Errorf("failed to limit the container's bandwidth: %v", err)
+		}
+		c.mutex.Lock()
+		c.endpoint = endpoint
+		c.mutex.Unlock()
+		if err := c.publishPorts(endpoint); err != nil {
+			return err
+		}
+	}
+
+	initdClient := client3.New(c.socketPath())
+	if err := initdClient.WaitForSocket(client3.DefaultTimeout); err != nil {
+		return fmt.Errorf("failed to connect to the restored initd: %v", err)
+	}
+
+	c.mutex.Lock()
+	c.initdClient = initdClient
+	c.networkNamespace = state.NetworkNamespace
+	select {
+	case <-c.waitch:
+		c.waitch = make(chan bool)
+	default:
+	}
+	c.state = RUNNING
+	c.stopping = false
+	c.startTime = time.Now()
+	c.mutex.Unlock()
+	c.saveState()
+
+	c.startWaitLoop()
+	c.watchHealth()
+	c.log.Info("Restored the container.")
+	return nil
+}
+
+// reserveEndpoint reserves the container's addresses on the container network
+// so it can be restored with them.
+func (c *Container) reserveEndpoint(state *checkpointState) (*network.Endpoint, error) {
+	if c.manager.network == nil {
+		return nil, fmt.Errorf("the container network is not configured")
+	}
+	address, err := parseCheckpointAddress(state.Address)
+	if err != nil {
+		return nil, err
+	}
+	address6, err := parseCheckpointAddress(state.Address6)
+	if err != nil {
+		return nil, err
+	}
+	if address == nil {
+		return nil, fmt.Errorf("the checkpoint has no address for %s", state.HostInterface)
+	}
+	return c.manager.network.Reserve(state.HostInterface, address, address6)
+}
+
+// parseCheckpointAddress parses an address recorded in the checkpoint state,
+// which may be empty.
+func parseCheckpointAddress(s string) (*net.IPNet, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q in the checkpoint: %v", s, err)
+	}
+	return &net.IPNet{IP: ip, Mask: ipnet.Mask}, nil
+}
+
+// checkpointState returns the container's configuration to be saved with its
+// checkpoint. The container's mutex must be held.
+func (c *Container) checkpointState() *checkpointState {
+	state := &checkpointState{
+		UUID:             c.uuid,
+		Name:             c.name,
+		Labels:           c.labels,
+		Image:            c.image,
+		Pod:              c.pod,
+		RestartPolicy:    c.restartPolicy,
+		MaxRetries:       c.maxRetries,
+		StopGracePeriod:  c.stopGracePeriod,
+		Privileged:       c.privileged,
+		SecurityLabel:    c.securityLabel,
+		ReadOnlyRootFS:   c.readOnlyRootFS,
+		Tmpfs:            c.tmpfs,
+		HealthCheck:      c.healthCheck,
+		Sysctls:          c.sysctls,
+		Logging:          c.logConfig,
+		Ports:            c.requestedPorts,
+		Mounts:           c.mounts,
+		ImageSize:        c.imageSize,
+		NetworkNamespace: c.networkNamespace,
+		MetadataToken:    c.metadataToken,
+		UserNamespace:    c.idMapping,
+		Filesystem:       c.filesystem,
+		Quota:            c.quota,
+		Bandwidth:        c.bandwidth,
+		CPUPlacement:     c.cpuPlacement,
+		Priority:         c.priority,
+	}
+	for _, a := range c.apps {
+		state.Apps = append(state.Apps, checkpointApp{
+			State:    a.state,
+			ExitCode: a.exitCode,
+			Restarts: a.restarts,
+			Runs:     a.runs,
+		})
+	}
+	if c.endpoint != nil {
+		state.HostInterface = c.endpoint.HostInterface
+		state.Address = c.endpoint.Address.String()
+		if c.endpoint.Address6 != nil {
+			state.Address6 = c.endpoint.Address6.String()
+		}
+	}
+	return state
+}
+
+// writeCheckpointState creates an empty checkpoint directory and saves the
+// container's configuration in it.
+func (c *Container) writeCheckpointState(state *checkpointState) error {
+	dir := c.checkpointPath()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, os.FileMode(0700)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, checkpointStateFile), b, os.FileMode(0600))
+}
+
+// readCheckpointState reads the configuration saved in the checkpoint
+// directory.
+func readCheckpointState(dir string) (*checkpointState, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, checkpointStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no checkpoint was found")
+		}
+		return nil, err
+	}
+	state := &checkpointState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse the checkpoint: %v", err)
+	}
+	if state.Image == nil || state.Pod == nil || len(state.Apps) != len(state.Pod.Apps) {
+		return nil, fmt.Errorf("the checkpoint is incomplete")
+	}
+	return state, nil
+}
+
+// criu runs the CRIU action against the container's checkpoint directory. Its
+// log is kept in the directory as ACTION.log.
+func (c *Container) criu(action string, args ...string) error {
+	args = append([]string{
+		action,
+		"--images-dir", c.checkpointPath(),
+		"--log-file", action + ".log",
+		"--manage-cgroups",
+		"--ext-mount-map", "auto",
+		"--ext-unix-sk",
+		"--tcp-established",
+		"--file-locks",
+	}, args...)
+	if b, err := exec.Command("criu", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("criu %s failed: %v: %s (see %s)", action, err,
+			strings.TrimSpace(string(b)), filepath.Join(c.checkpointPath(), action+".log"))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"io"
+	"os"
+
+	"github.com/kr/pty"
+)
+
+// consolePath is the path within the container where the console is bind
+// mounted and passed to the app as its stdin, stdout, and stderr.
+const consolePath = "/dev/console"
+
+// console manages the pseudo-terminal that is used as the console for an app.
+// Output from the console is written to the app's stdout log and to the clients
+// which are attached through the output tap.
+type console struct {
+	master *os.File
+	slave  *os.File
+	output io.Writer
+	done   chan bool
+}
+
+// newConsole allocates a new pseudo-terminal for a console. The output will be
+// written to the provided writer.
+func newConsole(output io.Writer) (*console, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &console{
+		master: master,
+		slave:  slave,
+		output: output,
+		done:   make(chan bool),
+	}, nil
+}
+
+// run copies the output from the console until it is closed. It should be
+// called once the app has been started.
+func (c *console) run() {
+	defer close(c.done)
+
+	// Close our reference to the slave. Once the app and all of its children
+	// have exited, reads from the master will return an error.
+	c.slave.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := c.master.Read(buf)
+		if n > 0 {
+			c.output.Write(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// close releases the pseudo-terminal.
+func (c *console) close() {
+	c.master.Close()
+	c.slave.Close()
+}
@@ -7,9 +7,10 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 
-	kschema "github.com/apcera/kurma/schema"
-	client2 "github.com/apcera/kurma/stage2/client"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/storage"
 	client3 "github.com/apcera/kurma/stage3/client"
 	"github.com/apcera/kurma/util/cgroups"
 	"github.com/apcera/logray"
@@ -28,8 +29,29 @@ const (
 	STOPPING
 	STOPPED
 	EXITED
+	RESTARTING
+	CHECKPOINTED
 )
 
+var stateNames = map[ContainerState]string{
+	NEW:          "new",
+	STARTING:     "starting",
+	RUNNING:      "running",
+	STOPPING:     "stopping",
+	STOPPED:      "stopped",
+	EXITED:       "exited",
+	RESTARTING:   "restarting",
+	CHECKPOINTED: "checkpointed",
+}
+
+// String returns the lowercase name of the state.
+func (s ContainerState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
 // Container represents the operation and management of an individual container
 // on the current system.
 type Container struct {
@@ -38,18 +60,128 @@ type Container struct {
 
 	image            *schema.ImageManifest
 	pod              *schema.PodManifest
+	volumes          []*VolumeMount
+	apps             []*app
+	restartPolicy    RestartPolicy
+	maxRetries       int
+	stopGracePeriod  time.Duration
+	privileged       bool
+	securityLabel    string
+	readOnlyRootFS   bool
+	runtime          Runtime
+	tmpfs            []*TmpfsMount
+	filesystem       *storage.Filesystem
+	diskLimit        int64
+	bandwidth        network.Bandwidth
+	restartch        chan bool
 	uuid             string
+	name             string
+	labels           map[string]string
 	initialImageFile io.ReadCloser
 
+	// created orders the containers by when they were added to the manager,
+	// so they can be stopped in the reverse order when the host shuts down.
+	created uint64
+
 	cgroup      *cgroups.Cgroup
 	directory   string
 	environment *envmap.EnvMap
+	mounts      []*Mount
+	startTime   time.Time
+	exitTime    time.Time
+	imageSize   int64
+	oomKills    int64
+
+	// cgroupOOMKills is the number of OOM kills the current cgroup had last
+	// reported, and stopOOMWatch stops handling its OOM notifications.
+	// startOOMKills is how many kills there had been when the apps were last
+	// started.
+	cgroupOOMKills int64
+	startOOMKills  int64
+	stopOOMWatch   func()
+
+	// quota limits the disk space the container uses within its directory,
+	// diskQuotaFull is whether it was at its limit when last checked, and
+	// stopDiskWatch stops checking it.
+	quota         *storage.Quota
+	diskQuotaFull bool
+	stopDiskWatch func()
+
+	// healthCheck is the container's liveness probe, health the status of its
+	// checks, and healthFailures how many have failed in a row. healthProbing
+	// is set while a check's command is running, and stopHealthWatch stops
+	// running the checks.
+	healthCheck     *HealthCheck
+	health          HealthStatus
+	healthFailures  int
+	healthProbing   bool
+	stopHealthWatch func()
+
+	// idMapping is the block of host IDs the container's user namespace is
+	// mapped to, if it has one.
+	idMapping *idMapping
+
+	// cpuPlacement is where the container asked to be placed on the host's
+	// CPUs, and cpus are the CPUs allocated to it for its exclusive use.
+	cpuPlacement *CPUPlacement
+	cpus         []int
+
+	// priority orders the container against the others when the host is
+	// short of memory, and throttled is whether its memory was throttled
+	// while it was.
+	priority  Priority
+	throttled bool
+
+	// logConfig selects the driver the apps' output is captured by, and
+	// logCapture passes the output to it.
+	logConfig  LogConfig
+	logCapture *logCapture
+
+	// secrets are exposed to the apps as files or environment variables, and
+	// secretEnv holds the values of the latter while the container runs.
+	secrets   []*SecretMount
+	secretEnv map[string]string
+
+	// metadataToken identifies the container to the metadata service, and is
+	// the secret part of its apps' AC_METADATA_URL.
+	metadataToken string
+
+	// sysctls are the namespaced kernel parameters set within the container,
+	// which requires its own network or IPC namespace.
+	sysctls      map[string]string
+	ipcNamespace bool
 
-	initdClient  client3.Client
-	shuttingDown bool
-	state        ContainerState
-	mutex        sync.Mutex
-	waitch       chan bool
+	networkNamespace bool
+	endpoint         *network.Endpoint
+	requestedPorts   []network.PortMapping
+	ports            []network.PortMapping
+
+	// stdin keeps the first app's stdin open to be written to by attached
+	// clients, and holdOutput holds the output until the first client
+	// attaches, which tap copies the output to.
+	stdin      bool
+	holdOutput bool
+	tap        *outputTap
+
+	// startError is why the container failed to start, if it did.
+	startError error
+
+	// stateMutex orders the writes of the container's saved state.
+	stateMutex sync.Mutex
+
+	// handoffFiles are the descriptors of the files capturing the container's
+	// output which are left open for an upgraded kurma to inherit.
+	handoffFiles []int
+
+	initdClient   client3.Client
+	console       *console
+	shuttingDown  bool
+	stopping      bool
+	checkpointing bool
+	state         ContainerState
+	mutex         sync.Mutex
+	waitch        chan bool
+	waitLoopch    chan bool
 }
 
 // Manifest returns the current pod manifest for the App Container
@@ -65,6 +197,43 @@ func (container *Container) State() ContainerState {
 	return container.state
 }
 
+// StopGracePeriod returns how long the container's apps are given to exit
+// after being sent SIGTERM when the container is stopped without a grace period
+// being specified.
+func (container *Container) StopGracePeriod() time.Duration {
+	if container.stopGracePeriod <= 0 {
+		return DefaultStopGracePeriod
+	}
+	return container.stopGracePeriod
+}
+
+// Privileged returns whether the container's apps were granted all
+// capabilities when it was created.
+func (container *Container) Privileged() bool {
+	return container.privileged
+}
+
+// SecurityLabel returns the AppArmor profile or SELinux context the
+// container's apps are confined by, or an empty string if they're unconfined.
+func (container *Container) SecurityLabel() string {
+	return container.securityLabel
+}
+
+// ReadOnlyRootFS returns whether the container's root filesystem is mounted
+// read-only.
+func (container *Container) ReadOnlyRootFS() bool {
+	return container.readOnlyRootFS
+}
+
+// execLabel returns what the apps' processes write to /proc/self/attr/exec to
+// be confined by the container's security label, if it has one.
+func (container *Container) execLabel() string {
+	if container.securityLabel == "" {
+		return ""
+	}
+	return container.manager.securityModule.ExecAttribute(container.securityLabel)
+}
+
 // isShuttingDown returns whether the container is currently in the state of
 // being shut down. This is an internal flag, separate from the State.
 func (container *Container) isShuttingDown() bool {
@@ -85,6 +254,10 @@ func (container *Container) start() {
 		if err := f(container); err != nil {
 			// FIXME more error handling
 			container.log.Errorf("startup error: %v", err)
+			container.mutex.Lock()
+			container.startError = err
+			container.mutex.Unlock()
+			container.markExited()
 			return
 		}
 	}
@@ -92,6 +265,9 @@ func (container *Container) start() {
 	container.mutex.Lock()
 	container.state = RUNNING
 	container.mutex.Unlock()
+	container.saveState()
+	container.publish(&Event{Type: EventStarted})
+	container.watchHealth()
 }
 
 // Stop triggers the shutdown of the Container.
@@ -100,6 +276,7 @@ func (container *Container) Stop() error {
 	container.shuttingDown = true
 	container.state = STOPPING
 	container.mutex.Unlock()
+	container.removeState()
 
 	// loop over the container stopping functions
 	for _, f := range containerStopping {
@@ -124,6 +301,12 @@ func (container *Container) UUID() string {
 	return container.uuid
 }
 
+// LogConfig returns the configuration of the driver capturing the output of
+// the container's apps.
+func (container *Container) LogConfig() LogConfig {
+	return container.logConfig
+}
+
 // ShortName returns a shortened name that can be used to reference the
 // Container. It is made of up of the first 8 digits of the container's UUID.
 func (container *Container) ShortName() string {
@@ -139,42 +322,62 @@ func (container *Container) ShortName() string {
 // the container through the stage2 rather than through the initd so that it can
 // easily stream in and out.
 func (c *Container) Enter(stream *os.File) error {
-	launcher := &client2.Launcher{
-		Environment: c.environment.Strings(),
-		Taskfiles:   c.cgroup.TasksFiles(),
-		Stdin:       stream,
-		Stdout:      stream,
-		Stderr:      stream,
-		User:        c.image.App.User,
-		Group:       c.image.App.Group,
-	}
+	_, err := c.Exec([]string{"/bin/bash"}, stream, stream, stream)
+	return err
+}
 
-	// Check for a privileged isolator
-	if iso := c.image.App.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
-		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
-			if *piso {
-				launcher.HostPrivileged = true
-			}
-		}
+// Exec is used to run the specified command within the container through its
+// runtime, which for the native runtime re-enters the container through the
+// stage2 and joins the namespaces of the processes already running within it.
+// It blocks until the command has finished and returns the exit code of the
+// process.
+func (c *Container) Exec(cmdargs []string, stdin, stdout, stderr *os.File) (int, error) {
+	if len(cmdargs) == 0 {
+		return -1, fmt.Errorf("no command was specified")
 	}
 
-	// Get a process from the container and copy its namespaces
-	tasks, err := c.cgroup.Tasks()
-	if err != nil {
-		return err
-	}
-	if len(tasks) == 0 {
-		return fmt.Errorf("no processes are running inside the container")
+	// commands are run with the settings of the pod's first app
+	a := c.apps[0]
+	return c.runtime.Exec(&ExecSpec{
+		Command:        cmdargs,
+		Environment:    c.appEnvironment(a).Strings(),
+		Cgroup:         c.cgroup,
+		Stdin:          stdin,
+		Stdout:         stdout,
+		Stderr:         stderr,
+		User:           a.app.User,
+		Group:          a.app.Group,
+		Capabilities:   c.appCapabilities(a),
+		ExecLabel:      c.execLabel(),
+		HostPrivileged: c.isHostPrivileged(),
+		UserNamespace:  c.idMapping != nil,
+	})
+}
+
+// AttachConsole connects to the console of the container's app. Output from the
+// console is copied to w until the returned function is called to detach. The
+// returned file is the console itself, which input can be written to and can be
+// resized.
+func (c *Container) AttachConsole(w io.Writer) (*os.File, func(), error) {
+	c.mutex.Lock()
+	console := c.console
+	c.mutex.Unlock()
+
+	if console == nil {
+		return nil, nil, fmt.Errorf("the container does not have a console")
 	}
-	launcher.SetNS(tasks[0])
+	return console.master, c.outputTap().attach(w, w), nil
+}
 
-	// launch!
-	p, err := launcher.Run("/bin/bash")
-	if err != nil {
-		return err
+// hasConsole returns whether one of the apps has requested to be started with a
+// console.
+func (c *Container) hasConsole() bool {
+	for _, a := range c.apps {
+		if a.hasConsole() {
+			return true
+		}
 	}
-	_, err = p.Wait()
-	return err
+	return false
 }
 
 // getInitdClient is an accessor to get current initd client object. This should
@@ -191,16 +394,41 @@ func (c *Container) getInitdClient() client3.Client {
 // markFailed is used to transition the container to the exited state.
 func (c *Container) markExited() {
 	c.mutex.Lock()
-	if c.state != EXITED {
+	exited := c.state != EXITED
+	if exited {
 		close(c.waitch)
+		c.exitTime = time.Now()
 	}
 	c.state = EXITED
 	c.mutex.Unlock()
+
+	if exited {
+		c.saveState()
+		c.publish(&Event{Type: EventExited, ExitCode: c.ExitCode()})
+	}
 }
 
 // Wait can be used to block until the processes within a container are finished
 // executed. It is primarily intended for an internal API to code against system
 // services.
 func (c *Container) Wait() {
-	<-c.waitch
+	c.mutex.Lock()
+	waitch := c.waitch
+	c.mutex.Unlock()
+	<-waitch
+}
+
+// Exited returns a channel which is closed once the container has exited. A
+// container which is started again after it exits has a new channel.
+func (c *Container) Exited() <-chan bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.waitch
+}
+
+// StartError returns why the container failed to start, or nil if it didn't.
+func (c *Container) StartError() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.startError
 }
@@ -5,6 +5,7 @@ package container
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,10 +13,10 @@ import (
 	"time"
 
 	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/storage"
 	"github.com/apcera/kurma/stage3/client"
+	"github.com/apcera/kurma/util/cgroups"
 	"github.com/apcera/util/envmap"
-	"github.com/apcera/util/hashutil"
-	"github.com/apcera/util/tarhelper"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
 )
@@ -28,8 +29,12 @@ var (
 		(*Container).startingFilesystem,
 		(*Container).startingNetworking,
 		(*Container).startingEnvironment,
+		(*Container).startingSecrets,
 		(*Container).startingCgroups,
+		(*Container).startingLogs,
 		(*Container).launchStage2,
+		(*Container).startingContainerNetwork,
+		(*Container).startingSysctls,
 		(*Container).startApp,
 	}
 
@@ -37,6 +42,9 @@ var (
 	// teardown.
 	containerStopping = []func(*Container) error{
 		(*Container).stoppingCgroups,
+		(*Container).stoppingContainerNetwork,
+		(*Container).stoppingConsole,
+		(*Container).stoppingLogs,
 		(*Container).stoppingDirectories,
 		(*Container).stoppingrRemoveFromParent,
 	}
@@ -57,18 +65,31 @@ func (c *Container) startingBaseDirectories() error {
 		return err
 	}
 
-	// Ensure the directories are owned by the uid/gid that is root inside the
-	// container
-	// if err := chowns(dirs, c.manager.namespaceUidOffset, c.manager.namespaceGidOffset); err != nil {
-	// 	return err
-	// }
+	// Limit the disk space used within the directory before anything is
+	// written to it, since a loopback filesystem is mounted over it
+	if err := c.setQuota(); err != nil {
+		return err
+	}
+
+	// Allocate the host IDs for the container's user namespace, and ensure the
+	// directories are owned by the uid/gid that is root inside the container
+	if c.usesUserNamespace() {
+		m, err := c.manager.userNamespaces.allocate()
+		if err != nil {
+			return err
+		}
+		c.idMapping = m
+		if err := chowns(dirs, m.UID, m.GID); err != nil {
+			return err
+		}
+	}
 
 	c.log.Debug("Done setting up directories.")
 	return nil
 }
 
-// startingFilesystem extracts the provided ACI file into the container
-// filesystem.
+// startingFilesystem sets up the container filesystem from the provided ACI
+// file with the manager's storage driver.
 func (c *Container) startingFilesystem() error {
 	c.log.Debug("Setting up stage2 filesystem")
 
@@ -82,23 +103,25 @@ func (c *Container) startingFilesystem() error {
 		c.initialImageFile = nil
 	}()
 
-	// handle reading the sha
-	sr := hashutil.NewSha512(c.initialImageFile)
-
-	// untar the file
-	tarfile := tarhelper.NewUntar(sr, filepath.Join(c.directory))
-	tarfile.PreserveOwners = true
-	tarfile.PreservePermissions = true
-	tarfile.Compression = tarhelper.DETECT
-	tarfile.AbsoluteRoot = c.directory
-	if err := tarfile.Extract(); err != nil {
-		return fmt.Errorf("failed to extract stage2 image filesystem: %v", err)
+	// Containers with their own user namespace are given their own copy of the
+	// image, since its files are owned by the container's block of IDs.
+	driver := c.manager.storage
+	if c.idMapping != nil {
+		driver = storage.NewPlain(c.idMapping.hostUID, c.idMapping.hostGID)
 	}
+	fs, err := driver.Create(c.uuid, c.directory, c.initialImageFile)
+	if err != nil {
+		return fmt.Errorf("failed to set up stage2 image filesystem: %v", err)
+	}
+	c.mutex.Lock()
+	c.filesystem = fs
+	c.imageSize = fs.ImageSize
+	c.mutex.Unlock()
 
 	// put the hash on the pod manifest
 	for i, app := range c.pod.Apps {
 		if app.Image.Name.Equals(c.image.Name) {
-			if err := app.Image.ID.Set(fmt.Sprintf("sha512-%s", sr.Sha512())); err != nil {
+			if err := app.Image.ID.Set(fs.Image); err != nil {
 				return err
 			}
 			c.pod.Apps[i] = app
@@ -138,8 +161,22 @@ func (c *Container) startingNetworking() error {
 			return err
 		}
 		defer cf.Close()
+		if err := c.chownToRoot(resolvPath); err != nil {
+			return err
+		}
 
-		if _, err := io.Copy(cf, hf); err != nil {
+		// containers which will be connected to the container network use
+		// the host's DNS server on it
+		if c.manager.nameserver != "" && c.manager.network != nil && !c.isHostPrivileged() {
+			b, err := ioutil.ReadAll(hf)
+			if err != nil {
+				return err
+			}
+			conf := containerResolvConf(string(b), c.manager.nameserver, c.manager.nameserverDomain)
+			if _, err := io.WriteString(cf, conf); err != nil {
+				return err
+			}
+		} else if _, err := io.Copy(cf, hf); err != nil {
 			return err
 		}
 	}
@@ -148,26 +185,44 @@ func (c *Container) startingNetworking() error {
 	return nil
 }
 
-// startingEnvironment sets up the environment variables for the container.
+// containerResolvConf returns the resolv.conf for a container using the
+// nameserver, based on the host's. The host's nameservers are replaced, and
+// the domain is searched ahead of the host's search domains.
+func containerResolvConf(host, nameserver, domain string) string {
+	var search, other []string
+	if domain != "" {
+		search = append(search, domain)
+	}
+	for _, line := range strings.Split(host, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+		case "search", "domain":
+			search = append(search, fields[1:]...)
+		default:
+			other = append(other, line)
+		}
+	}
+
+	lines := []string{"nameserver " + nameserver}
+	if len(search) > 0 {
+		lines = append(lines, "search "+strings.Join(search, " "))
+	}
+	lines = append(lines, other...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// startingEnvironment sets up the environment variables for the container
+// which are shared by all of its apps.
 func (c *Container) startingEnvironment() error {
 	c.environment = envmap.NewEnvMap()
 	c.environment.Set("HOME", "/")
 	c.environment.Set("PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin")
 	c.environment.Set("SHELL", "/bin/sh")
 	c.environment.Set("TMPDIR", "/tmp")
-	c.environment.Set("USER", c.image.App.User)
-	c.environment.Set("LOGNAME", c.image.App.User)
-
-	c.environment.Set("AC_APP_NAME", c.image.Name.String())
-	// FIXME set AC_METADATA_URL once metadata API is added
-
-	// Add the application's environment
-	appenv := c.environment.NewChild()
-	for _, env := range c.image.App.Environment {
-		appenv.Set(env.Name, env.Value)
-	}
-	c.environment = appenv
-
 	return nil
 }
 
@@ -182,20 +237,31 @@ func (c *Container) startingCgroups() error {
 		c.log.Debugf("Error setting up the cgroup: %v", err)
 		return err
 	} else {
+		c.mutex.Lock()
 		c.cgroup = cgroup
+		c.cgroupOOMKills = 0
+		c.mutex.Unlock()
+	}
+	if err := c.placeCPUs(); err != nil {
+		return err
+	}
+	if err := c.limitHugepages(); err != nil {
+		return err
 	}
 
-	// FIXME add OOM notification handler
+	// Watch for the container running out of memory, so that the processes
+	// killed because of it are recorded even when they aren't an app.
+	c.watchOOM()
 
 	c.log.Debug("Done setting up cgroup.")
 	return nil
 }
 
-// Start the initd. This doesn't actually configure it, just starts it so we
-// have a process and namespace to work with in the networking side of the
-// world.
+// Start the initd through the container's runtime. This doesn't actually
+// configure it, just starts it so we have a process and namespace to work with
+// in the networking side of the world.
 func (c *Container) launchStage2() error {
-	c.log.Debug("Starting stage 2.")
+	c.log.Debugf("Starting stage 2 with the %s runtime.", c.runtime.Name())
 
 	// Open a log file that all output from the container will be written to
 	var err error
@@ -206,42 +272,54 @@ func (c *Container) launchStage2() error {
 	}
 	defer stage2Stdout.Close()
 
-	// Initialize the stage2 launcher
-	launcher := &client.Launcher{
+	// Describe the container to the runtime
+	requested, declared := c.portMappings()
+	spec := &LaunchSpec{
+		Name:       c.ShortName(),
+		Directory:  c.directory,
+		Root:       c.stage3Path(),
 		SocketPath: c.socketPath(),
-		Directory:  c.stage3Path(),
-		Chroot:     true,
 		Cgroup:     c.cgroup,
-		Stdout:     stage2Stdout,
-		Stderr:     stage2Stdout,
+		Ports:      append(requested, declared...),
+		Output:     stage2Stdout,
 	}
 
 	// Configure which linux namespaces to create
 	nsisolators := false
-	if iso := c.image.App.Isolators.GetByName(kschema.LinuxNamespacesName); iso != nil {
+	if iso := c.isolator(kschema.LinuxNamespacesName); iso != nil {
 		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok {
-			launcher.NewIPCNamespace = niso.IPC()
-			launcher.NewMountNamespace = niso.Mount()
-			launcher.NewNetworkNamespace = niso.Net()
-			launcher.NewPIDNamespace = niso.PID()
-			launcher.NewUserNamespace = niso.User()
-			launcher.NewUTSNamespace = niso.UTS()
+			spec.NewIPCNamespace = niso.IPC()
+			spec.NewMountNamespace = niso.Mount()
+			spec.NewNetworkNamespace = niso.Net()
+			spec.NewPIDNamespace = niso.PID()
+			spec.NewUTSNamespace = niso.UTS()
 			nsisolators = true
 		}
 	}
 	if !nsisolators {
 		// set some defaults if no namespace isolator was given
-		launcher.NewIPCNamespace = true
-		launcher.NewMountNamespace = true
-		launcher.NewPIDNamespace = true
-		launcher.NewUTSNamespace = true
+		spec.NewIPCNamespace = true
+		spec.NewMountNamespace = true
+		spec.NewPIDNamespace = true
+		spec.NewUTSNamespace = true
+
+		// When the container network is configured, containers are isolated in
+		// their own network namespace unless they're host privileged.
+		spec.NewNetworkNamespace = c.manager.network != nil && !c.isHostPrivileged()
+	}
+
+	// Map the container's user namespace to its block of host IDs
+	spec.NewUserNamespace = c.idMapping != nil
+	if c.idMapping != nil {
+		spec.Uidmap = c.idMapping.uidmap()
+		spec.Gidmap = c.idMapping.gidmap()
 	}
 
 	// Check for a privileged isolator
-	if iso := c.image.App.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
+	if iso := c.isolator(kschema.HostPrivilegedName); iso != nil {
 		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
 			if *piso {
-				launcher.HostPrivileged = true
+				spec.HostPrivileged = true
 
 				// create the mount point
 				podsDest, err := c.ensureContainerPathExists("host/pods")
@@ -257,7 +335,7 @@ func (c *Container) launchStage2() error {
 				procMount := strings.Replace(procDest, c.stage3Path(), client.DefaultChrootPath, 1)
 
 				// create the mount point definitions for host access
-				launcher.MountPoints = []*client.MountPoint{
+				spec.MountPoints = []*client.MountPoint{
 					// Add the pods mount
 					&client.MountPoint{
 						Source:      c.manager.containerDirectory,
@@ -290,6 +368,9 @@ func (c *Container) launchStage2() error {
 					},
 				}
 
+				c.addMount(c.manager.containerDirectory, "/host/pods", true)
+				c.addMount("/proc", "/host/proc", false)
+
 				// If a volume directory is defined, then map it in as well.
 				if c.manager.volumeDirectory != "" {
 					volumesDest, err := c.ensureContainerPathExists("host/volumes")
@@ -297,119 +378,239 @@ func (c *Container) launchStage2() error {
 						return err
 					}
 					volumesMount := strings.Replace(volumesDest, c.stage3Path(), client.DefaultChrootPath, 1)
-					launcher.MountPoints = append(launcher.MountPoints,
+					spec.MountPoints = append(spec.MountPoints,
 						&client.MountPoint{
 							Source:      c.manager.volumeDirectory,
 							Destination: volumesMount,
 							Flags:       syscall.MS_BIND,
 						})
+					c.addMount(c.manager.volumeDirectory, "/host/volumes", false)
 				}
 			}
 		}
 	}
 
-	// Apply any volumes that are needed as mount points on the launcher
+	// Apply any volumes that are needed as mount points on the spec. The
+	// apps' mount points use a volume of the same name, which is created if it
+	// doesn't exist yet, while requested volumes must already exist and are
+	// available to all of the apps.
 	if c.manager.volumeDirectory != "" {
-		podApp := c.pod.Apps.Get(types.ACName(c.image.Name.String()))
-		for _, mp := range c.image.App.MountPoints {
-			hostPath, err := c.manager.getVolumePath(mp.Name.String())
+		for _, a := range c.apps {
+			for _, mp := range a.app.MountPoints {
+				hostPath, err := c.manager.getVolumePath(mp.Name.String())
+				if err != nil {
+					return err
+				}
+				err = c.addVolumeMount(spec, []*app{a}, mp.Name, hostPath, mp.Path, mp.ReadOnly)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, v := range c.volumes {
+			hostPath, err := c.manager.existingVolumePath(v.Name)
 			if err != nil {
 				return err
 			}
-
-			podPath, err := c.ensureContainerPathExists(mp.Path)
+			err = c.addVolumeMount(spec, c.apps, types.ACName(v.Name), hostPath, v.Path, v.ReadOnly)
 			if err != nil {
 				return err
 			}
-			podMount := strings.Replace(podPath, c.stage3Path(), client.DefaultChrootPath, 1)
-
-			launcher.MountPoints = append(launcher.MountPoints, &client.MountPoint{
-				Source:      hostPath,
-				Destination: podMount,
-				Flags:       syscall.MS_BIND,
-			})
-
-			// If the mount point should be read only, then add a second mount handler
-			// to trigger it to be read-only.
-			if mp.ReadOnly {
-				launcher.MountPoints = append(launcher.MountPoints, &client.MountPoint{
-					Source:      hostPath,
-					Destination: podMount,
-					Flags:       syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY,
-				})
-			}
+		}
+	}
 
-			// Add to the PodManifest
-			ro := mp.ReadOnly
-			podApp.Mounts = append(podApp.Mounts, schema.Mount{
-				Volume:     mp.Name,
-				MountPoint: mp.Name,
-			})
-			c.pod.Volumes = append(c.pod.Volumes, types.Volume{
-				Name:     mp.Name,
-				Kind:     "host",
-				Source:   hostPath,
-				ReadOnly: &ro,
-			})
+	// If the app requested a console, allocate it and have it bind mounted into
+	// the container so it can be passed to the app. Its output is captured
+	// along with the other apps' stdout.
+	if c.hasConsole() {
+		c.mutex.Lock()
+		capture := c.logCapture
+		c.mutex.Unlock()
+
+		output := io.MultiWriter(capture.writer("stdout"), c.outputTap().writer("stdout"))
+		console, err := newConsole(output)
+		if err != nil {
+			return err
 		}
+		c.mutex.Lock()
+		c.console = console
+		c.mutex.Unlock()
+
+		spec.MountPoints = append(spec.MountPoints, &client.MountPoint{
+			Source:      console.slave.Name(),
+			Destination: filepath.Join(client.DefaultChrootPath, consolePath),
+			Flags:       syscall.MS_BIND,
+		})
 	}
 
-	client, err := launcher.Run()
+	// Mount the tmpfs scratch areas, hugepages, and the secrets, and then make
+	// the root filesystem read-only if requested.
+	if err := c.addTmpfsMounts(spec); err != nil {
+		return err
+	}
+	if err := c.addHugepageMounts(spec); err != nil {
+		return err
+	}
+	if err := c.addSecretMounts(spec); err != nil {
+		return err
+	}
+	if c.readOnlyRootFS {
+		spec.ReadOnly = true
+		spec.Writable = []string{c.appStdoutPath(), c.appStderrPath()}
+	}
+
+	if c.manager.runtimeBundles && c.Isolation() == IsolationNamespaces {
+		if err := c.writeRuntimeBundle(spec); err != nil {
+			return fmt.Errorf("failed to write the runtime bundle: %v", err)
+		}
+	}
+
+	initdClient, err := c.runtime.Launch(spec)
 	if err != nil {
 		return err
 	}
 	c.mutex.Lock()
-	c.initdClient = client
+	c.initdClient = initdClient
+	c.networkNamespace = spec.NewNetworkNamespace
+	c.ipcNamespace = spec.NewIPCNamespace
 	c.mutex.Unlock()
 
 	c.log.Trace("Done starting stage 2.")
 	return nil
 }
 
-// startApp will start the application defined in the image manifest within the
-// pod.
-func (c *Container) startApp() error {
-	client := c.getInitdClient()
-
-	// iterate the command arguments and fill in any potential environment
-	// variable references
-	envmap := c.environment.Map()
-	envfunc := func(env string) string { return envmap[env] }
-	cmdargs := make([]string, len(c.image.App.Exec))
-	copy(cmdargs, c.image.App.Exec)
-	for i, s := range cmdargs {
-		cmdargs[i] = os.Expand(s, envfunc)
-	}
-
-	// validate the working directory
-	workingDirectory := c.image.App.WorkingDirectory
-	if workingDirectory == "" {
-		workingDirectory = "/"
-	}
-
-	c.log.Tracef("Launching application [%q:%q]: %#v", c.image.App.User, c.image.App.Group, cmdargs)
-	c.log.Tracef("Application environment: %#v", c.environment.Strings())
-	err := client.Start(
-		"app", cmdargs, workingDirectory, c.environment.Strings(),
-		"/app.stdout", "/app.stderr",
-		c.image.App.User, c.image.App.Group,
-		time.Second*5)
+// startingContainerNetwork connects the container's network namespace to the
+// container network, if it has its own namespace and the network is configured.
+func (c *Container) startingContainerNetwork() error {
+	c.mutex.Lock()
+	networkNamespace := c.networkNamespace
+	c.mutex.Unlock()
+	if !networkNamespace || c.manager.network == nil {
+		if c.bandwidth.Limited() {
+			return fmt.Errorf("bandwidth limits require the container to be connected to the container network")
+		}
+		return nil
+	}
+
+	c.log.Debug("Connecting the container to the network.")
+	endpoint, err := c.manager.network.Setup(c.ShortName(), c.Pid())
+	if err != nil {
+		return fmt.Errorf("failed to set up the container network: %v", err)
+	}
+	c.mutex.Lock()
+	c.endpoint = endpoint
+	c.mutex.Unlock()
+
+	if c.bandwidth.Limited() {
+		if err := c.manager.network.Limit(endpoint, c.bandwidth); err != nil {
+			return fmt.Errorf("failed to limit the container's bandwidth: %v", err)
+		}
+		c.log.Debugf("Limited the bandwidth to %d bytes/s in and %d bytes/s out", c.bandwidth.Ingress, c.bandwidth.Egress)
+	}
+
+	if endpoint.Address6 != nil {
+		c.log.Debugf("Container has addresses %s and %s via %s", endpoint.Address, endpoint.Address6, endpoint.HostInterface)
+	} else {
+		c.log.Debugf("Container has address %s via %s", endpoint.Address, endpoint.HostInterface)
+	}
+	return c.publishPorts(endpoint)
+}
+
+// addVolumeMount configures the spec to bind mount the volume's host path
+// into the container at the specified path and records it in the pod manifest
+// for the specified apps.
+func (c *Container) addVolumeMount(
+	spec *LaunchSpec, apps []*app, name types.ACName, hostPath, path string, readOnly bool,
+) error {
+	podPath, err := c.ensureContainerPathExists(path)
 	if err != nil {
 		return err
 	}
+	podMount := strings.Replace(podPath, c.stage3Path(), client.DefaultChrootPath, 1)
+
+	spec.MountPoints = append(spec.MountPoints, &client.MountPoint{
+		Source:      hostPath,
+		Destination: podMount,
+		Flags:       syscall.MS_BIND,
+	})
+
+	// If the mount point should be read only, then add a second mount handler
+	// to trigger it to be read-only.
+	if readOnly {
+		spec.MountPoints = append(spec.MountPoints, &client.MountPoint{
+			Source:      hostPath,
+			Destination: podMount,
+			Flags:       syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY,
+		})
+	}
+
+	c.addMount(hostPath, path, readOnly)
+
+	// Add to the PodManifest
+	for _, a := range apps {
+		for i := range c.pod.Apps {
+			if c.pod.Apps[i].Name.Equals(a.name) {
+				c.pod.Apps[i].Mounts = append(c.pod.Apps[i].Mounts, schema.Mount{
+					Volume:     name,
+					MountPoint: name,
+				})
+			}
+		}
+	}
+	for _, v := range c.pod.Volumes {
+		if v.Name == name {
+			return nil
+		}
+	}
+	c.pod.Volumes = append(c.pod.Volumes, types.Volume{
+		Name:     name,
+		Kind:     "host",
+		Source:   hostPath,
+		ReadOnly: &readOnly,
+	})
+	return nil
+}
+
+// startApp will start each of the applications defined in the pod.
+func (c *Container) startApp() error {
+	c.mutex.Lock()
+	c.startTime = time.Now()
+	c.mutex.Unlock()
+
+	for _, a := range c.apps {
+		if err := c.startAppProcess(a); err != nil {
+			return err
+		}
+	}
+
+	if c.console != nil {
+		go c.console.run()
+	}
 
 	// Start a goroutine to handle transitioning to the exited state when all
 	// processes die.
-	go c.waitLoop()
+	c.startWaitLoop()
 
 	return nil
 }
 
+// startWaitLoop launches the wait loop. The channel it closes once it returns is
+// kept so that stopping the apps can wait for it to be done.
+func (c *Container) startWaitLoop() {
+	done := make(chan bool)
+	c.mutex.Lock()
+	c.waitLoopch = done
+	c.mutex.Unlock()
+	go c.waitLoop(done)
+}
+
 // waitLoop continously runs a combination of 'WAIT' and 'STATUS' on the initd
-// client in order to get notifications of process termination.
-func (c *Container) waitLoop() {
+// client in order to get notifications of process termination. The done
+// channel is closed when it returns.
+func (c *Container) waitLoop(done chan bool) {
 	c.log.Debug("Starting wait loop")
 	defer c.log.Debug("Done with wait loop")
+	defer close(done)
 
 	initdClient := c.getInitdClient()
 	if initdClient == nil {
@@ -449,6 +650,10 @@ func (c *Container) waitLoop() {
 						c.log.Info("Container is shutting down, ignoring Wait() error")
 						return
 					}
+					if c.isCheckpointing() {
+						c.log.Info("Container is being checkpointed, ignoring Wait() error")
+						return
+					}
 					c.log.Warn("Retrying failed Wait()")
 				}
 			}
@@ -462,6 +667,10 @@ func (c *Container) waitLoop() {
 				c.log.Info("Container is shutting down, ignoring Status() error")
 				return
 			}
+			if c.isCheckpointing() {
+				c.log.Info("Container is being checkpointed, ignoring Status() error")
+				return
+			}
 			c.log.Error("Marking container as failed after Status() error")
 			c.markExited()
 			return
@@ -475,7 +684,25 @@ func (c *Container) waitLoop() {
 			}
 		}
 
+		// Record the state of the apps and restart any which have exited if
+		// their restart policy calls for it.
+		restarting := c.updateApps(statuses)
+
 		if nProcsRunning == 0 {
+			// If the apps were stopped, the container is left in place so they
+			// can be started again with a new wait loop.
+			if c.isStopping() {
+				c.log.Debug("The container's apps were stopped, exiting wait loop")
+				return
+			}
+
+			// Wait() won't block while no processes are running, so wait for
+			// the pending restarts to be handled before checking again.
+			if restarting {
+				<-c.restartch
+				continue
+			}
+
 			c.log.Debugf("There were no running processes in the container, tearing it down, marking exited.")
 			c.markExited()
 			return
@@ -487,40 +714,11 @@ func (c *Container) waitLoop() {
 // current container's cgroup and then deleting the cgroup itself.
 func (c *Container) stoppingCgroups() error {
 	c.log.Trace("Tearing down cgroups containers.")
+	c.stopWatchingOOM()
+	c.stopWatchingHealth()
 
-	if c.cgroup == nil {
-		//  Do nothing, the cgroup was never setup in the first place.
-	} else if d, err := c.cgroup.Destroyed(); err != nil {
-		return err
-	} else if d == false {
-		// Now loop through trying to kill all children in the container. This
-		// may end up competing with the kernel's zap task. This may take a
-		// short period of time so we make sure to induce a very short sleep
-		// between iterations.
-		for duration := 10 * time.Millisecond; true; duration *= 2 {
-			_, err := c.cgroup.SignalAll(syscall.SIGKILL)
-			if err != nil {
-				return fmt.Errorf("error killing processes: %s", err)
-			} else if tasks, _ := c.cgroup.Tasks(); len(tasks) < 2 {
-				// No processes killed. The container has no processes
-				// running inside of it (including the initd process).
-				// It should now be safe to shut it down.
-				break
-			}
-
-			// Once we send SIGKILL to all processes it will take a small
-			// amount of time for parents to be notified of children's
-			// death, and for all the various resource cleanup to happen.
-			// Since we don't have a callback for when that is complete we
-			// sleep here a very small amount of time before we try again.
-			// Each iteration we increase the sleep so that we don't almost
-			// busy loop the host OS.
-			time.Sleep(duration)
-		}
-
-		// So the cgroup should no longer have members. Because of this we can
-		// Destroy it safely.
-		if err := c.cgroup.Destroy(); err != nil {
+	if c.cgroup != nil {
+		if err := destroyCgroup(c.cgroup); err != nil {
 			return err
 		}
 	}
@@ -530,10 +728,81 @@ func (c *Container) stoppingCgroups() error {
 	c.cgroup = nil
 	c.mutex.Unlock()
 
+	// With its processes gone, the container's CPUs can be given to another
+	c.releaseCPUs()
+
 	c.log.Trace("Done tearing down cgroups containers.")
 	return nil
 }
 
+// destroyCgroup kills all of the processes within the cgroup and then removes
+// it, unless it is already gone.
+func destroyCgroup(cgroup *cgroups.Cgroup) error {
+	if d, err := cgroup.Destroyed(); err != nil || d {
+		return err
+	}
+
+	// Now loop through trying to kill all children in the container. This may
+	// end up competing with the kernel's zap task. This may take a short period
+	// of time so we make sure to induce a very short sleep between iterations.
+	for duration := 10 * time.Millisecond; true; duration *= 2 {
+		_, err := cgroup.SignalAll(syscall.SIGKILL)
+		if err != nil {
+			return fmt.Errorf("error killing processes: %s", err)
+		} else if tasks, _ := cgroup.Tasks(); len(tasks) < 2 {
+			// No processes killed. The container has no processes running
+			// inside of it (including the initd process). It should now be
+			// safe to shut it down.
+			break
+		}
+
+		// Once we send SIGKILL to all processes it will take a small amount
+		// of time for parents to be notified of children's death, and for all
+		// the various resource cleanup to happen. Since we don't have a
+		// callback for when that is complete we sleep here a very small amount
+		// of time before we try again. Each iteration we increase the sleep so
+		// that we don't almost busy loop the host OS.
+		time.Sleep(duration)
+	}
+
+	// So the cgroup should no longer have members. Because of this we can
+	// Destroy it safely.
+	return cgroup.Destroy()
+}
+
+// stoppingContainerNetwork removes the container's connection to the network
+// and releases its address.
+func (c *Container) stoppingContainerNetwork() error {
+	c.mutex.Lock()
+	endpoint := c.endpoint
+	c.endpoint = nil
+	c.ports = nil
+	c.mutex.Unlock()
+	if endpoint == nil {
+		return nil
+	}
+
+	c.log.Trace("Removing the container from the network.")
+	if err := c.manager.network.Teardown(endpoint); err != nil {
+		c.log.Warnf("Failed to remove %s: %v", endpoint.HostInterface, err)
+	}
+	return nil
+}
+
+// stoppingConsole releases the container's console, if it has one.
+func (c *Container) stoppingConsole() error {
+	c.mutex.Lock()
+	console := c.console
+	c.console = nil
+	c.mutex.Unlock()
+
+	if console != nil {
+		c.log.Trace("Closing the console.")
+		console.close()
+	}
+	return nil
+}
+
 // stoppingDirectories removes the directories associated with this Container.
 func (c *Container) stoppingDirectories() error {
 	c.log.Trace("Removing container directories.")
@@ -549,6 +818,25 @@ func (c *Container) stoppingDirectories() error {
 		return err
 	}
 
+	// Release the container's filesystem from the storage driver, which may
+	// hold it outside of the container's directory
+	if c.filesystem != nil {
+		driver, err := c.manager.storageDriver(c.filesystem)
+		if err == nil {
+			err = driver.Remove(c.uuid, c.directory, c.filesystem)
+		}
+		if err != nil {
+			c.log.Warnf("failed to remove the container's filesystem: %s", err)
+			return err
+		}
+		c.filesystem = nil
+	}
+
+	if err := c.removeQuota(); err != nil {
+		c.log.Warnf("failed to remove the container's disk quota: %s", err)
+		return err
+	}
+
 	// Remove the directory that was created for this container, unless it is
 	// specified to keep it.
 	if err := os.RemoveAll(c.directory); err != nil {
@@ -557,6 +845,12 @@ func (c *Container) stoppingDirectories() error {
 		}
 	}
 
+	// With its files gone, the container's IDs can be given to another
+	if c.idMapping != nil {
+		c.manager.userNamespaces.release(c.idMapping)
+		c.idMapping = nil
+	}
+
 	c.log.Trace("Done tearing down container directories.")
 	return nil
 }
@@ -566,5 +860,6 @@ func (c *Container) stoppingDirectories() error {
 func (c *Container) stoppingrRemoveFromParent() error {
 	c.log.Trace("Removing from the Container Manager.")
 	c.manager.remove(c)
+	c.publish(&Event{Type: EventDestroyed})
 	return nil
 }
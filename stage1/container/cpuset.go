@@ -0,0 +1,224 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/util/cpuset"
+)
+
+// defaultReservedCPUs are the CPUs kept for kurma and the containers sharing
+// the host's CPUs when the manager isn't configured with others.
+const defaultReservedCPUs = "0"
+
+// CPUPlacement places a container's processes on the host's CPUs. A container
+// without CPUs of its own shares those which aren't allocated to any
+// container, on its NUMA node if it has one.
+type CPUPlacement struct {
+	// ExclusiveCPUs is how many CPUs to allocate for the container's use
+	// alone.
+	ExclusiveCPUs int `json:"exclusive_cpus,omitempty"`
+
+	// NUMANode, if set, keeps the container's processes and their memory on
+	// the node.
+	NUMANode *int `json:"numa_node,omitempty"`
+}
+
+// node returns the NUMA node the placement keeps the container on, or
+// cpuset.AnyNode.
+func (p *CPUPlacement) node() int {
+	if p == nil || p.NUMANode == nil {
+		return cpuset.AnyNode
+	}
+	return *p.NUMANode
+}
+
+// newCPUPool returns the pool the containers' exclusive CPUs are allocated
+// from. If the host's CPUs can't be read, the containers share all of them and
+// none can be given CPUs of its own.
+func (manager *Manager) newCPUPool(reserved string) (*cpuset.Pool, error) {
+	if reserved == "" {
+		reserved = defaultReservedCPUs
+	}
+	cpus, err := cpuset.Parse(reserved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reserved CPUs: %v", err)
+	}
+	topology, err := cpuset.ReadTopology("/sys")
+	if err != nil {
+		manager.Log.Warnf("Failed to read the host's CPUs, containers can't be given CPUs of their own: %v", err)
+		return nil, nil
+	}
+	pool, err := cpuset.NewPool(topology, cpus)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reserved CPUs: %v", err)
+	}
+	return pool, nil
+}
+
+// validateCPUPlacement checks that the placement can be met by the host, other
+// than for the CPUs which are allocated to other containers.
+func (manager *Manager) validateCPUPlacement(p *CPUPlacement) error {
+	if p == nil {
+		return nil
+	}
+	if p.ExclusiveCPUs < 0 {
+		return fmt.Errorf("the number of exclusive CPUs must not be negative")
+	}
+	if p.ExclusiveCPUs == 0 && p.NUMANode == nil {
+		return nil
+	}
+	if manager.cpus == nil {
+		return fmt.Errorf("the host's CPUs are unknown, so the container can't be placed on them")
+	}
+	node := p.node()
+	if node != cpuset.AnyNode && !manager.cpus.Topology().HasNode(node) {
+		return fmt.Errorf("the host has no NUMA node %d with CPUs", node)
+	}
+	if capacity := manager.cpus.Capacity(node); p.ExclusiveCPUs > capacity {
+		if node != cpuset.AnyNode {
+			return fmt.Errorf("only %d of the CPUs on node %d may be given to a container", capacity, node)
+		}
+		return fmt.Errorf("only %d of the host's CPUs may be given to a container", capacity)
+	}
+	return nil
+}
+
+// placeCPUs allocates the container's exclusive CPUs, if it asked for any, and
+// places its cgroup on its CPUs and memory nodes. The containers sharing the
+// host's CPUs are moved off those allocated.
+func (c *Container) placeCPUs() error {
+	pool := c.manager.cpus
+	if pool == nil {
+		return nil
+	}
+	if p := c.cpuPlacement; p != nil && p.ExclusiveCPUs > 0 {
+		cpus, err := pool.Allocate(c.uuid, p.ExclusiveCPUs, p.node())
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		c.cpus = cpus
+		c.mutex.Unlock()
+		c.log.Debugf("Allocated CPUs %s to the container.", cpuset.Format(cpus))
+		c.manager.updateSharedCPUs()
+	}
+	if err := c.applyCPUs(); err != nil {
+		c.releaseCPUs()
+		return fmt.Errorf("failed to place the container on its CPUs: %v", err)
+	}
+	return nil
+}
+
+// recoverCPUs records that the CPUs the container was given by an earlier
+// manager are still its own. If they can't be, as when the reserved CPUs have
+// changed, the container is moved onto the shared CPUs instead.
+func (c *Container) recoverCPUs(cpus []int) {
+	pool := c.manager.cpus
+	if pool == nil || len(cpus) == 0 {
+		return
+	}
+	if err := pool.Claim(c.uuid, cpus); err != nil {
+		c.log.Warnf("Failed to recover the container's CPUs, moving it to the shared CPUs: %v", err)
+		if err := c.applyCPUs(); err != nil {
+			c.log.Warnf("Failed to place the container on the shared CPUs: %v", err)
+		}
+		return
+	}
+	c.mutex.Lock()
+	c.cpus = cpus
+	c.mutex.Unlock()
+}
+
+// releaseCPUs returns the container's exclusive CPUs to the pool, and gives
+// them back to the containers sharing the host's CPUs.
+func (c *Container) releaseCPUs() {
+	c.mutex.Lock()
+	cpus := c.cpus
+	c.cpus = nil
+	c.mutex.Unlock()
+	if len(cpus) == 0 {
+		return
+	}
+	c.manager.cpus.Release(c.uuid)
+	c.manager.updateSharedCPUs()
+}
+
+// cpuset returns the CPUs and memory nodes the container is placed on: its
+// exclusive CPUs, or the shared CPUs of its node, which are those of any node
+// if the rest of its node's CPUs are allocated.
+func (c *Container) cpuset() (cpus, mems []int) {
+	pool := c.manager.cpus
+	c.mutex.Lock()
+	exclusive := c.cpus
+	node := c.cpuPlacement.node()
+	c.mutex.Unlock()
+
+	cpus = exclusive
+	if len(cpus) == 0 {
+		if cpus = pool.Shared(node); len(cpus) == 0 {
+			cpus = pool.Shared(cpuset.AnyNode)
+		}
+	}
+	switch {
+	case node != cpuset.AnyNode:
+		mems = []int{node}
+	case len(exclusive) > 0:
+		mems = pool.Topology().NodesOf(exclusive)
+	default:
+		mems = pool.Topology().Nodes
+	}
+	return cpus, mems
+}
+
+// applyCPUs writes the container's CPUs and memory nodes to its cgroup.
+func (c *Container) applyCPUs() error {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return nil
+	}
+	cpus, mems := c.cpuset()
+	return cgroup.SetCpuset(cpuset.Format(cpus), cpuset.Format(mems))
+}
+
+// updateSharedCPUs places the containers without CPUs of their own on the
+// shared CPUs, once some have been allocated or released.
+func (manager *Manager) updateSharedCPUs() {
+	for _, c := range manager.Containers() {
+		c.mutex.Lock()
+		shared := c.cgroup != nil && len(c.cpus) == 0
+		c.mutex.Unlock()
+		if !shared {
+			continue
+		}
+		if err := c.applyCPUs(); err != nil {
+			c.log.Warnf("Failed to place the container on the shared CPUs: %v", err)
+		}
+	}
+}
+
+// CPUs returns the CPUs and memory nodes the container's processes are placed
+// on, as lists such as "0-3,8", or empty strings if it has no cgroup.
+func (c *Container) CPUs() (cpus, mems string) {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return "", ""
+	}
+	cpus, mems, err := cgroup.Cpuset()
+	if err != nil {
+		c.log.Warnf("Failed to read the container's CPUs: %v", err)
+		return "", ""
+	}
+	return cpus, mems
+}
+
+// CPUPlacement returns where the container asked to be placed on the host's
+// CPUs, which may be nil.
+func (c *Container) CPUPlacement() *CPUPlacement {
+	return c.cpuPlacement
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"time"
+)
+
+// EventType identifies what happened in an Event.
+type EventType string
+
+const (
+	// EventCreated is published when a container is created.
+	EventCreated = EventType("created")
+
+	// EventStarted is published when a container's apps are started.
+	EventStarted = EventType("started")
+
+	// EventExited is published when all of a container's apps have exited.
+	EventExited = EventType("exited")
+
+	// EventStopped is published when a container's apps have been stopped,
+	// leaving the container in place.
+	EventStopped = EventType("stopped")
+
+	// EventOOMKilled is published when one of a container's processes is killed
+	// for exceeding its memory limit.
+	EventOOMKilled = EventType("oom-killed")
+
+	// EventDiskQuotaExceeded is published when a container's disk usage reaches
+	// its disk limit.
+	EventDiskQuotaExceeded = EventType("disk-quota-exceeded")
+
+	// EventUnhealthy is published when a container's health check has failed
+	// enough times in a row, and EventHealthy when it passes again.
+	EventUnhealthy = EventType("unhealthy")
+	EventHealthy   = EventType("healthy")
+
+	// EventThrottled is published when a container's memory is throttled, and
+	// EventEvicted when its apps are stopped, because the host is short of
+	// memory.
+	EventThrottled = EventType("throttled")
+	EventEvicted   = EventType("evicted")
+
+	// EventDestroyed is published once a container has been torn down.
+	EventDestroyed = EventType("destroyed")
+
+	// EventImagePulled is published when an image is retrieved from a remote
+	// location.
+	EventImagePulled = EventType("image-pulled")
+)
+
+// eventBufferSize is how many events can be queued for a subscriber before
+// further events are dropped for it.
+const eventBufferSize = 100
+
+// Event describes a change in the lifecycle of a container, or an image being
+// retrieved for one.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// UUID is the container the event is for. It is empty for image events.
+	UUID string
+
+	// App is the app an OOM kill was attributed to, if known.
+	App string
+
+	// Image is the name of the image for created and image-pulled events.
+	Image string
+
+	// ExitCode is set for exited, stopped, and evicted events.
+	ExitCode int
+
+	// Reason says why the container was throttled or evicted.
+	Reason string
+}
+
+// Subscribe returns a channel which receives the events published from now on,
+// along with a function to cancel the subscription. Events are dropped for
+// subscribers which don't keep up rather than blocking the containers.
+func (manager *Manager) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, eventBufferSize)
+
+	manager.subscribersLock.Lock()
+	manager.subscribers[ch] = true
+	manager.subscribersLock.Unlock()
+
+	cancel := func() {
+		manager.subscribersLock.Lock()
+		defer manager.subscribersLock.Unlock()
+		if manager.subscribers[ch] {
+			delete(manager.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Publish sends the event to all of the current subscribers. The event's time
+// is set if it is zero.
+func (manager *Manager) Publish(e *Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	manager.subscribersLock.Lock()
+	defer manager.subscribersLock.Unlock()
+	for ch := range manager.subscribers {
+		select {
+		case ch <- e:
+		default:
+			manager.Log.Warnf("Dropped %s event for a subscriber which is not keeping up", e.Type)
+		}
+	}
+}
+
+// publish sends an event for the container.
+func (c *Container) publish(e *Event) {
+	e.UUID = c.uuid
+	c.manager.Publish(e)
+}
+
+// checkOOMKills publishes an event for each process in the container which has
+// been killed by the OOM killer since it was last checked, and returns how many
+// there were. The app is the one which was seen to be killed with SIGKILL, if
+// any.
+func (c *Container) checkOOMKills(app string) int64 {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return 0
+	}
+
+	kills, err := cgroup.OOMKills()
+	if err != nil {
+		c.log.Warnf("Failed to check for OOM kills: %v", err)
+		return 0
+	}
+
+	c.mutex.Lock()
+	n := kills - c.cgroupOOMKills
+	if n <= 0 {
+		c.mutex.Unlock()
+		return 0
+	}
+	c.cgroupOOMKills = kills
+	c.oomKills += n
+	c.mutex.Unlock()
+
+	c.log.Warnf("%d process(es) killed for exceeding the memory limit", n)
+	for i := n; i > 0; i-- {
+		c.publish(&Event{Type: EventOOMKilled, App: app})
+	}
+	return n
+}
@@ -0,0 +1,227 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apcera/kurma/util/cgroups"
+)
+
+const (
+	// defaultEvictionInterval is how often the host's memory is checked when
+	// the interval isn't configured. It matches the window of the pressure
+	// which is compared, so that each check sees the effect of the last.
+	defaultEvictionInterval = 10 * time.Second
+
+	// throttleRatio is the share of its usage a throttled container's memory
+	// is held to, so that the kernel reclaims the rest from it first.
+	throttleRatio = 0.75
+
+	// throttleLiftDelay is how long the host must have had enough memory
+	// before the throttled containers are given theirs back.
+	throttleLiftDelay = time.Minute
+)
+
+// EvictionOptions sets when the host is short of memory. It is once some of
+// its tasks were stalled waiting on memory for at least Pressure percent of
+// the last 10 seconds, on kernels which report pressure stall information, or
+// once less than MinAvailable bytes of memory are available. Either is ignored
+// if it is 0. The host is checked every Interval, and at most one container is
+// throttled or evicted each time.
+type EvictionOptions struct {
+	Pressure     float64
+	MinAvailable int64
+	Interval     time.Duration
+}
+
+// watchMemory checks the host's memory every interval until the manager shuts
+// down, and throttles or evicts a container each time the host is short.
+// Containers are given back their memory once the host has had enough for a
+// while.
+func (manager *Manager) watchMemory(opts EvictionOptions) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultEvictionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var calm time.Time
+	for range ticker.C {
+		manager.containersLock.RLock()
+		shuttingDown, handingOff := manager.shuttingDown, manager.handingOff
+		manager.containersLock.RUnlock()
+		if shuttingDown {
+			return
+		} else if handingOff {
+			continue
+		}
+
+		reason := manager.memoryShortage(opts)
+		if reason == "" {
+			if calm.IsZero() {
+				calm = time.Now()
+			} else if time.Since(calm) >= throttleLiftDelay {
+				manager.liftThrottles()
+			}
+			continue
+		}
+		calm = time.Time{}
+		manager.relieveMemory(reason)
+	}
+}
+
+// memoryShortage returns why the host is short of memory, or an empty string
+// if it isn't.
+func (manager *Manager) memoryShortage(opts EvictionOptions) string {
+	if opts.Pressure > 0 {
+		p, err := cgroups.HostPressure("memory")
+		if err == nil && p.Some.Avg10 >= opts.Pressure {
+			return fmt.Sprintf("memory pressure of %.2f%% over the last 10 seconds", p.Some.Avg10)
+		} else if err != nil && err != cgroups.ErrNoPressure {
+			manager.Log.Debugf("Failed to read the host's memory pressure: %v", err)
+		}
+	}
+	if opts.MinAvailable > 0 {
+		available, err := hostMeminfo("MemAvailable")
+		if err == nil && available < opts.MinAvailable {
+			return fmt.Sprintf("only %d MiB of memory available", available>>20)
+		} else if err != nil {
+			manager.Log.Debugf("Failed to read the host's available memory: %v", err)
+		}
+	}
+	return ""
+}
+
+// evictionCandidate is a running container which may be throttled or evicted,
+// with how much memory it is using.
+type evictionCandidate struct {
+	container *Container
+	priority  Priority
+	usage     int64
+	throttled bool
+}
+
+// relieveMemory throttles the container using the most memory among those of
+// the lowest priority running on the host. Once each of them is throttled, the
+// one using the most is evicted instead. Critical containers are left alone.
+func (manager *Manager) relieveMemory(reason string) {
+	var candidates []*evictionCandidate
+	for _, c := range manager.Containers() {
+		c.mutex.Lock()
+		cgroup, priority, throttled := c.cgroup, c.Priority(), c.throttled
+		running := !c.shuttingDown && (c.state == RUNNING || c.state == RESTARTING)
+		c.mutex.Unlock()
+		if !running || cgroup == nil || priority == PriorityCritical {
+			continue
+		}
+		usage, err := cgroup.MemoryUsed()
+		if err != nil {
+			c.log.Debugf("Failed to read the memory usage: %v", err)
+			continue
+		}
+		candidates = append(candidates, &evictionCandidate{
+			container: c,
+			priority:  priority,
+			usage:     usage,
+			throttled: throttled,
+		})
+	}
+	if len(candidates) == 0 {
+		manager.Log.Debugf("The host has %s, but no containers may be evicted", reason)
+		return
+	}
+
+	sort.Sort(byEviction(candidates))
+	lowest := candidates[0].priority
+	for _, cand := range candidates {
+		if cand.priority != lowest {
+			break
+		}
+		if !cand.throttled {
+			cand.container.throttleMemory(cand.usage, reason)
+			return
+		}
+	}
+	candidates[0].container.evict(reason)
+}
+
+// byEviction sorts candidates from the first to be throttled or evicted: by
+// priority, and then by the memory they use, the most first.
+type byEviction []*evictionCandidate
+
+func (s byEviction) Len() int      { return len(s) }
+func (s byEviction) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byEviction) Less(i, j int) bool {
+	if s[i].priority.rank() != s[j].priority.rank() {
+		return s[i].priority.rank() < s[j].priority.rank()
+	}
+	return s[i].usage > s[j].usage
+}
+
+// throttleMemory holds the container to a share of the memory it is using, so
+// that the kernel reclaims the rest from it before others.
+func (c *Container) throttleMemory(usage int64, reason string) {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return
+	}
+
+	limit := int64(float64(usage) * throttleRatio)
+	if err := cgroup.ThrottleMemory(limit); err != nil {
+		c.log.Warnf("Failed to throttle the container's memory: %v", err)
+		return
+	}
+	c.mutex.Lock()
+	c.throttled = true
+	c.mutex.Unlock()
+	c.saveState()
+	c.log.Warnf("Throttled the container's memory to %d MiB, the host has %s", limit>>20, reason)
+	c.publish(&Event{Type: EventThrottled, Reason: reason})
+}
+
+// unthrottleMemory gives the container back the memory it was throttled from.
+func (c *Container) unthrottleMemory() {
+	c.mutex.Lock()
+	cgroup, throttled := c.cgroup, c.throttled
+	c.throttled = false
+	c.mutex.Unlock()
+	if cgroup == nil || !throttled {
+		return
+	}
+	if err := cgroup.ThrottleMemory(0); err != nil {
+		c.log.Warnf("Failed to lift the throttle on the container's memory: %v", err)
+	}
+	c.saveState()
+}
+
+// liftThrottles gives back the memory of each of the throttled containers.
+func (manager *Manager) liftThrottles() {
+	for _, c := range manager.Containers() {
+		c.mutex.Lock()
+		throttled := c.throttled
+		c.mutex.Unlock()
+		if throttled {
+			c.unthrottleMemory()
+			c.log.Info("Lifted the throttle on the container's memory.")
+		}
+	}
+}
+
+// evict stops the container's apps, leaving the container in place so that it
+// can be inspected and started again.
+func (c *Container) evict(reason string) {
+	c.log.Warnf("Evicting the container, the host has %s", reason)
+	if err := c.stopApps(c.StopGracePeriod()); err != nil {
+		c.log.Warnf("Failed to evict the container: %v", err)
+		return
+	}
+	c.unthrottleMemory()
+	c.saveState()
+	c.publish(&Event{Type: EventEvicted, ExitCode: c.ExitCode(), Reason: reason})
+}
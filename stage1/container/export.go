@@ -0,0 +1,122 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apcera/util/proc"
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+)
+
+// Export writes a tar archive of the container's root filesystem as it
+// currently is to w. Files owned by the container's user namespace are archived
+// with their owners within it. Filesystems mounted within the root filesystem
+// on the host, such as the host's directories given to privileged containers,
+// are left out.
+func (c *Container) Export(w io.Writer) error {
+	c.log.Debug("Exporting the container's filesystem.")
+	return c.archiveRootfs(w, "")
+}
+
+// Commit writes an ACI with the manifest and the container's root filesystem as
+// it currently is to w, so that containers can be created with the changes made
+// within it. The files kurma adds to capture the apps' output are left out.
+// Files which are being written as the image is committed may be captured
+// partway through the change.
+func (c *Container) Commit(w io.Writer, manifest *schema.ImageManifest) error {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{
+		Name:    "manifest",
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+
+	// the root filesystem is archived under rootfs and copied into the image
+	// after its manifest
+	r, pw := io.Pipe()
+	go func() {
+		excluded := []string{
+			filepath.Base(c.appStdinPath()),
+			filepath.Base(c.appStdoutPath()),
+			filepath.Base(c.appStderrPath()),
+		}
+		pw.CloseWithError(c.archiveRootfs(pw, "rootfs", excluded...))
+	}()
+	defer r.Close()
+
+	c.log.Debugf("Committing the container's filesystem as %s.", manifest.Name)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// archiveRootfs writes a tar archive of the container's root filesystem to w,
+// with its files under the prefix. The excluded paths are relative to the root
+// filesystem, and any filesystems mounted within it on the host are also left
+// out.
+func (c *Container) archiveRootfs(w io.Writer, prefix string, excluded ...string) error {
+	c.mutex.Lock()
+	fs := c.filesystem
+	c.mutex.Unlock()
+	if fs == nil {
+		return fmt.Errorf("the container's filesystem is not set up")
+	}
+
+	root := c.stage3Path()
+	t := tarhelper.NewTar(w, root)
+	t.IncludeOwners = true
+	t.VirtualPath = prefix
+	if m := c.idMapping; m != nil {
+		t.OwnerMappingFunc = m.containerUID
+		t.GroupMappingFunc = m.containerGID
+	}
+
+	mounts, err := proc.MountPoints()
+	if err != nil {
+		return err
+	}
+	for path := range mounts {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		excluded = append(excluded, rel)
+	}
+	for _, path := range excluded {
+		t.ExcludePath(regexp.QuoteMeta(path))
+	}
+	return t.Archive()
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errHandingOff is returned when a container is created or restored once the
+// manager has begun handing its containers off to an upgraded kurma.
+var errHandingOff = errors.New("the host is being upgraded")
+
+// Handoff prepares the containers to be recovered by a newer kurma which is to
+// replace the manager's process through an exec. The state of each container
+// is saved, and the files capturing its output are left open across the exec,
+// so that the apps' writes don't fail while no kurma is reading them. It fails
+// if any container is starting, stopping, or restarting, since that would be
+// lost along with the process, or if a VM is running, since its agent can't be
+// reconnected to. No more containers are created until the handoff is
+// cancelled.
+func (manager *Manager) Handoff() error {
+	manager.containersLock.Lock()
+	if manager.shuttingDown {
+		manager.containersLock.Unlock()
+		return errHostShuttingDown
+	}
+	if manager.handingOff {
+		manager.containersLock.Unlock()
+		return fmt.Errorf("the containers are already being handed off")
+	}
+	containers := make([]*Container, 0, len(manager.containers))
+	for _, c := range manager.containers {
+		s := c.State()
+		switch s {
+		case NEW, STARTING, STOPPING, RESTARTING:
+			manager.containersLock.Unlock()
+			return fmt.Errorf("container %s is %s, retry once it has settled", c.ShortName(), s)
+		}
+		if c.Isolation() == IsolationVM && s != EXITED && s != CHECKPOINTED {
+			manager.containersLock.Unlock()
+			return fmt.Errorf("container %s is run in a VM, which would be lost along with kurma", c.ShortName())
+		}
+		containers = append(containers, c)
+	}
+	manager.handingOff = true
+	manager.containersLock.Unlock()
+
+	for _, c := range containers {
+		if err := c.handoff(); err != nil {
+			manager.CancelHandoff()
+			return fmt.Errorf("failed to hand off container %s: %v", c.ShortName(), err)
+		}
+	}
+	return nil
+}
+
+// CancelHandoff resumes managing the containers when the newer kurma couldn't
+// be exec'd, closing the files which were left open for it on exec again.
+func (manager *Manager) CancelHandoff() {
+	manager.containersLock.Lock()
+	manager.handingOff = false
+	containers := make([]*Container, 0, len(manager.containers))
+	for _, c := range manager.containers {
+		containers = append(containers, c)
+	}
+	manager.containersLock.Unlock()
+
+	for _, c := range containers {
+		c.cancelHandoff()
+	}
+}
+
+// handoff leaves the files capturing the container's output open on exec, and
+// saves its state along with their descriptors.
+func (c *Container) handoff() error {
+	c.mutex.Lock()
+	var files []*os.File
+	if c.logCapture != nil {
+		files = c.logCapture.files()
+	}
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		fd := int(f.Fd())
+		if fd < 0 {
+			continue
+		}
+		if err := setCloseOnExec(fd, false); err != nil {
+			c.mutex.Unlock()
+			for _, fd := range fds {
+				setCloseOnExec(fd, true)
+			}
+			return err
+		}
+		fds = append(fds, fd)
+	}
+	c.handoffFiles = fds
+	c.mutex.Unlock()
+
+	c.saveState()
+	return nil
+}
+
+// cancelHandoff closes the files left open for an upgraded kurma on exec again.
+func (c *Container) cancelHandoff() {
+	c.mutex.Lock()
+	fds := c.handoffFiles
+	c.handoffFiles = nil
+	c.mutex.Unlock()
+
+	if len(fds) == 0 {
+		return
+	}
+	for _, fd := range fds {
+		setCloseOnExec(fd, true)
+	}
+	c.saveState()
+}
+
+// closeInheritedFiles closes the files the container's output was captured
+// through by the kurma it was handed off from, once they have been opened
+// again. Only descriptors which are still the container's FIFOs are closed,
+// since the others may have been reused.
+func (c *Container) closeInheritedFiles(fds []int) {
+	fifos := make(map[uint64]bool)
+	for _, path := range []string{c.appStdoutPath(), c.appStderrPath(), c.appStdinPath()} {
+		var st syscall.Stat_t
+		if err := syscall.Stat(path, &st); err == nil && st.Mode&syscall.S_IFMT == syscall.S_IFIFO {
+			fifos[st.Ino] = true
+		}
+	}
+	for _, fd := range fds {
+		var st syscall.Stat_t
+		if err := syscall.Fstat(fd, &st); err != nil {
+			continue
+		}
+		if st.Mode&syscall.S_IFMT == syscall.S_IFIFO && fifos[st.Ino] {
+			syscall.Close(fd)
+		}
+	}
+}
+
+// files returns the capture's ends of the FIFOs which must stay open for the
+// apps to keep writing their output and reading their stdin.
+func (l *logCapture) files() []*os.File {
+	files := append([]*os.File(nil), l.readers...)
+	l.stdinMutex.Lock()
+	defer l.stdinMutex.Unlock()
+	if l.stdin != nil {
+		files = append(files, l.stdin)
+	}
+	if l.stdinReader != nil {
+		files = append(files, l.stdinReader)
+	}
+	return files
+}
+
+// setCloseOnExec sets or clears whether the descriptor is closed on exec.
+func setCloseOnExec(fd int, closeOnExec bool) error {
+	var flag uintptr
+	if closeOnExec {
+		flag = syscall.FD_CLOEXEC
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, flag); errno != 0 {
+		return errno
+	}
+	return nil
+}
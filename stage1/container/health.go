@@ -0,0 +1,347 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/util/ns"
+)
+
+// HealthStatus is the result of a container's health checks.
+type HealthStatus string
+
+const (
+	// HealthNone is the status of containers without a health check.
+	HealthNone = HealthStatus("")
+
+	// HealthStarting is the status of a container whose health check has
+	// neither passed nor failed enough times since its apps were started.
+	HealthStarting = HealthStatus("starting")
+
+	// HealthHealthy is the status of a container whose last health check
+	// passed.
+	HealthHealthy = HealthStatus("healthy")
+
+	// HealthUnhealthy is the status of a container whose health check has
+	// failed its threshold of times in a row.
+	HealthUnhealthy = HealthStatus("unhealthy")
+)
+
+const (
+	// DefaultHealthInterval is how often a container's health is checked when
+	// its health check doesn't specify an interval.
+	DefaultHealthInterval = 30 * time.Second
+
+	// DefaultHealthTimeout is how long a health check is given to pass when it
+	// doesn't specify a timeout.
+	DefaultHealthTimeout = 10 * time.Second
+
+	// DefaultHealthThreshold is how many times in a row a health check must
+	// fail for the container to be unhealthy when it doesn't specify a
+	// threshold.
+	DefaultHealthThreshold = 3
+)
+
+// HealthCheck is a liveness probe for a container, which runs a command within
+// it, connects to one of its TCP ports, or requests a path from an HTTP server
+// on one of its ports. An HTTP check passes when the response has a 2xx or 3xx
+// status.
+type HealthCheck struct {
+	// Exec is the command run within the container, which passes when it
+	// exits successfully.
+	Exec []string `json:"exec,omitempty"`
+
+	// TCPPort is the container port which must accept connections.
+	TCPPort int `json:"tcp_port,omitempty"`
+
+	// HTTPPort and HTTPPath are where the HTTP check's request is sent. The
+	// path defaults to "/".
+	HTTPPort int    `json:"http_port,omitempty"`
+	HTTPPath string `json:"http_path,omitempty"`
+
+	// Interval is how often the check is run, Timeout how long it is given to
+	// pass, and Threshold how many times it must fail in a row for the
+	// container to be unhealthy. The defaults are used for those left as 0.
+	Interval  time.Duration `json:"interval,omitempty"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
+	Threshold int           `json:"threshold,omitempty"`
+}
+
+// validateHealthCheck ensures the health check specifies exactly one probe and
+// valid settings for it.
+func validateHealthCheck(h *HealthCheck) error {
+	if h == nil {
+		return nil
+	}
+	probes := 0
+	if len(h.Exec) > 0 {
+		probes++
+	}
+	if h.TCPPort != 0 {
+		probes++
+	}
+	if h.HTTPPort != 0 {
+		probes++
+	}
+	if probes != 1 {
+		return fmt.Errorf("the health check must specify exactly one of a command, a tcp port, or an http port")
+	}
+	for _, port := range []int{h.TCPPort, h.HTTPPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid health check port %d", port)
+		}
+	}
+	if h.HTTPPath != "" && !strings.HasPrefix(h.HTTPPath, "/") {
+		return fmt.Errorf("the health check's http path must begin with a /")
+	}
+	if h.Interval < 0 || h.Timeout < 0 || h.Threshold < 0 {
+		return fmt.Errorf("the health check's interval, timeout, and threshold must not be negative")
+	}
+	return nil
+}
+
+func (h *HealthCheck) interval() time.Duration {
+	if h.Interval <= 0 {
+		return DefaultHealthInterval
+	}
+	return h.Interval
+}
+
+func (h *HealthCheck) timeout() time.Duration {
+	if h.Timeout <= 0 {
+		return DefaultHealthTimeout
+	}
+	return h.Timeout
+}
+
+func (h *HealthCheck) threshold() int {
+	if h.Threshold <= 0 {
+		return DefaultHealthThreshold
+	}
+	return h.Threshold
+}
+
+// Health returns the status of the container's health checks, which is
+// HealthNone if it has none.
+func (c *Container) Health() HealthStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.health
+}
+
+// watchHealth begins running the container's health check every interval, if
+// it has one, until it is stopped. It does nothing if the check is already
+// running.
+func (c *Container) watchHealth() {
+	if c.healthCheck == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	if c.stopHealthWatch != nil {
+		c.mutex.Unlock()
+		return
+	}
+	stop := make(chan bool)
+	c.stopHealthWatch = func() { close(stop) }
+	c.health = HealthStarting
+	c.healthFailures = 0
+	c.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(c.healthCheck.interval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.checkHealth()
+			}
+		}
+	}()
+}
+
+// stopWatchingHealth stops running the container's health check.
+func (c *Container) stopWatchingHealth() {
+	c.mutex.Lock()
+	stop := c.stopHealthWatch
+	c.stopHealthWatch = nil
+	c.mutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// checkHealth runs the health check while the container's apps are running,
+// and updates its status. An event is published when the container becomes
+// healthy or unhealthy. Once it is unhealthy, the apps are restarted if the
+// container's restart policy calls for it.
+func (c *Container) checkHealth() {
+	c.mutex.Lock()
+	running := c.state == RUNNING && !c.stopping
+	c.mutex.Unlock()
+	if !running {
+		return
+	}
+
+	err := c.probe()
+
+	c.mutex.Lock()
+	previous := c.health
+	if err == nil {
+		c.health = HealthHealthy
+		c.healthFailures = 0
+	} else {
+		c.healthFailures++
+		if c.healthFailures >= c.healthCheck.threshold() {
+			c.health = HealthUnhealthy
+		}
+	}
+	status := c.health
+	restart := status == HealthUnhealthy && previous != HealthUnhealthy && c.shouldRestartAfterFailure()
+	if restart {
+		for _, a := range c.apps {
+			a.restarts++
+		}
+		c.health = HealthStarting
+		c.healthFailures = 0
+	}
+	c.mutex.Unlock()
+
+	if err != nil {
+		c.log.Debugf("Health check failed: %v", err)
+	}
+	if status != previous {
+		switch status {
+		case HealthHealthy:
+			c.log.Infof("The container is healthy")
+			c.publish(&Event{Type: EventHealthy})
+		case HealthUnhealthy:
+			c.log.Warnf("The container is unhealthy: %v", err)
+			c.publish(&Event{Type: EventUnhealthy})
+		}
+	}
+	if !restart {
+		return
+	}
+
+	c.log.Infof("Restarting apps after failing health checks")
+	if err := c.RestartApps(c.StopGracePeriod()); err != nil {
+		c.log.Errorf("Failed to restart the apps after failing health checks: %v", err)
+	}
+}
+
+// probe runs the health check once, returning why it failed.
+func (c *Container) probe() error {
+	h := c.healthCheck
+	switch {
+	case len(h.Exec) > 0:
+		return c.probeExec(h)
+
+	case h.TCPPort != 0:
+		conn, err := c.dialProbe(h.TCPPort, h.timeout())
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+
+	default:
+		path := h.HTTPPath
+		if path == "" {
+			path = "/"
+		}
+		client := &http.Client{
+			Timeout: h.timeout(),
+			Transport: &http.Transport{
+				Dial: func(network, addr string) (net.Conn, error) {
+					return c.dialProbe(h.HTTPPort, h.timeout())
+				},
+				DisableKeepAlives: true,
+			},
+		}
+		resp, err := client.Get("http://" + c.probeAddress(h.HTTPPort) + path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", path, resp.Status)
+		}
+		return nil
+	}
+}
+
+// probeExec runs the health check's command within the container. A command
+// which exceeds the timeout is left to finish, and no other is started until
+// it does.
+func (c *Container) probeExec(h *HealthCheck) error {
+	c.mutex.Lock()
+	if c.healthProbing {
+		c.mutex.Unlock()
+		return fmt.Errorf("the previous check has not finished")
+	}
+	c.healthProbing = true
+	c.mutex.Unlock()
+
+	result := make(chan error, 1)
+	go func() {
+		code, err := c.Exec(h.Exec, nil, nil, nil)
+		c.mutex.Lock()
+		c.healthProbing = false
+		c.mutex.Unlock()
+		if err == nil && code != 0 {
+			err = fmt.Errorf("%s exited with %d", h.Exec[0], code)
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(h.timeout()):
+		return fmt.Errorf("%s did not finish within %s", h.Exec[0], h.timeout())
+	}
+}
+
+// dialProbe connects to the container's port for a health check. Containers on
+// the LAN can't be reached from the host, so they are connected to from within
+// their network namespace, on its loopback interface.
+func (c *Container) dialProbe(port int, timeout time.Duration) (net.Conn, error) {
+	c.mutex.Lock()
+	endpoint := c.endpoint
+	c.mutex.Unlock()
+	if endpoint == nil || c.manager.network.Bridged() {
+		return net.DialTimeout("tcp", c.probeAddress(port), timeout)
+	}
+
+	var conn net.Conn
+	err := ns.Run(c.Pid(), []ns.Namespace{ns.Net}, func() error {
+		var err error
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), timeout)
+		return err
+	})
+	return conn, err
+}
+
+// probeAddress returns the address of the container port, which is on its
+// address on the container network if it has one, and otherwise on the host's
+// loopback interface.
+func (c *Container) probeAddress(port int) string {
+	c.mutex.Lock()
+	endpoint := c.endpoint
+	c.mutex.Unlock()
+
+	host := "127.0.0.1"
+	if endpoint != nil && endpoint.Address != nil {
+		host = endpoint.Address.IP.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage3/client"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/apcera/kurma/util/hugepages"
+)
+
+// hugepagesPath is where the hugetlbfs of the host's default page size is
+// mounted within a container, with those of other sizes beside it.
+const hugepagesPath = "/dev/hugepages"
+
+// hugepageLimits returns the most bytes of hugepages of each page size the
+// container may use, from the apps' hugepages isolator, if any.
+func (c *Container) hugepageLimits() map[int64]int64 {
+	if iso := c.isolator(kschema.HugepagesName); iso != nil {
+		if hiso, ok := iso.Value().(*kschema.Hugepages); ok {
+			return hiso.Limits()
+		}
+	}
+	return nil
+}
+
+// validateHugepages checks that the host can give the container the hugepages
+// it asks for. They're limited by the hugetlb cgroup controller, and mounted
+// with filesystems which can't be mounted within a user namespace.
+func (c *Container) validateHugepages() error {
+	limits := c.hugepageLimits()
+	if len(limits) == 0 {
+		return nil
+	}
+	if !cgroups.HasController("hugetlb") {
+		return fmt.Errorf("hugepages require the hugetlb cgroup controller, which the host doesn't have")
+	}
+	if c.usesUserNamespace() {
+		return fmt.Errorf("containers with their own user namespace can't be given hugepages")
+	}
+	for size := range limits {
+		if !hugepages.Supported(size) {
+			return fmt.Errorf("the host doesn't support %s hugepages", hugepages.SizeName(size))
+		}
+	}
+	return nil
+}
+
+// limitHugepages applies the container's hugepage limits to its cgroup.
+func (c *Container) limitHugepages() error {
+	for size, limit := range c.hugepageLimits() {
+		if err := c.cgroup.LimitHugepages(size, limit); err != nil {
+			return fmt.Errorf("failed to limit the container's %s hugepages: %v", hugepages.SizeName(size), err)
+		}
+	}
+	return nil
+}
+
+// addHugepageMounts configures the spec to mount a hugetlbfs of each page size
+// the container is given, for the apps to map the pages from.
+func (c *Container) addHugepageMounts(spec *LaunchSpec) error {
+	limits := c.hugepageLimits()
+	if len(limits) == 0 {
+		return nil
+	}
+	defaultSize, err := hugepages.DefaultSize()
+	if err != nil {
+		return err
+	}
+	sizes := make([]int64, 0, len(limits))
+	for size := range limits {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	for _, size := range sizes {
+		path := hugepages.MountPath(hugepagesPath, size, defaultSize)
+		podPath, err := c.ensureContainerPathExists(path)
+		if err != nil {
+			return err
+		}
+		spec.MountPoints = append(spec.MountPoints, &client.MountPoint{
+			Source:      "hugetlbfs",
+			Destination: strings.Replace(podPath, c.stage3Path(), client.DefaultChrootPath, 1),
+			FSType:      "hugetlbfs",
+			Flags:       syscall.MS_NOSUID | syscall.MS_NODEV,
+			Data:        fmt.Sprintf("pagesize=%d,size=%d,mode=1777", size, limits[size]),
+		})
+		c.addMount("hugetlbfs", path, false)
+	}
+	return nil
+}
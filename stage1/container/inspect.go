@@ -0,0 +1,212 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mount describes a path on the host which is bind mounted into the container.
+type Mount struct {
+	Source      string
+	Destination string
+	ReadOnly    bool
+}
+
+// StartTime returns when the container's apps were last started. It is the
+// zero time if they have not been started yet.
+func (c *Container) StartTime() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.startTime
+}
+
+// ExitTime returns when all of the container's apps last exited. It is only
+// meaningful while the container is in the EXITED state.
+func (c *Container) ExitTime() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.exitTime
+}
+
+// Pid returns the pid on the host of the container's init process, or 0 if it
+// isn't known.
+func (c *Container) Pid() int {
+	initdClient := c.getInitdClient()
+	if initdClient == nil {
+		return 0
+	}
+	return initdClient.Pid()
+}
+
+// ExitCode returns the first non-zero exit code of the container's apps, or 0
+// if they all exited successfully.
+func (c *Container) ExitCode() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, a := range c.apps {
+		if a.exitCode != 0 {
+			return a.exitCode
+		}
+	}
+	return 0
+}
+
+// ImageSize returns the size in bytes of the image file the container's
+// filesystem was extracted from.
+func (c *Container) ImageSize() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.imageSize
+}
+
+// CgroupPaths returns the paths to the directories of the container's cgroup.
+func (c *Container) CgroupPaths() []string {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+
+	if cgroup == nil {
+		return nil
+	}
+	files := cgroup.TasksFiles()
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = filepath.Dir(f)
+	}
+	return paths
+}
+
+// Mounts returns the host paths which are bind mounted into the container.
+func (c *Container) Mounts() []Mount {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	mounts := make([]Mount, len(c.mounts))
+	for i, m := range c.mounts {
+		mounts[i] = *m
+	}
+	return mounts
+}
+
+// NetworkAddresses returns the IP addresses assigned to the interfaces within
+// the container's network namespace.
+func (c *Container) NetworkAddresses() ([]string, error) {
+	pid := c.Pid()
+	if pid == 0 {
+		return nil, nil
+	}
+
+	ipv4, err := localIPv4Addresses(fmt.Sprintf("/proc/%d/net/fib_trie", pid))
+	if err != nil {
+		return nil, err
+	}
+	ipv6, err := localIPv6Addresses(fmt.Sprintf("/proc/%d/net/if_inet6", pid))
+	if err != nil {
+		return nil, err
+	}
+	return append(ipv4, ipv6...), nil
+}
+
+// IPAddresses returns the addresses the container was given on the container
+// network, or none if it isn't connected to it.
+func (c *Container) IPAddresses() []net.IP {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.endpoint == nil {
+		return nil
+	}
+	ips := []net.IP{c.endpoint.Address.IP}
+	if c.endpoint.Address6 != nil {
+		ips = append(ips, c.endpoint.Address6.IP)
+	}
+	return ips
+}
+
+// addMount records a path which was bind mounted into the container.
+func (c *Container) addMount(source, destination string, readOnly bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.mounts = append(c.mounts, &Mount{
+		Source:      source,
+		Destination: destination,
+		ReadOnly:    readOnly,
+	})
+}
+
+// localIPv4Addresses parses the routing table in the fib_trie file to find the
+// addresses local to the network namespace. Each is listed as a leaf whose
+// route is marked as "/32 host LOCAL".
+func localIPv4Addresses(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var addresses []string
+	var leaf string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "|-- "):
+			leaf = strings.TrimPrefix(line, "|-- ")
+		case strings.HasPrefix(line, "/32 host LOCAL") && leaf != "":
+			if !seen[leaf] {
+				seen[leaf] = true
+				addresses = append(addresses, leaf)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// localIPv6Addresses parses the if_inet6 file for the IPv6 addresses assigned
+// to the interfaces in the network namespace. Each line begins with the
+// address as 32 hex digits.
+func localIPv6Addresses(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var addresses []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		b, err := hex.DecodeString(fields[0])
+		if err != nil || len(b) != net.IPv6len {
+			return nil, fmt.Errorf("invalid address in %s: %q", path, fields[0])
+		}
+		addresses = append(addresses, net.IP(b).String())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
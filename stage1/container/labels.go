@@ -0,0 +1,44 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// maxLabelKeyLength and maxLabelValueLength limit the size of the labels
+	// a container may be given.
+	maxLabelKeyLength   = 128
+	maxLabelValueLength = 1024
+)
+
+var labelKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_./-]*$`)
+
+// validateLabels checks that the labels' keys are well formed and that neither
+// their keys nor values are too long.
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if len(key) > maxLabelKeyLength {
+			return fmt.Errorf("the label key %q is longer than %d characters", key, maxLabelKeyLength)
+		}
+		if !labelKeyRegexp.MatchString(key) {
+			return fmt.Errorf("the label key %q must start with a letter or digit and contain only letters, digits, '_', '.', '/', and '-'", key)
+		}
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("the value of label %s is longer than %d characters", key, maxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// Labels returns a copy of the key=value pairs the container was labelled with
+// when it was created.
+func (container *Container) Labels() map[string]string {
+	labels := make(map[string]string, len(container.labels))
+	for key, value := range container.labels {
+		labels[key] = value
+	}
+	return labels
+}
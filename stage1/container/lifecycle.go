@@ -0,0 +1,202 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultStopGracePeriod is how long apps are given to exit after being
+	// sent SIGTERM before they are killed.
+	DefaultStopGracePeriod = 10 * time.Second
+
+	// How long to wait for apps to exit once they have been sent SIGKILL.
+	stopKillTimeout = 10 * time.Second
+
+	// How often the status of the apps is checked while waiting for them to
+	// exit.
+	stopPollInterval = 100 * time.Millisecond
+)
+
+// StopApps stops the apps running within the container while leaving the
+// container itself in place, so they can later be started again. Each app is
+// sent SIGTERM and is killed if it has not exited after the grace period.
+func (c *Container) StopApps(gracePeriod time.Duration) error {
+	if err := c.stopApps(gracePeriod); err != nil {
+		return err
+	}
+	c.saveState()
+	c.publish(&Event{Type: EventStopped, ExitCode: c.ExitCode()})
+	return nil
+}
+
+// stopApps stops the apps as StopApps does, without publishing that they were
+// stopped, for when they're about to be started again.
+func (c *Container) stopApps(gracePeriod time.Duration) error {
+	initdClient := c.getInitdClient()
+
+	c.mutex.Lock()
+	if c.shuttingDown || initdClient == nil || (c.state != RUNNING && c.state != RESTARTING) {
+		c.mutex.Unlock()
+		return fmt.Errorf("the container is not running")
+	}
+	c.state = STOPPING
+	c.stopping = true
+	waitLoopch := c.waitLoopch
+
+	// Apps waiting to be restarted will have their restart abandoned, so only
+	// the running ones need to be signaled.
+	var stopped []*app
+	var names []string
+	for _, a := range c.apps {
+		switch a.state {
+		case RUNNING:
+			names = append(names, a.processName())
+			fallthrough
+		case RESTARTING:
+			stopped = append(stopped, a)
+		}
+	}
+	c.mutex.Unlock()
+
+	c.log.Debugf("Stopping apps with a grace period of %s", gracePeriod)
+	if !c.signalApps(names, syscall.SIGTERM, gracePeriod) {
+		c.log.Warnf("Apps did not exit within %s, killing them", gracePeriod)
+		if !c.signalApps(names, syscall.SIGKILL, stopKillTimeout) {
+			return fmt.Errorf("the apps did not exit after being killed")
+		}
+	}
+
+	// Wake the wait loop in case it is waiting on a pending restart, then wait
+	// for it to be done so that a new one can be started with the apps.
+	select {
+	case c.restartch <- true:
+	default:
+	}
+	if waitLoopch != nil {
+		<-waitLoopch
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, a := range stopped {
+		a.state = STOPPED
+	}
+	c.state = STOPPED
+	return nil
+}
+
+// StartApps starts the apps within a container which was stopped, or whose apps
+// have all exited.
+func (c *Container) StartApps() error {
+	initdClient := c.getInitdClient()
+
+	c.mutex.Lock()
+	if c.shuttingDown || initdClient == nil || (c.state != STOPPED && c.state != EXITED) {
+		c.mutex.Unlock()
+		return fmt.Errorf("the container must be stopped or exited to be started")
+	}
+
+	// The container will be able to exit again, so anything waiting on it needs
+	// a new channel if it already exited.
+	select {
+	case <-c.waitch:
+		c.waitch = make(chan bool)
+	default:
+	}
+	c.state = STARTING
+	c.stopping = false
+	c.startTime = time.Now()
+	c.startOOMKills = c.oomKills
+	for _, a := range c.apps {
+		a.runs++
+		a.exitCode = 0
+	}
+	c.mutex.Unlock()
+
+	c.log.Debug("Starting apps")
+	var err error
+	for _, a := range c.apps {
+		if serr := c.startAppProcess(a); serr != nil {
+			c.log.Errorf("failed to start app %s: %v", a.name, serr)
+			c.mutex.Lock()
+			a.state = EXITED
+			c.mutex.Unlock()
+			if err == nil {
+				err = serr
+			}
+		}
+	}
+
+	// The wait loop will mark the container as exited if none of the apps
+	// could be started.
+	c.mutex.Lock()
+	c.state = RUNNING
+	c.mutex.Unlock()
+	c.saveState()
+	c.publish(&Event{Type: EventStarted})
+	c.startWaitLoop()
+	return err
+}
+
+// RestartApps stops the apps within the container, if they're running, and
+// then starts them again.
+func (c *Container) RestartApps(gracePeriod time.Duration) error {
+	switch c.State() {
+	case RUNNING, RESTARTING:
+		if err := c.stopApps(gracePeriod); err != nil {
+			return err
+		}
+	}
+	return c.StartApps()
+}
+
+// isStopping returns whether the container's apps are being, or have been,
+// stopped.
+func (c *Container) isStopping() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stopping
+}
+
+// signalApps sends the signal to each of the named processes and waits up to
+// the timeout for them to exit. It returns whether they all exited.
+func (c *Container) signalApps(names []string, signal syscall.Signal, timeout time.Duration) bool {
+	initdClient := c.getInitdClient()
+	if initdClient == nil {
+		return true
+	}
+
+	for _, name := range names {
+		if err := initdClient.Signal(name, signal, time.Second); err != nil {
+			c.log.Warnf("Failed to signal %s: %v", name, err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		statuses, err := initdClient.Status(time.Second)
+		if err != nil {
+			c.log.Warnf("Failed to get the status of the apps: %v", err)
+		} else {
+			running := false
+			for _, name := range names {
+				if statuses[name] == "running" {
+					running = true
+					break
+				}
+			}
+			if !running {
+				return true
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/apcera/logray"
+
+	// registers the syslog output used by the syslog log driver
+	_ "github.com/apcera/kurma/util/logsink"
+)
+
+// The drivers a container's output may be captured by.
+const (
+	LogDriverJSONFile = "json-file"
+	LogDriverSyslog   = "syslog"
+	LogDriverNone     = "none"
+)
+
+const (
+	// DefaultLogMaxSize and DefaultLogMaxFiles limit the json-file driver's
+	// files when neither the host nor the container configure them.
+	DefaultLogMaxSize  = 10 << 20
+	DefaultLogMaxFiles = 3
+
+	// maxLogLineSize is the longest line passed to a log driver. Longer lines
+	// are split.
+	maxLogLineSize = 16 << 10
+)
+
+// LogConfig selects the driver which captures a container's output. The
+// json-file driver records each line in the container's directory, where it
+// can be read back, and starts a new file once the current one would exceed
+// MaxSize bytes, keeping at most MaxFiles files. The syslog driver sends each
+// line to the syslog server at the "address" option, and also takes the
+// "protocol", "facility", and "tag" options. The none driver discards the
+// output.
+type LogConfig struct {
+	Driver   string            `json:"driver,omitempty"`
+	MaxSize  int64             `json:"max_size,omitempty"`
+	MaxFiles int               `json:"max_files,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+// logDriverOptions lists the options each driver takes.
+var logDriverOptions = map[string][]string{
+	LogDriverJSONFile: nil,
+	LogDriverSyslog:   []string{"address", "protocol", "facility", "tag"},
+	LogDriverNone:     nil,
+}
+
+// withDefaults fills in what the configuration leaves unset from the defaults.
+// The default options are only used when the driver is the same, and are
+// overridden by the configuration's own.
+func (l LogConfig) withDefaults(defaults LogConfig) LogConfig {
+	if l.Driver == "" {
+		l.Driver = defaults.Driver
+	}
+	if l.Driver == "" {
+		l.Driver = LogDriverJSONFile
+	}
+	if l.Driver == defaults.Driver && len(defaults.Options) > 0 {
+		options := make(map[string]string, len(defaults.Options)+len(l.Options))
+		for k, v := range defaults.Options {
+			options[k] = v
+		}
+		for k, v := range l.Options {
+			options[k] = v
+		}
+		l.Options = options
+	}
+	if l.MaxSize == 0 {
+		l.MaxSize = defaults.MaxSize
+	}
+	if l.MaxSize == 0 {
+		l.MaxSize = DefaultLogMaxSize
+	}
+	if l.MaxFiles == 0 {
+		l.MaxFiles = defaults.MaxFiles
+	}
+	if l.MaxFiles == 0 {
+		l.MaxFiles = DefaultLogMaxFiles
+	}
+	return l
+}
+
+// validateLogConfig checks that the driver is known and is only given the
+// options it takes.
+func validateLogConfig(l LogConfig) error {
+	accepted, ok := logDriverOptions[l.Driver]
+	if !ok {
+		return fmt.Errorf("unknown log driver %q", l.Driver)
+	}
+	if l.MaxSize < 0 || l.MaxFiles < 0 {
+		return fmt.Errorf("the log's max size and max files must not be negative")
+	}
+	for name := range l.Options {
+		found := false
+		for _, a := range accepted {
+			found = found || a == name
+		}
+		if !found {
+			return fmt.Errorf("the %s log driver doesn't take the %q option", l.Driver, name)
+		}
+	}
+	if l.Driver == LogDriverSyslog {
+		if l.Options["address"] == "" {
+			return fmt.Errorf("the syslog log driver requires an address")
+		}
+		// the output is cached, so it is reused by the container's driver
+		log := logray.New()
+		log.ResetOutput()
+		if err := log.AddOutput(syslogLogURL(l.Options), logray.ALL); err != nil {
+			return fmt.Errorf("invalid syslog log driver options: %v", err)
+		}
+	}
+	return nil
+}
+
+// logDriver records the lines the container's apps write.
+type logDriver interface {
+	// Log records the line, without its newline, which was written to the
+	// stream, either "stdout" or "stderr", at the time.
+	Log(stream string, line []byte, t time.Time) error
+
+	Close() error
+}
+
+// newLogDriver creates the driver the container's configuration selects.
+func (c *Container) newLogDriver() (logDriver, error) {
+	switch c.logConfig.Driver {
+	case LogDriverJSONFile:
+		return newJSONFileLog(c.logPath(), c.logConfig.MaxSize, c.logConfig.MaxFiles)
+	case LogDriverSyslog:
+		return newSyslogLog(c.uuid, c.logConfig.Options)
+	case LogDriverNone:
+		return discardLog{}, nil
+	}
+	return nil, fmt.Errorf("unknown log driver %q", c.logConfig.Driver)
+}
+
+// LogEntry is a line of a container's output, as recorded by the json-file
+// driver. The Log includes the line's newline.
+type LogEntry struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// jsonFileLog records each line as a LogEntry in a file, renaming the file with
+// a numbered suffix once it is full and removing the oldest beyond maxFiles.
+type jsonFileLog struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+func newJSONFileLog(path string, maxSize int64, maxFiles int) (*jsonFileLog, error) {
+	l := &jsonFileLog{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current file, appending to it if it exists.
+func (l *jsonFileLog) open() error {
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	f, err := os.OpenFile(l.path, flags, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, fi.Size()
+	return nil
+}
+
+func (l *jsonFileLog) Log(stream string, line []byte, t time.Time) error {
+	b, err := json.Marshal(&LogEntry{Log: string(line) + "\n", Stream: stream, Time: t.UTC()})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if l.file != nil && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		l.file.Close()
+		l.file = nil
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	// a file which failed to be opened is retried with the next line
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// rotate shifts each of the files to the next suffix, leaving the current file
+// to be recreated. With only one file, the current file is removed.
+func (l *jsonFileLog) rotate() error {
+	if l.maxFiles <= 1 {
+		return os.Remove(l.path)
+	}
+	for i := l.maxFiles - 1; i > 0; i-- {
+		src := l.path
+		if i > 1 {
+			src = rotatedLogPath(l.path, i-1)
+		}
+		if err := os.Rename(src, rotatedLogPath(l.path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *jsonFileLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// rotatedLogPath returns the path of the log file which was rotated n times.
+func rotatedLogPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// syslogLog sends each line to a syslog server, with the container and stream
+// as its fields. Lines written to stderr are sent as errors.
+type syslogLog struct {
+	stdout *logray.Logger
+	stderr *logray.Logger
+}
+
+func newSyslogLog(uuid string, options map[string]string) (*syslogLog, error) {
+	log := logray.New()
+	log.ResetOutput()
+	if err := log.AddOutput(syslogLogURL(options), logray.ALL); err != nil {
+		return nil, err
+	}
+	log.SetField("container", uuid)
+
+	l := &syslogLog{stdout: log.Clone(), stderr: log.Clone()}
+	l.stdout.SetField("stream", "stdout")
+	l.stderr.SetField("stream", "stderr")
+	return l, nil
+}
+
+// syslogLogURL returns the URL of the log sinks' syslog output for the syslog
+// driver's options.
+func syslogLogURL(options map[string]string) string {
+	values := url.Values{}
+	for _, name := range []string{"protocol", "facility", "tag"} {
+		if v := options[name]; v != "" {
+			values.Set(name, v)
+		}
+	}
+	u := &url.URL{Scheme: "syslog", Host: options["address"], RawQuery: values.Encode()}
+	return u.String()
+}
+
+func (l *syslogLog) Log(stream string, line []byte, t time.Time) error {
+	if stream == "stderr" {
+		l.stderr.Error(string(line))
+	} else {
+		l.stdout.Info(string(line))
+	}
+	return nil
+}
+
+// Close leaves the output open, since it is shared by the containers sending
+// to the same server.
+func (l *syslogLog) Close() error {
+	return nil
+}
+
+// discardLog is the none driver, which drops the output.
+type discardLog struct{}
+
+func (discardLog) Log(stream string, line []byte, t time.Time) error { return nil }
+func (discardLog) Close() error                                      { return nil }
@@ -0,0 +1,422 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// logCapture reads what the apps write to the FIFOs at the container's stdout
+// and stderr paths and passes each line to the log driver. It holds a write end
+// of each FIFO open, so the capture continues as the apps are restarted, until
+// it is stopped. The output is also copied to the clients attached through the
+// tap.
+//
+// When the container is created with its stdin open, the capture also holds
+// both ends of the FIFO at the first app's stdin path. Input from attached
+// clients is written to it, and the app reads EOF once the write end is closed.
+type logCapture struct {
+	driver  logDriver
+	tap     *outputTap
+	readers []*os.File
+	writers []*os.File
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+
+	// copying counts the reads from the FIFOs which are still being passed on.
+	copying int32
+
+	stdin       *os.File
+	stdinReader *os.File
+	stdinMutex  sync.Mutex
+}
+
+// startingLogs creates the FIFOs the apps' output is written to and begins
+// capturing it with the container's log driver. The FIFOs are within the
+// container's root filesystem, so they're opened by the initd when it starts
+// each app.
+func (c *Container) startingLogs() error {
+	driver, err := c.newLogDriver()
+	if err != nil {
+		return err
+	}
+	capture := &logCapture{driver: driver, tap: c.outputTap()}
+	defer func() {
+		go func() {
+			capture.wg.Wait()
+			driver.Close()
+		}()
+	}()
+
+	streams := []struct{ name, path string }{
+		{"stdout", c.appStdoutPath()},
+		{"stderr", c.appStderrPath()},
+	}
+	for _, s := range streams {
+		r, w, err := openFIFO(s.path)
+		if err != nil {
+			capture.stop()
+			return fmt.Errorf("failed to create the app's %s: %v", s.name, err)
+		}
+		capture.writers = append(capture.writers, w)
+		if err := c.chownToRoot(s.path); err != nil {
+			r.Close()
+			capture.stop()
+			return err
+		}
+		capture.readers = append(capture.readers, r)
+		capture.wg.Add(1)
+		go capture.copy(s.name, r)
+	}
+
+	// The read end of the stdin FIFO is held so that it can be opened for
+	// writing without the app, and so the app's stdin can be reopened when
+	// it restarts.
+	if c.stdin {
+		r, w, err := openFIFO(c.appStdinPath())
+		if err != nil {
+			capture.stop()
+			return fmt.Errorf("failed to create the app's stdin: %v", err)
+		}
+		capture.stdinReader, capture.stdin = r, w
+		if err := c.chownToRoot(c.appStdinPath()); err != nil {
+			capture.stop()
+			return err
+		}
+	}
+
+	c.mutex.Lock()
+	c.logCapture = capture
+	c.mutex.Unlock()
+	return nil
+}
+
+// stoppingLogs stops capturing the container's output once the apps have closed
+// the FIFOs.
+func (c *Container) stoppingLogs() error {
+	c.mutex.Lock()
+	capture := c.logCapture
+	c.logCapture = nil
+	c.mutex.Unlock()
+
+	if capture != nil {
+		c.log.Trace("Stopping the log capture.")
+		capture.stop()
+	}
+	return nil
+}
+
+// openFIFO creates the FIFO at the path, replacing any other type of file, and
+// opens it to be read along with a write end which holds it open. The read end
+// is opened without blocking, since there's no writer yet, and is then made to
+// block.
+func openFIFO(path string) (r, w *os.File, err error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeNamedPipe == 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := syscall.Mkfifo(path, 0600); err != nil && err != syscall.EEXIST {
+		return nil, nil, err
+	}
+
+	r, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err = os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	if err := syscall.SetNonblock(int(r.Fd()), false); err != nil {
+		r.Close()
+		w.Close()
+		return nil, nil, err
+	}
+	return r, w, nil
+}
+
+// copy passes the lines read from the FIFO to the driver, and the output to the
+// tap, until every writer has closed it.
+func (l *logCapture) copy(stream string, r *os.File) {
+	defer l.wg.Done()
+	defer r.Close()
+
+	w := l.writer(stream)
+	tap := l.tap.writer(stream)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			atomic.AddInt32(&l.copying, 1)
+			w.Write(buf[:n])
+			tap.Write(buf[:n])
+			atomic.AddInt32(&l.copying, -1)
+		}
+		if err != nil {
+			break
+		}
+	}
+	w.flush()
+}
+
+// drain waits, for up to a second, until nothing is left in the FIFOs and what
+// was read from them has been passed on. The FIFOs must be seen to be idle
+// twice in a row, so a read which was just returned is passed on too.
+func (l *logCapture) drain() {
+	idle := 0
+	for i := 0; i < 100 && idle < 2; i++ {
+		if l.pending() {
+			idle = 0
+		} else {
+			idle++
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pending returns whether any output is still waiting to be read from the FIFOs
+// or passed on.
+func (l *logCapture) pending() bool {
+	if atomic.LoadInt32(&l.copying) > 0 {
+		return true
+	}
+	for _, r := range l.readers {
+		var n int32
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, r.Fd(), syscall.TIOCINQ, uintptr(unsafe.Pointer(&n)))
+		if errno == 0 && n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stdinWriter returns the write end of the app's stdin FIFO, or nil if the
+// container's stdin wasn't kept open or has been closed.
+func (l *logCapture) stdinWriter() *os.File {
+	l.stdinMutex.Lock()
+	defer l.stdinMutex.Unlock()
+	return l.stdin
+}
+
+// closeStdin closes the write end of the app's stdin FIFO, if it is still the
+// given file.
+func (l *logCapture) closeStdin(f *os.File) {
+	l.stdinMutex.Lock()
+	defer l.stdinMutex.Unlock()
+	if l.stdin == f {
+		l.stdin.Close()
+		l.stdin = nil
+	}
+}
+
+// stop closes the capture's write ends of the FIFOs, along with both ends of
+// the stdin FIFO.
+func (l *logCapture) stop() {
+	for _, w := range l.writers {
+		w.Close()
+	}
+
+	l.stdinMutex.Lock()
+	defer l.stdinMutex.Unlock()
+	if l.stdin != nil {
+		l.stdin.Close()
+		l.stdin = nil
+	}
+	if l.stdinReader != nil {
+		l.stdinReader.Close()
+		l.stdinReader = nil
+	}
+}
+
+// writer returns a writer which splits what is written to it into lines for the
+// driver.
+func (l *logCapture) writer(stream string) *logLineWriter {
+	return &logLineWriter{capture: l, stream: stream}
+}
+
+// log passes the line to the driver. Lines which fail to be recorded are
+// dropped.
+func (l *logCapture) log(stream string, line []byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.driver.Log(stream, line, time.Now())
+}
+
+// logLineWriter buffers what is written to it until it has a whole line, or
+// the longest line allowed, to send to the driver. Carriage returns before the
+// newline, as a terminal writes, are dropped.
+type logLineWriter struct {
+	capture *logCapture
+	stream  string
+	partial []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+		line := append(w.partial, p[:i]...)
+		w.capture.log(w.stream, bytes.TrimSuffix(line, []byte("\r")))
+		w.partial = w.partial[:0]
+		p = p[i+1:]
+	}
+	for len(w.partial) >= maxLogLineSize {
+		w.capture.log(w.stream, w.partial[:maxLogLineSize])
+		w.partial = append(w.partial[:0], w.partial[maxLogLineSize:]...)
+	}
+	return n, nil
+}
+
+// flush sends any partial line to the driver.
+func (w *logLineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.capture.log(w.stream, w.partial)
+		w.partial = nil
+	}
+}
+
+// OpenLogs opens the container's output as recorded by the json-file log
+// driver, from the oldest line which is kept. The output captured by the other
+// drivers can't be read back.
+func (c *Container) OpenLogs() (*LogReader, error) {
+	if c.logConfig.Driver != LogDriverJSONFile {
+		return nil, fmt.Errorf("the container's output can't be read with the %s log driver", c.logConfig.Driver)
+	}
+	return openLogReader(c.logPath(), c.logConfig.MaxFiles)
+}
+
+// LogReader reads the entries the json-file log driver records, continuing
+// into the newer files as they're rotated.
+type LogReader struct {
+	path string
+
+	// files are the open files yet to be read, oldest first. The last is the
+	// current file, which is reopened once it is rotated.
+	files   []*os.File
+	reader  *bufio.Reader
+	partial []byte
+}
+
+// openLogReader opens the rotated files and the current file at the path. The
+// files are held open, so they can be read even if they're rotated away.
+func openLogReader(path string, maxFiles int) (*LogReader, error) {
+	r := &LogReader{path: path}
+	for i := maxFiles - 1; i > 0; i-- {
+		f, err := os.Open(rotatedLogPath(path, i))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.files = append(r.files, f)
+		if len(r.files) == 1 {
+			r.reader = bufio.NewReader(f)
+		}
+	}
+	if err := r.openCurrent(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openCurrent adds the current file to those to be read, if it exists.
+func (r *LogReader) openCurrent() error {
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.files = append(r.files, f)
+	if len(r.files) == 1 {
+		r.reader = bufio.NewReader(f)
+	}
+	return nil
+}
+
+// Next returns the next entry. Once every entry recorded so far has been read,
+// it returns io.EOF, and may be called again to read any which are recorded
+// after. Lines which aren't valid entries are skipped.
+func (r *LogReader) Next() (*LogEntry, error) {
+	for {
+		if len(r.files) == 0 {
+			if err := r.openCurrent(); err != nil {
+				return nil, err
+			}
+			if len(r.files) == 0 {
+				return nil, io.EOF
+			}
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		r.partial = append(r.partial, line...)
+		if err == nil {
+			var entry LogEntry
+			jerr := json.Unmarshal(r.partial, &entry)
+			r.partial = r.partial[:0]
+			if jerr != nil {
+				continue
+			}
+			return &entry, nil
+		} else if err != io.EOF {
+			return nil, err
+		}
+
+		// At the end of an older file, move on to the next. The current file
+		// is only left once it has been rotated, and is read again first in
+		// case more was written to it before then.
+		if len(r.files) > 1 {
+			r.files[0].Close()
+			r.files = r.files[1:]
+			r.reader = bufio.NewReader(r.files[0])
+			r.partial = r.partial[:0]
+			continue
+		}
+		if r.rotated() {
+			if err := r.openCurrent(); err != nil {
+				return nil, err
+			}
+			if len(r.files) > 1 {
+				continue
+			}
+		}
+		return nil, io.EOF
+	}
+}
+
+// rotated returns whether the file being read is no longer the current file.
+func (r *LogReader) rotated() bool {
+	fi, err := r.files[0].Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(r.path)
+	return err == nil && !os.SameFile(fi, current)
+}
+
+// Close closes the files which are open.
+func (r *LogReader) Close() error {
+	for _, f := range r.files {
+		f.Close()
+	}
+	r.files = nil
+	return nil
+}
@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/secrets"
+	"github.com/apcera/kurma/stage1/storage"
+	"github.com/apcera/kurma/stage1/vm"
 	"github.com/apcera/kurma/util/cgroups"
+	"github.com/apcera/kurma/util/cpuset"
+	"github.com/apcera/kurma/util/lsm"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
 	"github.com/appc/spec/schema"
@@ -24,6 +32,85 @@ type Options struct {
 	ContainerDirectory string
 	VolumeDirectory    string
 	RequiredNamespaces []string
+
+	// Network, if set, is used to give containers their own network namespace
+	// connected to the host through a bridge.
+	Network *network.Network
+
+	// UserNamespaces, if set, is the range of host IDs containers are mapped
+	// into when they're run in their own user namespace. Volumes remain owned
+	// by the host's root, so are only writable by such containers if their
+	// permissions allow it.
+	UserNamespaces *IDRange
+
+	// SecurityModule is the Linux Security Module enabled on the host, which
+	// confines containers that have a security label. SecurityLabel is the
+	// label used for containers which aren't given one, other than privileged
+	// ones, and may be empty to leave them unconfined.
+	SecurityModule lsm.Module
+	SecurityLabel  string
+
+	// Storage is the driver which provides containers' root filesystems. If it
+	// is nil, each container's image is extracted into its directory.
+	Storage storage.Driver
+
+	// Logging is the log driver configuration containers use for whatever
+	// their own doesn't set. If the driver isn't set, json-file is used.
+	Logging LogConfig
+
+	// Secrets is the store of the secrets containers may reference. Without
+	// one, containers can't be given secrets.
+	Secrets *secrets.Store
+
+	// MetadataURL is the base URL of the App Container metadata service, if
+	// the host runs one. Each container's apps are given their own URL under it
+	// in AC_METADATA_URL.
+	MetadataURL string
+
+	// Nameserver is the address of the DNS server on the container network,
+	// if the host runs one, and NameserverDomain the domain it resolves the
+	// containers under. Containers connected to the network have it as their
+	// only nameserver, while the rest use the host's resolv.conf.
+	Nameserver       string
+	NameserverDomain string
+
+	// RuntimeBundles, if set, has each container's directory written as an OCI
+	// runtime bundle, with a config.json describing how the container is run
+	// alongside its rootfs, for tools which work with OCI bundles.
+	RuntimeBundles bool
+
+	// VM, if set, configures how the VMs of containers created with the
+	// IsolationVM isolation are booted. Without it, containers can't be run in
+	// VMs.
+	VM *vm.Config
+
+	// Runtimes are additional runtimes containers may select, alongside the
+	// native runtime and the VM runtime if VMs are configured.
+	Runtimes []Runtime
+
+	// ReservedCPUs lists the CPUs, such as "0-1", which are never allocated to
+	// a container for its exclusive use, so that kurma and the containers
+	// sharing the host's CPUs always have somewhere to run. If it is empty,
+	// CPU 0 is reserved.
+	ReservedCPUs string
+
+	// OvercommitRatio, if set, has containers reserve the CPU and memory their
+	// apps' resource isolators request, and refuses those which don't fit. The
+	// host's CPUs and memory, less the SystemReserved for kurma and the host's
+	// services, are scaled by the ratio, so that above 1 more may be reserved
+	// than the host has. If it is 0, containers are never refused.
+	OvercommitRatio float64
+	SystemReserved  Resources
+
+	// Eviction, if set, has the manager watch for the host running short of
+	// memory, and throttle and then evict containers, lowest priority first,
+	// while it is.
+	Eviction *EvictionOptions
+
+	// Upgraded is set when the manager's process was exec'd by an upgrade of
+	// an earlier kurma, whose containers are recovered along with the files
+	// it left open for them.
+	Upgraded bool
 }
 
 // Manager handles the management of the containers running and available on the
@@ -33,13 +120,41 @@ type Manager struct {
 
 	containers     map[string]*Container
 	containersLock sync.RWMutex
+	created        uint64
+	shuttingDown   bool
+	handingOff     bool
+	upgraded       bool
 
 	volumeDirectory string
 	volumeLock      sync.Mutex
 
+	// secretLock serializes deleting secrets against creating containers
+	// which use them.
+	secretLock sync.Mutex
+
+	subscribers     map[chan *Event]bool
+	subscribersLock sync.Mutex
+
+	// pinging is set while a Ping is waiting on the manager's locks.
+	pinging int32
+
 	cgroup             *cgroups.Cgroup
 	containerDirectory string
 	requiredNamespaces []string
+	network            *network.Network
+	userNamespaces     *idAllocator
+	cpus               *cpuset.Pool
+	allocatable        *Resources
+	securityModule     lsm.Module
+	securityLabel      string
+	storage            storage.Driver
+	logConfig          LogConfig
+	secrets            *secrets.Store
+	metadataURL        string
+	nameserver         string
+	nameserverDomain   string
+	runtimeBundles     bool
+	runtimes           map[string]Runtime
 }
 
 // NewManager creates a new Manager with the provided options. It will ensure
@@ -60,10 +175,66 @@ func NewManager(opts *Options) (*Manager, error) {
 	m := &Manager{
 		Log:                logray.New(),
 		containers:         make(map[string]*Container),
+		subscribers:        make(map[chan *Event]bool),
 		containerDirectory: opts.ContainerDirectory,
 		volumeDirectory:    opts.VolumeDirectory,
 		cgroup:             cg,
 		requiredNamespaces: opts.RequiredNamespaces,
+		network:            opts.Network,
+		securityModule:     opts.SecurityModule,
+		securityLabel:      opts.SecurityLabel,
+		storage:            opts.Storage,
+		logConfig:          opts.Logging.withDefaults(LogConfig{}),
+		secrets:            opts.Secrets,
+		metadataURL:        strings.TrimSuffix(opts.MetadataURL, "/"),
+		nameserver:         opts.Nameserver,
+		nameserverDomain:   opts.NameserverDomain,
+		runtimeBundles:     opts.RuntimeBundles,
+		runtimes:           make(map[string]Runtime),
+		upgraded:           opts.Upgraded,
+	}
+	if m.storage == nil {
+		m.storage = storage.NewPlain(nil, nil)
+	}
+	if m.securityLabel != "" && m.securityModule == "" {
+		return nil, fmt.Errorf("a default security label requires a security module")
+	}
+	if err := validateLogConfig(m.logConfig); err != nil {
+		return nil, err
+	}
+	if opts.UserNamespaces != nil {
+		if m.userNamespaces, err = newIDAllocator(*opts.UserNamespaces); err != nil {
+			return nil, err
+		}
+	}
+	if m.cpus, err = m.newCPUPool(opts.ReservedCPUs); err != nil {
+		return nil, err
+	}
+	if opts.OvercommitRatio != 0 {
+		allocatable, err := newAllocatable(opts.SystemReserved, opts.OvercommitRatio)
+		if err != nil {
+			return nil, err
+		}
+		m.allocatable = &allocatable
+	}
+
+	runtimes := []Runtime{nativeRuntime{}}
+	if opts.VM != nil {
+		if err := opts.VM.Validate(); err != nil {
+			return nil, err
+		}
+		runtimes = append(runtimes, &vmRuntime{config: opts.VM, volumes: opts.VolumeDirectory != ""})
+	}
+	for _, r := range append(runtimes, opts.Runtimes...) {
+		if err := m.addRuntime(r); err != nil {
+			return nil, err
+		}
+	}
+	if e := opts.Eviction; e != nil {
+		if e.Pressure < 0 || e.MinAvailable < 0 {
+			return nil, fmt.Errorf("the memory pressure and available memory to evict at must not be negative")
+		}
+		go m.watchMemory(*e)
 	}
 	return m, nil
 }
@@ -75,12 +246,72 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 	if imageManifest.App == nil {
 		return fmt.Errorf("the manifest must specify an App")
 	}
-	if len(imageManifest.App.Exec) == 0 {
+	return manager.validateApp(imageManifest.App)
+}
+
+// ValidatePod will ensure that the pod manifest provided is valid to be run on
+// the system using the image. Only the container's image is retrieved, so each
+// of the apps in the pod must reference that image, and either specify an App
+// or use the image's App.
+func (manager *Manager) ValidatePod(imageManifest *schema.ImageManifest, pod *schema.PodManifest) error {
+	if len(pod.Apps) == 0 {
+		return fmt.Errorf("the pod manifest must specify at least one app")
+	}
+	if len(pod.Volumes) > 0 {
+		return fmt.Errorf("pod volumes are not supported, volumes must be mounted by name")
+	}
+
+	names := make(map[types.ACName]bool)
+	consoles := 0
+	for _, ra := range pod.Apps {
+		if ra.Name.Empty() {
+			return fmt.Errorf("the pod's apps must each have a name")
+		}
+		if names[ra.Name] {
+			return fmt.Errorf("the pod has multiple apps named %q", ra.Name)
+		}
+		names[ra.Name] = true
+
+		if len(ra.Mounts) > 0 {
+			return fmt.Errorf("app %q cannot specify mounts, volumes must be mounted by name", ra.Name)
+		}
+		if ra.Image.Name == nil || !ra.Image.Name.Equals(imageManifest.Name) {
+			return fmt.Errorf("app %q must use the container's image %q, pods may not run apps from other images",
+				ra.Name, imageManifest.Name)
+		}
+
+		a := &app{name: ra.Name, app: ra.App}
+		if a.app == nil {
+			a.app = imageManifest.App
+		}
+		if a.app == nil {
+			return fmt.Errorf("app %q must specify an App", ra.Name)
+		}
+		if err := manager.validateApp(a.app); err != nil {
+			return fmt.Errorf("app %q is not valid: %v", ra.Name, err)
+		}
+		if a.hasConsole() {
+			consoles++
+		}
+	}
+	if consoles > 1 {
+		return fmt.Errorf("only one app in the pod may request a console")
+	}
+	return nil
+}
+
+// validateApp ensures the settings for an individual app are valid.
+func (manager *Manager) validateApp(app *types.App) error {
+	if len(app.Exec) == 0 {
 		return fmt.Errorf("the manifest App.Exec must specify a command to run")
 	}
 
+	if err := validateCapabilities(app); err != nil {
+		return err
+	}
+
 	// If the namespaces isolator is specified, validate a minimum set of namespaces
-	if iso := imageManifest.App.Isolators.GetByName(kschema.LinuxNamespacesName); iso != nil {
+	if iso := app.Isolators.GetByName(kschema.LinuxNamespacesName); iso != nil {
 		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok {
 			checks := map[string]func() bool{
 				"ipc":   niso.IPC,
@@ -90,6 +321,9 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 				"user":  niso.User,
 				"uts":   niso.UTS,
 			}
+			if niso.User() && manager.userNamespaces == nil {
+				return fmt.Errorf("the host is not configured to run containers in user namespaces")
+			}
 			for _, ns := range manager.requiredNamespaces {
 				f, exists := checks[ns]
 				if !exists {
@@ -106,13 +340,202 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 	return nil
 }
 
+// CreateOptions contains the optional settings for a container that is being
+// created.
+type CreateOptions struct {
+	// Name is the unique name to give the container, by which it may be
+	// referred to instead of its UUID. If it is blank, one is generated from
+	// the image's name.
+	Name string
+
+	// Labels are arbitrary key=value pairs describing the container, by which
+	// clients may select it.
+	Labels map[string]string
+
+	// Volumes lists the volumes to mount into the container. They must already
+	// exist.
+	Volumes []*VolumeMount
+
+	// RestartPolicy specifies when an app is restarted once it exits, up to
+	// MaxRetries times. A MaxRetries of 0 means there is no limit.
+	RestartPolicy RestartPolicy
+	MaxRetries    int
+
+	// Pod optionally specifies the apps to run from the image within the
+	// container. All of the apps share the container's filesystem and
+	// namespaces. If it is nil, the image's App is run.
+	Pod *schema.PodManifest
+
+	// Ports lists container ports to publish on the host, in addition to those
+	// declared in the apps' manifests, or to publish a declared port on a
+	// different host port. They require the container network.
+	Ports []network.PortMapping
+
+	// StopGracePeriod is how long the apps are given to exit after being sent
+	// SIGTERM when the container is stopped, including when the host shuts
+	// down. If it is 0, DefaultStopGracePeriod is used.
+	StopGracePeriod time.Duration
+
+	// Privileged runs the apps with all capabilities, rather than limiting them
+	// to the default set or the set given by their isolators. Unless it is
+	// given a SecurityLabel, a privileged container is also left unconfined by
+	// the host's security module.
+	Privileged bool
+
+	// SecurityLabel is the AppArmor profile or SELinux context the apps are
+	// confined by, instead of the manager's default.
+	SecurityLabel string
+
+	// ReadOnlyRootFS mounts the container's root filesystem read-only, so that
+	// the apps can only write to volumes and tmpfs mounts.
+	ReadOnlyRootFS bool
+
+	// Tmpfs lists tmpfs filesystems to mount into the container as scratch
+	// areas.
+	Tmpfs []*TmpfsMount
+
+	// Secrets lists the secrets from the host's store to expose to the apps.
+	Secrets []*SecretMount
+
+	// HealthCheck is a liveness probe run against the container while its apps
+	// are running. Once it is unhealthy, the apps are restarted if the restart
+	// policy calls for it.
+	HealthCheck *HealthCheck
+
+	// DiskLimit is the most disk space, in bytes, the container may write to
+	// its root filesystem. If it is 0, the limit from the apps' storage
+	// isolator is used, if any.
+	DiskLimit int64
+
+	// Bandwidth limits the rate of the container's traffic on the container
+	// network. A direction which is 0 uses the limit from the apps' network
+	// bandwidth isolator, if any.
+	Bandwidth network.Bandwidth
+
+	// Sysctls are kernel parameters to set within the container's namespaces.
+	// Only the namespaced net.* and kernel.shm* parameters may be set.
+	Sysctls map[string]string
+
+	// Logging selects the driver which captures the apps' output, overriding
+	// the manager's configuration for whatever it sets.
+	Logging LogConfig
+
+	// Tty gives the first app a console, which clients attach to for its
+	// input and output.
+	Tty bool
+
+	// Stdin keeps the first app's stdin open for clients attaching to the
+	// container to write to. The app reads EOF once a client closes it.
+	Stdin bool
+
+	// Attach holds the apps' output from when the container starts until the
+	// first client attaches, for clients which create a container to attach
+	// to it.
+	Attach bool
+
+	// Isolation selects whether the apps are run within namespaces on the
+	// host or within a VM of their own, which requires the manager to be
+	// configured with a VM. If it is empty, the runtime's isolation is used.
+	Isolation Isolation
+
+	// Runtime names the runtime which runs the apps. If it is empty, the
+	// default runtime for the isolation is used: the native runtime for
+	// namespaces, and the VM runtime for VMs.
+	Runtime string
+
+	// CPUPlacement, if set, gives the container CPUs of its own or keeps it
+	// on a NUMA node.
+	CPUPlacement *CPUPlacement
+
+	// Priority orders the container against the others when the host is short
+	// of memory, with the lowest throttled and then evicted first. If it is
+	// empty, PriorityNormal is used.
+	Priority Priority
+}
+
 // Create begins launching a container with the provided image manifest and
-// reader as the source of the ACI.
+// reader as the source of the ACI. The options may be nil.
 func (manager *Manager) Create(
 	name string, imageManifest *schema.ImageManifest, image io.ReadCloser,
+	opts *CreateOptions,
 ) (*Container, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+
 	// revalidate the image
-	if err := manager.Validate(imageManifest); err != nil {
+	if opts.Pod != nil {
+		if err := manager.ValidatePod(imageManifest, opts.Pod); err != nil {
+			return nil, err
+		}
+	} else if err := manager.Validate(imageManifest); err != nil {
+		return nil, err
+	}
+	if err := manager.validatePorts(opts.Ports); err != nil {
+		return nil, err
+	}
+	restartPolicy, err := ParseRestartPolicy(string(opts.RestartPolicy))
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxRetries < 0 {
+		return nil, fmt.Errorf("the max retries must not be negative")
+	}
+	if opts.StopGracePeriod < 0 {
+		return nil, fmt.Errorf("the stop grace period must not be negative")
+	}
+	var isolation Isolation
+	if opts.Isolation != "" {
+		if isolation, err = ParseIsolation(string(opts.Isolation)); err != nil {
+			return nil, err
+		}
+	}
+	runtime, err := manager.selectRuntime(opts.Runtime, isolation)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SecurityLabel != "" && manager.securityModule == "" {
+		return nil, fmt.Errorf("the host has no security module enabled to apply the security label")
+	}
+	if err := validateTmpfs(opts.Tmpfs); err != nil {
+		return nil, err
+	}
+	if err := manager.validateSecrets(opts.Secrets, opts.Tmpfs); err != nil {
+		return nil, err
+	}
+	if err := validateHealthCheck(opts.HealthCheck); err != nil {
+		return nil, err
+	}
+	if opts.DiskLimit < 0 {
+		return nil, fmt.Errorf("the disk limit must not be negative")
+	}
+	if err := validateSysctls(opts.Sysctls); err != nil {
+		return nil, err
+	}
+	if err := opts.Bandwidth.Validate(); err != nil {
+		return nil, err
+	}
+	if err := manager.validateCPUPlacement(opts.CPUPlacement); err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(string(opts.Priority))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name != "" {
+		if err := ValidateName(opts.Name); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateLabels(opts.Labels); err != nil {
+		return nil, err
+	}
+	logConfig := opts.Logging.withDefaults(manager.logConfig)
+	if err := validateLogConfig(logConfig); err != nil {
+		return nil, err
+	}
+	metadataToken, err := newMetadataToken()
+	if err != nil {
 		return nil, err
 	}
 
@@ -127,30 +550,107 @@ func (manager *Manager) Create(
 		log:              manager.Log.Clone(),
 		uuid:             uuid.Variant4().String(),
 		waitch:           make(chan bool),
+		restartch:        make(chan bool, 1),
 		initialImageFile: image,
 		image:            imageManifest,
+		volumes:          opts.Volumes,
+		requestedPorts:   opts.Ports,
+		restartPolicy:    restartPolicy,
+		maxRetries:       opts.MaxRetries,
+		stopGracePeriod:  opts.StopGracePeriod,
+		privileged:       opts.Privileged,
+		readOnlyRootFS:   opts.ReadOnlyRootFS,
+		runtime:          runtime,
+		tmpfs:            opts.Tmpfs,
+		secrets:          opts.Secrets,
+		diskLimit:        opts.DiskLimit,
+		bandwidth:        opts.Bandwidth,
+		cpuPlacement:     opts.CPUPlacement,
+		priority:         priority,
+		healthCheck:      opts.HealthCheck,
+		sysctls:          opts.Sysctls,
+		labels:           opts.Labels,
+		logConfig:        logConfig,
+		metadataToken:    metadataToken,
+		stdin:            opts.Stdin && !opts.Tty,
+		holdOutput:       opts.Attach,
 		pod: &schema.PodManifest{
 			ACKind:    schema.PodManifestKind,
 			ACVersion: schema.AppContainerVersion,
-			Apps: schema.AppList([]schema.RuntimeApp{
-				schema.RuntimeApp{
-					Name: types.ACName(name),
-					App:  imageManifest.App,
-					Image: schema.RuntimeImage{
-						Name:   &imageManifest.Name,
-						Labels: imageManifest.Labels,
-					},
-				},
-			}),
 		},
 	}
+
+	// build the pod's apps, either from the specified pod or from the image
+	runtimeApps := []schema.RuntimeApp{
+		schema.RuntimeApp{
+			Name: types.ACName(name),
+			Image: schema.RuntimeImage{
+				Name: &imageManifest.Name,
+			},
+		},
+	}
+	if opts.Pod != nil {
+		runtimeApps = opts.Pod.Apps
+		container.pod.Annotations = opts.Pod.Annotations
+	}
+	for _, ra := range runtimeApps {
+		if ra.App == nil {
+			ra.App = imageManifest.App
+		}
+		ra.Image.Labels = imageManifest.Labels
+		container.pod.Apps = append(container.pod.Apps, ra)
+		container.apps = append(container.apps, &app{
+			name:  ra.Name,
+			app:   ra.App,
+			state: NEW,
+		})
+	}
+	container.apps[0].tty = opts.Tty
+	if err := runtime.Validate(opts, container.pod); err != nil {
+		return nil, err
+	}
+
+	if container.diskLimit == 0 {
+		container.diskLimit = container.storageLimit()
+	}
+	if err := container.validateDiskLimit(); err != nil {
+		return nil, err
+	}
+	if err := container.setBandwidth(); err != nil {
+		return nil, err
+	}
+	if err := container.validateHugepages(); err != nil {
+		return nil, err
+	}
+
+	// confine the apps by the requested security label, or the manager's
+	// default unless the container is privileged or confined by a VM
+	container.securityLabel = opts.SecurityLabel
+	if container.securityLabel == "" && !container.privileged && !container.isHostPrivileged() && container.Isolation() != IsolationVM {
+		container.securityLabel = manager.securityLabel
+	}
+
 	container.log.SetField("container", container.uuid)
 	container.log.Debugf("Launching container %s", container.uuid)
 
-	// add it to the manager's map
-	manager.containersLock.Lock()
-	manager.containers[container.uuid] = container
-	manager.containersLock.Unlock()
+	// add it to the manager's map, once the host has room for it. Its volumes
+	// and secrets are checked holding their locks until it's added, so none
+	// can be deleted before the container is seen to be using them.
+	manager.volumeLock.Lock()
+	manager.secretLock.Lock()
+	err = manager.validateVolumes(opts.Volumes)
+	if err == nil {
+		err = manager.checkSecretsExist(opts.Secrets)
+	}
+	if err == nil {
+		err = manager.register(container, opts.Name, true)
+	}
+	manager.secretLock.Unlock()
+	manager.volumeLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	container.publish(&Event{Type: EventCreated, Image: imageManifest.Name.String()})
 
 	// begin the startup sequence
 	container.start()
@@ -158,6 +658,35 @@ func (manager *Manager) Create(
 	return container, nil
 }
 
+// register adds the container to the manager's map, giving it the requested
+// name or a generated one. If admit is set, the container is refused when the
+// host can't fit what it reserves.
+func (manager *Manager) register(container *Container, name string, admit bool) error {
+	manager.containersLock.Lock()
+	defer manager.containersLock.Unlock()
+	if manager.shuttingDown {
+		return errHostShuttingDown
+	}
+	if manager.handingOff {
+		return errHandingOff
+	}
+	if manager.containers[container.uuid] != nil {
+		return fmt.Errorf("container %s is already being managed", container.uuid)
+	}
+	if admit {
+		if err := manager.admit(container); err != nil {
+			return err
+		}
+	}
+	if err := manager.assignName(container, name, container.image.Name); err != nil {
+		return err
+	}
+	manager.created++
+	container.created = manager.created
+	manager.containers[container.uuid] = container
+	return nil
+}
+
 // removes a child container from the Container Manager.
 func (manager *Manager) remove(container *Container) {
 	manager.containersLock.Lock()
@@ -186,15 +715,41 @@ func (manager *Manager) Container(uuid string) *Container {
 	return manager.containers[uuid]
 }
 
+// Ping returns an error if the locks guarding the containers and the
+// publishing of events can't be taken within the timeout, which means the
+// manager is wedged. Once a Ping has timed out, later ones fail immediately
+// until the locks are finally taken, rather than piling up behind them.
+func (manager *Manager) Ping(timeout time.Duration) error {
+	if !atomic.CompareAndSwapInt32(&manager.pinging, 0, 1) {
+		return fmt.Errorf("the manager has been unresponsive for over %s", timeout)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		manager.containersLock.RLock()
+		manager.containersLock.RUnlock()
+		manager.subscribersLock.Lock()
+		manager.subscribersLock.Unlock()
+		atomic.StoreInt32(&manager.pinging, 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("the manager did not respond within %s", timeout)
+	}
+}
+
 // getVolumePath will get the absolute path on the host to the named volume. It
 // will also ensure that the volume name exists within the volumes directory.
 func (manager *Manager) getVolumePath(name string) (string, error) {
-	if !types.ValidACName.MatchString(name) {
-		return "", fmt.Errorf("invalid characters present in volume name")
+	volumePath, err := manager.volumePath(name)
+	if err != nil {
+		return "", err
 	}
 
-	volumePath := filepath.Join(manager.volumeDirectory, name)
-
 	manager.volumeLock.Lock()
 	defer manager.volumeLock.Unlock()
 
@@ -203,3 +758,45 @@ func (manager *Manager) getVolumePath(name string) (string, error) {
 	}
 	return volumePath, nil
 }
+
+// CgroupName returns the name of the cgroup the containers' cgroups are created
+// within.
+func (manager *Manager) CgroupName() string {
+	return manager.cgroup.Name()
+}
+
+// Directory returns the directory the containers' files are kept in.
+func (manager *Manager) Directory() string {
+	return manager.containerDirectory
+}
+
+// VolumeDirectory returns the directory the named volumes are kept in, or an
+// empty string if volumes aren't configured.
+func (manager *Manager) VolumeDirectory() string {
+	return manager.volumeDirectory
+}
+
+// StorageDriver returns the name of the storage driver providing containers'
+// root filesystems.
+func (manager *Manager) StorageDriver() string {
+	return manager.storage.Name()
+}
+
+// PruneLayers removes the images extracted by the storage driver which no
+// containers are using, and returns their IDs.
+func (manager *Manager) PruneLayers() ([]string, error) {
+	return manager.storage.Prune()
+}
+
+// storageDriver returns the driver which created the filesystem. Filesystems
+// from the plain driver, such as those of containers with their own user
+// namespace, are always supported.
+func (manager *Manager) storageDriver(fs *storage.Filesystem) (storage.Driver, error) {
+	switch fs.Driver {
+	case manager.storage.Name():
+		return manager.storage, nil
+	case storage.Plain:
+		return storage.NewPlain(nil, nil), nil
+	}
+	return nil, fmt.Errorf("the container's filesystem requires the %s storage driver, which the host is not using", fs.Driver)
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+func TestValidatePod(t *testing.T) {
+	image := schema.BlankImageManifest()
+	image.Name = types.ACIdentifier("example.com/web")
+	image.App = &types.App{Exec: types.Exec{"/bin/web"}, User: "0", Group: "0"}
+	other := types.ACIdentifier("example.com/other")
+
+	runtimeApp := func(name string, imageName *types.ACIdentifier, app *types.App) schema.RuntimeApp {
+		return schema.RuntimeApp{
+			Name:  types.ACName(name),
+			Image: schema.RuntimeImage{Name: imageName},
+			App:   app,
+		}
+	}
+	worker := &types.App{Exec: types.Exec{"/bin/worker"}, User: "0", Group: "0"}
+
+	tests := []struct {
+		desc  string
+		apps  schema.AppList
+		valid bool
+	}{
+		{"image's app", schema.AppList{runtimeApp("web", &image.Name, nil)}, true},
+		{"own apps", schema.AppList{runtimeApp("web", &image.Name, nil), runtimeApp("worker", &image.Name, worker)}, true},
+		{"no apps", schema.AppList{}, false},
+		{"unnamed app", schema.AppList{runtimeApp("", &image.Name, nil)}, false},
+		{"duplicate names", schema.AppList{runtimeApp("web", &image.Name, nil), runtimeApp("web", &image.Name, worker)}, false},
+		{"no image", schema.AppList{runtimeApp("web", nil, nil)}, false},
+		{"other image", schema.AppList{runtimeApp("web", &image.Name, nil), runtimeApp("other", &other, worker)}, false},
+		{"no exec", schema.AppList{runtimeApp("web", &image.Name, &types.App{User: "0", Group: "0"})}, false},
+	}
+
+	manager := &Manager{}
+	for _, test := range tests {
+		pod := schema.BlankPodManifest()
+		pod.Apps = test.apps
+		err := manager.ValidatePod(image, pod)
+		if test.valid && err != nil {
+			t.Fatalf("Expected the pod with %s to be valid; got %s", test.desc, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("Expected the pod with %s to be invalid", test.desc)
+		}
+	}
+}
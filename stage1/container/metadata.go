@@ -0,0 +1,57 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/appc/spec/schema"
+)
+
+// metadataTokenSize is the number of random bytes in the tokens which identify
+// containers to the metadata service.
+const metadataTokenSize = 32
+
+// newMetadataToken returns a random token to identify a container to the
+// metadata service.
+func newMetadataToken() (string, error) {
+	b := make([]byte, metadataTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the metadata token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// metadataURL returns the URL the container's apps reach the metadata service
+// at, or an empty string if the host doesn't run one.
+func (c *Container) metadataURL() string {
+	if c.manager.metadataURL == "" {
+		return ""
+	}
+	return c.manager.metadataURL + "/" + c.metadataToken
+}
+
+// ImageManifest returns the manifest of the image the container was created
+// from.
+func (container *Container) ImageManifest() *schema.ImageManifest {
+	return container.image
+}
+
+// MetadataContainer returns the container which was given the metadata token,
+// or nil if none was. The tokens are compared in constant time, as they are
+// the only thing keeping containers from reading each other's metadata.
+func (manager *Manager) MetadataContainer(token string) *Container {
+	manager.containersLock.RLock()
+	defer manager.containersLock.RUnlock()
+
+	var found *Container
+	for _, c := range manager.containers {
+		if subtle.ConstantTimeCompare([]byte(c.metadataToken), []byte(token)) == 1 {
+			found = c
+		}
+	}
+	return found
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appc/spec/schema/types"
+)
+
+// maxNameLength is the longest name a container may be given.
+const maxNameLength = 64
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateName returns an error if the name can't be given to a container.
+func ValidateName(name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("the container name %q is longer than %d characters", name, maxNameLength)
+	}
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("the container name %q must start with a letter or digit and contain only letters, digits, '_', '.', and '-'", name)
+	}
+	return nil
+}
+
+// Name returns the unique name the container was given when it was created.
+func (container *Container) Name() string {
+	if container == nil {
+		return ""
+	}
+	return container.name
+}
+
+// generateNames returns the names a container with the UUID may be given when
+// none was requested, in order of preference. They're made of the last part
+// of the image's name followed by the container's short name, or its whole
+// UUID should that be taken.
+func generateNames(image types.ACIdentifier, uuid string) []string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		}
+		return '-'
+	}, path.Base(image.String()))
+	base = strings.Trim(base, "-_.")
+	if base == "" {
+		base = "container"
+	}
+	if max := maxNameLength - len(uuid) - 1; len(base) > max {
+		base = base[:max]
+	}
+
+	names := []string{base + "-" + uuid}
+	if len(uuid) > 8 {
+		names = append([]string{base + "-" + uuid[:8]}, names...)
+	}
+	return names
+}
+
+// assignName gives the container the requested name, or the first of the
+// generated ones which is free. The manager's containersLock must be held.
+func (manager *Manager) assignName(container *Container, requested string, image types.ACIdentifier) error {
+	if requested != "" {
+		if other := manager.containerNamed(requested); other != nil {
+			return fmt.Errorf("the container name %q is already in use by container %s", requested, other.uuid)
+		}
+		container.name = requested
+		return nil
+	}
+	for _, name := range generateNames(image, container.uuid) {
+		if manager.containerNamed(name) == nil {
+			container.name = name
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to generate a unique name for container %s", container.uuid)
+}
+
+// containerNamed returns the container with the name, or nil if there isn't
+// one. The manager's containersLock must be held.
+func (manager *Manager) containerNamed(name string) *Container {
+	for _, c := range manager.containers {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Lookup returns the container the reference refers to, which may be the
+// container's UUID, its name, or a prefix of its UUID which no other
+// container's UUID starts with. Exact UUIDs take precedence over names, and
+// names over prefixes.
+func (manager *Manager) Lookup(ref string) (*Container, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("no container was specified")
+	}
+
+	manager.containersLock.RLock()
+	defer manager.containersLock.RUnlock()
+	if c := manager.containers[ref]; c != nil {
+		return c, nil
+	}
+	if c := manager.containerNamed(ref); c != nil {
+		return c, nil
+	}
+
+	var matches []*Container
+	for uuid, c := range manager.containers {
+		if strings.HasPrefix(uuid, ref) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no container is named %q or has a UUID starting with it", ref)
+	case 1:
+		return matches[0], nil
+	}
+	uuids := make([]string, len(matches))
+	for i, c := range matches {
+		uuids[i] = c.uuid
+	}
+	sort.Strings(uuids)
+	return nil, fmt.Errorf("%q is ambiguous, it prefixes the UUIDs of containers %s", ref, strings.Join(uuids, ", "))
+}
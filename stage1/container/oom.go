@@ -0,0 +1,111 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"time"
+)
+
+// oomSettleDelay is how long to wait after the kernel reports the container
+// reached its memory limit before checking how many processes were killed. The
+// notification is sent before the OOM killer picks its victim, and it gives the
+// wait loop time to attribute the kill to an app which exited because of it.
+const oomSettleDelay = time.Second
+
+// OOMKills returns the number of the container's processes which have been
+// killed for exceeding its memory limit.
+func (c *Container) OOMKills() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.oomKills
+}
+
+// OOMKilled returns whether any of the container's processes have been killed
+// for exceeding its memory limit since its apps were last started.
+func (c *Container) OOMKilled() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.oomKills > c.startOOMKills
+}
+
+// watchOOM registers for the kernel's notifications that the container's cgroup
+// has run out of memory, and handles each of them until the cgroup is removed.
+func (c *Container) watchOOM() {
+	ch, stop, err := c.cgroup.NotifyOOM()
+	if err != nil {
+		c.log.Warnf("Failed to register for OOM notifications: %v", err)
+		return
+	}
+	c.mutex.Lock()
+	c.stopOOMWatch = stop
+	c.mutex.Unlock()
+
+	go func() {
+		for range ch {
+			c.handleOOM()
+		}
+	}()
+}
+
+// stopWatchingOOM stops handling the OOM notifications for the container's
+// cgroup.
+func (c *Container) stopWatchingOOM() {
+	c.mutex.Lock()
+	stop := c.stopOOMWatch
+	c.stopOOMWatch = nil
+	c.mutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// handleOOM records the processes killed when the container ran out of memory.
+// Kills which weren't attributed to an app exiting, such as those of processes
+// the apps started, leave the apps running in an unknown state, so they are
+// restarted if the container's restart policy calls for it.
+func (c *Container) handleOOM() {
+	time.Sleep(oomSettleDelay)
+	if c.checkOOMKills("") == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	restart := c.shouldRestartAfterFailure()
+	if restart {
+		for _, a := range c.apps {
+			a.restarts++
+		}
+	}
+	c.mutex.Unlock()
+	if !restart {
+		return
+	}
+
+	c.log.Infof("Restarting apps after an OOM kill")
+	if err := c.RestartApps(c.StopGracePeriod()); err != nil {
+		c.log.Errorf("Failed to restart the apps after an OOM kill: %v", err)
+	}
+}
+
+// shouldRestartAfterFailure returns whether the container's apps should be
+// restarted after a failure which leaves them running in an unknown state, such
+// as one of its processes being killed for exceeding the memory limit or the
+// container becoming unhealthy. The apps are restarted under both the always
+// and on-failure policies, unless any has reached the maximum number of
+// restarts. The container's mutex must be held.
+func (c *Container) shouldRestartAfterFailure() bool {
+	if c.shuttingDown || c.stopping || c.checkpointing || c.state != RUNNING {
+		return false
+	}
+	switch c.restartPolicy {
+	case RestartAlways, RestartOnFailure:
+	default:
+		return false
+	}
+	for _, a := range c.apps {
+		if c.maxRetries > 0 && a.restarts >= c.maxRetries {
+			return false
+		}
+	}
+	return true
+}
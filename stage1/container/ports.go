@@ -0,0 +1,120 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+
+	"github.com/apcera/kurma/stage1/network"
+)
+
+// Ports returns the container's ports which are published on the host.
+func (c *Container) Ports() []network.PortMapping {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ports := make([]network.PortMapping, len(c.ports))
+	copy(ports, c.ports)
+	return ports
+}
+
+// validatePorts ensures the ports requested to be published for a container
+// are valid and that their host ports are free.
+func (manager *Manager) validatePorts(ports []network.PortMapping) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	if manager.network == nil {
+		return fmt.Errorf("publishing ports requires the container network to be enabled")
+	}
+	if !manager.network.Bridged() {
+		return fmt.Errorf("ports can't be published in the %s mode, the containers' ports are reachable at their addresses on the LAN", manager.network.Mode())
+	}
+
+	hostPorts := make(map[string]bool)
+	containerPorts := make(map[string]bool)
+	for _, p := range ports {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		hostPort := fmt.Sprintf("%d/%s", p.HostPort, p.Protocol)
+		containerPort := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		if hostPorts[hostPort] {
+			return fmt.Errorf("host port %s is specified multiple times", hostPort)
+		}
+		if containerPorts[containerPort] {
+			return fmt.Errorf("container port %s is specified multiple times", containerPort)
+		}
+		hostPorts[hostPort] = true
+		containerPorts[containerPort] = true
+
+		if manager.network.PortInUse(p) {
+			return fmt.Errorf("host port %s is already published", hostPort)
+		}
+	}
+	return nil
+}
+
+// portMappings returns the ports to publish for the container. Each port
+// declared by the apps' manifests is published on the same host port, unless
+// a different host port was requested for it when the container was created.
+func (c *Container) portMappings() (requested, declared []network.PortMapping) {
+	overridden := make(map[string]bool)
+	for _, p := range c.requestedPorts {
+		overridden[fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range c.apps {
+		for _, port := range a.app.Ports {
+			count := port.Count
+			if count == 0 {
+				count = 1
+			}
+			for i := uint(0); i < count; i++ {
+				p := network.PortMapping{
+					Protocol:      port.Protocol,
+					HostPort:      int(port.Port + i),
+					ContainerPort: int(port.Port + i),
+				}
+				key := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+				if overridden[key] || seen[key] {
+					continue
+				}
+				seen[key] = true
+				declared = append(declared, p)
+			}
+		}
+	}
+	return c.requestedPorts, declared
+}
+
+// publishPorts publishes the container's ports on the host. Ports which were
+// requested when the container was created must be published, while a port
+// declared by a manifest is skipped with a warning if its host port is taken.
+func (c *Container) publishPorts(endpoint *network.Endpoint) error {
+	// containers on the LAN are reached at their own addresses instead
+	if !c.manager.network.Bridged() {
+		return nil
+	}
+	requested, declared := c.portMappings()
+
+	var published []network.PortMapping
+	for _, p := range requested {
+		if err := c.manager.network.Publish(endpoint, p); err != nil {
+			return fmt.Errorf("failed to publish port %s: %v", p, err)
+		}
+		published = append(published, p)
+	}
+	for _, p := range declared {
+		if err := c.manager.network.Publish(endpoint, p); err != nil {
+			c.log.Warnf("Not publishing port %s: %v", p, err)
+			continue
+		}
+		published = append(published, p)
+	}
+
+	c.mutex.Lock()
+	c.ports = published
+	c.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+)
+
+// Priority is the class of a container which orders it against the others when
+// the host runs short of memory and containers are throttled or evicted.
+type Priority string
+
+const (
+	// PriorityCritical containers, such as those the host launches on boot,
+	// are never throttled or evicted.
+	PriorityCritical = Priority("critical")
+
+	// PriorityHigh containers are the last to be throttled or evicted.
+	PriorityHigh = Priority("high")
+
+	// PriorityNormal is the class of containers which aren't given one.
+	PriorityNormal = Priority("normal")
+
+	// PriorityLow containers are the first to be throttled or evicted.
+	PriorityLow = Priority("low")
+)
+
+// ParsePriority returns the Priority matching the provided string. An empty
+// string is treated as PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch p := Priority(s); p {
+	case "":
+		return PriorityNormal, nil
+	case PriorityCritical, PriorityHigh, PriorityNormal, PriorityLow:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// rank orders the priorities from the first to be evicted.
+func (p Priority) rank() int {
+	switch p {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	case PriorityCritical:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// Priority returns the container's priority class.
+func (c *Container) Priority() Priority {
+	if c.priority == "" {
+		return PriorityNormal
+	}
+	return c.priority
+}
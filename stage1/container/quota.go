@@ -0,0 +1,150 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"time"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/storage"
+)
+
+const (
+	// diskQuotaCheckInterval is how often a container's disk usage is compared
+	// with its limit.
+	diskQuotaCheckInterval = 10 * time.Second
+
+	// diskQuotaFullMargin is how close to its limit a container's disk usage
+	// must be for it to have reached its limit. Writes which don't fit are
+	// refused, so the usage may stop short of the limit by a few blocks.
+	diskQuotaFullMargin = 64 << 10
+)
+
+// DiskUsage returns the disk space the container has written within its
+// directory and the most it may write, in bytes. Both are 0 if the container
+// has no disk limit.
+func (c *Container) DiskUsage() (int64, int64, error) {
+	c.mutex.Lock()
+	quota := c.quota
+	c.mutex.Unlock()
+	if quota == nil {
+		return 0, 0, nil
+	}
+	return storage.QuotaUsage(c.directory, quota)
+}
+
+// storageLimit returns the disk limit given by the apps' storage isolator, or 0
+// if there isn't one.
+func (c *Container) storageLimit() int64 {
+	if iso := c.isolator(kschema.StorageName); iso != nil {
+		if siso, ok := iso.Value().(*kschema.Storage); ok {
+			return siso.Limit().Value()
+		}
+	}
+	return 0
+}
+
+// validateDiskLimit checks the container's disk limit can be enforced. It is
+// set on the container's directory, so the storage driver has to write the
+// container's changes within it.
+func (c *Container) validateDiskLimit() error {
+	if c.diskLimit == 0 {
+		return nil
+	}
+	if c.diskLimit < storage.MinQuota {
+		return fmt.Errorf("the disk limit must be at least %d bytes", storage.MinQuota)
+	}
+	if c.usesUserNamespace() {
+		return nil
+	}
+	if name := c.manager.storage.Name(); !storage.SupportsQuota(name) {
+		return fmt.Errorf("disk limits are not supported by the %s storage driver", name)
+	}
+	return nil
+}
+
+// setQuota limits the disk space used within the container's directory, which
+// must still be empty, and begins watching for the container reaching it.
+func (c *Container) setQuota() error {
+	if c.diskLimit == 0 {
+		return nil
+	}
+	quota, err := storage.SetQuota(c.directory, c.diskLimit)
+	if err != nil {
+		return err
+	}
+	c.log.Debugf("Limited the disk usage to %d bytes with a %s quota", quota.Limit, quota.Type)
+	c.mutex.Lock()
+	c.quota = quota
+	c.mutex.Unlock()
+	c.watchDiskQuota()
+	return nil
+}
+
+// removeQuota stops watching the container's disk usage and removes the quota
+// from its directory.
+func (c *Container) removeQuota() error {
+	c.mutex.Lock()
+	quota := c.quota
+	stop := c.stopDiskWatch
+	c.stopDiskWatch = nil
+	c.mutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+	if quota == nil {
+		return nil
+	}
+
+	if err := storage.RemoveQuota(c.directory, quota); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.quota = nil
+	c.mutex.Unlock()
+	return nil
+}
+
+// watchDiskQuota periodically checks whether the container has reached its
+// disk limit until it is stopped.
+func (c *Container) watchDiskQuota() {
+	stop := make(chan bool)
+	c.mutex.Lock()
+	c.stopDiskWatch = func() { close(stop) }
+	c.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(diskQuotaCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.checkDiskQuota()
+			}
+		}
+	}()
+}
+
+// checkDiskQuota publishes an event when the container's disk usage reaches its
+// limit. Another is only published once the usage has dropped back below it.
+func (c *Container) checkDiskQuota() {
+	usage, limit, err := c.DiskUsage()
+	if err != nil {
+		c.log.Warnf("Failed to check the disk usage: %v", err)
+		return
+	}
+	full := limit > 0 && limit-usage < diskQuotaFullMargin
+
+	c.mutex.Lock()
+	reached := full && !c.diskQuotaFull
+	c.diskQuotaFull = full
+	c.mutex.Unlock()
+
+	if reached {
+		c.log.Warnf("The container has used %d of its %d bytes of disk", usage, limit)
+		c.publish(&Event{Type: EventDiskQuotaExceeded})
+	}
+}
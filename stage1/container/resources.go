@@ -0,0 +1,64 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+)
+
+// ResourceUpdate specifies new resource limits to apply to a running
+// container. Any fields left as zero are not changed.
+type ResourceUpdate struct {
+	// Memory is the limit on the memory the container may use, in bytes.
+	Memory int64
+
+	// CPUShares is the container's relative weight when competing for CPU time.
+	CPUShares int64
+
+	// BlkioWeight is the container's relative weight when competing for block
+	// IO, between 10 and 1000.
+	BlkioWeight int64
+}
+
+// UpdateResources applies the new resource limits to the container's cgroup.
+// The apps do not need to be restarted for them to take effect. Lowering the
+// memory limit below what the container is currently using will fail.
+func (c *Container) UpdateResources(update *ResourceUpdate) error {
+	if update.Memory < 0 {
+		return fmt.Errorf("the memory limit must not be negative")
+	}
+	if update.CPUShares < 0 || (update.CPUShares > 0 && update.CPUShares < 2) {
+		return fmt.Errorf("the cpu shares must be at least 2")
+	}
+	if update.BlkioWeight < 0 || (update.BlkioWeight > 0 && (update.BlkioWeight < 10 || update.BlkioWeight > 1000)) {
+		return fmt.Errorf("the blkio weight must be between 10 and 1000")
+	}
+
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	running := c.state == RUNNING || c.state == RESTARTING
+	c.mutex.Unlock()
+	if cgroup == nil || !running {
+		return fmt.Errorf("the container is not running")
+	}
+
+	if update.Memory > 0 {
+		if err := cgroup.LimitMemory(update.Memory); err != nil {
+			return fmt.Errorf("failed to set the memory limit: %v", err)
+		}
+	}
+	if update.CPUShares > 0 {
+		if err := cgroup.SetCPUShares(update.CPUShares); err != nil {
+			return fmt.Errorf("failed to set the cpu shares: %v", err)
+		}
+	}
+	if update.BlkioWeight > 0 {
+		if err := cgroup.SetBlkioWeight(update.BlkioWeight); err != nil {
+			return fmt.Errorf("failed to set the blkio weight: %v", err)
+		}
+	}
+
+	c.log.Infof("Updated resources: memory=%d cpu-shares=%d blkio-weight=%d",
+		update.Memory, update.CPUShares, update.BlkioWeight)
+	return nil
+}
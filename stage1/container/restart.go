@@ -0,0 +1,133 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestartPolicy defines when a container's apps should be restarted once they
+// have exited.
+type RestartPolicy string
+
+const (
+	// RestartNever leaves an app exited once it exits.
+	RestartNever = RestartPolicy("never")
+
+	// RestartAlways restarts an app whenever it exits.
+	RestartAlways = RestartPolicy("always")
+
+	// RestartOnFailure restarts an app only if it exits with a non-zero status
+	// or is killed by a signal.
+	RestartOnFailure = RestartPolicy("on-failure")
+)
+
+const (
+	// The initial delay before restarting an app. It doubles with each
+	// consecutive restart, up to restartMaxBackoff.
+	restartInitialBackoff = time.Second
+	restartMaxBackoff     = 5 * time.Minute
+)
+
+// ParseRestartPolicy returns the RestartPolicy matching the provided string. An
+// empty string is treated as RestartNever.
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch p := RestartPolicy(s); p {
+	case "":
+		return RestartNever, nil
+	case RestartNever, RestartAlways, RestartOnFailure:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q", s)
+	}
+}
+
+// RestartPolicy returns the container's restart policy and the total number of
+// times its apps have been restarted.
+func (c *Container) RestartPolicy() (RestartPolicy, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	restarts := 0
+	for _, a := range c.apps {
+		restarts += a.restarts
+	}
+	return c.restartPolicy, restarts
+}
+
+// shouldRestart returns whether the app should be restarted based on the
+// restart policy, the code it exited with, and how many times it has already
+// been restarted. The container's mutex must be held.
+func (c *Container) shouldRestart(a *app) bool {
+	if c.shuttingDown || c.stopping {
+		return false
+	}
+	if c.maxRetries > 0 && a.restarts >= c.maxRetries {
+		return false
+	}
+
+	switch c.restartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return a.exitCode != 0
+	default:
+		return false
+	}
+}
+
+// restartApp waits for the backoff period and then starts the app again. If the
+// container begins shutting down or is stopped in the meantime, the restart is
+// abandoned. The wait loop is notified once the restart has been handled.
+func (c *Container) restartApp(a *app) {
+	defer func() {
+		select {
+		case c.restartch <- true:
+		default:
+		}
+	}()
+
+	c.mutex.Lock()
+	backoff := restartInitialBackoff << uint(a.restarts)
+	if backoff > restartMaxBackoff || backoff <= 0 {
+		backoff = restartMaxBackoff
+	}
+	if c.state == RUNNING {
+		c.state = RESTARTING
+	}
+	c.mutex.Unlock()
+
+	c.log.Infof("Restarting app %s in %s", a.name, backoff)
+	time.Sleep(backoff)
+
+	c.mutex.Lock()
+	if c.shuttingDown || c.stopping || a.state != RESTARTING {
+		c.mutex.Unlock()
+		return
+	}
+	a.restarts++
+	a.runs++
+	c.mutex.Unlock()
+
+	err := c.startAppProcess(a)
+	defer c.saveState()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		c.log.Errorf("failed to restart app %s: %v", a.name, err)
+		a.state = EXITED
+	}
+
+	// the container is running again once none of its apps are restarting
+	if c.state == RESTARTING {
+		c.state = RUNNING
+		for _, o := range c.apps {
+			if o.state == RESTARTING {
+				c.state = RESTARTING
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/apcera/kurma/stage1/network"
+	client2 "github.com/apcera/kurma/stage2/client"
+	client3 "github.com/apcera/kurma/stage3/client"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/appc/spec/schema"
+)
+
+const (
+	// NativeRuntime is the name of the runtime which runs the apps within
+	// namespaces on the host, under the stage3 initd.
+	NativeRuntime = "native"
+
+	// VMRuntime is the name of the runtime which runs the apps within a VM.
+	VMRuntime = "vm"
+)
+
+// Runtime is a driver which executes a container's apps once the manager has
+// set up its filesystem, cgroup, and logs. It launches the container's init,
+// through whose client the manager then starts, signals, and waits on the
+// apps, and tears nothing down itself: the container is stopped by killing the
+// processes within its cgroup.
+type Runtime interface {
+	// Name returns the name the runtime is selected by.
+	Name() string
+
+	// Isolation returns how the runtime isolates the apps from the host.
+	Isolation() Isolation
+
+	// Validate checks a container being created with the options and pod can
+	// be run by the runtime.
+	Validate(opts *CreateOptions, pod *schema.PodManifest) error
+
+	// Launch launches the container's init as the spec describes, and returns
+	// the client to it once it is ready to start the apps.
+	Launch(spec *LaunchSpec) (client3.Client, error)
+
+	// Recover reconnects to the init of a container launched by an earlier
+	// instance of the manager, which kept running without it, and returns the
+	// client to it.
+	Recover(spec *LaunchSpec) (client3.Client, error)
+
+	// Exec runs a command within the running container as the spec describes,
+	// blocking until it exits, and returns its exit code.
+	Exec(spec *ExecSpec) (int, error)
+}
+
+// LaunchSpec describes the container whose init a Runtime launches.
+type LaunchSpec struct {
+	// Name is the container's short name, Directory is the directory the
+	// manager keeps its files in, and Root is its root filesystem.
+	Name      string
+	Directory string
+	Root      string
+
+	// SocketPath is where the stage3 initd listens, for runtimes which use it.
+	SocketPath string
+
+	// Cgroup is the container's cgroup, which its processes must be within.
+	Cgroup *cgroups.Cgroup
+
+	// The namespaces to create for the container, and the mappings of its user
+	// namespace if it has one.
+	NewIPCNamespace     bool
+	NewMountNamespace   bool
+	NewNetworkNamespace bool
+	NewPIDNamespace     bool
+	NewUTSNamespace     bool
+	NewUserNamespace    bool
+	Uidmap              string
+	Gidmap              string
+
+	// HostPrivileged gives the container access to the host's devices.
+	HostPrivileged bool
+
+	// MountPoints are mounted within the container before it is chrooted, in
+	// the terms of the stage3 initd.
+	MountPoints []*client3.MountPoint
+
+	// ReadOnly makes the root filesystem read-only once the mount points are
+	// mounted, other than the Writable paths within it, such as the FIFOs the
+	// apps' output is captured through.
+	ReadOnly bool
+	Writable []string
+
+	// Ports are the container ports which are to be reachable from the
+	// container network.
+	Ports []network.PortMapping
+
+	// Output is where the runtime's own output is written.
+	Output *os.File
+}
+
+// ExecSpec describes a command a Runtime runs within a running container, with
+// the settings of the pod's first app.
+type ExecSpec struct {
+	Command     []string
+	Environment []string
+	Cgroup      *cgroups.Cgroup
+
+	Stdin  *os.File
+	Stdout *os.File
+	Stderr *os.File
+
+	User         string
+	Group        string
+	Capabilities []int
+	ExecLabel    string
+
+	// HostPrivileged and UserNamespace match how the container was launched.
+	HostPrivileged bool
+	UserNamespace  bool
+}
+
+// nativeRuntime runs the apps within namespaces on the host, under the stage3
+// initd, which is launched through stage2.
+type nativeRuntime struct{}
+
+func (nativeRuntime) Name() string {
+	return NativeRuntime
+}
+
+func (nativeRuntime) Isolation() Isolation {
+	return IsolationNamespaces
+}
+
+// Validate has nothing further to check, as the manager's validation covers
+// what the native runtime supports.
+func (nativeRuntime) Validate(opts *CreateOptions, pod *schema.PodManifest) error {
+	return nil
+}
+
+func (nativeRuntime) Launch(spec *LaunchSpec) (client3.Client, error) {
+	launcher := &client3.Launcher{
+		SocketPath:          spec.SocketPath,
+		Directory:           spec.Root,
+		Chroot:              true,
+		Cgroup:              spec.Cgroup,
+		Stdout:              spec.Output,
+		Stderr:              spec.Output,
+		NewIPCNamespace:     spec.NewIPCNamespace,
+		NewMountNamespace:   spec.NewMountNamespace,
+		NewNetworkNamespace: spec.NewNetworkNamespace,
+		NewPIDNamespace:     spec.NewPIDNamespace,
+		NewUTSNamespace:     spec.NewUTSNamespace,
+		NewUserNamespace:    spec.NewUserNamespace,
+		Uidmap:              spec.Uidmap,
+		Gidmap:              spec.Gidmap,
+		HostPrivileged:      spec.HostPrivileged,
+		MountPoints:         spec.MountPoints,
+	}
+
+	// Make the root filesystem read-only after the other mounts, which are
+	// left as they are. The writable paths are bind mounted over themselves
+	// so that the remount doesn't apply to them.
+	if spec.ReadOnly {
+		for _, path := range spec.Writable {
+			podMount := chrootPath(spec.Root, path)
+			launcher.MountPoints = append(launcher.MountPoints, &client3.MountPoint{
+				Source:      podMount,
+				Destination: podMount,
+				Flags:       syscall.MS_BIND,
+			})
+		}
+		launcher.MountPoints = append(launcher.MountPoints, &client3.MountPoint{
+			Source:      client3.DefaultChrootPath,
+			Destination: client3.DefaultChrootPath,
+			Flags:       syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY,
+		})
+	}
+	return launcher.Run()
+}
+
+// Recover connects to the initd's socket again, which it keeps listening on for
+// as long as it runs.
+func (nativeRuntime) Recover(spec *LaunchSpec) (client3.Client, error) {
+	initdClient := client3.New(spec.SocketPath)
+	if err := initdClient.WaitForSocket(client3.DefaultTimeout); err != nil {
+		return nil, fmt.Errorf("failed to connect to the initd: %v", err)
+	}
+	return initdClient, nil
+}
+
+// Exec re-enters the container through stage2, joining the namespaces of the
+// processes already running within it.
+func (nativeRuntime) Exec(spec *ExecSpec) (int, error) {
+	launcher := &client2.Launcher{
+		Environment:    spec.Environment,
+		Taskfiles:      spec.Cgroup.TasksFiles(),
+		Stdin:          spec.Stdin,
+		Stdout:         spec.Stdout,
+		Stderr:         spec.Stderr,
+		User:           spec.User,
+		Group:          spec.Group,
+		Capabilities:   spec.Capabilities,
+		ExecLabel:      spec.ExecLabel,
+		HostPrivileged: spec.HostPrivileged,
+	}
+
+	// Get a process from the container and copy its namespaces
+	tasks, err := spec.Cgroup.Tasks()
+	if err != nil {
+		return -1, err
+	}
+	if len(tasks) == 0 {
+		return -1, fmt.Errorf("no processes are running inside the container")
+	}
+	launcher.SetNS(tasks[0])
+	if !spec.UserNamespace {
+		launcher.UserNamespace = 0
+	}
+
+	// launch!
+	p, err := launcher.Run(spec.Command...)
+	if err != nil {
+		return -1, err
+	}
+	ps, err := p.Wait()
+	if err != nil {
+		return -1, err
+	}
+	if status, ok := ps.Sys().(syscall.WaitStatus); ok {
+		return status.ExitStatus(), nil
+	}
+	return 0, nil
+}
+
+// chrootPath returns where the path within the root filesystem is found by the
+// stage3 initd before it chroots.
+func chrootPath(root, path string) string {
+	return strings.Replace(path, root, client3.DefaultChrootPath, 1)
+}
+
+// addRuntime registers the runtime with the manager, by its name.
+func (manager *Manager) addRuntime(r Runtime) error {
+	if _, ok := manager.runtimes[r.Name()]; ok {
+		return fmt.Errorf("multiple runtimes are named %q", r.Name())
+	}
+	manager.runtimes[r.Name()] = r
+	return nil
+}
+
+// Runtimes returns the names of the runtimes containers may be run with.
+func (manager *Manager) Runtimes() []string {
+	names := make([]string, 0, len(manager.runtimes))
+	for name := range manager.runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectRuntime returns the runtime a container is run with. The named runtime
+// is used if there is one, which must provide the isolation if it is given, and
+// otherwise the isolation's default runtime is.
+func (manager *Manager) selectRuntime(name string, isolation Isolation) (Runtime, error) {
+	if name == "" {
+		name = NativeRuntime
+		if isolation == IsolationVM {
+			name = VMRuntime
+		}
+	}
+	r, ok := manager.runtimes[name]
+	if !ok {
+		if name == VMRuntime {
+			return nil, fmt.Errorf("the host is not configured to run containers in VMs")
+		}
+		return nil, fmt.Errorf("unknown runtime %q", name)
+	}
+	if isolation != "" && r.Isolation() != isolation {
+		return nil, fmt.Errorf("the %s runtime does not provide the %s isolation", name, isolation)
+	}
+	return r, nil
+}
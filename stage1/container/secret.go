@@ -0,0 +1,279 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/apcera/kurma/stage1/secrets"
+	"github.com/apcera/kurma/stage3/client"
+)
+
+// DefaultSecretsPath is the directory within a container in which secrets are
+// exposed as files when they aren't given a path or environment variable.
+const DefaultSecretsPath = "/run/secrets"
+
+// secretsDirectory is the directory within the container's directory on the
+// host where the tmpfs holding its secret files is mounted.
+const secretsDirectory = "secrets"
+
+var envNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SecretMount exposes a secret from the host's store to a container's apps. It
+// is set as the environment variable Env if one is given, and otherwise is a
+// read-only file at Path, which defaults to the secret's name within
+// DefaultSecretsPath. The files are kept on a tmpfs, so secrets are never
+// written to the container's filesystem.
+type SecretMount struct {
+	Name string
+	Path string
+	Env  string
+}
+
+// CreateSecret stores a new secret, encrypted, in the host's secret store.
+func (manager *Manager) CreateSecret(name string, value []byte) error {
+	if manager.secrets == nil {
+		return fmt.Errorf("no secret store is configured")
+	}
+	return manager.secrets.Create(name, value)
+}
+
+// Secrets returns the names of the secrets in the host's store.
+func (manager *Manager) Secrets() ([]string, error) {
+	if manager.secrets == nil {
+		return nil, fmt.Errorf("no secret store is configured")
+	}
+	return manager.secrets.List()
+}
+
+// DeleteSecret removes the secret from the host's store. A secret cannot be
+// deleted while a container references it.
+func (manager *Manager) DeleteSecret(name string) error {
+	if manager.secrets == nil {
+		return fmt.Errorf("no secret store is configured")
+	}
+
+	// the lock is held across the check, as containers are created holding
+	// it until they're registered and seen to be using their secrets
+	manager.secretLock.Lock()
+	defer manager.secretLock.Unlock()
+
+	for _, c := range manager.Containers() {
+		if c.usesSecret(name) {
+			return fmt.Errorf("secret %q is in use by container %s", name, c.UUID())
+		}
+	}
+	return manager.secrets.Delete(name)
+}
+
+// validateSecrets checks that each secret is exposed at a distinct absolute
+// path or environment variable. Secrets without either are given their default
+// path, and none may be within the container's tmpfs mounts, which would hide
+// them. Whether the secrets exist is checked by checkSecretsExist.
+func (manager *Manager) validateSecrets(mounts []*SecretMount, tmpfs []*TmpfsMount) error {
+	if len(mounts) == 0 {
+		return nil
+	}
+	if manager.secrets == nil {
+		return fmt.Errorf("no secret store is configured")
+	}
+
+	paths := make(map[string]bool)
+	envs := make(map[string]bool)
+	for _, s := range mounts {
+		if err := secrets.ValidateName(s.Name); err != nil {
+			return err
+		}
+
+		if s.Env != "" {
+			if s.Path != "" {
+				return fmt.Errorf("secret %q may be given a path or an environment variable, not both", s.Name)
+			}
+			if !envNameRegexp.MatchString(s.Env) {
+				return fmt.Errorf("invalid environment variable name %q for secret %q", s.Env, s.Name)
+			}
+			if envs[s.Env] {
+				return fmt.Errorf("multiple secrets are specified for the environment variable %s", s.Env)
+			}
+			envs[s.Env] = true
+			continue
+		}
+
+		if s.Path == "" {
+			s.Path = filepath.Join(DefaultSecretsPath, s.Name)
+		}
+		if !filepath.IsAbs(s.Path) {
+			return fmt.Errorf("the path %q of secret %q must be absolute", s.Path, s.Name)
+		}
+		path := filepath.Clean(s.Path)
+		if path == "/" {
+			return fmt.Errorf("secret %q cannot be mounted over the container's root", s.Name)
+		}
+		if paths[path] {
+			return fmt.Errorf("multiple secrets are specified for %s", path)
+		}
+		for _, t := range tmpfs {
+			if strings.HasPrefix(path, filepath.Clean(t.Path)+"/") {
+				return fmt.Errorf("secret %q cannot be mounted within the tmpfs at %s", s.Name, t.Path)
+			}
+		}
+		paths[path] = true
+	}
+	return nil
+}
+
+// checkSecretsExist checks that the secrets are in the host's store. It is
+// called holding the secret lock.
+func (manager *Manager) checkSecretsExist(mounts []*SecretMount) error {
+	for _, s := range mounts {
+		if !manager.secrets.Exists(s.Name) {
+			return fmt.Errorf("secret %q does not exist", s.Name)
+		}
+	}
+	return nil
+}
+
+// usesSecret returns whether the container references the named secret.
+func (c *Container) usesSecret(name string) bool {
+	for _, s := range c.secrets {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretsPath returns the path on the host of the tmpfs holding the
+// container's secret files.
+func (c *Container) secretsPath() string {
+	return filepath.Join(c.directory, secretsDirectory)
+}
+
+// startingSecrets reads the container's secrets from the store. Those exposed
+// as files are written to a tmpfs within the container's directory, from which
+// they are bind mounted into the container, and the rest are kept in memory to
+// be added to the apps' environment.
+func (c *Container) startingSecrets() error {
+	if len(c.secrets) == 0 {
+		return nil
+	}
+	if c.manager.secrets == nil {
+		return fmt.Errorf("no secret store is configured")
+	}
+	c.log.Debug("Setting up secrets.")
+
+	env := make(map[string]string)
+	mounted := false
+	for _, s := range c.secrets {
+		value, err := c.manager.secrets.Get(s.Name)
+		if err != nil {
+			return err
+		}
+		if s.Env != "" {
+			env[s.Env] = string(value)
+			continue
+		}
+
+		if !mounted {
+			if err := os.Mkdir(c.secretsPath(), os.FileMode(0700)); err != nil && !os.IsExist(err) {
+				return err
+			}
+			flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC)
+			if err := syscall.Mount("tmpfs", c.secretsPath(), "tmpfs", flags, "mode=0755"); err != nil {
+				return fmt.Errorf("failed to mount the secrets tmpfs: %v", err)
+			}
+			mounted = true
+		}
+		path := filepath.Join(c.secretsPath(), s.Name)
+		if err := ioutil.WriteFile(path, value, os.FileMode(0444)); err != nil {
+			return err
+		}
+	}
+
+	c.mutex.Lock()
+	c.secretEnv = env
+	c.mutex.Unlock()
+	c.log.Debug("Done setting up secrets.")
+	return nil
+}
+
+// recoverSecretEnv reads the secrets exposed as environment variables from the
+// store again for a container recovered from its saved state, which they're
+// never written to. Its secret files remain on their tmpfs.
+func (c *Container) recoverSecretEnv() error {
+	env := make(map[string]string)
+	for _, s := range c.secrets {
+		if s.Env == "" {
+			continue
+		}
+		if c.manager.secrets == nil {
+			return fmt.Errorf("no secret store is configured")
+		}
+		value, err := c.manager.secrets.Get(s.Name)
+		if err != nil {
+			return err
+		}
+		env[s.Env] = string(value)
+	}
+
+	c.mutex.Lock()
+	c.secretEnv = env
+	c.mutex.Unlock()
+	return nil
+}
+
+// addSecretMounts configures the spec to bind mount the container's secret
+// files read-only at their paths within the container.
+func (c *Container) addSecretMounts(spec *LaunchSpec) error {
+	for _, s := range c.secrets {
+		if s.Env != "" {
+			continue
+		}
+		dir, err := c.ensureContainerPathExists(filepath.Dir(s.Path))
+		if err != nil {
+			return err
+		}
+
+		// the secret is mounted over an empty file, which is created if the
+		// image doesn't already have one at its path
+		podPath := filepath.Join(dir, filepath.Base(s.Path))
+		fi, err := os.Lstat(podPath)
+		switch {
+		case os.IsNotExist(err):
+			f, err := os.OpenFile(podPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(0444))
+			if err != nil {
+				return err
+			}
+			f.Close()
+			if err := c.chownToRoot(podPath); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !fi.Mode().IsRegular():
+			return fmt.Errorf("secret %q cannot be mounted at %s, which is not a regular file", s.Name, s.Path)
+		}
+
+		hostPath := filepath.Join(c.secretsPath(), s.Name)
+		podMount := strings.Replace(podPath, c.stage3Path(), client.DefaultChrootPath, 1)
+		spec.MountPoints = append(spec.MountPoints,
+			&client.MountPoint{
+				Source:      hostPath,
+				Destination: podMount,
+				Flags:       syscall.MS_BIND,
+			},
+			&client.MountPoint{
+				Source:      hostPath,
+				Destination: podMount,
+				Flags:       syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY,
+			})
+		c.addMount(hostPath, s.Path, true)
+	}
+	return nil
+}
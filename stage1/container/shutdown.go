@@ -0,0 +1,50 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"errors"
+	"sort"
+)
+
+// errHostShuttingDown is returned when a container is created or restored once
+// the manager has begun shutting down.
+var errHostShuttingDown = errors.New("the host is shutting down")
+
+// byCreated sorts containers from the most recently created to the oldest.
+type byCreated []*Container
+
+func (s byCreated) Len() int           { return len(s) }
+func (s byCreated) Less(i, j int) bool { return s[i].created > s[j].created }
+func (s byCreated) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Shutdown stops and removes all of the containers on the host in preparation
+// for it to be powered off. Containers are stopped one at a time in the reverse
+// of the order they were created, so that those launched on boot, which later
+// containers may rely on, are the last to go. Each container's apps are given
+// its stop grace period to exit. No containers may be created once it has been
+// called.
+func (manager *Manager) Shutdown() {
+	manager.containersLock.Lock()
+	manager.shuttingDown = true
+	manager.containersLock.Unlock()
+
+	containers := manager.Containers()
+	sort.Sort(byCreated(containers))
+	for _, c := range containers {
+		if c.isShuttingDown() {
+			continue
+		}
+
+		c.log.Infof("Stopping container %s", c.uuid)
+		switch c.State() {
+		case RUNNING, RESTARTING:
+			if err := c.StopApps(c.StopGracePeriod()); err != nil {
+				c.log.Warnf("Failed to stop the apps: %v", err)
+			}
+		}
+		if err := c.Stop(); err != nil {
+			c.log.Errorf("Failed to stop the container: %v", err)
+		}
+	}
+}
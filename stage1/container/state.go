@@ -0,0 +1,441 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/stage1/storage"
+	"github.com/apcera/kurma/util/cgroups"
+)
+
+// stateFile is the file within each container's directory which records its
+// configuration and state as they change, so that it can be recovered by a
+// later instance of the manager.
+const stateFile = "state.json"
+
+// errCheckpointOnly is returned when recovering a container whose directory
+// only has a checkpoint, which is recovered by restoring it.
+var errCheckpointOnly = errors.New("the container is only checkpointed")
+
+// persistedState is what is saved of a container to recover it. Secrets are
+// recorded by name only, and their values are read from the store again.
+type persistedState struct {
+	checkpointState
+
+	State   ContainerState `json:"state"`
+	Runtime string         `json:"runtime"`
+	Volumes []*VolumeMount `json:"volumes,omitempty"`
+	Secrets []*SecretMount `json:"secrets,omitempty"`
+	Stdin   bool           `json:"stdin,omitempty"`
+
+	// The pid of the container's init and the cgroup its processes are in,
+	// once it has been launched.
+	Pid    int    `json:"pid,omitempty"`
+	Cgroup string `json:"cgroup,omitempty"`
+
+	// The CPUs allocated to the container for its exclusive use.
+	CPUs []int `json:"cpus,omitempty"`
+
+	// Whether the container's memory is throttled while the host is short.
+	Throttled bool `json:"throttled,omitempty"`
+
+	StartTime     time.Time `json:"start_time"`
+	ExitTime      time.Time `json:"exit_time"`
+	OOMKills      int64     `json:"oom_kills,omitempty"`
+	StartOOMKills int64     `json:"start_oom_kills,omitempty"`
+
+	// InheritedFiles are the descriptors of the files capturing the output
+	// which were left open for the kurma the manager was handed off to.
+	InheritedFiles []int `json:"inherited_files,omitempty"`
+}
+
+func (c *Container) statePath() string {
+	return filepath.Join(c.directory, stateFile)
+}
+
+// saveState writes the container's current state to its directory, replacing
+// what was saved before. Failing to is logged rather than returned, since the
+// container carries on regardless.
+func (c *Container) saveState() {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	c.mutex.Lock()
+	if c.directory == "" || c.shuttingDown {
+		c.mutex.Unlock()
+		return
+	}
+	state := &persistedState{
+		checkpointState: *c.checkpointState(),
+		State:           c.state,
+		Runtime:         c.Runtime(),
+		Volumes:         c.volumes,
+		Secrets:         c.secrets,
+		Stdin:           c.stdin,
+		StartTime:       c.startTime,
+		ExitTime:        c.exitTime,
+		OOMKills:        c.oomKills,
+		StartOOMKills:   c.startOOMKills,
+		InheritedFiles:  c.handoffFiles,
+	}
+	if c.cgroup != nil {
+		state.Cgroup = c.cgroup.Name()
+	}
+	state.CPUs = c.cpus
+	state.Throttled = c.throttled
+	if c.initdClient != nil {
+		state.Pid = c.initdClient.Pid()
+	}
+	path := c.statePath()
+	c.mutex.Unlock()
+
+	if err := writeState(path, state); err != nil {
+		c.log.Warnf("Failed to save the container's state: %v", err)
+	}
+}
+
+// removeState removes the container's saved state once it is being destroyed,
+// so that what remains of it is cleaned up rather than recovered if the
+// manager exits before it's done. The container must be shutting down.
+func (c *Container) removeState() {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if c.directory == "" {
+		return
+	}
+	if err := os.Remove(c.statePath()); err != nil && !os.IsNotExist(err) {
+		c.log.Warnf("Failed to remove the container's saved state: %v", err)
+	}
+}
+
+// writeState writes the state to a temporary file which is then renamed over
+// the path, so that the saved state is never left partially written.
+func writeState(path string, state *persistedState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, os.FileMode(0600)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readState reads the state saved in the container directory. The error
+// satisfies os.IsNotExist if no state was saved.
+func readState(directory string) (*persistedState, error) {
+	b, err := ioutil.ReadFile(filepath.Join(directory, stateFile))
+	if err != nil {
+		return nil, err
+	}
+	state := &persistedState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse the saved state: %v", err)
+	}
+	if state.UUID == "" || state.Image == nil || state.Pod == nil || len(state.Apps) != len(state.Pod.Apps) {
+		return nil, fmt.Errorf("the saved state is incomplete")
+	}
+	return state, nil
+}
+
+// isShortName returns whether the name is that of a container's directory and
+// cgroup, which are named by the first 8 hex digits of its UUID.
+func isShortName(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	for _, r := range name {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Recover reconciles the manager with the containers an earlier instance of it
+// left in its directory, such as before the daemon restarted. Containers whose
+// init is still running are reattached to and managed as they were, while
+// those whose processes are gone, such as after the host rebooted, are
+// recovered as exited so they can be inspected and destroyed. What remains of
+// containers which never saved their state, or were being destroyed, is
+// removed, and any processes left in cgroups belonging to no container are
+// killed. Containers which can't be recovered are logged and left in place. It
+// is to be called once, before any containers are created, and returns the
+// recovered containers.
+func (manager *Manager) Recover() ([]*Container, error) {
+	fis, err := ioutil.ReadDir(manager.containerDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var recovered []*Container
+	var orphans []string
+	known := make(map[string]bool)
+	for _, fi := range fis {
+		if !fi.IsDir() || !isShortName(fi.Name()) {
+			continue
+		}
+		directory := filepath.Join(manager.containerDirectory, fi.Name())
+		c, err := manager.recoverContainer(directory)
+		switch {
+		case err == errCheckpointOnly:
+			known[fi.Name()] = true
+		case os.IsNotExist(err):
+			orphans = append(orphans, directory)
+		case err != nil:
+			manager.Log.Errorf("Failed to recover the container in %s: %v", directory, err)
+			known[fi.Name()] = true
+		default:
+			recovered = append(recovered, c)
+			known[fi.Name()] = true
+		}
+	}
+
+	// the processes are killed before the orphaned directories are removed,
+	// since they may be using the filesystems mounted within them
+	children, err := manager.cgroup.Children()
+	if err != nil {
+		manager.Log.Warnf("Failed to list the containers' cgroups: %v", err)
+	}
+	for _, cg := range children {
+		if name := path.Base(cg.Name()); !isShortName(name) || known[name] {
+			continue
+		}
+		manager.Log.Warnf("Killing the processes left in cgroup %s, which belongs to no container", cg.Name())
+		if err := destroyCgroup(cg); err != nil {
+			manager.Log.Warnf("Failed to remove cgroup %s: %v", cg.Name(), err)
+		}
+	}
+	for _, directory := range orphans {
+		manager.Log.Warnf("Removing %s, which belongs to no container", directory)
+		if err := RemoveDirectory(directory); err != nil {
+			manager.Log.Warnf("Failed to remove %s: %v", directory, err)
+			continue
+		}
+		storage.RemoveQuota(directory, &storage.Quota{Type: storage.LoopbackQuota})
+	}
+	return recovered, nil
+}
+
+// recoverContainer recovers the container from the state saved in its
+// directory, reattaching to it if its init is still running.
+func (manager *Manager) recoverContainer(directory string) (*Container, error) {
+	// the loopback filesystem of the container's disk quota is mounted over
+	// its directory again if the host has restarted
+	if err := storage.MountQuota(directory); err != nil {
+		return nil, err
+	}
+	state, err := readState(directory)
+	if os.IsNotExist(err) {
+		if _, serr := os.Stat(filepath.Join(directory, "checkpoint")); serr == nil {
+			err = errCheckpointOnly
+		}
+	}
+	if err == nil && state.SecurityLabel != "" && manager.securityModule == "" {
+		err = fmt.Errorf("the container's security label requires a security module")
+	}
+	var runtime Runtime
+	if err == nil {
+		if runtime = manager.runtimes[state.Runtime]; runtime == nil {
+			err = fmt.Errorf("the container's %s runtime is not configured", state.Runtime)
+		}
+	}
+	if err != nil {
+		storage.UnmountQuota(directory)
+		return nil, err
+	}
+
+	c, err := manager.loadContainer(directory, &state.checkpointState, runtime)
+	if err != nil {
+		storage.UnmountQuota(directory)
+		return nil, err
+	}
+	if manager.upgraded {
+		defer c.closeInheritedFiles(state.InheritedFiles)
+	}
+	c.volumes = state.Volumes
+	c.secrets = state.Secrets
+	c.stdin = state.Stdin
+	c.startTime = state.StartTime
+	c.exitTime = state.ExitTime
+	c.oomKills = state.OOMKills
+	c.startOOMKills = state.StartOOMKills
+
+	switch {
+	case state.State == CHECKPOINTED:
+		c.state = CHECKPOINTED
+		if err = c.startingEnvironment(); err == nil {
+			err = c.startingCgroups()
+		}
+	case c.reattach(state):
+		c.log.Infof("Reattached to the container's %s init (pid %d)", runtime.Name(), state.Pid)
+	default:
+		c.log.Warn("The container's processes are no longer running, marking it exited")
+		c.recoverExited(state)
+	}
+	if err == nil {
+		err = manager.register(c, state.Name, false)
+	}
+	if err != nil {
+		c.releaseIDs()
+		c.releaseCPUs()
+		storage.UnmountQuota(directory)
+		return nil, err
+	}
+
+	if c.quota != nil {
+		c.watchDiskQuota()
+	}
+	if c.State() == RUNNING {
+		c.startWaitLoop()
+		c.watchHealth()
+	}
+	c.saveState()
+	return c, nil
+}
+
+// reattach reconnects to the container's init, if it is still running within
+// the container's cgroup, and resumes capturing the apps' output and tracking
+// their state. It returns whether it reconnected.
+func (c *Container) reattach(state *persistedState) bool {
+	if state.Pid == 0 || state.Cgroup == "" {
+		return false
+	}
+	cgroup := cgroups.Open(state.Cgroup)
+	tasks, err := cgroup.Tasks()
+	if err != nil {
+		return false
+	}
+	running := false
+	for _, pid := range tasks {
+		if pid == state.Pid {
+			running = true
+			break
+		}
+	}
+	if !running {
+		return false
+	}
+
+	initdClient, err := c.runtime.Recover(&LaunchSpec{
+		Name:       state.Name,
+		Directory:  c.directory,
+		Root:       c.stage3Path(),
+		SocketPath: c.socketPath(),
+		Cgroup:     cgroup,
+	})
+	if err != nil {
+		c.log.Warnf("Failed to reconnect to the container's init: %v", err)
+		return false
+	}
+	if err := c.startingEnvironment(); err != nil {
+		c.log.Warnf("Failed to set up the apps' environment: %v", err)
+	}
+	if err := c.recoverSecretEnv(); err != nil {
+		c.log.Warnf("Failed to read the container's secrets, its apps will be restarted without them: %v", err)
+	}
+	if err := c.startingLogs(); err != nil {
+		c.log.Warnf("Failed to capture the apps' output: %v", err)
+	}
+	if c.hasConsole() {
+		c.log.Warn("The container's console was lost along with the manager")
+	}
+
+	c.mutex.Lock()
+	c.cgroup = cgroup
+	c.throttled = state.Throttled
+	if kills, err := cgroup.OOMKills(); err == nil {
+		c.cgroupOOMKills = kills
+	}
+	c.mutex.Unlock()
+	c.recoverCPUs(state.CPUs)
+	c.watchOOM()
+	c.recoverEndpoint(state)
+
+	// Apps which were waiting to be restarted are seen to have exited by the
+	// wait loop, and restarted then.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.initdClient = initdClient
+	switch state.State {
+	case STOPPED:
+		c.state = STOPPED
+		c.stopping = true
+	case EXITED:
+		c.state = EXITED
+		close(c.waitch)
+	default:
+		c.state = RUNNING
+		for _, a := range c.apps {
+			if a.state == RESTARTING {
+				a.state = RUNNING
+			}
+		}
+	}
+	return true
+}
+
+// recoverEndpoint reserves the container's addresses on the container network
+// again and republishes its ports. The container keeps its interface if they
+// can't be, but they aren't tracked.
+func (c *Container) recoverEndpoint(state *persistedState) {
+	if state.Address == "" {
+		return
+	}
+	if c.manager.network == nil {
+		c.log.Warn("The container is connected to the container network, which is no longer configured")
+		return
+	}
+	endpoint, err := c.reserveEndpoint(&state.checkpointState)
+	if err == nil {
+		err = c.manager.network.Attach(endpoint)
+	}
+	if err != nil {
+		c.log.Warnf("Failed to recover the container's connection to the network: %v", err)
+		return
+	}
+	c.mutex.Lock()
+	c.endpoint = endpoint
+	c.mutex.Unlock()
+	if err := c.publishPorts(endpoint); err != nil {
+		c.log.Warnf("Failed to publish the container's ports: %v", err)
+	}
+}
+
+// recoverExited marks a container whose processes are gone as exited, killing
+// anything left in its cgroup and removing its interface on the host.
+func (c *Container) recoverExited(state *persistedState) {
+	if state.Cgroup != "" {
+		if err := destroyCgroup(cgroups.Open(state.Cgroup)); err != nil {
+			c.log.Warnf("Failed to remove the container's cgroup: %v", err)
+		}
+	}
+	if state.HostInterface != "" && c.manager.network != nil {
+		c.manager.network.Teardown(&network.Endpoint{HostInterface: state.HostInterface})
+	}
+
+	for _, a := range c.apps {
+		switch a.state {
+		case RUNNING, RESTARTING, STARTING:
+			a.state = EXITED
+		}
+	}
+	if state.State != EXITED || c.exitTime.IsZero() {
+		c.exitTime = time.Now()
+	}
+	c.state = EXITED
+	close(c.waitch)
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/util/cgroups"
+)
+
+// Stats is a snapshot of the resources used by a container. The counters are
+// cumulative, so rates are calculated by comparing snapshots.
+type Stats struct {
+	Time time.Time
+
+	// CPUUsage is the total CPU time used by the container, in nanoseconds.
+	CPUUsage int64
+
+	// MemoryUsage and MemoryLimit are in bytes.
+	MemoryUsage int64
+	MemoryLimit int64
+
+	// OOMKills is the number of processes killed for exceeding the memory
+	// limit.
+	OOMKills int64
+
+	// BlkioRead and BlkioWrite are the bytes read from and written to block
+	// devices.
+	BlkioRead  int64
+	BlkioWrite int64
+
+	// DiskUsage is the disk space the container has written to its root
+	// filesystem and DiskLimit the most it may write, in bytes. Both are 0 if
+	// the container has no disk limit.
+	DiskUsage int64
+	DiskLimit int64
+
+	// IngressLimit and EgressLimit cap the rate of the container's traffic on
+	// the container network, in bytes per second, or are 0 if it is unlimited.
+	IngressLimit int64
+	EgressLimit  int64
+
+	// Pressure is how much the container's processes were stalled waiting on
+	// each of the cgroups.PressureResources, for those the kernel reports.
+	Pressure map[string]*cgroups.Pressure
+
+	Networks []NetworkStats
+}
+
+// NetworkStats are the counters for one of the network interfaces within the
+// container's network namespace.
+type NetworkStats struct {
+	Interface string
+	RxBytes   int64
+	RxPackets int64
+	TxBytes   int64
+	TxPackets int64
+}
+
+// Stats reads the container's resource usage from its cgroup and the network
+// interfaces in its namespace.
+func (c *Container) Stats() (*Stats, error) {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return nil, fmt.Errorf("the container's cgroup has not been set up")
+	}
+
+	stats := &Stats{Time: time.Now()}
+	var err error
+	if stats.CPUUsage, err = cgroup.CPUUsed(); err != nil {
+		return nil, fmt.Errorf("failed to read the cpu usage: %v", err)
+	}
+	if stats.MemoryUsage, err = cgroup.MemoryUsed(); err != nil {
+		return nil, fmt.Errorf("failed to read the memory usage: %v", err)
+	}
+	if stats.MemoryLimit, err = cgroup.MemoryLimit(); err != nil {
+		return nil, fmt.Errorf("failed to read the memory limit: %v", err)
+	}
+	if stats.BlkioRead, stats.BlkioWrite, err = cgroup.BlkioUsed(); err != nil {
+		return nil, fmt.Errorf("failed to read the blkio usage: %v", err)
+	}
+	if stats.DiskUsage, stats.DiskLimit, err = c.DiskUsage(); err != nil {
+		return nil, fmt.Errorf("failed to read the disk usage: %v", err)
+	}
+	stats.OOMKills = c.OOMKills()
+	stats.IngressLimit, stats.EgressLimit = c.Bandwidth()
+	for _, resource := range cgroups.PressureResources {
+		p, err := cgroup.Pressure(resource)
+		if err == cgroups.ErrNoPressure {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read the %s pressure: %v", resource, err)
+		}
+		if stats.Pressure == nil {
+			stats.Pressure = make(map[string]*cgroups.Pressure)
+		}
+		stats.Pressure[resource] = p
+	}
+
+	if pid := c.Pid(); pid != 0 {
+		stats.Networks, err = networkStats(fmt.Sprintf("/proc/%d/net/dev", pid))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the network usage: %v", err)
+		}
+	}
+	return stats, nil
+}
+
+// networkStats parses the counters for each interface from the net/dev file.
+// After two header lines, each line is the interface name followed by its
+// receive and then transmit counters, beginning with bytes and packets.
+func networkStats(path string) ([]NetworkStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var networks []NetworkStats
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 10 {
+			continue
+		}
+
+		var values [4]int64
+		for i, field := range []string{fields[0], fields[1], fields[8], fields[9]} {
+			if values[i], err = strconv.ParseInt(field, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid line in %s: %q", path, scanner.Text())
+			}
+		}
+		networks = append(networks, NetworkStats{
+			Interface: strings.TrimSpace(parts[0]),
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			TxBytes:   values[2],
+			TxPackets: values[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apcera/kurma/util/ns"
+	"github.com/apcera/kurma/util/sysctl"
+)
+
+// validateSysctls checks that each of the sysctls is one which is set per
+// namespace, so setting it within a container doesn't change the host.
+func validateSysctls(sysctls map[string]string) error {
+	for name := range sysctls {
+		if _, err := sysctl.Path(name); err != nil {
+			return err
+		}
+		if !sysctl.Namespaced(name) {
+			return fmt.Errorf("the sysctl %s isn't namespaced, so can't be set for a container", name)
+		}
+	}
+	return nil
+}
+
+// startingSysctls sets the container's sysctls from within its network and IPC
+// namespaces. It comes after the container is connected to the network, so the
+// settings of its interfaces can be set.
+func (c *Container) startingSysctls() error {
+	if len(c.sysctls) == 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	networkNamespace, ipcNamespace := c.networkNamespace, c.ipcNamespace
+	c.mutex.Unlock()
+
+	names := make([]string, 0, len(c.sysctls))
+	for name := range c.sysctls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var enterNet, enterIPC bool
+	for _, name := range names {
+		if strings.HasPrefix(name, "net.") {
+			if !networkNamespace {
+				return fmt.Errorf("the sysctl %s requires the container to have its own network namespace", name)
+			}
+			enterNet = true
+		} else {
+			if !ipcNamespace {
+				return fmt.Errorf("the sysctl %s requires the container to have its own IPC namespace", name)
+			}
+			enterIPC = true
+		}
+	}
+	var namespaces []ns.Namespace
+	if enterNet {
+		namespaces = append(namespaces, ns.Net)
+	}
+	if enterIPC {
+		namespaces = append(namespaces, ns.IPC)
+	}
+
+	c.log.Debugf("Setting %d sysctls.", len(names))
+	return ns.Run(c.Pid(), namespaces, func() error {
+		for _, name := range names {
+			if err := sysctl.Set(name, c.sysctls[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
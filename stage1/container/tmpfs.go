@@ -0,0 +1,68 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/apcera/kurma/stage3/client"
+)
+
+// TmpfsMount is a tmpfs filesystem mounted into a container at the specified
+// path, giving it a scratch area which is discarded when the container is
+// destroyed. A Size of 0 leaves it at the kernel's default of half of the
+// host's memory.
+type TmpfsMount struct {
+	Path string
+	Size int64
+}
+
+// validateTmpfs checks that the tmpfs mounts are each at a distinct absolute
+// path within the container.
+func validateTmpfs(mounts []*TmpfsMount) error {
+	paths := make(map[string]bool)
+	for _, t := range mounts {
+		if !filepath.IsAbs(t.Path) {
+			return fmt.Errorf("the tmpfs path %q must be absolute", t.Path)
+		}
+		path := filepath.Clean(t.Path)
+		if path == "/" {
+			return fmt.Errorf("a tmpfs cannot be mounted over the container's root")
+		}
+		if paths[path] {
+			return fmt.Errorf("multiple tmpfs mounts are specified for %s", path)
+		}
+		if t.Size < 0 {
+			return fmt.Errorf("the size of the tmpfs at %s must not be negative", path)
+		}
+		paths[path] = true
+	}
+	return nil
+}
+
+// addTmpfsMounts configures the spec to mount the container's tmpfs
+// filesystems.
+func (c *Container) addTmpfsMounts(spec *LaunchSpec) error {
+	for _, t := range c.tmpfs {
+		podPath, err := c.ensureContainerPathExists(t.Path)
+		if err != nil {
+			return err
+		}
+		data := "mode=1777"
+		if t.Size > 0 {
+			data += fmt.Sprintf(",size=%d", t.Size)
+		}
+		spec.MountPoints = append(spec.MountPoints, &client.MountPoint{
+			Source:      "tmpfs",
+			Destination: strings.Replace(podPath, c.stage3Path(), client.DefaultChrootPath, 1),
+			FSType:      "tmpfs",
+			Flags:       syscall.MS_NOSUID | syscall.MS_NODEV,
+			Data:        data,
+		})
+		c.addMount("tmpfs", t.Path, false)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"sync"
+
+	kschema "github.com/apcera/kurma/schema"
+)
+
+// DefaultIDBlockSize is the number of uids and gids mapped into each
+// container's user namespace when no block size is given.
+const DefaultIDBlockSize = 65536
+
+// IDRange is the range of host uids and gids which are allocated to the
+// containers run in user namespaces. Each container is given its own block of
+// BlockSize IDs from the range, which are mapped to the IDs from 0 within it,
+// so that root within a container is an unprivileged user on the host.
+type IDRange struct {
+	UIDStart  int
+	GIDStart  int
+	Length    int
+	BlockSize int
+}
+
+// idMapping is the block of host IDs a container's user namespace is mapped
+// to.
+type idMapping struct {
+	UID  int `json:"uid"`
+	GID  int `json:"gid"`
+	Size int `json:"size"`
+}
+
+// uidmap returns the contents of the container's uid_map file.
+func (m *idMapping) uidmap() string {
+	return fmt.Sprintf("0 %d %d\n", m.UID, m.Size)
+}
+
+// gidmap returns the contents of the container's gid_map file.
+func (m *idMapping) gidmap() string {
+	return fmt.Sprintf("0 %d %d\n", m.GID, m.Size)
+}
+
+// hostUID returns the host uid of the uid within the container.
+func (m *idMapping) hostUID(uid int) (int, error) {
+	if uid < 0 || uid >= m.Size {
+		return -1, fmt.Errorf("uid %d is outside of the container's user namespace", uid)
+	}
+	return m.UID + uid, nil
+}
+
+// hostGID returns the host gid of the gid within the container.
+func (m *idMapping) hostGID(gid int) (int, error) {
+	if gid < 0 || gid >= m.Size {
+		return -1, fmt.Errorf("gid %d is outside of the container's user namespace", gid)
+	}
+	return m.GID + gid, nil
+}
+
+// overflowID is the ID the kernel shows within a user namespace for host IDs
+// which aren't mapped into it.
+const overflowID = 65534
+
+// containerUID returns the uid within the container of the host uid, which is
+// the overflow uid if it isn't mapped into the container.
+func (m *idMapping) containerUID(uid int) (int, error) {
+	if uid < m.UID || uid >= m.UID+m.Size {
+		return overflowID, nil
+	}
+	return uid - m.UID, nil
+}
+
+// containerGID returns the gid within the container of the host gid, which is
+// the overflow gid if it isn't mapped into the container.
+func (m *idMapping) containerGID(gid int) (int, error) {
+	if gid < m.GID || gid >= m.GID+m.Size {
+		return overflowID, nil
+	}
+	return gid - m.GID, nil
+}
+
+// idAllocator hands out the blocks of an IDRange to containers.
+type idAllocator struct {
+	idRange IDRange
+	used    []bool
+	mutex   sync.Mutex
+}
+
+func newIDAllocator(r IDRange) (*idAllocator, error) {
+	if r.BlockSize == 0 {
+		r.BlockSize = DefaultIDBlockSize
+	}
+	if r.UIDStart <= 0 || r.GIDStart <= 0 {
+		return nil, fmt.Errorf("the user namespace ID range must not include root")
+	}
+	if r.BlockSize < 0 || r.Length < r.BlockSize {
+		return nil, fmt.Errorf("the user namespace ID range must hold at least one block of %d IDs", r.BlockSize)
+	}
+	return &idAllocator{
+		idRange: r,
+		used:    make([]bool, r.Length/r.BlockSize),
+	}, nil
+}
+
+// allocate returns the first unused block of IDs.
+func (a *idAllocator) allocate() (*idMapping, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for i, used := range a.used {
+		if !used {
+			a.used[i] = true
+			return a.mapping(i), nil
+		}
+	}
+	return nil, fmt.Errorf("all %d user namespace ID blocks are in use", len(a.used))
+}
+
+// reserve marks the block of IDs as used, such as for a container restored
+// from a checkpoint whose files are already owned by them.
+func (a *idAllocator) reserve(m *idMapping) error {
+	i, err := a.block(m)
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.used[i] {
+		return fmt.Errorf("the user namespace ID block for uid %d is already in use", m.UID)
+	}
+	a.used[i] = true
+	return nil
+}
+
+// release returns the block of IDs so it can be allocated again.
+func (a *idAllocator) release(m *idMapping) {
+	i, err := a.block(m)
+	if err != nil {
+		return
+	}
+	a.mutex.Lock()
+	a.used[i] = false
+	a.mutex.Unlock()
+}
+
+// mapping returns the mapping for the block with the index.
+func (a *idAllocator) mapping(i int) *idMapping {
+	return &idMapping{
+		UID:  a.idRange.UIDStart + i*a.idRange.BlockSize,
+		GID:  a.idRange.GIDStart + i*a.idRange.BlockSize,
+		Size: a.idRange.BlockSize,
+	}
+}
+
+// block returns the index of the block the mapping refers to.
+func (a *idAllocator) block(m *idMapping) (int, error) {
+	i := (m.UID - a.idRange.UIDStart) / a.idRange.BlockSize
+	if m.UID < a.idRange.UIDStart || i >= len(a.used) || *a.mapping(i) != *m {
+		return -1, fmt.Errorf("uid %d is not the start of a block of the user namespace ID range", m.UID)
+	}
+	return i, nil
+}
+
+// usesUserNamespace returns whether the container is run in its own user
+// namespace. Containers are when the manager has been given an ID range, unless
+// their namespaces isolator leaves out the user namespace. Host privileged
+// containers never are, since they need to act as root on the host, and nor
+// are containers run in VMs, which are already confined by the hypervisor.
+func (c *Container) usesUserNamespace() bool {
+	if c.manager.userNamespaces == nil || c.isHostPrivileged() || c.Isolation() == IsolationVM {
+		return false
+	}
+	if iso := c.isolator(kschema.LinuxNamespacesName); iso != nil {
+		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok {
+			return niso.User()
+		}
+	}
+	return true
+}
+
+// chownToRoot changes the owner of the path to root within the container's user
+// namespace, if it has one, so that it is writable from within the container.
+func (c *Container) chownToRoot(path string) error {
+	if c.idMapping == nil {
+		return nil
+	}
+	return chowns([]string{path}, c.idMapping.UID, c.idMapping.GID)
+}
@@ -28,6 +28,22 @@ func (c *Container) stage3Path() string {
 	return filepath.Join(c.directory, "rootfs")
 }
 
+func (c *Container) appStdinPath() string {
+	return filepath.Join(c.stage3Path(), "app.stdin")
+}
+
+func (c *Container) appStdoutPath() string {
+	return filepath.Join(c.stage3Path(), "app.stdout")
+}
+
+func (c *Container) appStderrPath() string {
+	return filepath.Join(c.stage3Path(), "app.stderr")
+}
+
+func (c *Container) logPath() string {
+	return filepath.Join(c.directory, "output.log")
+}
+
 func (c *Container) socketPath() string {
 	return filepath.Join(c.directory, "socket")
 }
@@ -100,6 +116,16 @@ func unmountDirectories(path string) error {
 	return nil
 }
 
+// RemoveDirectory unmounts anything still mounted beneath the directory and
+// removes it. It is for cleaning up the container directory of a manager whose
+// process exited without destroying its containers.
+func RemoveDirectory(path string) error {
+	if err := unmountDirectories(path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
 // ensureContainerPathExists ensures that the specified path within the
 // container exists. It will create any missing directories and walk the
 // filesystem to ensure any portions that are symlinks are resolved. It returns
@@ -124,6 +150,9 @@ func (c *Container) ensureContainerPathExists(name string) (string, error) {
 				if err := os.Mkdir(resolvedPath, os.FileMode(0755)); err != nil {
 					return "", err
 				}
+				if err := c.chownToRoot(resolvedPath); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", err
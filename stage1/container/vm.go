@@ -0,0 +1,160 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/vm"
+	client3 "github.com/apcera/kurma/stage3/client"
+	"github.com/appc/spec/schema"
+)
+
+// Isolation is how a container's apps are isolated from the host.
+type Isolation string
+
+const (
+	// IsolationNamespaces runs the apps directly on the host's kernel, within
+	// the container's namespaces and cgroup.
+	IsolationNamespaces = Isolation("namespaces")
+
+	// IsolationVM runs the apps within a virtual machine of their own, with
+	// the container's root filesystem shared into it, for apps which aren't
+	// trusted with the host's kernel.
+	IsolationVM = Isolation("vm")
+)
+
+// ParseIsolation returns the Isolation matching the provided string. An empty
+// string is treated as IsolationNamespaces.
+func ParseIsolation(s string) (Isolation, error) {
+	switch i := Isolation(s); i {
+	case "":
+		return IsolationNamespaces, nil
+	case IsolationNamespaces, IsolationVM:
+		return i, nil
+	default:
+		return "", fmt.Errorf("unknown isolation %q", s)
+	}
+}
+
+// Runtime returns the name of the runtime running the container's apps.
+func (c *Container) Runtime() string {
+	if c.runtime == nil {
+		return NativeRuntime
+	}
+	return c.runtime.Name()
+}
+
+// Isolation returns how the container's apps are isolated from the host.
+func (c *Container) Isolation() Isolation {
+	if c.runtime == nil {
+		return IsolationNamespaces
+	}
+	return c.runtime.Isolation()
+}
+
+// vmRuntime runs the apps within a VM of their own, in place of the stage3
+// initd. The VM's qemu process is the only process of the container on the
+// host.
+type vmRuntime struct {
+	config *vm.Config
+
+	// volumes is whether the apps' mount points are mounted as volumes, which
+	// can't be passed into a VM.
+	volumes bool
+}
+
+func (r *vmRuntime) Name() string {
+	return VMRuntime
+}
+
+func (r *vmRuntime) Isolation() Isolation {
+	return IsolationVM
+}
+
+// Validate ensures the container needs nothing from the host besides its own
+// root filesystem, which is all a VM is given, and doesn't need to be entered.
+func (r *vmRuntime) Validate(opts *CreateOptions, pod *schema.PodManifest) error {
+	switch {
+	case opts.Tty || opts.Stdin:
+		return fmt.Errorf("containers run in VMs can't be given a console or stdin")
+	case opts.Privileged:
+		return fmt.Errorf("containers run in VMs can't be privileged")
+	case opts.SecurityLabel != "":
+		return fmt.Errorf("containers run in VMs can't be given a security label")
+	case len(opts.Volumes) > 0 || len(opts.Tmpfs) > 0:
+		return fmt.Errorf("containers run in VMs can't have volumes or tmpfs mounts")
+	case len(opts.Sysctls) > 0:
+		return fmt.Errorf("containers run in VMs can't be given sysctls")
+	}
+	for _, s := range opts.Secrets {
+		if s.Path != "" {
+			return fmt.Errorf("containers run in VMs can only be given secrets as environment variables")
+		}
+	}
+	for _, ra := range pod.Apps {
+		a := &app{name: ra.Name, app: ra.App}
+		if a.hasConsole() {
+			return fmt.Errorf("containers run in VMs can't be given a console")
+		}
+		if iso := ra.App.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
+			if piso, ok := iso.Value().(*kschema.HostPrivileged); ok && bool(*piso) {
+				return fmt.Errorf("containers run in VMs can't be host privileged")
+			}
+		}
+		if ra.App.Isolators.GetByName(kschema.HugepagesName) != nil {
+			return fmt.Errorf("containers run in VMs can't be given hugepages")
+		}
+		if r.volumes && len(ra.App.MountPoints) > 0 {
+			return fmt.Errorf("app %q can't have mount points when run in a VM", ra.Name)
+		}
+	}
+	return nil
+}
+
+// Launch boots the VM, whose agent is used to run the apps. When the container
+// is to have its own network namespace, qemu is run within it, and forwards
+// the container's ports to the VM.
+func (r *vmRuntime) Launch(spec *LaunchSpec) (client3.Client, error) {
+	if len(spec.MountPoints) > 0 || spec.NewUserNamespace || spec.HostPrivileged {
+		return nil, fmt.Errorf("containers run in VMs can't have mounts, user namespaces, or host privileges")
+	}
+
+	var ports []vm.Port
+	seen := make(map[string]bool)
+	for _, p := range spec.Ports {
+		key := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, vm.Port{Protocol: p.Protocol, Port: p.ContainerPort})
+	}
+
+	machine, err := vm.Boot(r.config, &vm.BootOptions{
+		Root:                spec.Root,
+		ReadOnly:            spec.ReadOnly,
+		Directory:           filepath.Join(spec.Directory, "vm"),
+		Hostname:            spec.Name,
+		Ports:               ports,
+		NewNetworkNamespace: spec.NewNetworkNamespace,
+		Taskfiles:           spec.Cgroup.TasksFiles(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to boot the VM: %v", err)
+	}
+	return machine, nil
+}
+
+// Recover isn't supported, since qemu doesn't connect to the agent's socket
+// again once the connection it made as the VM booted is lost.
+func (r *vmRuntime) Recover(spec *LaunchSpec) (client3.Client, error) {
+	return nil, fmt.Errorf("the agents of VMs can't be reconnected to")
+}
+
+// Exec isn't supported, since nothing on the host can join the VM.
+func (r *vmRuntime) Exec(spec *ExecSpec) (int, error) {
+	return -1, fmt.Errorf("commands can't be run within containers run in VMs")
+}
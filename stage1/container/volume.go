@@ -0,0 +1,177 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/appc/spec/schema/types"
+)
+
+// VolumeMount references a named volume that should be bind mounted into a
+// container at the specified path.
+type VolumeMount struct {
+	Name     string
+	Path     string
+	ReadOnly bool
+}
+
+// CreateVolume creates a new named volume within the volume directory. Volumes
+// persist until they're deleted, so their data remains available across the
+// containers which mount them.
+func (manager *Manager) CreateVolume(name string) error {
+	volumePath, err := manager.volumePath(name)
+	if err != nil {
+		return err
+	}
+
+	manager.volumeLock.Lock()
+	defer manager.volumeLock.Unlock()
+
+	if err := os.Mkdir(volumePath, os.FileMode(0755)); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("volume %q already exists", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Volumes returns the names of the volumes which exist on the host.
+func (manager *Manager) Volumes() ([]string, error) {
+	if manager.volumeDirectory == "" {
+		return nil, fmt.Errorf("no volume directory is configured")
+	}
+
+	manager.volumeLock.Lock()
+	defer manager.volumeLock.Unlock()
+
+	fis, err := ioutil.ReadDir(manager.volumeDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() {
+			volumes = append(volumes, fi.Name())
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+// DeleteVolume removes the named volume and all of its data. A volume cannot be
+// deleted while a container is using it.
+func (manager *Manager) DeleteVolume(name string) error {
+	volumePath, err := manager.volumePath(name)
+	if err != nil {
+		return err
+	}
+
+	// the lock is held across the check, as containers are created holding
+	// it until they're registered and seen to be using their volumes
+	manager.volumeLock.Lock()
+	defer manager.volumeLock.Unlock()
+
+	for _, c := range manager.Containers() {
+		if c.usesVolume(name) {
+			return fmt.Errorf("volume %q is in use by container %s", name, c.UUID())
+		}
+	}
+
+	if _, err := os.Stat(volumePath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("volume %q does not exist", name)
+		}
+		return err
+	}
+	return os.RemoveAll(volumePath)
+}
+
+// validateVolumes ensures the volumes requested for a container exist and can
+// be mounted.
+func (manager *Manager) validateVolumes(volumes []*VolumeMount) error {
+	names := make(map[string]bool)
+	paths := make(map[string]bool)
+	for _, v := range volumes {
+		volumePath, err := manager.volumePath(v.Name)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(v.Path) {
+			return fmt.Errorf("the path for volume %q must be absolute", v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("volume %q is specified multiple times", v.Name)
+		}
+		if paths[filepath.Clean(v.Path)] {
+			return fmt.Errorf("multiple volumes are specified for %s", v.Path)
+		}
+		names[v.Name] = true
+		paths[filepath.Clean(v.Path)] = true
+
+		fi, err := os.Stat(volumePath)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("volume %q does not exist", v.Name)
+		} else if err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return fmt.Errorf("volume %q is not a directory", v.Name)
+		}
+	}
+	return nil
+}
+
+// existingVolumePath returns the absolute path on the host to the named volume,
+// which must already exist.
+func (manager *Manager) existingVolumePath(name string) (string, error) {
+	volumePath, err := manager.volumePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	manager.volumeLock.Lock()
+	defer manager.volumeLock.Unlock()
+
+	if _, err := os.Stat(volumePath); os.IsNotExist(err) {
+		return "", fmt.Errorf("volume %q does not exist", name)
+	} else if err != nil {
+		return "", err
+	}
+	return volumePath, nil
+}
+
+// volumePath returns the absolute path on the host to the named volume, without
+// checking if it exists.
+func (manager *Manager) volumePath(name string) (string, error) {
+	if manager.volumeDirectory == "" {
+		return "", fmt.Errorf("no volume directory is configured")
+	}
+	if !types.ValidACName.MatchString(name) {
+		return "", fmt.Errorf("invalid characters present in volume name")
+	}
+	return filepath.Join(manager.volumeDirectory, name), nil
+}
+
+// usesVolume returns whether the container mounts the named volume, either by
+// request or through one of its apps' mount points.
+func (c *Container) usesVolume(name string) bool {
+	for _, v := range c.volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	for _, a := range c.apps {
+		for _, mp := range a.app.MountPoints {
+			if mp.Name.String() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,1871 @@
+// Code generated by protoc-gen-go.
+// source: stage1/cri/api.proto
+// DO NOT EDIT!
+
+/*
+Package cri is a generated protocol buffer package.
+
+It is generated from these files:
+	stage1/cri/api.proto
+
+It has these top-level messages:
+	VersionRequest
+	VersionResponse
+	PortMapping
+	Mount
+	PodSandboxMetadata
+	PodSandboxConfig
+	RunPodSandboxRequest
+	RunPodSandboxResponse
+	StopPodSandboxRequest
+	StopPodSandboxResponse
+	RemovePodSandboxRequest
+	RemovePodSandboxResponse
+	PodSandboxStatusRequest
+	PodIP
+	PodSandboxNetworkStatus
+	PodSandboxStatus
+	PodSandboxStatusResponse
+	PodSandboxStateValue
+	PodSandboxFilter
+	ListPodSandboxRequest
+	PodSandbox
+	ListPodSandboxResponse
+	ImageSpec
+	KeyValue
+	ContainerMetadata
+	ContainerConfig
+	CreateContainerRequest
+	CreateContainerResponse
+	StartContainerRequest
+	StartContainerResponse
+	StopContainerRequest
+	StopContainerResponse
+	RemoveContainerRequest
+	RemoveContainerResponse
+	ContainerStateValue
+	ContainerFilter
+	ListContainersRequest
+	Container
+	ListContainersResponse
+	ContainerStatusRequest
+	ContainerStatus
+	ContainerStatusResponse
+	ExecSyncRequest
+	ExecSyncResponse
+	NetworkConfig
+	RuntimeConfig
+	UpdateRuntimeConfigRequest
+	UpdateRuntimeConfigResponse
+	RuntimeCondition
+	RuntimeStatus
+	StatusRequest
+	StatusResponse
+	ImageFilter
+	ListImagesRequest
+	Int64Value
+	Image
+	ListImagesResponse
+	ImageStatusRequest
+	ImageStatusResponse
+	AuthConfig
+	PullImageRequest
+	PullImageResponse
+	RemoveImageRequest
+	RemoveImageResponse
+	ImageFsInfoRequest
+	FilesystemIdentifier
+	UInt64Value
+	FilesystemUsage
+	ImageFsInfoResponse
+*/
+package cri
+
+import proto "github.com/golang/protobuf/proto"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+
+type Protocol int32
+
+const (
+	Protocol_TCP  Protocol = 0
+	Protocol_UDP  Protocol = 1
+	Protocol_SCTP Protocol = 2
+)
+
+var Protocol_name = map[int32]string{
+	0: "TCP",
+	1: "UDP",
+	2: "SCTP",
+}
+var Protocol_value = map[string]int32{
+	"TCP":  0,
+	"UDP":  1,
+	"SCTP": 2,
+}
+
+func (x Protocol) String() string {
+	return proto.EnumName(Protocol_name, int32(x))
+}
+
+type PodSandboxState int32
+
+const (
+	PodSandboxState_SANDBOX_READY    PodSandboxState = 0
+	PodSandboxState_SANDBOX_NOTREADY PodSandboxState = 1
+)
+
+var PodSandboxState_name = map[int32]string{
+	0: "SANDBOX_READY",
+	1: "SANDBOX_NOTREADY",
+}
+var PodSandboxState_value = map[string]int32{
+	"SANDBOX_READY":    0,
+	"SANDBOX_NOTREADY": 1,
+}
+
+func (x PodSandboxState) String() string {
+	return proto.EnumName(PodSandboxState_name, int32(x))
+}
+
+type ContainerState int32
+
+const (
+	ContainerState_CONTAINER_CREATED ContainerState = 0
+	ContainerState_CONTAINER_RUNNING ContainerState = 1
+	ContainerState_CONTAINER_EXITED  ContainerState = 2
+	ContainerState_CONTAINER_UNKNOWN ContainerState = 3
+)
+
+var ContainerState_name = map[int32]string{
+	0: "CONTAINER_CREATED",
+	1: "CONTAINER_RUNNING",
+	2: "CONTAINER_EXITED",
+	3: "CONTAINER_UNKNOWN",
+}
+var ContainerState_value = map[string]int32{
+	"CONTAINER_CREATED": 0,
+	"CONTAINER_RUNNING": 1,
+	"CONTAINER_EXITED":  2,
+	"CONTAINER_UNKNOWN": 3,
+}
+
+func (x ContainerState) String() string {
+	return proto.EnumName(ContainerState_name, int32(x))
+}
+
+type VersionRequest struct {
+	Version string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *VersionRequest) Reset()         { *m = VersionRequest{} }
+func (m *VersionRequest) String() string { return proto.CompactTextString(m) }
+func (*VersionRequest) ProtoMessage()    {}
+
+type VersionResponse struct {
+	Version           string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	RuntimeName       string `protobuf:"bytes,2,opt,name=runtime_name" json:"runtime_name,omitempty"`
+	RuntimeVersion    string `protobuf:"bytes,3,opt,name=runtime_version" json:"runtime_version,omitempty"`
+	RuntimeApiVersion string `protobuf:"bytes,4,opt,name=runtime_api_version" json:"runtime_api_version,omitempty"`
+}
+
+func (m *VersionResponse) Reset()         { *m = VersionResponse{} }
+func (m *VersionResponse) String() string { return proto.CompactTextString(m) }
+func (*VersionResponse) ProtoMessage()    {}
+
+type PortMapping struct {
+	Protocol      Protocol `protobuf:"varint,1,opt,name=protocol,enum=runtime.v1.Protocol" json:"protocol,omitempty"`
+	ContainerPort int32    `protobuf:"varint,2,opt,name=container_port" json:"container_port,omitempty"`
+	HostPort      int32    `protobuf:"varint,3,opt,name=host_port" json:"host_port,omitempty"`
+	HostIp        string   `protobuf:"bytes,4,opt,name=host_ip" json:"host_ip,omitempty"`
+}
+
+func (m *PortMapping) Reset()         { *m = PortMapping{} }
+func (m *PortMapping) String() string { return proto.CompactTextString(m) }
+func (*PortMapping) ProtoMessage()    {}
+
+type Mount struct {
+	ContainerPath  string `protobuf:"bytes,1,opt,name=container_path" json:"container_path,omitempty"`
+	HostPath       string `protobuf:"bytes,2,opt,name=host_path" json:"host_path,omitempty"`
+	Readonly       bool   `protobuf:"varint,3,opt,name=readonly" json:"readonly,omitempty"`
+	SelinuxRelabel bool   `protobuf:"varint,4,opt,name=selinux_relabel" json:"selinux_relabel,omitempty"`
+}
+
+func (m *Mount) Reset()         { *m = Mount{} }
+func (m *Mount) String() string { return proto.CompactTextString(m) }
+func (*Mount) ProtoMessage()    {}
+
+type PodSandboxMetadata struct {
+	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Uid       string `protobuf:"bytes,2,opt,name=uid" json:"uid,omitempty"`
+	Namespace string `protobuf:"bytes,3,opt,name=namespace" json:"namespace,omitempty"`
+	Attempt   uint32 `protobuf:"varint,4,opt,name=attempt" json:"attempt,omitempty"`
+}
+
+func (m *PodSandboxMetadata) Reset()         { *m = PodSandboxMetadata{} }
+func (m *PodSandboxMetadata) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxMetadata) ProtoMessage()    {}
+
+type PodSandboxConfig struct {
+	Metadata     *PodSandboxMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Hostname     string              `protobuf:"bytes,2,opt,name=hostname" json:"hostname,omitempty"`
+	LogDirectory string              `protobuf:"bytes,3,opt,name=log_directory" json:"log_directory,omitempty"`
+	PortMappings []*PortMapping      `protobuf:"bytes,5,rep,name=port_mappings" json:"port_mappings,omitempty"`
+	Labels       map[string]string   `protobuf:"bytes,6,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations  map[string]string   `protobuf:"bytes,7,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PodSandboxConfig) Reset()         { *m = PodSandboxConfig{} }
+func (m *PodSandboxConfig) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxConfig) ProtoMessage()    {}
+
+func (m *PodSandboxConfig) GetMetadata() *PodSandboxMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *PodSandboxConfig) GetPortMappings() []*PortMapping {
+	if m != nil {
+		return m.PortMappings
+	}
+	return nil
+}
+
+func (m *PodSandboxConfig) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *PodSandboxConfig) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type RunPodSandboxRequest struct {
+	Config         *PodSandboxConfig `protobuf:"bytes,1,opt,name=config" json:"config,omitempty"`
+	RuntimeHandler string            `protobuf:"bytes,2,opt,name=runtime_handler" json:"runtime_handler,omitempty"`
+}
+
+func (m *RunPodSandboxRequest) Reset()         { *m = RunPodSandboxRequest{} }
+func (m *RunPodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*RunPodSandboxRequest) ProtoMessage()    {}
+
+func (m *RunPodSandboxRequest) GetConfig() *PodSandboxConfig {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+type RunPodSandboxResponse struct {
+	PodSandboxId string `protobuf:"bytes,1,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+}
+
+func (m *RunPodSandboxResponse) Reset()         { *m = RunPodSandboxResponse{} }
+func (m *RunPodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*RunPodSandboxResponse) ProtoMessage()    {}
+
+type StopPodSandboxRequest struct {
+	PodSandboxId string `protobuf:"bytes,1,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+}
+
+func (m *StopPodSandboxRequest) Reset()         { *m = StopPodSandboxRequest{} }
+func (m *StopPodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*StopPodSandboxRequest) ProtoMessage()    {}
+
+type StopPodSandboxResponse struct {
+}
+
+func (m *StopPodSandboxResponse) Reset()         { *m = StopPodSandboxResponse{} }
+func (m *StopPodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*StopPodSandboxResponse) ProtoMessage()    {}
+
+type RemovePodSandboxRequest struct {
+	PodSandboxId string `protobuf:"bytes,1,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+}
+
+func (m *RemovePodSandboxRequest) Reset()         { *m = RemovePodSandboxRequest{} }
+func (m *RemovePodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*RemovePodSandboxRequest) ProtoMessage()    {}
+
+type RemovePodSandboxResponse struct {
+}
+
+func (m *RemovePodSandboxResponse) Reset()         { *m = RemovePodSandboxResponse{} }
+func (m *RemovePodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*RemovePodSandboxResponse) ProtoMessage()    {}
+
+type PodSandboxStatusRequest struct {
+	PodSandboxId string `protobuf:"bytes,1,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+	Verbose      bool   `protobuf:"varint,2,opt,name=verbose" json:"verbose,omitempty"`
+}
+
+func (m *PodSandboxStatusRequest) Reset()         { *m = PodSandboxStatusRequest{} }
+func (m *PodSandboxStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatusRequest) ProtoMessage()    {}
+
+type PodIP struct {
+	Ip string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+}
+
+func (m *PodIP) Reset()         { *m = PodIP{} }
+func (m *PodIP) String() string { return proto.CompactTextString(m) }
+func (*PodIP) ProtoMessage()    {}
+
+type PodSandboxNetworkStatus struct {
+	Ip            string   `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	AdditionalIps []*PodIP `protobuf:"bytes,2,rep,name=additional_ips" json:"additional_ips,omitempty"`
+}
+
+func (m *PodSandboxNetworkStatus) Reset()         { *m = PodSandboxNetworkStatus{} }
+func (m *PodSandboxNetworkStatus) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxNetworkStatus) ProtoMessage()    {}
+
+func (m *PodSandboxNetworkStatus) GetAdditionalIps() []*PodIP {
+	if m != nil {
+		return m.AdditionalIps
+	}
+	return nil
+}
+
+type PodSandboxStatus struct {
+	Id             string                   `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Metadata       *PodSandboxMetadata      `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+	State          PodSandboxState          `protobuf:"varint,3,opt,name=state,enum=runtime.v1.PodSandboxState" json:"state,omitempty"`
+	CreatedAt      int64                    `protobuf:"varint,4,opt,name=created_at" json:"created_at,omitempty"`
+	Network        *PodSandboxNetworkStatus `protobuf:"bytes,5,opt,name=network" json:"network,omitempty"`
+	Labels         map[string]string        `protobuf:"bytes,7,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations    map[string]string        `protobuf:"bytes,8,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	RuntimeHandler string                   `protobuf:"bytes,9,opt,name=runtime_handler" json:"runtime_handler,omitempty"`
+}
+
+func (m *PodSandboxStatus) Reset()         { *m = PodSandboxStatus{} }
+func (m *PodSandboxStatus) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatus) ProtoMessage()    {}
+
+func (m *PodSandboxStatus) GetMetadata() *PodSandboxMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *PodSandboxStatus) GetNetwork() *PodSandboxNetworkStatus {
+	if m != nil {
+		return m.Network
+	}
+	return nil
+}
+
+func (m *PodSandboxStatus) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *PodSandboxStatus) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type PodSandboxStatusResponse struct {
+	Status *PodSandboxStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Info   map[string]string `protobuf:"bytes,2,rep,name=info" json:"info,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PodSandboxStatusResponse) Reset()         { *m = PodSandboxStatusResponse{} }
+func (m *PodSandboxStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatusResponse) ProtoMessage()    {}
+
+func (m *PodSandboxStatusResponse) GetStatus() *PodSandboxStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *PodSandboxStatusResponse) GetInfo() map[string]string {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type PodSandboxStateValue struct {
+	State PodSandboxState `protobuf:"varint,1,opt,name=state,enum=runtime.v1.PodSandboxState" json:"state,omitempty"`
+}
+
+func (m *PodSandboxStateValue) Reset()         { *m = PodSandboxStateValue{} }
+func (m *PodSandboxStateValue) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStateValue) ProtoMessage()    {}
+
+type PodSandboxFilter struct {
+	Id            string                `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	State         *PodSandboxStateValue `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	LabelSelector map[string]string     `protobuf:"bytes,3,rep,name=label_selector" json:"label_selector,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PodSandboxFilter) Reset()         { *m = PodSandboxFilter{} }
+func (m *PodSandboxFilter) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxFilter) ProtoMessage()    {}
+
+func (m *PodSandboxFilter) GetState() *PodSandboxStateValue {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+func (m *PodSandboxFilter) GetLabelSelector() map[string]string {
+	if m != nil {
+		return m.LabelSelector
+	}
+	return nil
+}
+
+type ListPodSandboxRequest struct {
+	Filter *PodSandboxFilter `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+}
+
+func (m *ListPodSandboxRequest) Reset()         { *m = ListPodSandboxRequest{} }
+func (m *ListPodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxRequest) ProtoMessage()    {}
+
+func (m *ListPodSandboxRequest) GetFilter() *PodSandboxFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+type PodSandbox struct {
+	Id             string              `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Metadata       *PodSandboxMetadata `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+	State          PodSandboxState     `protobuf:"varint,3,opt,name=state,enum=runtime.v1.PodSandboxState" json:"state,omitempty"`
+	CreatedAt      int64               `protobuf:"varint,4,opt,name=created_at" json:"created_at,omitempty"`
+	Labels         map[string]string   `protobuf:"bytes,5,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations    map[string]string   `protobuf:"bytes,6,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	RuntimeHandler string              `protobuf:"bytes,7,opt,name=runtime_handler" json:"runtime_handler,omitempty"`
+}
+
+func (m *PodSandbox) Reset()         { *m = PodSandbox{} }
+func (m *PodSandbox) String() string { return proto.CompactTextString(m) }
+func (*PodSandbox) ProtoMessage()    {}
+
+func (m *PodSandbox) GetMetadata() *PodSandboxMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *PodSandbox) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *PodSandbox) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type ListPodSandboxResponse struct {
+	Items []*PodSandbox `protobuf:"bytes,1,rep,name=items" json:"items,omitempty"`
+}
+
+func (m *ListPodSandboxResponse) Reset()         { *m = ListPodSandboxResponse{} }
+func (m *ListPodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxResponse) ProtoMessage()    {}
+
+func (m *ListPodSandboxResponse) GetItems() []*PodSandbox {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+type ImageSpec struct {
+	Image       string            `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Annotations map[string]string `protobuf:"bytes,2,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ImageSpec) Reset()         { *m = ImageSpec{} }
+func (m *ImageSpec) String() string { return proto.CompactTextString(m) }
+func (*ImageSpec) ProtoMessage()    {}
+
+func (m *ImageSpec) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type KeyValue struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *KeyValue) Reset()         { *m = KeyValue{} }
+func (m *KeyValue) String() string { return proto.CompactTextString(m) }
+func (*KeyValue) ProtoMessage()    {}
+
+type ContainerMetadata struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Attempt uint32 `protobuf:"varint,2,opt,name=attempt" json:"attempt,omitempty"`
+}
+
+func (m *ContainerMetadata) Reset()         { *m = ContainerMetadata{} }
+func (m *ContainerMetadata) String() string { return proto.CompactTextString(m) }
+func (*ContainerMetadata) ProtoMessage()    {}
+
+type ContainerConfig struct {
+	Metadata    *ContainerMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Image       *ImageSpec         `protobuf:"bytes,2,opt,name=image" json:"image,omitempty"`
+	Command     []string           `protobuf:"bytes,3,rep,name=command" json:"command,omitempty"`
+	Args        []string           `protobuf:"bytes,4,rep,name=args" json:"args,omitempty"`
+	WorkingDir  string             `protobuf:"bytes,5,opt,name=working_dir" json:"working_dir,omitempty"`
+	Envs        []*KeyValue        `protobuf:"bytes,6,rep,name=envs" json:"envs,omitempty"`
+	Mounts      []*Mount           `protobuf:"bytes,7,rep,name=mounts" json:"mounts,omitempty"`
+	Labels      map[string]string  `protobuf:"bytes,9,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations map[string]string  `protobuf:"bytes,10,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LogPath     string             `protobuf:"bytes,11,opt,name=log_path" json:"log_path,omitempty"`
+	Stdin       bool               `protobuf:"varint,12,opt,name=stdin" json:"stdin,omitempty"`
+	StdinOnce   bool               `protobuf:"varint,13,opt,name=stdin_once" json:"stdin_once,omitempty"`
+	Tty         bool               `protobuf:"varint,14,opt,name=tty" json:"tty,omitempty"`
+}
+
+func (m *ContainerConfig) Reset()         { *m = ContainerConfig{} }
+func (m *ContainerConfig) String() string { return proto.CompactTextString(m) }
+func (*ContainerConfig) ProtoMessage()    {}
+
+func (m *ContainerConfig) GetMetadata() *ContainerMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *ContainerConfig) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *ContainerConfig) GetEnvs() []*KeyValue {
+	if m != nil {
+		return m.Envs
+	}
+	return nil
+}
+
+func (m *ContainerConfig) GetMounts() []*Mount {
+	if m != nil {
+		return m.Mounts
+	}
+	return nil
+}
+
+func (m *ContainerConfig) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *ContainerConfig) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type CreateContainerRequest struct {
+	PodSandboxId  string            `protobuf:"bytes,1,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+	Config        *ContainerConfig  `protobuf:"bytes,2,opt,name=config" json:"config,omitempty"`
+	SandboxConfig *PodSandboxConfig `protobuf:"bytes,3,opt,name=sandbox_config" json:"sandbox_config,omitempty"`
+}
+
+func (m *CreateContainerRequest) Reset()         { *m = CreateContainerRequest{} }
+func (m *CreateContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateContainerRequest) ProtoMessage()    {}
+
+func (m *CreateContainerRequest) GetConfig() *ContainerConfig {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+func (m *CreateContainerRequest) GetSandboxConfig() *PodSandboxConfig {
+	if m != nil {
+		return m.SandboxConfig
+	}
+	return nil
+}
+
+type CreateContainerResponse struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+}
+
+func (m *CreateContainerResponse) Reset()         { *m = CreateContainerResponse{} }
+func (m *CreateContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateContainerResponse) ProtoMessage()    {}
+
+type StartContainerRequest struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+}
+
+func (m *StartContainerRequest) Reset()         { *m = StartContainerRequest{} }
+func (m *StartContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*StartContainerRequest) ProtoMessage()    {}
+
+type StartContainerResponse struct {
+}
+
+func (m *StartContainerResponse) Reset()         { *m = StartContainerResponse{} }
+func (m *StartContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*StartContainerResponse) ProtoMessage()    {}
+
+type StopContainerRequest struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+	Timeout     int64  `protobuf:"varint,2,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *StopContainerRequest) Reset()         { *m = StopContainerRequest{} }
+func (m *StopContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*StopContainerRequest) ProtoMessage()    {}
+
+type StopContainerResponse struct {
+}
+
+func (m *StopContainerResponse) Reset()         { *m = StopContainerResponse{} }
+func (m *StopContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*StopContainerResponse) ProtoMessage()    {}
+
+type RemoveContainerRequest struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+}
+
+func (m *RemoveContainerRequest) Reset()         { *m = RemoveContainerRequest{} }
+func (m *RemoveContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*RemoveContainerRequest) ProtoMessage()    {}
+
+type RemoveContainerResponse struct {
+}
+
+func (m *RemoveContainerResponse) Reset()         { *m = RemoveContainerResponse{} }
+func (m *RemoveContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveContainerResponse) ProtoMessage()    {}
+
+type ContainerStateValue struct {
+	State ContainerState `protobuf:"varint,1,opt,name=state,enum=runtime.v1.ContainerState" json:"state,omitempty"`
+}
+
+func (m *ContainerStateValue) Reset()         { *m = ContainerStateValue{} }
+func (m *ContainerStateValue) String() string { return proto.CompactTextString(m) }
+func (*ContainerStateValue) ProtoMessage()    {}
+
+type ContainerFilter struct {
+	Id            string               `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	State         *ContainerStateValue `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	PodSandboxId  string               `protobuf:"bytes,3,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+	LabelSelector map[string]string    `protobuf:"bytes,4,rep,name=label_selector" json:"label_selector,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ContainerFilter) Reset()         { *m = ContainerFilter{} }
+func (m *ContainerFilter) String() string { return proto.CompactTextString(m) }
+func (*ContainerFilter) ProtoMessage()    {}
+
+func (m *ContainerFilter) GetState() *ContainerStateValue {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+func (m *ContainerFilter) GetLabelSelector() map[string]string {
+	if m != nil {
+		return m.LabelSelector
+	}
+	return nil
+}
+
+type ListContainersRequest struct {
+	Filter *ContainerFilter `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+}
+
+func (m *ListContainersRequest) Reset()         { *m = ListContainersRequest{} }
+func (m *ListContainersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListContainersRequest) ProtoMessage()    {}
+
+func (m *ListContainersRequest) GetFilter() *ContainerFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+type Container struct {
+	Id           string             `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	PodSandboxId string             `protobuf:"bytes,2,opt,name=pod_sandbox_id" json:"pod_sandbox_id,omitempty"`
+	Metadata     *ContainerMetadata `protobuf:"bytes,3,opt,name=metadata" json:"metadata,omitempty"`
+	Image        *ImageSpec         `protobuf:"bytes,4,opt,name=image" json:"image,omitempty"`
+	ImageRef     string             `protobuf:"bytes,5,opt,name=image_ref" json:"image_ref,omitempty"`
+	State        ContainerState     `protobuf:"varint,6,opt,name=state,enum=runtime.v1.ContainerState" json:"state,omitempty"`
+	CreatedAt    int64              `protobuf:"varint,7,opt,name=created_at" json:"created_at,omitempty"`
+	Labels       map[string]string  `protobuf:"bytes,8,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations  map[string]string  `protobuf:"bytes,9,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Container) Reset()         { *m = Container{} }
+func (m *Container) String() string { return proto.CompactTextString(m) }
+func (*Container) ProtoMessage()    {}
+
+func (m *Container) GetMetadata() *ContainerMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *Container) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *Container) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Container) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type ListContainersResponse struct {
+	Containers []*Container `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
+}
+
+func (m *ListContainersResponse) Reset()         { *m = ListContainersResponse{} }
+func (m *ListContainersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListContainersResponse) ProtoMessage()    {}
+
+func (m *ListContainersResponse) GetContainers() []*Container {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type ContainerStatusRequest struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+	Verbose     bool   `protobuf:"varint,2,opt,name=verbose" json:"verbose,omitempty"`
+}
+
+func (m *ContainerStatusRequest) Reset()         { *m = ContainerStatusRequest{} }
+func (m *ContainerStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatusRequest) ProtoMessage()    {}
+
+type ContainerStatus struct {
+	Id          string             `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Metadata    *ContainerMetadata `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+	State       ContainerState     `protobuf:"varint,3,opt,name=state,enum=runtime.v1.ContainerState" json:"state,omitempty"`
+	CreatedAt   int64              `protobuf:"varint,4,opt,name=created_at" json:"created_at,omitempty"`
+	StartedAt   int64              `protobuf:"varint,5,opt,name=started_at" json:"started_at,omitempty"`
+	FinishedAt  int64              `protobuf:"varint,6,opt,name=finished_at" json:"finished_at,omitempty"`
+	ExitCode    int32              `protobuf:"varint,7,opt,name=exit_code" json:"exit_code,omitempty"`
+	Image       *ImageSpec         `protobuf:"bytes,8,opt,name=image" json:"image,omitempty"`
+	ImageRef    string             `protobuf:"bytes,9,opt,name=image_ref" json:"image_ref,omitempty"`
+	Reason      string             `protobuf:"bytes,10,opt,name=reason" json:"reason,omitempty"`
+	Message     string             `protobuf:"bytes,11,opt,name=message" json:"message,omitempty"`
+	Labels      map[string]string  `protobuf:"bytes,12,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations map[string]string  `protobuf:"bytes,13,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Mounts      []*Mount           `protobuf:"bytes,14,rep,name=mounts" json:"mounts,omitempty"`
+	LogPath     string             `protobuf:"bytes,15,opt,name=log_path" json:"log_path,omitempty"`
+}
+
+func (m *ContainerStatus) Reset()         { *m = ContainerStatus{} }
+func (m *ContainerStatus) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatus) ProtoMessage()    {}
+
+func (m *ContainerStatus) GetMetadata() *ContainerMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *ContainerStatus) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *ContainerStatus) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *ContainerStatus) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *ContainerStatus) GetMounts() []*Mount {
+	if m != nil {
+		return m.Mounts
+	}
+	return nil
+}
+
+type ContainerStatusResponse struct {
+	Status *ContainerStatus  `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Info   map[string]string `protobuf:"bytes,2,rep,name=info" json:"info,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ContainerStatusResponse) Reset()         { *m = ContainerStatusResponse{} }
+func (m *ContainerStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatusResponse) ProtoMessage()    {}
+
+func (m *ContainerStatusResponse) GetStatus() *ContainerStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ContainerStatusResponse) GetInfo() map[string]string {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type ExecSyncRequest struct {
+	ContainerId string   `protobuf:"bytes,1,opt,name=container_id" json:"container_id,omitempty"`
+	Cmd         []string `protobuf:"bytes,2,rep,name=cmd" json:"cmd,omitempty"`
+	Timeout     int64    `protobuf:"varint,3,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *ExecSyncRequest) Reset()         { *m = ExecSyncRequest{} }
+func (m *ExecSyncRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecSyncRequest) ProtoMessage()    {}
+
+type ExecSyncResponse struct {
+	Stdout   []byte `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr   []byte `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ExitCode int32  `protobuf:"varint,3,opt,name=exit_code" json:"exit_code,omitempty"`
+}
+
+func (m *ExecSyncResponse) Reset()         { *m = ExecSyncResponse{} }
+func (m *ExecSyncResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecSyncResponse) ProtoMessage()    {}
+
+type NetworkConfig struct {
+	PodCidr string `protobuf:"bytes,1,opt,name=pod_cidr" json:"pod_cidr,omitempty"`
+}
+
+func (m *NetworkConfig) Reset()         { *m = NetworkConfig{} }
+func (m *NetworkConfig) String() string { return proto.CompactTextString(m) }
+func (*NetworkConfig) ProtoMessage()    {}
+
+type RuntimeConfig struct {
+	NetworkConfig *NetworkConfig `protobuf:"bytes,1,opt,name=network_config" json:"network_config,omitempty"`
+}
+
+func (m *RuntimeConfig) Reset()         { *m = RuntimeConfig{} }
+func (m *RuntimeConfig) String() string { return proto.CompactTextString(m) }
+func (*RuntimeConfig) ProtoMessage()    {}
+
+func (m *RuntimeConfig) GetNetworkConfig() *NetworkConfig {
+	if m != nil {
+		return m.NetworkConfig
+	}
+	return nil
+}
+
+type UpdateRuntimeConfigRequest struct {
+	RuntimeConfig *RuntimeConfig `protobuf:"bytes,1,opt,name=runtime_config" json:"runtime_config,omitempty"`
+}
+
+func (m *UpdateRuntimeConfigRequest) Reset()         { *m = UpdateRuntimeConfigRequest{} }
+func (m *UpdateRuntimeConfigRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateRuntimeConfigRequest) ProtoMessage()    {}
+
+func (m *UpdateRuntimeConfigRequest) GetRuntimeConfig() *RuntimeConfig {
+	if m != nil {
+		return m.RuntimeConfig
+	}
+	return nil
+}
+
+type UpdateRuntimeConfigResponse struct {
+}
+
+func (m *UpdateRuntimeConfigResponse) Reset()         { *m = UpdateRuntimeConfigResponse{} }
+func (m *UpdateRuntimeConfigResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateRuntimeConfigResponse) ProtoMessage()    {}
+
+type RuntimeCondition struct {
+	Type    string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Status  bool   `protobuf:"varint,2,opt,name=status" json:"status,omitempty"`
+	Reason  string `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+	Message string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *RuntimeCondition) Reset()         { *m = RuntimeCondition{} }
+func (m *RuntimeCondition) String() string { return proto.CompactTextString(m) }
+func (*RuntimeCondition) ProtoMessage()    {}
+
+type RuntimeStatus struct {
+	Conditions []*RuntimeCondition `protobuf:"bytes,1,rep,name=conditions" json:"conditions,omitempty"`
+}
+
+func (m *RuntimeStatus) Reset()         { *m = RuntimeStatus{} }
+func (m *RuntimeStatus) String() string { return proto.CompactTextString(m) }
+func (*RuntimeStatus) ProtoMessage()    {}
+
+func (m *RuntimeStatus) GetConditions() []*RuntimeCondition {
+	if m != nil {
+		return m.Conditions
+	}
+	return nil
+}
+
+type StatusRequest struct {
+	Verbose bool `protobuf:"varint,1,opt,name=verbose" json:"verbose,omitempty"`
+}
+
+func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
+func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
+func (*StatusRequest) ProtoMessage()    {}
+
+type StatusResponse struct {
+	Status *RuntimeStatus    `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Info   map[string]string `protobuf:"bytes,2,rep,name=info" json:"info,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *StatusResponse) Reset()         { *m = StatusResponse{} }
+func (m *StatusResponse) String() string { return proto.CompactTextString(m) }
+func (*StatusResponse) ProtoMessage()    {}
+
+func (m *StatusResponse) GetStatus() *RuntimeStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetInfo() map[string]string {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type ImageFilter struct {
+	Image *ImageSpec `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+}
+
+func (m *ImageFilter) Reset()         { *m = ImageFilter{} }
+func (m *ImageFilter) String() string { return proto.CompactTextString(m) }
+func (*ImageFilter) ProtoMessage()    {}
+
+func (m *ImageFilter) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+type ListImagesRequest struct {
+	Filter *ImageFilter `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+}
+
+func (m *ListImagesRequest) Reset()         { *m = ListImagesRequest{} }
+func (m *ListImagesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListImagesRequest) ProtoMessage()    {}
+
+func (m *ListImagesRequest) GetFilter() *ImageFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+type Int64Value struct {
+	Value int64 `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *Int64Value) Reset()         { *m = Int64Value{} }
+func (m *Int64Value) String() string { return proto.CompactTextString(m) }
+func (*Int64Value) ProtoMessage()    {}
+
+type Image struct {
+	Id          string      `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	RepoTags    []string    `protobuf:"bytes,2,rep,name=repo_tags" json:"repo_tags,omitempty"`
+	RepoDigests []string    `protobuf:"bytes,3,rep,name=repo_digests" json:"repo_digests,omitempty"`
+	Size        uint64      `protobuf:"varint,4,opt,name=size" json:"size,omitempty"`
+	Uid         *Int64Value `protobuf:"bytes,5,opt,name=uid" json:"uid,omitempty"`
+	Username    string      `protobuf:"bytes,6,opt,name=username" json:"username,omitempty"`
+	Spec        *ImageSpec  `protobuf:"bytes,7,opt,name=spec" json:"spec,omitempty"`
+	Pinned      bool        `protobuf:"varint,8,opt,name=pinned" json:"pinned,omitempty"`
+}
+
+func (m *Image) Reset()         { *m = Image{} }
+func (m *Image) String() string { return proto.CompactTextString(m) }
+func (*Image) ProtoMessage()    {}
+
+func (m *Image) GetUid() *Int64Value {
+	if m != nil {
+		return m.Uid
+	}
+	return nil
+}
+
+func (m *Image) GetSpec() *ImageSpec {
+	if m != nil {
+		return m.Spec
+	}
+	return nil
+}
+
+type ListImagesResponse struct {
+	Images []*Image `protobuf:"bytes,1,rep,name=images" json:"images,omitempty"`
+}
+
+func (m *ListImagesResponse) Reset()         { *m = ListImagesResponse{} }
+func (m *ListImagesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListImagesResponse) ProtoMessage()    {}
+
+func (m *ListImagesResponse) GetImages() []*Image {
+	if m != nil {
+		return m.Images
+	}
+	return nil
+}
+
+type ImageStatusRequest struct {
+	Image   *ImageSpec `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Verbose bool       `protobuf:"varint,2,opt,name=verbose" json:"verbose,omitempty"`
+}
+
+func (m *ImageStatusRequest) Reset()         { *m = ImageStatusRequest{} }
+func (m *ImageStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*ImageStatusRequest) ProtoMessage()    {}
+
+func (m *ImageStatusRequest) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+type ImageStatusResponse struct {
+	Image *Image            `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Info  map[string]string `protobuf:"bytes,2,rep,name=info" json:"info,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ImageStatusResponse) Reset()         { *m = ImageStatusResponse{} }
+func (m *ImageStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*ImageStatusResponse) ProtoMessage()    {}
+
+func (m *ImageStatusResponse) GetImage() *Image {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *ImageStatusResponse) GetInfo() map[string]string {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type AuthConfig struct {
+	Username      string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password      string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+	Auth          string `protobuf:"bytes,3,opt,name=auth" json:"auth,omitempty"`
+	ServerAddress string `protobuf:"bytes,4,opt,name=server_address" json:"server_address,omitempty"`
+	IdentityToken string `protobuf:"bytes,5,opt,name=identity_token" json:"identity_token,omitempty"`
+	RegistryToken string `protobuf:"bytes,6,opt,name=registry_token" json:"registry_token,omitempty"`
+}
+
+func (m *AuthConfig) Reset()         { *m = AuthConfig{} }
+func (m *AuthConfig) String() string { return proto.CompactTextString(m) }
+func (*AuthConfig) ProtoMessage()    {}
+
+type PullImageRequest struct {
+	Image         *ImageSpec        `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Auth          *AuthConfig       `protobuf:"bytes,2,opt,name=auth" json:"auth,omitempty"`
+	SandboxConfig *PodSandboxConfig `protobuf:"bytes,3,opt,name=sandbox_config" json:"sandbox_config,omitempty"`
+}
+
+func (m *PullImageRequest) Reset()         { *m = PullImageRequest{} }
+func (m *PullImageRequest) String() string { return proto.CompactTextString(m) }
+func (*PullImageRequest) ProtoMessage()    {}
+
+func (m *PullImageRequest) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *PullImageRequest) GetAuth() *AuthConfig {
+	if m != nil {
+		return m.Auth
+	}
+	return nil
+}
+
+func (m *PullImageRequest) GetSandboxConfig() *PodSandboxConfig {
+	if m != nil {
+		return m.SandboxConfig
+	}
+	return nil
+}
+
+type PullImageResponse struct {
+	ImageRef string `protobuf:"bytes,1,opt,name=image_ref" json:"image_ref,omitempty"`
+}
+
+func (m *PullImageResponse) Reset()         { *m = PullImageResponse{} }
+func (m *PullImageResponse) String() string { return proto.CompactTextString(m) }
+func (*PullImageResponse) ProtoMessage()    {}
+
+type RemoveImageRequest struct {
+	Image *ImageSpec `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+}
+
+func (m *RemoveImageRequest) Reset()         { *m = RemoveImageRequest{} }
+func (m *RemoveImageRequest) String() string { return proto.CompactTextString(m) }
+func (*RemoveImageRequest) ProtoMessage()    {}
+
+func (m *RemoveImageRequest) GetImage() *ImageSpec {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+type RemoveImageResponse struct {
+}
+
+func (m *RemoveImageResponse) Reset()         { *m = RemoveImageResponse{} }
+func (m *RemoveImageResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveImageResponse) ProtoMessage()    {}
+
+type ImageFsInfoRequest struct {
+}
+
+func (m *ImageFsInfoRequest) Reset()         { *m = ImageFsInfoRequest{} }
+func (m *ImageFsInfoRequest) String() string { return proto.CompactTextString(m) }
+func (*ImageFsInfoRequest) ProtoMessage()    {}
+
+type FilesystemIdentifier struct {
+	Mountpoint string `protobuf:"bytes,1,opt,name=mountpoint" json:"mountpoint,omitempty"`
+}
+
+func (m *FilesystemIdentifier) Reset()         { *m = FilesystemIdentifier{} }
+func (m *FilesystemIdentifier) String() string { return proto.CompactTextString(m) }
+func (*FilesystemIdentifier) ProtoMessage()    {}
+
+type UInt64Value struct {
+	Value uint64 `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *UInt64Value) Reset()         { *m = UInt64Value{} }
+func (m *UInt64Value) String() string { return proto.CompactTextString(m) }
+func (*UInt64Value) ProtoMessage()    {}
+
+type FilesystemUsage struct {
+	Timestamp  int64                 `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	FsId       *FilesystemIdentifier `protobuf:"bytes,2,opt,name=fs_id" json:"fs_id,omitempty"`
+	UsedBytes  *UInt64Value          `protobuf:"bytes,3,opt,name=used_bytes" json:"used_bytes,omitempty"`
+	InodesUsed *UInt64Value          `protobuf:"bytes,4,opt,name=inodes_used" json:"inodes_used,omitempty"`
+}
+
+func (m *FilesystemUsage) Reset()         { *m = FilesystemUsage{} }
+func (m *FilesystemUsage) String() string { return proto.CompactTextString(m) }
+func (*FilesystemUsage) ProtoMessage()    {}
+
+func (m *FilesystemUsage) GetFsId() *FilesystemIdentifier {
+	if m != nil {
+		return m.FsId
+	}
+	return nil
+}
+
+func (m *FilesystemUsage) GetUsedBytes() *UInt64Value {
+	if m != nil {
+		return m.UsedBytes
+	}
+	return nil
+}
+
+func (m *FilesystemUsage) GetInodesUsed() *UInt64Value {
+	if m != nil {
+		return m.InodesUsed
+	}
+	return nil
+}
+
+type ImageFsInfoResponse struct {
+	ImageFilesystems []*FilesystemUsage `protobuf:"bytes,1,rep,name=image_filesystems" json:"image_filesystems,omitempty"`
+}
+
+func (m *ImageFsInfoResponse) Reset()         { *m = ImageFsInfoResponse{} }
+func (m *ImageFsInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*ImageFsInfoResponse) ProtoMessage()    {}
+
+func (m *ImageFsInfoResponse) GetImageFilesystems() []*FilesystemUsage {
+	if m != nil {
+		return m.ImageFilesystems
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("runtime.v1.Protocol", Protocol_name, Protocol_value)
+	proto.RegisterEnum("runtime.v1.PodSandboxState", PodSandboxState_name, PodSandboxState_value)
+	proto.RegisterEnum("runtime.v1.ContainerState", ContainerState_name, ContainerState_value)
+}
+
+// Client API for RuntimeService service
+
+type RuntimeServiceClient interface {
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+	RunPodSandbox(ctx context.Context, in *RunPodSandboxRequest, opts ...grpc.CallOption) (*RunPodSandboxResponse, error)
+	StopPodSandbox(ctx context.Context, in *StopPodSandboxRequest, opts ...grpc.CallOption) (*StopPodSandboxResponse, error)
+	RemovePodSandbox(ctx context.Context, in *RemovePodSandboxRequest, opts ...grpc.CallOption) (*RemovePodSandboxResponse, error)
+	PodSandboxStatus(ctx context.Context, in *PodSandboxStatusRequest, opts ...grpc.CallOption) (*PodSandboxStatusResponse, error)
+	ListPodSandbox(ctx context.Context, in *ListPodSandboxRequest, opts ...grpc.CallOption) (*ListPodSandboxResponse, error)
+	CreateContainer(ctx context.Context, in *CreateContainerRequest, opts ...grpc.CallOption) (*CreateContainerResponse, error)
+	StartContainer(ctx context.Context, in *StartContainerRequest, opts ...grpc.CallOption) (*StartContainerResponse, error)
+	StopContainer(ctx context.Context, in *StopContainerRequest, opts ...grpc.CallOption) (*StopContainerResponse, error)
+	RemoveContainer(ctx context.Context, in *RemoveContainerRequest, opts ...grpc.CallOption) (*RemoveContainerResponse, error)
+	ListContainers(ctx context.Context, in *ListContainersRequest, opts ...grpc.CallOption) (*ListContainersResponse, error)
+	ContainerStatus(ctx context.Context, in *ContainerStatusRequest, opts ...grpc.CallOption) (*ContainerStatusResponse, error)
+	ExecSync(ctx context.Context, in *ExecSyncRequest, opts ...grpc.CallOption) (*ExecSyncResponse, error)
+	UpdateRuntimeConfig(ctx context.Context, in *UpdateRuntimeConfigRequest, opts ...grpc.CallOption) (*UpdateRuntimeConfigResponse, error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type runtimeServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRuntimeServiceClient(cc *grpc.ClientConn) RuntimeServiceClient {
+	return &runtimeServiceClient{cc}
+}
+
+func (c *runtimeServiceClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/Version", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) RunPodSandbox(ctx context.Context, in *RunPodSandboxRequest, opts ...grpc.CallOption) (*RunPodSandboxResponse, error) {
+	out := new(RunPodSandboxResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/RunPodSandbox", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) StopPodSandbox(ctx context.Context, in *StopPodSandboxRequest, opts ...grpc.CallOption) (*StopPodSandboxResponse, error) {
+	out := new(StopPodSandboxResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/StopPodSandbox", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) RemovePodSandbox(ctx context.Context, in *RemovePodSandboxRequest, opts ...grpc.CallOption) (*RemovePodSandboxResponse, error) {
+	out := new(RemovePodSandboxResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/RemovePodSandbox", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) PodSandboxStatus(ctx context.Context, in *PodSandboxStatusRequest, opts ...grpc.CallOption) (*PodSandboxStatusResponse, error) {
+	out := new(PodSandboxStatusResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/PodSandboxStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) ListPodSandbox(ctx context.Context, in *ListPodSandboxRequest, opts ...grpc.CallOption) (*ListPodSandboxResponse, error) {
+	out := new(ListPodSandboxResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/ListPodSandbox", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) CreateContainer(ctx context.Context, in *CreateContainerRequest, opts ...grpc.CallOption) (*CreateContainerResponse, error) {
+	out := new(CreateContainerResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/CreateContainer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) StartContainer(ctx context.Context, in *StartContainerRequest, opts ...grpc.CallOption) (*StartContainerResponse, error) {
+	out := new(StartContainerResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/StartContainer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) StopContainer(ctx context.Context, in *StopContainerRequest, opts ...grpc.CallOption) (*StopContainerResponse, error) {
+	out := new(StopContainerResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/StopContainer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) RemoveContainer(ctx context.Context, in *RemoveContainerRequest, opts ...grpc.CallOption) (*RemoveContainerResponse, error) {
+	out := new(RemoveContainerResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/RemoveContainer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) ListContainers(ctx context.Context, in *ListContainersRequest, opts ...grpc.CallOption) (*ListContainersResponse, error) {
+	out := new(ListContainersResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/ListContainers", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) ContainerStatus(ctx context.Context, in *ContainerStatusRequest, opts ...grpc.CallOption) (*ContainerStatusResponse, error) {
+	out := new(ContainerStatusResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/ContainerStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) ExecSync(ctx context.Context, in *ExecSyncRequest, opts ...grpc.CallOption) (*ExecSyncResponse, error) {
+	out := new(ExecSyncResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/ExecSync", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) UpdateRuntimeConfig(ctx context.Context, in *UpdateRuntimeConfigRequest, opts ...grpc.CallOption) (*UpdateRuntimeConfigResponse, error) {
+	out := new(UpdateRuntimeConfigResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/UpdateRuntimeConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runtimeServiceClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.RuntimeService/Status", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for RuntimeService service
+
+type RuntimeServiceServer interface {
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+	RunPodSandbox(context.Context, *RunPodSandboxRequest) (*RunPodSandboxResponse, error)
+	StopPodSandbox(context.Context, *StopPodSandboxRequest) (*StopPodSandboxResponse, error)
+	RemovePodSandbox(context.Context, *RemovePodSandboxRequest) (*RemovePodSandboxResponse, error)
+	PodSandboxStatus(context.Context, *PodSandboxStatusRequest) (*PodSandboxStatusResponse, error)
+	ListPodSandbox(context.Context, *ListPodSandboxRequest) (*ListPodSandboxResponse, error)
+	CreateContainer(context.Context, *CreateContainerRequest) (*CreateContainerResponse, error)
+	StartContainer(context.Context, *StartContainerRequest) (*StartContainerResponse, error)
+	StopContainer(context.Context, *StopContainerRequest) (*StopContainerResponse, error)
+	RemoveContainer(context.Context, *RemoveContainerRequest) (*RemoveContainerResponse, error)
+	ListContainers(context.Context, *ListContainersRequest) (*ListContainersResponse, error)
+	ContainerStatus(context.Context, *ContainerStatusRequest) (*ContainerStatusResponse, error)
+	ExecSync(context.Context, *ExecSyncRequest) (*ExecSyncResponse, error)
+	UpdateRuntimeConfig(context.Context, *UpdateRuntimeConfigRequest) (*UpdateRuntimeConfigResponse, error)
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+}
+
+func RegisterRuntimeServiceServer(s *grpc.Server, srv RuntimeServiceServer) {
+	s.RegisterService(&_RuntimeService_serviceDesc, srv)
+}
+
+func _RuntimeService_Version_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).Version(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_RunPodSandbox_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RunPodSandboxRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).RunPodSandbox(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_StopPodSandbox_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopPodSandboxRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).StopPodSandbox(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_RemovePodSandbox_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RemovePodSandboxRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).RemovePodSandbox(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_PodSandboxStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PodSandboxStatusRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).PodSandboxStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_ListPodSandbox_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ListPodSandboxRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).ListPodSandbox(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_CreateContainer_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CreateContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).CreateContainer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_StartContainer_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StartContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).StartContainer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_StopContainer_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).StopContainer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_RemoveContainer_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RemoveContainerRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).RemoveContainer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_ListContainers_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ListContainersRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).ListContainers(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_ContainerStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ContainerStatusRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).ContainerStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_ExecSync_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecSyncRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).ExecSync(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_UpdateRuntimeConfig_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(UpdateRuntimeConfigRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).UpdateRuntimeConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _RuntimeService_Status_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(RuntimeServiceServer).Status(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _RuntimeService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "runtime.v1.RuntimeService",
+	HandlerType: (*RuntimeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Version",
+			Handler:    _RuntimeService_Version_Handler,
+		},
+		{
+			MethodName: "RunPodSandbox",
+			Handler:    _RuntimeService_RunPodSandbox_Handler,
+		},
+		{
+			MethodName: "StopPodSandbox",
+			Handler:    _RuntimeService_StopPodSandbox_Handler,
+		},
+		{
+			MethodName: "RemovePodSandbox",
+			Handler:    _RuntimeService_RemovePodSandbox_Handler,
+		},
+		{
+			MethodName: "PodSandboxStatus",
+			Handler:    _RuntimeService_PodSandboxStatus_Handler,
+		},
+		{
+			MethodName: "ListPodSandbox",
+			Handler:    _RuntimeService_ListPodSandbox_Handler,
+		},
+		{
+			MethodName: "CreateContainer",
+			Handler:    _RuntimeService_CreateContainer_Handler,
+		},
+		{
+			MethodName: "StartContainer",
+			Handler:    _RuntimeService_StartContainer_Handler,
+		},
+		{
+			MethodName: "StopContainer",
+			Handler:    _RuntimeService_StopContainer_Handler,
+		},
+		{
+			MethodName: "RemoveContainer",
+			Handler:    _RuntimeService_RemoveContainer_Handler,
+		},
+		{
+			MethodName: "ListContainers",
+			Handler:    _RuntimeService_ListContainers_Handler,
+		},
+		{
+			MethodName: "ContainerStatus",
+			Handler:    _RuntimeService_ContainerStatus_Handler,
+		},
+		{
+			MethodName: "ExecSync",
+			Handler:    _RuntimeService_ExecSync_Handler,
+		},
+		{
+			MethodName: "UpdateRuntimeConfig",
+			Handler:    _RuntimeService_UpdateRuntimeConfig_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _RuntimeService_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// Client API for ImageService service
+
+type ImageServiceClient interface {
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error)
+	ImageStatus(ctx context.Context, in *ImageStatusRequest, opts ...grpc.CallOption) (*ImageStatusResponse, error)
+	PullImage(ctx context.Context, in *PullImageRequest, opts ...grpc.CallOption) (*PullImageResponse, error)
+	RemoveImage(ctx context.Context, in *RemoveImageRequest, opts ...grpc.CallOption) (*RemoveImageResponse, error)
+	ImageFsInfo(ctx context.Context, in *ImageFsInfoRequest, opts ...grpc.CallOption) (*ImageFsInfoResponse, error)
+}
+
+type imageServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewImageServiceClient(cc *grpc.ClientConn) ImageServiceClient {
+	return &imageServiceClient{cc}
+}
+
+func (c *imageServiceClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error) {
+	out := new(ListImagesResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.ImageService/ListImages", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) ImageStatus(ctx context.Context, in *ImageStatusRequest, opts ...grpc.CallOption) (*ImageStatusResponse, error) {
+	out := new(ImageStatusResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.ImageService/ImageStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) PullImage(ctx context.Context, in *PullImageRequest, opts ...grpc.CallOption) (*PullImageResponse, error) {
+	out := new(PullImageResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.ImageService/PullImage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) RemoveImage(ctx context.Context, in *RemoveImageRequest, opts ...grpc.CallOption) (*RemoveImageResponse, error) {
+	out := new(RemoveImageResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.ImageService/RemoveImage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) ImageFsInfo(ctx context.Context, in *ImageFsInfoRequest, opts ...grpc.CallOption) (*ImageFsInfoResponse, error) {
+	out := new(ImageFsInfoResponse)
+	err := grpc.Invoke(ctx, "/runtime.v1.ImageService/ImageFsInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for ImageService service
+
+type ImageServiceServer interface {
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	ImageStatus(context.Context, *ImageStatusRequest) (*ImageStatusResponse, error)
+	PullImage(context.Context, *PullImageRequest) (*PullImageResponse, error)
+	RemoveImage(context.Context, *RemoveImageRequest) (*RemoveImageResponse, error)
+	ImageFsInfo(context.Context, *ImageFsInfoRequest) (*ImageFsInfoResponse, error)
+}
+
+func RegisterImageServiceServer(s *grpc.Server, srv ImageServiceServer) {
+	s.RegisterService(&_ImageService_serviceDesc, srv)
+}
+
+func _ImageService_ListImages_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ImageServiceServer).ListImages(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ImageService_ImageStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ImageStatusRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ImageServiceServer).ImageStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ImageService_PullImage_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PullImageRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ImageServiceServer).PullImage(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ImageService_RemoveImage_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RemoveImageRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ImageServiceServer).RemoveImage(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ImageService_ImageFsInfo_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ImageFsInfoRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ImageServiceServer).ImageFsInfo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _ImageService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "runtime.v1.ImageService",
+	HandlerType: (*ImageServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListImages",
+			Handler:    _ImageService_ListImages_Handler,
+		},
+		{
+			MethodName: "ImageStatus",
+			Handler:    _ImageService_ImageStatus_Handler,
+		},
+		{
+			MethodName: "PullImage",
+			Handler:    _ImageService_PullImage_Handler,
+		},
+		{
+			MethodName: "RemoveImage",
+			Handler:    _ImageService_RemoveImage_Handler,
+		},
+		{
+			MethodName: "ImageFsInfo",
+			Handler:    _ImageService_ImageFsInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
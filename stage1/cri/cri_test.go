@@ -0,0 +1,266 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cri
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apcera/kurma/stage1/image"
+	. "github.com/apcera/util/testtool"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testService returns a Service without a container manager, along with the
+// path of its state file, whose store holds busybox.
+func testService(t *testing.T) (*Service, string) {
+	dir := TempDir(t)
+	store, err := image.NewStore(filepath.Join(dir, "images"))
+	TestExpectSuccess(t, err)
+	_, err = store.Add("docker://docker.io/library/busybox:latest", strings.NewReader("busybox"))
+	TestExpectSuccess(t, err)
+	path := filepath.Join(dir, "cri.json")
+	s, err := New(nil, store, path)
+	TestExpectSuccess(t, err)
+	return s, path
+}
+
+func TestSandboxes(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s, path := testService(t)
+	ctx := context.Background()
+
+	run := func(name string, labels map[string]string) string {
+		resp, err := s.RunPodSandbox(ctx, &RunPodSandboxRequest{Config: &PodSandboxConfig{
+			Metadata: &PodSandboxMetadata{Name: name, Namespace: "default", Uid: name + "-uid"},
+			Labels:   labels,
+		}})
+		TestExpectSuccess(t, err)
+		return resp.PodSandboxId
+	}
+	web := run("web", map[string]string{"app": "web"})
+	db := run("db", map[string]string{"app": "db"})
+	_, err := s.RunPodSandbox(ctx, &RunPodSandboxRequest{})
+	TestExpectError(t, err)
+
+	// Test 1: Sandboxes are listed, and may be selected by their labels.
+	list, err := s.ListPodSandbox(ctx, &ListPodSandboxRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Items), 2)
+	list, err = s.ListPodSandbox(ctx, &ListPodSandboxRequest{Filter: &PodSandboxFilter{
+		LabelSelector: map[string]string{"app": "db"},
+	}})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Items), 1)
+	TestEqual(t, list.Items[0].Id, db)
+	TestEqual(t, list.Items[0].Metadata.Name, "db")
+
+	// Test 2: A stopped sandbox is no longer ready.
+	_, err = s.StopPodSandbox(ctx, &StopPodSandboxRequest{PodSandboxId: web})
+	TestExpectSuccess(t, err)
+	status, err := s.PodSandboxStatus(ctx, &PodSandboxStatusRequest{PodSandboxId: web})
+	TestExpectSuccess(t, err)
+	TestEqual(t, status.Status.State, PodSandboxState_SANDBOX_NOTREADY)
+	list, err = s.ListPodSandbox(ctx, &ListPodSandboxRequest{Filter: &PodSandboxFilter{
+		State: &PodSandboxStateValue{State: PodSandboxState_SANDBOX_READY},
+	}})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Items), 1)
+	TestEqual(t, list.Items[0].Id, db)
+
+	// Test 3: The sandboxes are loaded again from the file.
+	s, err = New(nil, s.images, path)
+	TestExpectSuccess(t, err)
+	status, err = s.PodSandboxStatus(ctx, &PodSandboxStatusRequest{PodSandboxId: web})
+	TestExpectSuccess(t, err)
+	TestEqual(t, status.Status.State, PodSandboxState_SANDBOX_NOTREADY)
+	TestEqual(t, status.Status.Metadata.Uid, "web-uid")
+
+	// Test 4: Removing a sandbox forgets it, and removing it again is not an
+	// error.
+	_, err = s.RemovePodSandbox(ctx, &RemovePodSandboxRequest{PodSandboxId: web})
+	TestExpectSuccess(t, err)
+	_, err = s.RemovePodSandbox(ctx, &RemovePodSandboxRequest{PodSandboxId: web})
+	TestExpectSuccess(t, err)
+	_, err = s.PodSandboxStatus(ctx, &PodSandboxStatusRequest{PodSandboxId: web})
+	TestEqual(t, grpc.Code(err), codes.NotFound)
+}
+
+func TestContainers(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s, path := testService(t)
+	ctx := context.Background()
+	sb, err := s.RunPodSandbox(ctx, &RunPodSandboxRequest{Config: &PodSandboxConfig{
+		Metadata: &PodSandboxMetadata{Name: "web", Namespace: "default"},
+	}})
+	TestExpectSuccess(t, err)
+
+	// Test 1: Containers may only be created from images which were pulled,
+	// and within sandboxes which exist.
+	config := &ContainerConfig{
+		Metadata: &ContainerMetadata{Name: "app"},
+		Image:    &ImageSpec{Image: "busybox"},
+		Labels:   map[string]string{"tier": "front"},
+	}
+	created, err := s.CreateContainer(ctx, &CreateContainerRequest{PodSandboxId: sb.PodSandboxId, Config: config})
+	TestExpectSuccess(t, err)
+	_, err = s.CreateContainer(ctx, &CreateContainerRequest{PodSandboxId: "missing", Config: config})
+	TestEqual(t, grpc.Code(err), codes.NotFound)
+	_, err = s.CreateContainer(ctx, &CreateContainerRequest{PodSandboxId: sb.PodSandboxId, Config: &ContainerConfig{
+		Metadata: &ContainerMetadata{Name: "app"},
+		Image:    &ImageSpec{Image: "nginx"},
+	}})
+	TestEqual(t, grpc.Code(err), codes.NotFound)
+
+	// Test 2: A container which hasn't been started is created, and refers to
+	// its image by the image's ID.
+	status, err := s.ContainerStatus(ctx, &ContainerStatusRequest{ContainerId: created.ContainerId})
+	TestExpectSuccess(t, err)
+	TestEqual(t, status.Status.State, ContainerState_CONTAINER_CREATED)
+	TestEqual(t, status.Status.Metadata.Name, "app")
+	img, err := s.ImageStatus(ctx, &ImageStatusRequest{Image: &ImageSpec{Image: "busybox"}})
+	TestExpectSuccess(t, err)
+	TestEqual(t, status.Status.ImageRef, img.Image.Id)
+
+	// Test 3: Containers are listed by their sandbox, state, and labels.
+	for _, f := range []*ContainerFilter{
+		nil,
+		{PodSandboxId: sb.PodSandboxId},
+		{State: &ContainerStateValue{State: ContainerState_CONTAINER_CREATED}},
+		{LabelSelector: map[string]string{"tier": "front"}},
+	} {
+		list, err := s.ListContainers(ctx, &ListContainersRequest{Filter: f})
+		TestExpectSuccess(t, err)
+		TestEqual(t, len(list.Containers), 1)
+		TestEqual(t, list.Containers[0].Id, created.ContainerId)
+	}
+	for _, f := range []*ContainerFilter{
+		{PodSandboxId: "missing"},
+		{State: &ContainerStateValue{State: ContainerState_CONTAINER_RUNNING}},
+		{LabelSelector: map[string]string{"tier": "back"}},
+	} {
+		list, err := s.ListContainers(ctx, &ListContainersRequest{Filter: f})
+		TestExpectSuccess(t, err)
+		TestEqual(t, len(list.Containers), 0)
+	}
+
+	// Test 4: Starting a container requires a container manager.
+	_, err = s.StartContainer(ctx, &StartContainerRequest{ContainerId: created.ContainerId})
+	TestExpectError(t, err)
+
+	// Test 5: The containers are loaded again from the file, and are removed
+	// along with their sandbox.
+	s, err = New(nil, s.images, path)
+	TestExpectSuccess(t, err)
+	list, err := s.ListContainers(ctx, &ListContainersRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Containers), 1)
+	_, err = s.RemovePodSandbox(ctx, &RemovePodSandboxRequest{PodSandboxId: sb.PodSandboxId})
+	TestExpectSuccess(t, err)
+	list, err = s.ListContainers(ctx, &ListContainersRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Containers), 0)
+}
+
+func TestContainerApp(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	manifest := schema.BlankImageManifest()
+	manifest.App = &types.App{
+		Exec:  types.Exec{"/bin/app", "--default"},
+		User:  "1000",
+		Group: "1000",
+	}
+	manifest.App.Environment.Set("PATH", "/bin")
+
+	// Test 1: Arguments replace all but the image's command.
+	app, err := containerApp(manifest, &ContainerConfig{
+		Args: []string{"--verbose"},
+		Envs: []*KeyValue{{Key: "MODE", Value: "test"}},
+	})
+	TestExpectSuccess(t, err)
+	TestEqual(t, []string(app.Exec), []string{"/bin/app", "--verbose"})
+	TestEqual(t, app.User, "1000")
+	mode, _ := app.Environment.Get("MODE")
+	TestEqual(t, mode, "test")
+	path, _ := app.Environment.Get("PATH")
+	TestEqual(t, path, "/bin")
+
+	// the image's app is left as it was
+	TestEqual(t, []string(manifest.App.Exec), []string{"/bin/app", "--default"})
+	_, ok := manifest.App.Environment.Get("MODE")
+	TestFalse(t, ok)
+
+	// Test 2: A command replaces the image's, along with its arguments.
+	app, err = containerApp(manifest, &ContainerConfig{
+		Command:    []string{"/bin/sh", "-c"},
+		Args:       []string{"echo hi"},
+		WorkingDir: "/tmp",
+	})
+	TestExpectSuccess(t, err)
+	TestEqual(t, []string(app.Exec), []string{"/bin/sh", "-c", "echo hi"})
+	TestEqual(t, app.WorkingDirectory, "/tmp")
+
+	// Test 3: An image without an app needs a command.
+	manifest.App = nil
+	_, err = containerApp(manifest, &ContainerConfig{Args: []string{"--verbose"}})
+	TestExpectError(t, err)
+	app, err = containerApp(manifest, &ContainerConfig{Command: []string{"/bin/true"}})
+	TestExpectSuccess(t, err)
+	TestEqual(t, app.User, "0")
+}
+
+func TestImages(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s, _ := testService(t)
+	ctx := context.Background()
+	_, err := s.images.Add("https://example.com/app.aci", strings.NewReader("app"))
+	TestExpectSuccess(t, err)
+
+	// Test 1: Only images from Docker registries are listed.
+	list, err := s.ListImages(ctx, &ListImagesRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Images), 1)
+	TestEqual(t, list.Images[0].RepoTags, []string{"docker.io/library/busybox:latest"})
+	TestEqual(t, list.Images[0].Size, uint64(len("busybox")))
+
+	// Test 2: Images are found by any reference to them, or by their ID.
+	for _, ref := range []string{"busybox", "library/busybox:latest", "docker.io/library/busybox", list.Images[0].Id} {
+		status, err := s.ImageStatus(ctx, &ImageStatusRequest{Image: &ImageSpec{Image: ref}})
+		TestExpectSuccess(t, err)
+		TestNotEqual(t, status.Image, nil)
+		TestEqual(t, status.Image.Id, list.Images[0].Id)
+	}
+	status, err := s.ImageStatus(ctx, &ImageStatusRequest{Image: &ImageSpec{Image: "busybox:1.24"}})
+	TestExpectSuccess(t, err)
+	TestEqual(t, status.Image, (*Image)(nil))
+
+	// Test 3: The filesystem usage covers all of the images.
+	info, err := s.ImageFsInfo(ctx, &ImageFsInfoRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(info.ImageFilesystems), 1)
+	TestEqual(t, info.ImageFilesystems[0].UsedBytes.Value, uint64(len("busybox")+len("app")))
+	TestEqual(t, info.ImageFilesystems[0].FsId.Mountpoint, s.images.Directory())
+
+	// Test 4: Removed images are no longer held, and removing them again is
+	// not an error.
+	_, err = s.RemoveImage(ctx, &RemoveImageRequest{Image: &ImageSpec{Image: "busybox"}})
+	TestExpectSuccess(t, err)
+	_, err = s.RemoveImage(ctx, &RemoveImageRequest{Image: &ImageSpec{Image: "busybox"}})
+	TestExpectSuccess(t, err)
+	list, err = s.ListImages(ctx, &ListImagesRequest{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(list.Images), 0)
+}
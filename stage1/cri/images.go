@@ -0,0 +1,161 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cri
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/util/remote"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// dockerScheme prefixes the URIs of the images the kubelet refers to, which
+// are all retrieved from Docker registries.
+const dockerScheme = "docker://"
+
+// imageURI returns the URI the image the kubelet refers to is held under in
+// the store.
+func imageURI(ref string) (string, error) {
+	canonical, err := remote.DockerReference(dockerScheme + ref)
+	if err != nil {
+		return "", err
+	}
+	return dockerScheme + canonical, nil
+}
+
+// imageID returns the ID the image is known to the kubelet by, which is taken
+// from the name of its file within the store.
+func imageID(img *image.Image) string {
+	return "sha256:" + strings.TrimSuffix(img.File, filepath.Ext(img.File))
+}
+
+// findImage returns the image in the store with the reference or ID, or nil if
+// it isn't held.
+func (s *Service) findImage(ref string) *image.Image {
+	uri, err := imageURI(ref)
+	if err != nil {
+		uri = ""
+	}
+	for _, img := range s.images.Images() {
+		if (uri != "" && img.URI == uri) || imageID(&img) == ref {
+			return &img
+		}
+	}
+	return nil
+}
+
+// pbImage returns the description of the image given to the kubelet.
+func pbImage(img *image.Image) *Image {
+	ref := strings.TrimPrefix(img.URI, dockerScheme)
+	pbi := &Image{
+		Id:   imageID(img),
+		Size: uint64(img.Size),
+		Spec: &ImageSpec{Image: ref},
+	}
+	if strings.Contains(ref, "@") {
+		pbi.RepoDigests = []string{ref}
+	} else {
+		pbi.RepoTags = []string{ref}
+	}
+	return pbi
+}
+
+// ListImages returns the images held in the store which were retrieved from
+// Docker registries, as the kubelet can only refer to those.
+func (s *Service) ListImages(ctx context.Context, in *ListImagesRequest) (*ListImagesResponse, error) {
+	var filter *image.Image
+	if f := in.Filter; f != nil && f.Image != nil && f.Image.Image != "" {
+		if filter = s.findImage(f.Image.Image); filter == nil {
+			return &ListImagesResponse{}, nil
+		}
+	}
+
+	resp := &ListImagesResponse{}
+	for _, img := range s.images.Images() {
+		if !strings.HasPrefix(img.URI, dockerScheme) || (filter != nil && img.URI != filter.URI) {
+			continue
+		}
+		resp.Images = append(resp.Images, pbImage(&img))
+	}
+	return resp, nil
+}
+
+// ImageStatus returns the image, or no image if it isn't held.
+func (s *Service) ImageStatus(ctx context.Context, in *ImageStatusRequest) (*ImageStatusResponse, error) {
+	if in.Image == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no image was given")
+	}
+	resp := &ImageStatusResponse{}
+	if img := s.findImage(in.Image.Image); img != nil {
+		resp.Image = pbImage(img)
+	}
+	return resp, nil
+}
+
+// PullImage retrieves the image into the store. The credentials the kubelet
+// passes are ignored in favour of those the host is configured with for the
+// image's registry. Since images in Docker registries aren't signed, they're
+// retrieved without verifying a signature.
+func (s *Service) PullImage(ctx context.Context, in *PullImageRequest) (*PullImageResponse, error) {
+	if in.Image == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no image was given")
+	}
+	uri, err := imageURI(in.Image.Image)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	f, err := s.images.Retrieve(uri, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image: %v", err)
+	}
+	f.Close()
+	if s.manager != nil {
+		s.manager.Publish(&container.Event{Type: container.EventImagePulled, Image: uri})
+	}
+
+	img := s.findImage(in.Image.Image)
+	if img == nil {
+		return nil, fmt.Errorf("image %q was removed as it was pulled", in.Image.Image)
+	}
+	return &PullImageResponse{ImageRef: imageID(img)}, nil
+}
+
+// RemoveImage removes the image from the store. Removing an image which isn't
+// held is not an error.
+func (s *Service) RemoveImage(ctx context.Context, in *RemoveImageRequest) (*RemoveImageResponse, error) {
+	if in.Image == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no image was given")
+	}
+	img := s.findImage(in.Image.Image)
+	if img == nil {
+		return &RemoveImageResponse{}, nil
+	}
+	if err := s.images.Remove(img.URI); err != nil {
+		return nil, err
+	}
+	return &RemoveImageResponse{}, nil
+}
+
+// ImageFsInfo returns the space used by all of the images in the store, which
+// the kubelet uses to decide when to garbage collect images.
+func (s *Service) ImageFsInfo(ctx context.Context, in *ImageFsInfoRequest) (*ImageFsInfoResponse, error) {
+	images := s.images.Images()
+	var used uint64
+	for _, img := range images {
+		used += uint64(img.Size)
+	}
+	return &ImageFsInfoResponse{
+		ImageFilesystems: []*FilesystemUsage{{
+			Timestamp:  s.now().UnixNano(),
+			FsId:       &FilesystemIdentifier{Mountpoint: s.images.Directory()},
+			UsedBytes:  &UInt64Value{Value: used},
+			InodesUsed: &UInt64Value{Value: uint64(len(images))},
+		}},
+	}, nil
+}
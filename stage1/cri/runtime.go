@@ -0,0 +1,636 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/apcera/kurma/util/remote"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// goneExitCode is the exit code reported for containers whose kurma container
+// no longer exists, such as after the host restarted.
+const goneExitCode = -1
+
+func (s *Service) Version(ctx context.Context, in *VersionRequest) (*VersionResponse, error) {
+	return &VersionResponse{
+		Version:           "0.1.0",
+		RuntimeName:       runtimeName,
+		RuntimeVersion:    pb.Version,
+		RuntimeApiVersion: runtimeAPIVersion,
+	}, nil
+}
+
+func (s *Service) Status(ctx context.Context, in *StatusRequest) (*StatusResponse, error) {
+	return &StatusResponse{
+		Status: &RuntimeStatus{
+			Conditions: []*RuntimeCondition{
+				{Type: "RuntimeReady", Status: true},
+				{Type: "NetworkReady", Status: true},
+			},
+		},
+	}, nil
+}
+
+// UpdateRuntimeConfig accepts the pod CIDR the kubelet was given, which kurma
+// has no use for since the container network is configured with the host.
+func (s *Service) UpdateRuntimeConfig(ctx context.Context, in *UpdateRuntimeConfigRequest) (*UpdateRuntimeConfigResponse, error) {
+	return &UpdateRuntimeConfigResponse{}, nil
+}
+
+func (s *Service) RunPodSandbox(ctx context.Context, in *RunPodSandboxRequest) (*RunPodSandboxResponse, error) {
+	if in.Config == nil || in.Config.Metadata == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "the sandbox config and its metadata are required")
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sandboxes[id] = &sandbox{ID: id, Config: in.Config, Created: s.now(), Ready: true}
+	if err := s.save(); err != nil {
+		delete(s.sandboxes, id)
+		return nil, err
+	}
+	s.Log.Infof("Running pod sandbox %s for %s/%s", id, in.Config.Metadata.Namespace, in.Config.Metadata.Name)
+	return &RunPodSandboxResponse{PodSandboxId: id}, nil
+}
+
+// StopPodSandbox stops the apps of the sandbox's containers, leaving them in
+// place until the sandbox is removed. Stopping a sandbox which doesn't exist
+// is not an error, since it may already have been removed.
+func (s *Service) StopPodSandbox(ctx context.Context, in *StopPodSandboxRequest) (*StopPodSandboxResponse, error) {
+	s.mutex.Lock()
+	sb := s.sandboxes[in.PodSandboxId]
+	if sb == nil {
+		s.mutex.Unlock()
+		return &StopPodSandboxResponse{}, nil
+	}
+	sb.Ready = false
+	containers := s.sandboxContainers(sb.ID)
+	err := s.save()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		if err := s.stopContainer(c.ID, 0); err != nil {
+			return nil, err
+		}
+	}
+	return &StopPodSandboxResponse{}, nil
+}
+
+// RemovePodSandbox destroys the sandbox's containers and forgets them along
+// with the sandbox.
+func (s *Service) RemovePodSandbox(ctx context.Context, in *RemovePodSandboxRequest) (*RemovePodSandboxResponse, error) {
+	s.mutex.Lock()
+	containers := s.sandboxContainers(in.PodSandboxId)
+	s.mutex.Unlock()
+	for _, c := range containers {
+		if err := s.removeContainer(c.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sb := s.sandboxes[in.PodSandboxId]
+	if sb == nil {
+		return &RemovePodSandboxResponse{}, nil
+	}
+	delete(s.sandboxes, sb.ID)
+	if err := s.save(); err != nil {
+		s.sandboxes[sb.ID] = sb
+		return nil, err
+	}
+	return &RemovePodSandboxResponse{}, nil
+}
+
+func (s *Service) PodSandboxStatus(ctx context.Context, in *PodSandboxStatusRequest) (*PodSandboxStatusResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sb := s.sandboxes[in.PodSandboxId]
+	if sb == nil {
+		return nil, grpc.Errorf(codes.NotFound, "pod sandbox %q not found", in.PodSandboxId)
+	}
+
+	status := &PodSandboxStatus{
+		Id:          sb.ID,
+		Metadata:    sb.Config.Metadata,
+		State:       sandboxState(sb),
+		CreatedAt:   sb.Created.UnixNano(),
+		Labels:      sb.Config.Labels,
+		Annotations: sb.Config.Annotations,
+		Network:     &PodSandboxNetworkStatus{Ip: s.sandboxIP(sb)},
+	}
+	return &PodSandboxStatusResponse{Status: status}, nil
+}
+
+// sandboxIP returns the first address of the sandbox's first container which
+// has one. The mutex must be held.
+func (s *Service) sandboxIP(sb *sandbox) string {
+	for _, c := range s.sandboxContainers(sb.ID) {
+		if kc := s.kurmaContainer(c.ID); kc != nil {
+			if ips := kc.IPAddresses(); len(ips) > 0 {
+				return ips[0].String()
+			}
+		}
+	}
+	return ""
+}
+
+func (s *Service) ListPodSandbox(ctx context.Context, in *ListPodSandboxRequest) (*ListPodSandboxResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	resp := &ListPodSandboxResponse{}
+	for _, sb := range s.sandboxes {
+		state := sandboxState(sb)
+		if f := in.Filter; f != nil {
+			if (f.Id != "" && f.Id != sb.ID) ||
+				(f.State != nil && f.State.State != state) ||
+				!matchLabels(sb.Config.Labels, f.LabelSelector) {
+				continue
+			}
+		}
+		resp.Items = append(resp.Items, &PodSandbox{
+			Id:          sb.ID,
+			Metadata:    sb.Config.Metadata,
+			State:       state,
+			CreatedAt:   sb.Created.UnixNano(),
+			Labels:      sb.Config.Labels,
+			Annotations: sb.Config.Annotations,
+		})
+	}
+	return resp, nil
+}
+
+// sandboxState returns the sandbox's state.
+func sandboxState(sb *sandbox) PodSandboxState {
+	if sb.Ready {
+		return PodSandboxState_SANDBOX_READY
+	}
+	return PodSandboxState_SANDBOX_NOTREADY
+}
+
+// CreateContainer records the container within the sandbox. Its image must
+// already have been pulled, and its kurma container is created once it is
+// started.
+func (s *Service) CreateContainer(ctx context.Context, in *CreateContainerRequest) (*CreateContainerResponse, error) {
+	if in.Config == nil || in.Config.Metadata == nil || in.Config.Image == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "the container config, its metadata, and its image are required")
+	}
+	img := s.findImage(in.Config.Image.Image)
+	if img == nil {
+		return nil, grpc.Errorf(codes.NotFound, "image %q has not been pulled", in.Config.Image.Image)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.sandboxes[in.PodSandboxId] == nil {
+		return nil, grpc.Errorf(codes.NotFound, "pod sandbox %q not found", in.PodSandboxId)
+	}
+	s.containers[id] = &criContainer{
+		ID:        id,
+		SandboxID: in.PodSandboxId,
+		Config:    in.Config,
+		ImageURI:  img.URI,
+		ImageRef:  imageID(img),
+		Created:   s.now(),
+	}
+	if err := s.save(); err != nil {
+		delete(s.containers, id)
+		return nil, err
+	}
+	return &CreateContainerResponse{ContainerId: id}, nil
+}
+
+// StartContainer creates the container's kurma container, which starts its
+// app. The sandbox's port mappings are published by its first container.
+func (s *Service) StartContainer(ctx context.Context, in *StartContainerRequest) (*StartContainerResponse, error) {
+	s.mutex.Lock()
+	c := s.containers[in.ContainerId]
+	if c == nil {
+		s.mutex.Unlock()
+		return nil, grpc.Errorf(codes.NotFound, "container %q not found", in.ContainerId)
+	}
+	if !c.Started.IsZero() {
+		s.mutex.Unlock()
+		return nil, grpc.Errorf(codes.FailedPrecondition, "container %q has already been started", c.ID)
+	}
+	sb := s.sandboxes[c.SandboxID]
+	first := true
+	for _, other := range s.sandboxContainers(c.SandboxID) {
+		if !other.Started.IsZero() {
+			first = false
+		}
+	}
+	s.mutex.Unlock()
+	if s.manager == nil {
+		return nil, fmt.Errorf("no container manager is configured")
+	}
+
+	opts, err := s.createOptions(c, sb, first)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.images.Retrieve(c.ImageURI, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image: %v", err)
+	}
+	manifest, err := remote.FindManifest(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to find manifest in image: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	name, err := appName(c.Config.Metadata.Name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	app, err := containerApp(manifest, c.Config)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	opts.Pod = &schema.PodManifest{
+		Apps: schema.AppList{{
+			Name:  name,
+			Image: schema.RuntimeImage{Name: &manifest.Name},
+			App:   app,
+		}},
+	}
+	if _, err := s.manager.Create(name.String(), manifest, f, opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c.Started = s.now()
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &StartContainerResponse{}, nil
+}
+
+// createOptions returns the options the container's kurma container is created
+// with. Only mounts of kurma's named volumes are supported, so the kubelet's
+// other mounts are skipped.
+func (s *Service) createOptions(c *criContainer, sb *sandbox, first bool) (*container.CreateOptions, error) {
+	labels := make(map[string]string, len(c.Config.Labels)+1)
+	for key, value := range c.Config.Labels {
+		labels[key] = value
+	}
+	labels[sandboxLabel] = sb.ID
+
+	opts := &container.CreateOptions{
+		Name:          c.ID,
+		Labels:        labels,
+		RestartPolicy: container.RestartNever,
+		Tty:           c.Config.Tty,
+		Stdin:         c.Config.Stdin,
+	}
+
+	volumes := s.manager.VolumeDirectory()
+	var skipped []string
+	for _, m := range c.Config.Mounts {
+		if volumes == "" || filepath.Dir(filepath.Clean(m.HostPath)) != filepath.Clean(volumes) {
+			skipped = append(skipped, m.HostPath)
+			continue
+		}
+		opts.Volumes = append(opts.Volumes, &container.VolumeMount{
+			Name:     filepath.Base(m.HostPath),
+			Path:     m.ContainerPath,
+			ReadOnly: m.Readonly,
+		})
+	}
+	if len(skipped) > 0 {
+		s.Log.Warnf("Skipping mounts of %s into container %s, which aren't kurma volumes", strings.Join(skipped, ", "), c.ID)
+	}
+
+	if first {
+		for _, p := range sb.Config.PortMappings {
+			if p.HostPort == 0 {
+				continue
+			}
+			opts.Ports = append(opts.Ports, network.PortMapping{
+				Protocol:      strings.ToLower(p.Protocol.String()),
+				HostPort:      int(p.HostPort),
+				ContainerPort: int(p.ContainerPort),
+			})
+		}
+	}
+	return opts, nil
+}
+
+// appName returns the name of the container's app, which is its name within
+// the pod made into a valid app name.
+func appName(name string) (types.ACName, error) {
+	sanitized, err := types.SanitizeACName(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid container name %q: %v", name, err)
+	}
+	return types.ACName(sanitized), nil
+}
+
+// containerApp returns the image's app with the container's command, working
+// directory, and environment applied. ACIs don't keep an image's entrypoint
+// apart from its arguments, so arguments given without a command replace all
+// but the first element of the image's command.
+func containerApp(manifest *schema.ImageManifest, config *ContainerConfig) (*types.App, error) {
+	app := &types.App{User: "0", Group: "0"}
+	if manifest.App != nil {
+		copied := *manifest.App
+		copied.Exec = append(types.Exec(nil), manifest.App.Exec...)
+		copied.Environment = append(types.Environment(nil), manifest.App.Environment...)
+		app = &copied
+	}
+
+	switch {
+	case len(config.Command) > 0:
+		app.Exec = types.Exec(append(append([]string(nil), config.Command...), config.Args...))
+	case len(config.Args) > 0:
+		if len(app.Exec) == 0 {
+			return nil, fmt.Errorf("the image has no command for the arguments to be passed to")
+		}
+		app.Exec = types.Exec(append([]string{app.Exec[0]}, config.Args...))
+	}
+	if len(app.Exec) == 0 {
+		return nil, fmt.Errorf("neither the container nor its image specify a command")
+	}
+	if config.WorkingDir != "" {
+		app.WorkingDirectory = config.WorkingDir
+	}
+	for _, kv := range config.Envs {
+		app.Environment.Set(kv.Key, kv.Value)
+	}
+	return app, nil
+}
+
+// StopContainer stops the container's app, killing it if it hasn't exited
+// after the timeout in seconds. Stopping a container which isn't running is
+// not an error.
+func (s *Service) StopContainer(ctx context.Context, in *StopContainerRequest) (*StopContainerResponse, error) {
+	s.mutex.Lock()
+	c := s.containers[in.ContainerId]
+	s.mutex.Unlock()
+	if c == nil {
+		return nil, grpc.Errorf(codes.NotFound, "container %q not found", in.ContainerId)
+	}
+	if err := s.stopContainer(c.ID, time.Duration(in.Timeout)*time.Second); err != nil {
+		return nil, err
+	}
+	return &StopContainerResponse{}, nil
+}
+
+// stopContainer stops the apps of the container's kurma container if they're
+// running. A grace period of 0 uses the container's own.
+func (s *Service) stopContainer(id string, gracePeriod time.Duration) error {
+	kc := s.kurmaContainer(id)
+	if kc == nil {
+		return nil
+	}
+	switch kc.State() {
+	case container.RUNNING, container.RESTARTING:
+	default:
+		return nil
+	}
+	if gracePeriod == 0 {
+		gracePeriod = kc.StopGracePeriod()
+	}
+	if err := kc.StopApps(gracePeriod); err != nil && kc.State() != container.STOPPED && kc.State() != container.EXITED {
+		return err
+	}
+	return nil
+}
+
+// RemoveContainer destroys the container's kurma container and forgets it.
+// Removing a container which doesn't exist is not an error.
+func (s *Service) RemoveContainer(ctx context.Context, in *RemoveContainerRequest) (*RemoveContainerResponse, error) {
+	if err := s.removeContainer(in.ContainerId); err != nil {
+		return nil, err
+	}
+	return &RemoveContainerResponse{}, nil
+}
+
+// removeContainer destroys the container's kurma container, if it has one,
+// and forgets the container.
+func (s *Service) removeContainer(id string) error {
+	if kc := s.kurmaContainer(id); kc != nil {
+		if err := kc.Stop(); err != nil {
+			return fmt.Errorf("failed to destroy container %s: %v", id, err)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c := s.containers[id]
+	if c == nil {
+		return nil
+	}
+	delete(s.containers, id)
+	if err := s.save(); err != nil {
+		s.containers[id] = c
+		return err
+	}
+	return nil
+}
+
+func (s *Service) ListContainers(ctx context.Context, in *ListContainersRequest) (*ListContainersResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	resp := &ListContainersResponse{}
+	for _, c := range s.containers {
+		state, _ := s.containerState(c)
+		if f := in.Filter; f != nil {
+			if (f.Id != "" && f.Id != c.ID) ||
+				(f.PodSandboxId != "" && f.PodSandboxId != c.SandboxID) ||
+				(f.State != nil && f.State.State != state) ||
+				!matchLabels(c.Config.Labels, f.LabelSelector) {
+				continue
+			}
+		}
+		resp.Containers = append(resp.Containers, &Container{
+			Id:           c.ID,
+			PodSandboxId: c.SandboxID,
+			Metadata:     c.Config.Metadata,
+			Image:        c.Config.Image,
+			ImageRef:     c.ImageRef,
+			State:        state,
+			CreatedAt:    c.Created.UnixNano(),
+			Labels:       c.Config.Labels,
+			Annotations:  c.Config.Annotations,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) ContainerStatus(ctx context.Context, in *ContainerStatusRequest) (*ContainerStatusResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c := s.containers[in.ContainerId]
+	if c == nil {
+		return nil, grpc.Errorf(codes.NotFound, "container %q not found", in.ContainerId)
+	}
+
+	state, kc := s.containerState(c)
+	status := &ContainerStatus{
+		Id:          c.ID,
+		Metadata:    c.Config.Metadata,
+		State:       state,
+		CreatedAt:   c.Created.UnixNano(),
+		Image:       c.Config.Image,
+		ImageRef:    c.ImageRef,
+		Labels:      c.Config.Labels,
+		Annotations: c.Config.Annotations,
+		Mounts:      c.Config.Mounts,
+		LogPath:     c.Config.LogPath,
+	}
+	switch {
+	case kc != nil:
+		if t := kc.StartTime(); !t.IsZero() {
+			status.StartedAt = t.UnixNano()
+		}
+		if state == ContainerState_CONTAINER_EXITED {
+			if t := kc.ExitTime(); !t.IsZero() {
+				status.FinishedAt = t.UnixNano()
+			}
+			status.ExitCode = int32(kc.ExitCode())
+			switch {
+			case kc.OOMKilled():
+				status.Reason = "OOMKilled"
+			case status.ExitCode == 0:
+				status.Reason = "Completed"
+			default:
+				status.Reason = "Error"
+			}
+		}
+	case !c.Started.IsZero():
+		status.StartedAt = c.Started.UnixNano()
+		status.ExitCode = goneExitCode
+		status.Reason = "Unknown"
+		status.Message = "the container no longer exists"
+	}
+	return &ContainerStatusResponse{Status: status}, nil
+}
+
+// containerState returns the state of the container, along with its kurma
+// container if it exists. Containers whose kurma container is gone have
+// exited. The mutex must be held.
+func (s *Service) containerState(c *criContainer) (ContainerState, *container.Container) {
+	if c.Started.IsZero() {
+		return ContainerState_CONTAINER_CREATED, nil
+	}
+	kc := s.kurmaContainer(c.ID)
+	if kc == nil {
+		return ContainerState_CONTAINER_EXITED, nil
+	}
+	switch kc.State() {
+	case container.NEW, container.STARTING:
+		return ContainerState_CONTAINER_CREATED, kc
+	case container.RUNNING, container.RESTARTING, container.STOPPING:
+		return ContainerState_CONTAINER_RUNNING, kc
+	case container.STOPPED, container.EXITED:
+		return ContainerState_CONTAINER_EXITED, kc
+	}
+	return ContainerState_CONTAINER_UNKNOWN, kc
+}
+
+// ExecSync runs the command within the container, returning its output once it
+// exits. If it hasn't exited within the timeout in seconds, an error is
+// returned while it is left to finish.
+func (s *Service) ExecSync(ctx context.Context, in *ExecSyncRequest) (*ExecSyncResponse, error) {
+	if len(in.Cmd) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no command was given")
+	}
+	kc := s.kurmaContainer(in.ContainerId)
+	if kc == nil {
+		return nil, grpc.Errorf(codes.NotFound, "container %q is not running", in.ContainerId)
+	}
+
+	done := make(chan *ExecSyncResponse, 1)
+	errch := make(chan error, 1)
+	go func() {
+		resp, err := execSync(kc, in.Cmd)
+		if err != nil {
+			errch <- err
+			return
+		}
+		done <- resp
+	}()
+
+	var timeout <-chan time.Time
+	if in.Timeout > 0 {
+		timeout = time.After(time.Duration(in.Timeout) * time.Second)
+	}
+	select {
+	case resp := <-done:
+		return resp, nil
+	case err := <-errch:
+		return nil, err
+	case <-timeout:
+		return nil, grpc.Errorf(codes.DeadlineExceeded, "the command did not exit within %d seconds", in.Timeout)
+	}
+}
+
+// execSync runs the command within the container, capturing its output within
+// temporary files.
+func execSync(kc *container.Container, cmd []string) (*ExecSyncResponse, error) {
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, err
+	}
+	defer stdin.Close()
+	var outputs [2]*os.File
+	for i := range outputs {
+		f, err := ioutil.TempFile("", "cri-exec")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		outputs[i] = f
+	}
+
+	code, err := kc.Exec(cmd, stdin, outputs[0], outputs[1])
+	if err != nil {
+		return nil, err
+	}
+	resp := &ExecSyncResponse{ExitCode: int32(code)}
+	for i, b := range []*[]byte{&resp.Stdout, &resp.Stderr} {
+		if _, err := outputs[i].Seek(0, 0); err != nil {
+			return nil, err
+		}
+		if *b, err = ioutil.ReadAll(outputs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package cri serves the Kubernetes Container Runtime Interface, so that a
+// kubelet can run pods on the host with kurma as its runtime. Sandboxes are
+// kept by the service itself, and each container within one is a kurma
+// container named after its ID, created from an image held in the image store.
+//
+// Kurma containers each have their own namespaces, so the containers within a
+// sandbox don't share their network or IPC namespaces as they would under other
+// runtimes, and the sandbox's address is that of its first container. The
+// streaming methods, such as Exec and PortForward, aren't served, and container
+// output is captured by kurma's log drivers rather than written to the log path
+// the kubelet gives.
+package cri
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/logray"
+	"google.golang.org/grpc"
+)
+
+const (
+	// sandboxLabel is the label on kurma containers giving the ID of the
+	// sandbox they belong to.
+	sandboxLabel = "kurma.cri.sandbox"
+
+	// runtimeName and runtimeAPIVersion are reported to the kubelet as the
+	// runtime and the version of the interface it serves.
+	runtimeName       = "kurma"
+	runtimeAPIVersion = "v1"
+)
+
+// sandbox is a pod sandbox run by the kubelet.
+type sandbox struct {
+	ID      string            `json:"id"`
+	Config  *PodSandboxConfig `json:"config"`
+	Created time.Time         `json:"created"`
+	Ready   bool              `json:"ready"`
+}
+
+// criContainer is a container created within a sandbox. Its kurma container is
+// only created once it is started.
+type criContainer struct {
+	ID        string           `json:"id"`
+	SandboxID string           `json:"sandbox_id"`
+	Config    *ContainerConfig `json:"config"`
+	ImageURI  string           `json:"image_uri"`
+	ImageRef  string           `json:"image_ref"`
+	Created   time.Time        `json:"created"`
+	Started   time.Time        `json:"started"`
+}
+
+// state is how the sandboxes and containers are saved.
+type state struct {
+	Sandboxes  []*sandbox      `json:"sandboxes"`
+	Containers []*criContainer `json:"containers"`
+}
+
+// Service implements the runtime and image services of the interface. The
+// sandboxes and containers are saved to a file, so they persist as long as
+// their kurma containers do.
+type Service struct {
+	Log *logray.Logger
+
+	manager    *container.Manager
+	images     *image.Store
+	path       string
+	sandboxes  map[string]*sandbox
+	containers map[string]*criContainer
+	mutex      sync.Mutex
+	now        func() time.Time
+}
+
+// New returns a Service running containers with the manager from images in the
+// store, which keeps its sandboxes and containers in the file at the path. The
+// file is created once a sandbox is run.
+func New(manager *container.Manager, images *image.Store, path string) (*Service, error) {
+	s := &Service{
+		Log:        logray.New(),
+		manager:    manager,
+		images:     images,
+		path:       path,
+		sandboxes:  make(map[string]*sandbox),
+		containers: make(map[string]*criContainer),
+		now:        time.Now,
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("failed to load the CRI state from %s: %v", path, err)
+	}
+	for _, sb := range st.Sandboxes {
+		s.sandboxes[sb.ID] = sb
+	}
+	for _, c := range st.Containers {
+		if s.sandboxes[c.SandboxID] != nil {
+			s.containers[c.ID] = c
+		}
+	}
+	return s, nil
+}
+
+// Register registers the runtime and image services with the gRPC server.
+func (s *Service) Register(gs *grpc.Server) {
+	RegisterRuntimeServiceServer(gs, s)
+	RegisterImageServiceServer(gs, s)
+}
+
+// kurmaContainer returns the kurma container of the container with the ID, or
+// nil if it hasn't been created or no longer exists.
+func (s *Service) kurmaContainer(id string) *container.Container {
+	if s.manager == nil {
+		return nil
+	}
+	for _, c := range s.manager.Containers() {
+		if c.Name() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// sandboxContainers returns the containers within the sandbox, oldest first.
+// The mutex must be held.
+func (s *Service) sandboxContainers(id string) []*criContainer {
+	var containers []*criContainer
+	for _, c := range s.containers {
+		if c.SandboxID == id {
+			containers = append(containers, c)
+		}
+	}
+	sort.Sort(byCreated(containers))
+	return containers
+}
+
+type byCreated []*criContainer
+
+func (c byCreated) Len() int      { return len(c) }
+func (c byCreated) Swap(a, b int) { c[a], c[b] = c[b], c[a] }
+func (c byCreated) Less(a, b int) bool {
+	if c[a].Created.Equal(c[b].Created) {
+		return c[a].ID < c[b].ID
+	}
+	return c[a].Created.Before(c[b].Created)
+}
+
+// save writes the sandboxes and containers to the file, replacing it
+// atomically. The mutex must be held.
+func (s *Service) save() error {
+	var st state
+	for _, sb := range s.sandboxes {
+		st.Sandboxes = append(st.Sandboxes, sb)
+	}
+	sort.Sort(sandboxesByID(st.Sandboxes))
+	for _, c := range s.containers {
+		st.Containers = append(st.Containers, c)
+	}
+	sort.Sort(byCreated(st.Containers))
+	b, err := json.MarshalIndent(&st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), ".cri")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+type sandboxesByID []*sandbox
+
+func (s sandboxesByID) Len() int           { return len(s) }
+func (s sandboxesByID) Less(a, b int) bool { return s[a].ID < s[b].ID }
+func (s sandboxesByID) Swap(a, b int)      { s[a], s[b] = s[b], s[a] }
+
+// newID returns a random ID for a sandbox or container, which is also a valid
+// kurma container name.
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// matchLabels returns whether the labels include each of the selector's.
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,319 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package dns implements a small DNS server for the container network, which
+// resolves the names of containers and their apps to the containers'
+// addresses, and forwards other queries to the host's nameservers.
+package dns
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/logray"
+)
+
+const (
+	// DefaultDomain is the domain the containers' names are resolved under
+	// when no other is given.
+	DefaultDomain = "kurma"
+
+	// recordTTL is the TTL of the answers for containers. It is short, as
+	// containers come and go.
+	recordTTL = 5
+
+	// forwardTimeout is how long each upstream nameserver is given to
+	// respond to a forwarded query.
+	forwardTimeout = 2 * time.Second
+
+	// maxMessageSize is the largest message which is read, allowing for
+	// clients and nameservers using EDNS.
+	maxMessageSize = 4096
+)
+
+// Record is a container which may be resolved by name, along with the names of
+// its apps.
+type Record struct {
+	Name      string
+	Apps      []string
+	Addresses []net.IP
+}
+
+// RecordsFunc returns the containers which may currently be resolved.
+type RecordsFunc func() []*Record
+
+// Server answers queries from the containers. A name, either by itself or
+// under the domain, resolves to the addresses of:
+//
+//	CONTAINER      the container with the name
+//	APP.CONTAINER  the container, if it has an app with the name
+//	APP            each of the containers with an app with the name
+//
+// Names under the domain which don't match are answered with NXDOMAIN, and all
+// others are forwarded to the upstream nameservers.
+type Server struct {
+	Log *logray.Logger
+
+	domain    string
+	records   RecordsFunc
+	upstreams func() []string
+}
+
+// New returns a Server which resolves the records under the domain, and
+// forwards other queries to the upstream nameservers, given as IP addresses
+// with an optional port.
+func New(domain string, records RecordsFunc, upstreams func() []string) *Server {
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	return &Server{
+		Log:       logray.New(),
+		domain:    strings.ToLower(strings.Trim(domain, ".")),
+		records:   records,
+		upstreams: upstreams,
+	}
+}
+
+// Domain returns the domain the containers are resolved under.
+func (s *Server) Domain() string {
+	return s.domain
+}
+
+// Serve answers the queries received on the connection until it is closed.
+func (s *Server) Serve(conn net.PacketConn) error {
+	for {
+		b := make([]byte, maxMessageSize)
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+				continue
+			}
+			return err
+		}
+		go func() {
+			if resp := s.handle(b[:n]); resp != nil {
+				if _, err := conn.WriteTo(resp, addr); err != nil {
+					s.Log.Debugf("Failed to respond to %s: %v", addr, err)
+				}
+			}
+		}()
+	}
+}
+
+// handle returns the response to a request, or nil if there should be none.
+func (s *Server) handle(b []byte) []byte {
+	q, err := parseQuery(b)
+	if q == nil {
+		// responses and runts aren't answered
+		return nil
+	} else if err != nil {
+		return q.response(rcodeFormatError, false, nil, 0)
+	}
+	if q.opcode() != 0 {
+		return q.response(rcodeNotImplemented, false, nil, 0)
+	}
+
+	name, local := s.localName(q.name)
+	if q.qclass == classIN && name != "" {
+		if ips, ok := s.lookup(name); ok {
+			return q.response(rcodeSuccess, true, filterAddresses(ips, q.qtype), recordTTL)
+		}
+	}
+	if local {
+		return q.response(rcodeNameError, true, nil, 0)
+	}
+	return s.forward(q, b)
+}
+
+// localName returns the name with the domain removed, and whether it was
+// under the domain.
+func (s *Server) localName(name string) (string, bool) {
+	if name == s.domain {
+		return "", true
+	}
+	if strings.HasSuffix(name, "."+s.domain) {
+		return strings.TrimSuffix(name, "."+s.domain), true
+	}
+	return name, false
+}
+
+// lookup returns the addresses the name resolves to, and whether it matched
+// any containers.
+func (s *Server) lookup(name string) ([]net.IP, bool) {
+	records := s.records()
+	for _, r := range records {
+		if strings.ToLower(r.Name) == name {
+			return r.Addresses, true
+		}
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		app, cname := name[:i], name[i+1:]
+		for _, r := range records {
+			if strings.ToLower(r.Name) == cname && hasApp(r, app) {
+				return r.Addresses, true
+			}
+		}
+	}
+
+	var ips []net.IP
+	found := false
+	for _, r := range records {
+		if hasApp(r, name) {
+			ips = append(ips, r.Addresses...)
+			found = true
+		}
+	}
+	return ips, found
+}
+
+func hasApp(r *Record, name string) bool {
+	for _, app := range r.Apps {
+		if strings.ToLower(app) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAddresses returns the addresses which answer the type of query. A name
+// which is found has no answers for other types.
+func filterAddresses(ips []net.IP, qtype uint16) []net.IP {
+	var answers []net.IP
+	for _, ip := range ips {
+		ipv4 := ip.To4() != nil
+		if qtype == typeANY || (qtype == typeA && ipv4) || (qtype == typeAAAA && !ipv4) {
+			answers = append(answers, ip)
+		}
+	}
+	return answers
+}
+
+// forward sends the request to each upstream nameserver in turn, returning the
+// first response.
+func (s *Server) forward(q *query, b []byte) []byte {
+	for _, upstream := range s.upstreams() {
+		if _, _, err := net.SplitHostPort(upstream); err != nil {
+			upstream = net.JoinHostPort(upstream, "53")
+		}
+		resp, err := exchange(upstream, q.id, b)
+		if err != nil {
+			s.Log.Debugf("Failed to forward the query for %s to %s: %v", q.name, upstream, err)
+			continue
+		}
+		return resp
+	}
+	return q.response(rcodeServerFailure, false, nil, 0)
+}
+
+// exchange sends the request to the nameserver and waits for its response.
+func exchange(addr string, id uint16, b []byte) ([]byte, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		// responses to earlier queries on the same port are skipped
+		if n >= headerSize && uint16(resp[0])<<8|uint16(resp[1]) == id {
+			return resp[:n], nil
+		}
+	}
+}
+
+// ManagerRecords returns a RecordsFunc for the manager's containers whose apps
+// are running and which are connected to the container network.
+func ManagerRecords(manager *container.Manager) RecordsFunc {
+	return func() []*Record {
+		var records []*Record
+		for _, c := range manager.Containers() {
+			switch c.State() {
+			case container.STARTING, container.RUNNING, container.RESTARTING:
+			default:
+				continue
+			}
+			ips := c.IPAddresses()
+			if len(ips) == 0 {
+				continue
+			}
+			r := &Record{Name: c.Name(), Addresses: ips}
+			for _, app := range c.Apps() {
+				r.Apps = append(r.Apps, app.Name)
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+}
+
+// resolvConfCache caches the nameservers read from a resolv.conf until it is
+// modified.
+type resolvConfCache struct {
+	mutex       sync.Mutex
+	path        string
+	modified    time.Time
+	nameservers []string
+}
+
+// ResolvConfUpstreams returns a function for the nameservers listed in the
+// resolv.conf, which is read again when it changes. Any of the addresses in
+// exclude are left out, so that the server doesn't forward queries to itself.
+func ResolvConfUpstreams(path string, exclude ...net.IP) func() []string {
+	c := &resolvConfCache{path: path}
+	return func() []string {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		fi, err := os.Stat(c.path)
+		if err != nil {
+			return nil
+		}
+		if !fi.ModTime().Equal(c.modified) {
+			c.nameservers = readNameservers(c.path, exclude)
+			c.modified = fi.ModTime()
+		}
+		return c.nameservers
+	}
+}
+
+// readNameservers returns the nameservers in the resolv.conf, other than those
+// excluded.
+func readNameservers(path string, exclude []net.IP) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var nameservers []string
+	scanner := bufio.NewScanner(f)
+lines:
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		ip := net.ParseIP(fields[1])
+		if ip == nil {
+			continue
+		}
+		for _, e := range exclude {
+			if ip.Equal(e) {
+				continue lines
+			}
+		}
+		nameservers = append(nameservers, ip.String())
+	}
+	return nameservers
+}
@@ -0,0 +1,232 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package dns
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// buildQuery returns a request for the name and type.
+func buildQuery(id uint16, name string, qtype uint16) []byte {
+	b := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], flagRD)
+	binary.BigEndian.PutUint16(b[4:6], 1)
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0, byte(qtype>>8), byte(qtype), 0, classIN)
+	return b
+}
+
+// parseResponse returns the rcode, flags, and answers of a response.
+func parseResponse(t *testing.T, b []byte) (int, uint16, []net.IP) {
+	TestTrue(t, len(b) >= headerSize)
+	flags := binary.BigEndian.Uint16(b[2:4])
+	TestTrue(t, flags&flagQR != 0)
+	answers := int(binary.BigEndian.Uint16(b[6:8]))
+
+	// skip the question
+	off := headerSize
+	for b[off] != 0 {
+		off += int(b[off]) + 1
+	}
+	off += 5
+
+	var ips []net.IP
+	for i := 0; i < answers; i++ {
+		TestEqual(t, binary.BigEndian.Uint16(b[off:off+2]), uint16(0xc000|headerSize))
+		n := int(binary.BigEndian.Uint16(b[off+10 : off+12]))
+		ips = append(ips, net.IP(b[off+12:off+12+n]))
+		off += 12 + n
+	}
+	return int(flags & 0xf), flags, ips
+}
+
+func testRecords() []*Record {
+	return []*Record{
+		{Name: "web", Apps: []string{"nginx", "Sidecar"}, Addresses: []net.IP{
+			net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2")}},
+		{Name: "worker", Apps: []string{"sidecar"}, Addresses: []net.IP{net.ParseIP("10.0.0.3")}},
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	q, err := parseQuery(buildQuery(7, "Web.Kurma.", typeA))
+	TestExpectSuccess(t, err)
+	TestEqual(t, q.id, uint16(7))
+	TestEqual(t, q.name, "web.kurma")
+	TestEqual(t, q.qtype, uint16(typeA))
+	TestEqual(t, q.qclass, uint16(classIN))
+
+	_, err = parseQuery([]byte{1, 2, 3})
+	TestExpectError(t, err)
+
+	b := buildQuery(7, "web", typeA)
+	q, err = parseQuery(b[:len(b)-2])
+	TestExpectError(t, err)
+	TestEqual(t, q.id, uint16(7))
+}
+
+func TestServerResolvesContainers(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s := New("", testRecords, func() []string { return nil })
+
+	lookup := func(name string, qtype uint16) (int, uint16, []net.IP) {
+		return parseResponse(t, s.handle(buildQuery(1, name, qtype)))
+	}
+
+	rcode, flags, ips := lookup("web", typeA)
+	TestEqual(t, rcode, rcodeSuccess)
+	TestTrue(t, flags&flagAA != 0)
+	TestTrue(t, flags&flagRD != 0)
+	TestEqual(t, len(ips), 1)
+	TestEqual(t, ips[0].String(), "10.0.0.2")
+
+	_, _, ips = lookup("web.kurma", typeAAAA)
+	TestEqual(t, len(ips), 1)
+	TestEqual(t, ips[0].String(), "fd00::2")
+
+	_, _, ips = lookup("nginx.web.kurma", typeA)
+	TestEqual(t, len(ips), 1)
+	TestEqual(t, ips[0].String(), "10.0.0.2")
+
+	// an app name resolves to each container with the app
+	_, _, ips = lookup("sidecar", typeA)
+	TestEqual(t, len(ips), 2)
+
+	// a container without an IPv6 address has no answers for AAAA
+	rcode, _, ips = lookup("worker", typeAAAA)
+	TestEqual(t, rcode, rcodeSuccess)
+	TestEqual(t, len(ips), 0)
+
+	rcode, _, _ = lookup("missing.kurma", typeA)
+	TestEqual(t, rcode, rcodeNameError)
+	rcode, _, _ = lookup("nginx.worker.kurma", typeA)
+	TestEqual(t, rcode, rcodeNameError)
+
+	// other names fail without any upstream nameservers
+	rcode, _, _ = lookup("example.com", typeA)
+	TestEqual(t, rcode, rcodeServerFailure)
+
+	// responses aren't answered
+	resp := s.handle(buildQuery(1, "web", typeA))
+	TestTrue(t, s.handle(resp) == nil)
+}
+
+func TestServerForwards(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	defer upstream.Close()
+	go func() {
+		b := make([]byte, maxMessageSize)
+		for {
+			n, addr, err := upstream.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			q, err := parseQuery(b[:n])
+			if err != nil {
+				continue
+			}
+			upstream.WriteTo(q.response(rcodeSuccess, false, []net.IP{net.ParseIP("192.0.2.1")}, 60), addr)
+		}
+	}()
+
+	// the unreachable nameserver is skipped
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	s := New("cluster.local.", testRecords, func() []string {
+		return []string{deadAddr, upstream.LocalAddr().String()}
+	})
+	TestEqual(t, s.Domain(), "cluster.local")
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	go s.Serve(conn)
+	defer conn.Close()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	TestExpectSuccess(t, err)
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+
+	exchange := func(name string) (int, []net.IP) {
+		_, err := client.Write(buildQuery(42, name, typeA))
+		TestExpectSuccess(t, err)
+		b := make([]byte, maxMessageSize)
+		n, err := client.Read(b)
+		TestExpectSuccess(t, err)
+		TestEqual(t, binary.BigEndian.Uint16(b[0:2]), uint16(42))
+		rcode, _, ips := parseResponse(t, b[:n])
+		return rcode, ips
+	}
+
+	rcode, ips := exchange("example.com")
+	TestEqual(t, rcode, rcodeSuccess)
+	TestEqual(t, len(ips), 1)
+	TestEqual(t, ips[0].String(), "192.0.2.1")
+
+	_, ips = exchange("web.cluster.local")
+	TestEqual(t, len(ips), 1)
+	TestEqual(t, ips[0].String(), "10.0.0.2")
+}
+
+func TestResponseTruncated(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	var ips []net.IP
+	for i := 0; i < 100; i++ {
+		ips = append(ips, net.IPv4(10, 0, 1, byte(i)))
+	}
+	q, err := parseQuery(buildQuery(1, "many", typeA))
+	TestExpectSuccess(t, err)
+	b := q.response(rcodeSuccess, true, ips, recordTTL)
+	TestTrue(t, len(b) <= maxUDPSize)
+	_, flags, answers := parseResponse(t, b)
+	TestTrue(t, flags&flagTC != 0)
+	TestTrue(t, len(answers) > 0)
+	TestTrue(t, len(answers) < 100)
+}
+
+func TestResolvConfUpstreams(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), "resolv.conf")
+	conf := "search example.com\nnameserver 10.0.0.1\nnameserver 8.8.8.8\n# nameserver 1.1.1.1\nnameserver bad\n"
+	TestExpectSuccess(t, ioutil.WriteFile(path, []byte(conf), 0644))
+
+	upstreams := ResolvConfUpstreams(path, net.ParseIP("10.0.0.1"))
+	TestEqual(t, upstreams(), []string{"8.8.8.8"})
+
+	// the file is read again once it changes
+	TestExpectSuccess(t, ioutil.WriteFile(path, []byte("nameserver 2001:db8::1\n"), 0644))
+	TestExpectSuccess(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	TestEqual(t, upstreams(), []string{"2001:db8::1"})
+
+	TestExpectSuccess(t, os.Remove(path))
+	TestEqual(t, len(upstreams()), 0)
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The parts of the DNS wire format, from RFC 1035, which the server needs.
+const (
+	headerSize = 12
+
+	typeA    = 1
+	typeAAAA = 28
+	typeANY  = 255
+	classIN  = 1
+
+	flagQR = 1 << 15
+	flagAA = 1 << 10
+	flagTC = 1 << 9
+	flagRD = 1 << 8
+	flagRA = 1 << 7
+
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeServerFailure  = 2
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+
+	// maxUDPSize is the largest response sent to clients which don't
+	// advertise a larger buffer.
+	maxUDPSize = 512
+)
+
+// query is the parsed header and question of a request.
+type query struct {
+	id     uint16
+	flags  uint16
+	name   string
+	qtype  uint16
+	qclass uint16
+
+	// question is the question section as it was received, which is echoed
+	// in the response.
+	question []byte
+}
+
+// opcode returns the kind of request, where 0 is a standard query.
+func (q *query) opcode() uint16 {
+	return (q.flags >> 11) & 0xf
+}
+
+// parseQuery parses the header and the single question of a request. The
+// name is returned in lowercase, without the trailing dot.
+func parseQuery(b []byte) (*query, error) {
+	if len(b) < headerSize {
+		return nil, fmt.Errorf("the message is too short")
+	}
+	q := &query{
+		id:    binary.BigEndian.Uint16(b[0:2]),
+		flags: binary.BigEndian.Uint16(b[2:4]),
+	}
+	if q.flags&flagQR != 0 {
+		return nil, fmt.Errorf("the message is a response")
+	}
+	if n := binary.BigEndian.Uint16(b[4:6]); n != 1 {
+		return q, fmt.Errorf("the message has %d questions", n)
+	}
+
+	var labels []string
+	off := headerSize
+	for {
+		if off >= len(b) {
+			return q, fmt.Errorf("the question is truncated")
+		}
+		n := int(b[off])
+		off++
+		if n == 0 {
+			break
+		}
+		// questions aren't compressed, as there's nothing earlier to point to
+		if n > 63 || off+n > len(b) {
+			return q, fmt.Errorf("the question has an invalid name")
+		}
+		labels = append(labels, string(b[off:off+n]))
+		off += n
+	}
+	if off+4 > len(b) {
+		return q, fmt.Errorf("the question is truncated")
+	}
+	q.name = strings.ToLower(strings.Join(labels, "."))
+	q.qtype = binary.BigEndian.Uint16(b[off : off+2])
+	q.qclass = binary.BigEndian.Uint16(b[off+2 : off+4])
+	q.question = b[headerSize : off+4]
+	return q, nil
+}
+
+// response builds the response to the query with the rcode and the addresses
+// as answers. Answers which don't fit in a UDP response are left out, and it
+// is marked as truncated.
+func (q *query) response(rcode uint16, authoritative bool, ips []net.IP, ttl uint32) []byte {
+	b := make([]byte, headerSize, maxUDPSize)
+	flags := flagQR | flagRA | q.flags&(0xf<<11|flagRD) | rcode
+	if authoritative {
+		flags |= flagAA
+	}
+	binary.BigEndian.PutUint16(b[0:2], q.id)
+	if q.question != nil {
+		binary.BigEndian.PutUint16(b[4:6], 1)
+		b = append(b, q.question...)
+	}
+
+	answers := 0
+	for _, ip := range ips {
+		rtype, data := uint16(typeAAAA), ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			rtype, data = typeA, ip4
+		}
+		if len(b)+12+len(data) > maxUDPSize {
+			flags |= flagTC
+			break
+		}
+		// the name points back to the one in the question
+		rr := make([]byte, 12, 12+len(data))
+		binary.BigEndian.PutUint16(rr[0:2], 0xc000|headerSize)
+		binary.BigEndian.PutUint16(rr[2:4], rtype)
+		binary.BigEndian.PutUint16(rr[4:6], classIN)
+		binary.BigEndian.PutUint32(rr[6:10], ttl)
+		binary.BigEndian.PutUint16(rr[10:12], uint16(len(data)))
+		b = append(b, append(rr, data...)...)
+		answers++
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(flags))
+	binary.BigEndian.PutUint16(b[6:8], uint16(answers))
+	return b
+}
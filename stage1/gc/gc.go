@@ -0,0 +1,135 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package gc implements the garbage collection of exited containers and of the
+// images cached on the host.
+package gc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/logray"
+)
+
+// Options configures what the Collector removes, and how often.
+type Options struct {
+	// ExitedRetention is how long containers are kept once all of their apps
+	// have exited before they are destroyed.
+	ExitedRetention time.Duration
+
+	// ImageCacheSize is the maximum number of bytes of cached images to keep.
+	// The least recently used images are removed first.
+	ImageCacheSize int64
+
+	// Interval is how often the Collector runs in the background once it is
+	// started. It is not run in the background if it is 0.
+	Interval time.Duration
+}
+
+// Result lists what was removed by a collection.
+type Result struct {
+	// Containers are the UUIDs of the containers which were destroyed.
+	Containers []string
+
+	// Images are the URIs of the images which were removed, and ImageBytes
+	// their total size.
+	Images     []string
+	ImageBytes int64
+
+	// Layers are the IDs of the image layers which were removed since no
+	// containers were using them.
+	Layers []string
+}
+
+// Collector removes the exited containers and cached images which are no longer
+// being kept.
+type Collector struct {
+	Log *logray.Logger
+
+	manager *container.Manager
+	images  *image.Store
+	options *Options
+	mutex   sync.Mutex
+}
+
+// New returns a Collector for the manager's containers and the images in the
+// store. The store may be nil if images aren't being cached.
+func New(manager *container.Manager, images *image.Store, options *Options) *Collector {
+	return &Collector{
+		Log:     logray.New(),
+		manager: manager,
+		images:  images,
+		options: options,
+	}
+}
+
+// Start begins running the Collector in the background every interval.
+func (c *Collector) Start() {
+	if c.options.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(c.options.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := c.Collect(); err != nil {
+				c.Log.Warnf("Garbage collection failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Collect destroys the containers which exited longer ago than the retention
+// period, then removes the image layers no longer in use and the least recently
+// used images until the cache fits within its size. Only one collection runs at
+// a time.
+func (c *Collector) Collect() (*Result, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	result := &Result{}
+	for _, ctr := range c.manager.Containers() {
+		if ctr.State() != container.EXITED {
+			continue
+		}
+		if time.Since(ctr.ExitTime()) < c.options.ExitedRetention {
+			continue
+		}
+
+		c.Log.Debugf("Destroying exited container %s", ctr.UUID())
+		if err := ctr.Stop(); err != nil {
+			c.Log.Warnf("Failed to destroy exited container %s: %v", ctr.UUID(), err)
+			continue
+		}
+		result.Containers = append(result.Containers, ctr.UUID())
+	}
+
+	layers, err := c.manager.PruneLayers()
+	for _, id := range layers {
+		c.Log.Debugf("Removed image layer %s", id)
+	}
+	result.Layers = layers
+	if err != nil {
+		return result, err
+	}
+
+	if c.images != nil {
+		removed, err := c.images.Prune(c.options.ImageCacheSize)
+		for _, img := range removed {
+			c.Log.Debugf("Removed cached image %s", img.URI)
+			result.Images = append(result.Images, img.URI)
+			result.ImageBytes += img.Size
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if len(result.Containers) > 0 || len(result.Images) > 0 || len(result.Layers) > 0 {
+		c.Log.Infof("Garbage collection removed %d containers, %d images, and %d image layers",
+			len(result.Containers), len(result.Images), len(result.Layers))
+	}
+	return result, nil
+}
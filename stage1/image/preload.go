@@ -0,0 +1,225 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apcera/kurma/util/remote"
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+)
+
+const (
+	// preloadExt is the extension of the images which are preloaded.
+	preloadExt = ".aci"
+
+	// urisExt and signatureExt are appended to an image's file name for the
+	// files listing the URIs it is stored under and holding its signature.
+	urisExt      = ".uris"
+	signatureExt = ".asc"
+)
+
+// Preload imports the images within the directory into the store, so that
+// containers can be created from them without retrieving them, such as on hosts
+// without network access. Each .aci file, which may instead hold an OCI image
+// layout that is converted to an ACI, is stored under its name, followed by its
+// version label if it has one, in the form NAME:VERSION, which is how it is
+// retrieved through discovery. It is also stored under each of the URIs listed
+// on the lines of a file with .uris appended to its name, such as
+// app.aci.uris, so that it is used in place of retrieving them.
+//
+// Images are read in full to check that they're complete. Those with a
+// signature in a file with .asc appended to their name, which verifies against
+// the trusted keys, are stored as verified, while the rest are only used for
+// insecure retrievals. Images already held under a URI with the same contents
+// are left as they are. Preloaded images aren't pruned, since they may not be
+// retrievable again. The images which were imported are returned, along with
+// an error describing each image which couldn't be.
+func (s *Store) Preload(dir string) ([]Image, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+preloadExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var imported []Image
+	var failures []string
+	for _, name := range names {
+		images, err := s.preload(name)
+		imported = append(imported, images...)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", filepath.Base(name), err))
+		}
+	}
+	if len(failures) > 0 {
+		return imported, fmt.Errorf("failed to preload %s", strings.Join(failures, "; "))
+	}
+	return imported, nil
+}
+
+// preload imports the image file under each of its URIs which it isn't already
+// held under.
+func (s *Store) preload(name string) ([]Image, error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	// OCI image layouts are converted first, so that the ACI is what's held
+	f, err := remote.OpenImage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest, err := validateImage(f)
+	if err != nil {
+		return nil, err
+	}
+	uris, err := preloadURIs(name, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature is checked as it is for local files which are retrieved
+	verified := false
+	if _, err := os.Stat(name + signatureExt); err == nil {
+		r, err := remote.RetrieveImage("file://"+name, false)
+		if err != nil {
+			return nil, err
+		}
+		r.Close()
+		verified = true
+	}
+
+	sum, err := hashFile(f)
+	if err != nil {
+		return nil, err
+	}
+	var imported []Image
+	for _, uri := range uris {
+		held := s.holds(uri, sum, verified)
+		if !held {
+			if _, err := f.Seek(0, 0); err != nil {
+				return imported, err
+			}
+			stored, err := s.add(uri, verified, f)
+			if err != nil {
+				return imported, err
+			}
+			stored.Close()
+		}
+
+		// images held from being retrieved before are kept from now on too
+		s.mutex.Lock()
+		img := s.images[uri]
+		if img != nil && !img.Preloaded {
+			img.Preloaded = true
+			err = s.saveIndex()
+		}
+		if img != nil && !held {
+			imported = append(imported, *img)
+		}
+		s.mutex.Unlock()
+		if err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// holds returns whether the store holds the image with the hash under the URI,
+// and as verified if it is.
+func (s *Store) holds(uri string, sum []byte, verified bool) bool {
+	s.mutex.Lock()
+	img := s.images[uri]
+	s.mutex.Unlock()
+	if img == nil || (verified && !img.Verified) {
+		return false
+	}
+	f, err := os.Open(filepath.Join(s.directory, img.File))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stored, err := hashFile(f)
+	return err == nil && bytes.Equal(stored, sum)
+}
+
+// validateImage reads the whole of the image, returning its manifest, to check
+// that it is a complete archive.
+func validateImage(f io.ReadSeeker) (*schema.ImageManifest, error) {
+	manifest, err := remote.FindManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	arch, err := tarhelper.DetectArchiveCompression(f)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := arch.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("the image is incomplete: %v", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, arch); err != nil {
+			return nil, fmt.Errorf("the image is incomplete: %v", err)
+		}
+	}
+	return manifest, nil
+}
+
+// preloadURIs returns the URIs the image file is stored under: its name and
+// version, and those listed in its .uris file.
+func preloadURIs(name string, manifest *schema.ImageManifest) ([]string, error) {
+	uri := manifest.Name.String()
+	if version, ok := manifest.GetLabel("version"); ok {
+		uri += ":" + version
+	}
+	uris := []string{uri}
+
+	f, err := os.Open(name + urisExt)
+	if os.IsNotExist(err) {
+		return uris, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !cacheable(line) {
+			return nil, fmt.Errorf("local file URI %q can't be preloaded", line)
+		}
+		uris = append(uris, line)
+	}
+	return uris, scanner.Err()
+}
+
+// hashFile returns the SHA-512 hash of the image's contents.
+func hashFile(f io.ReadSeeker) ([]byte, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
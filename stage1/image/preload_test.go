@@ -0,0 +1,104 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// testACI returns an ACI holding the manifest and a file.
+func testACI(t *testing.T, manifest string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range []struct{ name, content string }{
+		{"manifest", manifest},
+		{"rootfs/hello", "hello"},
+	} {
+		TestExpectSuccess(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))}))
+		_, err := tw.Write([]byte(file.content))
+		TestExpectSuccess(t, err)
+	}
+	TestExpectSuccess(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestStorePreload(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	app := testACI(t, `{"acKind": "ImageManifest", "acVersion": "0.7.4", "name": "example.com/app",
+		"labels": [{"name": "version", "value": "1.0"}]}`)
+	tool := testACI(t, `{"acKind": "ImageManifest", "acVersion": "0.7.4", "name": "example.com/tool"}`)
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "app.aci"), app, 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "app.aci.uris"),
+		[]byte("# mirrors\nhttps://example.com/app-1.0.aci\n\ndocker://example/app:1.0\n"), 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "tool.aci"), tool, 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0644))
+
+	s, err := NewStore(TempDir(t))
+	TestExpectSuccess(t, err)
+
+	// Test 1: The images are stored under their names and listed URIs, as
+	// unverified without signatures.
+	imported, err := s.Preload(dir)
+	TestExpectSuccess(t, err)
+	var uris []string
+	for _, img := range imported {
+		uris = append(uris, img.URI)
+		TestEqual(t, img.Verified, false)
+		TestEqual(t, img.Preloaded, true)
+	}
+	TestEqual(t, uris, []string{
+		"example.com/app:1.0",
+		"https://example.com/app-1.0.aci",
+		"docker://example/app:1.0",
+		"example.com/tool",
+	})
+	f, err := s.Retrieve("https://example.com/app-1.0.aci", true)
+	TestExpectSuccess(t, err)
+	b, err := ioutil.ReadAll(f)
+	TestExpectSuccess(t, err)
+	f.Close()
+	TestEqual(t, b, app)
+
+	// Test 2: Images which are already held aren't imported again, while those
+	// which changed are.
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "tool.aci"), testACI(t,
+		`{"acKind": "ImageManifest", "acVersion": "0.7.4", "name": "example.com/tool",
+		"labels": [{"name": "os", "value": "linux"}]}`), 0644))
+	imported, err = s.Preload(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(imported), 1)
+	TestEqual(t, imported[0].URI, "example.com/tool")
+
+	// Test 3: Incomplete images, those without manifests, and those whose
+	// signatures can't be verified are errors, while the rest are still
+	// imported. The truncated image ends within its second file.
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "truncated.aci"), app[:3*512+2], 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "signed.aci"), app, 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "signed.aci.asc"), []byte("bad"), 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "empty.aci"), nil, 0644))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "new.aci"), testACI(t,
+		`{"acKind": "ImageManifest", "acVersion": "0.7.4", "name": "example.com/new"}`), 0644))
+	imported, err = s.Preload(dir)
+	TestExpectError(t, err)
+	TestEqual(t, len(imported), 1)
+	TestEqual(t, imported[0].URI, "example.com/new")
+	TestEqual(t, len(s.Images()), 5)
+
+	// Test 4: Preloaded images aren't pruned, unlike those retrieved.
+	_, err = s.Add("example.com/built", bytes.NewReader(app))
+	TestExpectSuccess(t, err)
+	removed, err := s.Prune(0)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(removed), 1)
+	TestEqual(t, removed[0].URI, "example.com/built")
+	TestEqual(t, len(s.Images()), 5)
+}
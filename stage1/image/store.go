@@ -0,0 +1,359 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package image implements the cache of images which have been retrieved from
+// remote locations, so that containers can be created from them again without
+// retrieving them each time.
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/util/remote"
+)
+
+const (
+	// indexFile is the name of the file within the store's directory which
+	// records the cached images.
+	indexFile = "index.json"
+
+	// downloadPrefix is the prefix of the temporary files images are written to
+	// as they're added to the store.
+	downloadPrefix = ".download-"
+)
+
+// Image describes an image held in the store.
+type Image struct {
+	// URI is the location the image was retrieved from.
+	URI string `json:"uri"`
+
+	// File is the name of the image's file within the store's directory.
+	File string `json:"file"`
+
+	// Size is the size of the image's file in bytes.
+	Size int64 `json:"size"`
+
+	// Verified is whether the image's signature was verified when it was
+	// retrieved. Unverified images are only used for insecure retrievals.
+	Verified bool `json:"verified"`
+
+	// LastUsed is when a container was last created from the image.
+	LastUsed time.Time `json:"last_used"`
+
+	// Preloaded is whether the image was imported with Preload, which keeps it
+	// from being pruned.
+	Preloaded bool `json:"preloaded,omitempty"`
+}
+
+// Store caches retrieved images within a directory, keyed by the URI they were
+// retrieved from. Local file URIs are not cached.
+type Store struct {
+	directory string
+	images    map[string]*Image
+	mutex     sync.Mutex
+}
+
+// NewStore opens the store within the directory, creating it if needed. Any
+// images recorded in its index whose files are missing are forgotten, and any
+// incomplete downloads are removed.
+func NewStore(directory string) (*Store, error) {
+	if err := os.MkdirAll(directory, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		directory: directory,
+		images:    make(map[string]*Image),
+	}
+	b, err := ioutil.ReadFile(filepath.Join(directory, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		var images []*Image
+		if err := json.Unmarshal(b, &images); err != nil {
+			return nil, err
+		}
+		for _, img := range images {
+			if _, err := os.Stat(filepath.Join(directory, img.File)); err == nil {
+				s.images[img.URI] = img
+			}
+		}
+	}
+
+	fis, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), downloadPrefix) {
+			os.Remove(filepath.Join(directory, fi.Name()))
+		}
+	}
+	return s, nil
+}
+
+// Retrieve returns the image from the URI, using the cached copy if there is
+// one. Otherwise it is retrieved with remote.RetrieveImage and added to the
+// store. Unless insecure is set, only images whose signature was verified are
+// used.
+func (s *Store) Retrieve(uri string, insecure bool) (remote.ReaderCloserSeeker, error) {
+	if !cacheable(uri) {
+		return remote.RetrieveImage(uri, insecure)
+	}
+	if f := s.open(uri, insecure); f != nil {
+		return f, nil
+	}
+
+	r, err := remote.RetrieveImage(uri, insecure)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return s.add(uri, !insecure, r)
+}
+
+// Add stores the image under the URI, replacing any stored under it, and
+// returns its description. Images added directly, such as those committed from
+// containers, were produced on the host, so they're trusted as verified.
+func (s *Store) Add(uri string, r io.Reader) (Image, error) {
+	f, err := s.add(uri, true, r)
+	if err != nil {
+		return Image{}, err
+	}
+	f.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return *s.images[uri], nil
+}
+
+// Open returns the stored copy of the image retrieved from the URI, such as to
+// export it, without it counting as a use of the image.
+func (s *Store) Open(uri string) (*os.File, error) {
+	s.mutex.Lock()
+	img := s.images[uri]
+	s.mutex.Unlock()
+	if img == nil {
+		return nil, fmt.Errorf("image %q is not in the store", uri)
+	}
+	return os.Open(filepath.Join(s.directory, img.File))
+}
+
+// Remove removes the image stored under the URI. Containers already created
+// from it are unaffected, since their filesystems were extracted from it.
+func (s *Store) Remove(uri string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	img := s.images[uri]
+	if img == nil {
+		return fmt.Errorf("image %q is not in the store", uri)
+	}
+	if err := os.Remove(filepath.Join(s.directory, img.File)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.images, uri)
+	return s.saveIndex()
+}
+
+// Directory returns the directory the images are stored within.
+func (s *Store) Directory() string {
+	return s.directory
+}
+
+// Images returns the images held in the store, ordered from the least recently
+// used.
+func (s *Store) Images() []Image {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sorted()
+}
+
+// Prune removes the least recently used images until the total size of those
+// remaining is no more than maxSize bytes. Preloaded images are kept, though
+// they count towards the size. It returns the images which were removed.
+func (s *Store) Prune(maxSize int64) ([]Image, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	images := s.sorted()
+	var total int64
+	for _, img := range images {
+		total += img.Size
+	}
+
+	var removed []Image
+	for _, img := range images {
+		if total <= maxSize {
+			break
+		}
+		if img.Preloaded {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.directory, img.File)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		delete(s.images, img.URI)
+		total -= img.Size
+		removed = append(removed, img)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, s.saveIndex()
+}
+
+// open returns the cached copy of the image from the URI, or nil if there is
+// no usable copy.
+func (s *Store) open(uri string, insecure bool) *os.File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	img := s.images[uri]
+	if img == nil || (!img.Verified && !insecure) {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(s.directory, img.File))
+	if err != nil {
+		return nil
+	}
+	img.LastUsed = time.Now()
+	s.saveIndex()
+	return f
+}
+
+// add writes the image to the store and returns the stored copy.
+func (s *Store) add(uri string, verified bool, r io.Reader) (*os.File, error) {
+	tmp, err := ioutil.TempFile(s.directory, downloadPrefix)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		size, err = convertLayout(tmp.Name(), size)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	// each URI has its own file, so a newer image replaces the previous one
+	sum := sha256.Sum256([]byte(uri))
+	name := hex.EncodeToString(sum[:]) + ".aci"
+	path := filepath.Join(s.directory, name)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	s.images[uri] = &Image{
+		URI:      uri,
+		File:     name,
+		Size:     size,
+		Verified: verified,
+		LastUsed: time.Now(),
+	}
+	if err := s.saveIndex(); err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// convertLayout replaces the file with the ACI converted from it if it holds an
+// OCI image layout, so that the store only holds ACIs. It returns the size of
+// the file.
+func convertLayout(name string, size int64) (int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if layout, err := remote.IsOCILayout(f); err != nil || !layout {
+		return size, nil
+	}
+
+	out, err := ioutil.TempFile(filepath.Dir(name), downloadPrefix)
+	if err != nil {
+		return 0, err
+	}
+	_, err = remote.ConvertOCILayout(out, f)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err == nil {
+		size, err = out.Seek(0, 1)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), name)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return 0, err
+	}
+	return size, nil
+}
+
+// sorted returns copies of the images ordered from the least recently used.
+// The store's mutex must be held.
+func (s *Store) sorted() []Image {
+	images := make([]Image, 0, len(s.images))
+	for _, img := range s.images {
+		images = append(images, *img)
+	}
+	sort.Sort(byLastUsed(images))
+	return images
+}
+
+// saveIndex writes the list of images to the index file. The store's mutex
+// must be held.
+func (s *Store) saveIndex() error {
+	images := make([]*Image, 0, len(s.images))
+	for _, img := range s.images {
+		images = append(images, img)
+	}
+	b, err := json.Marshal(images)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.directory, indexFile)
+	if err := ioutil.WriteFile(path+".tmp", b, os.FileMode(0644)); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// cacheable returns whether images from the URI should be stored. Local files
+// are used directly.
+func cacheable(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme != "file"
+}
+
+// byLastUsed sorts images from the least recently used.
+type byLastUsed []Image
+
+func (s byLastUsed) Len() int           { return len(s) }
+func (s byLastUsed) Less(i, j int) bool { return s[i].LastUsed.Before(s[j].LastUsed) }
+func (s byLastUsed) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
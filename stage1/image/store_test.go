@@ -0,0 +1,271 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/util/remote"
+	. "github.com/apcera/util/testtool"
+)
+
+// imageServer serves the contents of the images by their path, counting the
+// number of GET requests.
+func imageServer(images map[string]string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			*requests++
+		}
+		content, ok := images[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+}
+
+func TestStoreRetrieve(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	requests := 0
+	server := imageServer(map[string]string{"/a.aci": "image a"}, &requests)
+	defer server.Close()
+
+	dir := TempDir(t)
+	s, err := NewStore(dir)
+	TestExpectSuccess(t, err)
+
+	// Test 1: The image is retrieved and then served from the store.
+	for i := 0; i < 2; i++ {
+		f, err := s.Retrieve(server.URL+"/a.aci", true)
+		TestExpectSuccess(t, err)
+		b, err := ioutil.ReadAll(f)
+		TestExpectSuccess(t, err)
+		f.Close()
+		TestEqual(t, string(b), "image a")
+	}
+	TestEqual(t, requests, 1)
+	images := s.Images()
+	TestEqual(t, len(images), 1)
+	TestEqual(t, images[0].Size, int64(len("image a")))
+	TestEqual(t, images[0].Verified, false)
+
+	// Test 2: Unverified images aren't used for secure retrievals, so the image
+	// and its missing signature are requested.
+	_, err = s.Retrieve(server.URL+"/a.aci", false)
+	TestExpectError(t, err)
+	TestEqual(t, requests, 3)
+
+	// Test 3: The store is reloaded from its index, forgetting images whose
+	// files are missing and removing incomplete downloads.
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, downloadPrefix+"1"), nil, 0644))
+	s, err = NewStore(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(s.Images()), 1)
+	_, err = os.Stat(filepath.Join(dir, downloadPrefix+"1"))
+	TestEqual(t, os.IsNotExist(err), true)
+
+	TestExpectSuccess(t, os.Remove(filepath.Join(dir, images[0].File)))
+	s, err = NewStore(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(s.Images()), 0)
+
+	// Test 4: Stored images can be opened without being marked as used, while
+	// other URIs are errors.
+	f, err := s.Retrieve(server.URL+"/a.aci", true)
+	TestExpectSuccess(t, err)
+	f.Close()
+	used := s.Images()[0].LastUsed
+	f, err = s.Open(server.URL + "/a.aci")
+	TestExpectSuccess(t, err)
+	b, err := ioutil.ReadAll(f)
+	TestExpectSuccess(t, err)
+	f.Close()
+	TestEqual(t, string(b), "image a")
+	TestEqual(t, s.Images()[0].LastUsed, used)
+	_, err = s.Open(server.URL + "/b.aci")
+	TestExpectError(t, err)
+	TestExpectSuccess(t, os.Remove(filepath.Join(dir, s.Images()[0].File)))
+	s, err = NewStore(dir)
+	TestExpectSuccess(t, err)
+
+	// Test 5: Local files aren't stored.
+	local := filepath.Join(TempDir(t), "local.aci")
+	TestExpectSuccess(t, ioutil.WriteFile(local, []byte("local"), 0644))
+	f, err = s.Retrieve("file://"+local, true)
+	TestExpectSuccess(t, err)
+	f.Close()
+	TestEqual(t, len(s.Images()), 0)
+}
+
+// testOCILayout returns an OCI image layout holding an image with a single
+// layer which has an executable hello file.
+func testOCILayout(t *testing.T) []byte {
+	writeTar := func(files ...string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for i := 0; i < len(files); i += 2 {
+			TestExpectSuccess(t, tw.WriteHeader(&tar.Header{Name: files[i], Mode: 0755, Size: int64(len(files[i+1]))}))
+			_, err := tw.Write([]byte(files[i+1]))
+			TestExpectSuccess(t, err)
+		}
+		TestExpectSuccess(t, tw.Close())
+		return buf.Bytes()
+	}
+	digest := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+
+	layer := writeTar("hello", "hello")
+	config := []byte(`{"os": "linux", "config": {"Cmd": ["/hello"]}}`)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion": 2, "config": {"digest": "sha256:%s"}, "layers": [{"digest": "sha256:%s"}]}`,
+		digest(config), digest(layer)))
+	index := fmt.Sprintf(`{"schemaVersion": 2, "manifests": [{"digest": "sha256:%s",
+		"annotations": {"org.opencontainers.image.ref.name": "example.com/hello:1.0"}}]}`, digest(manifest))
+	return writeTar(
+		"oci-layout", `{"imageLayoutVersion": "1.0.0"}`,
+		"index.json", index,
+		"blobs/sha256/"+digest(layer), string(layer),
+		"blobs/sha256/"+digest(config), string(config),
+		"blobs/sha256/"+digest(manifest), string(manifest))
+}
+
+func TestStoreAdd(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s, err := NewStore(TempDir(t))
+	TestExpectSuccess(t, err)
+
+	// Test 1: Added images are used for secure retrievals without being
+	// retrieved.
+	img, err := s.Add("example.com/debug:v1", strings.NewReader("committed"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, img.Size, int64(len("committed")))
+	TestEqual(t, img.Verified, true)
+	f, err := s.Retrieve("example.com/debug:v1", false)
+	TestExpectSuccess(t, err)
+	b, err := ioutil.ReadAll(f)
+	TestExpectSuccess(t, err)
+	f.Close()
+	TestEqual(t, string(b), "committed")
+
+	// Test 2: Adding an image again replaces it.
+	_, err = s.Add("example.com/debug:v1", strings.NewReader("again"))
+	TestExpectSuccess(t, err)
+	f, err = s.Open("example.com/debug:v1")
+	TestExpectSuccess(t, err)
+	b, err = ioutil.ReadAll(f)
+	TestExpectSuccess(t, err)
+	f.Close()
+	TestEqual(t, string(b), "again")
+	TestEqual(t, len(s.Images()), 1)
+
+	// Test 3: OCI image layouts are stored as the ACI they convert to.
+	img, err = s.Add("example.com/hello:1.0", bytes.NewReader(testOCILayout(t)))
+	TestExpectSuccess(t, err)
+	stored, err := s.Open("example.com/hello:1.0")
+	TestExpectSuccess(t, err)
+	defer stored.Close()
+	fi, err := stored.Stat()
+	TestExpectSuccess(t, err)
+	TestEqual(t, img.Size, fi.Size())
+	layout, err := remote.IsOCILayout(stored)
+	TestExpectSuccess(t, err)
+	TestEqual(t, layout, false)
+	manifest, err := remote.FindManifest(stored)
+	TestExpectSuccess(t, err)
+	TestEqual(t, manifest.Name.String(), "example.com/hello")
+	TestEqual(t, []string(manifest.App.Exec), []string{"/hello"})
+}
+
+func TestStorePrune(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	requests := 0
+	server := imageServer(map[string]string{
+		"/a.aci": "aaaa",
+		"/b.aci": "bbbb",
+		"/c.aci": "cccc",
+	}, &requests)
+	defer server.Close()
+
+	s, err := NewStore(TempDir(t))
+	TestExpectSuccess(t, err)
+	for _, name := range []string{"a", "b", "c"} {
+		f, err := s.Retrieve(server.URL+"/"+name+".aci", true)
+		TestExpectSuccess(t, err)
+		f.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// using a makes b the least recently used
+	f, err := s.Retrieve(server.URL+"/a.aci", true)
+	TestExpectSuccess(t, err)
+	f.Close()
+
+	// Test 1: Nothing is removed while the images fit.
+	removed, err := s.Prune(12)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(removed), 0)
+
+	// Test 2: The least recently used images are removed first.
+	removed, err = s.Prune(5)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(removed), 2)
+	TestEqual(t, removed[0].URI, server.URL+"/b.aci")
+	TestEqual(t, removed[1].URI, server.URL+"/c.aci")
+	images := s.Images()
+	TestEqual(t, len(images), 1)
+	TestEqual(t, images[0].URI, server.URL+"/a.aci")
+
+	// Test 3: A size of 0 removes all of the images.
+	removed, err = s.Prune(0)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(removed), 1)
+	TestEqual(t, len(s.Images()), 0)
+}
+
+func TestStoreRemove(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	s, err := NewStore(dir)
+	TestExpectSuccess(t, err)
+	img, err := s.Add("example.com/debug:v1", strings.NewReader("committed"))
+	TestExpectSuccess(t, err)
+	_, err = s.Add("example.com/debug:v2", strings.NewReader("again"))
+	TestExpectSuccess(t, err)
+
+	// Test 1: The image's file and its entry in the index are removed.
+	TestExpectSuccess(t, s.Remove("example.com/debug:v1"))
+	_, err = os.Stat(filepath.Join(dir, img.File))
+	TestEqual(t, os.IsNotExist(err), true)
+	_, err = s.Open("example.com/debug:v1")
+	TestExpectError(t, err)
+	s, err = NewStore(dir)
+	TestExpectSuccess(t, err)
+	images := s.Images()
+	TestEqual(t, len(images), 1)
+	TestEqual(t, images[0].URI, "example.com/debug:v2")
+
+	// Test 2: Removing an image which isn't held is an error.
+	TestExpectError(t, s.Remove("example.com/debug:v1"))
+}
@@ -0,0 +1,382 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package jobs runs containers on a schedule, as cron does, keeping a history
+// of their runs.
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/logray"
+)
+
+// MaxRuns is how many of each job's most recent runs are kept in its history.
+const MaxRuns = 10
+
+// Job is a container which is created from the same spec each time its
+// schedule comes around.
+type Job struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Created  time.Time `json:"created"`
+
+	// Spec describes the container to create, and is only interpreted by the
+	// scheduler's CreateFunc.
+	Spec json.RawMessage `json:"spec"`
+
+	// Runs are the most recent runs of the job, oldest first.
+	Runs []*Run `json:"runs,omitempty"`
+
+	// Next is when the job is next run, and Running whether it is running
+	// now. They're set on the jobs the Scheduler returns.
+	Next    time.Time `json:"-"`
+	Running bool      `json:"-"`
+}
+
+// Run records one run of a job. The container is left in place once it exits,
+// until the job's next run or the garbage collector destroys it, so its output
+// can be retrieved.
+type Run struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Container string    `json:"container,omitempty"`
+	ExitCode  int       `json:"exit_code"`
+	OOMKilled bool      `json:"oom_killed,omitempty"`
+
+	// Error is why the container couldn't be created or failed to start.
+	Error string `json:"error,omitempty"`
+}
+
+// CreateFunc creates a container for a run of the job from its spec.
+type CreateFunc func(job *Job) (*container.Container, error)
+
+// jobNameRegexp matches the names jobs may be given.
+var jobNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateName returns an error if the name can't be used for a job.
+func ValidateName(name string) error {
+	if len(name) > 64 || !jobNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid job name %q, it must start with a letter or digit and contain only letters, digits, '_', '.', and '-'", name)
+	}
+	return nil
+}
+
+// scheduledJob is a job along with its parsed schedule and the state of its
+// current run.
+type scheduledJob struct {
+	*Job
+	schedule  *Schedule
+	next      time.Time
+	running   bool
+	container string
+}
+
+// Scheduler creates the containers for the jobs as they come due, and waits
+// for them to exit to record their runs. The jobs and their history are saved
+// to a file so they persist across reboots. A job whose previous run is still
+// going when it comes due again is skipped until its following time.
+type Scheduler struct {
+	Log *logray.Logger
+
+	manager *container.Manager
+	path    string
+	create  CreateFunc
+	jobs    map[string]*scheduledJob
+	mutex   sync.Mutex
+	wakech  chan bool
+	stopch  chan bool
+	now     func() time.Time
+}
+
+// New returns a Scheduler for the jobs saved at the path, which creates their
+// containers with create. The file is created once a job is added.
+func New(manager *container.Manager, path string, create CreateFunc) (*Scheduler, error) {
+	s := &Scheduler{
+		Log:     logray.New(),
+		manager: manager,
+		path:    path,
+		create:  create,
+		jobs:    make(map[string]*scheduledJob),
+		wakech:  make(chan bool, 1),
+		stopch:  make(chan bool),
+		now:     time.Now,
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to load the jobs from %s: %v", path, err)
+	}
+	for _, j := range jobs {
+		schedule, err := ParseSchedule(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load job %q: %v", j.Name, err)
+		}
+		sj := &scheduledJob{Job: j, schedule: schedule, next: schedule.Next(s.now())}
+		if len(j.Runs) > 0 {
+			sj.container = j.Runs[len(j.Runs)-1].Container
+		}
+		s.jobs[j.Name] = sj
+	}
+	return s, nil
+}
+
+// Add adds the job, which is first run at the next time its schedule matches.
+func (s *Scheduler) Add(job *Job) error {
+	if err := ValidateName(job.Name); err != nil {
+		return err
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(s.now())
+	if next.IsZero() {
+		return fmt.Errorf("the schedule %q never matches", job.Schedule)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("a job named %q already exists", job.Name)
+	}
+	j := *job
+	j.Created = s.now()
+	j.Runs = nil
+	s.jobs[j.Name] = &scheduledJob{Job: &j, schedule: schedule, next: next}
+	if err := s.save(); err != nil {
+		delete(s.jobs, j.Name)
+		return err
+	}
+	s.wake()
+	return nil
+}
+
+// Remove removes the job. A run which is in progress is left to finish, but
+// isn't recorded.
+func (s *Scheduler) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("no job named %q exists", name)
+	}
+	delete(s.jobs, name)
+	if err := s.save(); err != nil {
+		s.jobs[name] = j
+		return err
+	}
+	s.wake()
+	return nil
+}
+
+// Jobs returns copies of the jobs, sorted by name.
+func (s *Scheduler) Jobs() []*Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, sj := range s.jobs {
+		j := *sj.Job
+		j.Runs = make([]*Run, len(sj.Runs))
+		for i, r := range sj.Runs {
+			run := *r
+			j.Runs[i] = &run
+		}
+		j.Next = sj.next
+		j.Running = sj.running
+		jobs = append(jobs, &j)
+	}
+	sort.Sort(jobsByName(jobs))
+	return jobs
+}
+
+type jobsByName []*Job
+
+func (j jobsByName) Len() int           { return len(j) }
+func (j jobsByName) Less(a, b int) bool { return j[a].Name < j[b].Name }
+func (j jobsByName) Swap(a, b int)      { j[a], j[b] = j[b], j[a] }
+
+// Start begins running the jobs as they come due in the background.
+func (s *Scheduler) Start() {
+	go s.loop()
+}
+
+// Stop stops running jobs. Runs which are in progress are left to finish.
+func (s *Scheduler) Stop() {
+	close(s.stopch)
+}
+
+// wake has the loop recompute when the next job is due. The mutex must be held.
+func (s *Scheduler) wake() {
+	select {
+	case s.wakech <- true:
+	default:
+	}
+}
+
+// loop sleeps until the next job is due, and starts the runs of all the jobs
+// which are.
+func (s *Scheduler) loop() {
+	for {
+		timer := time.NewTimer(s.runDue())
+		select {
+		case <-timer.C:
+		case <-s.wakech:
+		case <-s.stopch:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// runDue starts the runs of the jobs which are due, and returns how long until
+// the next one is.
+func (s *Scheduler) runDue() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	wait := time.Hour
+	for _, sj := range s.jobs {
+		if !sj.next.IsZero() && !sj.next.After(now) {
+			if sj.running {
+				s.Log.Warnf("Skipping job %s, as its previous run is still going", sj.Name)
+			} else {
+				sj.running = true
+				go s.run(sj)
+			}
+			sj.next = sj.schedule.Next(now)
+		}
+		if !sj.next.IsZero() && sj.next.Sub(now) < wait {
+			wait = sj.next.Sub(now)
+		}
+	}
+	return wait
+}
+
+// run creates the job's container, waits for it to exit, and records the run.
+// The container of the job's previous run is destroyed first.
+func (s *Scheduler) run(sj *scheduledJob) {
+	s.mutex.Lock()
+	previous := sj.container
+	sj.container = ""
+	s.mutex.Unlock()
+	if previous != "" {
+		if c := s.manager.Container(previous); c != nil {
+			if err := c.Stop(); err != nil {
+				s.Log.Warnf("Failed to destroy the previous container %s of job %s: %v", previous, sj.Name, err)
+			}
+		}
+	}
+
+	s.Log.Infof("Running job %s", sj.Name)
+	run := &Run{Start: s.now()}
+	c, err := s.create(sj.Job)
+	if err == nil {
+		run.Container = c.UUID()
+		s.mutex.Lock()
+		sj.container = run.Container
+		s.mutex.Unlock()
+		err = s.wait(c)
+	}
+	if err != nil {
+		s.Log.Errorf("Job %s failed: %v", sj.Name, err)
+		run.Error = err.Error()
+	} else {
+		run.ExitCode = c.ExitCode()
+		run.OOMKilled = c.OOMKilled()
+		s.Log.Infof("Job %s exited with code %d", sj.Name, run.ExitCode)
+	}
+	run.End = s.now()
+	s.record(sj, run)
+}
+
+// waitPollInterval is how often a run's container is checked for having been
+// destroyed before it exited.
+const waitPollInterval = 10 * time.Second
+
+// wait blocks until the container exits, returning an error if it failed to
+// start or was destroyed first.
+func (s *Scheduler) wait(c *container.Container) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	exited := c.Exited()
+	for {
+		select {
+		case <-exited:
+			if err := c.StartError(); err != nil {
+				return fmt.Errorf("the container failed to start: %v", err)
+			}
+			return nil
+		case <-ticker.C:
+			if s.manager.Container(c.UUID()) == nil {
+				return fmt.Errorf("the container was destroyed before it exited")
+			}
+		}
+	}
+}
+
+// record adds the run to the job's history, unless the job has been removed.
+func (s *Scheduler) record(sj *scheduledJob, run *Run) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sj.running = false
+	if s.jobs[sj.Name] != sj {
+		return
+	}
+	sj.Runs = append(sj.Runs, run)
+	if len(sj.Runs) > MaxRuns {
+		sj.Runs = append([]*Run(nil), sj.Runs[len(sj.Runs)-MaxRuns:]...)
+	}
+	if err := s.save(); err != nil {
+		s.Log.Errorf("Failed to save the jobs: %v", err)
+	}
+}
+
+// save writes the jobs to the file, replacing it atomically. The mutex must be
+// held.
+func (s *Scheduler) save() error {
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, sj := range s.jobs {
+		jobs = append(jobs, sj.Job)
+	}
+	sort.Sort(jobsByName(jobs))
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), ".jobs")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	. "github.com/apcera/util/testtool"
+)
+
+func TestSchedulerJobs(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), "jobs.json")
+	s, err := New(nil, path, nil)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(s.Jobs()), 0)
+
+	spec := []byte(`{"image_uri":"docker://busybox"}`)
+	TestExpectSuccess(t, s.Add(&Job{Name: "backup", Schedule: "@daily", Spec: spec}))
+	TestExpectSuccess(t, s.Add(&Job{Name: "cleanup", Schedule: "*/5 * * * *", Spec: spec}))
+	TestExpectError(t, s.Add(&Job{Name: "backup", Schedule: "@hourly", Spec: spec}))
+	TestExpectError(t, s.Add(&Job{Name: "../bad", Schedule: "@hourly", Spec: spec}))
+	TestExpectError(t, s.Add(&Job{Name: "bad", Schedule: "every day", Spec: spec}))
+	TestExpectError(t, s.Add(&Job{Name: "never", Schedule: "0 0 31 2 *", Spec: spec}))
+
+	jobs := s.Jobs()
+	TestEqual(t, len(jobs), 2)
+	TestEqual(t, jobs[0].Name, "backup")
+	TestEqual(t, jobs[1].Name, "cleanup")
+	TestFalse(t, jobs[0].Next.IsZero())
+
+	// the jobs are loaded again from the file
+	s, err = New(nil, path, nil)
+	TestExpectSuccess(t, err)
+	jobs = s.Jobs()
+	TestEqual(t, len(jobs), 2)
+	TestEqual(t, jobs[1].Schedule, "*/5 * * * *")
+	var loaded map[string]string
+	TestExpectSuccess(t, json.Unmarshal(jobs[1].Spec, &loaded))
+	TestEqual(t, loaded["image_uri"], "docker://busybox")
+
+	TestExpectSuccess(t, s.Remove("backup"))
+	TestExpectError(t, s.Remove("backup"))
+	s, err = New(nil, path, nil)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(s.Jobs()), 1)
+}
+
+func TestSchedulerRunsDueJobs(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	created := make(chan string, 10)
+	create := func(job *Job) (*container.Container, error) {
+		created <- job.Name
+		return nil, fmt.Errorf("no image")
+	}
+
+	now := time.Date(2015, 3, 13, 10, 7, 30, 0, time.UTC)
+	s, err := New(nil, filepath.Join(TempDir(t), "jobs.json"), create)
+	TestExpectSuccess(t, err)
+	s.now = func() time.Time { return now }
+	TestExpectSuccess(t, s.Add(&Job{Name: "report", Schedule: "*/15 * * * *"}))
+
+	// nothing is due yet
+	TestEqual(t, s.runDue(), 7*time.Minute+30*time.Second)
+	TestEqual(t, len(created), 0)
+
+	// once it is due, it is run and its next run is scheduled
+	now = now.Add(8 * time.Minute)
+	TestEqual(t, s.runDue(), 14*time.Minute+30*time.Second)
+	TestEqual(t, <-created, "report")
+
+	// the failed run is recorded
+	var jobs []*Job
+	for i := 0; i < 100; i++ {
+		jobs = s.Jobs()
+		if len(jobs[0].Runs) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	TestEqual(t, len(jobs[0].Runs), 1)
+	TestEqual(t, jobs[0].Runs[0].Error, "no image")
+	TestFalse(t, jobs[0].Running)
+}
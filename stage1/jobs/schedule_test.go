@@ -0,0 +1,86 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParseSchedule(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	valid := []string{
+		"* * * * *",
+		"*/15 * * * *",
+		"0 3 * * mon-fri",
+		"30 2 1,15 * *",
+		"0 0 1 jan,jul *",
+		"5-55/10 8-18 * * 7",
+		"@daily",
+		"@Hourly",
+	}
+	for _, expr := range valid {
+		_, err := ParseSchedule(expr)
+		TestExpectSuccess(t, err)
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every",
+	}
+	for _, expr := range invalid {
+		_, err := ParseSchedule(expr)
+		TestExpectError(t, err)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Friday, March 13th 2015
+	now := time.Date(2015, 3, 13, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2015, 3, 13, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2015, 3, 13, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2015, 3, 13, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2015, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * mon-fri", time.Date(2015, 3, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2015, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2015, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2015, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2016, 2, 29, 12, 0, 0, 0, time.UTC)},
+
+		// the day of the month or week matches when both are restricted
+		{"0 0 20 * fri", time.Date(2015, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 14 * mon", time.Date(2015, 3, 14, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s, err := ParseSchedule(test.expr)
+		TestExpectSuccess(t, err)
+		TestEqual(t, s.Next(now), test.next, test.expr)
+	}
+
+	// a schedule which never matches has no next time
+	s, err := ParseSchedule("0 0 30 2 *")
+	TestExpectSuccess(t, err)
+	TestTrue(t, s.Next(now).IsZero())
+}
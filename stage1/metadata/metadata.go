@@ -0,0 +1,243 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package metadata implements the App Container metadata service, through
+// which apps retrieve their pod's manifest and annotations and have their
+// identity vouched for by the host.
+package metadata
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/appc/spec/schema/types"
+)
+
+// DefaultPort is the port the metadata service is served on, unless another
+// is configured.
+const DefaultPort = 18112
+
+const (
+	flavorHeader = "Metadata-Flavor"
+	flavor       = "AppContainer"
+	apiPrefix    = "acMetadata/v1/"
+)
+
+// Server serves the metadata of the manager's containers. Each container's
+// apps are given a URL with a token identifying the container, and the
+// requests under it are answered with that container's metadata:
+//
+//	GET  TOKEN/acMetadata/v1/pod/annotations/[NAME]
+//	GET  TOKEN/acMetadata/v1/pod/manifest
+//	GET  TOKEN/acMetadata/v1/pod/uuid
+//	GET  TOKEN/acMetadata/v1/apps/APP/annotations/[NAME]
+//	GET  TOKEN/acMetadata/v1/apps/APP/image/manifest
+//	GET  TOKEN/acMetadata/v1/apps/APP/image/id
+//	POST TOKEN/acMetadata/v1/pod/hmac/sign
+//	POST TOKEN/acMetadata/v1/pod/hmac/verify
+//
+// Signatures are an HMAC of the signing pod's UUID and the content, keyed by a
+// secret only the server knows, so a pod can prove its identity to another on
+// the host by having content signed which the other then verifies.
+type Server struct {
+	manager *container.Manager
+	key     []byte
+}
+
+// New returns a server for the manager's containers, with a new random key
+// to sign content with.
+func New(manager *container.Manager) (*Server, error) {
+	key := make([]byte, sha512.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate the signing key: %v", err)
+	}
+	return &Server{manager: manager, key: key}, nil
+}
+
+// Serve serves the metadata service on the listener until it fails.
+func (s *Server) Serve(l net.Listener) error {
+	return http.Serve(l, s)
+}
+
+// ServeHTTP answers a request for the metadata of the container its token
+// identifies.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set(flavorHeader, flavor)
+	if req.Header.Get(flavorHeader) != flavor {
+		http.Error(w, fmt.Sprintf("the %s: %s header is required", flavorHeader, flavor), http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], apiPrefix) {
+		http.NotFound(w, req)
+		return
+	}
+	c := s.manager.MetadataContainer(parts[0])
+	if c == nil {
+		http.NotFound(w, req)
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(parts[1], apiPrefix), "/")
+
+	switch {
+	case len(path) >= 2 && path[0] == "pod" && path[1] == "hmac":
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveHMAC(w, req, c, path[2:])
+	case req.Method != "GET" && req.Method != "HEAD":
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case path[0] == "pod":
+		s.servePod(w, req, c, path[1:])
+	case path[0] == "apps" && len(path) >= 2:
+		s.serveApp(w, req, c, path[1], path[2:])
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// servePod answers requests for the pod's own metadata.
+func (s *Server) servePod(w http.ResponseWriter, req *http.Request, c *container.Container, path []string) {
+	pod := c.Manifest()
+	switch {
+	case len(path) == 2 && path[0] == "annotations":
+		serveAnnotations(w, req, pod.Annotations, path[1])
+	case len(path) == 1 && path[0] == "manifest":
+		serveJSON(w, pod)
+	case len(path) == 1 && path[0] == "uuid":
+		serveText(w, c.UUID())
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveApp answers requests for the metadata of one of the pod's apps.
+func (s *Server) serveApp(w http.ResponseWriter, req *http.Request, c *container.Container, name string, path []string) {
+	ra := c.Manifest().Apps.Get(types.ACName(name))
+	if ra == nil {
+		http.NotFound(w, req)
+		return
+	}
+	image := c.ImageManifest()
+
+	switch {
+	case len(path) == 2 && path[0] == "annotations":
+		// the runtime app's annotations take precedence over the image's
+		annotations := append(types.Annotations(nil), image.Annotations...)
+		for _, a := range ra.Annotations {
+			annotations.Set(a.Name, a.Value)
+		}
+		serveAnnotations(w, req, annotations, path[1])
+	case len(path) == 2 && path[0] == "image" && path[1] == "manifest":
+		serveJSON(w, image)
+	case len(path) == 2 && path[0] == "image" && path[1] == "id":
+		if ra.Image.ID.Empty() {
+			http.NotFound(w, req)
+			return
+		}
+		serveText(w, ra.Image.ID.String())
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveHMAC signs content as the container's pod, or verifies the signature
+// of content signed by any pod on the host.
+func (s *Server) serveHMAC(w http.ResponseWriter, req *http.Request, c *container.Container, path []string) {
+	if len(path) != 1 {
+		http.NotFound(w, req)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	content := req.PostForm.Get("content")
+
+	switch path[0] {
+	case "sign":
+		serveText(w, s.sign(c.UUID(), content))
+	case "verify":
+		uuid := req.PostForm.Get("uuid")
+		signature := req.PostForm.Get("signature")
+		if uuid == "" || signature == "" {
+			http.Error(w, "the uuid and signature are required", http.StatusBadRequest)
+			return
+		}
+		if !s.verify(uuid, content, signature) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// sign returns the base64 encoded signature of the content by the pod.
+func (s *Server) sign(uuid, content string) string {
+	return base64.StdEncoding.EncodeToString(s.mac(uuid, content))
+}
+
+// verify returns whether the signature is of the content by the pod.
+func (s *Server) verify(uuid, content, signature string) bool {
+	b, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(b, s.mac(uuid, content))
+}
+
+func (s *Server) mac(uuid, content string) []byte {
+	h := hmac.New(sha512.New, s.key)
+	h.Write([]byte(uuid))
+	h.Write([]byte(content))
+	return h.Sum(nil)
+}
+
+// serveAnnotations writes the value of the named annotation, or the names of
+// all of them, one per line, if the name is empty.
+func serveAnnotations(w http.ResponseWriter, req *http.Request, annotations types.Annotations, name string) {
+	if name == "" {
+		names := make([]string, 0, len(annotations))
+		for _, a := range annotations {
+			names = append(names, string(a.Name))
+		}
+		sort.Strings(names)
+		serveText(w, strings.Join(names, "\n"))
+		return
+	}
+	value, ok := annotations.Get(name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	serveText(w, value)
+}
+
+func serveText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(s))
+}
+
+func serveJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
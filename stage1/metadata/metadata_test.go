@@ -0,0 +1,79 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/apcera/kurma/stage1/container"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	s, err := New(&container.Manager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := s.sign("pod-a", "hello")
+	if !s.verify("pod-a", "hello", sig) {
+		t.Fatal("expected the signature to verify")
+	}
+	if s.verify("pod-b", "hello", sig) {
+		t.Fatal("expected the signature not to verify for another pod")
+	}
+	if s.verify("pod-a", "goodbye", sig) {
+		t.Fatal("expected the signature not to verify for other content")
+	}
+	if s.verify("pod-a", "hello", "not base64!") {
+		t.Fatal("expected an invalid signature not to verify")
+	}
+
+	other, err := New(&container.Manager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.verify("pod-a", "hello", sig) {
+		t.Fatal("expected the signature not to verify with another key")
+	}
+}
+
+func TestServeHTTPRequests(t *testing.T) {
+	s, err := New(&container.Manager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		flavor bool
+		status int
+	}{
+		{"GET", "/token/acMetadata/v1/pod/uuid", false, http.StatusBadRequest},
+		{"GET", "/token/acMetadata/v1/pod/uuid", true, http.StatusNotFound},
+		{"GET", "/token/other/pod/uuid", true, http.StatusNotFound},
+		{"GET", "/", true, http.StatusNotFound},
+		{"POST", "/token/acMetadata/v1/pod/hmac/sign", true, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.path, strings.NewReader(url.Values{"content": {"x"}}.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.flavor {
+			req.Header.Set(flavorHeader, flavor)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.path, test.status, w.Code)
+		}
+		if w.Header().Get(flavorHeader) != flavor {
+			t.Errorf("%s %s: expected the %s header", test.method, test.path, flavorHeader)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package netpolicy restricts the traffic between the containers on the
+// container network, and from them to the host, with iptables rules generated
+// from policies which select the containers by their labels.
+package netpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/logray"
+)
+
+// Config describes the network the policies are applied to.
+type Config struct {
+	// Path is the file the policies are kept in, so they persist across
+	// reboots. It is created once a policy is set.
+	Path string
+
+	// Bridge is the bridge the containers are attached to.
+	Bridge string
+
+	// IPv6 is whether the containers have IPv6 addresses, which need rules
+	// of their own.
+	IPv6 bool
+}
+
+// MembersFunc returns the containers on the network.
+type MembersFunc func() []*Member
+
+// Engine keeps the policies, and programs the rules for them whenever they or
+// the containers on the network change.
+type Engine struct {
+	Log *logray.Logger
+
+	config   Config
+	members  MembersFunc
+	policies map[string]*Policy
+	mutex    sync.Mutex
+	wakech   chan bool
+	stopch   chan bool
+
+	// program replaces the rules in the policy chains with the input for
+	// iptables-restore, or ip6tables-restore if ipv6 is set.
+	program func(input string, ipv6 bool) error
+	ready   map[bool]bool
+}
+
+// New returns an Engine for the policies saved in the configured file, if it
+// exists. Their rules aren't programmed until it is started.
+func New(config Config, members MembersFunc) (*Engine, error) {
+	e := &Engine{
+		Log:      logray.New(),
+		config:   config,
+		members:  members,
+		policies: make(map[string]*Policy),
+		wakech:   make(chan bool, 1),
+		stopch:   make(chan bool),
+		ready:    make(map[bool]bool),
+	}
+	e.program = e.iptables
+
+	b, err := ioutil.ReadFile(config.Path)
+	if os.IsNotExist(err) {
+		return e, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var policies []*Policy
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("failed to load the network policies from %s: %v", config.Path, err)
+	}
+	for _, p := range policies {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("failed to load network policy %q: %v", p.Name, err)
+		}
+		e.policies[p.Name] = p
+	}
+	return e, nil
+}
+
+// Set adds the policy, or replaces the one with the same name, and programs
+// the rules for it.
+func (e *Engine) Set(p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	var copied Policy
+	if err := json.Unmarshal(b, &copied); err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	previous, existed := e.policies[p.Name]
+	e.policies[p.Name] = &copied
+	if err := e.save(); err != nil {
+		if existed {
+			e.policies[p.Name] = previous
+		} else {
+			delete(e.policies, p.Name)
+		}
+		return err
+	}
+	if err := e.sync(); err != nil {
+		return fmt.Errorf("the policy was saved, but its rules couldn't be programmed: %v", err)
+	}
+	return nil
+}
+
+// Remove removes the policy, and the rules for it.
+func (e *Engine) Remove(name string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	p, ok := e.policies[name]
+	if !ok {
+		return fmt.Errorf("no network policy named %q exists", name)
+	}
+	delete(e.policies, name)
+	if err := e.save(); err != nil {
+		e.policies[name] = p
+		return err
+	}
+	if err := e.sync(); err != nil {
+		return fmt.Errorf("the policy was removed, but its rules couldn't be: %v", err)
+	}
+	return nil
+}
+
+// Policies returns copies of the policies, sorted by name.
+func (e *Engine) Policies() []*Policy {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	policies := make([]*Policy, 0, len(e.policies))
+	for _, p := range e.policies {
+		b, _ := json.Marshal(p)
+		var copied Policy
+		json.Unmarshal(b, &copied)
+		policies = append(policies, &copied)
+	}
+	sort.Sort(policiesByName(policies))
+	return policies
+}
+
+// Start programs the rules, and then programs them again in the background
+// each time it is woken.
+func (e *Engine) Start() error {
+	e.mutex.Lock()
+	err := e.sync()
+	e.mutex.Unlock()
+	go e.loop()
+	return err
+}
+
+// Stop stops programming the rules when woken. The rules are left in place.
+func (e *Engine) Stop() {
+	close(e.stopch)
+}
+
+// Wake has the rules programmed again, such as when a container has been
+// connected to the network or removed from it.
+func (e *Engine) Wake() {
+	select {
+	case e.wakech <- true:
+	default:
+	}
+}
+
+func (e *Engine) loop() {
+	for {
+		select {
+		case <-e.wakech:
+		case <-e.stopch:
+			return
+		}
+		e.mutex.Lock()
+		if err := e.sync(); err != nil {
+			e.Log.Errorf("Failed to program the network policies: %v", err)
+		}
+		e.mutex.Unlock()
+	}
+}
+
+// sync programs the rules for the current policies and members. The mutex must
+// be held.
+func (e *Engine) sync() error {
+	policies := make([]*Policy, 0, len(e.policies))
+	for _, p := range e.policies {
+		policies = append(policies, p)
+	}
+	members := e.members()
+
+	if err := e.program(restoreInput(policies, members, false), false); err != nil {
+		return err
+	}
+	if e.config.IPv6 {
+		if err := e.program(restoreInput(policies, members, true), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iptables replaces the rules in the policy chains atomically, creating the
+// chains and the jumps to them the first time.
+func (e *Engine) iptables(input string, ipv6 bool) error {
+	command, restore, sysctl := "iptables", "iptables-restore", "bridge-nf-call-iptables"
+	if ipv6 {
+		command, restore, sysctl = "ip6tables", "ip6tables-restore", "bridge-nf-call-ip6tables"
+	}
+
+	cmd := exec.Command(restore, "--noflush")
+	cmd.Stdin = strings.NewReader(input)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", restore, err, strings.TrimSpace(string(b)))
+	}
+	if e.ready[ipv6] {
+		return nil
+	}
+
+	// traffic between containers on the same bridge is only seen by
+	// iptables once bridge netfilter is enabled
+	path := filepath.Join("/proc/sys/net/bridge", sysctl)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		exec.Command("modprobe", "br_netfilter").Run()
+	}
+	if err := ioutil.WriteFile(path, []byte("1\n"), 0644); err != nil {
+		e.Log.Warnf("Failed to enable %s, policies won't apply to traffic between containers: %v", sysctl, err)
+	}
+
+	jumps := [][]string{
+		{"FORWARD", "-i", e.config.Bridge, "-o", e.config.Bridge, "-j", containerChain},
+		{"INPUT", "-i", e.config.Bridge, "-j", hostChain},
+	}
+	for _, rule := range jumps {
+		// -C exits with a non-zero status if the rule doesn't exist, and
+		// the jump is inserted ahead of any rules accepting the traffic
+		if exec.Command(command, append([]string{"-t", "filter", "-C"}, rule...)...).Run() == nil {
+			continue
+		}
+		args := append([]string{"-t", "filter", "-I", rule[0], "1"}, rule[1:]...)
+		if b, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %v: %s", command, err, strings.TrimSpace(string(b)))
+		}
+	}
+	e.ready[ipv6] = true
+	return nil
+}
+
+// save writes the policies to the file, replacing it atomically. The mutex
+// must be held.
+func (e *Engine) save() error {
+	policies := make([]*Policy, 0, len(e.policies))
+	for _, p := range e.policies {
+		policies = append(policies, p)
+	}
+	sort.Sort(policiesByName(policies))
+	b, err := json.MarshalIndent(policies, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(e.config.Path), ".netpolicies")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), e.config.Path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// ManagerMembers returns a MembersFunc for the manager's containers which are
+// connected to the container network.
+func ManagerMembers(manager *container.Manager) MembersFunc {
+	return func() []*Member {
+		var members []*Member
+		for _, c := range manager.Containers() {
+			ips := c.IPAddresses()
+			if len(ips) == 0 {
+				continue
+			}
+			members = append(members, &Member{UUID: c.UUID(), Labels: c.Labels(), Addresses: ips})
+		}
+		return members
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func testMembers() []*Member {
+	return []*Member{
+		{UUID: "b", Labels: map[string]string{"tier": "db"}, Addresses: []net.IP{
+			net.ParseIP("10.0.0.3"), net.ParseIP("fd00::3")}},
+		{UUID: "a", Labels: map[string]string{"tier": "web"}, Addresses: []net.IP{
+			net.ParseIP("10.0.0.2")}},
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestExpectSuccess(t, (&Policy{Name: "db", Default: Deny, Rules: []*Rule{
+		{Action: Allow, Protocol: "tcp", Port: 5432},
+		{Action: Deny, Protocol: "icmp"},
+	}}).Validate())
+	TestExpectError(t, (&Policy{Name: "../db"}).Validate())
+	TestExpectError(t, (&Policy{Name: "db", Default: "reject"}).Validate())
+	TestExpectError(t, (&Policy{Name: "db", Rules: []*Rule{{Action: "accept"}}}).Validate())
+	TestExpectError(t, (&Policy{Name: "db", Rules: []*Rule{{Action: Allow, Protocol: "sctp"}}}).Validate())
+	TestExpectError(t, (&Policy{Name: "db", Rules: []*Rule{{Action: Allow, Port: 80}}}).Validate())
+	TestExpectError(t, (&Policy{Name: "db", Rules: []*Rule{{Action: Allow, Protocol: "tcp", Port: 70000}}}).Validate())
+}
+
+func TestRestoreInput(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	policies := []*Policy{
+		{
+			Name:     "isolate-db",
+			Selector: map[string]string{"tier": "db"},
+			Default:  Deny,
+			Rules: []*Rule{
+				{Action: Allow, From: map[string]string{"tier": "web"}, Protocol: "tcp", Port: 5432},
+				{Action: Allow, From: map[string]string{"tier": "missing"}},
+			},
+		},
+		{
+			Name:    "host",
+			Host:    true,
+			Default: Deny,
+			Rules:   []*Rule{{Action: Allow, Protocol: "udp", Port: 53}},
+		},
+	}
+
+	input := restoreInput(policies, testMembers(), false)
+	TestEqual(t, strings.Split(input, "\n"), []string{
+		"*filter",
+		":KURMA-POLICY - [0:0]",
+		":KURMA-POLICY-HOST - [0:0]",
+		"-A KURMA-POLICY -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		"-A KURMA-POLICY-HOST -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		"-A KURMA-POLICY -s 10.0.0.2 -d 10.0.0.3 -p tcp --dport 5432 -j ACCEPT",
+		"-A KURMA-POLICY -d 10.0.0.3 -j DROP",
+		"-A KURMA-POLICY-HOST -p udp --dport 53 -j ACCEPT",
+		"-A KURMA-POLICY-HOST -j DROP",
+		"COMMIT",
+		"",
+	})
+
+	// only the IPv6 addresses are used for ip6tables, so the web container
+	// can't reach the database over IPv6
+	input = restoreInput(policies, testMembers(), true)
+	TestTrue(t, strings.Contains(input, "-A KURMA-POLICY -d fd00::3 -j DROP\n"))
+	TestFalse(t, strings.Contains(input, "10.0.0"))
+	TestFalse(t, strings.Contains(input, "--dport 5432"))
+
+	// without policies, only replies are matched
+	input = restoreInput(nil, testMembers(), false)
+	TestEqual(t, len(strings.Split(input, "\n")), 7)
+}
+
+func TestEngine(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), "netpolicies.json")
+	newEngine := func() (*Engine, map[bool]string) {
+		e, err := New(Config{Path: path, Bridge: "kurma0", IPv6: true}, testMembers)
+		TestExpectSuccess(t, err)
+		programmed := make(map[bool]string)
+		e.program = func(input string, ipv6 bool) error {
+			programmed[ipv6] = input
+			return nil
+		}
+		return e, programmed
+	}
+
+	e, programmed := newEngine()
+	TestExpectSuccess(t, e.Start())
+	defer e.Stop()
+	TestEqual(t, len(programmed), 2)
+
+	p := &Policy{Name: "db", Selector: map[string]string{"tier": "db"}, Default: Deny}
+	TestExpectSuccess(t, e.Set(p))
+	TestTrue(t, strings.Contains(programmed[false], "-d 10.0.0.3 -j DROP"))
+	TestTrue(t, strings.Contains(programmed[true], "-d fd00::3 -j DROP"))
+	TestExpectError(t, e.Set(&Policy{Name: "bad", Default: "maybe"}))
+
+	// the policy is replaced
+	p.Default = Allow
+	TestExpectSuccess(t, e.Set(p))
+	TestFalse(t, strings.Contains(programmed[false], "DROP"))
+	TestExpectSuccess(t, e.Set(&Policy{Name: "another"}))
+	policies := e.Policies()
+	TestEqual(t, len(policies), 2)
+	TestEqual(t, policies[0].Name, "another")
+	TestEqual(t, policies[1].Default, Allow)
+
+	// the policies are loaded again from the file
+	e2, _ := newEngine()
+	TestEqual(t, len(e2.Policies()), 2)
+
+	TestExpectSuccess(t, e.Remove("another"))
+	TestExpectError(t, e.Remove("another"))
+	e2, _ = newEngine()
+	TestEqual(t, len(e2.Policies()), 1)
+
+	// a failure to program the rules is reported, but the policy is kept
+	e.program = func(input string, ipv6 bool) error {
+		return fmt.Errorf("iptables failed")
+	}
+	TestExpectError(t, e.Set(&Policy{Name: "kept"}))
+	TestEqual(t, len(e.Policies()), 2)
+}
@@ -0,0 +1,252 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The actions a rule may take on the traffic it matches.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Policy restricts the traffic from containers to the containers it selects
+// by their labels, or to the host. Traffic to a target is checked against the
+// rules of each policy selecting it, in the order of the policies' names, and
+// the first rule to match decides whether it is allowed. Traffic matching no
+// rule is allowed, unless any of the policies has a default of deny.
+//
+// Replies to allowed traffic are always allowed, as is traffic from the host
+// to the containers.
+type Policy struct {
+	Name string `json:"name"`
+
+	// Selector picks the containers the policy applies to, by labels they
+	// must all have. An empty selector picks every container. It is ignored
+	// if Host is set, in which case the policy applies to traffic from the
+	// containers to the host.
+	Selector map[string]string `json:"selector,omitempty"`
+	Host     bool              `json:"host,omitempty"`
+
+	Rules   []*Rule `json:"rules,omitempty"`
+	Default string  `json:"default,omitempty"`
+}
+
+// Rule allows or denies traffic from the containers with all of the From
+// labels, or from all containers if From is empty. The traffic may be limited
+// to a protocol, and for tcp and udp, to a destination port.
+type Rule struct {
+	Action   string            `json:"action"`
+	From     map[string]string `json:"from,omitempty"`
+	Protocol string            `json:"protocol,omitempty"`
+	Port     int               `json:"port,omitempty"`
+}
+
+// Member is a container on the network which policies may select, or allow
+// traffic from.
+type Member struct {
+	UUID      string
+	Labels    map[string]string
+	Addresses []net.IP
+}
+
+// policyNameRegexp matches the names policies may be given.
+var policyNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validate checks the policy's name, default, and rules.
+func (p *Policy) Validate() error {
+	if len(p.Name) > 64 || !policyNameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("invalid policy name %q, it must start with a letter or digit and contain only letters, digits, '_', '.', and '-'", p.Name)
+	}
+	switch p.Default {
+	case "", Allow, Deny:
+	default:
+		return fmt.Errorf("invalid default %q, it must be allow or deny", p.Default)
+	}
+	for i, r := range p.Rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("invalid rule %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if r.Action != Allow && r.Action != Deny {
+		return fmt.Errorf("the action %q must be allow or deny", r.Action)
+	}
+	switch r.Protocol {
+	case "", "tcp", "udp", "icmp":
+	default:
+		return fmt.Errorf("unsupported protocol %q, it must be tcp, udp, or icmp", r.Protocol)
+	}
+	if r.Port != 0 {
+		if r.Protocol != "tcp" && r.Protocol != "udp" {
+			return fmt.Errorf("a port may only be given with the tcp or udp protocol")
+		}
+		if r.Port < 1 || r.Port > 65535 {
+			return fmt.Errorf("invalid port %d", r.Port)
+		}
+	}
+	return nil
+}
+
+// selects returns whether the member has all of the labels.
+func selects(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// The chains in the filter table holding the rules for the traffic between
+// containers, and from containers to the host.
+const (
+	containerChain = "KURMA-POLICY"
+	hostChain      = "KURMA-POLICY-HOST"
+)
+
+// restoreInput returns the input to iptables-restore, or ip6tables-restore if
+// ipv6 is set, which replaces the rules in the policy chains with those for the
+// policies and members. Only the members' addresses of the family are used.
+func restoreInput(policies []*Policy, members []*Member, ipv6 bool) string {
+	sorted := make([]*Policy, len(policies))
+	copy(sorted, policies)
+	sort.Sort(policiesByName(sorted))
+	members = append([]*Member(nil), members...)
+	sort.Sort(membersByUUID(members))
+
+	addresses := func(m *Member) []string {
+		var addrs []string
+		for _, ip := range m.Addresses {
+			if (ip.To4() == nil) == ipv6 {
+				addrs = append(addrs, ip.String())
+			}
+		}
+		return addrs
+	}
+
+	var lines []string
+	add := func(chain string, args ...string) {
+		lines = append(lines, "-A "+chain+" "+strings.Join(args, " "))
+	}
+	lines = append(lines, "*filter", ":"+containerChain+" - [0:0]", ":"+hostChain+" - [0:0]")
+	for _, chain := range []string{containerChain, hostChain} {
+		add(chain, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT")
+	}
+
+	// rules adds the rules of the policies for traffic to the destination,
+	// followed by a rule dropping the rest if any of them denies it by
+	// default.
+	rules := func(chain string, dst []string, applied []*Policy) {
+		deny := false
+		for _, p := range applied {
+			for _, r := range p.Rules {
+				var sources []string
+				if len(r.From) > 0 {
+					for _, m := range members {
+						if selects(r.From, m.Labels) {
+							sources = append(sources, addresses(m)...)
+						}
+					}
+					// a selector matching no containers matches no traffic
+					if len(sources) == 0 {
+						continue
+					}
+				} else {
+					sources = []string{""}
+				}
+				target := "ACCEPT"
+				if r.Action == Deny {
+					target = "DROP"
+				}
+				for _, src := range sources {
+					for _, d := range dst {
+						var args []string
+						if src != "" {
+							args = append(args, "-s", src)
+						}
+						if d != "" {
+							args = append(args, "-d", d)
+						}
+						args = append(args, r.matchArgs(ipv6)...)
+						add(chain, append(args, "-j", target)...)
+					}
+				}
+			}
+			if p.Default == Deny {
+				deny = true
+			}
+		}
+		if deny {
+			for _, d := range dst {
+				if d == "" {
+					add(chain, "-j", "DROP")
+				} else {
+					add(chain, "-d", d, "-j", "DROP")
+				}
+			}
+		}
+	}
+
+	for _, m := range members {
+		dst := addresses(m)
+		if len(dst) == 0 {
+			continue
+		}
+		var applied []*Policy
+		for _, p := range sorted {
+			if !p.Host && selects(p.Selector, m.Labels) {
+				applied = append(applied, p)
+			}
+		}
+		rules(containerChain, dst, applied)
+	}
+
+	var applied []*Policy
+	for _, p := range sorted {
+		if p.Host {
+			applied = append(applied, p)
+		}
+	}
+	rules(hostChain, []string{""}, applied)
+
+	lines = append(lines, "COMMIT", "")
+	return strings.Join(lines, "\n")
+}
+
+// matchArgs returns the arguments matching the rule's protocol and port.
+func (r *Rule) matchArgs(ipv6 bool) []string {
+	if r.Protocol == "" {
+		return nil
+	}
+	protocol := r.Protocol
+	if protocol == "icmp" && ipv6 {
+		protocol = "ipv6-icmp"
+	}
+	args := []string{"-p", protocol}
+	if r.Port != 0 {
+		args = append(args, "--dport", fmt.Sprintf("%d", r.Port))
+	}
+	return args
+}
+
+type policiesByName []*Policy
+
+func (p policiesByName) Len() int           { return len(p) }
+func (p policiesByName) Less(a, b int) bool { return p[a].Name < p[b].Name }
+func (p policiesByName) Swap(a, b int)      { p[a], p[b] = p[b], p[a] }
+
+type membersByUUID []*Member
+
+func (m membersByUUID) Len() int           { return len(m) }
+func (m membersByUUID) Less(a, b int) bool { return m[a].UUID < m[b].UUID }
+func (m membersByUUID) Swap(a, b int)      { m[a], m[b] = m[b], m[a] }
@@ -0,0 +1,107 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// MinBandwidth is the lowest rate, in bytes per second, an endpoint's
+	// traffic may be limited to.
+	MinBandwidth = 1 << 10
+
+	// minBurst is the least traffic, in bytes, which may be sent at once
+	// through a limited endpoint. It must be more than the MTU for anything
+	// to get through.
+	minBurst = 32 << 10
+
+	// shapeLatency is how long traffic to a limited endpoint may be queued
+	// before it is dropped.
+	shapeLatency = "50ms"
+)
+
+// Bandwidth limits the rate of the traffic through an endpoint, in bytes per
+// second. Ingress is the traffic to the container and egress that from it. A
+// direction is unlimited if its limit is 0.
+type Bandwidth struct {
+	Ingress int64 `json:"ingress,omitempty"`
+	Egress  int64 `json:"egress,omitempty"`
+}
+
+// Limited returns whether either direction is limited.
+func (b Bandwidth) Limited() bool {
+	return b.Ingress != 0 || b.Egress != 0
+}
+
+// Validate checks that the limits are either 0 or at least MinBandwidth.
+func (b Bandwidth) Validate() error {
+	for _, limit := range []struct {
+		name  string
+		value int64
+	}{{"ingress", b.Ingress}, {"egress", b.Egress}} {
+		if limit.value < 0 {
+			return fmt.Errorf("the %s limit must not be negative", limit.name)
+		}
+		if limit.value != 0 && limit.value < MinBandwidth {
+			return fmt.Errorf("the %s limit must be at least %d bytes per second", limit.name, MinBandwidth)
+		}
+	}
+	return nil
+}
+
+// Limit caps the rate of the traffic through the endpoint's interface. The
+// limits are removed along with the interface, so they needn't be torn down.
+func (n *Network) Limit(endpoint *Endpoint, b Bandwidth) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	for _, args := range bandwidthCommands(endpoint.HostInterface, b) {
+		if out, err := exec.Command("tc", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("tc failed: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// bandwidthCommands returns the arguments to tc which limit the traffic through
+// the host's side of an endpoint. Traffic to the container leaves through the
+// host's interface, so it is shaped by a token bucket on its root qdisc, while
+// traffic from the container arrives on it and can only be policed, dropping
+// what exceeds the limit so the container's TCP connections slow down.
+func bandwidthCommands(iface string, b Bandwidth) [][]string {
+	var commands [][]string
+	if b.Ingress > 0 {
+		commands = append(commands, []string{
+			"qdisc", "replace", "dev", iface, "root", "tbf",
+			"rate", rate(b.Ingress), "burst", burst(b.Ingress), "latency", shapeLatency,
+		})
+	}
+	if b.Egress > 0 {
+		commands = append(commands, []string{
+			"qdisc", "replace", "dev", iface, "handle", "ffff:", "ingress",
+		}, []string{
+			"filter", "add", "dev", iface, "parent", "ffff:", "protocol", "all",
+			"prio", "1", "u32", "match", "u32", "0", "0",
+			"police", "rate", rate(b.Egress), "burst", burst(b.Egress), "drop", "flowid", ":1",
+		})
+	}
+	return commands
+}
+
+// rate formats the limit for tc, which takes rates in bits per second.
+func rate(limit int64) string {
+	return fmt.Sprintf("%dbit", limit*8)
+}
+
+// burst returns the traffic which may be sent at once at the limit, as a tenth
+// of a second's worth.
+func burst(limit int64) string {
+	b := limit / 10
+	if b < minBurst {
+		b = minBurst
+	}
+	return fmt.Sprintf("%d", b)
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestBandwidthValidate(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestExpectSuccess(t, Bandwidth{}.Validate())
+	TestExpectSuccess(t, Bandwidth{Ingress: 1 << 20}.Validate())
+	TestExpectSuccess(t, Bandwidth{Egress: MinBandwidth}.Validate())
+	TestExpectError(t, Bandwidth{Ingress: -1}.Validate())
+	TestExpectError(t, Bandwidth{Egress: MinBandwidth - 1}.Validate())
+
+	TestFalse(t, Bandwidth{}.Limited())
+	TestTrue(t, Bandwidth{Egress: MinBandwidth}.Limited())
+}
+
+func TestBandwidthCommands(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: An unlimited endpoint needs no commands.
+	TestEqual(t, len(bandwidthCommands("vethabc", Bandwidth{})), 0)
+
+	// Test 2: Ingress is shaped on the root qdisc, with a tenth of a second's
+	// burst.
+	TestEqual(t, bandwidthCommands("vethabc", Bandwidth{Ingress: 10 << 20}), [][]string{
+		{"qdisc", "replace", "dev", "vethabc", "root", "tbf",
+			"rate", "83886080bit", "burst", "1048576", "latency", "50ms"},
+	})
+
+	// Test 3: Egress is policed on the ingress qdisc, and small limits are
+	// given the minimum burst.
+	commands := bandwidthCommands("vethabc", Bandwidth{Ingress: 1 << 20, Egress: 4 << 10})
+	TestEqual(t, len(commands), 3)
+	TestEqual(t, commands[1], []string{"qdisc", "replace", "dev", "vethabc", "handle", "ffff:", "ingress"})
+	TestEqual(t, commands[2], []string{
+		"filter", "add", "dev", "vethabc", "parent", "ffff:", "protocol", "all",
+		"prio", "1", "u32", "match", "u32", "0", "0",
+		"police", "rate", "32768bit", "burst", "32768", "drop", "flowid", ":1",
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+)
+
+// allocator hands out the addresses within a subnet. The first address after
+// the network address is reserved for the gateway, and the network and
+// broadcast addresses are never allocated. Addresses are tracked by their offset
+// within the subnet, so at most 2^32 addresses of an IPv6 subnet are used.
+type allocator struct {
+	subnet *net.IPNet
+	first  uint32
+	last   uint32
+	next   uint32
+	inUse  map[uint32]bool
+	mutex  sync.Mutex
+}
+
+// newAllocator returns an allocator for the subnet, which must have room for
+// at least the gateway and one other address.
+func newAllocator(subnet *net.IPNet) (*allocator, error) {
+	ip := subnet.IP.To4()
+	if ip == nil {
+		ip = subnet.IP.To16()
+	}
+	if ip == nil || len(subnet.Mask) != len(ip) {
+		return nil, fmt.Errorf("subnet %s has an invalid mask", subnet)
+	}
+	ones, bits := subnet.Mask.Size()
+	if bits == 0 {
+		return nil, fmt.Errorf("subnet %s has an invalid mask", subnet)
+	}
+	hostBits := uint(bits - ones)
+	if hostBits < 2 {
+		return nil, fmt.Errorf("subnet %s is too small", subnet)
+	}
+
+	// the highest offset, which is the broadcast address of an IPv4 subnet
+	max := uint32(math.MaxUint32)
+	if hostBits < 32 {
+		max = 1<<hostBits - 1
+	}
+	a := &allocator{
+		subnet: &net.IPNet{IP: ip.Mask(subnet.Mask), Mask: subnet.Mask},
+		first:  2,
+		last:   max - 1,
+		inUse:  make(map[uint32]bool),
+	}
+	a.next = a.first
+	return a, nil
+}
+
+// restrict limits the addresses handed out to those within the range, which
+// must be within the subnet. The first address is no longer reserved for the
+// gateway, so a gateway within the range must be reserved separately.
+func (a *allocator) restrict(r *net.IPNet) error {
+	ones, bits := a.subnet.Mask.Size()
+	rOnes, rBits := r.Mask.Size()
+	if rBits != bits || rOnes < ones || !a.subnet.Contains(r.IP) {
+		return fmt.Errorf("range %s is not within %s", r, a.subnet)
+	}
+	start, _ := a.offset(r.IP.Mask(r.Mask))
+	end := uint64(start) + 1<<uint(rBits-rOnes) - 1
+
+	first, last := uint64(start), end
+	if first < 1 {
+		first = 1
+	}
+	if last > uint64(a.last) {
+		last = uint64(a.last)
+	}
+	if first > last {
+		return fmt.Errorf("range %s has no addresses which can be allocated", r)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.first, a.last, a.next = uint32(first), uint32(last), uint32(first)
+	return nil
+}
+
+// gateway returns the address reserved for the gateway.
+func (a *allocator) gateway() net.IP {
+	return a.toIP(a.first - 1)
+}
+
+// allocate returns an address which is not in use. Addresses are handed out
+// in order, wrapping around once the end of the subnet is reached, so recently
+// released addresses aren't immediately reused.
+func (a *allocator) allocate() (net.IP, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for n := a.first; n <= a.last; n++ {
+		offset := a.next
+		if a.next == a.last {
+			a.next = a.first
+		} else {
+			a.next++
+		}
+		if !a.inUse[offset] {
+			a.inUse[offset] = true
+			return a.toIP(offset), nil
+		}
+	}
+	return nil, fmt.Errorf("no addresses are available in %s", a.subnet)
+}
+
+// reserve marks the specific address as allocated. It returns an error if the
+// address isn't one which could be allocated or is already in use.
+func (a *allocator) reserve(ip net.IP) error {
+	offset, ok := a.offset(ip)
+	if !ok || offset < a.first || offset > a.last {
+		return fmt.Errorf("address %s can't be allocated from %s", ip, a.subnet)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.inUse[offset] {
+		return fmt.Errorf("address %s is already in use", ip)
+	}
+	a.inUse[offset] = true
+	return nil
+}
+
+// release returns the address so that it can be allocated again.
+func (a *allocator) release(ip net.IP) {
+	offset, ok := a.offset(ip)
+	if !ok {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.inUse, offset)
+}
+
+// offset returns the offset of the address within the subnet, and whether the
+// address is one the allocator could hand out.
+func (a *allocator) offset(ip net.IP) (uint32, bool) {
+	if !a.subnet.Contains(ip) {
+		return 0, false
+	}
+	if len(a.subnet.IP) == net.IPv4len {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	n := len(ip) - 4
+	offset := binary.BigEndian.Uint32(ip[n:]) &^ binary.BigEndian.Uint32(a.subnet.Mask[n:])
+	if !a.toIP(offset).Equal(ip) {
+		return 0, false
+	}
+	return offset, true
+}
+
+// toIP returns the address at the offset within the subnet. The offset is
+// never larger than the host portion of the subnet, so it can be combined with
+// the network address.
+func (a *allocator) toIP(offset uint32) net.IP {
+	ip := make(net.IP, len(a.subnet.IP))
+	copy(ip, a.subnet.IP)
+	n := len(ip) - 4
+	binary.BigEndian.PutUint32(ip[n:], binary.BigEndian.Uint32(ip[n:])|offset)
+	return ip
+}
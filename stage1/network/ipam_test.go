@@ -0,0 +1,159 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func mustParseSubnet(t *testing.T, s string) *net.IPNet {
+	_, subnet, err := net.ParseCIDR(s)
+	TestExpectSuccess(t, err)
+	return subnet
+}
+
+func TestNewAllocator(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: IPv6 subnets are supported, with the same gateway address.
+	a, err := newAllocator(mustParseSubnet(t, "fd00:1::/64"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, a.gateway().String(), "fd00:1::1")
+
+	// Test 2: The subnet needs room for the gateway and a container.
+	_, err = newAllocator(mustParseSubnet(t, "10.0.0.0/31"))
+	TestExpectError(t, err)
+
+	// Test 3: The gateway is the first address after the network address.
+	a, err = newAllocator(mustParseSubnet(t, "10.0.0.0/24"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, a.gateway().String(), "10.0.0.1")
+}
+
+func TestAllocator(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// A /29 has 6 usable addresses, one of which is the gateway.
+	a, err := newAllocator(mustParseSubnet(t, "192.168.1.0/29"))
+	TestExpectSuccess(t, err)
+
+	var allocated []string
+	for i := 0; i < 5; i++ {
+		ip, err := a.allocate()
+		TestExpectSuccess(t, err)
+		allocated = append(allocated, ip.String())
+	}
+	TestEqual(t, allocated, []string{
+		"192.168.1.2", "192.168.1.3", "192.168.1.4", "192.168.1.5", "192.168.1.6",
+	})
+
+	// The subnet is exhausted.
+	_, err = a.allocate()
+	TestExpectError(t, err)
+
+	// Released addresses are allocated again.
+	a.release(net.ParseIP("192.168.1.4"))
+	ip, err := a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "192.168.1.4")
+
+	// Addresses are handed out in order rather than reusing the most recently
+	// released one.
+	a.release(net.ParseIP("192.168.1.2"))
+	a.release(net.ParseIP("192.168.1.3"))
+	ip, err = a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "192.168.1.2")
+	a.release(ip)
+	ip, err = a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "192.168.1.3")
+}
+
+func TestAllocatorIPv6(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	a, err := newAllocator(mustParseSubnet(t, "fd00:1::/64"))
+	TestExpectSuccess(t, err)
+
+	ip, err := a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "fd00:1::2")
+	ip, err = a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "fd00:1::3")
+
+	// Addresses outside of the subnet are ignored when released.
+	a.release(net.ParseIP("fd00:2::2"))
+	TestEqual(t, len(a.inUse), 2)
+
+	a.release(net.ParseIP("fd00:1::2"))
+	TestEqual(t, len(a.inUse), 1)
+	TestEqual(t, a.inUse[3], true)
+}
+
+func TestAllocatorReserve(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	a, err := newAllocator(mustParseSubnet(t, "10.0.0.0/24"))
+	TestExpectSuccess(t, err)
+
+	// The gateway, the broadcast address, and addresses outside of the subnet
+	// can't be reserved.
+	TestExpectError(t, a.reserve(net.ParseIP("10.0.0.1")))
+	TestExpectError(t, a.reserve(net.ParseIP("10.0.0.255")))
+	TestExpectError(t, a.reserve(net.ParseIP("10.0.1.5")))
+
+	// Reserved addresses aren't allocated, and can't be reserved twice.
+	TestExpectSuccess(t, a.reserve(net.ParseIP("10.0.0.2")))
+	TestExpectError(t, a.reserve(net.ParseIP("10.0.0.2")))
+	ip, err := a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "10.0.0.3")
+
+	a.release(net.ParseIP("10.0.0.2"))
+	TestExpectSuccess(t, a.reserve(net.ParseIP("10.0.0.2")))
+}
+
+func TestAllocatorRestrict(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: Only addresses within the range are allocated, including its
+	// first.
+	a, err := newAllocator(mustParseSubnet(t, "10.1.0.0/16"))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, a.restrict(mustParseSubnet(t, "10.1.2.0/30")))
+	var allocated []string
+	for i := 0; i < 4; i++ {
+		ip, err := a.allocate()
+		TestExpectSuccess(t, err)
+		allocated = append(allocated, ip.String())
+	}
+	TestEqual(t, allocated, []string{"10.1.2.0", "10.1.2.1", "10.1.2.2", "10.1.2.3"})
+	_, err = a.allocate()
+	TestExpectError(t, err)
+	TestExpectError(t, a.reserve(net.ParseIP("10.1.3.0")))
+
+	// Test 2: Restricted to the whole subnet, the network and broadcast
+	// addresses are still excluded, but the first address isn't reserved.
+	a, err = newAllocator(mustParseSubnet(t, "192.168.1.0/29"))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, a.restrict(mustParseSubnet(t, "192.168.1.0/29")))
+	TestExpectSuccess(t, a.reserve(net.ParseIP("192.168.1.1")))
+	ip, err := a.allocate()
+	TestExpectSuccess(t, err)
+	TestEqual(t, ip.String(), "192.168.1.2")
+	TestExpectError(t, a.reserve(net.ParseIP("192.168.1.7")))
+
+	// Test 3: The range must be within the subnet.
+	TestExpectError(t, a.restrict(mustParseSubnet(t, "192.168.2.0/30")))
+	TestExpectError(t, a.restrict(mustParseSubnet(t, "192.168.0.0/16")))
+}
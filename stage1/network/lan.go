@@ -0,0 +1,304 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/util/dhcp"
+	"github.com/apcera/kurma/util/ns"
+	"github.com/apcera/logray"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// dhcpTimeout is how long a container is given to acquire or renew its
+	// lease.
+	dhcpTimeout = 30 * time.Second
+
+	// dhcpRetryInterval is how long to wait before retrying to renew a lease
+	// after a failure.
+	dhcpRetryInterval = time.Minute
+)
+
+// newLAN returns the network for the macvlan or ipvlan mode, checking the
+// parent interface exists and preparing to allocate addresses from the LAN's
+// subnet, unless DHCP is used.
+func newLAN(config Config) (*Network, error) {
+	if config.Parent == "" {
+		return nil, fmt.Errorf("the %s mode requires a parent interface", config.Mode)
+	}
+	if config.Subnet6 != "" {
+		return nil, fmt.Errorf("IPv6 subnets aren't supported in the %s mode", config.Mode)
+	}
+	parent, err := netlink.LinkByName(config.Parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the parent interface %s: %v", config.Parent, err)
+	}
+	// the sub-interfaces can't pass traffic while the parent is down
+	if err := netlink.LinkSetUp(parent); err != nil {
+		return nil, fmt.Errorf("failed to set %s up: %v", config.Parent, err)
+	}
+
+	n := &Network{
+		Log:    logray.New(),
+		config: config,
+		parent: parent,
+		ports:  make(map[string]bool),
+	}
+	if config.DHCP {
+		if config.Mode != ModeMacvlan {
+			return nil, fmt.Errorf("DHCP is only supported in the %s mode", ModeMacvlan)
+		}
+		return n, nil
+	}
+
+	if config.Subnet == "" {
+		return nil, fmt.Errorf("the %s mode requires the LAN's subnet, unless DHCP is used", config.Mode)
+	}
+	_, subnet, err := net.ParseCIDR(config.Subnet)
+	if err != nil || subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid subnet %q", config.Subnet)
+	}
+	alloc, err := newAllocator(subnet)
+	if err != nil {
+		return nil, err
+	}
+	rng := subnet
+	if config.Range != "" {
+		if _, rng, err = net.ParseCIDR(config.Range); err != nil {
+			return nil, fmt.Errorf("invalid range %q: %v", config.Range, err)
+		}
+	}
+	if err := alloc.restrict(rng); err != nil {
+		return nil, err
+	}
+
+	gateway := net.ParseIP(config.Gateway)
+	if gateway == nil || !subnet.Contains(gateway) {
+		return nil, fmt.Errorf("the gateway %q must be an address within %s", config.Gateway, subnet)
+	}
+	// the gateway and the host's own addresses on the LAN are never given to
+	// containers, though they are only reserved if they're within the range
+	alloc.reserve(gateway)
+	if addrs, err := netlink.AddrList(parent, netlink.FAMILY_V4); err == nil {
+		for _, a := range addrs {
+			alloc.reserve(a.IP)
+		}
+	}
+
+	n.subnet = subnet
+	n.allocator = alloc
+	n.gateway = gateway.To4()
+	return n, nil
+}
+
+// setupLAN gives the process's network namespace a sub-interface of the parent
+// interface, with an address from the subnet or the LAN's DHCP server.
+func (n *Network) setupLAN(id string, pid int) (*Endpoint, error) {
+	endpoint := &Endpoint{}
+	if !n.config.DHCP {
+		ip, err := n.allocator.allocate()
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Address = &net.IPNet{IP: ip, Mask: n.subnet.Mask}
+		endpoint.Gateway = n.gateway
+	}
+	if err := n.setupSubInterface(endpoint, id, pid); err != nil {
+		n.Teardown(endpoint)
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// setupSubInterface creates the sub-interface for the endpoint, moves it into
+// the process's namespace and configures it there, acquiring a lease for it
+// first when DHCP is used.
+func (n *Network) setupSubInterface(endpoint *Endpoint, id string, pid int) error {
+	name := "lan" + id
+	attrs := netlink.LinkAttrs{Name: name, ParentIndex: n.parent.Attrs().Index, MTU: n.config.MTU}
+	var link netlink.Link
+	if n.config.Mode == ModeMacvlan {
+		// bridge mode lets containers on the same parent reach each other
+		link = &netlink.Macvlan{LinkAttrs: attrs, Mode: netlink.MACVLAN_MODE_BRIDGE}
+	} else {
+		link = &netlink.IPVlan{LinkAttrs: attrs, Mode: netlink.IPVLAN_MODE_L2}
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return fmt.Errorf("failed to create the %s interface: %v", n.config.Mode, err)
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetNsPid(link, pid); err != nil {
+		netlink.LinkDel(link)
+		return fmt.Errorf("failed to move %s into the container: %v", name, err)
+	}
+
+	var client *dhcp.Client
+	var lease *dhcp.Lease
+	err = ns.Run(pid, []ns.Namespace{ns.Net}, func() error {
+		link, err := containerLink(name)
+		if err != nil {
+			return err
+		}
+		if n.config.DHCP {
+			// the link must be up to send and receive the requests
+			if err := netlink.LinkSetUp(link); err != nil {
+				return err
+			}
+			if client, lease, err = acquireLease(id); err != nil {
+				return err
+			}
+			endpoint.Address = lease.Address
+			endpoint.Gateway = lease.Router
+		}
+		return configureLink(link, endpoint)
+	})
+	if err != nil {
+		return err
+	}
+
+	if lease != nil {
+		k := &leaseKeeper{
+			log:      n.Log.Clone(),
+			pid:      pid,
+			client:   client,
+			endpoint: endpoint,
+			lease:    lease,
+			stopch:   make(chan bool),
+		}
+		k.log.SetField("container", id)
+		endpoint.lease = k
+		go k.run()
+	}
+	return nil
+}
+
+// acquireLease obtains a lease for the container's interface from the LAN's
+// DHCP server. It is called from within the container's namespace, so the
+// client's socket is opened within it.
+func acquireLease(hostname string) (*dhcp.Client, *dhcp.Lease, error) {
+	iface, err := net.InterfaceByName(ContainerInterface)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := dhcp.NewClient(iface)
+	client.Hostname = hostname
+	lease, err := client.Acquire(dhcpTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to acquire a DHCP lease: %v", err)
+	}
+	return client, lease, nil
+}
+
+// leaseKeeper renews a container's DHCP lease in the background until it is
+// stopped. The container's address can't change while it runs, so if the
+// lease expires, the address is removed until the server leases it again.
+type leaseKeeper struct {
+	log      *logray.Logger
+	pid      int
+	client   *dhcp.Client
+	endpoint *Endpoint
+	lease    *dhcp.Lease
+	expired  bool
+	stopch   chan bool
+	mutex    sync.Mutex
+}
+
+func (k *leaseKeeper) run() {
+	wait := k.lease.Acquired.Add(k.lease.RenewalTime).Sub(time.Now())
+	for {
+		select {
+		case <-time.After(wait):
+		case <-k.stopch:
+			return
+		}
+		k.mutex.Lock()
+		select {
+		case <-k.stopch:
+			k.mutex.Unlock()
+			return
+		default:
+		}
+		wait = k.renew()
+		k.mutex.Unlock()
+	}
+}
+
+// renew renews the lease, or once it has expired, tries to lease the address
+// again. It returns how long to wait before it is next called. The mutex must
+// be held.
+func (k *leaseKeeper) renew() time.Duration {
+	address := k.lease.Address
+	if !k.expired && time.Now().After(k.lease.Expires()) {
+		k.log.Errorf("The DHCP lease for %s has expired, removing the address until it is leased again", address)
+		err := ns.Run(k.pid, []ns.Namespace{ns.Net}, func() error {
+			link, err := netlink.LinkByName(ContainerInterface)
+			if err != nil {
+				return err
+			}
+			return netlink.AddrDel(link, &netlink.Addr{IPNet: address})
+		})
+		if err != nil {
+			k.log.Warnf("Failed to remove %s from the container: %v", address, err)
+		}
+		k.expired = true
+	}
+
+	var lease *dhcp.Lease
+	err := ns.Run(k.pid, []ns.Namespace{ns.Net}, func() error {
+		var err error
+		if k.expired {
+			k.client.Address = address.IP
+			lease, err = k.client.Acquire(dhcpTimeout)
+		} else {
+			lease, err = k.client.Renew(k.lease, dhcpTimeout)
+		}
+		if err == nil && !lease.Address.IP.Equal(address.IP) {
+			k.client.Release(lease)
+			err = fmt.Errorf("the server leased %s instead", lease.Address.IP)
+		}
+		if err == nil && k.expired {
+			var link netlink.Link
+			if link, err = netlink.LinkByName(ContainerInterface); err == nil {
+				err = configureLink(link, k.endpoint)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		k.log.Warnf("Failed to renew the DHCP lease for %s: %v", address, err)
+		return dhcpRetryInterval
+	}
+
+	if k.expired {
+		k.log.Infof("Leased %s again", address)
+		k.expired = false
+	}
+	k.lease = lease
+	k.log.Debugf("DHCP lease for %s is valid until %s", address, lease.Expires().Format(time.RFC3339))
+	return lease.Acquired.Add(lease.RenewalTime).Sub(time.Now())
+}
+
+// stop stops renewing the lease and releases it, if the container's namespace
+// still exists to release it from.
+func (k *leaseKeeper) stop() {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	close(k.stopch)
+	if k.expired {
+		return
+	}
+	err := ns.Run(k.pid, []ns.Namespace{ns.Net}, func() error {
+		return k.client.Release(k.lease)
+	})
+	if err != nil {
+		k.log.Debugf("Failed to release the DHCP lease for %s: %v", k.lease.Address, err)
+	}
+}
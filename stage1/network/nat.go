@@ -0,0 +1,19 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"net"
+)
+
+// ensureMasquerade adds the iptables rule to masquerade traffic from the
+// subnet which leaves through an interface other than the bridge, unless the
+// rule already exists.
+func ensureMasquerade(subnet *net.IPNet, bridge string) error {
+	return ensureRule("POSTROUTING", "-s", subnet.String(), "!", "-o", bridge, "-j", "MASQUERADE")
+}
+
+// ensureMasquerade6 adds the equivalent rule to ip6tables for the IPv6 subnet.
+func ensureMasquerade6(subnet *net.IPNet, bridge string) error {
+	return ensureRuleWith("ip6tables", "POSTROUTING", "-s", subnet.String(), "!", "-o", bridge, "-j", "MASQUERADE")
+}
@@ -0,0 +1,532 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package network provides isolated networking for containers. Each container
+// is given its own network namespace, connected to a bridge on the host with a
+// veth pair and assigned an address from the bridge's subnet. Traffic leaving
+// the subnet is masqueraded behind the host's addresses, and containers' ports
+// can be published on the host. Containers are also given an IPv6 address if an
+// IPv6 subnet is configured.
+//
+// Alternatively, each container may be given a macvlan or ipvlan sub-interface
+// of one of the host's interfaces, putting it directly on the host's LAN with
+// an address from the LAN's subnet or its DHCP server.
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/apcera/kurma/util/ns"
+	"github.com/apcera/logray"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// DefaultBridge is the name of the bridge containers are attached to when
+	// none is configured.
+	DefaultBridge = "kurma0"
+
+	// DefaultSubnet is the subnet container addresses are allocated from when
+	// none is configured.
+	DefaultSubnet = "172.30.0.0/16"
+
+	// ContainerInterface is the name of the interface within the container.
+	ContainerInterface = "eth0"
+)
+
+// The modes containers may be connected to the network in.
+const (
+	// ModeBridge connects containers to the bridge with veth pairs.
+	ModeBridge = "bridge"
+
+	// ModeMacvlan gives each container a macvlan sub-interface of the parent
+	// interface, with a MAC address of its own.
+	ModeMacvlan = "macvlan"
+
+	// ModeIPvlan gives each container an ipvlan sub-interface of the parent
+	// interface, sharing its MAC address, for networks which limit the MAC
+	// addresses allowed on a port.
+	ModeIPvlan = "ipvlan"
+)
+
+// Config contains the settings for the container network.
+type Config struct {
+	// Bridge is the name of the bridge on the host. It is created if it
+	// doesn't exist.
+	Bridge string
+
+	// Subnet is the subnet containers are given addresses from. The bridge is
+	// assigned the first address in it, which is the containers' gateway.
+	Subnet string
+
+	// Subnet6 is the IPv6 subnet containers are given addresses from, in the
+	// same way as Subnet. Containers only have IPv4 addresses if it is empty.
+	Subnet6 string
+
+	// MTU is the MTU of the bridge and containers' interfaces. The default is
+	// used if it is 0.
+	MTU int
+
+	// NAT configures masquerading of traffic from the containers which leaves
+	// the subnet.
+	NAT bool
+
+	// Mode is how containers are connected, ModeBridge if it is empty. In the
+	// macvlan and ipvlan modes, Bridge, Subnet6, and NAT don't apply, and the
+	// containers are given addresses from Subnet, which is the LAN's subnet,
+	// or by DHCP.
+	Mode string
+
+	// Parent is the host's interface the containers' sub-interfaces are
+	// created on in the macvlan and ipvlan modes.
+	Parent string
+
+	// Gateway is the containers' default gateway on the LAN, which must be
+	// within Subnet. It is required unless DHCP is used.
+	Gateway string
+
+	// Range limits the addresses given to containers on the LAN to part of
+	// Subnet, so they don't conflict with the LAN's other hosts. All of Subnet
+	// is used if it is empty.
+	Range string
+
+	// DHCP has containers on the LAN acquire their addresses, and gateway,
+	// from its DHCP server instead. It is only supported in the macvlan mode,
+	// as ipvlan sub-interfaces share the parent's MAC address.
+	DHCP bool
+}
+
+// Network manages the bridge and the addresses of the containers attached to
+// it, or the containers' sub-interfaces on the LAN.
+type Network struct {
+	Log *logray.Logger
+
+	config     Config
+	subnet     *net.IPNet
+	allocator  *allocator
+	subnet6    *net.IPNet
+	allocator6 *allocator
+	bridge     netlink.Link
+	parent     netlink.Link
+	gateway    net.IP
+
+	ports          map[string]bool
+	portChainReady bool
+	portsMutex     sync.Mutex
+}
+
+// Endpoint is a container's connection to the network.
+type Endpoint struct {
+	// HostInterface is the name of the host side of the veth pair. It is
+	// empty for a container on the LAN, whose only interface is within its
+	// namespace.
+	HostInterface string
+
+	// Address is the address assigned to the container's interface.
+	Address *net.IPNet
+
+	// Gateway is the container's default gateway.
+	Gateway net.IP
+
+	// Address6 and Gateway6 are the container's IPv6 address and gateway. They
+	// are nil if the network doesn't have an IPv6 subnet.
+	Address6 *net.IPNet
+	Gateway6 net.IP
+
+	// ports are the container's ports which are published on the host.
+	ports []PortMapping
+
+	// lease keeps the container's address on the LAN when it was acquired by
+	// DHCP.
+	lease *leaseKeeper
+}
+
+// New sets up the bridge and NAT rules for the network and returns it, or in
+// the macvlan and ipvlan modes, checks the parent interface.
+func New(config Config) (*Network, error) {
+	switch config.Mode {
+	case "", ModeBridge:
+		config.Mode = ModeBridge
+	case ModeMacvlan, ModeIPvlan:
+		return newLAN(config)
+	default:
+		return nil, fmt.Errorf("unknown mode %q, must be %s, %s, or %s", config.Mode, ModeBridge, ModeMacvlan, ModeIPvlan)
+	}
+
+	if config.Bridge == "" {
+		config.Bridge = DefaultBridge
+	}
+	if config.Subnet == "" {
+		config.Subnet = DefaultSubnet
+	}
+
+	_, subnet, err := net.ParseCIDR(config.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q: %v", config.Subnet, err)
+	}
+	alloc, err := newAllocator(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Network{
+		Log:       logray.New(),
+		config:    config,
+		subnet:    subnet,
+		allocator: alloc,
+		ports:     make(map[string]bool),
+	}
+	if config.Subnet6 != "" {
+		_, subnet6, err := net.ParseCIDR(config.Subnet6)
+		if err != nil || subnet6.IP.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 subnet %q", config.Subnet6)
+		}
+		if n.allocator6, err = newAllocator(subnet6); err != nil {
+			return nil, err
+		}
+		n.subnet6 = subnet6
+	}
+
+	if err := n.setupBridge(); err != nil {
+		return nil, fmt.Errorf("failed to set up bridge %s: %v", config.Bridge, err)
+	}
+	if err := ioutil.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to enable ip forwarding: %v", err)
+	}
+	if n.subnet6 != nil {
+		if err := ioutil.WriteFile("/proc/sys/net/ipv6/conf/all/forwarding", []byte("1\n"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to enable IPv6 forwarding: %v", err)
+		}
+	}
+	if config.NAT {
+		if err := ensureMasquerade(subnet, config.Bridge); err != nil {
+			return nil, fmt.Errorf("failed to configure NAT: %v", err)
+		}
+		if n.subnet6 != nil {
+			if err := ensureMasquerade6(n.subnet6, config.Bridge); err != nil {
+				return nil, fmt.Errorf("failed to configure IPv6 NAT: %v", err)
+			}
+		}
+	}
+	return n, nil
+}
+
+// Subnet returns the subnet containers are given addresses from. It is nil if
+// the containers on the LAN are given addresses by DHCP.
+func (n *Network) Subnet() *net.IPNet {
+	return n.subnet
+}
+
+// Gateway returns the bridge's address within the subnet, which is the
+// containers' default gateway. In the macvlan and ipvlan modes it is the LAN's
+// configured gateway, which isn't the host, or nil if DHCP is used.
+func (n *Network) Gateway() net.IP {
+	if !n.Bridged() {
+		return n.gateway
+	}
+	return n.allocator.gateway()
+}
+
+// Bridge returns the name of the bridge the containers are attached to.
+func (n *Network) Bridge() string {
+	return n.config.Bridge
+}
+
+// Mode returns how containers are connected to the network.
+func (n *Network) Mode() string {
+	return n.config.Mode
+}
+
+// Bridged returns whether the containers are attached to the bridge, so that
+// the host can reach them and filter or forward their traffic. Containers on
+// the LAN can't reach the host over their sub-interfaces, nor it them.
+func (n *Network) Bridged() bool {
+	return n.config.Mode == ModeBridge
+}
+
+// Subnet6 returns the IPv6 subnet containers are given addresses from, or nil
+// if there is none.
+func (n *Network) Subnet6() *net.IPNet {
+	return n.subnet6
+}
+
+// setupBridge creates the bridge, if needed, and ensures it has the gateway
+// addresses and is up.
+func (n *Network) setupBridge() error {
+	link, err := netlink.LinkByName(n.config.Bridge)
+	if err != nil {
+		bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: n.config.Bridge}}
+		if err := netlink.LinkAdd(bridge); err != nil {
+			return err
+		}
+		if link, err = netlink.LinkByName(n.config.Bridge); err != nil {
+			return err
+		}
+	}
+
+	if n.config.MTU > 0 {
+		if err := netlink.LinkSetMTU(link, n.config.MTU); err != nil {
+			return err
+		}
+	}
+
+	gateway := &net.IPNet{IP: n.allocator.gateway(), Mask: n.subnet.Mask}
+	if err := ensureAddr(link, gateway, netlink.FAMILY_V4); err != nil {
+		return err
+	}
+	if n.subnet6 != nil {
+		// the gateway is the only address on the subnet which isn't allocated,
+		// so duplicate address detection would only delay its use
+		if err := disableDAD(n.config.Bridge); err != nil {
+			return err
+		}
+		gateway6 := &net.IPNet{IP: n.allocator6.gateway(), Mask: n.subnet6.Mask}
+		if err := ensureAddr(link, gateway6, netlink.FAMILY_V6); err != nil {
+			return err
+		}
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return err
+	}
+	n.bridge = link
+	return nil
+}
+
+// ensureAddr assigns the address to the link, unless it is already assigned.
+func ensureAddr(link netlink.Link, addr *net.IPNet, family int) error {
+	addrs, err := netlink.AddrList(link, family)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if a.IPNet.String() == addr.String() {
+			return nil
+		}
+	}
+	return netlink.AddrAdd(link, &netlink.Addr{IPNet: addr})
+}
+
+// disableDAD disables duplicate address detection for IPv6 addresses assigned
+// to the interface, so they can be used immediately. Since sysctls under
+// /proc/sys/net are specific to a network namespace, it applies to the
+// interface in the namespace of the calling thread.
+func disableDAD(name string) error {
+	path := fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/accept_dad", name)
+	return ioutil.WriteFile(path, []byte("0\n"), 0644)
+}
+
+// Setup connects the network namespace of the process to the network. The id
+// is used to name the host's interface, so must be unique and no more than 11
+// characters.
+func (n *Network) Setup(id string, pid int) (*Endpoint, error) {
+	if !n.Bridged() {
+		return n.setupLAN(id, pid)
+	}
+	ip, err := n.allocator.allocate()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &Endpoint{
+		HostInterface: "veth" + id,
+		Address:       &net.IPNet{IP: ip, Mask: n.subnet.Mask},
+		Gateway:       n.allocator.gateway(),
+	}
+	if n.allocator6 != nil {
+		ip6, err := n.allocator6.allocate()
+		if err != nil {
+			n.Teardown(endpoint)
+			return nil, err
+		}
+		endpoint.Address6 = &net.IPNet{IP: ip6, Mask: n.subnet6.Mask}
+		endpoint.Gateway6 = n.allocator6.gateway()
+	}
+	if err := n.setupEndpoint(endpoint, pid); err != nil {
+		n.Teardown(endpoint)
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// setupEndpoint creates the veth pair for the endpoint, attaches the host side
+// to the bridge and moves the other side into the process's namespace.
+func (n *Network) setupEndpoint(endpoint *Endpoint, pid int) error {
+	peerName := endpoint.HostInterface + "p"
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: endpoint.HostInterface, MTU: n.config.MTU},
+		PeerName:  peerName,
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return fmt.Errorf("failed to create veth pair: %v", err)
+	}
+
+	host, err := netlink.LinkByName(endpoint.HostInterface)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetMasterByIndex(host, n.bridge.Attrs().Index); err != nil {
+		return fmt.Errorf("failed to attach %s to %s: %v", endpoint.HostInterface, n.config.Bridge, err)
+	}
+	if err := netlink.LinkSetUp(host); err != nil {
+		return err
+	}
+
+	peer, err := netlink.LinkByName(peerName)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetNsPid(peer, pid); err != nil {
+		return fmt.Errorf("failed to move %s into the container: %v", peerName, err)
+	}
+
+	return ns.Run(pid, []ns.Namespace{ns.Net}, func() error {
+		return configureContainer(peerName, endpoint)
+	})
+}
+
+// configureContainer configures the interfaces within the container's
+// namespace. It is called from within the namespace.
+func configureContainer(peerName string, endpoint *Endpoint) error {
+	link, err := containerLink(peerName)
+	if err != nil {
+		return err
+	}
+	return configureLink(link, endpoint)
+}
+
+// containerLink brings up the loopback interface within the container's
+// namespace and renames the interface moved into it to ContainerInterface.
+// It is called from within the namespace.
+func containerLink(name string) (netlink.Link, error) {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		return nil, err
+	}
+	if err := netlink.LinkSetUp(lo); err != nil {
+		return nil, err
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := netlink.LinkSetName(link, ContainerInterface); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// configureLink assigns the endpoint's addresses to the container's interface,
+// brings it up, and routes through the endpoint's gateways. It is called from
+// within the namespace.
+func configureLink(link netlink.Link, endpoint *Endpoint) error {
+	if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: endpoint.Address}); err != nil {
+		return fmt.Errorf("failed to assign %s: %v", endpoint.Address, err)
+	}
+	if endpoint.Address6 != nil {
+		if err := disableDAD(ContainerInterface); err != nil {
+			return err
+		}
+		if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: endpoint.Address6}); err != nil {
+			return fmt.Errorf("failed to assign %s: %v", endpoint.Address6, err)
+		}
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return err
+	}
+
+	// a container on the LAN has no gateway if its DHCP lease didn't give it
+	// a router
+	var gateways []net.IP
+	for _, gw := range []net.IP{endpoint.Gateway, endpoint.Gateway6} {
+		if gw != nil {
+			gateways = append(gateways, gw)
+		}
+	}
+	for _, gw := range gateways {
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Gw:        gw,
+		}
+		if err := netlink.RouteAdd(route); err != nil {
+			return fmt.Errorf("failed to add the default route via %s: %v", gw, err)
+		}
+	}
+	return nil
+}
+
+// Reserve recreates the endpoint for a container whose network namespace is
+// being restored, such as from a checkpoint, with the same interface and
+// addresses. The addresses must be within the network's subnets and not in use.
+// The host's interface is connected to the network with Attach once it exists.
+// On the LAN, the host has no interface, and addresses acquired by DHCP can't
+// be reserved as their leases aren't known.
+func (n *Network) Reserve(hostInterface string, address, address6 *net.IPNet) (*Endpoint, error) {
+	if n.allocator == nil {
+		return nil, fmt.Errorf("addresses acquired by DHCP can't be reserved")
+	}
+	if (address6 != nil) != (n.subnet6 != nil) {
+		return nil, fmt.Errorf("the IPv6 configuration of the endpoint doesn't match the network")
+	}
+	if err := n.allocator.reserve(address.IP); err != nil {
+		return nil, err
+	}
+	endpoint := &Endpoint{
+		HostInterface: hostInterface,
+		Address:       &net.IPNet{IP: address.IP, Mask: n.subnet.Mask},
+		Gateway:       n.Gateway(),
+	}
+	if address6 != nil {
+		if err := n.allocator6.reserve(address6.IP); err != nil {
+			n.allocator.release(address.IP)
+			return nil, err
+		}
+		endpoint.Address6 = &net.IPNet{IP: address6.IP, Mask: n.subnet6.Mask}
+		endpoint.Gateway6 = n.allocator6.gateway()
+	}
+	return endpoint, nil
+}
+
+// Attach connects the host's interface of a reserved endpoint to the bridge.
+// Endpoints on the LAN have nothing to attach.
+func (n *Network) Attach(endpoint *Endpoint) error {
+	if endpoint.HostInterface == "" {
+		return nil
+	}
+	host, err := netlink.LinkByName(endpoint.HostInterface)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetMasterByIndex(host, n.bridge.Attrs().Index); err != nil {
+		return fmt.Errorf("failed to attach %s to %s: %v", endpoint.HostInterface, n.config.Bridge, err)
+	}
+	return netlink.LinkSetUp(host)
+}
+
+// Teardown stops publishing the endpoint's ports, removes its interface and
+// releases its addresses, or stops renewing its DHCP lease. The interface is removed by the kernel along with the
+// container's namespace, so it is not an error if it is already gone.
+func (n *Network) Teardown(endpoint *Endpoint) error {
+	if endpoint.lease != nil {
+		endpoint.lease.stop()
+	} else if endpoint.Address != nil {
+		defer n.allocator.release(endpoint.Address.IP)
+	}
+	if endpoint.Address6 != nil {
+		defer n.allocator6.release(endpoint.Address6.IP)
+	}
+
+	err := n.unpublishAll(endpoint)
+	if endpoint.HostInterface == "" {
+		return err
+	}
+	if link, lerr := netlink.LinkByName(endpoint.HostInterface); lerr == nil {
+		if derr := netlink.LinkDel(link); derr != nil && err == nil {
+			err = derr
+		}
+	}
+	return err
+}
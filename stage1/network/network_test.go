@@ -0,0 +1,43 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestReserveLAN(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	subnet := mustParseSubnet(t, "192.168.1.0/24")
+	alloc, err := newAllocator(subnet)
+	TestExpectSuccess(t, err)
+	n := &Network{
+		config:    Config{Mode: ModeMacvlan},
+		subnet:    subnet,
+		allocator: alloc,
+		gateway:   net.ParseIP("192.168.1.254").To4(),
+	}
+
+	// Endpoints on the LAN route through its gateway and have no interface on
+	// the host to attach.
+	address := &net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: subnet.Mask}
+	endpoint, err := n.Reserve("", address, nil)
+	TestExpectSuccess(t, err)
+	TestEqual(t, endpoint.Address.String(), "192.168.1.20/24")
+	TestEqual(t, endpoint.Gateway.String(), "192.168.1.254")
+	TestExpectSuccess(t, n.Attach(endpoint))
+
+	// The address is in use until it is released.
+	_, err = n.Reserve("", address, nil)
+	TestExpectError(t, err)
+
+	// Leases acquired by DHCP can't be reserved.
+	n = &Network{config: Config{Mode: ModeMacvlan, DHCP: true}}
+	_, err = n.Reserve("", address, nil)
+	TestExpectError(t, err)
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// portChain is the iptables chain in the nat table holding the rules which
+// forward published ports to containers.
+const portChain = "KURMA"
+
+// PortMapping publishes a container's port on the host.
+type PortMapping struct {
+	// Protocol is either "tcp" or "udp".
+	Protocol      string
+	HostPort      int
+	ContainerPort int
+}
+
+// String returns the mapping formatted as HOST->CONTAINER/PROTOCOL.
+func (p PortMapping) String() string {
+	return fmt.Sprintf("%d->%d/%s", p.HostPort, p.ContainerPort, p.Protocol)
+}
+
+// key identifies the host port used by the mapping.
+func (p PortMapping) key() string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.HostPort)
+}
+
+// Validate checks that the mapping's protocol and ports are valid.
+func (p PortMapping) Validate() error {
+	if p.Protocol != "tcp" && p.Protocol != "udp" {
+		return fmt.Errorf("unsupported protocol %q, must be tcp or udp", p.Protocol)
+	}
+	if p.HostPort < 1 || p.HostPort > 65535 {
+		return fmt.Errorf("invalid host port %d", p.HostPort)
+	}
+	if p.ContainerPort < 1 || p.ContainerPort > 65535 {
+		return fmt.Errorf("invalid container port %d", p.ContainerPort)
+	}
+	return nil
+}
+
+// PortInUse returns whether the mapping's host port is already published.
+func (n *Network) PortInUse(p PortMapping) bool {
+	n.portsMutex.Lock()
+	defer n.portsMutex.Unlock()
+	return n.ports[p.key()]
+}
+
+// Publish forwards the host port of the mapping to the endpoint's address.
+// Connections to the host's loopback address are not forwarded.
+func (n *Network) Publish(endpoint *Endpoint, p PortMapping) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	n.portsMutex.Lock()
+	defer n.portsMutex.Unlock()
+	if n.ports[p.key()] {
+		return fmt.Errorf("host port %d/%s is already published", p.HostPort, p.Protocol)
+	}
+	if !n.portChainReady {
+		if err := setupPortChain(); err != nil {
+			return fmt.Errorf("failed to set up the %s chain: %v", portChain, err)
+		}
+		n.portChainReady = true
+	}
+
+	if err := iptables(append([]string{"-A", portChain}, dnatRule(endpoint, p)...)...); err != nil {
+		return err
+	}
+	n.ports[p.key()] = true
+	endpoint.ports = append(endpoint.ports, p)
+	return nil
+}
+
+// unpublishAll removes the forwarding of each of the endpoint's ports.
+func (n *Network) unpublishAll(endpoint *Endpoint) error {
+	n.portsMutex.Lock()
+	defer n.portsMutex.Unlock()
+
+	var err error
+	for _, p := range endpoint.ports {
+		delete(n.ports, p.key())
+		if derr := iptables(append([]string{"-D", portChain}, dnatRule(endpoint, p)...)...); derr != nil && err == nil {
+			err = derr
+		}
+	}
+	endpoint.ports = nil
+	return err
+}
+
+// dnatRule returns the rule specification which forwards the mapping's host
+// port to the endpoint.
+func dnatRule(endpoint *Endpoint, p PortMapping) []string {
+	return []string{
+		"-p", p.Protocol, "--dport", fmt.Sprintf("%d", p.HostPort),
+		"-j", "DNAT", "--to-destination", fmt.Sprintf("%s:%d", endpoint.Address.IP, p.ContainerPort),
+	}
+}
+
+// setupPortChain creates the chain for the forwarding rules and jumps to it for
+// traffic addressed to the host, both from the network and from the host
+// itself.
+func setupPortChain() error {
+	// -N fails if the chain already exists, such as after a restart, in which
+	// case it is flushed of stale rules instead.
+	if err := iptables("-N", portChain); err != nil {
+		if err := iptables("-F", portChain); err != nil {
+			return err
+		}
+	}
+
+	jumps := [][]string{
+		{"PREROUTING", "-m", "addrtype", "--dst-type", "LOCAL", "-j", portChain},
+		{"OUTPUT", "!", "-d", "127.0.0.0/8", "-m", "addrtype", "--dst-type", "LOCAL", "-j", portChain},
+	}
+	for _, rule := range jumps {
+		if err := ensureRule(rule...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureRule appends the rule to the chain in the nat table, unless it already
+// exists there. The first argument is the chain.
+func ensureRule(rule ...string) error {
+	return ensureRuleWith("iptables", rule...)
+}
+
+// ensureRuleWith appends the rule using the specified command, which is either
+// iptables or ip6tables.
+func ensureRuleWith(command string, rule ...string) error {
+	// -C exits with a non-zero status if the rule doesn't exist.
+	if err := exec.Command(command, append([]string{"-t", "nat", "-C"}, rule...)...).Run(); err == nil {
+		return nil
+	}
+	return xtables(command, append([]string{"-A"}, rule...)...)
+}
+
+// iptables runs iptables with the arguments against the nat table.
+func iptables(args ...string) error {
+	return xtables("iptables", args...)
+}
+
+// xtables runs the command with the arguments against the nat table.
+func xtables(command string, args ...string) error {
+	args = append([]string{"-t", "nat"}, args...)
+	if b, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", command, err, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
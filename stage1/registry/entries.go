@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package registry
+
+import (
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+)
+
+// Host is the entry for the host, at hosts/NODE. It is refreshed on every
+// round, so Updated shows when the host was last seen.
+type Host struct {
+	Node       string    `json:"node"`
+	Address    string    `json:"address,omitempty"`
+	Version    string    `json:"version"`
+	Updated    time.Time `json:"updated"`
+	Containers int       `json:"containers"`
+	Running    int       `json:"running"`
+}
+
+// Container is the entry for each of the host's containers, at
+// containers/NODE/UUID. Its ports are published on the host's address.
+type Container struct {
+	UUID      string            `json:"uuid"`
+	Name      string            `json:"name,omitempty"`
+	Node      string            `json:"node"`
+	Address   string            `json:"address,omitempty"`
+	State     string            `json:"state"`
+	Health    string            `json:"health,omitempty"`
+	Addresses []string          `json:"addresses,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Ports     []*Port           `json:"ports,omitempty"`
+}
+
+// Port is a port published on the host which is forwarded to the container.
+type Port struct {
+	Protocol      string `json:"protocol"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+}
+
+// ManagerEntries returns an EntriesFunc for the host and the manager's
+// containers, where the host is the node reachable at the address.
+func ManagerEntries(manager *container.Manager, node, address string) EntriesFunc {
+	return func() (map[string]interface{}, error) {
+		containers := manager.Containers()
+		host := &Host{
+			Node:       node,
+			Address:    address,
+			Version:    pb.Version,
+			Updated:    time.Now().UTC(),
+			Containers: len(containers),
+		}
+		entries := map[string]interface{}{
+			"hosts/" + node: host,
+		}
+		for _, c := range containers {
+			state := c.State()
+			if state == container.RUNNING {
+				host.Running++
+			}
+			entry := &Container{
+				UUID:    c.UUID(),
+				Name:    c.Name(),
+				Node:    node,
+				Address: address,
+				State:   state.String(),
+				Health:  string(c.Health()),
+				Labels:  c.Labels(),
+			}
+			// the addresses are only known while the apps are running
+			if addrs, err := c.NetworkAddresses(); err == nil {
+				entry.Addresses = addrs
+			}
+			for _, pm := range c.Ports() {
+				entry.Ports = append(entry.Ports, &Port{
+					Protocol:      pm.Protocol,
+					HostPort:      pm.HostPort,
+					ContainerPort: pm.ContainerPort,
+				})
+			}
+			entries["containers/"+node+"/"+c.UUID()] = entry
+		}
+		return entries, nil
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package registry publishes the host and the containers running on it to etcd
+// or Consul, so that service discovery and schedulers outside of the host can
+// find them. The entries are put with a TTL and refreshed while the host is up,
+// so those of a host which goes away expire on their own.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apcera/logray"
+)
+
+// DefaultPrefix is the key the entries are published under when no other is
+// given.
+const DefaultPrefix = "/kurma"
+
+// EntriesFunc returns the entries to publish, by their keys relative to the
+// prefix. Each value is published as JSON.
+type EntriesFunc func() (map[string]interface{}, error)
+
+// Publisher puts the entries in a store three times per TTL, or sooner when
+// woken, and deletes those which are no longer returned.
+type Publisher struct {
+	Log *logray.Logger
+
+	store   Store
+	prefix  string
+	ttl     time.Duration
+	entries EntriesFunc
+
+	// published are the keys which have been put and not since deleted. It
+	// is only used by the publishing loop, and by Stop once it has exited.
+	published map[string]bool
+
+	stopOnce sync.Once
+	wakech   chan bool
+	stopch   chan bool
+	donech   chan bool
+}
+
+// NewPublisher returns a Publisher which puts the entries in the store under
+// the prefix with the TTL.
+func NewPublisher(store Store, prefix string, ttl time.Duration, entries EntriesFunc) *Publisher {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Publisher{
+		Log:       logray.New(),
+		store:     store,
+		prefix:    prefix,
+		ttl:       ttl,
+		entries:   entries,
+		published: make(map[string]bool),
+		wakech:    make(chan bool, 1),
+		stopch:    make(chan bool),
+		donech:    make(chan bool),
+	}
+}
+
+// Start begins publishing the entries in the background.
+func (p *Publisher) Start() {
+	go p.loop()
+}
+
+// Wake has the entries published again without waiting for the next round,
+// such as when a container has changed.
+func (p *Publisher) Wake() {
+	select {
+	case p.wakech <- true:
+	default:
+	}
+}
+
+// Stop stops publishing and deletes the entries which were published, so the
+// host is removed right away rather than when they expire.
+func (p *Publisher) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopch)
+		<-p.donech
+		for _, key := range p.sortedPublished() {
+			if err := p.store.Delete(key); err != nil {
+				p.Log.Warnf("Failed to delete %s from the registry: %v", key, err)
+			}
+			delete(p.published, key)
+		}
+	})
+}
+
+func (p *Publisher) loop() {
+	defer close(p.donech)
+	for {
+		if err := p.publish(); err != nil {
+			p.Log.Warnf("Failed to publish to the registry: %v", err)
+		}
+		timer := time.NewTimer(p.ttl / 3)
+		select {
+		case <-timer.C:
+		case <-p.wakech:
+		case <-p.stopch:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// publish puts all of the current entries, and deletes the keys of those which
+// are gone. A failure for one key doesn't stop the others, and the first error
+// is returned.
+func (p *Publisher) publish() error {
+	entries, err := p.entries()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	failed := 0
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+	}
+
+	current := make(map[string]bool, len(entries))
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := path.Join(p.prefix, name)
+		current[key] = true
+		value, err := json.Marshal(entries[name])
+		if err != nil {
+			fail(fmt.Errorf("failed to encode %s: %v", key, err))
+			continue
+		}
+		// the key is tracked even if the put fails, as an earlier put of it
+		// may have succeeded
+		p.published[key] = true
+		if err := p.store.Put(key, value, p.ttl); err != nil {
+			fail(fmt.Errorf("failed to put %s: %v", key, err))
+		}
+	}
+
+	for _, key := range p.sortedPublished() {
+		if current[key] {
+			continue
+		}
+		if err := p.store.Delete(key); err != nil {
+			fail(fmt.Errorf("failed to delete %s: %v", key, err))
+			continue
+		}
+		delete(p.published, key)
+	}
+
+	if failed > 1 {
+		return fmt.Errorf("%v, and %d other errors", firstErr, failed-1)
+	}
+	return firstErr
+}
+
+func (p *Publisher) sortedPublished() []string {
+	keys := make([]string, 0, len(p.published))
+	for key := range p.published {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
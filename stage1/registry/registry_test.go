@@ -0,0 +1,151 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// memoryStore is a Store which keeps the keys in memory, and can be made to
+// fail.
+type memoryStore struct {
+	mutex sync.Mutex
+	keys  map[string]string
+	ttls  map[string]time.Duration
+	fail  bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{keys: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (s *memoryStore) Put(key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("unavailable")
+	}
+	s.keys[key] = string(value)
+	s.ttls[key] = ttl
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("unavailable")
+	}
+	delete(s.keys, key)
+	return nil
+}
+
+func (s *memoryStore) snapshot() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make(map[string]string, len(s.keys))
+	for k, v := range s.keys {
+		keys[k] = v
+	}
+	return keys
+}
+
+func TestPublisherPutsAndDeletes(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	store := newMemoryStore()
+	entries := map[string]interface{}{
+		"hosts/a":        map[string]string{"node": "a"},
+		"containers/a/1": map[string]string{"uuid": "1"},
+	}
+	p := NewPublisher(store, "", 30*time.Second, func() (map[string]interface{}, error) {
+		return entries, nil
+	})
+
+	TestExpectSuccess(t, p.publish())
+	keys := store.snapshot()
+	TestEqual(t, len(keys), 2)
+	TestEqual(t, keys["/kurma/hosts/a"], `{"node":"a"}`)
+	TestEqual(t, keys["/kurma/containers/a/1"], `{"uuid":"1"}`)
+	TestEqual(t, store.ttls["/kurma/hosts/a"], 30*time.Second)
+
+	// a container which is gone is deleted
+	delete(entries, "containers/a/1")
+	entries["containers/a/2"] = map[string]string{"uuid": "2"}
+	TestExpectSuccess(t, p.publish())
+	keys = store.snapshot()
+	TestEqual(t, len(keys), 2)
+	_, ok := keys["/kurma/containers/a/1"]
+	TestFalse(t, ok)
+	TestEqual(t, keys["/kurma/containers/a/2"], `{"uuid":"2"}`)
+
+	// deletes which fail are retried on the next round
+	delete(entries, "containers/a/2")
+	store.fail = true
+	TestExpectError(t, p.publish())
+	store.fail = false
+	TestExpectSuccess(t, p.publish())
+	keys = store.snapshot()
+	TestEqual(t, len(keys), 1)
+
+	// the remaining entries are deleted when it is stopped
+	p.Start()
+	p.Stop()
+	TestEqual(t, len(store.snapshot()), 0)
+}
+
+func TestPublisherEntriesError(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	store := newMemoryStore()
+	p := NewPublisher(store, "/custom", time.Minute, func() (map[string]interface{}, error) {
+		return nil, fmt.Errorf("failed")
+	})
+	TestExpectError(t, p.publish())
+	TestEqual(t, len(store.snapshot()), 0)
+}
+
+func TestPublisherWake(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	store := newMemoryStore()
+	var mutex sync.Mutex
+	entries := map[string]interface{}{"hosts/a": 1}
+	p := NewPublisher(store, "/custom", time.Hour, func() (map[string]interface{}, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		copied := make(map[string]interface{}, len(entries))
+		for k, v := range entries {
+			copied[k] = v
+		}
+		return copied, nil
+	})
+	p.Start()
+	defer p.Stop()
+
+	waitFor := func(n int) {
+		for i := 0; i < 100; i++ {
+			if len(store.snapshot()) == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d keys, have %v", n, store.snapshot())
+	}
+	waitFor(1)
+
+	mutex.Lock()
+	entries["hosts/b"] = 2
+	mutex.Unlock()
+	p.Wake()
+	waitFor(2)
+	TestEqual(t, store.snapshot()["/custom/hosts/b"], "2")
+}
@@ -0,0 +1,237 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is a key/value store which entries are published to. Each key is put
+// with a TTL, and is removed by the store unless it is put again before the TTL
+// passes.
+type Store interface {
+	Put(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// The endpoints the stores are reached on when none is given.
+const (
+	DefaultEtcdEndpoint   = "http://127.0.0.1:2379"
+	DefaultConsulEndpoint = "http://127.0.0.1:8500"
+)
+
+// requestTimeout is how long each request to a store is given.
+const requestTimeout = 10 * time.Second
+
+// NewStore returns the store for the backend, which is either "etcd" or
+// "consul", reached over HTTP at the endpoint.
+func NewStore(backend, endpoint string) (Store, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	switch backend {
+	case "etcd":
+		if endpoint == "" {
+			endpoint = DefaultEtcdEndpoint
+		}
+		return &etcdStore{endpoint: strings.TrimSuffix(endpoint, "/"), client: client}, nil
+	case "consul":
+		if endpoint == "" {
+			endpoint = DefaultConsulEndpoint
+		}
+		return &consulStore{endpoint: strings.TrimSuffix(endpoint, "/"), client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown registry backend %q, it must be etcd or consul", backend)
+}
+
+// etcdStore puts keys through the etcd v2 API, which expires each key on its
+// own TTL.
+type etcdStore struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (s *etcdStore) keyURL(key string) string {
+	return s.endpoint + "/v2/keys/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *etcdStore) Put(key string, value []byte, ttl time.Duration) error {
+	form := url.Values{
+		"value": {string(value)},
+		"ttl":   {strconv.Itoa(ttlSeconds(ttl))},
+	}
+	req, err := http.NewRequest("PUT", s.keyURL(key), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(s.client, req, nil, http.StatusOK, http.StatusCreated)
+}
+
+func (s *etcdStore) Delete(key string) error {
+	req, err := http.NewRequest("DELETE", s.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	return do(s.client, req, nil, http.StatusOK, http.StatusNotFound)
+}
+
+// consulStore puts keys through the Consul KV API. Consul expires sessions
+// rather than keys, so the keys are acquired by a session which deletes them
+// when it expires, and which is renewed as the keys are put.
+type consulStore struct {
+	endpoint string
+	client   *http.Client
+
+	mutex   sync.Mutex
+	session string
+	ttl     time.Duration
+	renewed time.Time
+}
+
+func (s *consulStore) keyURL(key string) string {
+	return s.endpoint + "/v1/kv/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *consulStore) Put(key string, value []byte, ttl time.Duration) error {
+	session, err := s.ensureSession(ttl)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", s.keyURL(key)+"?acquire="+url.QueryEscape(session), bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	var acquired bool
+	if err := do(s.client, req, &acquired, http.StatusOK); err != nil {
+		return err
+	}
+	if !acquired {
+		return fmt.Errorf("the key %s is held by another session", key)
+	}
+	return nil
+}
+
+func (s *consulStore) Delete(key string) error {
+	req, err := http.NewRequest("DELETE", s.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	return do(s.client, req, nil, http.StatusOK)
+}
+
+// ensureSession returns the session the keys are acquired by, creating it if
+// there is none or the TTL has changed, and renewing it once a third of its TTL
+// has passed since it was last renewed.
+func (s *consulStore) ensureSession(ttl time.Duration) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.session != "" && s.ttl == ttl {
+		if time.Since(s.renewed) < ttl/3 {
+			return s.session, nil
+		}
+		req, err := http.NewRequest("PUT", s.endpoint+"/v1/session/renew/"+s.session, nil)
+		if err != nil {
+			return "", err
+		}
+		err = do(s.client, req, nil, http.StatusOK)
+		if err == nil {
+			s.renewed = time.Now()
+			return s.session, nil
+		}
+		if !isStatus(err, http.StatusNotFound) {
+			return "", err
+		}
+		// the session expired, so its keys are gone along with it
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"Name":      "kurma",
+		"TTL":       fmt.Sprintf("%ds", ttlSeconds(ttl)),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("PUT", s.endpoint+"/v1/session/create", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		ID string
+	}
+	if err := do(s.client, req, &created, http.StatusOK); err != nil {
+		return "", fmt.Errorf("failed to create a session: %v", err)
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("failed to create a session: no ID was returned")
+	}
+	s.session, s.ttl, s.renewed = created.ID, ttl, time.Now()
+	return s.session, nil
+}
+
+// ttlSeconds rounds the TTL up to whole seconds, as the stores require.
+func ttlSeconds(ttl time.Duration) int {
+	n := int((ttl + time.Second - 1) / time.Second)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// statusError is returned when a store responds with an unexpected status.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("unexpected response: %d %s", e.code, http.StatusText(e.code))
+	}
+	return fmt.Sprintf("unexpected response: %d %s: %s", e.code, http.StatusText(e.code), e.body)
+}
+
+func isStatus(err error, code int) bool {
+	serr, ok := err.(*statusError)
+	return ok && serr.code == code
+}
+
+// do sends the request, returning an error unless the response has one of the
+// statuses. The response body is decoded into v if it isn't nil.
+func do(client *http.Client, req *http.Request, v interface{}, statuses ...int) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	ok := false
+	for _, code := range statuses {
+		if resp.StatusCode == code {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode the response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestNewStore(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s, err := NewStore("etcd", "")
+	TestExpectSuccess(t, err)
+	TestEqual(t, s.(*etcdStore).endpoint, DefaultEtcdEndpoint)
+
+	s, err = NewStore("consul", "http://consul:8500/")
+	TestExpectSuccess(t, err)
+	TestEqual(t, s.(*consulStore).endpoint, "http://consul:8500")
+
+	_, err = NewStore("zookeeper", "")
+	TestExpectError(t, err)
+}
+
+func TestEtcdStore(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	var mutex sync.Mutex
+	keys := make(map[string]string)
+	ttls := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/v2/keys")
+		switch r.Method {
+		case "PUT":
+			r.ParseForm()
+			keys[key] = r.PostForm.Get("value")
+			ttls[key] = r.PostForm.Get("ttl")
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			if _, ok := keys[key]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(keys, key)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewStore("etcd", server.URL)
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, s.Put("/kurma/hosts/a", []byte(`{"node":"a"}`), 1500*time.Millisecond))
+	TestEqual(t, keys["/kurma/hosts/a"], `{"node":"a"}`)
+	TestEqual(t, ttls["/kurma/hosts/a"], "2")
+
+	TestExpectSuccess(t, s.Delete("/kurma/hosts/a"))
+	TestEqual(t, len(keys), 0)
+
+	// deleting a key which has already expired succeeds
+	TestExpectSuccess(t, s.Delete("/kurma/hosts/a"))
+}
+
+// fakeConsul implements the parts of Consul's session and KV APIs the store
+// uses.
+type fakeConsul struct {
+	mutex    sync.Mutex
+	sessions map[string]string
+	keys     map[string]string
+	owners   map[string]string
+	created  int
+	renewed  int
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		c.created++
+		id := fmt.Sprintf("session-%d", c.created)
+		c.sessions[id] = body["TTL"]
+		json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")
+		if _, ok := c.sessions[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		c.renewed++
+		w.Write([]byte("[]"))
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		if r.Method == "DELETE" {
+			delete(c.keys, key)
+			w.Write([]byte("true"))
+			return
+		}
+		session := r.URL.Query().Get("acquire")
+		if _, ok := c.sessions[session]; !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if owner, ok := c.owners[key]; ok && owner != session {
+			w.Write([]byte("false"))
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		c.keys[key] = string(b)
+		c.owners[key] = session
+		w.Write([]byte("true"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// expire expires the sessions, deleting the keys they hold.
+func (c *fakeConsul) expire() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, owner := range c.owners {
+		if _, ok := c.sessions[owner]; ok {
+			delete(c.keys, key)
+			delete(c.owners, key)
+		}
+	}
+	c.sessions = make(map[string]string)
+}
+
+func TestConsulStore(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	consul := &fakeConsul{
+		sessions: make(map[string]string),
+		keys:     make(map[string]string),
+		owners:   make(map[string]string),
+	}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	store, err := NewStore("consul", server.URL)
+	TestExpectSuccess(t, err)
+	s := store.(*consulStore)
+
+	TestExpectSuccess(t, s.Put("/kurma/hosts/a", []byte(`{"node":"a"}`), 30*time.Second))
+	TestEqual(t, consul.keys["kurma/hosts/a"], `{"node":"a"}`)
+	TestEqual(t, consul.sessions["session-1"], "30s")
+	TestEqual(t, consul.created, 1)
+
+	// the session is reused, and renewed once a third of its TTL has passed
+	TestExpectSuccess(t, s.Put("/kurma/hosts/a", []byte(`{"node":"a"}`), 30*time.Second))
+	TestEqual(t, consul.created, 1)
+	TestEqual(t, consul.renewed, 0)
+	s.renewed = time.Now().Add(-11 * time.Second)
+	TestExpectSuccess(t, s.Put("/kurma/hosts/a", []byte(`{"node":"a"}`), 30*time.Second))
+	TestEqual(t, consul.created, 1)
+	TestEqual(t, consul.renewed, 1)
+
+	// a session which has expired is replaced
+	consul.expire()
+	TestEqual(t, len(consul.keys), 0)
+	s.renewed = time.Now().Add(-11 * time.Second)
+	TestExpectSuccess(t, s.Put("/kurma/hosts/a", []byte(`{"node":"a"}`), 30*time.Second))
+	TestEqual(t, consul.created, 2)
+	TestEqual(t, consul.owners["kurma/hosts/a"], "session-2")
+
+	// keys held by another session can't be put
+	consul.owners["kurma/hosts/b"] = "other"
+	TestExpectError(t, s.Put("/kurma/hosts/b", []byte(`{}`), 30*time.Second))
+
+	TestExpectSuccess(t, s.Delete("/kurma/hosts/a"))
+	_, ok := consul.keys["kurma/hosts/a"]
+	TestFalse(t, ok)
+}
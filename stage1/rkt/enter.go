@@ -0,0 +1,80 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package rkt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	client2 "github.com/apcera/kurma/stage2/client"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/apcera/util/envmap"
+)
+
+// defaultPath is the PATH commands are run with unless the app sets its own.
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Enter runs the command following -- within the namespaces of the pod's
+// process given by --pid, with the environment of the app given by --appname,
+// and returns its exit code. The command is added to the pod's cgroup so it is
+// cleaned up along with the pod. rkt calls enter from the pod's directory,
+// which is named after its UUID.
+func Enter(dir string, args []string) (int, error) {
+	a := parseArguments(args)
+	pid, err := strconv.Atoi(a.flags["pid"])
+	if err != nil || pid <= 0 {
+		return -1, fmt.Errorf("a valid process ID must be given with --pid")
+	}
+	if len(a.command) == 0 {
+		return -1, fmt.Errorf("no command was given")
+	}
+
+	pod, _, _, err := loadPod(dir)
+	if err != nil {
+		return -1, err
+	}
+	app, err := findApp(pod, a.flags["appname"])
+	if err != nil {
+		return -1, err
+	}
+	env := envmap.NewEnvMap()
+	env.Set("PATH", defaultPath)
+	env.Set("AC_APP_NAME", app.Name.String())
+	if app.App != nil {
+		for _, e := range app.App.Environment {
+			env.Set(e.Name, e.Value)
+		}
+	}
+
+	launcher := &client2.Launcher{
+		Environment: env.Strings(),
+		Stdin:       os.Stdin,
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+	}
+	launcher.SetNS(pid)
+	launcher.UserNamespace = 0
+	children, err := cgroups.Open(cgroupName(filepath.Base(dir))).Children()
+	if err != nil {
+		return -1, err
+	}
+	if len(children) > 0 {
+		launcher.Taskfiles = children[0].TasksFiles()
+	}
+
+	p, err := launcher.Run(a.command...)
+	if err != nil {
+		return -1, err
+	}
+	ps, err := p.Wait()
+	if err != nil {
+		return -1, err
+	}
+	if status, ok := ps.Sys().(syscall.WaitStatus); ok {
+		return status.ExitStatus(), nil
+	}
+	return 0, nil
+}
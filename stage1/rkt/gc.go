@@ -0,0 +1,29 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package rkt
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/util/cgroups"
+)
+
+// GC removes what is left of the pod in the directory once it has exited. Run
+// normally destroys the pod's container itself, so this only finds anything to
+// remove if run was killed, in which case any of the pod's processes which are
+// still running are killed.
+func GC(dir string, args []string) error {
+	uuid, err := parseArguments(args).uuid()
+	if err != nil {
+		return err
+	}
+	if err := cgroups.Open(cgroupName(uuid)).Destroy(); err != nil {
+		return fmt.Errorf("failed to destroy the pod's cgroup: %v", err)
+	}
+	if err := container.RemoveDirectory(filepath.Join(dir, containerDirectory)); err != nil {
+		return fmt.Errorf("failed to remove the pod's container: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package rkt implements the stage1 entrypoints rkt calls, so that kurma can be
+// used as the stage1 image of rkt pods. The image holds the kurma-rkt binary
+// with run, enter, and gc linked to it, named by its manifest's
+// coreos.com/rkt/stage1/run, coreos.com/rkt/stage1/enter, and
+// coreos.com/rkt/stage1/gc annotations.
+//
+// Each pod is run as a kurma container by a container manager of its own, kept
+// within the pod's directory and under a cgroup named after the pod. Kurma runs
+// all of a container's apps from one root filesystem, so the pod's apps must all
+// use the same image, and pods must be prepared without overlayfs so that the
+// image's files are present in the pod's directory. Only host networking is
+// supported, and volumes other than empty ones aren't.
+package rkt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// The paths within the pod's directory which rkt and the stage1 share.
+const (
+	podManifestFile     = "pod"
+	pidFile             = "pid"
+	overlayPreparedFile = "overlay-prepared"
+	appsDirectory       = "stage1/rootfs/opt/stage2"
+	statusDirectory     = "stage1/rootfs/rkt/status"
+
+	// containerDirectory is where the pod's kurma container is kept.
+	containerDirectory = "kurma"
+)
+
+// arguments are the arguments rkt passes to an entrypoint. Flags are given as
+// --name=value, or as --name alone for a flag which is set. Flags which aren't
+// known are kept, since newer versions of rkt may pass flags which kurma has no
+// use for.
+type arguments struct {
+	flags      map[string]string
+	positional []string
+
+	// command is what follows --, which is the command enter is to run.
+	command []string
+}
+
+// parseArguments splits the arguments an entrypoint was called with.
+func parseArguments(args []string) *arguments {
+	a := &arguments{flags: make(map[string]string)}
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			a.command = append([]string(nil), args[i+1:]...)
+			return a
+		case strings.HasPrefix(arg, "--"):
+			parts := strings.SplitN(arg[2:], "=", 2)
+			if len(parts) == 1 {
+				a.flags[parts[0]] = "true"
+			} else {
+				a.flags[parts[0]] = parts[1]
+			}
+		default:
+			a.positional = append(a.positional, arg)
+		}
+	}
+	return a
+}
+
+// bool returns whether the flag is set.
+func (a *arguments) bool(name string) bool {
+	switch a.flags[name] {
+	case "", "false", "0":
+		return false
+	}
+	return true
+}
+
+// uuid returns the pod's UUID, which rkt passes to run and gc as their only
+// positional argument.
+func (a *arguments) uuid() (string, error) {
+	if len(a.positional) != 1 {
+		return "", fmt.Errorf("the pod's UUID must be given")
+	}
+	return a.positional[0], nil
+}
+
+// cgroupName returns the name of the cgroup the pod's container manager
+// creates its container within.
+func cgroupName(uuid string) string {
+	return "rkt-" + uuid
+}
+
+// loadPod reads the pod manifest rkt wrote to the pod's directory. It returns
+// the pod to create the kurma container with, along with the manifest of the
+// image its apps share and the directory holding that image.
+func loadPod(dir string) (*schema.PodManifest, *schema.ImageManifest, string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, podManifestFile))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	pod := new(schema.PodManifest)
+	if err := json.Unmarshal(b, pod); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to parse the pod manifest: %v", err)
+	}
+	if len(pod.Apps) == 0 {
+		return nil, nil, "", fmt.Errorf("the pod has no apps")
+	}
+	for _, ra := range pod.Apps[1:] {
+		if ra.Image.ID.String() != pod.Apps[0].Image.ID.String() {
+			return nil, nil, "", fmt.Errorf("the pod's apps must all use the same image, but %q and %q don't",
+				pod.Apps[0].Name, ra.Name)
+		}
+	}
+
+	imageDir := filepath.Join(dir, appsDirectory, pod.Apps[0].Name.String())
+	b, err = ioutil.ReadFile(filepath.Join(imageDir, "manifest"))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	manifest := new(schema.ImageManifest)
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to parse the image manifest: %v", err)
+	}
+
+	kpod, err := kurmaPod(pod, manifest)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return kpod, manifest, imageDir, nil
+}
+
+// kurmaPod returns the pod as kurma runs it. Kurma containers have no pod
+// volumes, so empty volumes are dropped along with their mounts, leaving the
+// apps to use the directories within the image, and other volumes aren't
+// supported. Apps without a name for their image are given the image's.
+func kurmaPod(pod *schema.PodManifest, manifest *schema.ImageManifest) (*schema.PodManifest, error) {
+	empty := make(map[types.ACName]bool)
+	for _, v := range pod.Volumes {
+		if v.Kind != "empty" {
+			return nil, fmt.Errorf("volume %q is of kind %q, and only empty volumes are supported", v.Name, v.Kind)
+		}
+		empty[v.Name] = true
+	}
+
+	kpod := *pod
+	kpod.Volumes = nil
+	kpod.Apps = make(schema.AppList, len(pod.Apps))
+	for i, ra := range pod.Apps {
+		for _, m := range ra.Mounts {
+			if !empty[m.Volume] {
+				return nil, fmt.Errorf("app %q mounts volume %q, which the pod doesn't have", ra.Name, m.Volume)
+			}
+		}
+		ra.Mounts = nil
+		if ra.Image.Name == nil {
+			name := manifest.Name
+			ra.Image.Name = &name
+		}
+		kpod.Apps[i] = ra
+	}
+	return &kpod, nil
+}
+
+// findApp returns the pod's app with the name, or its first app if no name is
+// given.
+func findApp(pod *schema.PodManifest, name string) (*schema.RuntimeApp, error) {
+	if name == "" {
+		return &pod.Apps[0], nil
+	}
+	for i := range pod.Apps {
+		if pod.Apps[i].Name.String() == name {
+			return &pod.Apps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the pod has no app named %q", name)
+}
+
+// writeStatus records the exit code of the app where rkt reads it from.
+func writeStatus(dir, app string, code int) error {
+	statusDir := filepath.Join(dir, statusDirectory)
+	if err := os.MkdirAll(statusDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(statusDir, app), []byte(fmt.Sprintf("%d", code)), 0644)
+}
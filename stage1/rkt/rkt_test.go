@@ -0,0 +1,118 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package rkt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+const testImageManifest = `{
+	"acKind": "ImageManifest",
+	"acVersion": "0.7.4",
+	"name": "example.com/web",
+	"app": {"exec": ["/bin/web"], "user": "0", "group": "0"}
+}`
+
+// writePod lays out a pod directory as rkt prepares it, with the pod manifest
+// and the image of its first app.
+func writePod(t *testing.T, podManifest string) string {
+	dir := TempDir(t)
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, podManifestFile), []byte(podManifest), 0644))
+	imageDir := filepath.Join(dir, appsDirectory, "web")
+	TestExpectSuccess(t, os.MkdirAll(filepath.Join(imageDir, "rootfs"), 0755))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(imageDir, "manifest"), []byte(testImageManifest), 0644))
+	return dir
+}
+
+func TestParseArguments(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	a := parseArguments([]string{"--debug", "--net=host", "--interactive=false", "--mds-token=abc=", "0f9b2f6e"})
+	TestEqual(t, a.bool("debug"), true)
+	TestEqual(t, a.bool("interactive"), false)
+	TestEqual(t, a.flags["net"], "host")
+	TestEqual(t, a.flags["mds-token"], "abc=")
+	uuid, err := a.uuid()
+	TestExpectSuccess(t, err)
+	TestEqual(t, uuid, "0f9b2f6e")
+
+	a = parseArguments([]string{"--pid=42", "--appname=web", "--", "/bin/sh", "-c", "--debug"})
+	TestEqual(t, a.flags["pid"], "42")
+	TestEqual(t, a.bool("debug"), false)
+	TestEqual(t, a.command, []string{"/bin/sh", "-c", "--debug"})
+	_, err = a.uuid()
+	TestExpectError(t, err)
+}
+
+func TestLoadPod(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: The apps are given the image's name, and empty volumes are dropped
+	// along with their mounts.
+	dir := writePod(t, `{
+		"acKind": "PodManifest",
+		"acVersion": "0.7.4",
+		"apps": [
+			{"name": "web", "image": {"id": "sha512-aaaa"}, "mounts": [{"volume": "cache", "mountPoint": "cache"}]},
+			{"name": "sidecar", "image": {"id": "sha512-aaaa"}, "app": {"exec": ["/bin/sidecar"], "user": "0", "group": "0"}}
+		],
+		"volumes": [{"name": "cache", "kind": "empty"}]
+	}`)
+	pod, manifest, imageDir, err := loadPod(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, manifest.Name.String(), "example.com/web")
+	TestEqual(t, imageDir, filepath.Join(dir, appsDirectory, "web"))
+	TestEqual(t, len(pod.Apps), 2)
+	TestEqual(t, len(pod.Volumes), 0)
+	TestEqual(t, len(pod.Apps[0].Mounts), 0)
+	TestEqual(t, pod.Apps[1].Image.Name.String(), "example.com/web")
+
+	app, err := findApp(pod, "sidecar")
+	TestExpectSuccess(t, err)
+	TestEqual(t, app.Name.String(), "sidecar")
+	app, err = findApp(pod, "")
+	TestExpectSuccess(t, err)
+	TestEqual(t, app.Name.String(), "web")
+	_, err = findApp(pod, "db")
+	TestExpectError(t, err)
+
+	// Test 2: Apps using different images are rejected.
+	dir = writePod(t, `{
+		"acKind": "PodManifest",
+		"acVersion": "0.7.4",
+		"apps": [
+			{"name": "web", "image": {"id": "sha512-aaaa"}},
+			{"name": "db", "image": {"id": "sha512-bbbb"}}
+		]
+	}`)
+	_, _, _, err = loadPod(dir)
+	TestExpectError(t, err)
+
+	// Test 3: Host volumes aren't supported.
+	dir = writePod(t, `{
+		"acKind": "PodManifest",
+		"acVersion": "0.7.4",
+		"apps": [{"name": "web", "image": {"id": "sha512-aaaa"}, "mounts": [{"volume": "data", "mountPoint": "data"}]}],
+		"volumes": [{"name": "data", "kind": "host", "source": "/srv/data"}]
+	}`)
+	_, _, _, err = loadPod(dir)
+	TestExpectError(t, err)
+}
+
+func TestWriteStatus(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	TestExpectSuccess(t, writeStatus(dir, "web", 3))
+	b, err := ioutil.ReadFile(filepath.Join(dir, statusDirectory, "web"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "3")
+}
@@ -0,0 +1,135 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package rkt
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/logray"
+	"github.com/apcera/util/tarhelper"
+)
+
+// Run runs the pod in the directory as a kurma container, returning once its
+// apps have exited with the exit code rkt should exit with. The apps' output is
+// copied to stdout and stderr, or with --interactive the first app is given a
+// console connected to stdin and stdout. Interrupting or terminating the
+// process stops the apps.
+func Run(dir string, args []string) (int, error) {
+	log := logray.New()
+	a := parseArguments(args)
+	uuid, err := a.uuid()
+	if err != nil {
+		return -1, err
+	}
+	if net := a.flags["net"]; net != "" && net != "host" {
+		return -1, fmt.Errorf("only host networking is supported, the pod must be run with --net=host")
+	}
+	if a.flags["private-users"] != "" {
+		return -1, fmt.Errorf("running pods in user namespaces is not supported")
+	}
+	if _, err := os.Stat(filepath.Join(dir, overlayPreparedFile)); err == nil {
+		return -1, fmt.Errorf("the pod must be prepared with --no-overlay")
+	}
+
+	pod, manifest, imageDir, err := loadPod(dir)
+	if err != nil {
+		return -1, err
+	}
+
+	containerDir := filepath.Join(dir, containerDirectory)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		return -1, err
+	}
+	manager, err := container.NewManager(&container.Options{
+		ParentCgroupName:   cgroupName(uuid),
+		ContainerDirectory: containerDir,
+		RequiredNamespaces: []string{"ipc", "mount", "pid", "uts"},
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to create the container manager: %v", err)
+	}
+
+	// the image's directory is laid out as an ACI, so it is archived as the
+	// image the container is created from
+	r, w := io.Pipe()
+	go func() {
+		t := tarhelper.NewTar(w, imageDir)
+		t.IncludeOwners = true
+		w.CloseWithError(t.Archive())
+	}()
+	interactive := a.bool("interactive")
+	c, err := manager.Create("", manifest, r, &container.CreateOptions{
+		Pod:           pod,
+		RestartPolicy: container.RestartNever,
+		Tty:           interactive,
+		Attach:        !interactive,
+	})
+	if err != nil {
+		r.Close()
+		return -1, err
+	}
+	defer func() {
+		if err := c.Stop(); err != nil {
+			log.Warnf("Failed to destroy the pod's container: %v", err)
+		}
+	}()
+	if err := c.StartError(); err != nil {
+		return -1, fmt.Errorf("the pod failed to start: %v", err)
+	}
+	pid := strconv.Itoa(c.Pid())
+	if err := ioutil.WriteFile(filepath.Join(dir, pidFile), []byte(pid), 0644); err != nil {
+		return -1, err
+	}
+
+	var detach func()
+	if interactive {
+		var console *os.File
+		console, detach, err = c.AttachConsole(os.Stdout)
+		if err == nil {
+			go io.Copy(console, os.Stdin)
+		}
+	} else {
+		_, detach, err = c.AttachStdio(os.Stdout, os.Stderr)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	wait(log, c)
+	c.DrainOutput()
+	detach()
+
+	for _, app := range c.Apps() {
+		if err := writeStatus(dir, app.Name, app.ExitCode); err != nil {
+			return -1, err
+		}
+	}
+	return c.ExitCode(), nil
+}
+
+// wait blocks until the container exits, stopping its apps if the process is
+// interrupted or terminated first.
+func wait(log *logray.Logger, c *container.Container) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	exited := c.Exited()
+	for {
+		select {
+		case <-exited:
+			return
+		case sig := <-signals:
+			log.Infof("Stopping the pod's apps on %s", sig)
+			go c.StopApps(c.StopGracePeriod())
+		}
+	}
+}
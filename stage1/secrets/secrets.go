@@ -0,0 +1,220 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package secrets implements the host's store of secrets, which are kept
+// encrypted on disk and given to the containers which reference them.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// MaxSize is the largest value a secret may have, in bytes.
+const MaxSize = 1 << 20
+
+// maxNameLength is the longest name a secret may be given.
+const maxNameLength = 253
+
+// keySize is the size of the keys generated by LoadKey.
+const keySize = 32
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateName returns an error if the name can't be given to a secret.
+func ValidateName(name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("the secret name %q is longer than %d characters", name, maxNameLength)
+	}
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("the secret name %q must start with a letter or digit and contain only letters, digits, '_', '.', and '-'", name)
+	}
+	return nil
+}
+
+// Store keeps each secret in its own file within a directory, encrypted with
+// AES-256-GCM. The secret's name is authenticated along with its value, so a
+// secret's file can't be passed off as another's.
+type Store struct {
+	directory string
+	aead      cipher.AEAD
+	lock      sync.Mutex
+}
+
+// NewStore returns a store of the secrets in the directory, which are
+// encrypted with the key. The key may be of any length, as the encryption key
+// is derived from it.
+func NewStore(directory string, key []byte) (*Store, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("a key is required to encrypt secrets")
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(directory, os.FileMode(0700)); err != nil {
+		return nil, err
+	}
+	return &Store{directory: directory, aead: aead}, nil
+}
+
+// LoadKey returns the key kept in the file, generating one and writing it to
+// the file if it doesn't exist yet.
+func LoadKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("the key file %s is empty", path)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate a key: %v", err)
+	}
+	if err := writeFile(path, key); err != nil {
+		return nil, fmt.Errorf("failed to write the key to %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// Create stores a new secret. Secrets can't be replaced, so one which is
+// still referenced by containers keeps its value.
+func (s *Store) Create(name string, value []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if len(value) > MaxSize {
+		return fmt.Errorf("the secret is larger than %d bytes", MaxSize)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, value, []byte(name))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	path := filepath.Join(s.directory, name)
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("secret %q already exists", name)
+	}
+	return writeFile(path, sealed)
+}
+
+// Get returns the value of the secret.
+func (s *Store) Get(name string) ([]byte, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	sealed, err := ioutil.ReadFile(filepath.Join(s.directory, name))
+	s.lock.Unlock()
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("secret %q does not exist", name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("secret %q is corrupt", name)
+	}
+	value, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %q, it may have been stored with another key", name)
+	}
+	return value, nil
+}
+
+// Exists returns whether the secret is in the store.
+func (s *Store) Exists(name string) bool {
+	if ValidateName(name) != nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err := os.Lstat(filepath.Join(s.directory, name))
+	return err == nil
+}
+
+// List returns the names of the secrets in the store.
+func (s *Store) List() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fis, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && ValidateName(fi.Name()) == nil {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes the secret from the store.
+func (s *Store) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := os.Remove(filepath.Join(s.directory, name)); os.IsNotExist(err) {
+		return fmt.Errorf("secret %q does not exist", name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeFile writes the data to a temporary file which is then renamed into
+// place, so the file is never left partially written.
+func writeFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestStore(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	s, err := NewStore(dir, []byte("key"))
+	TestExpectSuccess(t, err)
+
+	TestExpectSuccess(t, s.Create("db-password", []byte("hunter2")))
+	TestExpectError(t, s.Create("db-password", []byte("other")))
+	TestExpectError(t, s.Create("../escape", []byte("value")))
+	TestExpectError(t, s.Create("big", make([]byte, MaxSize+1)))
+
+	value, err := s.Get("db-password")
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(value), "hunter2")
+	TestTrue(t, s.Exists("db-password"))
+	TestFalse(t, s.Exists("missing"))
+
+	// the value is not stored in the clear
+	b, err := ioutil.ReadFile(filepath.Join(dir, "db-password"))
+	TestExpectSuccess(t, err)
+	TestFalse(t, strings.Contains(string(b), "hunter2"))
+
+	names, err := s.List()
+	TestExpectSuccess(t, err)
+	TestEqual(t, names, []string{"db-password"})
+
+	TestExpectSuccess(t, s.Delete("db-password"))
+	TestExpectError(t, s.Delete("db-password"))
+	_, err = s.Get("db-password")
+	TestExpectError(t, err)
+}
+
+func TestStoreRejectsTampering(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	s, err := NewStore(dir, []byte("key"))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, s.Create("a", []byte("value-a")))
+
+	// a secret's file can't be read as another secret
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a"))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "b"), b, 0600))
+	_, err = s.Get("b")
+	TestExpectError(t, err)
+
+	// nor with another key
+	other, err := NewStore(dir, []byte("other key"))
+	TestExpectSuccess(t, err)
+	_, err = other.Get("a")
+	TestExpectError(t, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), ".key")
+	key, err := LoadKey(path)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(key), keySize)
+
+	fi, err := os.Stat(path)
+	TestExpectSuccess(t, err)
+	TestEqual(t, fi.Mode().Perm(), os.FileMode(0600))
+
+	again, err := LoadKey(path)
+	TestExpectSuccess(t, err)
+	TestEqual(t, again, key)
+}
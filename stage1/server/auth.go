@@ -0,0 +1,318 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Role is the level of access a user is given to the API. Each role permits
+// everything the roles below it do.
+type Role int
+
+const (
+	// RoleReadOnly may list and inspect the containers and the host, and read
+	// their logs, stats, and events.
+	RoleReadOnly Role = iota + 1
+
+	// RoleOperator may also create, start, stop, and destroy containers, and
+	// manage their volumes and resources.
+	RoleOperator
+
+	// RoleAdmin may do anything, including running commands in containers,
+	// reloading the configuration, and shutting down the host.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleReadOnly: "read-only",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole returns the role with the name, which is one of "read-only",
+// "operator", or "admin".
+func ParseRole(name string) (Role, error) {
+	for r, n := range roleNames {
+		if n == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q", name)
+}
+
+// methodRoles is the role required for each method. Methods which are not
+// listed require RoleAdmin.
+var methodRoles = map[string]Role{
+	"List":           RoleReadOnly,
+	"Get":            RoleReadOnly,
+	"Inspect":        RoleReadOnly,
+	"Logs":           RoleReadOnly,
+	"Stats":          RoleReadOnly,
+	"StreamStats":    RoleReadOnly,
+	"Events":         RoleReadOnly,
+	"Wait":           RoleReadOnly,
+	"ListVolumes":    RoleReadOnly,
+	"ListSecrets":    RoleReadOnly,
+	"ListJobs":       RoleReadOnly,
+	"HostStatus":     RoleReadOnly,
+	"HostInfo":       RoleReadOnly,
+	"HostStorage":    RoleReadOnly,
+	"HostEncryption": RoleReadOnly,
+	"UpdateStatus":   RoleReadOnly,
+	"AttestHost":     RoleReadOnly,
+	"ListCrashes":    RoleReadOnly,
+	"Info":           RoleReadOnly,
+	"ListNodes":      RoleReadOnly,
+
+	"ListNetworkPolicies": RoleReadOnly,
+
+	"Create":          RoleOperator,
+	"UploadImage":     RoleOperator,
+	"Destroy":         RoleOperator,
+	"Start":           RoleOperator,
+	"Stop":            RoleOperator,
+	"Restart":         RoleOperator,
+	"UpdateResources": RoleOperator,
+	"Checkpoint":      RoleOperator,
+	"Restore":         RoleOperator,
+	"CreateVolume":    RoleOperator,
+	"DeleteVolume":    RoleOperator,
+	"CreateSecret":    RoleOperator,
+	"DeleteSecret":    RoleOperator,
+	"AddJob":          RoleOperator,
+	"RemoveJob":       RoleOperator,
+	"Apply":           RoleOperator,
+	"GarbageCollect":  RoleOperator,
+	"ExportImage":     RoleOperator,
+	"PushImage":       RoleOperator,
+
+	"Enter":        RoleAdmin,
+	"Exec":         RoleAdmin,
+	"Attach":       RoleAdmin,
+	"Export":       RoleAdmin,
+	"Commit":       RoleAdmin,
+	"Build":        RoleAdmin,
+	"GetCrash":     RoleAdmin,
+	"ReloadConfig": RoleAdmin,
+	"StartConsole": RoleAdmin,
+	"UpdateHost":   RoleAdmin,
+	"UpgradeHost":  RoleAdmin,
+	"Shutdown":     RoleAdmin,
+
+	"SetNetworkPolicy":    RoleAdmin,
+	"RemoveNetworkPolicy": RoleAdmin,
+}
+
+// AuthOptions lists the users requests to the API must be authenticated as.
+// Clients authenticate by sending a user's token with each request, or, on
+// listeners which require a client certificate, by presenting one whose common
+// name is the user's name. Requests over local unix sockets, which are created
+// with mode 0600 so that only root can connect to them, are permitted
+// everything. The roles are enforced in addition to the listeners' own limits
+// on privileged operations.
+type AuthOptions struct {
+	Users []*User
+}
+
+// User is a client of the API and the role it is given.
+type User struct {
+	Name  string
+	Role  Role
+	Token string
+}
+
+// userForToken returns the user with the token, or nil if there isn't one.
+// Every user's token is compared, in constant time, so the time taken doesn't
+// reveal how much of a token was guessed.
+func (a *AuthOptions) userForToken(token string) *User {
+	var found *User
+	for _, u := range a.Users {
+		if u.Token != "" && subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			found = u
+		}
+	}
+	return found
+}
+
+// userForName returns the user with the name, or nil if there isn't one.
+func (a *AuthOptions) userForName(name string) *User {
+	for _, u := range a.Users {
+		if u.Name == name {
+			return u
+		}
+	}
+	return nil
+}
+
+// caller is who a request is from. The user is nil if the request wasn't
+// authenticated, and badToken is whether it carried a token no user has.
+type caller struct {
+	user     *User
+	badToken bool
+}
+
+type callerKey struct{}
+
+// callerFromContext returns who the request is from, as identified by the
+// identify interceptor.
+func callerFromContext(ctx context.Context) caller {
+	c, _ := ctx.Value(callerKey{}).(caller)
+	return c
+}
+
+// identify is the interceptor which determines the user the request is from,
+// for the interceptors after it. A token sent with the request takes
+// precedence over the user the connection's client certificate names.
+func (s *rpcServer) identify(ctx context.Context, method string, handle func(context.Context) error) error {
+	var c caller
+	if s.auth != nil {
+		c.user = s.certUser
+		if token := pb.TokenFromContext(ctx); token != "" {
+			c.user = s.auth.userForToken(token)
+			c.badToken = c.user == nil
+		}
+	}
+	return handle(context.WithValue(ctx, callerKey{}, c))
+}
+
+// authorize is the interceptor which permits the request if the client is
+// authenticated as a user whose role permits the method.
+func (s *rpcServer) authorize(ctx context.Context, method string, handle func(context.Context) error) error {
+	if s.auth == nil || s.local {
+		return handle(ctx)
+	}
+
+	c := callerFromContext(ctx)
+	if c.badToken {
+		s.log.Warnf("Denied %s request with an unknown token", method)
+		return grpc.Errorf(codes.Unauthenticated, "invalid token")
+	}
+	if c.user == nil {
+		return grpc.Errorf(codes.Unauthenticated, "requests must be authenticated with a token or client certificate")
+	}
+
+	required, ok := methodRoles[method]
+	if !ok {
+		required = RoleAdmin
+	}
+	if c.user.Role < required {
+		s.log.Warnf("Denied %s request from %q, which has the %s role", method, c.user.Name, c.user.Role)
+		return grpc.Errorf(codes.PermissionDenied, "%s requires the %s role", method, required)
+	}
+	return handle(ctx)
+}
+
+// handshakeTimeout is how long a client has to complete the TLS handshake.
+const handshakeTimeout = 10 * time.Second
+
+// userListeners splits the connections accepted by a TLS listener which
+// requires client certificates by the user the certificate names, so each
+// user's connections can be served by a handler which knows who they are.
+// Connections from certificates which name no user are given to the nil user.
+type userListeners struct {
+	listener  net.Listener
+	auth      *AuthOptions
+	listeners map[*User]*userListener
+
+	// done is closed once the listener stops accepting connections, with the
+	// error it stopped with in err.
+	done chan struct{}
+	err  error
+}
+
+// userListener is the listener for one user's connections. Closing it closes
+// the listener it is split from.
+type userListener struct {
+	parent *userListeners
+	conns  chan net.Conn
+}
+
+// splitByUser returns the listener for each of the users' connections, and the
+// nil user's, and begins accepting connections on the listener.
+func splitByUser(l net.Listener, auth *AuthOptions) map[*User]net.Listener {
+	u := &userListeners{
+		listener:  l,
+		auth:      auth,
+		listeners: make(map[*User]*userListener),
+		done:      make(chan struct{}),
+	}
+	listeners := make(map[*User]net.Listener)
+	for _, user := range append([]*User{nil}, auth.Users...) {
+		ul := &userListener{parent: u, conns: make(chan net.Conn)}
+		u.listeners[user] = ul
+		listeners[user] = ul
+	}
+	go u.accept()
+	return listeners
+}
+
+// accept routes each connection once its handshake has completed, until the
+// listener is closed.
+func (u *userListeners) accept() {
+	for {
+		conn, err := u.listener.Accept()
+		if err != nil {
+			u.err = err
+			close(u.done)
+			return
+		}
+		go u.route(conn)
+	}
+}
+
+// route completes the connection's handshake and passes it to the listener for
+// the user its certificate names.
+func (u *userListeners) route(conn net.Conn) {
+	var user *User
+	if tc, ok := conn.(*tls.Conn); ok {
+		tc.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return
+		}
+		tc.SetDeadline(time.Time{})
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			user = u.auth.userForName(certs[0].Subject.CommonName)
+		}
+	}
+
+	select {
+	case u.listeners[user].conns <- conn:
+	case <-u.done:
+		conn.Close()
+	}
+}
+
+func (l *userListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.parent.done:
+		return nil, l.parent.err
+	}
+}
+
+func (l *userListener) Close() error {
+	return l.parent.listener.Close()
+}
+
+func (l *userListener) Addr() net.Addr {
+	return l.parent.listener.Addr()
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// containerFilter selects the containers a List request is for. It maps each
+// filter key to the values it may have, a container having to match one of
+// the values of every key. Labels are keyed by their key with labelPrefix,
+// which can't occur in the reserved keys.
+type containerFilter map[string][]filterValue
+
+// filterValue is a value a filter key may have. If any is set, the filter
+// only requires the label to be present.
+type filterValue struct {
+	value string
+	any   bool
+}
+
+const (
+	// labelFilterKey is the filter key used to select by labels whose keys are
+	// reserved, as in label=state=x.
+	labelFilterKey = "label"
+
+	labelPrefix = "label:"
+)
+
+// reservedFilterKeys are the filter keys which select by the container's
+// attributes rather than its labels.
+var reservedFilterKeys = map[string]bool{
+	"state":  true,
+	"name":   true,
+	"uuid":   true,
+	"health": true,
+}
+
+// parseFilters parses filters of the form key=value, or just a key to select
+// the containers having that label.
+func parseFilters(filters []string) (containerFilter, error) {
+	f := make(containerFilter)
+	for _, filter := range filters {
+		key, value, hasValue := splitFilter(filter)
+		if key == labelFilterKey {
+			if value == "" {
+				return nil, fmt.Errorf("invalid filter %q, it must name a label", filter)
+			}
+			key, value, hasValue = splitFilter(value)
+			key = labelPrefix + key
+		} else if key != "" && !reservedFilterKeys[key] {
+			key = labelPrefix + key
+		}
+
+		if key == "" || key == labelPrefix {
+			return nil, fmt.Errorf("invalid filter %q, it must be of the form key=value", filter)
+		}
+		if !hasValue && !strings.HasPrefix(key, labelPrefix) {
+			return nil, fmt.Errorf("invalid filter %q, it must be of the form %s=value", filter, key)
+		}
+		switch key {
+		case "state":
+			value = strings.ToUpper(value)
+			if _, ok := pb.Container_State_value[value]; !ok {
+				return nil, fmt.Errorf("invalid filter %q, it isn't a container state", filter)
+			}
+		case "name":
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid filter %q, the name pattern is malformed", filter)
+			}
+		}
+		f[key] = append(f[key], filterValue{value: value, any: !hasValue})
+	}
+	return f, nil
+}
+
+// splitFilter splits a filter into its key and value, if it has one.
+func splitFilter(filter string) (key, value string, hasValue bool) {
+	if i := strings.Index(filter, "="); i >= 0 {
+		return filter[:i], filter[i+1:], true
+	}
+	return filter, "", false
+}
+
+// matches returns whether the container matches one of the values of each of
+// the filter's keys.
+func (f containerFilter) matches(c *pb.Container) bool {
+	for key, values := range f {
+		matched := false
+		for _, v := range values {
+			if matchesFilter(c, key, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesFilter returns whether the container has the value for the key. Names
+// are matched as patterns and UUIDs by their prefix.
+func matchesFilter(c *pb.Container, key string, v filterValue) bool {
+	switch key {
+	case "state":
+		return c.State.String() == v.value
+	case "name":
+		ok, _ := path.Match(v.value, c.Name)
+		return ok
+	case "uuid":
+		return strings.HasPrefix(c.Uuid, v.value)
+	case "health":
+		return c.Health == v.value
+	}
+	label, ok := c.Labels[strings.TrimPrefix(key, labelPrefix)]
+	return ok && (v.any || label == v.value)
+}
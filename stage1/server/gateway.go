@@ -0,0 +1,483 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// gateway serves the API as JSON over HTTP, for clients which can't use gRPC.
+// Each request is passed through the same interceptors as those received over
+// gRPC, so it is authenticated, authorized, limited, and logged the same way.
+// The routes are:
+//
+//	GET    /info                         Info
+//	GET    /containers?filter=KEY=VALUE  List
+//	POST   /containers                   Create, with a CreateRequest
+//	GET    /containers/REF               Get
+//	DELETE /containers/REF?force=true    Destroy
+//	GET    /containers/REF/inspect       Inspect
+//	GET    /containers/REF/stats         Stats
+//	GET    /containers/REF/logs          Logs, as plain text
+//	POST   /containers/REF/start         Start
+//	POST   /containers/REF/stop          Stop, with an optional grace_period
+//	POST   /containers/REF/restart       Restart, likewise
+//	POST   /containers/REF/wait          Wait, with an optional timeout
+//	PUT    /images/UPLOAD_ID             UploadImage, with the image as the body
+//
+// Clients authenticate with a user's token in an "Authorization: Bearer"
+// header, or with a client certificate on listeners which require one.
+type gateway struct {
+	handler     *rpcServer
+	clientCerts bool
+}
+
+// serveGateway begins serving the gateway in the background, with the handler
+// for the listener it is served on.
+func (s *Server) serveGateway(handler rpcServer, l *listener) {
+	handler.privileged = l.privileged
+	handler.local = l.local
+	handler.endpoint = l.endpoint
+	g := &gateway{handler: &handler, clientCerts: l.clientCerts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", g.info)
+	mux.HandleFunc("/containers", g.containers)
+	mux.HandleFunc("/containers/", g.container)
+	mux.HandleFunc("/images/", g.image)
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			s.log.Errorf("Gateway on %s stopped: %v", l.endpoint, err)
+		}
+	}()
+	s.log.Debugf("Serving the gateway on %s", l.endpoint)
+}
+
+// call passes the request for the method through the interceptors to handle,
+// which calls the handler it is given. The handler knows the user the
+// client's certificate names, and the context carries the token the client
+// sent and is cancelled if the client goes away.
+func (g *gateway) call(w http.ResponseWriter, req *http.Request, method string, handle func(context.Context, *rpcServer) error) error {
+	handler := *g.handler
+	if g.clientCerts && handler.auth != nil && req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		handler.certUser = handler.auth.userForName(req.TLS.PeerCertificates[0].Subject.CommonName)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed := cn.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		ctx = pb.NewTokenContext(ctx, strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	return pb.Intercept(ctx, method, handler.interceptors(), func(ctx context.Context) error {
+		return handle(ctx, &handler)
+	})
+}
+
+// unary calls the method, and responds with the JSON of the result it returns
+// and the status, or with the error it fails with.
+func (g *gateway) unary(w http.ResponseWriter, req *http.Request, method string, status int, handle func(context.Context, *rpcServer) (interface{}, error)) {
+	var out interface{}
+	err := g.call(w, req, method, func(ctx context.Context, s *rpcServer) error {
+		var err error
+		out, err = handle(ctx, s)
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, out)
+}
+
+func (g *gateway) info(w http.ResponseWriter, req *http.Request) {
+	if !allowMethods(w, req, "GET") {
+		return
+	}
+	g.unary(w, req, "Info", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+		return s.Info(ctx, &pb.None{})
+	})
+}
+
+// containers lists the containers, or creates one.
+func (g *gateway) containers(w http.ResponseWriter, req *http.Request) {
+	if !allowMethods(w, req, "GET", "POST") {
+		return
+	}
+	if req.Method == "GET" {
+		in := &pb.ListRequest{Filters: req.URL.Query()["filter"]}
+		g.unary(w, req, "List", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			resp, err := s.List(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+			if resp.Containers == nil {
+				resp.Containers = []*pb.Container{}
+			}
+			return resp.Containers, nil
+		})
+		return
+	}
+
+	in := &pb.CreateRequest{}
+	if err := readJSON(req, in); err != nil {
+		writeError(w, err)
+		return
+	}
+	g.unary(w, req, "Create", http.StatusCreated, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+		return s.Create(ctx, in)
+	})
+}
+
+// container serves the routes for a single container, given by its UUID, its
+// name, or a prefix of its UUID.
+func (g *gateway) container(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/containers/"), "/")
+	ref := parts[0]
+	if ref == "" || len(parts) > 2 {
+		http.NotFound(w, req)
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	query := req.URL.Query()
+
+	switch action {
+	case "":
+		if !allowMethods(w, req, "GET", "DELETE") {
+			return
+		}
+		if req.Method == "GET" {
+			g.unary(w, req, "Get", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+				return s.Get(ctx, &pb.ContainerRequest{Uuid: ref})
+			})
+			return
+		}
+		in := &pb.DestroyRequest{Uuid: ref, Force: query.Get("force") == "true"}
+		g.unary(w, req, "Destroy", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			return s.Destroy(ctx, in)
+		})
+
+	case "inspect", "stats":
+		if !allowMethods(w, req, "GET") {
+			return
+		}
+		in := &pb.ContainerRequest{Uuid: ref}
+		if action == "inspect" {
+			g.unary(w, req, "Inspect", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+				return s.Inspect(ctx, in)
+			})
+			return
+		}
+		g.unary(w, req, "Stats", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			return s.Stats(ctx, in)
+		})
+
+	case "start":
+		if !allowMethods(w, req, "POST") {
+			return
+		}
+		g.unary(w, req, "Start", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			return s.Start(ctx, &pb.ContainerRequest{Uuid: ref})
+		})
+
+	case "stop", "restart":
+		if !allowMethods(w, req, "POST") {
+			return
+		}
+		in := &pb.StopRequest{Uuid: ref}
+		if v := query.Get("grace_period"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, grpc.Errorf(codes.InvalidArgument, "invalid grace_period %q", v))
+				return
+			}
+			in.GracePeriod = int32(n)
+		}
+		if action == "stop" {
+			g.unary(w, req, "Stop", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+				return s.Stop(ctx, in)
+			})
+			return
+		}
+		g.unary(w, req, "Restart", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			return s.Restart(ctx, in)
+		})
+
+	case "wait":
+		if !allowMethods(w, req, "POST") {
+			return
+		}
+		in := &pb.WaitRequest{Uuid: ref}
+		if v := query.Get("timeout"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, grpc.Errorf(codes.InvalidArgument, "invalid timeout %q", v))
+				return
+			}
+			in.Timeout = int32(n)
+		}
+		g.unary(w, req, "Wait", http.StatusOK, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+			return s.Wait(ctx, in)
+		})
+
+	case "logs":
+		if !allowMethods(w, req, "GET") {
+			return
+		}
+		g.logs(w, req, ref)
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// logs streams the output of the container's apps as plain text. The query
+// may set follow to true, tail to the number of lines to start with, and
+// since to a unix timestamp, as in a LogsRequest.
+func (g *gateway) logs(w http.ResponseWriter, req *http.Request, ref string) {
+	query := req.URL.Query()
+	in := &pb.LogsRequest{Uuid: ref, Follow: query.Get("follow") == "true"}
+	if v := query.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, grpc.Errorf(codes.InvalidArgument, "invalid tail %q", v))
+			return
+		}
+		in.Tail = int32(n)
+	}
+	if v := query.Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, grpc.Errorf(codes.InvalidArgument, "invalid since %q", v))
+			return
+		}
+		in.Since = n
+	}
+
+	stream := &gatewayLogsStream{w: w}
+	err := g.call(w, req, "Logs", func(ctx context.Context, s *rpcServer) error {
+		stream.ctx = ctx
+		return s.Logs(in, stream)
+	})
+	if stream.started {
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	stream.start()
+}
+
+// image uploads the body of the request as the image for the container
+// created with the upload ID, and responds with the container. If the upload
+// had been interrupted, the body is skipped over up to where it resumes.
+func (g *gateway) image(w http.ResponseWriter, req *http.Request) {
+	if !allowMethods(w, req, "PUT") {
+		return
+	}
+	id := strings.TrimPrefix(req.URL.Path, "/images/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	stream := &gatewayUploadStream{id: id, body: req.Body, buf: make([]byte, 64*1024)}
+	g.unary(w, req, "UploadImage", http.StatusCreated, func(ctx context.Context, s *rpcServer) (interface{}, error) {
+		stream.ctx = ctx
+		if err := s.UploadImage(stream); err != nil {
+			return nil, err
+		}
+		if stream.container == nil {
+			return nil, fmt.Errorf("upload ended before the container was created")
+		}
+		return stream.container, nil
+	})
+}
+
+// gatewayStream stands in for a gRPC stream, providing only its context, as
+// that is all of it the handlers use besides sending and receiving.
+type gatewayStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *gatewayStream) Context() context.Context {
+	return s.ctx
+}
+
+// gatewayLogsStream writes the output sent to it to the response, flushing it
+// after each write so followed logs arrive as they're written.
+type gatewayLogsStream struct {
+	gatewayStream
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *gatewayLogsStream) start() {
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *gatewayLogsStream) Send(resp *pb.LogsResponse) error {
+	if !s.started {
+		s.start()
+	}
+	for _, b := range [][]byte{resp.Stdout, resp.Stderr} {
+		if _, err := s.w.Write(b); err != nil {
+			return err
+		}
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// gatewayUploadStream receives an image upload from a request body. The first
+// chunk received opens the upload, and once the server acknowledges it with
+// how much it already has, the body is read from there.
+type gatewayUploadStream struct {
+	gatewayStream
+	id        string
+	body      io.Reader
+	buf       []byte
+	offset    int64
+	opened    bool
+	acked     bool
+	container *pb.Container
+}
+
+func (s *gatewayUploadStream) Recv() (*pb.ByteChunk, error) {
+	if !s.opened {
+		s.opened = true
+		return &pb.ByteChunk{StreamId: s.id}, nil
+	}
+	for {
+		n, err := s.body.Read(s.buf)
+		if n > 0 {
+			chunk := &pb.ByteChunk{StreamId: s.id, Bytes: s.buf[:n], Offset: s.offset}
+			s.offset += int64(n)
+			return chunk, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *gatewayUploadStream) Send(ack *pb.UploadAck) error {
+	if ack.Container != nil {
+		s.container = ack.Container
+	}
+	if !s.acked {
+		s.acked = true
+		if ack.Offset > 0 {
+			if _, err := io.CopyN(ioutil.Discard, s.body, ack.Offset); err != nil {
+				return fmt.Errorf("failed to resume the upload at %d bytes: %v", ack.Offset, err)
+			}
+			s.offset = ack.Offset
+		}
+	}
+	return nil
+}
+
+// allowMethods returns whether the request's method is one of those given,
+// and otherwise responds that it isn't allowed.
+func allowMethods(w http.ResponseWriter, req *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if req.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	return false
+}
+
+// readJSON decodes the request's body into v.
+func readJSON(req *http.Request, v interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		return grpc.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// httpStatuses maps the codes requests fail with to the HTTP status they are
+// given. Errors returned by the handlers without a code are nearly always
+// about the request, such as a container which doesn't exist or isn't in the
+// right state, so they're treated as bad requests.
+var httpStatuses = map[codes.Code]int{
+	codes.Unknown:            http.StatusBadRequest,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// writeError responds with the error's message and the status for its code.
+func writeError(w http.ResponseWriter, err error) {
+	status, ok := httpStatuses[grpc.Code(err)]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	if err == errNotPrivileged || err == errNotLocal {
+		status = http.StatusForbidden
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeJSON(w, status, map[string]string{"error": errorDesc(err)})
+}
+
+// errorDesc returns the description of an error created with grpc.Errorf,
+// which is otherwise only available within its message, or the message of any
+// other error.
+func errorDesc(err error) string {
+	msg := err.Error()
+	if grpc.Code(err) == codes.Unknown {
+		return msg
+	}
+	if i := strings.Index(msg, " desc = "); i >= 0 {
+		if desc, uerr := strconv.Unquote(msg[i+len(" desc = "):]); uerr == nil {
+			return desc
+		}
+	}
+	return msg
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// RateLimitOptions limits how often each client may make requests. A client
+// may make Burst requests at once, and then Rate requests a second. Clients
+// are told apart by the user they authenticate as, and otherwise share a limit
+// with the other clients of the same listener. Requests over local unix
+// sockets aren't limited.
+type RateLimitOptions struct {
+	Rate  float64
+	Burst int
+}
+
+// interceptors returns the chain each request is passed through before it
+// reaches the handler, ending with the additional interceptors the server was
+// configured with.
+func (s *rpcServer) interceptors() []pb.Interceptor {
+	chain := []pb.Interceptor{
+		s.identify,
+		s.logRequest,
+		s.translateErrors,
+		s.recoverPanic,
+		s.limitRate,
+		s.authorize,
+	}
+	return append(chain, s.extraInterceptors...)
+}
+
+// logRequest is the interceptor which logs each request once it has been
+// handled, with how long it took and the code it failed with, if any. Streams
+// are logged once they end.
+func (s *rpcServer) logRequest(ctx context.Context, method string, handle func(context.Context) error) error {
+	start := time.Now()
+	err := handle(ctx)
+
+	log := s.log.Clone()
+	log.SetField("method", method)
+	log.SetField("duration", time.Since(start).String())
+	log.SetField("code", grpc.Code(err).String())
+	if c := callerFromContext(ctx); c.user != nil {
+		log.SetField("user", c.user.Name)
+	}
+	if err != nil {
+		log.Infof("%s request failed: %v", method, err)
+	} else {
+		log.Debugf("%s request succeeded", method)
+	}
+	return err
+}
+
+// translateErrors is the interceptor which turns the manager's errors that
+// clients are to tell apart into the errors of the API.
+func (s *rpcServer) translateErrors(ctx context.Context, method string, handle func(context.Context) error) error {
+	err := handle(ctx)
+	if e, ok := err.(*container.InsufficientResourcesError); ok {
+		return (&pb.InsufficientResourcesError{
+			Resource:  e.Resource,
+			Requested: e.Requested,
+			Available: e.Available,
+		}).GRPCError()
+	}
+	return err
+}
+
+// recoverPanic is the interceptor which turns a panic while handling the
+// request into an internal error, rather than letting it take down the init
+// process. Panics in goroutines the handler starts aren't recovered.
+func (s *rpcServer) recoverPanic(ctx context.Context, method string, handle func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Errorf("Recovered from a panic handling a %s request: %v\n%s", method, r, debug.Stack())
+			err = grpc.Errorf(codes.Internal, "internal error handling the %s request", method)
+		}
+	}()
+	return handle(ctx)
+}
+
+// limitRate is the interceptor which refuses requests from clients which have
+// exceeded their rate limit.
+func (s *rpcServer) limitRate(ctx context.Context, method string, handle func(context.Context) error) error {
+	if s.limiter == nil || s.local {
+		return handle(ctx)
+	}
+
+	client := "listener " + s.endpoint
+	if c := callerFromContext(ctx); c.user != nil {
+		client = "user " + c.user.Name
+	}
+	if !s.limiter.allow(client) {
+		return grpc.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return handle(ctx)
+}
+
+// rateLimiter keeps a token bucket for each client, which is refilled at the
+// rate up to the burst, and which each request takes a token from.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(opts *RateLimitOptions) *rateLimiter {
+	burst := float64(opts.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    opts.Rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the client's bucket, returning false if it is
+// empty.
+func (l *rateLimiter) allow(client string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens += now.Sub(b.updated).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
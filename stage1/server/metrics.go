@@ -0,0 +1,209 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/apcera/logray"
+)
+
+// metricsHandler serves the metrics for the host and its containers in the
+// Prometheus text exposition format.
+type metricsHandler struct {
+	manager *container.Manager
+	log     *logray.Logger
+}
+
+// metricFamily is a set of samples of a metric which share a name, type and
+// description.
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+// add appends a sample to the family. The labels are given as pairs of names
+// and values.
+func (f *metricFamily) add(value float64, labels ...string) {
+	f.samples = append(f.samples, metricSample{labels: labels, value: value})
+}
+
+// write renders the family in the text exposition format. Families without
+// any samples are omitted.
+func (f *metricFamily) write(buf *bytes.Buffer) {
+	if len(f.samples) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.kind)
+	for _, s := range f.samples {
+		buf.WriteString(f.name)
+		if len(s.labels) > 0 {
+			buf.WriteByte('{')
+			for i := 0; i+1 < len(s.labels); i += 2 {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, "%s=\"%s\"", s.labels[i], escapeLabelValue(s.labels[i+1]))
+			}
+			buf.WriteByte('}')
+		}
+		fmt.Fprintf(buf, " %s\n", strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes the characters which are not allowed unescaped
+// within a label value.
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, f := range h.collect() {
+		f.write(&buf)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// collect gathers the current metrics for the host and each of its containers.
+func (h *metricsHandler) collect() []*metricFamily {
+	uptime := &metricFamily{name: "kurma_uptime_seconds", kind: "gauge",
+		help: "Time since the host booted."}
+	pressure := &metricFamily{name: "kurma_pressure_stalled_seconds_total", kind: "counter",
+		help: "Time some or all of the host's tasks were stalled waiting on the resource."}
+	containers := &metricFamily{name: "kurma_containers", kind: "gauge",
+		help: "Number of containers on the host by state."}
+	images := &metricFamily{name: "kurma_image_bytes", kind: "gauge",
+		help: "Total size of the images the host's containers were created from."}
+	restarts := &metricFamily{name: "kurma_container_restarts_total", kind: "counter",
+		help: "Number of times the container's apps have been restarted."}
+	cpu := &metricFamily{name: "kurma_container_cpu_seconds_total", kind: "counter",
+		help: "CPU time used by the container."}
+	memory := &metricFamily{name: "kurma_container_memory_usage_bytes", kind: "gauge",
+		help: "Memory used by the container."}
+	memoryLimit := &metricFamily{name: "kurma_container_memory_limit_bytes", kind: "gauge",
+		help: "Memory limit of the container."}
+	oomKills := &metricFamily{name: "kurma_container_oom_kills_total", kind: "counter",
+		help: "Number of the container's processes killed for exceeding its memory limit."}
+	blkioRead := &metricFamily{name: "kurma_container_blkio_read_bytes_total", kind: "counter",
+		help: "Bytes read from block devices by the container."}
+	blkioWrite := &metricFamily{name: "kurma_container_blkio_write_bytes_total", kind: "counter",
+		help: "Bytes written to block devices by the container."}
+	rxBytes := &metricFamily{name: "kurma_container_network_receive_bytes_total", kind: "counter",
+		help: "Bytes received on the container's network interface."}
+	rxPackets := &metricFamily{name: "kurma_container_network_receive_packets_total", kind: "counter",
+		help: "Packets received on the container's network interface."}
+	txBytes := &metricFamily{name: "kurma_container_network_transmit_bytes_total", kind: "counter",
+		help: "Bytes transmitted on the container's network interface."}
+	txPackets := &metricFamily{name: "kurma_container_network_transmit_packets_total", kind: "counter",
+		help: "Packets transmitted on the container's network interface."}
+	containerPressure := &metricFamily{name: "kurma_container_pressure_stalled_seconds_total", kind: "counter",
+		help: "Time some or all of the container's tasks were stalled waiting on the resource."}
+
+	if seconds, err := hostUptime(); err != nil {
+		h.log.Warnf("Failed to read the host uptime: %v", err)
+	} else {
+		uptime.add(seconds)
+	}
+	for _, resource := range cgroups.PressureResources {
+		if p, err := cgroups.HostPressure(resource); err == nil {
+			addPressure(pressure, p, "resource", resource)
+		}
+	}
+
+	states := make(map[string]int)
+	var imageSize int64
+	for _, c := range h.manager.Containers() {
+		uuid := c.UUID()
+		states[pbState(c.State()).String()]++
+		imageSize += c.ImageSize()
+
+		_, n := c.RestartPolicy()
+		restarts.add(float64(n), "uuid", uuid)
+
+		stats, err := c.Stats()
+		if err != nil {
+			h.log.Debugf("Failed to get the stats for %s: %v", uuid, err)
+			continue
+		}
+		cpu.add(float64(stats.CPUUsage)/1e9, "uuid", uuid)
+		memory.add(float64(stats.MemoryUsage), "uuid", uuid)
+		memoryLimit.add(float64(stats.MemoryLimit), "uuid", uuid)
+		oomKills.add(float64(stats.OOMKills), "uuid", uuid)
+		blkioRead.add(float64(stats.BlkioRead), "uuid", uuid)
+		blkioWrite.add(float64(stats.BlkioWrite), "uuid", uuid)
+		for _, n := range stats.Networks {
+			rxBytes.add(float64(n.RxBytes), "uuid", uuid, "interface", n.Interface)
+			rxPackets.add(float64(n.RxPackets), "uuid", uuid, "interface", n.Interface)
+			txBytes.add(float64(n.TxBytes), "uuid", uuid, "interface", n.Interface)
+			txPackets.add(float64(n.TxPackets), "uuid", uuid, "interface", n.Interface)
+		}
+		for _, resource := range cgroups.PressureResources {
+			if p := stats.Pressure[resource]; p != nil {
+				addPressure(containerPressure, p, "uuid", uuid, "resource", resource)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(states))
+	for state := range states {
+		names = append(names, state)
+	}
+	sort.Strings(names)
+	for _, state := range names {
+		containers.add(float64(states[state]), "state", state)
+	}
+	images.add(float64(imageSize))
+
+	return []*metricFamily{
+		uptime, pressure, containers, images, restarts, cpu, memory, memoryLimit,
+		oomKills, blkioRead, blkioWrite, rxBytes, rxPackets, txBytes, txPackets,
+		containerPressure,
+	}
+}
+
+// addPressure adds the total time some and all of the tasks were stalled to the
+// family, in seconds, with the labels along with the kind of stall.
+func addPressure(f *metricFamily, p *cgroups.Pressure, labels ...string) {
+	n := len(labels)
+	f.add(float64(p.Some.Total)/1e6, append(labels[:n:n], "kind", "some")...)
+	f.add(float64(p.Full.Total)/1e6, append(labels[:n:n], "kind", "full")...)
+}
+
+// hostUptime returns the number of seconds since the host booted, which is the
+// first value in /proc/uptime.
+func hostUptime() (float64, error) {
+	b, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("/proc/uptime is empty")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
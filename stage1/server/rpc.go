@@ -4,10 +4,18 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 
+	"github.com/apcera/kurma/stage1/build"
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/gc"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/stage1/jobs"
+	"github.com/apcera/kurma/stage1/netpolicy"
+	"github.com/apcera/kurma/util/remote"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
 	"github.com/appc/spec/schema"
@@ -18,25 +26,119 @@ type rpcServer struct {
 	log     *logray.Logger
 	manager *container.Manager
 
-	pendingUploads map[string]*pendingContainer
+	// privileged is whether the connection the request was received over
+	// permits privileged operations.
+	privileged bool
+
+	// local is whether the request was received over a local unix socket, which
+	// is required to create containers with all capabilities.
+	local bool
+
+	// auth, if set, lists the users requests must be authenticated as, and
+	// certUser is the one the connection's client certificate names.
+	auth     *AuthOptions
+	certUser *User
+
+	// endpoint is the listener the request was received over, limiter limits
+	// the rate of requests from each client, and extraInterceptors are the
+	// interceptors the server was configured with in addition to its own.
+	endpoint          string
+	limiter           *rateLimiter
+	extraInterceptors []pb.Interceptor
+
+	shutdownHandler func(reboot bool)
+	images          *image.Store
+	collector       *gc.Collector
+	hostStatus      func() []*pb.HostUnit
+	hostInfo        func() *pb.HostInfoResponse
+	hostStorage     func() *pb.HostStorageResponse
+	hostEncryption  func() *pb.HostEncryptionResponse
+	crashes         func() (*pb.ListCrashesResponse, error)
+	crash           func(name string) (*pb.Crash, error)
+	reloadConfig    func() (*pb.ReloadConfigResponse, error)
+	nodes           func() []*pb.Node
+	console         func() (*pb.ConsoleResponse, error)
+	updateStatus    func() (*pb.UpdateStatusResponse, error)
+	update          func(url string) (*pb.UpdateStatusResponse, error)
+	attest          func(nonce []byte) (*pb.AttestResponse, error)
+
+	// upgradeDirectory is where the binaries the host is upgraded to are
+	// written.
+	upgradeDirectory string
+
+	uploads  *pendingUploads
+	jobs     *jobs.Scheduler
+	policies *netpolicy.Engine
+	builder  *build.Builder
 }
 
-type pendingContainer struct {
-	name          string
-	imageManifest *schema.ImageManifest
+// errNotPrivileged is returned for privileged operations requested over a
+// listener which does not permit them.
+var errNotPrivileged = errors.New("privileged operations require an authenticated client certificate")
+
+// errNotLocal is returned for requests to create privileged containers which
+// weren't received over a local unix socket.
+var errNotLocal = errors.New("privileged containers may only be created over a local unix socket")
+
+// authorizeCreate returns an error if the options request privileges to
+// create the container which the client's connection doesn't permit.
+func (s *rpcServer) authorizeCreate(opts *container.CreateOptions) error {
+	if opts.Privileged && !s.local {
+		return errNotLocal
+	}
+	if opts.SecurityLabel != "" && !s.privileged {
+		return errNotPrivileged
+	}
+	if opts.Priority == container.PriorityCritical && !s.privileged {
+		return errNotPrivileged
+	}
+	return nil
 }
 
 func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.CreateResponse, error) {
 	s.log.Debug("Received Create request.")
 
+	opts, err := createOptions(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeCreate(opts); err != nil {
+		return nil, err
+	}
+
+	// the name is checked again once the container is created, but checking it
+	// now saves retrieving or uploading the image only to find it's taken
+	if opts.Name != "" {
+		for _, c := range s.manager.Containers() {
+			if c.Name() == opts.Name {
+				return nil, fmt.Errorf("the container name %q is already in use by container %s", opts.Name, c.UUID())
+			}
+		}
+	}
+
+	// if an image uri was given, retrieve the image and create the container
+	// directly rather than waiting for an upload
+	if in.ImageUri != "" {
+		return s.createFromURI(in, opts)
+	}
+
 	// unmarshal the image manifest, ensure its valid
 	var imageManifest *schema.ImageManifest
 	if err := json.Unmarshal(in.Manifest, &imageManifest); err != nil {
 		return nil, fmt.Errorf("invalid image manifest: %v", err)
 	}
 
+	// host privileged containers may only be created by privileged clients
+	if !s.privileged && requiresPrivilege(imageManifest, opts.Pod) {
+		return nil, errNotPrivileged
+	}
+
 	// validate the manifest with the manager
-	if err := s.manager.Validate(imageManifest); err != nil {
+	if opts.Pod != nil {
+		if err := s.manager.ValidatePod(imageManifest, opts.Pod); err != nil {
+			return nil, fmt.Errorf("pod manifest is not valid: %v", err)
+		}
+	} else if err := s.manager.Validate(imageManifest); err != nil {
 		return nil, fmt.Errorf("image manifest is not valid: %v", err)
 	}
 
@@ -44,36 +146,147 @@ func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.Creat
 	pc := &pendingContainer{
 		name:          in.Name,
 		imageManifest: imageManifest,
+		opts:          opts,
 	}
 	resp := &pb.CreateResponse{
 		ImageUploadId: uuid.Variant4().String(),
 	}
-	s.pendingUploads[resp.ImageUploadId] = pc
+	if err := s.uploads.add(resp.ImageUploadId, pc); err != nil {
+		return nil, err
+	}
 
 	s.log.Debug("Finished Create request.")
 	return resp, nil
 }
 
+// createFromURI retrieves the image from the request's image uri and creates
+// the container from it.
+func (s *rpcServer) createFromURI(in *pb.CreateRequest, opts *container.CreateOptions) (*pb.CreateResponse, error) {
+	container, err := s.retrieveAndCreate(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	pbc, err := pbContainer(container)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Debug("Finished Create request.")
+	return &pb.CreateResponse{Container: pbc}, nil
+}
+
+// retrieveAndCreate retrieves the image from the request's image uri and
+// returns the container created from it.
+func (s *rpcServer) retrieveAndCreate(in *pb.CreateRequest, opts *container.CreateOptions) (*container.Container, error) {
+	retrieve := remote.RetrieveImage
+	if s.images != nil {
+		retrieve = s.images.Retrieve
+	}
+	f, err := retrieve(in.ImageUri, in.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image: %v", err)
+	}
+	if in.ImageHash != "" {
+		if err := remote.VerifyHash(f, in.ImageHash); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	s.manager.Publish(&container.Event{Type: container.EventImagePulled, Image: in.ImageUri})
+
+	manifest, err := remote.FindManifest(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to find manifest in image: %v", err)
+	}
+	if !s.privileged && requiresPrivilege(manifest, opts.Pod) {
+		f.Close()
+		return nil, errNotPrivileged
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	container, err := s.manager.Create(in.Name, manifest, f, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return container, nil
+}
+
+// UploadImage receives the image for a container requested by Create. Each
+// chunk is acknowledged with the number of bytes received, and the container is
+// created and returned once the client closes the stream. If the stream ends
+// early, the upload is kept so it can be resumed by another stream.
 func (s *rpcServer) UploadImage(stream pb.Kurma_UploadImageServer) error {
 	s.log.Debug("Received upload request")
-	packet, err := stream.Recv()
+	chunk, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	id := chunk.StreamId
+
+	pc, err := s.uploads.acquire(id)
 	if err != nil {
 		return err
 	}
+	for {
+		if len(chunk.Bytes) > 0 {
+			if chunk.Offset != pc.received {
+				s.uploads.release(pc)
+				return fmt.Errorf("chunk offset %d does not follow the %d bytes received", chunk.Offset, pc.received)
+			}
+			if err := pc.write(chunk.Bytes); err != nil {
+				s.uploads.release(pc)
+				return err
+			}
+		}
+		if err := stream.Send(&pb.UploadAck{Offset: pc.received}); err != nil {
+			s.uploads.release(pc)
+			return err
+		}
 
-	pc := s.pendingUploads[packet.StreamId]
-	delete(s.pendingUploads, packet.StreamId)
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			s.log.Debugf("Upload %s interrupted after %d bytes: %v", id, pc.received, err)
+			s.uploads.release(pc)
+			return err
+		}
+	}
 
-	r := pb.NewByteStreamReader(stream, packet)
+	f, err := s.uploads.complete(id)
+	if err != nil {
+		return err
+	}
+	// OCI image layouts are converted to the ACI the container is created from
+	image, err := remote.OpenImage(f)
+	if err != nil {
+		return err
+	}
 	s.log.Debug("Initializing container")
-	_, err = s.manager.Create(pc.name, pc.imageManifest, r)
-	return err
+	c, err := s.manager.Create(pc.name, pc.imageManifest, image, pc.opts)
+	if err != nil {
+		image.Close()
+		return err
+	}
+	pbc, err := pbContainer(c)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&pb.UploadAck{Offset: pc.received, Container: pbc})
 }
 
-func (s *rpcServer) Destroy(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
-	container := s.manager.Container(in.Uuid)
-	if container == nil {
-		return nil, fmt.Errorf("specified container not found")
+func (s *rpcServer) Destroy(ctx context.Context, in *pb.DestroyRequest) (*pb.None, error) {
+	container, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if !in.Force && appsRunning(container.State()) {
+		return nil, fmt.Errorf("the container's apps are running, stop it first or force it to be destroyed")
 	}
 	if err := container.Stop(); err != nil {
 		return nil, err
@@ -82,7 +295,11 @@ func (s *rpcServer) Destroy(ctx context.Context, in *pb.ContainerRequest) (*pb.N
 	return &pb.None{}, nil
 }
 
-func (s *rpcServer) List(ctx context.Context, in *pb.None) (*pb.ListResponse, error) {
+func (s *rpcServer) List(ctx context.Context, in *pb.ListRequest) (*pb.ListResponse, error) {
+	filter, err := parseFilters(in.Filters)
+	if err != nil {
+		return nil, err
+	}
 	resp := &pb.ListResponse{
 		Containers: make([]*pb.Container, 0),
 	}
@@ -92,16 +309,18 @@ func (s *rpcServer) List(ctx context.Context, in *pb.None) (*pb.ListResponse, er
 		if err != nil {
 			return nil, err
 		}
-		resp.Containers = append(resp.Containers, c)
+		if filter.matches(c) {
+			resp.Containers = append(resp.Containers, c)
+		}
 	}
 
 	return resp, nil
 }
 
 func (s *rpcServer) Get(ctx context.Context, in *pb.ContainerRequest) (*pb.Container, error) {
-	container := s.manager.Container(in.Uuid)
-	if container == nil {
-		return nil, fmt.Errorf("specified container not found")
+	container, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
 	}
 	return pbContainer(container)
 }
@@ -0,0 +1,185 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"golang.org/x/net/context"
+)
+
+// appliedLabel marks the containers which are managed by Apply. Its value is
+// the hash of the request the container was created from, so that changes to
+// it can be detected.
+const appliedLabel = "kurma.applied"
+
+// appliedContainer is a container in an apply request, once it is validated.
+type appliedContainer struct {
+	req  *pb.CreateRequest
+	opts *container.CreateOptions
+	hash string
+}
+
+// Apply reconciles the containers on the host with those in the request. Each
+// is created if it is missing, and replaced if the request it was created from
+// has changed, and its apps are started if they aren't running. The managed
+// containers which are no longer in the request are removed. Containers which
+// weren't created by Apply are left alone, and one in the request with the same
+// name as one of them is an error. The request's containers are created in
+// order, and a failure to create one doesn't stop the others.
+func (s *rpcServer) Apply(ctx context.Context, in *pb.ApplyRequest) (*pb.ApplyResponse, error) {
+	s.log.Debugf("Received apply request for %d containers", len(in.Containers))
+
+	// validate the whole request before changing anything
+	applied := make([]*appliedContainer, len(in.Containers))
+	names := make(map[string]bool, len(in.Containers))
+	for i, req := range in.Containers {
+		ac, err := s.validateApplied(req)
+		if err != nil {
+			return nil, err
+		}
+		if names[req.ContainerName] {
+			return nil, fmt.Errorf("the container %q is given more than once", req.ContainerName)
+		}
+		names[req.ContainerName] = true
+		applied[i] = ac
+	}
+
+	existing := make(map[string]*container.Container)
+	var removed []*container.Container
+	for _, c := range s.manager.Containers() {
+		if c.Name() == "" {
+			continue
+		}
+		existing[c.Name()] = c
+		if _, ok := c.Labels()[appliedLabel]; ok && !names[c.Name()] {
+			removed = append(removed, c)
+		}
+	}
+	sort.Sort(containersByName(removed))
+
+	resp := &pb.ApplyResponse{}
+	for _, c := range removed {
+		change := &pb.ApplyChange{Name: c.Name(), Action: pb.ApplyChange_REMOVED, Uuid: c.UUID()}
+		if !in.DryRun {
+			if err := c.Stop(); err != nil {
+				change.Error = err.Error()
+			}
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+
+	for _, ac := range applied {
+		change := s.applyContainer(ac, existing[ac.req.ContainerName], in.DryRun)
+		if change.Error != "" {
+			s.log.Warnf("Failed to apply container %s: %s", change.Name, change.Error)
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+	return resp, nil
+}
+
+// validateApplied checks the container in an apply request, and labels it with
+// the hash of the request.
+func (s *rpcServer) validateApplied(req *pb.CreateRequest) (*appliedContainer, error) {
+	if req.ContainerName == "" {
+		return nil, fmt.Errorf("each applied container must be given a name")
+	}
+	if req.ImageUri == "" {
+		return nil, fmt.Errorf("the image of container %q must be given by its uri", req.ContainerName)
+	}
+	if req.Stdin || req.Attach {
+		return nil, fmt.Errorf("the container %q can't be attached to", req.ContainerName)
+	}
+	if _, ok := req.Labels[appliedLabel]; ok {
+		return nil, fmt.Errorf("the container %q may not set the %s label", req.ContainerName, appliedLabel)
+	}
+
+	opts, err := createOptions(req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container %q: %v", req.ContainerName, err)
+	}
+	if err := s.authorizeCreate(opts); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	hash := "sha256-" + hex.EncodeToString(sum[:])
+
+	labels := make(map[string]string, len(opts.Labels)+1)
+	for key, value := range opts.Labels {
+		labels[key] = value
+	}
+	labels[appliedLabel] = hash
+	opts.Labels = labels
+	return &appliedContainer{req: req, opts: opts, hash: hash}, nil
+}
+
+// applyContainer brings the existing container with the applied container's
+// name, if there is one, in line with it, and returns what was changed.
+func (s *rpcServer) applyContainer(ac *appliedContainer, c *container.Container, dryRun bool) *pb.ApplyChange {
+	change := &pb.ApplyChange{Name: ac.req.ContainerName}
+	if c != nil {
+		change.Uuid = c.UUID()
+		hash, ok := c.Labels()[appliedLabel]
+		switch {
+		case !ok:
+			change.Error = fmt.Sprintf("container %s has the name, but isn't managed by apply", c.UUID())
+			return change
+		case hash == ac.hash && !appsStopped(c.State()):
+			change.Action = pb.ApplyChange_UNCHANGED
+			return change
+		case hash == ac.hash:
+			change.Action = pb.ApplyChange_STARTED
+			if !dryRun {
+				if err := c.StartApps(); err != nil {
+					change.Error = err.Error()
+				}
+			}
+			return change
+		}
+		change.Action = pb.ApplyChange_REPLACED
+		if dryRun {
+			return change
+		}
+		if err := c.Stop(); err != nil {
+			change.Error = fmt.Sprintf("failed to remove the previous container: %v", err)
+			return change
+		}
+	} else {
+		change.Action = pb.ApplyChange_CREATED
+		if dryRun {
+			return change
+		}
+	}
+
+	created, err := s.retrieveAndCreate(ac.req, ac.opts)
+	if err != nil {
+		change.Error = err.Error()
+		return change
+	}
+	change.Uuid = created.UUID()
+	return change
+}
+
+// appsStopped returns whether a container in the state has apps which exited or
+// were stopped, so may be started again.
+func appsStopped(state container.ContainerState) bool {
+	return state == container.STOPPED || state == container.EXITED
+}
+
+type containersByName []*container.Container
+
+func (c containersByName) Len() int           { return len(c) }
+func (c containersByName) Less(a, b int) bool { return c[a].Name() < c[b].Name() }
+func (c containersByName) Swap(a, b int)      { c[a], c[b] = c[b], c[a] }
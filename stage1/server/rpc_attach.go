@@ -0,0 +1,99 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Attach(stream pb.Kurma_AttachServer) error {
+	s.log.Debug("Received attach request")
+	if !s.privileged {
+		return errNotPrivileged
+	}
+
+	// Receive the first request, which contains the container UUID.
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	container, err := s.manager.Lookup(req.Uuid)
+	if err != nil {
+		return err
+	}
+
+	// Attach to the console if the container has one, and otherwise to its
+	// stdio.
+	var stdin io.WriteCloser
+	console, detach, err := container.AttachConsole(&attachStreamWriter{stream: stream})
+	if err == nil {
+		stdin = console
+	} else {
+		stdin, detach, err = container.AttachStdio(
+			&attachStreamWriter{stream: stream}, &attachStreamWriter{stream: stream, stderr: true})
+		if err != nil {
+			return err
+		}
+	}
+	defer detach()
+
+	// Copy any input and resize requests from the client until it detaches.
+	detached := make(chan bool)
+	go func() {
+		defer close(detached)
+		for {
+			if console != nil && req.Rows > 0 && req.Cols > 0 {
+				if err := setWindowSize(console, req.Rows, req.Cols); err != nil {
+					s.log.Warnf("Failed to resize the console: %v", err)
+				}
+			}
+			if len(req.Stdin) > 0 && stdin != nil {
+				if _, err := stdin.Write(req.Stdin); err != nil {
+					s.log.Warnf("Failed to write to the stdin of %s: %v", container.UUID(), err)
+				}
+			}
+			if req.StdinClosed && stdin != nil && console == nil {
+				stdin.Close()
+				stdin = nil
+			}
+
+			var err error
+			if req, err = stream.Recv(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The stream ends once the container exits and its output has been sent.
+	select {
+	case <-detached:
+		s.log.Debugf("Detached from %s", container.UUID())
+	case <-container.Exited():
+		container.DrainOutput()
+		s.log.Debugf("Container %s exited while attached", container.UUID())
+	}
+	return nil
+}
+
+// attachStreamWriter is an io.Writer which sends the output written to it as
+// AttachResponse messages on the stream.
+type attachStreamWriter struct {
+	stream pb.Kurma_AttachServer
+	stderr bool
+}
+
+func (w *attachStreamWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	resp := &pb.AttachResponse{Output: b}
+	if w.stderr {
+		resp = &pb.AttachResponse{Stderr: b}
+	}
+	if err := w.stream.Send(resp); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
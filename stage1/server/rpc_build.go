@@ -0,0 +1,116 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/apcera/kurma/stage1/build"
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Build(stream pb.Kurma_BuildServer) error {
+	// the steps' commands run as root within the containers, and the image is
+	// trusted once it is added to the store
+	if !s.privileged {
+		return errNotPrivileged
+	}
+
+	s.log.Debug("Received build request")
+	if s.builder == nil {
+		return fmt.Errorf("the host has no image store")
+	}
+
+	// Receive the first request, which contains the spec.
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.Spec == nil {
+		return fmt.Errorf("the build's spec must be sent first")
+	}
+	spec := buildSpec(req.Spec)
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	// The build context is buffered to a file, since each step copying from it
+	// reads it again.
+	f, err := ioutil.TempFile(s.uploads.directory, uploadPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	for {
+		if _, err := f.Write(req.Context); err != nil {
+			return err
+		}
+		if req, err = stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	mutex := new(sync.Mutex)
+	result, err := s.builder.Build(stream.Context(), spec, f,
+		&buildStreamWriter{stream: stream, mutex: mutex},
+		&buildStreamWriter{stream: stream, mutex: mutex, stderr: true})
+	if err != nil {
+		return err
+	}
+	return stream.Send(&pb.BuildResponse{
+		Image: &pb.CommitResponse{
+			Uri:       result.URI,
+			ImageHash: result.Hash,
+			Size:      result.Size,
+		},
+	})
+}
+
+// buildSpec converts the build's spec from the request.
+func buildSpec(in *pb.BuildSpec) *build.Spec {
+	spec := &build.Spec{
+		Base:     in.Base,
+		Insecure: in.Insecure,
+		Name:     in.Name,
+		Labels:   in.Labels,
+	}
+	for _, step := range in.Steps {
+		spec.Steps = append(spec.Steps, build.Step{
+			Run:         step.Run,
+			Source:      step.Source,
+			Destination: step.Destination,
+		})
+	}
+	return spec
+}
+
+// buildStreamWriter is an io.Writer which sends the output written to it as
+// BuildResponse messages on the stream. The build's stdout and stderr writers
+// share the mutex, since the apps' output streams are copied concurrently.
+type buildStreamWriter struct {
+	stream pb.Kurma_BuildServer
+	mutex  *sync.Mutex
+	stderr bool
+}
+
+func (w *buildStreamWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	resp := &pb.BuildResponse{Output: b}
+	if w.stderr {
+		resp = &pb.BuildResponse{Stderr: b}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.stream.Send(resp); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Checkpoint(ctx context.Context, in *pb.CheckpointRequest) (*pb.None, error) {
+	// CRIU runs with full privileges against the container's processes
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received checkpoint request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Checkpoint(in.LeaveRunning); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) Restore(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received restore request for %s", in.Uuid)
+
+	// containers the manager knows of may be referred to by name or prefix,
+	// while those only found on disk need their full UUID
+	uuid := in.Uuid
+	if c, err := s.manager.Lookup(uuid); err == nil {
+		uuid = c.UUID()
+	}
+	if _, err := s.manager.Restore(uuid); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
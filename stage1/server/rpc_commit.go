@@ -0,0 +1,53 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/apcera/kurma/stage1/build"
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Commit(ctx context.Context, in *pb.CommitRequest) (*pb.CommitResponse, error) {
+	// the image includes files only readable by root within the container
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received commit request for %s", in.Uuid)
+	if s.images == nil {
+		return nil, fmt.Errorf("the host has no image store")
+	}
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := build.Manifest(c.ImageManifest(), in.Name, in.Labels)
+	if err != nil {
+		return nil, err
+	}
+	uri := build.ImageURI(manifest)
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(c.Commit(w, manifest))
+	}()
+	defer r.Close()
+	h := sha512.New()
+	img, err := s.images.Add(uri, io.TeeReader(r, h))
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the container: %v", err)
+	}
+
+	s.log.Infof("Committed container %s as %s", c.UUID(), uri)
+	return &pb.CommitResponse{
+		Uri:       uri,
+		ImageHash: "sha512-" + hex.EncodeToString(h.Sum(nil)),
+		Size:      img.Size,
+	}, nil
+}
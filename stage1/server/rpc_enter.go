@@ -3,7 +3,6 @@
 package server
 
 import (
-	"fmt"
 	"io"
 
 	pb "github.com/apcera/kurma/stage1/client"
@@ -12,6 +11,9 @@ import (
 
 func (s *rpcServer) Enter(stream pb.Kurma_EnterServer) error {
 	s.log.Debug("Received enter request")
+	if !s.privileged {
+		return errNotPrivileged
+	}
 
 	// Receive the first chunk so we can get the stream ID, which will be the UUID
 	// of the container. The byte portion will be blank, the client always sends a
@@ -22,9 +24,9 @@ func (s *rpcServer) Enter(stream pb.Kurma_EnterServer) error {
 	}
 
 	// get the container
-	container := s.manager.Container(chunk.StreamId)
-	if container == nil {
-		return fmt.Errorf("specified container not found")
+	container, err := s.manager.Lookup(chunk.StreamId)
+	if err != nil {
+		return err
 	}
 
 	// configure the io.Reader/Writer for the transport
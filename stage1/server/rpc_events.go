@@ -0,0 +1,80 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+)
+
+func (s *rpcServer) Events(in *pb.EventsRequest, stream pb.Kurma_EventsServer) error {
+	s.log.Debugf("Received events request for %q", in.Uuid)
+
+	events, cancel := s.manager.Subscribe()
+	defer cancel()
+
+	// a container which doesn't exist yet can only be followed by its UUID
+	uuid := in.Uuid
+	if uuid != "" {
+		if c, err := s.manager.Lookup(uuid); err == nil {
+			uuid = c.UUID()
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-events:
+			if uuid != "" && e.UUID != uuid {
+				continue
+			}
+			if err := stream.Send(pbEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pbEvent converts an event into its protobuf form.
+func pbEvent(e *container.Event) *pb.Event {
+	return &pb.Event{
+		Type:     pbEventType(e.Type),
+		Time:     e.Time.UnixNano(),
+		Uuid:     e.UUID,
+		App:      e.App,
+		Image:    e.Image,
+		ExitCode: int32(e.ExitCode),
+		Reason:   e.Reason,
+	}
+}
+
+// pbEventType maps an event type to its protobuf equivalent.
+func pbEventType(t container.EventType) pb.Event_Type {
+	switch t {
+	case container.EventStarted:
+		return pb.Event_STARTED
+	case container.EventExited:
+		return pb.Event_EXITED
+	case container.EventStopped:
+		return pb.Event_STOPPED
+	case container.EventOOMKilled:
+		return pb.Event_OOM_KILLED
+	case container.EventDestroyed:
+		return pb.Event_DESTROYED
+	case container.EventImagePulled:
+		return pb.Event_IMAGE_PULLED
+	case container.EventDiskQuotaExceeded:
+		return pb.Event_DISK_QUOTA_EXCEEDED
+	case container.EventUnhealthy:
+		return pb.Event_UNHEALTHY
+	case container.EventHealthy:
+		return pb.Event_HEALTHY
+	case container.EventThrottled:
+		return pb.Event_THROTTLED
+	case container.EventEvicted:
+		return pb.Event_EVICTED
+	default:
+		return pb.Event_CREATED
+	}
+}
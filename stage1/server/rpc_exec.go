@@ -0,0 +1,165 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/kr/pty"
+)
+
+func (s *rpcServer) Exec(stream pb.Kurma_ExecServer) error {
+	s.log.Debug("Received exec request")
+	if !s.privileged {
+		return errNotPrivileged
+	}
+
+	// Receive the first request, which contains the container UUID and the
+	// command to be executed.
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	container, err := s.manager.Lookup(req.Uuid)
+	if err != nil {
+		return err
+	}
+	if len(req.Command) == 0 {
+		return fmt.Errorf("a command to execute must be specified")
+	}
+
+	// Setup the file descriptors to pass to the process. With a tty, the slave
+	// side of a pty is used for all three. Otherwise, pipes are created for each
+	// of them so stdout and stderr can be kept separate.
+	var stdin, stdout, stderr *os.File
+	var inWriter io.WriteCloser
+	var outReader, errReader io.Reader
+	var master *os.File
+	var cleanup []*os.File
+	defer func() {
+		for _, f := range cleanup {
+			f.Close()
+		}
+	}()
+
+	if req.Tty {
+		m, slave, err := pty.Open()
+		if err != nil {
+			return err
+		}
+		master = m
+		cleanup = append(cleanup, master, slave)
+		if err := setWindowSize(master, req.Rows, req.Cols); err != nil {
+			s.log.Warnf("Failed to set the terminal size: %v", err)
+		}
+		stdin, stdout, stderr = slave, slave, slave
+		inWriter, outReader = master, master
+	} else {
+		inr, inw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		outr, outw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		errr, errw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		cleanup = append(cleanup, inr, inw, outr, outw, errr, errw)
+		stdin, stdout, stderr = inr, outw, errw
+		inWriter, outReader, errReader = inw, outr, errr
+	}
+
+	// Copy any stdin data and resize requests from the client. With a tty, the
+	// master is left open since it is also used to read the output.
+	go func() {
+		if master == nil {
+			defer inWriter.Close()
+		}
+		if len(req.Stdin) > 0 {
+			if _, err := inWriter.Write(req.Stdin); err != nil {
+				return
+			}
+		}
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if master != nil && r.Rows > 0 && r.Cols > 0 {
+				if err := setWindowSize(master, r.Rows, r.Cols); err != nil {
+					s.log.Warnf("Failed to resize the terminal: %v", err)
+				}
+			}
+			if len(r.Stdin) > 0 {
+				if _, err := inWriter.Write(r.Stdin); err != nil {
+					return
+				}
+			}
+			if r.StdinClosed {
+				return
+			}
+		}
+	}()
+
+	// Copy the output from the process back to the client. Sends on the stream
+	// are not safe to do concurrently, so they're serialized with a mutex.
+	var sendLock sync.Mutex
+	var wg sync.WaitGroup
+	copyOutput := func(r io.Reader, isStderr bool) {
+		defer wg.Done()
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				resp := &pb.ExecResponse{}
+				b := make([]byte, n)
+				copy(b, buf[:n])
+				if isStderr {
+					resp.Stderr = b
+				} else {
+					resp.Stdout = b
+				}
+				sendLock.Lock()
+				serr := stream.Send(resp)
+				sendLock.Unlock()
+				if serr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(1)
+	go copyOutput(outReader, false)
+	if errReader != nil {
+		wg.Add(1)
+		go copyOutput(errReader, true)
+	}
+
+	exitCode, err := container.Exec(req.Command, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+
+	// Close our copies of the process's side of the descriptors so the output
+	// readers will receive an EOF once everything has been flushed.
+	stdin.Close()
+	stdout.Close()
+	stderr.Close()
+	wg.Wait()
+
+	sendLock.Lock()
+	defer sendLock.Unlock()
+	s.log.Debugf("Exec request finished")
+	return stream.Send(&pb.ExecResponse{Exited: true, ExitCode: int32(exitCode)})
+}
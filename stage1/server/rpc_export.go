@@ -0,0 +1,53 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+// exportChunkSize is the size of the chunks exports are streamed in.
+const exportChunkSize = 64 * 1024
+
+func (s *rpcServer) ExportImage(in *pb.ImageRequest, stream pb.Kurma_ExportImageServer) error {
+	s.log.Debugf("Received image export request for %s", in.Uri)
+	if s.images == nil {
+		return fmt.Errorf("the host has no image store")
+	}
+
+	f, err := s.images.Open(in.Uri)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriterSize(pb.NewByteStreamWriter(stream, in.Uri), exportChunkSize)
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *rpcServer) Export(in *pb.ContainerRequest, stream pb.Kurma_ExportServer) error {
+	// the archive includes files only readable by root within the container
+	if !s.privileged {
+		return errNotPrivileged
+	}
+
+	s.log.Debugf("Received export request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return err
+	}
+
+	// the archive is written in small pieces, so they're buffered into chunks
+	w := bufio.NewWriterSize(pb.NewByteStreamWriter(stream, c.UUID()), exportChunkSize)
+	if err := c.Export(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) GarbageCollect(ctx context.Context, in *pb.None) (*pb.GarbageCollectResponse, error) {
+	s.log.Debug("Received garbage collection request")
+	if s.collector == nil {
+		return nil, fmt.Errorf("garbage collection is not configured")
+	}
+
+	result, err := s.collector.Collect()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GarbageCollectResponse{
+		Containers: result.Containers,
+		Images:     result.Images,
+		ImageBytes: result.ImageBytes,
+		Layers:     result.Layers,
+	}, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/upgrade"
+	"golang.org/x/net/context"
+)
+
+// upgradeDelay is how long the upgraded binary is exec'd after the response to
+// the upgrade request, giving it time to reach the client.
+const upgradeDelay = 500 * time.Millisecond
+
+// maxAttestNonce is the largest nonce a quote is requested with, which is the
+// size of the TPM's largest digest.
+const maxAttestNonce = 64
+
+func (s *rpcServer) HostStatus(ctx context.Context, in *pb.None) (*pb.HostStatusResponse, error) {
+	s.log.Debug("Received host status request")
+	if s.hostStatus == nil {
+		return nil, fmt.Errorf("the host's status is not available")
+	}
+	return &pb.HostStatusResponse{Units: s.hostStatus()}, nil
+}
+
+func (s *rpcServer) HostInfo(ctx context.Context, in *pb.None) (*pb.HostInfoResponse, error) {
+	s.log.Debug("Received host info request")
+	if s.hostInfo == nil {
+		return nil, fmt.Errorf("the host's info is not available")
+	}
+	return s.hostInfo(), nil
+}
+
+func (s *rpcServer) HostStorage(ctx context.Context, in *pb.None) (*pb.HostStorageResponse, error) {
+	s.log.Debug("Received host storage request")
+	if s.hostStorage == nil {
+		return nil, fmt.Errorf("the host's storage is not available")
+	}
+	return s.hostStorage(), nil
+}
+
+func (s *rpcServer) HostEncryption(ctx context.Context, in *pb.None) (*pb.HostEncryptionResponse, error) {
+	s.log.Debug("Received host encryption request")
+	if s.hostEncryption == nil {
+		return nil, fmt.Errorf("the host's encryption status is not available")
+	}
+	return s.hostEncryption(), nil
+}
+
+func (s *rpcServer) ListCrashes(ctx context.Context, in *pb.None) (*pb.ListCrashesResponse, error) {
+	s.log.Debug("Received list crashes request")
+	if s.crashes == nil {
+		return nil, fmt.Errorf("the host's crashes are not available")
+	}
+	return s.crashes()
+}
+
+func (s *rpcServer) GetCrash(ctx context.Context, in *pb.CrashRequest) (*pb.Crash, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received get crash request for %s", in.Name)
+	if s.crash == nil {
+		return nil, fmt.Errorf("the host's crashes are not available")
+	}
+	return s.crash(in.Name)
+}
+
+func (s *rpcServer) ReloadConfig(ctx context.Context, in *pb.None) (*pb.ReloadConfigResponse, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debug("Received reload config request")
+	if s.reloadConfig == nil {
+		return nil, fmt.Errorf("reloading the configuration is not supported")
+	}
+	return s.reloadConfig()
+}
+
+func (s *rpcServer) StartConsole(ctx context.Context, in *pb.None) (*pb.ConsoleResponse, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debug("Received start console request")
+	if s.console == nil {
+		return nil, fmt.Errorf("the host has no console")
+	}
+	return s.console()
+}
+
+func (s *rpcServer) UpdateStatus(ctx context.Context, in *pb.None) (*pb.UpdateStatusResponse, error) {
+	s.log.Debug("Received update status request")
+	if s.updateStatus == nil {
+		return nil, fmt.Errorf("updating the system image is not supported")
+	}
+	return s.updateStatus()
+}
+
+func (s *rpcServer) UpdateHost(ctx context.Context, in *pb.UpdateHostRequest) (*pb.UpdateStatusResponse, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received update host request for %s, reboot: %t", in.Url, in.Reboot)
+	if s.update == nil {
+		return nil, fmt.Errorf("updating the system image is not supported")
+	}
+	if in.Url == "" {
+		return nil, fmt.Errorf("the url of the system image is required")
+	}
+	if in.Reboot && s.shutdownHandler == nil {
+		return nil, fmt.Errorf("rebooting the host is not supported")
+	}
+
+	resp, err := s.update(in.Url)
+	if err != nil {
+		return nil, err
+	}
+	if in.Reboot {
+		// as with Shutdown, the handler doesn't return
+		go s.shutdownHandler(true)
+	}
+	return resp, nil
+}
+
+func (s *rpcServer) AttestHost(ctx context.Context, in *pb.AttestRequest) (*pb.AttestResponse, error) {
+	s.log.Debug("Received attest host request")
+	if s.attest == nil {
+		return nil, fmt.Errorf("attestation is not supported")
+	}
+	if len(in.Nonce) > maxAttestNonce {
+		return nil, fmt.Errorf("the nonce may be at most %d bytes", maxAttestNonce)
+	}
+	return s.attest(in.Nonce)
+}
+
+func (s *rpcServer) UpgradeHost(ctx context.Context, in *pb.UpgradeHostRequest) (*pb.UpgradeHostResponse, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received upgrade host request for %s", in.Url)
+	if s.upgradeDirectory == "" {
+		return nil, fmt.Errorf("upgrading the host is not supported")
+	}
+	if in.Url == "" {
+		return nil, fmt.Errorf("the url of the update bundle is required")
+	}
+
+	path, digest, err := upgrade.Fetch(in.Url, s.upgradeDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the update bundle: %v", err)
+	}
+	if err := s.manager.Handoff(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	go s.upgrade(path)
+	return &pb.UpgradeHostResponse{Digest: digest}, nil
+}
+
+// upgrade execs the binary in place of the process once the response has been
+// sent. If it can't be, the manager resumes managing the containers.
+func (s *rpcServer) upgrade(path string) {
+	time.Sleep(upgradeDelay)
+	s.log.Infof("Upgrading to %s", path)
+	err := upgrade.Exec(path)
+	s.log.Errorf("Failed to upgrade to %s: %v", path, err)
+	s.manager.CancelHandoff()
+	os.Remove(path)
+}
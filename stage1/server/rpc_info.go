@@ -0,0 +1,119 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/cgroups"
+	"github.com/apcera/kurma/util/sysctl"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Info(ctx context.Context, in *pb.None) (*pb.InfoResponse, error) {
+	s.log.Debug("Received info request")
+	info := &pb.InfoResponse{
+		Version:           pb.Version,
+		MinApiVersion:     pb.MinAPIVersion,
+		MaxApiVersion:     pb.APIVersion,
+		StorageDriver:     s.manager.StorageDriver(),
+		CgroupRoot:        cgroups.CgroupsDirPrefix(),
+		CgroupParent:      s.manager.CgroupName(),
+		CgroupControllers: cgroups.Controllers(),
+		Cpus:              int32(runtime.NumCPU()),
+		Containers:        make(map[string]int32),
+		Runtimes:          s.manager.Runtimes(),
+	}
+	info.Hostname, _ = os.Hostname()
+	if allocatable, ok := s.manager.Allocatable(); ok {
+		reserved := s.manager.Committed()
+		info.CpuAllocatable, info.MemoryAllocatable = allocatable.CPU, allocatable.Memory
+		info.CpuReserved, info.MemoryReserved = reserved.CPU, reserved.Memory
+	}
+	info.CgroupVersion = 1
+	if cgroups.Unified() {
+		info.CgroupVersion = 2
+	}
+
+	if release, err := sysctl.KernelRelease(); err == nil {
+		info.Kernel = release
+	}
+	if seconds, err := hostUptime(); err == nil {
+		info.Uptime = int64(seconds)
+	}
+	if total, available, err := hostMemory(); err != nil {
+		s.log.Warnf("Failed to read the host's memory: %v", err)
+	} else {
+		info.MemoryTotal, info.MemoryAvailable = total, available
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.manager.Directory(), &st); err != nil {
+		s.log.Warnf("Failed to stat the container directory: %v", err)
+	} else {
+		info.DiskTotal = int64(st.Blocks) * int64(st.Bsize)
+		info.DiskAvailable = int64(st.Bavail) * int64(st.Bsize)
+	}
+	for _, resource := range cgroups.PressureResources {
+		p, err := cgroups.HostPressure(resource)
+		if err == cgroups.ErrNoPressure {
+			continue
+		} else if err != nil {
+			s.log.Warnf("Failed to read the host's %s pressure: %v", resource, err)
+			continue
+		}
+		info.Pressure = append(info.Pressure, pressureToPb(resource, p))
+	}
+
+	for _, c := range s.manager.Containers() {
+		info.Containers[pbState(c.State()).String()]++
+	}
+	return info, nil
+}
+
+// hostMemory returns the host's total memory and the memory available to start
+// new processes without swapping, in bytes, from /proc/meminfo.
+func hostMemory() (total, available int64, err error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	found := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var dst *int64
+		switch fields[0] {
+		case "MemTotal:":
+			dst = &total
+		case "MemAvailable:":
+			dst = &available
+		default:
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q", fields[0], fields[1])
+		}
+		*dst = kb * 1024
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if found < 2 {
+		return 0, 0, fmt.Errorf("/proc/meminfo lacks MemTotal or MemAvailable")
+	}
+	return total, available, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Inspect(ctx context.Context, in *pb.ContainerRequest) (*pb.InspectResponse, error) {
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	pbc, err := pbContainer(c)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.InspectResponse{
+		Container:      pbc,
+		Cgroups:        c.CgroupPaths(),
+		Pid:            int32(c.Pid()),
+		ExitCode:       int32(c.ExitCode()),
+		OomKills:       c.OOMKills(),
+		Privileged:     c.Privileged(),
+		SecurityLabel:  c.SecurityLabel(),
+		ReadOnlyRootfs: c.ReadOnlyRootFS(),
+		Isolation:      string(c.Isolation()),
+		Runtime:        c.Runtime(),
+		Priority:       string(c.Priority()),
+	}
+	l := c.LogConfig()
+	resp.LogConfig = &pb.LogConfig{
+		Driver:   l.Driver,
+		MaxSize:  l.MaxSize,
+		MaxFiles: int32(l.MaxFiles),
+		Options:  l.Options,
+	}
+	resp.CpuPlacement = &pb.CPUPlacement{}
+	if p := c.CPUPlacement(); p != nil {
+		resp.CpuPlacement.ExclusiveCpus = int32(p.ExclusiveCPUs)
+		if p.NUMANode != nil {
+			resp.CpuPlacement.PinNumaNode = true
+			resp.CpuPlacement.NumaNode = int32(*p.NUMANode)
+		}
+	}
+	resp.CpuPlacement.Cpus, resp.CpuPlacement.Mems = c.CPUs()
+	if t := c.StartTime(); !t.IsZero() {
+		resp.StartTime = t.Unix()
+	}
+
+	addresses, err := c.NetworkAddresses()
+	if err != nil {
+		s.log.Warnf("Failed to get the network addresses of container %s: %v", in.Uuid, err)
+	}
+	resp.Addresses = addresses
+
+	for _, app := range c.Apps() {
+		resp.Capabilities = append(resp.Capabilities, &pb.AppCapabilities{
+			App:          app.Name,
+			Capabilities: app.Capabilities,
+		})
+	}
+
+	for _, m := range c.Mounts() {
+		resp.Mounts = append(resp.Mounts, &pb.Mount{
+			Source:      m.Source,
+			Destination: m.Destination,
+			ReadOnly:    m.ReadOnly,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/jobs"
+	"golang.org/x/net/context"
+)
+
+// errNoJobs is returned for job requests when the server wasn't configured
+// with a file to keep the jobs in.
+var errNoJobs = errors.New("scheduled jobs are not enabled on this host")
+
+// jobLabel is the label each container created for a job is given, with the
+// job's name as its value.
+const jobLabel = "kurma.job"
+
+// jobSpec is what is saved for a job to create its containers. Along with the
+// create request, it records whether the client which added the job could
+// create privileged containers, as the image is only checked for requiring it
+// once it is retrieved for a run.
+type jobSpec struct {
+	Create     *pb.CreateRequest `json:"create"`
+	Privileged bool              `json:"privileged,omitempty"`
+	Local      bool              `json:"local,omitempty"`
+}
+
+func (s *rpcServer) AddJob(ctx context.Context, in *pb.AddJobRequest) (*pb.None, error) {
+	s.log.Debugf("Received job add request for %s", in.Name)
+	if s.jobs == nil {
+		return nil, errNoJobs
+	}
+	if in.Create == nil || in.Create.ImageUri == "" {
+		return nil, fmt.Errorf("the image a job runs must be given by its uri")
+	}
+	if in.Create.Stdin || in.Create.Attach {
+		return nil, fmt.Errorf("a job's containers can't be attached to")
+	}
+
+	opts, err := createOptions(in.Create)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeCreate(opts); err != nil {
+		return nil, err
+	}
+
+	spec, err := json.Marshal(&jobSpec{
+		Create:     in.Create,
+		Privileged: s.privileged,
+		Local:      s.local,
+	})
+	if err != nil {
+		return nil, err
+	}
+	job := &jobs.Job{
+		Name:     in.Name,
+		Schedule: in.Schedule,
+		Spec:     spec,
+	}
+	if err := s.jobs.Add(job); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) ListJobs(ctx context.Context, in *pb.None) (*pb.ListJobsResponse, error) {
+	if s.jobs == nil {
+		return nil, errNoJobs
+	}
+	resp := &pb.ListJobsResponse{}
+	for _, j := range s.jobs.Jobs() {
+		pbj, err := pbJob(j)
+		if err != nil {
+			return nil, err
+		}
+		resp.Jobs = append(resp.Jobs, pbj)
+	}
+	return resp, nil
+}
+
+func (s *rpcServer) RemoveJob(ctx context.Context, in *pb.JobRequest) (*pb.None, error) {
+	s.log.Debugf("Received job remove request for %s", in.Name)
+	if s.jobs == nil {
+		return nil, errNoJobs
+	}
+	if err := s.jobs.Remove(in.Name); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+// createJobContainer creates the container for a run of the job, with the
+// privileges of the client which added it.
+func (s *rpcServer) createJobContainer(job *jobs.Job) (*container.Container, error) {
+	var spec jobSpec
+	if err := json.Unmarshal(job.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("invalid job spec: %v", err)
+	}
+	if spec.Create == nil {
+		return nil, fmt.Errorf("invalid job spec: no create request")
+	}
+	opts, err := createOptions(spec.Create)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(opts.Labels)+1)
+	for key, value := range opts.Labels {
+		labels[key] = value
+	}
+	labels[jobLabel] = job.Name
+	opts.Labels = labels
+
+	handler := *s
+	handler.privileged = spec.Privileged
+	handler.local = spec.Local
+	return handler.retrieveAndCreate(spec.Create, opts)
+}
+
+// pbJob converts the job to its protobuf representation.
+func pbJob(j *jobs.Job) (*pb.Job, error) {
+	var spec jobSpec
+	if err := json.Unmarshal(j.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec for job %s: %v", j.Name, err)
+	}
+	pbj := &pb.Job{
+		Name:     j.Name,
+		Schedule: j.Schedule,
+		Create:   spec.Create,
+		Created:  j.Created.Unix(),
+		Running:  j.Running,
+	}
+	if !j.Next.IsZero() {
+		pbj.NextRun = j.Next.Unix()
+	}
+	for _, r := range j.Runs {
+		pbj.Runs = append(pbj.Runs, &pb.JobRun{
+			Start:     r.Start.Unix(),
+			End:       r.End.Unix(),
+			Container: r.Container,
+			ExitCode:  int32(r.ExitCode),
+			OomKilled: r.OOMKilled,
+			Error:     r.Error,
+		})
+	}
+	return pbj, nil
+}
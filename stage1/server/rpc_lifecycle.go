@@ -0,0 +1,120 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Stop(ctx context.Context, in *pb.StopRequest) (*pb.None, error) {
+	s.log.Debugf("Received stop request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.StopApps(gracePeriod(c, in)); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) Start(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
+	s.log.Debugf("Received start request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.StartApps(); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) Restart(ctx context.Context, in *pb.StopRequest) (*pb.None, error) {
+	s.log.Debugf("Received restart request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.RestartApps(gracePeriod(c, in)); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+// gracePeriod returns how long the apps should be given to exit for the stop
+// request, falling back to the container's own grace period.
+func gracePeriod(c *container.Container, in *pb.StopRequest) time.Duration {
+	if in.GracePeriod <= 0 {
+		return c.StopGracePeriod()
+	}
+	return time.Duration(in.GracePeriod) * time.Second
+}
+
+func (s *rpcServer) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.WaitResponse, error) {
+	s.log.Debugf("Received wait request for %s", in.Uuid)
+	if in.Timeout < 0 {
+		return nil, fmt.Errorf("the timeout must not be negative")
+	}
+
+	// Subscribe first, so that the container's apps being stopped, or it being
+	// destroyed, isn't missed.
+	events, cancel := s.manager.Subscribe()
+	defer cancel()
+
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	var timeout <-chan time.Time
+	if in.Timeout > 0 {
+		timer := time.NewTimer(time.Duration(in.Timeout) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	exited := c.Exited()
+	if c.State() == container.STOPPED {
+		return waitResponse(c), nil
+	}
+	for {
+		select {
+		case <-exited:
+			if err := c.StartError(); err != nil {
+				return nil, fmt.Errorf("the container failed to start: %v", err)
+			}
+			return waitResponse(c), nil
+		case e, ok := <-events:
+			if !ok {
+				return nil, fmt.Errorf("stopped receiving the container's events")
+			}
+			if e.UUID != c.UUID() {
+				continue
+			}
+			switch e.Type {
+			case container.EventStopped, container.EventEvicted:
+				return waitResponse(c), nil
+			case container.EventDestroyed:
+				return nil, fmt.Errorf("the container was destroyed before it exited")
+			}
+		case <-timeout:
+			return &pb.WaitResponse{State: pbState(c.State()), TimedOut: true}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// waitResponse returns the state the container's wait ended in.
+func waitResponse(c *container.Container) *pb.WaitResponse {
+	return &pb.WaitResponse{
+		ExitCode:  int32(c.ExitCode()),
+		OomKilled: c.OOMKilled(),
+		State:     pbState(c.State()),
+	}
+}
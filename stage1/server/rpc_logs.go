@@ -0,0 +1,105 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"io"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+)
+
+// logPollInterval is how often the container's output is checked for new
+// entries when following the logs.
+var logPollInterval = 250 * time.Millisecond
+
+func (s *rpcServer) Logs(in *pb.LogsRequest, stream pb.Kurma_LogsServer) error {
+	s.log.Debugf("Received logs request for %s", in.Uuid)
+
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if in.Since > 0 {
+		since = time.Unix(in.Since, 0)
+	}
+
+	logs, err := c.OpenLogs()
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	// Skip the entries from before the time given, and only keep the last of
+	// the rest if a tail is given.
+	var entries []*container.LogEntry
+	for {
+		entry, err := logs.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+		if in.Tail > 0 && len(entries) > int(in.Tail) {
+			entries = entries[1:]
+		}
+	}
+	for _, entry := range entries {
+		if err := sendLogEntry(stream, entry); err != nil {
+			return err
+		}
+	}
+	if !in.Follow {
+		return nil
+	}
+
+	// Poll for new entries until the client goes away or the container is no
+	// longer running.
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			entry, err := logs.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			if err := sendLogEntry(stream, entry); err != nil {
+				return err
+			}
+		}
+
+		if s.manager.Container(c.UUID()) == nil {
+			return nil
+		}
+		switch c.State() {
+		case container.EXITED, container.STOPPING, container.STOPPED, container.CHECKPOINTED:
+			return nil
+		}
+	}
+}
+
+// sendLogEntry sends the entry as a LogsResponse message on the stream.
+func sendLogEntry(stream pb.Kurma_LogsServer, entry *container.LogEntry) error {
+	resp := &pb.LogsResponse{}
+	if entry.Stream == "stderr" {
+		resp.Stderr = []byte(entry.Log)
+	} else {
+		resp.Stdout = []byte(entry.Log)
+	}
+	return stream.Send(resp)
+}
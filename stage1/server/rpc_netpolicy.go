@@ -0,0 +1,96 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"errors"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/netpolicy"
+	"golang.org/x/net/context"
+)
+
+// errNoNetworkPolicies is returned for network policy requests when the host
+// has no container network for them to apply to.
+var errNoNetworkPolicies = errors.New("network policies require the container network, which is not enabled on this host")
+
+func (s *rpcServer) SetNetworkPolicy(ctx context.Context, in *pb.NetworkPolicy) (*pb.None, error) {
+	s.log.Debugf("Received network policy set request for %s", in.Name)
+	if s.policies == nil {
+		return nil, errNoNetworkPolicies
+	}
+	if err := s.policies.Set(policyFromPB(in)); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) ListNetworkPolicies(ctx context.Context, in *pb.None) (*pb.ListNetworkPoliciesResponse, error) {
+	if s.policies == nil {
+		return nil, errNoNetworkPolicies
+	}
+	resp := &pb.ListNetworkPoliciesResponse{}
+	for _, p := range s.policies.Policies() {
+		resp.Policies = append(resp.Policies, pbPolicy(p))
+	}
+	return resp, nil
+}
+
+func (s *rpcServer) RemoveNetworkPolicy(ctx context.Context, in *pb.NetworkPolicyRequest) (*pb.None, error) {
+	s.log.Debugf("Received network policy remove request for %s", in.Name)
+	if s.policies == nil {
+		return nil, errNoNetworkPolicies
+	}
+	if err := s.policies.Remove(in.Name); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+// policyFromPB converts a network policy from its protobuf equivalent.
+func policyFromPB(in *pb.NetworkPolicy) *netpolicy.Policy {
+	p := &netpolicy.Policy{
+		Name:     in.Name,
+		Selector: in.Selector,
+		Host:     in.Host,
+	}
+	if in.DefaultDeny {
+		p.Default = netpolicy.Deny
+	}
+	for _, r := range in.Rules {
+		rule := &netpolicy.Rule{
+			Action:   netpolicy.Allow,
+			From:     r.From,
+			Protocol: r.Protocol,
+			Port:     int(r.Port),
+		}
+		if r.Action == pb.NetworkPolicyRule_DENY {
+			rule.Action = netpolicy.Deny
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	return p
+}
+
+// pbPolicy maps a network policy to its protobuf equivalent.
+func pbPolicy(p *netpolicy.Policy) *pb.NetworkPolicy {
+	pbp := &pb.NetworkPolicy{
+		Name:        p.Name,
+		Selector:    p.Selector,
+		Host:        p.Host,
+		DefaultDeny: p.Default == netpolicy.Deny,
+	}
+	for _, r := range p.Rules {
+		rule := &pb.NetworkPolicyRule{
+			Action:   pb.NetworkPolicyRule_ALLOW,
+			From:     r.From,
+			Protocol: r.Protocol,
+			Port:     int32(r.Port),
+		}
+		if r.Action == netpolicy.Deny {
+			rule.Action = pb.NetworkPolicyRule_DENY
+		}
+		pbp.Rules = append(pbp.Rules, rule)
+	}
+	return pbp
+}
@@ -0,0 +1,21 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"os"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) ListNodes(ctx context.Context, in *pb.None) (*pb.ListNodesResponse, error) {
+	s.log.Debug("Received node list request")
+	if s.nodes != nil {
+		return &pb.ListNodesResponse{Nodes: s.nodes()}, nil
+	}
+
+	// without a cluster, the host is on its own
+	name, _ := os.Hostname()
+	return &pb.ListNodesResponse{Nodes: []*pb.Node{{Name: name, Self: true}}}, nil
+}
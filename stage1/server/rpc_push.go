@@ -0,0 +1,53 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/remote"
+)
+
+// pushProgressInterval is how often the progress of a push is sent.
+const pushProgressInterval = 250 * time.Millisecond
+
+func (s *rpcServer) PushImage(in *pb.PushRequest, stream pb.Kurma_PushImageServer) error {
+	s.log.Debugf("Received image push request for %s to %q", in.Uri, in.Destination)
+	if s.images == nil {
+		return fmt.Errorf("the host has no image store")
+	}
+
+	f, err := s.images.Open(in.Uri)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// progress is reported as the upload is read, so it is limited to avoid a
+	// message for every chunk
+	var last time.Time
+	var sendErr error
+	opts := remote.PushOptions{
+		Username: in.Username,
+		Password: in.Password,
+		Insecure: in.Insecure,
+		Progress: func(sent, total int64) {
+			if sendErr != nil || (sent < total && time.Since(last) < pushProgressInterval) {
+				return
+			}
+			last = time.Now()
+			sendErr = stream.Send(&pb.PushProgress{Sent: sent, Total: total})
+		},
+	}
+	location, err := remote.PushImage(f, in.Destination, opts)
+	if err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	s.log.Infof("Pushed image %s to %s", in.Uri, location)
+	return stream.Send(&pb.PushProgress{Location: location})
+}
@@ -0,0 +1,27 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) UpdateResources(ctx context.Context, in *pb.UpdateResourcesRequest) (*pb.None, error) {
+	s.log.Debugf("Received resource update request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &container.ResourceUpdate{
+		Memory:      in.Memory,
+		CPUShares:   in.CpuShares,
+		BlkioWeight: in.BlkioWeight,
+	}
+	if err := c.UpdateResources(update); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) CreateSecret(ctx context.Context, in *pb.SecretRequest) (*pb.None, error) {
+	s.log.Debugf("Received secret create request for %s", in.Name)
+	if err := s.manager.CreateSecret(in.Name, in.Value); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) ListSecrets(ctx context.Context, in *pb.None) (*pb.ListSecretsResponse, error) {
+	secrets, err := s.manager.Secrets()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListSecretsResponse{Secrets: secrets}, nil
+}
+
+func (s *rpcServer) DeleteSecret(ctx context.Context, in *pb.SecretRequest) (*pb.None, error) {
+	s.log.Debugf("Received secret delete request for %s", in.Name)
+	if err := s.manager.DeleteSecret(in.Name); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
@@ -0,0 +1,26 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Shutdown(ctx context.Context, in *pb.ShutdownRequest) (*pb.None, error) {
+	if !s.privileged {
+		return nil, errNotPrivileged
+	}
+
+	s.log.Debugf("Received shutdown request, reboot: %t", in.Reboot)
+	if s.shutdownHandler == nil {
+		return nil, fmt.Errorf("shutting down the host is not supported")
+	}
+
+	// The handler doesn't return, so run it in the background to allow the
+	// response to be sent before the server is stopped.
+	go s.shutdownHandler(in.Reboot)
+	return &pb.None{}, nil
+}
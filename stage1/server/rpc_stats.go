@@ -0,0 +1,114 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/util/cgroups"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Stats(ctx context.Context, in *pb.ContainerRequest) (*pb.StatsResponse, error) {
+	s.log.Debugf("Received stats request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return statsToPb(c.UUID(), stats), nil
+}
+
+func (s *rpcServer) StreamStats(in *pb.StatsRequest, stream pb.Kurma_StreamStatsServer) error {
+	s.log.Debugf("Received stats stream request for %s", in.Uuid)
+	c, err := s.manager.Lookup(in.Uuid)
+	if err != nil {
+		return err
+	}
+
+	interval := time.Second
+	if in.Interval < 0 {
+		return fmt.Errorf("the interval must not be negative")
+	} else if in.Interval > 0 {
+		interval = time.Duration(in.Interval) * time.Second
+	}
+
+	// Send the stats until the client goes away or the container is destroyed.
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(statsToPb(c.UUID(), stats)); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if s.manager.Container(c.UUID()) == nil {
+			return nil
+		}
+	}
+}
+
+// statsToPb converts the container's stats into their protobuf form.
+func statsToPb(uuid string, stats *container.Stats) *pb.StatsResponse {
+	resp := &pb.StatsResponse{
+		Uuid:         uuid,
+		Time:         stats.Time.UnixNano(),
+		CpuUsage:     stats.CPUUsage,
+		MemoryUsage:  stats.MemoryUsage,
+		MemoryLimit:  stats.MemoryLimit,
+		BlkioRead:    stats.BlkioRead,
+		BlkioWrite:   stats.BlkioWrite,
+		OomKills:     stats.OOMKills,
+		DiskUsage:    stats.DiskUsage,
+		DiskLimit:    stats.DiskLimit,
+		IngressLimit: stats.IngressLimit,
+		EgressLimit:  stats.EgressLimit,
+		Networks:     make([]*pb.NetworkStats, len(stats.Networks)),
+	}
+	for _, resource := range cgroups.PressureResources {
+		if p := stats.Pressure[resource]; p != nil {
+			resp.Pressure = append(resp.Pressure, pressureToPb(resource, p))
+		}
+	}
+	for i, n := range stats.Networks {
+		resp.Networks[i] = &pb.NetworkStats{
+			Interface: n.Interface,
+			RxBytes:   n.RxBytes,
+			RxPackets: n.RxPackets,
+			TxBytes:   n.TxBytes,
+			TxPackets: n.TxPackets,
+		}
+	}
+	return resp
+}
+
+// pressureToPb converts the pressure on the resource into its protobuf form.
+func pressureToPb(resource string, p *cgroups.Pressure) *pb.PressureStats {
+	return &pb.PressureStats{
+		Resource:   resource,
+		SomeAvg10:  p.Some.Avg10,
+		SomeAvg60:  p.Some.Avg60,
+		SomeAvg300: p.Some.Avg300,
+		SomeTotal:  p.Some.Total,
+		FullAvg10:  p.Full.Avg10,
+		FullAvg60:  p.Full.Avg60,
+		FullAvg300: p.Full.Avg300,
+		FullTotal:  p.Full.Total,
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) CreateVolume(ctx context.Context, in *pb.VolumeRequest) (*pb.None, error) {
+	s.log.Debugf("Received volume create request for %s", in.Name)
+	if err := s.manager.CreateVolume(in.Name); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
+
+func (s *rpcServer) ListVolumes(ctx context.Context, in *pb.None) (*pb.ListVolumesResponse, error) {
+	volumes, err := s.manager.Volumes()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListVolumesResponse{Volumes: volumes}, nil
+}
+
+func (s *rpcServer) DeleteVolume(ctx context.Context, in *pb.VolumeRequest) (*pb.None, error) {
+	s.log.Debugf("Received volume delete request for %s", in.Name)
+	if err := s.manager.DeleteVolume(in.Name); err != nil {
+		return nil, err
+	}
+	return &pb.None{}, nil
+}
@@ -3,12 +3,23 @@
 package server
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net"
+	"net/http"
 
+	"github.com/apcera/kurma/stage1/build"
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/cri"
+	"github.com/apcera/kurma/stage1/gc"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/kurma/stage1/jobs"
+	"github.com/apcera/kurma/stage1/netpolicy"
+	"github.com/apcera/kurma/stage1/upgrade"
 	"github.com/apcera/logray"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Options devices the configuration fields that can be passed to New() when
@@ -18,8 +29,146 @@ type Options struct {
 	ContainerDirectory string
 	RequiredNamespaces []string
 	ContainerManager   *container.Manager
+
+	// Listeners are the endpoints the API is served on, such as
+	// unix:///var/run/kurma.sock or tcp://127.0.0.1:12311. If none are given,
+	// DefaultListener is used.
+	Listeners []string
+
+	// TLS configures the API to be served over TLS on any tcp listeners.
+	TLS *TLSOptions
+
+	// Auth, if set, requires clients to authenticate as one of its users, and
+	// limits each to the methods its role permits.
+	Auth *AuthOptions
+
+	// RateLimit, if set, limits how often each client may make requests.
+	RateLimit *RateLimitOptions
+
+	// Interceptors are passed each request after the server's own, which
+	// identify the client, log the request, recover from panics, and enforce
+	// the rate limit and the client's role.
+	Interceptors []pb.Interceptor
+
+	// MetricsListener is the endpoint, such as tcp://0.0.0.0:12312, on which
+	// the host and container metrics are served over HTTP at /metrics for
+	// Prometheus to scrape. Metrics are not served if it is empty.
+	MetricsListener string
+
+	// GatewayListener is the endpoint, such as tcp://0.0.0.0:12313, on which
+	// the API is also served as JSON over HTTP, for clients which can't use
+	// gRPC. It is secured with TLS and authenticated like the API's listeners.
+	// The gateway is not served if it is empty.
+	GatewayListener string
+
+	// CRIListener is the unix socket, such as unix:///var/run/kurma-cri.sock,
+	// on which the Kubernetes Container Runtime Interface is served for a
+	// kubelet to run pods on the host. Kubelets don't authenticate to their
+	// runtime, so it may only be served locally, and it requires the
+	// ImageStore. The interface is not served if it is empty.
+	CRIListener string
+
+	// CRIFile is where the pod sandboxes and containers created through the
+	// Container Runtime Interface are kept.
+	CRIFile string
+
+	// ShutdownHandler, if set, is invoked to power off the host, or reboot it,
+	// when a privileged client requests it. It is expected to stop the
+	// containers and to not return.
+	ShutdownHandler func(reboot bool)
+
+	// ImageStore, if set, caches the images retrieved to create containers.
+	ImageStore *image.Store
+
+	// UploadDirectory is where images uploaded by clients are buffered as they
+	// are received, so that interrupted uploads can be resumed. It defaults to
+	// the system's temporary directory.
+	UploadDirectory string
+
+	// GarbageCollector, if set, is run when a client requests the exited
+	// containers and unused images be cleaned up.
+	GarbageCollector *gc.Collector
+
+	// HostStatusHandler, if set, returns the status of the units the host was
+	// set up with when a client requests it.
+	HostStatusHandler func() []*pb.HostUnit
+
+	// HostInfoHandler, if set, returns the description of the host when a
+	// client requests it.
+	HostInfoHandler func() *pb.HostInfoResponse
+
+	// HostStorageHandler, if set, returns the health of the host's RAID arrays
+	// and volume groups when a client requests it.
+	HostStorageHandler func() *pb.HostStorageResponse
+
+	// HostEncryptionHandler, if set, returns the status of the host's
+	// encrypted devices when a client requests it.
+	HostEncryptionHandler func() *pb.HostEncryptionResponse
+
+	// CrashesHandler, if set, returns the kernel crashes recorded on the host,
+	// without their contents, and CrashHandler returns the named one with
+	// them, when a client requests them.
+	CrashesHandler func() (*pb.ListCrashesResponse, error)
+	CrashHandler   func(name string) (*pb.Crash, error)
+
+	// NodesHandler, if set, returns the hosts in the cluster the host belongs
+	// to, starting with itself, when a client requests them.
+	NodesHandler func() []*pb.Node
+
+	// JobsFile is where the containers scheduled to run periodically are kept,
+	// along with the history of their runs. Jobs are not supported if it is
+	// empty.
+	JobsFile string
+
+	// NetworkPolicies, if set, keeps the policies restricting the traffic on
+	// the container network. Without it, policies are not supported.
+	NetworkPolicies *netpolicy.Engine
+
+	// ReloadConfigHandler, if set, is invoked to reload the host's
+	// configuration when a privileged client requests it. It returns the
+	// changes which were found.
+	ReloadConfigHandler func() (*pb.ReloadConfigResponse, error)
+
+	// ConsoleHandler, if set, starts the host's console container if it isn't
+	// running when a privileged client requests a debug shell, and returns it
+	// along with the shell to run within it.
+	ConsoleHandler func() (*pb.ConsoleResponse, error)
+
+	// UpdateStatusHandler, if set, returns the state of the partitions the
+	// host's system image is booted from, and UpdateHandler writes the system
+	// image at a url to the partition which wasn't booted, to be tried on the
+	// next boot. Updating the system image is not supported without them.
+	UpdateStatusHandler func() (*pb.UpdateStatusResponse, error)
+	UpdateHandler       func(url string) (*pb.UpdateStatusResponse, error)
+
+	// AttestHandler, if set, returns a quote of the host's PCRs from its TPM
+	// with the nonce, along with the log of the measurements kurma extended
+	// into them. Attestation is not supported without it.
+	AttestHandler func(nonce []byte) (*pb.AttestResponse, error)
+
+	// UpgradeDirectory is where the kurma binaries retrieved from update
+	// bundles are written when a privileged client requests the host be
+	// upgraded. The process is replaced with the binary, which inherits the
+	// API's listeners and recovers the containers. Upgrades are not supported
+	// if it is empty.
+	UpgradeDirectory string
+}
+
+// TLSOptions contains the paths to the certificate and key the API is served
+// with over TLS. When a CA is given, clients must present a certificate signed
+// by it. Privileged operations, such as exec'ing into a container or creating
+// a host privileged container, are only permitted over network listeners when
+// the client has been authenticated this way.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
 }
 
+// DefaultListener is the endpoint the API is served on when no listeners are
+// configured.
+const DefaultListener = "tcp://127.0.0.1:12311"
+
 // Server represents the process that acts as a daemon to receive container
 // management requests.
 type Server struct {
@@ -40,16 +189,62 @@ func New(options *Options) *Server {
 // Start begins the server. It will return an error if starting the Server
 // fails, or return nil on success.
 func (s *Server) Start() error {
-	l, err := net.Listen("tcp", "127.0.0.1:12311")
-	if err != nil {
-		return err
+	endpoints := s.options.Listeners
+	if len(endpoints) == 0 {
+		endpoints = []string{DefaultListener}
+	}
+
+	var tlsConfig *tls.Config
+	if s.options.TLS != nil {
+		var err error
+		tlsConfig, err = pb.ServerTLSConfig(
+			s.options.TLS.CertFile, s.options.TLS.KeyFile, s.options.TLS.CAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	listeners := make([]*listener, 0, len(endpoints))
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for _, endpoint := range endpoints {
+		l, err := s.listen(endpoint, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %q: %v", endpoint, err)
+		}
+		listeners = append(listeners, l)
 	}
-	defer l.Close()
 
 	// create the RPC handler
 	rpc := &rpcServer{
-		log:            s.log.Clone(),
-		pendingUploads: make(map[string]*pendingContainer),
+		log:             s.log.Clone(),
+		shutdownHandler: s.options.ShutdownHandler,
+		images:          s.options.ImageStore,
+		collector:       s.options.GarbageCollector,
+		hostStatus:      s.options.HostStatusHandler,
+		hostInfo:        s.options.HostInfoHandler,
+		hostStorage:     s.options.HostStorageHandler,
+		hostEncryption:  s.options.HostEncryptionHandler,
+		crashes:         s.options.CrashesHandler,
+		crash:           s.options.CrashHandler,
+		reloadConfig:    s.options.ReloadConfigHandler,
+		nodes:           s.options.NodesHandler,
+		console:         s.options.ConsoleHandler,
+		updateStatus:    s.options.UpdateStatusHandler,
+		update:          s.options.UpdateHandler,
+		attest:          s.options.AttestHandler,
+		policies:        s.options.NetworkPolicies,
+		uploads:         newPendingUploads(s.options.UploadDirectory),
+		auth:            s.options.Auth,
+
+		upgradeDirectory: s.options.UpgradeDirectory,
+	}
+	rpc.extraInterceptors = s.options.Interceptors
+	if s.options.RateLimit != nil {
+		rpc.limiter = newRateLimiter(s.options.RateLimit)
 	}
 
 	// check if we were given an existing manager
@@ -57,20 +252,183 @@ func (s *Server) Start() error {
 		rpc.manager = s.options.ContainerManager
 	} else {
 		// initialize the container manager
+		var err error
 		rpc.manager, err = s.initializeManager()
 		if err != nil {
 			return err
 		}
 	}
 
-	// create the gRPC server and run
+	if s.options.JobsFile != "" {
+		scheduler, err := jobs.New(rpc.manager, s.options.JobsFile, rpc.createJobContainer)
+		if err != nil {
+			return err
+		}
+		scheduler.Log = s.log.Clone()
+		scheduler.Start()
+		defer scheduler.Stop()
+		rpc.jobs = scheduler
+	}
+
+	if rpc.images != nil {
+		rpc.builder = build.New(rpc.manager, rpc.images, s.options.UploadDirectory)
+		rpc.builder.Log = s.log.Clone()
+	}
+
+	if s.options.MetricsListener != "" {
+		if err := s.serveMetrics(rpc.manager); err != nil {
+			return fmt.Errorf("failed to serve metrics on %q: %v", s.options.MetricsListener, err)
+		}
+	}
+
+	if s.options.GatewayListener != "" {
+		l, err := s.listen(s.options.GatewayListener, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to serve the gateway on %q: %v", s.options.GatewayListener, err)
+		}
+		defer l.Close()
+		s.serveGateway(*rpc, l)
+	}
+
+	if s.options.CRIListener != "" {
+		if err := s.serveCRI(rpc.manager); err != nil {
+			return fmt.Errorf("failed to serve the CRI on %q: %v", s.options.CRIListener, err)
+		}
+	}
+
+	// Create a gRPC server for each of the listeners and serve on them,
+	// returning once any of them stop. Each has its own handler so privileged
+	// operations can be limited to the listeners which permit them. When
+	// clients are authenticated by their certificates, the connections are
+	// split further by the user they're from.
+	type served struct {
+		l       net.Listener
+		handler *rpcServer
+	}
+	var servers []served
+	for _, l := range listeners {
+		handler := *rpc
+		handler.privileged = l.privileged
+		handler.local = l.local
+		handler.endpoint = l.endpoint
+		if handler.auth == nil || !l.clientCerts {
+			servers = append(servers, served{l, &handler})
+			continue
+		}
+		for user, ul := range splitByUser(l, handler.auth) {
+			userHandler := handler
+			userHandler.certUser = user
+			servers = append(servers, served{ul, &userHandler})
+		}
+	}
+
+	errch := make(chan error, len(servers))
+	for _, sv := range servers {
+		gs := grpc.NewServer()
+		pb.RegisterKurmaServerWithInterceptors(gs, sv.handler, sv.handler.interceptors()...)
+		go func(l net.Listener) {
+			errch <- gs.Serve(l)
+		}(sv.l)
+	}
+	s.log.Debugf("Server is ready on %v", endpoints)
+	return <-errch
+}
+
+// serveMetrics begins serving the metrics endpoint in the background.
+func (s *Server) serveMetrics(manager *container.Manager) error {
+	l, err := upgrade.Listen(s.options.MetricsListener)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &metricsHandler{manager: manager, log: s.log.Clone()})
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			s.log.Errorf("Metrics server on %s stopped: %v", s.options.MetricsListener, err)
+		}
+	}()
+	s.log.Debugf("Serving metrics on %s", s.options.MetricsListener)
+	return nil
+}
+
+// serveCRI begins serving the Container Runtime Interface in the background.
+func (s *Server) serveCRI(manager *container.Manager) error {
+	if s.options.ImageStore == nil {
+		return fmt.Errorf("the image store is required")
+	}
+	network, _, err := pb.ParseEndpoint(s.options.CRIListener)
+	if err != nil {
+		return err
+	}
+	if network != "unix" {
+		return fmt.Errorf("the CRI may only be served on a unix socket")
+	}
+	service, err := cri.New(manager, s.options.ImageStore, s.options.CRIFile)
+	if err != nil {
+		return err
+	}
+	service.Log = s.log.Clone()
+	l, err := upgrade.Listen(s.options.CRIListener)
+	if err != nil {
+		return err
+	}
+
 	gs := grpc.NewServer()
-	pb.RegisterKurmaServer(gs, rpc)
-	s.log.Debug("Server is ready")
-	gs.Serve(l)
+	service.Register(gs)
+	go func() {
+		if err := gs.Serve(l); err != nil {
+			s.log.Errorf("CRI server on %s stopped: %v", s.options.CRIListener, err)
+		}
+	}()
+	s.log.Debugf("Serving the CRI on %s", s.options.CRIListener)
 	return nil
 }
 
+// listener wraps a net.Listener for an endpoint along with whether privileged
+// operations are permitted through it, whether it is a local unix socket, and
+// whether its clients must present a certificate.
+type listener struct {
+	net.Listener
+	endpoint    string
+	privileged  bool
+	local       bool
+	clientCerts bool
+}
+
+// listen creates the listener for the endpoint. Unix sockets are only
+// accessible to root, so are always privileged. Tcp addresses, including
+// loopback ones which any local user or container sharing the host's network
+// can reach, are served over TLS if it is configured, and are privileged only
+// if clients must authenticate with a certificate.
+func (s *Server) listen(endpoint string, tlsConfig *tls.Config) (*listener, error) {
+	network, _, err := pb.ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	l, err := upgrade.Listen(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "unix" {
+		return &listener{Listener: l, endpoint: endpoint, privileged: true, local: true}, nil
+	}
+	if tlsConfig == nil {
+		s.log.Warnf("Serving the API on %s without TLS", endpoint)
+		return &listener{Listener: l, endpoint: endpoint}, nil
+	}
+
+	l = credentials.NewTLS(tlsConfig).NewListener(l)
+	clientCerts := tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert
+	return &listener{
+		Listener:    l,
+		endpoint:    endpoint,
+		privileged:  clientCerts,
+		clientCerts: clientCerts,
+	}, nil
+}
+
 // initializeManager creates the stage0 manager object which will handle
 // container launching.
 func (s *Server) initializeManager() (*container.Manager, error) {
@@ -78,6 +436,7 @@ func (s *Server) initializeManager() (*container.Manager, error) {
 		ParentCgroupName:   s.options.ParentCgroupName,
 		ContainerDirectory: s.options.ContainerDirectory,
 		RequiredNamespaces: s.options.RequiredNamespaces,
+		Upgraded:           upgrade.Upgraded(),
 	}
 
 	m, err := container.NewManager(mopts)
@@ -85,5 +444,13 @@ func (s *Server) initializeManager() (*container.Manager, error) {
 		return nil, err
 	}
 	m.Log = s.log.Clone()
+
+	recovered, err := m.Recover()
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover existing containers: %v", err)
+	}
+	if len(recovered) > 0 {
+		s.log.Infof("Recovered %d existing container(s)", len(recovered))
+	}
 	return m, nil
 }
@@ -0,0 +1,148 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/appc/spec/schema"
+)
+
+// uploadPrefix is the prefix of the files uploaded images are buffered to.
+const uploadPrefix = ".upload-"
+
+// uploadExpiry is how long a pending upload may go without being written to
+// before it is discarded.
+const uploadExpiry = time.Hour
+
+// pendingContainer is a container which has been requested, but whose image is
+// still being uploaded. The image is buffered to a file as it is received so
+// that an interrupted upload can be resumed.
+type pendingContainer struct {
+	name          string
+	imageManifest *schema.ImageManifest
+	opts          *container.CreateOptions
+
+	file       *os.File
+	received   int64
+	active     bool
+	lastActive time.Time
+}
+
+// write appends the bytes to the buffered image.
+func (pc *pendingContainer) write(b []byte) error {
+	n, err := pc.file.Write(b)
+	pc.received += int64(n)
+	return err
+}
+
+// pendingUploads tracks the images still to be uploaded by their upload ID. It
+// is shared by the handlers for each listener.
+type pendingUploads struct {
+	directory string
+	uploads   map[string]*pendingContainer
+	mutex     sync.Mutex
+}
+
+// newPendingUploads returns the tracker for uploads buffered within the
+// directory. Any files left in it by uploads from before a restart are removed,
+// since those uploads can no longer be resumed.
+func newPendingUploads(directory string) *pendingUploads {
+	if directory != "" {
+		if fis, err := ioutil.ReadDir(directory); err == nil {
+			for _, fi := range fis {
+				if strings.HasPrefix(fi.Name(), uploadPrefix) {
+					os.Remove(filepath.Join(directory, fi.Name()))
+				}
+			}
+		}
+	}
+	return &pendingUploads{
+		directory: directory,
+		uploads:   make(map[string]*pendingContainer),
+	}
+}
+
+// add creates the file the container's image is buffered to and records it
+// under the upload ID. Any uploads which have expired are discarded.
+func (p *pendingUploads) add(id string, pc *pendingContainer) error {
+	if p.directory != "" {
+		if err := os.MkdirAll(p.directory, os.FileMode(0755)); err != nil {
+			return err
+		}
+	}
+	f, err := ioutil.TempFile(p.directory, uploadPrefix)
+	if err != nil {
+		return err
+	}
+	pc.file = f
+	pc.lastActive = time.Now()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for id, pc := range p.uploads {
+		if !pc.active && time.Since(pc.lastActive) > uploadExpiry {
+			p.discard(id)
+		}
+	}
+	p.uploads[id] = pc
+	return nil
+}
+
+// acquire returns the pending upload for a stream to write to. Only one stream
+// may write an upload at a time.
+func (p *pendingUploads) acquire(id string) (*pendingContainer, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pc := p.uploads[id]
+	if pc == nil {
+		return nil, fmt.Errorf("specified image upload not found")
+	}
+	if pc.active {
+		return nil, fmt.Errorf("the image is already being uploaded")
+	}
+	pc.active = true
+	return pc, nil
+}
+
+// release returns the pending upload once its stream has ended, so that it can
+// be resumed.
+func (p *pendingUploads) release(pc *pendingContainer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pc.active = false
+	pc.lastActive = time.Now()
+}
+
+// complete removes the upload once its image has been received, returning its
+// buffered image rewound to the start. The file has already been unlinked, so
+// it is removed once it is closed.
+func (p *pendingUploads) complete(id string) (*os.File, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pc := p.uploads[id]
+	delete(p.uploads, id)
+	os.Remove(pc.file.Name())
+	if _, err := pc.file.Seek(0, 0); err != nil {
+		pc.file.Close()
+		return nil, err
+	}
+	return pc.file, nil
+}
+
+// discard removes the upload and its buffered image. The mutex must be held.
+func (p *pendingUploads) discard(id string) {
+	pc := p.uploads[id]
+	delete(p.uploads, id)
+	pc.file.Close()
+	os.Remove(pc.file.Name())
+}
@@ -3,13 +3,26 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	kschema "github.com/apcera/kurma/schema"
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/network"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
 )
 
 func pbContainer(c *container.Container) (*pb.Container, error) {
 	pbc := &pb.Container{
-		Uuid: c.UUID(),
+		Uuid:   c.UUID(),
+		Name:   c.Name(),
+		Labels: c.Labels(),
 	}
 
 	// marshal the pod manifest
@@ -21,20 +34,238 @@ func pbContainer(c *container.Container) (*pb.Container, error) {
 	pbc.Manifest = b
 
 	// map the container state
-	switch c.State() {
-	case container.NEW:
-		pbc.State = pb.Container_NEW
+	pbc.State = pbState(c.State())
+
+	policy, restarts := c.RestartPolicy()
+	pbc.RestartPolicy = string(policy)
+	pbc.Restarts = int32(restarts)
+	pbc.Health = string(c.Health())
+
+	for _, app := range c.Apps() {
+		pbc.Apps = append(pbc.Apps, &pb.AppStatus{
+			Name:     app.Name,
+			State:    pbState(app.State),
+			ExitCode: int32(app.ExitCode),
+			Restarts: int32(app.Restarts),
+		})
+	}
+
+	for _, p := range c.Ports() {
+		pbc.Ports = append(pbc.Ports, &pb.PortMapping{
+			Protocol:      p.Protocol,
+			HostPort:      int32(p.HostPort),
+			ContainerPort: int32(p.ContainerPort),
+		})
+	}
+
+	return pbc, nil
+}
+
+// pbState maps a container state to its protobuf equivalent.
+func pbState(state container.ContainerState) pb.Container_State {
+	switch state {
 	case container.STARTING:
-		pbc.State = pb.Container_STARTING
+		return pb.Container_STARTING
 	case container.RUNNING:
-		pbc.State = pb.Container_RUNNING
+		return pb.Container_RUNNING
 	case container.STOPPING:
-		pbc.State = pb.Container_STOPPING
+		return pb.Container_STOPPING
 	case container.STOPPED:
-		pbc.State = pb.Container_STOPPED
+		return pb.Container_STOPPED
 	case container.EXITED:
-		pbc.State = pb.Container_EXITED
+		return pb.Container_EXITED
+	case container.RESTARTING:
+		return pb.Container_RESTARTING
+	case container.CHECKPOINTED:
+		return pb.Container_CHECKPOINTED
+	default:
+		return pb.Container_NEW
 	}
+}
 
-	return pbc, nil
+// appsRunning returns whether a container in the state has apps which are
+// running, or are being started or stopped.
+func appsRunning(state container.ContainerState) bool {
+	switch state {
+	case container.STARTING, container.RUNNING, container.RESTARTING, container.STOPPING:
+		return true
+	}
+	return false
+}
+
+// requiresPrivilege returns whether any of the apps to be run request to be
+// host privileged.
+func requiresPrivilege(imageManifest *schema.ImageManifest, pod *schema.PodManifest) bool {
+	apps := []*types.App{imageManifest.App}
+	if pod != nil {
+		apps = apps[:0]
+		for _, ra := range pod.Apps {
+			if ra.App != nil {
+				apps = append(apps, ra.App)
+			} else {
+				apps = append(apps, imageManifest.App)
+			}
+		}
+	}
+
+	for _, app := range apps {
+		if app == nil {
+			continue
+		}
+		if iso := app.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
+			if piso, ok := iso.Value().(*kschema.HostPrivileged); ok && bool(*piso) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// createOptions converts the settings from a create request into the options
+// used by the container manager.
+func createOptions(in *pb.CreateRequest) (*container.CreateOptions, error) {
+	policy, err := container.ParseRestartPolicy(in.RestartPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if in.MaxRetries < 0 {
+		return nil, fmt.Errorf("the max retries must not be negative")
+	}
+	if in.StopGracePeriod < 0 {
+		return nil, fmt.Errorf("the stop grace period must not be negative")
+	}
+	if in.DiskLimit < 0 {
+		return nil, fmt.Errorf("the disk limit must not be negative")
+	}
+	if in.ContainerName != "" {
+		if err := container.ValidateName(in.ContainerName); err != nil {
+			return nil, err
+		}
+	}
+	priority, err := container.ParsePriority(in.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &container.CreateOptions{
+		Name:            in.ContainerName,
+		Labels:          in.Labels,
+		RestartPolicy:   policy,
+		MaxRetries:      int(in.MaxRetries),
+		StopGracePeriod: time.Duration(in.StopGracePeriod) * time.Second,
+		Privileged:      in.Privileged,
+		SecurityLabel:   in.SecurityLabel,
+		ReadOnlyRootFS:  in.ReadOnlyRootfs,
+		Isolation:       container.Isolation(in.Isolation),
+		Runtime:         in.Runtime,
+		Priority:        priority,
+		DiskLimit:       in.DiskLimit,
+		Sysctls:         in.Sysctls,
+		Bandwidth: network.Bandwidth{
+			Ingress: in.IngressLimit,
+			Egress:  in.EgressLimit,
+		},
+	}
+	if p := in.CpuPlacement; p != nil {
+		opts.CPUPlacement = &container.CPUPlacement{ExclusiveCPUs: int(p.ExclusiveCpus)}
+		if p.PinNumaNode {
+			node := int(p.NumaNode)
+			opts.CPUPlacement.NUMANode = &node
+		}
+	}
+	if l := in.LogConfig; l != nil {
+		opts.Logging = container.LogConfig{
+			Driver:   l.Driver,
+			MaxSize:  l.MaxSize,
+			MaxFiles: int(l.MaxFiles),
+			Options:  l.Options,
+		}
+	}
+	if len(in.PodManifest) > 0 {
+		if err := json.Unmarshal(in.PodManifest, &opts.Pod); err != nil {
+			return nil, fmt.Errorf("invalid pod manifest: %v", err)
+		}
+		if podReadOnlyRootFS(in.PodManifest) {
+			opts.ReadOnlyRootFS = true
+		}
+	}
+	for _, v := range in.Volumes {
+		opts.Volumes = append(opts.Volumes, &container.VolumeMount{
+			Name:     v.Name,
+			Path:     v.Path,
+			ReadOnly: v.ReadOnly,
+		})
+	}
+	for _, p := range in.Ports {
+		opts.Ports = append(opts.Ports, network.PortMapping{
+			Protocol:      p.Protocol,
+			HostPort:      int(p.HostPort),
+			ContainerPort: int(p.ContainerPort),
+		})
+	}
+	if h := in.HealthCheck; h != nil {
+		opts.HealthCheck = &container.HealthCheck{
+			Exec:      h.Exec,
+			TCPPort:   int(h.TcpPort),
+			HTTPPort:  int(h.HttpPort),
+			HTTPPath:  h.HttpPath,
+			Interval:  time.Duration(h.Interval) * time.Second,
+			Timeout:   time.Duration(h.Timeout) * time.Second,
+			Threshold: int(h.Threshold),
+		}
+	}
+	for _, t := range in.Tmpfs {
+		opts.Tmpfs = append(opts.Tmpfs, &container.TmpfsMount{
+			Path: t.Path,
+			Size: t.Size,
+		})
+	}
+	for _, s := range in.Secrets {
+		opts.Secrets = append(opts.Secrets, &container.SecretMount{
+			Name: s.Name,
+			Path: s.Path,
+			Env:  s.Env,
+		})
+	}
+	opts.Tty = in.Tty
+	opts.Stdin = in.Stdin
+	opts.Attach = in.Attach
+	return opts, nil
+}
+
+// podReadOnlyRootFS returns whether any of the apps in the pod manifest set the
+// appc readOnlyRootFS flag. The apps share the container's filesystem, so it
+// applies to the whole container. The vendored appc schema predates the flag,
+// so it is read from the raw manifest.
+func podReadOnlyRootFS(b []byte) bool {
+	var pod struct {
+		Apps []struct {
+			ReadOnlyRootFS bool `json:"readOnlyRootFS"`
+		} `json:"apps"`
+	}
+	if err := json.Unmarshal(b, &pod); err != nil {
+		return false
+	}
+	for _, a := range pod.Apps {
+		if a.ReadOnlyRootFS {
+			return true
+		}
+	}
+	return false
+}
+
+// setWindowSize applies the provided terminal dimensions to the pty. If either
+// dimension is zero, the size is left untouched.
+func setWindowSize(f *os.File, rows, cols uint32) error {
+	if rows == 0 || cols == 0 {
+		return nil
+	}
+	ws := struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}{uint16(rows), uint16(cols), 0, 0}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
 }
@@ -0,0 +1,181 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// btrfsSuperMagic is the filesystem type statfs reports for btrfs.
+	btrfsSuperMagic = 0x9123683E
+
+	// The btrfs ioctls for creating, snapshotting, and destroying subvolumes,
+	// which all take a btrfsVolArgs.
+	btrfsIocSnapCreate   = 0x50009401
+	btrfsIocSubvolCreate = 0x5000940E
+	btrfsIocSnapDestroy  = 0x5000940F
+
+	btrfsPathNameMax = 4087
+)
+
+// btrfsVolArgs is struct btrfs_ioctl_vol_args from linux/btrfs.h.
+type btrfsVolArgs struct {
+	fd   int64
+	name [btrfsPathNameMax + 1]byte
+}
+
+// btrfsDriver extracts each image into a btrfs subvolume, and gives each
+// container a writable snapshot of it which is bind mounted at its rootfs.
+type btrfsDriver struct {
+	layers     *layerStore
+	containers string
+}
+
+func newBtrfsDriver(opts *Options) (Driver, error) {
+	if opts.Directory == "" {
+		return nil, fmt.Errorf("the btrfs driver requires a storage directory")
+	}
+	if err := os.MkdirAll(opts.Directory, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(opts.Directory, &st); err != nil {
+		return nil, err
+	}
+	if st.Type != btrfsSuperMagic {
+		return nil, fmt.Errorf("the storage directory %s is not on a btrfs filesystem", opts.Directory)
+	}
+
+	layers, err := newLayerStore(filepath.Join(opts.Directory, "layers"), btrfsLayers{})
+	if err != nil {
+		return nil, err
+	}
+	d := &btrfsDriver{
+		layers:     layers,
+		containers: filepath.Join(opts.Directory, "containers"),
+	}
+	if err := os.MkdirAll(d.containers, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *btrfsDriver) Name() string {
+	return Btrfs
+}
+
+func (d *btrfsDriver) Create(name, directory string, image io.Reader) (*Filesystem, error) {
+	id, size, err := d.layers.acquire(name, image)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := filepath.Join(d.containers, name)
+	if err := btrfsSnapshot(d.layers.path(id), snapshot); err != nil {
+		d.layers.release(id, name)
+		return nil, err
+	}
+	if err := d.mount(name, directory); err != nil {
+		btrfsDestroy(snapshot)
+		d.layers.release(id, name)
+		return nil, err
+	}
+	return &Filesystem{Driver: Btrfs, Image: id, ImageSize: size}, nil
+}
+
+func (d *btrfsDriver) Restore(name, directory string, fs *Filesystem) error {
+	if _, err := os.Stat(filepath.Join(d.containers, name)); err != nil {
+		return fmt.Errorf("the container's btrfs snapshot is not present on the host")
+	}
+	d.layers.reference(fs.Image, name)
+	if err := d.mount(name, directory); err != nil {
+		d.layers.release(fs.Image, name)
+		return err
+	}
+	return nil
+}
+
+func (d *btrfsDriver) Remove(name, directory string, fs *Filesystem) error {
+	d.layers.release(fs.Image, name)
+	if err := unmount(rootfs(directory)); err != nil {
+		return err
+	}
+	return btrfsDestroy(filepath.Join(d.containers, name))
+}
+
+func (d *btrfsDriver) Prune() ([]string, error) {
+	return d.layers.prune()
+}
+
+// mount bind mounts the rootfs of the container's snapshot at its rootfs.
+func (d *btrfsDriver) mount(name, directory string) error {
+	target := rootfs(directory)
+	if err := os.MkdirAll(target, os.FileMode(0755)); err != nil {
+		return err
+	}
+	return bindMount(rootfs(filepath.Join(d.containers, name)), target)
+}
+
+// btrfsLayers extracts layers into btrfs subvolumes.
+type btrfsLayers struct{}
+
+func (btrfsLayers) extract(path string, image io.Reader) (string, int64, error) {
+	if err := btrfsIoctl(filepath.Dir(path), btrfsIocSubvolCreate, filepath.Base(path), 0); err != nil {
+		return "", 0, fmt.Errorf("failed to create the btrfs subvolume %q: %v", path, err)
+	}
+	return extractImage(image, path, nil, nil)
+}
+
+func (btrfsLayers) remove(path string) error {
+	return btrfsDestroy(path)
+}
+
+// btrfsSnapshot creates a writable snapshot of the subvolume at the path.
+func btrfsSnapshot(subvolume, path string) error {
+	f, err := os.Open(subvolume)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := btrfsIoctl(filepath.Dir(path), btrfsIocSnapCreate, filepath.Base(path), int64(f.Fd())); err != nil {
+		return fmt.Errorf("failed to snapshot the btrfs subvolume %q: %v", subvolume, err)
+	}
+	return nil
+}
+
+// btrfsDestroy destroys the subvolume, if it exists, along with its contents.
+func btrfsDestroy(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := btrfsIoctl(filepath.Dir(path), btrfsIocSnapDestroy, filepath.Base(path), 0); err != nil {
+		return fmt.Errorf("failed to destroy the btrfs subvolume %q: %v", path, err)
+	}
+	return nil
+}
+
+// btrfsIoctl performs the subvolume ioctl on the directory, for the subvolume
+// with the name within it.
+func btrfsIoctl(directory string, op uintptr, name string, fd int64) error {
+	if len(name) > btrfsPathNameMax {
+		return fmt.Errorf("the subvolume name %q is too long", name)
+	}
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	args := &btrfsVolArgs{fd: fd}
+	copy(args.name[:], name)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dir.Fd(), op, uintptr(unsafe.Pointer(args)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
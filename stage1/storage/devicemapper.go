@@ -0,0 +1,284 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// deviceFile is the file within a devicemapper layer which records the
+	// thin device ID holding the image.
+	deviceFile = "device"
+
+	// nextDeviceFile is the file within the storage directory which records the
+	// next thin device ID to allocate.
+	nextDeviceFile = "next_device"
+
+	// deviceAllocateAttempts is how many thin device IDs are tried when
+	// creating a device, in case the pool already has devices with them.
+	deviceAllocateAttempts = 16
+)
+
+// deviceMapperDriver extracts each image into a thin device from the pool, and
+// gives each container a thin snapshot of it. The container's snapshot is
+// mounted within the storage directory, and its rootfs bind mounted at the
+// container's rootfs.
+type deviceMapperDriver struct {
+	pool       string
+	sectors    int64
+	directory  string
+	containers string
+	layers     *layerStore
+	mutex      sync.Mutex
+}
+
+func newDeviceMapperDriver(opts *Options) (Driver, error) {
+	if opts.Directory == "" {
+		return nil, fmt.Errorf("the devicemapper driver requires a storage directory")
+	}
+	if opts.ThinPool == "" {
+		return nil, fmt.Errorf("the devicemapper driver requires a thin pool")
+	}
+	out, err := dmsetup("status", opts.ThinPool)
+	if err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(out); len(fields) < 3 || fields[2] != "thin-pool" {
+		return nil, fmt.Errorf("the device %q is not a thin pool", opts.ThinPool)
+	}
+
+	size := opts.BaseSize
+	if size <= 0 {
+		size = DefaultBaseSize
+	}
+	d := &deviceMapperDriver{
+		pool:       opts.ThinPool,
+		sectors:    size / 512,
+		directory:  opts.Directory,
+		containers: filepath.Join(opts.Directory, "containers"),
+	}
+	if err := os.MkdirAll(d.containers, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	if d.layers, err = newLayerStore(filepath.Join(opts.Directory, "layers"), deviceMapperLayers{d}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *deviceMapperDriver) Name() string {
+	return DeviceMapper
+}
+
+func (d *deviceMapperDriver) Create(name, directory string, image io.Reader) (*Filesystem, error) {
+	id, size, err := d.layers.acquire(name, image)
+	if err != nil {
+		return nil, err
+	}
+	base, err := readDevice(d.layers.path(id))
+	if err != nil {
+		d.layers.release(id, name)
+		return nil, err
+	}
+	device, err := d.createDevice(fmt.Sprintf("create_snap %%d %d", base))
+	if err != nil {
+		d.layers.release(id, name)
+		return nil, err
+	}
+
+	fs := &Filesystem{Driver: DeviceMapper, Image: id, ImageSize: size, Device: device}
+	if err := d.mount(name, directory, device); err != nil {
+		d.Remove(name, directory, fs)
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (d *deviceMapperDriver) Restore(name, directory string, fs *Filesystem) error {
+	d.layers.reference(fs.Image, name)
+	if err := d.mount(name, directory, fs.Device); err != nil {
+		d.layers.release(fs.Image, name)
+		return err
+	}
+	return nil
+}
+
+func (d *deviceMapperDriver) Remove(name, directory string, fs *Filesystem) error {
+	d.layers.release(fs.Image, name)
+	mountPoint := filepath.Join(d.containers, name)
+	if err := unmount(rootfs(directory)); err != nil {
+		return err
+	}
+	if err := unmount(mountPoint); err != nil {
+		return err
+	}
+	os.Remove(mountPoint)
+	return d.deleteDevice(d.deviceName(name), fs.Device)
+}
+
+func (d *deviceMapperDriver) Prune() ([]string, error) {
+	return d.layers.prune()
+}
+
+// deviceName returns the name the container's device is activated with.
+func (d *deviceMapperDriver) deviceName(name string) string {
+	return "kurma-" + name
+}
+
+// mount activates the container's device, mounts it within the storage
+// directory, and bind mounts its rootfs at the container's rootfs. Each step is
+// skipped if it was already done.
+func (d *deviceMapperDriver) mount(name, directory string, device int) error {
+	deviceName := d.deviceName(name)
+	if err := d.activate(deviceName, device); err != nil {
+		return err
+	}
+	mountPoint := filepath.Join(d.containers, name)
+	if err := os.MkdirAll(mountPoint, os.FileMode(0755)); err != nil {
+		return err
+	}
+	if mounted, err := isMountPoint(mountPoint); err != nil {
+		return err
+	} else if !mounted {
+		if err := syscall.Mount(devicePath(deviceName), mountPoint, "ext4", 0, ""); err != nil {
+			return fmt.Errorf("failed to mount the device %q: %v", deviceName, err)
+		}
+	}
+
+	target := rootfs(directory)
+	if err := os.MkdirAll(target, os.FileMode(0755)); err != nil {
+		return err
+	}
+	return bindMount(rootfs(mountPoint), target)
+}
+
+// createDevice creates a thin device in the pool with the message, formatted
+// with the device ID to allocate, and returns the ID.
+func (d *deviceMapperDriver) createDevice(message string) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	path := filepath.Join(d.directory, nextDeviceFile)
+	next := 1
+	if b, err := ioutil.ReadFile(path); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && n > 0 {
+			next = n
+		}
+	}
+
+	var err error
+	for i := 0; i < deviceAllocateAttempts; i++ {
+		device := next
+		next++
+		if _, err = dmsetup("message", d.pool, "0", fmt.Sprintf(message, device)); err == nil {
+			return device, ioutil.WriteFile(path, []byte(strconv.Itoa(next)), os.FileMode(0644))
+		}
+	}
+	return 0, err
+}
+
+// activate activates the thin device with the name, unless it already is.
+func (d *deviceMapperDriver) activate(deviceName string, device int) error {
+	if _, err := os.Stat(devicePath(deviceName)); err == nil {
+		return nil
+	}
+	table := fmt.Sprintf("0 %d thin %s %d", d.sectors, devicePath(d.pool), device)
+	_, err := dmsetup("create", deviceName, "--table", table)
+	return err
+}
+
+// deleteDevice deactivates the thin device, if it is active, and deletes it
+// from the pool.
+func (d *deviceMapperDriver) deleteDevice(deviceName string, device int) error {
+	if _, err := os.Stat(devicePath(deviceName)); err == nil {
+		if _, err := dmsetup("remove", deviceName); err != nil {
+			return err
+		}
+	}
+	_, err := dmsetup("message", d.pool, "0", fmt.Sprintf("delete %d", device))
+	return err
+}
+
+// deviceMapperLayers extracts layers into thin devices, recording the device
+// ID in the layer's directory.
+type deviceMapperLayers struct {
+	d *deviceMapperDriver
+}
+
+func (l deviceMapperLayers) extract(path string, image io.Reader) (string, int64, error) {
+	if err := os.Mkdir(path, os.FileMode(0755)); err != nil {
+		return "", 0, err
+	}
+	device, err := l.d.createDevice("create_thin %d")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, deviceFile), []byte(strconv.Itoa(device)), os.FileMode(0644)); err != nil {
+		return "", 0, err
+	}
+
+	// format the device and extract the image into it, leaving it inactive
+	// once it is complete
+	deviceName := "kurma-layer-" + strconv.Itoa(device)
+	if err := l.d.activate(deviceName, device); err != nil {
+		return "", 0, err
+	}
+	defer dmsetup("remove", deviceName)
+	if out, err := exec.Command("mkfs.ext4", "-q", devicePath(deviceName)).CombinedOutput(); err != nil {
+		return "", 0, fmt.Errorf("failed to format the device %q: %v: %s", deviceName, err, strings.TrimSpace(string(out)))
+	}
+	mountPoint := filepath.Join(path, "mnt")
+	if err := os.Mkdir(mountPoint, os.FileMode(0755)); err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(mountPoint)
+	if err := syscall.Mount(devicePath(deviceName), mountPoint, "ext4", 0, ""); err != nil {
+		return "", 0, fmt.Errorf("failed to mount the device %q: %v", deviceName, err)
+	}
+	defer syscall.Unmount(mountPoint, 0)
+	return extractImage(image, mountPoint, nil, nil)
+}
+
+func (l deviceMapperLayers) remove(path string) error {
+	if device, err := readDevice(path); err == nil {
+		deviceName := "kurma-layer-" + strconv.Itoa(device)
+		unmount(filepath.Join(path, "mnt"))
+		if err := l.d.deleteDevice(deviceName, device); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(path)
+}
+
+// readDevice returns the thin device ID recorded in the layer.
+func readDevice(path string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(path, deviceFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// devicePath returns the path to the devicemapper device with the name.
+func devicePath(name string) string {
+	return filepath.Join("/dev/mapper", name)
+}
+
+// dmsetup runs the dmsetup command with the arguments, and returns its output.
+func dmsetup(args ...string) (string, error) {
+	out, err := exec.Command("dmsetup", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("dmsetup %s failed: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
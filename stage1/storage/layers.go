@@ -0,0 +1,165 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apcera/util/hashutil"
+	"github.com/apcera/util/uuid"
+)
+
+// layerExtractPrefix is the prefix of the layers images are extracted into
+// before they're added to the layer store.
+const layerExtractPrefix = ".extract-"
+
+// layerBackend creates and removes the layers of a layerStore.
+type layerBackend interface {
+	// extract creates the layer at the path, which doesn't exist yet, and
+	// extracts the image into it, returning the image's ID and size.
+	extract(path string, image io.Reader) (string, int64, error)
+
+	// remove removes the layer at the path.
+	remove(path string) error
+}
+
+// layerStore holds the images containers are created from, each extracted once
+// into a layer named after the image's SHA-512 hash, and tracks the containers
+// using each of them by name.
+type layerStore struct {
+	directory string
+	backend   layerBackend
+	users     map[string]map[string]bool
+	mutex     sync.Mutex
+}
+
+// newLayerStore opens the layer store within the directory, creating it if
+// needed. Any extractions which were interrupted are removed.
+func newLayerStore(directory string, backend layerBackend) (*layerStore, error) {
+	if err := os.MkdirAll(directory, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), layerExtractPrefix) {
+			backend.remove(filepath.Join(directory, fi.Name()))
+		}
+	}
+	return &layerStore{
+		directory: directory,
+		backend:   backend,
+		users:     make(map[string]map[string]bool),
+	}, nil
+}
+
+// path returns the path to the layer.
+func (s *layerStore) path(id string) string {
+	return filepath.Join(s.directory, id)
+}
+
+// acquire returns the ID of the layer holding the image, along with the image's
+// size, extracting it if it isn't in the store yet. When the image can seek, it
+// is hashed first so that an existing layer is used without extracting it
+// again. The layer is kept until the named container releases it.
+func (s *layerStore) acquire(name string, image io.Reader) (string, int64, error) {
+	if rs, ok := image.(io.ReadSeeker); ok {
+		sr := hashutil.NewSha512(rs)
+		if _, err := io.Copy(ioutil.Discard, sr); err != nil {
+			return "", 0, err
+		}
+		id := "sha512-" + sr.Sha512()
+		if s.reference(id, name) {
+			return id, sr.Length(), nil
+		}
+		if _, err := rs.Seek(0, 0); err != nil {
+			return "", 0, err
+		}
+	}
+
+	tmp := s.path(layerExtractPrefix + uuid.Variant4().String())
+	id, size, err := s.backend.extract(tmp, image)
+	if err != nil {
+		s.backend.remove(tmp)
+		return "", 0, err
+	}
+
+	// if the same image was added while it was being extracted, that layer is
+	// used instead
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := os.Stat(s.path(id)); err == nil {
+		s.backend.remove(tmp)
+	} else if err := os.Rename(tmp, s.path(id)); err != nil {
+		s.backend.remove(tmp)
+		return "", 0, err
+	}
+	s.use(id, name)
+	return id, size, nil
+}
+
+// reference records that the named container uses the layer, if it is in the
+// store. A container may reference the same layer more than once.
+func (s *layerStore) reference(id, name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := os.Stat(s.path(id)); err != nil {
+		return false
+	}
+	s.use(id, name)
+	return true
+}
+
+// use records that the named container uses the layer. The store's mutex must
+// be held.
+func (s *layerStore) use(id, name string) {
+	if s.users[id] == nil {
+		s.users[id] = make(map[string]bool)
+	}
+	s.users[id][name] = true
+}
+
+// release records that the named container no longer uses the layer, allowing
+// it to be pruned once no containers do.
+func (s *layerStore) release(id, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.users[id], name)
+	if len(s.users[id]) == 0 {
+		delete(s.users, id)
+	}
+}
+
+// prune removes the layers which no containers are using, and returns their
+// IDs. The layers of checkpointed containers which haven't been restored since
+// the store was opened aren't known to be in use, so they may be removed too.
+func (s *layerStore) prune() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fis, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, fi := range fis {
+		id := fi.Name()
+		if strings.HasPrefix(id, layerExtractPrefix) || len(s.users[id]) > 0 {
+			continue
+		}
+		if err := s.backend.remove(s.path(id)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, id)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
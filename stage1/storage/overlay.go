@@ -0,0 +1,126 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// filesystemsFile lists the filesystems supported by the kernel.
+var filesystemsFile = "/proc/filesystems"
+
+// overlaySupported returns whether the kernel supports overlayfs.
+func overlaySupported() bool {
+	f, err := os.Open(filesystemsFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == "overlay" {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayDriver assembles containers' root filesystems with overlayfs, using
+// the image's layer as the read-only lower layer and directories within the
+// container's directory as the upper layer its changes are written to.
+type overlayDriver struct {
+	layers *layerStore
+}
+
+func newOverlayDriver(opts *Options) (Driver, error) {
+	if opts.Directory == "" {
+		return nil, fmt.Errorf("the overlay driver requires a storage directory")
+	}
+	layers, err := newLayerStore(filepath.Join(opts.Directory, "layers"), directoryLayers{})
+	if err != nil {
+		return nil, err
+	}
+	return &overlayDriver{layers: layers}, nil
+}
+
+func (d *overlayDriver) Name() string {
+	return Overlay
+}
+
+func (d *overlayDriver) Create(name, directory string, image io.Reader) (*Filesystem, error) {
+	id, size, err := d.layers.acquire(name, image)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.mount(directory, id); err != nil {
+		d.layers.release(id, name)
+		return nil, err
+	}
+	return &Filesystem{Driver: Overlay, Image: id, ImageSize: size}, nil
+}
+
+func (d *overlayDriver) Restore(name, directory string, fs *Filesystem) error {
+	if !d.layers.reference(fs.Image, name) {
+		return fmt.Errorf("the image layer %s is not present on the host", fs.Image)
+	}
+	if err := d.mount(directory, fs.Image); err != nil {
+		d.layers.release(fs.Image, name)
+		return err
+	}
+	return nil
+}
+
+func (d *overlayDriver) Remove(name, directory string, fs *Filesystem) error {
+	d.layers.release(fs.Image, name)
+	return nil
+}
+
+func (d *overlayDriver) Prune() ([]string, error) {
+	return d.layers.prune()
+}
+
+// mount mounts the overlay filesystem at the container's rootfs, unless it is
+// already mounted.
+func (d *overlayDriver) mount(directory, id string) error {
+	target := rootfs(directory)
+	if mounted, err := isMountPoint(target); err != nil || mounted {
+		return err
+	}
+
+	upper := filepath.Join(directory, "upper")
+	work := filepath.Join(directory, "work")
+	for _, dir := range []string{upper, work, target} {
+		if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+			return err
+		}
+	}
+
+	lower := rootfs(d.layers.path(id))
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work)
+	if err := syscall.Mount("overlay", target, "overlay", 0, data); err != nil {
+		return fmt.Errorf("failed to mount the container's overlay filesystem: %v", err)
+	}
+	return nil
+}
+
+// directoryLayers extracts layers into plain directories.
+type directoryLayers struct{}
+
+func (directoryLayers) extract(path string, image io.Reader) (string, int64, error) {
+	if err := os.Mkdir(path, os.FileMode(0755)); err != nil {
+		return "", 0, err
+	}
+	return extractImage(image, path, nil, nil)
+}
+
+func (directoryLayers) remove(path string) error {
+	return os.RemoveAll(path)
+}
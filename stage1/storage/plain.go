@@ -0,0 +1,47 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"io"
+)
+
+// plainDriver extracts each container's image into the container's directory.
+type plainDriver struct {
+	uidMapping func(int) (int, error)
+	gidMapping func(int) (int, error)
+}
+
+// NewPlain returns the plain driver. If the mapping functions are set, the
+// owners of the image's files are mapped by them, such as for a container with
+// its own user namespace.
+func NewPlain(uidMapping, gidMapping func(int) (int, error)) Driver {
+	return &plainDriver{
+		uidMapping: uidMapping,
+		gidMapping: gidMapping,
+	}
+}
+
+func (d *plainDriver) Name() string {
+	return Plain
+}
+
+func (d *plainDriver) Create(name, directory string, image io.Reader) (*Filesystem, error) {
+	id, size, err := extractImage(image, directory, d.uidMapping, d.gidMapping)
+	if err != nil {
+		return nil, err
+	}
+	return &Filesystem{Driver: Plain, Image: id, ImageSize: size}, nil
+}
+
+func (d *plainDriver) Restore(name, directory string, fs *Filesystem) error {
+	return nil
+}
+
+func (d *plainDriver) Remove(name, directory string, fs *Filesystem) error {
+	return nil
+}
+
+func (d *plainDriver) Prune() ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,457 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// The ways a quota is enforced.
+const (
+	ProjectQuota  = "project"
+	LoopbackQuota = "loopback"
+)
+
+// MinQuota is the smallest limit a quota may have, in bytes, which leaves room
+// for the metadata of the filesystem a loopback quota is formatted with.
+const MinQuota = 16 << 20
+
+const (
+	// quotaDiskSuffix is appended to the path of a directory to name the file
+	// which backs its loopback quota.
+	quotaDiskSuffix = ".disk"
+
+	// projectQuotaBase is the first project ID given to a directory, and
+	// projectQuotaRange how many are tried when looking for an unused one.
+	projectQuotaBase  = 1 << 20
+	projectQuotaRange = 1 << 16
+
+	// The quotactl commands and the project quota type from linux/quota.h.
+	// Limits are set in blocks of quotaBlockSize bytes.
+	qGetQuota      = 0x800007
+	qSetQuota      = 0x800008
+	prjQuota       = 2
+	qifBLimits     = 1
+	quotaBlockSize = 1024
+
+	// The ioctls and flag for a file's project ID from linux/fs.h.
+	fsIocFsGetXattr    = 0x801c581f
+	fsIocFsSetXattr    = 0x401c5820
+	fsXflagProjInherit = 0x200
+
+	// The loop device ioctls and flag from linux/loop.h. Devices with the
+	// autoclear flag are detached once the filesystem on them is unmounted.
+	loopCtlGetFree   = 0x4C82
+	loopSetFd        = 0x4C00
+	loopClrFd        = 0x4C01
+	loopSetStatus64  = 0x4C04
+	loFlagsAutoclear = 4
+
+	// loopAttachAttempts is how many free loop devices are tried, in case
+	// another process attaches to one first.
+	loopAttachAttempts = 8
+)
+
+var (
+	// mountInfoFile lists the mounts visible to the process.
+	mountInfoFile = "/proc/self/mountinfo"
+
+	// projectMutex prevents two directories being given the same project ID.
+	projectMutex sync.Mutex
+)
+
+// ifDqblk is struct if_dqblk from linux/quota.h.
+type ifDqblk struct {
+	bhardlimit uint64
+	bsoftlimit uint64
+	curspace   uint64
+	ihardlimit uint64
+	isoftlimit uint64
+	curinodes  uint64
+	btime      uint64
+	itime      uint64
+	valid      uint32
+}
+
+// fsxattr is struct fsxattr from linux/fs.h.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// loopInfo64 is struct loop_info64 from linux/loop.h.
+type loopInfo64 struct {
+	device         uint64
+	inode          uint64
+	rdevice        uint64
+	offset         uint64
+	sizelimit      uint64
+	number         uint32
+	encryptType    uint32
+	encryptKeySize uint32
+	flags          uint32
+	fileName       [64]byte
+	cryptName      [64]byte
+	encryptKey     [32]byte
+	init           [2]uint64
+}
+
+// Quota limits the disk space used within a container's directory, and is saved
+// with the container's checkpoints.
+type Quota struct {
+	// Type is how the quota is enforced.
+	Type string `json:"type"`
+
+	// Limit is the most space which may be used, in bytes.
+	Limit int64 `json:"limit"`
+
+	// Project is the project ID of a project quota.
+	Project uint32 `json:"project,omitempty"`
+}
+
+// SupportsQuota returns whether the named driver writes the changes containers
+// make to their root filesystems within their directories, where a quota can
+// limit them.
+func SupportsQuota(driver string) bool {
+	return driver == Plain || driver == Overlay
+}
+
+// SetQuota limits the disk space used within the directory, which must be
+// empty. A project quota is used when the directory is on an xfs or ext4
+// filesystem with them enabled. Otherwise, a filesystem of the limit's size is
+// formatted in a sparse file alongside the directory and mounted at it through
+// a loop device, using the mkfs.ext4 command.
+func SetQuota(directory string, limit int64) (*Quota, error) {
+	if limit < MinQuota {
+		return nil, fmt.Errorf("the disk limit must be at least %d bytes", MinQuota)
+	}
+	if q, err := setProjectQuota(directory, limit); err == nil {
+		return q, nil
+	}
+	if err := createLoopback(directory, limit); err != nil {
+		return nil, fmt.Errorf("failed to create the loopback filesystem for the disk limit: %v", err)
+	}
+	return &Quota{Type: LoopbackQuota, Limit: limit}, nil
+}
+
+// MountQuota mounts the loopback filesystem of the directory's quota, if it has
+// one which isn't mounted, such as after the host restarts. A container's files
+// are all within it, so this is done before anything else in the directory is
+// read.
+func MountQuota(directory string) error {
+	disk := directory + quotaDiskSuffix
+	if _, err := os.Stat(disk); os.IsNotExist(err) {
+		return nil
+	}
+	if mounted, err := isMountPoint(directory); err != nil || mounted {
+		return err
+	}
+	return mountLoopback(disk, directory)
+}
+
+// UnmountQuota unmounts the loopback filesystem of the directory's quota, if it
+// is mounted.
+func UnmountQuota(directory string) error {
+	if _, err := os.Stat(directory + quotaDiskSuffix); os.IsNotExist(err) {
+		return nil
+	}
+	return unmount(directory)
+}
+
+// RemoveQuota removes the quota from the directory. A loopback filesystem must
+// already be unmounted, and is discarded along with its contents.
+func RemoveQuota(directory string, q *Quota) error {
+	switch q.Type {
+	case ProjectQuota:
+		device, err := quotaDevice(directory)
+		if err != nil {
+			return err
+		}
+		return setProjectLimit(device, q.Project, 0)
+	case LoopbackQuota:
+		if err := os.Remove(directory + quotaDiskSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown disk quota type %q", q.Type)
+	}
+}
+
+// QuotaUsage returns the disk space used within the directory and the most
+// which may be, in bytes. For a loopback quota, the most is what the filesystem
+// has room for, which is less than the limit once its metadata is taken out.
+func QuotaUsage(directory string, q *Quota) (int64, int64, error) {
+	switch q.Type {
+	case ProjectQuota:
+		device, err := quotaDevice(directory)
+		if err != nil {
+			return 0, 0, err
+		}
+		dq, err := getProjectQuota(device, q.Project)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int64(dq.curspace), q.Limit, nil
+	case LoopbackQuota:
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(directory, &st); err != nil {
+			return 0, 0, err
+		}
+		return int64(st.Blocks-st.Bfree) * int64(st.Bsize), int64(st.Blocks) * int64(st.Bsize), nil
+	default:
+		return 0, 0, fmt.Errorf("unknown disk quota type %q", q.Type)
+	}
+}
+
+// setProjectQuota gives the directory an unused project ID, which the files
+// created within it inherit, and limits the space used by the project.
+func setProjectQuota(directory string, limit int64) (*Quota, error) {
+	device, err := quotaDevice(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+	id, err := unusedProject(device)
+	if err != nil {
+		return nil, err
+	}
+	if err := setProjectID(directory, id); err != nil {
+		return nil, err
+	}
+	if err := setProjectLimit(device, id, limit); err != nil {
+		return nil, err
+	}
+	return &Quota{Type: ProjectQuota, Limit: limit, Project: id}, nil
+}
+
+// unusedProject returns a project ID on the device which has no limit and no
+// files. The project mutex must be held.
+func unusedProject(device string) (uint32, error) {
+	for id := uint32(projectQuotaBase); id < projectQuotaBase+projectQuotaRange; id++ {
+		dq, err := getProjectQuota(device, id)
+		if err == syscall.ENOENT {
+			return id, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if dq.bhardlimit == 0 && dq.curspace == 0 && dq.curinodes == 0 {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("no unused project IDs are left on %s", device)
+}
+
+// getProjectQuota returns the limits and usage of the project on the device.
+func getProjectQuota(device string, id uint32) (*ifDqblk, error) {
+	dq := &ifDqblk{}
+	if err := quotactl(qGetQuota, device, id, unsafe.Pointer(dq)); err != nil {
+		return nil, err
+	}
+	return dq, nil
+}
+
+// setProjectLimit sets the hard limit on the space used by the project on the
+// device, in bytes. A limit of 0 removes it.
+func setProjectLimit(device string, id uint32, limit int64) error {
+	dq := &ifDqblk{
+		bhardlimit: uint64((limit + quotaBlockSize - 1) / quotaBlockSize),
+		valid:      qifBLimits,
+	}
+	if err := quotactl(qSetQuota, device, id, unsafe.Pointer(dq)); err != nil {
+		return fmt.Errorf("failed to set the quota of project %d: %v", id, err)
+	}
+	return nil
+}
+
+// quotactl runs the project quota command against the device.
+func quotactl(cmd int, device string, id uint32, addr unsafe.Pointer) error {
+	p, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL, uintptr(cmd<<8|prjQuota),
+		uintptr(unsafe.Pointer(p)), uintptr(id), uintptr(addr), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setProjectID sets the directory's project ID, and flags it so that the files
+// created within it inherit the ID.
+func setProjectID(directory string, id uint32) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	attr := &fsxattr{}
+	if err := ioctl(dir.Fd(), fsIocFsGetXattr, uintptr(unsafe.Pointer(attr))); err != nil {
+		return fmt.Errorf("failed to get the attributes of %q: %v", directory, err)
+	}
+	attr.projid = id
+	attr.xflags |= fsXflagProjInherit
+	if err := ioctl(dir.Fd(), fsIocFsSetXattr, uintptr(unsafe.Pointer(attr))); err != nil {
+		return fmt.Errorf("failed to set the project ID of %q: %v", directory, err)
+	}
+	return nil
+}
+
+// quotaDevice returns the block device of the filesystem the path is on, which
+// must be xfs or ext4 to support project quotas.
+func quotaDevice(path string) (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", err
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+
+	source, fstype, err := mountSource(mountInfoFile, fmt.Sprintf("%d:%d", major, minor))
+	if err != nil {
+		return "", err
+	}
+	if fstype != "xfs" && fstype != "ext4" {
+		return "", fmt.Errorf("project quotas are not supported on %s filesystems", fstype)
+	}
+	return source, nil
+}
+
+// mountSource returns the source and type of the filesystem with the device
+// number, in the form "major:minor", from the mountinfo file. Each line has the
+// device number as its third field, and the type and source follow the "-"
+// which ends the optional fields.
+func mountSource(path, device string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[2] != device {
+			continue
+		}
+		for i := 6; i+2 < len(fields); i++ {
+			if fields[i] == "-" {
+				return fields[i+2], fields[i+1], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	return "", "", fmt.Errorf("no filesystem with the device number %s is mounted", device)
+}
+
+// createLoopback creates the sparse file for the directory's loopback quota,
+// formats it, and mounts it at the directory.
+func createLoopback(directory string, limit int64) error {
+	disk := directory + quotaDiskSuffix
+	f, err := os.OpenFile(disk, os.O_RDWR|os.O_CREATE|os.O_EXCL, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(limit)
+	f.Close()
+	if err != nil {
+		os.Remove(disk)
+		return err
+	}
+
+	// the filesystem has no reserved blocks, so the container can use all of it
+	if out, err := exec.Command("mkfs.ext4", "-q", "-F", "-m", "0", disk).CombinedOutput(); err != nil {
+		os.Remove(disk)
+		return fmt.Errorf("mkfs.ext4 failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := mountLoopback(disk, directory); err != nil {
+		os.Remove(disk)
+		return err
+	}
+	return nil
+}
+
+// mountLoopback attaches the file to a loop device and mounts its filesystem at
+// the directory. The device is detached once the filesystem is unmounted.
+func mountLoopback(disk, directory string) error {
+	device, err := attachLoop(disk)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	if err := syscall.Mount(device.Name(), directory, "ext4", 0, ""); err != nil {
+		return fmt.Errorf("failed to mount %q: %v", disk, err)
+	}
+	return nil
+}
+
+// attachLoop attaches the file to a free loop device with the autoclear flag,
+// and returns the open device. It is detached once it is closed, unless a
+// filesystem on it has been mounted.
+func attachLoop(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctl, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ctl.Close()
+
+	for i := 0; i < loopAttachAttempts; i++ {
+		n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ctl.Fd(), loopCtlGetFree, 0)
+		if errno != 0 {
+			return nil, fmt.Errorf("failed to find a free loop device: %v", errno)
+		}
+		device, err := os.OpenFile(fmt.Sprintf("/dev/loop%d", n), os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioctl(device.Fd(), loopSetFd, f.Fd()); err != nil {
+			device.Close()
+			if err == syscall.EBUSY {
+				continue
+			}
+			return nil, fmt.Errorf("failed to attach %q to a loop device: %v", path, err)
+		}
+
+		info := &loopInfo64{flags: loFlagsAutoclear}
+		copy(info.fileName[:], path)
+		if err := ioctl(device.Fd(), loopSetStatus64, uintptr(unsafe.Pointer(info))); err != nil {
+			ioctl(device.Fd(), loopClrFd, 0)
+			device.Close()
+			return nil, fmt.Errorf("failed to configure the loop device for %q: %v", path, err)
+		}
+		return device, nil
+	}
+	return nil, fmt.Errorf("failed to attach %q to a loop device: all were busy", path)
+}
+
+// ioctl performs the ioctl on the file descriptor.
+func ioctl(fd, op, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, op, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package storage implements the drivers which provide containers' root
+// filesystems from the images they're created from. The plain driver extracts
+// each container's image into its own directory, while the others extract each
+// image once and give containers a copy-on-write view of it, using overlayfs,
+// btrfs snapshots, or devicemapper thin provisioning.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+
+	"github.com/apcera/util/hashutil"
+	"github.com/apcera/util/proc"
+	"github.com/apcera/util/tarhelper"
+)
+
+// The names of the storage drivers.
+const (
+	Plain        = "plain"
+	Overlay      = "overlay"
+	Btrfs        = "btrfs"
+	DeviceMapper = "devicemapper"
+)
+
+// Driver provides the root filesystems of containers. Each container is
+// identified by a unique name, and has a directory on the host within which its
+// root filesystem is made available at "rootfs".
+type Driver interface {
+	// Name returns the name of the driver.
+	Name() string
+
+	// Create sets up the container's root filesystem from the image, which is
+	// an ACI.
+	Create(name, directory string, image io.Reader) (*Filesystem, error)
+
+	// Restore makes a filesystem created earlier available again, such as for
+	// a container restored from a checkpoint, mounting it if it isn't.
+	Restore(name, directory string, fs *Filesystem) error
+
+	// Remove releases the container's filesystem. The container's directory is
+	// unmounted and removed by the caller.
+	Remove(name, directory string, fs *Filesystem) error
+
+	// Prune removes the images extracted by the driver which no containers are
+	// using, and returns their IDs.
+	Prune() ([]string, error)
+}
+
+// Filesystem describes a container's root filesystem, and is saved with the
+// container's checkpoints.
+type Filesystem struct {
+	// Driver is the name of the driver which created it.
+	Driver string `json:"driver"`
+
+	// Image is the ID of the image it was created from, in the form
+	// sha512-<hex>, and ImageSize the size of the image in bytes.
+	Image     string `json:"image"`
+	ImageSize int64  `json:"image_size"`
+
+	// Device is the devicemapper thin device ID of the container's snapshot.
+	Device int `json:"device,omitempty"`
+}
+
+// Options configures the storage drivers.
+type Options struct {
+	// Directory is where the drivers which share images between containers keep
+	// them. For the btrfs driver, it must be on a btrfs filesystem.
+	Directory string
+
+	// ThinPool is the name of the existing devicemapper thin pool the
+	// devicemapper driver allocates devices from, and BaseSize the size of each
+	// device in bytes. The pool's devices are formatted as ext4 using the
+	// dmsetup and mkfs.ext4 commands.
+	ThinPool string
+	BaseSize int64
+}
+
+// DefaultBaseSize is the size of the devicemapper driver's devices when no base
+// size is given.
+const DefaultBaseSize = 10 << 30
+
+// New returns the named driver. If the name is empty, the overlay driver is used
+// when the kernel supports overlayfs, and the plain driver otherwise.
+func New(name string, opts *Options) (Driver, error) {
+	switch name {
+	case "":
+		if overlaySupported() {
+			return newOverlayDriver(opts)
+		}
+		return NewPlain(nil, nil), nil
+	case Plain:
+		return NewPlain(nil, nil), nil
+	case Overlay:
+		if !overlaySupported() {
+			return nil, fmt.Errorf("the kernel does not support overlayfs")
+		}
+		return newOverlayDriver(opts)
+	case Btrfs:
+		return newBtrfsDriver(opts)
+	case DeviceMapper:
+		return newDeviceMapperDriver(opts)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", name)
+	}
+}
+
+// extractImage extracts the image into the directory, and returns its ID and
+// size. The owners of its files are mapped by the functions if they're set.
+func extractImage(image io.Reader, directory string, uidMapping, gidMapping func(int) (int, error)) (string, int64, error) {
+	sr := hashutil.NewSha512(image)
+	tarfile := tarhelper.NewUntar(sr, directory)
+	tarfile.PreserveOwners = true
+	tarfile.PreservePermissions = true
+	tarfile.Compression = tarhelper.DETECT
+	tarfile.AbsoluteRoot = directory
+	if uidMapping != nil {
+		tarfile.OwnerMappingFunc = uidMapping
+	}
+	if gidMapping != nil {
+		tarfile.GroupMappingFunc = gidMapping
+	}
+	if err := tarfile.Extract(); err != nil {
+		return "", 0, fmt.Errorf("failed to extract the image: %v", err)
+	}
+
+	// hash the whole image, including any padding after the archive, so the ID
+	// matches the hash of the image's file
+	if _, err := io.Copy(ioutil.Discard, sr); err != nil {
+		return "", 0, err
+	}
+	return "sha512-" + sr.Sha512(), sr.Length(), nil
+}
+
+// rootfs returns the path to the container's root filesystem.
+func rootfs(directory string) string {
+	return filepath.Join(directory, "rootfs")
+}
+
+// isMountPoint returns whether a filesystem is mounted at the path.
+func isMountPoint(path string) (bool, error) {
+	mounted := false
+	err := proc.ParseSimpleProcFile(
+		proc.MountProcFile,
+		nil,
+		func(line int, index int, elem string) error {
+			if index == 1 && elem == path {
+				mounted = true
+			}
+			return nil
+		})
+	return mounted, err
+}
+
+// unmount unmounts the path if a filesystem is mounted there.
+func unmount(path string) error {
+	mounted, err := isMountPoint(path)
+	if err != nil || !mounted {
+		return err
+	}
+	if err := syscall.Unmount(path, 0); err != nil {
+		return fmt.Errorf("failed to unmount %q: %v", path, err)
+	}
+	return nil
+}
+
+// bindMount bind mounts the source onto the destination if it isn't already.
+func bindMount(source, destination string) error {
+	mounted, err := isMountPoint(destination)
+	if err != nil || mounted {
+		return err
+	}
+	if err := syscall.Mount(source, destination, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to bind mount %q: %v", source, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// testImage returns an ACI holding a rootfs with a single file.
+func testImage(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	TestExpectSuccess(t, tw.WriteHeader(&tar.Header{Name: "rootfs/", Mode: 0755, Typeflag: tar.TypeDir}))
+	TestExpectSuccess(t, tw.WriteHeader(&tar.Header{Name: "rootfs/file", Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, tw.Close())
+	return buf.Bytes()
+}
+
+func imageID(image []byte) string {
+	sum := sha512.Sum512(image)
+	return "sha512-" + hex.EncodeToString(sum[:])
+}
+
+func TestPlainDriver(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	image := testImage(t, "plain")
+	dir := TempDir(t)
+	d := NewPlain(nil, nil)
+	TestEqual(t, d.Name(), Plain)
+
+	fs, err := d.Create("a", dir, ioutil.NopCloser(bytes.NewReader(image)))
+	TestExpectSuccess(t, err)
+	TestEqual(t, fs.Driver, Plain)
+	TestEqual(t, fs.Image, imageID(image))
+	TestEqual(t, fs.ImageSize, int64(len(image)))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "rootfs", "file"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "plain")
+}
+
+func TestLayerStore(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	a := testImage(t, "a")
+	b := testImage(t, "b")
+	dir := TempDir(t)
+	TestExpectSuccess(t, os.Mkdir(filepath.Join(dir, layerExtractPrefix+"1"), 0755))
+	s, err := newLayerStore(dir, directoryLayers{})
+	TestExpectSuccess(t, err)
+
+	// Test 1: Interrupted extractions are removed when the store is opened.
+	_, err = os.Stat(filepath.Join(dir, layerExtractPrefix+"1"))
+	TestEqual(t, os.IsNotExist(err), true)
+
+	// Test 2: An image which can't seek is extracted, and the same image which
+	// can is found by its hash and shares the layer.
+	id, size, err := s.acquire("one", ioutil.NopCloser(bytes.NewReader(a)))
+	TestExpectSuccess(t, err)
+	TestEqual(t, id, imageID(a))
+	TestEqual(t, size, int64(len(a)))
+	content, err := ioutil.ReadFile(filepath.Join(s.path(id), "rootfs", "file"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(content), "a")
+
+	id2, _, err := s.acquire("two", bytes.NewReader(a))
+	TestExpectSuccess(t, err)
+	TestEqual(t, id2, id)
+
+	idb, _, err := s.acquire("three", bytes.NewReader(b))
+	TestExpectSuccess(t, err)
+	fis, err := ioutil.ReadDir(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(fis), 2)
+
+	// Test 3: Layers are only pruned once every container using them has
+	// released them, however many times they referenced them.
+	TestEqual(t, s.reference(id, "one"), true)
+	TestEqual(t, s.reference("sha512-missing", "one"), false)
+	s.release(idb, "three")
+	removed, err := s.prune()
+	TestExpectSuccess(t, err)
+	TestEqual(t, removed, []string{idb})
+
+	s.release(id, "one")
+	removed, err = s.prune()
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(removed), 0)
+
+	s.release(id, "two")
+	removed, err = s.prune()
+	TestExpectSuccess(t, err)
+	TestEqual(t, removed, []string{id})
+	fis, err = ioutil.ReadDir(dir)
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(fis), 0)
+}
+
+func TestOverlaySupported(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(f string) { filesystemsFile = f }(filesystemsFile)
+	filesystemsFile = filepath.Join(TempDir(t), "filesystems")
+
+	TestExpectSuccess(t, ioutil.WriteFile(filesystemsFile, []byte("nodev\tsysfs\n\text4\n"), 0644))
+	TestEqual(t, overlaySupported(), false)
+	TestExpectSuccess(t, ioutil.WriteFile(filesystemsFile, []byte("nodev\tsysfs\nnodev\toverlay\n"), 0644))
+	TestEqual(t, overlaySupported(), true)
+}
+
+func TestNew(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	d, err := New(Plain, &Options{})
+	TestExpectSuccess(t, err)
+	TestEqual(t, d.Name(), Plain)
+
+	_, err = New("zfs", &Options{})
+	TestExpectError(t, err)
+	_, err = New(Overlay, &Options{})
+	TestExpectError(t, err)
+	_, err = New(DeviceMapper, &Options{Directory: TempDir(t)})
+	TestExpectError(t, err)
+}
+
+func TestMountSource(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), "mountinfo")
+	TestExpectSuccess(t, ioutil.WriteFile(path, []byte(
+		"22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,prjquota\n"+
+			"23 22 0:21 / /proc rw,nosuid - proc proc rw\n"+
+			"24 22 8:16 / /var/lib/kurma rw master:2 shared:3 - xfs /dev/sdb rw,prjquota\n"),
+		0644))
+
+	source, fstype, err := mountSource(path, "8:16")
+	TestExpectSuccess(t, err)
+	TestEqual(t, source, "/dev/sdb")
+	TestEqual(t, fstype, "xfs")
+
+	source, fstype, err = mountSource(path, "8:1")
+	TestExpectSuccess(t, err)
+	TestEqual(t, source, "/dev/sda1")
+	TestEqual(t, fstype, "ext4")
+
+	_, _, err = mountSource(path, "8:2")
+	TestExpectError(t, err)
+}
+
+func TestSetQuotaLimits(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, SupportsQuota(Plain), true)
+	TestEqual(t, SupportsQuota(Overlay), true)
+	TestEqual(t, SupportsQuota(Btrfs), false)
+	TestEqual(t, SupportsQuota(DeviceMapper), false)
+
+	_, err := SetQuota(TempDir(t), MinQuota-1)
+	TestExpectError(t, err)
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package upgrade replaces the running kurma with a newer binary without
+// stopping its containers. The binary is retrieved from a signed update bundle
+// and exec'd in place of the process, inheriting the listeners the API is
+// served on, while the container manager recovers the containers from the
+// state it saved before handing them off.
+package upgrade
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/util/remote"
+	"github.com/apcera/util/tarhelper"
+)
+
+const (
+	// BundleName is the name of the binary within an update bundle, and the
+	// name its signature must be made by a key trusted for, as with an image's
+	// name.
+	BundleName = "kurma"
+
+	// upgradedEnv is set in the environment of the binary exec'd by an
+	// upgrade, and listenersEnv lists the listeners it inherits as
+	// endpoint=fd pairs separated by commas.
+	upgradedEnv  = "KURMA_UPGRADED"
+	listenersEnv = "KURMA_LISTENERS"
+)
+
+var (
+	inheritOnce sync.Once
+	upgraded    bool
+
+	// inherited are the descriptors of the listeners passed on by the earlier
+	// kurma which haven't been listened on yet, and listeners are those which
+	// are to be passed on, by their endpoints.
+	inherited      map[string]int
+	listeners      map[string]*listener
+	listenersMutex sync.Mutex
+)
+
+// Upgraded returns whether the process was exec'd by an upgrade of an earlier
+// kurma, whose containers are to be recovered rather than created again.
+func Upgraded() bool {
+	inherit()
+	return upgraded
+}
+
+// inherit reads what the earlier kurma passed on from the environment, which is
+// then cleared so that it isn't passed on to the processes kurma runs.
+func inherit() {
+	inheritOnce.Do(func() {
+		upgraded = os.Getenv(upgradedEnv) != ""
+		inherited = parseListeners(os.Getenv(listenersEnv))
+		listeners = make(map[string]*listener)
+		os.Unsetenv(upgradedEnv)
+		os.Unsetenv(listenersEnv)
+	})
+}
+
+// parseListeners parses the endpoint=fd pairs of the inherited listeners,
+// skipping any which are malformed.
+func parseListeners(s string) map[string]int {
+	fds := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			continue
+		}
+		fd, err := strconv.Atoi(pair[i+1:])
+		if err != nil || fd < 3 {
+			continue
+		}
+		fds[pair[:i]] = fd
+	}
+	return fds
+}
+
+// formatListeners formats the listeners' descriptors as parseListeners reads
+// them.
+func formatListeners(fds map[string]int) string {
+	pairs := make([]string, 0, len(fds))
+	for endpoint, fd := range fds {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", endpoint, fd))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// listener is a listener to be passed on when the process is upgraded, until it
+// is closed.
+type listener struct {
+	net.Listener
+	endpoint string
+}
+
+func (l *listener) Close() error {
+	listenersMutex.Lock()
+	if listeners[l.endpoint] == l {
+		delete(listeners, l.endpoint)
+	}
+	listenersMutex.Unlock()
+	return l.Listener.Close()
+}
+
+// Listen listens on the endpoint, in the form the API client's Listen takes.
+// The listener the earlier kurma passed on for the endpoint is used if there
+// is one, so that the connections waiting on it are accepted, and the listener
+// is passed on in turn by Exec until it is closed.
+func Listen(endpoint string) (net.Listener, error) {
+	inherit()
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	var l net.Listener
+	var err error
+	if fd, ok := inherited[endpoint]; ok {
+		delete(inherited, endpoint)
+		f := os.NewFile(uintptr(fd), endpoint)
+		l, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit the listener: %v", err)
+		}
+	} else if l, err = pb.Listen(endpoint); err != nil {
+		return nil, err
+	}
+
+	hl := &listener{Listener: l, endpoint: endpoint}
+	listeners[endpoint] = hl
+	return hl, nil
+}
+
+// Fetch retrieves the update bundle at the url, which must be signed by a key
+// trusted for BundleName with the armored detached signature at the url with
+// ".asc" appended. The bundle is a tar archive, which may be compressed,
+// holding the binary as BundleName. The binary is written to the directory,
+// and its path is returned along with its SHA-512 hash in the form
+// sha512-<hex>.
+func Fetch(uri, directory string) (string, string, error) {
+	r, err := remote.RetrieveSignedFile(uri, BundleName)
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close()
+
+	arch, err := tarhelper.DetectArchiveCompression(r)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the update bundle: %v", err)
+	}
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			return "", "", fmt.Errorf("the update bundle has no %s binary", BundleName)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read the update bundle: %v", err)
+		}
+		if filepath.Clean(header.Name) != BundleName {
+			continue
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			return "", "", fmt.Errorf("the update bundle's %s is not a regular file", BundleName)
+		}
+		return writeBinary(arch, directory)
+	}
+}
+
+// writeBinary writes the binary into the directory, named by its hash.
+func writeBinary(r io.Reader, directory string) (string, string, error) {
+	if err := os.MkdirAll(directory, os.FileMode(0755)); err != nil {
+		return "", "", err
+	}
+	f, err := ioutil.TempFile(directory, BundleName)
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", "", fmt.Errorf("failed to write the binary: %v", err)
+	}
+	if err := f.Chmod(os.FileMode(0755)); err != nil {
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", "", err
+	}
+
+	digest := "sha512-" + hex.EncodeToString(h.Sum(nil))
+	path := filepath.Join(directory, BundleName+"-"+digest[:len("sha512-")+32])
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", "", err
+	}
+	removeOldBinaries(directory, path)
+	return path, digest, nil
+}
+
+// removeOldBinaries removes the binaries retrieved by earlier upgrades from the
+// directory, other than the one being upgraded to and the one running.
+func removeOldBinaries(directory, keep string) {
+	running, _ := os.Executable()
+	matches, _ := filepath.Glob(filepath.Join(directory, BundleName+"-sha512-*"))
+	for _, path := range matches {
+		if path != keep && path != running {
+			os.Remove(path)
+		}
+	}
+}
+
+// Exec replaces the process with the binary at the path, run with the same
+// arguments, and passes on the listeners created with Listen which are still
+// open. It only returns if the binary couldn't be exec'd.
+func Exec(path string) error {
+	inherit()
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	fds := make(map[string]int)
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for endpoint, l := range listeners {
+		fl, ok := l.Listener.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("failed to pass on the listener on %s: %v", endpoint, err)
+		}
+		files = append(files, f)
+		fd := f.Fd()
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_SETFD, 0); errno != 0 {
+			return fmt.Errorf("failed to pass on the listener on %s: %v", endpoint, errno)
+		}
+		fds[endpoint] = int(fd)
+	}
+
+	env := append(os.Environ(), upgradedEnv+"=1", listenersEnv+"="+formatListeners(fds))
+	return syscall.Exec(path, os.Args, env)
+}
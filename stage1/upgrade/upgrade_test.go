@@ -0,0 +1,145 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package upgrade
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/apcera/kurma/util/remote"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// writeSignedBundle writes an update bundle holding the files to the path,
+// signed by the entity.
+func writeSignedBundle(t *testing.T, path string, files map[string]string, entity *openpgp.Entity) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		TestExpectSuccess(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data))}))
+		_, err := tw.Write([]byte(data))
+		TestExpectSuccess(t, err)
+	}
+	TestExpectSuccess(t, tw.Close())
+	TestExpectSuccess(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	var sig bytes.Buffer
+	TestExpectSuccess(t, openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(buf.Bytes()), nil))
+	TestExpectSuccess(t, ioutil.WriteFile(path+".asc", sig.Bytes(), 0644))
+}
+
+// trustKey writes the entity's public key into the keystore, trusted for the
+// bundle's name.
+func trustKey(t *testing.T, keystore string, entity *openpgp.Entity) {
+	dir := filepath.Join(keystore, "prefix.d", BundleName)
+	TestExpectSuccess(t, os.MkdirAll(dir, 0755))
+	f, err := os.Create(filepath.Join(dir, "key"))
+	TestExpectSuccess(t, err)
+	defer f.Close()
+	w, err := armor.Encode(f, openpgp.PublicKeyType, nil)
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, entity.Serialize(w))
+	TestExpectSuccess(t, w.Close())
+}
+
+func TestFetch(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir := TempDir(t)
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	TestExpectSuccess(t, err)
+
+	defer func(d string) { remote.KeystoreDirectory = d }(remote.KeystoreDirectory)
+	remote.KeystoreDirectory = filepath.Join(dir, "keystore")
+
+	bundle := filepath.Join(dir, "bundle.tar")
+	writeSignedBundle(t, bundle, map[string]string{
+		"README": "release notes",
+		"kurma":  "#!/bin/sh\n",
+	}, entity)
+	binaries := filepath.Join(dir, "binaries")
+
+	// Test 1: The bundle isn't used unless its signer is trusted.
+	_, _, err = Fetch("file://"+bundle, binaries)
+	TestExpectError(t, err)
+
+	// Test 2: The binary is written executable, named by its hash.
+	trustKey(t, remote.KeystoreDirectory, entity)
+	path, digest, err := Fetch("file://"+bundle, binaries)
+	TestExpectSuccess(t, err)
+	TestTrue(t, strings.HasPrefix(digest, "sha512-"))
+	TestEqual(t, filepath.Dir(path), binaries)
+	TestTrue(t, strings.HasPrefix(filepath.Base(path), "kurma-sha512-"))
+	b, err := ioutil.ReadFile(path)
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "#!/bin/sh\n")
+	fi, err := os.Stat(path)
+	TestExpectSuccess(t, err)
+	TestEqual(t, fi.Mode().Perm(), os.FileMode(0755))
+
+	// Test 3: A bundle without the binary is refused.
+	writeSignedBundle(t, bundle, map[string]string{"README": "release notes"}, entity)
+	_, _, err = Fetch("file://"+bundle, binaries)
+	TestExpectError(t, err)
+}
+
+func TestParseListeners(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	fds := map[string]int{
+		"tcp://127.0.0.1:12311":      3,
+		"unix:///var/run/kurma.sock": 7,
+	}
+	TestEqual(t, parseListeners(formatListeners(fds)), fds)
+
+	// malformed pairs and the standard descriptors are skipped
+	TestEqual(t, parseListeners("=4,tcp://:1=x,tcp://:2=1,,tcp://:3=5"), map[string]int{"tcp://:3": 5})
+}
+
+func TestListenInherited(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	inherit()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	TestExpectSuccess(t, err)
+	fd, err := syscall.Dup(int(f.Fd()))
+	TestExpectSuccess(t, err)
+	f.Close()
+
+	// The inherited listener is used for its endpoint, and only once.
+	endpoint := "tcp://" + l.Addr().String()
+	listenersMutex.Lock()
+	inherited[endpoint] = fd
+	listenersMutex.Unlock()
+	il, err := Listen(endpoint)
+	TestExpectSuccess(t, err)
+	TestEqual(t, il.Addr().String(), l.Addr().String())
+	listenersMutex.Lock()
+	_, ok := inherited[endpoint]
+	TestFalse(t, ok)
+	TestEqual(t, listeners[endpoint], il)
+	listenersMutex.Unlock()
+
+	// Closed listeners aren't passed on.
+	TestExpectSuccess(t, il.Close())
+	listenersMutex.Lock()
+	_, ok = listeners[endpoint]
+	listenersMutex.Unlock()
+	TestFalse(t, ok)
+}
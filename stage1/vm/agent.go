@@ -0,0 +1,537 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// agentEnv is set on the kernel command line of the VM, which the kernel
+	// passes to init within its environment, so that the binary knows to run
+	// as the agent. readOnlyEnv is set when the root filesystem is to be
+	// mounted read only.
+	agentEnv    = "KURMA_VM_AGENT"
+	readOnlyEnv = "KURMA_VM_READONLY"
+
+	// defaultPath is the PATH commands are looked up within if the process's
+	// environment doesn't set one.
+	defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// outputTimeout is how long an exited process's output is waited on, in
+	// case it left children holding its stdout or stderr open.
+	outputTimeout = time.Second
+)
+
+// init takes over the binary when it is run as the VM's init, so that the
+// agent runs before anything else in the binary is started.
+func init() {
+	if os.Getpid() != 1 || os.Getenv(agentEnv) == "" {
+		return
+	}
+
+	port, err := bootGuest(os.Getenv(readOnlyEnv) != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kurma: failed to boot the VM: %v\n", err)
+	} else {
+		a := newAgent(port)
+		a.reapAll = true
+		a.serve()
+	}
+	syscall.Sync()
+	syscall.Reboot(syscall.LINUX_REBOOT_CMD_POWER_OFF)
+	os.Exit(1)
+}
+
+// bootGuest mounts the container's filesystem as the VM's root and configures
+// its network, returning the port the host is connected to.
+func bootGuest(readOnly bool) (*os.File, error) {
+	mounts := []struct {
+		source, target, fstype string
+	}{
+		{"proc", "/proc", "proc"},
+		{"sysfs", "/sys", "sysfs"},
+		{"devtmpfs", "/dev", "devtmpfs"},
+	}
+	for _, m := range mounts {
+		if err := syscall.Mount(m.source, m.target, m.fstype, 0, ""); err != nil {
+			return nil, fmt.Errorf("failed to mount %s: %v", m.target, err)
+		}
+	}
+
+	port, err := openPort(agentPort)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uintptr
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount(rootTag, "/root", "9p", flags, "trans=virtio,version=9p2000.L,access=any"); err != nil {
+		return nil, fmt.Errorf("failed to mount the root filesystem: %v", err)
+	}
+	for _, m := range mounts {
+		target := filepath.Join("/root", m.target)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return nil, err
+		}
+		if err := syscall.Mount(m.target, target, "", syscall.MS_MOVE, ""); err != nil {
+			return nil, fmt.Errorf("failed to move %s: %v", m.target, err)
+		}
+	}
+
+	// switch to the new root, leaving the initramfs mounted beneath it
+	if err := os.Chdir("/root"); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount(".", "/", "", syscall.MS_MOVE, ""); err != nil {
+		return nil, fmt.Errorf("failed to move the root filesystem: %v", err)
+	}
+	if err := syscall.Chroot("."); err != nil {
+		return nil, err
+	}
+	if err := os.Chdir("/"); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{"/dev/pts", "/dev/shm"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := syscall.Mount("devpts", "/dev/pts", "devpts", 0, "newinstance,ptmxmode=0666"); err != nil {
+		return nil, fmt.Errorf("failed to mount /dev/pts: %v", err)
+	}
+	if err := syscall.Mount("shm", "/dev/shm", "tmpfs", 0, "mode=1777"); err != nil {
+		return nil, fmt.Errorf("failed to mount /dev/shm: %v", err)
+	}
+
+	if err := configureNetwork(); err != nil {
+		return nil, fmt.Errorf("failed to configure the network: %v", err)
+	}
+	return port, nil
+}
+
+// openPort opens the virtio serial port with the name, waiting for the device
+// to appear.
+func openPort(name string) (*os.File, error) {
+	deadline := time.Now().Add(bootTimeout)
+	for {
+		paths, _ := filepath.Glob("/sys/class/virtio-ports/*/name")
+		for _, p := range paths {
+			b, err := ioutil.ReadFile(p)
+			if err != nil || strings.TrimSpace(string(b)) != name {
+				continue
+			}
+			return os.OpenFile(filepath.Join("/dev", filepath.Base(filepath.Dir(p))), os.O_RDWR, 0)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to find the %s port", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// configureNetwork brings up the loopback and the VM's interface on qemu's
+// user mode network.
+func configureNetwork() error {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetUp(lo); err != nil {
+		return err
+	}
+
+	eth, err := netlink.LinkByName("eth0")
+	if err != nil {
+		return err
+	}
+	addr, err := netlink.ParseAddr(guestAddress)
+	if err != nil {
+		return err
+	}
+	if err := netlink.AddrAdd(eth, addr); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetUp(eth); err != nil {
+		return err
+	}
+	gateway, err := netlink.ParseAddr(guestGateway + "/32")
+	if err != nil {
+		return err
+	}
+	return netlink.RouteAdd(&netlink.Route{LinkIndex: eth.Attrs().Index, Gw: gateway.IP})
+}
+
+// process is a process the agent started.
+type process struct {
+	pid    int
+	done   bool
+	status syscall.WaitStatus
+	output sync.WaitGroup
+}
+
+// agent handles the host's requests within the VM.
+type agent struct {
+	conn      io.ReadWriter
+	encoder   *json.Encoder
+	sendMutex sync.Mutex
+
+	// reapAll is set when the agent is the VM's init, so that it reaps every
+	// process orphaned to it rather than only those it started.
+	reapAll bool
+
+	processes map[string]*process
+	exited    chan struct{}
+	mutex     sync.Mutex
+}
+
+// newAgent returns an agent which serves the requests sent over the
+// connection.
+func newAgent(conn io.ReadWriter) *agent {
+	return &agent{
+		conn:      conn,
+		encoder:   json.NewEncoder(conn),
+		processes: make(map[string]*process),
+		exited:    make(chan struct{}),
+	}
+}
+
+// serve handles requests until the connection is closed.
+func (a *agent) serve() {
+	if a.reapAll {
+		go a.reap()
+	}
+	scanner := bufio.NewScanner(a.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		req := new(request)
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			continue
+		}
+		go a.handle(req)
+	}
+}
+
+// send sends the message to the host.
+func (a *agent) send(msg *message) {
+	a.sendMutex.Lock()
+	defer a.sendMutex.Unlock()
+	a.encoder.Encode(msg)
+}
+
+// handle handles the request and responds to it.
+func (a *agent) handle(req *request) {
+	resp := &message{ID: req.ID}
+	var err error
+	switch req.Op {
+	case opPing:
+	case opHostname:
+		err = syscall.Sethostname([]byte(req.Hostname))
+	case opStart:
+		err = a.start(req)
+	case opSignal:
+		err = a.signal(req.Name, syscall.Signal(req.Signal))
+	case opStatus:
+		resp.Statuses = a.statuses()
+	case opWait:
+		a.wait()
+	default:
+		err = fmt.Errorf("unknown request %q", req.Op)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	a.send(resp)
+}
+
+// start starts the requested process.
+func (a *agent) start(req *request) error {
+	if req.Name == "" || len(req.Command) == 0 {
+		return fmt.Errorf("a name and command must be given")
+	}
+	path, err := lookPath(req.Command[0], req.Env)
+	if err != nil {
+		return err
+	}
+	credential, err := lookupCredential(req.User, req.Group)
+	if err != nil {
+		return err
+	}
+	cmd := &exec.Cmd{
+		Path: path,
+		Args: req.Command,
+		Env:  req.Env,
+		Dir:  req.WorkingDirectory,
+		SysProcAttr: &syscall.SysProcAttr{
+			Credential: credential,
+			Setsid:     true,
+		},
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, ok := a.processes[req.Name]; ok {
+		return fmt.Errorf("a process named %q already exists", req.Name)
+	}
+	p := new(process)
+
+	// the output is forwarded from pipes, which are closed once the process
+	// is started so that only it holds their write ends
+	var writers []*os.File
+	defer func() {
+		for _, w := range writers {
+			w.Close()
+		}
+	}()
+	for _, o := range []struct {
+		forward bool
+		stream  string
+	}{{req.Stdout, "stdout"}, {req.Stderr, "stderr"}} {
+		var w *os.File
+		if o.forward {
+			r, pw, err := os.Pipe()
+			if err != nil {
+				return err
+			}
+			w = pw
+			p.output.Add(1)
+			go a.forward(req.Name, o.stream, r, &p.output)
+		} else if w, err = os.OpenFile(os.DevNull, os.O_WRONLY, 0); err != nil {
+			return err
+		}
+		writers = append(writers, w)
+		if o.stream == "stdout" {
+			cmd.Stdout = w
+		} else {
+			cmd.Stderr = w
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.pid = cmd.Process.Pid
+	a.processes[req.Name] = p
+	if !a.reapAll {
+		go func() {
+			var status syscall.WaitStatus
+			if _, err := syscall.Wait4(p.pid, &status, 0, nil); err == nil {
+				a.exit(p.pid, status)
+			}
+		}()
+	}
+	return nil
+}
+
+// forward sends the output read from the pipe to the host.
+func (a *agent) forward(name, stream string, r *os.File, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer r.Close()
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+			a.send(&message{Name: name, Stream: stream, Data: data})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// reap reaps every child which exits, as the init of the VM must.
+func (a *agent) reap() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGCHLD)
+	for range signals {
+		for {
+			var status syscall.WaitStatus
+			pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+			if err != nil || pid <= 0 {
+				break
+			}
+			a.exit(pid, status)
+		}
+	}
+}
+
+// exit records the exit of the process with the pid, once its output is sent,
+// and wakes anything waiting on a process to exit. Pids of processes the agent
+// didn't start are ignored.
+func (a *agent) exit(pid int, status syscall.WaitStatus) {
+	a.mutex.Lock()
+	var p *process
+	for _, proc := range a.processes {
+		if proc.pid == pid && !proc.done {
+			p = proc
+		}
+	}
+	a.mutex.Unlock()
+	if p == nil {
+		return
+	}
+
+	go func() {
+		flushed := make(chan struct{})
+		go func() {
+			p.output.Wait()
+			close(flushed)
+		}()
+		select {
+		case <-flushed:
+		case <-time.After(outputTimeout):
+		}
+
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		p.done = true
+		p.status = status
+		close(a.exited)
+		a.exited = make(chan struct{})
+	}()
+}
+
+// signal sends the signal to the named process if it is still running.
+func (a *agent) signal(name string, sig syscall.Signal) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	p, ok := a.processes[name]
+	if !ok {
+		return fmt.Errorf("no process named %q exists", name)
+	}
+	if p.done {
+		return nil
+	}
+	return syscall.Kill(p.pid, sig)
+}
+
+// statuses returns the status of each process.
+func (a *agent) statuses() map[string]string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	statuses := make(map[string]string, len(a.processes))
+	for name, p := range a.processes {
+		statuses[name] = formatStatus(p.done, p.status)
+	}
+	return statuses
+}
+
+// wait blocks until a process exits, returning immediately if none are
+// running.
+func (a *agent) wait() {
+	a.mutex.Lock()
+	running := false
+	for _, p := range a.processes {
+		if !p.done {
+			running = true
+		}
+	}
+	exited := a.exited
+	a.mutex.Unlock()
+	if running {
+		<-exited
+	}
+}
+
+// lookPath finds the command within the PATH of the process's environment.
+func lookPath(file string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+	path := defaultPath
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			path = e[len("PATH="):]
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		p := filepath.Join(dir, file)
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s was not found within the PATH", file)
+}
+
+// lookupCredential returns the credential the process is run with for the user
+// and group, which may be names or IDs. The group defaults to the user's
+// primary group. If neither are given, the process runs as the agent does.
+func lookupCredential(user, group string) (*syscall.Credential, error) {
+	if user == "" && group == "" {
+		return nil, nil
+	}
+	credential := &syscall.Credential{}
+	if user != "" {
+		fields, err := lookupEntry("/etc/passwd", user, 4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user %q: %v", user, err)
+		}
+		credential.Uid = fields[2]
+		credential.Gid = fields[3]
+	}
+	if group != "" {
+		fields, err := lookupEntry("/etc/group", group, 3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up group %q: %v", group, err)
+		}
+		credential.Gid = fields[2]
+	}
+	return credential, nil
+}
+
+// lookupEntry returns the IDs of the entry within the passwd or group formatted
+// file which has the name or ID. Only the fields needed are parsed, and fields
+// which aren't IDs are returned as 0.
+func lookupEntry(path, name string, fields int) ([]uint32, error) {
+	id, idErr := strconv.ParseUint(name, 10, 32)
+	b, err := ioutil.ReadFile(path)
+	if err != nil && idErr != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) < fields {
+			continue
+		}
+		ids := make([]uint32, fields)
+		for i := 2; i < fields; i++ {
+			n, err := strconv.ParseUint(parts[i], 10, 32)
+			if err != nil {
+				continue
+			}
+			ids[i] = uint32(n)
+		}
+		if parts[0] == name || (idErr == nil && uint64(ids[2]) == id) {
+			return ids, nil
+		}
+	}
+	if idErr == nil {
+		// numeric IDs needn't exist within the file
+		ids := make([]uint32, fields)
+		for i := 2; i < fields; i++ {
+			ids[i] = uint32(id)
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("no such entry")
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// cpioEntry is a file within the initramfs.
+type cpioEntry struct {
+	name  string
+	mode  uint32
+	size  int64
+	major uint32
+	minor uint32
+	data  io.Reader
+}
+
+const (
+	cpioDirectory = 0040000
+	cpioRegular   = 0100000
+	cpioCharacter = 0020000
+)
+
+// writeInitramfs writes the initramfs the VM boots with to the path, which
+// holds the agent as its init along with the directories it mounts the VM's
+// filesystems on.
+func writeInitramfs(path, agent string) error {
+	af, err := os.Open(agent)
+	if err != nil {
+		return err
+	}
+	defer af.Close()
+	fi, err := af.Stat()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	entries := []cpioEntry{
+		{name: "dev", mode: cpioDirectory | 0755},
+		{name: "dev/console", mode: cpioCharacter | 0600, major: 5, minor: 1},
+		{name: "proc", mode: cpioDirectory | 0755},
+		{name: "sys", mode: cpioDirectory | 0755},
+		{name: "root", mode: cpioDirectory | 0755},
+		{name: "init", mode: cpioRegular | 0755, size: fi.Size(), data: af},
+	}
+	if err := writeCpio(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeCpio writes the entries as a cpio archive in the "newc" format the
+// kernel unpacks initramfs images from.
+func writeCpio(w io.Writer, entries []cpioEntry) error {
+	entries = append(entries, cpioEntry{name: "TRAILER!!!"})
+	for i, e := range entries {
+		hdr := fmt.Sprintf("070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			i+1, e.mode, 0, 0, 1, 0, e.size, 0, 0, e.major, e.minor, len(e.name)+1, 0)
+		if _, err := io.WriteString(w, hdr+e.name+"\x00"+cpioPadding(len(hdr)+len(e.name)+1)); err != nil {
+			return err
+		}
+		if e.size == 0 {
+			continue
+		}
+		n, err := io.CopyN(w, e.data, e.size)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %v", e.name, err)
+		}
+		if _, err := io.WriteString(w, cpioPadding(int(n))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cpioPadding returns the padding which aligns what follows a header or file
+// of the length to four bytes.
+func cpioPadding(n int) string {
+	return "\x00\x00\x00"[:(4-n%4)%4]
+}
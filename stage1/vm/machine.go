@@ -0,0 +1,294 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errStopped is returned for requests made once the Machine is stopped.
+var errStopped = errors.New("client is being shut down.")
+
+// Machine is a running VM, through which its agent is sent requests. It
+// implements stage3's client.Client.
+type Machine struct {
+	root string
+	pid  int
+
+	conn    io.ReadWriteCloser
+	writer  *json.Encoder
+	pending map[uint64]chan *message
+	nextID  uint64
+	broken  error
+
+	// outputs are the paths on the host each process's stdout and stderr are
+	// written to, and files the files opened for them.
+	outputs map[string][2]string
+	files   map[string]*os.File
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+	mutex    sync.Mutex
+}
+
+// newMachine returns a Machine talking to the agent over the connection, which
+// writes the processes' output within the root filesystem.
+func newMachine(conn io.ReadWriteCloser, root string, pid int) *Machine {
+	m := &Machine{
+		root:    root,
+		pid:     pid,
+		conn:    conn,
+		writer:  json.NewEncoder(conn),
+		pending: make(map[uint64]chan *message),
+		outputs: make(map[string][2]string),
+		files:   make(map[string]*os.File),
+		stopped: make(chan struct{}),
+	}
+	go m.read()
+	return m
+}
+
+// read dispatches the agent's messages until the connection fails, after which
+// all requests fail.
+func (m *Machine) read() {
+	scanner := bufio.NewScanner(m.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		msg := new(message)
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			continue
+		}
+		if msg.ID == 0 {
+			m.writeOutput(msg)
+			continue
+		}
+		m.mutex.Lock()
+		ch := m.pending[msg.ID]
+		delete(m.pending, msg.ID)
+		m.mutex.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	m.mutex.Lock()
+	m.broken = fmt.Errorf("the connection to the VM's agent failed: %v", err)
+	for id, ch := range m.pending {
+		close(ch)
+		delete(m.pending, id)
+	}
+	m.mutex.Unlock()
+}
+
+// writeOutput writes the output of a process to the file on the host its
+// stream is written to.
+func (m *Machine) writeOutput(msg *message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	paths, ok := m.outputs[msg.Name]
+	if !ok {
+		return
+	}
+	path := paths[0]
+	if msg.Stream == "stderr" {
+		path = paths[1]
+	}
+	if path == "" {
+		return
+	}
+	f := m.files[path]
+	if f == nil {
+		var err error
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+			return
+		}
+		m.files[path] = f
+	}
+	f.Write(msg.Data)
+}
+
+// request sends the request to the agent and waits for its response. A timeout
+// of 0 waits for as long as the agent takes.
+func (m *Machine) request(req *request, timeout time.Duration) (*message, error) {
+	ch := make(chan *message, 1)
+	m.mutex.Lock()
+	if m.Stopped() {
+		m.mutex.Unlock()
+		return nil, errStopped
+	}
+	if m.broken != nil {
+		m.mutex.Unlock()
+		return nil, m.broken
+	}
+	m.nextID++
+	req.ID = m.nextID
+	m.pending[req.ID] = ch
+	err := m.writer.Encode(req)
+	m.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			return nil, m.broken
+		}
+		if msg.Error != "" {
+			return nil, errors.New(msg.Error)
+		}
+		return msg, nil
+	case <-expired:
+		m.mutex.Lock()
+		delete(m.pending, req.ID)
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("timeout (%v) waiting for the VM's agent to respond.", timeout)
+	case <-m.stopped:
+		return nil, errStopped
+	}
+}
+
+// Chroot isn't supported, since the agent has already made the container's
+// filesystem the VM's root.
+func (m *Machine) Chroot(dir string, timeout time.Duration) error {
+	return fmt.Errorf("chroot is not supported within a VM")
+}
+
+func (m *Machine) SetHostname(hostname string, timeout time.Duration) error {
+	_, err := m.request(&request{Op: opHostname, Hostname: hostname}, timeout)
+	return err
+}
+
+// Exec isn't supported, since the agent must remain the VM's init.
+func (m *Machine) Exec(command []string, env []string, stdout string, stderr string, timeout time.Duration) error {
+	return fmt.Errorf("exec is not supported within a VM")
+}
+
+// Start starts the process within the VM. The stdout and stderr are paths
+// within the root filesystem, which are written to on the host with what the
+// process outputs. Processes can't be given a stdin, and the capabilities and
+// label aren't applied, as the VM rather than the process is confined.
+func (m *Machine) Start(
+	name string, command []string, workingDirectory string, env []string,
+	stdin, stdout, stderr, user, group string, capabilities []int,
+	label string, timeout time.Duration,
+) error {
+	if stdin != "" {
+		return fmt.Errorf("processes within a VM can't be given a stdin")
+	}
+	var paths [2]string
+	for i, p := range []string{stdout, stderr} {
+		if p != "" {
+			paths[i] = filepath.Join(m.root, p)
+		}
+	}
+	m.mutex.Lock()
+	if _, ok := m.outputs[name]; ok {
+		m.mutex.Unlock()
+		return fmt.Errorf("a process named %q already exists", name)
+	}
+	m.outputs[name] = paths
+	m.mutex.Unlock()
+
+	_, err := m.request(&request{
+		Op:               opStart,
+		Name:             name,
+		Command:          command,
+		WorkingDirectory: workingDirectory,
+		Env:              env,
+		User:             user,
+		Group:            group,
+		Stdout:           stdout != "",
+		Stderr:           stderr != "",
+	}, timeout)
+	if err != nil {
+		m.mutex.Lock()
+		delete(m.outputs, name)
+		m.mutex.Unlock()
+	}
+	return err
+}
+
+// Mount isn't supported, since the VM only has the container's filesystem.
+func (m *Machine) Mount(source, destination, fstype string, flags uintptr, data string, timeout time.Duration) error {
+	return fmt.Errorf("mounting is not supported within a VM")
+}
+
+func (m *Machine) Signal(name string, signal syscall.Signal, timeout time.Duration) error {
+	_, err := m.request(&request{Op: opSignal, Name: name, Signal: int(signal)}, timeout)
+	return err
+}
+
+// Pid returns the pid of qemu, as the processes within the VM have no pid on
+// the host.
+func (m *Machine) Pid() int {
+	return m.pid
+}
+
+func (m *Machine) Status(timeout time.Duration) (map[string]string, error) {
+	msg, err := m.request(&request{Op: opStatus}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Statuses == nil {
+		return map[string]string{}, nil
+	}
+	return msg.Statuses, nil
+}
+
+// Stop disconnects from the agent, failing any requests which are waiting. The
+// VM itself keeps running until qemu is killed.
+func (m *Machine) Stop() {
+	m.stopOnce.Do(func() {
+		m.mutex.Lock()
+		close(m.stopped)
+		for _, f := range m.files {
+			f.Close()
+		}
+		m.files = nil
+		m.mutex.Unlock()
+		m.conn.Close()
+	})
+}
+
+func (m *Machine) Stopped() bool {
+	select {
+	case <-m.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until a process within the VM exits, returning immediately if
+// none are running.
+func (m *Machine) Wait(timeout time.Duration) error {
+	_, err := m.request(&request{Op: opWait}, timeout)
+	return err
+}
+
+// WaitForSocket waits for the agent to respond, which it does once the VM has
+// booted.
+func (m *Machine) WaitForSocket(timeout time.Duration) error {
+	_, err := m.request(&request{Op: opPing}, timeout)
+	return err
+}
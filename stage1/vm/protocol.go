@@ -0,0 +1,69 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package vm
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// The operations the host requests of the agent.
+const (
+	opPing     = "ping"
+	opHostname = "hostname"
+	opStart    = "start"
+	opSignal   = "signal"
+	opStatus   = "status"
+	opWait     = "wait"
+)
+
+// request is sent from the host to the agent, one JSON object per line. The
+// agent handles each request as it arrives, and responds with the request's ID
+// once it is done, so a wait doesn't hold up the requests after it.
+type request struct {
+	ID uint64 `json:"id"`
+	Op string `json:"op"`
+
+	// Name names the process being started or signaled, and Hostname is the
+	// VM's new hostname.
+	Name     string `json:"name,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+
+	// The process to start. Its output is only sent to the host if Stdout or
+	// Stderr are set, and is otherwise discarded.
+	Command          []string `json:"command,omitempty"`
+	WorkingDirectory string   `json:"working_directory,omitempty"`
+	Env              []string `json:"env,omitempty"`
+	User             string   `json:"user,omitempty"`
+	Group            string   `json:"group,omitempty"`
+	Stdout           bool     `json:"stdout,omitempty"`
+	Stderr           bool     `json:"stderr,omitempty"`
+
+	Signal int `json:"signal,omitempty"`
+}
+
+// message is sent from the agent to the host. It is either the response to the
+// request with the ID, or output written by the named process to the stream
+// if the ID is 0.
+type message struct {
+	ID       uint64            `json:"id,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Statuses map[string]string `json:"statuses,omitempty"`
+
+	Name   string `json:"name,omitempty"`
+	Stream string `json:"stream,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// formatStatus describes the process's status in the form initd does.
+func formatStatus(done bool, status syscall.WaitStatus) string {
+	switch {
+	case !done:
+		return "running"
+	case status.Exited():
+		return fmt.Sprintf("exited(%d)", status.ExitStatus())
+	case status.Signaled():
+		return fmt.Sprintf("signaled(%d)", int(status.Signal()))
+	}
+	return "unknown"
+}
@@ -0,0 +1,273 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package vm boots a minimal virtual machine with qemu and KVM to run a
+// container's apps, for containers which need to be isolated from the host by a
+// hypervisor rather than by namespaces. The container's root filesystem is
+// shared with the VM over 9p, and a small agent is run as the VM's init from an
+// initramfs, which mounts it as its root and runs the apps on the host's
+// behalf. The host talks to the agent over a virtio serial port, through which
+// the agent also sends the apps' output.
+//
+// The Machine implements the same client interface as stage3's initd, so that
+// the container's apps are started, signaled, and waited on as they are within
+// a namespaced container.
+package vm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	client2 "github.com/apcera/kurma/stage2/client"
+)
+
+const (
+	// DefaultMemory and DefaultCPUs are the memory, in megabytes, and the
+	// number of CPUs a VM is given unless configured otherwise.
+	DefaultMemory = 256
+	DefaultCPUs   = 1
+
+	// defaultQemu is the qemu binary looked up within the PATH if no path is
+	// configured.
+	defaultQemu = "qemu-system-x86_64"
+
+	// bootTimeout is how long the VM is given to boot and for its agent to
+	// respond.
+	bootTimeout = 30 * time.Second
+
+	// The tags the root filesystem and the agent's port are given, which the
+	// agent finds them by.
+	rootTag   = "kurma.root"
+	agentPort = "kurma.agent"
+
+	// guestAddress and guestGateway are the address of the VM and of qemu's
+	// gateway on the network qemu's user mode networking gives it.
+	guestAddress = "10.0.2.15/24"
+	guestGateway = "10.0.2.2"
+)
+
+// Config configures how VMs are booted.
+type Config struct {
+	// Qemu is the path to the qemu binary, which is looked up within the PATH
+	// if it is empty.
+	Qemu string
+
+	// Kernel is the path to the kernel the VMs boot, which must have the
+	// virtio, 9p, and devtmpfs support built in.
+	Kernel string
+
+	// Memory is the memory each VM is given in megabytes, and CPUs the number
+	// of CPUs.
+	Memory int
+	CPUs   int
+
+	// Agent is the path to the binary run as the init of each VM. It must be a
+	// statically linked kurma binary, and defaults to the running binary.
+	Agent string
+}
+
+// Validate checks the VMs can be booted on the host, and fills in the paths
+// which were left empty.
+func (cfg *Config) Validate() error {
+	if cfg.Kernel == "" {
+		return fmt.Errorf("a kernel must be configured to boot VMs")
+	}
+	if _, err := os.Stat(cfg.Kernel); err != nil {
+		return fmt.Errorf("failed to find the VM kernel: %v", err)
+	}
+	if cfg.Qemu == "" {
+		path, err := exec.LookPath(defaultQemu)
+		if err != nil {
+			return fmt.Errorf("failed to find %s: %v", defaultQemu, err)
+		}
+		cfg.Qemu = path
+	}
+	if cfg.Agent == "" {
+		self, err := os.Readlink("/proc/self/exe")
+		if err != nil {
+			return err
+		}
+		cfg.Agent = self
+	}
+	if cfg.Memory < 0 || cfg.CPUs < 0 {
+		return fmt.Errorf("the VM memory and CPUs must not be negative")
+	}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		return fmt.Errorf("KVM is not available on the host: %v", err)
+	}
+	return nil
+}
+
+// Port is a port forwarded to the same port within the VM.
+type Port struct {
+	Protocol string
+	Port     int
+}
+
+// BootOptions are the settings for an individual VM.
+type BootOptions struct {
+	// Root is the root filesystem shared with the VM, and ReadOnly whether the
+	// VM may only read it.
+	Root     string
+	ReadOnly bool
+
+	// Directory is where the VM's initramfs, console log, and agent socket are
+	// kept.
+	Directory string
+
+	// Hostname is the VM's hostname.
+	Hostname string
+
+	// Ports are forwarded to the VM from qemu's network namespace. If
+	// NewNetworkNamespace is set, qemu is run in a network namespace of its
+	// own, which may then be connected to the container network.
+	Ports               []Port
+	NewNetworkNamespace bool
+
+	// Taskfiles are the cgroup tasks files qemu is added to.
+	Taskfiles []string
+}
+
+// Boot boots a VM, returning once its agent responds.
+func Boot(cfg *Config, opts *BootOptions) (*Machine, error) {
+	if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+		return nil, err
+	}
+	initramfs := filepath.Join(opts.Directory, "initramfs")
+	if err := writeInitramfs(initramfs, cfg.Agent); err != nil {
+		return nil, fmt.Errorf("failed to write the initramfs: %v", err)
+	}
+
+	// qemu connects to the agent's socket as it starts
+	socket := filepath.Join(opts.Directory, "agent.sock")
+	os.Remove(socket)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(socket)
+	defer l.Close()
+
+	console, err := os.OpenFile(filepath.Join(opts.Directory, "console.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer console.Close()
+
+	pidfile := filepath.Join(opts.Directory, "qemu.pid")
+	launcher := &client2.Launcher{
+		NewNetworkNamespace: opts.NewNetworkNamespace,
+		Taskfiles:           opts.Taskfiles,
+		Detach:              true,
+		MaxOpenFiles:        4096,
+		Stdout:              console,
+		Stderr:              console,
+	}
+	p, err := launcher.Run(qemuArgs(cfg, opts, initramfs, socket, pidfile)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start qemu: %v", err)
+	}
+	if _, err := p.Wait(); err != nil {
+		return nil, err
+	}
+
+	l.SetDeadline(time.Now().Add(bootTimeout))
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("qemu did not connect to the agent's socket: %v", err)
+	}
+	pid, err := readPidfile(pidfile, bootTimeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	m := newMachine(conn, opts.Root, pid)
+	if err := m.WaitForSocket(bootTimeout); err != nil {
+		m.Stop()
+		return nil, fmt.Errorf("the VM's agent did not respond: %v", err)
+	}
+	if opts.Hostname != "" {
+		if err := m.SetHostname(opts.Hostname, time.Second); err != nil {
+			m.Stop()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// readPidfile returns the pid qemu writes to the file, waiting for it to be
+// written.
+func readPidfile(path string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		b, err := ioutil.ReadFile(path)
+		if err == nil && len(b) > 0 && b[len(b)-1] == '\n' {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				return 0, fmt.Errorf("invalid qemu pid file: %v", err)
+			}
+			return pid, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("qemu did not write its pid file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// qemuArgs returns the command qemu is run with.
+func qemuArgs(cfg *Config, opts *BootOptions, initramfs, socket, pidfile string) []string {
+	memory, cpus := cfg.Memory, cfg.CPUs
+	if memory == 0 {
+		memory = DefaultMemory
+	}
+	if cpus == 0 {
+		cpus = DefaultCPUs
+	}
+
+	cmdline := "console=ttyS0 panic=-1 quiet " + agentEnv + "=1"
+	fsdev := "local,id=root,security_model=passthrough,path=" + escapeOption(opts.Root)
+	if opts.ReadOnly {
+		cmdline += " " + readOnlyEnv + "=1"
+		fsdev += ",readonly"
+	}
+	netdev := "user,id=net0"
+	for _, p := range opts.Ports {
+		netdev += fmt.Sprintf(",hostfwd=%s::%d-:%d", p.Protocol, p.Port, p.Port)
+	}
+
+	return []string{
+		cfg.Qemu,
+		"-enable-kvm", "-cpu", "host",
+		"-m", strconv.Itoa(memory),
+		"-smp", strconv.Itoa(cpus),
+		"-nodefaults", "-no-user-config", "-no-reboot",
+		"-display", "none", "-serial", "stdio",
+		"-pidfile", pidfile,
+		"-kernel", cfg.Kernel,
+		"-initrd", initramfs,
+		"-append", cmdline,
+		"-fsdev", fsdev,
+		"-device", "virtio-9p-pci,fsdev=root,mount_tag=" + rootTag,
+		"-chardev", "socket,id=agent,path=" + escapeOption(socket),
+		"-device", "virtio-serial-pci",
+		"-device", "virtserialport,chardev=agent,name=" + agentPort,
+		"-netdev", netdev,
+		"-device", "virtio-net-pci,netdev=net0",
+	}
+}
+
+// escapeOption escapes a value within one of qemu's comma separated options.
+func escapeOption(s string) string {
+	return strings.Replace(s, ",", ",,", -1)
+}
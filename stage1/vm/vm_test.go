@@ -0,0 +1,123 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package vm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestQemuArgs(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	cfg := &Config{Qemu: "/usr/bin/qemu", Kernel: "/boot/vmlinuz"}
+	opts := &BootOptions{
+		Root:     "/var/lib/kurma/a,b/rootfs",
+		ReadOnly: true,
+		Ports:    []Port{{Protocol: "tcp", Port: 80}},
+	}
+	args := strings.Join(qemuArgs(cfg, opts, "/vm/initramfs", "/vm/agent.sock", "/vm/qemu.pid"), " ")
+
+	TestEqual(t, strings.HasPrefix(args, "/usr/bin/qemu -enable-kvm"), true)
+	TestEqual(t, strings.Contains(args, "-m 256 -smp 1 "), true)
+	TestEqual(t, strings.Contains(args, "-append console=ttyS0 panic=-1 quiet KURMA_VM_AGENT=1 KURMA_VM_READONLY=1 "), true)
+	TestEqual(t, strings.Contains(args, "path=/var/lib/kurma/a,,b/rootfs,readonly "), true)
+	TestEqual(t, strings.Contains(args, "-netdev user,id=net0,hostfwd=tcp::80-:80 "), true)
+	TestEqual(t, strings.Contains(args, "name=kurma.agent "), true)
+}
+
+func TestWriteCpio(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	var b bytes.Buffer
+	TestExpectSuccess(t, writeCpio(&b, []cpioEntry{
+		{name: "dev", mode: cpioDirectory | 0755},
+		{name: "init", mode: cpioRegular | 0755, size: 5, data: strings.NewReader("hello")},
+	}))
+
+	// each header is 110 bytes, and names and data are padded to four bytes
+	out := b.String()
+	TestEqual(t, b.Len()%4, 0)
+	TestEqual(t, out[:6], "070701")
+	TestEqual(t, out[110:114], "dev\x00")
+	init := out[116:]
+	TestEqual(t, init[:14], "07070100000002")
+	TestEqual(t, init[110:115], "init\x00")
+	TestEqual(t, init[116:121], "hello")
+	TestEqual(t, strings.Contains(out, "TRAILER!!!\x00"), true)
+}
+
+func TestLookupEntry(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path := filepath.Join(TempDir(t), "passwd")
+	TestExpectSuccess(t, ioutil.WriteFile(path, []byte("root:x:0:0::/root:/bin/sh\nweb:x:1000:100::/home/web:/bin/sh\n"), 0644))
+
+	ids, err := lookupEntry(path, "web", 4)
+	TestExpectSuccess(t, err)
+	TestEqual(t, ids[2:], []uint32{1000, 100})
+
+	ids, err = lookupEntry(path, "1000", 4)
+	TestExpectSuccess(t, err)
+	TestEqual(t, ids[2:], []uint32{1000, 100})
+
+	// IDs which aren't within the file are used as they are
+	ids, err = lookupEntry(path, "2000", 4)
+	TestExpectSuccess(t, err)
+	TestEqual(t, ids[2:], []uint32{2000, 2000})
+
+	_, err = lookupEntry(path, "missing", 4)
+	TestExpectError(t, err)
+}
+
+func TestMachineAgent(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	root := TempDir(t)
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(root, "app.stdout"), nil, 0644))
+
+	host, guest := net.Pipe()
+	go newAgent(guest).serve()
+	m := newMachine(host, root, 42)
+	defer m.Stop()
+
+	TestExpectSuccess(t, m.WaitForSocket(time.Second))
+	TestEqual(t, m.Pid(), 42)
+
+	// Nothing is running, so waiting returns immediately.
+	TestExpectSuccess(t, m.Wait(time.Second))
+
+	env := []string{"PATH=/usr/bin:/bin"}
+	TestExpectSuccess(t, m.Start("app", []string{"sh", "-c", "echo hello; exit 3"}, "/", env,
+		"", "/app.stdout", "", "", "", nil, "", time.Second))
+	TestExpectError(t, m.Start("app", []string{"true"}, "/", env, "", "", "", "", "", nil, "", time.Second))
+	TestExpectError(t, m.Start("stdin", []string{"true"}, "/", env, "/app.stdin", "", "", "", "", nil, "", time.Second))
+
+	TestExpectSuccess(t, m.Wait(5*time.Second))
+	statuses, err := m.Status(time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, statuses, map[string]string{"app": "exited(3)"})
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "app.stdout"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "hello\n")
+
+	// Signaling an exited process does nothing, but unknown processes fail.
+	TestExpectSuccess(t, m.Signal("app", 15, time.Second))
+	TestExpectError(t, m.Signal("missing", 15, time.Second))
+
+	// Requests fail once the agent's connection is gone.
+	guest.Close()
+	TestExpectError(t, m.WaitForSocket(time.Second))
+}
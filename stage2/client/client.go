@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/apcera/util/str"
@@ -48,6 +49,14 @@ type Launcher struct {
 	MaxOpenFiles int
 	MaxProcesses int
 
+	// Capabilities lists the numbers of the capabilities the process is limited
+	// to. If it is nil, the process keeps all of them.
+	Capabilities []int
+
+	// ExecLabel is written to /proc/self/attr/exec before the command is run,
+	// to confine it by the host's security module.
+	ExecLabel string
+
 	Chroot         bool
 	Detach         bool
 	HostPrivileged bool
@@ -78,10 +87,6 @@ func (l *Launcher) SetNS(pid int) {
 // return the arguments as well as the extra files that need to be passed, such
 // as for its stdin, stdout, and stderr.
 func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
-	// Generate the uid and gid maps for user namespaces
-	// uidmap := fmt.Sprintf("0 %d %d\n", c.manager.namespaceUidOffset, c.manager.namespaceUidMaximum)
-	// gidmap := fmt.Sprintf("0 %d %d\n", c.manager.namespaceGidOffset, c.manager.namespaceGidMaximum)
-
 	// Initialize the options that will be passed to spawn the container.
 	var args []string
 
@@ -120,11 +125,11 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 	if l.UTSNamespace > 0 {
 		args = append(args, "--uts-namespace", nsPath(l.UTSNamespace, "uts"))
 	}
-	// FIXME this needs testing when re-enabling username spaces... without
-	// changing user namespaces, setns on it fails.
-	// if l.UserNamespace > 0 {
-	// 	args = append(args, "--user-namespace", nsPath(l.UserNamespace, "user"))
-	// }
+	// Joining the user namespace the process is already in fails, so callers
+	// should only set it when the target has its own user namespace.
+	if l.UserNamespace > 0 {
+		args = append(args, "--user-namespace", nsPath(l.UserNamespace, "user"))
+	}
 
 	// Add applicalble new namespace flags
 	if l.NewIPCNamespace {
@@ -146,9 +151,9 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 		args = append(args, "--new-user-namespace")
 	}
 
-	// If user namespaces are to be used, then add the parameter to populate it
-	// and the uid and gid maps.
-	if l.UserNamespace > 0 || l.NewUserNamespace {
+	// If a new user namespace is to be created, then add the uid and gid maps
+	// to populate it with.
+	if l.NewUserNamespace {
 		args = append(args, "--uidmap", l.Uidmap)
 		args = append(args, "--gidmap", l.Gidmap)
 	}
@@ -158,6 +163,18 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 		args = append(args, "--host-privileged")
 	}
 
+	// Restrict the capabilities, if they're limited
+	if l.Capabilities != nil {
+		caps := make([]string, len(l.Capabilities))
+		for i, c := range l.Capabilities {
+			caps[i] = strconv.Itoa(c)
+		}
+		args = append(args, "--capabilities", strings.Join(caps, ","))
+	}
+	if l.ExecLabel != "" {
+		args = append(args, "--exec-label", l.ExecLabel)
+	}
+
 	// Loop and append all the cgroups taskfiles the container should be in.
 	for _, f := range l.Taskfiles {
 		args = append(args, "--taskfile", f)
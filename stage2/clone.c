@@ -170,6 +170,18 @@ static void setup_container(clone_destination_data *args, pid_t uidmap_child) {
 		// --------------------------------------------------------------------
 		// Step 11: Drop privledges down to the specified user
 		// --------------------------------------------------------------------
+
+		// The capabilities must be dropped, and the security label set, while
+		// still root.
+		if (args->capabilities != NULL) {
+			DEBUG("Dropping capabilities\n");
+			dropcapabilities(args->capabilities);
+		}
+		if (args->exec_label != NULL) {
+			DEBUG("Setting the security label\n");
+			setexeclabel(args->exec_label);
+		}
+
 		if (args->group != NULL) {
 			int gid = gidforgroup(args->group);
 			if (gid < 0)
@@ -99,6 +99,10 @@ void createroot(char *src, char *dst, bool privileged) {
 		bindnode("/dev/urandom", "dev/urandom");
 		bindnode("/dev/zero", "dev/zero");
 
+		// Create an empty dev/console so a console can be bind mounted onto it.
+		if ((console = open("dev/console", O_WRONLY | O_CREAT, 0600)) >= 0)
+			close(console);
+
 		res = symlink("pts/ptmx", "dev/ptmx");
 		res = symlink("/proc/kcore", "dev/core");
 		res = symlink("/proc/self/fd", "dev/fd");
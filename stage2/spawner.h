@@ -76,6 +76,14 @@ typedef struct clone_destination_data {
 	// Specifies whether the container should be setup in a privileged mode.
 	bool privileged;
 
+	// The comma separated list of capability numbers the command is limited to,
+	// or NULL if it keeps all of them.
+	char *capabilities;
+
+	// The attribute written to /proc/self/attr/exec so the command is confined
+	// by the host's security module, or NULL if it isn't.
+	char *exec_label;
+
 	// Tells the spawner to chroot into the directory.
 	bool chroot;
 
@@ -128,6 +136,8 @@ char *append(char **destination, const char *format, ...);
 char *string(const char *format, ...);
 void spawner_print_time(FILE *fd);
 void writemap(pid_t pid, char *type, char *map);
+void dropcapabilities(char *list);
+void setexeclabel(char *attr);
 void waitforstop(pid_t child);
 void waitforexit(pid_t child);
 int uidforuser(char *user);
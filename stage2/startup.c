@@ -101,6 +101,9 @@ void cspawner(int argc, char **argv) {
 				{"max-open-files", required_argument, 0, 'r'},
 				{"max-processes", required_argument, 0, 's'},
 
+				{"capabilities", required_argument, 0, 't'},
+				{"exec-label", required_argument, 0, 'u'},
+
 				{"detach", no_argument, &detach, 1},
 				{"chroot", no_argument, &chroot, 1},
 				{"host-privileged", no_argument, &privileged, 1},
@@ -109,7 +112,7 @@ void cspawner(int argc, char **argv) {
 		/* getopt_long stores the option index here. */
 		int option_index = 0;
 
-		c = getopt_long(argc, argv, "abcdefghijklmnopqrs", long_options, &option_index);
+		c = getopt_long(argc, argv, "abcdefghijklmnopqrstu", long_options, &option_index);
 
 		/* Detect the end of the options. */
 		if (c == -1)
@@ -197,6 +200,14 @@ void cspawner(int argc, char **argv) {
 			args->group = optarg;
 			break;
 
+			// capabilities
+		case 't':
+			args->capabilities = optarg;
+			break;
+		case 'u':
+			args->exec_label = optarg;
+			break;
+
 			// limits
 		case 'r':
 		  args->max_open_files = atoi(optarg);
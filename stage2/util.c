@@ -9,12 +9,17 @@
 #include <grp.h>
 #include <pwd.h>
 #include <stdarg.h>
+#include <stdint.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include <time.h>
 #include <unistd.h>
 
+#include <linux/capability.h>
+
+#include <sys/prctl.h>
+#include <sys/syscall.h>
 #include <sys/types.h>
 #include <sys/time.h>
 #include <sys/wait.h>
@@ -84,6 +89,66 @@ void writemap(pid_t pid, char *type, char *map) {
 	free(path);
 }
 
+// Limits the process to the comma separated list of capability numbers. The
+// rest are removed from its bounding, inheritable and ambient sets, so the
+// command it execs can't gain them.
+void dropcapabilities(char *list) {
+	struct __user_cap_header_struct header;
+	struct __user_cap_data_struct data[2];
+	uint64_t retain = 0;
+	char *copy, *token, *saveptr, *endptr;
+	long cap;
+
+	copy = string("%s", list);
+	for (token = strtok_r(copy, ",", &saveptr); token != NULL;
+			token = strtok_r(NULL, ",", &saveptr)) {
+		errno = 0;
+		cap = strtol(token, &endptr, 10);
+		if (errno != 0 || *endptr != '\0' || cap < 0 || cap > 63)
+			error(1, 0, "Invalid capability %s", token);
+		retain |= 1ULL << cap;
+	}
+	free(copy);
+
+	// Drop from the bounding set until the kernel reports that there are no
+	// more capabilities.
+	for (cap = 0; cap < 64; cap++) {
+		if (retain & (1ULL << cap))
+			continue;
+		if (prctl(PR_CAPBSET_DROP, cap, 0, 0, 0) < 0) {
+			if (errno == EINVAL)
+				break;
+			error(1, errno, "Failed to drop capability %ld", cap);
+		}
+	}
+
+#ifdef PR_CAP_AMBIENT
+	if (prctl(PR_CAP_AMBIENT, PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0) < 0 && errno != EINVAL)
+		error(1, errno, "Failed to clear ambient capabilities");
+#endif
+
+	header.version = _LINUX_CAPABILITY_VERSION_3;
+	header.pid = 0;
+	if (syscall(SYS_capget, &header, data) < 0)
+		error(1, errno, "capget");
+	data[0].inheritable &= (uint32_t) retain;
+	data[1].inheritable &= (uint32_t) (retain >> 32);
+	if (syscall(SYS_capset, &header, data) < 0)
+		error(1, errno, "capset");
+}
+
+// Writes the attribute to /proc/self/attr/exec so the command the process
+// execs is confined by the security module label it names.
+void setexeclabel(char *attr) {
+	int fd;
+
+	if ((fd = open("/proc/self/attr/exec", O_WRONLY)) < 0)
+		error(1, errno, "Failed to open the exec security label");
+	else if (write(fd, attr, strlen(attr)) != (ssize_t) strlen(attr))
+		error(1, errno, "Failed to set the exec security label");
+	close(fd);
+}
+
 void waitforstop(pid_t child) {
 	int status;
 
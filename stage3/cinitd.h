@@ -146,6 +146,10 @@ void status_request(struct request *r);
 // to "WAIT".
 void wait_request(struct request *r);
 
+// This is called once a request object is found that has a COMMAND element set
+// to "SIGNAL".
+void signal_request(struct request *r);
+
 // ------------------
 // Response handlers.
 // ------------------
@@ -277,10 +281,11 @@ int initd_close(int fd);
 // Sets the given file descriptor as non blocking.
 int initd_setnonblocking(int fd);
 
-// Opens the given files as stdout and stderr, opens /dev/null as stdin, and
-// closes all other open file descriptors. This is used inside of the forked
-// process to safely execute customer code.
-void initd_setup_fds(char *stdout_fn, char *stderr_fn);
+// Opens the given files as stdin, stdout and stderr, and closes all other open
+// file descriptors. If stdin_fn is NULL then /dev/null is used for stdin, and
+// if it is a FIFO then it is opened read only. This is used inside of the
+// forked process to safely execute customer code.
+void initd_setup_fds(char *stdin_fn, char *stdout_fn, char *stderr_fn);
 
 // This will close all fds > 2, so it ignores stdin, stdout, and stderr.
 void close_all_fds();
@@ -302,6 +307,21 @@ int uidforuser2(char *user);
 // all digits.
 int gidforgroup2(char *group);
 
+// Parses the comma separated list of capability numbers into a mask with the
+// bit for each of them set. An empty list results in an empty mask. Returns -1
+// if any of the numbers are invalid.
+int initd_capability_mask(char *list, uint64_t *mask);
+
+// Removes every capability not in the mask from the bounding, inheritable and
+// ambient sets of the calling process, so that the program it execs can't gain
+// them. This requires CAP_SETPCAP, so must be called before changing to a
+// non-root user.
+int initd_drop_capabilities(uint64_t retain);
+
+// Writes the attribute to /proc/self/attr/exec so that the next program the
+// process execs is confined by the security module label it names.
+int initd_set_exec_label(char *attr);
+
 // -------
 // Logging
 // -------
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -30,16 +31,27 @@ type Client interface {
 		command []string, env []string, stdout string, stderr string, timeout time.Duration,
 	) error
 
-	// Starts a given named command within the initd server.
+	// Starts a given named command within the initd server. If stdin is empty,
+	// then the command's stdin will be /dev/null. If capabilities is not nil,
+	// the command is limited to the capabilities with those numbers. If label is
+	// set, it is written to /proc/self/attr/exec to confine the command.
 	Start(
 		name string, command []string, workingDirectory string, env []string,
-		stdout string, stderr, user, group string, timeout time.Duration,
+		stdin, stdout, stderr, user, group string, capabilities []int,
+		label string, timeout time.Duration,
 	) error
 
 	// Mount will perform a mount within the container with the specified
 	// settings.
 	Mount(source, destination, fstype string, flags uintptr, data string, timeout time.Duration) error
 
+	// Sends the signal to the named command if it is still running.
+	Signal(name string, signal syscall.Signal, timeout time.Duration) error
+
+	// Returns the pid of the initd process, as seen from the caller's pid
+	// namespace. It is 0 until a request has been made.
+	Pid() int
+
 	// Returns the status of all named commands in the container.
 	Status(timeout time.Duration) (map[string]string, error)
 
@@ -75,6 +87,10 @@ type client struct {
 
 	// Protect Stop() and ONLY Stop()
 	stopMutex sync.Mutex
+
+	// The pid of the initd process, recorded from the first connection to it.
+	pid      int
+	pidMutex sync.Mutex
 }
 
 // Creates a new client object that uses the given socket file. If the file does
@@ -114,6 +130,10 @@ func (c *client) request(request [][]string, timeout time.Duration) ([]string, e
 	}
 	defer conn.Close()
 
+	// Getting the peer's credentials puts the connection into blocking mode,
+	// so it is done once the request has been handled.
+	defer c.recordPid(conn)
+
 	// Abandon whatever we're doing and close the request if stop is called
 	done := make(chan struct{})
 	defer close(done)
@@ -325,16 +345,32 @@ func (c *client) Exec(
 
 // Issues a request to start a new command.
 func (c *client) Start(
-	name string, command []string, workingDirectory string, env []string, stdout string, stderr,
-	user, group string, timeout time.Duration,
+	name string, command []string, workingDirectory string, env []string,
+	stdin, stdout, stderr, user, group string, capabilities []int,
+	label string, timeout time.Duration,
 ) error {
+	stdio := []string{stdout, stderr}
+	if stdin != "" {
+		stdio = append(stdio, stdin)
+	}
+	credentials := []string{user, group}
+	if capabilities != nil {
+		caps := make([]string, len(capabilities))
+		for i, c := range capabilities {
+			caps[i] = strconv.Itoa(c)
+		}
+		credentials = append(credentials, strings.Join(caps, ","))
+	}
 	request := [][]string{
 		[]string{"START", name},
 		command,
 		[]string{workingDirectory},
 		env,
-		[]string{stdout, stderr},
-		[]string{user, group},
+		stdio,
+		credentials,
+	}
+	if label != "" {
+		request = append(request, []string{label})
 	}
 
 	// Make the request.
@@ -375,6 +411,60 @@ func (c *client) Mount(source, destination, fstype string, flags uintptr, data s
 	return nil
 }
 
+// Returns the pid of the initd process.
+func (c *client) Pid() int {
+	c.pidMutex.Lock()
+	defer c.pidMutex.Unlock()
+	return c.pid
+}
+
+// Records the pid of the initd process from the credentials of the peer on the
+// other end of the connection, if it isn't already known.
+func (c *client) recordPid(conn net.Conn) {
+	c.pidMutex.Lock()
+	defer c.pidMutex.Unlock()
+	if c.pid != 0 {
+		return
+	}
+
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+	f, err := uconn.File()
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	cred, err := syscall.GetsockoptUcred(int(f.Fd()), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil {
+		return
+	}
+	c.pid = int(cred.Pid)
+}
+
+// Sends a signal to a named process in the container.
+func (c *client) Signal(name string, signal syscall.Signal, timeout time.Duration) error {
+	request := [][]string{
+		[]string{"SIGNAL", name, strconv.Itoa(int(signal))},
+	}
+
+	// Make the request.
+	response, err := c.request(request, timeout)
+	if err != nil {
+		return err
+	}
+
+	// We expect two lines, ["REQUEST OK", ""]
+	if len(response) != 2 || response[0] != "REQUEST OK" || response[1] != "" {
+		return fmt.Errorf("Invalid response: %#v", response)
+	}
+
+	// Success!
+	return nil
+}
+
 // Get the status of all named processes in the container.
 func (c *client) Status(timeout time.Duration) (map[string]string, error) {
 	// Make the request.
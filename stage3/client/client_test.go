@@ -213,7 +213,7 @@ func TestClient_Start(t *testing.T) {
 	client := New(socketFile)
 	err := client.Start(
 		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
-		"/a", "/b", "123", "456", time.Second,
+		"", "/a", "/b", "123", "456", nil, "", time.Second,
 	)
 	tt.TestExpectSuccess(t, err)
 
@@ -227,6 +227,87 @@ func TestClient_Start(t *testing.T) {
 	tt.TestEqual(t, startContent, expectedRequest)
 }
 
+func TestClient_StartWithStdin(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var startContent string
+	readChan := setupReadRequest(t, l, &startContent, "REQUEST OK\n")
+
+	client := New(socketFile)
+	err := client.Start(
+		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
+		"/c", "/a", "/b", "123", "456", nil, "", time.Second,
+	)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedRequest := "1\n6\n2\n5\nSTART4\necho1\n3\n1231\n3\ndir1\n7\nFOO=bar3\n2\n/a2\n/b2\n/c2\n3\n1233\n456"
+	tt.TestEqual(t, startContent, expectedRequest)
+}
+
+func TestClient_StartWithCapabilities(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var startContent string
+	readChan := setupReadRequest(t, l, &startContent, "REQUEST OK\n")
+
+	client := New(socketFile)
+	err := client.Start(
+		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
+		"", "/a", "/b", "123", "456", []int{0, 10}, "", time.Second,
+	)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedRequest := "1\n6\n2\n5\nSTART4\necho1\n3\n1231\n3\ndir1\n7\nFOO=bar2\n2\n/a2\n/b3\n3\n1233\n4564\n0,10"
+	tt.TestEqual(t, startContent, expectedRequest)
+}
+
+func TestClient_StartWithLabel(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var startContent string
+	readChan := setupReadRequest(t, l, &startContent, "REQUEST OK\n")
+
+	client := New(socketFile)
+	err := client.Start(
+		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
+		"", "/a", "/b", "123", "456", nil, "exec kurma", time.Second,
+	)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedRequest := "1\n7\n2\n5\nSTART4\necho1\n3\n1231\n3\ndir1\n7\nFOO=bar2\n2\n/a2\n/b2\n3\n1233\n4561\n10\nexec kurma"
+	tt.TestEqual(t, startContent, expectedRequest)
+}
+
 func TestClient_Mount(t *testing.T) {
 	tt.StartTest(t)
 	defer tt.FinishTest(t)
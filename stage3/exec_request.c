@@ -74,7 +74,7 @@ void exec_request(struct request *r)
 		close_all_fds();
 
 		// Setup the initial FD's.
-		initd_setup_fds(r->data[3][0], r->data[3][1]);
+		initd_setup_fds(NULL, r->data[3][0], r->data[3][1]);
 
 		// Ensure that we are fully root.
 		if (setregid(0, 0) != 0) { _exit(EX_OSERR); }
@@ -15,7 +15,10 @@
 #include <string.h>
 #include <sysexits.h>
 
+#include <linux/capability.h>
+
 #include <sys/mount.h>
+#include <sys/prctl.h>
 #include <sys/stat.h>
 #include <sys/syscall.h>
 #include <sys/types.h>
@@ -126,12 +129,13 @@ void close_all_fds()
 }
 
 // Documented in cinitd.h
-void initd_setup_fds(char *stdout_fn, char *stderr_fn)
+void initd_setup_fds(char *stdin_fn, char *stdout_fn, char *stderr_fn)
 {
 	int fd;
 	int flags;
 	int flags_dev_null;
 	int mode;
+	struct stat st;
 
 	DEBUG("initd_setup_fds\n");
 
@@ -148,7 +152,16 @@ void initd_setup_fds(char *stdout_fn, char *stderr_fn)
 	mode = 0700;
 
 	// stdin
-	fd = open(_PATH_DEVNULL, O_RDONLY | O_NOFOLLOW, mode);
+	if (stdin_fn == NULL || !strcmp(stdin_fn, _PATH_DEVNULL)) {
+		fd = open(_PATH_DEVNULL, O_RDONLY | O_NOFOLLOW, mode);
+	} else if (lstat(stdin_fn, &st) == 0 && S_ISFIFO(st.st_mode)) {
+		// A FIFO is only read from, so that the process sees EOF once the
+		// writer closes its end rather than holding a write end itself.
+		fd = open(stdin_fn, O_RDONLY | O_NOFOLLOW, mode);
+	} else {
+		// A console is both read from and written to.
+		fd = open(stdin_fn, O_RDWR | O_NOFOLLOW, mode);
+	}
 	if (fd == -1) {
 		ERROR("Exiting, could not open new stdin\n");
 		_exit(EX_OSERR);
@@ -261,4 +274,90 @@ int gidforgroup2(char *group) {
 	return -1;
 }
 
+// Documented in cinitd.h
+int initd_capability_mask(char *list, uint64_t *mask)
+{
+	char *copy, *token, *saveptr, *endptr;
+	long cap;
+	int ret = 0;
+
+	*mask = 0;
+	copy = strdup(list);
+	if (copy == NULL) {
+		return -1;
+	}
+	for (token = strtok_r(copy, ",", &saveptr); token != NULL;
+			token = strtok_r(NULL, ",", &saveptr)) {
+		errno = 0;
+		cap = strtol(token, &endptr, 10);
+		if (errno != 0 || *endptr != '\0' || cap < 0 || cap > 63) {
+			ret = -1;
+			break;
+		}
+		*mask |= 1ULL << cap;
+	}
+	free(copy);
+	return ret;
+}
+
+// Documented in cinitd.h
+int initd_drop_capabilities(uint64_t retain)
+{
+	struct __user_cap_header_struct header;
+	struct __user_cap_data_struct data[2];
+	int cap;
+
+	// Drop from the bounding set until the kernel reports that there are no
+	// more capabilities.
+	for (cap = 0; cap < 64; cap++) {
+		if (retain & (1ULL << cap)) {
+			continue;
+		}
+		if (prctl(PR_CAPBSET_DROP, cap, 0, 0, 0) == -1) {
+			if (errno == EINVAL) {
+				break;
+			}
+			return -1;
+		}
+	}
+
+#ifdef PR_CAP_AMBIENT
+	// Older kernels don't have ambient capabilities at all.
+	if (prctl(PR_CAP_AMBIENT, PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0) == -1 &&
+			errno != EINVAL) {
+		return -1;
+	}
+#endif
+
+	header.version = _LINUX_CAPABILITY_VERSION_3;
+	header.pid = 0;
+	if (syscall(SYS_capget, &header, data) == -1) {
+		return -1;
+	}
+	data[0].inheritable &= (uint32_t) retain;
+	data[1].inheritable &= (uint32_t) (retain >> 32);
+	if (syscall(SYS_capset, &header, data) == -1) {
+		return -1;
+	}
+	return 0;
+}
+
+// Documented in cinitd.h
+int initd_set_exec_label(char *attr)
+{
+	int fd;
+	ssize_t len;
+
+	fd = open("/proc/self/attr/exec", O_WRONLY);
+	if (fd == -1) {
+		return -1;
+	}
+	len = strlen(attr);
+	if (write(fd, attr, len) != len) {
+		initd_close(fd);
+		return -1;
+	}
+	return initd_close(fd);
+}
+
 #endif
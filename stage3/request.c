@@ -248,6 +248,8 @@ static int outer_done(struct request *r)
 		status_request(r);
 	} else if (!strncmp(r->data[0][0], "WAIT", 5)) {
 		wait_request(r);
+	} else if (!strncmp(r->data[0][0], "SIGNAL", 7)) {
+		signal_request(r);
 	} else {
 		// This is an unknown request!
 		ERROR("[%d] Unknown command: %s\n", r->fd, r->data[0][0]);
@@ -0,0 +1,78 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+#ifndef INITD_SERVER_SIGNAL_REQUEST_C
+#define INITD_SERVER_SIGNAL_REQUEST_C
+
+#include <errno.h>
+#include <signal.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+#include <sys/types.h>
+
+#include "cinitd.h"
+
+// Documented in cinitd.h
+void signal_request(struct request *r)
+{
+	struct process *p;
+	char *endptr;
+	long signum;
+
+	// The expected protocol for a SIGNAL statement looks like this:
+	// {
+	//   { "SIGNAL", "<NAME>", "<SIGNAL>" },
+	// }
+	//
+	// The signal is sent to the named process if it is still running. Signaling
+	// a process which has already terminated is not an error.
+
+	INFO("[%d] SIGNAL request.\n", r->fd);
+
+	// Protocol error conditions.
+	if (
+			(r->outer_len != 1) ||
+			(r->data[0][1] == NULL) ||
+			(r->data[0][2] == NULL) ||
+			(r->data[0][3] != NULL))
+	{
+		ERROR("[%d] Protocol error.\n", r->fd);
+		initd_response_protocol_error(r);
+		return;
+	}
+
+	// Parse the signal number.
+	errno = 0;
+	signum = strtol(r->data[0][2], &endptr, 10);
+	if (errno != 0 || *endptr != '\0' || signum <= 0 || signum >= NSIG) {
+		ERROR("[%d] Invalid signal: %s\n", r->fd, r->data[0][2]);
+		initd_response_protocol_error(r);
+		return;
+	}
+
+	// Find the named process.
+	for (p = process_head; p != NULL; p = p->next) {
+		if (!strcmp(p->name, r->data[0][1])) {
+			break;
+		}
+	}
+	if (p == NULL) {
+		ERROR("[%d] Unknown process: %s\n", r->fd, r->data[0][1]);
+		initd_response_protocol_error(r);
+		return;
+	}
+
+	if (!p->terminated) {
+		if (kill(p->pid, (int)signum) == -1 && errno != ESRCH) {
+			ERROR("[%d] Error in kill(): %s\n", r->fd, strerror(errno));
+			initd_response_internal_error(r);
+			return;
+		}
+	}
+
+	INFO("[%d] Successful signal.\n", r->fd);
+	initd_response_request_ok(r);
+}
+
+#endif
@@ -0,0 +1,97 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package stage3_test
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestSignalRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// Start the initd process.
+	cgroup, socket, _, _ := StartInitd(t)
+
+	// Start a long running process to signal.
+	dir := TempDir(t)
+	request := [][]string{
+		[]string{"START", "sleep"},
+		[]string{"/bin/sleep", "60"},
+		[]string{""},
+		[]string{"KTEST=VTEST"},
+		[]string{path.Join(dir, "stdout"), path.Join(dir, "stderr")},
+		[]string{"99", "99"},
+	}
+	reply, err := MakeRequest(socket, request, 10*time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, reply, "REQUEST OK\n")
+	waitTask(t, cgroup, []string{"/bin/sleep", "60"}, 5*time.Second)
+
+	// Send it SIGTERM and ensure it is reported as signaled.
+	request = [][]string{[]string{"SIGNAL", "sleep", "15"}}
+	reply, err = MakeRequest(socket, request, 10*time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, reply, "REQUEST OK\n")
+
+	Timeout(t, 5*time.Second, 100*time.Millisecond, func() bool {
+		request := [][]string{[]string{"STATUS"}}
+		reply, err := MakeRequest(socket, request, 10*time.Second)
+		TestExpectSuccess(t, err)
+		return strings.Contains(reply, "sleep\nsignaled(15)\n")
+	})
+
+	// Signaling a process which has already exited succeeds.
+	request = [][]string{[]string{"SIGNAL", "sleep", "9"}}
+	reply, err = MakeRequest(socket, request, 10*time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, reply, "REQUEST OK\n")
+}
+
+func TestBadSignalRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	tests := [][][]string{
+		// Test 1: No name or signal.
+		[][]string{
+			[]string{"SIGNAL"},
+		},
+
+		// Test 2: No signal.
+		[][]string{
+			[]string{"SIGNAL", "name"},
+		},
+
+		// Test 3: Extra cruft after the signal.
+		[][]string{
+			[]string{"SIGNAL", "name", "15", "EXTRA"},
+		},
+
+		// Test 4: Signal is not a number.
+		[][]string{
+			[]string{"SIGNAL", "name", "TERM"},
+		},
+
+		// Test 5: Unknown process.
+		[][]string{
+			[]string{"SIGNAL", "unknown", "15"},
+		},
+
+		// Test 6: Request is too long.
+		[][]string{
+			[]string{"SIGNAL", "name", "15"},
+			[]string{},
+		},
+	}
+	BadResultsCheck(t, tests)
+}
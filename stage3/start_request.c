@@ -14,6 +14,7 @@
 #include <sysexits.h>
 #include <unistd.h>
 
+#include <sys/ioctl.h>
 #include <sys/stat.h>
 #include <sys/types.h>
 
@@ -28,6 +29,7 @@ void start_request(struct request *r)
 	uid_t uid;
 	gid_t gid;
 	unsigned long int ul;
+	uint64_t capabilities;
 
 	// The expected protocol for an start statement looks like this:
 	// {
@@ -35,15 +37,21 @@ void start_request(struct request *r)
 	//   { "<COMMAND>", ["<ARGS>", ...]},
 	//   { "<WORKING DIRECTORY" },
 	//   { ["<ENV=VALUE>", ...]},
-	//   { "<STDOUTFILE>", "<STDERRFILE>" },
-	//   { "<UID>", "<GID>" },
+	//   { "<STDOUTFILE>", "<STDERRFILE>", ["<STDINFILE>"] },
+	//   { "<UID>", "<GID>", ["<CAPABILITIES>"] },
+	//   [{ "<LABEL>" }],
 	// }
+	//
+	// If CAPABILITIES is given, it is a comma separated list of the capability
+	// numbers the command is limited to, and may be empty to drop them all. If
+	// LABEL is given, it is written to /proc/self/attr/exec to have the command
+	// confined by the host's security module.
 
 	INFO("[%d] START request.\n", r->fd);
 
 	// Protocol error conditions.
 	if (
-			(r->outer_len != 6) ||
+			(r->outer_len != 6 && r->outer_len != 7) ||
 			// START/NAME
 			(r->data[0][1] != NULL && r->data[0][2] != NULL) ||
 			// COMMAND
@@ -51,16 +59,19 @@ void start_request(struct request *r)
 			// WORKING DIRECTORY
 			(r->data[2][1] != NULL) ||
 			// ENV (all values are valid.)
-			// STDOUTFILE, STDERRFILE
+			// STDOUTFILE, STDERRFILE, STDINFILE (optional)
 			(r->data[4][0] == NULL) ||
 			(r->data[4][1] == NULL) ||
-			(r->data[4][2] != NULL) ||
+			(r->data[4][2] != NULL && r->data[4][3] != NULL) ||
 			// UID, GID
 			(r->data[5][0] == NULL) ||
 			(r->data[5][1] == NULL) ||
-			(r->data[5][2] != NULL) ||
+			(r->data[5][2] != NULL && r->data[5][3] != NULL) ||
+			// LABEL (optional)
+			(r->outer_len == 7 &&
+				(r->data[6][0] == NULL || r->data[6][1] != NULL)) ||
 			// END
-			(r->data[6] != NULL))
+			(r->data[r->outer_len] != NULL))
 	{
 		ERROR("[%d] Protocol error.\n", r->fd);
 		initd_response_protocol_error(r);
@@ -87,6 +98,14 @@ void start_request(struct request *r)
 		name_len = 0;
 	}
 
+	// Parse the capabilities to retain, if given.
+	if (r->data[5][2] != NULL &&
+			initd_capability_mask(r->data[5][2], &capabilities) != 0) {
+		ERROR("[%d] Invalid capabilities: %s\n", r->fd, r->data[5][2]);
+		initd_response_protocol_error(r);
+		return;
+	}
+
 	// Compute the uid and gid
 	uid = (uid_t)uidforuser2(r->data[5][0]);
 	if (uid < 0) {
@@ -111,7 +130,25 @@ void start_request(struct request *r)
 	} else if (pid == 0) {
 		// Setup the initial FD's.
 		close_all_fds();
-		initd_setup_fds(r->data[4][0], r->data[4][1]);
+		initd_setup_fds(r->data[4][2], r->data[4][0], r->data[4][1]);
+
+		// If stdin is a terminal, such as a console, then start a new session
+		// with it as the controlling terminal so job control and signals work.
+		if (isatty(STDIN_FILENO)) {
+			if (setsid() == -1) { _exit(EX_OSERR); }
+			if (ioctl(STDIN_FILENO, TIOCSCTTY, 0) == -1) { _exit(EX_OSERR); }
+		}
+
+		// Drop the capabilities the command isn't allowed while still root.
+		if (r->data[5][2] != NULL) {
+			if (initd_drop_capabilities(capabilities) != 0) { _exit(EX_OSERR); }
+		}
+
+		// Have the command confined by its security label once it is exec'd.
+		if (r->outer_len == 7 && initd_set_exec_label(r->data[6][0]) != 0) {
+			ERROR("[%d] Error setting the security label: %s\n", r->fd, strerror(errno));
+			_exit(EX_OSERR);
+		}
 
 		// Ensure that we are fully root.
 		if (setregid(gid, gid) != 0) { _exit(EX_OSERR); }
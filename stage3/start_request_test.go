@@ -9,6 +9,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -225,6 +227,67 @@ func TestNamedStartRequest(t *testing.T) {
 	TestEqual(t, len(dirs), 3, fmt.Sprintf("Found fds: %#v", dirs))
 }
 
+func TestFIFOStdinStartRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// Start the initd process.
+	cgroup, socket, _, _ := StartInitd(t)
+
+	// Create the FIFO and hold only its write end, as stage1 does for the
+	// app's stdin.
+	dir := TempDir(t)
+	stdin := path.Join(dir, "stdin")
+	stdout := path.Join(dir, "stdout")
+	stderr := path.Join(dir, "stderr")
+	TestExpectSuccess(t, syscall.Mkfifo(stdin, 0600))
+	r, err := os.OpenFile(stdin, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	TestExpectSuccess(t, err)
+	w, err := os.OpenFile(stdin, os.O_WRONLY, 0)
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, r.Close())
+
+	cat := []string{"/bin/cat"}
+	request := [][]string{
+		[]string{"START"},
+		cat,
+		[]string{""},
+		[]string{},
+		[]string{stdout, stderr, stdin},
+		[]string{"99", "99"},
+	}
+	reply, err := MakeRequest(socket, request, 10*time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, reply, "REQUEST OK\n")
+	catpid, _ := waitTask(t, cgroup, cat, 5*time.Second)
+
+	// The process must only hold the read end of the FIFO.
+	fdinfo, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/fdinfo/0", catpid))
+	TestExpectSuccess(t, err)
+	flags := -1
+	for _, line := range strings.Split(string(fdinfo), "\n") {
+		if strings.HasPrefix(line, "flags:") {
+			_, err := fmt.Sscanf(line, "flags:\t%o", &flags)
+			TestExpectSuccess(t, err)
+		}
+	}
+	TestEqual(t, flags&syscall.O_ACCMODE, syscall.O_RDONLY)
+
+	// Write finite input and close the write end. The process reads EOF and
+	// exits once it has copied the input.
+	_, err = w.Write([]byte("hello\n"))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, w.Close())
+	Timeout(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		_, err := os.Stat(fmt.Sprintf("/proc/%d", catpid))
+		return os.IsNotExist(err)
+	})
+	data, err := ioutil.ReadFile(stdout)
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(data), "hello\n")
+}
+
 func TestBadStartRequest(t *testing.T) {
 	StartTest(t)
 	defer FinishTest(t)
@@ -261,13 +324,13 @@ func TestBadStartRequest(t *testing.T) {
 			[]string{"UID", "GID"},
 		},
 
-		// Test 4: Extra cruft after STDERR
+		// Test 4: Extra cruft after STDIN
 		[][]string{
 			[]string{"START"},
 			[]string{"COMMAND"},
 			[]string{"DIR"},
 			[]string{"ENVKEY=ENVVALUE"},
-			[]string{"STDOUT", "STDERR", "EXTRA"},
+			[]string{"STDOUT", "STDERR", "STDIN", "EXTRA"},
 			[]string{"UID", "GID"},
 		},
 
@@ -340,6 +403,37 @@ func TestBadStartRequest(t *testing.T) {
 			[]string{"STDOUT", "STDERR"},
 			[]string{},
 		},
+
+		// Test 12: Extra cruft after CAPABILITIES
+		[][]string{
+			[]string{"START"},
+			[]string{"COMMAND"},
+			[]string{"DIR"},
+			[]string{"ENVKEY=ENVVALUE"},
+			[]string{"STDOUT", "STDERR"},
+			[]string{"UID", "GID", "0,1", "EXTRA"},
+		},
+
+		// Test 13: Capability out of range
+		[][]string{
+			[]string{"START"},
+			[]string{"COMMAND"},
+			[]string{"DIR"},
+			[]string{"ENVKEY=ENVVALUE"},
+			[]string{"STDOUT", "STDERR"},
+			[]string{"UID", "GID", "0,64"},
+		},
+
+		// Test 14: Extra cruft after LABEL
+		[][]string{
+			[]string{"START"},
+			[]string{"COMMAND"},
+			[]string{"DIR"},
+			[]string{"ENVKEY=ENVVALUE"},
+			[]string{"STDOUT", "STDERR"},
+			[]string{"UID", "GID"},
+			[]string{"LABEL", "EXTRA"},
+		},
 	}
 	BadResultsCheck(t, tests)
 }
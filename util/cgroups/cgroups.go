@@ -19,14 +19,39 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/apcera/kurma/util/hugepages"
 	"github.com/apcera/util/proc"
 )
 
 const (
-	cpuPeriod = "cpu.cfs_period_us"
-	cpuQuota  = "cpu.cfs_quota_us"
-	memLimit  = "memory.limit_in_bytes"
-	memUsage  = "memory.usage_in_bytes"
+	blkioBytes  = "blkio.throttle.io_service_bytes"
+	blkioWeight = "blkio.weight"
+	cpuPeriod   = "cpu.cfs_period_us"
+	cpuQuota    = "cpu.cfs_quota_us"
+	cpuShares   = "cpu.shares"
+	cpusetCpus  = "cpuset.cpus"
+	cpusetMems  = "cpuset.mems"
+	eventCtl    = "cgroup.event_control"
+	memLimit    = "memory.limit_in_bytes"
+	memOOM      = "memory.oom_control"
+	memSoft     = "memory.soft_limit_in_bytes"
+	memUsage    = "memory.usage_in_bytes"
+)
+
+// The files of the unified hierarchy which replace those above.
+const (
+	cgroupControllers = "cgroup.controllers"
+	cgroupProcs       = "cgroup.procs"
+	cgroupSubtree     = "cgroup.subtree_control"
+	cpuMax            = "cpu.max"
+	cpuStat           = "cpu.stat"
+	cpuWeight         = "cpu.weight"
+	ioStat            = "io.stat"
+	ioWeight          = "io.weight"
+	memCurrent        = "memory.current"
+	memEvents         = "memory.events"
+	memHigh           = "memory.high"
+	memMax            = "memory.max"
 )
 
 // ------------------------
@@ -50,7 +75,7 @@ type Cgroup struct {
 }
 
 // Creates a new Cgroup on the system. this will make a directory in each of the
-// defaultCgroups subdirectories named for the given name.
+// controllers' subdirectories named for the given name.
 func New(name string) (*Cgroup, error) {
 	c := new(Cgroup)
 	c.name = name
@@ -74,7 +99,7 @@ func New(name string) (*Cgroup, error) {
 		// Ensure that the mount point was created properly by checking for the
 		// 'tasks' file. If it doesn't exist, or has anything in it then this
 		// creation failed.
-		tasksFile := path.Join(dir, "tasks")
+		tasksFile := path.Join(dir, tasksName())
 		if b, err := ioutilReadFile(tasksFile); err != nil {
 			return fmt.Errorf("Error reading cgroup 'tasks' file %s: %s", tasksFile, err)
 		} else if len(b) != 0 {
@@ -84,28 +109,105 @@ func New(name string) (*Cgroup, error) {
 		return nil
 	}
 
-	// Loop through each of the default cgroup types attempting to make the child
+	// On the unified hierarchy there is only the one directory to make, once
+	// the controllers have been enabled for the parent's children.
+	if unified() {
+		if err := enableControllers(path.Dir(name)); err != nil {
+			return nil, err
+		}
+		if err := makeChildDirectory(c.dir("")); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	// Loop through each of the cgroup types attempting to make the child
 	// directory.
-	for _, cgroup := range defaultCgroups {
+	for _, cgroup := range controllers() {
 		dir := path.Join(cgroupsDir, cgroup, name)
 		if err := makeChildDirectory(dir); err != nil {
 			return nil, err
 		}
+		if cgroup == "cpuset" {
+			if err := inheritCpuset(dir); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return c, nil
 }
 
+// inheritCpuset copies the CPUs and memory nodes of the parent cpuset into the
+// new one in the directory, which starts out with none and so can't be given
+// tasks until they're set.
+func inheritCpuset(dir string) error {
+	for _, file := range []string{cpusetCpus, cpusetMems} {
+		fn := path.Join(dir, file)
+		b, err := ioutilReadFile(fn)
+		if err != nil {
+			return fmt.Errorf("Error reading cgroup file %s: %s", fn, err)
+		} else if len(bytes.TrimSpace(b)) != 0 {
+			continue
+		}
+		parent, err := ioutilReadFile(path.Join(path.Dir(dir), file))
+		if err != nil {
+			return fmt.Errorf("Error reading the parent of cgroup file %s: %s", fn, err)
+		}
+		if err := ioutil.WriteFile(fn, parent, 0644); err != nil {
+			return fmt.Errorf("Error writing cgroup file %s: %s", fn, err)
+		}
+	}
+	return nil
+}
+
+// Open returns a cgroup which already exists, such as one created by another
+// process, without creating it.
+func Open(name string) *Cgroup {
+	return &Cgroup{name: name}
+}
+
 // Simple function to recover a cgroup from a stored state.
 func (c *Cgroup) Recover(name string) *Cgroup {
 	return &Cgroup{name: path.Join(c.name, name)}
 }
 
-// Loop through all the groups in defaultCgroups and call the given function on
+// tasksName returns the name of the file listing the tasks in a cgroup. On the
+// unified hierarchy, it lists the processes rather than each of their threads.
+func tasksName() string {
+	if unified() {
+		return cgroupProcs
+	}
+	return "tasks"
+}
+
+// dir returns the cgroup's directory in the hierarchy of the controller. On the
+// unified hierarchy, the controllers all share the one directory.
+func (c *Cgroup) dir(controller string) string {
+	if unified() {
+		return path.Join(cgroupsDir, c.name)
+	}
+	return path.Join(cgroupsDir, controller, c.name)
+}
+
+// dirs returns the cgroup's directory in each of the hierarchies it is made
+// in.
+func (c *Cgroup) dirs() []string {
+	if unified() {
+		return []string{c.dir("")}
+	}
+	types := controllers()
+	dirs := make([]string, len(types))
+	for i, ctype := range types {
+		dirs[i] = c.dir(ctype)
+	}
+	return dirs
+}
+
+// Loop through all the groups in controllers and call the given function on
 // the directory.
 func (c *Cgroup) forEach(f func(string) error) error {
-	for _, ctype := range defaultCgroups {
-		dir := path.Join(cgroupsDir, ctype, c.name)
+	for _, dir := range c.dirs() {
 		if err := f(dir); err != nil {
 			return err
 		}
@@ -118,7 +220,7 @@ func (c *Cgroup) forEach(f func(string) error) error {
 func (c *Cgroup) AddTask(pid int) error {
 	addTask := func(dir string) error {
 		flags := os.O_WRONLY | os.O_APPEND
-		tasksfile := path.Join(dir, "tasks")
+		tasksfile := path.Join(dir, tasksName())
 		f, err := os.OpenFile(tasksfile, flags, os.FileMode(0644))
 		if err != nil {
 			return err
@@ -131,7 +233,7 @@ func (c *Cgroup) AddTask(pid int) error {
 		return nil
 	}
 
-	// Walk through each cgroup type in defaultCgroups
+	// Walk through each cgroup type in controllers
 	if err := c.forEach(addTask); err != nil {
 		return err
 	}
@@ -141,6 +243,10 @@ func (c *Cgroup) AddTask(pid int) error {
 
 // CPUUsed returns the total amount of cpu used by processes in the cgroup.
 func (c *Cgroup) CPUUsed() (i int64, err error) {
+	if unified() {
+		usec, err := readKeyedValue(filepath.Join(c.dir("cpu"), cpuStat), "usage_usec")
+		return usec * 1000, err
+	}
 	return proc.ReadInt64(filepath.Join(cgroupsDir, "cpuacct", c.name, "cpuacct.usage"))
 }
 
@@ -228,8 +334,7 @@ func (c *Cgroup) Destroy() error {
 // Checks to see if this cgroup has already been destroyed. If this is true then
 // its directory is removed and it is completely shut down.
 func (c *Cgroup) Destroyed() (bool, error) {
-	for _, cgroup := range defaultCgroups {
-		dir := path.Join(cgroupsDir, cgroup, c.name)
+	for _, dir := range c.dirs() {
 		if _, err := osLstat(dir); err == nil {
 			return false, nil
 		} else if !os.IsNotExist(err) {
@@ -244,6 +349,12 @@ func (c *Cgroup) Destroyed() (bool, error) {
 // LimitCPU sets the CPU utilization allowance for this container in ms/sec. A
 // value over 1000 grants access to more than one CPU.
 func (c *Cgroup) LimitCPU(limit int64) error {
+	if unified() {
+		quota := limit * unifiedCPUPeriod / 1000
+		max := fmt.Sprintf("%d %d", quota, unifiedCPUPeriod)
+		return ioutil.WriteFile(filepath.Join(c.dir("cpu"), cpuMax), []byte(max), 0644)
+	}
+
 	// Period of child containers should match the period of top-level cgroup or
 	// jobs won't start.
 	defaultPeriod, err := ioutil.ReadFile(filepath.Join(cgroupsDir, "cpu", cpuPeriod))
@@ -284,6 +395,9 @@ func (c *Cgroup) LimitMemory(limit int64) error {
 	limitBytes := []byte(fmt.Sprintf("%d", limit))
 
 	fn := filepath.Join(cgroupsDir, "memory", c.name, memLimit)
+	if unified() {
+		fn = filepath.Join(c.dir("memory"), memMax)
+	}
 	if err := ioutil.WriteFile(fn, limitBytes, 0644); err != nil {
 		return err
 	}
@@ -291,11 +405,245 @@ func (c *Cgroup) LimitMemory(limit int64) error {
 	return nil
 }
 
+// ThrottleMemory sets the usage above which the cgroup's memory is reclaimed
+// first, without its processes being killed. The kernel only holds the cgroup
+// to it when the host is short of memory on cgroup v1, while the unified
+// hierarchy throttles the processes as they allocate beyond it. A limit of 0
+// removes the throttle.
+func (c *Cgroup) ThrottleMemory(limit int64) error {
+	if unified() {
+		value := "max"
+		if limit > 0 {
+			value = strconv.FormatInt(limit, 10)
+		}
+		return ioutil.WriteFile(filepath.Join(c.dir("memory"), memHigh), []byte(value), 0644)
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	fn := filepath.Join(cgroupsDir, "memory", c.name, memSoft)
+	return ioutil.WriteFile(fn, []byte(strconv.FormatInt(limit, 10)), 0644)
+}
+
+// SetCPUShares sets the relative weight of this container's processes when
+// competing for CPU time with other cgroups. The kernel's default is 1024. On
+// the unified hierarchy, the shares are converted to the equivalent weight.
+func (c *Cgroup) SetCPUShares(shares int64) error {
+	if unified() {
+		weight := strconv.FormatInt(sharesToWeight(shares), 10)
+		return ioutil.WriteFile(filepath.Join(c.dir("cpu"), cpuWeight), []byte(weight), 0644)
+	}
+	fn := filepath.Join(cgroupsDir, "cpu", c.name, cpuShares)
+	return ioutil.WriteFile(fn, []byte(strconv.FormatInt(shares, 10)), 0644)
+}
+
+// SetBlkioWeight sets the relative weight of this container's processes when
+// competing for block IO with other cgroups. The weight must be between 10 and
+// 1000. On the unified hierarchy, it is converted to the equivalent io weight.
+func (c *Cgroup) SetBlkioWeight(weight int64) error {
+	if unified() {
+		w := fmt.Sprintf("default %d", blkioToIOWeight(weight))
+		return ioutil.WriteFile(filepath.Join(c.dir("io"), ioWeight), []byte(w), 0644)
+	}
+	fn := filepath.Join(cgroupsDir, "blkio", c.name, blkioWeight)
+	return ioutil.WriteFile(fn, []byte(strconv.FormatInt(weight, 10)), 0644)
+}
+
+// SetCpuset places the processes in the cgroup on the CPUs and memory nodes,
+// given as lists such as "0-3,8". Either may be empty to leave it unchanged.
+func (c *Cgroup) SetCpuset(cpus, mems string) error {
+	if cpus != "" {
+		fn := filepath.Join(c.dir("cpuset"), cpusetCpus)
+		if err := ioutil.WriteFile(fn, []byte(cpus), 0644); err != nil {
+			return err
+		}
+	}
+	if mems != "" {
+		fn := filepath.Join(c.dir("cpuset"), cpusetMems)
+		if err := ioutil.WriteFile(fn, []byte(mems), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cpuset returns the CPUs and memory nodes the processes in the cgroup are
+// placed on.
+func (c *Cgroup) Cpuset() (cpus, mems string, err error) {
+	if cpus, err = c.readCpuset(cpusetCpus); err != nil {
+		return "", "", err
+	}
+	if mems, err = c.readCpuset(cpusetMems); err != nil {
+		return "", "", err
+	}
+	return cpus, mems, nil
+}
+
+// readCpuset reads the list from the cpuset file. On the unified hierarchy, a
+// cpuset which hasn't been set is left empty and uses its parent's, so those
+// in effect are read instead.
+func (c *Cgroup) readCpuset(file string) (string, error) {
+	fn := filepath.Join(c.dir("cpuset"), file)
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(b)) == 0 && unified() {
+		if b, err = ioutilReadFile(fn + ".effective"); err != nil {
+			return "", err
+		}
+	}
+	return string(bytes.TrimSpace(b)), nil
+}
+
+// LimitHugepages limits the hugepages of the page size the processes in the
+// cgroup may use to the limit, both in bytes.
+func (c *Cgroup) LimitHugepages(pageSize, limit int64) error {
+	file := fmt.Sprintf("hugetlb.%s.limit_in_bytes", hugepages.SizeName(pageSize))
+	if unified() {
+		file = fmt.Sprintf("hugetlb.%s.max", hugepages.SizeName(pageSize))
+	}
+	fn := filepath.Join(c.dir("hugetlb"), file)
+	return ioutil.WriteFile(fn, []byte(strconv.FormatInt(limit, 10)), 0644)
+}
+
 // MemoryUsed returns the total number of bytes used by processes in the cgroup.
 func (c *Cgroup) MemoryUsed() (int64, error) {
+	if unified() {
+		return proc.ReadInt64(filepath.Join(c.dir("memory"), memCurrent))
+	}
 	return proc.ReadInt64(filepath.Join(cgroupsDir, "memory", c.name, "memory.usage_in_bytes"))
 }
 
+// MemoryLimit returns the memory limit for processes in the cgroup, in bytes.
+// It is math.MaxInt64 on the unified hierarchy if the memory is unlimited.
+func (c *Cgroup) MemoryLimit() (int64, error) {
+	if unified() {
+		return readMax(filepath.Join(c.dir("memory"), memMax))
+	}
+	return proc.ReadInt64(filepath.Join(cgroupsDir, "memory", c.name, memLimit))
+}
+
+// BlkioUsed returns the total number of bytes read from and written to block
+// devices by processes in the cgroup.
+func (c *Cgroup) BlkioUsed() (read int64, write int64, err error) {
+	if unified() {
+		return ioUsed(filepath.Join(c.dir("io"), ioStat))
+	}
+
+	b, err := ioutilReadFile(filepath.Join(cgroupsDir, "blkio", c.name, blkioBytes))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Each line is formatted as "<major>:<minor> <operation> <bytes>", with a
+	// final line giving the overall total.
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid line in %s: %q", blkioBytes, line)
+		}
+		switch fields[1] {
+		case "Read":
+			read += value
+		case "Write":
+			write += value
+		}
+	}
+	return read, write, nil
+}
+
+// OOMKills returns the number of processes in the cgroup which have been killed
+// by the OOM killer. Kernels older than 4.13 do not report this, in which case
+// it returns 0.
+func (c *Cgroup) OOMKills() (int64, error) {
+	file := memOOM
+	if unified() {
+		file = memEvents
+	}
+	b, err := ioutilReadFile(filepath.Join(c.dir("memory"), file))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+		kills, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid line in %s: %q", file, line)
+		}
+		return kills, nil
+	}
+	return 0, nil
+}
+
+// NotifyOOM registers an eventfd with the kernel to be notified each time the
+// cgroup's memory usage reaches its limit and the OOM killer is invoked. A value
+// is sent on the returned channel when a notification is received, with
+// notifications that arrive before it is read being merged. The channel is
+// closed once the cgroup is removed or the returned function is called to stop
+// watching for notifications.
+func (c *Cgroup) NotifyOOM() (<-chan struct{}, func(), error) {
+	if unified() {
+		return c.notifyOOMEvents()
+	}
+
+	dir := filepath.Join(cgroupsDir, "memory", c.name)
+	oomControl, err := os.Open(filepath.Join(dir, memOOM))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The eventfd is non-blocking so that reading from it goes through the
+	// runtime poller, allowing the read to be interrupted by closing it. It is
+	// registered by its raw fd, as calling Fd on the file would set it back to
+	// blocking mode.
+	fd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if errno != 0 {
+		oomControl.Close()
+		return nil, nil, fmt.Errorf("failed to create eventfd: %v", errno)
+	}
+	eventfd := os.NewFile(fd, "oom-eventfd")
+
+	registration := fmt.Sprintf("%d %d", fd, oomControl.Fd())
+	if err := ioutil.WriteFile(filepath.Join(dir, eventCtl), []byte(registration), 0644); err != nil {
+		eventfd.Close()
+		oomControl.Close()
+		return nil, nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		defer oomControl.Close()
+
+		buf := make([]byte, 8)
+		for {
+			if _, err := eventfd.Read(buf); err != nil {
+				return
+			}
+
+			// The kernel also signals the eventfd when the cgroup is removed.
+			if _, err := osLstat(oomControl.Name()); os.IsNotExist(err) {
+				eventfd.Close()
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, func() { eventfd.Close() }, nil
+}
+
 // Returns the total number of bytes used in the container for disk.  Keys off
 // of the directory path to the container's root directory.  This runs as IM
 // (root) from outside the container itself.  Since the container root is an LVM
@@ -369,7 +717,7 @@ func (c *Cgroup) Tasks() ([]int, error) {
 	// Gathers all the tasks in the cgroup, checks to see if we have already added
 	// them and if not then add them to r (the list we will return)
 	tasks := func(dir string) error {
-		if b, err := ioutilReadFile(path.Join(dir, "tasks")); err != nil {
+		if b, err := ioutilReadFile(path.Join(dir, tasksName())); err != nil {
 			if os.IsNotExist(err) {
 				return nil
 			}
@@ -418,9 +766,10 @@ func (c *Cgroup) Tasks() ([]int, error) {
 // Returns the list of tasks files that need to be modified in order to be an
 // active member of all the various containers.
 func (c *Cgroup) TasksFiles() []string {
-	out := make([]string, len(defaultCgroups))
-	for i, cgroup := range defaultCgroups {
-		out[i] = path.Join(cgroupsDir, cgroup, c.name, "tasks")
+	dirs := c.dirs()
+	out := make([]string, len(dirs))
+	for i, dir := range dirs {
+		out[i] = path.Join(dir, tasksName())
 	}
 	return out
 }
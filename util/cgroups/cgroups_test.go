@@ -926,6 +926,326 @@ func TestCgroup_LimitMemory(t *testing.T) {
 	}
 }
 
+func TestCgroup_ThrottleMemory(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(c string) { cgroupsDir = c }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+	cgroup := Cgroup{name: "test"}
+	dir := path.Join(cgroupsDir, "memory", "test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	// Test 1: The throttle is the cgroup's soft limit.
+	TestExpectSuccess(t, cgroup.ThrottleMemory(1<<20))
+	b, err := ioutil.ReadFile(path.Join(dir, memSoft))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "1048576")
+
+	// Test 2: Removing the throttle lifts the soft limit.
+	TestExpectSuccess(t, cgroup.ThrottleMemory(0))
+	b, err = ioutil.ReadFile(path.Join(dir, memSoft))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "-1")
+}
+
+func TestCgroup_BlkioUsed(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: File not found.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "foo"}
+
+		if _, _, err := cgroup.BlkioUsed(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// Test 2: Not an integer.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "tmp"}
+
+		fn := path.Join(cgroupsDir, "blkio", "tmp")
+		if err := os.MkdirAll(fn, 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+		fn = path.Join(fn, blkioBytes)
+		if err := ioutil.WriteFile(fn, []byte("8:0 Read xyz\n"), 0644); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+
+		if _, _, err := cgroup.BlkioUsed(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// -------
+	// Success
+	// -------
+
+	defer func(s string) { cgroupsDir = s }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+	cgroup := Cgroup{name: "tmp"}
+
+	fn := path.Join(cgroupsDir, "blkio", "tmp")
+	if err := os.MkdirAll(fn, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	contents := strings.Join([]string{
+		"8:0 Read 4096",
+		"8:0 Write 1024",
+		"8:0 Sync 5120",
+		"8:0 Async 0",
+		"8:0 Total 5120",
+		"8:16 Read 100",
+		"8:16 Write 200",
+		"8:16 Total 300",
+		"Total 5420",
+		"",
+	}, "\n")
+	if err := ioutil.WriteFile(path.Join(fn, blkioBytes), []byte(contents), 0644); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	read, write, err := cgroup.BlkioUsed()
+	TestExpectSuccess(t, err)
+	TestEqual(t, read, int64(4196))
+	TestEqual(t, write, int64(1224))
+}
+
+func TestCgroup_OOMKills(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: File not found.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "foo"}
+
+		if _, err := cgroup.OOMKills(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// Test 2: Not an integer.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "tmp"}
+
+		fn := path.Join(cgroupsDir, "memory", "tmp")
+		if err := os.MkdirAll(fn, 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+		fn = path.Join(fn, memOOM)
+		if err := ioutil.WriteFile(fn, []byte("oom_kill xyz\n"), 0644); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+
+		if _, err := cgroup.OOMKills(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// -------
+	// Success
+	// -------
+
+	defer func(s string) { cgroupsDir = s }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+	cgroup := Cgroup{name: "tmp"}
+
+	fn := path.Join(cgroupsDir, "memory", "tmp")
+	if err := os.MkdirAll(fn, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	fn = path.Join(fn, memOOM)
+
+	// Test 1: Older kernels don't report the number of kills.
+	contents := "oom_kill_disable 0\nunder_oom 0\n"
+	if err := ioutil.WriteFile(fn, []byte(contents), 0644); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	kills, err := cgroup.OOMKills()
+	TestExpectSuccess(t, err)
+	TestEqual(t, kills, int64(0))
+
+	// Test 2: The number of kills is reported.
+	contents = "oom_kill_disable 0\nunder_oom 0\noom_kill 3\n"
+	if err := ioutil.WriteFile(fn, []byte(contents), 0644); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	kills, err = cgroup.OOMKills()
+	TestExpectSuccess(t, err)
+	TestEqual(t, kills, int64(3))
+}
+
+func TestCgroup_NotifyOOM(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: The memory cgroup doesn't exist.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "foo"}
+
+		if _, _, err := cgroup.NotifyOOM(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// -------
+	// Success
+	// -------
+
+	defer func(s string) { cgroupsDir = s }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+	cgroup := Cgroup{name: "tmp"}
+
+	dir := path.Join(cgroupsDir, "memory", "tmp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	for _, f := range []string{memOOM, eventCtl} {
+		if err := ioutil.WriteFile(path.Join(dir, f), nil, 0644); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+	}
+
+	// Test 1: The eventfd and the oom_control file are registered.
+	ch, stop, err := cgroup.NotifyOOM()
+	TestExpectSuccess(t, err)
+	b, err := ioutil.ReadFile(path.Join(dir, eventCtl))
+	TestExpectSuccess(t, err)
+	if fields := strings.Fields(string(b)); len(fields) != 2 {
+		Fatalf(t, "Unexpected registration: %q", string(b))
+	}
+
+	// Test 2: The channel is closed once watching is stopped.
+	stop()
+	select {
+	case _, ok := <-ch:
+		TestEqual(t, ok, false)
+	case <-time.After(5 * time.Second):
+		Fatalf(t, "The channel was not closed.")
+	}
+}
+
+func TestCgroup_SetCPUShares(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: cpu.shares is unwritable.
+	func() {
+		defer func(c string) { cgroupsDir = c }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+
+		cgroup := Cgroup{name: "test"}
+		fn := path.Join(cgroupsDir, "cpu", "test", cpuShares)
+		if err := os.MkdirAll(fn, 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+		if err := cgroup.SetCPUShares(512); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// ------------------
+	// Success Conditions
+	// ------------------
+
+	uniquename, cgroup := MakeUniqueCgroup(t)
+	defer CleanupCgroup(t, cgroup)
+
+	if err := cgroup.SetCPUShares(512); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	// Read the value back and make sure that it was applied.
+	fn := path.Join(cgroupsDir, "cpu", uniquename, cpuShares)
+	sharesBytes, err := ioutil.ReadFile(fn)
+	if err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	TestEqual(t, sharesBytes, []byte("512\n"))
+}
+
+func TestCgroup_SetBlkioWeight(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: blkio.weight is unwritable.
+	func() {
+		defer func(c string) { cgroupsDir = c }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+
+		cgroup := Cgroup{name: "test"}
+		fn := path.Join(cgroupsDir, "blkio", "test", blkioWeight)
+		if err := os.MkdirAll(fn, 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+		if err := cgroup.SetBlkioWeight(500); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// ------------------
+	// Success Conditions
+	// ------------------
+
+	uniquename, cgroup := MakeUniqueCgroup(t)
+	defer CleanupCgroup(t, cgroup)
+
+	// Not all IO schedulers support weights, in which case the file won't
+	// exist.
+	fn := path.Join(cgroupsDir, "blkio", uniquename, blkioWeight)
+	if _, err := os.Stat(fn); os.IsNotExist(err) {
+		return
+	}
+
+	if err := cgroup.SetBlkioWeight(500); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	// Read the value back and make sure that it was applied.
+	weightBytes, err := ioutil.ReadFile(fn)
+	if err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	TestEqual(t, weightBytes, []byte("500\n"))
+}
+
 func TestCgroup_MemoryUsed(t *testing.T) {
 	StartTest(t)
 	defer FinishTest(t)
@@ -1190,7 +1510,7 @@ func TestCgroup_TasksFiles(t *testing.T) {
 	tasksfiles := cgroup.TasksFiles()
 
 	// ensure that the lenghts are the same.
-	if len(tasksfiles) != len(defaultCgroups) {
+	if len(tasksfiles) != len(controllers()) {
 		Fatalf(t, "tasks files size difference.")
 	}
 
@@ -1349,6 +1669,84 @@ func TestCgroup_SignalAll(t *testing.T) {
 	}
 }
 
+func TestCgroup_Cpuset(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(s string) { cgroupsDir = s }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+	parent := path.Join(cgroupsDir, "cpuset")
+	child := path.Join(parent, "test")
+	if err := os.MkdirAll(child, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	files := map[string]string{
+		path.Join(parent, cpusetCpus): "0-7\n",
+		path.Join(parent, cpusetMems): "0-1\n",
+		path.Join(child, cpusetCpus):  "\n",
+		path.Join(child, cpusetMems):  "1\n",
+	}
+	for fn, contents := range files {
+		if err := ioutil.WriteFile(fn, []byte(contents), 0644); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+	}
+
+	// Test 1: Only what the new cpuset is missing is copied from its parent.
+	if err := inheritCpuset(child); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	cgroup := Cgroup{name: "test"}
+	cpus, mems, err := cgroup.Cpuset()
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, "0-7")
+	TestEqual(t, mems, "1")
+
+	// Test 2: Setting the CPUs alone leaves the memory nodes.
+	TestExpectSuccess(t, cgroup.SetCpuset("2,4", ""))
+	cpus, mems, err = cgroup.Cpuset()
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, "2,4")
+	TestEqual(t, mems, "1")
+
+	// Test 3: A cpuset without a parent to inherit from is an error.
+	if err := os.Remove(path.Join(parent, cpusetMems)); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(path.Join(child, cpusetMems), nil, 0644); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	TestExpectError(t, inheritCpuset(child))
+}
+
+func TestCgroup_LimitHugepages(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(s string) { cgroupsDir = s }(cgroupsDir)
+	cgroupsDir = TempDir(t)
+
+	// Test 1: The hugetlb controller is only used once it is mounted.
+	TestFalse(t, HasController("hugetlb"))
+	TestTrue(t, HasController("cpuset"))
+	dir := path.Join(cgroupsDir, "hugetlb", "test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	TestTrue(t, HasController("hugetlb"))
+
+	// Test 2: The limit is written to the file named for the page size.
+	cgroup := Cgroup{name: "test"}
+	TestExpectSuccess(t, cgroup.LimitHugepages(2<<20, 64<<20))
+	TestExpectSuccess(t, cgroup.LimitHugepages(1<<30, 2<<30))
+	b, err := ioutil.ReadFile(path.Join(dir, "hugetlb.2MB.limit_in_bytes"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "67108864")
+	b, err = ioutil.ReadFile(path.Join(dir, "hugetlb.1GB.limit_in_bytes"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "2147483648")
+}
+
 /*
 
 cgroups.go:func (c *Cgroup) SignalAll(signal syscall.Signal) (int, error) {
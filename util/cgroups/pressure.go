@@ -0,0 +1,119 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PressureResources are the resources the kernel reports pressure stall
+// information for.
+var PressureResources = []string{"cpu", "memory", "io"}
+
+// ErrNoPressure is returned when the kernel doesn't report pressure stall
+// information, which needs a kernel of at least 4.20 built with it, and for
+// cgroups, the unified hierarchy.
+var ErrNoPressure = errors.New("pressure stall information is not available")
+
+var pressureDir = "/proc/pressure"
+
+// Pressure is the pressure stall information of a resource. Some gives the
+// time at least one of the tasks was stalled waiting on the resource, and Full
+// the time all of them were at once, which is always zero for the CPU of the
+// host as a whole.
+type Pressure struct {
+	Some PressureStall
+	Full PressureStall
+}
+
+// PressureStall gives the percentage of the last 10, 60, and 300 seconds tasks
+// were stalled, and the total time they have been, in microseconds.
+type PressureStall struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  int64
+}
+
+// HostPressure returns the pressure on the resource, one of the
+// PressureResources, across the whole host.
+func HostPressure(resource string) (*Pressure, error) {
+	return readPressure(filepath.Join(pressureDir, resource))
+}
+
+// Pressure returns the pressure on the resource, one of the PressureResources,
+// from the processes in the cgroup.
+func (c *Cgroup) Pressure(resource string) (*Pressure, error) {
+	if !unified() {
+		return nil, ErrNoPressure
+	}
+	return readPressure(filepath.Join(c.dir(resource), resource+".pressure"))
+}
+
+// readPressure reads the pressure from the file, which the kernel refuses to be
+// read if it was booted with psi=0.
+func readPressure(fn string) (*Pressure, error) {
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoPressure
+		}
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EOPNOTSUPP {
+			return nil, ErrNoPressure
+		}
+		return nil, err
+	}
+	return parsePressure(b)
+}
+
+// parsePressure parses the pressure from lines such as:
+//
+//	some avg10=0.12 avg60=0.05 avg300=0.01 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(b []byte) (*Pressure, error) {
+	p := &Pressure{}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var stall *PressureStall
+		switch fields[0] {
+		case "some":
+			stall = &p.Some
+		case "full":
+			stall = &p.Full
+		default:
+			return nil, fmt.Errorf("invalid pressure line %q", line)
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid pressure line %q", line)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				stall.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				stall.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				stall.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				stall.Total, err = strconv.ParseInt(kv[1], 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid pressure line %q", line)
+			}
+		}
+	}
+	return p, nil
+}
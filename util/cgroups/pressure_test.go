@@ -0,0 +1,70 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParsePressure(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	p, err := parsePressure([]byte("some avg10=1.50 avg60=0.25 avg300=0.01 total=123456\n" +
+		"full avg10=0.50 avg60=0.00 avg300=0.00 total=789\n"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, p.Some, PressureStall{Avg10: 1.5, Avg60: 0.25, Avg300: 0.01, Total: 123456})
+	TestEqual(t, p.Full, PressureStall{Avg10: 0.5, Total: 789})
+
+	// Older kernels only report some for the CPU.
+	p, err = parsePressure([]byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=10\n"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, p.Some.Total, int64(10))
+	TestEqual(t, p.Full, PressureStall{})
+
+	_, err = parsePressure([]byte("some avg10=abc\n"))
+	TestExpectError(t, err)
+	_, err = parsePressure([]byte("most avg10=0.00\n"))
+	TestExpectError(t, err)
+}
+
+func TestPressure(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: The host's pressure is unavailable on kernels without it.
+	defer func(d string) { pressureDir = d }(pressureDir)
+	pressureDir = TempDir(t)
+	_, err := HostPressure("memory")
+	TestEqual(t, err, ErrNoPressure)
+
+	// Test 2: The host's pressure is read from its file.
+	writeCgroupFile(t, pressureDir, "memory", "some avg10=2.00 avg60=1.00 avg300=0.50 total=1000\n")
+	p, err := HostPressure("memory")
+	TestExpectSuccess(t, err)
+	TestEqual(t, p.Some.Avg10, 2.0)
+
+	// Test 3: Cgroups only report pressure on the unified hierarchy.
+	func() {
+		defer func(c string) { cgroupsDir = c }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		_, err := (&Cgroup{name: "test"}).Pressure("cpu")
+		TestEqual(t, err, ErrNoPressure)
+	}()
+
+	// Test 4: The cgroup's pressure is read from its files.
+	defer makeUnified(t, "cpuset cpu io memory")()
+	cgroup := &Cgroup{name: "test"}
+	_, err = cgroup.Pressure("io")
+	TestEqual(t, err, ErrNoPressure)
+	writeCgroupFile(t, path.Join(cgroupsDir, "test"), "io.pressure",
+		"some avg10=0.00 avg60=0.00 avg300=0.00 total=5\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=3\n")
+	p, err = cgroup.Pressure("io")
+	TestExpectSuccess(t, err)
+	TestEqual(t, p.Full.Total, int64(3))
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 )
 
 // #include <sys/mount.h>
@@ -22,11 +23,95 @@ var defaultCgroups []string = []string{
 	"devices",
 	"memory",
 	"blkio",
+	"cpuset",
+}
+
+// optionalCgroups are used alongside the defaultCgroups when they're mounted,
+// since not every kernel is built with them.
+var optionalCgroups []string = []string{
+	"hugetlb",
+}
+
+// unifiedCgroups are the controllers which must be available on the unified
+// hierarchy, where io replaces blkio, cpu also does the accounting cpuacct
+// did, and devices are controlled through BPF rather than a controller.
+var unifiedCgroups []string = []string{
+	"cpu",
+	"cpuset",
+	"io",
+	"memory",
+}
+
+// unified returns whether the cgroups are on the unified hierarchy of cgroup
+// v2, which is mounted as a whole at the cgroupsDir, rather than each of the
+// controllers having a hierarchy of its own.
+func unified() bool {
+	_, err := osLstat(path.Join(cgroupsDir, cgroupControllers))
+	return err == nil
+}
+
+// Unified returns whether the host's cgroups are on the unified hierarchy.
+func Unified() bool {
+	return unified()
+}
+
+// availableControllers returns the controllers listed in the cgroup.controllers
+// file in the directory of the unified hierarchy.
+func availableControllers(dir string) (map[string]bool, error) {
+	b, err := ioutilReadFile(path.Join(dir, cgroupControllers))
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]bool)
+	for _, name := range strings.Fields(string(b)) {
+		available[name] = true
+	}
+	return available, nil
+}
+
+// controllers returns the cgroup types each cgroup is made in: the
+// defaultCgroups, and those of the optionalCgroups which are mounted. On the
+// unified hierarchy, they are the unifiedCgroups, and those of the
+// optionalCgroups the kernel has.
+func controllers() []string {
+	if unified() {
+		available, _ := availableControllers(cgroupsDir)
+		types := append([]string(nil), unifiedCgroups...)
+		for _, name := range optionalCgroups {
+			if available[name] {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+
+	types := append([]string(nil), defaultCgroups...)
+	for _, name := range optionalCgroups {
+		if _, err := osLstat(path.Join(cgroupsDir, name)); err == nil {
+			types = append(types, name)
+		}
+	}
+	return types
 }
 
 // Verifies that all of the cgroups directories are actually mounted. If one is
 // not mounted then an error will be returned, otherwise nil will be returned.
+// On the unified hierarchy, it instead verifies the kernel has each of the
+// unifiedCgroups.
 func CheckCgroups() error {
+	if unified() {
+		available, err := availableControllers(cgroupsDir)
+		if err != nil {
+			return err
+		}
+		for _, name := range unifiedCgroups {
+			if !available[name] {
+				return fmt.Errorf("Missing cgroup controller: %s", name)
+			}
+		}
+		return nil
+	}
+
 	// Ensure that each of the defaultCgroups exists in '/sys/fs/cgroup'
 	for _, name := range defaultCgroups {
 		dir := path.Join(cgroupsDir, name)
@@ -42,3 +127,20 @@ func CheckCgroups() error {
 func CgroupsDirPrefix() string {
 	return cgroupsDir
 }
+
+// Controllers returns the names of the cgroup controllers each cgroup is
+// created with. Unless the host is on the unified hierarchy, they are each
+// mounted separately under CgroupsDirPrefix.
+func Controllers() []string {
+	return controllers()
+}
+
+// HasController returns whether the cgroup controller is available and used.
+func HasController(name string) bool {
+	for _, c := range controllers() {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// unifiedCPUPeriod is the period, in microseconds, of the CPU quotas set on the
+// unified hierarchy, which is the kernel's default.
+const unifiedCPUPeriod = 100000
+
+// enableControllers enables the controllers for the children of the cgroup
+// with the name, and of each of its ancestors, on the unified hierarchy. A
+// controller can only be enabled for a cgroup's children once it is enabled
+// for the cgroup itself, so they're enabled from the root down.
+func enableControllers(name string) error {
+	wanted := controllers()
+	dir := cgroupsDir
+	for _, part := range append([]string{""}, strings.Split(path.Clean(name), "/")...) {
+		if part == "." {
+			continue
+		}
+		dir = path.Join(dir, part)
+		if err := enableChildControllers(dir, wanted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableChildControllers enables those of the controllers which aren't yet
+// enabled for the children of the cgroup in the directory, creating it if it
+// doesn't exist.
+func enableChildControllers(dir string, controllers []string) error {
+	if _, err := osLstat(dir); os.IsNotExist(err) {
+		if err := osMkdir(dir, 0755); err != nil {
+			return fmt.Errorf("Error making cgroup directory %s: %s", dir, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	available, err := availableControllers(dir)
+	if err != nil {
+		return fmt.Errorf("Error reading the controllers of cgroup %s: %s", dir, err)
+	}
+	fn := path.Join(dir, cgroupSubtree)
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		return fmt.Errorf("Error reading cgroup file %s: %s", fn, err)
+	}
+	enabled := make(map[string]bool)
+	for _, name := range strings.Fields(string(b)) {
+		enabled[name] = true
+	}
+
+	var enable []string
+	for _, name := range controllers {
+		if available[name] && !enabled[name] {
+			enable = append(enable, "+"+name)
+		}
+	}
+	if len(enable) == 0 {
+		return nil
+	}
+	if err := ioutil.WriteFile(fn, []byte(strings.Join(enable, " ")), 0644); err != nil {
+		return fmt.Errorf("Error enabling controllers in %s: %s", fn, err)
+	}
+	return nil
+}
+
+// readKeyedValue returns the value of the key in a file of "key value" lines,
+// such as cpu.stat or memory.events, or 0 if the key isn't listed.
+func readKeyedValue(fn, key string) (int64, error) {
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != key {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid line in %s: %q", filepath.Base(fn), line)
+		}
+		return value, nil
+	}
+	return 0, nil
+}
+
+// readMax reads a limit from a file such as memory.max, where "max" means
+// there is no limit, which is returned as math.MaxInt64.
+func readMax(fn string) (int64, error) {
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	s := string(bytes.TrimSpace(b))
+	if s == "max" {
+		return math.MaxInt64, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ioUsed returns the total number of bytes read and written from the io.stat
+// file. Each line is a device's "<major>:<minor>" followed by key=value pairs,
+// such as rbytes=4096.
+func ioUsed(fn string) (read int64, write int64, err error) {
+	b, err := ioutilReadFile(fn)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || (kv[0] != "rbytes" && kv[0] != "wbytes") {
+				continue
+			}
+			value, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid line in %s: %q", ioStat, line)
+			}
+			if kv[0] == "rbytes" {
+				read += value
+			} else {
+				write += value
+			}
+		}
+	}
+	return read, write, nil
+}
+
+// sharesToWeight converts CPU shares, from 2 to 262144, to the CPU weight of the
+// unified hierarchy, from 1 to 10000, so that the default of 1024 shares is
+// close to the default weight of 100.
+func sharesToWeight(shares int64) int64 {
+	switch {
+	case shares < 2:
+		shares = 2
+	case shares > 262144:
+		shares = 262144
+	}
+	return 1 + (shares-2)*9999/262142
+}
+
+// blkioToIOWeight converts a blkio weight, from 10 to 1000, to the io weight of
+// the unified hierarchy, from 1 to 10000.
+func blkioToIOWeight(weight int64) int64 {
+	switch {
+	case weight < 10:
+		weight = 10
+	case weight > 1000:
+		weight = 1000
+	}
+	return 1 + (weight-10)*9999/990
+}
+
+// notifyOOMEvents is NotifyOOM for the unified hierarchy, which has no event
+// control file. Instead memory.events is watched with inotify, since the
+// kernel reports it as modified when any of its counters change, and a value
+// is sent whenever its count of OOMs goes up.
+func (c *Cgroup) notifyOOMEvents() (<-chan struct{}, func(), error) {
+	fn := filepath.Join(c.dir("memory"), memEvents)
+	ooms, err := readKeyedValue(fn, "oom")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The inotify descriptor is non-blocking so that reading from it goes
+	// through the runtime poller, allowing the read to be interrupted by
+	// closing it.
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create inotify: %v", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, fn, syscall.IN_MODIFY|syscall.IN_DELETE_SELF); err != nil {
+		syscall.Close(fd)
+		return nil, nil, fmt.Errorf("failed to watch %s: %v", fn, err)
+	}
+	watch := os.NewFile(uintptr(fd), "oom-inotify")
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+
+		buf := make([]byte, 4096)
+		for {
+			if _, err := watch.Read(buf); err != nil {
+				return
+			}
+
+			// The counters can't be read once the cgroup is removed.
+			n, err := readKeyedValue(fn, "oom")
+			if err != nil {
+				watch.Close()
+				return
+			}
+			if n <= ooms {
+				continue
+			}
+			ooms = n
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, func() { watch.Close() }, nil
+}
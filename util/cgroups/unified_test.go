@@ -0,0 +1,270 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// makeUnified sets up a fake unified hierarchy in a temporary directory, whose
+// root has the controllers, and returns a function restoring the real one.
+func makeUnified(t *testing.T, controllers string) func() {
+	saved := cgroupsDir
+	cgroupsDir = TempDir(t)
+	writeCgroupFile(t, cgroupsDir, cgroupControllers, controllers)
+	writeCgroupFile(t, cgroupsDir, cgroupSubtree, "")
+	return func() { cgroupsDir = saved }
+}
+
+// writeCgroupFile writes the contents to the file in the directory, creating
+// the directory if necessary.
+func writeCgroupFile(t *testing.T, dir, file, contents string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, file), []byte(contents), 0644); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+}
+
+func readCgroupFile(t *testing.T, dir, file string) string {
+	b, err := ioutil.ReadFile(path.Join(dir, file))
+	TestExpectSuccess(t, err)
+	return string(b)
+}
+
+func TestUnified(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: Separately mounted controllers aren't the unified hierarchy.
+	func() {
+		defer func(c string) { cgroupsDir = c }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		TestFalse(t, Unified())
+	}()
+
+	// Test 2: A kernel lacking one of the controllers is refused.
+	func() {
+		defer makeUnified(t, "cpuset cpu memory pids")()
+		TestTrue(t, Unified())
+		TestExpectError(t, CheckCgroups())
+	}()
+
+	// Test 3: The optional controllers are used when the kernel has them.
+	defer makeUnified(t, "cpuset cpu io memory pids")()
+	TestExpectSuccess(t, CheckCgroups())
+	TestEqual(t, Controllers(), []string{"cpu", "cpuset", "io", "memory"})
+	TestFalse(t, HasController("hugetlb"))
+	writeCgroupFile(t, cgroupsDir, cgroupControllers, "cpuset cpu io memory hugetlb pids")
+	TestTrue(t, HasController("hugetlb"))
+}
+
+func TestUnified_New(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer makeUnified(t, "cpuset cpu io memory pids")()
+
+	// The kernel populates a new cgroup's files, which are faked here. Its
+	// controllers are those enabled for its parent's children.
+	defer func() { osMkdir = os.Mkdir }()
+	osMkdir = func(name string, mode os.FileMode) error {
+		if err := os.Mkdir(name, mode); err != nil {
+			return err
+		}
+		enabled := strings.Replace(readCgroupFile(t, path.Dir(name), cgroupSubtree), "+", "", -1)
+		writeCgroupFile(t, name, cgroupProcs, "")
+		writeCgroupFile(t, name, cgroupSubtree, "")
+		writeCgroupFile(t, name, cgroupControllers, enabled)
+		return nil
+	}
+
+	// Test 1: The controllers are enabled for the children of each ancestor.
+	cgroup, err := New("kurma/test")
+	TestExpectSuccess(t, err)
+	TestEqual(t, readCgroupFile(t, cgroupsDir, cgroupSubtree), "+cpu +cpuset +io +memory")
+	TestEqual(t, readCgroupFile(t, path.Join(cgroupsDir, "kurma"), cgroupSubtree), "+cpu +cpuset +io +memory")
+	TestEqual(t, cgroup.TasksFiles(), []string{path.Join(cgroupsDir, "kurma", "test", cgroupProcs)})
+
+	// Test 2: Controllers which are already enabled are left alone.
+	writeCgroupFile(t, cgroupsDir, cgroupSubtree, "cpu cpuset io memory")
+	_, err = New("kurma/other")
+	TestExpectSuccess(t, err)
+	TestEqual(t, readCgroupFile(t, cgroupsDir, cgroupSubtree), "cpu cpuset io memory")
+
+	// Test 3: A cgroup which already has processes is refused.
+	dir := path.Join(cgroupsDir, "kurma", "busy")
+	writeCgroupFile(t, dir, cgroupProcs, "1234\n")
+	_, err = New("kurma/busy")
+	TestExpectError(t, err)
+
+	// Test 4: The cgroup is destroyed by removing its one directory.
+	destroyed, err := cgroup.Destroyed()
+	TestExpectSuccess(t, err)
+	TestFalse(t, destroyed)
+	for _, f := range []string{cgroupProcs, cgroupSubtree, cgroupControllers} {
+		TestExpectSuccess(t, os.Remove(path.Join(cgroupsDir, "kurma", "test", f)))
+	}
+	TestExpectSuccess(t, cgroup.Shutdown())
+	destroyed, err = cgroup.Destroyed()
+	TestExpectSuccess(t, err)
+	TestTrue(t, destroyed)
+}
+
+func TestUnified_Limits(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer makeUnified(t, "cpuset cpu io memory hugetlb")()
+	cgroup := Cgroup{name: "test"}
+	dir := path.Join(cgroupsDir, "test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	TestExpectSuccess(t, cgroup.LimitCPU(1500))
+	TestEqual(t, readCgroupFile(t, dir, cpuMax), "150000 100000")
+
+	TestExpectSuccess(t, cgroup.LimitMemory(1<<20))
+	TestEqual(t, readCgroupFile(t, dir, memMax), "1048576")
+
+	TestExpectSuccess(t, cgroup.ThrottleMemory(512<<10))
+	TestEqual(t, readCgroupFile(t, dir, memHigh), "524288")
+	TestExpectSuccess(t, cgroup.ThrottleMemory(0))
+	TestEqual(t, readCgroupFile(t, dir, memHigh), "max")
+
+	TestExpectSuccess(t, cgroup.SetCPUShares(1024))
+	TestEqual(t, readCgroupFile(t, dir, cpuWeight), "39")
+
+	TestExpectSuccess(t, cgroup.SetBlkioWeight(500))
+	TestEqual(t, readCgroupFile(t, dir, ioWeight), "default 4950")
+
+	TestExpectSuccess(t, cgroup.LimitHugepages(2<<20, 4<<20))
+	TestEqual(t, readCgroupFile(t, dir, "hugetlb.2MB.max"), "4194304")
+
+	// A cpuset which hasn't been set uses its parent's.
+	writeCgroupFile(t, dir, cpusetCpus, "\n")
+	writeCgroupFile(t, dir, cpusetCpus+".effective", "0-3\n")
+	writeCgroupFile(t, dir, cpusetMems, "\n")
+	writeCgroupFile(t, dir, cpusetMems+".effective", "0\n")
+	cpus, mems, err := cgroup.Cpuset()
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, "0-3")
+	TestEqual(t, mems, "0")
+	TestExpectSuccess(t, cgroup.SetCpuset("2-3", ""))
+	cpus, mems, err = cgroup.Cpuset()
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, "2-3")
+	TestEqual(t, mems, "0")
+}
+
+func TestUnified_Usage(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer makeUnified(t, "cpuset cpu io memory")()
+	cgroup := Cgroup{name: "test"}
+	dir := path.Join(cgroupsDir, "test")
+
+	// Test 1: The files can't be read before the cgroup exists.
+	_, err := cgroup.CPUUsed()
+	TestExpectError(t, err)
+	_, _, err = cgroup.BlkioUsed()
+	TestExpectError(t, err)
+
+	// Test 2: The usage is read from the unified hierarchy's files.
+	writeCgroupFile(t, dir, cpuStat, "usage_usec 2500\nuser_usec 2000\nsystem_usec 500\n")
+	writeCgroupFile(t, dir, memCurrent, "8192\n")
+	writeCgroupFile(t, dir, memMax, "max\n")
+	writeCgroupFile(t, dir, memEvents, "low 0\nhigh 0\nmax 4\noom 2\noom_kill 1\n")
+	writeCgroupFile(t, dir, ioStat, strings.Join([]string{
+		"8:0 rbytes=4096 wbytes=1024 rios=1 wios=1 dbytes=0 dios=0",
+		"8:16 rbytes=100 wbytes=200 rios=1 wios=1 dbytes=0 dios=0",
+		"",
+	}, "\n"))
+
+	cpu, err := cgroup.CPUUsed()
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpu, int64(2500000))
+	used, err := cgroup.MemoryUsed()
+	TestExpectSuccess(t, err)
+	TestEqual(t, used, int64(8192))
+	limit, err := cgroup.MemoryLimit()
+	TestExpectSuccess(t, err)
+	TestEqual(t, limit, int64(math.MaxInt64))
+	kills, err := cgroup.OOMKills()
+	TestExpectSuccess(t, err)
+	TestEqual(t, kills, int64(1))
+	read, write, err := cgroup.BlkioUsed()
+	TestExpectSuccess(t, err)
+	TestEqual(t, read, int64(4196))
+	TestEqual(t, write, int64(1224))
+
+	// Test 3: Malformed counters are refused.
+	writeCgroupFile(t, dir, ioStat, "8:0 rbytes=xyz\n")
+	_, _, err = cgroup.BlkioUsed()
+	TestExpectError(t, err)
+}
+
+func TestUnified_NotifyOOM(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer makeUnified(t, "cpuset cpu io memory")()
+	cgroup := Cgroup{name: "test"}
+	dir := path.Join(cgroupsDir, "test")
+	writeCgroupFile(t, dir, memEvents, "oom 1\noom_kill 1\n")
+
+	ch, stop, err := cgroup.NotifyOOM()
+	TestExpectSuccess(t, err)
+	defer stop()
+
+	// Test 1: Changes to the other counters aren't sent.
+	writeCgroupFile(t, dir, memEvents, "max 1\noom 1\noom_kill 1\n")
+	select {
+	case <-ch:
+		Fatalf(t, "Unexpected notification.")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Test 2: An OOM is sent.
+	writeCgroupFile(t, dir, memEvents, "max 1\noom 2\noom_kill 2\n")
+	select {
+	case _, ok := <-ch:
+		TestTrue(t, ok)
+	case <-time.After(5 * time.Second):
+		Fatalf(t, "The OOM was not sent.")
+	}
+
+	// Test 3: The channel is closed once the cgroup is removed.
+	TestExpectSuccess(t, os.Remove(path.Join(dir, memEvents)))
+	select {
+	case _, ok := <-ch:
+		TestFalse(t, ok)
+	case <-time.After(5 * time.Second):
+		Fatalf(t, "The channel was not closed.")
+	}
+}
+
+func TestSharesToWeight(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, sharesToWeight(2), int64(1))
+	TestEqual(t, sharesToWeight(262144), int64(10000))
+	TestEqual(t, sharesToWeight(0), int64(1))
+	TestEqual(t, blkioToIOWeight(10), int64(1))
+	TestEqual(t, blkioToIOWeight(1000), int64(10000))
+	TestEqual(t, blkioToIOWeight(2000), int64(10000))
+}
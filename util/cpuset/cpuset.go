@@ -0,0 +1,308 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package cpuset reads the host's CPUs and NUMA nodes, and allocates CPUs to
+// containers for their exclusive use from a pool which keeps some of them
+// reserved for the host itself. CPUs and nodes are given as lists in the
+// kernel's format, such as "0-3,8".
+package cpuset
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Parse parses a list of CPUs or memory nodes in the kernel's format, such as
+// "0-3,8", returning them sorted without duplicates.
+func Parse(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid list %q", s)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid list %q", s)
+			}
+		}
+		for i := first; i <= last; i++ {
+			seen[i] = true
+		}
+	}
+	ids := make([]int, 0, len(seen))
+	for i := range seen {
+		ids = append(ids, i)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Format formats the sorted CPUs or memory nodes as a list in the kernel's
+// format, collapsing consecutive ones into ranges.
+func Format(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// Topology is the host's online CPUs and the NUMA node each is on.
+type Topology struct {
+	CPUs  []int
+	Nodes []int
+
+	node map[int]int
+}
+
+// ReadTopology reads the topology from sysfs, which is normally mounted at
+// /sys. A host without NUMA support is treated as having all of its CPUs on
+// node 0.
+func ReadTopology(sysfs string) (*Topology, error) {
+	b, err := ioutil.ReadFile(filepath.Join(sysfs, "devices/system/cpu/online"))
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no CPUs are online")
+	}
+
+	t := &Topology{CPUs: cpus, node: make(map[int]int)}
+	online := make(map[int]bool)
+	for _, cpu := range cpus {
+		online[cpu] = true
+	}
+	dirs, _ := filepath.Glob(filepath.Join(sysfs, "devices/system/node/node[0-9]*"))
+	for _, dir := range dirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		nodeCPUs, err := Parse(string(b))
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, cpu := range nodeCPUs {
+			if online[cpu] {
+				t.node[cpu] = node
+				found = true
+			}
+		}
+		if found {
+			t.Nodes = append(t.Nodes, node)
+		}
+	}
+	if len(t.Nodes) == 0 {
+		t.Nodes = []int{0}
+	}
+	for _, cpu := range cpus {
+		if _, ok := t.node[cpu]; !ok {
+			t.node[cpu] = t.Nodes[0]
+		}
+	}
+	sort.Ints(t.Nodes)
+	return t, nil
+}
+
+// HasNode returns whether the node has online CPUs.
+func (t *Topology) HasNode(node int) bool {
+	for _, n := range t.Nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// NodesOf returns the nodes the CPUs are on.
+func (t *Topology) NodesOf(cpus []int) []int {
+	seen := make(map[int]bool)
+	var nodes []int
+	for _, cpu := range cpus {
+		if n, ok := t.node[cpu]; ok && !seen[n] {
+			seen[n] = true
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Ints(nodes)
+	return nodes
+}
+
+// AnyNode is given to Pool's methods to not restrict CPUs to a single node.
+const AnyNode = -1
+
+// Pool allocates the CPUs of a topology to owners for their exclusive use.
+// Reserved CPUs are never allocated, so the host and the containers which
+// don't have CPUs of their own always have somewhere to run.
+type Pool struct {
+	topology *Topology
+	reserved map[int]bool
+	owners   map[int]string
+	mutex    sync.Mutex
+}
+
+// NewPool returns a pool of the topology's CPUs, other than those reserved,
+// which must be online. At least one CPU must be reserved.
+func NewPool(t *Topology, reserved []int) (*Pool, error) {
+	if len(reserved) == 0 {
+		return nil, fmt.Errorf("at least one CPU must be reserved")
+	}
+	p := &Pool{topology: t, reserved: make(map[int]bool), owners: make(map[int]string)}
+	for _, cpu := range reserved {
+		if _, ok := t.node[cpu]; !ok {
+			return nil, fmt.Errorf("the reserved CPU %d is not online", cpu)
+		}
+		p.reserved[cpu] = true
+	}
+	return p, nil
+}
+
+// Topology returns the topology the pool allocates from.
+func (p *Pool) Topology() *Topology {
+	return p.topology
+}
+
+// Capacity returns how many CPUs may be allocated in all, on the node unless
+// it is AnyNode.
+func (p *Pool) Capacity(node int) int {
+	n := 0
+	for _, cpu := range p.topology.CPUs {
+		if !p.reserved[cpu] && (node == AnyNode || p.topology.node[cpu] == node) {
+			n++
+		}
+	}
+	return n
+}
+
+// free returns the CPUs which aren't reserved or allocated, on the node unless
+// it is AnyNode. The mutex must be held.
+func (p *Pool) free(node int) []int {
+	var cpus []int
+	for _, cpu := range p.topology.CPUs {
+		if p.reserved[cpu] || p.owners[cpu] != "" {
+			continue
+		}
+		if node == AnyNode || p.topology.node[cpu] == node {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// Allocate allocates count CPUs to the owner, on the node unless it is
+// AnyNode. Without a node, the CPUs are kept on a single node if one has enough
+// free, preferring the node with the most.
+func (p *Pool) Allocate(owner string, count, node int) ([]int, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("the number of CPUs must be positive")
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var cpus []int
+	if node != AnyNode {
+		cpus = p.free(node)
+	} else {
+		best := -1
+		for _, n := range p.topology.Nodes {
+			free := p.free(n)
+			if len(free) >= count && len(free) > best {
+				best = len(free)
+				cpus = free
+			}
+		}
+		if best < 0 {
+			cpus = p.free(AnyNode)
+		}
+	}
+	if len(cpus) < count {
+		if node != AnyNode {
+			return nil, fmt.Errorf("only %d of the %d CPUs requested are free on node %d", len(cpus), count, node)
+		}
+		return nil, fmt.Errorf("only %d of the %d CPUs requested are free", len(cpus), count)
+	}
+	cpus = cpus[:count]
+	for _, cpu := range cpus {
+		p.owners[cpu] = owner
+	}
+	return cpus, nil
+}
+
+// Claim allocates the CPUs to the owner, as when recovering an allocation
+// made by an earlier pool.
+func (p *Pool) Claim(owner string, cpus []int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, cpu := range cpus {
+		if _, ok := p.topology.node[cpu]; !ok {
+			return fmt.Errorf("CPU %d is not online", cpu)
+		}
+		if p.reserved[cpu] {
+			return fmt.Errorf("CPU %d is reserved", cpu)
+		}
+		if o := p.owners[cpu]; o != "" && o != owner {
+			return fmt.Errorf("CPU %d is already allocated", cpu)
+		}
+	}
+	for _, cpu := range cpus {
+		p.owners[cpu] = owner
+	}
+	return nil
+}
+
+// Release frees the CPUs allocated to the owner.
+func (p *Pool) Release(owner string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for cpu, o := range p.owners {
+		if o == owner {
+			delete(p.owners, cpu)
+		}
+	}
+}
+
+// Shared returns the CPUs which aren't allocated to any owner, including the
+// reserved ones, on the node unless it is AnyNode.
+func (p *Pool) Shared(node int) []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var cpus []int
+	for _, cpu := range p.topology.CPUs {
+		if p.owners[cpu] != "" {
+			continue
+		}
+		if node == AnyNode || p.topology.node[cpu] == node {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
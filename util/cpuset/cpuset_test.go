@@ -0,0 +1,130 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package cpuset
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParseFormat(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	ids, err := Parse("8,0-3,2\n")
+	TestExpectSuccess(t, err)
+	TestEqual(t, ids, []int{0, 1, 2, 3, 8})
+	TestEqual(t, Format(ids), "0-3,8")
+	TestEqual(t, Format([]int{1, 3, 4, 5, 7}), "1,3-5,7")
+
+	ids, err = Parse("")
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(ids), 0)
+	TestEqual(t, Format(nil), "")
+
+	for _, s := range []string{"a", "3-1", "1-", "-1", "1,,2"} {
+		_, err := Parse(s)
+		TestExpectError(t, err)
+	}
+}
+
+// writeTopology writes the sysfs files describing the online CPUs and the CPUs
+// of each node into the directory.
+func writeTopology(t *testing.T, sysfs, online string, nodes map[string]string) {
+	write := func(name, contents string) {
+		fn := filepath.Join(sysfs, "devices/system", name)
+		TestExpectSuccess(t, os.MkdirAll(filepath.Dir(fn), 0755))
+		TestExpectSuccess(t, ioutil.WriteFile(fn, []byte(contents+"\n"), 0644))
+	}
+	write("cpu/online", online)
+	for node, cpus := range nodes {
+		write("node/"+node+"/cpulist", cpus)
+	}
+}
+
+func TestReadTopology(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Test 1: CPUs are placed on their nodes, skipping those offline.
+	sysfs := TempDir(t)
+	writeTopology(t, sysfs, "0-5", map[string]string{"node0": "0-3", "node1": "4-7"})
+	topology, err := ReadTopology(sysfs)
+	TestExpectSuccess(t, err)
+	TestEqual(t, topology.CPUs, []int{0, 1, 2, 3, 4, 5})
+	TestEqual(t, topology.Nodes, []int{0, 1})
+	TestTrue(t, topology.HasNode(1))
+	TestFalse(t, topology.HasNode(2))
+	TestEqual(t, topology.NodesOf([]int{5, 1, 4}), []int{0, 1})
+
+	// Test 2: Without NUMA, all of the CPUs are on node 0.
+	sysfs = TempDir(t)
+	writeTopology(t, sysfs, "0-1", nil)
+	topology, err = ReadTopology(sysfs)
+	TestExpectSuccess(t, err)
+	TestEqual(t, topology.Nodes, []int{0})
+	TestEqual(t, topology.NodesOf([]int{1}), []int{0})
+
+	// Test 3: The online CPUs must be readable.
+	_, err = ReadTopology(TempDir(t))
+	TestExpectError(t, err)
+}
+
+func TestPool(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	sysfs := TempDir(t)
+	writeTopology(t, sysfs, "0-7", map[string]string{"node0": "0-3", "node1": "4-7"})
+	topology, err := ReadTopology(sysfs)
+	TestExpectSuccess(t, err)
+
+	_, err = NewPool(topology, nil)
+	TestExpectError(t, err)
+	_, err = NewPool(topology, []int{9})
+	TestExpectError(t, err)
+	pool, err := NewPool(topology, []int{0})
+	TestExpectSuccess(t, err)
+	TestEqual(t, pool.Capacity(AnyNode), 7)
+	TestEqual(t, pool.Capacity(0), 3)
+
+	// Test 1: CPUs are kept on the node with the most free.
+	cpus, err := pool.Allocate("a", 2, AnyNode)
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, []int{4, 5})
+
+	// Test 2: A node may be required.
+	cpus, err = pool.Allocate("b", 3, 0)
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, []int{1, 2, 3})
+	_, err = pool.Allocate("c", 1, 0)
+	TestExpectError(t, err)
+
+	// Test 3: An allocation spans nodes when none has enough free.
+	_, err = pool.Allocate("c", 3, AnyNode)
+	TestExpectError(t, err)
+	pool.Release("b")
+	cpus, err = pool.Allocate("c", 4, AnyNode)
+	TestExpectSuccess(t, err)
+	TestEqual(t, cpus, []int{1, 2, 3, 6})
+
+	// Test 4: The shared CPUs are the reserved ones and those not allocated.
+	TestEqual(t, pool.Shared(AnyNode), []int{0, 7})
+	TestEqual(t, pool.Shared(1), []int{7})
+
+	// Test 5: Claims are refused for the reserved CPUs and those allocated to
+	// another owner, and are otherwise recorded.
+	TestExpectError(t, pool.Claim("d", []int{0}))
+	TestExpectError(t, pool.Claim("d", []int{6, 7}))
+	TestExpectSuccess(t, pool.Claim("a", []int{4, 5}))
+	TestExpectSuccess(t, pool.Claim("d", []int{7}))
+	TestEqual(t, pool.Shared(AnyNode), []int{0})
+	pool.Release("a")
+	pool.Release("c")
+	pool.Release("d")
+	TestEqual(t, pool.Shared(AnyNode), topology.CPUs)
+}
@@ -0,0 +1,225 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package dhcp implements a DHCPv4 client used to configure the host's network
+// interfaces, and those of containers on the LAN.
+package dhcp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	clientPort = 68
+	serverPort = 67
+
+	// The initial and maximum delays before a message is retransmitted when
+	// no reply is received.
+	retransmitInitial = 2 * time.Second
+	retransmitMax     = 16 * time.Second
+)
+
+// parameterList is the options requested from the server.
+var parameterList = []byte{
+	optSubnetMask, optRouter, optDNS, optDomainName,
+	optLeaseTime, optRenewalTime, optRebindingTime,
+}
+
+// Client acquires and renews leases for a network interface. The interface
+// must be up.
+type Client struct {
+	iface *net.Interface
+
+	// Hostname is sent to the server if it is set.
+	Hostname string
+
+	// Address is requested from the servers when acquiring a lease, if it is
+	// set, such as to get back the address of an expired lease. They may
+	// offer a different one.
+	Address net.IP
+}
+
+// NewClient returns a client for the interface.
+func NewClient(iface *net.Interface) *Client {
+	return &Client{iface: iface}
+}
+
+// Acquire obtains a new lease by discovering the servers on the network and
+// requesting an address from the first which makes an offer.
+func (c *Client) Acquire(timeout time.Duration) (*Lease, error) {
+	deadline := time.Now().Add(timeout)
+	conn, err := c.listen()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	discover := c.newPacket(Discover)
+	if ip := c.Address.To4(); ip != nil {
+		discover.options[optRequestedIP] = ip
+	}
+	offer, err := c.exchange(conn, discover, deadline, Offer)
+	if err != nil {
+		return nil, fmt.Errorf("no offer received: %v", err)
+	}
+
+	request := c.newPacket(Request)
+	request.xid = discover.xid
+	request.options[optRequestedIP] = offer.yiaddr.To4()
+	request.options[optServerID] = offer.options[optServerID]
+	return c.request(conn, request, deadline)
+}
+
+// Renew extends the lease. The server which granted it isn't required to be
+// the one which renews it, so the request is broadcast.
+func (c *Client) Renew(lease *Lease, timeout time.Duration) (*Lease, error) {
+	deadline := time.Now().Add(timeout)
+	conn, err := c.listen()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := c.newPacket(Request)
+	request.ciaddr = lease.Address.IP
+	return c.request(conn, request, deadline)
+}
+
+// Release gives up the lease, notifying the server which granted it.
+func (c *Client) Release(lease *Lease) error {
+	conn, err := c.listen()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	release := c.newPacket(Release)
+	release.flags = 0
+	release.ciaddr = lease.Address.IP
+	if lease.ServerID != nil {
+		release.options[optServerID] = lease.ServerID.To4()
+	}
+	return c.send(conn, release)
+}
+
+// request sends the request and returns the lease from the server's ACK.
+func (c *Client) request(conn net.PacketConn, request *packet, deadline time.Time) (*Lease, error) {
+	reply, err := c.exchange(conn, request, deadline, Ack, Nak)
+	if err != nil {
+		return nil, fmt.Errorf("no acknowledgement received: %v", err)
+	}
+	if reply.messageType() == Nak {
+		return nil, fmt.Errorf("the server declined the request")
+	}
+	return newLease(reply, time.Now())
+}
+
+// newPacket returns a request of the specified type with a new transaction id
+// and the client's options.
+func (c *Client) newPacket(t MessageType) *packet {
+	var xid [4]byte
+	rand.Read(xid[:])
+
+	p := &packet{
+		op:     opRequest,
+		xid:    binary.BigEndian.Uint32(xid[:]),
+		flags:  flagBroadcast,
+		chaddr: c.iface.HardwareAddr,
+		options: map[byte][]byte{
+			optMessageType:   []byte{byte(t)},
+			optParameterList: parameterList,
+			optClientID:      append([]byte{1}, c.iface.HardwareAddr...),
+		},
+	}
+	if c.Hostname != "" {
+		p.options[optHostname] = []byte(c.Hostname)
+	}
+	return p
+}
+
+// exchange sends the packet and waits for a reply of one of the specified
+// types, retransmitting it with an increasing delay until the deadline.
+func (c *Client) exchange(conn net.PacketConn, p *packet, deadline time.Time, types ...MessageType) (*packet, error) {
+	start := time.Now()
+	delay := retransmitInitial
+	buf := make([]byte, 1500)
+
+	for {
+		p.secs = uint16(time.Since(start) / time.Second)
+		if err := c.send(conn, p); err != nil {
+			return nil, err
+		}
+
+		wait := time.Now().Add(delay)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		conn.SetReadDeadline(wait)
+
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			reply, err := parsePacket(buf[:n])
+			if err != nil || reply.op != opReply || reply.xid != p.xid {
+				continue
+			}
+			if reply.chaddr.String() != c.iface.HardwareAddr.String() {
+				continue
+			}
+			for _, t := range types {
+				if reply.messageType() == t {
+					return reply, nil
+				}
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("timed out")
+		}
+		if delay *= 2; delay > retransmitMax {
+			delay = retransmitMax
+		}
+	}
+}
+
+// send broadcasts the packet to the servers.
+func (c *Client) send(conn net.PacketConn, p *packet) error {
+	addr := &net.UDPAddr{IP: net.IPv4bcast, Port: serverPort}
+	_, err := conn.WriteTo(p.marshal(), addr)
+	return err
+}
+
+// listen opens a socket on the client port which is bound to the interface,
+// so that packets can be sent and received before it has an address.
+func (c *Client) listen() (net.PacketConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "dhcp")
+	defer f.Close()
+
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		return nil, err
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); err != nil {
+		return nil, err
+	}
+	if err := syscall.BindToDevice(fd, c.iface.Name); err != nil {
+		return nil, fmt.Errorf("failed to bind to %s: %v", c.iface.Name, err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Port: clientPort}); err != nil {
+		return nil, err
+	}
+	return net.FilePacketConn(f)
+}
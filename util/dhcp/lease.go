@@ -0,0 +1,112 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultLeaseTime is used if a server doesn't specify the length of a lease.
+const defaultLeaseTime = time.Hour
+
+// Lease is the configuration acquired from a DHCP server.
+type Lease struct {
+	// Address is the address assigned to the interface, along with the mask of
+	// its subnet.
+	Address *net.IPNet
+
+	// Router is the default gateway, if the server provided one.
+	Router net.IP
+
+	// DNS lists the nameservers, and Domain the search domain, if the server
+	// provided them.
+	DNS    []net.IP
+	Domain string
+
+	// ServerID identifies the server which granted the lease.
+	ServerID net.IP
+
+	// Acquired is when the lease was granted. It is valid for the Duration,
+	// should be renewed with the server after the RenewalTime and with any
+	// server after the RebindingTime.
+	Acquired      time.Time
+	Duration      time.Duration
+	RenewalTime   time.Duration
+	RebindingTime time.Duration
+}
+
+// Expires returns when the lease expires.
+func (l *Lease) Expires() time.Time {
+	return l.Acquired.Add(l.Duration)
+}
+
+// newLease builds the lease described by an ACK from the server.
+func newLease(p *packet, acquired time.Time) (*Lease, error) {
+	ip := p.yiaddr.To4()
+	if ip == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("the server did not assign an address")
+	}
+
+	mask := ip.DefaultMask()
+	if v := p.options[optSubnetMask]; len(v) == net.IPv4len {
+		mask = net.IPMask(v)
+	}
+
+	l := &Lease{
+		Address:  &net.IPNet{IP: ip, Mask: mask},
+		ServerID: optionIP(p.options[optServerID]),
+		Acquired: acquired,
+		Duration: defaultLeaseTime,
+		Domain:   strings.TrimRight(string(p.options[optDomainName]), "\x00"),
+	}
+	if routers := optionIPs(p.options[optRouter]); len(routers) > 0 {
+		l.Router = routers[0]
+	}
+	l.DNS = optionIPs(p.options[optDNS])
+
+	if d, ok := optionDuration(p.options[optLeaseTime]); ok {
+		l.Duration = d
+	}
+	l.RenewalTime = l.Duration / 2
+	if d, ok := optionDuration(p.options[optRenewalTime]); ok && d < l.Duration {
+		l.RenewalTime = d
+	}
+	l.RebindingTime = l.Duration * 7 / 8
+	if d, ok := optionDuration(p.options[optRebindingTime]); ok && d < l.Duration {
+		l.RebindingTime = d
+	}
+	if l.RebindingTime < l.RenewalTime {
+		l.RebindingTime = l.RenewalTime
+	}
+	return l, nil
+}
+
+// optionIP returns the address held in an option, or nil if it doesn't hold
+// one.
+func optionIP(v []byte) net.IP {
+	if len(v) != net.IPv4len {
+		return nil
+	}
+	return net.IP(append([]byte(nil), v...))
+}
+
+// optionIPs returns the list of addresses held in an option.
+func optionIPs(v []byte) []net.IP {
+	var ips []net.IP
+	for i := 0; i+net.IPv4len <= len(v); i += net.IPv4len {
+		ips = append(ips, net.IP(append([]byte(nil), v[i:i+net.IPv4len]...)))
+	}
+	return ips
+}
+
+// optionDuration returns the number of seconds held in an option.
+func optionDuration(v []byte) (time.Duration, bool) {
+	if len(v) != 4 {
+		return 0, false
+	}
+	return time.Duration(binary.BigEndian.Uint32(v)) * time.Second, true
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package dhcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// MessageType is the type of a DHCP message, carried in option 53.
+type MessageType byte
+
+const (
+	Discover = MessageType(1)
+	Offer    = MessageType(2)
+	Request  = MessageType(3)
+	Decline  = MessageType(4)
+	Ack      = MessageType(5)
+	Nak      = MessageType(6)
+	Release  = MessageType(7)
+)
+
+// The options used by the client.
+const (
+	optPad           = 0
+	optSubnetMask    = 1
+	optRouter        = 3
+	optDNS           = 6
+	optHostname      = 12
+	optDomainName    = 15
+	optRequestedIP   = 50
+	optLeaseTime     = 51
+	optMessageType   = 53
+	optServerID      = 54
+	optParameterList = 55
+	optRenewalTime   = 58
+	optRebindingTime = 59
+	optClientID      = 61
+	optEnd           = 255
+)
+
+const (
+	opRequest = 1
+	opReply   = 2
+
+	// flagBroadcast asks the server to broadcast its replies, since the client
+	// can't receive unicast packets before it has an address.
+	flagBroadcast = 0x8000
+
+	// headerLen is the length of the fixed portion of a packet, up to and
+	// including the magic cookie.
+	headerLen = 240
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// packet is a DHCP message. Only the fields the client uses are represented.
+type packet struct {
+	op      byte
+	xid     uint32
+	secs    uint16
+	flags   uint16
+	ciaddr  net.IP
+	yiaddr  net.IP
+	siaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+// messageType returns the type of the message, or 0 if it has none.
+func (p *packet) messageType() MessageType {
+	if v := p.options[optMessageType]; len(v) == 1 {
+		return MessageType(v[0])
+	}
+	return 0
+}
+
+// marshal encodes the packet. Options are written in ascending order so the
+// encoding is deterministic.
+func (p *packet) marshal() []byte {
+	b := make([]byte, headerLen)
+	b[0] = p.op
+	b[1] = 1 // ethernet
+	b[2] = byte(len(p.chaddr))
+	binary.BigEndian.PutUint32(b[4:8], p.xid)
+	binary.BigEndian.PutUint16(b[8:10], p.secs)
+	binary.BigEndian.PutUint16(b[10:12], p.flags)
+	copy(b[12:16], p.ciaddr.To4())
+	copy(b[16:20], p.yiaddr.To4())
+	copy(b[20:24], p.siaddr.To4())
+	copy(b[28:44], p.chaddr)
+	copy(b[236:240], magicCookie)
+
+	buf := bytes.NewBuffer(b)
+	for code := 1; code < optEnd; code++ {
+		v, ok := p.options[byte(code)]
+		if !ok {
+			continue
+		}
+		buf.WriteByte(byte(code))
+		buf.WriteByte(byte(len(v)))
+		buf.Write(v)
+	}
+	buf.WriteByte(optEnd)
+	return buf.Bytes()
+}
+
+// parsePacket decodes a DHCP message.
+func parsePacket(b []byte) (*packet, error) {
+	if len(b) < headerLen {
+		return nil, fmt.Errorf("packet is too short")
+	}
+	if !bytes.Equal(b[236:240], magicCookie) {
+		return nil, fmt.Errorf("packet is missing the magic cookie")
+	}
+	hlen := int(b[2])
+	if hlen > 16 {
+		return nil, fmt.Errorf("invalid hardware address length %d", hlen)
+	}
+
+	p := &packet{
+		op:      b[0],
+		xid:     binary.BigEndian.Uint32(b[4:8]),
+		secs:    binary.BigEndian.Uint16(b[8:10]),
+		flags:   binary.BigEndian.Uint16(b[10:12]),
+		ciaddr:  net.IP(append([]byte(nil), b[12:16]...)),
+		yiaddr:  net.IP(append([]byte(nil), b[16:20]...)),
+		siaddr:  net.IP(append([]byte(nil), b[20:24]...)),
+		chaddr:  net.HardwareAddr(append([]byte(nil), b[28:28+hlen]...)),
+		options: make(map[byte][]byte),
+	}
+
+	opts := b[headerLen:]
+	for i := 0; i < len(opts); {
+		code := opts[i]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			i++
+			continue
+		}
+		if i+1 >= len(opts) {
+			return nil, fmt.Errorf("option %d is truncated", code)
+		}
+		n := int(opts[i+1])
+		if i+2+n > len(opts) {
+			return nil, fmt.Errorf("option %d is truncated", code)
+		}
+		// Options may be split across multiple instances, which are
+		// concatenated.
+		p.options[code] = append(p.options[code], opts[i+2:i+2+n]...)
+		i += 2 + n
+	}
+	return p, nil
+}
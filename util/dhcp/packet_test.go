@@ -0,0 +1,125 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	mac, err := net.ParseMAC("52:54:00:12:34:56")
+	TestExpectSuccess(t, err)
+
+	p := &packet{
+		op:     opRequest,
+		xid:    0xdeadbeef,
+		secs:   3,
+		flags:  flagBroadcast,
+		ciaddr: net.ParseIP("10.0.0.5"),
+		chaddr: mac,
+		options: map[byte][]byte{
+			optMessageType: []byte{byte(Request)},
+			optHostname:    []byte("kurma"),
+		},
+	}
+	b := p.marshal()
+	TestEqual(t, b[len(b)-1], byte(optEnd))
+
+	parsed, err := parsePacket(b)
+	TestExpectSuccess(t, err)
+	TestEqual(t, parsed.op, byte(opRequest))
+	TestEqual(t, parsed.xid, uint32(0xdeadbeef))
+	TestEqual(t, parsed.secs, uint16(3))
+	TestEqual(t, parsed.flags, uint16(flagBroadcast))
+	TestEqual(t, parsed.ciaddr.String(), "10.0.0.5")
+	TestEqual(t, parsed.yiaddr.String(), "0.0.0.0")
+	TestEqual(t, parsed.chaddr.String(), mac.String())
+	TestEqual(t, parsed.messageType(), Request)
+	TestEqual(t, string(parsed.options[optHostname]), "kurma")
+}
+
+func TestParsePacket(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	p := &packet{op: opReply, options: map[byte][]byte{}}
+	b := p.marshal()
+
+	// Test 1: Packets shorter than the header are rejected.
+	_, err := parsePacket(b[:headerLen-1])
+	TestExpectError(t, err)
+
+	// Test 2: The magic cookie is required.
+	bad := append([]byte(nil), b...)
+	bad[236] = 0
+	_, err = parsePacket(bad)
+	TestExpectError(t, err)
+
+	// Test 3: Options which run past the end of the packet are rejected.
+	truncated := append(append([]byte(nil), b[:headerLen]...), optHostname, 10, 'a')
+	_, err = parsePacket(truncated)
+	TestExpectError(t, err)
+
+	// Test 4: Padding is skipped and split options are concatenated.
+	split := append(append([]byte(nil), b[:headerLen]...),
+		optPad, optDNS, 4, 8, 8, 8, 8, optPad, optDNS, 4, 8, 8, 4, 4, optEnd)
+	parsed, err := parsePacket(split)
+	TestExpectSuccess(t, err)
+	TestEqual(t, parsed.options[optDNS], []byte{8, 8, 8, 8, 8, 8, 4, 4})
+}
+
+func TestNewLease(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	now := time.Now()
+
+	// Test 1: An address must be assigned.
+	_, err := newLease(&packet{yiaddr: net.IPv4zero, options: map[byte][]byte{}}, now)
+	TestExpectError(t, err)
+
+	// Test 2: The configuration is taken from the options.
+	ack := &packet{
+		yiaddr: net.ParseIP("192.168.1.20"),
+		options: map[byte][]byte{
+			optSubnetMask: []byte{255, 255, 255, 0},
+			optRouter:     []byte{192, 168, 1, 1, 192, 168, 1, 2},
+			optDNS:        []byte{192, 168, 1, 1, 8, 8, 8, 8},
+			optDomainName: []byte("example.com\x00"),
+			optServerID:   []byte{192, 168, 1, 1},
+			optLeaseTime:  []byte{0, 0, 0x0e, 0x10},
+		},
+	}
+	lease, err := newLease(ack, now)
+	TestExpectSuccess(t, err)
+	TestEqual(t, lease.Address.String(), "192.168.1.20/24")
+	TestEqual(t, lease.Router.String(), "192.168.1.1")
+	TestEqual(t, len(lease.DNS), 2)
+	TestEqual(t, lease.DNS[1].String(), "8.8.8.8")
+	TestEqual(t, lease.Domain, "example.com")
+	TestEqual(t, lease.ServerID.String(), "192.168.1.1")
+	TestEqual(t, lease.Duration, time.Hour)
+	TestEqual(t, lease.RenewalTime, 30*time.Minute)
+	TestEqual(t, lease.RebindingTime, 52*time.Minute+30*time.Second)
+	TestEqual(t, lease.Expires(), now.Add(time.Hour))
+
+	// Test 3: Without a subnet mask or lease time, the defaults are used.
+	lease, err = newLease(&packet{yiaddr: net.ParseIP("10.1.2.3"), options: map[byte][]byte{}}, now)
+	TestExpectSuccess(t, err)
+	TestEqual(t, lease.Address.String(), "10.1.2.3/8")
+	TestEqual(t, lease.Duration, defaultLeaseTime)
+	TestEqual(t, lease.Router == nil, true)
+
+	// Test 4: Renewal times longer than the lease are ignored.
+	ack.options[optRenewalTime] = []byte{0, 0, 0x1c, 0x20}
+	lease, err = newLease(ack, now)
+	TestExpectSuccess(t, err)
+	TestEqual(t, lease.RenewalTime, 30*time.Minute)
+}
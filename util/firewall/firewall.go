@@ -0,0 +1,182 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package firewall restricts the traffic the host accepts with a default-deny
+// policy on the INPUT chain, accepting only that to the ports its services are
+// reached on, from trusted interfaces, and that matched by raw iptables rules.
+package firewall
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Chain is the chain in the filter table holding the rules for the traffic the
+// host accepts. INPUT jumps to it, and drops whatever it doesn't accept.
+const Chain = "KURMA-FIREWALL"
+
+// Port is a port the host accepts traffic to.
+type Port struct {
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+	Port     int
+}
+
+// ParsePort parses a port in the form PORT[/PROTOCOL]. The protocol defaults
+// to tcp.
+func ParsePort(s string) (Port, error) {
+	p := Port{Protocol: "tcp"}
+	if i := strings.Index(s, "/"); i >= 0 {
+		s, p.Protocol = s[:i], s[i+1:]
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return Port{}, fmt.Errorf("invalid port %q", s)
+	}
+	p.Port = port
+	if err := p.Validate(); err != nil {
+		return Port{}, err
+	}
+	return p, nil
+}
+
+// String returns the port formatted as PORT/PROTOCOL.
+func (p Port) String() string {
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
+
+// Validate checks that the port's protocol and number are valid.
+func (p Port) Validate() error {
+	if p.Protocol != "tcp" && p.Protocol != "udp" {
+		return fmt.Errorf("unsupported protocol %q, must be tcp or udp", p.Protocol)
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		return fmt.Errorf("invalid port %d", p.Port)
+	}
+	return nil
+}
+
+// Config describes the traffic the host accepts. Traffic on the loopback
+// interface, that belonging to connections the host made, ICMP, and replies to
+// the host's DHCP clients are always accepted.
+type Config struct {
+	// Ports are the ports accepted on any interface.
+	Ports []Port
+
+	// Interfaces are the interfaces all traffic is accepted from, such as the
+	// container network's bridge. A name ending in "+" matches each interface
+	// starting with the rest of it.
+	Interfaces []string
+
+	// Rules are rule specifications for iptables, such as "-s 10.0.0.0/8 -p
+	// tcp --dport 9100 -j ACCEPT", which are appended to the chain ahead of
+	// those for the interfaces and ports, so they may also drop traffic which
+	// would otherwise be accepted. Rules6 are those for ip6tables.
+	Rules  []string
+	Rules6 []string
+}
+
+// Validate checks the ports, interfaces and rules are valid, though the rules
+// are only checked by iptables when they're applied.
+func (c *Config) Validate() error {
+	for _, p := range c.Ports {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, iface := range c.Interfaces {
+		if iface == "" || strings.ContainsAny(iface, " \t\n") {
+			return fmt.Errorf("invalid interface %q", iface)
+		}
+	}
+	for _, rule := range append(append([]string(nil), c.Rules...), c.Rules6...) {
+		if strings.TrimSpace(rule) == "" || strings.ContainsAny(rule, "\r\n") {
+			return fmt.Errorf("invalid rule %q, rules must be a single line", rule)
+		}
+	}
+	return nil
+}
+
+// Apply replaces the rules in the chain atomically, and has INPUT drop any
+// traffic the chain doesn't accept. The rules are applied to IPv6 as well,
+// unless IPv6 is disabled on the host.
+func Apply(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := apply(config, false); err != nil {
+		return err
+	}
+	if _, err := os.Stat("/proc/net/if_inet6"); os.IsNotExist(err) {
+		return nil
+	}
+	return apply(config, true)
+}
+
+// apply programs the rules with iptables-restore, or ip6tables-restore if ipv6
+// is set.
+func apply(config Config, ipv6 bool) error {
+	command, restore := "iptables", "iptables-restore"
+	if ipv6 {
+		command, restore = "ip6tables", "ip6tables-restore"
+	}
+
+	// -C exits with a non-zero status if the jump doesn't exist yet, in which
+	// case it is added along with the rules so INPUT never drops traffic the
+	// chain would accept
+	jump := exec.Command(command, "-t", "filter", "-C", "INPUT", "-j", Chain).Run() != nil
+
+	cmd := exec.Command(restore, "--noflush")
+	cmd.Stdin = strings.NewReader(restoreInput(config, ipv6, jump))
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", restore, err, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// restoreInput returns the input to iptables-restore, or ip6tables-restore if
+// ipv6 is set, which replaces the rules in the chain with those for the config,
+// and sets INPUT's policy to drop. The jump from INPUT to the chain is added
+// if jump is set.
+func restoreInput(config Config, ipv6, jump bool) string {
+	var lines []string
+	add := func(args ...string) {
+		lines = append(lines, "-A "+Chain+" "+strings.Join(args, " "))
+	}
+	lines = append(lines, "*filter", ":INPUT DROP [0:0]", ":"+Chain+" - [0:0]")
+	if jump {
+		lines = append(lines, "-A INPUT -j "+Chain)
+	}
+
+	add("-i", "lo", "-j", "ACCEPT")
+	add("-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT")
+	// ICMPv6 carries neighbor discovery, without which IPv6 doesn't work
+	// at all, and the DHCP servers' offers are broadcast, so they aren't
+	// matched as replies
+	if ipv6 {
+		add("-p", "ipv6-icmp", "-j", "ACCEPT")
+		add("-p", "udp", "--sport", "547", "--dport", "546", "-j", "ACCEPT")
+	} else {
+		add("-p", "icmp", "-j", "ACCEPT")
+		add("-p", "udp", "--sport", "67", "--dport", "68", "-j", "ACCEPT")
+	}
+
+	rules := config.Rules
+	if ipv6 {
+		rules = config.Rules6
+	}
+	for _, rule := range rules {
+		add(strings.TrimSpace(rule))
+	}
+	for _, iface := range config.Interfaces {
+		add("-i", iface, "-j", "ACCEPT")
+	}
+	for _, p := range config.Ports {
+		add("-p", p.Protocol, "--dport", strconv.Itoa(p.Port), "-j", "ACCEPT")
+	}
+
+	lines = append(lines, "COMMIT", "")
+	return strings.Join(lines, "\n")
+}
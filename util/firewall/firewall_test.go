@@ -0,0 +1,81 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package firewall
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParsePort(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	p, err := ParsePort("12311")
+	TestExpectSuccess(t, err)
+	TestEqual(t, p, Port{Protocol: "tcp", Port: 12311})
+
+	p, err = ParsePort("53/udp")
+	TestExpectSuccess(t, err)
+	TestEqual(t, p.String(), "53/udp")
+
+	for _, s := range []string{"", "ssh", "0", "65536", "22/sctp"} {
+		_, err := ParsePort(s)
+		TestExpectError(t, err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestExpectSuccess(t, (&Config{
+		Ports:      []Port{{"tcp", 22}},
+		Interfaces: []string{"kurma0", "veth+"},
+		Rules:      []string{"-s 10.0.0.0/8 -j ACCEPT"},
+	}).Validate())
+	TestExpectError(t, (&Config{Ports: []Port{{"tcp", 0}}}).Validate())
+	TestExpectError(t, (&Config{Interfaces: []string{""}}).Validate())
+	TestExpectError(t, (&Config{Rules6: []string{" "}}).Validate())
+	TestExpectError(t, (&Config{Rules: []string{"-j ACCEPT\nCOMMIT"}}).Validate())
+}
+
+func TestRestoreInput(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	config := Config{
+		Ports:      []Port{{"tcp", 22}, {"udp", 53}},
+		Interfaces: []string{"kurma0"},
+		Rules:      []string{"-p tcp --dport 12311 ! -s 10.0.0.0/8 -j DROP"},
+		Rules6:     []string{"-s fd00::/8 -j ACCEPT"},
+	}
+
+	// Test 1: The jump is added with the rules when it doesn't exist, and the
+	// raw rules come ahead of the interfaces and ports.
+	TestEqual(t, strings.Split(restoreInput(config, false, true), "\n"), []string{
+		"*filter",
+		":INPUT DROP [0:0]",
+		":KURMA-FIREWALL - [0:0]",
+		"-A INPUT -j KURMA-FIREWALL",
+		"-A KURMA-FIREWALL -i lo -j ACCEPT",
+		"-A KURMA-FIREWALL -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		"-A KURMA-FIREWALL -p icmp -j ACCEPT",
+		"-A KURMA-FIREWALL -p udp --sport 67 --dport 68 -j ACCEPT",
+		"-A KURMA-FIREWALL -p tcp --dport 12311 ! -s 10.0.0.0/8 -j DROP",
+		"-A KURMA-FIREWALL -i kurma0 -j ACCEPT",
+		"-A KURMA-FIREWALL -p tcp --dport 22 -j ACCEPT",
+		"-A KURMA-FIREWALL -p udp --dport 53 -j ACCEPT",
+		"COMMIT",
+		"",
+	})
+
+	// Test 2: IPv6 uses its own rules, and accepts neighbor discovery.
+	input := restoreInput(config, true, false)
+	TestFalse(t, strings.Contains(input, "-A INPUT"))
+	TestFalse(t, strings.Contains(input, "12311"))
+	TestTrue(t, strings.Contains(input, "-A KURMA-FIREWALL -p ipv6-icmp -j ACCEPT\n"))
+	TestTrue(t, strings.Contains(input, "-A KURMA-FIREWALL -s fd00::/8 -j ACCEPT\n"))
+}
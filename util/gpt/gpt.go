@@ -0,0 +1,339 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package gpt detects whether a disk is blank and partitions it with a GUID
+// partition table holding a single Linux filesystem partition, which is enough
+// to provision a data disk at boot without depending on a partitioning tool. It
+// also reads the partitions of an existing table and updates their attributes,
+// which is how a bootloader is told which of a pair of system partitions to
+// boot.
+package gpt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const (
+	// ioctls for the logical sector size and the size of a block device, and
+	// to have the kernel re-read its partition table.
+	blkSSZGet    = 0x1268
+	blkGetSize64 = 0x80081272
+	blkRRPart    = 0x125f
+
+	// defaultSectorSize is the sector size of disk images, which aren't block
+	// devices.
+	defaultSectorSize = 512
+
+	// numEntries and entrySize are the number and size of the partition
+	// entries, which are the sizes the specification requires space for.
+	numEntries = 128
+	entrySize  = 128
+
+	headerSize = 92
+
+	// alignment is the boundary the partition starts on.
+	alignment = 1 << 20
+
+	// probeSize is how much of the start of a disk is checked for data to
+	// determine whether it is blank.
+	probeSize = 1 << 20
+)
+
+var (
+	signature = []byte("EFI PART")
+	revision  = uint32(0x00010000)
+
+	// LinuxFilesystem is the partition type GUID for Linux filesystem data.
+	LinuxFilesystem = mustParseGUID("0FC63DAF-8483-4772-8E79-3D69D8477DE4")
+)
+
+// GUID is a globally unique identifier, stored in the mixed-endian form GPT
+// uses.
+type GUID [16]byte
+
+// String returns the GUID in its canonical form.
+func (g GUID) String() string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(g[0:4]), binary.LittleEndian.Uint16(g[4:6]),
+		binary.LittleEndian.Uint16(g[6:8]), g[8:10], g[10:16])
+}
+
+// mustParseGUID parses a GUID in its canonical form.
+func mustParseGUID(s string) GUID {
+	var a uint32
+	var b, c uint16
+	var d, e []byte
+	if _, err := fmt.Sscanf(s, "%08X-%04X-%04X-%X-%X", &a, &b, &c, &d, &e); err != nil || len(d) != 2 || len(e) != 6 {
+		panic(fmt.Sprintf("invalid GUID %q", s))
+	}
+	var g GUID
+	binary.LittleEndian.PutUint32(g[0:4], a)
+	binary.LittleEndian.PutUint16(g[4:6], b)
+	binary.LittleEndian.PutUint16(g[6:8], c)
+	copy(g[8:10], d)
+	copy(g[10:16], e)
+	return g
+}
+
+// randomGUID returns a random version 4 GUID.
+func randomGUID() (GUID, error) {
+	var g GUID
+	if _, err := io.ReadFull(rand.Reader, g[:]); err != nil {
+		return g, err
+	}
+	g[7] = g[7]&0x0f | 0x40
+	g[8] = g[8]&0x3f | 0x80
+	return g, nil
+}
+
+// IsBlank returns whether the start of the disk holds no data at all, so it
+// has neither a partition table nor a filesystem and can be partitioned
+// without losing anything.
+func IsBlank(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, probeSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	for _, b := range buf[:n] {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// HasPartitionTable returns whether the disk has an MBR or GUID partition
+// table.
+func HasPartitionTable(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sectorSize, _, err := geometry(f)
+	if err != nil {
+		return false, err
+	}
+	buf := make([]byte, 2*sectorSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return false, err
+	}
+	if bytes.Equal(buf[sectorSize:sectorSize+8], signature) {
+		return true, nil
+	}
+	return buf[510] == 0x55 && buf[511] == 0xaa, nil
+}
+
+// Partition writes a GUID partition table to the disk with a single Linux
+// filesystem partition spanning it, with the name, and has the kernel re-read
+// the disk's partitions if it is a block device. Anything on the disk is lost.
+func Partition(path, name string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sectorSize, size, err := geometry(f)
+	if err != nil {
+		return err
+	}
+	diskGUID, err := randomGUID()
+	if err != nil {
+		return err
+	}
+	partGUID, err := randomGUID()
+	if err != nil {
+		return err
+	}
+	layout, err := newLayout(sectorSize, size)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]byte, numEntries*entrySize)
+	copy(entries[0:16], LinuxFilesystem[:])
+	copy(entries[16:32], partGUID[:])
+	binary.LittleEndian.PutUint64(entries[32:], layout.partFirst)
+	binary.LittleEndian.PutUint64(entries[40:], layout.partLast)
+	for i, c := range utf16.Encode([]rune(name)) {
+		if i >= 36 {
+			break
+		}
+		binary.LittleEndian.PutUint16(entries[56+2*i:], c)
+	}
+	entriesCRC := crc32.ChecksumIEEE(entries)
+
+	primary := layout.header(diskGUID, entriesCRC, false)
+	backup := layout.header(diskGUID, entriesCRC, true)
+
+	writes := []struct {
+		lba  uint64
+		data []byte
+	}{
+		{0, layout.protectiveMBR()},
+		{1, primary},
+		{2, entries},
+		{layout.lastLBA - layout.entrySectors, entries},
+		{layout.lastLBA, backup},
+	}
+	for _, w := range writes {
+		if _, err := f.WriteAt(w.data, int64(w.lba*layout.sectorSize)); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return rereadPartitions(f)
+}
+
+// layout is where the structures of the partition table are placed on a disk.
+type layout struct {
+	sectorSize   uint64
+	lastLBA      uint64
+	entrySectors uint64
+	firstUsable  uint64
+	lastUsable   uint64
+	partFirst    uint64
+	partLast     uint64
+}
+
+// newLayout lays out the partition table and the partition for a disk of the
+// size.
+func newLayout(sectorSize, size uint64) (*layout, error) {
+	l := &layout{
+		sectorSize:   sectorSize,
+		lastLBA:      size/sectorSize - 1,
+		entrySectors: (numEntries*entrySize + sectorSize - 1) / sectorSize,
+	}
+	l.firstUsable = 2 + l.entrySectors
+	if l.lastLBA < 2*l.entrySectors+2 {
+		return nil, fmt.Errorf("the disk is too small to partition")
+	}
+	l.lastUsable = l.lastLBA - 1 - l.entrySectors
+
+	align := uint64(alignment) / sectorSize
+	if align == 0 {
+		align = 1
+	}
+	l.partFirst = (l.firstUsable + align - 1) / align * align
+	l.partLast = l.lastUsable
+	if l.partFirst >= l.partLast {
+		return nil, fmt.Errorf("the disk is too small to partition")
+	}
+	return l, nil
+}
+
+// protectiveMBR returns the MBR which marks the disk as in use by GPT for
+// tools which don't understand it.
+func (l *layout) protectiveMBR() []byte {
+	mbr := make([]byte, l.sectorSize)
+	entry := mbr[446:462]
+	entry[1], entry[2], entry[3] = 0x00, 0x02, 0x00
+	entry[4] = 0xee
+	entry[5], entry[6], entry[7] = 0xff, 0xff, 0xff
+	binary.LittleEndian.PutUint32(entry[8:], 1)
+	sectors := l.lastLBA
+	if sectors > 0xffffffff {
+		sectors = 0xffffffff
+	}
+	binary.LittleEndian.PutUint32(entry[12:], uint32(sectors))
+	mbr[510], mbr[511] = 0x55, 0xaa
+	return mbr
+}
+
+// header returns the primary or backup header of the partition table.
+func (l *layout) header(diskGUID GUID, entriesCRC uint32, backup bool) []byte {
+	current, other, entriesLBA := uint64(1), l.lastLBA, uint64(2)
+	if backup {
+		current, other, entriesLBA = l.lastLBA, 1, l.lastLBA-l.entrySectors
+	}
+
+	h := make([]byte, l.sectorSize)
+	copy(h[0:8], signature)
+	binary.LittleEndian.PutUint32(h[8:], revision)
+	binary.LittleEndian.PutUint32(h[12:], headerSize)
+	binary.LittleEndian.PutUint64(h[24:], current)
+	binary.LittleEndian.PutUint64(h[32:], other)
+	binary.LittleEndian.PutUint64(h[40:], l.firstUsable)
+	binary.LittleEndian.PutUint64(h[48:], l.lastUsable)
+	copy(h[56:72], diskGUID[:])
+	binary.LittleEndian.PutUint64(h[72:], entriesLBA)
+	binary.LittleEndian.PutUint32(h[80:], numEntries)
+	binary.LittleEndian.PutUint32(h[84:], entrySize)
+	binary.LittleEndian.PutUint32(h[88:], entriesCRC)
+	binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h[:headerSize]))
+	return h
+}
+
+// geometry returns the logical sector size and the size of the disk, which may
+// be a block device or a disk image.
+func geometry(f *os.File) (uint64, uint64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if fi.Mode()&os.ModeDevice == 0 {
+		return defaultSectorSize, uint64(fi.Size()), nil
+	}
+
+	var sectorSize uint32
+	if err := ioctl(f, blkSSZGet, uintptr(unsafe.Pointer(&sectorSize))); err != nil {
+		return 0, 0, fmt.Errorf("failed to get the sector size of %s: %v", f.Name(), err)
+	}
+	var size uint64
+	if err := ioctl(f, blkGetSize64, uintptr(unsafe.Pointer(&size))); err != nil {
+		return 0, 0, fmt.Errorf("failed to get the size of %s: %v", f.Name(), err)
+	}
+	return uint64(sectorSize), size, nil
+}
+
+// rereadPartitions has the kernel re-read the partition table of a block
+// device, so the nodes for its partitions are created.
+func rereadPartitions(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeDevice == 0 {
+		return nil
+	}
+	if err := ioctl(f, blkRRPart, 0); err != nil {
+		return fmt.Errorf("failed to re-read the partitions of %s: %v", f.Name(), err)
+	}
+	return nil
+}
+
+func ioctl(f *os.File, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// PartitionPath returns the path of the numbered partition of the disk. Disks
+// whose names end in a digit, such as nvme0n1, separate the number with a "p".
+func PartitionPath(disk string, n int) string {
+	if last := disk[len(disk)-1]; last >= '0' && last <= '9' {
+		return fmt.Sprintf("%sp%d", disk, n)
+	}
+	return fmt.Sprintf("%s%d", disk, n)
+}
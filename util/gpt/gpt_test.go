@@ -0,0 +1,111 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package gpt
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestGUID(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, LinuxFilesystem.String(), "0FC63DAF-8483-4772-8E79-3D69D8477DE4")
+	TestEqual(t, LinuxFilesystem[0], byte(0xaf))
+
+	g, err := randomGUID()
+	TestExpectSuccess(t, err)
+	TestEqual(t, g[7]>>4, byte(4))
+}
+
+func TestPartition(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	f, err := ioutil.TempFile("", "gpt")
+	TestExpectSuccess(t, err)
+	defer os.Remove(f.Name())
+	size := int64(16 << 20)
+	TestExpectSuccess(t, f.Truncate(size))
+	f.Close()
+
+	blank, err := IsBlank(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, blank, true)
+	table, err := HasPartitionTable(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, table, false)
+
+	TestExpectSuccess(t, Partition(f.Name(), "data"))
+
+	blank, err = IsBlank(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, blank, false)
+	table, err = HasPartitionTable(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, table, true)
+
+	b, err := ioutil.ReadFile(f.Name())
+	TestExpectSuccess(t, err)
+	lastLBA := uint64(size/512 - 1)
+
+	// the protective MBR
+	TestEqual(t, b[446+4], byte(0xee))
+	TestEqual(t, b[510], byte(0x55))
+	TestEqual(t, b[511], byte(0xaa))
+
+	// both headers are valid and point at each other and their entries
+	checkHeader := func(lba, other, entriesLBA uint64) {
+		h := append([]byte(nil), b[lba*512:lba*512+headerSize]...)
+		TestEqual(t, string(h[0:8]), "EFI PART")
+		TestEqual(t, binary.LittleEndian.Uint64(h[24:]), lba)
+		TestEqual(t, binary.LittleEndian.Uint64(h[32:]), other)
+		TestEqual(t, binary.LittleEndian.Uint64(h[72:]), entriesLBA)
+		crc := binary.LittleEndian.Uint32(h[16:])
+		binary.LittleEndian.PutUint32(h[16:], 0)
+		TestEqual(t, crc32.ChecksumIEEE(h), crc)
+
+		entries := b[entriesLBA*512 : entriesLBA*512+numEntries*entrySize]
+		TestEqual(t, crc32.ChecksumIEEE(entries), binary.LittleEndian.Uint32(h[88:]))
+	}
+	checkHeader(1, lastLBA, 2)
+	checkHeader(lastLBA, 1, lastLBA-32)
+
+	// the partition is aligned and ends at the last usable sector
+	entry := b[2*512:]
+	var partType GUID
+	copy(partType[:], entry[0:16])
+	TestEqual(t, partType, LinuxFilesystem)
+	TestEqual(t, binary.LittleEndian.Uint64(entry[32:]), uint64(2048))
+	TestEqual(t, binary.LittleEndian.Uint64(entry[40:]), lastLBA-33)
+	TestEqual(t, binary.LittleEndian.Uint16(entry[56:]), uint16('d'))
+}
+
+func TestPartitionTooSmall(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	f, err := ioutil.TempFile("", "gpt")
+	TestExpectSuccess(t, err)
+	defer os.Remove(f.Name())
+	TestExpectSuccess(t, f.Truncate(32<<10))
+	f.Close()
+
+	TestExpectError(t, Partition(f.Name(), "data"))
+}
+
+func TestPartitionPath(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, PartitionPath("/dev/sdb", 1), "/dev/sdb1")
+	TestEqual(t, PartitionPath("/dev/xvdf", 1), "/dev/xvdf1")
+	TestEqual(t, PartitionPath("/dev/nvme0n1", 1), "/dev/nvme0n1p1")
+	TestEqual(t, PartitionPath("/dev/mmcblk0", 2), "/dev/mmcblk0p2")
+}
@@ -0,0 +1,237 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"unicode/utf16"
+)
+
+// The attribute bits a bootloader chooses which of several partitions to boot
+// by, as ChromeOS and CoreOS's gptprio do. The partition with the highest
+// priority which has either booted successfully or has tries left is booted,
+// and its tries are decremented by the bootloader until it is marked
+// successful.
+const (
+	priorityShift   = 48
+	triesShift      = 52
+	successfulShift = 56
+
+	// MaxPriority and MaxTries are the largest values the attributes hold.
+	MaxPriority = 15
+	MaxTries    = 15
+
+	priorityMask = uint64(0xf)<<priorityShift | uint64(0xf)<<triesShift | uint64(1)<<successfulShift
+)
+
+// Entry is a partition within a GUID partition table.
+type Entry struct {
+	// Number is the partition's number, counting from 1, as the kernel names
+	// its node with.
+	Number int
+
+	Type       GUID
+	GUID       GUID
+	FirstLBA   uint64
+	LastLBA    uint64
+	Attributes uint64
+	Name       string
+}
+
+// Priority returns the partition's boot priority, from 0, which is never
+// booted, to MaxPriority.
+func (e *Entry) Priority() int {
+	return int(e.Attributes >> priorityShift & 0xf)
+}
+
+// Tries returns how many more times the bootloader will try booting the
+// partition before it has booted successfully.
+func (e *Entry) Tries() int {
+	return int(e.Attributes >> triesShift & 0xf)
+}
+
+// Successful returns whether the partition has been marked as having booted
+// successfully.
+func (e *Entry) Successful() bool {
+	return e.Attributes>>successfulShift&1 == 1
+}
+
+// Bootable returns whether the bootloader would consider booting the
+// partition.
+func (e *Entry) Bootable() bool {
+	return e.Priority() > 0 && (e.Successful() || e.Tries() > 0)
+}
+
+// PriorityAttributes returns the attributes with the boot priority, tries, and
+// whether the partition booted successfully replaced, leaving the others.
+func PriorityAttributes(attributes uint64, priority, tries int, successful bool) uint64 {
+	attributes &^= priorityMask
+	attributes |= uint64(priority&0xf) << priorityShift
+	attributes |= uint64(tries&0xf) << triesShift
+	if successful {
+		attributes |= uint64(1) << successfulShift
+	}
+	return attributes
+}
+
+// table is a partition table as read from a disk, along with what is needed to
+// write its entries back.
+type table struct {
+	sectorSize uint64
+	primary    []byte
+	backup     []byte
+	entries    []byte
+	entrySize  uint64
+}
+
+// ReadTable returns the partitions in the disk's GUID partition table, in the
+// order of their numbers. Unused entries are skipped.
+func ReadTable(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := readTable(f)
+	if err != nil {
+		return nil, err
+	}
+	var parts []*Entry
+	for i := uint64(0); i*t.entrySize < uint64(len(t.entries)); i++ {
+		raw := t.entries[i*t.entrySize : (i+1)*t.entrySize]
+		e := &Entry{Number: int(i) + 1}
+		copy(e.Type[:], raw[0:16])
+		if e.Type == (GUID{}) {
+			continue
+		}
+		copy(e.GUID[:], raw[16:32])
+		e.FirstLBA = binary.LittleEndian.Uint64(raw[32:])
+		e.LastLBA = binary.LittleEndian.Uint64(raw[40:])
+		e.Attributes = binary.LittleEndian.Uint64(raw[48:])
+		e.Name = decodeName(raw[56:128])
+		parts = append(parts, e)
+	}
+	return parts, nil
+}
+
+// SetAttributes replaces the attributes of the numbered partition, in both the
+// primary and the backup partition tables.
+func SetAttributes(path string, number int, attributes uint64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t, err := readTable(f)
+	if err != nil {
+		return err
+	}
+	if number < 1 || uint64(number)*t.entrySize > uint64(len(t.entries)) {
+		return fmt.Errorf("%s has no partition %d", path, number)
+	}
+	raw := t.entries[uint64(number-1)*t.entrySize:]
+	if bytes.Equal(raw[0:16], make([]byte, 16)) {
+		return fmt.Errorf("%s has no partition %d", path, number)
+	}
+	binary.LittleEndian.PutUint64(raw[48:], attributes)
+	entriesCRC := crc32.ChecksumIEEE(t.entries)
+
+	// The backup is written first, so that the primary, which is what is read,
+	// is only changed once there is a valid copy of it.
+	for _, h := range [][]byte{t.backup, t.primary} {
+		size := binary.LittleEndian.Uint32(h[12:])
+		binary.LittleEndian.PutUint32(h[88:], entriesCRC)
+		binary.LittleEndian.PutUint32(h[16:], 0)
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h[:size]))
+
+		entriesLBA := binary.LittleEndian.Uint64(h[72:])
+		if _, err := f.WriteAt(t.entries, int64(entriesLBA*t.sectorSize)); err != nil {
+			return err
+		}
+		lba := binary.LittleEndian.Uint64(h[24:])
+		if _, err := f.WriteAt(h, int64(lba*t.sectorSize)); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTable reads the primary header and the entries it points at, and the
+// backup header, checking each is intact.
+func readTable(f *os.File) (*table, error) {
+	sectorSize, _, err := geometry(f)
+	if err != nil {
+		return nil, err
+	}
+	t := &table{sectorSize: sectorSize}
+	if t.primary, err = readHeader(f, sectorSize, 1); err != nil {
+		return nil, fmt.Errorf("failed to read the partition table of %s: %v", f.Name(), err)
+	}
+
+	count := uint64(binary.LittleEndian.Uint32(t.primary[80:]))
+	t.entrySize = uint64(binary.LittleEndian.Uint32(t.primary[84:]))
+	if t.entrySize < entrySize || count == 0 || count*t.entrySize > 1<<20 {
+		return nil, fmt.Errorf("the partition table of %s has an invalid entry size or count", f.Name())
+	}
+	t.entries = make([]byte, count*t.entrySize)
+	entriesLBA := binary.LittleEndian.Uint64(t.primary[72:])
+	if _, err := f.ReadAt(t.entries, int64(entriesLBA*sectorSize)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(t.entries) != binary.LittleEndian.Uint32(t.primary[88:]) {
+		return nil, fmt.Errorf("the partition entries of %s are corrupt", f.Name())
+	}
+
+	backupLBA := binary.LittleEndian.Uint64(t.primary[32:])
+	if t.backup, err = readHeader(f, sectorSize, backupLBA); err != nil {
+		return nil, fmt.Errorf("failed to read the backup partition table of %s: %v", f.Name(), err)
+	}
+	return t, nil
+}
+
+// readHeader reads the partition table header at the LBA and checks its
+// signature and checksum.
+func readHeader(f *os.File, sectorSize, lba uint64) ([]byte, error) {
+	h := make([]byte, sectorSize)
+	if _, err := f.ReadAt(h, int64(lba*sectorSize)); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h[0:8], signature) {
+		return nil, fmt.Errorf("no GUID partition table was found")
+	}
+	size := binary.LittleEndian.Uint32(h[12:])
+	if size < headerSize || uint64(size) > sectorSize {
+		return nil, fmt.Errorf("the header has an invalid size")
+	}
+	check := append([]byte(nil), h[:size]...)
+	binary.LittleEndian.PutUint32(check[16:], 0)
+	if crc32.ChecksumIEEE(check) != binary.LittleEndian.Uint32(h[16:]) {
+		return nil, fmt.Errorf("the header is corrupt")
+	}
+	if binary.LittleEndian.Uint64(h[24:]) != lba {
+		return nil, fmt.Errorf("the header is misplaced")
+	}
+	return h, nil
+}
+
+// decodeName decodes a partition's UTF-16 name, which is padded with zeros.
+func decodeName(b []byte) string {
+	var chars []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package gpt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestPriorityAttributes(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// other attributes, such as the required partition bit, are kept
+	e := &Entry{Attributes: PriorityAttributes(1, 2, 1, false)}
+	TestEqual(t, e.Attributes&1, uint64(1))
+	TestEqual(t, e.Priority(), 2)
+	TestEqual(t, e.Tries(), 1)
+	TestFalse(t, e.Successful())
+	TestTrue(t, e.Bootable())
+
+	e.Attributes = PriorityAttributes(e.Attributes, 2, 0, false)
+	TestFalse(t, e.Bootable())
+	e.Attributes = PriorityAttributes(e.Attributes, 2, 0, true)
+	TestTrue(t, e.Successful())
+	TestTrue(t, e.Bootable())
+	e.Attributes = PriorityAttributes(e.Attributes, 0, 0, true)
+	TestFalse(t, e.Bootable())
+}
+
+func TestSetAttributes(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	f, err := ioutil.TempFile("", "gpt")
+	TestExpectSuccess(t, err)
+	defer os.Remove(f.Name())
+	TestExpectSuccess(t, f.Truncate(16<<20))
+	f.Close()
+
+	_, err = ReadTable(f.Name())
+	TestExpectError(t, err)
+
+	TestExpectSuccess(t, Partition(f.Name(), "KURMA-A"))
+	parts, err := ReadTable(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(parts), 1)
+	TestEqual(t, parts[0].Number, 1)
+	TestEqual(t, parts[0].Name, "KURMA-A")
+	TestEqual(t, parts[0].Type, LinuxFilesystem)
+	TestEqual(t, parts[0].FirstLBA, uint64(2048))
+	TestEqual(t, parts[0].Attributes, uint64(0))
+
+	attrs := PriorityAttributes(0, 3, 1, false)
+	TestExpectSuccess(t, SetAttributes(f.Name(), 1, attrs))
+	TestExpectError(t, SetAttributes(f.Name(), 2, attrs))
+	TestExpectError(t, SetAttributes(f.Name(), 0, attrs))
+
+	parts, err = ReadTable(f.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, parts[0].Attributes, attrs)
+
+	// the backup table is updated as well, and still valid
+	fh, err := os.Open(f.Name())
+	TestExpectSuccess(t, err)
+	defer fh.Close()
+	tbl, err := readTable(fh)
+	TestExpectSuccess(t, err)
+	backupLBA := uint64(16<<20)/512 - 1
+	b, err := ioutil.ReadFile(f.Name())
+	TestExpectSuccess(t, err)
+	entries := b[(backupLBA-32)*512 : (backupLBA-32)*512+numEntries*entrySize]
+	TestEqual(t, entries, tbl.entries)
+
+	// a corrupt primary table isn't used
+	b[2*512+100] ^= 0xff
+	TestExpectSuccess(t, ioutil.WriteFile(f.Name(), b, 0644))
+	_, err = ReadTable(f.Name())
+	TestExpectError(t, err)
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package hugepages reserves the kernel's hugepages and names their page sizes
+// the way the kernel does, for mounting hugetlbfs filesystems of each size.
+package hugepages
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// sysfsDir holds a directory for each page size the kernel supports.
+	sysfsDir = "/sys/kernel/mm/hugepages"
+
+	// meminfoFile gives the kernel's default page size.
+	meminfoFile = "/proc/meminfo"
+)
+
+// SizeName returns the name the kernel gives the page size, in bytes, in the
+// hugetlb cgroup controller's files, such as 2MB or 1GB.
+func SizeName(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%dGB", size>>30)
+	case size >= 1<<20:
+		return fmt.Sprintf("%dMB", size>>20)
+	default:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+}
+
+// MountPath returns where the hugetlbfs of the page size is mounted, given the
+// path the default size's is mounted at. Other sizes have theirs beside it with
+// the size appended, such as /dev/hugepages-1GB.
+func MountPath(path string, size, defaultSize int64) string {
+	if size == defaultSize {
+		return path
+	}
+	return path + "-" + SizeName(size)
+}
+
+// Supported returns whether the kernel supports pages of the size.
+func Supported(size int64) bool {
+	_, err := os.Stat(sizeDir(size))
+	return err == nil
+}
+
+// Reserve has the kernel keep count pages of the size for processes to use,
+// and returns how many it did, which may be fewer if memory is fragmented.
+func Reserve(size int64, count int) (int, error) {
+	fn := filepath.Join(sizeDir(size), "nr_hugepages")
+	if err := ioutil.WriteFile(fn, []byte(strconv.Itoa(count)), 0644); err != nil {
+		return 0, err
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// DefaultSize returns the kernel's default page size in bytes.
+func DefaultSize() (int64, error) {
+	f, err := os.Open(meminfoFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+// parseMeminfo returns the page size from the Hugepagesize line of
+// /proc/meminfo, which is given in kB.
+func parseMeminfo(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "Hugepagesize:" || fields[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid hugepage size %q", fields[1])
+		}
+		return kb << 10, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("the kernel doesn't support hugepages")
+}
+
+// sizeDir returns the kernel's directory for pages of the size.
+func sizeDir(size int64) string {
+	return filepath.Join(sysfsDir, fmt.Sprintf("hugepages-%dkB", size>>10))
+}
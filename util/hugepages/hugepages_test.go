@@ -0,0 +1,58 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package hugepages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestSizeName(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, SizeName(64<<10), "64KB")
+	TestEqual(t, SizeName(2<<20), "2MB")
+	TestEqual(t, SizeName(1<<30), "1GB")
+	TestEqual(t, MountPath("/dev/hugepages", 2<<20, 2<<20), "/dev/hugepages")
+	TestEqual(t, MountPath("/dev/hugepages", 1<<30, 2<<20), "/dev/hugepages-1GB")
+}
+
+func TestParseMeminfo(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	size, err := parseMeminfo(strings.NewReader("MemTotal:  16305724 kB\nHugePages_Total:  0\nHugepagesize:   2048 kB\n"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, size, int64(2<<20))
+
+	_, err = parseMeminfo(strings.NewReader("MemTotal:  16305724 kB\n"))
+	TestExpectError(t, err)
+}
+
+func TestReserve(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(d string) { sysfsDir = d }(sysfsDir)
+	sysfsDir = TempDir(t)
+	TestFalse(t, Supported(2<<20))
+	dir := filepath.Join(sysfsDir, "hugepages-2048kB")
+	TestExpectSuccess(t, os.MkdirAll(dir, 0755))
+	TestTrue(t, Supported(2<<20))
+
+	n, err := Reserve(2<<20, 128)
+	TestExpectSuccess(t, err)
+	TestEqual(t, n, 128)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "nr_hugepages"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "128")
+
+	_, err = Reserve(1<<30, 1)
+	TestExpectError(t, err)
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/apcera/logray"
+)
+
+// consoleOutput writes the lines to stdout.
+type consoleOutput struct {
+	format formatter
+	mutex  sync.Mutex
+}
+
+// newConsoleOutput parses a console:// URL, which only takes a format.
+func newConsoleOutput(u *url.URL) (logray.Output, error) {
+	if u.Host != "" || u.Path != "" {
+		return nil, fmt.Errorf("console output can't have a host or path")
+	}
+	values := u.Query()
+	format, err := parseFormat(values, "text")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValues(values); err != nil {
+		return nil, err
+	}
+	return &consoleOutput{format: format}, nil
+}
+
+func (o *consoleOutput) Write(ld *logray.LineData) error {
+	b := append(o.format(ld), '\n')
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	_, err := os.Stdout.Write(b)
+	return err
+}
+
+func (o *consoleOutput) Flush() error {
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/apcera/logray"
+)
+
+const (
+	// The defaults for how large a log file may grow before it is rotated, and
+	// how many rotated files are kept.
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 5
+)
+
+// fileOutput appends the lines to a file. Once writing a line would take it
+// past its maximum size, it is renamed with a .1 suffix, the files previously
+// rotated are shifted up by one, and those past the maximum number of files
+// are removed.
+type fileOutput struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	format   formatter
+
+	file  *os.File
+	size  int64
+	mutex sync.Mutex
+}
+
+// newFileOutput parses a logfile:// URL, creating the file's directory if it
+// doesn't exist.
+func newFileOutput(u *url.URL) (logray.Output, error) {
+	if u.Host != "" || u.Path == "" {
+		return nil, fmt.Errorf("logfile output must have a path and no host")
+	}
+	values := u.Query()
+	format, err := parseFormat(values, "text")
+	if err != nil {
+		return nil, err
+	}
+	maxSizeMB, err := intValue(values, "max_size_mb", defaultMaxSizeMB)
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := intValue(values, "max_files", defaultMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValues(values); err != nil {
+		return nil, err
+	}
+	if maxSizeMB <= 0 || maxFiles < 0 {
+		return nil, fmt.Errorf("max_size_mb must be positive and max_files must not be negative")
+	}
+
+	o := &fileOutput{
+		path:     u.Path,
+		maxSize:  int64(maxSizeMB) << 20,
+		maxFiles: maxFiles,
+		format:   format,
+	}
+	if err := os.MkdirAll(filepath.Dir(o.path), os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	if err := o.open(os.O_APPEND); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// open opens the log file with the flag, and records its size.
+func (o *fileOutput) open(flag int) error {
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|flag, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	o.file, o.size = f, fi.Size()
+	return nil
+}
+
+func (o *fileOutput) Write(ld *logray.LineData) error {
+	b := append(o.format(ld), '\n')
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.size > 0 && o.size+int64(len(b)) > o.maxSize {
+		if err := o.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := o.file.Write(b)
+	o.size += int64(n)
+	return err
+}
+
+// rotate moves the current file aside and starts a new one.
+func (o *fileOutput) rotate() error {
+	o.file.Close()
+	os.Remove(rotatedPath(o.path, o.maxFiles))
+	for i := o.maxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedPath(o.path, i), rotatedPath(o.path, i+1))
+	}
+	if o.maxFiles > 0 {
+		os.Rename(o.path, rotatedPath(o.path, 1))
+	}
+	return o.open(os.O_TRUNC)
+}
+
+func (o *fileOutput) Flush() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.file.Sync()
+}
+
+// rotatedPath returns the path of the nth rotated file.
+func rotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// intValue removes the named parameter from the values and returns it as an
+// integer, or the default if it isn't given.
+func intValue(values url.Values, name string, def int) (int, error) {
+	s := values.Get(name)
+	delete(values, name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/apcera/logray"
+)
+
+const (
+	// httpBatchSize and httpBatchWait are how many lines are sent in each
+	// request, and how long lines are collected for before a smaller batch is
+	// sent.
+	httpBatchSize = 100
+	httpBatchWait = time.Second
+)
+
+// httpOutput POSTs batches of lines to an HTTP endpoint, one line per line of
+// the body. Batches which fail are dropped.
+type httpOutput struct {
+	url         string
+	format      formatter
+	contentType string
+
+	client *http.Client
+	queue  *queue
+}
+
+// newHTTPOutput parses an http:// or https:// URL. The format parameter is
+// removed, and any others are left as part of the endpoint's URL.
+func newHTTPOutput(u *url.URL) (logray.Output, error) {
+	values := u.Query()
+	contentType := "application/x-ndjson"
+	if values.Get("format") == "text" {
+		contentType = "text/plain"
+	}
+	format, err := parseFormat(values, "json")
+	if err != nil {
+		return nil, err
+	}
+	endpoint := *u
+	endpoint.RawQuery = values.Encode()
+
+	o := &httpOutput{
+		url:         endpoint.String(),
+		format:      format,
+		contentType: contentType,
+		client:      &http.Client{Timeout: 2 * networkTimeout},
+	}
+	o.queue = newQueue(10*httpBatchSize, httpBatchSize, httpBatchWait, o.send)
+	return o, nil
+}
+
+func (o *httpOutput) Write(ld *logray.LineData) error {
+	o.queue.push(o.format(ld))
+	return nil
+}
+
+func (o *httpOutput) Flush() error {
+	return nil
+}
+
+// send POSTs the batch of lines.
+func (o *httpOutput) send(lines [][]byte) {
+	var body bytes.Buffer
+	for _, line := range lines {
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	resp, err := o.client.Post(o.url, o.contentType, &body)
+	if err != nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apcera/logray"
+)
+
+// defaultJournaldSocket is where journald receives entries in its native
+// protocol.
+const defaultJournaldSocket = "/run/systemd/journal/socket"
+
+// journaldOutput sends the lines to journald using its native protocol, which
+// keeps the line's fields as fields of the journal entry.
+type journaldOutput struct {
+	path string
+	tag  string
+
+	conn  net.Conn
+	queue *queue
+}
+
+// newJournaldOutput parses a journald:// URL, whose path is journald's socket.
+func newJournaldOutput(u *url.URL) (logray.Output, error) {
+	if u.Host != "" {
+		return nil, fmt.Errorf("journald output can't have a host")
+	}
+	values := u.Query()
+	o := &journaldOutput{path: u.Path, tag: values.Get("tag")}
+	delete(values, "tag")
+	if err := checkValues(values); err != nil {
+		return nil, err
+	}
+	if o.path == "" {
+		o.path = defaultJournaldSocket
+	}
+	if o.tag == "" {
+		o.tag = "kurma"
+	}
+
+	o.queue = newQueue(queueSize, 100, 0, o.send)
+	return o, nil
+}
+
+func (o *journaldOutput) Write(ld *logray.LineData) error {
+	o.queue.push(o.entry(ld))
+	return nil
+}
+
+func (o *journaldOutput) Flush() error {
+	return nil
+}
+
+// entry renders the line as a journal entry.
+func (o *journaldOutput) entry(ld *logray.LineData) []byte {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", ld.Message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(severity(ld.Class)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", o.tag)
+	if ld.SourceFile != "" {
+		writeJournalField(&b, "CODE_FILE", ld.SourceFile)
+		writeJournalField(&b, "CODE_LINE", strconv.Itoa(ld.SourceLine))
+	}
+	if ld.CallingFunction != "" {
+		writeJournalField(&b, "CODE_FUNC", ld.CallingFunction)
+	}
+	for _, k := range sortedKeys(ld.Fields) {
+		writeJournalField(&b, journalFieldName(k), fmt.Sprint(ld.Fields[k]))
+	}
+	return b.Bytes()
+}
+
+// send sends each entry as a datagram to journald's socket. Entries which
+// can't be sent are dropped.
+func (o *journaldOutput) send(entries [][]byte) {
+	for _, entry := range entries {
+		if o.conn == nil {
+			conn, err := net.DialTimeout("unixgram", o.path, networkTimeout)
+			if err != nil {
+				return
+			}
+			o.conn = conn
+		}
+		o.conn.SetWriteDeadline(time.Now().Add(networkTimeout))
+		if _, err := o.conn.Write(entry); err != nil {
+			o.conn.Close()
+			o.conn = nil
+		}
+	}
+}
+
+// writeJournalField writes the field as NAME=value, or in the binary form,
+// with the length of the value before it, if the value has a newline.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName makes the field name a valid journal field name, which is
+// made of uppercase letters, digits, and underscores, and doesn't start with
+// an underscore or a digit.
+func journalFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F" + name
+	}
+	return name
+}
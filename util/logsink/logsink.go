@@ -0,0 +1,191 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package logsink registers additional logray outputs, which are selected by
+// the scheme of their URL:
+//
+//	console://?format=json         the process's stdout
+//	logfile:///var/log/kurma.log?max_size_mb=10&max_files=5
+//	                               a local file, rotated once it is too large
+//	syslog://HOST:514?protocol=udp&facility=daemon&tag=kurma
+//	                               a remote syslog server, using RFC 5424
+//	journald:///run/systemd/journal/socket?tag=kurma
+//	                               journald's native protocol
+//	http://HOST/PATH, https://HOST/PATH
+//	                               batches of lines POSTed to the endpoint
+//
+// The format is either "text" or "json", which writes each line as an object
+// with its time, level, message, and fields. Outputs which send the lines
+// elsewhere queue them, and drop them while the destination can't keep up,
+// rather than blocking the process's logging.
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apcera/logray"
+)
+
+func init() {
+	logray.AddNewOutputFunc("console", newConsoleOutput)
+	logray.AddNewOutputFunc("logfile", newFileOutput)
+	logray.AddNewOutputFunc("syslog", newSyslogOutput)
+	logray.AddNewOutputFunc("journald", newJournaldOutput)
+	logray.AddNewOutputFunc("http", newHTTPOutput)
+	logray.AddNewOutputFunc("https", newHTTPOutput)
+}
+
+// formatter renders a log line, without a trailing newline.
+type formatter func(ld *logray.LineData) []byte
+
+// parseFormat removes the format parameter from the values, and returns its
+// formatter, or the default's if it isn't given.
+func parseFormat(values url.Values, def string) (formatter, error) {
+	format := values.Get("format")
+	delete(values, "format")
+	if format == "" {
+		format = def
+	}
+	switch format {
+	case "text":
+		return formatText, nil
+	case "json":
+		return formatJSON, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// checkValues returns an error if any parameters are left in the values once
+// those which are understood have been removed.
+func checkValues(values url.Values) error {
+	if len(values) == 0 {
+		return nil
+	}
+	bad := make([]string, 0, len(values))
+	for k := range values {
+		bad = append(bad, k)
+	}
+	sort.Strings(bad)
+	return fmt.Errorf("unknown parameters: %s", strings.Join(bad, ","))
+}
+
+// formatText renders the line as its time, level, and message, followed by its
+// fields as key=value pairs.
+func formatText(ld *logray.LineData) []byte {
+	var b bytes.Buffer
+	b.WriteString(ld.TimeStamp.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, " [%s] %s", ld.Class, ld.Message)
+	for _, k := range sortedKeys(ld.Fields) {
+		fmt.Fprintf(&b, " %s=%v", k, ld.Fields[k])
+	}
+	return b.Bytes()
+}
+
+// jsonLine is how a line is rendered in the json format.
+type jsonLine struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Source  string                 `json:"source,omitempty"`
+}
+
+// formatJSON renders the line as a JSON object. Fields which can't be
+// marshaled are rendered as strings.
+func formatJSON(ld *logray.LineData) []byte {
+	line := &jsonLine{
+		Time:    ld.TimeStamp.UTC(),
+		Level:   ld.Class.String(),
+		Message: ld.Message,
+		Fields:  ld.Fields,
+	}
+	if ld.SourceFile != "" {
+		line.Source = fmt.Sprintf("%s:%d", ld.SourceFile, ld.SourceLine)
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		fields := make(map[string]interface{}, len(ld.Fields))
+		for k, v := range ld.Fields {
+			fields[k] = fmt.Sprint(v)
+		}
+		line.Fields = fields
+		b, _ = json.Marshal(line)
+	}
+	return b
+}
+
+// sortedKeys returns the keys of the fields in order, so they're always
+// rendered the same way.
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// severity returns the syslog severity of the log class, which journald uses
+// as well.
+func severity(class logray.LogClass) int {
+	switch {
+	case class&logray.FATAL != 0:
+		return 2
+	case class&logray.ERROR != 0:
+		return 3
+	case class&logray.WARN != 0:
+		return 4
+	case class&logray.INFO != 0:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// queue sends lines to a destination from its own goroutine, in batches of up
+// to batchSize which are collected for up to wait. Lines are dropped while the
+// queue is full.
+type queue struct {
+	lines chan []byte
+}
+
+// newQueue creates a queue holding up to size lines, which calls send with
+// each batch.
+func newQueue(size, batchSize int, wait time.Duration, send func(lines [][]byte)) *queue {
+	q := &queue{lines: make(chan []byte, size)}
+	go q.run(batchSize, wait, send)
+	return q
+}
+
+// push adds the line to the queue, unless it is full.
+func (q *queue) push(line []byte) {
+	select {
+	case q.lines <- line:
+	default:
+	}
+}
+
+// run collects the batches and sends them.
+func (q *queue) run(batchSize int, wait time.Duration, send func(lines [][]byte)) {
+	for line := range q.lines {
+		batch := [][]byte{line}
+		timeout := time.After(wait)
+	collect:
+		for len(batch) < batchSize {
+			select {
+			case line := <-q.lines:
+				batch = append(batch, line)
+			case <-timeout:
+				break collect
+			}
+		}
+		send(batch)
+	}
+}
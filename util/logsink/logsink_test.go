@@ -0,0 +1,156 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apcera/logray"
+	. "github.com/apcera/util/testtool"
+)
+
+func testLine(message string) *logray.LineData {
+	return &logray.LineData{
+		Message:   message,
+		Class:     logray.WARN,
+		TimeStamp: time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC),
+		Fields:    map[string]interface{}{"container": "abc", "app": "web"},
+	}
+}
+
+func parseOutput(t *testing.T, uri string, f logray.NewOutputFunc) logray.Output {
+	u, err := url.Parse(uri)
+	TestExpectSuccess(t, err)
+	o, err := f(u)
+	TestExpectSuccess(t, err)
+	return o
+}
+
+func TestFormats(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, string(formatText(testLine("hello"))),
+		"2015-06-01T12:30:00Z [warn] hello app=web container=abc")
+
+	var line map[string]interface{}
+	TestExpectSuccess(t, json.Unmarshal(formatJSON(testLine("hello")), &line))
+	TestEqual(t, line["time"], "2015-06-01T12:30:00Z")
+	TestEqual(t, line["level"], "warn")
+	TestEqual(t, line["message"], "hello")
+	TestEqual(t, line["fields"], map[string]interface{}{"container": "abc", "app": "web"})
+
+	_, err := parseFormat(url.Values{"format": {"xml"}}, "text")
+	TestExpectError(t, err)
+	TestExpectError(t, checkValues(url.Values{"bogus": {"1"}}))
+}
+
+func TestFileRotation(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir, err := ioutil.TempDir("", "logsink")
+	TestExpectSuccess(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "kurma.log")
+
+	o := parseOutput(t, "logfile://"+path+"?max_files=2", newFileOutput).(*fileOutput)
+	line := append(formatText(testLine("hello")), '\n')
+	o.maxSize = int64(2 * len(line))
+
+	// each file holds two lines, and only two rotated files are kept
+	for i := 0; i < 7; i++ {
+		TestExpectSuccess(t, o.Write(testLine("hello")))
+	}
+	lines := map[string]int{path: 1, path + ".1": 2, path + ".2": 2}
+	for p, n := range lines {
+		b, err := ioutil.ReadFile(p)
+		TestExpectSuccess(t, err)
+		TestEqual(t, bytes.Count(b, []byte("\n")), n)
+	}
+	_, err = os.Stat(path + ".3")
+	TestEqual(t, os.IsNotExist(err), true)
+}
+
+func TestSyslog(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	defer conn.Close()
+
+	o := parseOutput(t, "syslog://"+conn.LocalAddr().String()+"?facility=local0&tag=test", newSyslogOutput)
+	TestExpectSuccess(t, o.Write(testLine(`say "hi"`)))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	TestExpectSuccess(t, err)
+
+	// local0 is 16, and warn is severity 4
+	want := fmt.Sprintf(`<132>1 2015-06-01T12:30:00.000000Z %s test %d - [fields@32473 app="web" container="abc"] say "hi"`,
+		o.(*syslogOutput).hostname, os.Getpid())
+	TestEqual(t, string(buf[:n]), want)
+
+	_, err = newSyslogOutput(&url.URL{Scheme: "syslog", Host: "localhost", RawQuery: "facility=bogus"})
+	TestExpectError(t, err)
+}
+
+func TestJournaldEntry(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	o := parseOutput(t, "journald:///nonexistent/socket", newJournaldOutput).(*journaldOutput)
+	ld := testLine("two\nlines")
+	ld.Fields = map[string]interface{}{"container-id": "abc", "1st": "x"}
+
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n")
+	want.WriteString("PRIORITY=4\nSYSLOG_IDENTIFIER=kurma\n")
+	want.WriteString("F1ST=x\nCONTAINER_ID=abc\n")
+	TestEqual(t, string(o.entry(ld)), want.String())
+}
+
+func TestHTTP(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" || r.URL.Query().Get("token") != "x" {
+			bodies <- "unexpected request to " + r.URL.String()
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer server.Close()
+
+	o := parseOutput(t, server.URL+"/logs?token=x", newHTTPOutput)
+	TestExpectSuccess(t, o.Write(testLine("one")))
+	TestExpectSuccess(t, o.Write(testLine("two")))
+
+	select {
+	case body := <-bodies:
+		lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+		if len(lines) != 2 {
+			Fatalf(t, "unexpected body: %q", body)
+		}
+		TestEqual(t, strings.Contains(lines[1], `"message":"two"`), true)
+	case <-time.After(5 * time.Second):
+		Fatalf(t, "no logs were posted")
+	}
+}
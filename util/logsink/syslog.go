@@ -0,0 +1,185 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logsink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apcera/logray"
+)
+
+const (
+	// syslogTimeFormat is the RFC 5424 timestamp, with microseconds.
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	// syslogFieldsID is the ID of the structured data element holding a
+	// line's fields, under the enterprise number reserved for examples.
+	syslogFieldsID = "fields@32473"
+
+	// networkTimeout is how long connecting to a remote destination and
+	// sending to it may take.
+	networkTimeout = 5 * time.Second
+
+	// queueSize is how many lines are queued for a remote destination.
+	queueSize = 1000
+)
+
+// syslogFacilities are the syslog facilities by name.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogOutput sends the lines to a remote syslog server in the RFC 5424
+// format, over UDP or over TCP with octet counting framing. In the text
+// format, the message is the line's message with its fields as structured
+// data, while in the json format it is the whole line.
+type syslogOutput struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	json     bool
+
+	conn  net.Conn
+	queue *queue
+}
+
+// newSyslogOutput parses a syslog:// URL.
+func newSyslogOutput(u *url.URL) (logray.Output, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("syslog output must have a host")
+	}
+	values := u.Query()
+	o := &syslogOutput{
+		network: values.Get("protocol"),
+		address: u.Host,
+		tag:     values.Get("tag"),
+	}
+	format := values.Get("format")
+	if _, err := parseFormat(values, "text"); err != nil {
+		return nil, err
+	}
+	o.json = format == "json"
+	facility := values.Get("facility")
+	delete(values, "protocol")
+	delete(values, "tag")
+	delete(values, "facility")
+	if err := checkValues(values); err != nil {
+		return nil, err
+	}
+
+	switch o.network {
+	case "":
+		o.network = "udp"
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported syslog protocol %q", o.network)
+	}
+	if _, _, err := net.SplitHostPort(o.address); err != nil {
+		o.address = net.JoinHostPort(o.address, "514")
+	}
+	if facility == "" {
+		facility = "daemon"
+	}
+	f, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	o.facility = f
+	if o.tag == "" {
+		o.tag = "kurma"
+	}
+	o.hostname, _ = os.Hostname()
+	if o.hostname == "" {
+		o.hostname = "-"
+	}
+
+	o.queue = newQueue(queueSize, 100, 0, o.send)
+	return o, nil
+}
+
+func (o *syslogOutput) Write(ld *logray.LineData) error {
+	o.queue.push(o.message(ld))
+	return nil
+}
+
+func (o *syslogOutput) Flush() error {
+	return nil
+}
+
+// message renders the line as a syslog message.
+func (o *syslogOutput) message(ld *logray.LineData) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - ", o.facility*8+severity(ld.Class),
+		ld.TimeStamp.UTC().Format(syslogTimeFormat), o.hostname, o.tag, os.Getpid())
+	if o.json {
+		b.WriteString("- ")
+		b.Write(formatJSON(ld))
+		return b.Bytes()
+	}
+
+	if len(ld.Fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + syslogFieldsID)
+		for _, k := range sortedKeys(ld.Fields) {
+			fmt.Fprintf(&b, " %s=\"%s\"", syslogParamName(k), syslogParamValue(fmt.Sprint(ld.Fields[k])))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(" " + ld.Message)
+	return b.Bytes()
+}
+
+// send sends the messages, connecting first if needed. Messages which can't be
+// sent are dropped, and the connection is re-established for the next ones.
+func (o *syslogOutput) send(messages [][]byte) {
+	for _, msg := range messages {
+		if o.conn == nil {
+			conn, err := net.DialTimeout(o.network, o.address, networkTimeout)
+			if err != nil {
+				return
+			}
+			o.conn = conn
+		}
+		if o.network == "tcp" {
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		o.conn.SetWriteDeadline(time.Now().Add(networkTimeout))
+		if _, err := o.conn.Write(msg); err != nil {
+			o.conn.Close()
+			o.conn = nil
+		}
+	}
+}
+
+// syslogParamName makes the field name a valid structured data parameter name,
+// which is up to 32 printable characters other than '=', ' ', ']', and '"'.
+func syslogParamName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// syslogParamValue escapes the characters which must be escaped within a
+// structured data parameter value.
+func syslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package lsm detects the Linux Security Module enabled on the host, and
+// formats the labels which confine processes under it.
+package lsm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+)
+
+// Module is a Linux Security Module which can confine processes to a profile
+// or label.
+type Module string
+
+const (
+	// AppArmor confines processes to a named profile, which must already be
+	// loaded into the kernel.
+	AppArmor Module = "apparmor"
+
+	// SELinux runs processes with a security context, such as
+	// "system_u:system_r:svirt_lxc_net_t:s0".
+	SELinux Module = "selinux"
+)
+
+var (
+	// The files checked to find whether each module is enabled. They're
+	// variables so they can be replaced in tests.
+	appArmorEnabledFile = "/sys/module/apparmor/parameters/enabled"
+	seLinuxEnforceFile  = "/sys/fs/selinux/enforce"
+)
+
+// Detect returns the security module enabled on the host, or an empty Module
+// if neither AppArmor nor SELinux is.
+func Detect() Module {
+	if b, err := ioutil.ReadFile(appArmorEnabledFile); err == nil && bytes.HasPrefix(b, []byte("Y")) {
+		return AppArmor
+	}
+	if _, err := os.Stat(seLinuxEnforceFile); err == nil {
+		return SELinux
+	}
+	return ""
+}
+
+// ExecAttribute returns what is written to /proc/self/attr/exec to have the
+// next program the process execs confined by the label.
+func (m Module) ExecAttribute(label string) string {
+	if m == AppArmor {
+		return "exec " + label
+	}
+	return label
+}
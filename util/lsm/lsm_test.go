@@ -0,0 +1,43 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package lsm
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestDetect(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	defer func(a, s string) {
+		appArmorEnabledFile, seLinuxEnforceFile = a, s
+	}(appArmorEnabledFile, seLinuxEnforceFile)
+	dir := TempDir(t)
+	appArmorEnabledFile = filepath.Join(dir, "enabled")
+	seLinuxEnforceFile = filepath.Join(dir, "enforce")
+
+	TestEqual(t, Detect(), Module(""))
+
+	TestExpectSuccess(t, ioutil.WriteFile(seLinuxEnforceFile, []byte("1\n"), 0644))
+	TestEqual(t, Detect(), SELinux)
+
+	// AppArmor is only enabled if the module says so
+	TestExpectSuccess(t, ioutil.WriteFile(appArmorEnabledFile, []byte("N\n"), 0644))
+	TestEqual(t, Detect(), SELinux)
+	TestExpectSuccess(t, ioutil.WriteFile(appArmorEnabledFile, []byte("Y\n"), 0644))
+	TestEqual(t, Detect(), AppArmor)
+}
+
+func TestExecAttribute(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, AppArmor.ExecAttribute("kurma-default"), "exec kurma-default")
+	TestEqual(t, SELinux.ExecAttribute("system_u:system_r:svirt_lxc_net_t:s0"),
+		"system_u:system_r:svirt_lxc_net_t:s0")
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package ns runs code within the namespaces of another process, such as a
+// container's init.
+package ns
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// Namespace is a type of namespace which can be entered.
+type Namespace struct {
+	// Name is the namespace's name within /proc/PID/ns.
+	Name string
+
+	// Flag is the namespace's clone flag.
+	Flag int
+}
+
+var (
+	// IPC is the namespace of System V IPC objects and POSIX message queues.
+	IPC = Namespace{Name: "ipc", Flag: syscall.CLONE_NEWIPC}
+
+	// Net is the namespace of network devices, addresses, and routes.
+	Net = Namespace{Name: "net", Flag: syscall.CLONE_NEWNET}
+)
+
+// Run runs the function within the namespaces of the process. Namespaces are
+// per thread, so the goroutine is locked to its thread while it is in the other
+// namespaces. Mount and user namespaces can't be entered by a multithreaded
+// process, so aren't supported.
+func Run(pid int, namespaces []Namespace, f func() error) error {
+	runtime.LockOSThread()
+
+	var entered []*os.File
+	restore := func() error {
+		for i := len(entered) - 1; i >= 0; i-- {
+			if err := setns(entered[i], namespaces[i]); err != nil {
+				return fmt.Errorf("failed to return to the host %s namespace: %v", namespaces[i].Name, err)
+			}
+		}
+		return nil
+	}
+	defer func() {
+		for _, origin := range entered {
+			origin.Close()
+		}
+	}()
+
+	for _, n := range namespaces {
+		origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/%s", syscall.Gettid(), n.Name))
+		if err != nil {
+			return unlock(restore(), err)
+		}
+		target, err := os.Open(fmt.Sprintf("/proc/%d/ns/%s", pid, n.Name))
+		if err != nil {
+			origin.Close()
+			return unlock(restore(), err)
+		}
+		err = setns(target, n)
+		target.Close()
+		if err != nil {
+			origin.Close()
+			return unlock(restore(), fmt.Errorf("failed to enter the %s namespace of %d: %v", n.Name, pid, err))
+		}
+		entered = append(entered, origin)
+	}
+
+	ferr := f()
+	return unlock(restore(), ferr)
+}
+
+// unlock unlocks the goroutine from its thread if it was returned to its
+// original namespaces, and returns the error which comes first. If the thread
+// couldn't be returned, it is left locked so that it is thrown away rather than
+// used for other goroutines.
+func unlock(restoreErr, err error) error {
+	if restoreErr != nil {
+		return restoreErr
+	}
+	runtime.UnlockOSThread()
+	return err
+}
+
+// setns moves the current thread into the namespace of the file.
+func setns(f *os.File, n Namespace) error {
+	_, _, errno := syscall.RawSyscall(sysSetns, f.Fd(), uintptr(n.Flag), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package ns
+
+// sysSetns is the number of the setns syscall, which the syscall package
+// doesn't define for every architecture.
+const sysSetns = 268
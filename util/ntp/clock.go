@@ -0,0 +1,29 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package ntp
+
+import (
+	"syscall"
+	"time"
+)
+
+// adjOffsetSingleshot has adjtimex gradually apply the offset, like adjtime(3).
+const adjOffsetSingleshot = 0x8001
+
+// Step immediately changes the system clock by the offset.
+func Step(offset time.Duration) error {
+	tv := syscall.NsecToTimeval(time.Now().Add(offset).UnixNano())
+	return syscall.Settimeofday(&tv)
+}
+
+// Slew gradually changes the system clock by the offset, by having the kernel
+// speed up or slow down the clock slightly until the offset has been applied.
+// The clock never jumps, so it should only be used for small offsets.
+func Slew(offset time.Duration) error {
+	tx := &syscall.Timex{
+		Modes:  adjOffsetSingleshot,
+		Offset: int64(offset / time.Microsecond),
+	}
+	_, err := syscall.Adjtimex(tx)
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package ntp implements a simple SNTP client, as described in RFC 4330, used
+// to set the host's clock.
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	port = "123"
+
+	// packetSize is the size of an NTP packet without extensions or an
+	// authenticator.
+	packetSize = 48
+
+	// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and
+	// the unix epoch.
+	ntpEpochOffset = 2208988800
+
+	versionNumber = 4
+	modeClient    = 3
+	modeServer    = 4
+
+	// leapNotSynchronized is the leap indicator of a server whose clock is not
+	// synchronized.
+	leapNotSynchronized = 3
+
+	// maxStratum is the highest valid stratum. Stratum 0 is a kiss-o'-death
+	// message from a server refusing the request.
+	maxStratum = 15
+)
+
+// Response is the result of querying an NTP server.
+type Response struct {
+	// Server is the address of the server which responded.
+	Server string
+
+	// Offset is the difference between the server's clock and the local clock,
+	// which is added to the local clock to correct it.
+	Offset time.Duration
+
+	// RTT is the round trip delay of the request, not including the time the
+	// server took to respond.
+	RTT time.Duration
+
+	// Stratum is the server's distance from its reference clock.
+	Stratum int
+}
+
+// Query sends a request to the NTP server, given as a host with an optional
+// port, and returns the local clock's offset from it.
+func Query(server string, timeout time.Duration) (*Response, error) {
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, port)
+	}
+
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	req := make([]byte, packetSize)
+	req[0] = versionNumber<<3 | modeClient
+	sent := time.Now()
+	transmit := toTimestamp(sent)
+	binary.BigEndian.PutUint64(req[40:], transmit)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, packetSize)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		received := time.Now()
+		if n < packetSize {
+			continue
+		}
+		// ignore responses which aren't to this request
+		if binary.BigEndian.Uint64(resp[24:]) != transmit {
+			continue
+		}
+		r, err := parseResponse(resp, sent, received)
+		if err != nil {
+			return nil, err
+		}
+		r.Server = conn.RemoteAddr().String()
+		return r, nil
+	}
+}
+
+// parseResponse checks the server's response is valid, and computes the offset
+// and round trip delay from the times the request was sent and the response was
+// received.
+func parseResponse(b []byte, sent, received time.Time) (*Response, error) {
+	leap := b[0] >> 6
+	mode := b[0] & 0x7
+	stratum := int(b[1])
+	if mode != modeServer {
+		return nil, fmt.Errorf("unexpected mode %d in response", mode)
+	}
+	if stratum == 0 {
+		return nil, fmt.Errorf("the server refused the request with code %q", string(b[12:16]))
+	}
+	if stratum > maxStratum || leap == leapNotSynchronized {
+		return nil, fmt.Errorf("the server's clock is not synchronized")
+	}
+
+	serverReceived := fromTimestamp(binary.BigEndian.Uint64(b[32:]))
+	serverTransmitted := fromTimestamp(binary.BigEndian.Uint64(b[40:]))
+	if serverTransmitted.IsZero() {
+		return nil, fmt.Errorf("the response has no transmit time")
+	}
+
+	return &Response{
+		Offset:  (serverReceived.Sub(sent) + serverTransmitted.Sub(received)) / 2,
+		RTT:     received.Sub(sent) - serverTransmitted.Sub(serverReceived),
+		Stratum: stratum,
+	}, nil
+}
+
+// toTimestamp converts the time to an NTP timestamp, which is the seconds since
+// 1900 in the upper 32 bits and the fraction of a second in the lower 32 bits.
+func toTimestamp(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// fromTimestamp converts an NTP timestamp to a time. The zero timestamp is
+// returned as the zero time.
+func fromTimestamp(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	secs := int64(ts>>32) - ntpEpochOffset
+	nsecs := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(secs, nsecs)
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package ntp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestTimestampRoundTrip(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	now := time.Unix(1440000000, 123456789)
+	ts := toTimestamp(now)
+	TestEqual(t, ts>>32, uint64(1440000000+ntpEpochOffset))
+
+	back := fromTimestamp(ts)
+	diff := back.Sub(now)
+	if diff < -time.Microsecond || diff > time.Microsecond {
+		Fatalf(t, "round trip of %s returned %s", now, back)
+	}
+	TestEqual(t, fromTimestamp(0).IsZero(), true)
+}
+
+func TestQuery(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	TestExpectSuccess(t, err)
+	defer conn.Close()
+
+	// respond as a server whose clock is 10 seconds ahead
+	go func() {
+		req := make([]byte, packetSize)
+		n, addr, err := conn.ReadFrom(req)
+		if err != nil || n < packetSize {
+			return
+		}
+		resp := make([]byte, packetSize)
+		resp[0] = versionNumber<<3 | modeServer
+		resp[1] = 2
+		copy(resp[24:32], req[40:48])
+		ahead := time.Now().Add(10 * time.Second)
+		binary.BigEndian.PutUint64(resp[32:], toTimestamp(ahead))
+		binary.BigEndian.PutUint64(resp[40:], toTimestamp(ahead))
+		conn.WriteTo(resp, addr)
+	}()
+
+	r, err := Query(conn.LocalAddr().String(), time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, r.Stratum, 2)
+	TestEqual(t, r.Server, conn.LocalAddr().String())
+	if r.Offset < 9*time.Second || r.Offset > 11*time.Second {
+		Fatalf(t, "expected an offset of about 10s, got %s", r.Offset)
+	}
+}
+
+func TestParseResponseErrors(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	now := time.Now()
+	b := make([]byte, packetSize)
+
+	// kiss-o'-death
+	b[0] = versionNumber<<3 | modeServer
+	copy(b[12:16], "RATE")
+	_, err := parseResponse(b, now, now)
+	TestExpectError(t, err)
+
+	// unsynchronized server
+	b[0] = leapNotSynchronized<<6 | versionNumber<<3 | modeServer
+	b[1] = 3
+	_, err = parseResponse(b, now, now)
+	TestExpectError(t, err)
+
+	// not a server response
+	b[0] = versionNumber<<3 | modeClient
+	_, err = parseResponse(b, now, now)
+	TestExpectError(t, err)
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// helperCacheDuration is how long the credentials a helper returns are reused
+// before it is run again, so that downloads made over several requests don't
+// run it for each one.
+const helperCacheDuration = time.Minute
+
+// RegistryCredentials authenticate the requests made to a registry when images
+// are retrieved from it. Requests use basic authentication with the Username
+// and Password, or the bearer Token. Otherwise the Helper command is run to
+// retrieve them, as Docker credential helpers are: with "get" as its argument
+// and the registry's host on stdin, printing JSON with the Username and Secret.
+// A Username of "<token>" returns the Secret as a bearer token.
+type RegistryCredentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Helper   string `json:"helper,omitempty"`
+}
+
+// String describes the credentials without their secrets, so that they may be
+// logged.
+func (c RegistryCredentials) String() string {
+	switch {
+	case c.Token != "":
+		return "bearer token"
+	case c.Username != "":
+		return fmt.Sprintf("username %s", c.Username)
+	case c.Helper != "":
+		return fmt.Sprintf("helper %s", c.Helper)
+	}
+	return "none"
+}
+
+// GoString keeps the secrets out of values printed with %#v.
+func (c RegistryCredentials) GoString() string {
+	return fmt.Sprintf("remote.RegistryCredentials{%s}", c.String())
+}
+
+var (
+	credentialsMutex sync.Mutex
+	credentials      map[string]RegistryCredentials
+	helperCache      map[string]helperResult
+)
+
+// helperResult holds the credentials a helper returned for a host.
+type helperResult struct {
+	creds   RegistryCredentials
+	expires time.Time
+}
+
+// SetCredentials replaces the credentials used to retrieve images from each
+// registry, keyed by its host with the port if it isn't the default. Docker
+// Hub's are given under docker.io.
+func SetCredentials(creds map[string]RegistryCredentials) {
+	credentialsMutex.Lock()
+	defer credentialsMutex.Unlock()
+	credentials = make(map[string]RegistryCredentials, len(creds))
+	for host, c := range creds {
+		credentials[host] = c
+	}
+	helperCache = make(map[string]helperResult)
+}
+
+// lookupCredentials returns the credentials for the host, running its helper if
+// it has one. The zero value is returned if there are none for it.
+func lookupCredentials(host string) (RegistryCredentials, error) {
+	credentialsMutex.Lock()
+	defer credentialsMutex.Unlock()
+
+	c, ok := credentials[host]
+	if !ok && host == dockerHubRegistry {
+		c = credentials["docker.io"]
+	}
+	if c.Helper == "" || c.Username != "" || c.Token != "" {
+		return c, nil
+	}
+
+	if cached, ok := helperCache[host]; ok && time.Now().Before(cached.expires) {
+		return cached.creds, nil
+	}
+	resolved, err := runHelper(c.Helper, host)
+	if err != nil {
+		return RegistryCredentials{}, err
+	}
+	helperCache[host] = helperResult{creds: resolved, expires: time.Now().Add(helperCacheDuration)}
+	return resolved, nil
+}
+
+// runHelper runs the credential helper for the host. Its output isn't included
+// in errors, since it may hold the secret.
+func runHelper(helper, host string) (RegistryCredentials, error) {
+	cmd := exec.Command(helper, "get")
+	cmd.Stdin = strings.NewReader(host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return RegistryCredentials{}, fmt.Errorf("credential helper %s failed for %s: %v: %s",
+			helper, host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return RegistryCredentials{}, fmt.Errorf("credential helper %s returned invalid output for %s", helper, host)
+	}
+	if resp.Username == "<token>" {
+		return RegistryCredentials{Token: resp.Secret}, nil
+	}
+	return RegistryCredentials{Username: resp.Username, Password: resp.Secret}, nil
+}
+
+// authorizeRequest adds the credentials for the request's host to it, if there
+// are any.
+func authorizeRequest(req *http.Request) error {
+	c, err := lookupCredentials(req.URL.Host)
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetrieveImageWithCredentials(t *testing.T) {
+	defer SetCredentials(nil)
+	aci := testACI(t, testPushManifest(), "bin/app", "binary")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if (!ok || user != "user" || pass != "pass") && r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(aci)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// Test 1: Without credentials, the image can't be retrieved.
+	if _, err := RetrieveImage(server.URL+"/app.aci", true); err == nil {
+		t.Fatalf("Expected an error retrieving the image without credentials")
+	}
+
+	// Test 2: Basic authentication and bearer tokens are sent to the host.
+	for _, creds := range []RegistryCredentials{
+		{Username: "user", Password: "pass"},
+		{Token: "secret"},
+	} {
+		SetCredentials(map[string]RegistryCredentials{host: creds})
+		r, err := RetrieveImage(server.URL+"/app.aci", true)
+		if err != nil {
+			t.Fatalf("Expected no error retrieving the image with %s; got %s", creds, err)
+		}
+		b, _ := ioutil.ReadAll(r)
+		r.Close()
+		if !bytes.Equal(b, aci) {
+			t.Fatalf("Retrieved the wrong image with %s", creds)
+		}
+	}
+
+	// Test 3: Credentials for other hosts aren't sent.
+	SetCredentials(map[string]RegistryCredentials{"example.com": {Username: "user", Password: "pass"}})
+	if _, err := RetrieveImage(server.URL+"/app.aci", true); err == nil {
+		t.Fatalf("Expected an error retrieving the image with another host's credentials")
+	}
+}
+
+func TestCredentialHelper(t *testing.T) {
+	defer SetCredentials(nil)
+	dir, err := ioutil.TempDir(os.TempDir(), "credentials")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the helper records each run, and returns the credentials for the host it
+	// is given
+	runs := filepath.Join(dir, "runs")
+	helper := filepath.Join(dir, "helper")
+	script := fmt.Sprintf(`#!/bin/sh
+[ "$1" = get ] || exit 1
+read host
+echo "$host" >> %s
+case "$host" in
+  token.example.com) echo '{"Username": "<token>", "Secret": "secret"}' ;;
+  *) echo '{"Username": "user", "Secret": "pass"}' ;;
+esac
+`, runs)
+	if err := ioutil.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatalf("Error writing the helper: %s", err)
+	}
+
+	SetCredentials(map[string]RegistryCredentials{
+		"example.com":       {Helper: helper},
+		"token.example.com": {Helper: helper},
+		"broken.com":        {Helper: filepath.Join(dir, "missing")},
+	})
+	for i := 0; i < 2; i++ {
+		c, err := lookupCredentials("example.com")
+		if err != nil {
+			t.Fatalf("Expected no error running the helper; got %s", err)
+		}
+		if c.Username != "user" || c.Password != "pass" {
+			t.Fatalf("Unexpected credentials %s", c)
+		}
+	}
+	if c, err := lookupCredentials("token.example.com"); err != nil || c.Token != "secret" {
+		t.Fatalf("Expected the token to be returned; got %s, %v", c, err)
+	}
+	if _, err := lookupCredentials("broken.com"); err == nil {
+		t.Fatalf("Expected an error running a missing helper")
+	}
+
+	// the helper's results are cached
+	b, _ := ioutil.ReadFile(runs)
+	if string(b) != "example.com\ntoken.example.com\n" {
+		t.Fatalf("Unexpected helper runs %q", b)
+	}
+}
+
+func TestRegistryCredentialsString(t *testing.T) {
+	creds := RegistryCredentials{Username: "user", Password: "hunter2", Token: "secret"}
+	for _, s := range []string{
+		fmt.Sprintf("%v", creds),
+		fmt.Sprintf("%+v", creds),
+		fmt.Sprintf("%#v", creds),
+		fmt.Sprintf("%v", map[string]RegistryCredentials{"example.com": creds}),
+	} {
+		if strings.Contains(s, "hunter2") || strings.Contains(s, "secret") {
+			t.Fatalf("Expected the secrets to be left out of %q", s)
+		}
+	}
+}
+
+func TestRetrievePrivateDockerImage(t *testing.T) {
+	defer SetCredentials(nil)
+	reg := newTestRegistry()
+	reg.private = true
+	defer reg.server.Close()
+	host := strings.TrimPrefix(reg.server.URL, "http://")
+	uri := fmt.Sprintf("docker://%s/test/app:1.0", host)
+
+	aci := testACI(t, testPushManifest(), "bin/app", "binary")
+	if _, err := PushImage(bytes.NewReader(aci), uri, PushOptions{Username: "user", Password: "pass", Insecure: true}); err != nil {
+		t.Fatalf("Expected no error pushing the image; got %s", err)
+	}
+
+	// Test 1: Private images can't be pulled anonymously.
+	if _, err := RetrieveImage(uri, true); err == nil {
+		t.Fatalf("Expected an error pulling a private image anonymously")
+	}
+
+	// Test 2: The host's credentials retrieve a token able to pull it.
+	SetCredentials(map[string]RegistryCredentials{host: {Username: "user", Password: "pass"}})
+	r, err := RetrieveImage(uri, true)
+	if err != nil {
+		t.Fatalf("Expected no error pulling with credentials; got %s", err)
+	}
+	r.Close()
+}
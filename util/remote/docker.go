@@ -0,0 +1,820 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	// dockerHubRegistry is the registry used for images which do not specify
+	// one, such as docker://busybox.
+	dockerHubRegistry = "registry-1.docker.io"
+
+	// defaultDockerPath is the PATH used to locate the image's command when the
+	// image's environment does not define one.
+	defaultDockerPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerConfig       = "application/vnd.docker.container.image.v1+json"
+	mediaTypeDockerLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// dockerArchMap maps the architecture names used by Docker to the ones used
+// within the App Container specification.
+var dockerArchMap = map[string]string{
+	"386":   "i386",
+	"arm":   "armv7l",
+	"arm64": "aarch64",
+}
+
+// dockerImage is a reference to an image within a Docker registry.
+type dockerImage struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+// parseDockerURI parses a docker:// url into its registry, repository, and tag
+// or digest. It follows the same defaults as the docker command line, so
+// docker://busybox refers to library/busybox:latest on the Docker Hub.
+func parseDockerURI(uri string) (*dockerImage, error) {
+	name := strings.TrimPrefix(uri, "docker://")
+	if name == "" {
+		return nil, fmt.Errorf("no image specified in %q", uri)
+	}
+
+	img := &dockerImage{registry: dockerHubRegistry}
+
+	// the first component is a registry if it looks like a hostname
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			img.registry = host
+			name = name[i+1:]
+		}
+		if host == "docker.io" || host == "index.docker.io" {
+			img.registry = dockerHubRegistry
+		}
+	}
+
+	// split off a digest or tag
+	if i := strings.Index(name, "@"); i >= 0 {
+		img.reference = name[i+1:]
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		img.reference = name[i+1:]
+		name = name[:i]
+	} else {
+		img.reference = "latest"
+	}
+
+	if name == "" || img.reference == "" {
+		return nil, fmt.Errorf("invalid docker image %q", uri)
+	}
+	if img.registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	img.repository = name
+	return img, nil
+}
+
+// DockerReference returns the canonical form of the image the docker:// URI
+// refers to, as the Docker command line and Kubernetes name images, such as
+// docker.io/library/busybox:latest for docker://busybox. Images on the Docker
+// Hub are named after docker.io, which docker:// URIs accept in place of the
+// registry's host.
+func DockerReference(uri string) (string, error) {
+	img, err := parseDockerURI(uri)
+	if err != nil {
+		return "", err
+	}
+	registry := img.registry
+	if registry == dockerHubRegistry {
+		registry = "docker.io"
+	}
+	sep := ":"
+	if strings.Contains(img.reference, ":") {
+		sep = "@"
+	}
+	return registry + "/" + img.repository + sep + img.reference, nil
+}
+
+// dockerRegistry handles requests against a Docker v2 registry, including
+// retrieving a bearer token when the registry requests authentication. The
+// username and password, if set, are used to authenticate, and otherwise an
+// anonymous token is requested.
+type dockerRegistry struct {
+	scheme   string
+	host     string
+	token    string
+	username string
+	password string
+	basic    bool
+}
+
+// newDockerRegistry returns a dockerRegistry for the host, which authenticates
+// with the username and password if they're given, and otherwise with the
+// credentials set for the host with SetCredentials.
+func newDockerRegistry(scheme, host, username, password string) (*dockerRegistry, error) {
+	r := &dockerRegistry{scheme: scheme, host: host, username: username, password: password}
+	if username != "" {
+		return r, nil
+	}
+	creds, err := lookupCredentials(host)
+	if err != nil {
+		return nil, err
+	}
+	r.username, r.password, r.token = creds.Username, creds.Password, creds.Token
+	return r, nil
+}
+
+// get performs a GET request against the registry for the specified path. If
+// the registry responds that authorization is required, it will retrieve a
+// token and retry the request.
+func (r *dockerRegistry) get(p string, accept ...string) (*http.Response, error) {
+	header := make(http.Header)
+	for _, a := range accept {
+		header.Add("Accept", a)
+	}
+	resp, err := r.send("GET", p, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d on retrieving %q from %s", resp.StatusCode, p, r.host)
+	}
+	return resp, nil
+}
+
+// send performs the request against the registry, where the target is either
+// a path or a URL the registry returned. If the registry responds that
+// authorization is required, it is retried once authorized, unless it has a
+// body, so requests with bodies should follow one which authorized for the
+// same scope. Tokens are scoped by the registry, so pushing needs a new token
+// after pulling.
+func (r *dockerRegistry) send(method, target string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	resp, err := r.do(method, target, header, body, size)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && body == nil {
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+		if err := r.authorize(challenge); err != nil {
+			return nil, err
+		}
+		resp, err = r.do(method, target, header, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (r *dockerRegistry) do(method, target string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	base := &url.URL{Scheme: r.scheme, Host: r.host}
+	u, err := base.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	switch {
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.basic:
+		req.SetBasicAuth(r.username, r.password)
+	}
+	return Client.Do(req)
+}
+
+// authorize retrieves a bearer token based on the challenge returned by the
+// registry, or uses basic authentication if the registry asks for it and
+// credentials were given.
+func (r *dockerRegistry) authorize(challenge string) error {
+	if strings.HasPrefix(challenge, "Basic ") && r.username != "" {
+		r.basic = true
+		return nil
+	}
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported authentication challenge from %s: %q", r.host, challenge)
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("authentication challenge from %s is missing a realm", r.host)
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		q.Set("scope", params["scope"])
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d on retrieving a token from %s", resp.StatusCode, realm)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("no token was returned from %s", realm)
+	}
+	return nil
+}
+
+// dockerDescriptor references content within the registry.
+type dockerDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+	// Annotations are only read from the indexes of OCI image layouts.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// dockerManifest covers both image manifests and manifest lists.
+type dockerManifest struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	MediaType     string             `json:"mediaType"`
+	Config        dockerDescriptor   `json:"config"`
+	Layers        []dockerDescriptor `json:"layers"`
+	Manifests     []dockerDescriptor `json:"manifests,omitempty"`
+}
+
+// dockerImageConfig is the subset of the image configuration that is used to
+// generate the image manifest, and that is written for images which are pushed.
+type dockerImageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       struct {
+		User         string              `json:"User,omitempty"`
+		Env          []string            `json:"Env,omitempty"`
+		Entrypoint   []string            `json:"Entrypoint,omitempty"`
+		Cmd          []string            `json:"Cmd,omitempty"`
+		WorkingDir   string              `json:"WorkingDir,omitempty"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+		Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	} `json:"config"`
+	RootFS *dockerRootFS `json:"rootfs,omitempty"`
+}
+
+// dockerRootFS lists the digests of the uncompressed layers of an image.
+type dockerRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// retrieveDockerImage pulls the image from a Docker registry and converts it
+// to an ACI. If insecure is set, registries which only support plain HTTP are
+// allowed.
+func retrieveDockerImage(uri string, insecure bool) (ReaderCloserSeeker, error) {
+	img, err := parseDockerURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	reg, err := newDockerRegistry("https", img.registry, "", "")
+	if err != nil {
+		return nil, err
+	}
+	token := reg.token
+	manifest, err := reg.manifest(img.repository, img.reference)
+	if err != nil && insecure {
+		reg.scheme, reg.token, reg.basic = "http", token, false
+		manifest, err = reg.manifest(img.repository, img.reference)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// retrieve the image configuration
+	var config dockerImageConfig
+	if err := reg.blobJSON(img.repository, manifest.Config.Digest, &config); err != nil {
+		return nil, fmt.Errorf("failed to retrieve image config: %v", err)
+	}
+
+	// download all of the layers, they're needed locally since they must be
+	// scanned from the top down before being written out
+	var layers []*os.File
+	defer func() {
+		for _, f := range layers {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	for _, layer := range manifest.Layers {
+		f, err := reg.blobFile(img.repository, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve layer %s: %v", layer.Digest, err)
+		}
+		layers = append(layers, f)
+	}
+
+	f, err := ioutil.TempFile(DownloadDirectory, "remote-aci-tarfile")
+	if err != nil {
+		return nil, err
+	}
+	tr := &tempFileReader{file: f}
+	if _, err := convertDockerImage(tr.file, img, &config, layers); err != nil {
+		tr.Close()
+		return nil, fmt.Errorf("failed to convert %q to an ACI: %v", uri, err)
+	}
+	if _, err := tr.file.Seek(0, 0); err != nil {
+		tr.Close()
+		return nil, err
+	}
+	return tr, nil
+}
+
+// manifest retrieves the image manifest for the specified reference. If the
+// reference is a manifest list, the manifest for the current platform is used.
+func (r *dockerRegistry) manifest(repository, reference string) (*dockerManifest, error) {
+	resp, err := r.get(fmt.Sprintf("/v2/%s/manifests/%s", repository, reference),
+		mediaTypeDockerManifest, mediaTypeDockerManifestList,
+		mediaTypeOCIManifest, mediaTypeOCIIndex)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var manifest dockerManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion != 2 {
+		return nil, fmt.Errorf("unsupported manifest schema version %d", manifest.SchemaVersion)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+				return r.manifest(repository, m.Digest)
+			}
+		}
+		return nil, fmt.Errorf("no image for linux/%s was found in %s", runtime.GOARCH, repository)
+	}
+	return &manifest, nil
+}
+
+// blobJSON retrieves the specified blob and decodes it into v.
+func (r *dockerRegistry) blobJSON(repository, digest string, v interface{}) error {
+	f, err := r.blobFile(repository, digest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	return json.NewDecoder(f).Decode(v)
+}
+
+// blobFile downloads the specified blob to a temporary file and verifies its
+// digest. The caller is responsible for removing the file.
+func (r *dockerRegistry) blobFile(repository, digest string) (*os.File, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("unsupported digest %q", digest)
+	}
+
+	resp, err := r.get(fmt.Sprintf("/v2/%s/blobs/%s", repository, digest))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	f, err := ioutil.TempFile(DownloadDirectory, "remote-docker-blob")
+	if err != nil {
+		return nil, err
+	}
+	success := false
+	defer func() {
+		if !success {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return nil, err
+	}
+	if sum := "sha256:" + hex.EncodeToString(h.Sum(nil)); sum != digest {
+		return nil, fmt.Errorf("digest mismatch, expected %s but got %s", digest, sum)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	success = true
+	return f, nil
+}
+
+// convertDockerImage writes an ACI to w containing the image manifest and the
+// root filesystem resulting from applying each of the layers in order. It
+// returns the manifest which was written.
+func convertDockerImage(w io.Writer, img *dockerImage, config *dockerImageConfig, layers []*os.File) (*schema.ImageManifest, error) {
+	// Scan the layers from the top down to determine which entries are visible
+	// in the final filesystem, accounting for whiteouts.
+	keep, entries, err := scanDockerLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := dockerImageManifest(img, config, entries)
+	if err != nil {
+		return nil, err
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "manifest",
+		Mode:     0644,
+		Size:     int64(len(manifestBytes)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(manifestBytes); err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "rootfs/",
+		Mode:     0755,
+		Typeflag: tar.TypeDir,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Write out the kept entries from the bottom layer up.
+	for i, layer := range layers {
+		if _, err := layer.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		arch, err := tarhelper.DetectArchiveCompression(layer)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			header, err := arch.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			name := path.Clean("/" + header.Name)
+			if !keep[i][name] {
+				continue
+			}
+			delete(keep[i], name)
+
+			header.Name = path.Join("rootfs", name)
+			if header.Typeflag == tar.TypeLink {
+				header.Linkname = path.Join("rootfs", path.Clean("/"+header.Linkname))
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(tw, arch); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return manifest, tw.Close()
+}
+
+// scanDockerLayers walks the layers from the top down and returns, for each
+// layer, the set of entries which should be written from it. It also returns
+// the headers of all of the entries visible in the resulting filesystem.
+func scanDockerLayers(layers []*os.File) ([]map[string]bool, map[string]*tar.Header, error) {
+	keep := make([]map[string]bool, len(layers))
+	entries := make(map[string]*tar.Header)
+	whiteouts := make(map[string]bool)
+	opaques := make(map[string]bool)
+
+	// hidden returns whether an upper layer has removed the path, either
+	// through a whiteout or replacing one of its parents with a non-directory.
+	hidden := func(name string) bool {
+		if whiteouts[name] {
+			return true
+		}
+		for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+			if whiteouts[dir] || opaques[dir] {
+				return true
+			}
+			if e, ok := entries[dir]; ok && e.Typeflag != tar.TypeDir {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		keep[i] = make(map[string]bool)
+		layerWhiteouts := make(map[string]bool)
+		layerOpaques := make(map[string]bool)
+
+		arch, err := tarhelper.DetectArchiveCompression(layers[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		for {
+			header, err := arch.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+
+			name := path.Clean("/" + header.Name)
+			if name == "/" {
+				continue
+			}
+			base := path.Base(name)
+			switch {
+			case base == ".wh..wh..opq":
+				layerOpaques[path.Dir(name)] = true
+				continue
+			case strings.HasPrefix(base, ".wh."):
+				layerWhiteouts[path.Join(path.Dir(name), strings.TrimPrefix(base, ".wh."))] = true
+				continue
+			}
+
+			if hidden(name) {
+				continue
+			}
+			if e, ok := entries[name]; ok {
+				// directories which exist in multiple layers are written from each
+				// so their contents are extracted in order
+				if e.Typeflag == tar.TypeDir && header.Typeflag == tar.TypeDir {
+					keep[i][name] = true
+				}
+				continue
+			}
+			h := *header
+			entries[name] = &h
+			keep[i][name] = true
+		}
+
+		// whiteouts only apply to the layers below the one they're in
+		for name := range layerWhiteouts {
+			whiteouts[name] = true
+		}
+		for name := range layerOpaques {
+			opaques[name] = true
+		}
+	}
+	return keep, entries, nil
+}
+
+// dockerImageManifest generates the image manifest for the Docker image.
+func dockerImageManifest(img *dockerImage, config *dockerImageConfig, entries map[string]*tar.Header) (*schema.ImageManifest, error) {
+	registry := img.registry
+	if registry == dockerHubRegistry {
+		registry = "docker.io"
+	}
+	name, err := types.SanitizeACIdentifier(path.Join(registry, img.repository))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := schema.BlankImageManifest()
+	if err := manifest.Name.Set(name); err != nil {
+		return nil, err
+	}
+
+	// labels
+	labels := map[types.ACIdentifier]string{}
+	if !strings.Contains(img.reference, ":") {
+		labels["version"] = img.reference
+	}
+	osName := config.OS
+	if osName == "" {
+		osName = "linux"
+	}
+	labels["os"] = osName
+	if config.Architecture != "" {
+		arch := config.Architecture
+		if a, ok := dockerArchMap[arch]; ok {
+			arch = a
+		}
+		labels["arch"] = arch
+	}
+	manifest.Labels, err = types.LabelsFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	// images converted from an OCI image layout weren't pulled from a registry
+	if img.registry != "" {
+		manifest.Annotations.Set("docker-registry", img.registry)
+		manifest.Annotations.Set("docker-repository", img.repository)
+		manifest.Annotations.Set("docker-reference", img.reference)
+	}
+
+	// An image without a command can only be used as a dependency.
+	cmdargs := append(append([]string{}, config.Config.Entrypoint...), config.Config.Cmd...)
+	if len(cmdargs) == 0 {
+		return manifest, nil
+	}
+
+	app := &types.App{
+		Exec:             types.Exec(cmdargs),
+		User:             "0",
+		Group:            "0",
+		WorkingDirectory: config.Config.WorkingDir,
+	}
+
+	// docker users may be either "user" or "user:group"
+	if config.Config.User != "" {
+		parts := strings.SplitN(config.Config.User, ":", 2)
+		app.User = parts[0]
+		if len(parts) == 2 {
+			app.Group = parts[1]
+		}
+	}
+
+	searchPath := defaultDockerPath
+	for _, env := range config.Config.Env {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		app.Environment.Set(kv[0], kv[1])
+		if kv[0] == "PATH" {
+			searchPath = kv[1]
+		}
+	}
+
+	// the App Container spec requires the command to be an absolute path
+	if !path.IsAbs(app.Exec[0]) {
+		cmd, ok := lookPath(app.Exec[0], searchPath, entries)
+		if !ok {
+			return nil, fmt.Errorf("unable to locate %q within the image", app.Exec[0])
+		}
+		app.Exec[0] = cmd
+	}
+
+	for p := range config.Config.ExposedPorts {
+		parts := strings.SplitN(p, "/", 2)
+		port, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exposed port %q", p)
+		}
+		protocol := "tcp"
+		if len(parts) == 2 {
+			protocol = parts[1]
+		}
+		pname, err := types.SanitizeACName(fmt.Sprintf("%d-%s", port, protocol))
+		if err != nil {
+			return nil, err
+		}
+		app.Ports = append(app.Ports, types.Port{
+			Name:     types.ACName(pname),
+			Protocol: protocol,
+			Port:     uint(port),
+			Count:    1,
+		})
+	}
+
+	for p := range config.Config.Volumes {
+		vname, err := types.SanitizeACName("volume-" + p)
+		if err != nil {
+			return nil, err
+		}
+		app.MountPoints = append(app.MountPoints, types.MountPoint{
+			Name: types.ACName(vname),
+			Path: p,
+		})
+	}
+
+	manifest.App = app
+	return manifest, nil
+}
+
+// lookPath searches the directories in the search path for the command within
+// the image's filesystem.
+func lookPath(cmd, searchPath string, entries map[string]*tar.Header) (string, bool) {
+	for _, dir := range strings.Split(searchPath, ":") {
+		if !path.IsAbs(dir) {
+			continue
+		}
+		p := path.Join(dir, cmd)
+		if e, ok := resolveEntry(p, entries); ok && e.Typeflag != tar.TypeDir {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// resolveEntry finds the entry at the specified path, following any symlinks
+// along the way.
+func resolveEntry(p string, entries map[string]*tar.Header) (*tar.Header, bool) {
+	for hops := 0; hops < 32; hops++ {
+		parts := strings.Split(strings.TrimPrefix(path.Clean(p), "/"), "/")
+		resolved := "/"
+		followed := false
+		for i, part := range parts {
+			cur := path.Join(resolved, part)
+			e, ok := entries[cur]
+			if !ok {
+				// parent directories may be implied by their contents
+				if i == len(parts)-1 {
+					return nil, false
+				}
+				resolved = cur
+				continue
+			}
+			if e.Typeflag == tar.TypeSymlink {
+				target := e.Linkname
+				if !path.IsAbs(target) {
+					target = path.Join(resolved, target)
+				}
+				p = path.Join(append([]string{target}, parts[i+1:]...)...)
+				followed = true
+				break
+			}
+			if i == len(parts)-1 {
+				return e, true
+			}
+			resolved = cur
+		}
+		if !followed {
+			return nil, false
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,274 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+)
+
+// pushDockerImage converts the ACI to a Docker image and pushes it to the
+// registry and tag of the docker:// URI. If insecure is set, registries which
+// only support plain HTTP are allowed.
+func pushDockerImage(image io.ReadSeeker, manifest *schema.ImageManifest, uri string, opts PushOptions) (string, error) {
+	img, err := parseDockerURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(img.reference, ":") {
+		return "", fmt.Errorf("images must be pushed to a tag rather than a digest")
+	}
+
+	// the layer is written to a file first, since its digest is needed before
+	// it can be uploaded
+	layer, err := ioutil.TempFile(DownloadDirectory, "remote-docker-layer")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		layer.Close()
+		os.Remove(layer.Name())
+	}()
+	h := sha256.New()
+	diffID, err := writeDockerLayer(io.MultiWriter(layer, h), image)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert the image to a Docker layer: %v", err)
+	}
+	layerDesc := dockerDescriptor{
+		MediaType: mediaTypeDockerLayer,
+		Digest:    "sha256:" + hex.EncodeToString(h.Sum(nil)),
+	}
+	if layerDesc.Size, err = layer.Seek(0, 1); err != nil {
+		return "", err
+	}
+
+	config, err := dockerPushConfig(manifest, diffID)
+	if err != nil {
+		return "", err
+	}
+	configDesc := dockerDescriptor{
+		MediaType: mediaTypeDockerConfig,
+		Size:      int64(len(config)),
+		Digest:    sha256Digest(config),
+	}
+	manifestBytes, err := json.Marshal(&dockerManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeDockerManifest,
+		Config:        configDesc,
+		Layers:        []dockerDescriptor{layerDesc},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the config is pushed first, and over plain HTTP if that fails and is
+	// allowed
+	reg, err := newDockerRegistry("https", img.registry, opts.Username, opts.Password)
+	if err != nil {
+		return "", err
+	}
+	token := reg.token
+	err = reg.pushBlob(img.repository, configDesc, bytes.NewReader(config))
+	if err != nil && opts.Insecure {
+		reg.scheme, reg.token, reg.basic = "http", token, false
+		err = reg.pushBlob(img.repository, configDesc, bytes.NewReader(config))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to push the image config: %v", err)
+	}
+
+	if _, err := layer.Seek(0, 0); err != nil {
+		return "", err
+	}
+	if err := reg.pushBlob(img.repository, layerDesc, newProgressReader(layer, layerDesc.Size, opts.Progress)); err != nil {
+		return "", fmt.Errorf("failed to push the layer: %v", err)
+	}
+	if err := reg.pushManifest(img.repository, img.reference, manifestBytes); err != nil {
+		return "", fmt.Errorf("failed to push the manifest: %v", err)
+	}
+
+	registry := img.registry
+	if registry == dockerHubRegistry {
+		registry = "docker.io"
+	}
+	return fmt.Sprintf("docker://%s/%s:%s", registry, img.repository, img.reference), nil
+}
+
+// pushBlob uploads the blob to the repository in a single request, unless the
+// registry already has it.
+func (r *dockerRegistry) pushBlob(repository string, desc dockerDescriptor, blob io.Reader) error {
+	resp, err := r.send("HEAD", fmt.Sprintf("/v2/%s/blobs/%s", repository, desc.Digest), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = r.send("POST", fmt.Sprintf("/v2/%s/blobs/uploads/", repository), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("HTTP %d on starting an upload to %s", resp.StatusCode, r.host)
+	}
+	u, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("%s did not return where to upload to", r.host)
+	}
+	q := u.Query()
+	q.Set("digest", desc.Digest)
+	u.RawQuery = q.Encode()
+
+	header := http.Header{"Content-Type": []string{"application/octet-stream"}}
+	resp, err = r.send("PUT", u.String(), header, blob, desc.Size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("HTTP %d on uploading %s to %s", resp.StatusCode, desc.Digest, r.host)
+	}
+	return nil
+}
+
+// pushManifest puts the image manifest in the repository under the tag.
+func (r *dockerRegistry) pushManifest(repository, tag string, manifest []byte) error {
+	header := http.Header{"Content-Type": []string{mediaTypeDockerManifest}}
+	resp, err := r.send("PUT", fmt.Sprintf("/v2/%s/manifests/%s", repository, tag),
+		header, bytes.NewReader(manifest), int64(len(manifest)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("HTTP %d on putting the manifest on %s", resp.StatusCode, r.host)
+	}
+	return nil
+}
+
+// writeDockerLayer writes the ACI's root filesystem to w as a gzipped layer,
+// and returns the digest of the uncompressed layer.
+func writeDockerLayer(w io.Writer, aci io.Reader) (string, error) {
+	arch, err := tarhelper.DetectArchiveCompression(aci)
+	if err != nil {
+		return "", err
+	}
+	gw := gzip.NewWriter(w)
+	h := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(gw, h))
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		name, ok := rootfsPath(header.Name)
+		if !ok {
+			continue
+		}
+		header.Name = name
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if header.Typeflag == tar.TypeLink {
+			if header.Linkname, ok = rootfsPath(header.Linkname); !ok {
+				return "", fmt.Errorf("%s links outside of the root filesystem", header.Name)
+			}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(tw, arch); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// rootfsPath returns the path within the root filesystem of an ACI's entry,
+// and false for the manifest and the rootfs directory itself.
+func rootfsPath(name string) (string, bool) {
+	name = path.Clean("/" + name)
+	if !strings.HasPrefix(name, "/rootfs/") {
+		return "", false
+	}
+	return strings.TrimPrefix(name, "/rootfs/"), true
+}
+
+// dockerPushConfig returns the Docker image config for the image manifest,
+// where diffID is the digest of its uncompressed layer.
+func dockerPushConfig(manifest *schema.ImageManifest, diffID string) ([]byte, error) {
+	config := &dockerImageConfig{
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		RootFS:       &dockerRootFS{Type: "layers", DiffIDs: []string{diffID}},
+	}
+	if osName, ok := manifest.GetLabel("os"); ok {
+		config.OS = osName
+	}
+	if arch, ok := manifest.GetLabel("arch"); ok {
+		config.Architecture = arch
+		for docker, appc := range dockerArchMap {
+			if appc == arch {
+				config.Architecture = docker
+			}
+		}
+	}
+
+	if app := manifest.App; app != nil {
+		config.Config.Entrypoint = app.Exec
+		config.Config.WorkingDir = app.WorkingDirectory
+		if app.User != "0" || app.Group != "0" {
+			config.Config.User = app.User + ":" + app.Group
+		}
+		for _, env := range app.Environment {
+			config.Config.Env = append(config.Config.Env, env.Name+"="+env.Value)
+		}
+		for _, p := range app.Ports {
+			if config.Config.ExposedPorts == nil {
+				config.Config.ExposedPorts = make(map[string]struct{})
+			}
+			config.Config.ExposedPorts[fmt.Sprintf("%d/%s", p.Port, p.Protocol)] = struct{}{}
+		}
+		for _, mp := range app.MountPoints {
+			if config.Config.Volumes == nil {
+				config.Config.Volumes = make(map[string]struct{})
+			}
+			config.Config.Volumes[mp.Path] = struct{}{}
+		}
+	}
+	return json.Marshal(config)
+}
+
+// sha256Digest returns the digest of the bytes in the form registries use.
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
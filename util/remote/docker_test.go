@@ -0,0 +1,256 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appc/spec/schema"
+)
+
+func TestParseDockerURI(t *testing.T) {
+	tests := []struct {
+		uri        string
+		registry   string
+		repository string
+		reference  string
+	}{
+		{"docker://busybox", dockerHubRegistry, "library/busybox", "latest"},
+		{"docker://busybox:1.24", dockerHubRegistry, "library/busybox", "1.24"},
+		{"docker://apcera/kurma", dockerHubRegistry, "apcera/kurma", "latest"},
+		{"docker://quay.io/coreos/etcd:v2.2.0", "quay.io", "coreos/etcd", "v2.2.0"},
+		{"docker://localhost:5000/app", "localhost:5000", "app", "latest"},
+		{"docker://busybox@sha256:abcd", dockerHubRegistry, "library/busybox", "sha256:abcd"},
+		{"docker://docker.io/library/busybox:1.24", dockerHubRegistry, "library/busybox", "1.24"},
+		{"docker://index.docker.io/apcera/kurma", dockerHubRegistry, "apcera/kurma", "latest"},
+	}
+
+	for _, test := range tests {
+		img, err := parseDockerURI(test.uri)
+		if err != nil {
+			t.Fatalf("Expected no error parsing %q; got %s", test.uri, err)
+		}
+		if img.registry != test.registry || img.repository != test.repository || img.reference != test.reference {
+			t.Fatalf("Unexpected result parsing %q: %#v", test.uri, img)
+		}
+	}
+
+	if _, err := parseDockerURI("docker://"); err == nil {
+		t.Fatalf("Expected an error parsing an empty image")
+	}
+}
+
+func TestDockerReference(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"docker://busybox":                          "docker.io/library/busybox:latest",
+		"docker://docker.io/library/busybox:latest": "docker.io/library/busybox:latest",
+		"docker://apcera/kurma:0.3":                 "docker.io/apcera/kurma:0.3",
+		"docker://quay.io/coreos/etcd:v2.2.0":       "quay.io/coreos/etcd:v2.2.0",
+		"docker://localhost:5000/app":               "localhost:5000/app:latest",
+		"docker://busybox@sha256:abcd":              "docker.io/library/busybox@sha256:abcd",
+	} {
+		ref, err := DockerReference(uri)
+		if err != nil {
+			t.Fatalf("Expected no error for %q; got %s", uri, err)
+		}
+		if ref != expected {
+			t.Fatalf("Expected %q for %q; got %q", expected, uri, ref)
+		}
+	}
+	if _, err := DockerReference("docker://"); err == nil {
+		t.Fatalf("Expected an error for an empty image")
+	}
+}
+
+// testLayer builds a gzipped layer from the specified files. Entries ending in
+// a slash are created as directories, and entries with a value starting with
+// "->" are created as symlinks.
+func testLayer(t *testing.T, files [][2]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		header := &tar.Header{Name: f[0], Mode: 0755}
+		switch {
+		case strings.HasSuffix(f[0], "/"):
+			header.Typeflag = tar.TypeDir
+		case strings.HasPrefix(f[1], "->"):
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = f[1][2:]
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(f[1]))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Error writing header: %s", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(f[1])); err != nil {
+				t.Fatalf("Error writing file: %s", err)
+			}
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func testDigest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestRetrieveDockerImage(t *testing.T) {
+	layers := [][]byte{
+		testLayer(t, [][2]string{
+			{"bin/", ""},
+			{"bin/sh", "shell"},
+			{"etc/", ""},
+			{"etc/removed", "gone"},
+			{"etc/config", "old"},
+			{"var/", ""},
+			{"var/cache/", ""},
+			{"var/cache/file", "cached"},
+		}),
+		testLayer(t, [][2]string{
+			{"etc/", ""},
+			{"etc/.wh.removed", ""},
+			{"etc/config", "new"},
+			{"var/cache/", ""},
+			{"var/cache/.wh..wh..opq", ""},
+			{"var/cache/other", "other"},
+			{"usr/", ""},
+			{"usr/bin/", ""},
+			{"usr/bin/app", "->/bin/sh"},
+		}),
+	}
+
+	config := []byte(`{
+		"architecture": "amd64",
+		"os": "linux",
+		"config": {
+			"Env": ["PATH=/usr/bin:/bin", "FOO=bar"],
+			"Entrypoint": ["app"],
+			"Cmd": ["--flag"],
+			"WorkingDir": "/var",
+			"ExposedPorts": {"80/tcp": {}}
+		}
+	}`)
+
+	blobs := map[string][]byte{testDigest(config): config}
+	manifest := dockerManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeDockerManifest,
+		Config:        dockerDescriptor{Digest: testDigest(config)},
+	}
+	for _, l := range layers {
+		blobs[testDigest(l)] = l
+		manifest.Layers = append(manifest.Layers, dockerDescriptor{Digest: testDigest(l)})
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Error marshaling manifest: %s", err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case r.URL.Path == "/v2/test/app/manifests/1.0":
+			w.Write(manifestBytes)
+			return
+		case strings.HasPrefix(r.URL.Path, "/v2/test/app/blobs/"):
+			if b, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/test/app/blobs/")]; ok {
+				w.Write(b)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	reader, err := RetrieveImage(fmt.Sprintf("docker://%s/test/app:1.0", host), true)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the image; got %s", err)
+	}
+	defer reader.Close()
+
+	// read the resulting ACI
+	files := make(map[string]string)
+	var imageManifest schema.ImageManifest
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading the ACI: %s", err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Error reading the ACI: %s", err)
+		}
+		if header.Name == "manifest" {
+			if err := json.Unmarshal(b, &imageManifest); err != nil {
+				t.Fatalf("Error parsing the image manifest: %s", err)
+			}
+			continue
+		}
+		files[header.Name] = string(b)
+	}
+
+	expected := map[string]string{
+		"rootfs/bin/sh":          "shell",
+		"rootfs/etc/config":      "new",
+		"rootfs/var/cache/other": "other",
+	}
+	for name, content := range expected {
+		if c, ok := files[name]; !ok || c != content {
+			t.Fatalf("Expected %s to contain %q; got %q", name, content, c)
+		}
+	}
+	for _, name := range []string{"rootfs/etc/removed", "rootfs/var/cache/file", "rootfs/etc/.wh.removed"} {
+		if _, ok := files[name]; ok {
+			t.Fatalf("Expected %s to not be in the image", name)
+		}
+	}
+
+	if imageManifest.Name.String() != strings.Replace(host, ":", "_", 1)+"/test/app" {
+		t.Fatalf("Unexpected image name %q", imageManifest.Name)
+	}
+	if v, _ := imageManifest.GetLabel("version"); v != "1.0" {
+		t.Fatalf("Expected the version label to be 1.0; got %q", v)
+	}
+	if imageManifest.App == nil {
+		t.Fatalf("Expected the image to have an app")
+	}
+	if exec := []string(imageManifest.App.Exec); len(exec) != 2 || exec[0] != "/usr/bin/app" || exec[1] != "--flag" {
+		t.Fatalf("Unexpected exec %#v", exec)
+	}
+	if v, _ := imageManifest.App.Environment.Get("FOO"); v != "bar" {
+		t.Fatalf("Expected FOO to be set in the environment")
+	}
+	if len(imageManifest.App.Ports) != 1 || imageManifest.App.Ports[0].Port != 80 {
+		t.Fatalf("Unexpected ports %#v", imageManifest.App.Ports)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DownloadDirectory is the directory images are downloaded into. They are
+	// removed once they're closed. If it is empty, the system's temporary
+	// directory is used.
+	DownloadDirectory string
+
+	// DownloadConnections is the number of concurrent ranged requests used to
+	// download images of at least ParallelDownloadSize bytes, when the server
+	// supports ranged requests.
+	DownloadConnections = 4
+
+	// ParallelDownloadSize is the size from which images are downloaded over
+	// concurrent requests.
+	ParallelDownloadSize int64 = 64 * 1024 * 1024
+
+	// DownloadRetries is how many times a request which fails transiently, such
+	// as from the connection being lost or a 5xx response, is retried. The delay
+	// before each retry starts at RetryBackoff and doubles each time.
+	DownloadRetries = 4
+	RetryBackoff    = time.Second
+)
+
+// transientError is an error which may succeed if the request is retried.
+type transientError struct {
+	error
+}
+
+// download retrieves the http or https url into a temporary file within the
+// DownloadDirectory. Large files are downloaded in parts over concurrent
+// ranged requests, and interrupted requests are resumed where the server
+// supports it.
+func download(uri string) (ReaderCloserSeeker, error) {
+	f, err := ioutil.TempFile(DownloadDirectory, "remote-aci-tarfile")
+	if err != nil {
+		return nil, err
+	}
+	tr := &tempFileReader{file: f}
+	success := false
+	defer func() {
+		if !success {
+			tr.Close()
+		}
+	}()
+
+	size, ranged := probe(uri)
+	if ranged && size >= ParallelDownloadSize && DownloadConnections > 1 {
+		err = downloadParts(f, uri, size)
+	} else {
+		err = downloadRange(f, uri, 0, -1, ranged)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	success = true
+	return tr, nil
+}
+
+// probe returns the size of the file at the url and whether the server
+// accepts ranged requests for it. If it can't be determined, the file is
+// downloaded with a single request.
+func probe(uri string) (int64, bool) {
+	req, err := http.NewRequest("HEAD", uri, nil)
+	if err != nil {
+		return 0, false
+	}
+	if err := authorizeRequest(req); err != nil {
+		return 0, false
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 {
+		return 0, false
+	}
+	return resp.ContentLength, resp.Header.Get("Accept-Ranges") == "bytes"
+}
+
+// downloadParts splits the file into a part for each connection, and downloads
+// them concurrently.
+func downloadParts(f writerAt, uri string, size int64) error {
+	partSize := (size + int64(DownloadConnections) - 1) / int64(DownloadConnections)
+
+	var wg sync.WaitGroup
+	errch := make(chan error, DownloadConnections)
+	for start := int64(0); start < size; start += partSize {
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := downloadRange(f, uri, start, end, true); err != nil {
+				errch <- err
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	close(errch)
+	return <-errch
+}
+
+// downloadRange downloads the bytes of the file from start to end, inclusive,
+// writing them at the same position in f. If end is negative, it downloads to
+// the end of the file. Failed requests which are transient are retried with
+// backoff, and if resumable is set, continue from where they stopped.
+func downloadRange(f writerAt, uri string, start, end int64, resumable bool) error {
+	offset := start
+	delay := RetryBackoff
+	for attempt := 0; ; attempt++ {
+		n, err := fetch(f, uri, offset, end)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(transientError); !ok || attempt == DownloadRetries {
+			return err
+		}
+
+		if resumable {
+			offset += n
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// fetch makes a single request for the bytes of the file from offset to end,
+// writing them to f and returning how many were written.
+func fetch(f writerAt, uri string, offset, end int64) (int64, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	ranged := offset > 0 || end >= 0
+	if end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if err := authorizeRequest(req); err != nil {
+		return 0, err
+	}
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, transientError{err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK && !ranged:
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+	case resp.StatusCode == http.StatusOK:
+		return 0, fmt.Errorf("server ignored the range requested retrieving %q", uri)
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return 0, transientError{fmt.Errorf("HTTP %d on retrieving %q", resp.StatusCode, uri)}
+	default:
+		return 0, fmt.Errorf("HTTP %d on retrieving %q", resp.StatusCode, uri)
+	}
+
+	n, err := io.Copy(&offsetWriter{w: f, offset: offset}, resp.Body)
+	if err != nil {
+		return n, transientError{err}
+	}
+	if end >= 0 && offset+n != end+1 {
+		return n, transientError{fmt.Errorf("retrieving %q ended after %d of %d bytes", uri, n, end+1-offset)}
+	}
+	return n, nil
+}
+
+// writerAt is the subset of *os.File the parts of a download are written with.
+type writerAt interface {
+	WriteAt(p []byte, off int64) (int, error)
+}
+
+// offsetWriter writes sequentially to a writerAt from the starting offset.
+type offsetWriter struct {
+	w      writerAt
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// VerifyHash checks that the SHA-512 hash of the image matches the expected
+// hash, given in the form used for appc image IDs: "sha512-" followed by at
+// least the first 32 hex characters of the hash. The image is rewound after it
+// has been read.
+func VerifyHash(image io.ReadSeeker, hash string) error {
+	expected := strings.ToLower(strings.TrimPrefix(hash, "sha512-"))
+	if expected == hash || len(expected) < 32 || len(expected) > sha512.Size*2 {
+		return fmt.Errorf("invalid image hash %q, must be sha512- and at least 32 hex characters", hash)
+	}
+
+	h := sha512.New()
+	if _, err := io.Copy(h, image); err != nil {
+		return err
+	}
+	if _, err := image.Seek(0, 0); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.HasPrefix(actual, expected) {
+		return fmt.Errorf("image hash sha512-%s does not match the expected %s", actual[:32], hash)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer serves the content at /image.aci with support for ranged
+// requests, failing the first failures GET requests with a 503.
+type rangeServer struct {
+	content  []byte
+	failures int
+
+	mutex  sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		s.mutex.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		fail := s.failures > 0
+		s.failures--
+		s.mutex.Unlock()
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if r.URL.Path != "/image.aci" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, "image.aci", time.Time{}, bytes.NewReader(s.content))
+}
+
+func TestDownload(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "download")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(d string, size int64, backoff time.Duration) {
+		DownloadDirectory, ParallelDownloadSize, RetryBackoff = d, size, backoff
+	}(DownloadDirectory, ParallelDownloadSize, RetryBackoff)
+	DownloadDirectory = dir
+	ParallelDownloadSize = 1024
+	RetryBackoff = time.Millisecond
+
+	content := []byte(strings.Repeat("kurma", 1000))
+	rs := &rangeServer{content: content, failures: 2}
+	server := httptest.NewServer(rs)
+	defer server.Close()
+
+	// the image is downloaded in parts into the download directory, with the
+	// failed requests retried
+	r, err := RetrieveImage(server.URL+"/image.aci", true)
+	if err != nil {
+		t.Fatalf("Expected no error downloading the image; got %s", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Error reading the image: %s", err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("Downloaded image does not match; got %d bytes", len(b))
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 1 {
+		t.Fatalf("Expected the image to be within the download directory; found %d files", len(fis))
+	}
+	r.Close()
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 0 {
+		t.Fatalf("Expected the image to be removed when closed; found %d files", len(fis))
+	}
+	if len(rs.ranges) != DownloadConnections+2 {
+		t.Fatalf("Expected %d requests; got %d", DownloadConnections+2, len(rs.ranges))
+	}
+	for _, rg := range rs.ranges {
+		if !strings.HasPrefix(rg, "bytes=") {
+			t.Fatalf("Expected only ranged requests; got %q", rg)
+		}
+	}
+
+	// small images are downloaded with a single request
+	rs.ranges = nil
+	ParallelDownloadSize = int64(len(content)) + 1
+	r, err = RetrieveImage(server.URL+"/image.aci", true)
+	if err != nil {
+		t.Fatalf("Expected no error downloading the image; got %s", err)
+	}
+	r.Close()
+	if len(rs.ranges) != 1 || rs.ranges[0] != "" {
+		t.Fatalf("Expected a single request for the whole image; got %q", rs.ranges)
+	}
+
+	// requests which keep failing eventually give up, and client errors
+	// aren't retried
+	rs.failures = DownloadRetries + 1
+	if _, err := RetrieveImage(server.URL+"/image.aci", true); err == nil {
+		t.Fatalf("Expected an error once the retries were exhausted")
+	}
+	rs.ranges = nil
+	if _, err := RetrieveImage(server.URL+"/missing.aci", true); err == nil {
+		t.Fatalf("Expected an error retrieving a missing image")
+	}
+	if len(rs.ranges) != 1 {
+		t.Fatalf("Expected the missing image to be requested once; got %d requests", len(rs.ranges))
+	}
+}
+
+func TestVerifyHash(t *testing.T) {
+	content := []byte("kurma")
+	sum := sha512.Sum512(content)
+	hash := "sha512-" + hex.EncodeToString(sum[:])
+
+	r := bytes.NewReader(content)
+	if err := VerifyHash(r, hash); err != nil {
+		t.Fatalf("Expected the full hash to match; got %s", err)
+	}
+	if err := VerifyHash(r, hash[:7+32]); err != nil {
+		t.Fatalf("Expected the truncated hash to match; got %s", err)
+	}
+	if b, _ := ioutil.ReadAll(r); !bytes.Equal(b, content) {
+		t.Fatalf("Expected the image to be rewound after verifying")
+	}
+
+	r.Seek(0, 0)
+	other := sha512.Sum512([]byte("other"))
+	if err := VerifyHash(r, "sha512-"+hex.EncodeToString(other[:])); err == nil {
+		t.Fatalf("Expected an error verifying a different hash")
+	}
+	for _, invalid := range []string{hex.EncodeToString(sum[:]), "sha512-abc", "sha256-" + hex.EncodeToString(sum[:32])} {
+		if err := VerifyHash(r, invalid); err == nil {
+			t.Fatalf("Expected an error for the invalid hash %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+)
+
+const (
+	// ociLayoutFile and ociIndexFile are the files at the top of an OCI image
+	// layout, marking it as one and listing the manifests it holds.
+	ociLayoutFile = "oci-layout"
+	ociIndexFile  = "index.json"
+
+	// ociRefNameAnnotation is the annotation on the index's manifests which
+	// gives the reference they're known by.
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+	// ociImageName is the repository used to name images whose reference
+	// doesn't give one, such as those tagged only with a version.
+	ociImageName = "oci-image"
+
+	// maxOCIIndexDepth limits how many nested indexes are followed to reach an
+	// image manifest.
+	maxOCIIndexDepth = 4
+)
+
+// IsOCILayout returns whether the archive read from r, which may be
+// compressed, holds an OCI image layout rather than an ACI. The reader is
+// rewound once it has been checked.
+func IsOCILayout(r io.ReadSeeker) (bool, error) {
+	arch, err := tarhelper.DetectArchiveCompression(r)
+	if err != nil {
+		return false, err
+	}
+	layout := false
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return false, err
+		}
+		name := ociEntryName(header.Name)
+		if name == "manifest" {
+			layout = false
+			break
+		}
+		if name == ociLayoutFile || name == ociIndexFile {
+			layout = true
+		}
+	}
+	if _, err := r.Seek(0, 0); err != nil {
+		return false, err
+	}
+	return layout, nil
+}
+
+// OpenImage returns the image read from r as an ACI. OCI image layouts are
+// converted to an ACI within a temporary file in the DownloadDirectory, which
+// is removed once it's closed, and r is closed. Anything else is returned
+// rewound as it is, leaving those which aren't archives to fail once they're
+// read as an ACI.
+func OpenImage(r ReaderCloserSeeker) (ReaderCloserSeeker, error) {
+	layout, err := IsOCILayout(r)
+	if err != nil || !layout {
+		if _, err := r.Seek(0, 0); err != nil {
+			r.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+	defer r.Close()
+
+	f, err := ioutil.TempFile(DownloadDirectory, "remote-aci-tarfile")
+	if err != nil {
+		return nil, err
+	}
+	tr := &tempFileReader{file: f}
+	if _, err := ConvertOCILayout(tr.file, r); err != nil {
+		tr.Close()
+		return nil, fmt.Errorf("failed to convert the OCI image layout to an ACI: %v", err)
+	}
+	if _, err := tr.file.Seek(0, 0); err != nil {
+		tr.Close()
+		return nil, err
+	}
+	return tr, nil
+}
+
+// ConvertOCILayout writes the image held in the OCI image layout archive to w
+// as an ACI, returning the ACI's manifest. If the layout's index lists more
+// than one image, the one for linux and the current architecture is used. The
+// image is named after the reference annotated on it when that names a
+// repository, and after ociImageName otherwise. Each blob's digest is verified
+// as it's read.
+func ConvertOCILayout(w io.Writer, layout io.ReadSeeker) (*schema.ImageManifest, error) {
+	var index dockerManifest
+	if err := readOCIFile(layout, ociIndexFile, "", &index); err != nil {
+		return nil, err
+	}
+	manifest, desc, err := resolveOCIManifest(layout, &index, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var config dockerImageConfig
+	if err := readOCIFile(layout, ociBlobPath(manifest.Config.Digest), manifest.Config.Digest, &config); err != nil {
+		return nil, fmt.Errorf("failed to read image config: %v", err)
+	}
+
+	layers, err := extractOCILayers(layout, manifest.Layers)
+	defer func() {
+		for _, f := range layers {
+			if f != nil {
+				f.Close()
+				os.Remove(f.Name())
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	// repeated layers are only extracted once, but each is applied in turn
+	ordered := make([]*os.File, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		ordered[i] = layers[layer.Digest]
+	}
+
+	img := ociImageReference(desc.Annotations[ociRefNameAnnotation])
+	return convertDockerImage(w, img, &config, ordered)
+}
+
+// resolveOCIManifest returns the image manifest selected from the index, along
+// with the descriptor which referenced it from the layout's index.
+func resolveOCIManifest(layout io.ReadSeeker, index *dockerManifest, depth int) (*dockerManifest, *dockerDescriptor, error) {
+	if depth > maxOCIIndexDepth {
+		return nil, nil, fmt.Errorf("image indexes are nested too deeply")
+	}
+
+	var desc *dockerDescriptor
+	for i, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+			desc = &index.Manifests[i]
+			break
+		}
+	}
+	if desc == nil {
+		switch len(index.Manifests) {
+		case 0:
+			return nil, nil, fmt.Errorf("the image index lists no manifests")
+		case 1:
+			desc = &index.Manifests[0]
+		default:
+			return nil, nil, fmt.Errorf("no image for linux/%s was found in the image index", runtime.GOARCH)
+		}
+	}
+
+	var manifest dockerManifest
+	if err := readOCIFile(layout, ociBlobPath(desc.Digest), desc.Digest, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	if manifest.MediaType == mediaTypeOCIIndex || manifest.MediaType == mediaTypeDockerManifestList || len(manifest.Manifests) > 0 {
+		nested, _, err := resolveOCIManifest(layout, &manifest, depth+1)
+		return nested, desc, err
+	}
+	return &manifest, desc, nil
+}
+
+// ociImageReference returns the reference the image is named after. A full
+// reference names the image after its repository, while a bare tag is used as
+// the version of an image named after ociImageName.
+func ociImageReference(ref string) *dockerImage {
+	if strings.Contains(ref, "/") {
+		if img, err := parseDockerURI("docker://" + ref); err == nil {
+			return img
+		}
+	}
+	img := &dockerImage{repository: ociImageName, reference: ref}
+	if ref == "" || strings.ContainsAny(ref, "/@") {
+		img.reference = "latest"
+	}
+	return img
+}
+
+// readOCIFile decodes the JSON file within the layout archive into v. If the
+// digest is given, the file's contents are verified against it.
+func readOCIFile(layout io.ReadSeeker, name, digest string, v interface{}) error {
+	if name == "" {
+		return fmt.Errorf("unsupported digest %q", digest)
+	}
+	found := false
+	err := walkOCILayout(layout, func(entry string, r io.Reader) error {
+		if entry != name || found {
+			return nil
+		}
+		found = true
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if digest != "" {
+			if sum := sha256Digest(b); sum != digest {
+				return fmt.Errorf("digest mismatch, expected %s but got %s", digest, sum)
+			}
+		}
+		return json.Unmarshal(b, v)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s is missing from the image layout", name)
+	}
+	return nil
+}
+
+// extractOCILayers copies each of the layer blobs out of the layout archive
+// into temporary files, verifying their digests, and returns them by digest.
+// The files which were created are returned even if it fails, so the caller
+// may remove them.
+func extractOCILayers(layout io.ReadSeeker, descs []dockerDescriptor) (map[string]*os.File, error) {
+	wanted := make(map[string]string)
+	for _, desc := range descs {
+		name := ociBlobPath(desc.Digest)
+		if name == "" {
+			return nil, fmt.Errorf("unsupported digest %q", desc.Digest)
+		}
+		wanted[name] = desc.Digest
+	}
+
+	layers := make(map[string]*os.File)
+	err := walkOCILayout(layout, func(entry string, r io.Reader) error {
+		digest, ok := wanted[entry]
+		if !ok || layers[digest] != nil {
+			return nil
+		}
+		f, err := ioutil.TempFile(DownloadDirectory, "remote-oci-blob")
+		if err != nil {
+			return err
+		}
+		layers[digest] = f
+
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+			return err
+		}
+		if sum := "sha256:" + hex.EncodeToString(h.Sum(nil)); sum != digest {
+			return fmt.Errorf("digest mismatch for layer %s, got %s", digest, sum)
+		}
+		_, err = f.Seek(0, 0)
+		return err
+	})
+	if err != nil {
+		return layers, err
+	}
+	for _, digest := range wanted {
+		if layers[digest] == nil {
+			return layers, fmt.Errorf("layer %s is missing from the image layout", digest)
+		}
+	}
+	return layers, nil
+}
+
+// walkOCILayout calls fn with the name and contents of each regular file within
+// the layout archive, from its start.
+func walkOCILayout(layout io.ReadSeeker, fn func(name string, r io.Reader) error) error {
+	if _, err := layout.Seek(0, 0); err != nil {
+		return err
+	}
+	arch, err := tarhelper.DetectArchiveCompression(layout)
+	if err != nil {
+		return err
+	}
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(ociEntryName(header.Name), arch); err != nil {
+			return err
+		}
+	}
+}
+
+// ociEntryName returns the path of the archive entry relative to the top of
+// the layout.
+func ociEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// ociBlobPath returns the path of the blob with the digest within the layout,
+// or an empty string if the digest isn't a sha256 digest.
+func ociBlobPath(digest string) string {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return ""
+	}
+	sum := strings.TrimPrefix(digest, "sha256:")
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return ""
+	}
+	return path.Join("blobs", "sha256", sum)
+}
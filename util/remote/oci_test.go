@@ -0,0 +1,203 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appc/spec/schema"
+)
+
+// testOCILayout returns an OCI image layout archive holding an image with the
+// layers, whose manifest is annotated with the reference if it's given.
+func testOCILayout(t *testing.T, ref string, layers ...[]byte) []byte {
+	config := []byte(`{
+		"architecture": "amd64",
+		"os": "linux",
+		"config": {
+			"Env": ["PATH=/usr/bin:/bin", "FOO=bar"],
+			"Entrypoint": ["/bin/app"],
+			"Cmd": ["--flag"]
+		}
+	}`)
+	blobs := map[string][]byte{testDigest(config): config}
+	manifest := dockerManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeOCIManifest,
+		Config:        dockerDescriptor{Digest: testDigest(config)},
+	}
+	for _, l := range layers {
+		blobs[testDigest(l)] = l
+		manifest.Layers = append(manifest.Layers, dockerDescriptor{Digest: testDigest(l)})
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Error marshaling manifest: %s", err)
+	}
+	blobs[testDigest(manifestBytes)] = manifestBytes
+
+	index := dockerManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeOCIIndex,
+		Manifests:     []dockerDescriptor{{MediaType: mediaTypeOCIManifest, Digest: testDigest(manifestBytes)}},
+	}
+	if ref != "" {
+		index.Manifests[0].Annotations = map[string]string{ociRefNameAnnotation: ref}
+	}
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("Error marshaling index: %s", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	write := func(name string, content []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("Error writing header: %s", err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatalf("Error writing file: %s", err)
+		}
+	}
+	write("./oci-layout", []byte(`{"imageLayoutVersion": "1.0.0"}`))
+	write("./index.json", indexBytes)
+	for digest, b := range blobs {
+		write("./blobs/sha256/"+strings.TrimPrefix(digest, "sha256:"), b)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error writing the layout: %s", err)
+	}
+	return buf.Bytes()
+}
+
+// readTestACI returns the manifest and files within the ACI.
+func readTestACI(t *testing.T, r io.Reader) (*schema.ImageManifest, map[string]string) {
+	var manifest *schema.ImageManifest
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error reading the ACI: %s", err)
+		}
+		b, _ := ioutil.ReadAll(tr)
+		if header.Name == "manifest" {
+			if err := json.Unmarshal(b, &manifest); err != nil {
+				t.Fatalf("Error parsing the image manifest: %s", err)
+			}
+			continue
+		}
+		files[header.Name] = string(b)
+	}
+	return manifest, files
+}
+
+func TestConvertOCILayout(t *testing.T) {
+	layout := testOCILayout(t, "example.com/test/app:1.0",
+		testLayer(t, [][2]string{{"bin/", ""}, {"bin/app", "binary"}, {"etc/", ""}, {"etc/config", "old"}}),
+		testLayer(t, [][2]string{{"etc/", ""}, {"etc/config", "new"}}))
+
+	// Test 1: The layout is told apart from an ACI.
+	if ok, err := IsOCILayout(bytes.NewReader(layout)); err != nil || !ok {
+		t.Fatalf("Expected the layout to be detected; got %v, %v", ok, err)
+	}
+	aci := testACI(t, testPushManifest(), "bin/app", "binary")
+	if ok, err := IsOCILayout(bytes.NewReader(aci)); err != nil || ok {
+		t.Fatalf("Expected the ACI not to be detected as a layout; got %v, %v", ok, err)
+	}
+
+	// Test 2: The layout converts to an ACI with the layers applied in order.
+	var buf bytes.Buffer
+	manifest, err := ConvertOCILayout(&buf, bytes.NewReader(layout))
+	if err != nil {
+		t.Fatalf("Expected no error converting the layout; got %s", err)
+	}
+	written, files := readTestACI(t, &buf)
+	if written == nil || written.Name != manifest.Name {
+		t.Fatalf("Expected the returned manifest to be written; got %#v", written)
+	}
+	if manifest.Name.String() != "example.com/test/app" {
+		t.Fatalf("Unexpected image name %q", manifest.Name)
+	}
+	if v, _ := manifest.GetLabel("version"); v != "1.0" {
+		t.Fatalf("Expected the version label 1.0; got %q", v)
+	}
+	if manifest.App == nil || len(manifest.App.Exec) != 2 || manifest.App.Exec[0] != "/bin/app" {
+		t.Fatalf("Unexpected app %#v", manifest.App)
+	}
+	if files["rootfs/bin/app"] != "binary" || files["rootfs/etc/config"] != "new" {
+		t.Fatalf("Unexpected files %v", files)
+	}
+
+	// Test 3: FindManifest detects the layout as well.
+	found, err := FindManifest(bytes.NewReader(layout))
+	if err != nil {
+		t.Fatalf("Expected no error finding the manifest; got %s", err)
+	}
+	if found.Name != manifest.Name {
+		t.Fatalf("Expected the converted manifest; got %q", found.Name)
+	}
+
+	// Test 4: A layout tagged only with a version is named after ociImageName.
+	manifest, err = ConvertOCILayout(ioutil.Discard, bytes.NewReader(testOCILayout(t, "2.0",
+		testLayer(t, [][2]string{{"bin/", ""}, {"bin/app", "binary"}}))))
+	if err != nil {
+		t.Fatalf("Expected no error converting the layout; got %s", err)
+	}
+	if v, _ := manifest.GetLabel("version"); manifest.Name.String() != ociImageName || v != "2.0" {
+		t.Fatalf("Unexpected image %s version %s", manifest.Name, v)
+	}
+	if _, ok := manifest.Annotations.Get("docker-registry"); ok {
+		t.Fatalf("Expected no registry annotation on an image from a layout")
+	}
+
+	// Test 5: Blobs which don't match their digest are rejected.
+	corrupt := bytes.Replace(layout, []byte(`"FOO=bar"`), []byte(`"FOO=baz"`), 1)
+	if _, err := ConvertOCILayout(ioutil.Discard, bytes.NewReader(corrupt)); err == nil {
+		t.Fatalf("Expected an error converting a corrupt layout")
+	}
+}
+
+func TestRetrieveOCILayout(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "oci")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { DownloadDirectory = d }(DownloadDirectory)
+	DownloadDirectory = dir
+
+	name := filepath.Join(dir, "app.tar")
+	layout := testOCILayout(t, "", testLayer(t, [][2]string{{"bin/", ""}, {"bin/app", "binary"}}))
+	if err := ioutil.WriteFile(name, layout, 0644); err != nil {
+		t.Fatalf("Error writing the layout: %s", err)
+	}
+
+	r, err := RetrieveImage("file://"+name, true)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the layout; got %s", err)
+	}
+	manifest, files := readTestACI(t, r)
+	if manifest == nil || manifest.Name.String() != ociImageName || files["rootfs/bin/app"] != "binary" {
+		t.Fatalf("Expected the layout to be retrieved as an ACI; got %v", files)
+	}
+
+	// the converted image is removed once it's closed
+	if err := r.Close(); err != nil {
+		t.Fatalf("Expected no error closing the image; got %s", err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the layout to remain; got %d files", len(entries))
+	}
+}
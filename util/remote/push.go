@@ -0,0 +1,162 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/appc/spec/discovery"
+	"github.com/appc/spec/schema"
+)
+
+// PushOptions are the credentials and settings an image is pushed with.
+type PushOptions struct {
+	// Username and Password, if set, authenticate with the destination, which
+	// otherwise uses the credentials set for its host with SetCredentials.
+	Username string
+	Password string
+
+	// Insecure allows pushing to destinations which are only served over
+	// plain HTTP.
+	Insecure bool
+
+	// Progress, if set, is called with the number of bytes uploaded and the
+	// total to be uploaded as the push proceeds.
+	Progress func(sent, total int64)
+}
+
+// PushImage uploads the ACI to the destination, and returns where it was pushed
+// to. The destination is either:
+//
+//   - an http or https URL, which the ACI is PUT to.
+//   - a docker:// URI, which the image is pushed to as a Docker image with its
+//     root filesystem as a single layer.
+//   - an image name with labels, in the form images are retrieved by such as
+//     example.com/app:1.0, which the ACI is PUT to the URL found for through
+//     appc discovery. Labels which aren't given are taken from the image.
+//
+// Images are pushed under their own name and labels through discovery if no
+// destination is given.
+func PushImage(image io.ReadSeeker, destination string, opts PushOptions) (string, error) {
+	manifest, err := FindManifest(image)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the image's manifest: %v", err)
+	}
+	size, err := image.Seek(0, 2)
+	if err != nil {
+		return "", err
+	}
+	if _, err := image.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return destination, putImage(image, size, destination, opts)
+
+	case "docker":
+		return pushDockerImage(image, manifest, destination, opts)
+
+	case "":
+		uri, err := discoverPushURL(destination, manifest, opts.Insecure)
+		if err != nil {
+			return "", err
+		}
+		return uri, putImage(image, size, uri, opts)
+
+	default:
+		return "", fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
+
+// discoverPushURL returns the URL the image is retrieved from by the name and
+// labels through appc discovery, which it is pushed to.
+func discoverPushURL(name string, manifest *schema.ImageManifest, insecure bool) (string, error) {
+	if name == "" {
+		name = manifest.Name.String()
+	}
+	app, err := discovery.NewAppFromString(name)
+	if err != nil {
+		return "", err
+	}
+	for _, l := range manifest.Labels {
+		if _, ok := app.Labels[l.Name]; !ok {
+			app.Labels[l.Name] = l.Value
+		}
+	}
+
+	endpoints, _, err := discovery.DiscoverEndpoints(*app, insecure)
+	if err != nil {
+		return "", err
+	}
+	if len(endpoints.ACIEndpoints) == 0 {
+		return "", fmt.Errorf("no image endpoint was discovered for %q", name)
+	}
+	return endpoints.ACIEndpoints[0].ACI, nil
+}
+
+// putImage uploads the image of the size to the URL with a PUT request.
+func putImage(image io.Reader, size int64, uri string, opts PushOptions) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "http" && !opts.Insecure {
+		return fmt.Errorf("images may only be pushed over plain HTTP as insecure")
+	}
+
+	req, err := http.NewRequest("PUT", uri, newProgressReader(image, size, opts.Progress))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	} else if err := authorizeRequest(req); err != nil {
+		return err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+	return fmt.Errorf("HTTP %d on pushing to %s", resp.StatusCode, uri)
+}
+
+// progressReader reports the bytes read through it to the progress function.
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+// newProgressReader returns a reader reporting the progress of reading r, which
+// holds total bytes. If progress is nil, r is returned as is.
+func newProgressReader(r io.Reader, total int64, progress func(sent, total int64)) io.Reader {
+	if progress == nil {
+		return r
+	}
+	return &progressReader{r: r, total: total, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.progress(r.sent, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,261 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// testACI returns an ACI with the manifest and the files, given as alternating
+// names and contents within the rootfs.
+func testACI(t *testing.T, manifest *schema.ImageManifest, files ...string) []byte {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Error marshaling manifest: %s", err)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	write := func(header *tar.Header, content string) {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Error writing header: %s", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Error writing file: %s", err)
+		}
+	}
+	write(&tar.Header{Name: "manifest", Mode: 0644, Size: int64(len(b))}, string(b))
+	write(&tar.Header{Name: "rootfs/", Mode: 0755, Typeflag: tar.TypeDir}, "")
+	for i := 0; i < len(files); i += 2 {
+		write(&tar.Header{Name: "rootfs/" + files[i], Mode: 0755, Size: int64(len(files[i+1]))}, files[i+1])
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error writing the ACI: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func testPushManifest() *schema.ImageManifest {
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier("example.com/app")
+	manifest.Labels = types.Labels{{Name: "version", Value: "1.0"}, {Name: "arch", Value: "aarch64"}}
+	manifest.App = &types.App{
+		Exec:  types.Exec{"/bin/app", "--flag"},
+		User:  "0",
+		Group: "0",
+		Ports: []types.Port{{Name: "http", Protocol: "tcp", Port: 80, Count: 1}},
+	}
+	manifest.App.Environment.Set("FOO", "bar")
+	return manifest
+}
+
+func TestPushImage(t *testing.T) {
+	aci := testACI(t, testPushManifest(), "bin/app", "binary")
+
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != "PUT" || r.URL.Path != "/app.aci" {
+			http.NotFound(w, r)
+			return
+		}
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// Test 1: The image is put at the URL, and its progress reported.
+	var sent, total int64
+	opts := PushOptions{
+		Username: "user",
+		Password: "pass",
+		Insecure: true,
+		Progress: func(s, t int64) { sent, total = s, t },
+	}
+	location, err := PushImage(bytes.NewReader(aci), server.URL+"/app.aci", opts)
+	if err != nil {
+		t.Fatalf("Expected no error pushing the image; got %s", err)
+	}
+	if location != server.URL+"/app.aci" || !bytes.Equal(received, aci) {
+		t.Fatalf("Unexpected push to %s of %d bytes", location, len(received))
+	}
+	if sent != int64(len(aci)) || total != int64(len(aci)) {
+		t.Fatalf("Unexpected progress %d of %d", sent, total)
+	}
+
+	// Test 2: Plain HTTP requires the push to be insecure, and failures are
+	// returned.
+	opts.Insecure = false
+	if _, err := PushImage(bytes.NewReader(aci), server.URL+"/app.aci", opts); err == nil {
+		t.Fatalf("Expected an error pushing over plain HTTP")
+	}
+	opts.Insecure = true
+	opts.Password = "wrong"
+	if _, err := PushImage(bytes.NewReader(aci), server.URL+"/app.aci", opts); err == nil {
+		t.Fatalf("Expected an error pushing with the wrong password")
+	}
+}
+
+// testRegistry is a Docker registry holding the blobs and manifests pushed to
+// it. Anonymous tokens may only pull, unless the registry is private, and
+// tokens retrieved with the password may also push.
+type testRegistry struct {
+	server    *httptest.Server
+	private   bool
+	mutex     sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+}
+
+func newTestRegistry() *testRegistry {
+	reg := &testRegistry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	reg.server = httptest.NewServer(http.HandlerFunc(reg.serve))
+	return reg
+}
+
+func (reg *testRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/token":
+		_, pass, ok := r.BasicAuth()
+		switch {
+		case !ok && !reg.private:
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+		case pass == "pass":
+			fmt.Fprint(w, `{"token": "secret"}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		return
+	case r.Header.Get("Authorization") == "Bearer anonymous" && (r.Method == "GET" || r.Method == "HEAD"):
+	case r.Header.Get("Authorization") != "Bearer secret":
+		w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, reg.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/v2/test/app/"
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+	switch {
+	case r.Method == "POST" && p == "blobs/uploads/":
+		w.Header().Set("Location", prefix+"blobs/uploads/1")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == "PUT" && p == "blobs/uploads/1":
+		b, _ := ioutil.ReadAll(r.Body)
+		digest := r.URL.Query().Get("digest")
+		if testDigest(b) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reg.blobs[digest] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && strings.HasPrefix(p, "manifests/"):
+		b, _ := ioutil.ReadAll(r.Body)
+		reg.manifests[strings.TrimPrefix(p, "manifests/")] = b
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(p, "blobs/"):
+		if b, ok := reg.blobs[strings.TrimPrefix(p, "blobs/")]; ok {
+			w.Write(b)
+			return
+		}
+		http.NotFound(w, r)
+	case strings.HasPrefix(p, "manifests/"):
+		if b, ok := reg.manifests[strings.TrimPrefix(p, "manifests/")]; ok {
+			w.Write(b)
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPushDockerImage(t *testing.T) {
+	aci := testACI(t, testPushManifest(), "bin/app", "binary", "etc/config", "setting")
+	reg := newTestRegistry()
+	defer reg.server.Close()
+	host := strings.TrimPrefix(reg.server.URL, "http://")
+	uri := fmt.Sprintf("docker://%s/test/app:1.0", host)
+
+	location, err := PushImage(bytes.NewReader(aci), uri, PushOptions{Username: "user", Password: "pass", Insecure: true})
+	if err != nil {
+		t.Fatalf("Expected no error pushing the image; got %s", err)
+	}
+	if location != uri {
+		t.Fatalf("Unexpected location %q", location)
+	}
+
+	var manifest dockerManifest
+	if err := json.Unmarshal(reg.manifests["1.0"], &manifest); err != nil {
+		t.Fatalf("Error parsing the pushed manifest: %s", err)
+	}
+	if manifest.MediaType != mediaTypeDockerManifest || len(manifest.Layers) != 1 {
+		t.Fatalf("Unexpected manifest %#v", manifest)
+	}
+	var config dockerImageConfig
+	if err := json.Unmarshal(reg.blobs[manifest.Config.Digest], &config); err != nil {
+		t.Fatalf("Error parsing the pushed config: %s", err)
+	}
+	if config.Architecture != "arm64" || config.Config.User != "" || len(config.RootFS.DiffIDs) != 1 {
+		t.Fatalf("Unexpected config %#v", config)
+	}
+
+	// the image converts back to the same files when it is retrieved
+	r, err := RetrieveImage(uri, true)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the pushed image; got %s", err)
+	}
+	defer r.Close()
+	files := make(map[string]string)
+	var retrieved schema.ImageManifest
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error reading the ACI: %s", err)
+		}
+		b, _ := ioutil.ReadAll(tr)
+		if header.Name == "manifest" {
+			json.Unmarshal(b, &retrieved)
+			continue
+		}
+		files[header.Name] = string(b)
+	}
+	if files["rootfs/bin/app"] != "binary" || files["rootfs/etc/config"] != "setting" {
+		t.Fatalf("Unexpected files %v", files)
+	}
+	if retrieved.App == nil || len(retrieved.App.Exec) != 2 || retrieved.App.Exec[0] != "/bin/app" {
+		t.Fatalf("Unexpected app %#v", retrieved.App)
+	}
+	if v, _ := retrieved.App.Environment.Get("FOO"); v != "bar" {
+		t.Fatalf("Expected FOO to be set in the environment")
+	}
+
+	// Test 2: The wrong password can't retrieve a token, and digests can't be
+	// pushed to.
+	if _, err := PushImage(bytes.NewReader(aci), uri, PushOptions{Username: "user", Password: "wrong", Insecure: true}); err == nil {
+		t.Fatalf("Expected an error pushing with the wrong password")
+	}
+	if _, err := PushImage(bytes.NewReader(aci), uri+"@sha256:abcd", PushOptions{Insecure: true}); err == nil {
+		t.Fatalf("Expected an error pushing to a digest")
+	}
+}
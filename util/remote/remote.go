@@ -0,0 +1,190 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/appc/spec/discovery"
+)
+
+var (
+	// Client is the http.Client that is used by RetrieveImage to download
+	// images.
+	Client *http.Client = &http.Client{
+		Transport: &http.Transport{},
+	}
+)
+
+// RetrieveImage can be used to retrieve a remote image, and optionally discover
+// an image based on the App Container Image Discovery specification. Supports
+// handling local images as well as images from a Docker registry, which are
+// converted to an ACI as they're retrieved, as are OCI image layouts. Unless
+// insecure is set, the image's signature is retrieved and verified against the
+// trusted keys within the KeystoreDirectory. Images retrieved over http or https are downloaded into the
+// DownloadDirectory, over concurrent ranged requests if they're large, and
+// requests are retried with backoff if they fail transiently.
+func RetrieveImage(imageUri string, insecure bool) (ReaderCloserSeeker, error) {
+	u, err := url.Parse(imageUri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file", "http", "https":
+		r, err := openURL(imageUri)
+		if err != nil {
+			return nil, err
+		}
+		if !insecure {
+			if r, err = checkSignature(r, imageUri+".asc"); err != nil {
+				return nil, err
+			}
+		}
+		return OpenImage(r)
+
+	case "docker":
+		// Docker images are pulled from the registry and converted to an ACI.
+		// They have no signature which can be verified.
+		if !insecure {
+			return nil, fmt.Errorf("docker images cannot be verified and must be retrieved as insecure")
+		}
+		return retrieveDockerImage(imageUri, insecure)
+
+	case "":
+		app, err := discovery.NewAppFromString(imageUri)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints, _, err := discovery.DiscoverEndpoints(*app, insecure)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		for _, ep := range endpoints.ACIEndpoints {
+			r, err := openURL(ep.ACI)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if !insecure {
+				r, err = checkSignature(r, ep.ASC)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+			}
+			return OpenImage(r)
+		}
+		if lastErr != nil {
+			return nil, fmt.Errorf("failed to find a valid image for %q: %v", imageUri, lastErr)
+		}
+		return nil, fmt.Errorf("failed to find a valid image for %q", imageUri)
+
+	default:
+		return nil, fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
+
+// RetrieveSignedFile retrieves the file at the file, http, or https url, such
+// as an update bundle, and verifies it with the armored detached signature at
+// the url with ".asc" appended. The signature must be made by a key within the
+// KeystoreDirectory which is trusted for the name, as it would be for an image
+// with that name.
+func RetrieveSignedFile(uri, name string) (ReaderCloserSeeker, error) {
+	r, err := openURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := openURL(uri + ".asc")
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to retrieve signature: %v", err)
+	}
+	defer signature.Close()
+
+	if err := verifyDetachedSignature(name, r, signature); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openURL opens the file referenced by a file, http, or https url.
+func openURL(uri string) (ReaderCloserSeeker, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		// for file:// urls, just load the file and return it
+		return os.Open(u.Path)
+
+	case "http", "https":
+		// Handle HTTP retrievals, wrapped with a tempfile that cleans up.
+		return download(uri)
+
+	default:
+		return nil, fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
+
+// checkSignature retrieves the signature at the specified url and verifies the
+// image with it. The image is closed if it could not be verified.
+func checkSignature(image ReaderCloserSeeker, signatureUri string) (ReaderCloserSeeker, error) {
+	signature, err := openURL(signatureUri)
+	if err != nil {
+		image.Close()
+		return nil, fmt.Errorf("failed to retrieve signature: %v", err)
+	}
+	defer signature.Close()
+
+	if err := verifySignature(image, signature); err != nil {
+		image.Close()
+		return nil, err
+	}
+	return image, nil
+}
+
+// ReaderCloserSeeker is a generic interface for the functions common for images
+// that are reteived. Seek is important as it will run through the image to
+// locate the ACI manifest before actually extracting it.
+type ReaderCloserSeeker interface {
+	io.Reader
+	io.Closer
+	io.Seeker
+}
+
+// tempFileReader is an implementation of the ReaderCloserSeeker interface which
+// is used with images that are remotely retrieved. It will download the remote
+// file to a local temp file and ensures the file is removed when Close is
+// called.
+type tempFileReader struct {
+	file *os.File
+}
+
+func (r *tempFileReader) Read(p []byte) (int, error) {
+	return r.file.Read(p)
+}
+
+func (r *tempFileReader) Seek(offset int64, whence int) (int64, error) {
+	return r.file.Seek(offset, whence)
+}
+
+func (r *tempFileReader) Close() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(r.file.Name()); err != nil {
+		return err
+	}
+	return nil
+}
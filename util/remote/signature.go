@@ -0,0 +1,156 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+	"golang.org/x/crypto/openpgp"
+)
+
+var (
+	// KeystoreDirectory is the directory containing the trusted public keys used
+	// to verify the signatures of retrieved images. Keys within its root.d
+	// directory are trusted for all images, while keys within prefix.d/<prefix>
+	// are only trusted for images whose name begins with the prefix, such as
+	// prefix.d/example.com/app for example.com/app/*.
+	KeystoreDirectory string
+)
+
+// FindManifest locates the image manifest within an ACI and returns it. If r
+// is an io.ReadSeeker holding an OCI image layout, the manifest of the ACI it
+// converts to is returned.
+func FindManifest(r io.Reader) (*schema.ImageManifest, error) {
+	arch, err := tarhelper.DetectArchiveCompression(r)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := false
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch filepath.Clean(header.Name) {
+		case ociLayoutFile, ociIndexFile:
+			layout = true
+			continue
+		case "manifest":
+		default:
+			continue
+		}
+
+		var manifest *schema.ImageManifest
+		if err := json.NewDecoder(arch).Decode(&manifest); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+
+	rs, ok := r.(io.ReadSeeker)
+	if !layout || !ok {
+		return nil, fmt.Errorf("failed to locate manifest file")
+	}
+	if _, err := rs.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return ConvertOCILayout(ioutil.Discard, rs)
+}
+
+// verifySignature checks that the armored detached signature for the image was
+// made by a key which is trusted for the image's name. The image is rewound
+// once it has been checked.
+func verifySignature(image io.ReadSeeker, signature io.Reader) error {
+	manifest, err := FindManifest(image)
+	if err != nil {
+		return err
+	}
+	if _, err := image.Seek(0, 0); err != nil {
+		return err
+	}
+	return verifyDetachedSignature(manifest.Name.String(), image, signature)
+}
+
+// verifyDetachedSignature checks that the armored detached signature for the
+// file was made by a key which is trusted for the name. The file is rewound
+// once it has been checked.
+func verifyDetachedSignature(name string, file io.ReadSeeker, signature io.Reader) error {
+	keyring, err := trustedKeys(name)
+	if err != nil {
+		return err
+	}
+	if len(keyring) == 0 {
+		return fmt.Errorf("no trusted keys found for %q", name)
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, file, signature); err != nil {
+		return fmt.Errorf("signature verification for %q failed: %v", name, err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// trustedKeys loads the keys from the keystore which are trusted for the image
+// with the specified name.
+func trustedKeys(name string) (openpgp.EntityList, error) {
+	if KeystoreDirectory == "" {
+		return nil, fmt.Errorf("no keystore is configured to verify image signatures")
+	}
+
+	dirs := []string{filepath.Join(KeystoreDirectory, "root.d")}
+	for prefix := path.Clean(name); prefix != "." && prefix != "/"; prefix = path.Dir(prefix) {
+		dirs = append(dirs, filepath.Join(KeystoreDirectory, "prefix.d", filepath.FromSlash(prefix)))
+	}
+
+	var keyring openpgp.EntityList
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, fi := range files {
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+			entities, err := readKeyFile(filepath.Join(dir, fi.Name()))
+			if err != nil {
+				return nil, err
+			}
+			keyring = append(keyring, entities...)
+		}
+	}
+	return keyring, nil
+}
+
+// readKeyFile reads the armored public keys from the specified file.
+func readKeyFile(name string) (openpgp.EntityList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %v", name, err)
+	}
+	return entities, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// writeSignedImage creates a minimal ACI for the named image within dir, along
+// with a signature for it made by the entity. It returns the image's path.
+func writeSignedImage(t *testing.T, dir, name string, entity *openpgp.Entity) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	manifest := []byte(`{"acKind":"ImageManifest","acVersion":"0.7.0","name":"` + name + `"}`)
+	if err := tw.WriteHeader(&tar.Header{Name: "manifest", Mode: 0644, Size: int64(len(manifest))}); err != nil {
+		t.Fatalf("Error writing header: %s", err)
+	}
+	tw.Write(manifest)
+	tw.Close()
+
+	imagePath := filepath.Join(dir, "image.aci")
+	if err := ioutil.WriteFile(imagePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing image: %s", err)
+	}
+
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(buf.Bytes()), nil); err != nil {
+		t.Fatalf("Error signing image: %s", err)
+	}
+	if err := ioutil.WriteFile(imagePath+".asc", sig.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing signature: %s", err)
+	}
+	return imagePath
+}
+
+// writeTrustedKey writes the entity's public key into the keystore directory.
+func writeTrustedKey(t *testing.T, dir string, entity *openpgp.Entity) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Error creating keystore: %s", err)
+	}
+	f, err := os.Create(filepath.Join(dir, "key"))
+	if err != nil {
+		t.Fatalf("Error creating key file: %s", err)
+	}
+	defer f.Close()
+
+	w, err := armor.Encode(f, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("Error serializing key: %s", err)
+	}
+	w.Close()
+}
+
+func TestRetrieveSignedImage(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "signedImage")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+
+	imagePath := writeSignedImage(t, dir, "example.com/app", entity)
+	uri := "file://" + imagePath
+
+	defer func(d string) { KeystoreDirectory = d }(KeystoreDirectory)
+	KeystoreDirectory = filepath.Join(dir, "keystore")
+
+	// with no trusted keys, the image should be refused unless insecure
+	if _, err := RetrieveImage(uri, false); err == nil {
+		t.Fatalf("Expected an error retrieving an image with no trusted keys")
+	}
+	reader, err := RetrieveImage(uri, true)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving an insecure image; got %s", err)
+	}
+	reader.Close()
+
+	// a key trusted for a different prefix should not be used
+	writeTrustedKey(t, filepath.Join(KeystoreDirectory, "prefix.d", "example.com", "other"), entity)
+	if _, err := RetrieveImage(uri, false); err == nil {
+		t.Fatalf("Expected an error retrieving an image with a key for another prefix")
+	}
+
+	// a different key should fail verification
+	writeTrustedKey(t, filepath.Join(KeystoreDirectory, "root.d"), other)
+	if _, err := RetrieveImage(uri, false); err == nil {
+		t.Fatalf("Expected an error retrieving an image signed by an untrusted key")
+	}
+
+	// trusting the key for the image's prefix should succeed
+	writeTrustedKey(t, filepath.Join(KeystoreDirectory, "prefix.d", "example.com"), entity)
+	reader, err = RetrieveImage(uri, false)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving a signed image; got %s", err)
+	}
+	defer reader.Close()
+
+	manifest, err := FindManifest(reader)
+	if err != nil {
+		t.Fatalf("Expected to find the manifest in the image; got %s", err)
+	}
+	if manifest.Name.String() != "example.com/app" {
+		t.Fatalf("Unexpected image name %q", manifest.Name)
+	}
+}
+
+func TestRetrieveSignedFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "signedFile")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+
+	// any file may be signed, not only images
+	path := filepath.Join(dir, "bundle.tar")
+	data := []byte("not an image")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("Error signing file: %s", err)
+	}
+	if err := ioutil.WriteFile(path+".asc", sig.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing signature: %s", err)
+	}
+	uri := "file://" + path
+
+	defer func(d string) { KeystoreDirectory = d }(KeystoreDirectory)
+	KeystoreDirectory = filepath.Join(dir, "keystore")
+
+	// the key must be trusted for the name the file is verified for
+	writeTrustedKey(t, filepath.Join(KeystoreDirectory, "prefix.d", "other"), entity)
+	if _, err := RetrieveSignedFile(uri, "kurma"); err == nil {
+		t.Fatalf("Expected an error retrieving a file with a key for another name")
+	}
+
+	writeTrustedKey(t, filepath.Join(KeystoreDirectory, "prefix.d", "kurma"), entity)
+	reader, err := RetrieveSignedFile(uri, "kurma")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving a signed file; got %s", err)
+	}
+	defer reader.Close()
+
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Error reading file: %s", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("Unexpected contents %q", b)
+	}
+}
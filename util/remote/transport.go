@@ -0,0 +1,168 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ProxyConfig selects the proxy each request is made through, as the
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables do.
+type ProxyConfig struct {
+	// HTTPProxy and HTTPSProxy are the URLs of the proxies used for http and
+	// https requests. Requests are made directly if the proxy for their scheme
+	// isn't set.
+	HTTPProxy  string
+	HTTPSProxy string
+
+	// NoProxy lists the hosts which are reached directly, separated by commas.
+	// Each is a domain name, which also matches its subdomains and may start
+	// with a dot, an IP address, or a CIDR range, optionally followed by a
+	// port, while * matches every host.
+	NoProxy string
+}
+
+// Func returns a function selecting the proxy for each request, for use as an
+// http.Transport's Proxy.
+func (c ProxyConfig) Func() (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, err := parseProxy(c.HTTPProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parseProxy(c.HTTPSProxy)
+	if err != nil {
+		return nil, err
+	}
+	var noProxy []string
+	for _, entry := range strings.Split(c.NoProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := httpProxy
+		if req.URL.Scheme == "https" {
+			proxy = httpsProxy
+		}
+		if proxy == nil || bypassProxy(noProxy, req.URL) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// parseProxy parses the proxy's URL, which defaults to the http scheme if none
+// is given. It returns nil if the proxy isn't set.
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	return u, nil
+}
+
+// bypassProxy returns whether the URL's host matches an entry of the no proxy
+// list.
+func bypassProxy(noProxy []string, u *url.URL) bool {
+	host, port := strings.ToLower(u.Host), ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	for _, entry := range noProxy {
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertPool returns the system's trusted CA certificates along with those in
+// the .pem and .crt files within the directory.
+func CertPool(dir string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || (ext != ".pem" && ext != ".crt") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates were found in %s", fi.Name())
+		}
+	}
+	return pool, nil
+}
+
+// ConfigureTransport sets the transport to make its requests through the
+// proxies, and to trust the CA certificates within the directory as well as
+// the system's, if a directory is given.
+func ConfigureTransport(t *http.Transport, proxy ProxyConfig, caDir string) error {
+	proxyFunc, err := proxy.Func()
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if caDir != "" {
+		if pool, err = CertPool(caDir); err != nil {
+			return err
+		}
+	}
+
+	t.Proxy = proxyFunc
+	if pool != nil {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package remote
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxyConfig(t *testing.T) {
+	proxy, err := ProxyConfig{
+		HTTPProxy:  "proxy.example.com:3128",
+		HTTPSProxy: "https://secure.example.com:3129",
+		NoProxy:    "internal.example.com, .corp.example.com,10.0.0.0/8, 192.168.1.5, mirror.example.com:8080",
+	}.Func()
+	if err != nil {
+		t.Fatalf("Expected no error creating the proxy function; got %s", err)
+	}
+
+	for uri, expected := range map[string]string{
+		"http://images.example.org/app.aci":      "http://proxy.example.com:3128",
+		"https://images.example.org/app.aci":     "https://secure.example.com:3129",
+		"http://internal.example.com/app.aci":    "",
+		"http://a.internal.example.com/app.aci":  "",
+		"http://builds.corp.example.com/app.aci": "",
+		"http://corp.example.com/app.aci":        "",
+		"http://notinternal.example.com/":        "http://proxy.example.com:3128",
+		"http://10.1.2.3/app.aci":                "",
+		"http://192.168.1.5:8000/app.aci":        "",
+		"http://192.168.1.6/app.aci":             "http://proxy.example.com:3128",
+		"http://mirror.example.com:8080/app.aci": "",
+		"http://mirror.example.com/app.aci":      "http://proxy.example.com:3128",
+		"http://localhost:5000/app.aci":          "",
+		"http://127.0.0.1/app.aci":               "",
+	} {
+		req, _ := http.NewRequest("GET", uri, nil)
+		u, err := proxy(req)
+		if err != nil {
+			t.Fatalf("Expected no error selecting the proxy for %s; got %s", uri, err)
+		}
+		actual := ""
+		if u != nil {
+			actual = u.String()
+		}
+		if actual != expected {
+			t.Fatalf("Expected %s to use proxy %q; got %q", uri, expected, actual)
+		}
+	}
+
+	// Test 2: Requests are made directly without a proxy, or with * as the no
+	// proxy list.
+	for _, c := range []ProxyConfig{{}, {HTTPProxy: "proxy.example.com", NoProxy: "*"}} {
+		proxy, err := c.Func()
+		if err != nil {
+			t.Fatalf("Expected no error creating the proxy function; got %s", err)
+		}
+		req, _ := http.NewRequest("GET", "http://images.example.org/app.aci", nil)
+		if u, _ := proxy(req); u != nil {
+			t.Fatalf("Expected no proxy for %#v; got %s", c, u)
+		}
+	}
+
+	if _, err := (ProxyConfig{HTTPProxy: "http://"}).Func(); err == nil {
+		t.Fatalf("Expected an error for an invalid proxy")
+	}
+}
+
+func TestRetrieveImageThroughProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.Write([]byte("image"))
+	}))
+	defer proxy.Close()
+
+	defer func(c *http.Client) { Client = c }(Client)
+	transport := &http.Transport{}
+	Client = &http.Client{Transport: transport}
+	if err := ConfigureTransport(transport, ProxyConfig{HTTPProxy: proxy.URL}, ""); err != nil {
+		t.Fatalf("Expected no error configuring the transport; got %s", err)
+	}
+
+	r, err := RetrieveImage("http://images.example.org/app.aci", true)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the image; got %s", err)
+	}
+	defer r.Close()
+	b, _ := ioutil.ReadAll(r)
+	if string(b) != "image" || requested != "http://images.example.org/app.aci" {
+		t.Fatalf("Expected the image to be retrieved through the proxy; got %q for %q", b, requested)
+	}
+}
+
+func TestConfigureTransportCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir(os.TempDir(), "transport")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Test 1: The server's certificate isn't trusted by default.
+	if _, err := (&http.Client{Transport: &http.Transport{}}).Get(server.URL); err == nil {
+		t.Fatalf("Expected an error from an untrusted certificate")
+	}
+
+	// Test 2: It is trusted once its CA is within the directory, while other
+	// files are ignored.
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.crt"), cert, 0644); err != nil {
+		t.Fatalf("Error writing the certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Error writing the file: %s", err)
+	}
+	transport := &http.Transport{}
+	if err := ConfigureTransport(transport, ProxyConfig{}, dir); err != nil {
+		t.Fatalf("Expected no error configuring the transport; got %s", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the certificate to be trusted; got %s", err)
+	}
+	resp.Body.Close()
+
+	// Test 3: Files without certificates are an error.
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Error writing the file: %s", err)
+	}
+	if err := ConfigureTransport(&http.Transport{}, ProxyConfig{}, dir); err == nil {
+		t.Fatalf("Expected an error for a file without certificates")
+	}
+}
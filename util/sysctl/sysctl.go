@@ -0,0 +1,85 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package sysctl reads and writes kernel parameters through /proc/sys, using
+// their dotted names such as net.ipv4.ip_forward.
+package sysctl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProcSysPath is where the kernel parameters are exposed.
+var ProcSysPath = "/proc/sys"
+
+// Path returns the file within /proc/sys for the parameter, or an error if the
+// name isn't a valid parameter name.
+func Path(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+		return "", fmt.Errorf("invalid sysctl name %q", name)
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == '-':
+		default:
+			return "", fmt.Errorf("invalid sysctl name %q", name)
+		}
+	}
+	parts := strings.Split(name, ".")
+	for _, p := range parts {
+		if p == "" {
+			return "", fmt.Errorf("invalid sysctl name %q", name)
+		}
+	}
+	return filepath.Join(ProcSysPath, filepath.Join(parts...)), nil
+}
+
+// Get returns the value of the parameter.
+func Get(name string) (string, error) {
+	path, err := Path(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// KernelRelease returns the release of the running kernel, as uname reports
+// it.
+func KernelRelease() (string, error) {
+	return Get("kernel.osrelease")
+}
+
+// Set sets the parameter to the value.
+func Set(name, value string) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("unknown sysctl %s", name)
+		}
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte(value)); err != nil {
+		return fmt.Errorf("failed to set %s to %q: %v", name, value, err)
+	}
+	return nil
+}
+
+// Namespaced returns whether the parameter is set per namespace, so it may be
+// set within a container without affecting the host. Only the network
+// parameters and the System V shared memory limits are supported.
+func Namespaced(name string) bool {
+	return strings.HasPrefix(name, "net.") || strings.HasPrefix(name, "kernel.shm")
+}
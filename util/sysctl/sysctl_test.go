@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package sysctl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestPath(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	path, err := Path("net.ipv4.ip_forward")
+	TestExpectSuccess(t, err)
+	TestEqual(t, path, "/proc/sys/net/ipv4/ip_forward")
+
+	path, err = Path("net.ipv4.conf.eth0-1.rp_filter")
+	TestExpectSuccess(t, err)
+	TestEqual(t, path, "/proc/sys/net/ipv4/conf/eth0-1/rp_filter")
+
+	for _, name := range []string{"", ".net", "net.", "net..ipv4", "net/ipv4", "../../etc/passwd", "kernel.shm max"} {
+		_, err := Path(name)
+		TestExpectError(t, err)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir, err := ioutil.TempDir("", "sysctl")
+	TestExpectSuccess(t, err)
+	defer os.RemoveAll(dir)
+	defer func(p string) { ProcSysPath = p }(ProcSysPath)
+	ProcSysPath = dir
+
+	TestExpectSuccess(t, os.MkdirAll(filepath.Join(dir, "vm"), 0755))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "vm", "overcommit_memory"), []byte("0\n"), 0644))
+
+	v, err := Get("vm.overcommit_memory")
+	TestExpectSuccess(t, err)
+	TestEqual(t, v, "0")
+
+	TestExpectSuccess(t, Set("vm.overcommit_memory", "1"))
+	v, err = Get("vm.overcommit_memory")
+	TestExpectSuccess(t, err)
+	TestEqual(t, v, "1")
+
+	TestExpectError(t, Set("vm.missing", "1"))
+}
+
+func TestNamespaced(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, Namespaced("net.ipv4.ip_forward"), true)
+	TestEqual(t, Namespaced("net.core.somaxconn"), true)
+	TestEqual(t, Namespaced("kernel.shmmax"), true)
+	TestEqual(t, Namespaced("kernel.shm_rmid_forced"), true)
+	TestEqual(t, Namespaced("kernel.hostname"), false)
+	TestEqual(t, Namespaced("vm.overcommit_memory"), false)
+	TestEqual(t, Namespaced("network.foo"), false)
+}
+
+func TestKernelRelease(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	dir, err := ioutil.TempDir("", "sysctl")
+	TestExpectSuccess(t, err)
+	defer os.RemoveAll(dir)
+	defer func(p string) { ProcSysPath = p }(ProcSysPath)
+	ProcSysPath = dir
+
+	_, err = KernelRelease()
+	TestExpectError(t, err)
+
+	TestExpectSuccess(t, os.MkdirAll(filepath.Join(dir, "kernel"), 0755))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "kernel", "osrelease"), []byte("4.1.2-kurma\n"), 0644))
+	release, err := KernelRelease()
+	TestExpectSuccess(t, err)
+	TestEqual(t, release, "4.1.2-kurma")
+}
@@ -0,0 +1,189 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package tpm measures data into the PCRs of a TPM 2.0 and quotes them, through
+// the tpm2-tools, so that a remote verifier can check what software the host
+// booted. Only the SHA-256 bank is used.
+package tpm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HashAlgorithm is the PCR bank measurements are extended into and quoted
+// from.
+const HashAlgorithm = "sha256"
+
+// Event is a measurement extended into a PCR, as recorded in the event log a
+// verifier replays to check the PCR's value.
+type Event struct {
+	PCR         int    `json:"pcr"`
+	Description string `json:"description"`
+	Digest      []byte `json:"digest"`
+}
+
+// Quote is a quote of PCRs signed by an attestation key.
+type Quote struct {
+	// Message is the TPMS_ATTEST structure which was signed, holding the
+	// nonce and the digest of the quoted PCRs, and Signature is its
+	// TPMT_SIGNATURE.
+	Message   []byte
+	Signature []byte
+
+	// PublicKey is the attestation key's public key, PEM encoded.
+	PublicKey []byte
+
+	// PCRs are the values of the quoted PCRs, read just after the quote.
+	// They match the digest within the message unless a PCR was extended in
+	// between, which a verifier detects and requests another quote for.
+	PCRs map[int][]byte
+}
+
+// Measure hashes the data and extends the hash into the PCR, returning the
+// event to record in the event log.
+func Measure(pcr int, description string, data io.Reader) (*Event, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %v", description, err)
+	}
+	e := &Event{PCR: pcr, Description: description, Digest: h.Sum(nil)}
+	if err := Extend(pcr, e.Digest); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Extend extends the SHA-256 digest into the PCR.
+func Extend(pcr int, digest []byte) error {
+	if len(digest) != sha256.Size {
+		return fmt.Errorf("the digest must be %d bytes, not %d", sha256.Size, len(digest))
+	}
+	arg := fmt.Sprintf("%d:%s=%s", pcr, HashAlgorithm, hex.EncodeToString(digest))
+	return run("tpm2_pcrextend", arg)
+}
+
+// ReadPCRs returns the values of the PCRs.
+func ReadPCRs(pcrs []int) (map[int][]byte, error) {
+	out, err := exec.Command("tpm2_pcrread", selection(pcrs)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("tpm2_pcrread failed: %v", err)
+	}
+	return parsePCRRead(out)
+}
+
+// QuotePCRs has the TPM quote the PCRs with the nonce, signed by the
+// attestation key at the persistent handle, such as 0x81010002.
+func QuotePCRs(key string, pcrs []int, nonce []byte) (*Quote, error) {
+	dir, err := ioutil.TempDir("", "tpm-quote")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	msg, sig, pub := filepath.Join(dir, "quote.msg"), filepath.Join(dir, "quote.sig"), filepath.Join(dir, "ak.pem")
+	args := []string{"-c", key, "-l", selection(pcrs), "-m", msg, "-s", sig, "-g", HashAlgorithm}
+	if len(nonce) > 0 {
+		args = append(args, "-q", hex.EncodeToString(nonce))
+	}
+	if err := run("tpm2_quote", args...); err != nil {
+		return nil, err
+	}
+	if err := run("tpm2_readpublic", "-c", key, "-f", "pem", "-o", pub); err != nil {
+		return nil, err
+	}
+
+	q := &Quote{}
+	if q.Message, err = ioutil.ReadFile(msg); err != nil {
+		return nil, err
+	}
+	if q.Signature, err = ioutil.ReadFile(sig); err != nil {
+		return nil, err
+	}
+	if q.PublicKey, err = ioutil.ReadFile(pub); err != nil {
+		return nil, err
+	}
+	if q.PCRs, err = ReadPCRs(pcrs); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Replay returns the value the PCR has once the events measured into it have
+// been extended into it from its reset value of zeros.
+func Replay(pcr int, events []*Event) []byte {
+	value := make([]byte, sha256.Size)
+	for _, e := range events {
+		if e.PCR != pcr {
+			continue
+		}
+		h := sha256.New()
+		h.Write(value)
+		h.Write(e.Digest)
+		value = h.Sum(nil)
+	}
+	return value
+}
+
+// selection returns the PCR selection of the PCRs in the SHA-256 bank, in the
+// form the tpm2-tools take, such as sha256:0,1,13.
+func selection(pcrs []int) string {
+	s := make([]string, len(pcrs))
+	for i, pcr := range pcrs {
+		s[i] = strconv.Itoa(pcr)
+	}
+	return HashAlgorithm + ":" + strings.Join(s, ",")
+}
+
+// parsePCRRead parses the values of the SHA-256 bank's PCRs from the output of
+// tpm2_pcrread, which lists each bank followed by lines such as "13: 0x0A..".
+func parsePCRRead(out []byte) (map[int][]byte, error) {
+	values := make(map[int][]byte)
+	inBank := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ":") {
+			inBank = line == HashAlgorithm+":"
+			continue
+		}
+		if !inBank {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		pcr, err := strconv.Atoi(strings.TrimSpace(kv[0]))
+		if err != nil {
+			continue
+		}
+		value, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(kv[1]), "0x"))
+		if err != nil || len(value) != sha256.Size {
+			return nil, fmt.Errorf("invalid value for PCR %d: %q", pcr, kv[1])
+		}
+		values[pcr] = value
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no %s PCR values were read", HashAlgorithm)
+	}
+	return values, nil
+}
+
+// run runs the tpm2-tools command, returning its output in the error if it
+// fails.
+func run(command string, args ...string) error {
+	if b, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", command, err, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
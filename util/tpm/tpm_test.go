@@ -0,0 +1,65 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package tpm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParsePCRRead(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	zero := strings.Repeat("00", 32)
+	ones := strings.Repeat("FF", 32)
+	out := "  sha1:\n" +
+		"    0 : 0x" + strings.Repeat("AA", 20) + "\n" +
+		"  sha256:\n" +
+		"    0 : 0x" + ones + "\n" +
+		"    13: 0x" + zero + "\n"
+	values, err := parsePCRRead([]byte(out))
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(values), 2)
+	TestEqual(t, values[0], bytes.Repeat([]byte{0xff}, 32))
+	TestEqual(t, values[13], make([]byte, 32))
+
+	// a truncated value is refused
+	_, err = parsePCRRead([]byte("sha256:\n  1: 0xABCD\n"))
+	TestExpectError(t, err)
+
+	// as is output without the bank
+	_, err = parsePCRRead([]byte("sha1:\n  0: 0x" + strings.Repeat("AA", 20) + "\n"))
+	TestExpectError(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	a := sha256.Sum256([]byte("kurma"))
+	b := sha256.Sum256([]byte("config"))
+	events := []*Event{
+		{PCR: 13, Description: "binary", Digest: a[:]},
+		{PCR: 14, Description: "other", Digest: b[:]},
+		{PCR: 13, Description: "config", Digest: b[:]},
+	}
+
+	first := sha256.Sum256(append(make([]byte, 32), a[:]...))
+	second := sha256.Sum256(append(first[:], b[:]...))
+	TestEqual(t, Replay(13, events), second[:])
+
+	// a PCR nothing was measured into keeps its reset value
+	TestEqual(t, Replay(15, events), make([]byte, 32))
+}
+
+func TestSelection(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, selection([]int{0, 1, 13}), "sha256:0,1,13")
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// Package uevent receives the kernel's device events over netlink, and reads
+// the events of existing devices from sysfs so they can be replayed at boot.
+package uevent
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// netlinkKobjectUevent is the netlink protocol the kernel sends device
+	// events over.
+	netlinkKobjectUevent = 15
+
+	// kernelGroup is the multicast group of the events sent by the kernel,
+	// rather than those rebroadcast by udev.
+	kernelGroup = 1
+
+	// SysfsPath is where sysfs is mounted.
+	SysfsPath = "/sys"
+)
+
+// Event is a change to a device, such as it being added or removed. The
+// environment holds the event's properties, such as SUBSYSTEM, DEVNAME, MAJOR,
+// MINOR, and MODALIAS.
+type Event struct {
+	Action  string
+	DevPath string
+	Env     map[string]string
+}
+
+// Parse parses an event as it is sent by the kernel, which is a header of
+// ACTION@DEVPATH followed by KEY=VALUE properties, each terminated by a null
+// byte.
+func Parse(b []byte) (*Event, error) {
+	fields := bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0})
+	header := string(fields[0])
+	at := strings.Index(header, "@")
+	if at <= 0 {
+		return nil, fmt.Errorf("invalid event header %q", header)
+	}
+
+	e := &Event{
+		Action:  header[:at],
+		DevPath: header[at+1:],
+		Env:     make(map[string]string),
+	}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) == 2 {
+			e.Env[kv[0]] = kv[1]
+		}
+	}
+	if action := e.Env["ACTION"]; action != "" {
+		e.Action = action
+	}
+	if devpath := e.Env["DEVPATH"]; devpath != "" {
+		e.DevPath = devpath
+	}
+	return e, nil
+}
+
+// Listener receives the kernel's device events.
+type Listener struct {
+	fd int
+}
+
+// Listen opens a netlink socket receiving the kernel's device events.
+func Listen() (*Listener, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkKobjectUevent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the uevent socket: %v", err)
+	}
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: kernelGroup,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind the uevent socket: %v", err)
+	}
+	return &Listener{fd: fd}, nil
+}
+
+// Read waits for the next event. Messages which can't be parsed are skipped.
+func (l *Listener) Read() (*Event, error) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, _, err := syscall.Recvfrom(l.fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e, err := Parse(buf[:n]); err == nil {
+			return e, nil
+		}
+	}
+}
+
+// Close closes the socket.
+func (l *Listener) Close() error {
+	return syscall.Close(l.fd)
+}
+
+// Existing returns an add event for each device under /sys/devices, read from
+// its uevent file, so the devices present at boot can be handled the same way
+// as those which are hotplugged.
+func Existing() ([]*Event, error) {
+	var events []*Event
+	root := filepath.Join(SysfsPath, "devices")
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// devices may disappear while walking
+			return nil
+		}
+		if fi.IsDir() || fi.Name() != "uevent" {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		devpath := strings.TrimPrefix(filepath.Dir(path), SysfsPath)
+		events = append(events, parseUeventFile(devpath, b))
+		return nil
+	})
+	return events, err
+}
+
+// parseUeventFile returns an add event for the device from the contents of its
+// uevent file, which has a KEY=VALUE property on each line.
+func parseUeventFile(devpath string, b []byte) *Event {
+	e := &Event{
+		Action:  "add",
+		DevPath: devpath,
+		Env:     make(map[string]string),
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 {
+			e.Env[kv[0]] = kv[1]
+		}
+	}
+	return e
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package uevent
+
+import (
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParse(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	msg := "add@/devices/virtual/block/loop0\x00" +
+		"ACTION=add\x00" +
+		"DEVPATH=/devices/virtual/block/loop0\x00" +
+		"SUBSYSTEM=block\x00" +
+		"MAJOR=7\x00" +
+		"MINOR=0\x00" +
+		"DEVNAME=loop0\x00" +
+		"DEVTYPE=disk\x00" +
+		"SEQNUM=1234\x00"
+	e, err := Parse([]byte(msg))
+	TestExpectSuccess(t, err)
+	TestEqual(t, e.Action, "add")
+	TestEqual(t, e.DevPath, "/devices/virtual/block/loop0")
+	TestEqual(t, e.Env["SUBSYSTEM"], "block")
+	TestEqual(t, e.Env["MAJOR"], "7")
+	TestEqual(t, e.Env["DEVNAME"], "loop0")
+
+	_, err = Parse([]byte("libudev\x00garbage"))
+	TestExpectError(t, err)
+}
+
+func TestParseUeventFile(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	e := parseUeventFile("/devices/pci0000:00/0000:00:03.0",
+		[]byte("DRIVER=e1000\nPCI_CLASS=20000\nMODALIAS=pci:v00008086d0000100Esv00001AF4sd00001100bc02sc00i00\n"))
+	TestEqual(t, e.Action, "add")
+	TestEqual(t, e.DevPath, "/devices/pci0000:00/0000:00:03.0")
+	TestEqual(t, e.Env["DRIVER"], "e1000")
+	TestEqual(t, e.Env["MODALIAS"], "pci:v00008086d0000100Esv00001AF4sd00001100bc02sc00i00")
+}